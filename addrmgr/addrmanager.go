@@ -6,6 +6,7 @@
 package addrmgr
 
 import (
+	"bytes"
 	"container/list"
 	crand "crypto/rand" // for seeding
 	"encoding/base32"
@@ -17,6 +18,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -25,6 +27,7 @@ import (
 
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/wire"
+	"golang.org/x/crypto/sha3"
 )
 
 // AddrManager provides a concurrency safe address manager for caching potential
@@ -62,7 +65,7 @@ type serializedAddrManager struct {
 	Version      int
 	Key          [32]byte
 	Addresses    []*serializedKnownAddress
-	NewBuckets   [newBucketCount][]string // string is NetAddressKey
+	NewBuckets   [newBucketCount][]string // string is NetAddressV2Key
 	TriedBuckets [triedBucketCount][]string
 }
 
@@ -71,6 +74,13 @@ type localAddress struct {
 	score AddressPriority
 }
 
+// LocalAddressInfo describes a known local address which is advertised to
+// peers along with the priority of the method it was discovered by.
+type LocalAddressInfo struct {
+	NetAddress *wire.NetAddress
+	Score      AddressPriority
+}
+
 // AddressPriority type is used to describe the hierarchy of local address
 // discovery methods.
 type AddressPriority int
@@ -160,14 +170,14 @@ const (
 
 // updateAddress is a helper function to either update an address already known
 // to the address manager, or to add the address if not already known.
-func (a *AddrManager) updateAddress(netAddr, srcAddr *wire.NetAddress) {
+func (a *AddrManager) updateAddress(netAddr, srcAddr *wire.NetAddressV2) {
 	// Filter out non-routable addresses. Note that non-routable
 	// also includes invalid and local addresses.
-	if !IsRoutable(netAddr) {
+	if !IsRoutableV2(netAddr) {
 		return
 	}
 
-	addr := NetAddressKey(netAddr)
+	addr := NetAddressV2Key(netAddr)
 	ka := a.find(netAddr)
 	if ka != nil {
 		// TODO: only update addresses periodically.
@@ -205,8 +215,13 @@ func (a *AddrManager) updateAddress(netAddr, srcAddr *wire.NetAddress) {
 	} else {
 		// Make a copy of the net address to avoid races since it is
 		// updated elsewhere in the addrmanager code and would otherwise
-		// change the actual netaddress on the peer.
+		// change the actual netaddress on the peer.  IPv6 addresses
+		// which are IPv4-mapped or in the OnionCat range are stored in
+		// the network they belong to.
 		netAddrCopy := *netAddr
+		if legacy := netAddr.ToLegacy(); legacy != nil {
+			netAddrCopy = *wire.NewNetAddressV2FromLegacy(legacy)
+		}
 		ka = &KnownAddress{na: &netAddrCopy, srcAddr: srcAddr}
 		a.addrIndex[addr] = ka
 		a.nNew++
@@ -262,7 +277,7 @@ func (a *AddrManager) expireNew(bucket int) {
 	}
 
 	if oldest != nil {
-		key := NetAddressKey(oldest.na)
+		key := NetAddressV2Key(oldest.na)
 		log.Tracef("expiring oldest address %v", key)
 
 		delete(a.addrNew[bucket], key)
@@ -291,14 +306,14 @@ func (a *AddrManager) pickTried(bucket int) *list.Element {
 	return oldestElem
 }
 
-func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
+func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddressV2) int {
 	// bitcoind:
 	// doublesha256(key + sourcegroup + int64(doublesha256(key + group + sourcegroup))%bucket_per_source_group) % num_new_buckets
 
 	data1 := []byte{}
 	data1 = append(data1, a.key[:]...)
-	data1 = append(data1, []byte(GroupKey(netAddr))...)
-	data1 = append(data1, []byte(GroupKey(srcAddr))...)
+	data1 = append(data1, []byte(GroupKeyV2(netAddr))...)
+	data1 = append(data1, []byte(GroupKeyV2(srcAddr))...)
 	hash1 := chainhash.DoubleHashB(data1)
 	hash64 := binary.LittleEndian.Uint64(hash1)
 	hash64 %= newBucketsPerGroup
@@ -306,19 +321,19 @@ func (a *AddrManager) getNewBucket(netAddr, srcAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(srcAddr)...)
+	data2 = append(data2, GroupKeyV2(srcAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.DoubleHashB(data2)
 	return int(binary.LittleEndian.Uint64(hash2) % newBucketCount)
 }
 
-func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddress) int {
+func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddressV2) int {
 	// bitcoind hashes this as:
 	// doublesha256(key + group + truncate_to_64bits(doublesha256(key)) % buckets_per_group) % num_buckets
 	data1 := []byte{}
 	data1 = append(data1, a.key[:]...)
-	data1 = append(data1, []byte(NetAddressKey(netAddr))...)
+	data1 = append(data1, []byte(NetAddressV2Key(netAddr))...)
 	hash1 := chainhash.DoubleHashB(data1)
 	hash64 := binary.LittleEndian.Uint64(hash1)
 	hash64 %= triedBucketsPerGroup
@@ -326,7 +341,7 @@ func (a *AddrManager) getTriedBucket(netAddr *wire.NetAddress) int {
 	binary.LittleEndian.PutUint64(hashbuf[:], hash64)
 	data2 := []byte{}
 	data2 = append(data2, a.key[:]...)
-	data2 = append(data2, GroupKey(netAddr)...)
+	data2 = append(data2, GroupKeyV2(netAddr)...)
 	data2 = append(data2, hashbuf[:]...)
 
 	hash2 := chainhash.DoubleHashB(data2)
@@ -371,7 +386,7 @@ func (a *AddrManager) savePeers() {
 		ska := new(serializedKnownAddress)
 		ska.Addr = k
 		ska.TimeStamp = v.na.Timestamp.Unix()
-		ska.Src = NetAddressKey(v.srcAddr)
+		ska.Src = NetAddressV2Key(v.srcAddr)
 		ska.Attempts = v.attempts
 		ska.LastAttempt = v.lastattempt.Unix()
 		ska.LastSuccess = v.lastsuccess.Unix()
@@ -393,7 +408,7 @@ func (a *AddrManager) savePeers() {
 		j := 0
 		for e := a.addrTried[i].Front(); e != nil; e = e.Next() {
 			ka := e.Value.(*KnownAddress)
-			sam.TriedBuckets[i][j] = NetAddressKey(ka.na)
+			sam.TriedBuckets[i][j] = NetAddressV2Key(ka.na)
 			j++
 		}
 	}
@@ -459,12 +474,12 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 
 	for _, v := range sam.Addresses {
 		ka := new(KnownAddress)
-		ka.na, err = a.DeserializeNetAddress(v.Addr)
+		ka.na, err = a.deserializeNetAddressV2(v.Addr)
 		if err != nil {
 			return fmt.Errorf("failed to deserialize netaddress "+
 				"%s: %v", v.Addr, err)
 		}
-		ka.srcAddr, err = a.DeserializeNetAddress(v.Src)
+		ka.srcAddr, err = a.deserializeNetAddressV2(v.Src)
 		if err != nil {
 			return fmt.Errorf("failed to deserialize netaddress "+
 				"%s: %v", v.Src, err)
@@ -472,7 +487,7 @@ func (a *AddrManager) deserializePeers(filePath string) error {
 		ka.attempts = v.Attempts
 		ka.lastattempt = time.Unix(v.LastAttempt, 0)
 		ka.lastsuccess = time.Unix(v.LastSuccess, 0)
-		a.addrIndex[NetAddressKey(ka.na)] = ka
+		a.addrIndex[NetAddressV2Key(ka.na)] = ka
 	}
 
 	for i := range sam.NewBuckets {
@@ -534,6 +549,22 @@ func (a *AddrManager) DeserializeNetAddress(addr string) (*wire.NetAddress, erro
 	return a.HostToNetAddress(host, uint16(port), wire.SFNodeNetwork)
 }
 
+// deserializeNetAddressV2 converts a given address string, which may also be
+// the address of a network which does not fit in a wire.NetAddress, to a
+// *wire.NetAddressV2.
+func (a *AddrManager) deserializeNetAddressV2(addr string) (*wire.NetAddressV2, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.HostToNetAddressV2(host, uint16(port), wire.SFNodeNetwork)
+}
+
 // Start begins the core address handler which manages a pool of known
 // addresses, timeouts, and interval based writes.
 func (a *AddrManager) Start() {
@@ -573,6 +604,20 @@ func (a *AddrManager) AddAddresses(addrs []*wire.NetAddress, srcAddr *wire.NetAd
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
+	src := wire.NewNetAddressV2FromLegacy(srcAddr)
+	for _, na := range addrs {
+		a.updateAddress(wire.NewNetAddressV2FromLegacy(na), src)
+	}
+}
+
+// AddAddressesV2 adds new addresses of any network to the address manager.
+// Addresses of unknown networks are ignored.  It enforces a max number of
+// addresses and silently ignores duplicate addresses.  It is safe for
+// concurrent access.
+func (a *AddrManager) AddAddressesV2(addrs []*wire.NetAddressV2, srcAddr *wire.NetAddressV2) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
 	for _, na := range addrs {
 		a.updateAddress(na, srcAddr)
 	}
@@ -585,7 +630,8 @@ func (a *AddrManager) AddAddress(addr, srcAddr *wire.NetAddress) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
-	a.updateAddress(addr, srcAddr)
+	a.updateAddress(wire.NewNetAddressV2FromLegacy(addr),
+		wire.NewNetAddressV2FromLegacy(srcAddr))
 }
 
 // AddAddressByIP adds an address where we are given an ip:port and not a
@@ -632,21 +678,55 @@ func (a *AddrManager) NeedMoreAddresses() bool {
 	return a.numAddresses() < needAddressThreshold
 }
 
-// AddressCache returns the current address cache.  It must be treated as
-// read-only (but since it is a copy now, this is not as dangerous).
+// AddressCache returns the current address cache of the addresses which fit
+// in a wire.NetAddress.  It must be treated as read-only (but since it is a
+// copy now, this is not as dangerous).
 func (a *AddrManager) AddressCache() []*wire.NetAddress {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
-	addrIndexLen := len(a.addrIndex)
-	if addrIndexLen == 0 {
+	addrs := a.addressCache(func(na *wire.NetAddressV2) bool {
+		return na.Network == wire.NetIPv4 ||
+			na.Network == wire.NetIPv6 || na.Network == wire.NetTorV2
+	})
+	if addrs == nil {
 		return nil
 	}
+	legacyAddrs := make([]*wire.NetAddress, 0, len(addrs))
+	for _, na := range addrs {
+		legacyAddrs = append(legacyAddrs, na.ToLegacy())
+	}
+	return legacyAddrs
+}
+
+// AddressCacheV2 returns the current address cache of the addresses of all
+// networks.  It must be treated as read-only.
+func (a *AddrManager) AddressCacheV2() []*wire.NetAddressV2 {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	return a.addressCache(func(*wire.NetAddressV2) bool {
+		return true
+	})
+}
 
-	allAddr := make([]*wire.NetAddress, 0, addrIndexLen)
+// addressCache returns a random selection of the known addresses the passed
+// function returns true for, which is limited to getAddrPercent of them and
+// at most getAddrMax addresses.
+//
+// This function MUST be called with the address manager lock held.
+func (a *AddrManager) addressCache(include func(*wire.NetAddressV2) bool) []*wire.NetAddressV2 {
+	allAddr := make([]*wire.NetAddressV2, 0, len(a.addrIndex))
 	// Iteration order is undefined here, but we randomise it anyway.
 	for _, v := range a.addrIndex {
-		allAddr = append(allAddr, v.na)
+		if include(v.na) {
+			allAddr = append(allAddr, v.na)
+		}
+	}
+
+	addrIndexLen := len(allAddr)
+	if addrIndexLen == 0 {
+		return nil
 	}
 
 	numAddresses := addrIndexLen * getAddrPercent / 100
@@ -734,6 +814,120 @@ func NetAddressKey(na *wire.NetAddress) string {
 	return net.JoinHostPort(ipString(na), port)
 }
 
+// HostToNetAddressV2 returns a netaddress of any network given a host address.
+// Version 3 Tor .onion addresses, I2P .b32.i2p addresses and CJDNS addresses,
+// which are in the fc00::/8 range, are taken care of, and other hosts are
+// handled like HostToNetAddress does.
+func (a *AddrManager) HostToNetAddressV2(host string, port uint16, services wire.ServiceFlag) (*wire.NetAddressV2, error) {
+	now := time.Now()
+	switch {
+	// version 3 tor address is 56 char base32 + ".onion"
+	case len(host) == 62 && host[56:] == ".onion":
+		pubKey, err := decodeTorV3Host(host[:56])
+		if err != nil {
+			return nil, err
+		}
+		return wire.NewNetAddressV2(now, services, wire.NetTorV3,
+			pubKey, port), nil
+
+	// i2p address is 52 char unpadded base32 + ".b32.i2p"
+	case len(host) == 60 && host[52:] == ".b32.i2p":
+		hash, err := i2pEncoding.DecodeString(strings.ToUpper(host[:52]))
+		if err != nil {
+			return nil, err
+		}
+		return wire.NewNetAddressV2(now, services, wire.NetI2P, hash,
+			port), nil
+	}
+
+	na, err := a.HostToNetAddress(host, port, services)
+	if err != nil {
+		return nil, err
+	}
+	naV2 := wire.NewNetAddressV2FromLegacy(na)
+	if naV2.Network == wire.NetIPv6 && naV2.Addr[0] == 0xfc {
+		naV2.Network = wire.NetCJDNS
+	}
+	return naV2, nil
+}
+
+// i2pEncoding is the unpadded base32 encoding of the hashes of I2P
+// destinations in their addresses.
+var i2pEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// torV3Version is the version byte of version 3 Tor onion addresses.
+const torV3Version = 0x03
+
+// torV3Checksum returns the checksum of the version 3 onion address of the
+// passed public key.
+func torV3Checksum(pubKey []byte) []byte {
+	h := sha3.New256()
+	h.Write([]byte(".onion checksum"))
+	h.Write(pubKey)
+	h.Write([]byte{torV3Version})
+	return h.Sum(nil)[:2]
+}
+
+// decodeTorV3Host returns the public key of the passed version 3 onion address
+// without the .onion suffix, after checking its version and checksum.
+func decodeTorV3Host(host string) ([]byte, error) {
+	// go base32 encoding uses capitals (as does the rfc
+	// but tor and bitcoind tend to user lowercase, so we switch
+	// case here.
+	data, err := base32.StdEncoding.DecodeString(strings.ToUpper(host))
+	if err != nil {
+		return nil, err
+	}
+	pubKey, checksum, version := data[:32], data[32:34], data[34]
+	if version != torV3Version {
+		return nil, fmt.Errorf("unsupported onion address version %d",
+			version)
+	}
+	if !bytes.Equal(checksum, torV3Checksum(pubKey)) {
+		return nil, fmt.Errorf("invalid onion address checksum")
+	}
+	return pubKey, nil
+}
+
+// hostStringV2 returns a string for the address of the provided NetAddressV2,
+// which must belong to a network which does not fit in a wire.NetAddress.
+// Version 3 Tor and I2P addresses are transformed into their .onion and
+// .b32.i2p host names.
+func hostStringV2(na *wire.NetAddressV2) string {
+	switch na.Network {
+	case wire.NetTorV3:
+		data := make([]byte, 0, 35)
+		data = append(data, na.Addr...)
+		data = append(data, torV3Checksum(na.Addr)...)
+		data = append(data, torV3Version)
+		return strings.ToLower(base32.StdEncoding.EncodeToString(data)) +
+			".onion"
+
+	case wire.NetI2P:
+		return strings.ToLower(i2pEncoding.EncodeToString(na.Addr)) +
+			".b32.i2p"
+
+	case wire.NetCJDNS:
+		return net.IP(na.Addr).String()
+	}
+
+	return fmt.Sprintf("%d:%x", na.Network, na.Addr)
+}
+
+// NetAddressV2Key returns a string key for the provided NetAddressV2.  It is
+// the same as the key NetAddressKey returns for the addresses which fit in a
+// wire.NetAddress, while version 3 Tor and I2P addresses are keyed in the form
+// host:port with their .onion and .b32.i2p host names and CJDNS addresses in
+// the form [ip]:port.
+func NetAddressV2Key(na *wire.NetAddressV2) string {
+	if legacy := na.ToLegacy(); legacy != nil {
+		return NetAddressKey(legacy)
+	}
+	port := strconv.FormatUint(uint64(na.Port), 10)
+
+	return net.JoinHostPort(hostStringV2(na), port)
+}
+
 // GetAddress returns a single address that should be routable.  It picks a
 // random one from the possible addresses with preference given to ones that
 // have not been used recently and should not pick 'close' addresses
@@ -769,7 +963,7 @@ func (a *AddrManager) GetAddress() *KnownAddress {
 			randval := a.rand.Intn(large)
 			if float64(randval) < (factor * ka.chance() * float64(large)) {
 				log.Tracef("Selected %v from tried bucket",
-					NetAddressKey(ka.na))
+					NetAddressV2Key(ka.na))
 				return ka
 			}
 			factor *= 1.2
@@ -797,7 +991,7 @@ func (a *AddrManager) GetAddress() *KnownAddress {
 			randval := a.rand.Intn(large)
 			if float64(randval) < (factor * ka.chance() * float64(large)) {
 				log.Tracef("Selected %v from new bucket",
-					NetAddressKey(ka.na))
+					NetAddressV2Key(ka.na))
 				return ka
 			}
 			factor *= 1.2
@@ -805,13 +999,31 @@ func (a *AddrManager) GetAddress() *KnownAddress {
 	}
 }
 
-func (a *AddrManager) find(addr *wire.NetAddress) *KnownAddress {
-	return a.addrIndex[NetAddressKey(addr)]
+func (a *AddrManager) find(addr *wire.NetAddressV2) *KnownAddress {
+	return a.addrIndex[NetAddressV2Key(addr)]
+}
+
+// Services returns the services the passed address is known to advertise, or
+// zero when the address is unknown.
+func (a *AddrManager) Services(addr *wire.NetAddress) wire.ServiceFlag {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	ka := a.find(wire.NewNetAddressV2FromLegacy(addr))
+	if ka == nil {
+		return 0
+	}
+	return ka.na.Services
 }
 
 // Attempt increases the given address' attempt counter and updates
 // the last attempt time.
 func (a *AddrManager) Attempt(addr *wire.NetAddress) {
+	a.AttemptV2(wire.NewNetAddressV2FromLegacy(addr))
+}
+
+// AttemptV2 is like Attempt for the addresses of any network.
+func (a *AddrManager) AttemptV2(addr *wire.NetAddressV2) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
@@ -830,6 +1042,11 @@ func (a *AddrManager) Attempt(addr *wire.NetAddress) {
 // current time.  The address must already be known to AddrManager else it will
 // be ignored.
 func (a *AddrManager) Connected(addr *wire.NetAddress) {
+	a.ConnectedV2(wire.NewNetAddressV2FromLegacy(addr))
+}
+
+// ConnectedV2 is like Connected for the addresses of any network.
+func (a *AddrManager) ConnectedV2(addr *wire.NetAddressV2) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
@@ -853,6 +1070,11 @@ func (a *AddrManager) Connected(addr *wire.NetAddress) {
 // connection and version exchange.  If the address is unknown to the address
 // manager it will be ignored.
 func (a *AddrManager) Good(addr *wire.NetAddress) {
+	a.GoodV2(wire.NewNetAddressV2FromLegacy(addr))
+}
+
+// GoodV2 is like Good for the addresses of any network.
+func (a *AddrManager) GoodV2(addr *wire.NetAddressV2) {
 	a.mtx.Lock()
 	defer a.mtx.Unlock()
 
@@ -877,7 +1099,7 @@ func (a *AddrManager) Good(addr *wire.NetAddress) {
 
 	// remove from all new buckets.
 	// record one of the buckets in question and call it the `first'
-	addrKey := NetAddressKey(addr)
+	addrKey := NetAddressV2Key(addr)
 	oldBucket := -1
 	for i := range a.addrNew {
 		// we check for existence so we can record the first one
@@ -931,7 +1153,7 @@ func (a *AddrManager) Good(addr *wire.NetAddress) {
 	// something back.
 	a.nNew++
 
-	rmkey := NetAddressKey(rmka.na)
+	rmkey := NetAddressV2Key(rmka.na)
 	log.Tracef("Replacing %s with %s in tried", rmkey, addrKey)
 
 	// We made sure there is space here just above.
@@ -963,6 +1185,29 @@ func (a *AddrManager) AddLocalAddress(na *wire.NetAddress, priority AddressPrior
 	return nil
 }
 
+// LocalAddresses returns the known local addresses which are advertised to
+// peers, ordered by their keys.
+func (a *AddrManager) LocalAddresses() []LocalAddressInfo {
+	a.lamtx.Lock()
+	defer a.lamtx.Unlock()
+
+	keys := make([]string, 0, len(a.localAddresses))
+	for key := range a.localAddresses {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	addrs := make([]LocalAddressInfo, 0, len(keys))
+	for _, key := range keys {
+		la := a.localAddresses[key]
+		addrs = append(addrs, LocalAddressInfo{
+			NetAddress: la.na,
+			Score:      la.score,
+		})
+	}
+	return addrs
+}
+
 // getReachabilityFrom returns the relative reachability of the provided local
 // address to the provided remote address.
 func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
@@ -992,6 +1237,12 @@ func getReachabilityFrom(localAddr, remoteAddr *wire.NetAddress) int {
 		return Default
 	}
 
+	// Onion addresses are reachable through Tor from peers of any other
+	// network, but the addresses of their own network are preferred.
+	if IsOnionCatTor(localAddr) {
+		return Default
+	}
+
 	if IsRFC4380(remoteAddr) {
 		if !IsRoutable(localAddr) {
 			return Default
@@ -209,6 +209,46 @@ func TestAddLocalAddress(t *testing.T) {
 	}
 }
 
+func TestLocalAddresses(t *testing.T) {
+	amgr := addrmgr.New("testlocaladdresses", nil)
+	if addrs := amgr.LocalAddresses(); len(addrs) != 0 {
+		t.Fatalf("LocalAddresses: got %d addresses, want 0", len(addrs))
+	}
+
+	// Unroutable addresses are not added, and adding an address again
+	// with a higher priority raises its score.
+	amgr.AddLocalAddress(&wire.NetAddress{IP: net.ParseIP("204.124.1.1"),
+		Port: 7979}, addrmgr.InterfacePrio)
+	amgr.AddLocalAddress(&wire.NetAddress{IP: net.ParseIP("192.168.0.100"),
+		Port: 7979}, addrmgr.ManualPrio)
+	amgr.AddLocalAddress(&wire.NetAddress{IP: net.ParseIP("2620:100::1"),
+		Port: 7979}, addrmgr.BoundPrio)
+	amgr.AddLocalAddress(&wire.NetAddress{IP: net.ParseIP("204.124.1.1"),
+		Port: 7979}, addrmgr.BoundPrio)
+
+	want := []struct {
+		ip    string
+		score addrmgr.AddressPriority
+	}{
+		{"204.124.1.1", addrmgr.BoundPrio + 1},
+		{"2620:100::1", addrmgr.BoundPrio},
+	}
+	addrs := amgr.LocalAddresses()
+	if len(addrs) != len(want) {
+		t.Fatalf("LocalAddresses: got %d addresses, want %d",
+			len(addrs), len(want))
+	}
+	for i, addr := range addrs {
+		if !addr.NetAddress.IP.Equal(net.ParseIP(want[i].ip)) ||
+			addr.Score != want[i].score {
+
+			t.Errorf("LocalAddresses #%d: got %s with score %d, "+
+				"want %s with score %d", i, addr.NetAddress.IP,
+				addr.Score, want[i].ip, want[i].score)
+		}
+	}
+}
+
 func TestAttempt(t *testing.T) {
 	n := addrmgr.New("testattempt", lookupFunc)
 
@@ -251,6 +291,29 @@ func TestConnected(t *testing.T) {
 	}
 }
 
+// TestServices ensures the services of known addresses are returned, and none
+// for unknown addresses.
+func TestServices(t *testing.T) {
+	n := addrmgr.New("testservices", lookupFunc)
+
+	na := wire.NewNetAddressIPPort(net.ParseIP("173.194.115.66"), 8333,
+		wire.SFNodeNetwork|wire.SFNodeTLS)
+	srcAddr := wire.NewNetAddressIPPort(net.ParseIP("173.144.173.111"),
+		8333, 0)
+	n.AddAddress(na, srcAddr)
+
+	if got := n.Services(na); got != wire.SFNodeNetwork|wire.SFNodeTLS {
+		t.Errorf("Services: got %v, want %v", got,
+			wire.SFNodeNetwork|wire.SFNodeTLS)
+	}
+	unknown := wire.NewNetAddressIPPort(net.ParseIP("173.194.115.67"),
+		8333, 0)
+	if got := n.Services(unknown); got != 0 {
+		t.Errorf("Services: got %v for an unknown address, want none",
+			got)
+	}
+}
+
 func TestNeedMoreAddresses(t *testing.T) {
 	n := addrmgr.New("testneedmoreaddresses", lookupFunc)
 	addrsToAdd := 1500
@@ -458,6 +521,58 @@ func TestGetBestLocalAddress(t *testing.T) {
 	*/
 }
 
+// TestGetBestLocalAddressOnion ensures onion addresses are advertised to peers
+// of other networks when there is no local address of their network, and
+// always to onion peers.
+func TestGetBestLocalAddressOnion(t *testing.T) {
+	onion := wire.NetAddress{IP: net.ParseIP("fd87:d87e:eb43:25::1")}
+	public := wire.NetAddress{IP: net.ParseIP("204.124.8.100")}
+
+	tests := []struct {
+		name       string
+		localAddrs []wire.NetAddress
+		remoteAddr wire.NetAddress
+		want       net.IP
+	}{
+		{
+			name:       "public IPv4 peer, onion only",
+			localAddrs: []wire.NetAddress{onion},
+			remoteAddr: wire.NetAddress{IP: net.ParseIP("204.124.8.1")},
+			want:       onion.IP,
+		},
+		{
+			name:       "public IPv6 peer, onion only",
+			localAddrs: []wire.NetAddress{onion},
+			remoteAddr: wire.NetAddress{IP: net.ParseIP("2602:100:abcd::102")},
+			want:       onion.IP,
+		},
+		{
+			name:       "public IPv4 peer, onion and public IPv4",
+			localAddrs: []wire.NetAddress{onion, public},
+			remoteAddr: wire.NetAddress{IP: net.ParseIP("204.124.8.1")},
+			want:       public.IP,
+		},
+		{
+			name:       "onion peer, onion and public IPv4",
+			localAddrs: []wire.NetAddress{onion, public},
+			remoteAddr: wire.NetAddress{IP: net.ParseIP("fd87:d87e:eb43::100")},
+			want:       onion.IP,
+		},
+	}
+
+	for _, test := range tests {
+		amgr := addrmgr.New("testgetbestlocaladdressonion", nil)
+		for i := range test.localAddrs {
+			amgr.AddLocalAddress(&test.localAddrs[i], addrmgr.ManualPrio)
+		}
+		got := amgr.GetBestLocalAddress(&test.remoteAddr)
+		if !test.want.Equal(got.IP) {
+			t.Errorf("%s: got %s, want %s", test.name, got.IP,
+				test.want)
+		}
+	}
+}
+
 func TestNetAddressKey(t *testing.T) {
 	addNaTests()
 
@@ -471,3 +586,125 @@ func TestNetAddressKey(t *testing.T) {
 	}
 
 }
+
+// TestHostToNetAddressV2 ensures the hosts of networks which do not fit in a
+// wire.NetAddress are converted to addresses of their network and back to the
+// same keys, and invalid version 3 onion addresses are rejected.
+func TestHostToNetAddressV2(t *testing.T) {
+	n := addrmgr.New("testhosttonetaddressv2", lookupFunc)
+
+	tests := []struct {
+		host    string
+		network wire.NetworkID
+		key     string
+	}{
+		{
+			host:    someIP,
+			network: wire.NetIPv4,
+			key:     someIP + ":8333",
+		},
+		{
+			host:    "fd87:d87e:eb43:25de:b744:916d:e5c7:3e1a",
+			network: wire.NetTorV2,
+			key:     "explorernxs4opq2.onion:8333",
+		},
+		{
+			host:    "pg6mmjiyjmcrsslvykfwnntlaru7p5svn6y2ymmju6nubxndf4pscryd.onion",
+			network: wire.NetTorV3,
+			key:     "pg6mmjiyjmcrsslvykfwnntlaru7p5svn6y2ymmju6nubxndf4pscryd.onion:8333",
+		},
+		{
+			host:    "ukeu3k5oycgaauneqgtnvselmt4yemvoilkln7jpvamvfx7dnkdq.b32.i2p",
+			network: wire.NetI2P,
+			key:     "ukeu3k5oycgaauneqgtnvselmt4yemvoilkln7jpvamvfx7dnkdq.b32.i2p:8333",
+		},
+		{
+			host:    "fc32:17ea:e415:c3bf:9808:149d:b5a2:c9aa",
+			network: wire.NetCJDNS,
+			key:     "[fc32:17ea:e415:c3bf:9808:149d:b5a2:c9aa]:8333",
+		},
+	}
+
+	for i, test := range tests {
+		na, err := n.HostToNetAddressV2(test.host, 8333,
+			wire.SFNodeNetwork)
+		if err != nil {
+			t.Errorf("HostToNetAddressV2 #%d: unexpected error: %v", i,
+				err)
+			continue
+		}
+		if na.Network != test.network {
+			t.Errorf("HostToNetAddressV2 #%d: got network %v, want %v",
+				i, na.Network, test.network)
+		}
+		if key := addrmgr.NetAddressV2Key(na); key != test.key {
+			t.Errorf("NetAddressV2Key #%d: got %s, want %s", i, key,
+				test.key)
+		}
+	}
+
+	// The checksum of version 3 onion addresses must be valid.
+	_, err := n.HostToNetAddressV2("pg6mmjiyjmcrsslvykfwnntlaru7p5svn6y2"+
+		"ymmju6nubxndf4pscrya.onion", 8333, wire.SFNodeNetwork)
+	if err == nil {
+		t.Errorf("HostToNetAddressV2: expected error for invalid " +
+			"onion address checksum")
+	}
+}
+
+// TestAddressCacheV2 ensures addresses which do not fit in a wire.NetAddress
+// are kept by the address manager and only returned in the address cache of
+// all networks.
+func TestAddressCacheV2(t *testing.T) {
+	n := addrmgr.New("testaddresscachev2", lookupFunc)
+
+	torV3, err := n.HostToNetAddressV2("pg6mmjiyjmcrsslvykfwnntlaru7p5sv"+
+		"n6y2ymmju6nubxndf4pscryd.onion", 8333, wire.SFNodeNetwork)
+	if err != nil {
+		t.Fatalf("HostToNetAddressV2: unexpected error: %v", err)
+	}
+	srcAddr := wire.NewNetAddressV2FromLegacy(wire.NewNetAddressIPPort(
+		net.IPv4(173, 144, 173, 111), 8333, 0))
+
+	// Only a part of the known addresses is shared, so add enough of them
+	// for any address to be shared.
+	addrs := []*wire.NetAddressV2{torV3}
+	for i := 0; i < 9; i++ {
+		na := wire.NewNetAddressV2(time.Now(), wire.SFNodeNetwork,
+			wire.NetIPv4, []byte{173, 194, 115, byte(66 + i)}, 8333)
+		addrs = append(addrs, na)
+	}
+	n.AddAddressesV2(addrs, srcAddr)
+	if got := n.NumAddresses(); got != 10 {
+		t.Fatalf("NumAddresses: got %d, want 10", got)
+	}
+
+	for i := 0; i < 20; i++ {
+		for _, na := range n.AddressCache() {
+			if na.IP.To4() == nil {
+				t.Fatalf("AddressCache: got address %v which "+
+					"is not IPv4", na.IP)
+			}
+		}
+	}
+
+	found := false
+	for i := 0; i < 100 && !found; i++ {
+		for _, na := range n.AddressCacheV2() {
+			found = found || na.Network == wire.NetTorV3
+		}
+	}
+	if !found {
+		t.Errorf("AddressCacheV2: version 3 onion address not returned")
+	}
+
+	n.GoodV2(torV3)
+	ka := n.GetAddress()
+	for ka.NetAddressV2().Network != wire.NetTorV3 {
+		ka = n.GetAddress()
+	}
+	if ka.NetAddress() != nil {
+		t.Errorf("NetAddress: got %v for a version 3 onion address, "+
+			"want nil", ka.NetAddress())
+	}
+}
@@ -30,7 +30,9 @@ drastically reduces the chances an attacker is able to coerce your peer into
 only connecting to nodes they control.
 
 The address manager also understands routability and tor addresses and tries
-hard to only return routable addresses.  In addition, it uses the information
+hard to only return routable addresses.  Besides the addresses carried by addr
+messages, it keeps the addresses of version 3 Tor onion services, I2P and CJDNS
+which are only carried by the addrv2 messages of BIP0155.  In addition, it uses the information
 provided by the caller about connected, known good, and attempted addresses to
 periodically purge peers which no longer appear to be good peers as well as
 bias the selection toward known good peers.  The general idea is to make a best
@@ -280,3 +280,43 @@ func GroupKey(na *wire.NetAddress) string {
 
 	return na.IP.Mask(net.CIDRMask(bits, 128)).String()
 }
+
+// IsRoutableV2 returns whether or not the passed address is routable.  The
+// addresses of the networks which fit in a wire.NetAddress are routable when
+// IsRoutable is true for them, the addresses of version 3 Tor onion services
+// and I2P are always routable, and CJDNS addresses are routable when they are
+// in the fc00::/8 range.  The addresses of unknown networks are not routable.
+func IsRoutableV2(na *wire.NetAddressV2) bool {
+	if legacy := na.ToLegacy(); legacy != nil {
+		return IsRoutable(legacy)
+	}
+	switch na.Network {
+	case wire.NetTorV3, wire.NetI2P:
+		return len(na.Addr) == 32
+	case wire.NetCJDNS:
+		return len(na.Addr) == 16 && na.Addr[0] == 0xfc
+	}
+	return false
+}
+
+// GroupKeyV2 returns a string representing the network group an address is
+// part of.  This is the same as GroupKey for the addresses of the networks
+// which fit in a wire.NetAddress, and the strings "torv3:key", "i2p:key" and
+// "cjdns:key" where key is the /4 of the public key, destination hash or
+// address after the fc prefix for version 3 Tor onion service, I2P and CJDNS
+// addresses.
+func GroupKeyV2(na *wire.NetAddressV2) string {
+	if legacy := na.ToLegacy(); legacy != nil {
+		return GroupKey(legacy)
+	}
+	if !IsRoutableV2(na) {
+		return "unroutable"
+	}
+	switch na.Network {
+	case wire.NetTorV3:
+		return fmt.Sprintf("torv3:%d", na.Addr[0]&((1<<4)-1))
+	case wire.NetI2P:
+		return fmt.Sprintf("i2p:%d", na.Addr[0]&((1<<4)-1))
+	}
+	return fmt.Sprintf("cjdns:%d", na.Addr[1]&((1<<4)-1))
+}
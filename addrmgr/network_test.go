@@ -8,6 +8,7 @@ package addrmgr_test
 import (
 	"net"
 	"testing"
+	"time"
 
 	"github.com/bitgo/prova/addrmgr"
 	"github.com/bitgo/prova/wire"
@@ -201,3 +202,49 @@ func TestGroupKey(t *testing.T) {
 		}
 	}
 }
+
+// TestGroupKeyV2 ensures the group keys of addresses which do not fit in a
+// wire.NetAddress are keyed by their network, and the group keys of the other
+// addresses are the same as returned by GroupKey.
+func TestGroupKeyV2(t *testing.T) {
+	torV3 := make([]byte, 32)
+	torV3[0] = 0x25
+	i2p := make([]byte, 32)
+	i2p[0] = 0x13
+	cjdns := net.ParseIP("fc32::1")
+	privateIPv6 := net.ParseIP("fd00::1")
+
+	tests := []struct {
+		name     string
+		network  wire.NetworkID
+		addr     []byte
+		routable bool
+		expected string
+	}{
+		{"ipv4", wire.NetIPv4, []byte{12, 1, 2, 3}, true, "12.1.0.0"},
+		{"tor v2", wire.NetTorV2, []byte{0x12, 0x34, 0, 0, 0, 0, 0, 0, 0,
+			0}, true, "tor:2"},
+		{"tor v3", wire.NetTorV3, torV3, true, "torv3:5"},
+		{"i2p", wire.NetI2P, i2p, true, "i2p:3"},
+		{"cjdns", wire.NetCJDNS, cjdns, true, "cjdns:2"},
+		{"cjdns outside fc00::/8", wire.NetCJDNS, privateIPv6, false,
+			"unroutable"},
+		{"unknown network", wire.NetworkID(0x20), []byte{1}, false,
+			"unroutable"},
+	}
+
+	for i, test := range tests {
+		na := wire.NewNetAddressV2(time.Now(), wire.SFNodeNetwork,
+			test.network, test.addr, 8333)
+		if routable := addrmgr.IsRoutableV2(na); routable != test.routable {
+			t.Errorf("TestGroupKeyV2 #%d (%s): unexpected "+
+				"routability - got %v, want %v", i, test.name,
+				routable, test.routable)
+		}
+		if key := addrmgr.GroupKeyV2(na); key != test.expected {
+			t.Errorf("TestGroupKeyV2 #%d (%s): unexpected group key "+
+				"- got '%s', want '%s'", i, test.name,
+				key, test.expected)
+		}
+	}
+}
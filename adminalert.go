@@ -0,0 +1,82 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/mempool"
+)
+
+// adminAlertWebhookTimeout is the maximum amount of time to wait for the admin
+// alert webhook to respond.
+const adminAlertWebhookTimeout = time.Second * 10
+
+// adminAlertClient is the HTTP client used to post admin alerts to the
+// webhook.
+var adminAlertClient = &http.Client{Timeout: adminAlertWebhookTimeout}
+
+// adminAlertNtfn returns the adminalert notification describing the passed
+// alert.  The same JSON object is posted to the admin alert webhook.
+func adminAlertNtfn(alert *mempool.AdminAlert) *btcjson.AdminAlertNtfn {
+	var pendingTxHash *string
+	if alert.PendingTx != nil {
+		pendingTxHash = btcjson.String(alert.PendingTx.Hash().String())
+	}
+	return btcjson.NewAdminAlertNtfn(alert.Type.String(),
+		alert.Tx.Hash().String(), adminThreadNames[alert.Thread],
+		alert.Reason, pendingTxHash)
+}
+
+// postAdminAlert posts the passed notification as a JSON object to the passed
+// webhook URL.
+func postAdminAlert(url string, ntfn *btcjson.AdminAlertNtfn) error {
+	body, err := json.Marshal(ntfn)
+	if err != nil {
+		return err
+	}
+	resp, err := adminAlertClient.Post(url, "application/json",
+		bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %q",
+			resp.Status)
+	}
+	return nil
+}
+
+// handleAdminAlert is invoked by the memory pool with the alerts raised by
+// admin transactions which compete with the pending admin transactions or
+// carry out unusual operations.  The alert is logged, sent to the websocket
+// clients which registered for admin transaction notifications, and posted to
+// the admin alert webhook when one is configured.
+//
+// This function is invoked with the mempool lock held, so it must not block.
+func (s *server) handleAdminAlert(alert *mempool.AdminAlert) {
+	srvrLog.Warnf("Admin alert (%v): %s", alert.Type, alert.Reason)
+
+	if s.rpcServer != nil {
+		s.rpcServer.ntfnMgr.NotifyAdminAlert(alert)
+	}
+
+	if cfg.AdminAlertWebhook == "" {
+		return
+	}
+	ntfn := adminAlertNtfn(alert)
+	go func() {
+		if err := postAdminAlert(cfg.AdminAlertWebhook, ntfn); err != nil {
+			srvrLog.Errorf("Failed to post admin alert for "+
+				"transaction %v to webhook: %v", ntfn.TxID, err)
+		}
+	}()
+}
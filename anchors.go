@@ -0,0 +1,71 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	// anchorsFileName is the name of the file in the data directory the
+	// addresses of the block relay only peers are saved to on shutdown.
+	anchorsFileName = "anchors.json"
+
+	// maxAnchors is the maximum number of anchors saved on shutdown and
+	// reconnected to on startup.
+	maxAnchors = 2
+)
+
+// saveAnchors writes the passed addresses of block relay only peers to the
+// anchors file at path, keeping at most maxAnchors of them.  The file is
+// replaced atomically, so a failure leaves the previously saved anchors
+// intact.
+func saveAnchors(path string, addrs []string) error {
+	if len(addrs) > maxAnchors {
+		addrs = addrs[:maxAnchors]
+	}
+	serialized, err := json.Marshal(addrs)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, serialized, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// loadAnchors returns the addresses saved to the anchors file at path and
+// removes the file, so the anchors are only reconnected to once even when the
+// node is unable to save them again on the next shutdown.  A missing file is
+// not an error and results in no anchors.
+func loadAnchors(path string) ([]string, error) {
+	serialized, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+
+	var addrs []string
+	if err := json.Unmarshal(serialized, &addrs); err != nil {
+		return nil, err
+	}
+	if len(addrs) > maxAnchors {
+		addrs = addrs[:maxAnchors]
+	}
+	return addrs, nil
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestAnchors ensures anchors are limited to maxAnchors when saved, loaded
+// back once and removed from the data directory when loaded.
+func TestAnchors(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "anchors")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, anchorsFileName)
+
+	// A missing file results in no anchors.
+	addrs, err := loadAnchors(path)
+	if err != nil || addrs != nil {
+		t.Fatalf("loadAnchors: got %v, %v, want no anchors", addrs, err)
+	}
+
+	saved := []string{"1.2.3.4:7979", "[2001:db8::1]:7979",
+		"5.6.7.8:7979"}
+	if err := saveAnchors(path, saved); err != nil {
+		t.Fatalf("saveAnchors: unexpected error: %v", err)
+	}
+	addrs, err = loadAnchors(path)
+	if err != nil {
+		t.Fatalf("loadAnchors: unexpected error: %v", err)
+	}
+	if want := saved[:maxAnchors]; !reflect.DeepEqual(addrs, want) {
+		t.Fatalf("loadAnchors: got %v, want %v", addrs, want)
+	}
+
+	// The anchors are only loaded once.
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("anchors file still exists after loading")
+	}
+	addrs, err = loadAnchors(path)
+	if err != nil || addrs != nil {
+		t.Fatalf("loadAnchors: got %v, %v, want no anchors", addrs, err)
+	}
+
+	// Malformed files are rejected.
+	if err := ioutil.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatalf("WriteFile: unexpected error: %v", err)
+	}
+	if _, err := loadAnchors(path); err == nil {
+		t.Fatalf("loadAnchors: unexpected success for malformed file")
+	}
+}
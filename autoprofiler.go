@@ -0,0 +1,235 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// autoProfileCheckInterval is the interval at which the memory use and
+	// the number of goroutines are checked against the thresholds.
+	autoProfileCheckInterval = 10 * time.Second
+
+	// autoProfileCooldown is the minimum time between two captures.  It
+	// keeps a lasting resource pressure from filling the disk with
+	// captures of the same condition.
+	autoProfileCooldown = 10 * time.Minute
+
+	// autoProfileCPUDuration is the duration of the CPU profile of each
+	// capture.
+	autoProfileCPUDuration = 30 * time.Second
+
+	// autoProfileTimeFormat is the format of the time each capture
+	// directory name starts with.  It sorts in chronological order.
+	autoProfileTimeFormat = "20060102-150405"
+)
+
+// autoProfiler captures heap, goroutine, and CPU profiles to disk when the
+// memory use, the number of goroutines, or the time to process a block exceeds
+// the configured thresholds.  Each capture is written to a directory of its
+// own, named after the time and the reason of the capture, and only the most
+// recent captures are retained.
+type autoProfiler struct {
+	dir                string
+	heapThreshold      uint64        // Bytes, 0 disables
+	goroutineThreshold int           // 0 disables
+	blockLatency       time.Duration // 0 disables
+	keep               int
+	cpuDuration        time.Duration
+
+	slowBlocks  chan string
+	lastCapture time.Time
+}
+
+// newAutoProfiler returns a new profiler which writes captures to the passed
+// directory and retains the passed number of captures.  Thresholds which are
+// zero are disabled.
+func newAutoProfiler(dir string, heapThreshold uint64, goroutineThreshold int,
+	blockLatency time.Duration, keep int) *autoProfiler {
+
+	return &autoProfiler{
+		dir:                dir,
+		heapThreshold:      heapThreshold,
+		goroutineThreshold: goroutineThreshold,
+		blockLatency:       blockLatency,
+		keep:               keep,
+		cpuDuration:        autoProfileCPUDuration,
+		slowBlocks:         make(chan string, 1),
+	}
+}
+
+// BlockProcessed notifies the profiler of the time it took to process the
+// passed block so a capture is triggered when it exceeds the block latency
+// threshold.  It never blocks.
+//
+// This function is safe for concurrent access.
+func (p *autoProfiler) BlockProcessed(desc string, duration time.Duration) {
+	if p.blockLatency == 0 || duration <= p.blockLatency {
+		return
+	}
+
+	select {
+	case p.slowBlocks <- desc + " took " + duration.String():
+	default:
+	}
+}
+
+// pressure returns the reason to capture profiles along with a description of
+// the condition when the current memory use or number of goroutines exceeds its
+// threshold.  It returns empty strings otherwise.
+func (p *autoProfiler) pressure() (string, string) {
+	if p.goroutineThreshold > 0 {
+		if n := runtime.NumGoroutine(); n > p.goroutineThreshold {
+			return "goroutines", fmt.Sprintf("%d goroutines", n)
+		}
+	}
+	if p.heapThreshold > 0 {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		if stats.HeapInuse > p.heapThreshold {
+			return "heap", fmt.Sprintf("%d MiB heap in use",
+				stats.HeapInuse/(1024*1024))
+		}
+	}
+	return "", ""
+}
+
+// writeProfile writes the named runtime profile to the passed file.
+func writeProfile(name, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := pprof.Lookup(name).WriteTo(f, 0); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// capture writes the heap and goroutine profiles followed by a CPU profile to
+// a new capture directory and removes the oldest captures beyond the number
+// to retain.  The CPU profile is cut short when the quit channel is closed.
+func (p *autoProfiler) capture(reason string, quit <-chan struct{}) error {
+	now := time.Now()
+	dir := filepath.Join(p.dir, now.Format(autoProfileTimeFormat)+"-"+reason)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if err := writeProfile("heap", filepath.Join(dir, "heap.pprof")); err != nil {
+		return err
+	}
+	err := writeProfile("goroutine", filepath.Join(dir, "goroutine.pprof"))
+	if err != nil {
+		return err
+	}
+
+	// CPU profiling fails when it is already running, for example due to
+	// the cpuprofile option, in which case the capture goes without.
+	f, err := os.Create(filepath.Join(dir, "cpu.pprof"))
+	if err != nil {
+		return err
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		srvrLog.Warnf("Unable to capture CPU profile: %v", err)
+	} else {
+		timer := time.NewTimer(p.cpuDuration)
+		select {
+		case <-timer.C:
+		case <-quit:
+			timer.Stop()
+		}
+		pprof.StopCPUProfile()
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	srvrLog.Infof("Captured profiles to %s", dir)
+	return p.prune()
+}
+
+// prune removes the oldest capture directories beyond the number of captures
+// to retain.  Entries which are not named like capture directories are left
+// alone.
+func (p *autoProfiler) prune() error {
+	entries, err := ioutil.ReadDir(p.dir)
+	if err != nil {
+		return err
+	}
+	var captures []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || len(name) <= len(autoProfileTimeFormat) ||
+			!strings.HasPrefix(name[len(autoProfileTimeFormat):], "-") {
+			continue
+		}
+		_, err := time.Parse(autoProfileTimeFormat,
+			name[:len(autoProfileTimeFormat)])
+		if err != nil {
+			continue
+		}
+		captures = append(captures, name)
+	}
+	if len(captures) <= p.keep {
+		return nil
+	}
+
+	sort.Strings(captures)
+	for _, name := range captures[:len(captures)-p.keep] {
+		if err := os.RemoveAll(filepath.Join(p.dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handler checks the thresholds until the quit channel is closed and captures
+// profiles when any of them is exceeded.  It must be run as a goroutine.
+func (p *autoProfiler) handler(quit <-chan struct{}) {
+	ticker := time.NewTicker(autoProfileCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		var reason, desc string
+		select {
+		case <-ticker.C:
+			reason, desc = p.pressure()
+			if reason == "" {
+				continue
+			}
+
+		case desc = <-p.slowBlocks:
+			reason = "slowblock"
+
+		case <-quit:
+			return
+		}
+
+		if time.Since(p.lastCapture) < autoProfileCooldown {
+			srvrLog.Debugf("Skipping profile capture (%s) during "+
+				"cooldown", desc)
+			continue
+		}
+		p.lastCapture = time.Now()
+
+		srvrLog.Warnf("Resource pressure detected (%s) -- capturing "+
+			"profiles", desc)
+		if err := p.capture(reason, quit); err != nil {
+			srvrLog.Errorf("Unable to capture profiles: %v", err)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestAutoProfilerCapture ensures captures contain the profiles and that only
+// the most recent captures are retained.
+func TestAutoProfilerCapture(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "prova")
+	if err != nil {
+		t.Fatalf("Failed creating a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Create older captures along with an unrelated directory which must
+	// survive the pruning.
+	for _, name := range []string{"20170101-000000-heap",
+		"20170102-000000-slowblock", "notes"} {
+
+		if err := os.Mkdir(filepath.Join(dir, name), 0700); err != nil {
+			t.Fatalf("Mkdir: unexpected error: %v", err)
+		}
+	}
+
+	p := newAutoProfiler(dir, 0, 1, 0, 2)
+	p.cpuDuration = time.Millisecond
+	if reason, _ := p.pressure(); reason != "goroutines" {
+		t.Fatalf("unexpected pressure reason %q", reason)
+	}
+	if err := p.capture("goroutines", nil); err != nil {
+		t.Fatalf("capture: unexpected error: %v", err)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: unexpected error: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if len(names) != 3 || names[0] != "20170102-000000-slowblock" ||
+		names[2] != "notes" {
+		t.Fatalf("unexpected entries after pruning: %v", names)
+	}
+
+	// The heap and goroutine profiles are always written while the CPU
+	// profile is skipped when another test is profiling the CPU.
+	for _, profile := range []string{"heap.pprof", "goroutine.pprof"} {
+		path := filepath.Join(dir, names[1], profile)
+		if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+			t.Fatalf("missing profile %s: %v", profile, err)
+		}
+	}
+}
+
+// TestAutoProfilerBlockProcessed ensures only blocks exceeding the latency
+// threshold trigger a capture.
+func TestAutoProfilerBlockProcessed(t *testing.T) {
+	t.Parallel()
+
+	p := newAutoProfiler("", 0, 0, time.Second, 1)
+	p.BlockProcessed("block 1", time.Millisecond)
+	select {
+	case desc := <-p.slowBlocks:
+		t.Fatalf("unexpected slow block %q", desc)
+	default:
+	}
+
+	p.BlockProcessed("block 2", 2*time.Second)
+	p.BlockProcessed("block 3", 3*time.Second)
+	if desc := <-p.slowBlocks; desc != "block 2 took 2s" {
+		t.Fatalf("unexpected slow block %q", desc)
+	}
+}
@@ -6,6 +6,8 @@
 package blockchain
 
 import (
+	"time"
+
 	"github.com/bitgo/prova/database"
 	"github.com/bitgo/prova/provautil"
 )
@@ -37,7 +39,9 @@ func (b *BlockChain) maybeAcceptBlock(block *provautil.Block, flags BehaviorFlag
 
 	// The block must pass all of the validation rules which depend on the
 	// position of the block within the block chain.
+	contextStart := time.Now()
 	err = b.checkBlockContext(block, prevNode, flags)
+	b.timer.since(PhaseHeaderChecks, contextStart)
 	if err != nil {
 		return false, err
 	}
@@ -71,7 +75,11 @@ func (b *BlockChain) maybeAcceptBlock(block *provautil.Block, flags BehaviorFlag
 	// Connect the passed block to the chain while respecting proper chain
 	// selection according to the chain with the most proof of work.  This
 	// also handles validation of the transaction scripts.
+	oldBest := b.bestNode
 	isMainChain, err := b.connectBestChain(newNode, block, flags)
+	if !dryRun {
+		b.updateChainTips(newNode, oldBest, err)
+	}
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,116 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"time"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+// BlockStats houses aggregate statistics about the transactions of a main chain
+// block as returned by BlockStats.  All values are in atoms.
+type BlockStats struct {
+	Height    uint32
+	Hash      chainhash.Hash
+	Timestamp time.Time
+	Size      int
+
+	// NumTxns includes the coinbase transaction, while NumInputs does not
+	// count its input since it does not spend anything.
+	NumTxns      int
+	NumInputs    int
+	NumOutputs   int
+	NumAdminTxns int
+
+	// TotalFees is the sum of the fees paid by the transactions, computed
+	// the same way as when the block was validated, so issue thread
+	// transactions which create coins do not pay negative fees.
+	TotalFees int64
+
+	// TotalOut is the total value of the outputs of the transactions other
+	// than the coinbase.
+	TotalOut int64
+
+	// AvgTxSize is the average serialized size of the transactions other
+	// than the coinbase.  It is zero when the block only holds the
+	// coinbase.
+	AvgTxSize int
+}
+
+// BlockStats returns aggregate statistics about the transactions of the main
+// chain block at the passed height.  The values of the outputs spent by the
+// block are loaded from the spend journal, so the block data must not have been
+// pruned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BlockStats(height uint32) (*BlockStats, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	var block *provautil.Block
+	var spentAmounts []int64
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		block, err = dbFetchBlockByHeight(dbTx, height)
+		if err != nil {
+			return err
+		}
+		spentAmounts, err = dbFetchSpentAmounts(dbTx, block)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	msgBlock := block.MsgBlock()
+	stats := &BlockStats{
+		Height:    height,
+		Hash:      *block.Hash(),
+		Timestamp: msgBlock.Header.Timestamp,
+		Size:      msgBlock.SerializeSize(),
+		NumTxns:   len(msgBlock.Transactions),
+	}
+	var spentIdx, totalTxSize int
+	for i, tx := range block.Transactions() {
+		msgTx := tx.MsgTx()
+		stats.NumOutputs += len(msgTx.TxOut)
+		threadInt, _ := txscript.GetAdminDetails(tx)
+		if threadInt >= 0 {
+			stats.NumAdminTxns++
+		}
+		if i == 0 {
+			continue
+		}
+
+		var totalIn, totalOut int64
+		for range msgTx.TxIn {
+			totalIn += spentAmounts[spentIdx]
+			spentIdx++
+		}
+		for _, txOut := range msgTx.TxOut {
+			totalOut += txOut.Value
+		}
+		fee := totalIn - totalOut
+		if fee < 0 && threadInt >= 0 &&
+			provautil.ThreadID(threadInt) == provautil.IssueThread {
+
+			fee = 0
+		}
+
+		stats.NumInputs += len(msgTx.TxIn)
+		stats.TotalFees += fee
+		stats.TotalOut += totalOut
+		totalTxSize += msgTx.SerializeSize()
+	}
+	if stats.NumTxns > 1 {
+		stats.AvgTxSize = totalTxSize / (stats.NumTxns - 1)
+	}
+
+	return stats, nil
+}
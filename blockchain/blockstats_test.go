@@ -0,0 +1,121 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestBlockStats ensures the statistics of the main chain blocks of a chain
+// which processed the blocks generated by the fullblocktests package match
+// those computed by tracking the values of the outputs the blocks create.
+func TestBlockStats(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("blockstats",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	for _, test := range tests {
+		for _, item := range test {
+			var block *provautil.Block
+			switch item := item.(type) {
+			case fullblocktests.AcceptedBlock:
+				block = provautil.NewBlock(item.Block)
+			case fullblocktests.RejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			case fullblocktests.OrphanOrRejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			default:
+				continue
+			}
+			chain.ProcessBlock(block, blockchain.BFNone)
+		}
+	}
+	best := chain.BestSnapshot()
+
+	var numInputs, adminTxns int
+	values := make(map[wire.OutPoint]int64)
+	for height := uint32(0); height <= best.Height; height++ {
+		block, err := chain.BlockByHeight(height)
+		if err != nil {
+			t.Fatalf("BlockByHeight: %v", err)
+		}
+
+		want := blockchain.BlockStats{
+			Height:    height,
+			Hash:      *block.Hash(),
+			Timestamp: block.MsgBlock().Header.Timestamp,
+			Size:      block.MsgBlock().SerializeSize(),
+			NumTxns:   len(block.Transactions()),
+		}
+		var totalTxSize int
+		for i, tx := range block.Transactions() {
+			msgTx := tx.MsgTx()
+			var totalIn, totalOut int64
+			for _, txIn := range msgTx.TxIn {
+				totalIn += values[txIn.PreviousOutPoint]
+			}
+			for j, txOut := range msgTx.TxOut {
+				values[wire.OutPoint{Hash: *tx.Hash(),
+					Index: uint32(j)}] = txOut.Value
+				totalOut += txOut.Value
+			}
+			want.NumOutputs += len(msgTx.TxOut)
+			threadInt, _ := txscript.GetAdminDetails(tx)
+			if threadInt >= 0 {
+				want.NumAdminTxns++
+			}
+			if i == 0 {
+				continue
+			}
+
+			want.NumInputs += len(msgTx.TxIn)
+			if totalIn > totalOut {
+				want.TotalFees += totalIn - totalOut
+			}
+			want.TotalOut += totalOut
+			totalTxSize += msgTx.SerializeSize()
+		}
+		if want.NumTxns > 1 {
+			want.AvgTxSize = totalTxSize / (want.NumTxns - 1)
+		}
+
+		stats, err := chain.BlockStats(height)
+		if err != nil {
+			t.Fatalf("BlockStats(%d): %v", height, err)
+		}
+		if *stats != want {
+			t.Fatalf("BlockStats(%d): got %+v, want %+v", height,
+				stats, want)
+		}
+		numInputs += stats.NumInputs
+		adminTxns += stats.NumAdminTxns
+	}
+	if numInputs == 0 {
+		t.Fatal("no spent outputs were tested")
+	}
+	if adminTxns == 0 {
+		t.Fatal("no admin transactions were tested")
+	}
+
+	if _, err := chain.BlockStats(best.Height + 1); err == nil {
+		t.Fatal("BlockStats: no error for height after the end of the " +
+			"main chain")
+	}
+}
@@ -192,6 +192,7 @@ type BestState struct {
 	NumTxns    uint64          // The number of txns in the block.
 	TotalTxns  uint64          // The total number of txns in the chain.
 	MedianTime time.Time       // Median time as per CalcPastMedianTime.
+	WorkSum    *big.Int        // The total work of the chain up to the block.
 }
 
 // newBestState returns a new best stats instance for the given parameters.
@@ -205,6 +206,7 @@ func newBestState(node *blockNode, blockSize, numTxns, totalTxns uint64,
 		NumTxns:    numTxns,
 		TotalTxns:  totalTxns,
 		MedianTime: medianTime,
+		WorkSum:    new(big.Int).Set(node.workSum),
 	}
 }
 
@@ -225,6 +227,10 @@ type BlockChain struct {
 	sigCache            *txscript.SigCache
 	hashCache           *txscript.HashCache
 	indexManager        IndexManager
+	pruneDepth          uint32
+	archiveDepth        uint32
+	scriptWorkers       int
+	disableCheckpoints  bool
 
 	// The following fields are calculated based upon the provided chain
 	// parameters.  They are also set when the instance is created and
@@ -254,6 +260,17 @@ type BlockChain struct {
 	index    map[chainhash.Hash]*blockNode
 	depNodes map[chainhash.Hash][]*blockNode
 
+	// deploymentCaches caches the threshold states of the windows of
+	// blocks for each of the defined deployments.  They are protected by
+	// the chain lock.
+	deploymentCaches []thresholdStateCache
+
+	// validateWindow houses the number of blocks signed by each validate
+	// key among the blocks of the averaging window ending at the best
+	// node, which count towards its rate limit.  It is nil when the counts
+	// need to be tallied from the block index again.
+	validateWindow map[wire.BlockValidatingPubKey]int
+
 	// These fields are related to the admin state of the chain. They are
 	// protected by the chain lock.
 
@@ -277,10 +294,19 @@ type BlockChain struct {
 	prevOrphans  map[chainhash.Hash][]*orphanBlock
 	oldestOrphan *orphanBlock
 
+	// timer accumulates the time spent in each validation phase of the
+	// blocks being connected.  It has its own mutex.
+	timer validationTimer
+
+	// subscribers houses the subscriptions registered with Subscribe.  It
+	// is protected by the subscribers lock.
+	subscribersLock sync.RWMutex
+	subscribers     map[*Subscription]struct{}
+
 	// These fields are related to checkpoint handling.  They are protected
 	// by the chain lock.
-	nextCheckpoint  *chaincfg.Checkpoint
-	checkpointBlock *provautil.Block
+	nextCheckpoint   *chaincfg.Checkpoint
+	checkpointHeader *wire.BlockHeader
 
 	// The state is used as a fairly efficient way to cache information
 	// about the current best chain state that is returned to callers when
@@ -734,15 +760,18 @@ func (b *BlockChain) CalcSequenceLock(tx *provautil.Tx, utxoView *UtxoViewpoint,
 	b.chainLock.Lock()
 	defer b.chainLock.Unlock()
 
-	return b.calcSequenceLock(tx, utxoView, mempool)
+	return b.calcSequenceLock(b.bestNode, tx, utxoView, mempool)
 }
 
 // calcSequenceLock computes the relative lock-times for the passed
-// transaction. See the exported version, CalcSequenceLock for further details.
+// transaction from the point of view of the passed block node, which is either
+// the block including the transaction or, for transactions in the mempool,
+// the end of the main chain. See the exported version, CalcSequenceLock for
+// further details.
 //
 // This function MUST be called with the chain state lock held (for writes).
-func (b *BlockChain) calcSequenceLock(tx *provautil.Tx, utxoView *UtxoViewpoint,
-	mempool bool) (*SequenceLock, error) {
+func (b *BlockChain) calcSequenceLock(node *blockNode, tx *provautil.Tx,
+	utxoView *UtxoViewpoint, mempool bool) (*SequenceLock, error) {
 
 	mTx := tx.MsgTx()
 
@@ -753,21 +782,31 @@ func (b *BlockChain) calcSequenceLock(tx *provautil.Tx, utxoView *UtxoViewpoint,
 	// activated.
 	sequenceLock := &SequenceLock{Seconds: -1, BlockHeight: -1}
 
-	// If the transaction's version is less than 2, and BIP 68 has not yet
-	// been activated then sequence locks are disabled. Additionally,
-	// sequence locks don't apply to coinbase transactions Therefore, we
-	// return sequence lock values of -1 indicating that this transaction
-	// can be included within a block at any given height or time.
-	// TODO(roasbeef): check version bits state or pass as param
-	// * true should be replaced with a version bits state check
-	sequenceLockActive := mTx.Version >= 2 && (mempool || true)
+	// Grab the next height to use for inputs present in the mempool.
+	nextHeight := node.height + 1
+
+	// If the transaction's version is less than 2, or the timelock
+	// deployment is not active yet for blocks, then sequence locks are
+	// disabled. The mempool always enforces them. Additionally, sequence
+	// locks don't apply to coinbase transactions Therefore, we return
+	// sequence lock values of -1 indicating that this transaction can be
+	// included within a block at any given height or time.
+	sequenceLockActive := mTx.Version >= 2 && mempool
+	if mTx.Version >= 2 && !mempool {
+		prevNode, err := b.getPrevNodeFromNode(node)
+		if err != nil {
+			return sequenceLock, err
+		}
+		sequenceLockActive, err = b.isDeploymentActive(prevNode,
+			chaincfg.DeploymentTimelocks)
+		if err != nil {
+			return sequenceLock, err
+		}
+	}
 	if !sequenceLockActive || IsCoinBase(tx) {
 		return sequenceLock, nil
 	}
 
-	// Grab the next height to use for inputs present in the mempool.
-	nextHeight := b.BestSnapshot().Height + 1
-
 	for txInIndex, txIn := range mTx.TxIn {
 		utxo := utxoView.LookupEntry(&txIn.PreviousOutPoint.Hash)
 		if utxo == nil {
@@ -804,8 +843,8 @@ func (b *BlockChain) calcSequenceLock(tx *provautil.Tx, utxoView *UtxoViewpoint,
 			// compute the past median time for the block prior to
 			// the one which included this referenced output.
 			// TODO: caching should be added to keep this speedy
-			inputDepth := uint32(b.bestNode.height-inputHeight) + 1
-			blockNode, err := b.relativeNode(b.bestNode, inputDepth)
+			inputDepth := uint32(node.height-inputHeight) + 1
+			blockNode, err := b.relativeNode(node, inputDepth)
 			if err != nil {
 				return sequenceLock, err
 			}
@@ -968,6 +1007,8 @@ func (b *BlockChain) connectBlock(node *blockNode, block *provautil.Block, utxoV
 	state := newBestState(node, blockSize, numTxns, curTotalTxns+numTxns,
 		medianTime)
 	// Atomically insert info into the database.
+	commitStart := time.Now()
+	var indexTime time.Duration
 	err = b.db.Update(func(dbTx database.Tx) error {
 		// Update best block state.
 		err := dbPutBestState(dbTx, state, node.workSum)
@@ -997,6 +1038,32 @@ func (b *BlockChain) connectBlock(node *blockNode, block *provautil.Block, utxoV
 			return err
 		}
 
+		// Record the changes the block makes to the admin key sets in
+		// their history.
+		err = dbPutKeySetHistory(dbTx, block)
+		if err != nil {
+			return err
+		}
+
+		// Record the token totals after the block when it issues or
+		// destroys tokens.
+		err = dbPutIssuanceHistory(dbTx, block)
+		if err != nil {
+			return err
+		}
+
+		// Register the key IDs provisioned and revoked by the block.
+		err = dbConnectKeyIDRegistry(dbTx, block)
+		if err != nil {
+			return err
+		}
+
+		// Record the block as the last one signed by its validate key.
+		err = dbConnectValidatorLastBlock(dbTx, node)
+		if err != nil {
+			return err
+		}
+
 		// Update the transaction spend journal by adding a record for
 		// the block that contains all txos spent by it.
 		err = dbPutSpendJournalEntry(dbTx, block.Hash(), stxos)
@@ -1008,17 +1075,33 @@ func (b *BlockChain) connectBlock(node *blockNode, block *provautil.Block, utxoV
 		// optional indexes with the block being connected so they can
 		// update themselves accordingly.
 		if b.indexManager != nil {
+			indexStart := time.Now()
 			err := b.indexManager.ConnectBlock(dbTx, block, utxoView)
+			indexTime = time.Since(indexStart)
 			if err != nil {
 				return err
 			}
 		}
 
-		return nil
+		// Remove the data of the blocks which are now outside of the
+		// retention window when pruning is enabled.
+		if err := b.pruneBlocks(dbTx, node.height); err != nil {
+			return err
+		}
+
+		// Move the data of the blocks which are now deep enough in the
+		// chain to the cold storage of the database when archiving is
+		// enabled.
+		return b.archiveBlocks(dbTx, node.height)
 	})
 	if err != nil {
 		return err
 	}
+	if b.indexManager != nil {
+		b.timer.add(PhaseIndexUpdate, indexTime)
+	}
+	b.timer.add(PhaseDBCommit, time.Since(commitStart)-indexTime)
+	b.timer.connected(node.hash, node.height)
 
 	// Prune fully spent entries and mark all entries in the view unmodified
 	// now that the modifications have been committed to the database.
@@ -1032,6 +1115,7 @@ func (b *BlockChain) connectBlock(node *blockNode, block *provautil.Block, utxoV
 
 	// This node is now the end of the best chain.
 	b.bestNode = node
+	b.updateValidateWindow(node, true)
 
 	// This is now the admin state of the best chain.
 	b.stateLock.Lock()
@@ -1053,7 +1137,12 @@ func (b *BlockChain) connectBlock(node *blockNode, block *provautil.Block, utxoV
 
 	// Notify the caller that the block was connected to the main chain.
 	// The caller would typically want to react with actions such as
-	// updating wallets.
+	// updating wallets.  The subscribers are notified while the chain
+	// lock is still held so they see the events in the order they happen.
+	b.notifySubscribers(&ChainEvent{
+		Type:  ChainEventBlockConnected,
+		Block: block,
+	})
 	b.chainLock.Unlock()
 	b.sendNotification(NTBlockConnected, block)
 	b.chainLock.Lock()
@@ -1116,12 +1205,31 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *provautil.Block, ut
 			return err
 		}
 
-		// Store the current admin key sets in the database.
+		// Store the current admin key sets in the database and remove
+		// the changes the block made to them, to the token totals, to
+		// the key ID registry, and to the last blocks of the validate
+		// keys.
 		err = dbPutKeySet(dbTx, keyView.Keys(), keyView.KeyIDs(),
 			keyView.ThreadTips(), keyView.LastKeyID(), keyView.TotalSupply())
 		if err != nil {
 			return err
 		}
+		err = dbRemoveKeySetHistory(dbTx, node.height)
+		if err != nil {
+			return err
+		}
+		err = dbRemoveIssuanceHistory(dbTx, node.height)
+		if err != nil {
+			return err
+		}
+		err = dbDisconnectKeyIDRegistry(dbTx, block)
+		if err != nil {
+			return err
+		}
+		err = dbDisconnectValidatorLastBlock(dbTx, node)
+		if err != nil {
+			return err
+		}
 
 		// Remove the block hash and height from the block index which
 		// tracks the main chain.
@@ -1170,6 +1278,7 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *provautil.Block, ut
 
 	// This node's parent is now the end of the best chain.
 	b.bestNode = node.parent
+	b.updateValidateWindow(node, false)
 
 	// Update the state for the best block.  Notice how this replaces the
 	// entire struct instead of updating the existing one.  This effectively
@@ -1182,7 +1291,12 @@ func (b *BlockChain) disconnectBlock(node *blockNode, block *provautil.Block, ut
 
 	// Notify the caller that the block was disconnected from the main
 	// chain.  The caller would typically want to react with actions such as
-	// updating wallets.
+	// updating wallets.  The subscribers are notified while the chain lock
+	// is still held so they see the events in the order they happen.
+	b.notifySubscribers(&ChainEvent{
+		Type:  ChainEventBlockDisconnected,
+		Block: block,
+	})
 	b.chainLock.Unlock()
 	b.sendNotification(NTBlockDisconnected, block)
 	b.chainLock.Lock()
@@ -1324,7 +1438,8 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List, flags
 		// thus will not be generated.  This is done because the state
 		// is not being immediately written to the database, so it is
 		// not needed.
-		err = b.checkConnectBlock(n, block, utxoView, keyView, nil)
+		err = b.checkConnectBlock(n, block, utxoView, keyView, nil,
+			BFNone)
 		if err != nil {
 			return err
 		}
@@ -1336,6 +1451,27 @@ func (b *BlockChain) reorganizeChain(detachNodes, attachNodes *list.List, flags
 		return nil
 	}
 
+	// Let the subscribers know the chain is about to be reorganized before
+	// the blocks are disconnected.
+	oldTip := detachNodes.Front().Value.(*blockNode)
+	newTip := attachNodes.Back().Value.(*blockNode)
+	commonAncestor, err := b.getPrevNodeFromNode(detachNodes.Back().Value.(*blockNode))
+	if err != nil {
+		return err
+	}
+	b.notifySubscribers(&ChainEvent{
+		Type: ChainEventReorg,
+		Reorg: &ChainReorg{
+			OldHash:              *oldTip.hash,
+			OldHeight:            oldTip.height,
+			NewHash:              *newTip.hash,
+			NewHeight:            newTip.height,
+			CommonAncestor:       *commonAncestor.hash,
+			CommonAncestorHeight: commonAncestor.height,
+			Depth:                uint32(detachNodes.Len()),
+		},
+	})
+
 	// Reset the view for the actual connection code below.  This is
 	// required because the view was previously modified when checking if
 	// the reorg would be successful and the connection code requires the
@@ -1458,7 +1594,8 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *provautil.Block, f
 		keyView.SetKeyIDs(b.aspKeyIdMap)
 		stxos := make([]spentTxOut, 0, countSpentOutputs(block))
 		if !fastAdd {
-			err := b.checkConnectBlock(node, block, utxoView, keyView, &stxos)
+			err := b.checkConnectBlock(node, block, utxoView,
+				keyView, &stxos, BFNone)
 			if err != nil {
 				return false, err
 			}
@@ -1474,6 +1611,7 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *provautil.Block, f
 		// utxos, spend them, and add the new utxos being created by
 		// this block.
 		if fastAdd {
+			utxoStart := time.Now()
 			err := utxoView.fetchInputUtxos(b.db, block)
 			if err != nil {
 				return false, err
@@ -1483,6 +1621,7 @@ func (b *BlockChain) connectBestChain(node *blockNode, block *provautil.Block, f
 				return false, err
 			}
 			keyView.connectTransactions(block)
+			b.timer.since(PhaseUtxoUpdate, utxoStart)
 		}
 
 		// Connect the block to the main chain.
@@ -1615,6 +1754,22 @@ func (b *BlockChain) BestSnapshot() *BestState {
 	return snapshot
 }
 
+// BackupDB writes a consistent copy of the database of the chain to a new
+// database at the passed path while the chain remains in use, and returns the
+// best chain state the copy houses.  Blocks are not processed while the copy is
+// written, so it is a copy of the chain as of the returned best state.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) BackupDB(dst string) (*BestState, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	if err := b.db.Backup(dst); err != nil {
+		return nil, err
+	}
+	return b.BestSnapshot(), nil
+}
+
 // ThreadTips returns information about the best chain block's unspent admin
 // transaction outputs.  These outputs are not consensus critical for the
 // chain, they are redundant to the checked utxos in the utxoview.
@@ -1717,6 +1872,29 @@ type Config struct {
 	// checkpoints.
 	Checkpoints []chaincfg.Checkpoint
 
+	// CheckpointFile is the path to a checkpoint file with additional
+	// checkpoints to load, which must be signed by one of CheckpointKeys.
+	// Its checkpoints are merged with Checkpoints and take precedence over
+	// those at the same height.  See WriteCheckpointFile for details on
+	// the format.
+	//
+	// This field can be empty if the caller does not wish to load a
+	// checkpoint file.
+	CheckpointFile string
+
+	// CheckpointKeys are the keys trusted to sign the checkpoint file.
+	//
+	// This field is required when CheckpointFile is set.
+	CheckpointKeys []*btcec.PublicKey
+
+	// DisableCheckpoints skips the verification of the checkpoints, so
+	// blocks which do not match them are accepted.  On the regression test
+	// and simulation test networks, it also lifts the restrictions based
+	// on the previous checkpoint, so blocks which fork the main chain
+	// before it are accepted.  This allows tests to rebuild historical
+	// forks without changing the chain parameters.
+	DisableCheckpoints bool
+
 	// TimeSource defines the median time source to use for things such as
 	// block processing and determining whether or not the chain is current.
 	//
@@ -1759,6 +1937,32 @@ type Config struct {
 	// This field can be nil if the caller does not wish to make use of an
 	// index manager.
 	IndexManager IndexManager
+
+	// PruneDepth is the number of the most recent main chain blocks whose
+	// data is retained.  The data of older blocks is removed from the
+	// database as new blocks are connected, while their headers as well as
+	// the utxo set and the admin state are kept.  It must be at least
+	// MinPruneDepth.
+	//
+	// This field can be zero if the caller does not wish to prune blocks.
+	PruneDepth uint32
+
+	// ArchiveDepth is the number of the most recent main chain blocks whose
+	// data is kept in the primary storage of the database.  The data of
+	// older blocks is moved to the cold storage of the database, if it has
+	// one, as new blocks are connected, and remains available from it.
+	//
+	// This field can be zero if the caller does not wish to archive
+	// blocks.
+	ArchiveDepth uint32
+
+	// ScriptWorkers is the number of goroutines used to validate the
+	// scripts of the transactions in a block.  The inputs of all of the
+	// transactions are validated in batches which span transactions, so
+	// a block with many small transactions still keeps all of them busy.
+	//
+	// This field can be zero to use DefaultScriptWorkers.
+	ScriptWorkers int
 }
 
 // New returns a BlockChain instance using the provided configuration details.
@@ -1773,15 +1977,42 @@ func New(config *Config) (*BlockChain, error) {
 	if config.TimeSource == nil {
 		return nil, AssertError("blockchain.New timesource is nil")
 	}
+	if config.PruneDepth != 0 && config.PruneDepth < MinPruneDepth {
+		return nil, AssertError("blockchain.New prune depth is below " +
+			"the minimum")
+	}
+	if config.ScriptWorkers < 0 {
+		return nil, AssertError("blockchain.New script workers is " +
+			"negative")
+	}
+
+	// Merge the checkpoints of the checkpoint file, if any, with the
+	// provided checkpoints.
+	checkpoints := config.Checkpoints
+	if config.CheckpointFile != "" {
+		if len(config.CheckpointKeys) == 0 {
+			return nil, AssertError("blockchain.New checkpoint " +
+				"file without trusted keys")
+		}
+		fileCheckpoints, err := LoadCheckpointFile(config.CheckpointFile,
+			config.CheckpointKeys)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load checkpoint file "+
+				"%s: %v", config.CheckpointFile, err)
+		}
+		checkpoints = MergeCheckpoints(checkpoints, fileCheckpoints)
+		log.Infof("Loaded %d checkpoints from %s", len(fileCheckpoints),
+			config.CheckpointFile)
+	}
 
 	// Generate a checkpoint by height map from the provided checkpoints
 	// and assert the provided checkpoints are sorted by height as required.
 	var checkpointsByHeight map[uint32]*chaincfg.Checkpoint
 	var prevCheckpointHeight uint32
-	if len(config.Checkpoints) > 0 {
+	if len(checkpoints) > 0 {
 		checkpointsByHeight = make(map[uint32]*chaincfg.Checkpoint)
-		for i := range config.Checkpoints {
-			checkpoint := &config.Checkpoints[i]
+		for i := range checkpoints {
+			checkpoint := &checkpoints[i]
 			if checkpoint.Height <= prevCheckpointHeight {
 				return nil, AssertError("blockchain.New " +
 					"checkpoints are not sorted by height")
@@ -1792,7 +2023,7 @@ func New(config *Config) (*BlockChain, error) {
 	}
 
 	b := BlockChain{
-		checkpoints:         config.Checkpoints,
+		checkpoints:         checkpoints,
 		checkpointsByHeight: checkpointsByHeight,
 		db:                  config.DB,
 		chainParams:         config.ChainParams,
@@ -1801,6 +2032,10 @@ func New(config *Config) (*BlockChain, error) {
 		sigCache:            config.SigCache,
 		hashCache:           config.HashCache,
 		indexManager:        config.IndexManager,
+		pruneDepth:          config.PruneDepth,
+		archiveDepth:        config.ArchiveDepth,
+		scriptWorkers:       config.ScriptWorkers,
+		disableCheckpoints:  config.DisableCheckpoints,
 		blocksPerRetarget:   int32(config.ChainParams.PowAveragingWindow),
 		minMemoryNodes:      int32(config.ChainParams.PowAveragingWindow),
 		bestNode:            nil,
@@ -1811,8 +2046,10 @@ func New(config *Config) (*BlockChain, error) {
 		aspKeyIdMap:         make(map[btcec.KeyID]*btcec.PublicKey),
 		index:               make(map[chainhash.Hash]*blockNode),
 		depNodes:            make(map[chainhash.Hash][]*blockNode),
+		deploymentCaches:    newThresholdCaches(chaincfg.DefinedDeployments),
 		orphans:             make(map[chainhash.Hash]*orphanBlock),
 		prevOrphans:         make(map[chainhash.Hash][]*orphanBlock),
+		subscribers:         make(map[*Subscription]struct{}),
 	}
 
 	// Initialize the chain state from the passed database.  When the db
@@ -1822,6 +2059,32 @@ func New(config *Config) (*BlockChain, error) {
 		return nil, err
 	}
 
+	// Create the admin key set history when the database predates it.
+	if err := b.initKeySetHistory(); err != nil {
+		return nil, err
+	}
+
+	// Create the issuance history when the database predates it.
+	if err := b.initIssuanceHistory(); err != nil {
+		return nil, err
+	}
+
+	// Create the key ID registry when the database predates it.
+	if err := b.initKeyIDRegistry(); err != nil {
+		return nil, err
+	}
+
+	// Track the last blocks of the validate keys when the database
+	// predates them.
+	if err := b.initValidatorLastBlocks(); err != nil {
+		return nil, err
+	}
+
+	// Rebuild the chain state from the stored blocks when it was reset.
+	if err := b.maybeFinishReindex(); err != nil {
+		return nil, err
+	}
+
 	// Initialize and catch up all of the currently active optional indexes
 	// as needed.
 	if config.IndexManager != nil {
@@ -44,6 +44,10 @@ var (
 	// admin key sets.
 	keySetBucketName = []byte("keyset")
 
+	// chainTipsBucketName is the name of the db bucket used to house the
+	// tips of the branches which fork from the main chain.
+	chainTipsBucketName = []byte("chaintips")
+
 	// byteOrder is the preferred byte order used for serializing numeric
 	// fields for storage in the database.
 	byteOrder = binary.LittleEndian
@@ -81,6 +85,13 @@ func isDeserializeErr(err error) bool {
 	return ok
 }
 
+// isDbBlockPrunedErr returns whether or not the passed error is a
+// database.Error with an error code of database.ErrBlockPruned.
+func isDbBlockPrunedErr(err error) bool {
+	dbErr, ok := err.(database.Error)
+	return ok && dbErr.ErrorCode == database.ErrBlockPruned
+}
+
 // -----------------------------------------------------------------------------
 // The transaction spend journal consists of an entry for each block connected
 // to the main chain which contains the transaction outputs the block spends
@@ -436,6 +447,53 @@ func dbFetchSpendJournalEntry(dbTx database.Tx, block *provautil.Block, view *Ut
 	return stxos, nil
 }
 
+// dbFetchSpentAmounts fetches the spend journal entry for the passed block and
+// returns the amounts of the txouts spent by its transactions in the order they
+// are spent.  Unlike dbFetchSpendJournalEntry, no view is needed since the
+// version of the containing transaction does not affect how the amount of a
+// spent txout is decoded.
+func dbFetchSpentAmounts(dbTx database.Tx, block *provautil.Block) ([]int64, error) {
+	// Exclude the coinbase transaction since it can't spend anything.
+	var numStxos int
+	for _, tx := range block.MsgBlock().Transactions[1:] {
+		numStxos += len(tx.TxIn)
+	}
+
+	// The stxos are serialized in reverse order, so fill in the amounts
+	// from the end.
+	spendBucket := dbTx.Metadata().Bucket(spendJournalBucketName)
+	serialized := spendBucket.Get(block.Hash()[:])
+	amounts := make([]int64, numStxos)
+	offset := 0
+	for i := numStxos - 1; i > -1; i-- {
+		if offset >= len(serialized) {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt spend "+
+					"information for %v: missing stxos",
+					block.Hash()),
+			}
+		}
+
+		// Any non-zero version may be used for the stxos which do not
+		// encode the version of their containing transaction.
+		var stxo spentTxOut
+		n, err := decodeSpentTxOut(serialized[offset:], &stxo, 1)
+		offset += n
+		if err != nil {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt spend "+
+					"information for %v: %v", block.Hash(),
+					err),
+			}
+		}
+		amounts[i] = int64(decompressTxOutAmount(uint64(stxo.amount)))
+	}
+
+	return amounts, nil
+}
+
 // dbPutSpendJournalEntry uses an existing database transaction to update the
 // spend journal entry for the given block hash using the provided slice of
 // spent txouts.   The spent txouts slice must contain an entry for every txout
@@ -1267,9 +1325,82 @@ func dbPutBestState(dbTx database.Tx, snapshot *BestState, workSum *big.Int) err
 	return dbTx.Metadata().Put(chainStateKeyName, serializedData)
 }
 
+// dbCheckBestChainState uses an existing database transaction to ensure the
+// passed best chain state refers to the end of the main chain in the block
+// index.
+func dbCheckBestChainState(dbTx database.Tx, state *bestChainState) error {
+	hash, err := dbFetchHashByHeight(dbTx, state.height)
+	if err != nil || *hash != state.hash {
+		return database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("best chain state block %v is "+
+				"not at height %d of the main chain", state.hash,
+				state.height),
+		}
+	}
+	if _, err := dbFetchHashByHeight(dbTx, state.height+1); err == nil {
+		return database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("best chain state block %v is "+
+				"not the end of the main chain", state.hash),
+		}
+	}
+	return nil
+}
+
+// dbRebuildBestChainState uses an existing database transaction to rebuild the
+// best chain state from the block index and the headers and transaction
+// counts of all blocks in the main chain.  This requires the data of all blocks
+// in the main chain, so it fails when blocks have been pruned.
+func dbRebuildBestChainState(dbTx database.Tx) (bestChainState, error) {
+	// The highest height in the block index is the end of the main chain.
+	var tipHeight uint32
+	heightIndex := dbTx.Metadata().Bucket(heightIndexBucketName)
+	err := heightIndex.ForEach(func(k, _ []byte) error {
+		if len(k) == 4 && byteOrder.Uint32(k) > tipHeight {
+			tipHeight = byteOrder.Uint32(k)
+		}
+		return nil
+	})
+	if err != nil {
+		return bestChainState{}, err
+	}
+
+	state := bestChainState{height: tipHeight, workSum: new(big.Int)}
+	for height := uint32(0); height <= tipHeight; height++ {
+		hash, err := dbFetchHashByHeight(dbTx, height)
+		if err != nil {
+			return bestChainState{}, err
+		}
+		header, err := dbFetchHeaderByHash(dbTx, hash)
+		if err != nil {
+			return bestChainState{}, err
+		}
+		state.workSum.Add(state.workSum, CalcWork(header.Bits))
+
+		// The number of transactions directly follows the header.
+		numTxnsBytes, err := dbTx.FetchBlockRegion(&database.BlockRegion{
+			Hash:   hash,
+			Offset: wire.MaxBlockHeaderPayload,
+			Len:    wire.MaxVarIntPayload,
+		})
+		if err != nil {
+			return bestChainState{}, err
+		}
+		numTxns, err := wire.ReadVarInt(bytes.NewReader(numTxnsBytes), 0)
+		if err != nil {
+			return bestChainState{}, err
+		}
+		state.totalTxns += numTxns
+		state.hash = *hash
+	}
+	return state, nil
+}
+
 // createChainState initializes both the database and the chain state to the
 // genesis block.  This includes creating the necessary buckets and inserting
-// the genesis block, so it must only be called on an uninitialized database.
+// the genesis block, so it must only be called on a database without chain
+// state, which is either uninitialized or was reset by ResetChainState.
 func (b *BlockChain) createChainState() error {
 	// Create a new node from the genesis block and set it as the best node.
 	genesisBlock := provautil.NewBlock(b.chainParams.GenesisBlock)
@@ -1369,18 +1500,28 @@ func (b *BlockChain) createChainState() error {
 			return err
 		}
 
-		// Store the genesis block into the database.
-		return dbTx.StoreBlock(genesisBlock)
+		// Store the genesis block into the database unless it is
+		// already there because the chain state is being rebuilt.
+		return dbMaybeStoreBlock(dbTx, genesisBlock)
 	})
 	return err
 }
 
 // initChainState attempts to load and initialize the chain state from the
 // database.  When the db does not yet contain any chain state, both it and the
-// chain state are initialized to the genesis block.
+// chain state are initialized to the genesis block.  A corrupt best chain state
+// is rebuilt from the block index.
 func (b *BlockChain) initChainState() error {
+	return b.loadChainState(false)
+}
+
+// loadChainState implements initChainState.  The passed flag is whether or not
+// the best chain state has already been rebuilt, in which case it is not
+// rebuilt again.
+func (b *BlockChain) loadChainState(repaired bool) error {
 	// Attempt to load the chain state from the database.
 	var isStateInitialized bool
+	var corruptErr error
 	err := b.db.View(func(dbTx database.Tx) error {
 		// Fetch the stored chain state from the database metadata.
 		// When it doesn't exist, it means the database hasn't been
@@ -1392,8 +1533,12 @@ func (b *BlockChain) initChainState() error {
 		}
 		log.Tracef("Serialized chain state: %x", serializedData)
 		state, err := deserializeBestChainState(serializedData)
+		if err == nil {
+			err = dbCheckBestChainState(dbTx, &state)
+		}
 		if err != nil {
-			return err
+			corruptErr = err
+			return nil
 		}
 
 		// Fetch the admin keys from the database.
@@ -1459,6 +1604,30 @@ func (b *BlockChain) initChainState() error {
 		return err
 	}
 
+	// The best chain state is derived from the block index, so rebuild it
+	// from there when it is corrupt and then load the chain state again.
+	if corruptErr != nil {
+		if repaired {
+			return corruptErr
+		}
+		log.Warnf("Rebuilding the best chain state from the block "+
+			"index: %v", corruptErr)
+		err := b.db.Update(func(dbTx database.Tx) error {
+			state, err := dbRebuildBestChainState(dbTx)
+			if err != nil {
+				return err
+			}
+			log.Infof("Rebuilt the best chain state at block %v "+
+				"(height %d)", state.hash, state.height)
+			return dbTx.Metadata().Put(chainStateKeyName,
+				serializeBestChainState(state))
+		})
+		if err != nil {
+			return err
+		}
+		return b.loadChainState(true)
+	}
+
 	// There is nothing more to do if the chain state was initialized.
 	if isStateInitialized {
 		return nil
@@ -1541,6 +1710,41 @@ func dbFetchBlockByHeight(dbTx database.Tx, height uint32) (*provautil.Block, er
 	return block, nil
 }
 
+// dbForEachMainChainBlock uses an existing database transaction to call the
+// passed function with each block of the main chain after the genesis block up
+// to and including the passed height, in order.  It stops and returns false
+// when a block is not stored since it was pruned, which is also the case for
+// the blocks before the snapshot of chains bootstrapped from a utxo set
+// snapshot.
+func dbForEachMainChainBlock(dbTx database.Tx, endHeight uint32, fn func(*provautil.Block) error) (bool, error) {
+	for height := uint32(1); height <= endHeight; height++ {
+		block, err := dbFetchBlockByHeight(dbTx, height)
+		if isDbBlockPrunedErr(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if err := fn(block); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// DBFetchBlockByHeight uses an existing database transaction to retrieve the
+// main chain block at the provided height with the height set.  It allows
+// indexers to load blocks consistently with the database transaction they are
+// updating.
+func DBFetchBlockByHeight(dbTx database.Tx, height uint32) (*provautil.Block, error) {
+	block, err := dbFetchBlockByHeight(dbTx, height)
+	if err != nil {
+		return nil, err
+	}
+	block.SetHeight(height)
+	return block, nil
+}
+
 // dbMainChainHasBlock uses an existing database transaction to return whether
 // or not the main chain contains the block identified by the provided hash.
 func dbMainChainHasBlock(dbTx database.Tx, hash *chainhash.Hash) bool {
@@ -1548,6 +1752,22 @@ func dbMainChainHasBlock(dbTx database.Tx, hash *chainhash.Hash) bool {
 	return hashIndex.Get(hash[:]) != nil
 }
 
+// DBMainChainHasBlock uses an existing database transaction to return whether
+// or not the main chain contains the block identified by the provided hash.  It
+// allows indexers to check the main chain from within the database transaction
+// they are updating rather than opening a nested one.
+func DBMainChainHasBlock(dbTx database.Tx, hash *chainhash.Hash) bool {
+	return dbMainChainHasBlock(dbTx, hash)
+}
+
+// DBFetchHashByHeight uses an existing database transaction to retrieve the
+// hash of the main chain block at the provided height.  It allows indexers to
+// look up blocks by height from within the database transaction they are
+// using rather than opening a nested one.
+func DBFetchHashByHeight(dbTx database.Tx, height uint32) (*chainhash.Hash, error) {
+	return dbFetchHashByHeight(dbTx, height)
+}
+
 // MainChainHasBlock returns whether or not the block with the given hash is in
 // the main chain.
 //
@@ -0,0 +1,200 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+)
+
+// ChainTipStatus describes the state of the branch which ends at a chain tip.
+type ChainTipStatus byte
+
+// These constants define the possible states of a chain tip.
+const (
+	// ChainTipActive is the status of the end of the main chain.
+	ChainTipActive ChainTipStatus = iota
+
+	// ChainTipValidFork is the status of the end of a side chain whose
+	// blocks passed all of the checks which are possible without
+	// connecting them, or which used to be the main chain.
+	ChainTipValidFork
+
+	// ChainTipInvalid is the status of the end of a branch with a block
+	// which failed to connect.
+	ChainTipInvalid
+
+	// ChainTipHeadersOnly is the status of the end of a branch of headers
+	// whose blocks have not been downloaded yet.  The chain itself only
+	// tracks blocks, so it never reports this status, but callers which
+	// sync headers ahead of blocks may use it.
+	ChainTipHeadersOnly
+)
+
+// chainTipStatusStrings is a map of chain tip states back to their constant
+// names for pretty printing.
+var chainTipStatusStrings = map[ChainTipStatus]string{
+	ChainTipActive:      "active",
+	ChainTipValidFork:   "valid-fork",
+	ChainTipInvalid:     "invalid",
+	ChainTipHeadersOnly: "headers-only",
+}
+
+// String returns the ChainTipStatus as a human-readable name.
+func (s ChainTipStatus) String() string {
+	if str, ok := chainTipStatusStrings[s]; ok {
+		return str
+	}
+	return fmt.Sprintf("Unknown ChainTipStatus (%d)", int(s))
+}
+
+// ChainTip describes a block without any known children.  BranchLen is the
+// number of blocks between the tip and the main chain, so it is zero for the
+// end of the main chain.
+type ChainTip struct {
+	Hash      chainhash.Hash
+	Height    uint32
+	BranchLen uint32
+	Status    ChainTipStatus
+}
+
+// -----------------------------------------------------------------------------
+// The chain tips bucket houses the tips of the branches which do not end the
+// main chain, keyed by their block hash.  The end of the main chain is not
+// stored since it is always the best block.
+//
+// The serialized value format is:
+//
+//   <status>
+//
+//   Field             Type             Size
+//   status            ChainTipStatus   1 byte
+// -----------------------------------------------------------------------------
+
+// dbUpdateChainTips uses an existing database transaction to update the chain
+// tips after the passed node was accepted into the block index.  The old best
+// node is the best node before the passed node was accepted and connectErr is
+// the result of connecting it to the best chain.  The bucket is created when
+// it does not exist, so databases which predate it are upgraded on demand.
+func dbUpdateChainTips(dbTx database.Tx, node, oldBest, newBest *blockNode, connectErr error) error {
+	bucket, err := dbTx.Metadata().CreateBucketIfNotExists(chainTipsBucketName)
+	if err != nil {
+		return err
+	}
+
+	// The parent of the block no longer is a tip.
+	if err := bucket.Delete(node.parentHash[:]); err != nil {
+		return err
+	}
+
+	switch {
+	// The block is invalid when it violates a rule, while other errors
+	// mean it could not be checked, so it is not a tip either way.
+	case connectErr != nil:
+		if _, ok := connectErr.(RuleError); !ok {
+			return nil
+		}
+		return bucket.Put(node.hash[:], []byte{byte(ChainTipInvalid)})
+
+	// The block extends or became the end of the main chain.  When it
+	// caused a reorganization, the old end of the main chain is the tip
+	// of a side chain now.
+	case newBest.hash.IsEqual(node.hash):
+		if oldBest.hash.IsEqual(node.parentHash) {
+			return nil
+		}
+		return bucket.Put(oldBest.hash[:], []byte{byte(ChainTipValidFork)})
+
+	default:
+		return bucket.Put(node.hash[:], []byte{byte(ChainTipValidFork)})
+	}
+}
+
+// updateChainTips updates the chain tips in the database after the passed
+// node was accepted into the block index.  See dbUpdateChainTips for details.
+// Failing to update them is not fatal since they are only informational.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) updateChainTips(node, oldBest *blockNode, connectErr error) {
+	// Blocks which extend the main chain are the most common case and
+	// don't change any of the stored tips.
+	if connectErr == nil && node.parentHash.IsEqual(oldBest.hash) &&
+		b.bestNode.hash.IsEqual(node.hash) {
+
+		return
+	}
+
+	err := b.db.Update(func(dbTx database.Tx) error {
+		return dbUpdateChainTips(dbTx, node, oldBest, b.bestNode,
+			connectErr)
+	})
+	if err != nil {
+		log.Warnf("Unable to update the chain tips: %v", err)
+	}
+}
+
+// ChainTips returns the end of the main chain along with the tips of all of
+// the known branches which fork from it, ordered by height from the highest to
+// the lowest.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ChainTips() ([]ChainTip, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	tips := []ChainTip{{
+		Hash:   *b.bestNode.hash,
+		Height: b.bestNode.height,
+		Status: ChainTipActive,
+	}}
+	err := b.db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(chainTipsBucketName)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			if len(v) != 1 {
+				return database.Error{
+					ErrorCode: database.ErrCorruption,
+					Description: fmt.Sprintf("corrupt chain tip "+
+						"status of block %x", k),
+				}
+			}
+			tip := ChainTip{Status: ChainTipStatus(v[0])}
+			copy(tip.Hash[:], k)
+
+			// Walk back to the main chain to find the length of the
+			// branch.  Tips which are part of the main chain are
+			// stale and skipped.
+			hash := tip.Hash
+			for !dbMainChainHasBlock(dbTx, &hash) {
+				header, err := dbFetchHeaderByHash(dbTx, &hash)
+				if err != nil {
+					return err
+				}
+				if tip.BranchLen == 0 {
+					tip.Height = header.Height
+				}
+				tip.BranchLen++
+				hash = header.PrevBlock
+			}
+			if tip.BranchLen != 0 {
+				tips = append(tips, tip)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(tips, func(i, j int) bool {
+		return tips[i].Height > tips[j].Height
+	})
+	return tips, nil
+}
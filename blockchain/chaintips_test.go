@@ -0,0 +1,94 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/provautil"
+)
+
+// TestChainTips ensures the chain tips reported after processing the blocks
+// generated by the fullblocktests package, which fork the chain, reorganize it,
+// and fail to connect, include the end of the main chain and list the side
+// chains with their branch lengths and states.
+func TestChainTips(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("chaintips",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	for _, test := range tests {
+		for _, item := range test {
+			var block *provautil.Block
+			switch item := item.(type) {
+			case fullblocktests.AcceptedBlock:
+				block = provautil.NewBlock(item.Block)
+			case fullblocktests.RejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			case fullblocktests.OrphanOrRejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			default:
+				continue
+			}
+			chain.ProcessBlock(block, blockchain.BFNone)
+		}
+	}
+
+	tips, err := chain.ChainTips()
+	if err != nil {
+		t.Fatalf("ChainTips: %v", err)
+	}
+	best := chain.BestSnapshot()
+	statuses := make(map[blockchain.ChainTipStatus]int)
+	for i, tip := range tips {
+		statuses[tip.Status]++
+		if i > 0 && tip.Height > tips[i-1].Height {
+			t.Fatalf("ChainTips: tips are not ordered by height: %+v",
+				tips)
+		}
+		if tip.Status == blockchain.ChainTipActive {
+			if tip.Hash != *best.Hash || tip.Height != best.Height ||
+				tip.BranchLen != 0 {
+
+				t.Fatalf("ChainTips: active tip %+v is not the "+
+					"best block %v", tip, best.Hash)
+			}
+			continue
+		}
+		if tip.BranchLen == 0 || tip.BranchLen > tip.Height {
+			t.Fatalf("ChainTips: tip %v has branch length %d at "+
+				"height %d", tip.Hash, tip.BranchLen, tip.Height)
+		}
+		mainChain, err := chain.MainChainHasBlock(&tip.Hash)
+		if err != nil {
+			t.Fatalf("MainChainHasBlock: %v", err)
+		}
+		if mainChain {
+			t.Fatalf("ChainTips: side chain tip %v is in the main "+
+				"chain", tip.Hash)
+		}
+	}
+	if statuses[blockchain.ChainTipActive] != 1 {
+		t.Fatalf("ChainTips: got %d active tips, want 1",
+			statuses[blockchain.ChainTipActive])
+	}
+	if statuses[blockchain.ChainTipValidFork] == 0 {
+		t.Fatal("ChainTips: no valid-fork tips")
+	}
+	if statuses[blockchain.ChainTipInvalid] == 0 {
+		t.Fatal("ChainTips: no invalid tips")
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+)
+
+// checkpointFileSigPrefix is the prefix of the line of a checkpoint file which
+// houses the signature of its checkpoints.
+const checkpointFileSigPrefix = "signature:"
+
+// checkpointSorter implements sort.Interface to allow a slice of checkpoints to
+// be sorted.
+type checkpointSorter []chaincfg.Checkpoint
+
+// Len returns the number of checkpoints in the slice.  It is part of the
+// sort.Interface implementation.
+func (s checkpointSorter) Len() int {
+	return len(s)
+}
+
+// Swap swaps the checkpoints at the passed indices.  It is part of the
+// sort.Interface implementation.
+func (s checkpointSorter) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// Less returns whether the checkpoint with index i should sort before the
+// checkpoint with index j.  It is part of the sort.Interface implementation.
+func (s checkpointSorter) Less(i, j int) bool {
+	return s[i].Height < s[j].Height
+}
+
+// MergeCheckpoints returns two slices of checkpoints merged into one slice
+// such that the checkpoints are sorted by height.  In the case the additional
+// checkpoints contain a checkpoint with the same height as a checkpoint in the
+// default checkpoints, the additional checkpoint will take precedence and
+// overwrite the default one.
+func MergeCheckpoints(defaultCheckpoints, additional []chaincfg.Checkpoint) []chaincfg.Checkpoint {
+	// Create a map of the additional checkpoints to remove duplicates while
+	// leaving the most recently-specified checkpoint.
+	extra := make(map[uint32]chaincfg.Checkpoint)
+	for _, checkpoint := range additional {
+		extra[checkpoint.Height] = checkpoint
+	}
+
+	// Add all default checkpoints that do not have an override in the
+	// additional checkpoints.
+	numDefault := len(defaultCheckpoints)
+	checkpoints := make([]chaincfg.Checkpoint, 0, numDefault+len(extra))
+	for _, checkpoint := range defaultCheckpoints {
+		if _, exists := extra[checkpoint.Height]; !exists {
+			checkpoints = append(checkpoints, checkpoint)
+		}
+	}
+
+	// Append the additional checkpoints and return the sorted results.
+	for _, checkpoint := range extra {
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	sort.Sort(checkpointSorter(checkpoints))
+	return checkpoints
+}
+
+// checkpointsSigHash returns the hash signed by the signature of a checkpoint
+// file with the passed checkpoints.  It is the double SHA-256 of the height as
+// a little-endian uint32 followed by the hash of each checkpoint, in the order
+// they appear in the file.
+func checkpointsSigHash(checkpoints []chaincfg.Checkpoint) []byte {
+	buf := make([]byte, 0, len(checkpoints)*(4+chainhash.HashSize))
+	for _, checkpoint := range checkpoints {
+		var height [4]byte
+		binary.LittleEndian.PutUint32(height[:], checkpoint.Height)
+		buf = append(buf, height[:]...)
+		buf = append(buf, checkpoint.Hash[:]...)
+	}
+	return chainhash.DoubleHashB(buf)
+}
+
+// WriteCheckpointFile writes the passed checkpoints to w in the checkpoint file
+// format along with their signature by the passed key.
+//
+// A checkpoint file houses one '<height>:<hash>' checkpoint per line followed
+// by a 'signature:<hex>' line with the DER-encoded signature of the
+// checkpoints.  Empty lines and lines starting with '#' are ignored.
+func WriteCheckpointFile(w io.Writer, checkpoints []chaincfg.Checkpoint, key *btcec.PrivateKey) error {
+	sig, err := key.Sign(checkpointsSigHash(checkpoints))
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, checkpoint := range checkpoints {
+		_, err := fmt.Fprintf(bw, "%d:%v\n", checkpoint.Height,
+			checkpoint.Hash)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(bw, "%s%x\n", checkpointFileSigPrefix,
+		sig.Serialize())
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// ReadCheckpointFile reads the checkpoints of a checkpoint file from r and
+// returns them sorted by height.  An error is returned unless the checkpoints
+// are signed by one of the passed keys.  See WriteCheckpointFile for details
+// on the format.
+func ReadCheckpointFile(r io.Reader, keys []*btcec.PublicKey) ([]chaincfg.Checkpoint, error) {
+	var checkpoints []chaincfg.Checkpoint
+	var sig *btcec.Signature
+	heights := make(map[uint32]struct{})
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if sig != nil {
+			return nil, fmt.Errorf("line %d: unexpected data after "+
+				"the signature", lineNum)
+		}
+
+		if strings.HasPrefix(line, checkpointFileSigPrefix) {
+			sigHex := strings.TrimPrefix(line, checkpointFileSigPrefix)
+			sigBytes, err := hex.DecodeString(sigHex)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: malformed "+
+					"signature: %v", lineNum, err)
+			}
+			sig, err = btcec.ParseDERSignature(sigBytes, btcec.S256())
+			if err != nil {
+				return nil, fmt.Errorf("line %d: malformed "+
+					"signature: %v", lineNum, err)
+			}
+			continue
+		}
+
+		parts := strings.Split(line, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("line %d: unable to parse "+
+				"checkpoint %q -- use the syntax <height>:<hash>",
+				lineNum, line)
+		}
+		height, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: malformed checkpoint "+
+				"height %q", lineNum, parts[0])
+		}
+		hash, err := chainhash.NewHashFromStr(parts[1])
+		if err != nil || len(parts[1]) != chainhash.MaxHashStringSize {
+			return nil, fmt.Errorf("line %d: malformed checkpoint "+
+				"hash %q", lineNum, parts[1])
+		}
+		if _, exists := heights[uint32(height)]; exists {
+			return nil, fmt.Errorf("line %d: duplicate checkpoint "+
+				"at height %d", lineNum, height)
+		}
+		heights[uint32(height)] = struct{}{}
+		checkpoints = append(checkpoints, chaincfg.Checkpoint{
+			Height: uint32(height),
+			Hash:   hash,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if sig == nil {
+		return nil, errors.New("checkpoint file is not signed")
+	}
+
+	// The checkpoints must be signed by one of the trusted keys.
+	sigHash := checkpointsSigHash(checkpoints)
+	signed := false
+	for _, key := range keys {
+		if sig.Verify(sigHash, key) {
+			signed = true
+			break
+		}
+	}
+	if !signed {
+		return nil, errors.New("checkpoint file is not signed by a " +
+			"trusted key")
+	}
+
+	sort.Sort(checkpointSorter(checkpoints))
+	return checkpoints, nil
+}
+
+// LoadCheckpointFile reads the checkpoints of the checkpoint file at the passed
+// path.  See ReadCheckpointFile for details.
+func LoadCheckpointFile(path string, keys []*btcec.PublicKey) ([]chaincfg.Checkpoint, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadCheckpointFile(f, keys)
+}
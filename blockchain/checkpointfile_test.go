@@ -0,0 +1,111 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+)
+
+// TestCheckpointFile ensures checkpoint files round trip, are returned sorted
+// by height, and are rejected when they are not signed by a trusted key or
+// were tampered with.
+func TestCheckpointFile(t *testing.T) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	otherKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+
+	checkpoints := []chaincfg.Checkpoint{
+		{Height: 200, Hash: &chainhash.Hash{0x02}},
+		{Height: 100, Hash: &chainhash.Hash{0x01}},
+	}
+	var buf bytes.Buffer
+	err = blockchain.WriteCheckpointFile(&buf, checkpoints, key)
+	if err != nil {
+		t.Fatalf("WriteCheckpointFile: %v", err)
+	}
+	file := "# checkpoints\n\n" + buf.String()
+
+	trusted := []*btcec.PublicKey{otherKey.PubKey(), key.PubKey()}
+	got, err := blockchain.ReadCheckpointFile(strings.NewReader(file),
+		trusted)
+	if err != nil {
+		t.Fatalf("ReadCheckpointFile: %v", err)
+	}
+	want := []chaincfg.Checkpoint{checkpoints[1], checkpoints[0]}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ReadCheckpointFile: got %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name string
+		file string
+		keys []*btcec.PublicKey
+	}{
+		{
+			name: "untrusted key",
+			file: file,
+			keys: []*btcec.PublicKey{otherKey.PubKey()},
+		},
+		{
+			name: "tampered height",
+			file: strings.Replace(file, "200:", "201:", 1),
+			keys: trusted,
+		},
+		{
+			name: "unsigned",
+			file: "100:" + checkpoints[1].Hash.String() + "\n",
+			keys: trusted,
+		},
+		{
+			name: "data after signature",
+			file: file + "300:" + checkpoints[1].Hash.String() + "\n",
+			keys: trusted,
+		},
+		{
+			name: "malformed checkpoint",
+			file: "100\n" + file,
+			keys: trusted,
+		},
+	}
+	for _, test := range tests {
+		_, err := blockchain.ReadCheckpointFile(strings.NewReader(test.file),
+			test.keys)
+		if err == nil {
+			t.Errorf("ReadCheckpointFile (%s): accepted invalid file",
+				test.name)
+		}
+	}
+}
+
+// TestMergeCheckpoints ensures merged checkpoints are sorted by height and the
+// additional checkpoints override the default ones at the same height.
+func TestMergeCheckpoints(t *testing.T) {
+	defaults := []chaincfg.Checkpoint{
+		{Height: 100, Hash: &chainhash.Hash{0x01}},
+		{Height: 300, Hash: &chainhash.Hash{0x03}},
+	}
+	additional := []chaincfg.Checkpoint{
+		{Height: 300, Hash: &chainhash.Hash{0x04}},
+		{Height: 200, Hash: &chainhash.Hash{0x02}},
+	}
+	got := blockchain.MergeCheckpoints(defaults, additional)
+	want := []chaincfg.Checkpoint{defaults[0], additional[1], additional[0]}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("MergeCheckpoints: got %v, want %v", got, want)
+	}
+}
@@ -7,12 +7,14 @@ package blockchain
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/database"
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
 )
 
 // CheckpointConfirmations is the number of blocks before the end of the current
@@ -57,11 +59,24 @@ func (b *BlockChain) LatestCheckpoint() *chaincfg.Checkpoint {
 	return &b.checkpoints[len(b.checkpoints)-1]
 }
 
+// checkpointRestrictionsLifted returns whether the restrictions based on the
+// previous checkpoint, such as rejecting blocks which fork the main chain before
+// it, are lifted.  This is only the case when checkpoints are disabled on the
+// regression test and simulation test networks, so tests are able to rebuild
+// historical forks.
+func (b *BlockChain) checkpointRestrictionsLifted() bool {
+	if !b.disableCheckpoints {
+		return false
+	}
+	net := b.chainParams.Net
+	return net == wire.RegNet || net == wire.SimNet
+}
+
 // verifyCheckpoint returns whether the passed block height and hash combination
 // match the checkpoint data.  It also returns true if there is no checkpoint
-// data for the passed block height.
+// data for the passed block height or checkpoints are disabled.
 func (b *BlockChain) verifyCheckpoint(height uint32, hash *chainhash.Hash) bool {
-	if !b.HasCheckpoints() {
+	if !b.HasCheckpoints() || b.disableCheckpoints {
 		return true
 	}
 
@@ -81,13 +96,15 @@ func (b *BlockChain) verifyCheckpoint(height uint32, hash *chainhash.Hash) bool
 }
 
 // findPreviousCheckpoint finds the most recent checkpoint that is already
-// available in the downloaded portion of the block chain and returns the
-// associated block.  It returns nil if a checkpoint can't be found (this should
-// really only happen for blocks before the first checkpoint).
+// available in the downloaded portion of the block chain and returns the header
+// of the associated block.  Only the header is loaded so the checkpoint remains
+// usable when the data of the block has been pruned.  It returns nil if a
+// checkpoint can't be found (this should really only happen for blocks before
+// the first checkpoint) or the restrictions based on it are lifted.
 //
 // This function MUST be called with the chain lock held (for reads).
-func (b *BlockChain) findPreviousCheckpoint() (*provautil.Block, error) {
-	if !b.HasCheckpoints() {
+func (b *BlockChain) findPreviousCheckpoint() (*wire.BlockHeader, error) {
+	if !b.HasCheckpoints() || b.checkpointRestrictionsLifted() {
 		return nil, nil
 	}
 
@@ -101,7 +118,7 @@ func (b *BlockChain) findPreviousCheckpoint() (*provautil.Block, error) {
 	// Perform the initial search to find and cache the latest known
 	// checkpoint if the best chain is not known yet or we haven't already
 	// previously searched.
-	if b.checkpointBlock == nil && b.nextCheckpoint == nil {
+	if b.checkpointHeader == nil && b.nextCheckpoint == nil {
 		// Loop backwards through the available checkpoints to find one
 		// that is already available.
 		checkpointIndex := -1
@@ -130,11 +147,11 @@ func (b *BlockChain) findPreviousCheckpoint() (*provautil.Block, error) {
 		// Cache the latest known checkpoint block for future lookups.
 		checkpoint := checkpoints[checkpointIndex]
 		err = b.db.View(func(dbTx database.Tx) error {
-			block, err := dbFetchBlockByHash(dbTx, checkpoint.Hash)
+			header, err := dbFetchHeaderByHash(dbTx, checkpoint.Hash)
 			if err != nil {
 				return err
 			}
-			b.checkpointBlock = block
+			b.checkpointHeader = header
 
 			// Set the next expected checkpoint block accordingly.
 			b.nextCheckpoint = nil
@@ -148,21 +165,21 @@ func (b *BlockChain) findPreviousCheckpoint() (*provautil.Block, error) {
 			return nil, err
 		}
 
-		return b.checkpointBlock, nil
+		return b.checkpointHeader, nil
 	}
 
 	// At this point we've already searched for the latest known checkpoint,
 	// so when there is no next checkpoint, the current checkpoint lockin
 	// will always be the latest known checkpoint.
 	if b.nextCheckpoint == nil {
-		return b.checkpointBlock, nil
+		return b.checkpointHeader, nil
 	}
 
 	// When there is a next checkpoint and the height of the current best
 	// chain does not exceed it, the current checkpoint lockin is still
 	// the latest known checkpoint.
 	if b.bestNode.height < b.nextCheckpoint.Height {
-		return b.checkpointBlock, nil
+		return b.checkpointHeader, nil
 	}
 
 	// We've reached or exceeded the next checkpoint height.  Note that
@@ -175,11 +192,11 @@ func (b *BlockChain) findPreviousCheckpoint() (*provautil.Block, error) {
 	// has already passed the checkpoint which was verified as accurate
 	// before inserting it.
 	err := b.db.View(func(tx database.Tx) error {
-		block, err := dbFetchBlockByHash(tx, b.nextCheckpoint.Hash)
+		header, err := dbFetchHeaderByHash(tx, b.nextCheckpoint.Hash)
 		if err != nil {
 			return err
 		}
-		b.checkpointBlock = block
+		b.checkpointHeader = header
 		return nil
 	})
 	if err != nil {
@@ -199,7 +216,7 @@ func (b *BlockChain) findPreviousCheckpoint() (*provautil.Block, error) {
 		b.nextCheckpoint = &checkpoints[checkpointIndex+1]
 	}
 
-	return b.checkpointBlock, nil
+	return b.checkpointHeader, nil
 }
 
 // isNonstandardTransaction determines whether a transaction contains any
@@ -258,47 +275,118 @@ func (b *BlockChain) IsCheckpointCandidate(block *provautil.Block) (bool, error)
 				block.Height(), blockHeight)
 		}
 
-		// A checkpoint must be at least CheckpointConfirmations blocks
-		// before the end of the main chain.
-		mainChainHeight := b.bestNode.height
-		if blockHeight > (mainChainHeight - CheckpointConfirmations) {
-			return nil
-		}
+		isCandidate, err = b.checkpointCandidate(dbTx, block, blockHeight)
+		return err
+	})
+	return isCandidate, err
+}
 
-		// Get the previous block header.
-		prevHash := &block.MsgBlock().Header.PrevBlock
-		prevHeader, err := dbFetchHeaderByHash(dbTx, prevHash)
-		if err != nil {
-			return err
-		}
+// checkpointCandidate returns whether or not the passed block, which is in the
+// main chain at the passed height, is a good checkpoint candidate.  See
+// IsCheckpointCandidate for the factors used to determine a good checkpoint.
+//
+// This function MUST be called with the chain lock held (for reads).
+func (b *BlockChain) checkpointCandidate(dbTx database.Tx, block *provautil.Block, blockHeight uint32) (bool, error) {
+	// A checkpoint must be at least CheckpointConfirmations blocks
+	// before the end of the main chain.
+	mainChainHeight := b.bestNode.height
+	if blockHeight > (mainChainHeight - CheckpointConfirmations) {
+		return false, nil
+	}
 
-		// Get the next block header.
-		nextHeader, err := dbFetchHeaderByHeight(dbTx, blockHeight+1)
-		if err != nil {
-			return err
-		}
+	// Get the previous block header.
+	prevHash := &block.MsgBlock().Header.PrevBlock
+	prevHeader, err := dbFetchHeaderByHash(dbTx, prevHash)
+	if err != nil {
+		return false, err
+	}
 
-		// A checkpoint must have timestamps for the block and the
-		// blocks on either side of it in order (due to the median time
-		// allowance this is not always the case).
-		prevTime := prevHeader.Timestamp
-		curTime := block.MsgBlock().Header.Timestamp
-		nextTime := nextHeader.Timestamp
-		if prevTime.After(curTime) || nextTime.Before(curTime) {
-			return nil
+	// Get the next block header.
+	nextHeader, err := dbFetchHeaderByHeight(dbTx, blockHeight+1)
+	if err != nil {
+		return false, err
+	}
+
+	// A checkpoint must have timestamps for the block and the
+	// blocks on either side of it in order (due to the median time
+	// allowance this is not always the case).
+	prevTime := prevHeader.Timestamp
+	curTime := block.MsgBlock().Header.Timestamp
+	nextTime := nextHeader.Timestamp
+	if prevTime.After(curTime) || nextTime.Before(curTime) {
+		return false, nil
+	}
+
+	// A checkpoint must have transactions that only contain
+	// standard scripts.
+	for _, tx := range block.Transactions() {
+		if isNonstandardTransaction(tx) {
+			return false, nil
 		}
+	}
+
+	// All of the checks passed, so the block is a candidate.
+	return true, nil
+}
+
+// CheckpointCandidate describes a main chain block which is a good checkpoint
+// candidate as returned by CheckpointCandidates.
+type CheckpointCandidate struct {
+	Height    uint32
+	Hash      chainhash.Hash
+	Timestamp time.Time
+}
+
+// CheckpointCandidates scans the main chain backwards from the most recent
+// block with enough confirmations and returns up to the passed number of
+// blocks which pass IsCheckpointCandidate, ordered from the highest to the
+// lowest.  The scan stops at the latest checkpoint since there is no point in
+// finding candidates before it, as well as at the first block whose data has
+// been pruned since its transactions are no longer available.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CheckpointCandidates(n int) ([]CheckpointCandidate, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	bestHeight := b.bestNode.height
+	if n <= 0 || bestHeight < CheckpointConfirmations {
+		return nil, nil
+	}
+
+	// Any block after the genesis block is able to be the first
+	// checkpoint.
+	minHeight := uint32(1)
+	if checkpoint := b.LatestCheckpoint(); checkpoint != nil {
+		minHeight = checkpoint.Height + 1
+	}
 
-		// A checkpoint must have transactions that only contain
-		// standard scripts.
-		for _, tx := range block.Transactions() {
-			if isNonstandardTransaction(tx) {
-				return nil
+	var candidates []CheckpointCandidate
+	err := b.db.View(func(dbTx database.Tx) error {
+		height := bestHeight - CheckpointConfirmations
+		for ; height >= minHeight && len(candidates) < n; height-- {
+			block, err := dbFetchBlockByHeight(dbTx, height)
+			if isDbBlockPrunedErr(err) {
+				break
+			}
+			if err != nil {
+				return err
 			}
+			isCandidate, err := b.checkpointCandidate(dbTx, block,
+				height)
+			if err != nil {
+				return err
+			}
+			if !isCandidate {
+				continue
+			}
+			candidates = append(candidates, CheckpointCandidate{
+				Height:    height,
+				Hash:      *block.Hash(),
+				Timestamp: block.MsgBlock().Header.Timestamp,
+			})
 		}
-
-		// All of the checks passed, so the block is a candidate.
-		isCandidate = true
 		return nil
 	})
-	return isCandidate, err
+	return candidates, err
 }
@@ -0,0 +1,184 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+// TestDisableCheckpoints ensures disabling checkpoints on the regression test
+// network accepts blocks which do not match the checkpoints as well as blocks
+// which fork the main chain before the latest checkpoint, while both are
+// rejected when checkpoints are enabled.
+func TestDisableCheckpoints(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	source, teardownFunc, err := chainSetup("disablecheckpoints",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	blocks := make(map[chainhash.Hash]*provautil.Block)
+	for _, test := range tests {
+		for _, item := range test {
+			accepted, ok := item.(fullblocktests.AcceptedBlock)
+			if !ok {
+				continue
+			}
+			block := provautil.NewBlock(accepted.Block)
+			blocks[*block.Hash()] = block
+			_, _, err := source.ProcessBlock(block, blockchain.BFNone)
+			if err != nil {
+				t.Fatalf("ProcessBlock: %v", err)
+			}
+		}
+	}
+	best := source.BestSnapshot()
+
+	// Find the side chain which forks the main chain the earliest.
+	tips, err := source.ChainTips()
+	if err != nil {
+		t.Fatalf("ChainTips: %v", err)
+	}
+	var fork []*provautil.Block
+	for _, tip := range tips {
+		if tip.Status != blockchain.ChainTipValidFork {
+			continue
+		}
+		var branch []*provautil.Block
+		for hash := tip.Hash; ; {
+			mainChain, err := source.MainChainHasBlock(&hash)
+			if err != nil {
+				t.Fatalf("MainChainHasBlock: %v", err)
+			}
+			if mainChain {
+				break
+			}
+			block := blocks[hash]
+			branch = append([]*provautil.Block{block}, branch...)
+			hash = block.MsgBlock().Header.PrevBlock
+		}
+		if fork == nil || branch[0].MsgBlock().Header.Height <
+			fork[0].MsgBlock().Header.Height {
+
+			fork = branch
+		}
+	}
+	if fork == nil {
+		t.Fatal("no side chains were generated")
+	}
+
+	dir, err := ioutil.TempDir("", "disablecheckpoints")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var dbs []database.DB
+	defer func() {
+		for _, db := range dbs {
+			db.Close()
+		}
+	}()
+
+	// newChain returns a chain instance with the passed checkpoints backed
+	// by a new database.
+	newChain := func(name string, checkpoints []chaincfg.Checkpoint, disable bool) *blockchain.BlockChain {
+		params := chaincfg.RegressionNetParams
+		db, err := database.Create(testDbType, filepath.Join(dir, name),
+			params.Net)
+		if err != nil {
+			t.Fatalf("Failed to create database: %v", err)
+		}
+		chain, err := blockchain.New(&blockchain.Config{
+			DB:                 db,
+			ChainParams:        &params,
+			Checkpoints:        checkpoints,
+			DisableCheckpoints: disable,
+			TimeSource:         blockchain.NewMedianTime(),
+			SigCache:           txscript.NewSigCache(1000),
+		})
+		if err != nil {
+			db.Close()
+			t.Fatalf("Failed to setup chain instance: %v", err)
+		}
+		dbs = append(dbs, db)
+		return chain
+	}
+
+	// A checkpoint which does not match the main chain rejects the block
+	// at its height unless checkpoints are disabled.
+	var bogusHash chainhash.Hash
+	bogus := chaincfg.Checkpoint{Height: 1, Hash: &bogusHash}
+	block, err := source.BlockByHeight(1)
+	if err != nil {
+		t.Fatalf("BlockByHeight: %v", err)
+	}
+	chain := newChain("bogus", []chaincfg.Checkpoint{bogus}, false)
+	_, _, err = chain.ProcessBlock(block, blockchain.BFNone)
+	if rerr, ok := err.(blockchain.RuleError); !ok ||
+		rerr.ErrorCode != blockchain.ErrBadCheckpoint {
+
+		t.Fatalf("ProcessBlock: got %v, want ErrBadCheckpoint", err)
+	}
+
+	// The side chain forks the main chain before the latest checkpoint, so
+	// it is only accepted when checkpoints are disabled.
+	latest := chaincfg.Checkpoint{Height: best.Height, Hash: best.Hash}
+	chainTests := []struct {
+		name        string
+		checkpoints []chaincfg.Checkpoint
+		disable     bool
+	}{
+		{"enabled", []chaincfg.Checkpoint{latest}, false},
+		{"disabled", []chaincfg.Checkpoint{bogus, latest}, true},
+	}
+	for _, test := range chainTests {
+		chain := newChain(test.name, test.checkpoints, test.disable)
+		for height := uint32(1); height <= best.Height; height++ {
+			block, err := source.BlockByHeight(height)
+			if err != nil {
+				t.Fatalf("BlockByHeight: %v", err)
+			}
+			_, _, err = chain.ProcessBlock(block, blockchain.BFNone)
+			if err != nil {
+				t.Fatalf("ProcessBlock (%s): block at height %d: %v",
+					test.name, height, err)
+			}
+		}
+
+		_, _, err := chain.ProcessBlock(fork[0], blockchain.BFNone)
+		if test.disable {
+			if err != nil {
+				t.Fatalf("ProcessBlock (%s): side chain block "+
+					"rejected: %v", test.name, err)
+			}
+			continue
+		}
+		rerr, ok := err.(blockchain.RuleError)
+		if !ok || (rerr.ErrorCode != blockchain.ErrForkTooOld &&
+			rerr.ErrorCode != blockchain.ErrCheckpointTimeTooOld) {
+
+			t.Fatalf("ProcessBlock (%s): got %v, want ErrForkTooOld",
+				test.name, err)
+		}
+	}
+}
@@ -25,7 +25,10 @@ Since this package does not deal with other bitcoin specifics such as network
 communication or wallets, it provides a notification system which gives the
 caller a high level of flexibility in how they want to react to certain events
 such as orphan blocks which need their parents requested and newly connected
-main chain blocks which might result in wallet updates.
+main chain blocks which might result in wallet updates.  Callers which need to
+stay consistent with the main chain, such as wallets and indexers, may also
+register with Subscribe to receive the connected, disconnected, and reorganize
+events in order over a channel.
 
 Bitcoin Chain Processing Overview
 
@@ -19,6 +19,16 @@ func (e AssertError) Error() string {
 	return "assertion failed: " + string(e)
 }
 
+// DeploymentError identifies an error that indicates a deployment ID was
+// specified that does not exist.
+type DeploymentError int
+
+// Error returns the deployment error as a human-readable string and satisfies
+// the error interface.
+func (e DeploymentError) Error() string {
+	return fmt.Sprintf("deployment ID %d does not exist", int(e))
+}
+
 // ErrorCode identifies a kind of error.
 type ErrorCode int
 
@@ -219,6 +229,10 @@ const (
 	// ErrFeeTooHigh indicates a transaction fee exceeds the limit for
 	// fee paid.
 	ErrFeeTooHigh
+
+	// ErrTimelockNotActive indicates a transaction pays to a timelocked
+	// Prova script before the timelock deployment is active.
+	ErrTimelockNotActive
 )
 
 // Map of ErrorCode values back to their constant names for pretty printing.
@@ -269,6 +283,7 @@ var errorCodeStrings = map[ErrorCode]string{
 	ErrInvalidAdminTx:       "ErrInvalidAdminTx",
 	ErrInvalidAdminOp:       "ErrInvalidAdminOp",
 	ErrFeeTooHigh:           "ErrFeeTooHigh",
+	ErrTimelockNotActive:    "ErrTimelockNotActive",
 }
 
 // String returns the ErrorCode as a human-readable name.
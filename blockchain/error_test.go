@@ -60,6 +60,7 @@ func TestErrorCodeStringer(t *testing.T) {
 		{blockchain.ErrInconsistentBlkSize, "ErrInconsistentBlkSize"},
 		{blockchain.ErrInvalidValidateKey, "ErrInvalidValidateKey"},
 		{blockchain.ErrFeeTooHigh, "ErrFeeTooHigh"},
+		{blockchain.ErrTimelockNotActive, "ErrTimelockNotActive"},
 		{0xffff, "Unknown ErrorCode (65535)"},
 	}
 
@@ -100,3 +101,30 @@ func TestRuleError(t *testing.T) {
 		}
 	}
 }
+
+// TestDeploymentError tests the error output for the DeploymentError type.
+func TestDeploymentError(t *testing.T) {
+	tests := []struct {
+		in   blockchain.DeploymentError
+		want string
+	}{
+		{
+			blockchain.DeploymentError(0),
+			"deployment ID 0 does not exist",
+		},
+		{
+			blockchain.DeploymentError(123),
+			"deployment ID 123 does not exist",
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		result := test.in.Error()
+		if result != test.want {
+			t.Errorf("Error #%d\n got: %s want: %s", i, result,
+				test.want)
+			continue
+		}
+	}
+}
@@ -247,3 +247,221 @@ func TestFullBlocks(t *testing.T) {
 		}
 	}
 }
+
+// TestFullBlockScenario ensures the blocks generated for a scenario are
+// accepted via ProcessBlock, admin operations and reorgs take effect, and the
+// same scenario always generates the same blocks.
+func TestFullBlockScenario(t *testing.T) {
+	scenario, err := fullblocktests.ParseScenario([]byte(`{
+		"seed": 7,
+		"steps": [
+			{"op": "mine", "count": 100, "name": "mature"},
+			{"op": "admin", "action": "issue", "amount": 5000},
+			{"op": "txbatch", "count": 2},
+			{"op": "admin", "action": "aspkeyadd", "keyid": 3,
+			 "pubkey": "025ceeba2ab4a635df2c0301a3d773da06ac5a18a7c3e0d09a795d7e57d233edf1"},
+			{"op": "fork", "from": "mature", "count": 4, "name": "reorg"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("ParseScenario: unexpected error: %v", err)
+	}
+
+	blocks, err := fullblocktests.GenerateScenario(scenario)
+	if err != nil {
+		t.Fatalf("GenerateScenario: unexpected error: %v", err)
+	}
+	if len(blocks) != 107 {
+		t.Fatalf("unexpected number of blocks - got %d, want %d",
+			len(blocks), 107)
+	}
+	again, err := fullblocktests.GenerateScenario(scenario)
+	if err != nil {
+		t.Fatalf("GenerateScenario: unexpected error: %v", err)
+	}
+	for i := range blocks {
+		if blocks[i].Block.BlockHash() != again[i].Block.BlockHash() {
+			t.Fatalf("block %q differs between generations",
+				blocks[i].Name)
+		}
+	}
+
+	chain, teardownFunc, err := chainSetup("fullblockscenario",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	for _, item := range blocks {
+		block := provautil.NewBlock(item.Block)
+		block.SetHeight(item.Height)
+		_, isOrphan, err := chain.ProcessBlock(block, blockchain.BFNone)
+		if err != nil {
+			t.Fatalf("block %q (hash %s, height %d) should have "+
+				"been accepted: %v", item.Name, block.Hash(),
+				item.Height, err)
+		}
+		if isOrphan {
+			t.Fatalf("block %q (hash %s, height %d) is an orphan",
+				item.Name, block.Hash(), item.Height)
+		}
+		if item.Name == "b103" {
+			if chain.TotalSupply() != 5000 {
+				t.Fatalf("unexpected total supply - got %d, "+
+					"want %d", chain.TotalSupply(), 5000)
+			}
+			if _, ok := chain.KeyIDs()[btcec.KeyID(3)]; !ok {
+				t.Fatalf("key ID 3 is not provisioned")
+			}
+		}
+	}
+
+	// The fork is longer than the chain with the admin operations, so the
+	// reorg reverts them.
+	last := blocks[len(blocks)-1]
+	best := chain.BestSnapshot()
+	if last.Name != "reorg" || *best.Hash != last.Block.BlockHash() {
+		t.Fatalf("unexpected best block - got %s, want %s (%s)",
+			best.Hash, last.Block.BlockHash(), last.Name)
+	}
+	if chain.TotalSupply() != 0 {
+		t.Fatalf("unexpected total supply after reorg - got %d, want 0",
+			chain.TotalSupply())
+	}
+	if _, ok := chain.KeyIDs()[btcec.KeyID(3)]; ok {
+		t.Fatalf("key ID 3 is still provisioned after reorg")
+	}
+}
+
+// TestGenerator ensures the valid blocks built by a fullblocktests generator
+// are accepted via ProcessBlock and each of its invalid blocks is rejected with
+// the expected error code without changing the tip.
+func TestGenerator(t *testing.T) {
+	g, err := fullblocktests.NewGenerator(11)
+	if err != nil {
+		t.Fatalf("NewGenerator: unexpected error: %v", err)
+	}
+
+	// The admin threads start at the coinbase of the genesis block, so
+	// mine until it matures.
+	maturity := int(g.Params().CoinbaseMaturity)
+	err = g.Step(&fullblocktests.ScenarioStep{
+		Op:    fullblocktests.ScenarioOpMine,
+		Count: maturity,
+		Name:  "tip",
+	})
+	if err != nil {
+		t.Fatalf("Step: unexpected error: %v", err)
+	}
+	if name, _, height := g.Tip(); name != "tip" || int(height) != maturity {
+		t.Fatalf("unexpected tip - got %s at height %d, want tip at "+
+			"height %d", name, height, maturity)
+	}
+	if err := g.SetTip("unknown"); err == nil {
+		t.Fatalf("SetTip: unexpected success for unknown block")
+	}
+
+	chain, teardownFunc, err := chainSetup("fullblockgenerator",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	for _, item := range g.Blocks() {
+		block := provautil.NewBlock(item.Block)
+		block.SetHeight(item.Height)
+		if _, _, err := chain.ProcessBlock(block, blockchain.BFNone); err != nil {
+			t.Fatalf("block %q (hash %s, height %d) should have "+
+				"been accepted: %v", item.Name, block.Hash(),
+				item.Height, err)
+		}
+	}
+
+	// Revoking a key ID which was never added is an invalid admin
+	// operation.
+	revokeTx, err := g.AdminTx(&fullblocktests.ScenarioStep{
+		Op:     fullblocktests.ScenarioOpAdmin,
+		Action: "aspkeyrevoke",
+		KeyID:  9,
+		PubKey: "025ceeba2ab4a635df2c0301a3d773da06ac5a18a7c3e0d09a795d7e57d233edf1",
+	})
+	if err != nil {
+		t.Fatalf("AdminTx: unexpected error: %v", err)
+	}
+	unknownKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		mungers []fullblocktests.Munger
+		code    blockchain.ErrorCode
+	}{
+		{
+			name:    "badsig",
+			mungers: []fullblocktests.Munger{fullblocktests.BadBlockSignature()},
+			code:    blockchain.ErrBadBlockSignature,
+		},
+		{
+			name:    "unknownkey",
+			mungers: []fullblocktests.Munger{fullblocktests.SignedBy(unknownKey)},
+			code:    blockchain.ErrInvalidValidateKey,
+		},
+		{
+			name: "oversize",
+			mungers: []fullblocktests.Munger{fullblocktests.OversizeBlock(
+				g.Params().BlockSizeLimit())},
+			code: blockchain.ErrBlockTooBig,
+		},
+		{
+			name:    "coinbasevalue",
+			mungers: []fullblocktests.Munger{fullblocktests.ChangeCoinbaseValue(1)},
+			code:    blockchain.ErrBadCoinbaseValue,
+		},
+		{
+			name:    "badadminop",
+			mungers: []fullblocktests.Munger{fullblocktests.AdditionalTx(revokeTx)},
+			code:    blockchain.ErrInvalidAdminOp,
+		},
+	}
+
+	for _, test := range tests {
+		msgBlock, err := g.InvalidBlock(test.name, test.mungers...)
+		if err != nil {
+			t.Fatalf("%s: InvalidBlock: unexpected error: %v",
+				test.name, err)
+		}
+		if name, _, _ := g.Tip(); name != "tip" {
+			t.Fatalf("%s: tip changed to %s", test.name, name)
+		}
+
+		block := provautil.NewBlock(msgBlock)
+		block.SetHeight(msgBlock.Header.Height)
+		_, _, err = chain.ProcessBlock(block, blockchain.BFNone)
+		rerr, ok := err.(blockchain.RuleError)
+		if !ok {
+			t.Fatalf("%s: unexpected error - got %v, want %v",
+				test.name, err, test.code)
+		}
+		if rerr.ErrorCode != test.code {
+			t.Fatalf("%s: unexpected error code - got %v, want %v",
+				test.name, rerr.ErrorCode, test.code)
+		}
+	}
+
+	// The generator keeps building valid blocks on the tip after the
+	// invalid ones.
+	if err := g.Step(&fullblocktests.ScenarioStep{Op: fullblocktests.ScenarioOpMine}); err != nil {
+		t.Fatalf("Step: unexpected error: %v", err)
+	}
+	blocks := g.Blocks()
+	last := blocks[len(blocks)-1]
+	block := provautil.NewBlock(last.Block)
+	block.SetHeight(last.Height)
+	if _, _, err := chain.ProcessBlock(block, blockchain.BFNone); err != nil {
+		t.Fatalf("block %q should have been accepted: %v", last.Name, err)
+	}
+}
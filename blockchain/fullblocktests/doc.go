@@ -13,6 +13,19 @@ goal, each test contains additional information about the expected result,
 however that information can be ignored when doing comparison tests between two
 independent versions over the peer-to-peer network.
 
+In addition, GenerateScenario deterministically generates the blocks of a
+scenario which describes the blocks to mine, the forks to create in order to
+force reorgs, the admin operations to perform, and the batches of transactions
+to include.  This allows complex consensus bugs to be reproduced, either
+directly against the blockchain code or against a running node via the
+simulatechain RPC.
+
+For tests which need finer control, a Generator builds a chain one step at a
+time and generates strategically invalid blocks on its tip, such as blocks with
+bad validator signatures, oversized blocks, or blocks with invalid admin
+operations, by applying Mungers to otherwise valid blocks.  Generators with the
+same seed always generate the same blocks.
+
 This package has intentionally been designed so it can be used as a standalone
 package for any projects needing to test their implementation against a full set
 of blocks that excerise the consensus validation rules.
@@ -214,6 +214,12 @@ type testGenerator struct {
 
 	// Common key for any tests which require signed transactions.
 	privKey *btcec.PrivateKey
+
+	// rng is the source of the random pkHashes of coinbase outputs and
+	// startTime is the timestamp of the first block.  Scenarios set both
+	// so they always generate the same blocks.
+	rng       *rand.Rand
+	startTime time.Time
 }
 
 // makeTestGenerator returns a test generator instance initialized with the
@@ -231,6 +237,8 @@ func makeTestGenerator(params *chaincfg.Params) (testGenerator, error) {
 		tipName:      "genesis",
 		tipHeight:    0,
 		privKey:      privKey2,
+		rng:          rand.New(rand.NewSource(time.Now().UnixNano())),
+		startTime:    time.Unix(time.Now().Unix(), 0),
 	}, nil
 }
 
@@ -317,7 +325,7 @@ func (g *testGenerator) createCoinbaseTx(blockHeight uint32) *wire.MsgTx {
 	//   - has keyId1 and keyId2, so it can be spend by always the same
 	//      private keys defined for this test suite
 	pkHash := make([]byte, 20)
-	rand.Read(pkHash)
+	g.rng.Read(pkHash)
 	addr, _ := provautil.NewAddressProva(pkHash, []btcec.KeyID{keyId1, keyId2}, &chaincfg.RegressionNetParams)
 	scriptPkScript, _ := txscript.PayToAddrScript(addr)
 
@@ -619,6 +627,7 @@ func createIssueTx(thread *spendableOut, value int64, spend *spendableOut) *wire
 // In order to simply the logic in the munge functions, the following rules are
 // applied after all munge functions have been invoked:
 // - The merkle root will be recalculated unless it was manually changed
+// - The block will be signed unless the validating public key was changed
 // - The block will be solved unless the nonce was changed
 func (g *testGenerator) nextBlock(blockName string, spend *spendableOut, mungers ...func(*wire.MsgBlock)) *wire.MsgBlock {
 	// Create coinbase transaction for the block using any additional
@@ -634,10 +643,10 @@ func (g *testGenerator) nextBlock(blockName string, spend *spendableOut, mungers
 	}
 
 	// Use a timestamp that is two minutes after the previous block unless
-	// this is the first block in which case the current time is used.
+	// this is the first block in which case the start time is used.
 	var ts time.Time
 	if nextHeight == 1 {
-		ts = time.Unix(time.Now().Unix(), 0)
+		ts = g.startTime
 	} else {
 		ts = g.tip.Header.Timestamp.Add(time.Minute * 2)
 	}
@@ -659,6 +668,7 @@ func (g *testGenerator) nextBlock(blockName string, spend *spendableOut, mungers
 	// merkle root if it wasn't manually changed by a munge function.
 	curMerkleRoot := block.Header.MerkleRoot
 	curNonce := block.Header.Nonce
+	curValidatingPubKey := block.Header.ValidatingPubKey
 	for _, f := range mungers {
 		f(&block)
 	}
@@ -666,7 +676,9 @@ func (g *testGenerator) nextBlock(blockName string, spend *spendableOut, mungers
 		block.Header.MerkleRoot = calcMerkleRoot(block.Transactions)
 	}
 	block.Header.Size = uint32(block.SerializeSize())
-	block.Header.Sign(validatePrivKey)
+	if block.Header.ValidatingPubKey == curValidatingPubKey {
+		block.Header.Sign(validatePrivKey)
+	}
 
 	// Only solve the block if the nonce wasn't manually changed by a munge
 	// function.
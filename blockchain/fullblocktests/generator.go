@@ -0,0 +1,195 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package fullblocktests
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// Munger modifies a block generated by a Generator before it is signed and
+// solved.  The merkle root of the block is recalculated after the mungers are
+// invoked unless one of them changed it, and the block is signed with the
+// validate key of the generator unless one of them changed the validating
+// public key.
+type Munger func(*wire.MsgBlock)
+
+// AdditionalTx returns a munger which adds the passed transaction to the block.
+func AdditionalTx(tx *wire.MsgTx) Munger {
+	return additionalTx(tx)
+}
+
+// ChangeCoinbaseValue returns a munger which changes the value claimed by the
+// coinbase of the block by the passed delta.  Blocks which claim more or less
+// than the subsidy and fees are rejected with ErrBadCoinbaseValue.
+func ChangeCoinbaseValue(delta int64) Munger {
+	return changeCoinbaseValue(delta)
+}
+
+// SignedBy returns a munger which signs the block with the passed validate key
+// instead of the validate key of the generator.  Blocks signed by a key which
+// is not part of the validate key set are rejected with ErrInvalidValidateKey.
+//
+// The munger must be passed after the mungers which change the transactions of
+// the block, since the signature commits to its merkle root.
+func SignedBy(validateKey wire.BlockSigner) Munger {
+	return func(b *wire.MsgBlock) {
+		b.Header.MerkleRoot = calcMerkleRoot(b.Transactions)
+		if err := b.Header.Sign(validateKey); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// BadBlockSignature returns a munger which sets the signature of the block to
+// a signature of the validate key of the generator over a different header,
+// so the block is rejected with ErrBadBlockSignature.
+func BadBlockSignature() Munger {
+	return func(b *wire.MsgBlock) {
+		header := b.Header
+		header.PrevBlock = chainhash.Hash{}
+		if err := header.Sign(validatePrivKey); err != nil {
+			panic(err)
+		}
+		b.Header.ValidatingPubKey = header.ValidatingPubKey
+		b.Header.Signature = header.Signature
+	}
+}
+
+// OversizeBlock returns a munger which adds a transaction with a large
+// OP_RETURN output to the block, so it serializes to more than the passed
+// maximum block size and is rejected with ErrBlockTooBig.
+func OversizeBlock(maxSize int) Munger {
+	return func(b *wire.MsgBlock) {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: wire.OutPoint{Hash: chainhash.Hash{0x01}},
+			Sequence:         wire.MaxTxInSequenceNum,
+		})
+		padding := maxSize - b.SerializeSize() + 1
+		if padding < 1 {
+			padding = 1
+		}
+		pkScript := make([]byte, padding)
+		pkScript[0] = txscript.OP_RETURN
+		tx.AddTxOut(wire.NewTxOut(0, pkScript))
+		b.AddTransaction(tx)
+	}
+}
+
+// Generator deterministically builds chains of blocks on the genesis block of
+// the regression test network, which is useful for testing implementations
+// against both valid chains and strategically invalid blocks.
+//
+// Valid blocks are generated by the steps of a scenario the same way as
+// GenerateScenario does.  Invalid blocks are generated by InvalidBlock with
+// mungers such as BadBlockSignature, OversizeBlock, or AdditionalTx along with
+// an admin transaction from AdminTx which performs an invalid operation.
+type Generator struct {
+	g *scenarioGenerator
+}
+
+// NewGenerator returns a generator initialized with the genesis block as the
+// tip.  Generators with the same seed generate the same blocks for the same
+// calls.
+func NewGenerator(seed int64) (*Generator, error) {
+	g, err := newScenarioGenerator(seed, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Generator{g: g}, nil
+}
+
+// recoverError replaces the passed error with the panic the generator recovered
+// from, if any.  The underlying generator panics on failures such as being
+// unable to solve a block.
+func recoverError(err *error) {
+	if r := recover(); r != nil {
+		switch rt := r.(type) {
+		case string:
+			*err = errors.New(rt)
+		case error:
+			*err = rt
+		default:
+			*err = errors.New("Unknown panic")
+		}
+	}
+}
+
+// Params returns the network parameters of the generated blocks.
+func (g *Generator) Params() *chaincfg.Params {
+	return g.g.params
+}
+
+// Step performs the passed scenario step on the current tip.
+func (g *Generator) Step(step *ScenarioStep) (err error) {
+	defer recoverError(&err)
+	return g.g.step(step)
+}
+
+// Blocks returns the valid blocks generated so far in the order they were
+// mined.
+func (g *Generator) Blocks() []ScenarioBlock {
+	return g.g.blocks
+}
+
+// Tip returns the name, block, and height of the current tip.
+func (g *Generator) Tip() (string, *wire.MsgBlock, uint32) {
+	return g.g.tipName, g.g.tip, g.g.tipHeight
+}
+
+// Block returns the generated block with the passed name along with its
+// height.  The returned flag is false when there is no such block.
+func (g *Generator) Block(name string) (*wire.MsgBlock, uint32, bool) {
+	block, ok := g.g.blocksByName[name]
+	if !ok {
+		return nil, 0, false
+	}
+	return block, g.g.blockHeights[name], true
+}
+
+// SetTip changes the tip to the valid block with the passed name, so the
+// blocks generated next fork from it.
+func (g *Generator) SetTip(name string) error {
+	if _, ok := g.g.states[name]; !ok {
+		return fmt.Errorf("unknown block %q", name)
+	}
+	g.g.setTip(name)
+	return nil
+}
+
+// AdminTx returns an admin transaction which performs the admin action of the
+// passed scenario step on the tip of its thread at the current tip.  The
+// transaction is not recorded, so it is able to perform invalid operations,
+// such as revoking a key which is not part of its key set, for use with
+// InvalidBlock.
+func (g *Generator) AdminTx(step *ScenarioStep) (*wire.MsgTx, error) {
+	return g.g.adminTx(step, g.g.tipState())
+}
+
+// InvalidBlock generates a block with the passed name on the current tip which
+// is modified by the passed mungers, and returns it.  The tip is left
+// unchanged and the block is not able to be forked from, since it is meant to
+// be rejected.
+func (g *Generator) InvalidBlock(name string, mungers ...Munger) (block *wire.MsgBlock, err error) {
+	defer recoverError(&err)
+
+	if _, ok := g.g.blocksByName[name]; ok {
+		return nil, fmt.Errorf("duplicate block name %q", name)
+	}
+	tipName := g.g.tipName
+	defer g.g.setTip(tipName)
+
+	blockMungers := make([]func(*wire.MsgBlock), 0, len(mungers))
+	for _, munger := range mungers {
+		blockMungers = append(blockMungers, munger)
+	}
+	return g.g.nextBlock(name, nil, blockMungers...), nil
+}
@@ -0,0 +1,488 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package fullblocktests
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// These constants define the operations of the steps of a scenario.
+const (
+	// ScenarioOpMine mines Count blocks on the current tip.
+	ScenarioOpMine = "mine"
+
+	// ScenarioOpFork makes the block named From the current tip and mines
+	// Count blocks on it.  A fork which grows longer than the main chain
+	// forces a reorg.
+	ScenarioOpFork = "fork"
+
+	// ScenarioOpAdmin mines a block with an admin transaction performing
+	// the admin operation named Action.
+	ScenarioOpAdmin = "admin"
+
+	// ScenarioOpTxBatch mines a block with Count transactions, each of which
+	// spends one of the oldest mature coinbase outputs of the chain.
+	ScenarioOpTxBatch = "txbatch"
+)
+
+var (
+	// scenarioProvisionKeys and scenarioIssueKeys are the private keys of
+	// the initial provision and issue key sets of the regression test
+	// network.  They sign the transactions of the provision and issue
+	// threads while the root keys sign the root thread.
+	scenarioProvisionKeys = []txscript.PrivateKey{
+		{Key: hexToPrivKey("f954b388f5db3a1d2915cda434206d791b47cf3d4e78cc32fbeb77ea25d20d7d"), Compressed: true},
+		{Key: hexToPrivKey("627f6f1d5d8f38bd60b6aaea2f74c72917deffcc2a5a64f67d3e0a28a2d711c1"), Compressed: true},
+	}
+	scenarioIssueKeys = []txscript.PrivateKey{
+		{Key: hexToPrivKey("3f9222ab4d30b1795941d9815e5833a4da70cb04bff59a5fd2ddc4641e58607e"), Compressed: true},
+		{Key: hexToPrivKey("0a40defde0e49e1f78edb9cea5c499f704fabc140d6fd1a4df8405365e2e4f0f"), Compressed: true},
+	}
+
+	// scenarioAdminActions maps the admin actions of a scenario to their
+	// admin operation and the thread the operation is performed on.
+	scenarioAdminActions = map[string]struct {
+		op       byte
+		threadID provautil.ThreadID
+	}{
+		"issuekeyadd":        {txscript.AdminOpIssueKeyAdd, provautil.RootThread},
+		"issuekeyrevoke":     {txscript.AdminOpIssueKeyRevoke, provautil.RootThread},
+		"provisionkeyadd":    {txscript.AdminOpProvisionKeyAdd, provautil.RootThread},
+		"provisionkeyrevoke": {txscript.AdminOpProvisionKeyRevoke, provautil.RootThread},
+		"validatekeyadd":     {txscript.AdminOpValidateKeyAdd, provautil.ProvisionThread},
+		"validatekeyrevoke":  {txscript.AdminOpValidateKeyRevoke, provautil.ProvisionThread},
+		"aspkeyadd":          {txscript.AdminOpASPKeyAdd, provautil.ProvisionThread},
+		"aspkeyrevoke":       {txscript.AdminOpASPKeyRevoke, provautil.ProvisionThread},
+		"issue":              {0, provautil.IssueThread},
+	}
+)
+
+// hexToPrivKey decodes the passed hex-encoded private key.  It panics on error
+// and must only be called with hard-coded values.
+func hexToPrivKey(s string) *btcec.PrivateKey {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic("invalid hex in source file: " + s)
+	}
+	privKey, _ := btcec.PrivKeyFromBytes(btcec.S256(), b)
+	return privKey
+}
+
+// ScenarioStep describes a step of a scenario.  Which fields are used depends
+// on the operation of the step.
+type ScenarioStep struct {
+	// Op is the operation of the step.  See the ScenarioOp constants.
+	Op string `json:"op"`
+
+	// Name names the last block mined by the step so later steps are able
+	// to fork from it.  Blocks are also named b1, b2, and so on in the
+	// order they are mined.
+	Name string `json:"name,omitempty"`
+
+	// Count is the number of blocks to mine for the mine and fork
+	// operations, and the number of transactions for the txbatch
+	// operation.  It defaults to one.
+	Count int `json:"count,omitempty"`
+
+	// From is the name of the block to fork from.  The genesis block is
+	// named genesis.
+	From string `json:"from,omitempty"`
+
+	// Action is the admin operation to perform: issuekeyadd,
+	// issuekeyrevoke, provisionkeyadd, provisionkeyrevoke, validatekeyadd,
+	// validatekeyrevoke, aspkeyadd, aspkeyrevoke, or issue.
+	Action string `json:"action,omitempty"`
+
+	// PubKey is the hex-encoded compressed public key the admin operation
+	// adds or revokes.
+	PubKey string `json:"pubkey,omitempty"`
+
+	// KeyID is the key ID of the aspkeyadd and aspkeyrevoke operations.
+	KeyID uint32 `json:"keyid,omitempty"`
+
+	// Amount is the number of atoms the issue operation issues.
+	Amount int64 `json:"amount,omitempty"`
+}
+
+// Scenario describes a chain for GenerateScenario to generate.  The chain
+// builds from the genesis block of the regression test network.
+type Scenario struct {
+	// Seed seeds the generation of addresses, so the same scenario always
+	// generates the same blocks.
+	Seed int64 `json:"seed"`
+
+	// StartTime is the unix time of the first block.  Each block is two
+	// minutes after the block it builds on.  It defaults to one day after
+	// the genesis block.
+	StartTime int64 `json:"starttime,omitempty"`
+
+	// Steps are the steps to perform in order.
+	Steps []ScenarioStep `json:"steps"`
+}
+
+// ParseScenario parses a JSON-encoded scenario.
+func ParseScenario(data []byte) (*Scenario, error) {
+	var scenario Scenario
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return nil, err
+	}
+	return &scenario, nil
+}
+
+// ScenarioBlock is a block generated for a scenario.
+type ScenarioBlock struct {
+	Name   string
+	Block  *wire.MsgBlock
+	Height uint32
+}
+
+// scenarioCoinbaseOut is a coinbase output along with the height of the block
+// which created it.
+type scenarioCoinbaseOut struct {
+	out    spendableOut
+	height uint32
+}
+
+// scenarioState houses the state of the chain ending at a block which is needed
+// to extend the chain: the tips of the admin threads and the coinbase outputs
+// which are not spent yet, oldest first.
+type scenarioState struct {
+	threadTips   map[provautil.ThreadID]spendableOut
+	coinbaseOuts []scenarioCoinbaseOut
+}
+
+// scenarioGenerator generates the blocks of a scenario.  It keeps the state of
+// the chain ending at every generated block so steps are able to fork from any
+// of them.
+type scenarioGenerator struct {
+	testGenerator
+	states map[string]*scenarioState
+	blocks []ScenarioBlock
+	seq    int
+}
+
+// address returns a new Prova address with key IDs 1 and 2 and a pkHash drawn
+// from the seeded source of the generator.
+func (g *scenarioGenerator) address() provautil.Address {
+	pkHash := make([]byte, 20)
+	g.rng.Read(pkHash)
+	addr, _ := provautil.NewAddressProva(pkHash,
+		[]btcec.KeyID{keyId1, keyId2}, &chaincfg.RegressionNetParams)
+	return addr
+}
+
+// signInput signs the input at the passed index of the passed transaction,
+// which spends the passed output, with the passed keys.
+func signInput(tx *wire.MsgTx, idx int, spend *spendableOut,
+	keys []txscript.PrivateKey) error {
+
+	lookupKeys := func(provautil.Address) ([]txscript.PrivateKey, error) {
+		return keys, nil
+	}
+	sigScript, err := txscript.SignTxOutput(&chaincfg.RegressionNetParams,
+		tx, idx, int64(spend.amount), spend.pkScript,
+		txscript.SigHashAll, txscript.KeyClosure(lookupKeys), nil)
+	if err != nil {
+		return err
+	}
+	tx.TxIn[idx].SignatureScript = sigScript
+	return nil
+}
+
+// adminTx returns a transaction spending the tip of the thread of the passed
+// admin step.  The thread tip of the passed state is updated to the output of
+// the transaction.
+func (g *scenarioGenerator) adminTx(step *ScenarioStep,
+	state *scenarioState) (*wire.MsgTx, error) {
+
+	action, ok := scenarioAdminActions[step.Action]
+	if !ok {
+		return nil, fmt.Errorf("unknown admin action %q", step.Action)
+	}
+
+	thread := state.threadTips[action.threadID]
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(&wire.TxIn{
+		PreviousOutPoint: thread.prevOut,
+		Sequence:         wire.MaxTxInSequenceNum,
+	})
+	tx.AddTxOut(wire.NewTxOut(0, provaThreadScript(action.threadID)))
+
+	var keys []txscript.PrivateKey
+	switch action.threadID {
+	case provautil.RootThread, provautil.ProvisionThread:
+		pubKeyBytes, err := hex.DecodeString(step.PubKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pubkey %q: %v",
+				step.PubKey, err)
+		}
+		pubKey, err := btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+		if err != nil {
+			return nil, fmt.Errorf("invalid pubkey %q: %v",
+				step.PubKey, err)
+		}
+
+		var script []byte
+		switch action.op {
+		case txscript.AdminOpASPKeyAdd, txscript.AdminOpASPKeyRevoke:
+			script = provaAdminASPScript(action.op, pubKey,
+				btcec.KeyID(step.KeyID))
+		default:
+			script = provaAdminScript(action.op, pubKey)
+		}
+		tx.AddTxOut(wire.NewTxOut(0, script))
+
+		keys = scenarioProvisionKeys
+		if action.threadID == provautil.RootThread {
+			keys = []txscript.PrivateKey{
+				{Key: privKey1, Compressed: true},
+				{Key: privKey2, Compressed: true},
+			}
+		}
+
+	case provautil.IssueThread:
+		if step.Amount <= 0 {
+			return nil, fmt.Errorf("invalid issue amount %d",
+				step.Amount)
+		}
+		pkScript, err := txscript.PayToAddrScript(g.address())
+		if err != nil {
+			return nil, err
+		}
+		tx.AddTxOut(wire.NewTxOut(step.Amount, pkScript))
+		keys = scenarioIssueKeys
+	}
+
+	if err := signInput(tx, 0, &thread, keys); err != nil {
+		return nil, err
+	}
+	state.threadTips[action.threadID] = makeSpendableOutForTx(tx, 0)
+	return tx, nil
+}
+
+// batchTxns returns the passed number of transactions, each of which spends
+// one of the oldest mature coinbase outputs of the passed state.  The spent
+// outputs are removed from the state.
+func (g *scenarioGenerator) batchTxns(count int,
+	state *scenarioState) ([]*wire.MsgTx, error) {
+
+	nextHeight := g.tipHeight + 1
+	maturity := uint32(g.params.CoinbaseMaturity)
+	txns := make([]*wire.MsgTx, 0, count)
+	for i := 0; i < count; i++ {
+		if len(state.coinbaseOuts) == 0 ||
+			nextHeight-state.coinbaseOuts[0].height < maturity {
+			return nil, fmt.Errorf("only %d of %d coinbase outputs "+
+				"are mature at height %d", i, count, nextHeight)
+		}
+		spend := state.coinbaseOuts[0].out
+		state.coinbaseOuts = state.coinbaseOuts[1:]
+
+		pkScript, err := txscript.PayToAddrScript(g.address())
+		if err != nil {
+			return nil, err
+		}
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: spend.prevOut,
+			Sequence:         wire.MaxTxInSequenceNum,
+		})
+		tx.AddTxOut(wire.NewTxOut(int64(spend.amount), pkScript))
+		err = signInput(tx, 0, &spend, []txscript.PrivateKey{
+			{Key: privKey1, Compressed: true},
+			{Key: privKey2, Compressed: true},
+		})
+		if err != nil {
+			return nil, err
+		}
+		txns = append(txns, tx)
+	}
+	return txns, nil
+}
+
+// mine mines a block with the passed transactions on the current tip and
+// records the passed state, which is the state of the chain ending at the tip
+// updated by the transactions, along with the coinbase output of the block.
+func (g *scenarioGenerator) mine(name string, state *scenarioState,
+	txns []*wire.MsgTx) error {
+
+	g.seq++
+	seqName := fmt.Sprintf("b%d", g.seq)
+	if name == "" {
+		name = seqName
+	}
+	if _, ok := g.states[name]; ok {
+		return fmt.Errorf("duplicate block name %q", name)
+	}
+
+	mungers := make([]func(*wire.MsgBlock), 0, len(txns))
+	for _, tx := range txns {
+		mungers = append(mungers, additionalTx(tx))
+	}
+	block := g.nextBlock(name, nil, mungers...)
+
+	state.coinbaseOuts = append(state.coinbaseOuts, scenarioCoinbaseOut{
+		out:    makeSpendableOut(block, 0, 0),
+		height: g.tipHeight,
+	})
+
+	g.states[name] = state
+	if name != seqName {
+		g.blocksByName[seqName] = block
+		g.blockHeights[seqName] = g.tipHeight
+		g.states[seqName] = state
+	}
+	g.blocks = append(g.blocks, ScenarioBlock{
+		Name:   name,
+		Block:  block,
+		Height: g.tipHeight,
+	})
+	return nil
+}
+
+// tipState returns a copy of the state of the chain ending at the current tip.
+func (g *scenarioGenerator) tipState() *scenarioState {
+	state := g.states[g.tipName]
+	threadTips := make(map[provautil.ThreadID]spendableOut,
+		len(state.threadTips))
+	for threadID, out := range state.threadTips {
+		threadTips[threadID] = out
+	}
+	coinbaseOuts := make([]scenarioCoinbaseOut, len(state.coinbaseOuts))
+	copy(coinbaseOuts, state.coinbaseOuts)
+	return &scenarioState{
+		threadTips:   threadTips,
+		coinbaseOuts: coinbaseOuts,
+	}
+}
+
+// step performs the passed step of a scenario.
+func (g *scenarioGenerator) step(step *ScenarioStep) error {
+	count := step.Count
+	if count == 0 {
+		count = 1
+	}
+	if count < 0 {
+		return fmt.Errorf("invalid count %d", step.Count)
+	}
+
+	switch step.Op {
+	case ScenarioOpFork:
+		if _, ok := g.states[step.From]; !ok {
+			return fmt.Errorf("unknown block %q to fork from",
+				step.From)
+		}
+		g.setTip(step.From)
+		fallthrough
+
+	case ScenarioOpMine:
+		for i := 0; i < count; i++ {
+			name := ""
+			if i == count-1 {
+				name = step.Name
+			}
+			if err := g.mine(name, g.tipState(), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case ScenarioOpAdmin:
+		state := g.tipState()
+		tx, err := g.adminTx(step, state)
+		if err != nil {
+			return err
+		}
+		return g.mine(step.Name, state, []*wire.MsgTx{tx})
+
+	case ScenarioOpTxBatch:
+		state := g.tipState()
+		txns, err := g.batchTxns(count, state)
+		if err != nil {
+			return err
+		}
+		return g.mine(step.Name, state, txns)
+	}
+
+	return fmt.Errorf("unknown operation %q", step.Op)
+}
+
+// newScenarioGenerator returns a scenario generator with the genesis block of
+// the regression test network as the tip, which draws from a source seeded with
+// the passed seed.  Blocks are timestamped from the passed Unix time, or from
+// one day after the genesis block when it is zero.
+func newScenarioGenerator(seed, startTime int64) (*scenarioGenerator, error) {
+	tg, err := makeTestGenerator(&chaincfg.RegressionNetParams)
+	if err != nil {
+		return nil, err
+	}
+	tg.rng = rand.New(rand.NewSource(seed))
+	tg.startTime = tg.tip.Header.Timestamp.Add(24 * time.Hour)
+	if startTime != 0 {
+		tg.startTime = time.Unix(startTime, 0)
+	}
+
+	g := &scenarioGenerator{
+		testGenerator: tg,
+		states:        make(map[string]*scenarioState),
+	}
+	g.states["genesis"] = &scenarioState{
+		threadTips: map[provautil.ThreadID]spendableOut{
+			provautil.RootThread:      makeSpendableOut(g.tip, 0, 0),
+			provautil.ProvisionThread: makeSpendableOut(g.tip, 0, 1),
+			provautil.IssueThread:     makeSpendableOut(g.tip, 0, 2),
+		},
+	}
+	return g, nil
+}
+
+// GenerateScenario generates the blocks of the passed scenario in the order
+// they are mined.  The same scenario always generates the same blocks, which
+// makes it possible to reproduce the chain, including any reorgs and admin
+// operations, which triggered a consensus bug.  Like the tests returned by
+// Generate, the blocks build from the genesis block of the regression test
+// network.
+func GenerateScenario(scenario *Scenario) (blocks []ScenarioBlock, err error) {
+	// Steps which fail due to an invalid scenario return errors, however
+	// the underlying generator panics on failures such as being unable to
+	// solve a block, so recover from those the same way Generate does.
+	defer func() {
+		if r := recover(); r != nil {
+			blocks = nil
+
+			switch rt := r.(type) {
+			case string:
+				err = errors.New(rt)
+			case error:
+				err = rt
+			default:
+				err = errors.New("Unknown panic")
+			}
+		}
+	}()
+
+	g, err := newScenarioGenerator(scenario.Seed, scenario.StartTime)
+	if err != nil {
+		return nil, err
+	}
+	for i := range scenario.Steps {
+		if err := g.step(&scenario.Steps[i]); err != nil {
+			return nil, fmt.Errorf("step %d (%s): %v", i+1,
+				scenario.Steps[i].Op, err)
+		}
+	}
+	return g.blocks, nil
+}
@@ -0,0 +1,596 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// addrBalanceIndexName is the human-readable name for the index.
+	addrBalanceIndexName = "address balance index"
+
+	// addrBalanceKeyTypeBalance is the type of a key in the address
+	// balance index which houses the balance of an address or key ID.
+	addrBalanceKeyTypeBalance = 'b'
+
+	// addrBalanceKeyTypeUtxo is the type of a key in the address balance
+	// index which houses an unspent output paying to an address or key ID.
+	addrBalanceKeyTypeUtxo = 'u'
+
+	// addrBalanceKeySize is the number of bytes a balance key consumes.  It
+	// consists of 1 byte key type + the address or key ID prefix.
+	addrBalanceKeySize = 1 + addrValuePrefixSize
+
+	// addrUtxoKeySize is the number of bytes an unspent output key
+	// consumes.  It consists of the balance key + 32 bytes tx hash + 4
+	// bytes output index.
+	addrUtxoKeySize = addrBalanceKeySize + chainhash.HashSize + 4
+
+	// addrBalanceEntrySize is the number of bytes a balance value consumes.
+	// It consists of 8 bytes balance + 4 bytes unspent output count.
+	addrBalanceEntrySize = 8 + 4
+
+	// addrUtxoEntryMinSize is the minimum number of bytes an unspent output
+	// value consumes.  It consists of 8 bytes value + 4 bytes block height
+	// followed by the public key script.
+	addrUtxoEntryMinSize = 8 + 4
+)
+
+var (
+	// addrBalanceIndexKey is the key of the address balance index and the
+	// db bucket used to house it.
+	addrBalanceIndexKey = []byte("addrbalanceidx")
+)
+
+// -----------------------------------------------------------------------------
+// The address balance index tracks the current balance of every address and
+// every key ID referenced by an address along with the unspent outputs making
+// up the balance.  Addresses and key IDs are identified by the same prefix used
+// by the address value index.  Balances are updated in place as blocks are
+// connected and disconnected, so they can be queried without replaying the
+// history of the address.
+//
+// There are two kinds of entries which are distinguished by the first byte of
+// their keys.
+//
+// The serialized format for balance entries is:
+//
+//   'b'<prefix> = <balance><utxo count>
+//
+//   Field           Type      Size
+//   prefix          [21]byte  21 bytes
+//   balance         int64     8 bytes
+//   utxo count      uint32    4 bytes
+//
+// The serialized format for unspent output entries is:
+//
+//   'u'<prefix><txhash><index> = <value><block height><pkscript>
+//
+//   Field           Type              Size
+//   prefix          [21]byte          21 bytes
+//   txhash          chainhash.Hash    32 bytes
+//   index           uint32            4 bytes (big endian)
+//   value           int64             8 bytes
+//   block height    uint32            4 bytes
+//   pkscript        []byte            variable
+//
+// Entries for addresses and key IDs without any unspent outputs are removed.
+// -----------------------------------------------------------------------------
+
+// AddrBalance houses the current balance of an address or key ID.
+type AddrBalance struct {
+	// Balance is the total value of the unspent outputs.
+	Balance int64
+
+	// NumUtxos is the number of unspent outputs.
+	NumUtxos uint32
+}
+
+// AddrUtxo describes an unspent output paying to an address or key ID.
+type AddrUtxo struct {
+	// OutPoint identifies the unspent output.
+	OutPoint wire.OutPoint
+
+	// Value is the value of the output.
+	Value int64
+
+	// Height is the height of the block containing the output.
+	Height uint32
+
+	// PkScript is the public key script of the output.
+	PkScript []byte
+}
+
+// addrBalanceKeyFor returns the key used to store the balance for the passed
+// address or key ID prefix.
+func addrBalanceKeyFor(prefix [addrValuePrefixSize]byte) []byte {
+	key := make([]byte, addrBalanceKeySize)
+	key[0] = addrBalanceKeyTypeBalance
+	copy(key[1:], prefix[:])
+	return key
+}
+
+// addrUtxoKeyFor returns the key used to store the passed unspent output for
+// the passed address or key ID prefix.
+func addrUtxoKeyFor(prefix [addrValuePrefixSize]byte, outPoint *wire.OutPoint) []byte {
+	key := make([]byte, addrUtxoKeySize)
+	key[0] = addrBalanceKeyTypeUtxo
+	offset := 1 + copy(key[1:], prefix[:])
+	offset += copy(key[offset:], outPoint.Hash[:])
+	binary.BigEndian.PutUint32(key[offset:], outPoint.Index)
+	return key
+}
+
+// serializeAddrBalance serializes the passed balance according to the format
+// described in detail above.
+func serializeAddrBalance(balance *AddrBalance) []byte {
+	serialized := make([]byte, addrBalanceEntrySize)
+	byteOrder.PutUint64(serialized, uint64(balance.Balance))
+	byteOrder.PutUint32(serialized[8:], balance.NumUtxos)
+	return serialized
+}
+
+// deserializeAddrBalance decodes the passed serialized balance into the passed
+// balance.
+func deserializeAddrBalance(serialized []byte, balance *AddrBalance) error {
+	if len(serialized) != addrBalanceEntrySize {
+		return errDeserialize("unexpected address balance entry length")
+	}
+	balance.Balance = int64(byteOrder.Uint64(serialized))
+	balance.NumUtxos = byteOrder.Uint32(serialized[8:])
+	return nil
+}
+
+// serializeAddrUtxo serializes the value of the passed unspent output according
+// to the format described in detail above.
+func serializeAddrUtxo(utxo *AddrUtxo) []byte {
+	serialized := make([]byte, addrUtxoEntryMinSize+len(utxo.PkScript))
+	byteOrder.PutUint64(serialized, uint64(utxo.Value))
+	byteOrder.PutUint32(serialized[8:], utxo.Height)
+	copy(serialized[addrUtxoEntryMinSize:], utxo.PkScript)
+	return serialized
+}
+
+// deserializeAddrUtxo decodes the passed key and serialized value into the
+// passed unspent output.
+func deserializeAddrUtxo(key, serialized []byte, utxo *AddrUtxo) error {
+	if len(key) != addrUtxoKeySize {
+		return errDeserialize("unexpected address utxo key length")
+	}
+	if len(serialized) < addrUtxoEntryMinSize {
+		return errDeserialize("unexpected end of address utxo data")
+	}
+
+	offset := addrBalanceKeySize
+	offset += copy(utxo.OutPoint.Hash[:], key[offset:])
+	utxo.OutPoint.Index = binary.BigEndian.Uint32(key[offset:])
+	utxo.Value = int64(byteOrder.Uint64(serialized))
+	utxo.Height = byteOrder.Uint32(serialized[8:])
+	utxo.PkScript = make([]byte, len(serialized)-addrUtxoEntryMinSize)
+	copy(utxo.PkScript, serialized[addrUtxoEntryMinSize:])
+	return nil
+}
+
+// dbFetchAddrBalance uses an existing database transaction to retrieve the
+// balance for the passed address or key ID prefix.  A zero balance is returned
+// when there is no entry.
+func dbFetchAddrBalance(dbTx database.Tx, prefix [addrValuePrefixSize]byte) (AddrBalance, error) {
+	bucket := dbTx.Metadata().Bucket(addrBalanceIndexKey)
+	return fetchAddrBalance(bucket, prefix)
+}
+
+// fetchAddrBalance retrieves the balance for the passed address or key ID
+// prefix from the passed bucket.  A zero balance is returned when there is no
+// entry.
+func fetchAddrBalance(bucket database.Bucket, prefix [addrValuePrefixSize]byte) (AddrBalance, error) {
+	var balance AddrBalance
+	serialized := bucket.Get(addrBalanceKeyFor(prefix))
+	if serialized == nil {
+		return balance, nil
+	}
+	if err := deserializeAddrBalance(serialized, &balance); err != nil {
+		return balance, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: "failed to deserialize address balance " +
+				"entry: " + err.Error(),
+		}
+	}
+	return balance, nil
+}
+
+// dbFetchAddrUtxos uses an existing database transaction to retrieve all of the
+// unspent outputs for the passed address or key ID prefix.
+func dbFetchAddrUtxos(dbTx database.Tx, prefix [addrValuePrefixSize]byte) ([]AddrUtxo, error) {
+	return fetchAddrUtxos(dbTx.Metadata().Bucket(addrBalanceIndexKey), prefix)
+}
+
+// fetchAddrUtxos retrieves all of the unspent outputs for the passed address or
+// key ID prefix from the passed bucket.
+func fetchAddrUtxos(bucket database.Bucket, prefix [addrValuePrefixSize]byte) ([]AddrUtxo, error) {
+	var utxos []AddrUtxo
+	keyPrefix := make([]byte, addrBalanceKeySize)
+	keyPrefix[0] = addrBalanceKeyTypeUtxo
+	copy(keyPrefix[1:], prefix[:])
+
+	cursor := bucket.Cursor()
+	for ok := cursor.Seek(keyPrefix); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if !bytes.HasPrefix(key, keyPrefix) {
+			break
+		}
+
+		var utxo AddrUtxo
+		if err := deserializeAddrUtxo(key, cursor.Value(), &utxo); err != nil {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: "failed to deserialize address " +
+					"utxo entry: " + err.Error(),
+			}
+		}
+		utxos = append(utxos, utxo)
+	}
+
+	return utxos, nil
+}
+
+// addrBalanceDelta houses the change in balance and number of unspent outputs
+// of an address or key ID caused by a block.
+type addrBalanceDelta struct {
+	balance  int64
+	numUtxos int64
+}
+
+// addrBalanceIndexData represents the address balance index changes to be
+// written for one block.  It maps each address or key ID prefix to the change
+// of its balance.
+type addrBalanceIndexData map[[addrValuePrefixSize]byte]*addrBalanceDelta
+
+// AddrBalanceIndex implements an index of the current balance of every address
+// and key ID.  That is to say, it supports querying the confirmed balance and
+// unspent outputs of a given address or key ID.
+type AddrBalanceIndex struct {
+	db          database.DB
+	chainParams *chaincfg.Params
+}
+
+// Ensure the AddrBalanceIndex type implements the Indexer interface.
+var _ Indexer = (*AddrBalanceIndex)(nil)
+
+// Ensure the AddrBalanceIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*AddrBalanceIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *AddrBalanceIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *AddrBalanceIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *AddrBalanceIndex) Key() []byte {
+	return addrBalanceIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *AddrBalanceIndex) Name() string {
+	return addrBalanceIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the address
+// balance index.
+//
+// This is part of the Indexer interface.
+func (idx *AddrBalanceIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(addrBalanceIndexKey)
+	return err
+}
+
+// scriptPrefixes returns the prefixes of the Prova addresses the passed public
+// key script pays to along with the prefixes of the key IDs they reference.
+func scriptPrefixes(pkScript []byte, chainParams *chaincfg.Params) [][addrValuePrefixSize]byte {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, chainParams)
+	if err != nil {
+		return nil
+	}
+
+	var prefixes [][addrValuePrefixSize]byte
+	for _, addr := range addrs {
+		prefix, err := addrValueKeyForAddr(addr)
+		if err != nil {
+			// Ignore unsupported address types.
+			continue
+		}
+		prefixes = append(prefixes, prefix)
+
+		for _, keyID := range addr.(*provautil.AddressProva).ScriptKeyIDs() {
+			prefixes = append(prefixes, addrValueKeyForKeyID(keyID))
+		}
+	}
+	return prefixes
+}
+
+// addUtxo stores the passed unspent output under every address and key ID its
+// public key script pays to and records the change in balance.
+func (idx *AddrBalanceIndex) addUtxo(bucket database.Bucket, data addrBalanceIndexData, utxo *AddrUtxo) error {
+	for _, prefix := range scriptPrefixes(utxo.PkScript, idx.chainParams) {
+		key := addrUtxoKeyFor(prefix, &utxo.OutPoint)
+		if err := bucket.Put(key, serializeAddrUtxo(utxo)); err != nil {
+			return err
+		}
+		data.add(prefix, utxo.Value, 1)
+	}
+	return nil
+}
+
+// removeUtxo removes the passed unspent output from every address and key ID
+// its public key script pays to and records the change in balance.
+func (idx *AddrBalanceIndex) removeUtxo(bucket database.Bucket, data addrBalanceIndexData, utxo *AddrUtxo) error {
+	for _, prefix := range scriptPrefixes(utxo.PkScript, idx.chainParams) {
+		key := addrUtxoKeyFor(prefix, &utxo.OutPoint)
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+		data.add(prefix, -utxo.Value, -1)
+	}
+	return nil
+}
+
+// add records the passed change in balance and number of unspent outputs for
+// the passed address or key ID prefix.
+func (data addrBalanceIndexData) add(prefix [addrValuePrefixSize]byte, value, numUtxos int64) {
+	delta := data[prefix]
+	if delta == nil {
+		delta = &addrBalanceDelta{}
+		data[prefix] = delta
+	}
+	delta.balance += value
+	delta.numUtxos += numUtxos
+}
+
+// spentUtxo returns the unspent output referenced by the passed input using the
+// passed view.  Nil is returned when the view does not contain it.
+func spentUtxo(view *blockchain.UtxoViewpoint, txIn *wire.TxIn) *AddrUtxo {
+	// The view should always have the input since the index contract
+	// requires it, however, be safe and simply ignore any missing entries.
+	origin := &txIn.PreviousOutPoint
+	entry := view.LookupEntry(&origin.Hash)
+	if entry == nil {
+		return nil
+	}
+
+	return &AddrUtxo{
+		OutPoint: *origin,
+		Value:    entry.AmountByIndex(origin.Index),
+		Height:   uint32(entry.BlockHeight()),
+		PkScript: entry.PkScriptByIndex(origin.Index),
+	}
+}
+
+// applyAddrBalanceDeltas updates the balance entries in the passed bucket with
+// the passed changes.  Entries without any unspent outputs left are removed.
+func applyAddrBalanceDeltas(bucket database.Bucket, data addrBalanceIndexData) error {
+	for prefix, delta := range data {
+		if delta.balance == 0 && delta.numUtxos == 0 {
+			continue
+		}
+
+		balance, err := fetchAddrBalance(bucket, prefix)
+		if err != nil {
+			return err
+		}
+		balance.Balance += delta.balance
+		balance.NumUtxos = uint32(int64(balance.NumUtxos) + delta.numUtxos)
+
+		key := addrBalanceKeyFor(prefix)
+		if balance.NumUtxos == 0 {
+			err = bucket.Delete(key)
+		} else {
+			err = bucket.Put(key, serializeAddrBalance(&balance))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer removes the outputs spent by the
+// transactions in the block from the addresses and key IDs they pay to, adds
+// the outputs created by them, and updates the balances accordingly.
+//
+// This is part of the Indexer interface.
+func (idx *AddrBalanceIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(addrBalanceIndexKey)
+	data := make(addrBalanceIndexData)
+	for txIdx, tx := range block.Transactions() {
+		// Coinbases do not reference any inputs.  Since the block is
+		// required to have already gone through full validation, it has
+		// already been proven on the first transaction in the block is
+		// a coinbase.
+		if txIdx != 0 {
+			for _, txIn := range tx.MsgTx().TxIn {
+				utxo := spentUtxo(view, txIn)
+				if utxo == nil {
+					continue
+				}
+				if err := idx.removeUtxo(bucket, data, utxo); err != nil {
+					return err
+				}
+			}
+		}
+
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			utxo := AddrUtxo{
+				OutPoint: wire.OutPoint{
+					Hash:  *tx.Hash(),
+					Index: uint32(txOutIdx),
+				},
+				Value:    txOut.Value,
+				Height:   block.Height(),
+				PkScript: txOut.PkScript,
+			}
+			if err := idx.addUtxo(bucket, data, &utxo); err != nil {
+				return err
+			}
+		}
+	}
+
+	return applyAddrBalanceDeltas(bucket, data)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the outputs created
+// by the transactions in the block, restores the outputs they spent, and
+// updates the balances accordingly.
+//
+// This is part of the Indexer interface.
+func (idx *AddrBalanceIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	// Undo the transactions in reverse order so outputs created and spent
+	// within the block are handled properly.
+	bucket := dbTx.Metadata().Bucket(addrBalanceIndexKey)
+	data := make(addrBalanceIndexData)
+	transactions := block.Transactions()
+	for txIdx := len(transactions) - 1; txIdx >= 0; txIdx-- {
+		tx := transactions[txIdx]
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			utxo := AddrUtxo{
+				OutPoint: wire.OutPoint{
+					Hash:  *tx.Hash(),
+					Index: uint32(txOutIdx),
+				},
+				Value:    txOut.Value,
+				PkScript: txOut.PkScript,
+			}
+			if err := idx.removeUtxo(bucket, data, &utxo); err != nil {
+				return err
+			}
+		}
+
+		if txIdx == 0 {
+			continue
+		}
+		for _, txIn := range tx.MsgTx().TxIn {
+			utxo := spentUtxo(view, txIn)
+			if utxo == nil {
+				continue
+			}
+			if err := idx.addUtxo(bucket, data, utxo); err != nil {
+				return err
+			}
+		}
+	}
+
+	return applyAddrBalanceDeltas(bucket, data)
+}
+
+// balance returns the balance stored under the passed prefix.
+func (idx *AddrBalanceIndex) balance(prefix [addrValuePrefixSize]byte) (AddrBalance, error) {
+	var balance AddrBalance
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		balance, err = dbFetchAddrBalance(dbTx, prefix)
+		return err
+	})
+	return balance, err
+}
+
+// utxos returns the unspent outputs stored under the passed prefix.
+func (idx *AddrBalanceIndex) utxos(prefix [addrValuePrefixSize]byte) ([]AddrUtxo, error) {
+	var utxos []AddrUtxo
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		utxos, err = dbFetchAddrUtxos(dbTx, prefix)
+		return err
+	})
+	return utxos, err
+}
+
+// BalanceForAddress returns the current balance of the passed address.  An
+// error is returned for unsupported address types.
+//
+// This function is safe for concurrent access.
+func (idx *AddrBalanceIndex) BalanceForAddress(addr provautil.Address) (AddrBalance, error) {
+	prefix, err := addrValueKeyForAddr(addr)
+	if err != nil {
+		return AddrBalance{}, err
+	}
+	return idx.balance(prefix)
+}
+
+// BalanceForKeyID returns the current balance of all addresses referencing the
+// passed key ID.
+//
+// This function is safe for concurrent access.
+func (idx *AddrBalanceIndex) BalanceForKeyID(keyID btcec.KeyID) (AddrBalance, error) {
+	return idx.balance(addrValueKeyForKeyID(keyID))
+}
+
+// UtxosForAddress returns the unspent outputs paying to the passed address.  An
+// error is returned for unsupported address types.
+//
+// This function is safe for concurrent access.
+func (idx *AddrBalanceIndex) UtxosForAddress(addr provautil.Address) ([]AddrUtxo, error) {
+	prefix, err := addrValueKeyForAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+	return idx.utxos(prefix)
+}
+
+// UtxosForKeyID returns the unspent outputs paying to addresses referencing the
+// passed key ID.
+//
+// This function is safe for concurrent access.
+func (idx *AddrBalanceIndex) UtxosForKeyID(keyID btcec.KeyID) ([]AddrUtxo, error) {
+	return idx.utxos(addrValueKeyForKeyID(keyID))
+}
+
+// NewAddrBalanceIndex returns a new instance of an indexer that is used to
+// maintain the current balance and unspent outputs of all addresses and the key
+// IDs they reference.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewAddrBalanceIndex(db database.DB, chainParams *chaincfg.Params) *AddrBalanceIndex {
+	return &AddrBalanceIndex{
+		db:          db,
+		chainParams: chainParams,
+	}
+}
+
+// DropAddrBalanceIndex drops the address balance index from the provided
+// database if it exists.
+func DropAddrBalanceIndex(db database.DB) error {
+	return dropIndex(db, addrBalanceIndexKey, addrBalanceIndexName)
+}
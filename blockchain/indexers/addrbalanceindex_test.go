@@ -0,0 +1,151 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestAddrBalanceSerialization ensures address balance and unspent output
+// entries round trip through serialization and that the unspent outputs of a
+// prefix are grouped after its balance entry.
+func TestAddrBalanceSerialization(t *testing.T) {
+	t.Parallel()
+
+	balance := AddrBalance{Balance: 1e12, NumUtxos: 3}
+	var gotBalance AddrBalance
+	err := deserializeAddrBalance(serializeAddrBalance(&balance), &gotBalance)
+	if err != nil {
+		t.Fatalf("unexpected balance error: %v", err)
+	}
+	if gotBalance != balance {
+		t.Fatalf("mismatched balance - got %+v, want %+v", gotBalance,
+			balance)
+	}
+	err = deserializeAddrBalance(serializeAddrBalance(&balance)[:8],
+		&gotBalance)
+	if !isDeserializeErr(err) {
+		t.Fatalf("unexpected error for short balance: %v", err)
+	}
+
+	prefix := addrValueKeyForKeyID(7)
+	utxo := AddrUtxo{
+		OutPoint: wire.OutPoint{
+			Hash:  chainhash.Hash{0x01},
+			Index: 258,
+		},
+		Value:    2500,
+		Height:   500,
+		PkScript: []byte{0x52, 0x14, 0x01, 0x02},
+	}
+	key := addrUtxoKeyFor(prefix, &utxo.OutPoint)
+	serialized := serializeAddrUtxo(&utxo)
+
+	var gotUtxo AddrUtxo
+	if err := deserializeAddrUtxo(key, serialized, &gotUtxo); err != nil {
+		t.Fatalf("unexpected utxo error: %v", err)
+	}
+	if !reflect.DeepEqual(gotUtxo, utxo) {
+		t.Fatalf("mismatched utxo - got %+v, want %+v", gotUtxo, utxo)
+	}
+	err = deserializeAddrUtxo(key, serialized[:10], &gotUtxo)
+	if !isDeserializeErr(err) {
+		t.Fatalf("unexpected error for short utxo: %v", err)
+	}
+
+	// Unspent output keys must not be confused with balance keys and must
+	// share a common prefix so they can be scanned with a cursor.
+	balanceKey := addrBalanceKeyFor(prefix)
+	if bytes.HasPrefix(key, balanceKey) {
+		t.Fatalf("utxo key %x shares prefix with balance key %x", key,
+			balanceKey)
+	}
+	otherKey := addrUtxoKeyFor(addrValueKeyForKeyID(8), &utxo.OutPoint)
+	if bytes.Compare(key, otherKey) >= 0 {
+		t.Fatalf("utxo key for key ID 7 does not sort before key ID 8")
+	}
+}
+
+// TestAddrBalanceIndexConnectBlock ensures the address balance index tracks
+// the balances and unspent outputs of addresses and key IDs as blocks are
+// connected, and restores the spent outputs when the blocks are disconnected.
+func TestAddrBalanceIndexConnectBlock(t *testing.T) {
+	t.Parallel()
+
+	db, teardown := newTestIndexDB(t, "addrbalanceindex")
+	defer teardown()
+
+	c, addrA, addrB := newTestPaymentChain(t)
+	idx := NewAddrBalanceIndex(db, &chaincfg.MainNetParams)
+	connectTestChain(t, db, idx, c)
+
+	checkAddr := func(addr provautil.Address, want AddrBalance) {
+		balance, err := idx.BalanceForAddress(addr)
+		if err != nil {
+			t.Fatalf("BalanceForAddress: unexpected error: %v", err)
+		}
+		if balance != want {
+			t.Fatalf("BalanceForAddress: got balance %+v for %v, "+
+				"want %+v", balance, addr, want)
+		}
+	}
+	checkKeyID := func(keyID btcec.KeyID, want AddrBalance) {
+		balance, err := idx.BalanceForKeyID(keyID)
+		if err != nil {
+			t.Fatalf("BalanceForKeyID: unexpected error: %v", err)
+		}
+		if balance != want {
+			t.Fatalf("BalanceForKeyID: got balance %+v for key ID "+
+				"%d, want %+v", balance, keyID, want)
+		}
+	}
+	checkUtxos := func(want AddrUtxo) {
+		utxos, err := idx.UtxosForAddress(addrA)
+		if err != nil {
+			t.Fatalf("UtxosForAddress: unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(utxos, []AddrUtxo{want}) {
+			t.Fatalf("UtxosForAddress: got outputs %+v, want %+v",
+				utxos, want)
+		}
+	}
+
+	// Ensure the output funding address A is replaced by the change once
+	// the second block spends it.
+	fund, spend := c.txns[0], c.txns[1]
+	scriptA := fund.TxOut[0].PkScript
+	checkAddr(addrA, AddrBalance{Balance: 400, NumUtxos: 1})
+	checkAddr(addrB, AddrBalance{Balance: 600, NumUtxos: 1})
+	checkKeyID(1, AddrBalance{Balance: 1000, NumUtxos: 2})
+	checkKeyID(2, AddrBalance{Balance: 400, NumUtxos: 1})
+	checkUtxos(AddrUtxo{
+		OutPoint: wire.OutPoint{Hash: spend.TxHash(), Index: 1},
+		Value:    400,
+		Height:   2,
+		PkScript: scriptA,
+	})
+
+	// Ensure the spent output is restored and the outputs of the second
+	// block are removed once it is disconnected.
+	disconnectTestTip(t, db, idx, c)
+	checkAddr(addrA, AddrBalance{Balance: 1000, NumUtxos: 1})
+	checkAddr(addrB, AddrBalance{})
+	checkKeyID(1, AddrBalance{Balance: 1000, NumUtxos: 1})
+	checkKeyID(3, AddrBalance{})
+	checkUtxos(AddrUtxo{
+		OutPoint: wire.OutPoint{Hash: fund.TxHash()},
+		Value:    1000,
+		Height:   1,
+		PkScript: scriptA,
+	})
+}
@@ -6,11 +6,13 @@
 package indexers
 
 import (
+	"encoding/binary"
 	"errors"
 	"fmt"
 	"sync"
 
 	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/database"
@@ -23,6 +25,10 @@ const (
 	// addrIndexName is the human-readable name for the index.
 	addrIndexName = "address index"
 
+	// addrIndexVersion is the version of the format of the address index.
+	// Version 2 added the entries for the key IDs bound to Prova outputs.
+	addrIndexVersion = 2
+
 	// level0MaxEntries is the maximum number of transactions that are
 	// stored in level 0 of an address index entry.  Subsequent levels store
 	// 2^n * level0MaxEntries entries, or in words, double the maximum of
@@ -52,6 +58,11 @@ const (
 	// hash.
 	addrKeyTypeScriptHash = 1
 
+	// addrKeyTypeKeyID is the address type in an address key which
+	// represents a key ID bound to Prova outputs.  The key ID is stored
+	// big endian at the start of the hash, which is otherwise zero.
+	addrKeyTypeKeyID = 2
+
 	// Size of a transaction entry.  It consists of 4 bytes block id + 4
 	// bytes offset + 4 bytes length.
 	txEntrySize = 4 + 4 + 4
@@ -77,6 +88,13 @@ var (
 // since it is needed in order to catch up old blocks due to the fact the spent
 // outputs will already be pruned from the utxo set.
 //
+// Prova outputs, both standard and generalized ones, are indexed under the hash
+// of every public key they are bound to, independent of the key IDs attached to
+// the address, as well as under every key ID they are bound to.  This way the
+// transactions of an address are found regardless of the key IDs it was created
+// with, and the transactions involving a key ID are found regardless of the
+// addresses it was bound to.
+//
 // The approach used to store the index is similar to a log-structured merge
 // tree (LSM tree) and is thus similar to how leveldb works internally.
 //
@@ -108,7 +126,7 @@ var (
 //
 //   Field           Type      Size
 //   addr type       uint8     1 byte
-//   addr hash       hash160   20 bytes
+//   addr hash       hash160   20 bytes (or key id and zero padding)
 //   level           uint8     1 byte
 //   -----
 //   Total: 22 bytes
@@ -578,6 +596,32 @@ func addrToKey(addr provautil.Address) ([addrKeySize]byte, error) {
 	return [addrKeySize]byte{}, errUnsupportedAddressType
 }
 
+// keyIDToKey converts a key ID to an addrindex key.
+func keyIDToKey(keyID btcec.KeyID) [addrKeySize]byte {
+	var result [addrKeySize]byte
+	result[0] = addrKeyTypeKeyID
+	binary.BigEndian.PutUint32(result[1:], uint32(keyID))
+	return result
+}
+
+// scriptAddrKeys returns the addrindex keys of the public key hashes and the key
+// IDs the passed public key script is bound to.  Scripts other than Prova
+// scripts have none.
+func scriptAddrKeys(pkScript []byte) [][addrKeySize]byte {
+	keyHashes, keyIDs := txscript.ExtractProvaKeys(pkScript)
+	addrKeys := make([][addrKeySize]byte, 0, len(keyHashes)+len(keyIDs))
+	for _, keyHash := range keyHashes {
+		var addrKey [addrKeySize]byte
+		addrKey[0] = addrKeyTypePubKeyHash
+		copy(addrKey[1:], keyHash)
+		addrKeys = append(addrKeys, addrKey)
+	}
+	for _, keyID := range keyIDs {
+		addrKeys = append(addrKeys, keyIDToKey(keyID))
+	}
+	return addrKeys
+}
+
 // AddrIndex implements a transaction by address index.  That is to say, it
 // supports querying all transactions that reference a given address because
 // they are either crediting or debiting the address.  The returned transactions
@@ -618,6 +662,9 @@ var _ Indexer = (*AddrIndex)(nil)
 // Ensure the AddrIndex type implements the NeedsInputser interface.
 var _ NeedsInputser = (*AddrIndex)(nil)
 
+// Ensure the AddrIndex type implements the Versioner interface.
+var _ Versioner = (*AddrIndex)(nil)
+
 // NeedsInputs signals that the index requires the referenced inputs in order
 // to properly create the index.
 //
@@ -626,6 +673,14 @@ func (idx *AddrIndex) NeedsInputs() bool {
 	return true
 }
 
+// Version returns the version of the format of the address index, so indexes
+// built before the key ID entries were added are rebuilt.
+//
+// This implements the Versioner interface.
+func (idx *AddrIndex) Version() uint32 {
+	return addrIndexVersion
+}
+
 // Init is only provided to satisfy the Indexer interface as there is nothing to
 // initialize for this index.
 //
@@ -665,25 +720,12 @@ func (idx *AddrIndex) Create(dbTx database.Tx) error {
 // stored in the order they appear in the block.
 type writeIndexData map[[addrKeySize]byte][]int
 
-// indexPkScript extracts all standard addresses from the passed public key
-// script and maps each of them to the associated transaction using the passed
-// map.
+// indexPkScript extracts all public key hashes and key IDs from the passed
+// public key script and maps each of them to the associated transaction using
+// the passed map.
 func (idx *AddrIndex) indexPkScript(data writeIndexData, pkScript []byte, txIdx int) {
-	// Nothing to index if the script is non-standard or otherwise doesn't
-	// contain any addresses.
-	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript,
-		idx.chainParams)
-	if err != nil || len(addrs) == 0 {
-		return
-	}
-
-	for _, addr := range addrs {
-		addrKey, err := addrToKey(addr)
-		if err != nil {
-			// Ignore unsupported address types.
-			continue
-		}
-
+	// Nothing to index if the script is not a Prova script.
+	for _, addrKey := range scriptAddrKeys(pkScript) {
 		// Avoid inserting the transaction more than once.  Since the
 		// transactions are indexed serially any duplicates will be
 		// indexed in a row, so checking the most recent entry for the
@@ -840,9 +882,33 @@ func (idx *AddrIndex) TxRegionsForAddress(dbTx database.Tx, addr provautil.Addre
 		return nil, 0, err
 	}
 
+	return idx.txRegionsForKey(addrKey, numToSkip, numRequested, reverse)
+}
+
+// TxRegionsForKeyID returns a slice of block regions which identify each
+// transaction that involves an output bound to the passed key ID according to
+// the specified number to skip, number requested, and whether or not the
+// results should be reversed.  It also returns the number actually skipped
+// since it could be less in the case where there are not enough entries.
+//
+// NOTE: These results only include transactions confirmed in blocks.  See the
+// UnconfirmedTxnsForKeyID method for obtaining unconfirmed transactions that
+// involve a given key ID.
+//
+// This function is safe for concurrent access.
+func (idx *AddrIndex) TxRegionsForKeyID(keyID btcec.KeyID, numToSkip, numRequested uint32, reverse bool) ([]database.BlockRegion, uint32, error) {
+	return idx.txRegionsForKey(keyIDToKey(keyID), numToSkip, numRequested,
+		reverse)
+}
+
+// txRegionsForKey returns the block regions which identify each transaction
+// indexed under the passed addrindex key as described by TxRegionsForAddress.
+//
+// This function is safe for concurrent access.
+func (idx *AddrIndex) txRegionsForKey(addrKey [addrKeySize]byte, numToSkip, numRequested uint32, reverse bool) ([]database.BlockRegion, uint32, error) {
 	var regions []database.BlockRegion
 	var skipped uint32
-	err = idx.db.View(func(dbTx database.Tx) error {
+	err := idx.db.View(func(dbTx database.Tx) error {
 		// Create closure to lookup the block hash given the ID using
 		// the database transaction.
 		fetchBlockHash := func(id []byte) (*chainhash.Hash, error) {
@@ -862,23 +928,12 @@ func (idx *AddrIndex) TxRegionsForAddress(dbTx database.Tx, addr provautil.Addre
 }
 
 // indexUnconfirmedAddresses modifies the unconfirmed (memory-only) address
-// index to include mappings for the addresses encoded by the passed public key
-// script to the transaction.
+// index to include mappings for the public key hashes and key IDs the passed
+// public key script is bound to to the transaction.
 //
 // This function is safe for concurrent access.
 func (idx *AddrIndex) indexUnconfirmedAddresses(pkScript []byte, tx *provautil.Tx) {
-	// The error is ignored here since the only reason it can fail is if the
-	// script fails to parse and it was already validated before being
-	// admitted to the mempool.
-	_, addresses, _, _ := txscript.ExtractPkScriptAddrs(pkScript,
-		idx.chainParams)
-	for _, addr := range addresses {
-		// Ignore unsupported address types.
-		addrKey, err := addrToKey(addr)
-		if err != nil {
-			continue
-		}
-
+	for _, addrKey := range scriptAddrKeys(pkScript) {
 		// Add a mapping from the address to the transaction.
 		idx.unconfirmedLock.Lock()
 		addrIndexEntry := idx.txnsByAddr[addrKey]
@@ -966,6 +1021,23 @@ func (idx *AddrIndex) UnconfirmedTxnsForAddress(addr provautil.Address) []*prova
 		return nil
 	}
 
+	return idx.unconfirmedTxnsForKey(addrKey)
+}
+
+// UnconfirmedTxnsForKeyID returns all transactions currently in the unconfirmed
+// (memory-only) address index that involve an output bound to the passed key
+// ID.
+//
+// This function is safe for concurrent access.
+func (idx *AddrIndex) UnconfirmedTxnsForKeyID(keyID btcec.KeyID) []*provautil.Tx {
+	return idx.unconfirmedTxnsForKey(keyIDToKey(keyID))
+}
+
+// unconfirmedTxnsForKey returns all transactions currently in the unconfirmed
+// (memory-only) address index under the passed addrindex key.
+//
+// This function is safe for concurrent access.
+func (idx *AddrIndex) unconfirmedTxnsForKey(addrKey [addrKeySize]byte) []*provautil.Tx {
 	// Protect concurrent access.
 	idx.unconfirmedLock.RLock()
 	defer idx.unconfirmedLock.RUnlock()
@@ -8,8 +8,13 @@ package indexers
 import (
 	"bytes"
 	"fmt"
+	"reflect"
 	"testing"
 
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
 )
 
@@ -275,3 +280,73 @@ nextTest:
 		}
 	}
 }
+
+// TestScriptAddrKeys ensures Prova scripts, both standard and generalized ones,
+// are indexed under the same key as every address with one of their key hashes
+// regardless of its key IDs, and under each of their key IDs.
+func TestScriptAddrKeys(t *testing.T) {
+	t.Parallel()
+
+	hash1 := bytes.Repeat([]byte{0x11}, 20)
+	hash2 := bytes.Repeat([]byte{0x22}, 20)
+	standard, err := txscript.NewScriptBuilder().AddInt64(2).
+		AddData(hash1).AddInt64(5).AddInt64(6).AddInt64(3).
+		AddOp(txscript.OP_CHECKSAFEMULTISIG).Script()
+	if err != nil {
+		t.Fatalf("unable to build script: %v", err)
+	}
+	general, err := txscript.NewScriptBuilder().AddInt64(3).
+		AddData(hash1).AddData(hash2).AddInt64(5).AddInt64(6).
+		AddInt64(7).AddInt64(5).AddOp(txscript.OP_CHECKSAFEMULTISIG).
+		Script()
+	if err != nil {
+		t.Fatalf("unable to build script: %v", err)
+	}
+
+	// The key of an address only depends on its key hash.
+	addrKey := func(hash []byte, keyIDs ...btcec.KeyID) [addrKeySize]byte {
+		addr, err := provautil.NewAddressProva(hash, keyIDs,
+			&chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatalf("unable to create address: %v", err)
+		}
+		key, err := addrToKey(addr)
+		if err != nil {
+			t.Fatalf("addrToKey: unexpected error: %v", err)
+		}
+		return key
+	}
+
+	tests := []struct {
+		name   string
+		script []byte
+		want   [][addrKeySize]byte
+	}{
+		{
+			name:   "standard prova",
+			script: standard,
+			want: [][addrKeySize]byte{addrKey(hash1, 1, 2),
+				keyIDToKey(5), keyIDToKey(6)},
+		},
+		{
+			name:   "general prova",
+			script: general,
+			want: [][addrKeySize]byte{addrKey(hash1, 8, 9),
+				addrKey(hash2, 5, 6), keyIDToKey(5),
+				keyIDToKey(6), keyIDToKey(7)},
+		},
+		{
+			name:   "nulldata",
+			script: []byte{txscript.OP_RETURN},
+			want:   [][addrKeySize]byte{},
+		},
+	}
+
+	for _, test := range tests {
+		got := scriptAddrKeys(test.script)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("scriptAddrKeys (%s): got %x, want %x",
+				test.name, got, test.want)
+		}
+	}
+}
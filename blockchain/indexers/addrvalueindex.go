@@ -0,0 +1,560 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+const (
+	// addrValueIndexName is the human-readable name for the index.
+	addrValueIndexName = "address value index"
+
+	// addrValueKeyTypeAddress is the type in an address value key which
+	// represents the hash160 of a Prova address.
+	addrValueKeyTypeAddress = 0
+
+	// addrValueKeyTypeKeyID is the type in an address value key which
+	// represents a key ID referenced by a Prova address.  This is
+	// necessary to keep key IDs from colliding with address hashes.
+	addrValueKeyTypeKeyID = 1
+
+	// addrValuePrefixSize is the number of bytes the address or key ID
+	// portion of a key consumes.  It consists of 1 byte type + 20 bytes
+	// hash160 or big-endian key ID padded with zeros.
+	addrValuePrefixSize = 1 + 20
+
+	// addrValueKeySize is the number of bytes a key in the address value
+	// index consumes.  It consists of the prefix + 4 bytes block height +
+	// 4 bytes transaction index within the block.
+	addrValueKeySize = addrValuePrefixSize + 4 + 4
+
+	// addrValueEntrySize is the number of bytes a value in the address
+	// value index consumes.  It consists of 4 bytes block id + 4 bytes
+	// offset + 4 bytes length + 8 bytes received + 8 bytes sent.
+	addrValueEntrySize = txEntrySize + 8 + 8
+)
+
+var (
+	// addrValueIndexKey is the key of the address value index and the db
+	// bucket used to house it.
+	addrValueIndexKey = []byte("addrvalueidx")
+)
+
+// -----------------------------------------------------------------------------
+// The address value index maps addresses and the key IDs they reference to all
+// of the transactions involving them along with the value each transaction
+// moved to and from them.  Unlike the address index, every transaction is
+// stored under its own key so that entries can be paged through with a cursor
+// in order of appearance in the blockchain and the value data can be kept next
+// to the transaction location.  Just like the address index, this index
+// requires the transaction index since it relies on the block ID mappings.
+//
+// The serialized key format is:
+//
+//   <type><addr hash or key id><block height><tx index>
+//
+//   Field           Type      Size
+//   type            uint8     1 byte
+//   addr hash       hash160   20 bytes (key ids use 4 bytes + 16 zero bytes)
+//   block height    uint32    4 bytes (big endian)
+//   tx index        uint32    4 bytes (big endian)
+//   -----
+//   Total: 29 bytes
+//
+// The serialized value format is:
+//
+//   <block id><start offset><tx length><received><sent>
+//
+//   Field           Type      Size
+//   block id        uint32    4 bytes
+//   start offset    uint32    4 bytes
+//   tx length       uint32    4 bytes
+//   received        int64     8 bytes
+//   sent            int64     8 bytes
+//   -----
+//   Total: 28 bytes
+// -----------------------------------------------------------------------------
+
+// AddrValueEntry houses the details of a transaction involving an address or
+// key ID as stored in the address value index.
+type AddrValueEntry struct {
+	// Region identifies the location of the transaction in the block
+	// that contains it.
+	Region database.BlockRegion
+
+	// Height is the height of the block that contains the transaction.
+	Height uint32
+
+	// Received is the total value of the transaction outputs which pay
+	// to the address or key ID.
+	Received int64
+
+	// Sent is the total value of the previous outputs paying to the
+	// address or key ID which are spent by the transaction.
+	Sent int64
+}
+
+// IsFunding returns whether or not the transaction paid value to the address.
+func (e *AddrValueEntry) IsFunding() bool {
+	return e.Received > 0
+}
+
+// IsSpending returns whether or not the transaction spent value from the
+// address.
+func (e *AddrValueEntry) IsSpending() bool {
+	return e.Sent > 0
+}
+
+// addrValueKeyForAddr returns the key prefix used to store the entries for the
+// passed address.  An error is returned for unsupported address types.
+func addrValueKeyForAddr(addr provautil.Address) ([addrValuePrefixSize]byte, error) {
+	var prefix [addrValuePrefixSize]byte
+	provaAddr, ok := addr.(*provautil.AddressProva)
+	if !ok {
+		return prefix, errUnsupportedAddressType
+	}
+	prefix[0] = addrValueKeyTypeAddress
+	copy(prefix[1:], provaAddr.ScriptAddress())
+	return prefix, nil
+}
+
+// addrValueKeyForKeyID returns the key prefix used to store the entries for the
+// passed key ID.
+func addrValueKeyForKeyID(keyID btcec.KeyID) [addrValuePrefixSize]byte {
+	var prefix [addrValuePrefixSize]byte
+	prefix[0] = addrValueKeyTypeKeyID
+	binary.BigEndian.PutUint32(prefix[1:], uint32(keyID))
+	return prefix
+}
+
+// addrValueIndexKeyFor returns the full key for the entry of the transaction
+// at the passed index within the block at the passed height.
+func addrValueIndexKeyFor(prefix [addrValuePrefixSize]byte, height uint32, txIdx int) []byte {
+	key := make([]byte, addrValueKeySize)
+	copy(key, prefix[:])
+	binary.BigEndian.PutUint32(key[addrValuePrefixSize:], height)
+	binary.BigEndian.PutUint32(key[addrValuePrefixSize+4:], uint32(txIdx))
+	return key
+}
+
+// serializeAddrValueEntry serializes the passed data according to the format
+// described in detail above.
+func serializeAddrValueEntry(blockID uint32, region *database.BlockRegion, received, sent int64) []byte {
+	serialized := make([]byte, addrValueEntrySize)
+	byteOrder.PutUint32(serialized, blockID)
+	byteOrder.PutUint32(serialized[4:], region.Offset)
+	byteOrder.PutUint32(serialized[8:], region.Len)
+	byteOrder.PutUint64(serialized[12:], uint64(received))
+	byteOrder.PutUint64(serialized[20:], uint64(sent))
+	return serialized
+}
+
+// deserializeAddrValueEntry decodes the passed key and serialized value into
+// the passed entry.  The block hash is looked up with the provided function.
+func deserializeAddrValueEntry(key, serialized []byte, entry *AddrValueEntry, fetchBlockHash fetchBlockHashFunc) error {
+	if len(key) != addrValueKeySize {
+		return errDeserialize("unexpected address value key length")
+	}
+	if len(serialized) != addrValueEntrySize {
+		return errDeserialize("unexpected address value entry length")
+	}
+
+	hash, err := fetchBlockHash(serialized[0:4])
+	if err != nil {
+		return err
+	}
+	entry.Region.Hash = hash
+	entry.Region.Offset = byteOrder.Uint32(serialized[4:8])
+	entry.Region.Len = byteOrder.Uint32(serialized[8:12])
+	entry.Height = binary.BigEndian.Uint32(key[addrValuePrefixSize:])
+	entry.Received = int64(byteOrder.Uint64(serialized[12:20]))
+	entry.Sent = int64(byteOrder.Uint64(serialized[20:28]))
+	return nil
+}
+
+// dbFetchAddrValueEntries returns the entries stored under the passed prefix
+// limited by the skip and count parameters.  The entries are ordered by their
+// appearance in the blockchain unless reverse is set.
+func dbFetchAddrValueEntries(bucket database.Bucket, prefix [addrValuePrefixSize]byte, numToSkip, numRequested uint32, reverse bool, fetchBlockHash fetchBlockHashFunc) ([]AddrValueEntry, error) {
+	// Start at the last entry for the prefix when iterating in reverse.
+	cursor := bucket.Cursor()
+	next := cursor.Next
+	var ok bool
+	if reverse {
+		next = cursor.Prev
+		ok = seekLastWithPrefix(cursor, prefix[:])
+	} else {
+		ok = cursor.Seek(prefix[:])
+	}
+
+	var entries []AddrValueEntry
+	var numSkipped uint32
+	for ; ok && uint32(len(entries)) < numRequested; ok = next() {
+		if !bytes.HasPrefix(cursor.Key(), prefix[:]) {
+			break
+		}
+		if numSkipped < numToSkip {
+			numSkipped++
+			continue
+		}
+
+		var entry AddrValueEntry
+		err := deserializeAddrValueEntry(cursor.Key(), cursor.Value(),
+			&entry, fetchBlockHash)
+		if err != nil {
+			// Ensure any deserialization errors are returned as
+			// database corruption errors.
+			if isDeserializeErr(err) {
+				err = database.Error{
+					ErrorCode: database.ErrCorruption,
+					Description: "failed to deserialize " +
+						"address value index entry: " +
+						err.Error(),
+				}
+			}
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// dbCountAddrValueEntries returns the number of entries stored under the passed
+// prefix.  It has to visit every entry, so it is only done when requested.
+func dbCountAddrValueEntries(bucket database.Bucket, prefix [addrValuePrefixSize]byte) uint32 {
+	var total uint32
+	cursor := bucket.Cursor()
+	for ok := cursor.Seek(prefix[:]); ok; ok = cursor.Next() {
+		if !bytes.HasPrefix(cursor.Key(), prefix[:]) {
+			break
+		}
+		total++
+	}
+	return total
+}
+
+// addrValueDelta houses the value a single transaction moved to and from an
+// address or key ID.
+type addrValueDelta struct {
+	received int64
+	sent     int64
+}
+
+// addrValueIndexData represents the address value index data to be written for
+// one block.  It maps each address or key ID prefix to the transaction indexes
+// within the block that involve it along with the value moved.
+type addrValueIndexData map[[addrValuePrefixSize]byte]map[int]*addrValueDelta
+
+// AddrValueIndex implements an index of the transactions and values involving
+// addresses and the key IDs they reference.  That is to say, it supports
+// paging through all transactions that either pay to or spend from a given
+// address or key ID, along with the amounts involved, in order of appearance in
+// the blockchain.
+type AddrValueIndex struct {
+	db          database.DB
+	chainParams *chaincfg.Params
+}
+
+// Ensure the AddrValueIndex type implements the Indexer interface.
+var _ Indexer = (*AddrValueIndex)(nil)
+
+// Ensure the AddrValueIndex type implements the Checker interface.
+var _ Checker = (*AddrValueIndex)(nil)
+
+// Ensure the AddrValueIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*AddrValueIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *AddrValueIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *AddrValueIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *AddrValueIndex) Key() []byte {
+	return addrValueIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *AddrValueIndex) Name() string {
+	return addrValueIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the address
+// value index.
+//
+// This is part of the Indexer interface.
+func (idx *AddrValueIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(addrValueIndexKey)
+	return err
+}
+
+// indexPkScript extracts all Prova addresses from the passed public key script
+// and adds the passed value to the delta of the transaction for each address
+// and each key ID the address references.
+func (idx *AddrValueIndex) indexPkScript(data addrValueIndexData, pkScript []byte, txIdx int, value int64, spent bool) {
+	// Nothing to index if the script is non-standard or otherwise doesn't
+	// contain any addresses.
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript,
+		idx.chainParams)
+	if err != nil || len(addrs) == 0 {
+		return
+	}
+
+	addDelta := func(prefix [addrValuePrefixSize]byte) {
+		txns := data[prefix]
+		if txns == nil {
+			txns = make(map[int]*addrValueDelta)
+			data[prefix] = txns
+		}
+		delta := txns[txIdx]
+		if delta == nil {
+			delta = &addrValueDelta{}
+			txns[txIdx] = delta
+		}
+		if spent {
+			delta.sent += value
+		} else {
+			delta.received += value
+		}
+	}
+
+	for _, addr := range addrs {
+		prefix, err := addrValueKeyForAddr(addr)
+		if err != nil {
+			// Ignore unsupported address types.
+			continue
+		}
+		addDelta(prefix)
+
+		for _, keyID := range addr.(*provautil.AddressProva).ScriptKeyIDs() {
+			addDelta(addrValueKeyForKeyID(keyID))
+		}
+	}
+}
+
+// indexBlock extracts all of the Prova addresses from all of the transactions
+// in the passed block and records the value each transaction moved to and from
+// them using the passed map.
+func (idx *AddrValueIndex) indexBlock(data addrValueIndexData, block *provautil.Block, view *blockchain.UtxoViewpoint) {
+	for txIdx, tx := range block.Transactions() {
+		// Coinbases do not reference any inputs.  Since the block is
+		// required to have already gone through full validation, it has
+		// already been proven on the first transaction in the block is
+		// a coinbase.
+		if txIdx != 0 {
+			for _, txIn := range tx.MsgTx().TxIn {
+				// The view should always have the input since
+				// the index contract requires it, however, be
+				// safe and simply ignore any missing entries.
+				origin := &txIn.PreviousOutPoint
+				entry := view.LookupEntry(&origin.Hash)
+				if entry == nil {
+					continue
+				}
+
+				pkScript := entry.PkScriptByIndex(origin.Index)
+				value := entry.AmountByIndex(origin.Index)
+				idx.indexPkScript(data, pkScript, txIdx, value, true)
+			}
+		}
+
+		for _, txOut := range tx.MsgTx().TxOut {
+			idx.indexPkScript(data, txOut.PkScript, txIdx,
+				txOut.Value, false)
+		}
+	}
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry for each address and
+// key ID the transactions in the block involve.
+//
+// This is part of the Indexer interface.
+func (idx *AddrValueIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	// The offset and length of the transactions within the serialized
+	// block.
+	txLocs, err := block.TxLoc()
+	if err != nil {
+		return err
+	}
+
+	// Get the internal block ID associated with the block.
+	blockID, err := dbFetchBlockIDByHash(dbTx, block.Hash())
+	if err != nil {
+		return err
+	}
+
+	data := make(addrValueIndexData)
+	idx.indexBlock(data, block, view)
+
+	bucket := dbTx.Metadata().Bucket(addrValueIndexKey)
+	height := block.Height()
+	for prefix, txns := range data {
+		for txIdx, delta := range txns {
+			region := database.BlockRegion{
+				Offset: uint32(txLocs[txIdx].TxStart),
+				Len:    uint32(txLocs[txIdx].TxLen),
+			}
+			key := addrValueIndexKeyFor(prefix, height, txIdx)
+			value := serializeAddrValueEntry(blockID, &region,
+				delta.received, delta.sent)
+			if err := bucket.Put(key, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries for each
+// address and key ID the transactions in the block involve.
+//
+// This is part of the Indexer interface.
+func (idx *AddrValueIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	data := make(addrValueIndexData)
+	idx.indexBlock(data, block, view)
+
+	bucket := dbTx.Metadata().Bucket(addrValueIndexKey)
+	height := block.Height()
+	for prefix, txns := range data {
+		for txIdx := range txns {
+			key := addrValueIndexKeyFor(prefix, height, txIdx)
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// CheckBlock verifies the entries the index stores for the passed block by
+// comparing them with the entries connecting the block produces.
+//
+// This is part of the Checker interface.
+func (idx *AddrValueIndex) CheckBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint, repair bool) ([]IndexInconsistency, error) {
+	return checkBlockEntries(dbTx, repair, func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, view)
+	})
+}
+
+// fetchEntries loads the entries stored under the passed prefix.
+func (idx *AddrValueIndex) fetchEntries(dbTx database.Tx, prefix [addrValuePrefixSize]byte, numToSkip, numRequested uint32, reverse bool) ([]AddrValueEntry, error) {
+	// Create closure to lookup the block hash given the ID using the
+	// database transaction.
+	fetchBlockHash := func(id []byte) (*chainhash.Hash, error) {
+		return dbFetchBlockHashBySerializedID(dbTx, id)
+	}
+
+	bucket := dbTx.Metadata().Bucket(addrValueIndexKey)
+	return dbFetchAddrValueEntries(bucket, prefix, numToSkip, numRequested,
+		reverse, fetchBlockHash)
+}
+
+// EntriesForAddress returns the entries for the transactions that involve the
+// passed address.  The number of entries to skip and the maximum number to
+// return are controlled by the numToSkip and numRequested parameters, and the
+// reverse flag requests the entries ordered from newest to oldest.  Only the
+// returned entries are loaded, so the cost does not depend on the number of
+// transactions involving the address.
+//
+// NOTE: These results only include transactions confirmed in blocks.
+//
+// This function is safe for concurrent access.
+func (idx *AddrValueIndex) EntriesForAddress(dbTx database.Tx, addr provautil.Address, numToSkip, numRequested uint32, reverse bool) ([]AddrValueEntry, error) {
+	prefix, err := addrValueKeyForAddr(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return idx.fetchEntries(dbTx, prefix, numToSkip, numRequested, reverse)
+}
+
+// EntriesForKeyID returns the entries for the transactions that involve any
+// address referencing the passed key ID.  The parameters are handled the same
+// as EntriesForAddress.
+//
+// NOTE: These results only include transactions confirmed in blocks.
+//
+// This function is safe for concurrent access.
+func (idx *AddrValueIndex) EntriesForKeyID(dbTx database.Tx, keyID btcec.KeyID, numToSkip, numRequested uint32, reverse bool) ([]AddrValueEntry, error) {
+	prefix := addrValueKeyForKeyID(keyID)
+	return idx.fetchEntries(dbTx, prefix, numToSkip, numRequested, reverse)
+}
+
+// NumEntriesForAddress returns the total number of entries for the transactions
+// that involve the passed address.  Every entry is visited, so the cost grows
+// with the number of transactions involving the address.
+//
+// This function is safe for concurrent access.
+func (idx *AddrValueIndex) NumEntriesForAddress(dbTx database.Tx, addr provautil.Address) (uint32, error) {
+	prefix, err := addrValueKeyForAddr(addr)
+	if err != nil {
+		return 0, err
+	}
+
+	bucket := dbTx.Metadata().Bucket(addrValueIndexKey)
+	return dbCountAddrValueEntries(bucket, prefix), nil
+}
+
+// NumEntriesForKeyID returns the total number of entries for the transactions
+// that involve any address referencing the passed key ID.  As with
+// NumEntriesForAddress, every entry is visited.
+//
+// This function is safe for concurrent access.
+func (idx *AddrValueIndex) NumEntriesForKeyID(dbTx database.Tx, keyID btcec.KeyID) uint32 {
+	bucket := dbTx.Metadata().Bucket(addrValueIndexKey)
+	return dbCountAddrValueEntries(bucket, addrValueKeyForKeyID(keyID))
+}
+
+// NewAddrValueIndex returns a new instance of an indexer that is used to create
+// a mapping of the addresses and key IDs to the transactions and values that
+// involve them.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewAddrValueIndex(db database.DB, chainParams *chaincfg.Params) *AddrValueIndex {
+	return &AddrValueIndex{
+		db:          db,
+		chainParams: chainParams,
+	}
+}
+
+// DropAddrValueIndex drops the address value index from the provided database
+// if it exists.
+func DropAddrValueIndex(db database.DB) error {
+	return dropIndex(db, addrValueIndexKey, addrValueIndexName)
+}
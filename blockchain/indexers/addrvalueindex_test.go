@@ -0,0 +1,202 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+)
+
+// TestAddrValueEntrySerialization ensures address value index entries round
+// trip through serialization and that keys sort in blockchain order.
+func TestAddrValueEntrySerialization(t *testing.T) {
+	t.Parallel()
+
+	blockHash := chainhash.Hash{0x01, 0x02, 0x03}
+	fetchBlockHash := func(serializedID []byte) (*chainhash.Hash, error) {
+		if id := byteOrder.Uint32(serializedID); id != 7 {
+			t.Fatalf("unexpected block id %d", id)
+		}
+		return &blockHash, nil
+	}
+
+	prefix := addrValueKeyForKeyID(0x10203)
+	key := addrValueIndexKeyFor(prefix, 1000, 3)
+	region := database.BlockRegion{Offset: 81, Len: 250}
+	serialized := serializeAddrValueEntry(7, &region, 5000, 12000)
+
+	var entry AddrValueEntry
+	err := deserializeAddrValueEntry(key, serialized, &entry, fetchBlockHash)
+	if err != nil {
+		t.Fatalf("deserializeAddrValueEntry: unexpected error: %v", err)
+	}
+	want := AddrValueEntry{
+		Region: database.BlockRegion{
+			Hash:   &blockHash,
+			Offset: 81,
+			Len:    250,
+		},
+		Height:   1000,
+		Received: 5000,
+		Sent:     12000,
+	}
+	if !reflect.DeepEqual(entry, want) {
+		t.Fatalf("deserializeAddrValueEntry: mismatched entry - got %+v, "+
+			"want %+v", entry, want)
+	}
+	if !entry.IsFunding() || !entry.IsSpending() {
+		t.Fatalf("unexpected classification for entry %+v", entry)
+	}
+
+	// Entries must sort by height and then by transaction index so that
+	// cursors iterate them in order of appearance in the blockchain.
+	keys := [][]byte{
+		addrValueIndexKeyFor(prefix, 999, 300),
+		addrValueIndexKeyFor(prefix, 1000, 2),
+		key,
+		addrValueIndexKeyFor(prefix, 1000, 256),
+		addrValueIndexKeyFor(prefix, 65536, 0),
+	}
+	for i := 1; i < len(keys); i++ {
+		if bytes.Compare(keys[i-1], keys[i]) >= 0 {
+			t.Fatalf("key %d does not sort before key %d", i-1, i)
+		}
+	}
+
+	// Ensure malformed entries are rejected as deserialization errors.
+	err = deserializeAddrValueEntry(key, serialized[:10], &entry,
+		fetchBlockHash)
+	if !isDeserializeErr(err) {
+		t.Fatalf("deserializeAddrValueEntry: unexpected error for short "+
+			"entry: %v", err)
+	}
+}
+
+// TestAddrValueIndexConnectBlock ensures the address value index records the
+// values received and sent by the transactions involving addresses and key IDs
+// when blocks are connected, pages them in both directions, and removes them
+// when the blocks are disconnected.
+func TestAddrValueIndexConnectBlock(t *testing.T) {
+	t.Parallel()
+
+	db, teardown := newTestIndexDB(t, "addrvalueindex")
+	defer teardown()
+
+	// The entries refer to their blocks through the block ID index of the
+	// transaction index, so assign the blocks their IDs first.
+	c, addrA, _ := newTestPaymentChain(t)
+	err := db.Update(func(dbTx database.Tx) error {
+		if err := NewTxIndex(db).Create(dbTx); err != nil {
+			return err
+		}
+		for i, block := range c.blocks {
+			err := dbPutBlockIDIndexEntry(dbTx, block.Hash(),
+				uint32(i+1))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to store block IDs: %v", err)
+	}
+	idx := NewAddrValueIndex(db, &chaincfg.MainNetParams)
+	connectTestChain(t, db, idx, c)
+
+	entries := make([]AddrValueEntry, len(c.blocks))
+	for i, block := range c.blocks {
+		txLocs, err := block.TxLoc()
+		if err != nil {
+			t.Fatalf("unable to locate transactions: %v", err)
+		}
+		entries[i] = AddrValueEntry{
+			Region: database.BlockRegion{
+				Hash:   block.Hash(),
+				Offset: uint32(txLocs[1].TxStart),
+				Len:    uint32(txLocs[1].TxLen),
+			},
+			Height: uint32(i + 1),
+		}
+	}
+	entries[0].Received = 1000
+	entries[1].Received = 400
+	entries[1].Sent = 1000
+
+	tests := []struct {
+		numToSkip uint32
+		reverse   bool
+		want      []AddrValueEntry
+	}{
+		{0, false, entries},
+		{1, false, entries[1:]},
+		{0, true, []AddrValueEntry{entries[1], entries[0]}},
+		{1, true, entries[:1]},
+		{2, true, nil},
+	}
+	err = db.View(func(dbTx database.Tx) error {
+		for i, test := range tests {
+			got, err := idx.EntriesForAddress(dbTx, addrA,
+				test.numToSkip, 10, test.reverse)
+			if err != nil {
+				return err
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("test #%d: got entries %+v, want %+v",
+					i, got, test.want)
+			}
+		}
+
+		// Ensure the entries are counted on request and key ID 3 is
+		// only involved in the second block.
+		numEntries, err := idx.NumEntriesForAddress(dbTx, addrA)
+		if err != nil {
+			return err
+		}
+		if numEntries != 2 {
+			t.Errorf("NumEntriesForAddress: got %d entries, want 2",
+				numEntries)
+		}
+		got, err := idx.EntriesForKeyID(dbTx, 3, 0, 10, false)
+		if err != nil {
+			return err
+		}
+		if len(got) != 1 || got[0].Height != 2 || got[0].Received != 600 {
+			t.Errorf("EntriesForKeyID: got entries %+v for key ID 3",
+				got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch entries: %v", err)
+	}
+
+	// Ensure only the entries of the first block remain once the second
+	// block is disconnected.
+	disconnectTestTip(t, db, idx, c)
+	err = db.View(func(dbTx database.Tx) error {
+		got, err := idx.EntriesForAddress(dbTx, addrA, 0, 10, true)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(got, entries[:1]) {
+			t.Errorf("EntriesForAddress: got entries %+v after "+
+				"disconnecting, want %+v", got, entries[:1])
+		}
+		if n := idx.NumEntriesForKeyID(dbTx, 3); n != 0 {
+			t.Errorf("NumEntriesForKeyID: got %d entries for key "+
+				"ID 3 after disconnecting, want none", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch entries: %v", err)
+	}
+}
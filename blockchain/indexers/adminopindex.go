@@ -0,0 +1,394 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"encoding/binary"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+const (
+	// adminOpIndexName is the human-readable name for the index.
+	adminOpIndexName = "admin operation index"
+
+	// adminOpIndexKeySize is the number of bytes a key in the admin
+	// operation index consumes.  It consists of 4 bytes block height + 4
+	// bytes tx index + 4 bytes output index.
+	adminOpIndexKeySize = 4 + 4 + 4
+
+	// adminOpEntrySize is the number of bytes a value in the admin
+	// operation index consumes.  It consists of the 32 byte tx hash + 1
+	// byte thread id + 1 byte operation type + 1 byte key set type + 4
+	// bytes key id + 8 bytes value + 33 bytes compressed public key.
+	adminOpEntrySize = chainhash.HashSize + 1 + 1 + 1 + 4 + 8 +
+		btcec.PubKeyBytesLenCompressed
+)
+
+// AdminOpType identifies the kind of an indexed admin operation.
+type AdminOpType byte
+
+// These constants define the types of admin operations.  The values are
+// stored in the database and therefore must not change.
+const (
+	// AdminOpKeyAdd indicates a key was provisioned to a key set.
+	AdminOpKeyAdd AdminOpType = 0
+
+	// AdminOpKeyRevoke indicates a key was revoked from a key set.
+	AdminOpKeyRevoke AdminOpType = 1
+
+	// AdminOpIssue indicates tokens were issued.
+	AdminOpIssue AdminOpType = 2
+
+	// AdminOpDestroy indicates tokens were destroyed.
+	AdminOpDestroy AdminOpType = 3
+)
+
+// adminOpTypeStrings is a map of admin operation types back to their names for
+// pretty printing.
+var adminOpTypeStrings = map[AdminOpType]string{
+	AdminOpKeyAdd:    "addkey",
+	AdminOpKeyRevoke: "revokekey",
+	AdminOpIssue:     "issue",
+	AdminOpDestroy:   "destroy",
+}
+
+// String returns the AdminOpType in human-readable form.
+func (t AdminOpType) String() string {
+	if s, ok := adminOpTypeStrings[t]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+var (
+	// adminOpIndexKey is the key of the admin operation index and the db
+	// bucket used to house it.
+	adminOpIndexKey = []byte("adminopidx")
+)
+
+// -----------------------------------------------------------------------------
+// The admin operation index consists of an entry for every operation carried
+// out by the admin transactions in the main chain.  That is to say, every key
+// provisioned or revoked on the root and provision threads, and every output
+// issuing or destroying tokens on the issue thread.  The keys are ordered by
+// block height, so the operations within a range of heights can be found with
+// a single cursor seek.
+//
+// The serialized key format is:
+//
+//   <block height><tx index><output index>
+//
+//   Field           Type      Size
+//   block height    uint32    4 bytes (big endian)
+//   tx index        uint32    4 bytes (big endian)
+//   output index    uint32    4 bytes (big endian)
+//   -----
+//   Total: 12 bytes
+//
+// The serialized value format is:
+//
+//   <txhash><thread id><op type><key set><key id><value><pubkey>
+//
+//   Field           Type              Size
+//   txhash          chainhash.Hash    32 bytes
+//   thread id       uint8             1 byte
+//   op type         uint8             1 byte
+//   key set         uint8             1 byte
+//   key id          uint32            4 bytes
+//   value           int64             8 bytes
+//   pubkey          []byte            33 bytes (compressed)
+//   -----
+//   Total: 80 bytes
+//
+// The key set, key id, and pubkey fields are only meaningful for key
+// operations while the value field is only meaningful for issuance and
+// destruction.  Unused fields are zero.
+// -----------------------------------------------------------------------------
+
+// AdminOperation describes a single operation carried out by an admin
+// transaction.
+type AdminOperation struct {
+	Type     AdminOpType
+	Height   uint32
+	TxHash   chainhash.Hash
+	Index    uint32
+	ThreadID provautil.ThreadID
+
+	// The following fields are only set for key operations.  The key ID is
+	// only set for ASP keys.
+	KeySetType btcec.KeySetType
+	PubKey     [btcec.PubKeyBytesLenCompressed]byte
+	KeyID      btcec.KeyID
+
+	// Value is only set for issuance and destruction.
+	Value int64
+}
+
+// adminOpIndexKeyFor returns the key used to store the admin operation for the
+// passed output of the transaction at the passed index in the block at the
+// passed height.
+func adminOpIndexKeyFor(height uint32, txIdx int, outIdx uint32) []byte {
+	key := make([]byte, adminOpIndexKeySize)
+	binary.BigEndian.PutUint32(key[0:4], height)
+	binary.BigEndian.PutUint32(key[4:8], uint32(txIdx))
+	binary.BigEndian.PutUint32(key[8:12], outIdx)
+	return key
+}
+
+// serializeAdminOperation serializes the value of the passed operation
+// according to the format described in detail above.
+func serializeAdminOperation(op *AdminOperation) []byte {
+	serialized := make([]byte, adminOpEntrySize)
+	offset := copy(serialized, op.TxHash[:])
+	serialized[offset] = byte(op.ThreadID)
+	serialized[offset+1] = byte(op.Type)
+	serialized[offset+2] = byte(op.KeySetType)
+	offset += 3
+	byteOrder.PutUint32(serialized[offset:], uint32(op.KeyID))
+	offset += 4
+	byteOrder.PutUint64(serialized[offset:], uint64(op.Value))
+	offset += 8
+	copy(serialized[offset:], op.PubKey[:])
+	return serialized
+}
+
+// deserializeAdminOperation decodes the passed key and serialized value into
+// the passed operation.
+func deserializeAdminOperation(key, serialized []byte, op *AdminOperation) error {
+	if len(key) != adminOpIndexKeySize {
+		return errDeserialize("unexpected admin operation key length")
+	}
+	if len(serialized) != adminOpEntrySize {
+		return errDeserialize("unexpected admin operation entry length")
+	}
+
+	op.Height = binary.BigEndian.Uint32(key[0:4])
+	op.Index = binary.BigEndian.Uint32(key[8:12])
+	offset := copy(op.TxHash[:], serialized)
+	op.ThreadID = provautil.ThreadID(serialized[offset])
+	op.Type = AdminOpType(serialized[offset+1])
+	op.KeySetType = btcec.KeySetType(serialized[offset+2])
+	offset += 3
+	op.KeyID = btcec.KeyID(byteOrder.Uint32(serialized[offset:]))
+	offset += 4
+	op.Value = int64(byteOrder.Uint64(serialized[offset:]))
+	offset += 8
+	copy(op.PubKey[:], serialized[offset:])
+	return nil
+}
+
+// dbFetchAdminOperations uses an existing database transaction to return all of
+// the admin operations in blocks between the start and end heights, inclusive,
+// ordered by their appearance in the blockchain.
+func dbFetchAdminOperations(dbTx database.Tx, startHeight, endHeight uint32) ([]AdminOperation, error) {
+	var ops []AdminOperation
+	cursor := dbTx.Metadata().Bucket(adminOpIndexKey).Cursor()
+	for ok := cursor.Seek(adminOpIndexKeyFor(startHeight, 0, 0)); ok; ok = cursor.Next() {
+		var op AdminOperation
+		err := deserializeAdminOperation(cursor.Key(), cursor.Value(), &op)
+		if err != nil {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: "failed to deserialize admin " +
+					"operation entry: " + err.Error(),
+			}
+		}
+		if op.Height > endHeight {
+			break
+		}
+		ops = append(ops, op)
+	}
+
+	return ops, nil
+}
+
+// TxAdminOperations returns the operations carried out by the passed admin
+// transaction at the passed height.  It returns nil for transactions which are
+// not admin transactions.
+func TxAdminOperations(tx *provautil.Tx, height uint32) []AdminOperation {
+	threadInt, adminOutputs := txscript.GetAdminDetails(tx)
+	if threadInt < 0 {
+		// Not an admin transaction.
+		return nil
+	}
+
+	threadID := provautil.ThreadID(threadInt)
+	msgTx := tx.MsgTx()
+	ops := make([]AdminOperation, 0, len(adminOutputs))
+	for i, adminOutput := range adminOutputs {
+		// The admin outputs start at the second output of the transaction
+		// since the first one is the thread output.
+		outIdx := uint32(i + 1)
+		op := AdminOperation{
+			Height:   height,
+			TxHash:   *tx.Hash(),
+			Index:    outIdx,
+			ThreadID: threadID,
+		}
+
+		if threadID == provautil.IssueThread {
+			// Transactions with more than one input destroy the value
+			// of their null data outputs, while all outputs of other
+			// transactions issue tokens.
+			op.Type = AdminOpIssue
+			if len(msgTx.TxIn) > 1 {
+				scriptType := txscript.TypeOfScript(adminOutput)
+				if scriptType != txscript.NullDataTy {
+					continue
+				}
+				op.Type = AdminOpDestroy
+			}
+			op.Value = msgTx.TxOut[outIdx].Value
+		} else {
+			isAddOp, keySetType, pubKey, keyID :=
+				txscript.ExtractAdminOpData(adminOutput)
+			op.Type = AdminOpKeyRevoke
+			if isAddOp {
+				op.Type = AdminOpKeyAdd
+			}
+			op.KeySetType = keySetType
+			op.KeyID = keyID
+			if pubKey != nil {
+				copy(op.PubKey[:], pubKey.SerializeCompressed())
+			}
+		}
+		ops = append(ops, op)
+	}
+
+	return ops
+}
+
+// blockAdminOperations invokes the passed function with the associated key for
+// every admin operation carried out by the transactions in the passed block.
+func blockAdminOperations(block *provautil.Block, fn func(key []byte, op *AdminOperation) error) error {
+	for txIdx, tx := range block.Transactions() {
+		ops := TxAdminOperations(tx, block.Height())
+		for i := range ops {
+			op := &ops[i]
+			key := adminOpIndexKeyFor(op.Height, txIdx, op.Index)
+			if err := fn(key, op); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// AdminOpIndex implements an index of the operations carried out by admin
+// transactions.  That is to say, it supports querying the history of key
+// provisioning and revocation along with issuance and destruction of tokens.
+type AdminOpIndex struct {
+	db database.DB
+}
+
+// Ensure the AdminOpIndex type implements the Indexer interface.
+var _ Indexer = (*AdminOpIndex)(nil)
+
+// Ensure the AdminOpIndex type implements the Checker interface.
+var _ Checker = (*AdminOpIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *AdminOpIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *AdminOpIndex) Key() []byte {
+	return adminOpIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *AdminOpIndex) Name() string {
+	return adminOpIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the admin
+// operation index.
+//
+// This is part of the Indexer interface.
+func (idx *AdminOpIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(adminOpIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry for every admin
+// operation carried out by the transactions in the block.
+//
+// This is part of the Indexer interface.
+func (idx *AdminOpIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(adminOpIndexKey)
+	return blockAdminOperations(block, func(key []byte, op *AdminOperation) error {
+		return bucket.Put(key, serializeAdminOperation(op))
+	})
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries for every
+// admin operation carried out by the transactions in the block.
+//
+// This is part of the Indexer interface.
+func (idx *AdminOpIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(adminOpIndexKey)
+	return blockAdminOperations(block, func(key []byte, op *AdminOperation) error {
+		return bucket.Delete(key)
+	})
+}
+
+// CheckBlock verifies the entries the index stores for the passed block by
+// comparing them with the entries connecting the block produces.
+//
+// This is part of the Checker interface.
+func (idx *AdminOpIndex) CheckBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint, repair bool) ([]IndexInconsistency, error) {
+	return checkBlockEntries(dbTx, repair, func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, view)
+	})
+}
+
+// AdminOperations returns all admin operations in blocks between the start and
+// end heights, inclusive, ordered by their appearance in the blockchain.
+//
+// This function is safe for concurrent access.
+func (idx *AdminOpIndex) AdminOperations(startHeight, endHeight uint32) ([]AdminOperation, error) {
+	var ops []AdminOperation
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		ops, err = dbFetchAdminOperations(dbTx, startHeight, endHeight)
+		return err
+	})
+	return ops, err
+}
+
+// NewAdminOpIndex returns a new instance of an indexer that is used to create a
+// history of all operations carried out by admin transactions.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewAdminOpIndex(db database.DB) *AdminOpIndex {
+	return &AdminOpIndex{db: db}
+}
+
+// DropAdminOpIndex drops the admin operation index from the provided database
+// if it exists.
+func DropAdminOpIndex(db database.DB) error {
+	return dropIndex(db, adminOpIndexKey, adminOpIndexName)
+}
@@ -0,0 +1,179 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestAdminOperationSerialization ensures admin operation entries round trip
+// through serialization.
+func TestAdminOperationSerialization(t *testing.T) {
+	t.Parallel()
+
+	tests := []AdminOperation{
+		{
+			Type:       AdminOpKeyAdd,
+			Height:     12,
+			TxHash:     chainhash.Hash{0x01},
+			Index:      1,
+			ThreadID:   provautil.ProvisionThread,
+			KeySetType: btcec.ASPKeySet,
+			PubKey:     [btcec.PubKeyBytesLenCompressed]byte{0x02, 0xff},
+			KeyID:      65536,
+		},
+		{
+			Type:       AdminOpKeyRevoke,
+			Height:     13,
+			TxHash:     chainhash.Hash{0x02},
+			Index:      2,
+			ThreadID:   provautil.RootThread,
+			KeySetType: btcec.IssueKeySet,
+			PubKey:     [btcec.PubKeyBytesLenCompressed]byte{0x03, 0x01},
+		},
+		{
+			Type:     AdminOpIssue,
+			Height:   14,
+			TxHash:   chainhash.Hash{0x03},
+			Index:    1,
+			ThreadID: provautil.IssueThread,
+			Value:    5000000,
+		},
+	}
+
+	for i, test := range tests {
+		key := adminOpIndexKeyFor(test.Height, 1, test.Index)
+		serialized := serializeAdminOperation(&test)
+
+		var got AdminOperation
+		err := deserializeAdminOperation(key, serialized, &got)
+		if err != nil {
+			t.Errorf("test #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test) {
+			t.Errorf("test #%d: mismatched operation - got %+v, "+
+				"want %+v", i, got, test)
+		}
+
+		// Ensure truncated entries are rejected.
+		err = deserializeAdminOperation(key, serialized[:40], &got)
+		if !isDeserializeErr(err) {
+			t.Errorf("test #%d: unexpected error for short entry: "+
+				"%v", i, err)
+		}
+	}
+}
+
+// TestTxAdminOperations ensures the operations carried out by admin
+// transactions are decoded from their outputs.
+func TestTxAdminOperations(t *testing.T) {
+	t.Parallel()
+
+	threadTx := func(threadID provautil.ThreadID, numInputs int) *wire.MsgTx {
+		threadScript, err := txscript.ProvaThreadScript(threadID)
+		if err != nil {
+			t.Fatalf("ProvaThreadScript: unexpected error: %v", err)
+		}
+		msgTx := wire.NewMsgTx(wire.TxVersion)
+		for i := 0; i < numInputs; i++ {
+			prevOut := wire.OutPoint{Index: uint32(i)}
+			msgTx.AddTxIn(wire.NewTxIn(&prevOut, nil))
+		}
+		msgTx.AddTxOut(wire.NewTxOut(0, threadScript))
+		return msgTx
+	}
+
+	var pubKey [btcec.PubKeyBytesLenCompressed]byte
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	copy(pubKey[:], privKey.PubKey().SerializeCompressed())
+	opData := append([]byte{txscript.AdminOpProvisionKeyAdd}, pubKey[:]...)
+	keyScript, err := txscript.NullDataScript(opData)
+	if err != nil {
+		t.Fatalf("NullDataScript: unexpected error: %v", err)
+	}
+	destroyScript, err := txscript.NullDataScript(nil)
+	if err != nil {
+		t.Fatalf("NullDataScript: unexpected error: %v", err)
+	}
+
+	rootTx := threadTx(provautil.RootThread, 1)
+	rootTx.AddTxOut(wire.NewTxOut(0, keyScript))
+	issueTx := threadTx(provautil.IssueThread, 1)
+	issueTx.AddTxOut(wire.NewTxOut(5000, []byte{txscript.OP_TRUE}))
+	destroyTx := threadTx(provautil.IssueThread, 2)
+	destroyTx.AddTxOut(wire.NewTxOut(3000, []byte{txscript.OP_TRUE}))
+	destroyTx.AddTxOut(wire.NewTxOut(2000, destroyScript))
+	regularTx := wire.NewMsgTx(wire.TxVersion)
+	regularTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{}, nil))
+	regularTx.AddTxOut(wire.NewTxOut(5000, []byte{txscript.OP_TRUE}))
+
+	tests := []struct {
+		name  string
+		msgTx *wire.MsgTx
+		want  []AdminOperation
+	}{
+		{
+			name:  "provision key added on root thread",
+			msgTx: rootTx,
+			want: []AdminOperation{{
+				Type:       AdminOpKeyAdd,
+				Height:     7,
+				TxHash:     rootTx.TxHash(),
+				Index:      1,
+				ThreadID:   provautil.RootThread,
+				KeySetType: btcec.ProvisionKeySet,
+				PubKey:     pubKey,
+			}},
+		},
+		{
+			name:  "issuance",
+			msgTx: issueTx,
+			want: []AdminOperation{{
+				Type:     AdminOpIssue,
+				Height:   7,
+				TxHash:   issueTx.TxHash(),
+				Index:    1,
+				ThreadID: provautil.IssueThread,
+				Value:    5000,
+			}},
+		},
+		{
+			name:  "destruction",
+			msgTx: destroyTx,
+			want: []AdminOperation{{
+				Type:     AdminOpDestroy,
+				Height:   7,
+				TxHash:   destroyTx.TxHash(),
+				Index:    2,
+				ThreadID: provautil.IssueThread,
+				Value:    2000,
+			}},
+		},
+		{
+			name:  "not an admin transaction",
+			msgTx: regularTx,
+			want:  nil,
+		},
+	}
+
+	for _, test := range tests {
+		got := TxAdminOperations(provautil.NewTx(test.msgTx), 7)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: mismatched operations - got %+v, want %+v",
+				test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"fmt"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/gcs/builder"
+)
+
+const (
+	// cfIndexName is the human-readable name for the index.
+	cfIndexName = "compact block filter index"
+
+	// cfEntryMinSize is the minimum number of bytes an entry of the compact
+	// block filter index consumes.  It consists of the 32 byte filter
+	// header + 32 byte filter hash followed by the filter.
+	cfEntryMinSize = 2 * chainhash.HashSize
+)
+
+var (
+	// cfIndexKey is the key of the compact block filter index and the db
+	// bucket used to house it.
+	cfIndexKey = []byte("cfidx")
+)
+
+// -----------------------------------------------------------------------------
+// The compact block filter index consists of an entry for every block in the
+// main chain which holds the basic filter of the block as built by the
+// builder package along with the hash of the filter and the filter header.
+// The filter header commits to the filter and to the filter header of the
+// previous block, so light clients are able to verify the filters they
+// download against a chain of filter headers.  The filter header of the
+// genesis block commits to the zero hash.
+//
+// The filter hash is stored so the hashes of a range of filters can be served
+// without hashing the filters.
+//
+// The serialized format for the keys and values in the compact block filter
+// index bucket is:
+//
+//   <block hash> = <filter header><filter hash><filter>
+//
+//   Field           Type              Size
+//   block hash      chainhash.Hash    32 bytes
+//   filter header   chainhash.Hash    32 bytes
+//   filter hash     chainhash.Hash    32 bytes
+//   filter          []byte            variable
+// -----------------------------------------------------------------------------
+
+// CfEntry is the compact block filter of a block along with its hash and
+// filter header.
+type CfEntry struct {
+	// Header is the filter header of the block.
+	Header chainhash.Hash
+
+	// FilterHash is the hash of the filter.
+	FilterHash chainhash.Hash
+
+	// Filter is the serialized basic filter of the block.
+	Filter []byte
+}
+
+// serializeCfEntry serializes the passed entry according to the format
+// described in detail above.
+func serializeCfEntry(entry *CfEntry) []byte {
+	serialized := make([]byte, cfEntryMinSize+len(entry.Filter))
+	copy(serialized, entry.Header[:])
+	copy(serialized[chainhash.HashSize:], entry.FilterHash[:])
+	copy(serialized[cfEntryMinSize:], entry.Filter)
+	return serialized
+}
+
+// deserializeCfEntry decodes the passed serialized entry of the compact block
+// filter index.
+func deserializeCfEntry(serialized []byte) (*CfEntry, error) {
+	if len(serialized) < cfEntryMinSize {
+		return nil, errDeserialize("unexpected end of data")
+	}
+
+	var entry CfEntry
+	copy(entry.Header[:], serialized)
+	copy(entry.FilterHash[:], serialized[chainhash.HashSize:])
+	entry.Filter = append([]byte(nil), serialized[cfEntryMinSize:]...)
+	return &entry, nil
+}
+
+// dbFetchCfEntry uses an existing database transaction to fetch the entry of
+// the block with the passed hash.  When the block is not indexed, nil is
+// returned for the entry.
+func dbFetchCfEntry(dbTx database.Tx, hash *chainhash.Hash) (*CfEntry, error) {
+	serialized := dbTx.Metadata().Bucket(cfIndexKey).Get(hash[:])
+	if serialized == nil {
+		return nil, nil
+	}
+	entry, err := deserializeCfEntry(serialized)
+	if err != nil {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: "corrupt compact block filter index entry " +
+				"for " + hash.String() + ": " + err.Error(),
+		}
+	}
+	return entry, nil
+}
+
+// CfIndex implements a compact block filter index.  That is to say, it stores
+// the basic compact block filter of every block in the main chain along with
+// the filter headers so they can be served to light clients.
+type CfIndex struct {
+	db          database.DB
+	chainParams *chaincfg.Params
+}
+
+// Ensure the CfIndex type implements the Indexer interface.
+var _ Indexer = (*CfIndex)(nil)
+
+// Ensure the CfIndex type implements the Checker interface.
+var _ Checker = (*CfIndex)(nil)
+
+// Ensure the CfIndex type implements the Verifier interface.
+var _ Verifier = (*CfIndex)(nil)
+
+// Ensure the CfIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*CfIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *CfIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *CfIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *CfIndex) Key() []byte {
+	return cfIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *CfIndex) Name() string {
+	return cfIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the compact
+// block filter index.
+//
+// This is part of the Indexer interface.
+func (idx *CfIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(cfIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer stores the basic filter of the
+// block along with its filter hash and filter header.
+//
+// This is part of the Indexer interface.
+func (idx *CfIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	// Gather the scripts of the outputs spent by the block.  Coinbases do
+	// not reference any inputs.
+	var prevOutScripts [][]byte
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			// The view should always have the input since the index
+			// contract requires it, however, be safe and simply
+			// ignore any missing entries.
+			origin := &txIn.PreviousOutPoint
+			entry := view.LookupEntry(&origin.Hash)
+			if entry == nil {
+				continue
+			}
+			prevOutScripts = append(prevOutScripts,
+				entry.PkScriptByIndex(origin.Index))
+		}
+	}
+
+	filter, err := builder.BuildBasicFilter(block.MsgBlock(),
+		prevOutScripts, idx.chainParams)
+	if err != nil {
+		return err
+	}
+
+	// The filter header commits to the filter header of the previous
+	// block, which is the zero hash for the genesis block.
+	var prevHeader chainhash.Hash
+	if block.Height() > 0 {
+		prevHash := &block.MsgBlock().Header.PrevBlock
+		prevEntry, err := dbFetchCfEntry(dbTx, prevHash)
+		if err != nil {
+			return err
+		}
+		if prevEntry == nil {
+			return AssertError("compact block filter of previous " +
+				"block " + prevHash.String() + " is not indexed")
+		}
+		prevHeader = prevEntry.Header
+	}
+
+	filterHash := builder.GetFilterHash(filter)
+	entry := CfEntry{
+		Header:     builder.MakeHeaderForFilterHash(filterHash, &prevHeader),
+		FilterHash: filterHash,
+		Filter:     filter.NBytes(),
+	}
+	return dbTx.Metadata().Bucket(cfIndexKey).Put(block.Hash()[:],
+		serializeCfEntry(&entry))
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the filter of the
+// block.
+//
+// This is part of the Indexer interface.
+func (idx *CfIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	return dbTx.Metadata().Bucket(cfIndexKey).Delete(block.Hash()[:])
+}
+
+// CheckBlock verifies the entries the index stores for the passed block by
+// comparing them with the entries connecting the block produces.
+//
+// This is part of the Checker interface.
+func (idx *CfIndex) CheckBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint, repair bool) ([]IndexInconsistency, error) {
+	return checkBlockEntries(dbTx, repair, func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, view)
+	})
+}
+
+// VerifyEntries verifies the filter headers stored for the blocks at the
+// heights of the filter header checkpoints up to the tip of the index against
+// the checkpoints since light clients rely on them.
+//
+// This is part of the Verifier interface.
+func (idx *CfIndex) VerifyEntries(dbTx database.Tx) error {
+	_, tipHeight, err := dbFetchIndexerTip(dbTx, cfIndexKey)
+	if err != nil {
+		return err
+	}
+
+	for _, checkpoint := range idx.chainParams.FilterHeaderCheckpoints {
+		if int32(checkpoint.Height) > tipHeight {
+			continue
+		}
+
+		hash, err := blockchain.DBFetchHashByHeight(dbTx,
+			checkpoint.Height)
+		if err != nil {
+			return err
+		}
+		entry, err := dbFetchCfEntry(dbTx, hash)
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return fmt.Errorf("compact block filter of block %v at "+
+				"height %d is not indexed", hash,
+				checkpoint.Height)
+		}
+		if !entry.Header.IsEqual(checkpoint.Header) {
+			return fmt.Errorf("filter header %v at height %d does "+
+				"not match checkpoint %v", entry.Header,
+				checkpoint.Height, checkpoint.Header)
+		}
+	}
+	return nil
+}
+
+// Entries returns the filter entries of the blocks with the passed hashes.
+// When a block is not indexed, nil is returned for its entry.
+//
+// This function is safe for concurrent access.
+func (idx *CfIndex) Entries(hashes []chainhash.Hash) ([]*CfEntry, error) {
+	entries := make([]*CfEntry, 0, len(hashes))
+	err := idx.db.View(func(dbTx database.Tx) error {
+		for i := range hashes {
+			entry, err := dbFetchCfEntry(dbTx, &hashes[i])
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// NewCfIndex returns a new instance of an indexer that is used to store the
+// compact block filters of all blocks in the main chain.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewCfIndex(db database.DB, chainParams *chaincfg.Params) *CfIndex {
+	return &CfIndex{
+		db:          db,
+		chainParams: chainParams,
+	}
+}
+
+// DropCfIndex drops the compact block filter index from the provided database
+// if it exists.
+func DropCfIndex(db database.DB) error {
+	return dropIndex(db, cfIndexKey, cfIndexName)
+}
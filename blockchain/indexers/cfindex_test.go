@@ -0,0 +1,314 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/gcs/builder"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestCfEntrySerialization ensures compact block filter entries round trip
+// through serialization and that truncated entries are rejected.
+func TestCfEntrySerialization(t *testing.T) {
+	t.Parallel()
+
+	tests := []CfEntry{
+		{
+			Header:     chainhash.Hash{0x01},
+			FilterHash: chainhash.Hash{0x02},
+			Filter:     []byte{0x00},
+		},
+		{
+			Header:     chainhash.Hash{0x03, 0x04},
+			FilterHash: chainhash.Hash{0x05, 0x06},
+			Filter:     []byte{0x02, 0x9a, 0x3c, 0x11},
+		},
+	}
+
+	for i, test := range tests {
+		serialized := serializeCfEntry(&test)
+		got, err := deserializeCfEntry(serialized)
+		if err != nil {
+			t.Errorf("test #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, test) {
+			t.Errorf("test #%d: mismatched entry - got %+v, want %+v",
+				i, *got, test)
+		}
+
+		_, err = deserializeCfEntry(serialized[:cfEntryMinSize-1])
+		if !isDeserializeErr(err) {
+			t.Errorf("test #%d: unexpected error for short entry: "+
+				"%v", i, err)
+		}
+	}
+}
+
+// TestCfIndexConnectBlock ensures the filter headers of connected blocks chain
+// together and that the entries are removed when the blocks are disconnected.
+func TestCfIndexConnectBlock(t *testing.T) {
+	t.Parallel()
+
+	dbPath, err := ioutil.TempDir("", "cfindex")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	// Create a chain of blocks which only contain a coinbase.
+	var blocks []*provautil.Block
+	var prevHash chainhash.Hash
+	for height := uint32(0); height < 3; height++ {
+		coinbase := wire.NewMsgTx(1)
+		coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex), []byte{byte(height)}))
+		coinbase.AddTxOut(wire.NewTxOut(0, []byte{0x51, byte(height)}))
+		msgBlock := wire.NewMsgBlock(&wire.BlockHeader{
+			PrevBlock: prevHash,
+			Height:    height,
+		})
+		msgBlock.AddTransaction(coinbase)
+		block := provautil.NewBlock(msgBlock)
+		blocks = append(blocks, block)
+		prevHash = *block.Hash()
+	}
+
+	// Derive the filter headers the index is expected to store.
+	var filterHashes []chainhash.Hash
+	for _, block := range blocks {
+		filter, err := builder.BuildBasicFilter(block.MsgBlock(), nil,
+			&chaincfg.MainNetParams)
+		if err != nil {
+			t.Fatalf("BuildBasicFilter: unexpected error: %v", err)
+		}
+		filterHashes = append(filterHashes, builder.GetFilterHash(filter))
+	}
+	headers, err := builder.VerifyHeaders(nil, 0, &chainhash.Hash{},
+		filterHashes)
+	if err != nil {
+		t.Fatalf("VerifyHeaders: unexpected error: %v", err)
+	}
+
+	idx := NewCfIndex(db, &chaincfg.MainNetParams)
+	err = db.Update(func(dbTx database.Tx) error {
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+		for _, block := range blocks {
+			if err := idx.ConnectBlock(dbTx, block, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to connect blocks: %v", err)
+	}
+
+	hashes := []chainhash.Hash{*blocks[0].Hash(), *blocks[1].Hash(),
+		*blocks[2].Hash()}
+	entries, err := idx.Entries(hashes)
+	if err != nil {
+		t.Fatalf("Entries: unexpected error: %v", err)
+	}
+	for i, entry := range entries {
+		if entry == nil || entry.Header != headers[i] ||
+			entry.FilterHash != filterHashes[i] {
+
+			t.Fatalf("Entries: mismatched entry %d - got %+v", i,
+				entry)
+		}
+	}
+
+	// Disconnecting the last block removes its entry.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, blocks[2], nil)
+	})
+	if err != nil {
+		t.Fatalf("unable to disconnect block: %v", err)
+	}
+	entries, err = idx.Entries(hashes)
+	if err != nil {
+		t.Fatalf("Entries: unexpected error: %v", err)
+	}
+	if entries[1] == nil || entries[2] != nil {
+		t.Fatalf("Entries: got entries %+v after disconnect", entries)
+	}
+}
+
+// TestCfIndexCheckpoints ensures the index manager verifies the compact block
+// filter index against the filter header checkpoints when it is loaded and
+// when it has caught up, and that it disables the index on a mismatch without
+// holding up the chain.
+func TestCfIndexCheckpoints(t *testing.T) {
+	t.Parallel()
+
+	scenario := &fullblocktests.Scenario{
+		Seed:  2,
+		Steps: []fullblocktests.ScenarioStep{{Op: "mine", Count: 12}},
+	}
+	blocks, err := fullblocktests.GenerateScenario(scenario)
+	if err != nil {
+		t.Fatalf("GenerateScenario: unexpected error: %v", err)
+	}
+
+	dbPath, err := ioutil.TempDir("", "cfindexcheckpoints")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		chaincfg.RegressionNetParams.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	// newManager returns an index manager for the transaction index and a
+	// compact block filter index using the passed checkpoints along with a
+	// chain instance which uses it, like a node does on every start.
+	newManager := func(checkpoints []chaincfg.FilterHeaderCheckpoint) (*Manager, *CfIndex, *blockchain.BlockChain) {
+		cfParams := chaincfg.RegressionNetParams
+		cfParams.FilterHeaderCheckpoints = checkpoints
+		cfIndex := NewCfIndex(db, &cfParams)
+		m := NewManager(db, []Indexer{NewTxIndex(db), cfIndex})
+
+		params := chaincfg.RegressionNetParams
+		chain, err := blockchain.New(&blockchain.Config{
+			DB:           db,
+			ChainParams:  &params,
+			TimeSource:   blockchain.NewMedianTime(),
+			SigCache:     txscript.NewSigCache(1000),
+			IndexManager: m,
+		})
+		if err != nil {
+			t.Fatalf("unable to create chain: %v", err)
+		}
+		return m, cfIndex, chain
+	}
+	processBlocks := func(chain *blockchain.BlockChain,
+		blocks []fullblocktests.ScenarioBlock) {
+
+		for _, item := range blocks {
+			block := provautil.NewBlock(item.Block)
+			block.SetHeight(item.Height)
+			_, _, err := chain.ProcessBlock(block, blockchain.BFNone)
+			if err != nil {
+				t.Fatalf("unable to process block %q: %v",
+					item.Name, err)
+			}
+		}
+	}
+	// waitForCatchUp waits for all indexes of the passed manager to catch
+	// up to the main chain.
+	waitForCatchUp := func(m *Manager) {
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			statuses, err := m.IndexStatuses()
+			if err != nil {
+				t.Fatalf("IndexStatuses: unexpected error: %v", err)
+			}
+			synced := true
+			for _, status := range statuses {
+				synced = synced && status.Synced
+			}
+			if synced {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("indexes did not catch up: %+v", statuses)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	fetchEntry := func(cfIndex *CfIndex, item fullblocktests.ScenarioBlock) *CfEntry {
+		entries, err := cfIndex.Entries([]chainhash.Hash{
+			item.Block.BlockHash()})
+		if err != nil {
+			t.Fatalf("Entries: unexpected error: %v", err)
+		}
+		return entries[0]
+	}
+
+	// Sync most of the chain without any checkpoints.
+	m, cfIndex, chain := newManager(nil)
+	m.Start()
+	processBlocks(chain, blocks[:10])
+	waitForCatchUp(m)
+	m.Stop()
+	checkpoint := fetchEntry(cfIndex, blocks[5]).Header
+
+	// Matching checkpoints and checkpoints past the tip of the index are
+	// accepted when the index is loaded.
+	m, cfIndex, _ = newManager([]chaincfg.FilterHeaderCheckpoint{
+		{Height: blocks[5].Height, Header: &checkpoint},
+		{Height: blocks[11].Height, Header: &chainhash.Hash{}},
+	})
+	if !m.IndexEnabled(cfIndex) {
+		t.Fatal("index disabled despite matching checkpoints")
+	}
+
+	// A mismatched checkpoint disables the index when it is loaded while
+	// the chain keeps connecting blocks.
+	wrongCheckpoints := []chaincfg.FilterHeaderCheckpoint{
+		{Height: blocks[5].Height, Header: &chainhash.Hash{0x01}},
+	}
+	m, cfIndex, chain = newManager(wrongCheckpoints)
+	if m.IndexEnabled(cfIndex) {
+		t.Fatal("index enabled despite a mismatched checkpoint")
+	}
+	m.Start()
+	processBlocks(chain, blocks[10:])
+	m.Stop()
+	if entry := fetchEntry(cfIndex, blocks[11]); entry != nil {
+		t.Fatalf("disabled index connected a block: %+v", entry)
+	}
+
+	// A mismatched checkpoint also disables the index once it has been
+	// rebuilt from scratch and caught up in the background.
+	if err := DropCfIndex(db); err != nil {
+		t.Fatalf("DropCfIndex: unexpected error: %v", err)
+	}
+	m, cfIndex, _ = newManager(wrongCheckpoints)
+	if !m.IndexEnabled(cfIndex) {
+		t.Fatal("empty index disabled before catching up")
+	}
+	m.Start()
+	defer m.Stop()
+	deadline := time.Now().Add(10 * time.Second)
+	for m.IndexEnabled(cfIndex) {
+		if time.Now().After(deadline) {
+			t.Fatal("index not disabled after catching up")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if entry := fetchEntry(cfIndex, blocks[11]); entry == nil {
+		t.Fatal("index disabled before catching up")
+	}
+}
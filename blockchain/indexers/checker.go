@@ -0,0 +1,206 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+// Checker provides an interface for an indexer which is able to verify the
+// entries it stores for a block against the block itself.  Indexes which
+// implement it can be checked and repaired by the index manager.
+type Checker interface {
+	// CheckBlock verifies the entries the index stores for the passed
+	// block, which must already be connected to the index, and returns
+	// every entry which diverges from the block.  When repair is set,
+	// the divergent entries are also rewritten, which requires the passed
+	// database transaction to be writable.
+	CheckBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint, repair bool) ([]IndexInconsistency, error)
+}
+
+// IndexInconsistency describes an index entry which does not match the block
+// it was created for.
+type IndexInconsistency struct {
+	// Height and BlockHash identify the block the entry belongs to.
+	Height    int32
+	BlockHash chainhash.Hash
+
+	// Key is the database key of the divergent entry.
+	Key []byte
+
+	// Reason describes how the entry diverges.  It is one of "missing",
+	// "mismatched", or "unexpected".
+	Reason string
+
+	// Repaired is whether or not the entry has been rewritten.
+	Repaired bool
+}
+
+// recordedWrite is a write made to a recordingBucket.  A nil value indicates
+// the key was deleted.
+type recordedWrite struct {
+	key   []byte
+	value []byte
+}
+
+// dbBucket is an alias for database.Bucket which allows recordingBucket to embed
+// it while overriding its Bucket method.
+type dbBucket = database.Bucket
+
+// recordingBucket wraps a database bucket and records all writes to it instead
+// of applying them.  Reads observe the recorded writes so code which reads back
+// what it wrote behaves the same as it would against the real bucket.
+type recordingBucket struct {
+	dbBucket
+
+	tx     *recordingTx
+	path   string
+	writes map[string]int
+	order  []recordedWrite
+}
+
+// Bucket returns a recording wrapper around the nested bucket with the passed
+// key.
+//
+// This is part of the database.Bucket interface.
+func (b *recordingBucket) Bucket(key []byte) database.Bucket {
+	bucket := b.dbBucket.Bucket(key)
+	if bucket == nil {
+		return nil
+	}
+	return b.tx.wrap(b.path+"/"+string(key), bucket)
+}
+
+// Get returns the value for the passed key taking the recorded writes into
+// account.
+//
+// This is part of the database.Bucket interface.
+func (b *recordingBucket) Get(key []byte) []byte {
+	if i, ok := b.writes[string(key)]; ok {
+		return b.order[i].value
+	}
+	return b.dbBucket.Get(key)
+}
+
+// record records a write of the passed value to the passed key.
+func (b *recordingBucket) record(key, value []byte) {
+	write := recordedWrite{
+		key:   append([]byte(nil), key...),
+		value: value,
+	}
+	if i, ok := b.writes[string(key)]; ok {
+		b.order[i] = write
+		return
+	}
+	b.writes[string(key)] = len(b.order)
+	b.order = append(b.order, write)
+}
+
+// Put records the passed key/value pair without writing it.
+//
+// This is part of the database.Bucket interface.
+func (b *recordingBucket) Put(key, value []byte) error {
+	b.record(key, append([]byte{}, value...))
+	return nil
+}
+
+// Delete records the deletion of the passed key without deleting it.
+//
+// This is part of the database.Bucket interface.
+func (b *recordingBucket) Delete(key []byte) error {
+	b.record(key, nil)
+	return nil
+}
+
+// recordingTx wraps a database transaction so that all writes made to its
+// metadata buckets are recorded instead of applied.
+type recordingTx struct {
+	database.Tx
+
+	buckets []*recordingBucket
+}
+
+// wrap returns the recording wrapper for the passed bucket which is identified
+// by the passed path of bucket keys.  The same wrapper is returned every time
+// the same bucket is requested so all writes to it are seen.
+func (tx *recordingTx) wrap(path string, bucket database.Bucket) *recordingBucket {
+	for _, b := range tx.buckets {
+		if b.path == path {
+			return b
+		}
+	}
+	b := &recordingBucket{
+		dbBucket: bucket,
+		tx:       tx,
+		path:     path,
+		writes:   make(map[string]int),
+	}
+	tx.buckets = append(tx.buckets, b)
+	return b
+}
+
+// Metadata returns a recording wrapper around the metadata bucket of the
+// wrapped transaction.
+//
+// This is part of the database.Tx interface.
+func (tx *recordingTx) Metadata() database.Bucket {
+	return tx.wrap("", tx.Tx.Metadata())
+}
+
+// checkBlockEntries verifies the entries of an index by invoking the passed
+// function, which must write the entries the index stores for a block, against
+// a recording transaction and comparing what it writes with the database.  Each
+// divergent entry is rewritten when repair is set.
+//
+// The function must write the same entries every time it is invoked for the
+// same block, regardless of the current contents of the index.
+func checkBlockEntries(dbTx database.Tx, repair bool, writeEntries func(dbTx database.Tx) error) ([]IndexInconsistency, error) {
+	recTx := &recordingTx{Tx: dbTx}
+	if err := writeEntries(recTx); err != nil {
+		return nil, err
+	}
+
+	var inconsistencies []IndexInconsistency
+	for _, b := range recTx.buckets {
+		for _, write := range b.order {
+			stored := b.dbBucket.Get(write.key)
+			var reason string
+			switch {
+			case write.value == nil && stored != nil:
+				reason = "unexpected"
+			case write.value != nil && stored == nil:
+				reason = "missing"
+			case !bytes.Equal(write.value, stored):
+				reason = "mismatched"
+			default:
+				continue
+			}
+
+			if repair {
+				var err error
+				if write.value == nil {
+					err = b.dbBucket.Delete(write.key)
+				} else {
+					err = b.dbBucket.Put(write.key, write.value)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+			inconsistencies = append(inconsistencies, IndexInconsistency{
+				Key:      write.key,
+				Reason:   reason,
+				Repaired: repair,
+			})
+		}
+	}
+
+	return inconsistencies, nil
+}
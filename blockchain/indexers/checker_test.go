@@ -0,0 +1,146 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/database"
+)
+
+// testBucket provides a mock database bucket by implementing the methods of
+// the database.Bucket interface used by checkBlockEntries.
+type testBucket struct {
+	dbBucket
+
+	data    map[string][]byte
+	buckets map[string]*testBucket
+}
+
+// newTestBucket returns a new empty mock bucket.
+func newTestBucket() *testBucket {
+	return &testBucket{
+		data:    make(map[string][]byte),
+		buckets: make(map[string]*testBucket),
+	}
+}
+
+// Bucket returns the nested bucket with the passed key.
+//
+// This is part of the database.Bucket interface.
+func (b *testBucket) Bucket(key []byte) database.Bucket {
+	if bucket, ok := b.buckets[string(key)]; ok {
+		return bucket
+	}
+	return nil
+}
+
+// Get returns the value for the passed key.
+//
+// This is part of the database.Bucket interface.
+func (b *testBucket) Get(key []byte) []byte {
+	return b.data[string(key)]
+}
+
+// Put stores the passed key/value pair.
+//
+// This is part of the database.Bucket interface.
+func (b *testBucket) Put(key, value []byte) error {
+	b.data[string(key)] = value
+	return nil
+}
+
+// Delete removes the passed key.
+//
+// This is part of the database.Bucket interface.
+func (b *testBucket) Delete(key []byte) error {
+	delete(b.data, string(key))
+	return nil
+}
+
+// testTx provides a mock database transaction which only provides access to
+// the metadata bucket.
+type testTx struct {
+	database.Tx
+
+	meta *testBucket
+}
+
+// Metadata returns the metadata bucket.
+//
+// This is part of the database.Tx interface.
+func (tx *testTx) Metadata() database.Bucket {
+	return tx.meta
+}
+
+// TestCheckBlockEntries ensures divergent entries are detected by comparing the
+// entries written for a block with the stored ones, that the stored entries are
+// left untouched unless a repair is requested, and that repairs rewrite them.
+func TestCheckBlockEntries(t *testing.T) {
+	t.Parallel()
+
+	bucket := newTestBucket()
+	bucket.data["ok"] = []byte{0x01}
+	bucket.data["bad"] = []byte{0x02}
+	bucket.data["stale"] = []byte{0x03}
+	meta := newTestBucket()
+	meta.buckets["idx"] = bucket
+	dbTx := &testTx{meta: meta}
+
+	writeEntries := func(dbTx database.Tx) error {
+		b := dbTx.Metadata().Bucket([]byte("idx"))
+		b.Put([]byte("ok"), []byte{0x01})
+		b.Put([]byte("bad"), []byte{0x01})
+		b.Put([]byte("new"), []byte{0x01})
+		b.Delete([]byte("stale"))
+
+		// Writes must be visible to later reads within the same
+		// block and the last write of a key wins.
+		if got := b.Get([]byte("new")); !reflect.DeepEqual(got, []byte{0x01}) {
+			t.Fatalf("recorded write not visible - got %x", got)
+		}
+		b.Put([]byte("ok"), []byte{0x01})
+		return nil
+	}
+
+	want := []IndexInconsistency{
+		{Key: []byte("bad"), Reason: "mismatched"},
+		{Key: []byte("new"), Reason: "missing"},
+		{Key: []byte("stale"), Reason: "unexpected"},
+	}
+	got, err := checkBlockEntries(dbTx, false, writeEntries)
+	if err != nil {
+		t.Fatalf("checkBlockEntries: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("checkBlockEntries: mismatched inconsistencies - got "+
+			"%+v, want %+v", got, want)
+	}
+	if len(bucket.data) != 3 || bucket.data["bad"][0] != 0x02 {
+		t.Fatalf("checkBlockEntries: entries modified without repair")
+	}
+
+	// Repair the entries and ensure they are consistent afterwards.
+	for i := range want {
+		want[i].Repaired = true
+	}
+	got, err = checkBlockEntries(dbTx, true, writeEntries)
+	if err != nil {
+		t.Fatalf("checkBlockEntries: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("checkBlockEntries: mismatched repairs - got %+v, "+
+			"want %+v", got, want)
+	}
+	got, err = checkBlockEntries(dbTx, false, writeEntries)
+	if err != nil {
+		t.Fatalf("checkBlockEntries: unexpected error: %v", err)
+	}
+	if len(got) != 0 {
+		t.Fatalf("checkBlockEntries: inconsistencies after repair: "+
+			"%+v", got)
+	}
+}
@@ -28,6 +28,25 @@ type NeedsInputser interface {
 	NeedsInputs() bool
 }
 
+// Versioner provides a generic interface for an indexer to specify the version
+// of the format of its entries.  An index which was built with a different
+// version is dropped and rebuilt from scratch when the index manager is
+// initialized.  Indexers which do not implement it are at version 1.
+type Versioner interface {
+	Version() uint32
+}
+
+// Verifier provides a generic interface for an indexer to verify its entries
+// against data which does not come from the chain, such as checkpoints.  The
+// index manager verifies an index whenever it is caught up to the main chain,
+// including on every load, and disables it when the verification fails rather
+// than failing the processing of blocks.
+type Verifier interface {
+	// VerifyEntries verifies the entries of the index up to its current
+	// tip using the passed database transaction.
+	VerifyEntries(dbTx database.Tx) error
+}
+
 // Indexer provides a generic interface for an indexer that is managed by an
 // index manager such as the Manager type provided by this package.
 type Indexer interface {
@@ -89,3 +108,26 @@ type internalBucket interface {
 	Put(key []byte, value []byte) error
 	Delete(key []byte) error
 }
+
+// seekLastWithPrefix positions the passed cursor at the last key which starts
+// with the passed prefix and returns whether a key exists at that position.
+// When no key starts with the prefix, the cursor is positioned at the last key
+// which sorts before it, so callers must still check the prefix of the key.
+func seekLastWithPrefix(cursor database.Cursor, prefix []byte) bool {
+	// The first key after all keys with the prefix is found by seeking to
+	// the prefix incremented by one.  A prefix which only consists of 0xff
+	// bytes can not be incremented, so all keys sort before it.
+	next := make([]byte, len(prefix))
+	copy(next, prefix)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] == 0 {
+			continue
+		}
+		if cursor.Seek(next[:i+1]) {
+			return cursor.Prev()
+		}
+		break
+	}
+	return cursor.Last()
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// newTestIndexDB creates a database in a temporary directory and returns it
+// along with a function which closes and removes it.
+func newTestIndexDB(t *testing.T, name string) (database.DB, func()) {
+	dbPath, err := ioutil.TempDir("", name)
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		os.RemoveAll(dbPath)
+		t.Fatalf("unable to create database: %v", err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dbPath)
+	}
+}
+
+// testProvaAddr returns a Prova address on the main network with a public key
+// hash made up of the passed byte and the passed key IDs, along with the public
+// key script paying to it.
+func testProvaAddr(t *testing.T, b byte, keyIDs ...btcec.KeyID) (*provautil.AddressProva, []byte) {
+	pkHash := make([]byte, 20)
+	for i := range pkHash {
+		pkHash[i] = b
+	}
+	addr, err := provautil.NewAddressProva(pkHash, keyIDs,
+		&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create address: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("unable to create script: %v", err)
+	}
+	return addr, pkScript
+}
+
+// testIndexChain is a chain of blocks, each with a coinbase followed by a
+// single transaction, for testing how indexes connect and disconnect blocks.
+type testIndexChain struct {
+	txns   []*wire.MsgTx
+	blocks []*provautil.Block
+}
+
+// newTestIndexChain returns a new test chain with a block at height one and
+// up for each passed transaction.
+func newTestIndexChain(txns ...*wire.MsgTx) *testIndexChain {
+	c := &testIndexChain{txns: txns}
+	prevHash := chainhash.Hash{}
+	for i, tx := range txns {
+		msgBlock := wire.NewMsgBlock(&wire.BlockHeader{
+			PrevBlock: prevHash,
+			Height:    uint32(i + 1),
+		})
+		coinbase := wire.NewMsgTx(1)
+		coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+			wire.MaxPrevOutIndex), nil))
+		coinbase.AddTxOut(wire.NewTxOut(0, nil))
+		msgBlock.AddTransaction(coinbase)
+		msgBlock.AddTransaction(tx)
+		block := provautil.NewBlock(msgBlock)
+		c.blocks = append(c.blocks, block)
+		prevHash = *block.Hash()
+	}
+	return c
+}
+
+// view returns a view of the outputs created by the blocks before the block at
+// the passed index.
+func (c *testIndexChain) view(i int) *blockchain.UtxoViewpoint {
+	view := blockchain.NewUtxoViewpoint()
+	for j, tx := range c.txns[:i] {
+		view.AddTxOuts(provautil.NewTx(tx), uint32(j+1))
+	}
+	return view
+}
+
+// newTestPaymentChain returns a test chain of two blocks along with two
+// addresses.  The first block funds address A, which references key IDs 1 and
+// 2, with 1000 and the second block spends the output to pay 600 to address B,
+// which references key IDs 1 and 3, with 400 change back to address A.
+func newTestPaymentChain(t *testing.T) (*testIndexChain, *provautil.AddressProva, *provautil.AddressProva) {
+	addrA, scriptA := testProvaAddr(t, 0x0a, 1, 2)
+	addrB, scriptB := testProvaAddr(t, 0x0b, 1, 3)
+
+	fund := wire.NewMsgTx(1)
+	fund.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{0xee}, 0),
+		nil))
+	fund.AddTxOut(wire.NewTxOut(1000, scriptA))
+	fundHash := fund.TxHash()
+
+	spend := wire.NewMsgTx(1)
+	spend.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&fundHash, 0), nil))
+	spend.AddTxOut(wire.NewTxOut(600, scriptB))
+	spend.AddTxOut(wire.NewTxOut(400, scriptA))
+
+	return newTestIndexChain(fund, spend), addrA, addrB
+}
+
+// connectTestChain creates the passed index and connects the blocks of the
+// passed chain to it.
+func connectTestChain(t *testing.T, db database.DB, idx Indexer, c *testIndexChain) {
+	err := db.Update(func(dbTx database.Tx) error {
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+		for i, block := range c.blocks {
+			err := idx.ConnectBlock(dbTx, block, c.view(i))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to connect blocks: %v", err)
+	}
+}
+
+// disconnectTestTip disconnects the last block of the passed chain from the
+// passed index.
+func disconnectTestTip(t *testing.T, db database.DB, idx Indexer, c *testIndexChain) {
+	err := db.Update(func(dbTx database.Tx) error {
+		i := len(c.blocks) - 1
+		return idx.DisconnectBlock(dbTx, c.blocks[i], c.view(i))
+	})
+	if err != nil {
+		t.Fatalf("unable to disconnect block: %v", err)
+	}
+}
@@ -0,0 +1,395 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+const (
+	// feeStatsIndexName is the human-readable name for the index.
+	feeStatsIndexName = "fee stats index"
+
+	// feeStatsKeySize is the number of bytes a key in the fee stats index
+	// consumes.  It consists of 4 bytes block height.
+	feeStatsKeySize = 4
+
+	// feeStatsEntrySize is the number of bytes a value in the fee stats
+	// index consumes.  It consists of 8 bytes total fee + 4 bytes standard
+	// tx count + 4 bytes admin tx count + 8 bytes total size + 8 bytes
+	// minimum fee rate + 8 bytes maximum fee rate + 8 bytes for each fee
+	// rate percentile.
+	feeStatsEntrySize = 8 + 4 + 4 + 8 + 8 + 8 + 8*len(FeeRatePercentiles)
+)
+
+var (
+	// feeStatsIndexKey is the key of the fee stats index and the db bucket
+	// used to house it.
+	feeStatsIndexKey = []byte("feestatsidx")
+
+	// FeeRatePercentiles are the percentiles of the fee rates recorded for
+	// every block.  The percentiles are weighted by the size of the
+	// transactions.
+	FeeRatePercentiles = [5]int{10, 25, 50, 75, 90}
+)
+
+// -----------------------------------------------------------------------------
+// The fee stats index consists of an entry for every block in the main chain
+// which holds aggregate fee data of the block.  The entries are keyed by the
+// block height serialized big endian, so the stats of a range of blocks are
+// read with a single cursor scan.
+//
+// Only standard transactions contribute to the fee data.  The coinbase does not
+// pay any fees and admin transactions may issue new tokens, so neither of them
+// has a meaningful fee.  Fee rates are in atoms per byte of the serialized
+// transaction.
+//
+// The serialized format for the keys and values in the fee stats index bucket
+// is:
+//
+//   <block height> = <total fee><num standard txns><num admin txns>
+//                    <total size><min fee rate><max fee rate>
+//                    <fee rate percentiles>
+//
+//   Field                 Type      Size
+//   block height          uint32    4 bytes (big endian)
+//   total fee             int64     8 bytes
+//   num standard txns     uint32    4 bytes
+//   num admin txns        uint32    4 bytes
+//   total size            uint64    8 bytes
+//   min fee rate          int64     8 bytes
+//   max fee rate          int64     8 bytes
+//   fee rate percentiles  [5]int64  40 bytes
+//   -----
+//   Total: 84 bytes
+// -----------------------------------------------------------------------------
+
+// BlockFeeStats houses aggregate fee data of a block.  The fee data only covers
+// the standard transactions of the block.
+type BlockFeeStats struct {
+	// Height is the height of the block.
+	Height uint32
+
+	// TotalFee is the sum of the fees paid by the standard transactions.
+	TotalFee int64
+
+	// NumStandardTxns is the number of standard transactions.
+	NumStandardTxns uint32
+
+	// NumAdminTxns is the number of admin transactions.
+	NumAdminTxns uint32
+
+	// TotalSize is the sum of the serialized sizes of the standard
+	// transactions.
+	TotalSize uint64
+
+	// MinFeeRate and MaxFeeRate are the lowest and highest fee rates paid
+	// by a standard transaction in atoms per byte.
+	MinFeeRate int64
+	MaxFeeRate int64
+
+	// FeeRates are the fee rates at the percentiles given by
+	// FeeRatePercentiles in atoms per byte.
+	FeeRates [len(FeeRatePercentiles)]int64
+}
+
+// feeStatsKeyFor returns the key used to store the fee stats of the block at
+// the passed height.
+func feeStatsKeyFor(height uint32) []byte {
+	key := make([]byte, feeStatsKeySize)
+	binary.BigEndian.PutUint32(key, height)
+	return key
+}
+
+// serializeBlockFeeStats serializes the passed fee stats according to the
+// format described in detail above.  The height is part of the key.
+func serializeBlockFeeStats(stats *BlockFeeStats) []byte {
+	serialized := make([]byte, feeStatsEntrySize)
+	byteOrder.PutUint64(serialized, uint64(stats.TotalFee))
+	byteOrder.PutUint32(serialized[8:], stats.NumStandardTxns)
+	byteOrder.PutUint32(serialized[12:], stats.NumAdminTxns)
+	byteOrder.PutUint64(serialized[16:], stats.TotalSize)
+	byteOrder.PutUint64(serialized[24:], uint64(stats.MinFeeRate))
+	byteOrder.PutUint64(serialized[32:], uint64(stats.MaxFeeRate))
+	for i, feeRate := range stats.FeeRates {
+		byteOrder.PutUint64(serialized[40+i*8:], uint64(feeRate))
+	}
+	return serialized
+}
+
+// deserializeBlockFeeStats decodes the passed key and serialized value into the
+// passed fee stats.
+func deserializeBlockFeeStats(key, serialized []byte, stats *BlockFeeStats) error {
+	if len(key) != feeStatsKeySize {
+		return errDeserialize("unexpected fee stats key length")
+	}
+	if len(serialized) != feeStatsEntrySize {
+		return errDeserialize("unexpected fee stats entry length")
+	}
+
+	stats.Height = binary.BigEndian.Uint32(key)
+	stats.TotalFee = int64(byteOrder.Uint64(serialized))
+	stats.NumStandardTxns = byteOrder.Uint32(serialized[8:])
+	stats.NumAdminTxns = byteOrder.Uint32(serialized[12:])
+	stats.TotalSize = byteOrder.Uint64(serialized[16:])
+	stats.MinFeeRate = int64(byteOrder.Uint64(serialized[24:]))
+	stats.MaxFeeRate = int64(byteOrder.Uint64(serialized[32:]))
+	for i := range stats.FeeRates {
+		stats.FeeRates[i] = int64(byteOrder.Uint64(serialized[40+i*8:]))
+	}
+	return nil
+}
+
+// txFee houses the fee and size of a standard transaction.
+type txFee struct {
+	fee     int64
+	size    int64
+	feeRate int64
+}
+
+// calcBlockFeeStats returns the fee stats of the standard transactions with the
+// passed fees.  The admin transaction count and the height are left unset.
+func calcBlockFeeStats(fees []txFee) BlockFeeStats {
+	var stats BlockFeeStats
+	if len(fees) == 0 {
+		return stats
+	}
+
+	sort.Slice(fees, func(i, j int) bool {
+		return fees[i].feeRate < fees[j].feeRate
+	})
+	stats.NumStandardTxns = uint32(len(fees))
+	stats.MinFeeRate = fees[0].feeRate
+	stats.MaxFeeRate = fees[len(fees)-1].feeRate
+	for _, fee := range fees {
+		stats.TotalFee += fee.fee
+		stats.TotalSize += uint64(fee.size)
+	}
+
+	// The fee rate at a percentile is the fee rate of the transaction
+	// containing the byte at that percentile when all transactions are
+	// ordered by fee rate.
+	var cumulativeSize uint64
+	next := 0
+	for _, fee := range fees {
+		cumulativeSize += uint64(fee.size)
+		for next < len(FeeRatePercentiles) &&
+			cumulativeSize*100 >= stats.TotalSize*uint64(FeeRatePercentiles[next]) {
+
+			stats.FeeRates[next] = fee.feeRate
+			next++
+		}
+	}
+	return stats
+}
+
+// blockFeeStats returns the fee stats of the passed block using the passed view
+// to look up the values of the spent outputs.
+func blockFeeStats(block *provautil.Block, view *blockchain.UtxoViewpoint) BlockFeeStats {
+	var numAdminTxns uint32
+	var fees []txFee
+	for txIdx, tx := range block.Transactions() {
+		// The coinbase does not pay any fees.
+		if txIdx == 0 {
+			continue
+		}
+		if threadInt, _ := txscript.GetAdminDetails(tx); threadInt >= 0 {
+			numAdminTxns++
+			continue
+		}
+
+		var totalIn int64
+		for _, txIn := range tx.MsgTx().TxIn {
+			// The view should always have the input since the
+			// index contract requires it, however, be safe and
+			// simply ignore any missing entries.
+			if spent := spentUtxo(view, txIn); spent != nil {
+				totalIn += spent.Value
+			}
+		}
+		var totalOut int64
+		for _, txOut := range tx.MsgTx().TxOut {
+			totalOut += txOut.Value
+		}
+
+		fee := totalIn - totalOut
+		size := int64(tx.MsgTx().SerializeSize())
+		fees = append(fees, txFee{
+			fee:     fee,
+			size:    size,
+			feeRate: fee / size,
+		})
+	}
+
+	stats := calcBlockFeeStats(fees)
+	stats.Height = block.Height()
+	stats.NumAdminTxns = numAdminTxns
+	return stats
+}
+
+// dbFetchBlockFeeStats uses an existing database transaction to retrieve the
+// fee stats of the main chain blocks in the passed inclusive height range.
+func dbFetchBlockFeeStats(dbTx database.Tx, startHeight, endHeight uint32) ([]BlockFeeStats, error) {
+	var results []BlockFeeStats
+	cursor := dbTx.Metadata().Bucket(feeStatsIndexKey).Cursor()
+	for ok := cursor.Seek(feeStatsKeyFor(startHeight)); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if len(key) == feeStatsKeySize &&
+			binary.BigEndian.Uint32(key) > endHeight {
+
+			break
+		}
+
+		var stats BlockFeeStats
+		err := deserializeBlockFeeStats(key, cursor.Value(), &stats)
+		if err != nil {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: "failed to deserialize fee stats " +
+					"entry: " + err.Error(),
+			}
+		}
+		results = append(results, stats)
+	}
+
+	return results, nil
+}
+
+// FeeStatsIndex implements an index of aggregate fee data of every block in the
+// main chain.  That is to say, it supports querying the fees paid in a range of
+// blocks without loading the blocks and the outputs they spend.
+type FeeStatsIndex struct {
+	db database.DB
+}
+
+// Ensure the FeeStatsIndex type implements the Indexer interface.
+var _ Indexer = (*FeeStatsIndex)(nil)
+
+// Ensure the FeeStatsIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*FeeStatsIndex)(nil)
+
+// Ensure the FeeStatsIndex type implements the Checker interface.
+var _ Checker = (*FeeStatsIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *FeeStatsIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *FeeStatsIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *FeeStatsIndex) Key() []byte {
+	return feeStatsIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *FeeStatsIndex) Name() string {
+	return feeStatsIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the fee stats
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *FeeStatsIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(feeStatsIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer stores the fee stats of the block.
+//
+// This is part of the Indexer interface.
+func (idx *FeeStatsIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	stats := blockFeeStats(block, view)
+	return dbTx.Metadata().Bucket(feeStatsIndexKey).Put(
+		feeStatsKeyFor(block.Height()), serializeBlockFeeStats(&stats))
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the fee stats of the
+// block.
+//
+// This is part of the Indexer interface.
+func (idx *FeeStatsIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	key := feeStatsKeyFor(block.Height())
+	return dbTx.Metadata().Bucket(feeStatsIndexKey).Delete(key)
+}
+
+// CheckBlock verifies the entries the index stores for the passed block by
+// comparing them with the entries connecting the block produces.
+//
+// This is part of the Checker interface.
+func (idx *FeeStatsIndex) CheckBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint, repair bool) ([]IndexInconsistency, error) {
+	return checkBlockEntries(dbTx, repair, func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, view)
+	})
+}
+
+// FeeStats returns the fee stats of the main chain block at the passed height.
+// When the block is not indexed, nil will be returned for the stats along with
+// a nil error.
+//
+// This function is safe for concurrent access.
+func (idx *FeeStatsIndex) FeeStats(height uint32) (*BlockFeeStats, error) {
+	results, err := idx.FeeStatsRange(height, height)
+	if err != nil || len(results) == 0 {
+		return nil, err
+	}
+	return &results[0], nil
+}
+
+// FeeStatsRange returns the fee stats of the indexed main chain blocks in the
+// passed inclusive height range ordered by height.
+//
+// This function is safe for concurrent access.
+func (idx *FeeStatsIndex) FeeStatsRange(startHeight, endHeight uint32) ([]BlockFeeStats, error) {
+	var results []BlockFeeStats
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		results, err = dbFetchBlockFeeStats(dbTx, startHeight,
+			endHeight)
+		return err
+	})
+	return results, err
+}
+
+// NewFeeStatsIndex returns a new instance of an indexer that is used to store
+// aggregate fee data of all blocks in the main chain.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewFeeStatsIndex(db database.DB) *FeeStatsIndex {
+	return &FeeStatsIndex{db: db}
+}
+
+// DropFeeStatsIndex drops the fee stats index from the provided database if it
+// exists.
+func DropFeeStatsIndex(db database.DB) error {
+	return dropIndex(db, feeStatsIndexKey, feeStatsIndexName)
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"testing"
+)
+
+// TestCalcBlockFeeStats ensures the fee stats of a block are aggregated and
+// the fee rate percentiles are weighted by transaction size.
+func TestCalcBlockFeeStats(t *testing.T) {
+	t.Parallel()
+
+	// The transaction paying 1 atom per byte makes up the first 40% of
+	// the bytes, so it determines both the 10th and the 25th percentile.
+	fees := []txFee{
+		{fee: 2000, size: 200, feeRate: 10},
+		{fee: 400, size: 400, feeRate: 1},
+		{fee: 1000, size: 200, feeRate: 5},
+		{fee: 600, size: 200, feeRate: 3},
+	}
+	got := calcBlockFeeStats(fees)
+	want := BlockFeeStats{
+		TotalFee:        4000,
+		NumStandardTxns: 4,
+		TotalSize:       1000,
+		MinFeeRate:      1,
+		MaxFeeRate:      10,
+		FeeRates:        [5]int64{1, 1, 3, 5, 10},
+	}
+	if got != want {
+		t.Fatalf("mismatched stats - got %+v, want %+v", got, want)
+	}
+
+	if got := calcBlockFeeStats(nil); got != (BlockFeeStats{}) {
+		t.Fatalf("unexpected stats for no transactions: %+v", got)
+	}
+}
+
+// TestBlockFeeStatsSerialization ensures fee stats entries round trip through
+// serialization.
+func TestBlockFeeStatsSerialization(t *testing.T) {
+	t.Parallel()
+
+	stats := BlockFeeStats{
+		Height:          100000,
+		TotalFee:        123456789,
+		NumStandardTxns: 12,
+		NumAdminTxns:    2,
+		TotalSize:       4500,
+		MinFeeRate:      1,
+		MaxFeeRate:      250,
+		FeeRates:        [5]int64{1, 2, 10, 40, 200},
+	}
+	key := feeStatsKeyFor(stats.Height)
+	serialized := serializeBlockFeeStats(&stats)
+
+	var got BlockFeeStats
+	if err := deserializeBlockFeeStats(key, serialized, &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != stats {
+		t.Fatalf("mismatched stats - got %+v, want %+v", got, stats)
+	}
+	err := deserializeBlockFeeStats(key, serialized[:40], &got)
+	if !isDeserializeErr(err) {
+		t.Fatalf("unexpected error for short entry: %v", err)
+	}
+}
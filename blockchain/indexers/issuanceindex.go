@@ -0,0 +1,468 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// issuanceIndexName is the human-readable name for the index.
+	issuanceIndexName = "issuance index"
+
+	// issuanceIndexHolderSize is the number of bytes the holder portion of
+	// a key in the issuance index consumes.  It is the hash160 of the
+	// Prova address the issued or destroyed output paid to.
+	issuanceIndexHolderSize = 20
+
+	// issuanceIndexKeySize is the number of bytes a key in the issuance
+	// index consumes.  It consists of the holder + 4 bytes block height + 4
+	// bytes tx index + 1 byte entry type + 4 bytes input or output index.
+	issuanceIndexKeySize = issuanceIndexHolderSize + 4 + 4 + 1 + 4
+
+	// issuanceEntrySize is the number of bytes a value for issued tokens
+	// consumes.  It consists of the 32 byte tx hash + 8 bytes value.
+	issuanceEntrySize = chainhash.HashSize + 8
+
+	// destructionEntrySize is the number of bytes a value for destroyed
+	// tokens consumes.  It consists of the 32 byte tx hash + 8 bytes value
+	// + 32 bytes previous tx hash + 4 bytes previous output index.
+	destructionEntrySize = issuanceEntrySize + chainhash.HashSize + 4
+)
+
+// IssuanceEntryType identifies whether an issuance index entry describes
+// tokens being issued or destroyed.
+type IssuanceEntryType byte
+
+// These constants define the types of issuance index entries.  The values are
+// stored in the database and therefore must not change.
+const (
+	// IssuanceEntryIssue indicates an output created by an issuance
+	// transaction.
+	IssuanceEntryIssue IssuanceEntryType = 0
+
+	// IssuanceEntryDestroy indicates a previous output consumed by a
+	// destruction transaction.
+	IssuanceEntryDestroy IssuanceEntryType = 1
+)
+
+// issuanceEntryTypeStrings is a map of issuance index entry types back to their
+// names for pretty printing.
+var issuanceEntryTypeStrings = map[IssuanceEntryType]string{
+	IssuanceEntryIssue:   "issue",
+	IssuanceEntryDestroy: "destroy",
+}
+
+// String returns the IssuanceEntryType in human-readable form.
+func (t IssuanceEntryType) String() string {
+	if s, ok := issuanceEntryTypeStrings[t]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+var (
+	// issuanceIndexKey is the key of the issuance index and the db bucket
+	// used to house it.
+	issuanceIndexKey = []byte("issuanceidx")
+)
+
+// -----------------------------------------------------------------------------
+// The issuance index attributes tokens issued and destroyed by transactions on
+// the issue thread to the holders involved.  There is an entry for every output
+// created by an issuance transaction, keyed by the address it pays to, and an
+// entry for every previous output consumed by a destruction transaction, keyed
+// by the address the consumed output paid to.  Since the keys start with the
+// address hash followed by the big-endian block height, all issuance and
+// destruction involving an address within a range of heights can be found with
+// a single cursor seek.
+//
+// The serialized key format is:
+//
+//   <addr hash><block height><tx index><type><io index>
+//
+//   Field           Type      Size
+//   addr hash       hash160   20 bytes
+//   block height    uint32    4 bytes (big endian)
+//   tx index        uint32    4 bytes (big endian)
+//   type            uint8     1 byte
+//   io index        uint32    4 bytes (big endian)
+//   -----
+//   Total: 33 bytes
+//
+// The io index is the output index for issued outputs and the input index for
+// consumed outputs.
+//
+// The serialized value format for issued outputs is:
+//
+//   <txhash><value>
+//
+//   Field           Type              Size
+//   txhash          chainhash.Hash    32 bytes
+//   value           int64             8 bytes
+//   -----
+//   Total: 40 bytes
+//
+// The serialized value format for consumed outputs is:
+//
+//   <txhash><value><prev txhash><prev index>
+//
+//   Field           Type              Size
+//   txhash          chainhash.Hash    32 bytes
+//   value           int64             8 bytes
+//   prev txhash     chainhash.Hash    32 bytes
+//   prev index      uint32            4 bytes
+//   -----
+//   Total: 76 bytes
+// -----------------------------------------------------------------------------
+
+// IssuanceEntry describes tokens issued to or destroyed from a holder.
+type IssuanceEntry struct {
+	// Type identifies whether the tokens were issued or destroyed.
+	Type IssuanceEntryType
+
+	// Height is the height of the block containing the transaction.
+	Height uint32
+
+	// TxHash is the hash of the issuance or destruction transaction.
+	TxHash chainhash.Hash
+
+	// Index is the output index for issued outputs and the input index
+	// for consumed outputs.
+	Index uint32
+
+	// PrevOut is the outpoint of the consumed output.  It is only set for
+	// destroyed tokens.
+	PrevOut wire.OutPoint
+
+	// Value is the value of the issued or consumed output.
+	Value int64
+}
+
+// issuanceIndexKeyFor returns the key used to store the issuance entry with the
+// passed details.
+func issuanceIndexKeyFor(holder []byte, height uint32, txIdx int, typ IssuanceEntryType, ioIdx uint32) []byte {
+	key := make([]byte, issuanceIndexKeySize)
+	copy(key, holder)
+	offset := issuanceIndexHolderSize
+	binary.BigEndian.PutUint32(key[offset:], height)
+	binary.BigEndian.PutUint32(key[offset+4:], uint32(txIdx))
+	key[offset+8] = byte(typ)
+	binary.BigEndian.PutUint32(key[offset+9:], ioIdx)
+	return key
+}
+
+// serializeIssuanceEntry serializes the value of the passed entry according to
+// the format described in detail above.
+func serializeIssuanceEntry(entry *IssuanceEntry) []byte {
+	size := issuanceEntrySize
+	if entry.Type == IssuanceEntryDestroy {
+		size = destructionEntrySize
+	}
+
+	serialized := make([]byte, size)
+	offset := copy(serialized, entry.TxHash[:])
+	byteOrder.PutUint64(serialized[offset:], uint64(entry.Value))
+	offset += 8
+	if entry.Type == IssuanceEntryDestroy {
+		offset += copy(serialized[offset:], entry.PrevOut.Hash[:])
+		byteOrder.PutUint32(serialized[offset:], entry.PrevOut.Index)
+	}
+	return serialized
+}
+
+// deserializeIssuanceEntry decodes the passed key and serialized value into the
+// passed entry.
+func deserializeIssuanceEntry(key, serialized []byte, entry *IssuanceEntry) error {
+	if len(key) != issuanceIndexKeySize {
+		return errDeserialize("unexpected issuance key length")
+	}
+	offset := issuanceIndexHolderSize
+	entry.Height = binary.BigEndian.Uint32(key[offset:])
+	entry.Type = IssuanceEntryType(key[offset+8])
+	entry.Index = binary.BigEndian.Uint32(key[offset+9:])
+
+	size := issuanceEntrySize
+	if entry.Type == IssuanceEntryDestroy {
+		size = destructionEntrySize
+	}
+	if len(serialized) != size {
+		return errDeserialize("unexpected issuance entry length")
+	}
+
+	offset = copy(entry.TxHash[:], serialized)
+	entry.Value = int64(byteOrder.Uint64(serialized[offset:]))
+	offset += 8
+	if entry.Type == IssuanceEntryDestroy {
+		offset += copy(entry.PrevOut.Hash[:], serialized[offset:])
+		entry.PrevOut.Index = byteOrder.Uint32(serialized[offset:])
+	}
+	return nil
+}
+
+// dbFetchIssuanceEntries uses an existing database transaction to return all of
+// the issuance entries for the passed holder in blocks between the start and end
+// heights, inclusive, ordered by their appearance in the blockchain.
+func dbFetchIssuanceEntries(dbTx database.Tx, holder []byte, startHeight, endHeight uint32) ([]IssuanceEntry, error) {
+	var entries []IssuanceEntry
+	cursor := dbTx.Metadata().Bucket(issuanceIndexKey).Cursor()
+	seek := issuanceIndexKeyFor(holder, startHeight, 0, 0, 0)
+	for ok := cursor.Seek(seek); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if !bytes.HasPrefix(key, holder) {
+			break
+		}
+
+		var entry IssuanceEntry
+		err := deserializeIssuanceEntry(key, cursor.Value(), &entry)
+		if err != nil {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: "failed to deserialize issuance " +
+					"entry: " + err.Error(),
+			}
+		}
+		if entry.Height > endHeight {
+			break
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// IssuanceIndex implements an index of tokens issued and destroyed attributed
+// to their holders.  That is to say, it supports querying all issuance to and
+// destruction from a given address within a range of block heights.
+type IssuanceIndex struct {
+	db          database.DB
+	chainParams *chaincfg.Params
+}
+
+// Ensure the IssuanceIndex type implements the Indexer interface.
+var _ Indexer = (*IssuanceIndex)(nil)
+
+// Ensure the IssuanceIndex type implements the Checker interface.
+var _ Checker = (*IssuanceIndex)(nil)
+
+// Ensure the IssuanceIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*IssuanceIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *IssuanceIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *IssuanceIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *IssuanceIndex) Key() []byte {
+	return issuanceIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *IssuanceIndex) Name() string {
+	return issuanceIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the issuance
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *IssuanceIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(issuanceIndexKey)
+	return err
+}
+
+// scriptHolder returns the hash160 of the Prova address the passed public key
+// script pays to, or nil when it does not pay to one.
+func (idx *IssuanceIndex) scriptHolder(pkScript []byte) []byte {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript,
+		idx.chainParams)
+	if err != nil {
+		return nil
+	}
+
+	for _, addr := range addrs {
+		if provaAddr, ok := addr.(*provautil.AddressProva); ok {
+			return provaAddr.ScriptAddress()
+		}
+	}
+	return nil
+}
+
+// blockEntries invokes the passed function with the entry and associated key
+// for every output issued and every previous output destroyed by the issue
+// thread transactions in the passed block.
+func (idx *IssuanceIndex) blockEntries(block *provautil.Block, view *blockchain.UtxoViewpoint, fn func(key []byte, entry *IssuanceEntry) error) error {
+	height := block.Height()
+	for txIdx, tx := range block.Transactions() {
+		threadInt, _ := txscript.GetAdminDetails(tx)
+		if threadInt < 0 ||
+			provautil.ThreadID(threadInt) != provautil.IssueThread {
+			continue
+		}
+
+		// Transactions on the issue thread with more than one input
+		// destroy the tokens held by the outputs they consume, while
+		// all others issue tokens to their outputs.  The first input
+		// and output always belong to the thread itself.
+		msgTx := tx.MsgTx()
+		if len(msgTx.TxIn) > 1 {
+			for txInIdx, txIn := range msgTx.TxIn[1:] {
+				// The view should always have the input since
+				// the index contract requires it, however, be
+				// safe and simply ignore any missing entries.
+				origin := &txIn.PreviousOutPoint
+				utxo := view.LookupEntry(&origin.Hash)
+				if utxo == nil {
+					continue
+				}
+
+				pkScript := utxo.PkScriptByIndex(origin.Index)
+				holder := idx.scriptHolder(pkScript)
+				if holder == nil {
+					continue
+				}
+
+				entry := IssuanceEntry{
+					Type:    IssuanceEntryDestroy,
+					Height:  height,
+					TxHash:  *tx.Hash(),
+					Index:   uint32(txInIdx + 1),
+					PrevOut: *origin,
+					Value:   utxo.AmountByIndex(origin.Index),
+				}
+				key := issuanceIndexKeyFor(holder, height, txIdx,
+					entry.Type, entry.Index)
+				if err := fn(key, &entry); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		for txOutIdx, txOut := range msgTx.TxOut[1:] {
+			holder := idx.scriptHolder(txOut.PkScript)
+			if holder == nil {
+				continue
+			}
+
+			entry := IssuanceEntry{
+				Type:   IssuanceEntryIssue,
+				Height: height,
+				TxHash: *tx.Hash(),
+				Index:  uint32(txOutIdx + 1),
+				Value:  txOut.Value,
+			}
+			key := issuanceIndexKeyFor(holder, height, txIdx,
+				entry.Type, entry.Index)
+			if err := fn(key, &entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry under each holder
+// for every output issued or destroyed by the transactions in the block.
+//
+// This is part of the Indexer interface.
+func (idx *IssuanceIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(issuanceIndexKey)
+	return idx.blockEntries(block, view, func(key []byte, entry *IssuanceEntry) error {
+		return bucket.Put(key, serializeIssuanceEntry(entry))
+	})
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries for every
+// output issued or destroyed by the transactions in the block.
+//
+// This is part of the Indexer interface.
+func (idx *IssuanceIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(issuanceIndexKey)
+	return idx.blockEntries(block, view, func(key []byte, entry *IssuanceEntry) error {
+		return bucket.Delete(key)
+	})
+}
+
+// CheckBlock verifies the entries the index stores for the passed block by
+// comparing them with the entries connecting the block produces.
+//
+// This is part of the Checker interface.
+func (idx *IssuanceIndex) CheckBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint, repair bool) ([]IndexInconsistency, error) {
+	return checkBlockEntries(dbTx, repair, func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, view)
+	})
+}
+
+// EntriesForAddress returns all tokens issued to or destroyed from the passed
+// address in blocks between the start and end heights, inclusive, ordered by
+// their appearance in the blockchain.  An error is returned for unsupported
+// address types.
+//
+// This function is safe for concurrent access.
+func (idx *IssuanceIndex) EntriesForAddress(addr provautil.Address, startHeight, endHeight uint32) ([]IssuanceEntry, error) {
+	provaAddr, ok := addr.(*provautil.AddressProva)
+	if !ok {
+		return nil, errUnsupportedAddressType
+	}
+
+	var entries []IssuanceEntry
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		entries, err = dbFetchIssuanceEntries(dbTx,
+			provaAddr.ScriptAddress(), startHeight, endHeight)
+		return err
+	})
+	return entries, err
+}
+
+// NewIssuanceIndex returns a new instance of an indexer that is used to create
+// a mapping of addresses to the tokens issued to and destroyed from them.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewIssuanceIndex(db database.DB, chainParams *chaincfg.Params) *IssuanceIndex {
+	return &IssuanceIndex{
+		db:          db,
+		chainParams: chainParams,
+	}
+}
+
+// DropIssuanceIndex drops the issuance index from the provided database if it
+// exists.
+func DropIssuanceIndex(db database.DB) error {
+	return dropIndex(db, issuanceIndexKey, issuanceIndexName)
+}
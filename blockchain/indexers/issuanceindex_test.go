@@ -0,0 +1,152 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestIssuanceEntrySerialization ensures issuance entries round trip through
+// serialization and that keys sort by holder and then height.
+func TestIssuanceEntrySerialization(t *testing.T) {
+	t.Parallel()
+
+	holder := bytes.Repeat([]byte{0x07}, issuanceIndexHolderSize)
+	tests := []IssuanceEntry{
+		{
+			Type:   IssuanceEntryIssue,
+			Height: 100,
+			TxHash: chainhash.Hash{0x01},
+			Index:  1,
+			Value:  1000000,
+		},
+		{
+			Type:   IssuanceEntryDestroy,
+			Height: 200,
+			TxHash: chainhash.Hash{0x02},
+			Index:  2,
+			PrevOut: wire.OutPoint{
+				Hash:  chainhash.Hash{0x01},
+				Index: 1,
+			},
+			Value: 1000000,
+		},
+	}
+
+	for i, test := range tests {
+		key := issuanceIndexKeyFor(holder, test.Height, 3, test.Type,
+			test.Index)
+		serialized := serializeIssuanceEntry(&test)
+
+		var got IssuanceEntry
+		err := deserializeIssuanceEntry(key, serialized, &got)
+		if err != nil {
+			t.Errorf("test #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test) {
+			t.Errorf("test #%d: mismatched entry - got %+v, want %+v",
+				i, got, test)
+		}
+
+		// Ensure truncated entries are rejected.
+		err = deserializeIssuanceEntry(key, serialized[:30], &got)
+		if !isDeserializeErr(err) {
+			t.Errorf("test #%d: unexpected error for short entry: "+
+				"%v", i, err)
+		}
+	}
+
+	// Keys must be grouped by holder and ordered by height within them so
+	// height ranges can be scanned with a cursor.
+	nextHolder := bytes.Repeat([]byte{0x08}, issuanceIndexHolderSize)
+	keys := [][]byte{
+		issuanceIndexKeyFor(holder, 255, 9, IssuanceEntryDestroy, 1),
+		issuanceIndexKeyFor(holder, 256, 0, IssuanceEntryIssue, 1),
+		issuanceIndexKeyFor(holder, 256, 0, IssuanceEntryDestroy, 1),
+		issuanceIndexKeyFor(nextHolder, 0, 0, IssuanceEntryIssue, 1),
+	}
+	for i := 1; i < len(keys); i++ {
+		if bytes.Compare(keys[i-1], keys[i]) >= 0 {
+			t.Fatalf("key %d does not sort before key %d", i-1, i)
+		}
+	}
+}
+
+// TestIssuanceIndexConnectBlock ensures the issuance index records the tokens
+// issued to and destroyed from a holder when blocks are connected, and removes
+// them when the blocks are disconnected.
+func TestIssuanceIndexConnectBlock(t *testing.T) {
+	t.Parallel()
+
+	db, teardown := newTestIndexDB(t, "issuanceindex")
+	defer teardown()
+
+	// Create a chain where the first block issues tokens to address A and
+	// the second block destroys them.
+	threadScript, err := txscript.ProvaThreadScript(provautil.IssueThread)
+	if err != nil {
+		t.Fatalf("unable to create thread script: %v", err)
+	}
+	addrA, scriptA := testProvaAddr(t, 0x0a, 1, 2)
+	issue := wire.NewMsgTx(1)
+	issue.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{0xee}, 0),
+		nil))
+	issue.AddTxOut(wire.NewTxOut(0, threadScript))
+	issue.AddTxOut(wire.NewTxOut(1000, scriptA))
+	issueHash := issue.TxHash()
+	destroy := wire.NewMsgTx(1)
+	destroy.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&issueHash, 0), nil))
+	destroy.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&issueHash, 1), nil))
+	destroy.AddTxOut(wire.NewTxOut(0, threadScript))
+	c := newTestIndexChain(issue, destroy)
+
+	idx := NewIssuanceIndex(db, &chaincfg.MainNetParams)
+	connectTestChain(t, db, idx, c)
+
+	issued := IssuanceEntry{
+		Type:   IssuanceEntryIssue,
+		Height: 1,
+		TxHash: issueHash,
+		Index:  1,
+		Value:  1000,
+	}
+	want := []IssuanceEntry{issued, {
+		Type:    IssuanceEntryDestroy,
+		Height:  2,
+		TxHash:  destroy.TxHash(),
+		Index:   1,
+		PrevOut: *wire.NewOutPoint(&issueHash, 1),
+		Value:   1000,
+	}}
+	entries, err := idx.EntriesForAddress(addrA, 0, 10)
+	if err != nil {
+		t.Fatalf("EntriesForAddress: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Fatalf("EntriesForAddress: got entries %+v, want %+v",
+			entries, want)
+	}
+
+	// Ensure only the issuance remains once the second block is
+	// disconnected.
+	disconnectTestTip(t, db, idx, c)
+	entries, err = idx.EntriesForAddress(addrA, 0, 10)
+	if err != nil {
+		t.Fatalf("EntriesForAddress: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(entries, []IssuanceEntry{issued}) {
+		t.Fatalf("EntriesForAddress: got entries %+v after "+
+			"disconnecting, want %+v", entries, issued)
+	}
+}
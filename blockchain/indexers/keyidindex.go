@@ -0,0 +1,550 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// keyIDIndexName is the human-readable name for the index.
+	keyIDIndexName = "key id activity index"
+
+	// keyIDIndexKeySize is the number of bytes a key in the key ID activity
+	// index consumes.  It consists of 4 bytes key ID + 4 bytes block height
+	// + 4 bytes tx index + 1 byte activity type + 4 bytes input or output
+	// index.
+	keyIDIndexKeySize = 4 + 4 + 4 + 1 + 4
+
+	// keyIDCreatedEntryMinSize is the minimum number of bytes a value for
+	// a created output consumes.  It consists of the 32 byte tx hash + 8
+	// bytes value followed by the public key script.
+	keyIDCreatedEntryMinSize = chainhash.HashSize + 8
+
+	// keyIDSpentEntryMinSize is the minimum number of bytes a value for a
+	// spent output consumes.  It consists of the 32 byte tx hash + 8 bytes
+	// value + 32 bytes previous tx hash + 4 bytes previous output index
+	// followed by the public key script.
+	keyIDSpentEntryMinSize = keyIDCreatedEntryMinSize + chainhash.HashSize + 4
+)
+
+// KeyIDActivityType identifies whether a key ID activity entry describes an
+// output being created or spent.
+type KeyIDActivityType byte
+
+// These constants define the types of key ID activity.  The values are stored
+// in the database and therefore must not change.
+const (
+	// KeyIDOutputCreated indicates a transaction output paying to an
+	// address which references the key ID was created.
+	KeyIDOutputCreated KeyIDActivityType = 0
+
+	// KeyIDOutputSpent indicates a previous output paying to an address
+	// which references the key ID was spent.
+	KeyIDOutputSpent KeyIDActivityType = 1
+)
+
+// keyIDActivityTypeStrings is a map of key ID activity types back to their
+// names for pretty printing.
+var keyIDActivityTypeStrings = map[KeyIDActivityType]string{
+	KeyIDOutputCreated: "created",
+	KeyIDOutputSpent:   "spent",
+}
+
+// String returns the KeyIDActivityType in human-readable form.
+func (t KeyIDActivityType) String() string {
+	if s, ok := keyIDActivityTypeStrings[t]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+var (
+	// keyIDIndexKey is the key of the key ID activity index and the db
+	// bucket used to house it.
+	keyIDIndexKey = []byte("keyidactivityidx")
+)
+
+// -----------------------------------------------------------------------------
+// The key ID activity index consists of an entry for every transaction output
+// created or spent in the main chain that pays to an address referencing a
+// key ID.  Outputs paying to addresses which reference multiple key IDs have an
+// entry under each of them.  Since the keys start with the key ID followed by
+// the big-endian block height, all activity for a key ID within a range of
+// heights can be found with a single cursor seek.
+//
+// The serialized key format is:
+//
+//   <key id><block height><tx index><type><io index>
+//
+//   Field           Type      Size
+//   key id          uint32    4 bytes (big endian)
+//   block height    uint32    4 bytes (big endian)
+//   tx index        uint32    4 bytes (big endian)
+//   type            uint8     1 byte
+//   io index        uint32    4 bytes (big endian)
+//   -----
+//   Total: 17 bytes
+//
+// The io index is the output index for created outputs and the input index for
+// spent outputs.
+//
+// The serialized value format for created outputs is:
+//
+//   <txhash><value><pkscript>
+//
+//   Field           Type              Size
+//   txhash          chainhash.Hash    32 bytes
+//   value           int64             8 bytes
+//   pkscript        []byte            variable
+//
+// The serialized value format for spent outputs is:
+//
+//   <txhash><value><prev txhash><prev index><pkscript>
+//
+//   Field           Type              Size
+//   txhash          chainhash.Hash    32 bytes
+//   value           int64             8 bytes
+//   prev txhash     chainhash.Hash    32 bytes
+//   prev index      uint32            4 bytes
+//   pkscript        []byte            variable
+// -----------------------------------------------------------------------------
+
+// KeyIDActivity describes an output created or spent under a key ID.
+type KeyIDActivity struct {
+	// Type identifies whether the output was created or spent.
+	Type KeyIDActivityType
+
+	// Height is the height of the block containing the transaction.
+	Height uint32
+
+	// TxHash is the hash of the transaction which created or spent the
+	// output.
+	TxHash chainhash.Hash
+
+	// Index is the output index for created outputs and the input index
+	// for spent outputs.
+	Index uint32
+
+	// PrevOut is the outpoint of the spent output.  It is only set for
+	// spent outputs.
+	PrevOut wire.OutPoint
+
+	// Value is the value of the output.
+	Value int64
+
+	// PkScript is the public key script of the output.
+	PkScript []byte
+}
+
+// keyIDIndexKeyFor returns the key used to store the activity entry with the
+// passed details.
+func keyIDIndexKeyFor(keyID btcec.KeyID, height uint32, txIdx int, typ KeyIDActivityType, ioIdx uint32) []byte {
+	key := make([]byte, keyIDIndexKeySize)
+	binary.BigEndian.PutUint32(key[0:4], uint32(keyID))
+	binary.BigEndian.PutUint32(key[4:8], height)
+	binary.BigEndian.PutUint32(key[8:12], uint32(txIdx))
+	key[12] = byte(typ)
+	binary.BigEndian.PutUint32(key[13:17], ioIdx)
+	return key
+}
+
+// serializeKeyIDActivity serializes the value of the passed activity according
+// to the format described in detail above.
+func serializeKeyIDActivity(activity *KeyIDActivity) []byte {
+	size := keyIDCreatedEntryMinSize + len(activity.PkScript)
+	if activity.Type == KeyIDOutputSpent {
+		size = keyIDSpentEntryMinSize + len(activity.PkScript)
+	}
+
+	serialized := make([]byte, size)
+	offset := copy(serialized, activity.TxHash[:])
+	byteOrder.PutUint64(serialized[offset:], uint64(activity.Value))
+	offset += 8
+	if activity.Type == KeyIDOutputSpent {
+		offset += copy(serialized[offset:], activity.PrevOut.Hash[:])
+		byteOrder.PutUint32(serialized[offset:], activity.PrevOut.Index)
+		offset += 4
+	}
+	copy(serialized[offset:], activity.PkScript)
+	return serialized
+}
+
+// deserializeKeyIDActivity decodes the passed key and serialized value into
+// the passed activity.
+func deserializeKeyIDActivity(key, serialized []byte, activity *KeyIDActivity) error {
+	if len(key) != keyIDIndexKeySize {
+		return errDeserialize("unexpected key id activity key length")
+	}
+	activity.Type = KeyIDActivityType(key[12])
+	activity.Height = binary.BigEndian.Uint32(key[4:8])
+	activity.Index = binary.BigEndian.Uint32(key[13:17])
+
+	minSize := keyIDCreatedEntryMinSize
+	if activity.Type == KeyIDOutputSpent {
+		minSize = keyIDSpentEntryMinSize
+	}
+	if len(serialized) < minSize {
+		return errDeserialize("unexpected end of key id activity data")
+	}
+
+	offset := copy(activity.TxHash[:], serialized)
+	activity.Value = int64(byteOrder.Uint64(serialized[offset:]))
+	offset += 8
+	if activity.Type == KeyIDOutputSpent {
+		offset += copy(activity.PrevOut.Hash[:], serialized[offset:])
+		activity.PrevOut.Index = byteOrder.Uint32(serialized[offset:])
+		offset += 4
+	}
+	activity.PkScript = make([]byte, len(serialized)-offset)
+	copy(activity.PkScript, serialized[offset:])
+	return nil
+}
+
+// dbFetchKeyIDActivity uses an existing database transaction to return all of
+// the activity for the passed key ID in blocks between the start and end
+// heights, inclusive, ordered by their appearance in the blockchain.
+func dbFetchKeyIDActivity(dbTx database.Tx, keyID btcec.KeyID, startHeight, endHeight uint32) ([]KeyIDActivity, error) {
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], uint32(keyID))
+
+	var activities []KeyIDActivity
+	cursor := dbTx.Metadata().Bucket(keyIDIndexKey).Cursor()
+	seek := keyIDIndexKeyFor(keyID, startHeight, 0, 0, 0)
+	for ok := cursor.Seek(seek); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if !bytes.HasPrefix(key, prefix[:]) {
+			break
+		}
+
+		var activity KeyIDActivity
+		err := deserializeKeyIDActivity(key, cursor.Value(), &activity)
+		if err != nil {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: "failed to deserialize key id " +
+					"activity entry: " + err.Error(),
+			}
+		}
+		if activity.Height > endHeight {
+			break
+		}
+		activities = append(activities, activity)
+	}
+
+	return activities, nil
+}
+
+// KeyIDTx identifies a transaction which created or spent outputs under a key
+// ID.
+type KeyIDTx struct {
+	// Height is the height of the block containing the transaction.
+	Height uint32
+
+	// TxHash is the hash of the transaction.
+	TxHash chainhash.Hash
+}
+
+// dbFetchKeyIDTxns uses an existing database transaction to return the
+// transactions which created or spent outputs under the passed key ID limited
+// by the skip and count parameters.  The transactions are ordered by their
+// appearance in the blockchain unless reverse is set.
+//
+// The entries of a transaction are stored next to each other, so each
+// transaction is only returned once without loading the rest of the activity
+// for the key ID.
+func dbFetchKeyIDTxns(dbTx database.Tx, keyID btcec.KeyID, numToSkip, numRequested uint32, reverse bool) ([]KeyIDTx, error) {
+	var prefix [4]byte
+	binary.BigEndian.PutUint32(prefix[:], uint32(keyID))
+
+	// Start at the last entry for the key ID when iterating in reverse.
+	cursor := dbTx.Metadata().Bucket(keyIDIndexKey).Cursor()
+	next := cursor.Next
+	var ok bool
+	if reverse {
+		next = cursor.Prev
+		ok = seekLastWithPrefix(cursor, prefix[:])
+	} else {
+		ok = cursor.Seek(prefix[:])
+	}
+
+	var txns []KeyIDTx
+	var lastTx []byte
+	var numSkipped uint32
+	for ; ok && uint32(len(txns)) < numRequested; ok = next() {
+		key := cursor.Key()
+		if !bytes.HasPrefix(key, prefix[:]) {
+			break
+		}
+		if len(key) != keyIDIndexKeySize {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: "unexpected key id activity key " +
+					"length",
+			}
+		}
+
+		// Skip the remaining entries of the previous transaction which
+		// is identified by its block height and index.
+		if lastTx != nil && bytes.Equal(key[4:12], lastTx) {
+			continue
+		}
+		lastTx = append(lastTx[:0], key[4:12]...)
+		if numSkipped < numToSkip {
+			numSkipped++
+			continue
+		}
+
+		value := cursor.Value()
+		if len(value) < chainhash.HashSize {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: "unexpected key id activity entry " +
+					"length",
+			}
+		}
+		tx := KeyIDTx{Height: binary.BigEndian.Uint32(key[4:8])}
+		copy(tx.TxHash[:], value[:chainhash.HashSize])
+		txns = append(txns, tx)
+	}
+
+	return txns, nil
+}
+
+// KeyIDIndex implements an index of the activity attributable to each key ID.
+// That is to say, it supports querying every output created or spent under a
+// given key ID within a range of block heights.
+type KeyIDIndex struct {
+	db          database.DB
+	chainParams *chaincfg.Params
+}
+
+// Ensure the KeyIDIndex type implements the Indexer interface.
+var _ Indexer = (*KeyIDIndex)(nil)
+
+// Ensure the KeyIDIndex type implements the Checker interface.
+var _ Checker = (*KeyIDIndex)(nil)
+
+// Ensure the KeyIDIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*KeyIDIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *KeyIDIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *KeyIDIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *KeyIDIndex) Key() []byte {
+	return keyIDIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *KeyIDIndex) Name() string {
+	return keyIDIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the key ID
+// activity index.
+//
+// This is part of the Indexer interface.
+func (idx *KeyIDIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(keyIDIndexKey)
+	return err
+}
+
+// scriptKeyIDs returns the key IDs referenced by the addresses the passed public
+// key script pays to.
+func (idx *KeyIDIndex) scriptKeyIDs(pkScript []byte) []btcec.KeyID {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript,
+		idx.chainParams)
+	if err != nil {
+		return nil
+	}
+
+	var keyIDs []btcec.KeyID
+	for _, addr := range addrs {
+		if provaAddr, ok := addr.(*provautil.AddressProva); ok {
+			keyIDs = append(keyIDs, provaAddr.ScriptKeyIDs()...)
+		}
+	}
+	return keyIDs
+}
+
+// blockActivity invokes the passed function with the activity and associated
+// key for every key ID referenced by each output created or spent by the
+// transactions in the passed block.
+func (idx *KeyIDIndex) blockActivity(block *provautil.Block, view *blockchain.UtxoViewpoint, fn func(key []byte, activity *KeyIDActivity) error) error {
+	height := block.Height()
+	for txIdx, tx := range block.Transactions() {
+		// Coinbases do not reference any inputs.  Since the block is
+		// required to have already gone through full validation, it has
+		// already been proven on the first transaction in the block is
+		// a coinbase.
+		if txIdx != 0 {
+			for txInIdx, txIn := range tx.MsgTx().TxIn {
+				// The view should always have the input since
+				// the index contract requires it, however, be
+				// safe and simply ignore any missing entries.
+				origin := &txIn.PreviousOutPoint
+				entry := view.LookupEntry(&origin.Hash)
+				if entry == nil {
+					continue
+				}
+
+				pkScript := entry.PkScriptByIndex(origin.Index)
+				activity := KeyIDActivity{
+					Type:     KeyIDOutputSpent,
+					Height:   height,
+					TxHash:   *tx.Hash(),
+					Index:    uint32(txInIdx),
+					PrevOut:  *origin,
+					Value:    entry.AmountByIndex(origin.Index),
+					PkScript: pkScript,
+				}
+				for _, keyID := range idx.scriptKeyIDs(pkScript) {
+					key := keyIDIndexKeyFor(keyID, height,
+						txIdx, KeyIDOutputSpent,
+						uint32(txInIdx))
+					if err := fn(key, &activity); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			activity := KeyIDActivity{
+				Type:     KeyIDOutputCreated,
+				Height:   height,
+				TxHash:   *tx.Hash(),
+				Index:    uint32(txOutIdx),
+				Value:    txOut.Value,
+				PkScript: txOut.PkScript,
+			}
+			for _, keyID := range idx.scriptKeyIDs(txOut.PkScript) {
+				key := keyIDIndexKeyFor(keyID, height, txIdx,
+					KeyIDOutputCreated, uint32(txOutIdx))
+				if err := fn(key, &activity); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry under each key ID
+// for every output created or spent by the transactions in the block.
+//
+// This is part of the Indexer interface.
+func (idx *KeyIDIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(keyIDIndexKey)
+	return idx.blockActivity(block, view, func(key []byte, activity *KeyIDActivity) error {
+		return bucket.Put(key, serializeKeyIDActivity(activity))
+	})
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries for every
+// output created or spent by the transactions in the block.
+//
+// This is part of the Indexer interface.
+func (idx *KeyIDIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(keyIDIndexKey)
+	return idx.blockActivity(block, view, func(key []byte, activity *KeyIDActivity) error {
+		return bucket.Delete(key)
+	})
+}
+
+// CheckBlock verifies the entries the index stores for the passed block by
+// comparing them with the entries connecting the block produces.
+//
+// This is part of the Checker interface.
+func (idx *KeyIDIndex) CheckBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint, repair bool) ([]IndexInconsistency, error) {
+	return checkBlockEntries(dbTx, repair, func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, view)
+	})
+}
+
+// ActivityForKeyID returns all outputs created or spent under the passed key ID
+// in blocks between the start and end heights, inclusive, ordered by their
+// appearance in the blockchain.
+//
+// This function is safe for concurrent access.
+func (idx *KeyIDIndex) ActivityForKeyID(keyID btcec.KeyID, startHeight, endHeight uint32) ([]KeyIDActivity, error) {
+	var activities []KeyIDActivity
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		activities, err = dbFetchKeyIDActivity(dbTx, keyID, startHeight,
+			endHeight)
+		return err
+	})
+	return activities, err
+}
+
+// TxnsForKeyID returns the transactions which created or spent outputs under
+// the passed key ID limited by the skip and count parameters.  The
+// transactions are ordered by their appearance in the blockchain unless
+// reverse is set.
+//
+// This function is safe for concurrent access.
+func (idx *KeyIDIndex) TxnsForKeyID(keyID btcec.KeyID, numToSkip, numRequested uint32, reverse bool) ([]KeyIDTx, error) {
+	var txns []KeyIDTx
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		txns, err = dbFetchKeyIDTxns(dbTx, keyID, numToSkip,
+			numRequested, reverse)
+		return err
+	})
+	return txns, err
+}
+
+// NewKeyIDIndex returns a new instance of an indexer that is used to create a
+// mapping of key IDs to all outputs created or spent under them.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewKeyIDIndex(db database.DB, chainParams *chaincfg.Params) *KeyIDIndex {
+	return &KeyIDIndex{
+		db:          db,
+		chainParams: chainParams,
+	}
+}
+
+// DropKeyIDIndex drops the key ID activity index from the provided database if
+// it exists.
+func DropKeyIDIndex(db database.DB) error {
+	return dropIndex(db, keyIDIndexKey, keyIDIndexName)
+}
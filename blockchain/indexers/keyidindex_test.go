@@ -0,0 +1,257 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestKeyIDActivitySerialization ensures key ID activity entries round trip
+// through serialization and that keys sort by key ID and then height.
+func TestKeyIDActivitySerialization(t *testing.T) {
+	t.Parallel()
+
+	tests := []KeyIDActivity{
+		{
+			Type:     KeyIDOutputCreated,
+			Height:   500,
+			TxHash:   chainhash.Hash{0x01},
+			Index:    1,
+			Value:    2500,
+			PkScript: []byte{0x52, 0x14, 0x01, 0x02},
+		},
+		{
+			Type:   KeyIDOutputSpent,
+			Height: 501,
+			TxHash: chainhash.Hash{0x02},
+			Index:  0,
+			PrevOut: wire.OutPoint{
+				Hash:  chainhash.Hash{0x01},
+				Index: 1,
+			},
+			Value:    2500,
+			PkScript: []byte{0x52, 0x14, 0x01, 0x02},
+		},
+	}
+
+	for i, test := range tests {
+		key := keyIDIndexKeyFor(7, test.Height, 3, test.Type, test.Index)
+		serialized := serializeKeyIDActivity(&test)
+
+		var got KeyIDActivity
+		err := deserializeKeyIDActivity(key, serialized, &got)
+		if err != nil {
+			t.Errorf("test #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test) {
+			t.Errorf("test #%d: mismatched activity - got %+v, "+
+				"want %+v", i, got, test)
+		}
+
+		// Ensure truncated entries are rejected.
+		err = deserializeKeyIDActivity(key, serialized[:30], &got)
+		if !isDeserializeErr(err) {
+			t.Errorf("test #%d: unexpected error for short entry: "+
+				"%v", i, err)
+		}
+	}
+
+	// Keys must be grouped by key ID and ordered by height within them so
+	// height ranges can be scanned with a cursor.
+	keys := [][]byte{
+		keyIDIndexKeyFor(7, 255, 9, KeyIDOutputSpent, 0),
+		keyIDIndexKeyFor(7, 256, 0, KeyIDOutputCreated, 0),
+		keyIDIndexKeyFor(7, 256, 0, KeyIDOutputSpent, 0),
+		keyIDIndexKeyFor(8, 0, 0, KeyIDOutputCreated, 0),
+	}
+	for i := 1; i < len(keys); i++ {
+		if bytes.Compare(keys[i-1], keys[i]) >= 0 {
+			t.Fatalf("key %d does not sort before key %d", i-1, i)
+		}
+	}
+}
+
+// TestKeyIDTxnsPaging ensures the transactions of a key ID are paged in both
+// directions, each transaction is only returned once even when it has several
+// entries, and the entries of neighbouring key IDs are not included.
+func TestKeyIDTxnsPaging(t *testing.T) {
+	t.Parallel()
+
+	db, teardown := newTestIndexDB(t, "keyidindex")
+	defer teardown()
+
+	// Store the activity of three transactions under key ID 7, the second
+	// of which both spends and creates outputs, surrounded by the activity
+	// of the neighbouring key IDs including the largest one.
+	entries := []struct {
+		keyID    btcec.KeyID
+		activity KeyIDActivity
+		txIdx    int
+	}{
+		{6, KeyIDActivity{Height: 9, TxHash: chainhash.Hash{0x06}}, 1},
+		{7, KeyIDActivity{Height: 2, TxHash: chainhash.Hash{0x01}}, 1},
+		{7, KeyIDActivity{Height: 2, TxHash: chainhash.Hash{0x01},
+			Index: 1}, 1},
+		{7, KeyIDActivity{Height: 5, TxHash: chainhash.Hash{0x02},
+			Type: KeyIDOutputSpent}, 0},
+		{7, KeyIDActivity{Height: 5, TxHash: chainhash.Hash{0x02}}, 0},
+		{7, KeyIDActivity{Height: 5, TxHash: chainhash.Hash{0x03}}, 2},
+		{8, KeyIDActivity{Height: 1, TxHash: chainhash.Hash{0x08}}, 1},
+		{^btcec.KeyID(0), KeyIDActivity{Height: 3,
+			TxHash: chainhash.Hash{0xff}}, 1},
+		{^btcec.KeyID(0), KeyIDActivity{Height: 4,
+			TxHash: chainhash.Hash{0xfe}}, 1},
+	}
+	idx := NewKeyIDIndex(db, nil)
+	err := db.Update(func(dbTx database.Tx) error {
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+		bucket := dbTx.Metadata().Bucket(keyIDIndexKey)
+		for i := range entries {
+			entry := &entries[i]
+			key := keyIDIndexKeyFor(entry.keyID,
+				entry.activity.Height, entry.txIdx,
+				entry.activity.Type, entry.activity.Index)
+			err := bucket.Put(key,
+				serializeKeyIDActivity(&entry.activity))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to store entries: %v", err)
+	}
+
+	tx1 := KeyIDTx{2, chainhash.Hash{0x01}}
+	tx2 := KeyIDTx{5, chainhash.Hash{0x02}}
+	tx3 := KeyIDTx{5, chainhash.Hash{0x03}}
+	tests := []struct {
+		keyID        btcec.KeyID
+		numToSkip    uint32
+		numRequested uint32
+		reverse      bool
+		want         []KeyIDTx
+	}{
+		{7, 0, 100, false, []KeyIDTx{tx1, tx2, tx3}},
+		{7, 1, 1, false, []KeyIDTx{tx2}},
+		{7, 2, 100, false, []KeyIDTx{tx3}},
+		{7, 3, 100, false, nil},
+		{7, 0, 0, false, nil},
+		{7, 0, 100, true, []KeyIDTx{tx3, tx2, tx1}},
+		{7, 1, 2, true, []KeyIDTx{tx2, tx1}},
+		{^btcec.KeyID(0), 0, 1, true, []KeyIDTx{
+			{4, chainhash.Hash{0xfe}},
+		}},
+		{5, 0, 100, true, nil},
+		{9, 0, 100, false, nil},
+	}
+	for i, test := range tests {
+		got, err := idx.TxnsForKeyID(test.keyID, test.numToSkip,
+			test.numRequested, test.reverse)
+		if err != nil {
+			t.Errorf("test #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("test #%d: got transactions %v, want %v", i,
+				got, test.want)
+		}
+	}
+}
+
+// TestKeyIDIndexConnectBlock ensures the key ID index records the outputs
+// created and spent under the key IDs of the addresses involved when blocks are
+// connected, and removes them when the blocks are disconnected.
+func TestKeyIDIndexConnectBlock(t *testing.T) {
+	t.Parallel()
+
+	db, teardown := newTestIndexDB(t, "keyidindex")
+	defer teardown()
+
+	c, _, _ := newTestPaymentChain(t)
+	idx := NewKeyIDIndex(db, &chaincfg.MainNetParams)
+	connectTestChain(t, db, idx, c)
+
+	// Ensure the transactions of the key IDs are returned in order, and
+	// that key ID 2 of address A has the output spent by the second block
+	// along with the change.
+	fund, spend := c.txns[0], c.txns[1]
+	fundTx := KeyIDTx{1, fund.TxHash()}
+	spendTx := KeyIDTx{2, spend.TxHash()}
+	checkTxns := func(keyID btcec.KeyID, want []KeyIDTx) {
+		got, err := idx.TxnsForKeyID(keyID, 0, 10, false)
+		if err != nil {
+			t.Fatalf("TxnsForKeyID: unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("TxnsForKeyID: got transactions %v for key ID "+
+				"%d, want %v", got, keyID, want)
+		}
+	}
+	checkTxns(1, []KeyIDTx{fundTx, spendTx})
+	checkTxns(3, []KeyIDTx{spendTx})
+
+	scriptA := fund.TxOut[0].PkScript
+	created := KeyIDActivity{
+		Type:     KeyIDOutputCreated,
+		Height:   1,
+		TxHash:   fund.TxHash(),
+		Value:    1000,
+		PkScript: scriptA,
+	}
+	want := []KeyIDActivity{
+		created,
+		{
+			Type:     KeyIDOutputCreated,
+			Height:   2,
+			TxHash:   spend.TxHash(),
+			Index:    1,
+			Value:    400,
+			PkScript: scriptA,
+		},
+		{
+			Type:     KeyIDOutputSpent,
+			Height:   2,
+			TxHash:   spend.TxHash(),
+			PrevOut:  *wire.NewOutPoint(&fundTx.TxHash, 0),
+			Value:    1000,
+			PkScript: scriptA,
+		},
+	}
+	activity, err := idx.ActivityForKeyID(2, 0, 10)
+	if err != nil {
+		t.Fatalf("ActivityForKeyID: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(activity, want) {
+		t.Fatalf("ActivityForKeyID: got activity %+v, want %+v",
+			activity, want)
+	}
+
+	// Ensure only the activity of the first block remains once the second
+	// block is disconnected.
+	disconnectTestTip(t, db, idx, c)
+	checkTxns(1, []KeyIDTx{fundTx})
+	checkTxns(3, nil)
+	activity, err = idx.ActivityForKeyID(2, 0, 10)
+	if err != nil {
+		t.Fatalf("ActivityForKeyID: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(activity, []KeyIDActivity{created}) {
+		t.Fatalf("ActivityForKeyID: got activity %+v after "+
+			"disconnecting, want %+v", activity, created)
+	}
+}
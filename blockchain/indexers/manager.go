@@ -8,6 +8,10 @@ package indexers
 import (
 	"bytes"
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/chaincfg/chainhash"
@@ -16,6 +20,13 @@ import (
 	"github.com/bitgo/prova/wire"
 )
 
+const (
+	// catchUpRetryInterval is the amount of time the background catch up
+	// waits before checking the state of the main chain again when it is
+	// unable to make progress.
+	catchUpRetryInterval = time.Second
+)
+
 var (
 	// indexTipsBucketName is the name of the db bucket used to house the
 	// current tip of each index.
@@ -33,6 +44,17 @@ var (
 //   Field           Type             Size
 //   block hash      chainhash.Hash   chainhash.HashSize
 //   block height    uint32           4 bytes
+//
+// The same bucket houses the version of the format each index was built with
+// under the key of the index prefixed with 'v'.  Indexes built before the
+// versions were recorded have no entry and are at version 1.
+//
+// The serialized format for an index version is:
+//
+//   <version>
+//
+//   Field           Type             Size
+//   version         uint32           4 bytes
 // -----------------------------------------------------------------------------
 
 // dbPutIndexerTip uses an existing database transaction to update or add the
@@ -46,6 +68,44 @@ func dbPutIndexerTip(dbTx database.Tx, idxKey []byte, hash *chainhash.Hash, heig
 	return indexesBucket.Put(idxKey, serialized)
 }
 
+// indexVersion returns the version of the format of the entries of the passed
+// indexer.
+func indexVersion(indexer Indexer) uint32 {
+	if versioner, ok := indexer.(Versioner); ok {
+		return versioner.Version()
+	}
+	return 1
+}
+
+// indexVersionKey returns the key for the version of the format an index was
+// built with.
+func indexVersionKey(idxKey []byte) []byte {
+	versionKey := make([]byte, len(idxKey)+1)
+	versionKey[0] = 'v'
+	copy(versionKey[1:], idxKey)
+	return versionKey
+}
+
+// dbPutIndexVersion uses an existing database transaction to record the
+// version of the format of the entries of the passed indexer.
+func dbPutIndexVersion(dbTx database.Tx, indexer Indexer) error {
+	var serialized [4]byte
+	byteOrder.PutUint32(serialized[:], indexVersion(indexer))
+	indexesBucket := dbTx.Metadata().Bucket(indexTipsBucketName)
+	return indexesBucket.Put(indexVersionKey(indexer.Key()), serialized[:])
+}
+
+// dbFetchIndexVersion uses an existing database transaction to retrieve the
+// version of the format the provided index was built with.
+func dbFetchIndexVersion(dbTx database.Tx, idxKey []byte) uint32 {
+	indexesBucket := dbTx.Metadata().Bucket(indexTipsBucketName)
+	serialized := indexesBucket.Get(indexVersionKey(idxKey))
+	if len(serialized) < 4 {
+		return 1
+	}
+	return byteOrder.Uint32(serialized)
+}
+
 // dbFetchIndexerTip uses an existing database transaction to retrieve the
 // hash and height of the current tip for the provided index.
 func dbFetchIndexerTip(dbTx database.Tx, idxKey []byte) (*chainhash.Hash, int32, error) {
@@ -126,9 +186,41 @@ func dbIndexDisconnectBlock(dbTx database.Tx, indexer Indexer, block *provautil.
 // Manager defines an index manager that manages multiple optional indexes and
 // implements the blockchain.IndexManager interface so it can be seamlessly
 // plugged into normal chain processing.
+//
+// Indexes which are behind the main chain when the manager is initialized are
+// caught up in the background once the manager is started so they do not
+// block startup.  Since the tip of each index is updated along with the index
+// entries for every block, the tips serve as progress checkpoints which allow
+// the catch up to resume where it left off after a restart.
+//
+// The enabled indexes can also be dropped and rebuilt while the node is
+// running.  A dropped index is no longer updated until it is rebuilt.  Indexes
+// which implement the Verifier interface and fail verification once they are
+// caught up are disabled the same way.
 type Manager struct {
+	started  int32
+	shutdown int32
+
 	db             database.DB
 	enabledIndexes []Indexer
+	chain          *blockchain.BlockChain
+
+	// synced tracks which of the enabled indexes have caught up to the
+	// main chain and therefore must be updated with every connected and
+	// disconnected block, while dropped tracks which of them have been
+	// dropped at runtime and must not be touched at all.  catchingUp is
+	// whether or not the background catch up is running.  They are
+	// protected by the mtx field.
+	mtx        sync.Mutex
+	synced     []bool
+	dropped    []bool
+	catchingUp bool
+
+	// manageMtx serializes runtime drops and rebuilds of the indexes.
+	manageMtx sync.Mutex
+
+	quit chan struct{}
+	wg   sync.WaitGroup
 }
 
 // Ensure the Manager type implements the blockchain.IndexManager interface.
@@ -189,6 +281,51 @@ func (m *Manager) maybeFinishDrops() error {
 	return nil
 }
 
+// maybeDropOutdated drops each of the enabled indexes which was built with a
+// different version of its format than the current one, so it is created again
+// and rebuilt from scratch.  Otherwise the index would serve a mix of entries
+// in the old and the new format.
+func (m *Manager) maybeDropOutdated() error {
+	indexVersions := make([]uint32, len(m.enabledIndexes))
+	err := m.db.View(func(dbTx database.Tx) error {
+		// None of the indexes exist yet if the index tips bucket
+		// hasn't been created yet.
+		indexesBucket := dbTx.Metadata().Bucket(indexTipsBucketName)
+		if indexesBucket == nil {
+			return nil
+		}
+
+		for i, indexer := range m.enabledIndexes {
+			idxKey := indexer.Key()
+			if indexesBucket.Get(idxKey) == nil {
+				continue
+			}
+			indexVersions[i] = dbFetchIndexVersion(dbTx, idxKey)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, indexer := range m.enabledIndexes {
+		version := indexVersion(indexer)
+		if indexVersions[i] == 0 || indexVersions[i] == version {
+			continue
+		}
+
+		log.Infof("Rebuilding %s since its format changed from "+
+			"version %d to %d", indexer.Name(), indexVersions[i],
+			version)
+		err := dropIndex(m.db, indexer.Key(), indexer.Name())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // maybeCreateIndexes determines if each of the enabled indexes have already
 // been created and creates them if not.
 func (m *Manager) maybeCreateIndexes(dbTx database.Tx) error {
@@ -206,6 +343,9 @@ func (m *Manager) maybeCreateIndexes(dbTx database.Tx) error {
 		if err := indexer.Create(dbTx); err != nil {
 			return err
 		}
+		if err := dbPutIndexVersion(dbTx, indexer); err != nil {
+			return err
+		}
 
 		// Set the tip for the index to values which represent an
 		// uninitialized index.
@@ -219,14 +359,15 @@ func (m *Manager) maybeCreateIndexes(dbTx database.Tx) error {
 }
 
 // Init initializes the enabled indexes.  This is called during chain
-// initialization and primarily consists of catching up all indexes to the
-// current best chain tip.  This is necessary since each index can be disabled
-// and re-enabled at any time and attempting to catch-up indexes at the same
-// time new blocks are being downloaded would lead to an overall longer time to
-// catch up due to the I/O contention.
+// initialization and primarily consists of rolling back any indexes whose tip
+// is no longer in the main chain and determining which indexes need to be
+// caught up to the current best chain tip.  The catch up itself happens in the
+// background once the manager is started.
 //
 // This is part of the blockchain.IndexManager interface.
 func (m *Manager) Init(chain *blockchain.BlockChain) error {
+	m.chain = chain
+
 	// Nothing to do when no indexes are enabled.
 	if len(m.enabledIndexes) == 0 {
 		return nil
@@ -237,6 +378,12 @@ func (m *Manager) Init(chain *blockchain.BlockChain) error {
 		return err
 	}
 
+	// Drop the indexes which were built with an outdated format so they
+	// are rebuilt from scratch.
+	if err := m.maybeDropOutdated(); err != nil {
+		return err
+	}
+
 	// Create the initial state for the indexes as needed.
 	err := m.db.Update(func(dbTx database.Tx) error {
 		// Create the bucket for the current tips as needed.
@@ -348,13 +495,11 @@ func (m *Manager) Init(chain *blockchain.BlockChain) error {
 		}
 	}
 
-	// Fetch the current tip heights for each index along with tracking the
-	// lowest one so the catchup code only needs to start at the earliest
-	// block and is able to skip connecting the block for the indexes that
-	// don't need it.
-	bestHeight := int32(chain.BestSnapshot().Height)
-	lowestHeight := bestHeight
-	indexerHeights := make([]int32, len(m.enabledIndexes))
+	// Mark the indexes which are already caught up to the current best
+	// chain tip as synced and track the lowest tip of the remaining ones so
+	// the details of the catch up can be logged.
+	best := chain.BestSnapshot()
+	lowestHeight := int32(best.Height)
 	err = m.db.View(func(dbTx database.Tx) error {
 		for i, indexer := range m.enabledIndexes {
 			idxKey := indexer.Key()
@@ -365,7 +510,11 @@ func (m *Manager) Init(chain *blockchain.BlockChain) error {
 
 			log.Debugf("Current %s tip (height %d, hash %v)",
 				indexer.Name(), height, hash)
-			indexerHeights[i] = height
+			if hash.IsEqual(best.Hash) {
+				m.synced[i] = true
+				m.verifyIndex(dbTx, i)
+				continue
+			}
 			if height < lowestHeight {
 				lowestHeight = height
 			}
@@ -376,65 +525,585 @@ func (m *Manager) Init(chain *blockchain.BlockChain) error {
 		return err
 	}
 
-	// Nothing to index if all of the indexes are caught up.
-	if lowestHeight == bestHeight {
-		return nil
+	// At this point, any indexes which are behind the current best chain
+	// tip are caught up in the background once the manager is started.
+	if lowestHeight < int32(best.Height) {
+		log.Infof("Catching up indexes from height %d to %d in the "+
+			"background", lowestHeight, best.Height)
 	}
+	return nil
+}
 
-	// Create a progress logger for the indexing process below.
-	progressLogger := newBlockProgressLogger("Indexed", log)
+// verifyIndex verifies the entries of the enabled index at the passed position
+// when it implements the Verifier interface.  An index which fails verification
+// is disabled like an index which has been dropped, however, its entries are
+// left in place so the index can be inspected or rebuilt.
+//
+// This function MUST be called with the manager lock held or before the manager
+// is started.
+func (m *Manager) verifyIndex(dbTx database.Tx, i int) {
+	verifier, ok := m.enabledIndexes[i].(Verifier)
+	if !ok {
+		return
+	}
 
-	// At this point, one or more indexes are behind the current best chain
-	// tip and need to be caught up, so log the details and loop through
-	// each block that needs to be indexed.
-	log.Infof("Catching up indexes from height %d to %d", lowestHeight,
-		bestHeight)
-	for height := lowestHeight + 1; height <= bestHeight; height++ {
-		// Load the block for the height since it is required to index
-		// it.
-		block, err := chain.BlockByHeight(uint32(height))
-		if err != nil {
-			return err
+	if err := verifier.VerifyEntries(dbTx); err != nil {
+		log.Errorf("Disabling %s since it failed verification: %v",
+			m.enabledIndexes[i].Name(), err)
+		m.dropped[i] = true
+		m.synced[i] = false
+	}
+}
+
+// markSynced marks the indexes which have caught up to the current best chain
+// tip as synced.  It returns the number of indexes which are still behind along
+// with the lowest height among their tips.
+func (m *Manager) markSynced() (int, int32, error) {
+	var numUnsynced int
+	var lowestHeight int32
+	err := m.db.Update(func(dbTx database.Tx) error {
+		// Holding a write transaction ensures no blocks are connected
+		// or disconnected while the tips are compared.
+		best := m.chain.BestSnapshot()
+		lowestHeight = int32(best.Height)
+
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
+		for i, indexer := range m.enabledIndexes {
+			if m.synced[i] || m.dropped[i] {
+				continue
+			}
+
+			hash, height, err := dbFetchIndexerTip(dbTx, indexer.Key())
+			if err != nil {
+				return err
+			}
+			if hash.IsEqual(best.Hash) {
+				log.Infof("Caught up %s to height %d",
+					indexer.Name(), height)
+				m.synced[i] = true
+				m.verifyIndex(dbTx, i)
+				continue
+			}
+
+			numUnsynced++
+			if height < lowestHeight {
+				lowestHeight = height
+			}
 		}
 
-		// Connect the block for all indexes that need it.
+		// The background catch up is done once all indexes are synced.
+		// This is updated while the lock is held so that rebuilds know
+		// whether they need to start it again.
+		if numUnsynced == 0 {
+			m.catchingUp = false
+		}
+		return nil
+	})
+	return numUnsynced, lowestHeight, err
+}
+
+// catchUpBlock connects the passed block to all indexes which are not synced
+// and whose tip is the parent of the block.
+func (m *Manager) catchUpBlock(block *provautil.Block) error {
+	return m.db.Update(func(dbTx database.Tx) error {
+		// The block might have been disconnected from the main chain
+		// since it was loaded, in which case it must not be indexed.
+		if !blockchain.DBMainChainHasBlock(dbTx, block.Hash()) {
+			return nil
+		}
+
+		m.mtx.Lock()
+		defer m.mtx.Unlock()
 		var view *blockchain.UtxoViewpoint
+		prevHash := &block.MsgBlock().Header.PrevBlock
 		for i, indexer := range m.enabledIndexes {
 			// Skip indexes that don't need to be updated with this
 			// block.
-			if indexerHeights[i] >= height {
+			if m.synced[i] || m.dropped[i] {
+				continue
+			}
+			tipHash, _, err := dbFetchIndexerTip(dbTx, indexer.Key())
+			if err != nil {
+				return err
+			}
+			if !tipHash.IsEqual(prevHash) {
 				continue
 			}
 
-			err := m.db.Update(func(dbTx database.Tx) error {
-				// When the index requires all of the referenced
-				// txouts and they haven't been loaded yet, they
-				// need to be retrieved from the transaction
-				// index.
-				if view == nil && indexNeedsInputs(indexer) {
-					var err error
-					view, err = makeUtxoView(dbTx, block)
-					if err != nil {
-						return err
-					}
+			// When the index requires all of the referenced txouts
+			// and they haven't been loaded yet, they need to be
+			// retrieved from the transaction index.
+			if view == nil && indexNeedsInputs(indexer) {
+				view, err = makeUtxoView(dbTx, block)
+				if err != nil {
+					return err
 				}
-				return dbIndexConnectBlock(dbTx, indexer, block,
-					view)
-			})
+			}
+			err = dbIndexConnectBlock(dbTx, indexer, block, view)
 			if err != nil {
 				return err
 			}
-			indexerHeights[i] = height
+		}
+		return nil
+	})
+}
+
+// catchUpHandler catches up all indexes which are behind the main chain one
+// block at a time until they reach the best chain tip.  This is necessary since
+// each index can be disabled and re-enabled at any time.  New blocks are
+// connected to an index that is being caught up as soon as its tip reaches
+// them, so the handler exits once every index is synced.
+//
+// It must be run as a goroutine.
+func (m *Manager) catchUpHandler() {
+	progressLogger := newBlockProgressLogger("Indexed", log)
+out:
+	for {
+		select {
+		case <-m.quit:
+			break out
+		default:
+		}
+
+		numUnsynced, lowestHeight, err := m.markSynced()
+		if err != nil {
+			log.Errorf("Unable to catch up indexes: %v", err)
+			break out
+		}
+		if numUnsynced == 0 {
+			m.wg.Done()
+			return
+		}
+
+		// Load the next block which needs to be indexed.  Wait for the
+		// chain to settle when there is no such block, which can
+		// happen when the best chain state is in the middle of being
+		// updated.
+		var block *provautil.Block
+		if lowestHeight < int32(m.chain.BestSnapshot().Height) {
+			block, err = m.chain.BlockByHeight(uint32(lowestHeight + 1))
+		}
+		if err != nil {
+			log.Debugf("Unable to load block at height %d: %v",
+				lowestHeight+1, err)
+		}
+		if block == nil || err != nil {
+			select {
+			case <-m.quit:
+				break out
+			case <-time.After(catchUpRetryInterval):
+			}
+			continue
+		}
+
+		if err := m.catchUpBlock(block); err != nil {
+			log.Errorf("Unable to catch up indexes: %v", err)
+			break out
 		}
 
 		// Log indexing progress.
 		progressLogger.LogBlockHeight(block)
 	}
 
-	log.Infof("Indexes caught up to height %d", bestHeight)
+	m.mtx.Lock()
+	m.catchingUp = false
+	m.mtx.Unlock()
+	m.wg.Done()
+}
+
+// maybeStartCatchUp starts catching up indexes in the background when the
+// manager is running and it is not already doing so.
+//
+// This function MUST be called with the manager lock held.
+func (m *Manager) maybeStartCatchUp() {
+	if atomic.LoadInt32(&m.started) == 0 ||
+		atomic.LoadInt32(&m.shutdown) != 0 || m.catchingUp {
+
+		return
+	}
+
+	m.catchingUp = true
+	m.wg.Add(1)
+	go m.catchUpHandler()
+}
+
+// Start begins catching up any indexes which are behind the main chain in the
+// background.
+func (m *Manager) Start() {
+	// Already started?
+	if atomic.AddInt32(&m.started, 1) != 1 {
+		return
+	}
+
+	m.mtx.Lock()
+	m.maybeStartCatchUp()
+	m.mtx.Unlock()
+}
+
+// Stop gracefully stops catching up indexes in the background and waits for
+// it to finish.  Progress is retained so it resumes on the next start.
+func (m *Manager) Stop() {
+	// The lock is held while flagging the shutdown so rebuilds can't start
+	// the background catch up again once it is being waited on.
+	m.mtx.Lock()
+	if atomic.AddInt32(&m.shutdown, 1) != 1 {
+		m.mtx.Unlock()
+		return
+	}
+	m.mtx.Unlock()
+
+	close(m.quit)
+	m.wg.Wait()
+}
+
+// indexByName returns the position of the enabled index with the passed name
+// or key.  Names are matched without regard to case.
+func (m *Manager) indexByName(name string) (int, error) {
+	for i, indexer := range m.enabledIndexes {
+		if strings.EqualFold(indexer.Name(), name) ||
+			string(indexer.Key()) == name {
+
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no enabled index named %q", name)
+}
+
+// checkDependencies returns an error when the index at the passed position
+// can't be dropped or rebuilt because of the transaction index.  Indexes which
+// require the referenced inputs rely on the transaction index to look them up
+// while catching up, so the transaction index can't be rebuilt from scratch
+// while any of them are enabled, and they can't be rebuilt without it.
+//
+// This function MUST be called with the manager lock held.
+func (m *Manager) checkDependencies(idx int, action string) error {
+	indexer := m.enabledIndexes[idx]
+	if bytes.Equal(indexer.Key(), txIndexKey) {
+		for i, other := range m.enabledIndexes {
+			if i != idx && !m.dropped[i] && indexNeedsInputs(other) {
+				return fmt.Errorf("unable to %s the %s while the "+
+					"%s depends on it", action,
+					indexer.Name(), other.Name())
+			}
+		}
+		return nil
+	}
+
+	if action != "rebuild" || !indexNeedsInputs(indexer) {
+		return nil
+	}
+	for i, other := range m.enabledIndexes {
+		if bytes.Equal(other.Key(), txIndexKey) && !m.dropped[i] {
+			return nil
+		}
+	}
+	return fmt.Errorf("unable to %s the %s without the %s", action,
+		indexer.Name(), txIndexName)
+}
+
+// DropIndex drops the enabled index with the passed name or key from the
+// database.  The index is no longer updated with new blocks until it is rebuilt
+// with RebuildIndex.
+//
+// This function is safe for concurrent access.
+func (m *Manager) DropIndex(name string) error {
+	m.manageMtx.Lock()
+	defer m.manageMtx.Unlock()
+
+	m.mtx.Lock()
+	idx, err := m.indexByName(name)
+	if err == nil && m.dropped[idx] {
+		err = fmt.Errorf("the %s has already been dropped",
+			m.enabledIndexes[idx].Name())
+	}
+	if err == nil {
+		err = m.checkDependencies(idx, "drop")
+	}
+	if err != nil {
+		m.mtx.Unlock()
+		return err
+	}
+	m.dropped[idx] = true
+	m.synced[idx] = false
+	m.mtx.Unlock()
+
+	indexer := m.enabledIndexes[idx]
+	return dropIndex(m.db, indexer.Key(), indexer.Name())
+}
+
+// RebuildIndex drops the enabled index with the passed name or key from the
+// database, if it still exists, and then rebuilds it from scratch in the
+// background.  This also re-enables indexes previously dropped with DropIndex.
+//
+// This function is safe for concurrent access.
+func (m *Manager) RebuildIndex(name string) error {
+	m.manageMtx.Lock()
+	defer m.manageMtx.Unlock()
+
+	m.mtx.Lock()
+	idx, err := m.indexByName(name)
+	if err == nil {
+		err = m.checkDependencies(idx, "rebuild")
+	}
+	if err != nil {
+		m.mtx.Unlock()
+		return err
+	}
+	m.dropped[idx] = true
+	m.synced[idx] = false
+	m.mtx.Unlock()
+
+	// Remove all existing entries and create the index again with a tip
+	// which represents an uninitialized index.
+	indexer := m.enabledIndexes[idx]
+	if err := dropIndex(m.db, indexer.Key(), indexer.Name()); err != nil {
+		return err
+	}
+	err = m.db.Update(func(dbTx database.Tx) error {
+		if err := indexer.Create(dbTx); err != nil {
+			return err
+		}
+		if err := dbPutIndexVersion(dbTx, indexer); err != nil {
+			return err
+		}
+		return dbPutIndexerTip(dbTx, indexer.Key(), &chainhash.Hash{}, -1)
+	})
+	if err != nil {
+		return err
+	}
+	if err := indexer.Init(); err != nil {
+		return err
+	}
+
+	// Re-enable the index and catch it up in the background.
+	log.Infof("Rebuilding %s", indexer.Name())
+	m.mtx.Lock()
+	m.dropped[idx] = false
+	m.maybeStartCatchUp()
+	m.mtx.Unlock()
 	return nil
 }
 
+// IndexCheckResult describes the outcome of a consistency check of an index.
+type IndexCheckResult struct {
+	// Name is the human-readable name of the checked index.
+	Name string
+
+	// BlocksChecked is the number of blocks which have been checked.
+	BlocksChecked int
+
+	// Inconsistencies are the divergent entries which have been found.
+	Inconsistencies []IndexInconsistency
+}
+
+// CheckIndex cross-checks the entries the enabled index with the passed name or
+// key stores for the main chain blocks in the passed range of heights against
+// the blocks themselves.  Only every interval-th block of the range is checked,
+// which allows large ranges to be sampled.  The range is limited to the blocks
+// the index has caught up to.  Divergent entries are rewritten when repair is
+// set.
+//
+// This function is safe for concurrent access.
+func (m *Manager) CheckIndex(name string, startHeight, endHeight, interval uint32, repair bool) (*IndexCheckResult, error) {
+	m.manageMtx.Lock()
+	defer m.manageMtx.Unlock()
+
+	m.mtx.Lock()
+	idx, err := m.indexByName(name)
+	if err == nil && m.dropped[idx] {
+		err = fmt.Errorf("the %s has been dropped",
+			m.enabledIndexes[idx].Name())
+	}
+	m.mtx.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	indexer := m.enabledIndexes[idx]
+	checker, ok := indexer.(Checker)
+	if !ok {
+		return nil, fmt.Errorf("the %s does not support consistency "+
+			"checks", indexer.Name())
+	}
+	if interval == 0 {
+		interval = 1
+	}
+
+	// Only the blocks which have already been connected to the index can
+	// be checked.  The tip can't move backwards while the checks run since
+	// the index can't be dropped or rebuilt in the mean time, and blocks
+	// which are disconnected are skipped below.
+	var tipHeight int32
+	err = m.db.View(func(dbTx database.Tx) error {
+		var err error
+		_, tipHeight, err = dbFetchIndexerTip(dbTx, indexer.Key())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := &IndexCheckResult{Name: indexer.Name()}
+	if tipHeight < 0 || startHeight > uint32(tipHeight) {
+		return result, nil
+	}
+	if endHeight > uint32(tipHeight) {
+		endHeight = uint32(tipHeight)
+	}
+
+	var numRepaired int
+	for height := startHeight; height <= endHeight; height += interval {
+		select {
+		case <-m.quit:
+			return nil, fmt.Errorf("the index manager is shutting down")
+		default:
+		}
+
+		block, err := m.chain.BlockByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		checkBlock := func(dbTx database.Tx) error {
+			// The block might have been disconnected from the main
+			// chain, and therefore from the index, since it was
+			// loaded.
+			if !blockchain.DBMainChainHasBlock(dbTx, block.Hash()) {
+				return nil
+			}
+
+			var view *blockchain.UtxoViewpoint
+			var err error
+			if indexNeedsInputs(indexer) {
+				view, err = makeUtxoView(dbTx, block)
+				if err != nil {
+					return err
+				}
+			}
+			inconsistencies, err := checker.CheckBlock(dbTx, block,
+				view, repair)
+			if err != nil {
+				return err
+			}
+			for i := range inconsistencies {
+				inconsistency := &inconsistencies[i]
+				inconsistency.Height = int32(height)
+				inconsistency.BlockHash = *block.Hash()
+				if inconsistency.Repaired {
+					numRepaired++
+				}
+			}
+			result.Inconsistencies = append(result.Inconsistencies,
+				inconsistencies...)
+			result.BlocksChecked++
+			return nil
+		}
+		if repair {
+			err = m.db.Update(checkBlock)
+		} else {
+			err = m.db.View(checkBlock)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// Avoid wrapping around at the end of the height range.
+		if endHeight-height < interval {
+			break
+		}
+	}
+
+	if len(result.Inconsistencies) > 0 {
+		log.Warnf("Found %d divergent entries in the %s (%d repaired)",
+			len(result.Inconsistencies), indexer.Name(), numRepaired)
+	}
+	return result, nil
+}
+
+// IndexEnabled returns whether or not the passed index is managed by the
+// manager and has not been dropped.
+//
+// This function is safe for concurrent access.
+func (m *Manager) IndexEnabled(indexer Indexer) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for i, enabled := range m.enabledIndexes {
+		if enabled == indexer {
+			return !m.dropped[i]
+		}
+	}
+	return false
+}
+
+// IndexStatus describes the progress of an index towards the best chain tip.
+type IndexStatus struct {
+	// Name is the human-readable name of the index.
+	Name string
+
+	// Key is the database key of the index.
+	Key string
+
+	// Enabled is whether or not the index is being maintained.  It is
+	// false for indexes which have been dropped at runtime.
+	Enabled bool
+
+	// Height is the height of the current tip of the index.
+	Height int32
+
+	// Synced is whether or not the index has caught up to the main chain.
+	Synced bool
+
+	// PercentComplete is the percentage of the blocks in the main chain
+	// which have been indexed.
+	PercentComplete float64
+}
+
+// IndexStatuses returns the status of each of the enabled indexes.
+//
+// This function is safe for concurrent access.
+func (m *Manager) IndexStatuses() ([]IndexStatus, error) {
+	m.mtx.Lock()
+	synced := make([]bool, len(m.synced))
+	copy(synced, m.synced)
+	dropped := make([]bool, len(m.dropped))
+	copy(dropped, m.dropped)
+	m.mtx.Unlock()
+
+	bestHeight := m.chain.BestSnapshot().Height
+	statuses := make([]IndexStatus, 0, len(m.enabledIndexes))
+	err := m.db.View(func(dbTx database.Tx) error {
+		for i, indexer := range m.enabledIndexes {
+			if dropped[i] {
+				statuses = append(statuses, IndexStatus{
+					Name:   indexer.Name(),
+					Key:    string(indexer.Key()),
+					Height: -1,
+				})
+				continue
+			}
+
+			_, height, err := dbFetchIndexerTip(dbTx, indexer.Key())
+			if err != nil {
+				return err
+			}
+
+			percent := float64(100)
+			if !synced[i] {
+				percent = float64(height+1) * 100 /
+					float64(bestHeight+1)
+			}
+			statuses = append(statuses, IndexStatus{
+				Name:            indexer.Name(),
+				Key:             string(indexer.Key()),
+				Enabled:         true,
+				Height:          height,
+				Synced:          synced[i],
+				PercentComplete: percent,
+			})
+		}
+		return nil
+	})
+	return statuses, err
+}
+
 // indexNeedsInputs returns whether or not the index needs access to the txouts
 // referenced by the transaction inputs being indexed.
 func indexNeedsInputs(index Indexer) bool {
@@ -511,9 +1180,28 @@ func makeUtxoView(dbTx database.Tx, block *provautil.Block) (*blockchain.UtxoVie
 //
 // This is part of the blockchain.IndexManager interface.
 func (m *Manager) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	// Call each of the currently active optional indexes with the block
-	// being connected so they can update accordingly.
-	for _, index := range m.enabledIndexes {
+	// being connected so they can update accordingly.  Indexes which are
+	// still being caught up only follow the chain once their tip reaches
+	// the parent of the block.
+	prevHash := &block.MsgBlock().Header.PrevBlock
+	for i, index := range m.enabledIndexes {
+		if m.dropped[i] {
+			continue
+		}
+		if !m.synced[i] {
+			tipHash, _, err := dbFetchIndexerTip(dbTx, index.Key())
+			if err != nil {
+				return err
+			}
+			if !tipHash.IsEqual(prevHash) {
+				continue
+			}
+		}
+
 		err := dbIndexConnectBlock(dbTx, index, block, view)
 		if err != nil {
 			return err
@@ -529,9 +1217,27 @@ func (m *Manager) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *b
 //
 // This is part of the blockchain.IndexManager interface.
 func (m *Manager) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
 	// Call each of the currently active optional indexes with the block
-	// being disconnected so they can update accordingly.
-	for _, index := range m.enabledIndexes {
+	// being disconnected so they can update accordingly.  Indexes which are
+	// still being caught up only need to be updated when their tip is the
+	// block.
+	for i, index := range m.enabledIndexes {
+		if m.dropped[i] {
+			continue
+		}
+		if !m.synced[i] {
+			tipHash, _, err := dbFetchIndexerTip(dbTx, index.Key())
+			if err != nil {
+				return err
+			}
+			if !tipHash.IsEqual(block.Hash()) {
+				continue
+			}
+		}
+
 		err := dbIndexDisconnectBlock(dbTx, index, block, view)
 		if err != nil {
 			return err
@@ -548,6 +1254,9 @@ func NewManager(db database.DB, enabledIndexes []Indexer) *Manager {
 	return &Manager{
 		db:             db,
 		enabledIndexes: enabledIndexes,
+		synced:         make([]bool, len(enabledIndexes)),
+		dropped:        make([]bool, len(enabledIndexes)),
+		quit:           make(chan struct{}),
 	}
 }
 
@@ -627,14 +1336,18 @@ func dropIndex(db database.DB, idxKey []byte, idxName string) error {
 		}
 	}
 
-	// Remove the index tip, index bucket, and in-progress drop flag now
-	// that all index entries have been removed.
+	// Remove the index tip, index version, index bucket, and in-progress
+	// drop flag now that all index entries have been removed.
 	err = db.Update(func(dbTx database.Tx) error {
 		meta := dbTx.Metadata()
 		indexesBucket := meta.Bucket(indexTipsBucketName)
 		if err := indexesBucket.Delete(idxKey); err != nil {
 			return err
 		}
+		err := indexesBucket.Delete(indexVersionKey(idxKey))
+		if err != nil {
+			return err
+		}
 
 		if err := meta.DeleteBucket(idxKey); err != nil {
 			return err
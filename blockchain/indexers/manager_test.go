@@ -0,0 +1,350 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestManagerIndexDependencies ensures the transaction index can't be dropped
+// or rebuilt while indexes which depend on it are enabled and that those
+// indexes can't be rebuilt without it.
+func TestManagerIndexDependencies(t *testing.T) {
+	t.Parallel()
+
+	txIndex := NewTxIndex(nil)
+	keyIDIndex := NewKeyIDIndex(nil, nil)
+	timeIndex := NewTimeIndex(nil)
+	m := NewManager(nil, []Indexer{txIndex, keyIDIndex, timeIndex})
+
+	// Indexes are found by name regardless of case and by key.
+	tests := []struct {
+		name    string
+		wantIdx int
+		wantErr bool
+	}{
+		{name: txIndexName, wantIdx: 0},
+		{name: "Key ID Activity Index", wantIdx: 1},
+		{name: string(timeIndexKey), wantIdx: 2},
+		{name: "bogus index", wantErr: true},
+	}
+	for i, test := range tests {
+		idx, err := m.indexByName(test.name)
+		if (err != nil) != test.wantErr {
+			t.Errorf("test #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if !test.wantErr && idx != test.wantIdx {
+			t.Errorf("test #%d: unexpected index - got %d, want %d",
+				i, idx, test.wantIdx)
+		}
+	}
+
+	// The transaction index is required by the key ID index.
+	if err := m.checkDependencies(0, "drop"); err == nil {
+		t.Fatal("dropping the transaction index did not fail")
+	}
+	if err := m.checkDependencies(0, "rebuild"); err == nil {
+		t.Fatal("rebuilding the transaction index did not fail")
+	}
+	if err := m.checkDependencies(1, "rebuild"); err != nil {
+		t.Fatalf("unable to rebuild the key ID index: %v", err)
+	}
+	if err := m.checkDependencies(2, "drop"); err != nil {
+		t.Fatalf("unable to drop the block time index: %v", err)
+	}
+
+	// Once the key ID index is dropped, the transaction index is free to be
+	// dropped, after which the key ID index can't be rebuilt.
+	m.dropped[1] = true
+	if err := m.checkDependencies(0, "drop"); err != nil {
+		t.Fatalf("unable to drop the transaction index: %v", err)
+	}
+	m.dropped[0] = true
+	if err := m.checkDependencies(1, "rebuild"); err == nil {
+		t.Fatal("rebuilding the key ID index did not fail")
+	}
+
+	// Dropped indexes are not reported as enabled.
+	if m.IndexEnabled(txIndex) || !m.IndexEnabled(timeIndex) {
+		t.Fatal("unexpected enabled state for indexes")
+	}
+	if m.IndexEnabled((*AddrIndex)(nil)) {
+		t.Fatal("unmanaged index reported as enabled")
+	}
+}
+
+// testVersionedIndex is an index with a single bucket and a configurable
+// format version.
+type testVersionedIndex struct {
+	version uint32
+}
+
+func (idx *testVersionedIndex) Key() []byte     { return []byte("testidx") }
+func (idx *testVersionedIndex) Name() string    { return "test index" }
+func (idx *testVersionedIndex) Init() error     { return nil }
+func (idx *testVersionedIndex) Version() uint32 { return idx.version }
+
+func (idx *testVersionedIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(idx.Key())
+	return err
+}
+
+func (idx *testVersionedIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	return nil
+}
+
+func (idx *testVersionedIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	return nil
+}
+
+// TestManagerDropOutdated ensures indexes built with a different version of
+// their format are dropped so they are created again, while indexes at the
+// current version are left untouched.
+func TestManagerDropOutdated(t *testing.T) {
+	t.Parallel()
+
+	dbPath, err := ioutil.TempDir("", "manager")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	// createIndex creates the index when needed as the manager does on
+	// initialization after dropping outdated indexes, and marks it as
+	// built up to a block.
+	idx := &testVersionedIndex{version: 1}
+	m := NewManager(db, []Indexer{idx})
+	tip := &chainhash.Hash{0x01}
+	createIndex := func() {
+		if err := m.maybeDropOutdated(); err != nil {
+			t.Fatalf("maybeDropOutdated: unexpected error: %v", err)
+		}
+		err := db.Update(func(dbTx database.Tx) error {
+			meta := dbTx.Metadata()
+			_, err := meta.CreateBucketIfNotExists(indexTipsBucketName)
+			if err != nil {
+				return err
+			}
+			if err := m.maybeCreateIndexes(dbTx); err != nil {
+				return err
+			}
+			// Only a newly created index has an uninitialized tip.
+			_, height, err := dbFetchIndexerTip(dbTx, idx.Key())
+			if err != nil || height != -1 {
+				return err
+			}
+			return dbPutIndexerTip(dbTx, idx.Key(), tip, 10)
+		})
+		if err != nil {
+			t.Fatalf("unable to create index: %v", err)
+		}
+	}
+	tipHeight := func() int32 {
+		var height int32
+		err := db.View(func(dbTx database.Tx) error {
+			var err error
+			_, height, err = dbFetchIndexerTip(dbTx, idx.Key())
+			return err
+		})
+		if err != nil {
+			t.Fatalf("dbFetchIndexerTip: unexpected error: %v", err)
+		}
+		return height
+	}
+
+	// The index is created at its version and kept while it doesn't
+	// change.
+	createIndex()
+	tip = &chainhash.Hash{0x02}
+	createIndex()
+	if height := tipHeight(); height != 10 {
+		t.Fatalf("index at the current version was rebuilt - got tip "+
+			"height %d", height)
+	}
+
+	// Changing the version drops the index, so it is created again with
+	// the new version.
+	idx.version = 2
+	if err := m.maybeDropOutdated(); err != nil {
+		t.Fatalf("maybeDropOutdated: unexpected error: %v", err)
+	}
+	err = db.View(func(dbTx database.Tx) error {
+		if dbTx.Metadata().Bucket(idx.Key()) != nil {
+			t.Fatal("outdated index was not dropped")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	createIndex()
+	err = db.View(func(dbTx database.Tx) error {
+		if v := dbFetchIndexVersion(dbTx, idx.Key()); v != 2 {
+			t.Fatalf("got index version %d, want 2", v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestManagerCatchUp ensures an index which is enabled on an already synced
+// node is caught up in the background once the manager is started, while
+// blocks keep being connected to the chain and the indexes which were already
+// enabled.
+func TestManagerCatchUp(t *testing.T) {
+	t.Parallel()
+
+	scenario := &fullblocktests.Scenario{
+		Seed:  1,
+		Steps: []fullblocktests.ScenarioStep{{Op: "mine", Count: 20}},
+	}
+	blocks, err := fullblocktests.GenerateScenario(scenario)
+	if err != nil {
+		t.Fatalf("GenerateScenario: unexpected error: %v", err)
+	}
+
+	dbPath, err := ioutil.TempDir("", "indexmanager")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		chaincfg.RegressionNetParams.Net)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	// newChain returns a chain instance backed by the database which uses
+	// the passed index manager, like a node does on every start.
+	newChain := func(m *Manager) *blockchain.BlockChain {
+		params := chaincfg.RegressionNetParams
+		chain, err := blockchain.New(&blockchain.Config{
+			DB:           db,
+			ChainParams:  &params,
+			TimeSource:   blockchain.NewMedianTime(),
+			SigCache:     txscript.NewSigCache(1000),
+			IndexManager: m,
+		})
+		if err != nil {
+			t.Fatalf("unable to create chain: %v", err)
+		}
+		return chain
+	}
+	processBlocks := func(chain *blockchain.BlockChain,
+		blocks []fullblocktests.ScenarioBlock) {
+
+		for _, item := range blocks {
+			block := provautil.NewBlock(item.Block)
+			block.SetHeight(item.Height)
+			_, _, err := chain.ProcessBlock(block, blockchain.BFNone)
+			if err != nil {
+				t.Fatalf("unable to process block %q: %v",
+					item.Name, err)
+			}
+		}
+	}
+
+	// waitForCatchUp waits for all indexes of the passed manager to catch
+	// up to the main chain and returns their statuses.
+	waitForCatchUp := func(m *Manager) []IndexStatus {
+		deadline := time.Now().Add(10 * time.Second)
+		for {
+			statuses, err := m.IndexStatuses()
+			if err != nil {
+				t.Fatalf("IndexStatuses: unexpected error: %v", err)
+			}
+			synced := true
+			for _, status := range statuses {
+				synced = synced && status.Synced
+			}
+			if synced {
+				return statuses
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("indexes did not catch up: %+v", statuses)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Sync most of the chain with only the transaction index enabled.
+	m := NewManager(db, []Indexer{NewTxIndex(db)})
+	chain := newChain(m)
+	m.Start()
+	processBlocks(chain, blocks[:15])
+	waitForCatchUp(m)
+	m.Stop()
+
+	// Enable the block time index on the synced node.  It starts out empty
+	// and does not hold up the initialization of the chain.
+	m = NewManager(db, []Indexer{NewTxIndex(db), NewTimeIndex(db)})
+	chain = newChain(m)
+	statuses, err := m.IndexStatuses()
+	if err != nil {
+		t.Fatalf("IndexStatuses: unexpected error: %v", err)
+	}
+	if !statuses[0].Synced || statuses[1].Synced ||
+		statuses[1].Height != -1 {
+
+		t.Fatalf("unexpected index statuses before the catch up: %+v",
+			statuses)
+	}
+
+	// Connect the rest of the chain while the new index catches up.
+	m.Start()
+	defer m.Stop()
+	processBlocks(chain, blocks[15:])
+
+	statuses = waitForCatchUp(m)
+	best := chain.BestSnapshot()
+	for _, status := range statuses {
+		if status.Height != int32(best.Height) ||
+			status.PercentComplete != 100 {
+
+			t.Errorf("%s did not catch up to height %d: %+v",
+				status.Name, best.Height, status)
+		}
+	}
+	err = db.View(func(dbTx database.Tx) error {
+		hash, _, err := dbFetchIndexerTip(dbTx, timeIndexKey)
+		if err != nil {
+			return err
+		}
+		if !hash.IsEqual(best.Hash) {
+			t.Errorf("unexpected block time index tip - got %v, "+
+				"want %v", hash, best.Hash)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to fetch index tip: %v", err)
+	}
+}
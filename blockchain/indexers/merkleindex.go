@@ -0,0 +1,245 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+const (
+	// merkleIndexName is the human-readable name for the index.
+	merkleIndexName = "merkle tree index"
+)
+
+var (
+	// merkleIndexKey is the key of the merkle tree index and the db bucket
+	// used to house it.
+	merkleIndexKey = []byte("merkleidx")
+)
+
+// -----------------------------------------------------------------------------
+// The merkle tree index consists of an entry for every block in the main chain
+// which holds all layers of the merkle tree over the hashes of the transactions
+// in the block.  The transaction hashes exclude the signatures, so the trees are
+// exactly the partial merkle trees served in merkle blocks and the paths of
+// any transactions in a block can be read from a single entry instead of
+// hashing the entire block again.
+//
+// The layers are stored from the transaction hashes up to the merkle root.
+// Each layer holds half as many nodes as the layer before it, rounded up, so
+// the layers are delimited by the number of transactions alone.
+//
+// The serialized format for the keys and values in the merkle tree index
+// bucket is:
+//
+//   <block hash> = <num txns><layer 0 hashes>...<root hash>
+//
+//   Field           Type              Size
+//   block hash      chainhash.Hash    32 bytes
+//   num txns        uint32            4 bytes
+//   layer hashes    []chainhash.Hash  32 bytes each
+// -----------------------------------------------------------------------------
+
+// merkleLayerWidths returns the number of nodes in each layer of the merkle
+// tree over the passed number of transactions.
+func merkleLayerWidths(numTx uint32) []uint32 {
+	widths := []uint32{numTx}
+	for width := numTx; width > 1; {
+		width = (width + 1) / 2
+		widths = append(widths, width)
+	}
+	return widths
+}
+
+// serializeMerkleLayers returns the serialization of the passed layers of a
+// merkle tree for storage in the merkle tree index.
+func serializeMerkleLayers(layers [][]chainhash.Hash) []byte {
+	numHashes := 0
+	for _, layer := range layers {
+		numHashes += len(layer)
+	}
+	serialized := make([]byte, 4, 4+numHashes*chainhash.HashSize)
+	byteOrder.PutUint32(serialized, uint32(len(layers[0])))
+	for _, layer := range layers {
+		for i := range layer {
+			serialized = append(serialized, layer[i][:]...)
+		}
+	}
+	return serialized
+}
+
+// deserializeMerkleLayers decodes the passed serialized layers of a merkle tree
+// as stored in the merkle tree index.
+func deserializeMerkleLayers(serialized []byte) ([][]chainhash.Hash, error) {
+	if len(serialized) < 4 {
+		return nil, errDeserialize("unexpected end of data")
+	}
+	numTx := byteOrder.Uint32(serialized)
+	if numTx == 0 {
+		return nil, errDeserialize("merkle tree without transactions")
+	}
+
+	widths := merkleLayerWidths(numTx)
+	numHashes := 0
+	for _, width := range widths {
+		numHashes += int(width)
+	}
+	if len(serialized) != 4+numHashes*chainhash.HashSize {
+		return nil, errDeserialize("unexpected size of merkle tree")
+	}
+
+	layers := make([][]chainhash.Hash, 0, len(widths))
+	offset := 4
+	for _, width := range widths {
+		layer := make([]chainhash.Hash, width)
+		for i := range layer {
+			copy(layer[i][:], serialized[offset:])
+			offset += chainhash.HashSize
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+// dbFetchMerkleLayers uses an existing database transaction to fetch the layers
+// of the merkle tree of the block with the passed hash.  When the block is not
+// indexed, nil is returned for the layers.
+func dbFetchMerkleLayers(dbTx database.Tx, hash *chainhash.Hash) ([][]chainhash.Hash, error) {
+	serialized := dbTx.Metadata().Bucket(merkleIndexKey).Get(hash[:])
+	if serialized == nil {
+		return nil, nil
+	}
+	layers, err := deserializeMerkleLayers(serialized)
+	if err != nil {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: "corrupt merkle tree index entry for " +
+				hash.String() + ": " + err.Error(),
+		}
+	}
+	return layers, nil
+}
+
+// MerkleLayersForBlock returns the layers of the merkle tree over the hashes of
+// the transactions in the passed block as built by
+// blockchain.BuildMerkleTreeLayers.
+func MerkleLayersForBlock(block *provautil.Block) [][]chainhash.Hash {
+	txns := block.Transactions()
+	hashes := make([]chainhash.Hash, 0, len(txns))
+	for _, tx := range txns {
+		hashes = append(hashes, *tx.Hash())
+	}
+	return blockchain.BuildMerkleTreeLayers(hashes)
+}
+
+// MerkleIndex implements a merkle tree index.  That is to say, it stores the
+// merkle tree of every block in the main chain so the merkle paths of
+// transactions can be served without hashing the block.
+type MerkleIndex struct {
+	db database.DB
+}
+
+// Ensure the MerkleIndex type implements the Indexer interface.
+var _ Indexer = (*MerkleIndex)(nil)
+
+// Ensure the MerkleIndex type implements the Checker interface.
+var _ Checker = (*MerkleIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *MerkleIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *MerkleIndex) Key() []byte {
+	return merkleIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *MerkleIndex) Name() string {
+	return merkleIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the merkle tree
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *MerkleIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(merkleIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer stores the merkle tree of the
+// block.
+//
+// This is part of the Indexer interface.
+func (idx *MerkleIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	serialized := serializeMerkleLayers(MerkleLayersForBlock(block))
+	return dbTx.Metadata().Bucket(merkleIndexKey).Put(block.Hash()[:],
+		serialized)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the merkle tree of
+// the block.
+//
+// This is part of the Indexer interface.
+func (idx *MerkleIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	return dbTx.Metadata().Bucket(merkleIndexKey).Delete(block.Hash()[:])
+}
+
+// CheckBlock verifies the entries the index stores for the passed block by
+// comparing them with the entries connecting the block produces.
+//
+// This is part of the Checker interface.
+func (idx *MerkleIndex) CheckBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint, repair bool) ([]IndexInconsistency, error) {
+	return checkBlockEntries(dbTx, repair, func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, view)
+	})
+}
+
+// MerkleLayers returns the layers of the merkle tree of the main chain block
+// with the passed hash.  When the block is not indexed, nil will be returned
+// for the layers along with a nil error.
+//
+// This function is safe for concurrent access.
+func (idx *MerkleIndex) MerkleLayers(hash *chainhash.Hash) ([][]chainhash.Hash, error) {
+	var layers [][]chainhash.Hash
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		layers, err = dbFetchMerkleLayers(dbTx, hash)
+		return err
+	})
+	return layers, err
+}
+
+// NewMerkleIndex returns a new instance of an indexer that is used to store
+// the merkle trees of all blocks in the main chain.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewMerkleIndex(db database.DB) *MerkleIndex {
+	return &MerkleIndex{db: db}
+}
+
+// DropMerkleIndex drops the merkle tree index from the provided database if it
+// exists.
+func DropMerkleIndex(db database.DB) error {
+	return dropIndex(db, merkleIndexKey, merkleIndexName)
+}
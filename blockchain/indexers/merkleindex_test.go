@@ -0,0 +1,51 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+)
+
+// TestMerkleLayersSerialization ensures the layers of merkle trees of various
+// sizes round trip through the serialization of the merkle tree index and that
+// malformed entries are rejected.
+func TestMerkleLayersSerialization(t *testing.T) {
+	t.Parallel()
+
+	for _, numTx := range []int{1, 2, 3, 7, 8, 13} {
+		hashes := make([]chainhash.Hash, numTx)
+		for i := range hashes {
+			hashes[i] = chainhash.Hash{byte(i), 0xaa}
+		}
+		layers := blockchain.BuildMerkleTreeLayers(hashes)
+
+		serialized := serializeMerkleLayers(layers)
+		got, err := deserializeMerkleLayers(serialized)
+		if err != nil {
+			t.Errorf("%d txns: unexpected error: %v", numTx, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, layers) {
+			t.Errorf("%d txns: mismatched layers - got %v, want %v",
+				numTx, got, layers)
+		}
+
+		_, err = deserializeMerkleLayers(serialized[:len(serialized)-1])
+		if !isDeserializeErr(err) {
+			t.Errorf("%d txns: truncated entry - unexpected error %v",
+				numTx, err)
+		}
+	}
+
+	for _, serialized := range [][]byte{nil, {0, 0, 0, 0}} {
+		if _, err := deserializeMerkleLayers(serialized); !isDeserializeErr(err) {
+			t.Errorf("entry %x: unexpected error %v", serialized, err)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// scriptUtxoIndexName is the human-readable name for the index.
+	scriptUtxoIndexName = "script utxo index"
+
+	// scriptUtxoKeySize is the number of bytes a key in the script utxo
+	// index consumes.  It consists of 32 bytes script hash + 32 bytes tx
+	// hash + 4 bytes output index.
+	scriptUtxoKeySize = chainhash.HashSize + chainhash.HashSize + 4
+
+	// scriptUtxoEntrySize is the number of bytes a value in the script
+	// utxo index consumes.  It consists of 8 bytes value + 4 bytes block
+	// height.
+	scriptUtxoEntrySize = 8 + 4
+)
+
+var (
+	// scriptUtxoIndexKey is the key of the script utxo index and the db
+	// bucket used to house it.
+	scriptUtxoIndexKey = []byte("scriptutxoidx")
+)
+
+// -----------------------------------------------------------------------------
+// The script utxo index tracks the current unspent outputs of every public key
+// script, regardless of whether or not the script pays to a standard address.
+// Scripts are identified by their script hash, which is the single sha256 of
+// the script, so arbitrary scripts are indexed with fixed size keys.
+//
+// The entries are keyed by the script hash followed by the outpoint, so all of
+// the unspent outputs of a script are found with a single cursor seek.
+//
+// The serialized format for the keys and values in the script utxo index
+// bucket is:
+//
+//   <script hash><txhash><index> = <value><block height>
+//
+//   Field           Type              Size
+//   script hash     chainhash.Hash    32 bytes
+//   txhash          chainhash.Hash    32 bytes
+//   index           uint32            4 bytes (big endian)
+//   value           int64             8 bytes
+//   block height    uint32            4 bytes
+//   -----
+//   Total: 80 bytes
+// -----------------------------------------------------------------------------
+
+// ScriptUtxo describes an unspent output paying to a public key script.
+type ScriptUtxo struct {
+	// OutPoint identifies the unspent output.
+	OutPoint wire.OutPoint
+
+	// Value is the value of the output.
+	Value int64
+
+	// Height is the height of the block containing the output.
+	Height uint32
+}
+
+// ScriptHash returns the hash the script utxo index identifies the passed
+// public key script by.
+func ScriptHash(pkScript []byte) chainhash.Hash {
+	return chainhash.HashH(pkScript)
+}
+
+// scriptUtxoKeyFor returns the key used to store the passed unspent output for
+// the public key script with the passed hash.
+func scriptUtxoKeyFor(scriptHash *chainhash.Hash, outPoint *wire.OutPoint) []byte {
+	key := make([]byte, scriptUtxoKeySize)
+	offset := copy(key, scriptHash[:])
+	offset += copy(key[offset:], outPoint.Hash[:])
+	binary.BigEndian.PutUint32(key[offset:], outPoint.Index)
+	return key
+}
+
+// serializeScriptUtxo serializes the value of the passed unspent output
+// according to the format described in detail above.
+func serializeScriptUtxo(utxo *ScriptUtxo) []byte {
+	serialized := make([]byte, scriptUtxoEntrySize)
+	byteOrder.PutUint64(serialized, uint64(utxo.Value))
+	byteOrder.PutUint32(serialized[8:], utxo.Height)
+	return serialized
+}
+
+// deserializeScriptUtxo decodes the passed key and serialized value into the
+// passed unspent output.
+func deserializeScriptUtxo(key, serialized []byte, utxo *ScriptUtxo) error {
+	if len(key) != scriptUtxoKeySize {
+		return errDeserialize("unexpected script utxo key length")
+	}
+	if len(serialized) != scriptUtxoEntrySize {
+		return errDeserialize("unexpected script utxo entry length")
+	}
+
+	offset := chainhash.HashSize
+	offset += copy(utxo.OutPoint.Hash[:], key[offset:])
+	utxo.OutPoint.Index = binary.BigEndian.Uint32(key[offset:])
+	utxo.Value = int64(byteOrder.Uint64(serialized))
+	utxo.Height = byteOrder.Uint32(serialized[8:])
+	return nil
+}
+
+// dbFetchScriptUtxos uses an existing database transaction to retrieve all of
+// the unspent outputs of the public key script with the passed hash.
+func dbFetchScriptUtxos(dbTx database.Tx, scriptHash *chainhash.Hash) ([]ScriptUtxo, error) {
+	var utxos []ScriptUtxo
+	cursor := dbTx.Metadata().Bucket(scriptUtxoIndexKey).Cursor()
+	for ok := cursor.Seek(scriptHash[:]); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if !bytes.HasPrefix(key, scriptHash[:]) {
+			break
+		}
+
+		var utxo ScriptUtxo
+		if err := deserializeScriptUtxo(key, cursor.Value(), &utxo); err != nil {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: "failed to deserialize script " +
+					"utxo entry: " + err.Error(),
+			}
+		}
+		utxos = append(utxos, utxo)
+	}
+
+	return utxos, nil
+}
+
+// dbPutScriptUtxo uses an existing database transaction to store the passed
+// unspent output under the passed public key script.
+func dbPutScriptUtxo(dbTx database.Tx, pkScript []byte, utxo *ScriptUtxo) error {
+	scriptHash := ScriptHash(pkScript)
+	key := scriptUtxoKeyFor(&scriptHash, &utxo.OutPoint)
+	return dbTx.Metadata().Bucket(scriptUtxoIndexKey).Put(key,
+		serializeScriptUtxo(utxo))
+}
+
+// dbRemoveScriptUtxo uses an existing database transaction to remove the
+// passed unspent output from the passed public key script.
+func dbRemoveScriptUtxo(dbTx database.Tx, pkScript []byte, outPoint *wire.OutPoint) error {
+	scriptHash := ScriptHash(pkScript)
+	key := scriptUtxoKeyFor(&scriptHash, outPoint)
+	return dbTx.Metadata().Bucket(scriptUtxoIndexKey).Delete(key)
+}
+
+// ScriptUtxoIndex implements an index of the current unspent outputs of every
+// public key script.  That is to say, it supports querying the unspent outputs
+// of arbitrary scripts without scanning the entire utxo set.
+type ScriptUtxoIndex struct {
+	db database.DB
+}
+
+// Ensure the ScriptUtxoIndex type implements the Indexer interface.
+var _ Indexer = (*ScriptUtxoIndex)(nil)
+
+// Ensure the ScriptUtxoIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*ScriptUtxoIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *ScriptUtxoIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptUtxoIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptUtxoIndex) Key() []byte {
+	return scriptUtxoIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptUtxoIndex) Name() string {
+	return scriptUtxoIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the script utxo
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptUtxoIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(scriptUtxoIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer removes the outputs spent by the
+// transactions in the block and adds the outputs created by them.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptUtxoIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	for txIdx, tx := range block.Transactions() {
+		// Coinbases do not reference any inputs.  Since the block is
+		// required to have already gone through full validation, it has
+		// already been proven on the first transaction in the block is
+		// a coinbase.
+		if txIdx != 0 {
+			for _, txIn := range tx.MsgTx().TxIn {
+				spent := spentUtxo(view, txIn)
+				if spent == nil {
+					continue
+				}
+				err := dbRemoveScriptUtxo(dbTx, spent.PkScript,
+					&spent.OutPoint)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			utxo := ScriptUtxo{
+				OutPoint: wire.OutPoint{
+					Hash:  *tx.Hash(),
+					Index: uint32(txOutIdx),
+				},
+				Value:  txOut.Value,
+				Height: block.Height(),
+			}
+			err := dbPutScriptUtxo(dbTx, txOut.PkScript, &utxo)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the outputs created
+// by the transactions in the block and restores the outputs they spent.
+//
+// This is part of the Indexer interface.
+func (idx *ScriptUtxoIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	// Undo the transactions in reverse order so outputs created and spent
+	// within the block are handled properly.
+	transactions := block.Transactions()
+	for txIdx := len(transactions) - 1; txIdx >= 0; txIdx-- {
+		tx := transactions[txIdx]
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			outPoint := wire.OutPoint{
+				Hash:  *tx.Hash(),
+				Index: uint32(txOutIdx),
+			}
+			err := dbRemoveScriptUtxo(dbTx, txOut.PkScript, &outPoint)
+			if err != nil {
+				return err
+			}
+		}
+
+		if txIdx == 0 {
+			continue
+		}
+		for _, txIn := range tx.MsgTx().TxIn {
+			spent := spentUtxo(view, txIn)
+			if spent == nil {
+				continue
+			}
+			utxo := ScriptUtxo{
+				OutPoint: spent.OutPoint,
+				Value:    spent.Value,
+				Height:   spent.Height,
+			}
+			err := dbPutScriptUtxo(dbTx, spent.PkScript, &utxo)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// UtxosForScriptHash returns the unspent outputs paying to the public key
+// script with the passed script hash.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptUtxoIndex) UtxosForScriptHash(scriptHash *chainhash.Hash) ([]ScriptUtxo, error) {
+	var utxos []ScriptUtxo
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		utxos, err = dbFetchScriptUtxos(dbTx, scriptHash)
+		return err
+	})
+	return utxos, err
+}
+
+// UtxosForScript returns the unspent outputs paying to the passed public key
+// script.
+//
+// This function is safe for concurrent access.
+func (idx *ScriptUtxoIndex) UtxosForScript(pkScript []byte) ([]ScriptUtxo, error) {
+	scriptHash := ScriptHash(pkScript)
+	return idx.UtxosForScriptHash(&scriptHash)
+}
+
+// NewScriptUtxoIndex returns a new instance of an indexer that is used to
+// maintain the current unspent outputs of all public key scripts.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewScriptUtxoIndex(db database.DB) *ScriptUtxoIndex {
+	return &ScriptUtxoIndex{db: db}
+}
+
+// DropScriptUtxoIndex drops the script utxo index from the provided database if
+// it exists.
+func DropScriptUtxoIndex(db database.DB) error {
+	return dropIndex(db, scriptUtxoIndexKey, scriptUtxoIndexName)
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestScriptUtxoSerialization ensures script unspent output entries round trip
+// through serialization and that the unspent outputs of a script are grouped
+// under its script hash.
+func TestScriptUtxoSerialization(t *testing.T) {
+	t.Parallel()
+
+	scriptHash := ScriptHash([]byte{0x6a, 0x01, 0x02})
+	utxo := ScriptUtxo{
+		OutPoint: wire.OutPoint{
+			Hash:  chainhash.Hash{0x01},
+			Index: 258,
+		},
+		Value:  2500,
+		Height: 500,
+	}
+	key := scriptUtxoKeyFor(&scriptHash, &utxo.OutPoint)
+	serialized := serializeScriptUtxo(&utxo)
+
+	var gotUtxo ScriptUtxo
+	if err := deserializeScriptUtxo(key, serialized, &gotUtxo); err != nil {
+		t.Fatalf("unexpected utxo error: %v", err)
+	}
+	if gotUtxo != utxo {
+		t.Fatalf("mismatched utxo - got %+v, want %+v", gotUtxo, utxo)
+	}
+	err := deserializeScriptUtxo(key, serialized[:10], &gotUtxo)
+	if !isDeserializeErr(err) {
+		t.Fatalf("unexpected error for short utxo: %v", err)
+	}
+	err = deserializeScriptUtxo(key[:40], serialized, &gotUtxo)
+	if !isDeserializeErr(err) {
+		t.Fatalf("unexpected error for short key: %v", err)
+	}
+
+	// The unspent outputs of a script must share the script hash as a
+	// common prefix and be ordered by outpoint.
+	if !bytes.HasPrefix(key, scriptHash[:]) {
+		t.Fatalf("utxo key %x does not start with script hash %x", key,
+			scriptHash)
+	}
+	next := utxo.OutPoint
+	next.Index++
+	if bytes.Compare(key, scriptUtxoKeyFor(&scriptHash, &next)) >= 0 {
+		t.Fatalf("utxo key for index 258 does not sort before 259")
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// spentIndexName is the human-readable name for the index.
+	spentIndexName = "spent index"
+
+	// spentIndexKeySize is the number of bytes a key in the spent index
+	// consumes.  It consists of the 32 byte transaction hash + 4 bytes
+	// output index.
+	spentIndexKeySize = chainhash.HashSize + 4
+
+	// spentIndexEntrySize is the number of bytes a value in the spent index
+	// consumes.  It consists of the 32 byte spending transaction hash + 4
+	// bytes input index + 4 bytes block height.
+	spentIndexEntrySize = chainhash.HashSize + 4 + 4
+)
+
+var (
+	// spentIndexKey is the key of the spent index and the db bucket used
+	// to house it.
+	spentIndexKey = []byte("spentbyoutpointidx")
+)
+
+// -----------------------------------------------------------------------------
+// The spent index consists of an entry for every transaction output in the main
+// chain that has been spent.  It maps the outpoint to the transaction and input
+// which spent it along with the height of the block that contains the spending
+// transaction.
+//
+// The serialized format for the keys and values in the spent index bucket is:
+//
+//   <txhash><output index> = <spending txhash><input index><block height>
+//
+//   Field              Type              Size
+//   txhash             chainhash.Hash    32 bytes
+//   output index       uint32            4 bytes
+//   spending txhash    chainhash.Hash    32 bytes
+//   input index        uint32            4 bytes
+//   block height       uint32            4 bytes
+//   -----
+//   Total: 76 bytes
+// -----------------------------------------------------------------------------
+
+// SpentInfo houses the details about the transaction input which spent an
+// output.
+type SpentInfo struct {
+	TxHash     chainhash.Hash
+	InputIndex uint32
+	Height     uint32
+}
+
+// spentIndexKeyFor returns the key used to store the spent index entry for the
+// passed outpoint.
+func spentIndexKeyFor(outpoint *wire.OutPoint) []byte {
+	key := make([]byte, spentIndexKeySize)
+	copy(key, outpoint.Hash[:])
+	byteOrder.PutUint32(key[chainhash.HashSize:], outpoint.Index)
+	return key
+}
+
+// serializeSpentInfo serializes the passed spent info according to the format
+// described in detail above.
+func serializeSpentInfo(info *SpentInfo) []byte {
+	serialized := make([]byte, spentIndexEntrySize)
+	copy(serialized, info.TxHash[:])
+	byteOrder.PutUint32(serialized[chainhash.HashSize:], info.InputIndex)
+	byteOrder.PutUint32(serialized[chainhash.HashSize+4:], info.Height)
+	return serialized
+}
+
+// deserializeSpentInfo decodes the passed serialized spent index entry into the
+// passed spent info.
+func deserializeSpentInfo(serialized []byte, info *SpentInfo) error {
+	if len(serialized) != spentIndexEntrySize {
+		return errDeserialize("unexpected spent index entry length")
+	}
+
+	copy(info.TxHash[:], serialized[:chainhash.HashSize])
+	info.InputIndex = byteOrder.Uint32(serialized[chainhash.HashSize:])
+	info.Height = byteOrder.Uint32(serialized[chainhash.HashSize+4:])
+	return nil
+}
+
+// dbFetchSpentInfo uses an existing database transaction to fetch the spent
+// info for the provided outpoint.  When there is no entry for the provided
+// outpoint, nil will be returned for the both the entry and the error.
+func dbFetchSpentInfo(dbTx database.Tx, outpoint *wire.OutPoint) (*SpentInfo, error) {
+	bucket := dbTx.Metadata().Bucket(spentIndexKey)
+	serialized := bucket.Get(spentIndexKeyFor(outpoint))
+	if len(serialized) == 0 {
+		return nil, nil
+	}
+
+	var info SpentInfo
+	if err := deserializeSpentInfo(serialized, &info); err != nil {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: "corrupt spent index entry for " +
+				outpoint.String() + ": " + err.Error(),
+		}
+	}
+
+	return &info, nil
+}
+
+// TxOutSpend houses the details about the transaction input which spent an
+// output of a transaction.
+type TxOutSpend struct {
+	OutputIndex uint32
+	SpentBy     SpentInfo
+}
+
+// dbFetchTxSpends uses an existing database transaction to fetch the spent
+// index entries of all of the spent outputs of the transaction with the
+// provided hash, ordered by output index.  Since the keys of the entries start
+// with the hash of the transaction, they are found with a single cursor seek.
+func dbFetchTxSpends(dbTx database.Tx, txHash *chainhash.Hash) ([]TxOutSpend, error) {
+	var spends []TxOutSpend
+	cursor := dbTx.Metadata().Bucket(spentIndexKey).Cursor()
+	for ok := cursor.Seek(txHash[:]); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if !bytes.HasPrefix(key, txHash[:]) {
+			break
+		}
+		if len(key) != spentIndexKeySize {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt spent index key "+
+					"for %s", txHash),
+			}
+		}
+
+		spend := TxOutSpend{
+			OutputIndex: byteOrder.Uint32(key[chainhash.HashSize:]),
+		}
+		err := deserializeSpentInfo(cursor.Value(), &spend.SpentBy)
+		if err != nil {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt spent index "+
+					"entry for %s:%d: %v", txHash,
+					spend.OutputIndex, err),
+			}
+		}
+		spends = append(spends, spend)
+	}
+
+	return spends, nil
+}
+
+// SpentIndex implements an index of the transaction inputs which spent each
+// output in the main chain.  That is to say, it supports querying which
+// transaction spent a given outpoint.
+type SpentIndex struct {
+	db database.DB
+}
+
+// Ensure the SpentIndex type implements the Indexer interface.
+var _ Indexer = (*SpentIndex)(nil)
+
+// Ensure the SpentIndex type implements the Checker interface.
+var _ Checker = (*SpentIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Key() []byte {
+	return spentIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Name() string {
+	return spentIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the spent
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(spentIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds an entry for every output
+// spent by the transactions in the passed block.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(spentIndexKey)
+	for _, tx := range block.Transactions()[1:] {
+		for txInIdx, txIn := range tx.MsgTx().TxIn {
+			info := SpentInfo{
+				TxHash:     *tx.Hash(),
+				InputIndex: uint32(txInIdx),
+				Height:     block.Height(),
+			}
+			err := bucket.Put(spentIndexKeyFor(&txIn.PreviousOutPoint),
+				serializeSpentInfo(&info))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the entries for every
+// output spent by the transactions in the passed block.
+//
+// This is part of the Indexer interface.
+func (idx *SpentIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	bucket := dbTx.Metadata().Bucket(spentIndexKey)
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			key := spentIndexKeyFor(&txIn.PreviousOutPoint)
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// CheckBlock verifies the entries the index stores for the passed block by
+// comparing them with the entries connecting the block produces.
+//
+// This is part of the Checker interface.
+func (idx *SpentIndex) CheckBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint, repair bool) ([]IndexInconsistency, error) {
+	return checkBlockEntries(dbTx, repair, func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, view)
+	})
+}
+
+// SpentInfo returns the details about the transaction input which spent the
+// provided outpoint.  When the outpoint has not been spent in the main chain,
+// nil will be returned for the both the entry and the error.
+//
+// This function is safe for concurrent access.
+func (idx *SpentIndex) SpentInfo(outpoint *wire.OutPoint) (*SpentInfo, error) {
+	var info *SpentInfo
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		info, err = dbFetchSpentInfo(dbTx, outpoint)
+		return err
+	})
+	return info, err
+}
+
+// TxSpends returns the details about the transaction inputs which spent the
+// outputs of the transaction with the provided hash in the main chain, ordered
+// by output index.  The outputs which have not been spent are omitted.
+//
+// This function is safe for concurrent access.
+func (idx *SpentIndex) TxSpends(hash *chainhash.Hash) ([]TxOutSpend, error) {
+	var spends []TxOutSpend
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		spends, err = dbFetchTxSpends(dbTx, hash)
+		return err
+	})
+	return spends, err
+}
+
+// NewSpentIndex returns a new instance of an indexer that is used to create a
+// mapping of every spent output in the blockchain to the transaction input that
+// spent it.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewSpentIndex(db database.DB) *SpentIndex {
+	return &SpentIndex{db: db}
+}
+
+// DropSpentIndex drops the spent index from the provided database if it exists.
+func DropSpentIndex(db database.DB) error {
+	return dropIndex(db, spentIndexKey, spentIndexName)
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestSpentInfoSerialization ensures spent index keys and entries serialize
+// as expected and entries round trip.
+func TestSpentInfoSerialization(t *testing.T) {
+	t.Parallel()
+
+	outpoint := wire.NewOutPoint(&chainhash.Hash{0xaa, 0xbb}, 0x01020304)
+	key := spentIndexKeyFor(outpoint)
+	wantKey := append(outpoint.Hash[:], 0x04, 0x03, 0x02, 0x01)
+	if !bytes.Equal(key, wantKey) {
+		t.Fatalf("spentIndexKeyFor: got %x, want %x", key, wantKey)
+	}
+
+	info := SpentInfo{
+		TxHash:     chainhash.Hash{0x11, 0x22, 0x33},
+		InputIndex: 2,
+		Height:     123456,
+	}
+	serialized := serializeSpentInfo(&info)
+	if len(serialized) != spentIndexEntrySize {
+		t.Fatalf("serializeSpentInfo: unexpected length %d",
+			len(serialized))
+	}
+
+	var got SpentInfo
+	if err := deserializeSpentInfo(serialized, &got); err != nil {
+		t.Fatalf("deserializeSpentInfo: unexpected error: %v", err)
+	}
+	if got != info {
+		t.Fatalf("deserializeSpentInfo: got %+v, want %+v", got, info)
+	}
+
+	// Ensure truncated entries are rejected.
+	err := deserializeSpentInfo(serialized[:spentIndexEntrySize-1], &got)
+	if !isDeserializeErr(err) {
+		t.Fatalf("deserializeSpentInfo: unexpected error for short "+
+			"entry: %v", err)
+	}
+}
+
+// TestSpentIndexConnectBlock ensures the spent index records the inputs which
+// spend the outputs of transactions when blocks are connected, returns the
+// spends of a transaction ordered by output index, and removes them when the
+// blocks are disconnected.
+func TestSpentIndexConnectBlock(t *testing.T) {
+	t.Parallel()
+
+	dbPath, err := ioutil.TempDir("", "spentindex")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	// Create a block which spends two of the outputs of a transaction
+	// besides its coinbase.
+	fundingHash := chainhash.Hash{0xaa}
+	otherHash := chainhash.Hash{0xbb}
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+		wire.MaxPrevOutIndex), nil))
+	coinbase.AddTxOut(wire.NewTxOut(0, nil))
+	spendTx := wire.NewMsgTx(1)
+	spendTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&fundingHash, 2), nil))
+	spendTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&otherHash, 0), nil))
+	spendTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&fundingHash, 0), nil))
+	spendTx.AddTxOut(wire.NewTxOut(0, nil))
+	msgBlock := wire.NewMsgBlock(&wire.BlockHeader{Height: 7})
+	msgBlock.AddTransaction(coinbase)
+	msgBlock.AddTransaction(spendTx)
+	block := provautil.NewBlock(msgBlock)
+
+	idx := NewSpentIndex(db)
+	err = db.Update(func(dbTx database.Tx) error {
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+		return idx.ConnectBlock(dbTx, block, nil)
+	})
+	if err != nil {
+		t.Fatalf("unable to connect block: %v", err)
+	}
+
+	// Ensure the spends of the funding transaction are returned ordered by
+	// output index.
+	spends, err := idx.TxSpends(&fundingHash)
+	if err != nil {
+		t.Fatalf("TxSpends: unexpected error: %v", err)
+	}
+	spendHash := spendTx.TxHash()
+	want := []TxOutSpend{
+		{OutputIndex: 0, SpentBy: SpentInfo{spendHash, 2, 7}},
+		{OutputIndex: 2, SpentBy: SpentInfo{spendHash, 0, 7}},
+	}
+	if len(spends) != len(want) {
+		t.Fatalf("TxSpends: got %d spends, want %d", len(spends),
+			len(want))
+	}
+	for i := range want {
+		if spends[i] != want[i] {
+			t.Fatalf("TxSpends: got spend %+v, want %+v", spends[i],
+				want[i])
+		}
+	}
+
+	// Ensure the spends are removed when the block is disconnected.
+	err = db.Update(func(dbTx database.Tx) error {
+		return idx.DisconnectBlock(dbTx, block, nil)
+	})
+	if err != nil {
+		t.Fatalf("unable to disconnect block: %v", err)
+	}
+	spends, err = idx.TxSpends(&fundingHash)
+	if err != nil {
+		t.Fatalf("TxSpends: unexpected error: %v", err)
+	}
+	if len(spends) != 0 {
+		t.Fatalf("TxSpends: got %d spends after disconnecting, want "+
+			"none", len(spends))
+	}
+}
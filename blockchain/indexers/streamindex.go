@@ -0,0 +1,736 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// streamIndexName is the human-readable name for the index.
+	streamIndexName = "stream index"
+
+	// streamKeyTypeEvent is the type of a key in the stream index which
+	// houses an event that has not been delivered yet.
+	streamKeyTypeEvent = 'e'
+
+	// streamEventKeySize is the number of bytes an event key consumes.  It
+	// consists of 1 byte key type + 8 bytes sequence number.
+	streamEventKeySize = 1 + 8
+
+	// streamStateSize is the number of bytes the state of the stream
+	// consumes.  It consists of 8 bytes epoch + 8 bytes next sequence
+	// number.
+	streamStateSize = 8 + 8
+
+	// streamBatchSize is the maximum number of events delivered to the sink
+	// at once.
+	streamBatchSize = 200
+
+	// streamPollInterval is the interval at which undelivered events are
+	// looked for when no new blocks have been indexed.
+	streamPollInterval = time.Second
+
+	// streamRetryInterval is the time to wait before events are delivered
+	// again after the sink failed to accept them.
+	streamRetryInterval = 5 * time.Second
+)
+
+var (
+	// streamIndexKey is the key of the stream index and the db bucket used
+	// to house it.
+	streamIndexKey = []byte("streamidx")
+
+	// streamStateKey is the key of the stream index which houses the state
+	// of the stream.
+	streamStateKey = []byte("s")
+)
+
+// -----------------------------------------------------------------------------
+// The stream index is an outbox of events describing the changes the blocks
+// connected to and disconnected from the main chain make.  The events are
+// written along with the blocks, so they are ordered exactly like the changes
+// to the chain and none of them are lost when the node stops unexpectedly.
+// They are delivered to an external sink in the background and removed once
+// the sink has accepted them.
+//
+// Every event is identified by a sequence number which increases by one with
+// each event and by the epoch of the stream, which changes whenever the index
+// is created.  Together they form the resume token of the event.  Since events
+// are delivered at least once, consumers should track the resume token of the
+// last event they processed and skip events with older tokens.
+//
+// The serialized format for the state of the stream is:
+//
+//   's' = <epoch><next sequence>
+//
+//   Field           Type      Size
+//   epoch           uint64    8 bytes
+//   next sequence   uint64    8 bytes
+//
+// The serialized format for undelivered events is:
+//
+//   'e'<sequence> = <type><payload>
+//
+//   Field           Type              Size
+//   sequence        uint64            8 bytes (big endian)
+//   type            StreamEventType   1 byte
+//   payload         []byte            variable (JSON)
+// -----------------------------------------------------------------------------
+
+// StreamEventType identifies the kind of change a stream event describes.
+type StreamEventType uint8
+
+// These constants define the kinds of changes stream events describe.
+const (
+	// StreamBlockConnected is the first event for a block which has been
+	// connected to the main chain.
+	StreamBlockConnected StreamEventType = iota
+
+	// StreamBlockDisconnected is the first event for a block which has
+	// been disconnected from the main chain.
+	StreamBlockDisconnected
+
+	// StreamTxConnected is emitted for every transaction in a connected
+	// block before its outputs.
+	StreamTxConnected
+
+	// StreamTxDisconnected is emitted for every transaction in a
+	// disconnected block before its outputs.
+	StreamTxDisconnected
+
+	// StreamUtxoCreated is emitted for every output created by a
+	// transaction in a connected block.
+	StreamUtxoCreated
+
+	// StreamUtxoSpent is emitted for every output spent by a transaction
+	// in a connected block.
+	StreamUtxoSpent
+
+	// StreamUtxoRemoved is emitted for every output which no longer exists
+	// because the block which created it has been disconnected.
+	StreamUtxoRemoved
+
+	// StreamUtxoRestored is emitted for every output which is unspent
+	// again because the block which spent it has been disconnected.
+	StreamUtxoRestored
+)
+
+// streamEventTypeStrings is a map of stream event types back to their constant
+// names for pretty printing.
+var streamEventTypeStrings = map[StreamEventType]string{
+	StreamBlockConnected:    "blockconnected",
+	StreamBlockDisconnected: "blockdisconnected",
+	StreamTxConnected:       "txconnected",
+	StreamTxDisconnected:    "txdisconnected",
+	StreamUtxoCreated:       "utxocreated",
+	StreamUtxoSpent:         "utxospent",
+	StreamUtxoRemoved:       "utxoremoved",
+	StreamUtxoRestored:      "utxorestored",
+}
+
+// String returns the StreamEventType as a human-readable name.
+func (t StreamEventType) String() string {
+	if s, ok := streamEventTypeStrings[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown StreamEventType (%d)", uint8(t))
+}
+
+// StreamEvent is an event delivered to a stream sink.
+type StreamEvent struct {
+	// Epoch identifies the stream the event belongs to.  It changes when
+	// the stream index is rebuilt.
+	Epoch uint64
+
+	// Sequence is the position of the event within the stream.
+	Sequence uint64
+
+	// Type is the kind of change the event describes.
+	Type StreamEventType
+
+	// Payload is the JSON encoding of the event.
+	Payload []byte
+}
+
+// ResumeToken returns the token which identifies the event across restarts.
+// Consumers can compare it with the token of the last event they processed in
+// order to skip events which are delivered more than once.
+func (e *StreamEvent) ResumeToken() string {
+	return fmt.Sprintf("%d-%d", e.Epoch, e.Sequence)
+}
+
+// StreamSink provides an interface for an external system which receives the
+// events of the stream index.
+type StreamSink interface {
+	// Send delivers the passed events, which are ordered by sequence, to
+	// the external system.  It must only return nil once the external
+	// system has accepted all of them.  The same events are sent again
+	// when an error is returned.
+	Send(events []StreamEvent) error
+
+	// Close releases any resources held by the sink.
+	Close() error
+}
+
+// streamEventHeader houses the fields common to all stream event payloads.
+type streamEventHeader struct {
+	Epoch     uint64 `json:"epoch"`
+	Sequence  uint64 `json:"sequence"`
+	Type      string `json:"type"`
+	Height    uint32 `json:"height"`
+	BlockHash string `json:"blockhash"`
+}
+
+// header returns the header of the event payload.  It is promoted to all
+// payloads embedding the header.
+func (h *streamEventHeader) header() *streamEventHeader {
+	return h
+}
+
+// streamEventPayload is implemented by all stream event payloads.
+type streamEventPayload interface {
+	header() *streamEventHeader
+}
+
+// streamBlockEvent is the payload of block events.
+type streamBlockEvent struct {
+	streamEventHeader
+	PrevBlockHash string `json:"prevblockhash"`
+	Time          int64  `json:"time"`
+	NumTx         int    `json:"numtx"`
+}
+
+// streamTxEvent is the payload of transaction events.
+type streamTxEvent struct {
+	streamEventHeader
+	Txid    string `json:"txid"`
+	TxIndex int    `json:"txindex"`
+}
+
+// streamUtxoEvent is the payload of output events.  SpendingTxid is only set
+// for outputs which are spent or restored.
+type streamUtxoEvent struct {
+	streamEventHeader
+	Txid         string   `json:"txid"`
+	Vout         uint32   `json:"vout"`
+	Value        int64    `json:"value"`
+	ScriptPubKey string   `json:"scriptpubkey"`
+	Addresses    []string `json:"addresses,omitempty"`
+	SpendingTxid string   `json:"spendingtxid,omitempty"`
+}
+
+// streamEventKeyFor returns the key used to store the event with the passed
+// sequence number.
+func streamEventKeyFor(sequence uint64) []byte {
+	key := make([]byte, streamEventKeySize)
+	key[0] = streamKeyTypeEvent
+	binary.BigEndian.PutUint64(key[1:], sequence)
+	return key
+}
+
+// serializeStreamState serializes the passed state according to the format
+// described in detail above.
+func serializeStreamState(epoch, nextSequence uint64) []byte {
+	serialized := make([]byte, streamStateSize)
+	byteOrder.PutUint64(serialized, epoch)
+	byteOrder.PutUint64(serialized[8:], nextSequence)
+	return serialized
+}
+
+// deserializeStreamState decodes the passed serialized state into the epoch and
+// next sequence number of the stream.
+func deserializeStreamState(serialized []byte) (uint64, uint64, error) {
+	if len(serialized) != streamStateSize {
+		return 0, 0, errDeserialize("unexpected stream state length")
+	}
+	return byteOrder.Uint64(serialized), byteOrder.Uint64(serialized[8:]),
+		nil
+}
+
+// deserializeStreamEvent decodes the passed key and serialized value into the
+// passed event.
+func deserializeStreamEvent(key, serialized []byte, event *StreamEvent) error {
+	if len(key) != streamEventKeySize || len(serialized) < 1 {
+		return errDeserialize("unexpected stream event length")
+	}
+	event.Sequence = binary.BigEndian.Uint64(key[1:])
+	event.Type = StreamEventType(serialized[0])
+	event.Payload = make([]byte, len(serialized)-1)
+	copy(event.Payload, serialized[1:])
+	return nil
+}
+
+// streamEventWriter appends the events for a block to the stream.
+type streamEventWriter struct {
+	bucket       database.Bucket
+	epoch        uint64
+	nextSequence uint64
+	height       uint32
+	blockHash    string
+}
+
+// newStreamEventWriter returns a writer which appends the events for the passed
+// block to the stream using the passed database transaction.
+func newStreamEventWriter(dbTx database.Tx, block *provautil.Block) (*streamEventWriter, error) {
+	bucket := dbTx.Metadata().Bucket(streamIndexKey)
+	epoch, nextSequence, err := deserializeStreamState(bucket.Get(streamStateKey))
+	if err != nil {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: "failed to deserialize stream state: " +
+				err.Error(),
+		}
+	}
+	return &streamEventWriter{
+		bucket:       bucket,
+		epoch:        epoch,
+		nextSequence: nextSequence,
+		height:       block.Height(),
+		blockHash:    block.Hash().String(),
+	}, nil
+}
+
+// add appends an event of the passed type with the passed payload to the
+// stream.  The header of the payload is filled in.
+func (w *streamEventWriter) add(eventType StreamEventType, payload streamEventPayload) error {
+	*payload.header() = streamEventHeader{
+		Epoch:     w.epoch,
+		Sequence:  w.nextSequence,
+		Type:      eventType.String(),
+		Height:    w.height,
+		BlockHash: w.blockHash,
+	}
+	serializedPayload, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	serialized := make([]byte, 1+len(serializedPayload))
+	serialized[0] = byte(eventType)
+	copy(serialized[1:], serializedPayload)
+	err = w.bucket.Put(streamEventKeyFor(w.nextSequence), serialized)
+	if err != nil {
+		return err
+	}
+	w.nextSequence++
+	return nil
+}
+
+// finish stores the updated state of the stream.
+func (w *streamEventWriter) finish() error {
+	return w.bucket.Put(streamStateKey, serializeStreamState(w.epoch,
+		w.nextSequence))
+}
+
+// StreamIndex implements an outbox of events describing the changes to the
+// main chain which are delivered to an external sink.  It allows external
+// systems to stay in sync with the chain, including reorganizations, without
+// polling the RPC server.
+type StreamIndex struct {
+	db          database.DB
+	chainParams *chaincfg.Params
+
+	started  int32
+	shutdown int32
+	sink     StreamSink
+	notify   chan struct{}
+	quit     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Ensure the StreamIndex type implements the Indexer interface.
+var _ Indexer = (*StreamIndex)(nil)
+
+// Ensure the StreamIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*StreamIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *StreamIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *StreamIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *StreamIndex) Key() []byte {
+	return streamIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *StreamIndex) Name() string {
+	return streamIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the stream
+// index and starts a new epoch.
+//
+// This is part of the Indexer interface.
+func (idx *StreamIndex) Create(dbTx database.Tx) error {
+	bucket, err := dbTx.Metadata().CreateBucket(streamIndexKey)
+	if err != nil {
+		return err
+	}
+	epoch := uint64(time.Now().UnixNano())
+	return bucket.Put(streamStateKey, serializeStreamState(epoch, 1))
+}
+
+// scriptAddresses returns the encoded addresses the passed public key script
+// pays to.
+func (idx *StreamIndex) scriptAddresses(pkScript []byte) []string {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript,
+		idx.chainParams)
+	if err != nil || len(addrs) == 0 {
+		return nil
+	}
+	encoded := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		encoded = append(encoded, addr.EncodeAddress())
+	}
+	return encoded
+}
+
+// outputEvent returns the payload of an event for the passed output.
+func (idx *StreamIndex) outputEvent(outPoint *wire.OutPoint, value int64, pkScript []byte, spendingTxHash *chainhash.Hash) *streamUtxoEvent {
+	event := &streamUtxoEvent{
+		Txid:         outPoint.Hash.String(),
+		Vout:         outPoint.Index,
+		Value:        value,
+		ScriptPubKey: hex.EncodeToString(pkScript),
+		Addresses:    idx.scriptAddresses(pkScript),
+	}
+	if spendingTxHash != nil {
+		event.SpendingTxid = spendingTxHash.String()
+	}
+	return event
+}
+
+// addInputEvents appends an event of the passed type for every output spent by
+// the passed transaction.
+func (idx *StreamIndex) addInputEvents(w *streamEventWriter, eventType StreamEventType, tx *provautil.Tx, view *blockchain.UtxoViewpoint) error {
+	for _, txIn := range tx.MsgTx().TxIn {
+		// The view should always have the input since the index
+		// contract requires it, however, be safe and simply ignore any
+		// missing entries.
+		origin := &txIn.PreviousOutPoint
+		entry := view.LookupEntry(&origin.Hash)
+		if entry == nil {
+			continue
+		}
+
+		event := idx.outputEvent(origin, entry.AmountByIndex(origin.Index),
+			entry.PkScriptByIndex(origin.Index), tx.Hash())
+		if err := w.add(eventType, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addOutputEvents appends an event of the passed type for every output created
+// by the passed transaction.
+func (idx *StreamIndex) addOutputEvents(w *streamEventWriter, eventType StreamEventType, tx *provautil.Tx) error {
+	for txOutIdx, txOut := range tx.MsgTx().TxOut {
+		outPoint := wire.OutPoint{Hash: *tx.Hash(), Index: uint32(txOutIdx)}
+		event := idx.outputEvent(&outPoint, txOut.Value, txOut.PkScript,
+			nil)
+		if err := w.add(eventType, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockEvent returns the payload of an event for the passed block.
+func blockEvent(block *provautil.Block) *streamBlockEvent {
+	header := &block.MsgBlock().Header
+	return &streamBlockEvent{
+		PrevBlockHash: header.PrevBlock.String(),
+		Time:          header.Timestamp.Unix(),
+		NumTx:         len(block.Transactions()),
+	}
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer appends an event for the block
+// followed by an event for each transaction in the block and the outputs it
+// spends and creates.
+//
+// This is part of the Indexer interface.
+func (idx *StreamIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	w, err := newStreamEventWriter(dbTx, block)
+	if err != nil {
+		return err
+	}
+	if err := w.add(StreamBlockConnected, blockEvent(block)); err != nil {
+		return err
+	}
+	for txIdx, tx := range block.Transactions() {
+		event := &streamTxEvent{Txid: tx.Hash().String(), TxIndex: txIdx}
+		if err := w.add(StreamTxConnected, event); err != nil {
+			return err
+		}
+
+		// Coinbases do not reference any inputs.
+		if txIdx != 0 {
+			err := idx.addInputEvents(w, StreamUtxoSpent, tx, view)
+			if err != nil {
+				return err
+			}
+		}
+		if err := idx.addOutputEvents(w, StreamUtxoCreated, tx); err != nil {
+			return err
+		}
+	}
+	if err := w.finish(); err != nil {
+		return err
+	}
+
+	idx.notifyEvents()
+	return nil
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer appends an event for the
+// block followed by an event for each transaction in the block, in reverse
+// order, and the outputs it created and spent.
+//
+// This is part of the Indexer interface.
+func (idx *StreamIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	w, err := newStreamEventWriter(dbTx, block)
+	if err != nil {
+		return err
+	}
+	if err := w.add(StreamBlockDisconnected, blockEvent(block)); err != nil {
+		return err
+	}
+	transactions := block.Transactions()
+	for txIdx := len(transactions) - 1; txIdx >= 0; txIdx-- {
+		tx := transactions[txIdx]
+		event := &streamTxEvent{Txid: tx.Hash().String(), TxIndex: txIdx}
+		if err := w.add(StreamTxDisconnected, event); err != nil {
+			return err
+		}
+		if err := idx.addOutputEvents(w, StreamUtxoRemoved, tx); err != nil {
+			return err
+		}
+		if txIdx != 0 {
+			err := idx.addInputEvents(w, StreamUtxoRestored, tx, view)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if err := w.finish(); err != nil {
+		return err
+	}
+
+	idx.notifyEvents()
+	return nil
+}
+
+// notifyEvents wakes up the delivery of events without blocking.
+func (idx *StreamIndex) notifyEvents() {
+	select {
+	case idx.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pendingEvents returns up to the passed number of events which have not been
+// delivered yet, ordered by sequence.
+func (idx *StreamIndex) pendingEvents(maxEvents int) ([]StreamEvent, error) {
+	var events []StreamEvent
+	err := idx.db.View(func(dbTx database.Tx) error {
+		// The index might have been dropped.
+		bucket := dbTx.Metadata().Bucket(streamIndexKey)
+		if bucket == nil {
+			return nil
+		}
+		epoch, _, err := deserializeStreamState(bucket.Get(streamStateKey))
+		if err != nil {
+			return err
+		}
+
+		cursor := bucket.Cursor()
+		keyPrefix := []byte{streamKeyTypeEvent}
+		for ok := cursor.Seek(keyPrefix); ok; ok = cursor.Next() {
+			key := cursor.Key()
+			if len(events) == maxEvents {
+				break
+			}
+			if len(key) == 0 || key[0] != streamKeyTypeEvent {
+				break
+			}
+
+			event := StreamEvent{Epoch: epoch}
+			err := deserializeStreamEvent(key, cursor.Value(), &event)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+		return nil
+	})
+	return events, err
+}
+
+// removeEvents removes the passed delivered events from the stream.
+func (idx *StreamIndex) removeEvents(events []StreamEvent) error {
+	return idx.db.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(streamIndexKey)
+		if bucket == nil {
+			return nil
+		}
+		for i := range events {
+			key := streamEventKeyFor(events[i].Sequence)
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// wait waits for the passed duration or new events, whichever comes first.  It
+// returns false when the index is being stopped.
+func (idx *StreamIndex) wait(d time.Duration, wakeOnEvents bool) bool {
+	var notify chan struct{}
+	if wakeOnEvents {
+		notify = idx.notify
+	}
+	select {
+	case <-idx.quit:
+		return false
+	case <-notify:
+	case <-time.After(d):
+	}
+	return true
+}
+
+// deliveryHandler delivers the events of the stream to the sink in order and
+// removes them once the sink has accepted them.  Events the sink fails to
+// accept are retried until it does.
+//
+// It must be run as a goroutine.
+func (idx *StreamIndex) deliveryHandler() {
+	defer idx.wg.Done()
+	for {
+		select {
+		case <-idx.quit:
+			return
+		default:
+		}
+
+		events, err := idx.pendingEvents(streamBatchSize)
+		if err != nil {
+			log.Errorf("Unable to load stream events: %v", err)
+			if !idx.wait(streamRetryInterval, false) {
+				return
+			}
+			continue
+		}
+		if len(events) == 0 {
+			if !idx.wait(streamPollInterval, true) {
+				return
+			}
+			continue
+		}
+
+		if err := idx.sink.Send(events); err != nil {
+			log.Warnf("Unable to deliver stream events %s through "+
+				"%s: %v", events[0].ResumeToken(),
+				events[len(events)-1].ResumeToken(), err)
+			if !idx.wait(streamRetryInterval, false) {
+				return
+			}
+			continue
+		}
+		if err := idx.removeEvents(events); err != nil {
+			log.Errorf("Unable to remove delivered stream events: %v",
+				err)
+			if !idx.wait(streamRetryInterval, false) {
+				return
+			}
+		}
+	}
+}
+
+// Start begins delivering the events of the stream to the passed sink in the
+// background.
+func (idx *StreamIndex) Start(sink StreamSink) {
+	// Already started?
+	if atomic.AddInt32(&idx.started, 1) != 1 {
+		return
+	}
+
+	idx.sink = sink
+	idx.wg.Add(1)
+	go idx.deliveryHandler()
+}
+
+// Stop stops delivering events, waits for the delivery in progress to finish
+// and closes the sink.  Undelivered events are delivered after the next start.
+func (idx *StreamIndex) Stop() error {
+	if atomic.AddInt32(&idx.shutdown, 1) != 1 {
+		return nil
+	}
+	close(idx.quit)
+	idx.wg.Wait()
+	if idx.sink == nil {
+		return nil
+	}
+	return idx.sink.Close()
+}
+
+// NewStreamIndex returns a new instance of an indexer that is used to stream
+// the changes to the main chain to an external sink.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewStreamIndex(db database.DB, chainParams *chaincfg.Params) *StreamIndex {
+	return &StreamIndex{
+		db:          db,
+		chainParams: chainParams,
+		notify:      make(chan struct{}, 1),
+		quit:        make(chan struct{}),
+	}
+}
+
+// DropStreamIndex drops the stream index from the provided database if it
+// exists.
+func DropStreamIndex(db database.DB) error {
+	return dropIndex(db, streamIndexKey, streamIndexName)
+}
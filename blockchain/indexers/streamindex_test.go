@@ -0,0 +1,93 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/provautil"
+)
+
+// TestStreamIndexEvents ensures connecting and disconnecting a block appends
+// the expected events to the stream in order and advances the sequence.
+func TestStreamIndexEvents(t *testing.T) {
+	t.Parallel()
+
+	params := &chaincfg.RegressionNetParams
+	block := provautil.NewBlock(params.GenesisBlock)
+	block.SetHeight(0)
+
+	bucket := newTestBucket()
+	bucket.data[string(streamStateKey)] = serializeStreamState(42, 1)
+	meta := newTestBucket()
+	meta.buckets[string(streamIndexKey)] = bucket
+	dbTx := &testTx{meta: meta}
+
+	idx := NewStreamIndex(nil, params)
+	if err := idx.ConnectBlock(dbTx, block, nil); err != nil {
+		t.Fatalf("ConnectBlock: unexpected error: %v", err)
+	}
+	if err := idx.DisconnectBlock(dbTx, block, nil); err != nil {
+		t.Fatalf("DisconnectBlock: unexpected error: %v", err)
+	}
+
+	numOutputs := len(params.GenesisBlock.Transactions[0].TxOut)
+	var want []StreamEventType
+	want = append(want, StreamBlockConnected, StreamTxConnected)
+	for i := 0; i < numOutputs; i++ {
+		want = append(want, StreamUtxoCreated)
+	}
+	want = append(want, StreamBlockDisconnected, StreamTxDisconnected)
+	for i := 0; i < numOutputs; i++ {
+		want = append(want, StreamUtxoRemoved)
+	}
+
+	for i, wantType := range want {
+		sequence := uint64(i + 1)
+		key := streamEventKeyFor(sequence)
+		event := StreamEvent{Epoch: 42}
+		err := deserializeStreamEvent(key, bucket.data[string(key)], &event)
+		if err != nil {
+			t.Fatalf("event #%d: unexpected error: %v", i, err)
+		}
+		if event.Type != wantType {
+			t.Fatalf("event #%d: mismatched type - got %v, want %v",
+				i, event.Type, wantType)
+		}
+
+		var header streamEventHeader
+		if err := json.Unmarshal(event.Payload, &header); err != nil {
+			t.Fatalf("event #%d: unexpected error: %v", i, err)
+		}
+		wantHeader := streamEventHeader{
+			Epoch:     42,
+			Sequence:  sequence,
+			Type:      wantType.String(),
+			Height:    0,
+			BlockHash: block.Hash().String(),
+		}
+		if header != wantHeader {
+			t.Fatalf("event #%d: mismatched header - got %+v, want "+
+				"%+v", i, header, wantHeader)
+		}
+		if event.ResumeToken() != fmt.Sprintf("42-%d", sequence) {
+			t.Fatalf("event #%d: mismatched resume token %s", i,
+				event.ResumeToken())
+		}
+	}
+
+	epoch, nextSequence, err := deserializeStreamState(
+		bucket.data[string(streamStateKey)])
+	if err != nil {
+		t.Fatalf("unexpected state error: %v", err)
+	}
+	if epoch != 42 || nextSequence != uint64(len(want)+1) {
+		t.Fatalf("mismatched state - got epoch %d, next sequence %d",
+			epoch, nextSequence)
+	}
+}
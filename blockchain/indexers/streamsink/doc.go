@@ -0,0 +1,30 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package streamsink implements sinks which deliver the events of the stream
+index to external messaging systems.
+
+The Kafka sink produces every event as a record to a topic with the kafka-go
+client and waits for all in-sync replicas to acknowledge each batch of records.
+The key of each record is the resume token of its event.  Records are assigned
+to the partitions of the topic by the hash of their keys, so the events are only
+ordered within each partition of a topic with several partitions.  Consumers
+which need all events in order must either use a topic with a single partition
+or merge the partitions by the sequence numbers of the events.
+
+The NATS sink publishes every event with the nats.go client to the subject
+formed by the configured subject prefix followed by the type of the event, for
+example prova.blockconnected.  The subjects must be captured by a JetStream
+stream since the sink waits for JetStream to acknowledge each event.  The resume
+token of the event is passed as the message ID, so JetStream discards events
+which are delivered more than once within its duplicate window.
+
+Both sinks are configured with the address of a single broker or server.  The
+Kafka sink discovers the brokers which lead the partitions of the topic through
+it.  The sinks reconnect on the next delivery after any failure and deliver the
+failed events again, so events are delivered at least once.  The tests with the brokers build tag, which
+CI runs against Kafka and NATS servers, check them against real brokers.
+*/
+package streamsink
@@ -0,0 +1,240 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is ignored during the regular tests due to the following build tag.
+// +build brokers
+
+// The tests in this file deliver events to real brokers and read them back
+// with the official clients pinned in glide.yaml.  They are run by a separate
+// CI job and skipped unless the address of the broker is set in the
+// environment, for example:
+//
+//   PROVA_TEST_KAFKA=127.0.0.1:9092 PROVA_TEST_NATS=127.0.0.1:4222 \
+//     go test -tags brokers ./blockchain/indexers/streamsink/
+//
+// The NATS server must have JetStream enabled.
+
+package streamsink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/blockchain/indexers"
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+)
+
+// brokerTimeout is the time the tests wait for the brokers.
+const brokerTimeout = 30 * time.Second
+
+// testBrokerAddr returns the address of the broker in the passed environment
+// variable and skips the test when it is not set.
+func testBrokerAddr(t *testing.T, env string) string {
+	addr := os.Getenv(env)
+	if addr == "" {
+		t.Skipf("%s is not set", env)
+	}
+	return addr
+}
+
+// sendWithRetry sends the passed events, retrying while the broker is still
+// setting up a newly created topic or stream.
+func sendWithRetry(t *testing.T, sink indexers.StreamSink, events []indexers.StreamEvent) {
+	deadline := time.Now().Add(brokerTimeout)
+	for {
+		err := sink.Send(events)
+		if err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Send: unexpected error: %v", err)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// kafkaTestPartitions is the number of partitions of the topics created by the
+// tests.
+const kafkaTestPartitions = 3
+
+// TestKafkaSinkBroker ensures events produced by the Kafka sink to a topic with
+// several partitions are read back by Kafka consumers exactly once with their
+// resume tokens as keys and in order within each partition, also after the
+// sink reconnects.
+func TestKafkaSinkBroker(t *testing.T) {
+	broker := testBrokerAddr(t, "PROVA_TEST_KAFKA")
+	topic := fmt.Sprintf("prova-test-%d", time.Now().UnixNano())
+
+	conn, err := kafka.Dial("tcp", broker)
+	if err != nil {
+		t.Fatalf("unable to connect to %s: %v", broker, err)
+	}
+	err = conn.CreateTopics(kafka.TopicConfig{
+		Topic:             topic,
+		NumPartitions:     kafkaTestPartitions,
+		ReplicationFactor: 1,
+	})
+	conn.Close()
+	if err != nil {
+		t.Fatalf("unable to create topic %s: %v", topic, err)
+	}
+
+	// Deliver enough events to spread them over the partitions in two
+	// batches with a reconnect in between.
+	var events []indexers.StreamEvent
+	for i := 1; i <= 20; i++ {
+		events = append(events, indexers.StreamEvent{
+			Epoch:    7,
+			Sequence: uint64(i),
+			Type:     indexers.StreamTxConnected,
+			Payload:  []byte(fmt.Sprintf(`{"sequence":%d}`, i)),
+		})
+	}
+	sink := NewKafkaSink(broker, topic, brokerTimeout)
+	sendWithRetry(t, sink, events[:5])
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+	sendWithRetry(t, sink, events[5:])
+	defer sink.Close()
+
+	// Read back every partition up to its last offset.
+	ctx, cancel := context.WithTimeout(context.Background(), brokerTimeout)
+	defer cancel()
+	received := make(map[string][]byte)
+	var numUsed int
+	for partition := 0; partition < kafkaTestPartitions; partition++ {
+		leader, err := kafka.DialLeader(ctx, "tcp", broker, topic,
+			partition)
+		if err != nil {
+			t.Fatalf("unable to connect to the leader of partition "+
+				"%d: %v", partition, err)
+		}
+		lastOffset, err := leader.ReadLastOffset()
+		leader.Close()
+		if err != nil {
+			t.Fatalf("ReadLastOffset: unexpected error: %v", err)
+		}
+
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   []string{broker},
+			Topic:     topic,
+			Partition: partition,
+			MaxWait:   time.Second,
+		})
+		if lastOffset > 0 {
+			numUsed++
+		}
+		var lastSequence uint64
+		for offset := int64(0); offset < lastOffset; offset++ {
+			msg, err := reader.ReadMessage(ctx)
+			if err != nil {
+				t.Fatalf("ReadMessage: unexpected error: %v", err)
+			}
+			var epoch, sequence uint64
+			_, err = fmt.Sscanf(string(msg.Key), "%d-%d", &epoch,
+				&sequence)
+			if err != nil || sequence <= lastSequence {
+				t.Errorf("partition %d: record with key %q at "+
+					"offset %d is out of order", partition,
+					msg.Key, msg.Offset)
+			}
+			lastSequence = sequence
+			if _, ok := received[string(msg.Key)]; ok {
+				t.Errorf("partition %d: duplicate record with key "+
+					"%q", partition, msg.Key)
+			}
+			received[string(msg.Key)] = msg.Value
+		}
+		reader.Close()
+	}
+
+	if numUsed < 2 {
+		t.Errorf("records were produced to %d partitions", numUsed)
+	}
+	if len(received) != len(events) {
+		t.Errorf("got %d records, want %d", len(received), len(events))
+	}
+	for i := range events {
+		value, ok := received[events[i].ResumeToken()]
+		if !ok || !bytes.Equal(value, events[i].Payload) {
+			t.Errorf("record with key %q: got value %q, want %q",
+				events[i].ResumeToken(), value, events[i].Payload)
+		}
+	}
+}
+
+// TestNATSSinkBroker ensures events published by the NATS sink are stored by
+// JetStream under the subjects of their types, and that events delivered more
+// than once are discarded as duplicates.
+func TestNATSSinkBroker(t *testing.T) {
+	server := testBrokerAddr(t, "PROVA_TEST_NATS")
+	subject := fmt.Sprintf("prova-test-%d", time.Now().UnixNano())
+	streamName := fmt.Sprintf("PROVATEST%d", time.Now().UnixNano())
+
+	nc, err := nats.Connect("nats://"+server, nats.Timeout(brokerTimeout))
+	if err != nil {
+		t.Fatalf("unable to connect to %s: %v", server, err)
+	}
+	defer nc.Close()
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatalf("JetStream: unexpected error: %v", err)
+	}
+	_, err = js.AddStream(&nats.StreamConfig{
+		Name:     streamName,
+		Subjects: []string{subject + ".>"},
+	})
+	if err != nil {
+		t.Fatalf("unable to create stream %s: %v", streamName, err)
+	}
+	defer js.DeleteStream(streamName)
+
+	// Deliver the events twice with a reconnect in between.
+	events := testStreamEvents()
+	sink := NewNATSSink(server, subject, brokerTimeout)
+	sendWithRetry(t, sink, events)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+	sendWithRetry(t, sink, events)
+	defer sink.Close()
+
+	info, err := js.StreamInfo(streamName)
+	if err != nil {
+		t.Fatalf("StreamInfo: unexpected error: %v", err)
+	}
+	if info.State.Msgs != uint64(len(events)) {
+		t.Errorf("got %d stored messages, want %d", info.State.Msgs,
+			len(events))
+	}
+
+	sub, err := js.SubscribeSync(subject+".>", nats.DeliverAll())
+	if err != nil {
+		t.Fatalf("SubscribeSync: unexpected error: %v", err)
+	}
+	defer sub.Unsubscribe()
+	for i := range events {
+		msg, err := sub.NextMsg(brokerTimeout)
+		if err != nil {
+			t.Fatalf("NextMsg: unexpected error: %v", err)
+		}
+		wantSubject := fmt.Sprintf("%s.%s", subject, events[i].Type)
+		if msg.Subject != wantSubject ||
+			msg.Header.Get("Nats-Msg-Id") != events[i].ResumeToken() ||
+			!bytes.Equal(msg.Data, events[i].Payload) {
+
+			t.Errorf("message %d: got subject %q id %q data %q, "+
+				"want subject %q id %q data %q", i, msg.Subject,
+				msg.Header.Get("Nats-Msg-Id"), msg.Data,
+				wantSubject, events[i].ResumeToken(),
+				events[i].Payload)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package streamsink
+
+import (
+	"context"
+	"time"
+
+	"github.com/bitgo/prova/blockchain/indexers"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// kafkaClientID is the client ID sent with every Kafka request.
+	kafkaClientID = "prova"
+
+	// kafkaBatchTimeout is the time the writer waits for more records
+	// before it produces a batch which is not full.  The sink always hands
+	// the writer all events it delivers at once, so it is kept short.
+	kafkaBatchTimeout = 10 * time.Millisecond
+)
+
+// KafkaSink delivers stream events to a Kafka topic.
+type KafkaSink struct {
+	broker  string
+	topic   string
+	timeout time.Duration
+
+	transport *kafka.Transport
+	writer    *kafka.Writer
+}
+
+// Ensure the KafkaSink type implements the indexers.StreamSink interface.
+var _ indexers.StreamSink = (*KafkaSink)(nil)
+
+// connect creates the writer which produces records to the topic and waits for
+// all in-sync replicas to acknowledge them.  Records are assigned to the
+// partitions of the topic by the hash of their keys.
+func (s *KafkaSink) connect() {
+	s.transport = &kafka.Transport{
+		DialTimeout: s.timeout,
+		ClientID:    kafkaClientID,
+	}
+	s.writer = &kafka.Writer{
+		Addr:         kafka.TCP(s.broker),
+		Topic:        s.topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: kafkaBatchTimeout,
+		ReadTimeout:  s.timeout,
+		WriteTimeout: s.timeout,
+		Transport:    s.transport,
+	}
+}
+
+// Send produces the passed events to the topic.  The key of each record is the
+// resume token of its event.
+//
+// This is part of the indexers.StreamSink interface.
+func (s *KafkaSink) Send(events []indexers.StreamEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if s.writer == nil {
+		s.connect()
+	}
+
+	msgs := make([]kafka.Message, 0, len(events))
+	for i := range events {
+		msgs = append(msgs, kafka.Message{
+			Key:   []byte(events[i].ResumeToken()),
+			Value: events[i].Payload,
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	return s.writer.WriteMessages(ctx, msgs...)
+}
+
+// Close closes the writer along with its connections to the brokers.  The next
+// delivery creates a new writer.
+//
+// This is part of the indexers.StreamSink interface.
+func (s *KafkaSink) Close() error {
+	if s.writer == nil {
+		return nil
+	}
+	err := s.writer.Close()
+	s.transport.CloseIdleConnections()
+	s.writer = nil
+	s.transport = nil
+	return err
+}
+
+// NewKafkaSink returns a new sink which delivers stream events to the passed
+// topic.  The passed broker is only used to discover the brokers which lead
+// the partitions of the topic.
+func NewKafkaSink(broker, topic string, timeout time.Duration) *KafkaSink {
+	return &KafkaSink{
+		broker:  broker,
+		topic:   topic,
+		timeout: timeout,
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package streamsink
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bitgo/prova/blockchain/indexers"
+	"github.com/nats-io/nats.go"
+)
+
+// natsClientName is the name the sink connects to NATS servers with.
+const natsClientName = "prova"
+
+// NATSSink delivers stream events to NATS JetStream.
+type NATSSink struct {
+	server  string
+	subject string
+	timeout time.Duration
+
+	conn *nats.Conn
+	js   nats.JetStreamContext
+}
+
+// Ensure the NATSSink type implements the indexers.StreamSink interface.
+var _ indexers.StreamSink = (*NATSSink)(nil)
+
+// connect connects to the server and sets up the JetStream context used to
+// publish the events.  The client does not reconnect on its own since the sink
+// connects again on the next delivery after any failure.
+func (s *NATSSink) connect() error {
+	conn, err := nats.Connect("nats://"+s.server,
+		nats.Name(natsClientName), nats.Timeout(s.timeout),
+		nats.NoReconnect())
+	if err != nil {
+		return err
+	}
+	js, err := conn.JetStream(nats.MaxWait(s.timeout))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	s.conn = conn
+	s.js = js
+	return nil
+}
+
+// publish publishes the passed events and waits for JetStream to acknowledge
+// each of them.
+func (s *NATSSink) publish(events []indexers.StreamEvent) error {
+	futures := make([]nats.PubAckFuture, 0, len(events))
+	for i := range events {
+		event := &events[i]
+		msg := nats.NewMsg(s.subject + "." + event.Type.String())
+		msg.Data = event.Payload
+		future, err := s.js.PublishMsgAsync(msg,
+			nats.MsgId(event.ResumeToken()))
+		if err != nil {
+			return err
+		}
+		futures = append(futures, future)
+	}
+
+	timeout := time.NewTimer(s.timeout)
+	defer timeout.Stop()
+	for _, future := range futures {
+		select {
+		case <-future.Ok():
+		case err := <-future.Err():
+			return err
+		case <-timeout.C:
+			return errors.New("timeout waiting for jetstream " +
+				"acknowledgments")
+		}
+	}
+	return nil
+}
+
+// Send publishes the passed events to JetStream.
+//
+// This is part of the indexers.StreamSink interface.
+func (s *NATSSink) Send(events []indexers.StreamEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+
+	// Drop the connection on failure since acknowledgments of the failed
+	// batch might still arrive on it.
+	if err := s.publish(events); err != nil {
+		s.Close()
+		return err
+	}
+	return nil
+}
+
+// Close closes the connection to the server.
+//
+// This is part of the indexers.StreamSink interface.
+func (s *NATSSink) Close() error {
+	if s.conn == nil {
+		return nil
+	}
+	s.conn.Close()
+	s.conn = nil
+	s.js = nil
+	return nil
+}
+
+// NewNATSSink returns a new sink which publishes stream events to subjects
+// starting with the passed subject prefix.
+func NewNATSSink(server, subject string, timeout time.Duration) *NATSSink {
+	return &NATSSink{
+		server:  server,
+		subject: subject,
+		timeout: timeout,
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package streamsink
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveNATS runs a minimal NATS server on the passed listener which answers
+// requests, such as the JetStream account lookup of the client, with an empty
+// response and acknowledges published messages with headers with the passed
+// acknowledgment payload.  The subjects and message IDs of the published
+// messages are sent on the returned channel.
+func serveNATS(listener net.Listener, ack string) <-chan string {
+	published := make(chan string, 10)
+	go func() {
+		defer close(published)
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// reply sends the passed payload to the subscription matching
+		// the passed reply subject.
+		subs := make(map[string]string)
+		reply := func(subject, payload string) {
+			for pattern, sid := range subs {
+				prefix := strings.TrimSuffix(pattern, "*")
+				if pattern == subject || (prefix != pattern &&
+					strings.HasPrefix(subject, prefix)) {
+
+					fmt.Fprintf(conn, "MSG %s %s %d\r\n%s\r\n",
+						subject, sid, len(payload),
+						payload)
+					return
+				}
+			}
+		}
+
+		r := bufio.NewReader(conn)
+		io.WriteString(conn, "INFO {\"headers\":true,"+
+			"\"max_payload\":1048576}\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			switch fields[0] {
+			case "SUB":
+				subs[fields[1]] = fields[len(fields)-1]
+			case "PING":
+				io.WriteString(conn, "PONG\r\n")
+			case "PUB":
+				size, _ := strconv.Atoi(fields[len(fields)-1])
+				io.ReadFull(r, make([]byte, size+2))
+				if len(fields) == 4 {
+					reply(fields[2], "{}")
+				}
+			case "HPUB":
+				hdrLen, _ := strconv.Atoi(fields[3])
+				totalLen, _ := strconv.Atoi(fields[4])
+				msg := make([]byte, totalLen+2)
+				io.ReadFull(r, msg)
+				hdr := strings.Split(string(msg[:hdrLen]), "\r\n")
+				published <- fields[1] + " " + hdr[1]
+				reply(fields[2], ack)
+			}
+		}
+	}()
+	return published
+}
+
+// TestNATSSinkSend ensures events are published with their message IDs and
+// that JetStream acknowledgments are checked.
+func TestNATSSinkSend(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		ack     string
+		wantErr bool
+	}{
+		{
+			name: "acknowledged",
+			ack:  `{"stream":"PROVA","seq":1}`,
+		},
+		{
+			name:    "rejected",
+			ack:     `{"error":{"code":503,"description":"unavailable"}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unable to listen: %v", err)
+		}
+		published := serveNATS(listener, test.ack)
+		sink := NewNATSSink(listener.Addr().String(), "prova",
+			time.Second)
+
+		err = sink.Send(testStreamEvents())
+		sink.Close()
+		listener.Close()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+
+		var got []string
+		for msg := range published {
+			got = append(got, msg)
+		}
+		want := []string{
+			"prova.blockconnected Nats-Msg-Id: 7-1",
+			"prova.txconnected Nats-Msg-Id: 7-2",
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("%s: mismatched messages - got %q, want %q",
+				test.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package streamsink
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bitgo/prova/blockchain/indexers"
+)
+
+// defaultTimeout is the time sinks wait for the external system to respond.
+const defaultTimeout = 30 * time.Second
+
+// New returns the sink described by the passed URL.  Supported URLs are
+// kafka://host:port/topic and nats://host:port/subject.
+func New(rawURL string) (indexers.StreamSink, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || name == "" {
+		return nil, fmt.Errorf("stream sink %q must specify a host and "+
+			"a topic or subject", rawURL)
+	}
+
+	switch u.Scheme {
+	case "kafka":
+		return NewKafkaSink(u.Host, name, defaultTimeout), nil
+	case "nats":
+		return NewNATSSink(u.Host, name, defaultTimeout), nil
+	}
+	return nil, fmt.Errorf("unsupported stream sink %q", u.Scheme)
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package streamsink
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/blockchain/indexers"
+)
+
+// testStreamEvents returns events to deliver in the tests.
+func testStreamEvents() []indexers.StreamEvent {
+	return []indexers.StreamEvent{
+		{
+			Epoch:    7,
+			Sequence: 1,
+			Type:     indexers.StreamBlockConnected,
+			Payload:  []byte(`{"sequence":1}`),
+		},
+		{
+			Epoch:    7,
+			Sequence: 2,
+			Type:     indexers.StreamTxConnected,
+			Payload:  []byte(`{"sequence":2}`),
+		},
+	}
+}
+
+// TestNew ensures sinks are created from valid URLs only.
+func TestNew(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: "kafka://localhost:9092/prova"},
+		{url: "nats://localhost:4222/prova"},
+		{url: "nats://localhost:4222", wantErr: true},
+		{url: "amqp://localhost:5672/prova", wantErr: true},
+	}
+	for _, test := range tests {
+		_, err := New(test.url)
+		if (err != nil) != test.wantErr {
+			t.Errorf("New(%q): unexpected error: %v", test.url, err)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+const (
+	// timeIndexName is the human-readable name for the index.
+	timeIndexName = "block time index"
+
+	// timeIndexKeySize is the number of bytes a key in the block time index
+	// consumes.  It consists of 8 bytes timestamp + 4 bytes block height.
+	timeIndexKeySize = 8 + 4
+)
+
+var (
+	// timeIndexKey is the key of the block time index and the db bucket
+	// used to house it.
+	timeIndexKey = []byte("blockbytimeidx")
+)
+
+// -----------------------------------------------------------------------------
+// The block time index consists of an entry for every block in the main chain
+// keyed by the block timestamp followed by the block height.  Both fields are
+// serialized big endian so the entries are ordered by timestamp and then by
+// height, which allows the block closest to a given time to be found with a
+// single cursor seek.
+//
+// Since block timestamps are only loosely ordered, a block may have an earlier
+// timestamp than its parent.  Lookups return the block with the latest
+// timestamp that is at or before the requested time, and when several blocks
+// share that timestamp, the highest of them.
+//
+// The serialized format for the keys and values in the block time index bucket
+// is:
+//
+//   <timestamp><block height> = <block hash>
+//
+//   Field           Type              Size
+//   timestamp       uint64            8 bytes
+//   block height    uint32            4 bytes
+//   block hash      chainhash.Hash    32 bytes
+//   -----
+//   Total: 44 bytes
+// -----------------------------------------------------------------------------
+
+// timeIndexKeyFor returns the key used to store the block time index entry for
+// the block with the passed timestamp and height.
+func timeIndexKeyFor(timestamp int64, height uint32) []byte {
+	key := make([]byte, timeIndexKeySize)
+	binary.BigEndian.PutUint64(key, uint64(timestamp))
+	binary.BigEndian.PutUint32(key[8:], height)
+	return key
+}
+
+// dbFetchBlockByTime uses an existing database transaction to find the block
+// with the latest timestamp at or before the passed time.  When there is no
+// such block, nil is returned for the hash.
+func dbFetchBlockByTime(dbTx database.Tx, timestamp int64) (*chainhash.Hash, uint32, time.Time, error) {
+	// There are no blocks before the epoch.
+	if timestamp < 0 {
+		return nil, 0, time.Time{}, nil
+	}
+
+	// Seek to the first entry after the requested time and step back one
+	// entry to find the latest one at or before it.  When there are no
+	// entries after the requested time, the last entry is the one.
+	cursor := dbTx.Metadata().Bucket(timeIndexKey).Cursor()
+	var ok bool
+	if cursor.Seek(timeIndexKeyFor(timestamp+1, 0)) {
+		ok = cursor.Prev()
+	} else {
+		ok = cursor.Last()
+	}
+	if !ok {
+		return nil, 0, time.Time{}, nil
+	}
+
+	key, value := cursor.Key(), cursor.Value()
+	if len(key) != timeIndexKeySize || len(value) != chainhash.HashSize {
+		return nil, 0, time.Time{}, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt block time index entry",
+		}
+	}
+
+	var hash chainhash.Hash
+	copy(hash[:], value)
+	blockTime := time.Unix(int64(binary.BigEndian.Uint64(key)), 0)
+	height := binary.BigEndian.Uint32(key[8:])
+	return &hash, height, blockTime, nil
+}
+
+// TimeIndex implements a block by timestamp index.  That is to say, it supports
+// finding the block in the main chain closest to a given time.
+type TimeIndex struct {
+	db database.DB
+}
+
+// Ensure the TimeIndex type implements the Indexer interface.
+var _ Indexer = (*TimeIndex)(nil)
+
+// Ensure the TimeIndex type implements the Checker interface.
+var _ Checker = (*TimeIndex)(nil)
+
+// Init is only provided to satisfy the Indexer interface as there is nothing to
+// initialize for this index.
+//
+// This is part of the Indexer interface.
+func (idx *TimeIndex) Init() error {
+	// Nothing to do.
+	return nil
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *TimeIndex) Key() []byte {
+	return timeIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *TimeIndex) Name() string {
+	return timeIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the block time
+// index.
+//
+// This is part of the Indexer interface.
+func (idx *TimeIndex) Create(dbTx database.Tx) error {
+	_, err := dbTx.Metadata().CreateBucket(timeIndexKey)
+	return err
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer adds the timestamp mapping for the
+// block.
+//
+// This is part of the Indexer interface.
+func (idx *TimeIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	timestamp := block.MsgBlock().Header.Timestamp.Unix()
+	key := timeIndexKeyFor(timestamp, block.Height())
+	return dbTx.Metadata().Bucket(timeIndexKey).Put(key, block.Hash()[:])
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the timestamp mapping
+// for the block.
+//
+// This is part of the Indexer interface.
+func (idx *TimeIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	timestamp := block.MsgBlock().Header.Timestamp.Unix()
+	key := timeIndexKeyFor(timestamp, block.Height())
+	return dbTx.Metadata().Bucket(timeIndexKey).Delete(key)
+}
+
+// CheckBlock verifies the entries the index stores for the passed block by
+// comparing them with the entries connecting the block produces.
+//
+// This is part of the Checker interface.
+func (idx *TimeIndex) CheckBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint, repair bool) ([]IndexInconsistency, error) {
+	return checkBlockEntries(dbTx, repair, func(dbTx database.Tx) error {
+		return idx.ConnectBlock(dbTx, block, view)
+	})
+}
+
+// BlockByTime returns the hash, height, and timestamp of the block in the main
+// chain with the latest timestamp at or before the passed time.  When there is
+// no such block, nil will be returned for the hash along with a nil error.
+//
+// This function is safe for concurrent access.
+func (idx *TimeIndex) BlockByTime(t time.Time) (*chainhash.Hash, uint32, time.Time, error) {
+	var hash *chainhash.Hash
+	var height uint32
+	var blockTime time.Time
+	err := idx.db.View(func(dbTx database.Tx) error {
+		var err error
+		hash, height, blockTime, err = dbFetchBlockByTime(dbTx,
+			t.Unix())
+		return err
+	})
+	return hash, height, blockTime, err
+}
+
+// NewTimeIndex returns a new instance of an indexer that is used to create a
+// mapping of the timestamps of all blocks in the main chain to the respective
+// block heights and hashes.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewTimeIndex(db database.DB) *TimeIndex {
+	return &TimeIndex{db: db}
+}
+
+// DropTimeIndex drops the block time index from the provided database if it
+// exists.
+func DropTimeIndex(db database.DB) error {
+	return dropIndex(db, timeIndexKey, timeIndexName)
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestBlockByTime ensures the block time index returns the block with the
+// latest timestamp at or before the requested time.
+func TestBlockByTime(t *testing.T) {
+	t.Parallel()
+
+	dbPath, err := ioutil.TempDir("", "timeindex")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+
+	db, err := database.Create("ffldb", filepath.Join(dbPath, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	defer db.Close()
+
+	// Blocks are intentionally not ordered by timestamp to ensure lookups
+	// handle timestamps which go backwards.
+	blocks := []struct {
+		height    uint32
+		timestamp int64
+	}{
+		{0, 1000},
+		{1, 1100},
+		{2, 1050},
+		{3, 1300},
+		{4, 1300},
+	}
+	err = db.Update(func(dbTx database.Tx) error {
+		idx := NewTimeIndex(db)
+		if err := idx.Create(dbTx); err != nil {
+			return err
+		}
+		bucket := dbTx.Metadata().Bucket(timeIndexKey)
+		for _, b := range blocks {
+			hash := chainhash.Hash{byte(b.height)}
+			key := timeIndexKeyFor(b.timestamp, b.height)
+			if err := bucket.Put(key, hash[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to populate index: %v", err)
+	}
+
+	tests := []struct {
+		timestamp  int64
+		found      bool
+		wantHeight uint32
+	}{
+		{timestamp: 999, found: false},
+		{timestamp: 1000, found: true, wantHeight: 0},
+		{timestamp: 1075, found: true, wantHeight: 2},
+		{timestamp: 1100, found: true, wantHeight: 1},
+		{timestamp: 1299, found: true, wantHeight: 1},
+		{timestamp: 1300, found: true, wantHeight: 4},
+		{timestamp: 5000, found: true, wantHeight: 4},
+	}
+	for i, test := range tests {
+		var hash *chainhash.Hash
+		var height uint32
+		err := db.View(func(dbTx database.Tx) error {
+			var err error
+			hash, height, _, err = dbFetchBlockByTime(dbTx,
+				test.timestamp)
+			return err
+		})
+		if err != nil {
+			t.Errorf("test #%d: unexpected error: %v", i, err)
+			continue
+		}
+		if (hash != nil) != test.found {
+			t.Errorf("test #%d: unexpected found result - got %v, "+
+				"want %v", i, hash != nil, test.found)
+			continue
+		}
+		if test.found && height != test.wantHeight {
+			t.Errorf("test #%d: unexpected height - got %d, want "+
+				"%d", i, height, test.wantHeight)
+		}
+	}
+}
@@ -290,6 +290,9 @@ type TxIndex struct {
 // Ensure the TxIndex type implements the Indexer interface.
 var _ Indexer = (*TxIndex)(nil)
 
+// Ensure the TxIndex type implements the Checker interface.
+var _ Checker = (*TxIndex)(nil)
+
 // Init initializes the hash-based transaction index.  In particular, it finds
 // the highest used block ID and stores it for later use when connecting or
 // disconnecting blocks.
@@ -427,6 +430,32 @@ func (idx *TxIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, vi
 	return nil
 }
 
+// CheckBlock verifies the hash-to-transaction mapping of every transaction in
+// the passed block along with the block ID entries of the block.  A block
+// without a block ID can't be repaired since the ID is assigned when the block
+// is connected.
+//
+// This is part of the Checker interface.
+func (idx *TxIndex) CheckBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint, repair bool) ([]IndexInconsistency, error) {
+	blockID, err := dbFetchBlockIDByHash(dbTx, block.Hash())
+	if err == errNoBlockIDEntry {
+		return []IndexInconsistency{{
+			Key:    block.Hash()[:],
+			Reason: "missing",
+		}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return checkBlockEntries(dbTx, repair, func(dbTx database.Tx) error {
+		if err := dbAddTxIndexEntries(dbTx, block, blockID); err != nil {
+			return err
+		}
+		return dbPutBlockIDIndexEntry(dbTx, block.Hash(), blockID)
+	})
+}
+
 // TxBlockRegion returns the block region for the provided transaction hash
 // from the transaction index.  The block region can in turn be used to load the
 // raw transaction bytes.  When there is no entry for the provided hash, nil
@@ -468,12 +497,16 @@ func dropBlockIDIndex(db database.DB) error {
 }
 
 // DropTxIndex drops the transaction index from the provided database if it
-// exists.  Since the address index relies on it, the address index will also be
-// dropped when it exists.
+// exists.  Since the address and address value indexes rely on it, they will
+// also be dropped when they exist.
 func DropTxIndex(db database.DB) error {
 	if err := dropIndex(db, addrIndexKey, addrIndexName); err != nil {
 		return err
 	}
+	err := dropIndex(db, addrValueIndexKey, addrValueIndexName)
+	if err != nil {
+		return err
+	}
 
 	return dropIndex(db, txIndexKey, txIndexName)
 }
@@ -0,0 +1,1280 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// watchIndexName is the human-readable name for the index.
+	watchIndexName = "watch-only index"
+
+	// watchKeyTypeHistory is the type of a key in the watch-only index
+	// which houses the value a transaction moved to and from a watched
+	// address or key ID.  Balance and unspent output keys use the types of
+	// the address balance index.
+	watchKeyTypeHistory = 'h'
+
+	// watchHistoryKeySize is the number of bytes a history key consumes.
+	// It consists of 1 byte key type + the address or key ID prefix + 4
+	// bytes block height + 4 bytes transaction index within the block.
+	watchHistoryKeySize = 1 + addrValuePrefixSize + 4 + 4
+
+	// watchHistoryEntrySize is the number of bytes a history value
+	// consumes.  It consists of 32 bytes tx hash + 8 bytes received + 8
+	// bytes sent.
+	watchHistoryEntrySize = chainhash.HashSize + 8 + 8
+
+	// watchTargetEntryMinSize is the minimum number of bytes a watched
+	// target value consumes.  It consists of 4 bytes block height + 1 byte
+	// flags followed by the name of the target.
+	watchTargetEntryMinSize = 4 + 1
+
+	// watchTargetFlagSeeded is the flag of a watched target whose unspent
+	// outputs were seeded from the address balance index when it was
+	// registered.
+	watchTargetFlagSeeded = 1 << 0
+
+	// watchTargetFlagRescanning is the flag of a watched target whose
+	// rescan of the blocks already connected to the index has not caught
+	// up yet.  The height of the last rescanned block follows the flags.
+	watchTargetFlagRescanning = 1 << 1
+
+	// watchRescanBatchSize is the number of blocks rescanned per database
+	// transaction.
+	watchRescanBatchSize = 100
+)
+
+var (
+	// watchIndexKey is the key of the watch-only index and the db bucket
+	// used to house it.
+	watchIndexKey = []byte("watchidx")
+
+	// watchTargetsKey is the key of the db bucket which houses the watched
+	// addresses and key IDs.  It is kept apart from the index bucket so the
+	// registrations survive dropping and rebuilding the index at runtime.
+	watchTargetsKey = []byte("watchidxtargets")
+
+	// ErrNotWatched is returned when querying an address or key ID which
+	// is not watched.
+	ErrNotWatched = errors.New("address or key ID is not watched")
+
+	// ErrRescanInterrupted is returned when a rescan is interrupted before
+	// it caught up with the index.  It is resumed by the next rescan.
+	ErrRescanInterrupted = errors.New("rescan interrupted")
+)
+
+// -----------------------------------------------------------------------------
+// The watch-only index tracks the unspent outputs, balance, and history of a
+// set of addresses and key IDs registered by the operator.  It covers the
+// common wallet integration case of following a handful of addresses without
+// indexing every address in the chain or holding any private keys.  Watched
+// addresses and key IDs are identified by the same prefix used by the address
+// value index and are only tracked for the blocks connected after they were
+// registered.  When the address balance index is enabled and caught up, the
+// unspent outputs existing at registration are copied from it.
+//
+// The watched addresses and key IDs are stored in a bucket of their own:
+//
+//   <prefix> = <block height><flags>[<rescan height>]<name>
+//
+//   Field           Type      Size
+//   prefix          [21]byte  21 bytes
+//   block height    uint32    4 bytes
+//   flags           uint8     1 byte
+//   rescan height   uint32    4 bytes (only while rescanning)
+//   name            string    variable
+//
+// The block height is the tip of the index at registration, or the height
+// before the first rescanned block for targets registered with a rescan.
+// Rebuilding the index resets it to zero, so the history is tracked from the
+// genesis block.  While a rescan is in progress, the rescan height is the
+// height of the last rescanned block and the blocks connected after it are
+// left to the rescan.
+//
+// The index bucket houses balance and unspent output entries in the format of
+// the address balance index along with history entries:
+//
+//   'h'<prefix><block height><tx index> = <txhash><received><sent>
+//
+//   Field           Type              Size
+//   prefix          [21]byte          21 bytes
+//   block height    uint32            4 bytes (big endian)
+//   tx index        uint32            4 bytes (big endian)
+//   txhash          chainhash.Hash    32 bytes
+//   received        int64             8 bytes
+//   sent            int64             8 bytes
+// -----------------------------------------------------------------------------
+
+// WatchTarget identifies an address or key ID tracked by the watch-only index.
+type WatchTarget struct {
+	prefix [addrValuePrefixSize]byte
+	name   string
+}
+
+// String returns the address or key ID the target identifies.
+func (t WatchTarget) String() string {
+	return t.name
+}
+
+// NewAddressWatchTarget returns the target identifying the passed address.  An
+// error is returned for unsupported address types.
+func NewAddressWatchTarget(addr provautil.Address) (WatchTarget, error) {
+	prefix, err := addrValueKeyForAddr(addr)
+	if err != nil {
+		return WatchTarget{}, err
+	}
+	return WatchTarget{prefix: prefix, name: addr.EncodeAddress()}, nil
+}
+
+// NewPubKeyWatchTarget returns the target identifying the addresses whose
+// public key hash is the hash of the passed serialized public key.  Like the
+// target of such an address, it matches the address regardless of the key IDs
+// it references.
+func NewPubKeyWatchTarget(serializedPubKey []byte) WatchTarget {
+	var prefix [addrValuePrefixSize]byte
+	prefix[0] = addrValueKeyTypeAddress
+	copy(prefix[1:], provautil.Hash160(serializedPubKey))
+	return WatchTarget{
+		prefix: prefix,
+		name:   hex.EncodeToString(serializedPubKey),
+	}
+}
+
+// NewKeyIDWatchTarget returns the target identifying all addresses referencing
+// the passed key ID.
+func NewKeyIDWatchTarget(keyID btcec.KeyID) WatchTarget {
+	return WatchTarget{
+		prefix: addrValueKeyForKeyID(keyID),
+		name:   strconv.FormatUint(uint64(keyID), 10),
+	}
+}
+
+// WatchInfo describes a watched address or key ID.
+type WatchInfo struct {
+	// Target identifies the watched address or key ID.
+	Target WatchTarget
+
+	// Height is the height of the block after which the target is
+	// tracked.
+	Height uint32
+
+	// Seeded is whether or not the unspent outputs existing at
+	// registration were copied from the address balance index.
+	Seeded bool
+
+	// Rescanning is whether or not the rescan of the blocks connected
+	// before registration is still in progress.
+	Rescanning bool
+
+	// RescanHeight is the height of the last rescanned block while
+	// rescanning.
+	RescanHeight uint32
+
+	// Balance is the current balance of the target.
+	Balance AddrBalance
+}
+
+// WatchHistoryEntry describes the value a transaction moved to and from a
+// watched address or key ID.
+type WatchHistoryEntry struct {
+	// TxHash is the hash of the transaction.
+	TxHash chainhash.Hash
+
+	// Height is the height of the block that contains the transaction.
+	Height uint32
+
+	// Received is the total value of the transaction outputs which pay
+	// to the target.
+	Received int64
+
+	// Sent is the total value of the tracked outputs of the target which
+	// are spent by the transaction.
+	Sent int64
+}
+
+// WatchActivity describes a transaction involving a watched address or key ID
+// which has been connected to the main chain or accepted to the memory pool.
+type WatchActivity struct {
+	// Target identifies the watched address or key ID.
+	Target WatchTarget
+
+	// TxHash is the hash of the transaction.
+	TxHash chainhash.Hash
+
+	// TxIndex is the index of the transaction within its block.  It is -1
+	// for transactions in the memory pool.
+	TxIndex int
+
+	// Received is the total value of the transaction outputs which pay
+	// to the target.
+	Received int64
+
+	// Sent is the total value of the tracked outputs of the target which
+	// are spent by the transaction.
+	Sent int64
+}
+
+// watchTargetEntry houses a watched target as stored in the targets bucket.
+type watchTargetEntry struct {
+	height     uint32
+	seeded     bool
+	rescanning bool
+	rescanned  uint32
+	name       string
+}
+
+// tracks returns whether or not the target is tracked in the block at the
+// passed height.
+func (e *watchTargetEntry) tracks(height uint32) bool {
+	return height > e.height && (!e.rescanning || height <= e.rescanned)
+}
+
+// serializeWatchTarget serializes the passed target entry according to the
+// format described in detail above.
+func serializeWatchTarget(entry *watchTargetEntry) []byte {
+	offset := watchTargetEntryMinSize
+	if entry.rescanning {
+		offset += 4
+	}
+	serialized := make([]byte, offset+len(entry.name))
+	byteOrder.PutUint32(serialized, entry.height)
+	if entry.seeded {
+		serialized[4] |= watchTargetFlagSeeded
+	}
+	if entry.rescanning {
+		serialized[4] |= watchTargetFlagRescanning
+		byteOrder.PutUint32(serialized[watchTargetEntryMinSize:],
+			entry.rescanned)
+	}
+	copy(serialized[offset:], entry.name)
+	return serialized
+}
+
+// deserializeWatchTarget decodes the passed serialized target entry into the
+// passed entry.
+func deserializeWatchTarget(serialized []byte, entry *watchTargetEntry) error {
+	if len(serialized) < watchTargetEntryMinSize {
+		return errDeserialize("unexpected end of watched target data")
+	}
+	entry.height = byteOrder.Uint32(serialized)
+	entry.seeded = serialized[4]&watchTargetFlagSeeded != 0
+	entry.rescanning = serialized[4]&watchTargetFlagRescanning != 0
+	offset := watchTargetEntryMinSize
+	if entry.rescanning {
+		if len(serialized) < offset+4 {
+			return errDeserialize("unexpected end of watched " +
+				"target data")
+		}
+		entry.rescanned = byteOrder.Uint32(serialized[offset:])
+		offset += 4
+	}
+	entry.name = string(serialized[offset:])
+	return nil
+}
+
+// watchHistoryKeyFor returns the key used to store the history entry of the
+// transaction at the passed height and index for the passed prefix.
+func watchHistoryKeyFor(prefix [addrValuePrefixSize]byte, height uint32, txIdx int) []byte {
+	key := make([]byte, watchHistoryKeySize)
+	key[0] = watchKeyTypeHistory
+	copy(key[1:], prefix[:])
+	binary.BigEndian.PutUint32(key[1+addrValuePrefixSize:], height)
+	binary.BigEndian.PutUint32(key[1+addrValuePrefixSize+4:], uint32(txIdx))
+	return key
+}
+
+// serializeWatchHistory serializes the passed history entry according to the
+// format described in detail above.
+func serializeWatchHistory(entry *WatchHistoryEntry) []byte {
+	serialized := make([]byte, watchHistoryEntrySize)
+	copy(serialized, entry.TxHash[:])
+	byteOrder.PutUint64(serialized[32:], uint64(entry.Received))
+	byteOrder.PutUint64(serialized[40:], uint64(entry.Sent))
+	return serialized
+}
+
+// deserializeWatchHistory decodes the passed key and serialized value into the
+// passed history entry.  The index of the transaction within its block is
+// returned.
+func deserializeWatchHistory(key, serialized []byte, entry *WatchHistoryEntry) (int, error) {
+	if len(key) != watchHistoryKeySize {
+		return 0, errDeserialize("unexpected watch history key length")
+	}
+	if len(serialized) != watchHistoryEntrySize {
+		return 0, errDeserialize("unexpected watch history entry length")
+	}
+
+	copy(entry.TxHash[:], serialized)
+	entry.Height = binary.BigEndian.Uint32(key[1+addrValuePrefixSize:])
+	entry.Received = int64(byteOrder.Uint64(serialized[32:]))
+	entry.Sent = int64(byteOrder.Uint64(serialized[40:]))
+	txIdx := binary.BigEndian.Uint32(key[1+addrValuePrefixSize+4:])
+	return int(txIdx), nil
+}
+
+// watchCorruptionError converts the passed deserialization error into a database
+// corruption error describing the passed kind of entry.
+func watchCorruptionError(kind string, err error) error {
+	if !isDeserializeErr(err) {
+		return err
+	}
+	return database.Error{
+		ErrorCode: database.ErrCorruption,
+		Description: "failed to deserialize " + kind + " entry: " +
+			err.Error(),
+	}
+}
+
+// dbFetchWatchTargets uses an existing database transaction to retrieve all of
+// the watched targets keyed by their prefix.
+func dbFetchWatchTargets(dbTx database.Tx) (map[[addrValuePrefixSize]byte]*watchTargetEntry, error) {
+	targets := make(map[[addrValuePrefixSize]byte]*watchTargetEntry)
+	cursor := dbTx.Metadata().Bucket(watchTargetsKey).Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		var prefix [addrValuePrefixSize]byte
+		if len(cursor.Key()) != addrValuePrefixSize {
+			return nil, watchCorruptionError("watched target",
+				errDeserialize("unexpected watched target key "+
+					"length"))
+		}
+		copy(prefix[:], cursor.Key())
+
+		var entry watchTargetEntry
+		err := deserializeWatchTarget(cursor.Value(), &entry)
+		if err != nil {
+			return nil, watchCorruptionError("watched target", err)
+		}
+		targets[prefix] = &entry
+	}
+	return targets, nil
+}
+
+// deleteKeysWithPrefix removes all keys starting with the passed prefix from
+// the passed bucket.
+func deleteKeysWithPrefix(bucket database.Bucket, keyPrefix []byte) error {
+	var keys [][]byte
+	cursor := bucket.Cursor()
+	for ok := cursor.Seek(keyPrefix); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		if !bytes.HasPrefix(key, keyPrefix) {
+			break
+		}
+		keys = append(keys, append([]byte(nil), key...))
+	}
+	for _, key := range keys {
+		if err := bucket.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WatchIndex implements an index of the unspent outputs, balances, and history
+// of a set of watched addresses and key IDs.  That is to say, it supports
+// following the funds of given addresses and key IDs without a wallet.
+type WatchIndex struct {
+	db          database.DB
+	chainParams *chaincfg.Params
+
+	// rescanMtx prevents concurrent rescans from processing the same
+	// blocks for a target twice.
+	rescanMtx sync.Mutex
+}
+
+// Ensure the WatchIndex type implements the Indexer interface.
+var _ Indexer = (*WatchIndex)(nil)
+
+// Ensure the WatchIndex type implements the NeedsInputser interface.
+var _ NeedsInputser = (*WatchIndex)(nil)
+
+// NeedsInputs signals that the index requires the referenced inputs in order
+// to properly create the index.
+//
+// This implements the NeedsInputser interface.
+func (idx *WatchIndex) NeedsInputs() bool {
+	return true
+}
+
+// Init resets the registration height of the watched targets when the index is
+// about to be built from scratch, so the targets are tracked from the genesis
+// block.
+//
+// This is part of the Indexer interface.
+func (idx *WatchIndex) Init() error {
+	return idx.db.Update(func(dbTx database.Tx) error {
+		_, height, err := dbFetchIndexerTip(dbTx, watchIndexKey)
+		if err != nil {
+			return err
+		}
+		if height != -1 {
+			return nil
+		}
+
+		targets, err := dbFetchWatchTargets(dbTx)
+		if err != nil {
+			return err
+		}
+		bucket := dbTx.Metadata().Bucket(watchTargetsKey)
+		for prefix, entry := range targets {
+			entry.height = 0
+			entry.seeded = false
+			entry.rescanning = false
+			err := bucket.Put(prefix[:], serializeWatchTarget(entry))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Key returns the database key to use for the index as a byte slice.
+//
+// This is part of the Indexer interface.
+func (idx *WatchIndex) Key() []byte {
+	return watchIndexKey
+}
+
+// Name returns the human-readable name of the index.
+//
+// This is part of the Indexer interface.
+func (idx *WatchIndex) Name() string {
+	return watchIndexName
+}
+
+// Create is invoked when the indexer manager determines the index needs
+// to be created for the first time.  It creates the bucket for the watch-only
+// index and, unless it survived a previous drop, the bucket for the watched
+// targets.
+//
+// This is part of the Indexer interface.
+func (idx *WatchIndex) Create(dbTx database.Tx) error {
+	meta := dbTx.Metadata()
+	if _, err := meta.CreateBucket(watchIndexKey); err != nil {
+		return err
+	}
+	_, err := meta.CreateBucketIfNotExists(watchTargetsKey)
+	return err
+}
+
+// connectTargets removes the tracked outputs of the passed targets spent by the
+// transactions in the passed block, adds the outputs paying to them, and
+// records the value each transaction moved.  The spent outputs are looked up in
+// the passed view or, when it is nil, among the tracked outputs of the targets,
+// which is how blocks are rescanned.
+func (idx *WatchIndex) connectTargets(bucket database.Bucket, block *provautil.Block, view *blockchain.UtxoViewpoint, targets map[[addrValuePrefixSize]byte]*watchTargetEntry) error {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	height := block.Height()
+	balances := make(addrBalanceIndexData)
+	for txIdx, tx := range block.Transactions() {
+		deltas := make(map[[addrValuePrefixSize]byte]*addrValueDelta)
+		delta := func(prefix [addrValuePrefixSize]byte) *addrValueDelta {
+			d := deltas[prefix]
+			if d == nil {
+				d = &addrValueDelta{}
+				deltas[prefix] = d
+			}
+			return d
+		}
+		spend := func(prefix [addrValuePrefixSize]byte, key []byte, value int64) error {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+			balances.add(prefix, -value, -1)
+			delta(prefix).sent += value
+			return nil
+		}
+
+		// Coinbases do not reference any inputs.  Outputs which are not
+		// tracked, because they predate the registration of the
+		// target, are skipped.
+		if txIdx != 0 {
+			for _, txIn := range tx.MsgTx().TxIn {
+				if view == nil {
+					for prefix := range targets {
+						key := addrUtxoKeyFor(prefix,
+							&txIn.PreviousOutPoint)
+						serialized := bucket.Get(key)
+						if serialized == nil {
+							continue
+						}
+						var utxo AddrUtxo
+						err := deserializeAddrUtxo(key,
+							serialized, &utxo)
+						if err != nil {
+							return watchCorruptionError(
+								"address utxo", err)
+						}
+						err = spend(prefix, key, utxo.Value)
+						if err != nil {
+							return err
+						}
+					}
+					continue
+				}
+
+				utxo := spentUtxo(view, txIn)
+				if utxo == nil {
+					continue
+				}
+				for _, prefix := range scriptPrefixes(utxo.PkScript, idx.chainParams) {
+					if targets[prefix] == nil {
+						continue
+					}
+					key := addrUtxoKeyFor(prefix, &utxo.OutPoint)
+					if bucket.Get(key) == nil {
+						continue
+					}
+					if err := spend(prefix, key, utxo.Value); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			utxo := AddrUtxo{
+				OutPoint: wire.OutPoint{
+					Hash:  *tx.Hash(),
+					Index: uint32(txOutIdx),
+				},
+				Value:    txOut.Value,
+				Height:   height,
+				PkScript: txOut.PkScript,
+			}
+			for _, prefix := range scriptPrefixes(txOut.PkScript, idx.chainParams) {
+				if targets[prefix] == nil {
+					continue
+				}
+				key := addrUtxoKeyFor(prefix, &utxo.OutPoint)
+				err := bucket.Put(key, serializeAddrUtxo(&utxo))
+				if err != nil {
+					return err
+				}
+				balances.add(prefix, utxo.Value, 1)
+				delta(prefix).received += utxo.Value
+			}
+		}
+
+		for prefix, d := range deltas {
+			entry := WatchHistoryEntry{
+				TxHash:   *tx.Hash(),
+				Received: d.received,
+				Sent:     d.sent,
+			}
+			key := watchHistoryKeyFor(prefix, height, txIdx)
+			err := bucket.Put(key, serializeWatchHistory(&entry))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return applyAddrBalanceDeltas(bucket, balances)
+}
+
+// ConnectBlock is invoked by the index manager when a new block has been
+// connected to the main chain.  This indexer removes the tracked outputs of the
+// watched targets spent by the transactions in the block, adds the outputs
+// paying to them, and records the value each transaction moved.
+//
+// This is part of the Indexer interface.
+func (idx *WatchIndex) ConnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	targets, err := dbFetchWatchTargets(dbTx)
+	if err != nil || len(targets) == 0 {
+		return err
+	}
+
+	// The rescans which have caught up with the previous block are
+	// finished, so the block is tracked for their targets right away.
+	height := block.Height()
+	targetsBucket := dbTx.Metadata().Bucket(watchTargetsKey)
+	tracked := make(map[[addrValuePrefixSize]byte]*watchTargetEntry)
+	for prefix, target := range targets {
+		if target.rescanning && target.rescanned == height-1 {
+			target.rescanning = false
+			err := targetsBucket.Put(prefix[:],
+				serializeWatchTarget(target))
+			if err != nil {
+				return err
+			}
+		}
+		if target.tracks(height) {
+			tracked[prefix] = target
+		}
+	}
+
+	bucket := dbTx.Metadata().Bucket(watchIndexKey)
+	return idx.connectTargets(bucket, block, view, tracked)
+}
+
+// DisconnectBlock is invoked by the index manager when a block has been
+// disconnected from the main chain.  This indexer removes the outputs paying to
+// the watched targets created by the transactions in the block, restores the
+// tracked outputs they spent, and removes the history entries of the block.
+//
+// This is part of the Indexer interface.
+func (idx *WatchIndex) DisconnectBlock(dbTx database.Tx, block *provautil.Block, view *blockchain.UtxoViewpoint) error {
+	targets, err := dbFetchWatchTargets(dbTx)
+	if err != nil || len(targets) == 0 {
+		return err
+	}
+
+	// Undo the transactions in reverse order so outputs created and spent
+	// within the block are handled properly.
+	bucket := dbTx.Metadata().Bucket(watchIndexKey)
+	height := block.Height()
+	balances := make(addrBalanceIndexData)
+	transactions := block.Transactions()
+	for txIdx := len(transactions) - 1; txIdx >= 0; txIdx-- {
+		tx := transactions[txIdx]
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			outPoint := wire.OutPoint{
+				Hash:  *tx.Hash(),
+				Index: uint32(txOutIdx),
+			}
+			for _, prefix := range scriptPrefixes(txOut.PkScript, idx.chainParams) {
+				target := targets[prefix]
+				if target == nil || !target.tracks(height) {
+					continue
+				}
+				key := addrUtxoKeyFor(prefix, &outPoint)
+				if bucket.Get(key) == nil {
+					continue
+				}
+				if err := bucket.Delete(key); err != nil {
+					return err
+				}
+				balances.add(prefix, -txOut.Value, -1)
+			}
+		}
+
+		if txIdx == 0 {
+			continue
+		}
+
+		// Spent outputs are only restored when they were tracked, which
+		// is the case when they were seeded at registration or created
+		// after it.
+		for _, txIn := range tx.MsgTx().TxIn {
+			utxo := spentUtxo(view, txIn)
+			if utxo == nil {
+				continue
+			}
+			for _, prefix := range scriptPrefixes(utxo.PkScript, idx.chainParams) {
+				target := targets[prefix]
+				if target == nil || !target.tracks(height) ||
+					(!target.seeded && !target.tracks(utxo.Height)) {
+					continue
+				}
+				key := addrUtxoKeyFor(prefix, &utxo.OutPoint)
+				err := bucket.Put(key, serializeAddrUtxo(utxo))
+				if err != nil {
+					return err
+				}
+				balances.add(prefix, utxo.Value, 1)
+			}
+		}
+	}
+
+	targetsBucket := dbTx.Metadata().Bucket(watchTargetsKey)
+	for prefix, target := range targets {
+		if !target.tracks(height) {
+			continue
+		}
+		keyPrefix := watchHistoryKeyFor(prefix, height, 0)[:1+addrValuePrefixSize+4]
+		if err := deleteKeysWithPrefix(bucket, keyPrefix); err != nil {
+			return err
+		}
+
+		// A rescan which reached the disconnected block has caught up
+		// with the index now.
+		if target.rescanning {
+			target.rescanning = false
+			err := targetsBucket.Put(prefix[:],
+				serializeWatchTarget(target))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return applyAddrBalanceDeltas(bucket, balances)
+}
+
+// balanceIndexSeedable returns whether or not the address balance index exists
+// and is at the passed tip, in which case its unspent outputs can be copied to
+// newly watched targets.
+func balanceIndexSeedable(dbTx database.Tx, tipHash *chainhash.Hash) bool {
+	meta := dbTx.Metadata()
+	indexesBucket := meta.Bucket(indexTipsBucketName)
+	if meta.Bucket(addrBalanceIndexKey) == nil ||
+		indexesBucket.Get(indexDropKey(addrBalanceIndexKey)) != nil ||
+		indexesBucket.Get(addrBalanceIndexKey) == nil {
+
+		return false
+	}
+	hash, _, err := dbFetchIndexerTip(dbTx, addrBalanceIndexKey)
+	return err == nil && hash.IsEqual(tipHash)
+}
+
+// Watch registers the passed addresses and key IDs so they are tracked from the
+// next block connected to the main chain.  When the address balance index is
+// enabled and at the same tip as this index, their current unspent outputs are
+// copied from it.  Targets which are already watched are left untouched.  The
+// number of newly watched targets is returned.
+//
+// This function is safe for concurrent access.
+func (idx *WatchIndex) Watch(targets []WatchTarget) (int, error) {
+	var numAdded int
+	err := idx.db.Update(func(dbTx database.Tx) error {
+		tipHash, tipHeight, err := dbFetchIndexerTip(dbTx, watchIndexKey)
+		if err != nil {
+			return err
+		}
+		var height uint32
+		if tipHeight > 0 {
+			height = uint32(tipHeight)
+		}
+		seed := balanceIndexSeedable(dbTx, tipHash)
+
+		meta := dbTx.Metadata()
+		targetsBucket := meta.Bucket(watchTargetsKey)
+		bucket := meta.Bucket(watchIndexKey)
+		balances := make(addrBalanceIndexData)
+		for _, target := range targets {
+			if targetsBucket.Get(target.prefix[:]) != nil {
+				continue
+			}
+			entry := watchTargetEntry{
+				height: height,
+				seeded: seed,
+				name:   target.name,
+			}
+			err := targetsBucket.Put(target.prefix[:],
+				serializeWatchTarget(&entry))
+			if err != nil {
+				return err
+			}
+			numAdded++
+
+			if !seed {
+				continue
+			}
+			utxos, err := dbFetchAddrUtxos(dbTx, target.prefix)
+			if err != nil {
+				return err
+			}
+			for i := range utxos {
+				utxo := &utxos[i]
+				key := addrUtxoKeyFor(target.prefix, &utxo.OutPoint)
+				err := bucket.Put(key, serializeAddrUtxo(utxo))
+				if err != nil {
+					return err
+				}
+				balances.add(target.prefix, utxo.Value, 1)
+			}
+		}
+
+		return applyAddrBalanceDeltas(bucket, balances)
+	})
+	return numAdded, err
+}
+
+// Unwatch removes the passed addresses and key IDs along with their tracked
+// unspent outputs, balances, and history.  The number of targets which were
+// watched is returned.
+//
+// This function is safe for concurrent access.
+func (idx *WatchIndex) Unwatch(targets []WatchTarget) (int, error) {
+	var numRemoved int
+	err := idx.db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		targetsBucket := meta.Bucket(watchTargetsKey)
+		bucket := meta.Bucket(watchIndexKey)
+		for _, target := range targets {
+			if targetsBucket.Get(target.prefix[:]) == nil {
+				continue
+			}
+			if err := targetsBucket.Delete(target.prefix[:]); err != nil {
+				return err
+			}
+			numRemoved++
+
+			if err := deleteWatchData(bucket, target.prefix); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return numRemoved, err
+}
+
+// WatchFromHeight registers the passed addresses and key IDs so they are
+// tracked from the block at the passed height.  The blocks already connected to
+// the index are left to Rescan, which is expected to be invoked afterwards.
+// Targets which are already watched are reset, so their unspent outputs,
+// balances, and history are rebuilt by the rescan.  The number of newly watched
+// targets is returned.
+//
+// This function is safe for concurrent access.
+func (idx *WatchIndex) WatchFromHeight(targets []WatchTarget, startHeight uint32) (int, error) {
+	var numAdded int
+	err := idx.db.Update(func(dbTx database.Tx) error {
+		_, tipHeight, err := dbFetchIndexerTip(dbTx, watchIndexKey)
+		if err != nil {
+			return err
+		}
+
+		// The outputs of the genesis block are not spendable, so it is
+		// never rescanned.
+		var entry watchTargetEntry
+		if startHeight > 0 {
+			entry.height = startHeight - 1
+		}
+		if int64(entry.height) < int64(tipHeight) {
+			entry.rescanning = true
+			entry.rescanned = entry.height
+		}
+
+		meta := dbTx.Metadata()
+		targetsBucket := meta.Bucket(watchTargetsKey)
+		bucket := meta.Bucket(watchIndexKey)
+		for _, target := range targets {
+			if targetsBucket.Get(target.prefix[:]) == nil {
+				numAdded++
+			} else {
+				err := deleteWatchData(bucket, target.prefix)
+				if err != nil {
+					return err
+				}
+			}
+
+			entry.name = target.name
+			err := targetsBucket.Put(target.prefix[:],
+				serializeWatchTarget(&entry))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return numAdded, err
+}
+
+// rescanBatch rescans the next batch of blocks for the targets whose rescan has
+// not caught up with the index yet.  It returns whether or not there were no
+// such targets left.
+func (idx *WatchIndex) rescanBatch(dbTx database.Tx) (bool, error) {
+	_, tipHeight, err := dbFetchIndexerTip(dbTx, watchIndexKey)
+	if err != nil {
+		return false, err
+	}
+	targets, err := dbFetchWatchTargets(dbTx)
+	if err != nil {
+		return false, err
+	}
+
+	// Rescans might have made different progress, so the batch starts
+	// with the block after the one which was rescanned last by the rescan
+	// which is the furthest behind.
+	pending := make(map[[addrValuePrefixSize]byte]*watchTargetEntry)
+	next := uint32(math.MaxUint32)
+	for prefix, target := range targets {
+		if !target.rescanning {
+			continue
+		}
+		pending[prefix] = target
+		if target.rescanned+1 < next {
+			next = target.rescanned + 1
+		}
+	}
+	if len(pending) == 0 {
+		return true, nil
+	}
+
+	var tip uint32
+	if tipHeight > 0 {
+		tip = uint32(tipHeight)
+	}
+	end := tip
+	if next <= tip && tip-next >= watchRescanBatchSize {
+		end = next + watchRescanBatchSize - 1
+	}
+
+	meta := dbTx.Metadata()
+	bucket := meta.Bucket(watchIndexKey)
+	for height := next; height <= end; height++ {
+		block, err := blockchain.DBFetchBlockByHeight(dbTx, height)
+		if err != nil {
+			return false, err
+		}
+		active := make(map[[addrValuePrefixSize]byte]*watchTargetEntry)
+		for prefix, target := range pending {
+			if target.rescanned == height-1 {
+				active[prefix] = target
+			}
+		}
+		if err := idx.connectTargets(bucket, block, nil, active); err != nil {
+			return false, err
+		}
+		for _, target := range active {
+			target.rescanned = height
+		}
+	}
+	log.Debugf("Rescanned blocks %d-%d for %d watched addresses and key "+
+		"IDs", next, end, len(pending))
+
+	targetsBucket := meta.Bucket(watchTargetsKey)
+	for prefix, target := range pending {
+		if target.rescanned >= tip {
+			target.rescanning = false
+		}
+		err := targetsBucket.Put(prefix[:], serializeWatchTarget(target))
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// Rescan processes the blocks connected to the index before the targets
+// registered with WatchFromHeight were watched, including the blocks left by
+// interrupted rescans, until the rescans have caught up with the index.  The
+// blocks are processed in batches, so new blocks are connected in between.
+// ErrRescanInterrupted is returned when the passed channel is closed first.
+//
+// This function is safe for concurrent access.
+func (idx *WatchIndex) Rescan(quit <-chan struct{}) error {
+	idx.rescanMtx.Lock()
+	defer idx.rescanMtx.Unlock()
+
+	for {
+		select {
+		case <-quit:
+			return ErrRescanInterrupted
+		default:
+		}
+
+		var done bool
+		err := idx.db.Update(func(dbTx database.Tx) error {
+			var err error
+			done, err = idx.rescanBatch(dbTx)
+			return err
+		})
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// deleteWatchData removes the unspent outputs, balance, and history tracked for
+// the passed address or key ID prefix.
+func deleteWatchData(bucket database.Bucket, prefix [addrValuePrefixSize]byte) error {
+	for _, keyType := range []byte{addrBalanceKeyTypeBalance,
+		addrBalanceKeyTypeUtxo, watchKeyTypeHistory} {
+
+		keyPrefix := append([]byte{keyType}, prefix[:]...)
+		if err := deleteKeysWithPrefix(bucket, keyPrefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Watched returns the watched addresses and key IDs along with their balances
+// ordered by name.
+//
+// This function is safe for concurrent access.
+func (idx *WatchIndex) Watched() ([]WatchInfo, error) {
+	var infos []WatchInfo
+	err := idx.db.View(func(dbTx database.Tx) error {
+		targets, err := dbFetchWatchTargets(dbTx)
+		if err != nil {
+			return err
+		}
+		bucket := dbTx.Metadata().Bucket(watchIndexKey)
+		for prefix, entry := range targets {
+			balance, err := fetchAddrBalance(bucket, prefix)
+			if err != nil {
+				return err
+			}
+			info := WatchInfo{
+				Target:     WatchTarget{prefix: prefix, name: entry.name},
+				Height:     entry.height,
+				Seeded:     entry.seeded,
+				Rescanning: entry.rescanning,
+				Balance:    balance,
+			}
+			if entry.rescanning {
+				info.RescanHeight = entry.rescanned
+			}
+			infos = append(infos, info)
+		}
+		return nil
+	})
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Target.name < infos[j].Target.name
+	})
+	return infos, err
+}
+
+// view runs the passed function within a read-only database transaction after
+// ensuring the passed target is watched.  ErrNotWatched is returned otherwise.
+func (idx *WatchIndex) view(target WatchTarget, fn func(bucket database.Bucket) error) error {
+	return idx.db.View(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if meta.Bucket(watchTargetsKey).Get(target.prefix[:]) == nil {
+			return ErrNotWatched
+		}
+		return fn(meta.Bucket(watchIndexKey))
+	})
+}
+
+// Balance returns the current balance of the passed watched target.
+//
+// This function is safe for concurrent access.
+func (idx *WatchIndex) Balance(target WatchTarget) (AddrBalance, error) {
+	var balance AddrBalance
+	err := idx.view(target, func(bucket database.Bucket) error {
+		var err error
+		balance, err = fetchAddrBalance(bucket, target.prefix)
+		return err
+	})
+	return balance, err
+}
+
+// Utxos returns the tracked unspent outputs of the passed watched target.
+//
+// This function is safe for concurrent access.
+func (idx *WatchIndex) Utxos(target WatchTarget) ([]AddrUtxo, error) {
+	var utxos []AddrUtxo
+	err := idx.view(target, func(bucket database.Bucket) error {
+		var err error
+		utxos, err = fetchAddrUtxos(bucket, target.prefix)
+		return err
+	})
+	return utxos, err
+}
+
+// History returns the entries for the transactions that involve the passed
+// watched target along with the total number of entries available for it.  The
+// number of entries to skip and the maximum number to return are controlled by
+// the numToSkip and numRequested parameters, and the reverse flag requests the
+// entries ordered from newest to oldest.
+//
+// This function is safe for concurrent access.
+func (idx *WatchIndex) History(target WatchTarget, numToSkip, numRequested uint32, reverse bool) ([]WatchHistoryEntry, uint32, error) {
+	var entries []WatchHistoryEntry
+	var total uint32
+	err := idx.view(target, func(bucket database.Bucket) error {
+		keyPrefix := append([]byte{watchKeyTypeHistory},
+			target.prefix[:]...)
+		var all []WatchHistoryEntry
+		cursor := bucket.Cursor()
+		for ok := cursor.Seek(keyPrefix); ok; ok = cursor.Next() {
+			if !bytes.HasPrefix(cursor.Key(), keyPrefix) {
+				break
+			}
+			var entry WatchHistoryEntry
+			_, err := deserializeWatchHistory(cursor.Key(),
+				cursor.Value(), &entry)
+			if err != nil {
+				return watchCorruptionError("watch history", err)
+			}
+			all = append(all, entry)
+		}
+
+		total = uint32(len(all))
+		if numToSkip >= total {
+			return nil
+		}
+		if reverse {
+			for i, j := 0, len(all)-1; i < j; i, j = i+1, j-1 {
+				all[i], all[j] = all[j], all[i]
+			}
+		}
+		end := total
+		if numRequested < total-numToSkip {
+			end = numToSkip + numRequested
+		}
+		entries = all[numToSkip:end]
+		return nil
+	})
+	return entries, total, err
+}
+
+// BlockActivity returns the activity of the watched targets in the passed block
+// as recorded when it was connected to the main chain.  Nothing is returned
+// for a block which is no longer part of the main chain.
+//
+// This function is safe for concurrent access.
+func (idx *WatchIndex) BlockActivity(block *provautil.Block) ([]WatchActivity, error) {
+	var activity []WatchActivity
+	err := idx.db.View(func(dbTx database.Tx) error {
+		targets, err := dbFetchWatchTargets(dbTx)
+		if err != nil {
+			return err
+		}
+
+		bucket := dbTx.Metadata().Bucket(watchIndexKey)
+		transactions := block.Transactions()
+		for prefix, target := range targets {
+			keyPrefix := watchHistoryKeyFor(prefix, block.Height(),
+				0)[:1+addrValuePrefixSize+4]
+			cursor := bucket.Cursor()
+			for ok := cursor.Seek(keyPrefix); ok; ok = cursor.Next() {
+				if !bytes.HasPrefix(cursor.Key(), keyPrefix) {
+					break
+				}
+				var entry WatchHistoryEntry
+				txIdx, err := deserializeWatchHistory(cursor.Key(),
+					cursor.Value(), &entry)
+				if err != nil {
+					return watchCorruptionError("watch history",
+						err)
+				}
+
+				// Ignore entries of another block at the same
+				// height.
+				if txIdx >= len(transactions) ||
+					!transactions[txIdx].Hash().IsEqual(&entry.TxHash) {
+					continue
+				}
+				activity = append(activity, WatchActivity{
+					Target:   WatchTarget{prefix: prefix, name: target.name},
+					TxHash:   entry.TxHash,
+					TxIndex:  txIdx,
+					Received: entry.Received,
+					Sent:     entry.Sent,
+				})
+			}
+		}
+		return nil
+	})
+	sort.Slice(activity, func(i, j int) bool {
+		if activity[i].TxIndex != activity[j].TxIndex {
+			return activity[i].TxIndex < activity[j].TxIndex
+		}
+		return activity[i].Target.name < activity[j].Target.name
+	})
+	return activity, err
+}
+
+// TxActivity returns the activity of the watched targets in the passed
+// unconfirmed transaction.  The value sent is limited to the spent outputs
+// which are tracked.
+//
+// This function is safe for concurrent access.
+func (idx *WatchIndex) TxActivity(tx *provautil.Tx) ([]WatchActivity, error) {
+	var activity []WatchActivity
+	err := idx.db.View(func(dbTx database.Tx) error {
+		targets, err := dbFetchWatchTargets(dbTx)
+		if err != nil || len(targets) == 0 {
+			return err
+		}
+
+		deltas := make(map[[addrValuePrefixSize]byte]*addrValueDelta)
+		delta := func(prefix [addrValuePrefixSize]byte) *addrValueDelta {
+			d := deltas[prefix]
+			if d == nil {
+				d = &addrValueDelta{}
+				deltas[prefix] = d
+			}
+			return d
+		}
+
+		bucket := dbTx.Metadata().Bucket(watchIndexKey)
+		for _, txIn := range tx.MsgTx().TxIn {
+			for prefix := range targets {
+				key := addrUtxoKeyFor(prefix, &txIn.PreviousOutPoint)
+				serialized := bucket.Get(key)
+				if serialized == nil {
+					continue
+				}
+				var utxo AddrUtxo
+				err := deserializeAddrUtxo(key, serialized, &utxo)
+				if err != nil {
+					return watchCorruptionError("address utxo",
+						err)
+				}
+				delta(prefix).sent += utxo.Value
+			}
+		}
+		for _, txOut := range tx.MsgTx().TxOut {
+			for _, prefix := range scriptPrefixes(txOut.PkScript, idx.chainParams) {
+				if targets[prefix] != nil {
+					delta(prefix).received += txOut.Value
+				}
+			}
+		}
+
+		for prefix, d := range deltas {
+			activity = append(activity, WatchActivity{
+				Target: WatchTarget{
+					prefix: prefix,
+					name:   targets[prefix].name,
+				},
+				TxHash:   *tx.Hash(),
+				TxIndex:  -1,
+				Received: d.received,
+				Sent:     d.sent,
+			})
+		}
+		return nil
+	})
+	sort.Slice(activity, func(i, j int) bool {
+		return activity[i].Target.name < activity[j].Target.name
+	})
+	return activity, err
+}
+
+// NewWatchIndex returns a new instance of an indexer that is used to track the
+// unspent outputs, balances, and history of watched addresses and key IDs.
+//
+// It implements the Indexer interface which plugs into the IndexManager that in
+// turn is used by the blockchain package.  This allows the index to be
+// seamlessly maintained along with the chain.
+func NewWatchIndex(db database.DB, chainParams *chaincfg.Params) *WatchIndex {
+	return &WatchIndex{
+		db:          db,
+		chainParams: chainParams,
+	}
+}
+
+// DropWatchIndex drops the watch-only index, including the watched addresses
+// and key IDs, from the provided database if it exists.
+func DropWatchIndex(db database.DB) error {
+	if err := dropIndex(db, watchIndexKey, watchIndexName); err != nil {
+		return err
+	}
+	return db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if meta.Bucket(watchTargetsKey) == nil {
+			return nil
+		}
+		return meta.DeleteBucket(watchTargetsKey)
+	})
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package indexers
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+)
+
+// TestWatchIndexSerialization ensures watched target and history entries round
+// trip through serialization and that history keys are ordered by height and
+// transaction index.
+func TestWatchIndexSerialization(t *testing.T) {
+	t.Parallel()
+
+	target := watchTargetEntry{height: 1200, seeded: true, name: "42"}
+	var gotTarget watchTargetEntry
+	err := deserializeWatchTarget(serializeWatchTarget(&target), &gotTarget)
+	if err != nil {
+		t.Fatalf("unexpected target error: %v", err)
+	}
+	if gotTarget != target {
+		t.Fatalf("mismatched target - got %+v, want %+v", gotTarget,
+			target)
+	}
+	if !gotTarget.tracks(1201) || gotTarget.tracks(1200) {
+		t.Fatalf("target registered at 1200 must only track later blocks")
+	}
+	if err := deserializeWatchTarget([]byte{1, 2}, &gotTarget); !isDeserializeErr(err) {
+		t.Fatalf("unexpected error for short target: %v", err)
+	}
+
+	prefix := NewKeyIDWatchTarget(42).prefix
+	entry := WatchHistoryEntry{
+		TxHash:   chainhash.Hash{0x02},
+		Height:   1500,
+		Received: 1e9,
+		Sent:     2500,
+	}
+	key := watchHistoryKeyFor(prefix, entry.Height, 3)
+	serialized := serializeWatchHistory(&entry)
+
+	var gotEntry WatchHistoryEntry
+	txIdx, err := deserializeWatchHistory(key, serialized, &gotEntry)
+	if err != nil {
+		t.Fatalf("unexpected history error: %v", err)
+	}
+	if gotEntry != entry || txIdx != 3 {
+		t.Fatalf("mismatched history - got %+v (tx %d), want %+v (tx 3)",
+			gotEntry, txIdx, entry)
+	}
+	_, err = deserializeWatchHistory(key, serialized[:40], &gotEntry)
+	if !isDeserializeErr(err) {
+		t.Fatalf("unexpected error for short history: %v", err)
+	}
+
+	// History keys must be grouped by prefix and sorted by height and
+	// transaction index so they can be paged through with a cursor.
+	laterTx := watchHistoryKeyFor(prefix, entry.Height, 4)
+	laterBlock := watchHistoryKeyFor(prefix, entry.Height+1, 0)
+	if bytes.Compare(key, laterTx) >= 0 || bytes.Compare(laterTx, laterBlock) >= 0 {
+		t.Fatalf("history keys are not ordered by height and tx index")
+	}
+	if NewKeyIDWatchTarget(42).String() != "42" {
+		t.Fatalf("unexpected key ID target name %q",
+			NewKeyIDWatchTarget(42).String())
+	}
+}
+
+// TestWatchTargetRescanning ensures targets with a rescan in progress round
+// trip through serialization and only track the blocks rescanned so far.
+func TestWatchTargetRescanning(t *testing.T) {
+	t.Parallel()
+
+	target := watchTargetEntry{
+		height:     99,
+		rescanning: true,
+		rescanned:  150,
+		name:       "031234",
+	}
+	serialized := serializeWatchTarget(&target)
+	var gotTarget watchTargetEntry
+	if err := deserializeWatchTarget(serialized, &gotTarget); err != nil {
+		t.Fatalf("unexpected target error: %v", err)
+	}
+	if gotTarget != target {
+		t.Fatalf("mismatched target - got %+v, want %+v", gotTarget,
+			target)
+	}
+	for height, want := range map[uint32]bool{99: false, 100: true,
+		150: true, 151: false} {
+
+		if gotTarget.tracks(height) != want {
+			t.Errorf("tracks(%d) = %v, want %v", height, !want, want)
+		}
+	}
+	err := deserializeWatchTarget(serialized[:watchTargetEntryMinSize+2],
+		&gotTarget)
+	if !isDeserializeErr(err) {
+		t.Fatalf("unexpected error for short rescan height: %v", err)
+	}
+
+	// Public key targets share the prefix of the addresses paying to the
+	// hash of the key.
+	pubKey := []byte{0x03, 0x12, 0x34}
+	pubKeyTarget := NewPubKeyWatchTarget(pubKey)
+	if !bytes.Equal(pubKeyTarget.prefix[1:], provautil.Hash160(pubKey)) ||
+		pubKeyTarget.prefix[0] != addrValueKeyTypeAddress ||
+		pubKeyTarget.String() != "031234" {
+
+		t.Fatalf("unexpected public key target %+v", pubKeyTarget)
+	}
+}
@@ -15,6 +15,9 @@ package blockchain
 
 import (
 	"sort"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
 )
 
 // TstSetCoinbaseMaturity makes the ability to set the coinbase maturity
@@ -39,6 +42,45 @@ func TstSetMaxMedianTimeEntries(val int) {
 // to the test package.
 var TstCheckBlockScripts = checkBlockScripts
 
+// TstScriptBatches returns the sizes of the batches the internal scriptBatches
+// function splits the passed number of inputs into for the passed number of
+// goroutines.
+func TstScriptBatches(numItems, workers int) []int {
+	items := make([]*txValidateItem, numItems)
+	for i := range items {
+		items[i] = &txValidateItem{txInIndex: i}
+	}
+	var sizes []int
+	next := 0
+	for _, batch := range scriptBatches(items, workers) {
+		for _, item := range batch {
+			if item.txInIndex != next {
+				return nil
+			}
+			next++
+		}
+		sizes = append(sizes, len(batch))
+	}
+	return sizes
+}
+
 // TstDeserializeUtxoEntry makes the internal deserializeUtxoEntry function
 // available to the test package.
 var TstDeserializeUtxoEntry = deserializeUtxoEntry
+
+// TstPutBestStateRecord replaces the best chain state record in the database
+// with the passed serialized data.
+func (b *BlockChain) TstPutBestStateRecord(serialized []byte) error {
+	return b.db.Update(func(dbTx database.Tx) error {
+		return dbTx.Metadata().Put(chainStateKeyName, serialized)
+	})
+}
+
+// TstDeleteUtxoEntry removes the utxo entry of the transaction with the passed
+// hash from the database.
+func (b *BlockChain) TstDeleteUtxoEntry(txHash *chainhash.Hash) error {
+	return b.db.Update(func(dbTx database.Tx) error {
+		utxoBucket := dbTx.Metadata().Bucket(utxoSetBucketName)
+		return utxoBucket.Delete(txHash[:])
+	})
+}
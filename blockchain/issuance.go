@@ -0,0 +1,294 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"encoding/binary"
+
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+var (
+	// issuanceHistoryBucketName is the name of the db bucket used to house
+	// the token totals after each block of the main chain which issues or
+	// destroys tokens.
+	issuanceHistoryBucketName = []byte("issuancehistory")
+
+	// issuanceHistoryStartKeyName is the name of the db key used to store
+	// the height the issuance history starts at along with the token
+	// totals at that height.
+	issuanceHistoryStartKeyName = []byte("issuancehistorystart")
+)
+
+// issuanceTotalsSize is the size of serialized token totals.
+const issuanceTotalsSize = 8 + 8 + 4
+
+// issuanceTotals houses the cumulative amounts of atoms issued and destroyed
+// by the issue thread up to a block of the main chain, along with the height
+// of the last block which issued new tokens.
+type issuanceTotals struct {
+	issued       uint64
+	destroyed    uint64
+	lastIssuance uint32
+}
+
+// IssuanceInfo describes the tokens issued and destroyed by the issue thread of
+// the main chain.
+type IssuanceInfo struct {
+	// Supply is the total spendable supply of atoms, which is the same as
+	// returned by TotalSupply.
+	Supply uint64
+
+	// TotalIssued and TotalDestroyed are the cumulative amounts of atoms
+	// issued and destroyed by the main chain.
+	TotalIssued    uint64
+	TotalDestroyed uint64
+
+	// LastIssuanceHeight is the height of the last main chain block which
+	// issued new tokens, or zero when none is known.
+	LastIssuanceHeight uint32
+
+	// HistoryStart is the height the issuance history starts at.  The
+	// tokens issued and destroyed before it are not known individually, so
+	// the supply at that height is counted as issued.
+	HistoryStart uint32
+}
+
+// blockIssuance returns the amounts of atoms the issue thread transactions of
+// the passed block issue and destroy.  Transactions which spend other outputs
+// besides the thread destroy the tokens of their null data outputs, while the
+// others issue the tokens of all of their outputs besides the thread.
+func blockIssuance(block *provautil.Block) (issued, destroyed uint64) {
+	for _, tx := range block.Transactions() {
+		threadInt, adminOutputs := txscript.GetAdminDetails(tx)
+		if threadInt < 0 || provautil.ThreadID(threadInt) !=
+			provautil.IssueThread {
+
+			continue
+		}
+		msgTx := tx.MsgTx()
+		if len(msgTx.TxIn) > 1 {
+			for i, adminOutput := range adminOutputs {
+				if txscript.TypeOfScript(adminOutput) ==
+					txscript.NullDataTy {
+
+					destroyed += uint64(msgTx.TxOut[i+1].Value)
+				}
+			}
+			continue
+		}
+		for _, txOut := range msgTx.TxOut[1:] {
+			issued += uint64(txOut.Value)
+		}
+	}
+	return issued, destroyed
+}
+
+// -----------------------------------------------------------------------------
+// The issuance history consists of an entry for each main chain block which
+// issues or destroys tokens, keyed by the height of the block.  The height is
+// big endian so the entries are iterated in the order of the chain, and the
+// last entry holds the totals of the main chain.  Blocks which neither issue
+// nor destroy tokens have no entry.
+//
+// The serialized value format is:
+//
+//   <total issued><total destroyed><last issuance height>
+//
+//   Field                 Type     Size
+//   total issued          uint64   8 bytes
+//   total destroyed       uint64   8 bytes
+//   last issuance height  uint32   4 bytes
+//
+// The history starts at the height stored under the history start key, which
+// is followed by the totals at that height in the same format.  The history of
+// databases which predate it is recreated from the stored blocks of the main
+// chain.  Chains bootstrapped from a utxo set snapshot, and pruned chains which
+// no longer store the blocks to recreate it from, start it at the end of the
+// main chain with the supply counted as issued.
+// -----------------------------------------------------------------------------
+
+// serializeIssuanceTotals returns the serialization of the passed totals.
+func serializeIssuanceTotals(totals *issuanceTotals) []byte {
+	serialized := make([]byte, issuanceTotalsSize)
+	byteOrder.PutUint64(serialized[0:8], totals.issued)
+	byteOrder.PutUint64(serialized[8:16], totals.destroyed)
+	byteOrder.PutUint32(serialized[16:20], totals.lastIssuance)
+	return serialized
+}
+
+// deserializeIssuanceTotals decodes the passed serialized totals.
+func deserializeIssuanceTotals(serialized []byte) (*issuanceTotals, error) {
+	if len(serialized) != issuanceTotalsSize {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt issuance history entry",
+		}
+	}
+	return &issuanceTotals{
+		issued:       byteOrder.Uint64(serialized[0:8]),
+		destroyed:    byteOrder.Uint64(serialized[8:16]),
+		lastIssuance: byteOrder.Uint32(serialized[16:20]),
+	}, nil
+}
+
+// dbFetchIssuanceTotals uses an existing database transaction to fetch the
+// token totals of the main chain and the height the issuance history starts
+// at.
+func dbFetchIssuanceTotals(dbTx database.Tx) (*issuanceTotals, uint32, error) {
+	meta := dbTx.Metadata()
+	serializedStart := meta.Get(issuanceHistoryStartKeyName)
+	if len(serializedStart) != 4+issuanceTotalsSize {
+		return nil, 0, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "missing issuance history start",
+		}
+	}
+	start := byteOrder.Uint32(serializedStart[0:4])
+
+	cursor := meta.Bucket(issuanceHistoryBucketName).Cursor()
+	serialized := serializedStart[4:]
+	if cursor.Last() {
+		serialized = cursor.Value()
+	}
+	totals, err := deserializeIssuanceTotals(serialized)
+	if err != nil {
+		return nil, 0, err
+	}
+	return totals, start, nil
+}
+
+// dbPutIssuanceHistory uses an existing database transaction to record the
+// token totals after the passed block when it issues or destroys tokens.
+func dbPutIssuanceHistory(dbTx database.Tx, block *provautil.Block) error {
+	issued, destroyed := blockIssuance(block)
+	if issued == 0 && destroyed == 0 {
+		return nil
+	}
+	totals, _, err := dbFetchIssuanceTotals(dbTx)
+	if err != nil {
+		return err
+	}
+	totals.issued += issued
+	totals.destroyed += destroyed
+	if issued > 0 {
+		totals.lastIssuance = block.Height()
+	}
+
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], block.Height())
+	bucket := dbTx.Metadata().Bucket(issuanceHistoryBucketName)
+	return bucket.Put(key[:], serializeIssuanceTotals(totals))
+}
+
+// dbRemoveIssuanceHistory uses an existing database transaction to remove the
+// token totals recorded at the passed height.
+func dbRemoveIssuanceHistory(dbTx database.Tx, height uint32) error {
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], height)
+	bucket := dbTx.Metadata().Bucket(issuanceHistoryBucketName)
+	return bucket.Delete(key[:])
+}
+
+// dbPutIssuanceHistoryStart uses an existing database transaction to store the
+// height the issuance history starts at, at which the passed supply is counted
+// as issued.
+func dbPutIssuanceHistoryStart(dbTx database.Tx, height uint32, supply uint64) error {
+	serialized := make([]byte, 4, 4+issuanceTotalsSize)
+	byteOrder.PutUint32(serialized, height)
+	serialized = append(serialized, serializeIssuanceTotals(
+		&issuanceTotals{issued: supply})...)
+	return dbTx.Metadata().Put(issuanceHistoryStartKeyName, serialized)
+}
+
+// initIssuanceHistory creates the issuance history when the database does not
+// house it yet.  The history of databases which predate it is recreated from
+// the stored blocks of the main chain, or starts at the end of the main chain
+// when the blocks have been pruned.
+func (b *BlockChain) initIssuanceHistory() error {
+	return b.db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if meta.Bucket(issuanceHistoryBucketName) != nil {
+			return nil
+		}
+		bucket, err := meta.CreateBucket(issuanceHistoryBucketName)
+		if err != nil {
+			return err
+		}
+		height := b.bestNode.height
+		if height == 0 {
+			return dbPutIssuanceHistoryStart(dbTx, 0, b.totalSupply)
+		}
+
+		// Replay the issue thread of the main chain.  The genesis block
+		// does not issue any tokens, so the totals start at zero.
+		log.Infof("Recreating the issuance history of %d blocks", height)
+		var totals issuanceTotals
+		entries := make(map[uint32][]byte)
+		stored, err := dbForEachMainChainBlock(dbTx, height,
+			func(block *provautil.Block) error {
+				issued, destroyed := blockIssuance(block)
+				if issued == 0 && destroyed == 0 {
+					return nil
+				}
+				totals.issued += issued
+				totals.destroyed += destroyed
+				if issued > 0 {
+					totals.lastIssuance = block.Height()
+				}
+				entries[block.Height()] = serializeIssuanceTotals(&totals)
+				return nil
+			})
+		if err != nil {
+			return err
+		}
+		if !stored {
+			log.Warnf("Issuance history starts at height %d since "+
+				"the blocks before it have been pruned", height)
+			return dbPutIssuanceHistoryStart(dbTx, height, b.totalSupply)
+		}
+
+		for entryHeight, serialized := range entries {
+			var key [4]byte
+			binary.BigEndian.PutUint32(key[:], entryHeight)
+			if err := bucket.Put(key[:], serialized); err != nil {
+				return err
+			}
+		}
+		return dbPutIssuanceHistoryStart(dbTx, 0, 0)
+	})
+}
+
+// IssuanceInfo returns the current supply of the main chain along with the
+// cumulative amounts of atoms issued and destroyed by it, so the supply is
+// able to be audited without replaying the issue thread.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) IssuanceInfo() (*IssuanceInfo, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	var info *IssuanceInfo
+	err := b.db.View(func(dbTx database.Tx) error {
+		totals, start, err := dbFetchIssuanceTotals(dbTx)
+		if err != nil {
+			return err
+		}
+		info = &IssuanceInfo{
+			Supply:             b.TotalSupply(),
+			TotalIssued:        totals.issued,
+			TotalDestroyed:     totals.destroyed,
+			LastIssuanceHeight: totals.lastIssuance,
+			HistoryStart:       start,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
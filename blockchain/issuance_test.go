@@ -0,0 +1,155 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+// TestIssuanceInfo ensures the token totals of a chain which processed the
+// blocks generated by the fullblocktests package, which issue tokens and
+// destroy them on a fork which is reorganized away, match those of a chain
+// which only connected the blocks of the main chain in order, that the totals
+// account for the supply after each block, and that the history of a database
+// which predates it is recreated from the stored blocks.
+func TestIssuanceInfo(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("issuance",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	var destroyed bool
+	for _, test := range tests {
+		for _, item := range test {
+			var block *provautil.Block
+			switch item := item.(type) {
+			case fullblocktests.AcceptedBlock:
+				block = provautil.NewBlock(item.Block)
+			case fullblocktests.RejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			case fullblocktests.OrphanOrRejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			default:
+				continue
+			}
+			chain.ProcessBlock(block, blockchain.BFNone)
+			info, err := chain.IssuanceInfo()
+			if err != nil {
+				t.Fatalf("IssuanceInfo: %v", err)
+			}
+			if info.TotalDestroyed > 0 {
+				destroyed = true
+			}
+		}
+	}
+	if !destroyed {
+		t.Fatal("the chain never destroyed tokens")
+	}
+	best := chain.BestSnapshot()
+
+	dir, err := ioutil.TempDir("", "issuance")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	replay, db, err := snapshotChainSetup(dir, nil)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer db.Close()
+
+	var prev blockchain.IssuanceInfo
+	var issuances int
+	for height := uint32(1); height <= best.Height; height++ {
+		block, err := chain.BlockByHeight(height)
+		if err != nil {
+			t.Fatalf("BlockByHeight: %v", err)
+		}
+		_, _, err = replay.ProcessBlock(block, blockchain.BFNone)
+		if err != nil {
+			t.Fatalf("ProcessBlock: %v", err)
+		}
+		info, err := replay.IssuanceInfo()
+		if err != nil {
+			t.Fatalf("IssuanceInfo: %v", err)
+		}
+		if info.Supply != info.TotalIssued-info.TotalDestroyed {
+			t.Fatalf("IssuanceInfo at height %d: supply %d does not "+
+				"match %d issued and %d destroyed", height,
+				info.Supply, info.TotalIssued,
+				info.TotalDestroyed)
+		}
+		wantLast := prev.LastIssuanceHeight
+		if info.TotalIssued != prev.TotalIssued {
+			wantLast = height
+			issuances++
+		}
+		if info.LastIssuanceHeight != wantLast {
+			t.Fatalf("IssuanceInfo at height %d: got last issuance "+
+				"height %d, want %d", height,
+				info.LastIssuanceHeight, wantLast)
+		}
+		prev = *info
+	}
+	if issuances == 0 {
+		t.Fatal("the main chain never issued tokens")
+	}
+
+	info, err := chain.IssuanceInfo()
+	if err != nil {
+		t.Fatalf("IssuanceInfo: %v", err)
+	}
+	if *info != prev {
+		t.Fatalf("IssuanceInfo: got %+v, want %+v", info, prev)
+	}
+
+	// Remove the history as if the database predates it and ensure
+	// loading the chain again recreates the same totals.
+	err = db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		err := meta.DeleteBucket([]byte("issuancehistory"))
+		if err != nil {
+			return err
+		}
+		return meta.Delete([]byte("issuancehistorystart"))
+	})
+	if err != nil {
+		t.Fatalf("failed to remove the issuance history: %v", err)
+	}
+	params := chaincfg.RegressionNetParams
+	reloaded, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  blockchain.NewMedianTime(),
+		SigCache:    txscript.NewSigCache(1000),
+	})
+	if err != nil {
+		t.Fatalf("Failed to load chain instance: %v", err)
+	}
+	info, err = reloaded.IssuanceInfo()
+	if err != nil {
+		t.Fatalf("IssuanceInfo: %v", err)
+	}
+	if *info != prev {
+		t.Fatalf("IssuanceInfo after recreating the history: got %+v, "+
+			"want %+v", info, prev)
+	}
+}
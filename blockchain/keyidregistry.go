@@ -0,0 +1,329 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+var (
+	// keyIDRegistryBucketName is the name of the db bucket used to house
+	// the bindings of the ASP key IDs provisioned by the main chain.
+	keyIDRegistryBucketName = []byte("keyidregistry")
+
+	// keyIDRegistryStartKeyName is the name of the db key used to store the
+	// height the key ID registry starts at.
+	keyIDRegistryStartKeyName = []byte("keyidregistrystart")
+)
+
+// keyIDBindingSize is the size of a serialized key ID binding.
+const keyIDBindingSize = btcec.PubKeyBytesLenCompressed + 4 + 4
+
+// keyIDBinding describes the binding of an ASP key ID to its key, along with
+// the heights of the main chain blocks which provisioned and revoked it.  The
+// revoke height is zero while the key ID is not revoked.
+type keyIDBinding struct {
+	pubKey          *btcec.PublicKey
+	provisionHeight uint32
+	revokeHeight    uint32
+}
+
+// KeyIDInfo describes an ASP key ID provisioned by the main chain.
+type KeyIDInfo struct {
+	// KeyID is the described key ID.
+	KeyID btcec.KeyID
+
+	// PubKey is the ASP key the key ID is bound to.  It is nil when the
+	// key ID was revoked before the start of the key ID registry, since
+	// the key is no longer part of the admin state.
+	PubKey *btcec.PublicKey
+
+	// ProvisionHeight is the height of the main chain block which
+	// provisioned the key ID.  Key IDs provisioned before the start of the
+	// key ID registry report the height it starts at.
+	ProvisionHeight uint32
+
+	// Revoked is whether or not the key ID has been revoked, and
+	// RevokeHeight the height of the main chain block which revoked it,
+	// which is zero when it is not known.
+	Revoked      bool
+	RevokeHeight uint32
+}
+
+// keyIDOp describes the provisioning or revocation of an ASP key ID.
+type keyIDOp struct {
+	keyID  btcec.KeyID
+	add    bool
+	pubKey *btcec.PublicKey
+}
+
+// blockKeyIDOps returns the operations the admin transactions of the passed
+// block perform on the ASP key IDs, in the order they are applied.
+func blockKeyIDOps(block *provautil.Block) []keyIDOp {
+	var ops []keyIDOp
+	for _, tx := range block.Transactions() {
+		threadInt, adminOutputs := txscript.GetAdminDetails(tx)
+		if threadInt < 0 || provautil.ThreadID(threadInt) !=
+			provautil.ProvisionThread {
+
+			continue
+		}
+		for _, adminOutput := range adminOutputs {
+			isAddOp, keySet, pubKey, keyID :=
+				txscript.ExtractAdminOpData(adminOutput)
+			if keySet != btcec.ASPKeySet {
+				continue
+			}
+			ops = append(ops, keyIDOp{
+				keyID:  keyID,
+				add:    isAddOp,
+				pubKey: pubKey,
+			})
+		}
+	}
+	return ops
+}
+
+// -----------------------------------------------------------------------------
+// The key ID registry consists of an entry for each ASP key ID provisioned by
+// the main chain, keyed by the key ID.  Key IDs are never provisioned twice,
+// so the entry of a revoked key ID is kept with the height of its revocation.
+//
+// The serialized value format is:
+//
+//   <public key><provision height><revoke height>
+//
+//   Field             Type        Size
+//   public key        PublicKey   33 bytes (compressed)
+//   provision height  uint32      4 bytes
+//   revoke height     uint32      4 bytes
+//
+// The registry starts at the height stored under the registry start key.  The
+// key IDs which are bound when it is created, which is at the genesis block for
+// new databases, are registered at that height, and the key IDs which were
+// revoked before it have no entry.
+// -----------------------------------------------------------------------------
+
+// serializeKeyIDBinding returns the serialization of the passed binding.
+func serializeKeyIDBinding(binding *keyIDBinding) []byte {
+	serialized := make([]byte, keyIDBindingSize)
+	copy(serialized, binding.pubKey.SerializeCompressed())
+	offset := btcec.PubKeyBytesLenCompressed
+	byteOrder.PutUint32(serialized[offset:], binding.provisionHeight)
+	byteOrder.PutUint32(serialized[offset+4:], binding.revokeHeight)
+	return serialized
+}
+
+// deserializeKeyIDBinding decodes the passed serialized binding.
+func deserializeKeyIDBinding(serialized []byte) (*keyIDBinding, error) {
+	if len(serialized) != keyIDBindingSize {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt key ID registry entry",
+		}
+	}
+	offset := btcec.PubKeyBytesLenCompressed
+	pubKey, err := btcec.ParsePubKey(serialized[:offset], btcec.S256())
+	if err != nil {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt key ID registry "+
+				"entry: %v", err),
+		}
+	}
+	return &keyIDBinding{
+		pubKey:          pubKey,
+		provisionHeight: byteOrder.Uint32(serialized[offset:]),
+		revokeHeight:    byteOrder.Uint32(serialized[offset+4:]),
+	}, nil
+}
+
+// keyIDRegistryKey returns the db key of the entry of the passed key ID.
+func keyIDRegistryKey(keyID btcec.KeyID) []byte {
+	var key [btcec.KeyIDSize]byte
+	byteOrder.PutUint32(key[:], uint32(keyID))
+	return key[:]
+}
+
+// dbFetchKeyIDBinding uses an existing database transaction to fetch the
+// binding of the passed key ID.  It returns nil when the key ID has no entry.
+func dbFetchKeyIDBinding(dbTx database.Tx, keyID btcec.KeyID) (*keyIDBinding, error) {
+	bucket := dbTx.Metadata().Bucket(keyIDRegistryBucketName)
+	serialized := bucket.Get(keyIDRegistryKey(keyID))
+	if serialized == nil {
+		return nil, nil
+	}
+	return deserializeKeyIDBinding(serialized)
+}
+
+// dbPutKeyIDBinding uses an existing database transaction to store the binding
+// of the passed key ID.
+func dbPutKeyIDBinding(dbTx database.Tx, keyID btcec.KeyID, binding *keyIDBinding) error {
+	bucket := dbTx.Metadata().Bucket(keyIDRegistryBucketName)
+	return bucket.Put(keyIDRegistryKey(keyID), serializeKeyIDBinding(binding))
+}
+
+// dbConnectKeyIDRegistry uses an existing database transaction to register the
+// key IDs provisioned and revoked by the passed block.
+func dbConnectKeyIDRegistry(dbTx database.Tx, block *provautil.Block) error {
+	for _, op := range blockKeyIDOps(block) {
+		if op.add {
+			err := dbPutKeyIDBinding(dbTx, op.keyID, &keyIDBinding{
+				pubKey:          op.pubKey,
+				provisionHeight: block.Height(),
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Only bound key IDs are able to be revoked, and all of them
+		// have an entry, so there is nothing to update otherwise.
+		binding, err := dbFetchKeyIDBinding(dbTx, op.keyID)
+		if err != nil {
+			return err
+		}
+		if binding == nil {
+			continue
+		}
+		binding.revokeHeight = block.Height()
+		if err := dbPutKeyIDBinding(dbTx, op.keyID, binding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dbDisconnectKeyIDRegistry uses an existing database transaction to undo the
+// changes the passed block made to the key ID registry.
+func dbDisconnectKeyIDRegistry(dbTx database.Tx, block *provautil.Block) error {
+	bucket := dbTx.Metadata().Bucket(keyIDRegistryBucketName)
+	ops := blockKeyIDOps(block)
+	for i := len(ops) - 1; i >= 0; i-- {
+		op := &ops[i]
+		if op.add {
+			if err := bucket.Delete(keyIDRegistryKey(op.keyID)); err != nil {
+				return err
+			}
+			continue
+		}
+		binding, err := dbFetchKeyIDBinding(dbTx, op.keyID)
+		if err != nil {
+			return err
+		}
+		if binding == nil || binding.revokeHeight != block.Height() {
+			continue
+		}
+		binding.revokeHeight = 0
+		if err := dbPutKeyIDBinding(dbTx, op.keyID, binding); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dbResetKeyIDRegistry uses an existing database transaction to start the key
+// ID registry at the passed height, with the passed bound key IDs registered at
+// that height.
+func dbResetKeyIDRegistry(dbTx database.Tx, height uint32, aspKeyIdMap btcec.KeyIdMap) error {
+	meta := dbTx.Metadata()
+	if meta.Bucket(keyIDRegistryBucketName) != nil {
+		if err := meta.DeleteBucket(keyIDRegistryBucketName); err != nil {
+			return err
+		}
+	}
+	if _, err := meta.CreateBucket(keyIDRegistryBucketName); err != nil {
+		return err
+	}
+	for keyID, pubKey := range aspKeyIdMap {
+		err := dbPutKeyIDBinding(dbTx, keyID, &keyIDBinding{
+			pubKey:          pubKey,
+			provisionHeight: height,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	var serialized [4]byte
+	byteOrder.PutUint32(serialized[:], height)
+	return meta.Put(keyIDRegistryStartKeyName, serialized[:])
+}
+
+// initKeyIDRegistry creates the key ID registry when the database does not
+// house it yet.  The registry of databases which predate it starts at the end
+// of the main chain.
+func (b *BlockChain) initKeyIDRegistry() error {
+	return b.db.Update(func(dbTx database.Tx) error {
+		if dbTx.Metadata().Bucket(keyIDRegistryBucketName) != nil {
+			return nil
+		}
+		height := b.bestNode.height
+		if height > 0 {
+			log.Infof("Key ID registry starts at height %d", height)
+		}
+		return dbResetKeyIDRegistry(dbTx, height, b.aspKeyIdMap)
+	})
+}
+
+// KeyIDInfo returns the ASP key the passed key ID is bound to, the height of the
+// main chain block which provisioned it, and whether or not it has been
+// revoked.  An error is returned when the main chain has not provisioned the
+// key ID.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) KeyIDInfo(keyID btcec.KeyID) (*KeyIDInfo, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	if keyID == 0 || keyID > b.LastKeyID() {
+		return nil, fmt.Errorf("key ID %d has not been provisioned",
+			keyID)
+	}
+
+	var info *KeyIDInfo
+	err := b.db.View(func(dbTx database.Tx) error {
+		binding, err := dbFetchKeyIDBinding(dbTx, keyID)
+		if err != nil {
+			return err
+		}
+
+		// Key IDs which were provisioned but have no entry were
+		// revoked before the start of the registry.
+		if binding == nil {
+			serializedStart := dbTx.Metadata().Get(
+				keyIDRegistryStartKeyName)
+			if len(serializedStart) != 4 {
+				return database.Error{
+					ErrorCode:   database.ErrCorruption,
+					Description: "missing key ID registry start",
+				}
+			}
+			info = &KeyIDInfo{
+				KeyID:           keyID,
+				ProvisionHeight: byteOrder.Uint32(serializedStart),
+				Revoked:         true,
+			}
+			return nil
+		}
+		info = &KeyIDInfo{
+			KeyID:           keyID,
+			PubKey:          binding.pubKey,
+			ProvisionHeight: binding.provisionHeight,
+			Revoked:         binding.revokeHeight != 0,
+			RevokeHeight:    binding.revokeHeight,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/provautil"
+)
+
+// TestKeyIDInfo ensures the key ID registry of a chain which processed the
+// blocks generated by the fullblocktests package, which provision and revoke
+// key IDs and reorganize the chain, matches the bindings observed by a chain
+// which only connected the blocks of the main chain in order.
+func TestKeyIDInfo(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("keyidregistry",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	for _, test := range tests {
+		for _, item := range test {
+			var block *provautil.Block
+			switch item := item.(type) {
+			case fullblocktests.AcceptedBlock:
+				block = provautil.NewBlock(item.Block)
+			case fullblocktests.RejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			case fullblocktests.OrphanOrRejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			default:
+				continue
+			}
+			chain.ProcessBlock(block, blockchain.BFNone)
+		}
+	}
+	best := chain.BestSnapshot()
+
+	dir, err := ioutil.TempDir("", "keyidregistry")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	replay, db, err := snapshotChainSetup(dir, nil)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer db.Close()
+
+	// Record the bindings of the key IDs as they are provisioned and
+	// revoked by the main chain.
+	want := make(map[btcec.KeyID]*blockchain.KeyIDInfo)
+	for height := uint32(0); height <= best.Height; height++ {
+		if height > 0 {
+			block, err := chain.BlockByHeight(height)
+			if err != nil {
+				t.Fatalf("BlockByHeight: %v", err)
+			}
+			_, _, err = replay.ProcessBlock(block, blockchain.BFNone)
+			if err != nil {
+				t.Fatalf("ProcessBlock: %v", err)
+			}
+		}
+		keyIDs := replay.KeyIDs()
+		for keyID, pubKey := range keyIDs {
+			if _, ok := want[keyID]; !ok {
+				want[keyID] = &blockchain.KeyIDInfo{
+					KeyID:           keyID,
+					PubKey:          pubKey,
+					ProvisionHeight: height,
+				}
+			}
+		}
+		for keyID, info := range want {
+			if _, ok := keyIDs[keyID]; !ok && !info.Revoked {
+				info.Revoked = true
+				info.RevokeHeight = height
+			}
+		}
+	}
+
+	var revoked int
+	for keyID := btcec.KeyID(1); keyID <= chain.LastKeyID(); keyID++ {
+		got, err := chain.KeyIDInfo(keyID)
+		if err != nil {
+			t.Fatalf("KeyIDInfo(%d): %v", keyID, err)
+		}
+		info := want[keyID]
+		if info == nil {
+			t.Fatalf("KeyIDInfo(%d): key ID was never bound", keyID)
+		}
+		if !got.PubKey.IsEqual(info.PubKey) ||
+			got.ProvisionHeight != info.ProvisionHeight ||
+			got.Revoked != info.Revoked ||
+			got.RevokeHeight != info.RevokeHeight {
+
+			t.Fatalf("KeyIDInfo(%d): got %+v, want %+v", keyID, got,
+				info)
+		}
+		if got.Revoked {
+			revoked++
+		}
+	}
+	if len(want) == 0 || revoked == 0 {
+		t.Fatalf("the main chain bound %d key IDs and revoked %d",
+			len(want), revoked)
+	}
+
+	if _, err := chain.KeyIDInfo(chain.LastKeyID() + 1); err == nil {
+		t.Fatal("KeyIDInfo: no error for key ID which has not been " +
+			"provisioned")
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+var (
+	// keySetHistoryBucketName is the name of the db bucket used to house
+	// the changes made to the admin key sets by the blocks of the main
+	// chain.
+	keySetHistoryBucketName = []byte("keysethistory")
+
+	// keySetHistoryStartKeyName is the name of the db key used to store the
+	// height the admin key set history starts at.
+	keySetHistoryStartKeyName = []byte("keysethistorystart")
+)
+
+// keySetOpSize is the size of a serialized admin key set operation.
+const keySetOpSize = 3 + btcec.PubKeyBytesLenCompressed
+
+// keySetOp describes the addition or removal of a key to or from an admin key
+// set by an admin transaction on the passed thread.
+type keySetOp struct {
+	thread provautil.ThreadID
+	keySet btcec.KeySetType
+	add    bool
+	pubKey *btcec.PublicKey
+}
+
+// blockKeySetOps returns the operations the admin transactions of the passed
+// block perform on the admin key sets, in the order they are applied.  The ASP
+// key IDs are not admin key sets, so their operations are left out.
+func blockKeySetOps(block *provautil.Block) []keySetOp {
+	var ops []keySetOp
+	for _, tx := range block.Transactions() {
+		threadInt, adminOutputs := txscript.GetAdminDetails(tx)
+		if threadInt < 0 || provautil.ThreadID(threadInt) ==
+			provautil.IssueThread {
+
+			continue
+		}
+		for _, adminOutput := range adminOutputs {
+			isAddOp, keySet, pubKey, _ :=
+				txscript.ExtractAdminOpData(adminOutput)
+			if keySet == btcec.ASPKeySet {
+				continue
+			}
+			ops = append(ops, keySetOp{
+				thread: provautil.ThreadID(threadInt),
+				keySet: keySet,
+				add:    isAddOp,
+				pubKey: pubKey,
+			})
+		}
+	}
+	return ops
+}
+
+// -----------------------------------------------------------------------------
+// The admin key set history consists of an entry for each main chain block
+// which changes the admin key sets, keyed by the height of the block.  The
+// height is big endian so the entries are iterated in the order of the chain.
+// Blocks which do not change the admin key sets have no entry.
+//
+// The serialized value format is:
+//
+//   [<thread><key set><add><public key>,...]
+//
+//   Field             Type             Size
+//   thread            ThreadID         1 byte
+//   key set           KeySetType       1 byte
+//   add               bool             1 byte
+//   public key        PublicKey        33 bytes (compressed)
+//
+// The history starts at the height stored under the history start key.  The
+// history of databases which predate it is recreated from the stored blocks of
+// the main chain.  Chains bootstrapped from a utxo set snapshot, and pruned
+// chains which no longer store the blocks to recreate it from, start it at the
+// end of the main chain and have no record of the changes before it.
+// -----------------------------------------------------------------------------
+
+// serializeKeySetOps returns the serialization of the passed admin key set
+// operations.
+func serializeKeySetOps(ops []keySetOp) []byte {
+	serialized := make([]byte, len(ops)*keySetOpSize)
+	offset := 0
+	for _, op := range ops {
+		serialized[offset] = byte(op.thread)
+		serialized[offset+1] = byte(op.keySet)
+		if op.add {
+			serialized[offset+2] = 1
+		}
+		copy(serialized[offset+3:], op.pubKey.SerializeCompressed())
+		offset += keySetOpSize
+	}
+	return serialized
+}
+
+// deserializeKeySetOps decodes the passed serialized admin key set operations.
+func deserializeKeySetOps(serialized []byte) ([]keySetOp, error) {
+	if len(serialized)%keySetOpSize != 0 {
+		return nil, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt admin key set history entry",
+		}
+	}
+	ops := make([]keySetOp, 0, len(serialized)/keySetOpSize)
+	for offset := 0; offset < len(serialized); offset += keySetOpSize {
+		pubKey, err := btcec.ParsePubKey(serialized[offset+3:offset+
+			keySetOpSize], btcec.S256())
+		if err != nil {
+			return nil, database.Error{
+				ErrorCode: database.ErrCorruption,
+				Description: fmt.Sprintf("corrupt admin key set "+
+					"history entry: %v", err),
+			}
+		}
+		ops = append(ops, keySetOp{
+			thread: provautil.ThreadID(serialized[offset]),
+			keySet: btcec.KeySetType(serialized[offset+1]),
+			add:    serialized[offset+2] != 0,
+			pubKey: pubKey,
+		})
+	}
+	return ops, nil
+}
+
+// dbPutKeySetHistory uses an existing database transaction to record the
+// changes the passed block makes to the admin key sets at its height.
+func dbPutKeySetHistory(dbTx database.Tx, block *provautil.Block) error {
+	ops := blockKeySetOps(block)
+	if len(ops) == 0 {
+		return nil
+	}
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], block.Height())
+	bucket := dbTx.Metadata().Bucket(keySetHistoryBucketName)
+	return bucket.Put(key[:], serializeKeySetOps(ops))
+}
+
+// dbRemoveKeySetHistory uses an existing database transaction to remove the
+// changes to the admin key sets recorded at the passed height.
+func dbRemoveKeySetHistory(dbTx database.Tx, height uint32) error {
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], height)
+	bucket := dbTx.Metadata().Bucket(keySetHistoryBucketName)
+	return bucket.Delete(key[:])
+}
+
+// dbPutKeySetHistoryStart uses an existing database transaction to store the
+// height the admin key set history starts at.
+func dbPutKeySetHistoryStart(dbTx database.Tx, height uint32) error {
+	var serialized [4]byte
+	byteOrder.PutUint32(serialized[:], height)
+	return dbTx.Metadata().Put(keySetHistoryStartKeyName, serialized[:])
+}
+
+// initKeySetHistory creates the admin key set history when the database does
+// not house it yet.  The history of databases which predate it is recreated
+// from the stored blocks of the main chain, or starts at the end of the main
+// chain when the blocks have been pruned.
+func (b *BlockChain) initKeySetHistory() error {
+	return b.db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		if meta.Bucket(keySetHistoryBucketName) != nil {
+			return nil
+		}
+		bucket, err := meta.CreateBucket(keySetHistoryBucketName)
+		if err != nil {
+			return err
+		}
+		height := b.bestNode.height
+		if height == 0 {
+			return dbPutKeySetHistoryStart(dbTx, 0)
+		}
+
+		// Collect the changes the admin transactions of the main chain
+		// made to the admin key sets since the genesis block.
+		log.Infof("Recreating the admin key set history of %d blocks",
+			height)
+		entries := make(map[uint32][]byte)
+		stored, err := dbForEachMainChainBlock(dbTx, height,
+			func(block *provautil.Block) error {
+				ops := blockKeySetOps(block)
+				if len(ops) > 0 {
+					entries[block.Height()] = serializeKeySetOps(ops)
+				}
+				return nil
+			})
+		if err != nil {
+			return err
+		}
+		if !stored {
+			log.Warnf("Admin key set history starts at height %d "+
+				"since the blocks before it have been pruned",
+				height)
+			return dbPutKeySetHistoryStart(dbTx, height)
+		}
+
+		for entryHeight, serialized := range entries {
+			var key [4]byte
+			binary.BigEndian.PutUint32(key[:], entryHeight)
+			if err := bucket.Put(key[:], serialized); err != nil {
+				return err
+			}
+		}
+		return dbPutKeySetHistoryStart(dbTx, 0)
+	})
+}
+
+// AdminKeySetAt returns the admin key sets which were authorized after the main
+// chain block at the passed height was connected.  They are recreated from the
+// current key sets by undoing the changes recorded in the admin key set
+// history, so the height must not be before the start of the history.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) AdminKeySetAt(height uint32) (map[btcec.KeySetType]btcec.PublicKeySet, error) {
+	b.chainLock.RLock()
+	defer b.chainLock.RUnlock()
+
+	if height > b.bestNode.height {
+		return nil, fmt.Errorf("height %d is after the end of the main "+
+			"chain at height %d", height, b.bestNode.height)
+	}
+
+	keySets := btcec.DeepCopy(b.adminKeySets)
+	err := b.db.View(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		serializedStart := meta.Get(keySetHistoryStartKeyName)
+		if len(serializedStart) != 4 {
+			return database.Error{
+				ErrorCode:   database.ErrCorruption,
+				Description: "missing admin key set history start",
+			}
+		}
+		start := byteOrder.Uint32(serializedStart)
+		if height < start {
+			return fmt.Errorf("height %d is before the start of the "+
+				"admin key set history at height %d", height,
+				start)
+		}
+
+		// Undo the changes made after the height from the most recent
+		// to the oldest.
+		cursor := meta.Bucket(keySetHistoryBucketName).Cursor()
+		for ok := cursor.Last(); ok; ok = cursor.Prev() {
+			if binary.BigEndian.Uint32(cursor.Key()) <= height {
+				break
+			}
+			ops, err := deserializeKeySetOps(cursor.Value())
+			if err != nil {
+				return err
+			}
+			for i := len(ops) - 1; i >= 0; i-- {
+				op := &ops[i]
+				if op.add {
+					pos := keySets[op.keySet].Pos(op.pubKey)
+					keySets[op.keySet] = keySets[op.keySet].Remove(pos)
+				} else {
+					keySets[op.keySet] = keySets[op.keySet].Add(op.pubKey)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keySets, nil
+}
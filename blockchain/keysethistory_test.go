@@ -0,0 +1,160 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+// sameKeySets returns whether the passed admin key sets hold the same keys,
+// regardless of their order.
+func sameKeySets(a, b map[btcec.KeySetType]btcec.PublicKeySet) bool {
+	for _, keySet := range []btcec.KeySetType{btcec.RootKeySet,
+		btcec.ProvisionKeySet, btcec.IssueKeySet, btcec.ValidateKeySet} {
+
+		if len(a[keySet]) != len(b[keySet]) {
+			return false
+		}
+		for i := range a[keySet] {
+			if b[keySet].Pos(&a[keySet][i]) == -1 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// TestAdminKeySetAt ensures the admin key sets recreated from the history of a
+// chain which processed the blocks generated by the fullblocktests package,
+// which change the admin key sets and reorganize the chain, match those of a
+// chain which only connected the blocks of the main chain in order, including
+// after the history of a database which predates it is recreated from the
+// stored blocks.
+func TestAdminKeySetAt(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("keysethistory",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	for _, test := range tests {
+		for _, item := range test {
+			var block *provautil.Block
+			switch item := item.(type) {
+			case fullblocktests.AcceptedBlock:
+				block = provautil.NewBlock(item.Block)
+			case fullblocktests.RejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			case fullblocktests.OrphanOrRejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			default:
+				continue
+			}
+			chain.ProcessBlock(block, blockchain.BFNone)
+		}
+	}
+	best := chain.BestSnapshot()
+
+	dir, err := ioutil.TempDir("", "keysethistory")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	replay, db, err := snapshotChainSetup(dir, nil)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer db.Close()
+
+	var changes int
+	var keySets []map[btcec.KeySetType]btcec.PublicKeySet
+	prev := replay.AdminKeySets()
+	for height := uint32(0); height <= best.Height; height++ {
+		if height > 0 {
+			block, err := chain.BlockByHeight(height)
+			if err != nil {
+				t.Fatalf("BlockByHeight: %v", err)
+			}
+			_, _, err = replay.ProcessBlock(block, blockchain.BFNone)
+			if err != nil {
+				t.Fatalf("ProcessBlock: %v", err)
+			}
+		}
+		want := replay.AdminKeySets()
+		keySets = append(keySets, btcec.DeepCopy(want))
+		if !sameKeySets(want, prev) {
+			changes++
+		}
+		prev = btcec.DeepCopy(want)
+
+		got, err := chain.AdminKeySetAt(height)
+		if err != nil {
+			t.Fatalf("AdminKeySetAt(%d): %v", height, err)
+		}
+		if !sameKeySets(got, want) {
+			t.Fatalf("AdminKeySetAt(%d): got %v, want %v", height,
+				got, want)
+		}
+	}
+	if changes == 0 {
+		t.Fatal("the admin key sets never changed")
+	}
+
+	if _, err := chain.AdminKeySetAt(best.Height + 1); err == nil {
+		t.Fatal("AdminKeySetAt: no error for height after the end of " +
+			"the main chain")
+	}
+
+	// Remove the history as if the database predates it and ensure
+	// loading the chain again recreates it for every height.
+	err = db.Update(func(dbTx database.Tx) error {
+		meta := dbTx.Metadata()
+		err := meta.DeleteBucket([]byte("keysethistory"))
+		if err != nil {
+			return err
+		}
+		return meta.Delete([]byte("keysethistorystart"))
+	})
+	if err != nil {
+		t.Fatalf("failed to remove the admin key set history: %v", err)
+	}
+	params := chaincfg.RegressionNetParams
+	reloaded, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  blockchain.NewMedianTime(),
+		SigCache:    txscript.NewSigCache(1000),
+	})
+	if err != nil {
+		t.Fatalf("Failed to load chain instance: %v", err)
+	}
+	for height, want := range keySets {
+		got, err := reloaded.AdminKeySetAt(uint32(height))
+		if err != nil {
+			t.Fatalf("AdminKeySetAt(%d) after recreating the "+
+				"history: %v", height, err)
+		}
+		if !sameKeySets(got, want) {
+			t.Fatalf("AdminKeySetAt(%d) after recreating the "+
+				"history: got %v, want %v", height, got, want)
+		}
+	}
+}
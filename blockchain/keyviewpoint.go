@@ -220,7 +220,10 @@ func (view *KeyViewpoint) disconnectTransactions(block *provautil.Block) error {
 					}
 				}
 			} else {
-				for i := 0; i < len(adminOutputs); i++ {
+				// Loop backwards through the admin operations so
+				// the last key ID is restored to its value before
+				// the transaction when it provisions several.
+				for i := len(adminOutputs) - 1; i >= 0; i-- {
 					isAddOp, keySetType, pubKey,
 						keyID := txscript.ExtractAdminOpData(adminOutputs[i])
 					if keySetType == btcec.ASPKeySet {
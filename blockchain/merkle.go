@@ -110,3 +110,33 @@ func BuildMerkleTreeStore(transactions []*provautil.Tx) []*chainhash.Hash {
 
 	return merkles
 }
+
+// BuildMerkleTreeLayers creates the layers of a merkle tree over the passed
+// hashes.  The first layer holds the passed hashes themselves and every
+// following layer holds the parents of the nodes in the layer before it, so the
+// last layer holds only the merkle root.  As in BuildMerkleTreeStore, a parent
+// node with only a single left child is calculated by concatenating the left
+// node with itself before hashing.  Unlike BuildMerkleTreeStore, the layers are
+// not padded to a power of two, which matches the partial merkle trees of
+// merkle blocks.
+func BuildMerkleTreeLayers(hashes []chainhash.Hash) [][]chainhash.Hash {
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	layers := [][]chainhash.Hash{hashes}
+	for layer := hashes; len(layer) > 1; {
+		parents := make([]chainhash.Hash, (len(layer)+1)/2)
+		for i := range parents {
+			left := &layer[i*2]
+			right := left
+			if i*2+1 < len(layer) {
+				right = &layer[i*2+1]
+			}
+			parents[i] = *HashMerkleBranches(left, right)
+		}
+		layers = append(layers, parents)
+		layer = parents
+	}
+	return layers
+}
@@ -26,3 +26,47 @@ func TestMerkle(t *testing.T) {
 			"got %v, want %v", calculatedMerkleRoot, wantMerkle)
 	}
 }
+
+// TestMerkleTreeLayers ensures the layers built by BuildMerkleTreeLayers over
+// the stripped and signature hashes of the transactions commit to the same
+// merkle root as BuildMerkleTreeStore.
+func TestMerkleTreeLayers(t *testing.T) {
+	block := provautil.NewBlock(&SomeBlock)
+	txns := block.Transactions()
+	stripped := make([]chainhash.Hash, 0, len(txns))
+	withSig := make([]chainhash.Hash, 0, len(txns))
+	for _, tx := range txns {
+		stripped = append(stripped, *tx.Hash())
+		withSig = append(withSig, *tx.HashWithSig())
+	}
+
+	strippedLayers := blockchain.BuildMerkleTreeLayers(stripped)
+	withSigLayers := blockchain.BuildMerkleTreeLayers(withSig)
+	for _, layers := range [][][]chainhash.Hash{strippedLayers, withSigLayers} {
+		if len(layers[len(layers)-1]) != 1 {
+			t.Fatalf("BuildMerkleTreeLayers: last layer has %d nodes",
+				len(layers[len(layers)-1]))
+		}
+		for i := 1; i < len(layers); i++ {
+			if want := (len(layers[i-1]) + 1) / 2; len(layers[i]) != want {
+				t.Fatalf("BuildMerkleTreeLayers: layer %d has %d "+
+					"nodes, want %d", i, len(layers[i]), want)
+			}
+		}
+	}
+
+	merkles := blockchain.BuildMerkleTreeStore(txns)
+	want := merkles[len(merkles)-1]
+	got := blockchain.HashMerkleBranches(
+		&strippedLayers[len(strippedLayers)-1][0],
+		&withSigLayers[len(withSigLayers)-1][0])
+	if !got.IsEqual(want) {
+		t.Errorf("BuildMerkleTreeLayers: merkle root mismatch - got %v, "+
+			"want %v", got, want)
+	}
+
+	if layers := blockchain.BuildMerkleTreeLayers(nil); layers != nil {
+		t.Errorf("BuildMerkleTreeLayers: unexpected layers %v for no "+
+			"hashes", layers)
+	}
+}
@@ -7,6 +7,7 @@ package blockchain
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/database"
@@ -34,6 +35,11 @@ const (
 	// without modifying the current state.
 	BFDryRun
 
+	// BFNoValidateKeyCheck may be set to indicate the checks of the validate
+	// key which signed the block will not be performed.  This is useful to
+	// check unsigned block templates which are signed once they are solved.
+	BFNoValidateKeyCheck
+
 	// BFNone is a convenience value to specifically indicate no flags.
 	BFNone BehaviorFlags = 0
 )
@@ -130,6 +136,9 @@ func (b *BlockChain) ProcessBlock(block *provautil.Block, flags BehaviorFlags) (
 	b.chainLock.Lock()
 	defer b.chainLock.Unlock()
 
+	// Drop the validation times of the block unless it is connected.
+	defer b.timer.discard()
+
 	fastAdd := flags&BFFastAdd == BFFastAdd
 	dryRun := flags&BFDryRun == BFDryRun
 
@@ -153,7 +162,9 @@ func (b *BlockChain) ProcessBlock(block *provautil.Block, flags BehaviorFlags) (
 	}
 
 	// Perform preliminary sanity checks on the block and its transactions.
+	sanityStart := time.Now()
 	err = checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource, flags)
+	b.timer.since(PhaseHeaderChecks, sanityStart)
 	if err != nil {
 		return false, false, err
 	}
@@ -165,13 +176,12 @@ func (b *BlockChain) ProcessBlock(block *provautil.Block, flags BehaviorFlags) (
 	// used to eat memory, and ensuring expected (versus claimed) proof of
 	// work requirements since the previous checkpoint are met.
 	blockHeader := &block.MsgBlock().Header
-	checkpointBlock, err := b.findPreviousCheckpoint()
+	checkpointHeader, err := b.findPreviousCheckpoint()
 	if err != nil {
 		return false, false, err
 	}
-	if checkpointBlock != nil {
+	if checkpointHeader != nil {
 		// Ensure the block timestamp is after the checkpoint timestamp.
-		checkpointHeader := &checkpointBlock.MsgBlock().Header
 		checkpointTime := checkpointHeader.Timestamp
 		if blockHeader.Timestamp.Before(checkpointTime) {
 			str := fmt.Sprintf("block %v has timestamp %v before "+
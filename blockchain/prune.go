@@ -0,0 +1,60 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"github.com/bitgo/prova/database"
+)
+
+// MinPruneDepth is the minimum number of the most recent main chain blocks
+// whose data is retained when pruning is enabled.  It ensures the blocks needed
+// to handle reorganizations and to serve peers which are catching up with the
+// recent history remain available.
+const MinPruneDepth = 288
+
+// PruneDepth returns the number of the most recent main chain blocks whose data
+// is retained.  It is zero when pruning is disabled.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) PruneDepth() uint32 {
+	return b.pruneDepth
+}
+
+// pruneBlocks removes the data of the blocks which are more than the prune
+// depth behind the main chain block at the passed height, when pruning is
+// enabled.  The headers of the pruned blocks, the utxo set, the spend journal,
+// and the admin state are retained, so the chain state and the checkpoints are
+// unaffected.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) pruneBlocks(dbTx database.Tx, height uint32) error {
+	if b.pruneDepth == 0 || height <= b.pruneDepth {
+		return nil
+	}
+
+	hash, err := dbFetchHashByHeight(dbTx, height-b.pruneDepth)
+	if err != nil {
+		return err
+	}
+	return dbTx.PruneBlocks(hash)
+}
+
+// archiveBlocks moves the data of the blocks which are more than the archive
+// depth behind the main chain block at the passed height to the cold storage
+// of the database, when archiving is enabled.  The archived blocks remain
+// available, so nothing else is affected.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) archiveBlocks(dbTx database.Tx, height uint32) error {
+	if b.archiveDepth == 0 || height <= b.archiveDepth {
+		return nil
+	}
+
+	hash, err := dbFetchHashByHeight(dbTx, height-b.archiveDepth)
+	if err != nil {
+		return err
+	}
+	return dbTx.ArchiveBlocks(hash)
+}
@@ -0,0 +1,56 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+)
+
+// TestPruneDepth ensures a chain instance is only created with a prune depth
+// which retains at least the minimum number of blocks.
+func TestPruneDepth(t *testing.T) {
+	dbPath := filepath.Join(os.TempDir(), "prunedepth")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(testDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("error creating db: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	tests := []struct {
+		depth uint32
+		valid bool
+	}{
+		{depth: 0, valid: true},
+		{depth: 1, valid: false},
+		{depth: blockchain.MinPruneDepth - 1, valid: false},
+		{depth: blockchain.MinPruneDepth, valid: true},
+		{depth: blockchain.MinPruneDepth * 10, valid: true},
+	}
+	for _, test := range tests {
+		chain, err := blockchain.New(&blockchain.Config{
+			DB:          db,
+			ChainParams: &chaincfg.RegressionNetParams,
+			TimeSource:  blockchain.NewMedianTime(),
+			PruneDepth:  test.depth,
+		})
+		if test.valid != (err == nil) {
+			t.Errorf("New (depth %d): got error %v, want valid %v",
+				test.depth, err, test.valid)
+			continue
+		}
+		if err == nil && chain.PruneDepth() != test.depth {
+			t.Errorf("PruneDepth: got %d, want %d",
+				chain.PruneDepth(), test.depth)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// reindexLogInterval is the minimum amount of time between the
+	// progress messages which are logged while the stored blocks are
+	// processed to rebuild the chain state.
+	reindexLogInterval = 10 * time.Second
+)
+
+var (
+	// reindexBlocksKeyName is the name of the db key used to house the
+	// hashes of the stored blocks which are processed to rebuild the chain
+	// state after it was reset.  The key is removed once all of them have
+	// been processed, so an interrupted rebuild resumes on the next start.
+	reindexBlocksKeyName = []byte("reindexblocks")
+
+	// chainStateBucketNames are the names of the db buckets which house
+	// the chain state that is rebuilt from the stored blocks.
+	chainStateBucketNames = [][]byte{
+		hashIndexBucketName,
+		heightIndexBucketName,
+		spendJournalBucketName,
+		utxoSetBucketName,
+		chainTipsBucketName,
+		keySetHistoryBucketName,
+		issuanceHistoryBucketName,
+		keyIDRegistryBucketName,
+		validatorLastBlockBucketName,
+		validatorLastBlockUndoBucketName,
+	}
+
+	// chainStateKeyNames are the names of the db keys which house the
+	// chain state that is rebuilt from the stored blocks.
+	chainStateKeyNames = [][]byte{
+		chainStateKeyName,
+		keySetBucketName,
+		keySetHistoryStartKeyName,
+		issuanceHistoryStartKeyName,
+		keyIDRegistryStartKeyName,
+		validatorLastBlockStartKeyName,
+	}
+)
+
+// serializeReindexBlocks returns the serialization of the passed block hashes
+// for the reindex blocks key, which is simply their concatenation.
+func serializeReindexBlocks(hashes []chainhash.Hash) []byte {
+	serialized := make([]byte, 0, len(hashes)*chainhash.HashSize)
+	for i := range hashes {
+		serialized = append(serialized, hashes[i][:]...)
+	}
+	return serialized
+}
+
+// deserializeReindexBlocks decodes the block hashes of the reindex blocks key.
+func deserializeReindexBlocks(serialized []byte) ([]chainhash.Hash, error) {
+	if len(serialized)%chainhash.HashSize != 0 {
+		return nil, database.Error{
+			ErrorCode: database.ErrCorruption,
+			Description: fmt.Sprintf("corrupt reindex blocks of "+
+				"length %d", len(serialized)),
+		}
+	}
+	hashes := make([]chainhash.Hash, len(serialized)/chainhash.HashSize)
+	for i := range hashes {
+		copy(hashes[i][:], serialized[i*chainhash.HashSize:])
+	}
+	return hashes, nil
+}
+
+// dbFetchMainChainHashes uses an existing database transaction to fetch the
+// hashes of all blocks in the main chain from the block index, ordered by
+// height.
+func dbFetchMainChainHashes(dbTx database.Tx) ([]chainhash.Hash, error) {
+	heightIndex := dbTx.Metadata().Bucket(heightIndexBucketName)
+	if heightIndex == nil {
+		return nil, nil
+	}
+	var tipHeight uint32
+	err := heightIndex.ForEach(func(k, _ []byte) error {
+		if len(k) == 4 && byteOrder.Uint32(k) > tipHeight {
+			tipHeight = byteOrder.Uint32(k)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]chainhash.Hash, 0, tipHeight+1)
+	for height := uint32(0); height <= tipHeight; height++ {
+		hash, err := dbFetchHashByHeight(dbTx, height)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, *hash)
+	}
+	return hashes, nil
+}
+
+// dbCheckReindexable uses an existing database transaction to ensure the
+// chain state is able to be rebuilt from the stored blocks with the passed
+// hashes.  That requires them to start with the genesis block followed by a
+// block with data which builds on it, which is not the case when blocks have
+// been pruned or the chain was bootstrapped from a utxo set snapshot.
+func dbCheckReindexable(dbTx database.Tx, hashes []chainhash.Hash) error {
+	errNotReindexable := errors.New("the chain state can't be rebuilt " +
+		"since the stored blocks don't start with the genesis block " +
+		"-- blocks have been pruned or the chain was bootstrapped " +
+		"from a utxo set snapshot")
+	header, err := dbFetchHeaderByHash(dbTx, &hashes[0])
+	if err != nil {
+		return err
+	}
+	if header.Height != 0 {
+		return errNotReindexable
+	}
+	if len(hashes) == 1 {
+		return nil
+	}
+
+	header, err = dbFetchHeaderByHash(dbTx, &hashes[1])
+	if err != nil {
+		return err
+	}
+	_, err = dbTx.FetchBlockRegion(&database.BlockRegion{
+		Hash: &hashes[1],
+		Len:  wire.MaxBlockHeaderPayload,
+	})
+	if isDbBlockPrunedErr(err) || header.PrevBlock != hashes[0] {
+		return errNotReindexable
+	}
+	return err
+}
+
+// ResetChainState removes the chain state from the passed database, so it is
+// rebuilt from the stored blocks without downloading them again when a chain
+// is next created with the database.  The chain state consists of the block
+// index of the main chain, the utxo set, the spend journal, the admin state and
+// the best chain state.
+//
+// When rebuildBlockIndex is set, the index of the stored blocks is rebuilt from
+// the block data first, and all stored blocks are processed again in the order
+// they were stored.  Otherwise, the blocks of the current main chain are.
+//
+// The optional indexes are left intact.  They follow the chain again once it
+// reaches their tips.
+func ResetChainState(db database.DB, rebuildBlockIndex bool) error {
+	return db.Update(func(dbTx database.Tx) error {
+		// A previous reset which has not been finished yet simply
+		// resumes, since the main chain has not been rebuilt.
+		meta := dbTx.Metadata()
+		if !rebuildBlockIndex && meta.Get(reindexBlocksKeyName) != nil {
+			log.Infof("Resuming the unfinished rebuild of the " +
+				"chain state")
+			return nil
+		}
+
+		var hashes []chainhash.Hash
+		var err error
+		if rebuildBlockIndex {
+			log.Infof("Rebuilding the block index from the block " +
+				"files")
+			hashes, err = dbTx.RebuildBlockIndex()
+		} else {
+			hashes, err = dbFetchMainChainHashes(dbTx)
+		}
+		if err != nil {
+			return err
+		}
+		if len(hashes) == 0 {
+			log.Infof("No stored blocks to rebuild the chain state from")
+			return nil
+		}
+		if err := dbCheckReindexable(dbTx, hashes); err != nil {
+			return err
+		}
+
+		for _, bucketName := range chainStateBucketNames {
+			if meta.Bucket(bucketName) == nil {
+				continue
+			}
+			if err := meta.DeleteBucket(bucketName); err != nil {
+				return err
+			}
+		}
+		for _, keyName := range chainStateKeyNames {
+			if err := meta.Delete(keyName); err != nil {
+				return err
+			}
+		}
+
+		// The genesis block is connected when the chain state is
+		// created.
+		log.Infof("Reset the chain state to rebuild it from %d stored "+
+			"blocks", len(hashes)-1)
+		return meta.Put(reindexBlocksKeyName,
+			serializeReindexBlocks(hashes[1:]))
+	})
+}
+
+// maybeFinishReindex processes the stored blocks which remain to be processed
+// after the chain state was reset by ResetChainState, if any, and logs the
+// progress while doing so.  The blocks are connected without notifying the
+// caller or the optional indexes since the chain is not yet set up.
+//
+// Blocks which are already known to the chain are skipped, so a rebuild which
+// was interrupted resumes with the first block which was not processed.
+// Blocks which fail to validate or whose parent is not available are skipped
+// too, the same way they are rejected when downloaded from peers.
+func (b *BlockChain) maybeFinishReindex() error {
+	var hashes []chainhash.Hash
+	err := b.db.View(func(dbTx database.Tx) error {
+		serialized := dbTx.Metadata().Get(reindexBlocksKeyName)
+		if serialized == nil {
+			return nil
+		}
+		var err error
+		hashes, err = deserializeReindexBlocks(serialized)
+		return err
+	})
+	if err != nil || hashes == nil {
+		return err
+	}
+
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	notifications, indexManager := b.notifications, b.indexManager
+	b.notifications, b.indexManager = nil, nil
+	defer func() {
+		b.notifications, b.indexManager = notifications, indexManager
+	}()
+
+	log.Infof("Rebuilding the chain state from %d stored blocks",
+		len(hashes))
+	lastLog := time.Now()
+	var numSkipped int
+	for i := range hashes {
+		skipped, err := b.processStoredBlock(&hashes[i])
+		if err != nil {
+			return err
+		}
+		if skipped {
+			numSkipped++
+		}
+
+		if time.Since(lastLog) >= reindexLogInterval {
+			log.Infof("Processed %d of %d stored blocks (height %d)",
+				i+1, len(hashes), b.bestNode.height)
+			lastLog = time.Now()
+		}
+	}
+
+	err = b.db.Update(func(dbTx database.Tx) error {
+		return dbTx.Metadata().Delete(reindexBlocksKeyName)
+	})
+	if err != nil {
+		return err
+	}
+	if numSkipped > 0 {
+		log.Warnf("Skipped %d stored blocks which could not be "+
+			"connected", numSkipped)
+	}
+	log.Infof("Rebuilt the chain state (height %d, hash %v)",
+		b.bestNode.height, b.bestNode.hash)
+	return nil
+}
+
+// processStoredBlock processes the stored block with the passed hash the same
+// way ProcessBlock does, except the block is allowed to be stored already and
+// is not added to the orphan pool.  Blocks which are already known are
+// ignored.  It returns whether or not the block was skipped because its parent
+// is not available or it failed to validate.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) processStoredBlock(hash *chainhash.Hash) (bool, error) {
+	defer b.timer.discard()
+
+	// Blocks which were processed before an interruption are either in
+	// the main chain or a side chain which is still in memory.
+	var block *provautil.Block
+	var known bool
+	err := b.db.View(func(dbTx database.Tx) error {
+		if _, ok := b.index[*hash]; ok || dbMainChainHasBlock(dbTx, hash) {
+			known = true
+			return nil
+		}
+		blockBytes, err := dbTx.FetchBlock(hash)
+		if err != nil {
+			return err
+		}
+		block, err = provautil.NewBlockFromBytes(blockBytes)
+		return err
+	})
+	if err != nil || known {
+		return false, err
+	}
+
+	prevHash := &block.MsgBlock().Header.PrevBlock
+	prevExists, err := b.blockExists(prevHash)
+	if err != nil {
+		return false, err
+	}
+	if !prevExists {
+		log.Warnf("Skipping stored block %v since its parent %v is not "+
+			"available", hash, prevHash)
+		return true, nil
+	}
+
+	err = checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource,
+		BFNone)
+	if err == nil {
+		_, err = b.maybeAcceptBlock(block, BFNone)
+	}
+	if _, ok := err.(RuleError); ok {
+		log.Warnf("Skipping stored block %v: %v", hash, err)
+		return true, nil
+	}
+	return false, err
+}
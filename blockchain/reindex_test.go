@@ -0,0 +1,131 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+// TestResetChainState ensures the chain state of the chain built from the
+// blocks generated by the fullblocktests package is rebuilt from the stored
+// blocks after it was reset, both from the main chain and from the block
+// files when index records of blocks were lost.
+func TestResetChainState(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	dbPath := filepath.Join(os.TempDir(), "resetchainstate")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(testDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("error creating db: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	params := chaincfg.RegressionNetParams
+	newChain := func() *blockchain.BlockChain {
+		chain, err := blockchain.New(&blockchain.Config{
+			DB:          db,
+			ChainParams: &params,
+			TimeSource:  blockchain.NewMedianTime(),
+			SigCache:    txscript.NewSigCache(1000),
+		})
+		if err != nil {
+			t.Fatalf("failed to create chain instance: %v", err)
+		}
+		return chain
+	}
+
+	// Resetting the chain state of an empty database is a no-op.
+	if err := blockchain.ResetChainState(db, false); err != nil {
+		t.Fatalf("ResetChainState: unexpected error: %v", err)
+	}
+	chain := newChain()
+	for _, test := range tests {
+		for _, item := range test {
+			switch item := item.(type) {
+			case fullblocktests.AcceptedBlock:
+				block := provautil.NewBlock(item.Block)
+				_, _, _ = chain.ProcessBlock(block, blockchain.BFNone)
+			case fullblocktests.RejectedBlock:
+				block := provautil.NewBlock(item.Block)
+				_, _, _ = chain.ProcessBlock(block, blockchain.BFNone)
+			}
+		}
+	}
+	best := chain.BestSnapshot()
+	if best.Height < 2 {
+		t.Fatalf("chain only has %d blocks", best.Height)
+	}
+
+	// checkRebuilt ensures a new chain rebuilds the chain state to the
+	// original best block and that it verifies.
+	checkRebuilt := func(name string) {
+		chain := newChain()
+		rebuilt := chain.BestSnapshot()
+		if *rebuilt.Hash != *best.Hash || rebuilt.Height != best.Height ||
+			rebuilt.TotalTxns != best.TotalTxns {
+
+			t.Fatalf("%s: got best state %+v after rebuilding, want "+
+				"%+v", name, rebuilt, best)
+		}
+		result, err := chain.VerifyChain(blockchain.VerifyConnect,
+			best.Height, false)
+		if err != nil || !result.Verified() {
+			t.Fatalf("%s: got verification %+v, %v after "+
+				"rebuilding", name, result, err)
+		}
+	}
+
+	// Rebuild the chain state from the blocks of the main chain.  A reset
+	// which has not been finished yet is resumed.
+	if err := blockchain.ResetChainState(db, false); err != nil {
+		t.Fatalf("ResetChainState: unexpected error: %v", err)
+	}
+	if err := blockchain.ResetChainState(db, false); err != nil {
+		t.Fatalf("ResetChainState (resume): unexpected error: %v", err)
+	}
+	err = db.View(func(dbTx database.Tx) error {
+		if dbTx.Metadata().Get([]byte("chainstate")) != nil {
+			t.Fatal("ResetChainState: best chain state not removed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+	checkRebuilt("ResetChainState")
+
+	// Remove the index record of a block in the main chain and rebuild
+	// both the block index and the chain state from the block files.
+	lostHash, err := chain.BlockHashByHeight(best.Height / 2)
+	if err != nil {
+		t.Fatalf("BlockHashByHeight: unexpected error: %v", err)
+	}
+	err = db.Update(func(dbTx database.Tx) error {
+		blockIdx := dbTx.Metadata().Bucket([]byte("ffldb-blockidx"))
+		return blockIdx.Delete(lostHash[:])
+	})
+	if err != nil {
+		t.Fatalf("Delete: unexpected error: %v", err)
+	}
+	if err := blockchain.ResetChainState(db, true); err != nil {
+		t.Fatalf("ResetChainState (block index): unexpected error: %v",
+			err)
+	}
+	checkRebuilt("ResetChainState (block index)")
+}
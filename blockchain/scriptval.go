@@ -14,6 +14,35 @@ import (
 	"runtime"
 )
 
+// scriptBatchesPerWorker is the number of batches the inputs to validate are
+// split into per validation goroutine.  Handing the goroutines batches of
+// inputs, which span transactions, rather than single inputs amortizes the
+// synchronization cost over many signature checks, while using several batches
+// per goroutine keeps them balanced when some inputs are more expensive to
+// validate than others.
+const scriptBatchesPerWorker = 4
+
+// DefaultScriptWorkers returns the default number of goroutines used to
+// validate scripts, which is three per processor core.
+func DefaultScriptWorkers() int {
+	workers := runtime.NumCPU() * 3
+	if workers <= 0 {
+		workers = 1
+	}
+	return workers
+}
+
+// ScriptWorkers returns the number of goroutines used to validate the scripts
+// of the transactions in a block.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ScriptWorkers() int {
+	if b.scriptWorkers == 0 {
+		return DefaultScriptWorkers()
+	}
+	return b.scriptWorkers
+}
+
 // txValidateItem holds a transaction along with which input to validate.
 type txValidateItem struct {
 	txInIndex int                   // the index of the input to be validated
@@ -26,7 +55,7 @@ type txValidateItem struct {
 // inputs.  It provides several channels for communication and a processing
 // function that is intended to be in run multiple goroutines.
 type txValidator struct {
-	validateChan chan *txValidateItem
+	validateChan chan []*txValidateItem
 	quitChan     chan struct{}
 	resultChan   chan error
 	utxoView     *UtxoViewpoint
@@ -34,6 +63,7 @@ type txValidator struct {
 	flags        txscript.ScriptFlags
 	sigCache     *txscript.SigCache
 	hashCache    *txscript.HashCache
+	workers      int
 }
 
 // sendResult sends the result of a script pair validation on the internal
@@ -47,136 +77,161 @@ func (v *txValidator) sendResult(result error) {
 	}
 }
 
-// validateHandler consumes items to validate from the internal validate channel
-// and returns the result of the validation on the internal result channel. It
-// must be run as a goroutine.
-func (v *txValidator) validateHandler() {
-out:
-	for {
-		select {
-		case txVI := <-v.validateChan:
-			// Ensure the referenced input transaction is available.
-			txIn := txVI.txIn
-			originTxHash := &txIn.PreviousOutPoint.Hash
-			originTxIndex := txIn.PreviousOutPoint.Index
-			txEntry := v.utxoView.LookupEntry(originTxHash)
-			if txEntry == nil {
-				str := fmt.Sprintf("unable to find input "+
-					"transaction %v referenced from "+
-					"transaction %v", originTxHash,
-					txVI.tx.Hash())
-				err := ruleError(ErrMissingTx, str)
-				v.sendResult(err)
-				break out
-			}
+// validateItem validates the script of the transaction input of the passed
+// item.  The signature checks which are able to be deferred are added to the
+// passed batch verifier, which must be verified for the input to be valid.
+func (v *txValidator) validateItem(txVI *txValidateItem, batch *txscript.BatchVerifier) error {
+	// Ensure the referenced input transaction is available.
+	txIn := txVI.txIn
+	originTxHash := &txIn.PreviousOutPoint.Hash
+	originTxIndex := txIn.PreviousOutPoint.Index
+	txEntry := v.utxoView.LookupEntry(originTxHash)
+	if txEntry == nil {
+		str := fmt.Sprintf("unable to find input "+
+			"transaction %v referenced from "+
+			"transaction %v", originTxHash,
+			txVI.tx.Hash())
+		return ruleError(ErrMissingTx, str)
+	}
 
-			// Ensure the referenced input transaction public key
-			// script is available.
-			pkScript := txEntry.PkScriptByIndex(originTxIndex)
-			if pkScript == nil {
-				str := fmt.Sprintf("unable to find unspent "+
-					"output %v script referenced from "+
-					"transaction %s:%d",
-					txIn.PreviousOutPoint, txVI.tx.Hash(),
-					txVI.txInIndex)
-				err := ruleError(ErrBadTxInput, str)
-				v.sendResult(err)
-				break out
-			}
+	// Ensure the referenced input transaction public key
+	// script is available.
+	pkScript := txEntry.PkScriptByIndex(originTxIndex)
+	if pkScript == nil {
+		str := fmt.Sprintf("unable to find unspent "+
+			"output %v script referenced from "+
+			"transaction %s:%d",
+			txIn.PreviousOutPoint, txVI.tx.Hash(),
+			txVI.txInIndex)
+		return ruleError(ErrBadTxInput, str)
+	}
 
-			// Before passing the script to the VM, we check whether it is an Prova script.
-			pops, err := txscript.ParseScript(pkScript)
-			if err != nil {
-				str := fmt.Sprintf("failed to parse script %s: %v", originTxHash, err)
-				err := ruleError(ErrScriptMalformed, str)
-				v.sendResult(err)
-				break out
-			}
-			// If script is Prova script, we replace all keyIDs with pubKeyHashes.
-			scriptType := txscript.TypeOfScript(pops)
-			if scriptType == txscript.ProvaTy || scriptType == txscript.GeneralProvaTy {
-				keyIDs, err := txscript.ExtractKeyIDs(pops)
-				if err != nil {
-					str := fmt.Sprintf("failed to extract keyIDs %s: %v", originTxHash, err)
-					err := ruleError(ErrScriptMalformed, str)
-					v.sendResult(err)
-					break out
-				}
-				keyIdMap := v.keyView.LookupKeyIDs(keyIDs)
-				err = txscript.ReplaceKeyIDs(pops, keyIdMap)
-				if err != nil {
-					str := fmt.Sprintf("failed to replace keyIDs %v, %v in %s", keyIDs[0], keyIDs[1], originTxHash)
-					err := ruleError(ErrScriptMalformed, str)
-					v.sendResult(err)
-					break out
-				}
-				pkScript, err = txscript.UnparseScript(pops)
-				if err != nil {
-					str := fmt.Sprintf("failed to unparse script %s: %v", originTxHash, err)
-					err := ruleError(ErrScriptMalformed, str)
-					v.sendResult(err)
-					break out
-				}
-			}
+	// Before passing the script to the VM, we check whether it is an Prova script.
+	pops, err := txscript.ParseScript(pkScript)
+	if err != nil {
+		str := fmt.Sprintf("failed to parse script %s: %v", originTxHash, err)
+		return ruleError(ErrScriptMalformed, str)
+	}
+	// If script is Prova script, we replace all keyIDs with pubKeyHashes.
+	scriptType := txscript.TypeOfScript(pops)
+	if scriptType == txscript.ProvaTy || scriptType == txscript.GeneralProvaTy ||
+		scriptType == txscript.ProvaTimelockTy {
+		keyIDs, err := txscript.ExtractKeyIDs(pops)
+		if err != nil {
+			str := fmt.Sprintf("failed to extract keyIDs %s: %v", originTxHash, err)
+			return ruleError(ErrScriptMalformed, str)
+		}
+		keyIdMap := v.keyView.LookupKeyIDs(keyIDs)
+		err = txscript.ReplaceKeyIDs(pops, keyIdMap)
+		if err != nil {
+			str := fmt.Sprintf("failed to replace keyIDs %v, %v in %s", keyIDs[0], keyIDs[1], originTxHash)
+			return ruleError(ErrScriptMalformed, str)
+		}
+		pkScript, err = txscript.UnparseScript(pops)
+		if err != nil {
+			str := fmt.Sprintf("failed to unparse script %s: %v", originTxHash, err)
+			return ruleError(ErrScriptMalformed, str)
+		}
+	}
 
-			// If script is Prova admin script, we replace the threadID with pubKeyHashes.
-			if txscript.TypeOfScript(pops) == txscript.ProvaAdminTy {
-				threadID, err := txscript.ExtractThreadID(pops)
-				if err != nil {
-					str := fmt.Sprintf("failed to extract threadID %s: %v", originTxHash, err)
-					err := ruleError(ErrScriptMalformed, str)
-					v.sendResult(err)
-					break out
-				}
-				keyHashes := v.keyView.GetAdminKeyHashes(threadID)
-				pkScript, err = txscript.ThreadPkScript(keyHashes)
-				if err != nil {
-					str := fmt.Sprintf("failed to replace threadID %s: %v", originTxHash, err)
-					err := ruleError(ErrScriptMalformed, str)
-					v.sendResult(err)
-					break out
-				}
-			}
+	// If script is Prova admin script, we replace the threadID with pubKeyHashes.
+	if txscript.TypeOfScript(pops) == txscript.ProvaAdminTy {
+		threadID, err := txscript.ExtractThreadID(pops)
+		if err != nil {
+			str := fmt.Sprintf("failed to extract threadID %s: %v", originTxHash, err)
+			return ruleError(ErrScriptMalformed, str)
+		}
+		keyHashes := v.keyView.GetAdminKeyHashes(threadID)
+		pkScript, err = txscript.ThreadPkScript(keyHashes)
+		if err != nil {
+			str := fmt.Sprintf("failed to replace threadID %s: %v", originTxHash, err)
+			return ruleError(ErrScriptMalformed, str)
+		}
+	}
 
-			// Create a new script engine for the script pair.
-			sigScript := txIn.SignatureScript
-			inputAmount := txEntry.AmountByIndex(originTxIndex)
-			vm, err := txscript.NewEngine(pkScript, txVI.tx.MsgTx(),
-				txVI.txInIndex, v.flags, v.sigCache, txVI.sigHashes, inputAmount)
-			if err != nil {
-				str := fmt.Sprintf("failed to parse input "+
-					"%s:%d which references output %s:%d - "+
-					"%v (input script bytes %x, prev output "+
-					"script bytes %x)", txVI.tx.Hash(),
-					txVI.txInIndex, originTxHash,
-					originTxIndex, err, sigScript, pkScript)
-				err := ruleError(ErrScriptMalformed, str)
-				v.sendResult(err)
-				break out
-			}
+	// Create a new script engine for the script pair.
+	sigScript := txIn.SignatureScript
+	inputAmount := txEntry.AmountByIndex(originTxIndex)
+	vm, err := txscript.NewEngine(pkScript, txVI.tx.MsgTx(),
+		txVI.txInIndex, v.flags, v.sigCache, txVI.sigHashes, inputAmount)
+	if err != nil {
+		str := fmt.Sprintf("failed to parse input "+
+			"%s:%d which references output %s:%d - "+
+			"%v (input script bytes %x, prev output "+
+			"script bytes %x)", txVI.tx.Hash(),
+			txVI.txInIndex, originTxHash,
+			originTxIndex, err, sigScript, pkScript)
+		return ruleError(ErrScriptMalformed, str)
+	}
+	vm.SetBatchVerifier(batch)
+
+	// Execute the script pair.
+	if err := vm.Execute(); err != nil {
+		str := fmt.Sprintf("failed to validate input "+
+			"%s:%d which references output %s:%d - "+
+			"%v (input script bytes %x, prev output "+
+			"script bytes %x)", txVI.tx.Hash(),
+			txVI.txInIndex, originTxHash,
+			originTxIndex, err, sigScript, pkScript)
+		return ruleError(ErrScriptValidation, str)
+	}
 
-			// Execute the script pair.
-			if err := vm.Execute(); err != nil {
-				str := fmt.Sprintf("failed to validate input "+
-					"%s:%d which references output %s:%d - "+
-					"%v (input script bytes %x, prev output "+
-					"script bytes %x)", txVI.tx.Hash(),
-					txVI.txInIndex, originTxHash,
-					originTxIndex, err, sigScript, pkScript)
-				err := ruleError(ErrScriptValidation, str)
-				v.sendResult(err)
+	return nil
+}
+
+// validateBatch validates the scripts of the transaction inputs of the passed
+// batch, verifying the signature checks deferred by the scripts of all of them
+// together once the scripts executed.
+func (v *txValidator) validateBatch(batch []*txValidateItem) error {
+	verifier := txscript.NewBatchVerifier(v.sigCache)
+	for _, txVI := range batch {
+		if err := v.validateItem(txVI, verifier); err != nil {
+			return err
+		}
+	}
+	if err := verifier.Verify(); err != nil {
+		str := fmt.Sprintf("failed to validate input: %v", err)
+		return ruleError(ErrScriptValidation, str)
+	}
+	return nil
+}
+
+// validateHandler consumes batches of items to validate from the internal
+// validate channel and returns the result of the validation of each batch on
+// the internal result channel.  It must be run as a goroutine.
+func (v *txValidator) validateHandler() {
+out:
+	for {
+		select {
+		case batch := <-v.validateChan:
+			err := v.validateBatch(batch)
+			v.sendResult(err)
+			if err != nil {
 				break out
 			}
 
-			// Validation succeeded.
-			v.sendResult(nil)
-
 		case <-v.quitChan:
 			break out
 		}
 	}
 }
 
+// scriptBatches splits the passed items into batches such that each of the
+// passed number of goroutines validates scriptBatchesPerWorker of them.
+func scriptBatches(items []*txValidateItem, workers int) [][]*txValidateItem {
+	numBatches := workers * scriptBatchesPerWorker
+	batchSize := (len(items) + numBatches - 1) / numBatches
+	batches := make([][]*txValidateItem, 0, numBatches)
+	for start := 0; start < len(items); start += batchSize {
+		end := start + batchSize
+		if end > len(items) {
+			end = len(items)
+		}
+		batches = append(batches, items[start:end])
+	}
+	return batches
+}
+
 // Validate validates the scripts for all of the passed transaction inputs using
 // multiple goroutines.
 func (v *txValidator) Validate(items []*txValidateItem) error {
@@ -184,46 +239,47 @@ func (v *txValidator) Validate(items []*txValidateItem) error {
 		return nil
 	}
 
-	// Limit the number of goroutines to do script validation based on the
-	// number of processor cores.  This help ensure the system stays
+	// Limit the number of goroutines to do script validation to the
+	// configured number of workers.  This help ensure the system stays
 	// reasonably responsive under heavy load.
-	maxGoRoutines := runtime.NumCPU() * 3
+	maxGoRoutines := v.workers
 	if maxGoRoutines <= 0 {
-		maxGoRoutines = 1
+		maxGoRoutines = DefaultScriptWorkers()
 	}
 	if maxGoRoutines > len(items) {
 		maxGoRoutines = len(items)
 	}
 
 	// Start up validation handlers that are used to asynchronously
-	// validate each transaction input.
+	// validate batches of transaction inputs.
 	for i := 0; i < maxGoRoutines; i++ {
 		go v.validateHandler()
 	}
 
-	// Validate each of the inputs.  The quit channel is closed when any
+	// Validate each of the batches.  The quit channel is closed when any
 	// errors occur so all processing goroutines exit regardless of which
 	// input had the validation error.
-	numInputs := len(items)
-	currentItem := 0
-	processedItems := 0
-	for processedItems < numInputs {
-		// Only send items while there are still items that need to
+	batches := scriptBatches(items, maxGoRoutines)
+	numBatches := len(batches)
+	currentBatch := 0
+	processedBatches := 0
+	for processedBatches < numBatches {
+		// Only send batches while there are still batches that need to
 		// be processed.  The select statement will never select a nil
 		// channel.
-		var validateChan chan *txValidateItem
-		var item *txValidateItem
-		if currentItem < numInputs {
+		var validateChan chan []*txValidateItem
+		var batch []*txValidateItem
+		if currentBatch < numBatches {
 			validateChan = v.validateChan
-			item = items[currentItem]
+			batch = batches[currentBatch]
 		}
 
 		select {
-		case validateChan <- item:
-			currentItem++
+		case validateChan <- batch:
+			currentBatch++
 
 		case err := <-v.resultChan:
-			processedItems++
+			processedBatches++
 			if err != nil {
 				close(v.quitChan)
 				return err
@@ -236,10 +292,11 @@ func (v *txValidator) Validate(items []*txValidateItem) error {
 }
 
 // newTxValidator returns a new instance of txValidator to be used for
-// validating transaction scripts asynchronously.
-func newTxValidator(utxoView *UtxoViewpoint, keyView *KeyViewpoint, flags txscript.ScriptFlags, sigCache *txscript.SigCache, hashCache *txscript.HashCache) *txValidator {
+// validating transaction scripts asynchronously with the passed number of
+// goroutines, or DefaultScriptWorkers when it is zero.
+func newTxValidator(utxoView *UtxoViewpoint, keyView *KeyViewpoint, flags txscript.ScriptFlags, sigCache *txscript.SigCache, hashCache *txscript.HashCache, workers int) *txValidator {
 	return &txValidator{
-		validateChan: make(chan *txValidateItem),
+		validateChan: make(chan []*txValidateItem),
 		quitChan:     make(chan struct{}),
 		resultChan:   make(chan error),
 		utxoView:     utxoView,
@@ -247,6 +304,7 @@ func newTxValidator(utxoView *UtxoViewpoint, keyView *KeyViewpoint, flags txscri
 		sigCache:     sigCache,
 		hashCache:    hashCache,
 		flags:        flags,
+		workers:      workers,
 	}
 }
 
@@ -287,13 +345,16 @@ func ValidateTransactionScripts(tx *provautil.Tx, utxoView *UtxoViewpoint, keyVi
 	}
 
 	// Validate all of the inputs.
-	validator := newTxValidator(utxoView, keyView, flags, sigCache, hashCache)
+	validator := newTxValidator(utxoView, keyView, flags, sigCache,
+		hashCache, 0)
 	return validator.Validate(txValItems)
 }
 
 // checkBlockScripts executes and validates the scripts for all transactions in
-// the passed block using multiple goroutines.
-func checkBlockScripts(block *provautil.Block, utxoView *UtxoViewpoint, keyView *KeyViewpoint, scriptFlags txscript.ScriptFlags, sigCache *txscript.SigCache, hashCache *txscript.HashCache) error {
+// the passed block using the passed number of goroutines, or
+// DefaultScriptWorkers when it is zero.  The inputs of all transactions are
+// validated in batches which span transactions.
+func checkBlockScripts(block *provautil.Block, utxoView *UtxoViewpoint, keyView *KeyViewpoint, scriptFlags txscript.ScriptFlags, sigCache *txscript.SigCache, hashCache *txscript.HashCache, workers int) error {
 	// Collect all of the transaction inputs and required information for
 	// validation for all transactions in the block into a single slice.
 	numInputs := 0
@@ -336,6 +397,20 @@ func checkBlockScripts(block *provautil.Block, utxoView *UtxoViewpoint, keyView
 	}
 
 	// Validate all of the inputs.
-	validator := newTxValidator(utxoView, keyView, scriptFlags, sigCache, hashCache)
-	return validator.Validate(txValItems)
+	validator := newTxValidator(utxoView, keyView, scriptFlags, sigCache,
+		hashCache, workers)
+	if err := validator.Validate(txValItems); err != nil {
+		return err
+	}
+
+	// The partial sighashes of the transactions of a valid block are not
+	// needed anymore once it was validated, so purge them from the cache,
+	// which is shared with the memory pool, to keep it from growing with
+	// every block.
+	if hashCache != nil {
+		for _, tx := range block.Transactions() {
+			hashCache.PurgeSigHashes(tx.Hash())
+		}
+	}
+	return nil
 }
@@ -7,10 +7,15 @@ package blockchain_test
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"runtime"
 	"testing"
 
 	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
 	"github.com/bitgo/prova/txscript"
 )
 
@@ -46,9 +51,76 @@ func CheckBlockScripts(t *testing.T) {
 
 	scriptFlags := txscript.ScriptBip16
 	err = blockchain.TstCheckBlockScripts(blocks[0], utxoView, nil, scriptFlags,
-		nil, nil)
+		nil, nil, 0)
 	if err != nil {
 		t.Errorf("Transaction script validation failed: %v\n", err)
 		return
 	}
 }
+
+// TestScriptWorkers ensures a chain instance is only created with a valid
+// number of script validation goroutines and uses the default when none is
+// given.
+func TestScriptWorkers(t *testing.T) {
+	dbPath := filepath.Join(os.TempDir(), "scriptworkers")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(testDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("error creating db: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	tests := []struct {
+		workers int
+		want    int
+		valid   bool
+	}{
+		{workers: -1, valid: false},
+		{workers: 0, want: blockchain.DefaultScriptWorkers(), valid: true},
+		{workers: 1, want: 1, valid: true},
+		{workers: 64, want: 64, valid: true},
+	}
+	for _, test := range tests {
+		chain, err := blockchain.New(&blockchain.Config{
+			DB:            db,
+			ChainParams:   &chaincfg.RegressionNetParams,
+			TimeSource:    blockchain.NewMedianTime(),
+			ScriptWorkers: test.workers,
+		})
+		if test.valid != (err == nil) {
+			t.Errorf("New (workers %d): got error %v, want valid %v",
+				test.workers, err, test.valid)
+			continue
+		}
+		if err == nil && chain.ScriptWorkers() != test.want {
+			t.Errorf("ScriptWorkers: got %d, want %d",
+				chain.ScriptWorkers(), test.want)
+		}
+	}
+}
+
+// TestScriptBatches ensures the inputs to validate are split into batches
+// which cover all of them in order and keep every goroutine busy.
+func TestScriptBatches(t *testing.T) {
+	tests := []struct {
+		numItems int
+		workers  int
+		want     []int
+	}{
+		{numItems: 1, workers: 1, want: []int{1}},
+		{numItems: 3, workers: 2, want: []int{1, 1, 1}},
+		{numItems: 8, workers: 2, want: []int{1, 1, 1, 1, 1, 1, 1, 1}},
+		{numItems: 10, workers: 1, want: []int{3, 3, 3, 1}},
+		{numItems: 100, workers: 5, want: []int{5, 5, 5, 5, 5, 5, 5,
+			5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5, 5}},
+	}
+	for _, test := range tests {
+		got := blockchain.TstScriptBatches(test.numItems, test.workers)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("scriptBatches (%d items, %d workers): got %v, "+
+				"want %v", test.numItems, test.workers, got,
+				test.want)
+		}
+	}
+}
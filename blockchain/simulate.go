@@ -0,0 +1,355 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// MaxSimulateDepth is the maximum number of blocks SimulateConnectBlock
+// disconnects from the end of the main chain to reach the parent of
+// the simulated block.  It bounds the work done while the chain lock is held.
+const MaxSimulateDepth = 1000
+
+// SimulatedOutput describes a transaction output which is spent or created by
+// a simulated block.
+type SimulatedOutput struct {
+	OutPoint wire.OutPoint
+	Amount   int64
+	PkScript []byte
+}
+
+// KeySetChange describes the keys an admin key set gains and loses by
+// connecting a simulated block.
+type KeySetChange struct {
+	KeySet  btcec.KeySetType
+	Added   btcec.PublicKeySet
+	Removed btcec.PublicKeySet
+}
+
+// KeyIDChange describes an ASP key ID which is provisioned or revoked by
+// connecting a simulated block.
+type KeyIDChange struct {
+	KeyID  btcec.KeyID
+	PubKey *btcec.PublicKey
+	Added  bool
+}
+
+// BlockSimulation houses the changes a block makes to the chain state, as
+// calculated by SimulateConnectBlock.
+//
+// Spent and Created only list the net changes to the set of unspent
+// transaction outputs, so outputs which are created and spent within the
+// block are left out of both.
+type BlockSimulation struct {
+	Hash          chainhash.Hash
+	Height        uint32
+	Depth         uint32
+	Fees          int64
+	Subsidy       int64
+	Spent         []SimulatedOutput
+	Created       []SimulatedOutput
+	KeySetChanges []KeySetChange
+	KeyIDChanges  []KeyIDChange
+	SupplyBefore  uint64
+	SupplyAfter   uint64
+	LastKeyID     btcec.KeyID
+}
+
+// SimulateConnectBlock performs full contextual validation of the passed block
+// as if it were connected on top of its parent and returns the resulting
+// changes to the unspent transaction outputs, admin key sets, and supply, all
+// without modifying the chain state.  The parent of the block must be part of
+// the main chain, but it does not need to be the end of it, so historical
+// blocks are able to be replayed in isolation as long as their parent is at
+// most MaxSimulateDepth blocks behind the end of the main chain.
+//
+// The returned error is a RuleError when the block violates a rule.  Any other
+// error means the block could not be simulated, such as when its parent is not
+// part of the main chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) SimulateConnectBlock(block *provautil.Block) (*BlockSimulation, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	// The block is not connected, so don't let the validation times of the
+	// simulation leak into those of the next block which is.
+	defer b.timer.discard()
+
+	err := checkBlockSanity(block, b.chainParams.PowLimit, b.timeSource,
+		BFNone)
+	if err != nil {
+		return nil, err
+	}
+
+	// The parent of the block must be part of the main chain so the chain
+	// state as of the parent is able to be recreated from the spend
+	// journal.
+	header := &block.MsgBlock().Header
+	prevHash := &header.PrevBlock
+	if prevHash.IsEqual(zeroHash) {
+		return nil, errors.New("the genesis block can not be simulated")
+	}
+	var inMainChain bool
+	err = b.db.View(func(dbTx database.Tx) error {
+		inMainChain = dbMainChainHasBlock(dbTx, prevHash)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !inMainChain {
+		str := fmt.Sprintf("previous block %v is not in the main chain",
+			prevHash)
+		return nil, errors.New(str)
+	}
+	prevNode, err := b.getPrevNodeFromBlock(block)
+	if err != nil {
+		return nil, err
+	}
+	depth := b.bestNode.height - prevNode.height
+	if depth > MaxSimulateDepth {
+		str := fmt.Sprintf("previous block %v is %d blocks behind the "+
+			"end of the main chain which exceeds the maximum of %d",
+			prevHash, depth, MaxSimulateDepth)
+		return nil, errors.New(str)
+	}
+
+	// Recreate the chain state as of the parent of the block.
+	utxoView, keyView, err := b.stateViewsAt(prevNode, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.checkBlockContext(block, prevNode, BFNone)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remember the admin state as of the parent so the changes made by the
+	// block are able to be reported.
+	keysBefore := btcec.DeepCopy(keyView.Keys())
+	keyIDsBefore := keyView.KeyIDs().DeepCopy()
+	supplyBefore := keyView.TotalSupply()
+
+	newNode := newBlockNode(header, block.Hash())
+	newNode.parent = prevNode
+	newNode.workSum.Add(prevNode.workSum, newNode.workSum)
+	block.SetHeight(newNode.height)
+	var stxos []spentTxOut
+	err = b.checkConnectBlock(newNode, block, utxoView, keyView, &stxos,
+		BFNone)
+	if err != nil {
+		return nil, err
+	}
+
+	sim := &BlockSimulation{
+		Hash:         *block.Hash(),
+		Height:       newNode.height,
+		Depth:        depth,
+		Subsidy:      CalcBlockSubsidy(newNode.height, b.chainParams),
+		SupplyBefore: supplyBefore,
+		SupplyAfter:  keyView.TotalSupply(),
+		LastKeyID:    keyView.LastKeyID(),
+	}
+	sim.Spent, sim.Created = simulatedOutputs(block, utxoView, stxos)
+	for _, txOut := range block.Transactions()[0].MsgTx().TxOut {
+		sim.Fees += txOut.Value
+	}
+	sim.Fees -= sim.Subsidy
+	sim.KeySetChanges = keySetChanges(keysBefore, keyView.Keys())
+	sim.KeyIDChanges = keyIDChanges(keyIDsBefore, keyView.KeyIDs())
+	return sim, nil
+}
+
+// stateViewsAt recreates the chain state as of the passed main chain node by
+// disconnecting all of the blocks after it in views of the utxo set and the
+// admin state, the same way a reorganization does.  The passed function, when
+// not nil, is called with each of the disconnected blocks.
+//
+// Note that the returned utxo view only houses the entries which differ from
+// those in the database.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) stateViewsAt(node *blockNode, detached func(*provautil.Block)) (*UtxoViewpoint, *KeyViewpoint, error) {
+	utxoView := NewUtxoViewpoint()
+	utxoView.SetBestHash(b.bestNode.hash)
+	keyView := NewKeyViewpoint()
+	keyView.SetThreadTips(b.threadTips)
+	keyView.SetLastKeyID(b.lastKeyID)
+	keyView.SetTotalSupply(b.totalSupply)
+	keyView.SetKeys(b.adminKeySets)
+	keyView.SetKeyIDs(b.aspKeyIdMap)
+	for n := b.bestNode; !n.hash.IsEqual(node.hash); {
+		var detach *provautil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			detach, err = dbFetchBlockByHash(dbTx, n.hash)
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		err = utxoView.fetchInputUtxos(b.db, detach)
+		if err != nil {
+			return nil, nil, err
+		}
+		var stxos []spentTxOut
+		err = b.db.View(func(dbTx database.Tx) error {
+			stxos, err = dbFetchSpendJournalEntry(dbTx, detach,
+				utxoView)
+			return err
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		err = utxoView.disconnectTransactions(detach, stxos)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = keyView.disconnectTransactions(detach)
+		if err != nil {
+			return nil, nil, err
+		}
+		if detached != nil {
+			detached(detach)
+		}
+
+		n, err = b.getPrevNodeFromNode(n)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	utxoView.SetBestHash(node.hash)
+	return utxoView, keyView, nil
+}
+
+// simulatedOutputs returns the outputs spent and created by the passed block,
+// which has been connected to the passed view with the passed spent txouts.
+// Outputs which are created and spent within the block are left out.
+func simulatedOutputs(block *provautil.Block, view *UtxoViewpoint,
+	stxos []spentTxOut) ([]SimulatedOutput, []SimulatedOutput) {
+
+	transactions := block.Transactions()
+	inBlock := make(map[chainhash.Hash]struct{}, len(transactions))
+	for _, tx := range transactions {
+		inBlock[*tx.Hash()] = struct{}{}
+	}
+
+	// The spent txouts are in the order the inputs of the transactions
+	// spend them, skipping the coinbase.
+	var spent []SimulatedOutput
+	stxoIdx := 0
+	for _, tx := range transactions[1:] {
+		for _, txIn := range tx.MsgTx().TxIn {
+			stxo := stxos[stxoIdx]
+			stxoIdx++
+			if _, ok := inBlock[txIn.PreviousOutPoint.Hash]; ok {
+				continue
+			}
+			spent = append(spent, SimulatedOutput{
+				OutPoint: txIn.PreviousOutPoint,
+				Amount:   stxo.amount,
+				PkScript: stxo.pkScript,
+			})
+		}
+	}
+
+	var created []SimulatedOutput
+	for _, tx := range transactions {
+		entry := view.LookupEntry(tx.Hash())
+		if entry == nil {
+			continue
+		}
+		for i := range tx.MsgTx().TxOut {
+			index := uint32(i)
+			if entry.IsOutputSpent(index) {
+				continue
+			}
+			created = append(created, SimulatedOutput{
+				OutPoint: *wire.NewOutPoint(tx.Hash(), index),
+				Amount:   entry.AmountByIndex(index),
+				PkScript: entry.PkScriptByIndex(index),
+			})
+		}
+	}
+	return spent, created
+}
+
+// keySetChanges returns the keys gained and lost by each admin key set between
+// the passed states, ordered by key set.  Key sets which did not change are
+// left out.
+func keySetChanges(before, after map[btcec.KeySetType]btcec.PublicKeySet) []KeySetChange {
+	types := make(map[btcec.KeySetType]struct{})
+	for keySet := range before {
+		types[keySet] = struct{}{}
+	}
+	for keySet := range after {
+		types[keySet] = struct{}{}
+	}
+	sorted := make([]int, 0, len(types))
+	for keySet := range types {
+		sorted = append(sorted, int(keySet))
+	}
+	sort.Ints(sorted)
+
+	var changes []KeySetChange
+	for _, i := range sorted {
+		keySet := btcec.KeySetType(i)
+		change := KeySetChange{KeySet: keySet}
+		for j := range after[keySet] {
+			key := &after[keySet][j]
+			if before[keySet].Pos(key) == -1 {
+				change.Added = append(change.Added, *key)
+			}
+		}
+		for j := range before[keySet] {
+			key := &before[keySet][j]
+			if after[keySet].Pos(key) == -1 {
+				change.Removed = append(change.Removed, *key)
+			}
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+// keyIDChanges returns the ASP key IDs provisioned and revoked between the
+// passed states, ordered by key ID.
+func keyIDChanges(before, after btcec.KeyIdMap) []KeyIDChange {
+	var changes []KeyIDChange
+	for keyID, pubKey := range after {
+		if prev, ok := before[keyID]; !ok || !prev.IsEqual(pubKey) {
+			changes = append(changes, KeyIDChange{
+				KeyID:  keyID,
+				PubKey: pubKey,
+				Added:  true,
+			})
+		}
+	}
+	for keyID, pubKey := range before {
+		if _, ok := after[keyID]; !ok {
+			changes = append(changes, KeyIDChange{
+				KeyID:  keyID,
+				PubKey: pubKey,
+			})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].KeyID < changes[j].KeyID
+	})
+	return changes
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+)
+
+// TestSimulateConnectBlock ensures simulating the blocks generated by the
+// fullblocktests package which extend the main chain leaves the chain state
+// untouched, predicts the changes made by processing them, rejects them with
+// the same rule violations, and is able to replay historical blocks.
+func TestSimulateConnectBlock(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("simulateconnectblock",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// simulations houses the simulations of the blocks which were connected
+	// to the main chain, in the order they were connected.
+	var simulations []*blockchain.BlockSimulation
+	var blocks []*provautil.Block
+
+	for _, test := range tests {
+		for _, item := range test {
+			var block *provautil.Block
+			var accepted bool
+			var rejectCode blockchain.ErrorCode
+			switch item := item.(type) {
+			case fullblocktests.AcceptedBlock:
+				block = provautil.NewBlock(item.Block)
+				accepted = item.IsMainChain
+			case fullblocktests.RejectedBlock:
+				block = provautil.NewBlock(item.Block)
+				rejectCode = item.RejectCode
+			case fullblocktests.OrphanOrRejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			default:
+				continue
+			}
+
+			// Only blocks which extend the main chain are simulated
+			// since the others either cause a reorganization or
+			// are orphans.
+			best := chain.BestSnapshot()
+			supply := chain.TotalSupply()
+			extendsTip := block.MsgBlock().Header.PrevBlock == *best.Hash
+			var sim *blockchain.BlockSimulation
+			if extendsTip {
+				var simErr error
+				sim, simErr = chain.SimulateConnectBlock(block)
+				after := chain.BestSnapshot()
+				if *after.Hash != *best.Hash ||
+					chain.TotalSupply() != supply {
+
+					t.Fatalf("SimulateConnectBlock: block %v "+
+						"changed the chain state", block.Hash())
+				}
+				if accepted && simErr != nil {
+					t.Fatalf("SimulateConnectBlock: block %v "+
+						"rejected: %v", block.Hash(), simErr)
+				}
+				if !accepted && simErr == nil {
+					t.Fatalf("SimulateConnectBlock: invalid "+
+						"block %v accepted", block.Hash())
+				}
+				rerr, ok := simErr.(blockchain.RuleError)
+				if rejectCode != 0 && (!ok || rerr.ErrorCode != rejectCode) {
+					t.Fatalf("SimulateConnectBlock: block %v got "+
+						"error %v, want %v", block.Hash(),
+						simErr, rejectCode)
+				}
+			}
+
+			isMainChain, _, err := chain.ProcessBlock(block,
+				blockchain.BFNone)
+			if !extendsTip || sim == nil || err != nil || !isMainChain {
+				continue
+			}
+
+			// The simulation predicts the changes made by
+			// connecting the block.
+			if chain.TotalSupply() != sim.SupplyAfter {
+				t.Fatalf("SimulateConnectBlock: block %v got supply "+
+					"%d, want %d", block.Hash(), sim.SupplyAfter,
+					chain.TotalSupply())
+			}
+			if chain.LastKeyID() != sim.LastKeyID {
+				t.Fatalf("SimulateConnectBlock: block %v got last "+
+					"key ID %d, want %d", block.Hash(),
+					sim.LastKeyID, chain.LastKeyID())
+			}
+			for _, output := range sim.Created {
+				hash := output.OutPoint.Hash
+				entry, err := chain.FetchUtxoEntry(&hash)
+				if err != nil || entry == nil ||
+					entry.IsOutputSpent(output.OutPoint.Index) {
+
+					t.Fatalf("SimulateConnectBlock: block %v "+
+						"created output %v which is not "+
+						"unspent", block.Hash(),
+						output.OutPoint)
+				}
+			}
+			simulations = append(simulations, sim)
+			blocks = append(blocks, block)
+		}
+	}
+	if len(simulations) == 0 {
+		t.Fatal("SimulateConnectBlock: no blocks were simulated")
+	}
+
+	// Historical blocks are replayed with the chain state as of their
+	// parent and produce the same changes as when they were connected.
+	// Replaying a block requires decoding the spend journal of every block
+	// after it, and the journal entry of the block which spends the last
+	// admin thread output of the genesis block lacks the version of the
+	// genesis transaction, so only the most recent blocks are replayed.
+	const replayDepth = 8
+	tip := chain.BestSnapshot().Height
+	replayed := 0
+	for i, block := range blocks {
+		height := block.MsgBlock().Header.Height
+		mainChain, err := chain.MainChainHasBlock(block.Hash())
+		if err != nil {
+			t.Fatalf("MainChainHasBlock: %v", err)
+		}
+		if !mainChain || tip-height >= replayDepth {
+			continue
+		}
+		sim, err := chain.SimulateConnectBlock(block)
+		if err != nil {
+			t.Fatalf("SimulateConnectBlock: historical block %v "+
+				"rejected: %v", block.Hash(), err)
+		}
+		want := *simulations[i]
+		want.Depth = tip - height + 1
+		if !reflect.DeepEqual(sim, &want) {
+			t.Fatalf("SimulateConnectBlock: historical block %v got "+
+				"%+v, want %+v", block.Hash(), sim, &want)
+		}
+		replayed++
+	}
+	if replayed == 0 {
+		t.Fatal("SimulateConnectBlock: no historical blocks replayed")
+	}
+
+	genesis := provautil.NewBlock(chaincfg.RegressionNetParams.GenesisBlock)
+	if _, err := chain.SimulateConnectBlock(genesis); err == nil {
+		t.Fatal("SimulateConnectBlock: simulated the genesis block")
+	}
+	orphan := *blocks[len(blocks)-1].MsgBlock()
+	orphan.Header.PrevBlock = chainhash.Hash{0x01}
+	if _, err := chain.SimulateConnectBlock(provautil.NewBlock(&orphan)); err == nil {
+		t.Fatal("SimulateConnectBlock: simulated an orphan block")
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+)
+
+// ChainEventType represents the type of an event delivered to subscribers.
+type ChainEventType int
+
+// Constants for the type of an event delivered to subscribers.
+const (
+	// ChainEventBlockConnected indicates the associated block was connected
+	// to the main chain.
+	ChainEventBlockConnected ChainEventType = iota
+
+	// ChainEventBlockDisconnected indicates the associated block was
+	// disconnected from the main chain.
+	ChainEventBlockDisconnected
+
+	// ChainEventReorg indicates the main chain is about to be reorganized.
+	// It is followed by the events for the disconnected blocks from the
+	// old end of the main chain back to the common ancestor, and then by
+	// the events for the connected blocks up to the new end of the main
+	// chain.
+	ChainEventReorg
+)
+
+// chainEventTypeStrings is a map of chain event types back to their constant
+// names for pretty printing.
+var chainEventTypeStrings = map[ChainEventType]string{
+	ChainEventBlockConnected:    "ChainEventBlockConnected",
+	ChainEventBlockDisconnected: "ChainEventBlockDisconnected",
+	ChainEventReorg:             "ChainEventReorg",
+}
+
+// String returns the ChainEventType in human-readable form.
+func (t ChainEventType) String() string {
+	if s, ok := chainEventTypeStrings[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown Chain Event Type (%d)", int(t))
+}
+
+// ChainReorg describes a reorganization of the main chain.  Depth is the
+// number of blocks disconnected from the old end of the main chain to reach
+// the common ancestor of the old and new ends.
+type ChainReorg struct {
+	OldHash              chainhash.Hash
+	OldHeight            uint32
+	NewHash              chainhash.Hash
+	NewHeight            uint32
+	CommonAncestor       chainhash.Hash
+	CommonAncestorHeight uint32
+	Depth                uint32
+}
+
+// ChainEvent is an event delivered to subscribers.  Block is set for the
+// connected and disconnected events and Reorg is set for the reorganize event.
+type ChainEvent struct {
+	Type  ChainEventType
+	Block *provautil.Block
+	Reorg *ChainReorg
+}
+
+// Subscription delivers the events of the chain, in the order they happen, to
+// a subscriber registered with Subscribe.  Events are queued without limit
+// rather than dropped, so a subscriber which falls behind never misses one,
+// but it must keep receiving them or call Unsubscribe.
+type Subscription struct {
+	chain  *BlockChain
+	events chan *ChainEvent
+	quit   chan struct{}
+
+	mtx    sync.Mutex
+	queue  []*ChainEvent
+	signal chan struct{}
+}
+
+// Events returns the channel the events are delivered on.  It is closed once
+// the subscription is cancelled with Unsubscribe.
+func (s *Subscription) Events() <-chan *ChainEvent {
+	return s.events
+}
+
+// Unsubscribe stops the delivery of events and closes the events channel.
+// Events which were not received yet are discarded.
+//
+// This function is safe for concurrent access.
+func (s *Subscription) Unsubscribe() {
+	s.chain.subscribersLock.Lock()
+	_, ok := s.chain.subscribers[s]
+	delete(s.chain.subscribers, s)
+	s.chain.subscribersLock.Unlock()
+
+	if ok {
+		close(s.quit)
+	}
+}
+
+// enqueue adds the passed event to the queue of the subscription without
+// blocking.
+func (s *Subscription) enqueue(event *ChainEvent) {
+	s.mtx.Lock()
+	s.queue = append(s.queue, event)
+	s.mtx.Unlock()
+
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+// deliver forwards the queued events to the events channel in order until the
+// subscription is cancelled.  It must be run as a goroutine.
+func (s *Subscription) deliver() {
+	defer close(s.events)
+
+	for {
+		s.mtx.Lock()
+		var event *ChainEvent
+		if len(s.queue) > 0 {
+			event = s.queue[0]
+			s.queue[0] = nil
+			s.queue = s.queue[1:]
+		}
+		s.mtx.Unlock()
+
+		if event == nil {
+			select {
+			case <-s.signal:
+				continue
+			case <-s.quit:
+				return
+			}
+		}
+
+		select {
+		case s.events <- event:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// Subscribe registers a new subscriber for the connected, disconnected, and
+// reorganize events of the main chain.  Unlike the callback provided with the
+// Notifications field of the config, the events are delivered asynchronously
+// over the channel returned by the Events method of the subscription, so slow
+// subscribers do not hold up the chain.
+//
+// The events are delivered in the same order the chain changes, so a
+// subscriber which applies the connected blocks and reverts the disconnected
+// ones stays consistent with the main chain across reorganizations.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) Subscribe() *Subscription {
+	s := &Subscription{
+		chain:  b,
+		events: make(chan *ChainEvent),
+		quit:   make(chan struct{}),
+		signal: make(chan struct{}, 1),
+	}
+
+	b.subscribersLock.Lock()
+	b.subscribers[s] = struct{}{}
+	b.subscribersLock.Unlock()
+
+	go s.deliver()
+	return s
+}
+
+// notifySubscribers queues the passed event for delivery to all subscribers.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) notifySubscribers(event *ChainEvent) {
+	b.subscribersLock.RLock()
+	for s := range b.subscribers {
+		s.enqueue(event)
+	}
+	b.subscribersLock.RUnlock()
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+)
+
+// TestSubscribe ensures a subscriber which applies the events delivered while
+// processing the blocks generated by the fullblocktests package, which fork and
+// reorganize the chain, ends up with the same main chain as the chain itself.
+func TestSubscribe(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("subscribe",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	// The subscriber keeps the hashes of the main chain after the genesis
+	// block, validates the events against them, and reports the end of
+	// its main chain after each event.
+	sub := chain.Subscribe()
+	genesis := *chain.BestSnapshot().Hash
+	tips := make(chan chainhash.Hash, 1)
+	errs := make(chan string, 1)
+	go func() {
+		mainChain := []chainhash.Hash{genesis}
+		var reorg *blockchain.ChainReorg
+		for event := range sub.Events() {
+			tip := mainChain[len(mainChain)-1]
+			switch event.Type {
+			case blockchain.ChainEventBlockConnected:
+				header := &event.Block.MsgBlock().Header
+				if header.PrevBlock != tip {
+					errs <- "connected block does not extend tip"
+					return
+				}
+				mainChain = append(mainChain, *event.Block.Hash())
+
+			case blockchain.ChainEventBlockDisconnected:
+				if *event.Block.Hash() != tip {
+					errs <- "disconnected block is not the tip"
+					return
+				}
+				mainChain = mainChain[:len(mainChain)-1]
+
+			case blockchain.ChainEventReorg:
+				reorg = event.Reorg
+				if reorg.OldHash != tip ||
+					reorg.OldHeight != uint32(len(mainChain)-1) {
+
+					errs <- "reorg does not start at the tip"
+					return
+				}
+			}
+
+			// Once all of the blocks of a reorganization have been
+			// disconnected, the end of the main chain is the common
+			// ancestor.
+			if reorg != nil && len(mainChain)-1 ==
+				int(reorg.OldHeight-reorg.Depth) {
+
+				ancestor := mainChain[len(mainChain)-1]
+				if ancestor != reorg.CommonAncestor ||
+					reorg.CommonAncestorHeight !=
+						reorg.OldHeight-reorg.Depth {
+
+					errs <- "wrong reorg common ancestor"
+					return
+				}
+				reorg = nil
+			}
+			select {
+			case <-tips:
+			default:
+			}
+			tips <- mainChain[len(mainChain)-1]
+		}
+	}()
+
+	var reorgs int
+	for _, test := range tests {
+		for _, item := range test {
+			var block *provautil.Block
+			switch item := item.(type) {
+			case fullblocktests.AcceptedBlock:
+				block = provautil.NewBlock(item.Block)
+			case fullblocktests.RejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			case fullblocktests.OrphanOrRejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			default:
+				continue
+			}
+			prevBest := *chain.BestSnapshot().Hash
+			isMainChain, _, _ := chain.ProcessBlock(block,
+				blockchain.BFNone)
+			if isMainChain && block.MsgBlock().Header.PrevBlock != prevBest {
+				reorgs++
+			}
+		}
+	}
+	if reorgs == 0 {
+		t.Fatal("no reorganizations were tested")
+	}
+
+	best := chain.BestSnapshot()
+	timeout := time.After(10 * time.Second)
+	for tip := genesis; tip != *best.Hash; {
+		select {
+		case tip = <-tips:
+		case msg := <-errs:
+			t.Fatalf("Subscribe: %s", msg)
+		case <-timeout:
+			t.Fatalf("Subscribe: subscriber tip is %v, want %v", tip,
+				best.Hash)
+		}
+	}
+
+	// Unsubscribing closes the events channel.
+	sub.Unsubscribe()
+	sub.Unsubscribe()
+	select {
+	case _, ok := <-sub.Events():
+		if ok {
+			t.Fatal("Subscribe: event delivered after Unsubscribe")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Subscribe: events channel not closed")
+	}
+}
@@ -0,0 +1,288 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+)
+
+// TestThresholdStateStringer tests the stringized output for the
+// ThresholdState type.
+func TestThresholdStateStringer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   ThresholdState
+		want string
+	}{
+		{ThresholdDefined, "ThresholdDefined"},
+		{ThresholdStarted, "ThresholdStarted"},
+		{ThresholdLockedIn, "ThresholdLockedIn"},
+		{ThresholdActive, "ThresholdActive"},
+		{ThresholdFailed, "ThresholdFailed"},
+		{0xff, "Unknown ThresholdState (255)"},
+	}
+
+	// Detect additional threshold states that don't have the stringer
+	// tested.
+	if len(tests)-1 != int(numThresholdsStates) {
+		t.Errorf("It appears a threshold state was added without adding " +
+			"an associated stringer test")
+	}
+
+	for i, test := range tests {
+		result := test.in.String()
+		if result != test.want {
+			t.Errorf("String #%d\n got: %s want: %s", i, result,
+				test.want)
+			continue
+		}
+	}
+}
+
+// newVersionBitsTestChain returns a chain instance for the passed network
+// parameters along with the memory block nodes of a chain of the passed number
+// of blocks, one minute apart, whose versions are returned by the passed
+// function.  The nodes are linked to their parents, so the threshold states
+// are calculated without a database.
+func newVersionBitsTestChain(params *chaincfg.Params, numBlocks uint32,
+	version func(height uint32) uint32) (*BlockChain, []*blockNode) {
+
+	chain := &BlockChain{
+		chainParams:      params,
+		deploymentCaches: newThresholdCaches(chaincfg.DefinedDeployments),
+	}
+	nodes := make([]*blockNode, 0, numBlocks)
+	var parent *blockNode
+	for height := uint32(0); height < numBlocks; height++ {
+		var hash chainhash.Hash
+		binary.LittleEndian.PutUint32(hash[:], height+1)
+		node := &blockNode{
+			parent:    parent,
+			hash:      &hash,
+			height:    height,
+			version:   version(height),
+			timestamp: 1500000000 + int64(height)*60,
+		}
+		if parent != nil {
+			node.parentHash = parent.hash
+		}
+		nodes = append(nodes, node)
+		parent = node
+	}
+	return chain, nodes
+}
+
+// TestDeploymentState ensures the state of a deployment progresses through the
+// BIP0009 threshold states as the validators signal it by the versions of the
+// blocks they sign.
+func TestDeploymentState(t *testing.T) {
+	t.Parallel()
+
+	const window = 10
+	params := chaincfg.RegressionNetParams
+	params.MinerConfirmationWindow = window
+	params.RuleChangeActivationThreshold = 8
+	deployment := &params.Deployments[chaincfg.DeploymentTimelocks]
+	deployment.BitNumber = 1
+	deployment.StartTime = 1500000000 + 5*60
+	deployment.ExpireTime = 1500000000 + 100*60
+	deployment.ActivationHeight = chaincfg.DeploymentNeverActive
+	signal := uint32(vbTopBits | 1<<1)
+
+	tests := []struct {
+		name    string
+		version func(height uint32) uint32
+		states  []ThresholdState
+	}{
+		{
+			// The deployment starts with the window after the
+			// median time reached the start time, locks in once a
+			// window of blocks signals it, and activates one window
+			// later.
+			name:    "signaled",
+			version: func(uint32) uint32 { return signal },
+			states: []ThresholdState{
+				ThresholdDefined, ThresholdStarted,
+				ThresholdLockedIn, ThresholdActive,
+				ThresholdActive, ThresholdActive,
+			},
+		},
+		{
+			// Blocks below the threshold or without the top bits
+			// of the version bits scheme do not count.
+			name: "below threshold",
+			version: func(height uint32) uint32 {
+				switch {
+				case height%window < 7:
+					return signal
+				case height%window == 7:
+					return 1 << 1
+				}
+				return vbTopBits
+			},
+			states: []ThresholdState{
+				ThresholdDefined, ThresholdStarted,
+				ThresholdStarted, ThresholdStarted,
+				ThresholdStarted, ThresholdStarted,
+			},
+		},
+		{
+			// The deployment locks in at exactly the threshold.
+			name: "at threshold",
+			version: func(height uint32) uint32 {
+				if height >= 20 && height%window < 8 {
+					return signal
+				}
+				return 4
+			},
+			states: []ThresholdState{
+				ThresholdDefined, ThresholdStarted,
+				ThresholdStarted, ThresholdLockedIn,
+				ThresholdActive, ThresholdActive,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		chain, nodes := newVersionBitsTestChain(&params, 6*window,
+			test.version)
+		for i, want := range test.states {
+			// The state of a window is the one of the block after
+			// the last block of the previous window.
+			var prevNode *blockNode
+			if i > 0 {
+				prevNode = nodes[i*window-1]
+			}
+			state, err := chain.deploymentState(prevNode,
+				chaincfg.DeploymentTimelocks)
+			if err != nil {
+				t.Fatalf("%s: window %d: unexpected error: %v",
+					test.name, i, err)
+			}
+			if state != want {
+				t.Errorf("%s: window %d: got state %v, want %v",
+					test.name, i, state, want)
+			}
+
+			// The state is the same for every block of the window.
+			state, err = chain.deploymentState(nodes[i*window+5],
+				chaincfg.DeploymentTimelocks)
+			if err != nil {
+				t.Fatalf("%s: window %d: unexpected error: %v",
+					test.name, i, err)
+			}
+			if state != want {
+				t.Errorf("%s: window %d: got state %v in the "+
+					"window, want %v", test.name, i, state,
+					want)
+			}
+
+			// Blocks signal the deployment while it is started or
+			// locked in, and only when it is opted into.
+			version, err := chain.calcNextBlockVersion(prevNode,
+				[]int{chaincfg.DeploymentTimelocks})
+			if err != nil {
+				t.Fatalf("%s: window %d: unexpected error: %v",
+					test.name, i, err)
+			}
+			wantVersion := uint32(vbTopBits)
+			if want == ThresholdStarted || want == ThresholdLockedIn {
+				wantVersion = signal
+			}
+			if version != wantVersion {
+				t.Errorf("%s: window %d: got version %08x, want "+
+					"%08x", test.name, i, version, wantVersion)
+			}
+			version, err = chain.calcNextBlockVersion(prevNode, nil)
+			if err != nil {
+				t.Fatalf("%s: window %d: unexpected error: %v",
+					test.name, i, err)
+			}
+			if version != vbTopBits {
+				t.Errorf("%s: window %d: got version %08x without "+
+					"opting in, want %08x", test.name, i,
+					version, vbTopBits)
+			}
+
+			active, err := chain.isDeploymentActive(prevNode,
+				chaincfg.DeploymentTimelocks)
+			if err != nil {
+				t.Fatalf("%s: window %d: unexpected error: %v",
+					test.name, i, err)
+			}
+			if active != (want == ThresholdActive) {
+				t.Errorf("%s: window %d: got active %v, want %v",
+					test.name, i, active, want == ThresholdActive)
+			}
+		}
+	}
+}
+
+// TestDeploymentExpiry ensures a deployment which is not locked in by its
+// expire time fails, and that a deployment which reached its activation height
+// is active regardless of its signaling.
+func TestDeploymentExpiry(t *testing.T) {
+	t.Parallel()
+
+	const window = 10
+	params := chaincfg.RegressionNetParams
+	params.MinerConfirmationWindow = window
+	params.RuleChangeActivationThreshold = 8
+	deployment := &params.Deployments[chaincfg.DeploymentTimelocks]
+	deployment.StartTime = 0
+	deployment.ExpireTime = 1500000000 + 25*60
+	deployment.ActivationHeight = 45
+
+	// The deployment is never signaled, so it fails at the window after
+	// the median time reached the expire time.
+	chain, nodes := newVersionBitsTestChain(&params, 6*window,
+		func(uint32) uint32 { return vbTopBits })
+	tests := []struct {
+		prevHeight uint32
+		state      ThresholdState
+		active     bool
+	}{
+		{9, ThresholdStarted, false},
+		{29, ThresholdStarted, false},
+		{39, ThresholdFailed, false},
+		{43, ThresholdFailed, false},
+		{44, ThresholdFailed, true},
+		{59, ThresholdFailed, true},
+	}
+	for _, test := range tests {
+		prevNode := nodes[test.prevHeight]
+		state, err := chain.deploymentState(prevNode,
+			chaincfg.DeploymentTimelocks)
+		if err != nil {
+			t.Fatalf("height %d: unexpected error: %v",
+				test.prevHeight+1, err)
+		}
+		if state != test.state {
+			t.Errorf("height %d: got state %v, want %v",
+				test.prevHeight+1, state, test.state)
+		}
+		active, err := chain.isDeploymentActive(prevNode,
+			chaincfg.DeploymentTimelocks)
+		if err != nil {
+			t.Fatalf("height %d: unexpected error: %v",
+				test.prevHeight+1, err)
+		}
+		if active != test.active {
+			t.Errorf("height %d: got active %v, want %v",
+				test.prevHeight+1, active, test.active)
+		}
+	}
+
+	// Unknown deployments are rejected.
+	_, err := chain.deploymentState(nodes[0], chaincfg.DefinedDeployments)
+	if _, ok := err.(DeploymentError); !ok {
+		t.Errorf("got error %v, want %T", err, DeploymentError(0))
+	}
+}
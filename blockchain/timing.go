@@ -0,0 +1,264 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+)
+
+// timingWindow is the number of most recent samples of each validation phase
+// the rolling statistics are calculated over.
+const timingWindow = 100
+
+// ValidationPhase identifies a phase of processing a block.
+type ValidationPhase int
+
+// These constants define the phases of processing a block which are timed.
+const (
+	// PhaseDeserialize is the time spent decoding the block from the wire.
+	PhaseDeserialize ValidationPhase = iota
+
+	// PhaseHeaderChecks is the time spent on the context-free sanity checks
+	// of the block and the checks of its header against the chain.
+	PhaseHeaderChecks
+
+	// PhaseScriptValidation is the time spent executing the scripts of the
+	// transactions of the block.
+	PhaseScriptValidation
+
+	// PhaseUtxoUpdate is the time spent loading the outputs the block
+	// spends and validating and applying its transactions to the utxo view,
+	// excluding script validation.
+	PhaseUtxoUpdate
+
+	// PhaseDBCommit is the time spent writing the block to the chain state
+	// in the database, excluding updating the optional indexes.
+	PhaseDBCommit
+
+	// PhaseIndexUpdate is the time spent updating the optional indexes.
+	PhaseIndexUpdate
+
+	// numValidationPhases is the number of timed validation phases.  It
+	// must be the last item.
+	numValidationPhases
+)
+
+// phaseStrings is a map of validation phases back to their constant names for
+// pretty printing.
+var phaseStrings = map[ValidationPhase]string{
+	PhaseDeserialize:      "deserialize",
+	PhaseHeaderChecks:     "headerchecks",
+	PhaseScriptValidation: "scriptvalidation",
+	PhaseUtxoUpdate:       "utxoupdate",
+	PhaseDBCommit:         "dbcommit",
+	PhaseIndexUpdate:      "indexupdate",
+}
+
+// String returns the ValidationPhase as a human-readable name.
+func (p ValidationPhase) String() string {
+	if s, ok := phaseStrings[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown ValidationPhase (%d)", int(p))
+}
+
+// PhaseStats houses the rolling statistics of a validation phase over the most
+// recent blocks.
+type PhaseStats struct {
+	Phase   ValidationPhase
+	Samples int
+	Last    time.Duration
+	Mean    time.Duration
+	Min     time.Duration
+	Max     time.Duration
+}
+
+// BlockTimings houses the time spent in each validation phase of the most
+// recently connected block.
+type BlockTimings struct {
+	Hash   chainhash.Hash
+	Height uint32
+	Phases [numValidationPhases]time.Duration
+}
+
+// ValidationTimings houses the timing breakdown of the most recently connected
+// block along with the rolling statistics of every validation phase.
+type ValidationTimings struct {
+	// Blocks is the total number of blocks connected since the chain
+	// instance was created.
+	Blocks uint64
+
+	// Window is the maximum number of most recent samples the statistics
+	// of each phase are calculated over.
+	Window int
+
+	// Last is the breakdown of the most recently connected block.  It is
+	// nil when no block has been connected yet.
+	Last *BlockTimings
+
+	// Phases houses the statistics of each validation phase in order.
+	Phases []PhaseStats
+}
+
+// phaseSamples is a fixed size ring of the most recent samples of a validation
+// phase.
+type phaseSamples struct {
+	samples [timingWindow]time.Duration
+	count   int
+	next    int
+}
+
+// add adds the passed sample to the ring, replacing the oldest one when it is
+// full.
+func (s *phaseSamples) add(d time.Duration) {
+	s.samples[s.next] = d
+	s.next = (s.next + 1) % timingWindow
+	if s.count < timingWindow {
+		s.count++
+	}
+}
+
+// stats returns the statistics of the samples in the ring.
+func (s *phaseSamples) stats(phase ValidationPhase) PhaseStats {
+	stats := PhaseStats{Phase: phase, Samples: s.count}
+	if s.count == 0 {
+		return stats
+	}
+	stats.Last = s.samples[(s.next+timingWindow-1)%timingWindow]
+	stats.Min = s.samples[0]
+	var total time.Duration
+	for _, d := range s.samples[:s.count] {
+		total += d
+		if d < stats.Min {
+			stats.Min = d
+		}
+		if d > stats.Max {
+			stats.Max = d
+		}
+	}
+	stats.Mean = total / time.Duration(s.count)
+	return stats
+}
+
+// validationTimer accumulates the time spent in each validation phase of the
+// block being connected and maintains the rolling statistics of the blocks
+// which were connected.
+type validationTimer struct {
+	mtx      sync.Mutex
+	phases   [numValidationPhases]phaseSamples
+	current  [numValidationPhases]time.Duration
+	measured [numValidationPhases]bool
+	last     *BlockTimings
+	blocks   uint64
+}
+
+// add adds the passed duration to the time spent in the passed phase by the
+// block being connected.
+func (t *validationTimer) add(phase ValidationPhase, d time.Duration) {
+	t.mtx.Lock()
+	t.current[phase] += d
+	t.measured[phase] = true
+	t.mtx.Unlock()
+}
+
+// since adds the time elapsed since the passed start time to the time spent in
+// the passed phase by the block being connected.
+func (t *validationTimer) since(phase ValidationPhase, start time.Time) {
+	t.add(phase, time.Since(start))
+}
+
+// connected records the times accumulated by the block being connected, which
+// is identified by the passed hash and height, in the rolling statistics and
+// starts accumulating for the next block.  Only the phases which were measured
+// for the block are recorded, so phases skipped for a block, such as script
+// validation below a checkpoint, do not skew the statistics.
+func (t *validationTimer) connected(hash *chainhash.Hash, height uint32) {
+	t.mtx.Lock()
+	last := &BlockTimings{Hash: *hash, Height: height}
+	for phase := ValidationPhase(0); phase < numValidationPhases; phase++ {
+		if !t.measured[phase] {
+			continue
+		}
+		if phase != PhaseDeserialize {
+			t.phases[phase].add(t.current[phase])
+		}
+		last.Phases[phase] = t.current[phase]
+	}
+	t.last = last
+	t.blocks++
+	t.current = [numValidationPhases]time.Duration{}
+	t.measured = [numValidationPhases]bool{}
+	t.mtx.Unlock()
+}
+
+// discard drops the times accumulated by a block which was not connected,
+// such as an orphan, a side chain block, or an invalid block.
+func (t *validationTimer) discard() {
+	t.mtx.Lock()
+	deserialize := t.current[PhaseDeserialize]
+	measured := t.measured[PhaseDeserialize]
+	t.current = [numValidationPhases]time.Duration{}
+	t.measured = [numValidationPhases]bool{}
+	t.current[PhaseDeserialize] = deserialize
+	t.measured[PhaseDeserialize] = measured
+	t.mtx.Unlock()
+}
+
+// deserialized records the time spent decoding a block.  Blocks are decoded
+// before they reach the chain, so the sample is added to the statistics right
+// away and also attributed to the next block which is connected.
+func (t *validationTimer) deserialized(d time.Duration) {
+	t.mtx.Lock()
+	t.phases[PhaseDeserialize].add(d)
+	t.current[PhaseDeserialize] = d
+	t.measured[PhaseDeserialize] = true
+	t.mtx.Unlock()
+}
+
+// timings returns a snapshot of the timing breakdown of the most recently
+// connected block and the rolling statistics of every phase.
+func (t *validationTimer) timings() *ValidationTimings {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	timings := &ValidationTimings{
+		Blocks: t.blocks,
+		Window: timingWindow,
+		Phases: make([]PhaseStats, 0, numValidationPhases),
+	}
+	if t.last != nil {
+		last := *t.last
+		timings.Last = &last
+	}
+	for phase := ValidationPhase(0); phase < numValidationPhases; phase++ {
+		timings.Phases = append(timings.Phases,
+			t.phases[phase].stats(phase))
+	}
+	return timings
+}
+
+// RecordDeserializeTime records the time spent decoding a block before it is
+// passed to ProcessBlock.  The chain does not decode blocks itself, so callers
+// which do are expected to report it.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) RecordDeserializeTime(d time.Duration) {
+	b.timer.deserialized(d)
+}
+
+// ValidationTimings returns the time spent in each validation phase by the most
+// recently connected block along with the rolling statistics of every phase
+// over the most recently connected blocks.  The blocks attached during a
+// reorganization are all validated before the first of them is connected, so
+// their validation time is attributed to that block.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ValidationTimings() *ValidationTimings {
+	return b.timer.timings()
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+)
+
+// TestValidationTimer ensures the validation timer attributes phase times to
+// the connected blocks, drops the times of blocks which are not connected, and
+// calculates the rolling statistics over its window.
+func TestValidationTimer(t *testing.T) {
+	var timer validationTimer
+
+	if timings := timer.timings(); timings.Last != nil ||
+		timings.Blocks != 0 || len(timings.Phases) != int(numValidationPhases) {
+
+		t.Fatalf("timings: unexpected initial timings %+v", timings)
+	}
+
+	// The times of a block which is not connected are dropped, except for
+	// the deserialize time which belongs to the next block.
+	timer.deserialized(3 * time.Millisecond)
+	timer.add(PhaseHeaderChecks, time.Second)
+	timer.discard()
+
+	hash := chainhash.Hash{0x01}
+	timer.add(PhaseHeaderChecks, 2*time.Millisecond)
+	timer.add(PhaseHeaderChecks, 2*time.Millisecond)
+	timer.add(PhaseScriptValidation, 10*time.Millisecond)
+	timer.add(PhaseDBCommit, 5*time.Millisecond)
+	timer.connected(&hash, 7)
+
+	timings := timer.timings()
+	if timings.Blocks != 1 || timings.Last == nil ||
+		timings.Last.Hash != hash || timings.Last.Height != 7 {
+
+		t.Fatalf("timings: unexpected last block %+v", timings.Last)
+	}
+	want := [numValidationPhases]time.Duration{
+		PhaseDeserialize:      3 * time.Millisecond,
+		PhaseHeaderChecks:     4 * time.Millisecond,
+		PhaseScriptValidation: 10 * time.Millisecond,
+		PhaseDBCommit:         5 * time.Millisecond,
+	}
+	if timings.Last.Phases != want {
+		t.Fatalf("timings: got phases %v, want %v", timings.Last.Phases,
+			want)
+	}
+
+	// Phases which are not measured for a block, such as scripts which are
+	// skipped, are left out of the statistics.
+	hash = chainhash.Hash{0x02}
+	timer.add(PhaseHeaderChecks, 8*time.Millisecond)
+	timer.connected(&hash, 8)
+
+	tests := []struct {
+		phase   ValidationPhase
+		samples int
+		last    time.Duration
+		mean    time.Duration
+		min     time.Duration
+		max     time.Duration
+	}{
+		{PhaseDeserialize, 1, 3 * time.Millisecond, 3 * time.Millisecond,
+			3 * time.Millisecond, 3 * time.Millisecond},
+		{PhaseHeaderChecks, 2, 8 * time.Millisecond, 6 * time.Millisecond,
+			4 * time.Millisecond, 8 * time.Millisecond},
+		{PhaseScriptValidation, 1, 10 * time.Millisecond,
+			10 * time.Millisecond, 10 * time.Millisecond,
+			10 * time.Millisecond},
+		{PhaseUtxoUpdate, 0, 0, 0, 0, 0},
+	}
+	timings = timer.timings()
+	for _, test := range tests {
+		stats := timings.Phases[test.phase]
+		if stats.Phase != test.phase || stats.Samples != test.samples ||
+			stats.Last != test.last || stats.Mean != test.mean ||
+			stats.Min != test.min || stats.Max != test.max {
+
+			t.Errorf("%v: unexpected stats %+v", test.phase, stats)
+		}
+	}
+
+	// Only the most recent samples are kept.
+	for i := 0; i < timingWindow; i++ {
+		timer.add(PhaseHeaderChecks, time.Millisecond)
+		timer.connected(&hash, 9)
+	}
+	stats := timer.timings().Phases[PhaseHeaderChecks]
+	if stats.Samples != timingWindow || stats.Max != time.Millisecond ||
+		stats.Mean != time.Millisecond {
+
+		t.Fatalf("timings: unexpected stats after a full window %+v",
+			stats)
+	}
+}
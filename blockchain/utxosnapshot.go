@@ -0,0 +1,518 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"sort"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// utxoSnapshotMagic identifies the start of a utxo set snapshot.
+	utxoSnapshotMagic uint32 = 0x76727073
+
+	// utxoSnapshotVersion is the current version of the utxo set snapshot
+	// format.
+	utxoSnapshotVersion uint32 = 1
+
+	// maxUtxoSnapshotEntrySize is the maximum size of a serialized utxo
+	// entry or admin state in a utxo set snapshot.
+	maxUtxoSnapshotEntrySize = wire.MaxBlockPayload
+)
+
+// -----------------------------------------------------------------------------
+// A utxo set snapshot houses the chain state as of a main chain block, which
+// is enough for a node to continue validating the chain from there without
+// replaying all of the blocks before it.
+//
+// The serialized format is:
+//
+//   <magic><version><network><height><headers><block><total txns>
+//   <admin state len><admin state><utxo entries><integrity hash>
+//
+//   Field             Type               Size
+//   magic             uint32             4 bytes
+//   version           uint32             4 bytes
+//   network           wire.BitcoinNet    4 bytes
+//   height            uint32             4 bytes
+//   headers           []wire.BlockHeader headers from height 1 to height-1
+//   block             wire.MsgBlock      the block at height
+//   total txns        uint64             8 bytes
+//   admin state len   VarInt             variable
+//   admin state       []byte             admin state len
+//   utxo entries      []utxo entry       variable, terminated by a 0 length
+//   integrity hash    chainhash.Hash     chainhash.HashSize
+//
+// The integers are little-endian and the admin state uses the format of the
+// key set bucket.  Each utxo entry is:
+//
+//   <entry len><tx hash><entry>
+//
+//   Field             Type               Size
+//   entry len         VarInt             variable
+//   tx hash           chainhash.Hash     chainhash.HashSize
+//   entry             []byte             entry len
+//
+// where the entry uses the format of the utxo set bucket.  The entries are
+// sorted by transaction hash, so the same chain state always results in the
+// same snapshot.  The integrity hash is the double SHA-256 of everything
+// before it.
+// -----------------------------------------------------------------------------
+
+// ExportUTXOSnapshot writes a snapshot of the utxo set and admin state as of
+// the main chain block at the passed height to w, along with the headers of
+// the main chain up to it.  The block must not have been pruned, and when it
+// is not the end of the main chain, the chain state is recreated from the
+// spend journal.  The integrity hash of the snapshot is returned.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ExportUTXOSnapshot(w io.Writer, height uint32) (*chainhash.Hash, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	if height == 0 || height > b.bestNode.height {
+		str := fmt.Sprintf("no main chain block at height %d to snapshot "+
+			"-- the best height is %d", height, b.bestNode.height)
+		return nil, errors.New(str)
+	}
+	node, err := b.relativeNode(b.bestNode, b.bestNode.height-height)
+	if err != nil {
+		return nil, err
+	}
+
+	// Recreate the chain state as of the block, keeping track of the
+	// number of transactions in the disconnected blocks.
+	b.stateLock.RLock()
+	totalTxns := b.stateSnapshot.TotalTxns
+	b.stateLock.RUnlock()
+	utxoView, keyView, err := b.stateViewsAt(node, func(block *provautil.Block) {
+		totalTxns -= uint64(len(block.Transactions()))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bw := bufio.NewWriter(w)
+	hasher := sha256.New()
+	sw := io.MultiWriter(bw, hasher)
+	err = b.db.View(func(dbTx database.Tx) error {
+		err := writeElements(sw, utxoSnapshotMagic, utxoSnapshotVersion,
+			uint32(b.chainParams.Net), height)
+		if err != nil {
+			return err
+		}
+		for h := uint32(1); h < height; h++ {
+			header, err := dbFetchHeaderByHeight(dbTx, h)
+			if err != nil {
+				return err
+			}
+			if err := header.Serialize(sw); err != nil {
+				return err
+			}
+		}
+		block, err := dbFetchBlockByHash(dbTx, node.hash)
+		if err != nil {
+			return err
+		}
+		if err := block.MsgBlock().Serialize(sw); err != nil {
+			return err
+		}
+		if err := writeElements(sw, totalTxns); err != nil {
+			return err
+		}
+
+		adminState := serializeKeySet(keyView.Keys(), keyView.KeyIDs(),
+			keyView.ThreadTips(), keyView.LastKeyID(),
+			keyView.TotalSupply())
+		if err := wire.WriteVarBytes(sw, 0, adminState); err != nil {
+			return err
+		}
+		return writeSnapshotUtxos(sw, dbTx, utxoView)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	integrityHash := snapshotIntegrityHash(hasher)
+	if _, err := bw.Write(integrityHash[:]); err != nil {
+		return nil, err
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	return &integrityHash, nil
+}
+
+// writeSnapshotUtxos writes the entries of the utxo set in the database as
+// modified by the passed view to w in the utxo set snapshot format.  The
+// entries of the view take precedence over the ones in the database, so both
+// are merged in order of their hashes.
+func writeSnapshotUtxos(w io.Writer, dbTx database.Tx, view *UtxoViewpoint) error {
+	viewHashes := make([]chainhash.Hash, 0, len(view.entries))
+	for hash := range view.entries {
+		viewHashes = append(viewHashes, hash)
+	}
+	sort.Slice(viewHashes, func(i, j int) bool {
+		return bytes.Compare(viewHashes[i][:], viewHashes[j][:]) < 0
+	})
+
+	writeEntry := func(hash []byte, serialized []byte) error {
+		// Fully spent entries have no serialization.
+		if len(serialized) == 0 {
+			return nil
+		}
+		err := wire.WriteVarInt(w, 0, uint64(len(serialized)))
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(hash); err != nil {
+			return err
+		}
+		_, err = w.Write(serialized)
+		return err
+	}
+	writeViewEntry := func(hash *chainhash.Hash) error {
+		entry := view.entries[*hash]
+		if entry == nil {
+			return nil
+		}
+		serialized, err := serializeUtxoEntry(entry)
+		if err != nil {
+			return err
+		}
+		return writeEntry(hash[:], serialized)
+	}
+
+	next := 0
+	cursor := dbTx.Metadata().Bucket(utxoSetBucketName).Cursor()
+	for ok := cursor.First(); ok; ok = cursor.Next() {
+		key := cursor.Key()
+		for ; next < len(viewHashes); next++ {
+			cmp := bytes.Compare(viewHashes[next][:], key)
+			if cmp > 0 {
+				break
+			}
+			if err := writeViewEntry(&viewHashes[next]); err != nil {
+				return err
+			}
+			if cmp == 0 {
+				key = nil
+			}
+		}
+		if key == nil {
+			continue
+		}
+		if err := writeEntry(key, cursor.Value()); err != nil {
+			return err
+		}
+	}
+	for ; next < len(viewHashes); next++ {
+		if err := writeViewEntry(&viewHashes[next]); err != nil {
+			return err
+		}
+	}
+	return wire.WriteVarInt(w, 0, 0)
+}
+
+// ImportUTXOSnapshot reads a utxo set snapshot written by ExportUTXOSnapshot
+// from r and makes its block the end of the main chain, so the chain is able
+// to continue from there with the blocks after it.  Only the data of the
+// snapshot block itself is stored, so the blocks before it are treated like
+// pruned blocks.
+//
+// The chain must only contain the genesis block, must not maintain any
+// optional indexes since they can't be built from a snapshot, and the block of
+// the snapshot must be one of the checkpoints of the chain.  Nothing is changed
+// when the snapshot is rejected.
+//
+// The integrity hash at the end of the snapshot only detects corruption, since
+// anyone able to alter the snapshot is able to recompute it as well, so the
+// snapshot must also match the passed expected integrity hash, which comes
+// from a trusted source such as the operator of the node.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ImportUTXOSnapshot(r io.Reader, expectedHash *chainhash.Hash) error {
+	if expectedHash == nil {
+		return errors.New("the expected integrity hash of a utxo set " +
+			"snapshot is required to import it")
+	}
+
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	if b.bestNode.height != 0 {
+		return errors.New("a utxo set snapshot can only be imported " +
+			"into a chain which only contains the genesis block")
+	}
+	if b.indexManager != nil {
+		return errors.New("a utxo set snapshot can not be imported " +
+			"while optional indexes are enabled")
+	}
+
+	hasher := sha256.New()
+	br := bufio.NewReader(r)
+	sr := io.TeeReader(br, hasher)
+	var hash chainhash.Hash
+	var height uint32
+	err := b.db.Update(func(dbTx database.Tx) error {
+		var magic, version, net uint32
+		err := readElements(sr, &magic, &version, &net, &height)
+		if err != nil {
+			return err
+		}
+		if magic != utxoSnapshotMagic {
+			return errors.New("not a utxo set snapshot")
+		}
+		if version != utxoSnapshotVersion {
+			str := fmt.Sprintf("unsupported utxo set snapshot "+
+				"version %d", version)
+			return errors.New(str)
+		}
+		if wire.BitcoinNet(net) != b.chainParams.Net {
+			str := fmt.Sprintf("utxo set snapshot is for network "+
+				"%v instead of %v", wire.BitcoinNet(net),
+				b.chainParams.Net)
+			return errors.New(str)
+		}
+		checkpoint, ok := b.checkpointsByHeight[height]
+		if !ok {
+			str := fmt.Sprintf("utxo set snapshot height %d is not a "+
+				"checkpoint", height)
+			return errors.New(str)
+		}
+
+		// Store the headers of the blocks before the snapshot block
+		// along with the block itself, ensuring they connect.
+		prevHash := *b.bestNode.hash
+		workSum := new(big.Int).Set(b.bestNode.workSum)
+		checkHeader := func(header *wire.BlockHeader, h uint32) error {
+			if header.PrevBlock != prevHash || header.Height != h {
+				str := fmt.Sprintf("utxo set snapshot header at "+
+					"height %d does not connect", h)
+				return errors.New(str)
+			}
+			// The database references the passed hash until the
+			// transaction is committed, so it must not be reused.
+			blockHash := header.BlockHash()
+			if err := dbPutBlockIndex(dbTx, &blockHash, h); err != nil {
+				return err
+			}
+			workSum.Add(workSum, CalcWork(header.Bits))
+			prevHash = blockHash
+			hash = blockHash
+			return nil
+		}
+		for h := uint32(1); h < height; h++ {
+			var header wire.BlockHeader
+			if err := header.Deserialize(sr); err != nil {
+				return err
+			}
+			if err := checkHeader(&header, h); err != nil {
+				return err
+			}
+			if err := dbTx.StoreBlockHeader(&header); err != nil {
+				return err
+			}
+		}
+		var msgBlock wire.MsgBlock
+		if err := msgBlock.Deserialize(sr); err != nil {
+			return err
+		}
+		if err := checkHeader(&msgBlock.Header, height); err != nil {
+			return err
+		}
+		if !hash.IsEqual(checkpoint.Hash) {
+			str := fmt.Sprintf("utxo set snapshot block %v does not "+
+				"match checkpoint %v at height %d", hash,
+				checkpoint.Hash, height)
+			return errors.New(str)
+		}
+		block := provautil.NewBlock(&msgBlock)
+		err = checkBlockSanity(block, b.chainParams.PowLimit,
+			b.timeSource, BFNone)
+		if err != nil {
+			return err
+		}
+		if err := dbTx.StoreBlock(block); err != nil {
+			return err
+		}
+
+		var totalTxns uint64
+		if err := readElements(sr, &totalTxns); err != nil {
+			return err
+		}
+		adminState, err := wire.ReadVarBytes(sr, 0,
+			maxUtxoSnapshotEntrySize, "admin state")
+		if err != nil {
+			return err
+		}
+		_, aspKeyIdMap, _, _, totalSupply, err :=
+			deserializeKeySet(adminState)
+		if err != nil {
+			return err
+		}
+		meta := dbTx.Metadata()
+		if err := meta.Put(keySetBucketName, adminState); err != nil {
+			return err
+		}
+
+		// Replace the utxo set with the entries of the snapshot, which
+		// must be sorted by their hashes.
+		if err := meta.DeleteBucket(utxoSetBucketName); err != nil {
+			return err
+		}
+		utxoBucket, err := meta.CreateBucket(utxoSetBucketName)
+		if err != nil {
+			return err
+		}
+		var prevTxHash chainhash.Hash
+		for i := 0; ; i++ {
+			size, err := wire.ReadVarInt(sr, 0)
+			if err != nil {
+				return err
+			}
+			if size == 0 {
+				break
+			}
+			if size > maxUtxoSnapshotEntrySize {
+				str := fmt.Sprintf("utxo set snapshot entry size %d "+
+					"exceeds the maximum of %d", size,
+					maxUtxoSnapshotEntrySize)
+				return errors.New(str)
+			}
+			var txHash chainhash.Hash
+			if _, err := io.ReadFull(sr, txHash[:]); err != nil {
+				return err
+			}
+			if i > 0 && bytes.Compare(prevTxHash[:], txHash[:]) >= 0 {
+				return errors.New("utxo set snapshot entries are " +
+					"not sorted")
+			}
+			prevTxHash = txHash
+			serialized := make([]byte, size)
+			if _, err := io.ReadFull(sr, serialized); err != nil {
+				return err
+			}
+			if _, err := deserializeUtxoEntry(serialized); err != nil {
+				return err
+			}
+			if err := utxoBucket.Put(txHash[:], serialized); err != nil {
+				return err
+			}
+		}
+
+		// The integrity hash is not part of the hashed data, so it is
+		// read from the underlying reader.
+		integrityHash := snapshotIntegrityHash(hasher)
+		var wantHash chainhash.Hash
+		if _, err := io.ReadFull(br, wantHash[:]); err != nil {
+			return err
+		}
+		if integrityHash != wantHash {
+			str := fmt.Sprintf("utxo set snapshot integrity hash %v "+
+				"does not match %v", integrityHash, wantHash)
+			return errors.New(str)
+		}
+		if integrityHash != *expectedHash {
+			str := fmt.Sprintf("utxo set snapshot integrity hash %v "+
+				"does not match the expected hash %v",
+				integrityHash, expectedHash)
+			return errors.New(str)
+		}
+
+		// The changes to the admin key sets before the snapshot are
+		// not known, so their history starts at it.
+		err = dbPutKeySetHistoryStart(dbTx, height)
+		if err != nil {
+			return err
+		}
+
+		// Likewise, the tokens issued and destroyed before the snapshot
+		// are not known, so the issuance history starts at it with the
+		// supply counted as issued.
+		err = dbPutIssuanceHistoryStart(dbTx, height, totalSupply)
+		if err != nil {
+			return err
+		}
+
+		// The key IDs bound by the snapshot are registered at it.
+		err = dbResetKeyIDRegistry(dbTx, height, aspKeyIdMap)
+		if err != nil {
+			return err
+		}
+
+		// The blocks signed before the snapshot are not known, so the
+		// last blocks of the validate keys are tracked from it.
+		err = dbResetValidatorLastBlocks(dbTx, height, nil)
+		if err != nil {
+			return err
+		}
+
+		state := bestChainState{
+			hash:      hash,
+			height:    height,
+			totalTxns: totalTxns,
+			workSum:   workSum,
+		}
+		return meta.Put(chainStateKeyName, serializeBestChainState(state))
+	})
+	if err != nil {
+		return err
+	}
+
+	// Reload the chain state from the database, which now ends with the
+	// snapshot block.
+	b.bestNode = nil
+	b.index = make(map[chainhash.Hash]*blockNode)
+	b.depNodes = make(map[chainhash.Hash][]*blockNode)
+	b.nextCheckpoint = nil
+	b.checkpointHeader = nil
+	if err := b.initChainState(); err != nil {
+		return err
+	}
+
+	log.Infof("Imported utxo set snapshot of block %v (height %d)", hash,
+		height)
+	return nil
+}
+
+// snapshotIntegrityHash returns the double SHA-256 of the data written to the
+// passed SHA-256 hasher.
+func snapshotIntegrityHash(hasher hash.Hash) chainhash.Hash {
+	return chainhash.HashH(hasher.Sum(nil))
+}
+
+// writeElements writes the passed fixed size values to w in little-endian.
+func writeElements(w io.Writer, elements ...interface{}) error {
+	for _, element := range elements {
+		if err := binary.Write(w, binary.LittleEndian, element); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readElements reads the passed fixed size values from r in little-endian.
+func readElements(r io.Reader, elements ...interface{}) error {
+	for _, element := range elements {
+		if err := binary.Read(r, binary.LittleEndian, element); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+// snapshotChainSetup creates a regression test chain instance backed by a new
+// database in the passed directory with the passed checkpoints.
+func snapshotChainSetup(dir string, checkpoints []chaincfg.Checkpoint) (*blockchain.BlockChain, database.DB, error) {
+	params := chaincfg.RegressionNetParams
+	db, err := database.Create(testDbType, filepath.Join(dir, "db"),
+		params.Net)
+	if err != nil {
+		return nil, nil, err
+	}
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		Checkpoints: checkpoints,
+		TimeSource:  blockchain.NewMedianTime(),
+		SigCache:    txscript.NewSigCache(1000),
+	})
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return chain, db, nil
+}
+
+// TestUTXOSnapshot ensures a snapshot of the chain state exported by one chain
+// instance and imported by another recreates the same chain state, that the
+// importing chain is able to continue with the blocks after the snapshot, and
+// that invalid snapshots are rejected.
+func TestUTXOSnapshot(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	chain, teardownFunc, err := chainSetup("utxosnapshot",
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	for _, test := range tests {
+		for _, item := range test {
+			accepted, ok := item.(fullblocktests.AcceptedBlock)
+			if !ok {
+				continue
+			}
+			block := provautil.NewBlock(accepted.Block)
+			_, _, err := chain.ProcessBlock(block, blockchain.BFNone)
+			if err != nil {
+				t.Fatalf("ProcessBlock: %v", err)
+			}
+		}
+	}
+	best := chain.BestSnapshot()
+
+	dir, err := ioutil.TempDir("", "utxosnapshot")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A snapshot of the end of the main chain recreates the same chain
+	// state, which results in the same snapshot.
+	var snapshot bytes.Buffer
+	hash, err := chain.ExportUTXOSnapshot(&snapshot, best.Height)
+	if err != nil {
+		t.Fatalf("ExportUTXOSnapshot: %v", err)
+	}
+	checkpoints := []chaincfg.Checkpoint{{Height: best.Height, Hash: best.Hash}}
+	imported, db, err := snapshotChainSetup(filepath.Join(dir, "tip"),
+		checkpoints)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer db.Close()
+	err = imported.ImportUTXOSnapshot(bytes.NewReader(snapshot.Bytes()), hash)
+	if err != nil {
+		t.Fatalf("ImportUTXOSnapshot: %v", err)
+	}
+	got := imported.BestSnapshot()
+	if *got.Hash != *best.Hash || got.Height != best.Height ||
+		got.TotalTxns != best.TotalTxns {
+
+		t.Fatalf("ImportUTXOSnapshot: got best block %v (height %d, "+
+			"%d txns), want %v (height %d, %d txns)", got.Hash,
+			got.Height, got.TotalTxns, best.Hash, best.Height,
+			best.TotalTxns)
+	}
+	if imported.TotalSupply() != chain.TotalSupply() ||
+		imported.LastKeyID() != chain.LastKeyID() {
+
+		t.Fatal("ImportUTXOSnapshot: admin state does not match")
+	}
+	tip, err := chain.BlockByHeight(best.Height)
+	if err != nil {
+		t.Fatalf("BlockByHeight: %v", err)
+	}
+	for _, tx := range tip.Transactions() {
+		want, err := chain.FetchUtxoEntry(tx.Hash())
+		if err != nil {
+			t.Fatalf("FetchUtxoEntry: %v", err)
+		}
+		entry, err := imported.FetchUtxoEntry(tx.Hash())
+		if err != nil {
+			t.Fatalf("FetchUtxoEntry: %v", err)
+		}
+		if (want == nil) != (entry == nil) || (want != nil &&
+			want.AmountByIndex(0) != entry.AmountByIndex(0)) {
+
+			t.Fatalf("ImportUTXOSnapshot: utxo entry of %v does "+
+				"not match", tx.Hash())
+		}
+	}
+	var reexported bytes.Buffer
+	rehash, err := imported.ExportUTXOSnapshot(&reexported, best.Height)
+	if err != nil {
+		t.Fatalf("ExportUTXOSnapshot: %v", err)
+	}
+	if *rehash != *hash || !bytes.Equal(reexported.Bytes(), snapshot.Bytes()) {
+		t.Fatal("ExportUTXOSnapshot: snapshot of imported chain differs")
+	}
+
+	// A snapshot of an earlier block is followed by the blocks after it.
+	const depth = 2
+	var earlier bytes.Buffer
+	earlierHash, err := chain.ExportUTXOSnapshot(&earlier, best.Height-depth)
+	if err != nil {
+		t.Fatalf("ExportUTXOSnapshot: %v", err)
+	}
+	checkpointHash, err := chain.BlockHashByHeight(best.Height - depth)
+	if err != nil {
+		t.Fatalf("BlockHashByHeight: %v", err)
+	}
+	checkpoints = []chaincfg.Checkpoint{{
+		Height: best.Height - depth,
+		Hash:   checkpointHash,
+	}}
+	continued, db2, err := snapshotChainSetup(filepath.Join(dir, "earlier"),
+		checkpoints)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer db2.Close()
+	err = continued.ImportUTXOSnapshot(bytes.NewReader(earlier.Bytes()),
+		earlierHash)
+	if err != nil {
+		t.Fatalf("ImportUTXOSnapshot: %v", err)
+	}
+	for height := best.Height - depth + 1; height <= best.Height; height++ {
+		block, err := chain.BlockByHeight(height)
+		if err != nil {
+			t.Fatalf("BlockByHeight: %v", err)
+		}
+		isMainChain, _, err := continued.ProcessBlock(block,
+			blockchain.BFNone)
+		if err != nil || !isMainChain {
+			t.Fatalf("ProcessBlock: block at height %d not connected "+
+				"after snapshot: %v", height, err)
+		}
+	}
+	got = continued.BestSnapshot()
+	if *got.Hash != *best.Hash || got.TotalTxns != best.TotalTxns {
+		t.Fatalf("ProcessBlock: got best block %v with %d txns, want "+
+			"%v with %d txns", got.Hash, got.TotalTxns, best.Hash,
+			best.TotalTxns)
+	}
+
+	// Corrupt snapshots, tampered snapshots with a recomputed integrity
+	// hash, snapshots of blocks which are not checkpoints, and imports into
+	// chains which are not empty are rejected.
+	rejected, db3, err := snapshotChainSetup(filepath.Join(dir, "rejected"),
+		[]chaincfg.Checkpoint{{Height: best.Height, Hash: best.Hash}})
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer db3.Close()
+	corrupt := append([]byte(nil), snapshot.Bytes()...)
+	corrupt[len(corrupt)-chainhash.HashSize-2] ^= 0x01
+	tampered := append([]byte(nil), corrupt...)
+	trailer := len(tampered) - chainhash.HashSize
+	rehashed := chainhash.DoubleHashH(tampered[:trailer])
+	copy(tampered[trailer:], rehashed[:])
+	invalid := [][]byte{
+		corrupt,
+		tampered,
+		snapshot.Bytes()[:snapshot.Len()-1],
+		earlier.Bytes(),
+	}
+	for i, data := range invalid {
+		err := rejected.ImportUTXOSnapshot(bytes.NewReader(data), hash)
+		if err == nil {
+			t.Fatalf("ImportUTXOSnapshot #%d: accepted invalid "+
+				"snapshot", i)
+		}
+		if rejected.BestSnapshot().Height != 0 {
+			t.Fatalf("ImportUTXOSnapshot #%d: rejected snapshot "+
+				"changed the chain", i)
+		}
+	}
+	err = rejected.ImportUTXOSnapshot(bytes.NewReader(snapshot.Bytes()), nil)
+	if err == nil {
+		t.Fatal("ImportUTXOSnapshot: imported without an expected hash")
+	}
+	err = imported.ImportUTXOSnapshot(bytes.NewReader(snapshot.Bytes()), hash)
+	if err == nil {
+		t.Fatal("ImportUTXOSnapshot: imported into a non-empty chain")
+	}
+}
@@ -429,6 +429,14 @@ func CheckProofOfWork(block *provautil.Block, powLimit *big.Int) error {
 	return checkProofOfWork(&block.MsgBlock().Header, powLimit, BFNone)
 }
 
+// CheckHeaderProofOfWork ensures the passed block header bits which indicate
+// the target difficulty is in min/max range and that the block hash is less
+// than the target difficulty as claimed.  It allows headers to be validated
+// before their blocks are downloaded.
+func CheckHeaderProofOfWork(header *wire.BlockHeader, powLimit *big.Int) error {
+	return checkProofOfWork(header, powLimit, BFNone)
+}
+
 // CountSigOps returns the number of signature operations for all transaction
 // input and output scripts in the provided transaction.  This uses the
 // quicker, but imprecise, signature operation counting mechanism from
@@ -742,14 +750,14 @@ func (b *BlockChain) checkBlockHeaderContext(header *wire.BlockHeader, prevNode
 	// chain before it.  This prevents storage of new, otherwise valid,
 	// blocks which build off of old blocks that are likely at a much easier
 	// difficulty and therefore could be used to waste cache and disk space.
-	checkpointBlock, err := b.findPreviousCheckpoint()
+	checkpointHeader, err := b.findPreviousCheckpoint()
 	if err != nil {
 		return err
 	}
-	if checkpointBlock != nil && blockHeight < checkpointBlock.Height() {
+	if checkpointHeader != nil && blockHeight < checkpointHeader.Height {
 		str := fmt.Sprintf("block at height %d forks the main chain "+
 			"before the previous checkpoint at height %d",
-			blockHeight, checkpointBlock.Height())
+			blockHeight, checkpointHeader.Height)
 		return ruleError(ErrForkTooOld, str)
 	}
 
@@ -813,6 +821,15 @@ func (b *BlockChain) checkBlockContext(block *provautil.Block, prevNode *blockNo
 		return err
 	}
 
+	// A block must not exceed the maximum block size of the network, which
+	// may be lower than the maximum allowed block payload.
+	serializedSize := int(header.Size)
+	if maxSize := b.chainParams.BlockSizeLimit(); serializedSize > maxSize {
+		str := fmt.Sprintf("serialized block is too big - got %d, "+
+			"max %d", serializedSize, maxSize)
+		return ruleError(ErrBlockTooBig, str)
+	}
+
 	fastAdd := flags&BFFastAdd == BFFastAdd
 	if !fastAdd {
 		// The height of this block is one more than the referenced
@@ -1065,6 +1082,24 @@ func CheckProvaOutput(tx *provautil.Tx, txOutIndex int, keyIDs []btcec.KeyID,
 	return nil
 }
 
+// CheckTransactionTimelocks ensures the passed transaction only pays to
+// timelocked Prova scripts when the timelock deployment is active for the block
+// which includes it, as reported by IsDeploymentActive.
+func CheckTransactionTimelocks(tx *provautil.Tx, timelocksActive bool) error {
+	if timelocksActive {
+		return nil
+	}
+	for i, txOut := range tx.MsgTx().TxOut {
+		if txscript.GetScriptClass(txOut.PkScript) == txscript.ProvaTimelockTy {
+			str := fmt.Sprintf("transaction %v output %d pays to a "+
+				"timelocked script before timelocks are active",
+				tx.Hash(), i)
+			return ruleError(ErrTimelockNotActive, str)
+		}
+	}
+	return nil
+}
+
 // CheckTransactionOutputs performs a series of checks on the outputs to ensure
 // that they are valid in the context of the chain state.
 //
@@ -1210,6 +1245,21 @@ func (b *BlockChain) IsValidateKeyRateLimited(validatePubKey wire.BlockValidatin
 	return b.isValidateKeyRateLimited(b.bestNode, validatePubKey, true)
 }
 
+// ValidateKeyShare returns the number of blocks signed with the passed validate
+// key among the blocks which count towards its rate limit if it signed the
+// next block, which are the blocks in the averaging window ending at the end of
+// the current main chain.
+func (b *BlockChain) ValidateKeyShare(validatePubKey wire.BlockValidatingPubKey) (int, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	counts, err := b.validateWindowCounts()
+	if err != nil {
+		return 0, err
+	}
+	return counts[validatePubKey], nil
+}
+
 // isValidateKeyRateLimited determines whether or not a rate limiting violation
 // is present with a given validate key. This can be used prospectively to
 // evaluate a potential key for inclusion, or to validate an existing series
@@ -1244,6 +1294,36 @@ func (b *BlockChain) isValidateKeyRateLimited(node *blockNode, validatePubKey wi
 	return IsGenerationShareRateLimited(validatePubKey, prevPubKeys, maxBlocks, prospectiveInclusion, lastValidatePubKey), nil
 }
 
+// checkValidateKey ensures the validate key which signed the block of the
+// passed node is represented in the admin key set state of the passed view and
+// has not signed more than its permitted share of the recent blocks.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) checkValidateKey(node *blockNode, blockHeader *wire.BlockHeader, keyView *KeyViewpoint) error {
+	// Check that the validate key used to sign the block is represented in
+	// the current admin keyset state.
+	validateKeySet := keyView.Keys()[btcec.ValidateKeySet]
+	pubKey, err := btcec.ParsePubKey(blockHeader.ValidatingPubKey[:], btcec.S256())
+	if err != nil {
+		return err
+	}
+	if len(validateKeySet) > 0 && validateKeySet.Pos(pubKey) == -1 {
+		str := fmt.Sprintf("invalid validate key %x", pubKey.SerializeCompressed())
+		return ruleError(ErrInvalidValidateKey, str)
+	}
+
+	// Check to see if there is a validate key rate limit breach.
+	isRateLimited, err := b.isValidateKeyRateLimited(node, blockHeader.ValidatingPubKey, false)
+	if err != nil {
+		return err
+	}
+	if isRateLimited {
+		str := fmt.Sprintf("Validate key rate limited %v", blockHeader.ValidatingPubKey)
+		return ruleError(ErrExcessiveChainShare, str)
+	}
+	return nil
+}
+
 // checkConnectBlock performs several checks to confirm connecting the passed
 // block to the chain represented by the passed view does not violate any rules.
 // In addition, the passed view is updated to spend all of the referenced
@@ -1260,8 +1340,19 @@ func (b *BlockChain) isValidateKeyRateLimited(node *blockNode, validatePubKey wi
 // See the comments for CheckConnectBlock for some examples of the type of
 // checks performed by this function.
 //
+// The flags modify the behavior of this function as follows:
+//   - BFNoValidateKeyCheck: The validate key which signed the block is not
+//     checked
+//
 // This function MUST be called with the chain state lock held (for writes).
-func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block, utxoView *UtxoViewpoint, keyView *KeyViewpoint, stxos *[]spentTxOut) error {
+func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block, utxoView *UtxoViewpoint, keyView *KeyViewpoint, stxos *[]spentTxOut, flags BehaviorFlags) error {
+	// Everything but running the scripts is part of updating the utxo view.
+	start := time.Now()
+	var scriptTime time.Duration
+	defer func() {
+		b.timer.add(PhaseUtxoUpdate, time.Since(start)-scriptTime)
+	}()
+
 	// If the side chain blocks end up in the database, a call to
 	// CheckBlockSanity should be done here in case a previous version
 	// allowed a block that is no longer valid.  However, since the
@@ -1342,6 +1433,25 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block,
 		}
 	}
 
+	// Get the previous block node.  This function is used over simply
+	// accessing node.parent directly as it will dynamically create previous
+	// block nodes as needed.  This helps allow only the pieces of the chain
+	// that are needed to remain in memory.
+	prevNode, err := b.getPrevNodeFromNode(node)
+	if err != nil {
+		log.Errorf("getPrevNodeFromNode: %v", err)
+		return err
+	}
+
+	// Determine whether the timelock deployment applies to the block, which
+	// is either the case once its activation height is reached or once the
+	// validators signaled it.
+	timelocksActive, err := b.isDeploymentActive(prevNode,
+		chaincfg.DeploymentTimelocks)
+	if err != nil {
+		return err
+	}
+
 	// Perform several checks on the inputs for each transaction.  Also
 	// accumulate the total fees.  This could technically be combined with
 	// the loop above instead of running another loop over the transactions,
@@ -1371,6 +1481,10 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block,
 		if err != nil {
 			return err
 		}
+		err = CheckTransactionTimelocks(tx, timelocksActive)
+		if err != nil {
+			return err
+		}
 
 		// Add all of the outputs for this transaction which are not
 		// provably unspendable as available utxos.  Also, the passed
@@ -1416,16 +1530,6 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block,
 		runScripts = false
 	}
 
-	// Get the previous block node.  This function is used over simply
-	// accessing node.parent directly as it will dynamically create previous
-	// block nodes as needed.  This helps allow only the pieces of the chain
-	// that are needed to remain in memory.
-	prevNode, err := b.getPrevNodeFromNode(node)
-	if err != nil {
-		log.Errorf("getPrevNodeFromNode: %v", err)
-		return err
-	}
-
 	// Blocks created after the BIP0016 activation time need to have the
 	// pay-to-script-hash checks enabled.
 	var scriptFlags txscript.ScriptFlags
@@ -1443,18 +1547,6 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block,
 		scriptFlags |= txscript.ScriptVerifyDERSignatures
 	}
 
-	// Check that the validate key used to sign the block is represented in
-	// the current admin keyset state.
-	validateKeySet := keyView.Keys()[btcec.ValidateKeySet]
-	pubKey, err := btcec.ParsePubKey(blockHeader.ValidatingPubKey[:], btcec.S256())
-	if err != nil {
-		return err
-	}
-	if len(validateKeySet) > 0 && validateKeySet.Pos(pubKey) == -1 {
-		str := fmt.Sprintf("invalid validate key %x", pubKey.SerializeCompressed())
-		return ruleError(ErrInvalidValidateKey, str)
-	}
-
 	// Enforce CHECKLOCKTIMEVERIFY for block versions 4+ once the majority
 	// of the network has upgraded to the enforcement threshold.  This is
 	// part of BIP0065.
@@ -1464,14 +1556,44 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block,
 		scriptFlags |= txscript.ScriptVerifyCheckLockTimeVerify
 	}
 
-	// Check to see if there is a validate key rate limit breach.
-	isRateLimited, err := b.isValidateKeyRateLimited(node, blockHeader.ValidatingPubKey, false)
-	if err != nil {
-		return err
+	// Enforce CHECKLOCKTIMEVERIFY, CHECKSEQUENCEVERIFY, and the relative
+	// lock times of the transaction inputs once the timelock deployment is
+	// active.
+	if timelocksActive {
+		scriptFlags |= txscript.ScriptVerifyCheckLockTimeVerify |
+			txscript.ScriptVerifyCheckSequenceVerify
+
+		// The relative lock times are relative to the median time of
+		// the blocks before this one, like the lock times the
+		// transactions are finalized by.
+		medianTime, err := b.calcPastMedianTime(prevNode)
+		if err != nil {
+			return err
+		}
+		for _, tx := range transactions {
+			sequenceLock, err := b.calcSequenceLock(node, tx,
+				utxoView, false)
+			if err != nil {
+				return err
+			}
+			if !SequenceLockActive(sequenceLock, int32(node.height),
+				medianTime) {
+
+				str := fmt.Sprintf("block contains transaction "+
+					"%v whose input sequence locks are not "+
+					"met", tx.Hash())
+				return ruleError(ErrUnfinalizedTx, str)
+			}
+		}
 	}
-	if isRateLimited {
-		str := fmt.Sprintf("Validate key rate limited %v", blockHeader.ValidatingPubKey)
-		return ruleError(ErrExcessiveChainShare, str)
+
+	// Check the validate key used to sign the block unless the block is an
+	// unsigned template.
+	if flags&BFNoValidateKeyCheck != BFNoValidateKeyCheck {
+		err := b.checkValidateKey(node, blockHeader, keyView)
+		if err != nil {
+			return err
+		}
 	}
 
 	// Now that the inexpensive checks are done and have passed, verify the
@@ -1479,7 +1601,11 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block,
 	// expensive ECDSA signature check scripts.  Doing this last helps
 	// prevent CPU exhaustion attacks.
 	if runScripts {
-		err := checkBlockScripts(block, utxoView, keyView, scriptFlags, b.sigCache, b.hashCache)
+		scriptStart := time.Now()
+		err := checkBlockScripts(block, utxoView, keyView, scriptFlags,
+			b.sigCache, b.hashCache, b.scriptWorkers)
+		scriptTime = time.Since(scriptStart)
+		b.timer.add(PhaseScriptValidation, scriptTime)
 		if err != nil {
 			return err
 		}
@@ -1500,11 +1626,19 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *provautil.Block,
 // per block, invalid values in relation to the expected block subsidy, or fail
 // transaction script validation.
 //
+// The flags modify the behavior of this function as follows:
+//   - BFNoValidateKeyCheck: The validate key which signed the block is not
+//     checked, which allows checking unsigned block templates
+//
 // This function is safe for concurrent access.
-func (b *BlockChain) CheckConnectBlock(block *provautil.Block) error {
+func (b *BlockChain) CheckConnectBlock(block *provautil.Block, flags BehaviorFlags) error {
 	b.chainLock.Lock()
 	defer b.chainLock.Unlock()
 
+	// The block is not connected, so don't let the validation times of the
+	// check leak into those of the next block which is.
+	defer b.timer.discard()
+
 	prevNode := b.bestNode
 	newNode := newBlockNode(&block.MsgBlock().Header, block.Hash())
 	newNode.parent = prevNode
@@ -1527,5 +1661,5 @@ func (b *BlockChain) CheckConnectBlock(block *provautil.Block) error {
 	keyView.SetTotalSupply(b.totalSupply)
 	keyView.SetKeys(b.adminKeySets)
 	keyView.SetKeyIDs(b.aspKeyIdMap)
-	return b.checkConnectBlock(newNode, block, utxoView, keyView, nil)
+	return b.checkConnectBlock(newNode, block, utxoView, keyView, nil, flags)
 }
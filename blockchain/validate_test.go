@@ -91,7 +91,8 @@ func TestCheckConnectBlock(t *testing.T) {
 
 	// The genesis block should fail to connect since it's already inserted.
 	genesisBlock := chaincfg.MainNetParams.GenesisBlock
-	err = chain.CheckConnectBlock(provautil.NewBlock(genesisBlock))
+	err = chain.CheckConnectBlock(provautil.NewBlock(genesisBlock),
+		blockchain.BFNone)
 	if err == nil {
 		t.Errorf("CheckConnectBlock: Did not received expected error")
 	}
@@ -896,6 +897,45 @@ func TestCheckTransactionOutputs(t *testing.T) {
 	}
 }
 
+// TestCheckTransactionTimelocks ensures transactions only pay to timelocked
+// Prova scripts once the timelock deployment is active.
+func TestCheckTransactionTimelocks(t *testing.T) {
+	payAddr, _ := provautil.NewAddressProva(make([]byte, 20),
+		[]btcec.KeyID{1, 2}, &chaincfg.RegressionNetParams)
+	provaPkScript, _ := txscript.PayToAddrScript(payAddr)
+	timelockPkScript, _ := txscript.PayToAddrSequenceLockScript(payAddr, 10)
+
+	tests := []struct {
+		name     string
+		pkScript []byte
+		active   bool
+		rejected bool
+	}{
+		{"prova before activation", provaPkScript, false, false},
+		{"timelocked before activation", timelockPkScript, false, true},
+		{"timelocked after activation", timelockPkScript, true, false},
+	}
+
+	for _, test := range tests {
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil))
+		tx.AddTxOut(wire.NewTxOut(1000, test.pkScript))
+		err := blockchain.CheckTransactionTimelocks(provautil.NewTx(tx),
+			test.active)
+		if !test.rejected {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", test.name, err)
+			}
+			continue
+		}
+		rerr, ok := err.(blockchain.RuleError)
+		if !ok || rerr.ErrorCode != blockchain.ErrTimelockNotActive {
+			t.Errorf("%s: got %v, want %v", test.name, err,
+				blockchain.ErrTimelockNotActive)
+		}
+	}
+}
+
 // TestCheckTransactionInputs tests the CheckTransactionInputs API.
 func TestCheckTransactionInputs(t *testing.T) {
 	// Create some dummy, but otherwise standard, data for transactions.
@@ -0,0 +1,346 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"encoding/binary"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/wire"
+)
+
+var (
+	// validatorLastBlockBucketName is the name of the db bucket used to
+	// house the height of the last main chain block signed by each
+	// validate key.
+	validatorLastBlockBucketName = []byte("validatorlastblock")
+
+	// validatorLastBlockUndoBucketName is the name of the db bucket used to
+	// house the height of the block the validate key of each main chain
+	// block signed before it, so the last blocks are able to be restored
+	// when blocks are disconnected.
+	validatorLastBlockUndoBucketName = []byte("validatorlastblockundo")
+
+	// validatorLastBlockStartKeyName is the name of the db key used to
+	// store the height the last blocks of the validate keys are tracked
+	// from.
+	validatorLastBlockStartKeyName = []byte("validatorlastblockstart")
+)
+
+// ValidatorStats describes the blocks a validate key recently signed along with
+// how many more it is allowed to sign before it is rate limited.
+type ValidatorStats struct {
+	// PubKey is the described validate key.
+	PubKey wire.BlockValidatingPubKey
+
+	// WindowBlocks is the number of blocks signed by the key among the
+	// blocks of the averaging window ending at the end of the main chain,
+	// which count towards its rate limit.
+	WindowBlocks int
+
+	// Remaining is the number of blocks the key is allowed to sign within
+	// the averaging window before it is rate limited.  It is zero when the
+	// key is not allowed to sign the next block.
+	Remaining int
+
+	// LastBlockHeight and LastBlockHash identify the last main chain block
+	// signed by the key.  The hash is nil when the key has not signed a
+	// block since the height the last blocks are tracked from.
+	LastBlockHeight uint32
+	LastBlockHash   *chainhash.Hash
+}
+
+// -----------------------------------------------------------------------------
+// The last blocks of the validate keys consist of an entry for each validate
+// key which signed a main chain block, keyed by the compressed key, with the
+// height of the last such block as a uint32.
+//
+// The undo entries are keyed by the big endian height of each main chain block
+// whose validate key signed a block before it, with the height of that block as
+// a uint32.
+//
+// The last blocks are tracked from the height stored under the start key.
+// Databases which predate them start at the end of the main chain with the
+// blocks of the averaging window, and chains bootstrapped from a utxo set
+// snapshot start at the snapshot without any.
+// -----------------------------------------------------------------------------
+
+// validatorLastBlockUndoKey returns the db key of the undo entry of the passed
+// height.
+func validatorLastBlockUndoKey(height uint32) []byte {
+	var key [4]byte
+	binary.BigEndian.PutUint32(key[:], height)
+	return key[:]
+}
+
+// dbFetchValidatorLastBlock uses an existing database transaction to fetch the
+// height of the last main chain block signed by the passed validate key.  The
+// returned flag is false when the key has no entry.
+func dbFetchValidatorLastBlock(dbTx database.Tx, pubKey wire.BlockValidatingPubKey) (uint32, bool, error) {
+	bucket := dbTx.Metadata().Bucket(validatorLastBlockBucketName)
+	serialized := bucket.Get(pubKey[:])
+	if serialized == nil {
+		return 0, false, nil
+	}
+	if len(serialized) != 4 {
+		return 0, false, database.Error{
+			ErrorCode:   database.ErrCorruption,
+			Description: "corrupt validator last block entry",
+		}
+	}
+	return byteOrder.Uint32(serialized), true, nil
+}
+
+// dbPutValidatorLastBlock uses an existing database transaction to store the
+// height of the last main chain block signed by the passed validate key.
+func dbPutValidatorLastBlock(dbTx database.Tx, pubKey wire.BlockValidatingPubKey, height uint32) error {
+	var serialized [4]byte
+	byteOrder.PutUint32(serialized[:], height)
+	bucket := dbTx.Metadata().Bucket(validatorLastBlockBucketName)
+	return bucket.Put(pubKey[:], serialized[:])
+}
+
+// dbConnectValidatorLastBlock uses an existing database transaction to record
+// the passed node as the last block signed by its validate key.
+func dbConnectValidatorLastBlock(dbTx database.Tx, node *blockNode) error {
+	prevHeight, ok, err := dbFetchValidatorLastBlock(dbTx,
+		node.validatingPubKey)
+	if err != nil {
+		return err
+	}
+	if ok {
+		var serialized [4]byte
+		byteOrder.PutUint32(serialized[:], prevHeight)
+		bucket := dbTx.Metadata().Bucket(validatorLastBlockUndoBucketName)
+		err := bucket.Put(validatorLastBlockUndoKey(node.height),
+			serialized[:])
+		if err != nil {
+			return err
+		}
+	}
+	return dbPutValidatorLastBlock(dbTx, node.validatingPubKey, node.height)
+}
+
+// dbDisconnectValidatorLastBlock uses an existing database transaction to
+// restore the last block signed by the validate key of the passed node to the
+// one it signed before it.
+func dbDisconnectValidatorLastBlock(dbTx database.Tx, node *blockNode) error {
+	meta := dbTx.Metadata()
+	undoBucket := meta.Bucket(validatorLastBlockUndoBucketName)
+	undoKey := validatorLastBlockUndoKey(node.height)
+	serializedUndo := undoBucket.Get(undoKey)
+
+	height, ok, err := dbFetchValidatorLastBlock(dbTx, node.validatingPubKey)
+	if err != nil {
+		return err
+	}
+	if ok && height == node.height {
+		switch {
+		case len(serializedUndo) == 4:
+			err = dbPutValidatorLastBlock(dbTx, node.validatingPubKey,
+				byteOrder.Uint32(serializedUndo))
+		default:
+			bucket := meta.Bucket(validatorLastBlockBucketName)
+			err = bucket.Delete(node.validatingPubKey[:])
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return undoBucket.Delete(undoKey)
+}
+
+// dbResetValidatorLastBlocks uses an existing database transaction to track the
+// last blocks of the validate keys from the passed height, with the passed last
+// blocks known at that height.
+func dbResetValidatorLastBlocks(dbTx database.Tx, height uint32, lastBlocks map[wire.BlockValidatingPubKey]uint32) error {
+	meta := dbTx.Metadata()
+	for _, bucketName := range [][]byte{validatorLastBlockBucketName,
+		validatorLastBlockUndoBucketName} {
+
+		if meta.Bucket(bucketName) != nil {
+			if err := meta.DeleteBucket(bucketName); err != nil {
+				return err
+			}
+		}
+		if _, err := meta.CreateBucket(bucketName); err != nil {
+			return err
+		}
+	}
+	for pubKey, lastHeight := range lastBlocks {
+		err := dbPutValidatorLastBlock(dbTx, pubKey, lastHeight)
+		if err != nil {
+			return err
+		}
+	}
+	var serialized [4]byte
+	byteOrder.PutUint32(serialized[:], height)
+	return meta.Put(validatorLastBlockStartKeyName, serialized[:])
+}
+
+// initValidatorLastBlocks starts tracking the last blocks of the validate keys
+// when the database does not house them yet.  Databases which predate them
+// start at the end of the main chain with the blocks of the averaging window.
+func (b *BlockChain) initValidatorLastBlocks() error {
+	var exists bool
+	err := b.db.View(func(dbTx database.Tx) error {
+		exists = dbTx.Metadata().Bucket(validatorLastBlockBucketName) != nil
+		return nil
+	})
+	if err != nil || exists {
+		return err
+	}
+
+	lastBlocks := make(map[wire.BlockValidatingPubKey]uint32)
+	iterNode := b.bestNode
+	for i := 0; iterNode != nil && i < b.chainParams.PowAveragingWindow; i++ {
+		if _, ok := lastBlocks[iterNode.validatingPubKey]; !ok {
+			lastBlocks[iterNode.validatingPubKey] = iterNode.height
+		}
+		iterNode, err = b.getPrevNodeFromNode(iterNode)
+		if err != nil {
+			return err
+		}
+	}
+
+	height := b.bestNode.height
+	if height > 0 {
+		log.Infof("Validator last blocks are tracked from height %d",
+			height)
+	}
+	return b.db.Update(func(dbTx database.Tx) error {
+		return dbResetValidatorLastBlocks(dbTx, height, lastBlocks)
+	})
+}
+
+// validateWindowCounts returns the number of blocks signed by each validate
+// key among the blocks of the averaging window ending at the end of the main
+// chain.  The counts are tracked as blocks are connected and disconnected, and
+// tallied from the block index when they are not known.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) validateWindowCounts() (map[wire.BlockValidatingPubKey]int, error) {
+	if b.validateWindow != nil {
+		return b.validateWindow, nil
+	}
+
+	counts := make(map[wire.BlockValidatingPubKey]int)
+	iterNode := b.bestNode
+	for i := 0; iterNode != nil && i < b.chainParams.PowAveragingWindow; i++ {
+		counts[iterNode.validatingPubKey]++
+
+		var err error
+		iterNode, err = b.getPrevNodeFromNode(iterNode)
+		if err != nil {
+			return nil, err
+		}
+	}
+	b.validateWindow = counts
+	return counts, nil
+}
+
+// updateValidateWindow updates the tracked number of blocks signed by each
+// validate key in the averaging window for the passed node being connected to
+// or disconnected from the end of the main chain, which moves the window by
+// one block.  The counts are tallied again on their next use when the block
+// which enters or leaves the window on the other end is not able to be loaded.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) updateValidateWindow(node *blockNode, connected bool) {
+	if b.validateWindow == nil {
+		return
+	}
+
+	// Find the block which leaves the window when the node is connected,
+	// or enters it again when the node is disconnected.
+	edgeNode := node
+	for i := 0; edgeNode != nil && i < b.chainParams.PowAveragingWindow; i++ {
+		var err error
+		edgeNode, err = b.getPrevNodeFromNode(edgeNode)
+		if err != nil {
+			log.Warnf("Unable to update validate key window counts: %v",
+				err)
+			b.validateWindow = nil
+			return
+		}
+	}
+
+	addBlocks := func(pubKey wire.BlockValidatingPubKey, n int) {
+		b.validateWindow[pubKey] += n
+		if b.validateWindow[pubKey] <= 0 {
+			delete(b.validateWindow, pubKey)
+		}
+	}
+	delta := 1
+	if !connected {
+		delta = -1
+	}
+	addBlocks(node.validatingPubKey, delta)
+	if edgeNode != nil {
+		addBlocks(edgeNode.validatingPubKey, -delta)
+	}
+}
+
+// ValidatorStats returns the number of blocks each key of the validate key set
+// of the main chain signed in the averaging window ending at the end of the
+// main chain, how many more it is allowed to sign before it is rate limited,
+// and the last main chain block it signed.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ValidatorStats() ([]ValidatorStats, error) {
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	counts, err := b.validateWindowCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	keySet := b.AdminKeySets()[btcec.ValidateKeySet]
+	stats := make([]ValidatorStats, 0, len(keySet))
+	err = b.db.View(func(dbTx database.Tx) error {
+		for i := range keySet {
+			var pubKey wire.BlockValidatingPubKey
+			copy(pubKey[:], keySet[i].SerializeCompressed())
+
+			// Validate keys are not rate limited when there is no
+			// maximum share of the window.
+			windowBlocks := counts[pubKey]
+			remaining := b.chainParams.PowAveragingWindow
+			if maxBlocks := b.chainParams.ChainWindowMaxBlocks; maxBlocks > 0 {
+				remaining = maxBlocks - windowBlocks
+				if remaining < 0 {
+					remaining = 0
+				}
+			}
+			keyStats := ValidatorStats{
+				PubKey:       pubKey,
+				WindowBlocks: windowBlocks,
+				Remaining:    remaining,
+			}
+
+			height, ok, err := dbFetchValidatorLastBlock(dbTx, pubKey)
+			if err != nil {
+				return err
+			}
+			if ok {
+				hash, err := dbFetchHashByHeight(dbTx, height)
+				if err != nil {
+					return err
+				}
+				keyStats.LastBlockHeight = height
+				keyStats.LastBlockHash = hash
+			}
+			stats = append(stats, keyStats)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
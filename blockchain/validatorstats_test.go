@@ -0,0 +1,118 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestValidatorStats ensures the window counts and last blocks of the validate
+// keys of a chain which processed the blocks generated by the fullblocktests
+// package, which reorganize the chain, match the blocks of its main chain.
+func TestValidatorStats(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	params := &chaincfg.RegressionNetParams
+	chain, teardownFunc, err := chainSetup("validatorstats", params)
+	if err != nil {
+		t.Fatalf("Failed to setup chain instance: %v", err)
+	}
+	defer teardownFunc()
+
+	for _, test := range tests {
+		for _, item := range test {
+			var block *provautil.Block
+			switch item := item.(type) {
+			case fullblocktests.AcceptedBlock:
+				block = provautil.NewBlock(item.Block)
+			case fullblocktests.RejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			case fullblocktests.OrphanOrRejectedBlock:
+				block = provautil.NewBlock(item.Block)
+			default:
+				continue
+			}
+			chain.ProcessBlock(block, blockchain.BFNone)
+		}
+	}
+	best := chain.BestSnapshot()
+
+	// Tally the blocks of the main chain signed by each validate key.
+	windowBlocks := make(map[wire.BlockValidatingPubKey]int)
+	lastBlocks := make(map[wire.BlockValidatingPubKey]uint32)
+	for height := uint32(0); height <= best.Height; height++ {
+		hash, err := chain.BlockHashByHeight(height)
+		if err != nil {
+			t.Fatalf("BlockHashByHeight: %v", err)
+		}
+		header, err := chain.FetchHeader(hash)
+		if err != nil {
+			t.Fatalf("FetchHeader: %v", err)
+		}
+		pubKey := header.ValidatingPubKey
+		lastBlocks[pubKey] = height
+		if best.Height-height < uint32(params.PowAveragingWindow) {
+			windowBlocks[pubKey]++
+		}
+	}
+
+	stats, err := chain.ValidatorStats()
+	if err != nil {
+		t.Fatalf("ValidatorStats: %v", err)
+	}
+	if len(stats) == 0 {
+		t.Fatal("ValidatorStats: no validate keys")
+	}
+	var signed int
+	for _, keyStats := range stats {
+		pubKey := keyStats.PubKey
+		if keyStats.WindowBlocks != windowBlocks[pubKey] {
+			t.Fatalf("ValidatorStats(%v): got %d window blocks, want %d",
+				pubKey, keyStats.WindowBlocks, windowBlocks[pubKey])
+		}
+		share, err := chain.ValidateKeyShare(pubKey)
+		if err != nil {
+			t.Fatalf("ValidateKeyShare: %v", err)
+		}
+		if share != keyStats.WindowBlocks {
+			t.Fatalf("ValidateKeyShare(%v): got %d, want %d", pubKey,
+				share, keyStats.WindowBlocks)
+		}
+
+		lastHeight, ok := lastBlocks[pubKey]
+		if !ok {
+			if keyStats.LastBlockHash != nil {
+				t.Fatalf("ValidatorStats(%v): unexpected last block "+
+					"%v", pubKey, keyStats.LastBlockHash)
+			}
+			continue
+		}
+		signed++
+		lastHash, err := chain.BlockHashByHeight(lastHeight)
+		if err != nil {
+			t.Fatalf("BlockHashByHeight: %v", err)
+		}
+		if keyStats.LastBlockHash == nil ||
+			!keyStats.LastBlockHash.IsEqual(lastHash) ||
+			keyStats.LastBlockHeight != lastHeight {
+
+			t.Fatalf("ValidatorStats(%v): got last block %v (%d), "+
+				"want %v (%d)", pubKey, keyStats.LastBlockHash,
+				keyStats.LastBlockHeight, lastHash, lastHeight)
+		}
+	}
+	if signed == 0 {
+		t.Fatal("ValidatorStats: no validate key signed a block")
+	}
+}
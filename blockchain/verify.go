@@ -0,0 +1,505 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// VerifyLevel specifies how thoroughly VerifyChain checks the blocks.  Each
+// level performs the checks of the levels below it as well.
+type VerifyLevel uint8
+
+const (
+	// VerifyLoad ensures each block can be loaded from the database.
+	VerifyLoad VerifyLevel = iota
+
+	// VerifySanity performs the context-free sanity checks on each block.
+	VerifySanity
+
+	// VerifyUtxoSet disconnects the blocks from a view of the utxo set
+	// with their spend journal entries, the same way a reorganization
+	// does, and ensures the outputs each block creates are unspent in the
+	// utxo set as of the block.  This cross-checks the utxo set against
+	// the spend journal.
+	VerifyUtxoSet
+
+	// VerifyConnect reconnects the disconnected blocks with full
+	// validation, including the scripts, and ensures connecting them
+	// reproduces their spend journal entries and the utxo set.
+	VerifyConnect
+)
+
+// ChainInconsistency describes an inconsistency VerifyChain found in the chain
+// state.
+type ChainInconsistency struct {
+	// Hash and Height identify the block the inconsistency was found for.
+	// The hash is nil for inconsistencies of the best chain state records.
+	Hash   *chainhash.Hash
+	Height uint32
+
+	// Description describes the inconsistency.
+	Description string
+}
+
+// String returns the inconsistency in a human-readable form.
+func (c *ChainInconsistency) String() string {
+	if c.Hash == nil {
+		return c.Description
+	}
+	return fmt.Sprintf("block %v (height %d): %s", c.Hash, c.Height,
+		c.Description)
+}
+
+// ChainVerification houses the outcome of VerifyChain.
+type ChainVerification struct {
+	// BlocksChecked is the number of blocks which passed all checks of the
+	// requested level.
+	BlocksChecked uint32
+
+	// BestStateRepaired is whether or not the best chain state records
+	// diverged from the chain state and were rewritten.
+	BestStateRepaired bool
+
+	// Inconsistencies houses the inconsistencies which were found.  The
+	// checks of the blocks stop at the first inconsistent block since the
+	// checks of the blocks before it depend on it.
+	Inconsistencies []ChainInconsistency
+}
+
+// Verified returns whether or not no inconsistencies were found.
+func (v *ChainVerification) Verified() bool {
+	return len(v.Inconsistencies) == 0
+}
+
+// VerifyChain checks the best chain state records and up to the passed number
+// of the most recent main chain blocks according to the passed level.  The
+// genesis block is never checked, and the checks stop at the first pruned
+// block.
+//
+// The best chain state records, which hold the best block along with the
+// admin state, are compared with the chain state the chain is running with.
+// When they diverge and repair is set, they are rewritten rather than
+// reported.
+//
+// The returned error is only set when the verification could not be
+// performed.  Everything which was found to be inconsistent is reported by
+// the returned verification instead.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) VerifyChain(level VerifyLevel, depth uint32, repair bool) (*ChainVerification, error) {
+	if level > VerifyConnect {
+		return nil, fmt.Errorf("verification level %d is not supported",
+			level)
+	}
+
+	b.chainLock.Lock()
+	defer b.chainLock.Unlock()
+
+	// The blocks are not connected, so don't let the validation times of
+	// the verification leak into those of the next block which is.
+	defer b.timer.discard()
+
+	result := &ChainVerification{}
+	desc, err := b.checkBestStateRecords()
+	if err != nil {
+		return nil, err
+	}
+	if desc != "" {
+		if repair {
+			log.Warnf("Repairing best chain state records: %s", desc)
+			err := b.db.Update(func(dbTx database.Tx) error {
+				return b.putBestStateRecords(dbTx)
+			})
+			if err != nil {
+				return nil, err
+			}
+			result.BestStateRepaired = true
+		} else {
+			result.Inconsistencies = append(result.Inconsistencies,
+				ChainInconsistency{Description: desc})
+		}
+	}
+
+	inconsistency, checked, err := b.verifyBlocks(level, depth)
+	if err != nil {
+		return nil, err
+	}
+	result.BlocksChecked = checked
+	if inconsistency != nil {
+		result.Inconsistencies = append(result.Inconsistencies,
+			*inconsistency)
+	}
+	return result, nil
+}
+
+// checkBestStateRecords compares the best chain state records in the database
+// with the chain state.  It returns the description of the first difference,
+// or an empty string when they match.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) checkBestStateRecords() (string, error) {
+	var desc string
+	err := b.db.View(func(dbTx database.Tx) error {
+		stored := dbTx.Metadata().Get(chainStateKeyName)
+		state := serializeBestChainState(bestChainState{
+			hash:      *b.stateSnapshot.Hash,
+			height:    b.stateSnapshot.Height,
+			totalTxns: b.stateSnapshot.TotalTxns,
+			workSum:   b.bestNode.workSum,
+		})
+		if !bytes.Equal(stored, state) {
+			desc = "the best chain state record does not match the " +
+				"best block"
+			return nil
+		}
+
+		stored = dbTx.Metadata().Get(keySetBucketName)
+		keySet := serializeKeySet(b.adminKeySets, b.aspKeyIdMap,
+			b.threadTips, b.lastKeyID, b.totalSupply)
+		if !bytes.Equal(stored, keySet) {
+			desc = "the admin state record does not match the admin " +
+				"state"
+		}
+		return nil
+	})
+	return desc, err
+}
+
+// putBestStateRecords uses an existing database transaction to rewrite the
+// best chain state records from the chain state.
+//
+// This function MUST be called with the chain state lock held (for reads).
+func (b *BlockChain) putBestStateRecords(dbTx database.Tx) error {
+	err := dbPutBestState(dbTx, b.stateSnapshot, b.bestNode.workSum)
+	if err != nil {
+		return err
+	}
+	return dbPutKeySet(dbTx, b.adminKeySets, b.aspKeyIdMap, b.threadTips,
+		b.lastKeyID, b.totalSupply)
+}
+
+// verifiedBlock houses a block checked by verifyBlocks along with its node.
+type verifiedBlock struct {
+	node  *blockNode
+	block *provautil.Block
+}
+
+// verifyBlocks checks up to the passed number of the most recent main chain
+// blocks according to the passed level as described by VerifyChain.  It
+// returns the first inconsistency which was found, if any, along with the
+// number of blocks which passed all checks.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) verifyBlocks(level VerifyLevel, depth uint32) (*ChainInconsistency, uint32, error) {
+	if depth > b.bestNode.height {
+		depth = b.bestNode.height
+	}
+	if depth > 0 {
+		log.Infof("Verifying the last %d blocks at level %d", depth,
+			level)
+	}
+
+	utxoView := NewUtxoViewpoint()
+	utxoView.SetBestHash(b.bestNode.hash)
+	keyView := NewKeyViewpoint()
+	keyView.SetThreadTips(b.threadTips)
+	keyView.SetLastKeyID(b.lastKeyID)
+	keyView.SetTotalSupply(b.totalSupply)
+	keyView.SetKeys(b.adminKeySets)
+	keyView.SetKeyIDs(b.aspKeyIdMap)
+
+	// Check the blocks from the end of the main chain backwards,
+	// disconnecting them from the views when the level requires it.
+	var checked []verifiedBlock
+	node := b.bestNode
+	for i := uint32(0); i < depth; i++ {
+		var block *provautil.Block
+		err := b.db.View(func(dbTx database.Tx) error {
+			var err error
+			block, err = dbFetchBlockByHash(dbTx, node.hash)
+			return err
+		})
+		if isDbBlockPrunedErr(err) {
+			log.Infof("Stopping verification at pruned block %v "+
+				"(height %d)", node.hash, node.height)
+			break
+		}
+		if err != nil {
+			return blockInconsistency(node, fmt.Sprintf("unable to "+
+				"load block: %v", err)), uint32(len(checked)), nil
+		}
+
+		if level >= VerifySanity {
+			err := checkBlockSanity(block, b.chainParams.PowLimit,
+				b.timeSource, BFNone)
+			if err != nil {
+				return blockInconsistency(node, err.Error()),
+					uint32(len(checked)), nil
+			}
+		}
+
+		if level >= VerifyUtxoSet {
+			desc, err := b.disconnectVerifiedBlock(block, utxoView,
+				keyView)
+			if err != nil {
+				return nil, 0, err
+			}
+			if desc != "" {
+				return blockInconsistency(node, desc),
+					uint32(len(checked)), nil
+			}
+		}
+
+		checked = append(checked, verifiedBlock{node: node, block: block})
+		prevNode, err := b.getPrevNodeFromNode(node)
+		if err != nil {
+			return nil, 0, err
+		}
+		node = prevNode
+	}
+	if level < VerifyConnect || len(checked) == 0 {
+		if len(checked) > 0 {
+			log.Infof("Verified %d blocks", len(checked))
+		}
+		return nil, uint32(len(checked)), nil
+	}
+
+	// Reconnect the disconnected blocks in the order they were originally
+	// connected.
+	checkpoint, err := b.findPreviousCheckpoint()
+	if err != nil {
+		return nil, 0, err
+	}
+	for i := len(checked) - 1; i >= 0; i-- {
+		node, block := checked[i].node, checked[i].block
+		numConnected := uint32(len(checked) - 1 - i)
+
+		// Blocks before the previous checkpoint are only able to be
+		// part of the chain leading to it, so their header context is
+		// not checked again since it would be rejected as forking the
+		// chain before the checkpoint.
+		if checkpoint == nil || node.height >= checkpoint.Height {
+			prevNode, err := b.getPrevNodeFromNode(node)
+			if err != nil {
+				return nil, 0, err
+			}
+			err = b.checkBlockContext(block, prevNode, BFNone)
+			if err != nil {
+				return blockInconsistency(node, err.Error()),
+					numConnected, nil
+			}
+		}
+
+		var stxos []spentTxOut
+		err := b.checkConnectBlock(node, block, utxoView, keyView,
+			&stxos, BFNone)
+		if err != nil {
+			return blockInconsistency(node, err.Error()),
+				numConnected, nil
+		}
+
+		var stored []byte
+		err = b.db.View(func(dbTx database.Tx) error {
+			spendBucket := dbTx.Metadata().Bucket(spendJournalBucketName)
+			stored = spendBucket.Get(block.Hash()[:])
+			return nil
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		if !bytes.Equal(stored, serializeSpendJournalEntry(stxos)) {
+			return blockInconsistency(node, "the spend journal entry "+
+					"does not match the outputs spent by the block"),
+				numConnected, nil
+		}
+	}
+
+	// Reconnecting the blocks reproduces the utxo set and admin state the
+	// chain is running with.
+	tip := checked[0].node
+	desc, err := b.compareUtxoView(utxoView)
+	if err != nil {
+		return nil, 0, err
+	}
+	if desc == "" && (keyView.TotalSupply() != b.totalSupply ||
+		keyView.LastKeyID() != b.lastKeyID) {
+
+		desc = "reconnecting the blocks does not reproduce the admin " +
+			"state"
+	}
+	if desc != "" {
+		return blockInconsistency(tip, desc), uint32(len(checked)) - 1,
+			nil
+	}
+	log.Infof("Verified %d blocks", len(checked))
+	return nil, uint32(len(checked)), nil
+}
+
+// blockInconsistency returns an inconsistency of the block of the passed node
+// with the passed description.
+func blockInconsistency(node *blockNode, desc string) *ChainInconsistency {
+	return &ChainInconsistency{
+		Hash:        node.hash,
+		Height:      node.height,
+		Description: desc,
+	}
+}
+
+// disconnectVerifiedBlock ensures the outputs created by the passed block are
+// unspent in the passed utxo view, which represents the utxo set as of the
+// block, unless they are spent by the block itself.  It then disconnects the
+// block from the views with its spend journal entry.  The description of the
+// first inconsistency is returned, or an empty string when none is found.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) disconnectVerifiedBlock(block *provautil.Block, utxoView *UtxoViewpoint, keyView *KeyViewpoint) (string, error) {
+	transactions := block.Transactions()
+	txSet := make(map[chainhash.Hash]struct{}, len(transactions))
+	spentInBlock := make(map[wire.OutPoint]struct{})
+	for _, tx := range transactions {
+		txSet[*tx.Hash()] = struct{}{}
+		if IsCoinBase(tx) {
+			continue
+		}
+		for _, txIn := range tx.MsgTx().TxIn {
+			spentInBlock[txIn.PreviousOutPoint] = struct{}{}
+		}
+	}
+	if err := utxoView.fetchUtxos(b.db, txSet); err != nil {
+		return "", err
+	}
+
+	for txIdx, tx := range transactions {
+		entry := utxoView.LookupEntry(tx.Hash())
+		if entry != nil && (entry.BlockHeight() != block.Height() ||
+			entry.IsCoinBase() != (txIdx == 0)) {
+
+			return fmt.Sprintf("utxo entry of transaction %v does "+
+				"not match the block", tx.Hash()), nil
+		}
+		for txOutIdx, txOut := range tx.MsgTx().TxOut {
+			outPoint := wire.OutPoint{
+				Hash:  *tx.Hash(),
+				Index: uint32(txOutIdx),
+			}
+			if _, ok := spentInBlock[outPoint]; ok {
+				continue
+			}
+			if txscript.IsUnspendable(txOut.PkScript) {
+				continue
+			}
+			if entry == nil || entry.IsOutputSpent(outPoint.Index) {
+				return fmt.Sprintf("output %v is missing from the "+
+					"utxo set", outPoint), nil
+			}
+			if entry.AmountByIndex(outPoint.Index) != txOut.Value ||
+				!bytes.Equal(entry.PkScriptByIndex(outPoint.Index),
+					txOut.PkScript) {
+
+				return fmt.Sprintf("output %v does not match the "+
+					"utxo set", outPoint), nil
+			}
+		}
+	}
+
+	if err := utxoView.fetchInputUtxos(b.db, block); err != nil {
+		return "", err
+	}
+
+	// The spend journal entry of the block which spends the last output of
+	// the genesis coinbase does not hold its version since the outputs of
+	// the genesis block are at height zero, so provide it with an entry
+	// without any outputs.
+	genesisTx := b.chainParams.GenesisBlock.Transactions[0]
+	genesisHash := genesisTx.TxHash()
+	if entry, ok := utxoView.entries[genesisHash]; ok && entry == nil {
+		utxoView.entries[genesisHash] = newUtxoEntry(genesisTx.Version,
+			true, 0)
+	}
+
+	var stxos []spentTxOut
+	err := b.db.View(func(dbTx database.Tx) error {
+		var err error
+		stxos, err = dbFetchSpendJournalEntry(dbTx, block, utxoView)
+		return err
+	})
+	if err != nil {
+		return fmt.Sprintf("unable to load spend journal entry: %v",
+			err), nil
+	}
+	if err := utxoView.disconnectTransactions(block, stxos); err != nil {
+		return err.Error(), nil
+	}
+	if err := keyView.disconnectTransactions(block); err != nil {
+		return err.Error(), nil
+	}
+	return "", nil
+}
+
+// compareUtxoView compares the entries of the passed view with those of the
+// utxo set in the database.  It returns the description of the first
+// difference, or an empty string when they match.
+func (b *BlockChain) compareUtxoView(view *UtxoViewpoint) (string, error) {
+	var desc string
+	err := b.db.View(func(dbTx database.Tx) error {
+		for txHash, entry := range view.entries {
+			hash := txHash
+			stored, err := dbFetchUtxoEntry(dbTx, &hash)
+			if err != nil {
+				return err
+			}
+			if !utxoEntriesEqual(entry, stored) {
+				desc = fmt.Sprintf("utxo entry of transaction %v "+
+					"does not match the reconnected blocks",
+					txHash)
+				return nil
+			}
+		}
+		return nil
+	})
+	return desc, err
+}
+
+// utxoEntriesEqual returns whether or not the passed entries hold the same
+// unspent outputs.  Nil entries are treated as fully spent.
+func utxoEntriesEqual(a, b *UtxoEntry) bool {
+	aSpent := a == nil || a.IsFullySpent()
+	bSpent := b == nil || b.IsFullySpent()
+	if aSpent || bSpent {
+		return aSpent == bSpent
+	}
+	if a.version != b.version || a.blockHeight != b.blockHeight ||
+		a.isCoinBase != b.isCoinBase {
+
+		return false
+	}
+
+	// Every unspent output of either entry must be an unspent output of
+	// the other one with the same amount and public key script.
+	for _, pair := range [][2]*UtxoEntry{{a, b}, {b, a}} {
+		x, y := pair[0], pair[1]
+		for outputIndex, output := range x.sparseOutputs {
+			if output.spent {
+				continue
+			}
+			if y.IsOutputSpent(outputIndex) ||
+				x.AmountByIndex(outputIndex) != y.AmountByIndex(outputIndex) ||
+				!bytes.Equal(x.PkScriptByIndex(outputIndex),
+					y.PkScriptByIndex(outputIndex)) {
+
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+// TestVerifyChain ensures the chain built from the blocks generated by the
+// fullblocktests package verifies at every level, that corrupt best chain
+// state records are reported and repaired, both by VerifyChain and when the
+// chain is loaded, and that a utxo set which diverges from the blocks is
+// reported.
+func TestVerifyChain(t *testing.T) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		t.Fatalf("failed to generate tests: %v", err)
+	}
+
+	dbPath := filepath.Join(os.TempDir(), "verifychain")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(testDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("error creating db: %v", err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	params := chaincfg.RegressionNetParams
+	newChain := func() *blockchain.BlockChain {
+		chain, err := blockchain.New(&blockchain.Config{
+			DB:          db,
+			ChainParams: &params,
+			TimeSource:  blockchain.NewMedianTime(),
+			SigCache:    txscript.NewSigCache(1000),
+		})
+		if err != nil {
+			t.Fatalf("failed to create chain instance: %v", err)
+		}
+		return chain
+	}
+	chain := newChain()
+	for _, test := range tests {
+		for _, item := range test {
+			switch item := item.(type) {
+			case fullblocktests.AcceptedBlock:
+				block := provautil.NewBlock(item.Block)
+				_, _, _ = chain.ProcessBlock(block, blockchain.BFNone)
+			case fullblocktests.RejectedBlock:
+				block := provautil.NewBlock(item.Block)
+				_, _, _ = chain.ProcessBlock(block, blockchain.BFNone)
+			}
+		}
+	}
+	best := chain.BestSnapshot()
+	if best.Height < 2 {
+		t.Fatalf("chain only has %d blocks", best.Height)
+	}
+
+	// Every level verifies the whole chain except the genesis block.
+	for level := blockchain.VerifyLoad; level <= blockchain.VerifyConnect; level++ {
+		result, err := chain.VerifyChain(level, best.Height+10, false)
+		if err != nil {
+			t.Fatalf("VerifyChain (level %d): unexpected error: %v",
+				level, err)
+		}
+		if !result.Verified() || result.BlocksChecked != best.Height {
+			t.Fatalf("VerifyChain (level %d): got %d blocks checked "+
+				"and inconsistencies %v, want %d blocks checked",
+				level, result.BlocksChecked,
+				result.Inconsistencies, best.Height)
+		}
+	}
+	if _, err := chain.VerifyChain(blockchain.VerifyConnect+1, 1, false); err == nil {
+		t.Fatal("VerifyChain: unsupported level accepted")
+	}
+
+	// A corrupt best chain state record is reported unless it is repaired.
+	if err := chain.TstPutBestStateRecord([]byte{0x01}); err != nil {
+		t.Fatalf("TstPutBestStateRecord: unexpected error: %v", err)
+	}
+	result, err := chain.VerifyChain(blockchain.VerifyLoad, 0, false)
+	if err != nil {
+		t.Fatalf("VerifyChain: unexpected error: %v", err)
+	}
+	if len(result.Inconsistencies) != 1 ||
+		result.Inconsistencies[0].Hash != nil || result.BestStateRepaired {
+
+		t.Fatalf("VerifyChain: got inconsistencies %v, want the best "+
+			"chain state record", result.Inconsistencies)
+	}
+	result, err = chain.VerifyChain(blockchain.VerifyLoad, 0, true)
+	if err != nil || !result.Verified() || !result.BestStateRepaired {
+		t.Fatalf("VerifyChain (repair): got %+v, %v, want the best "+
+			"chain state record repaired", result, err)
+	}
+	result, err = chain.VerifyChain(blockchain.VerifyLoad, 0, false)
+	if err != nil || !result.Verified() {
+		t.Fatalf("VerifyChain: got %+v, %v after repair", result, err)
+	}
+
+	// A corrupt best chain state record is rebuilt from the block index
+	// when the chain is loaded.
+	if err := chain.TstPutBestStateRecord([]byte{0x01}); err != nil {
+		t.Fatalf("TstPutBestStateRecord: unexpected error: %v", err)
+	}
+	chain = newChain()
+	rebuilt := chain.BestSnapshot()
+	if *rebuilt.Hash != *best.Hash || rebuilt.Height != best.Height ||
+		rebuilt.TotalTxns != best.TotalTxns {
+
+		t.Fatalf("New: got best state %+v after rebuilding, want %+v",
+			rebuilt, best)
+	}
+	result, err = chain.VerifyChain(blockchain.VerifyConnect, 2, false)
+	if err != nil || !result.Verified() {
+		t.Fatalf("VerifyChain: got %+v, %v after rebuilding", result,
+			err)
+	}
+
+	// Removing the outputs of the coinbase of the best block from the utxo
+	// set is reported for the best block.
+	block, err := chain.BlockByHash(best.Hash)
+	if err != nil {
+		t.Fatalf("BlockByHash: unexpected error: %v", err)
+	}
+	if err := chain.TstDeleteUtxoEntry(block.Transactions()[0].Hash()); err != nil {
+		t.Fatalf("TstDeleteUtxoEntry: unexpected error: %v", err)
+	}
+	for _, level := range []blockchain.VerifyLevel{blockchain.VerifySanity,
+		blockchain.VerifyUtxoSet} {
+
+		result, err := chain.VerifyChain(level, 1, false)
+		if err != nil {
+			t.Fatalf("VerifyChain (level %d): unexpected error: %v",
+				level, err)
+		}
+		wantFound := level >= blockchain.VerifyUtxoSet
+		if result.Verified() == wantFound {
+			t.Fatalf("VerifyChain (level %d): got inconsistencies "+
+				"%v, want found %v", level,
+				result.Inconsistencies, wantFound)
+		}
+		if wantFound && *result.Inconsistencies[0].Hash != *best.Hash {
+			t.Fatalf("VerifyChain (level %d): got inconsistency %v, "+
+				"want block %v", level,
+				result.Inconsistencies[0].String(), best.Hash)
+		}
+	}
+}
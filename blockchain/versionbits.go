@@ -0,0 +1,201 @@
+// Copyright (c) 2016 The btcsuite developers
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package blockchain
+
+import (
+	"github.com/bitgo/prova/chaincfg"
+)
+
+const (
+	// vbTopBits defines the bits to set in the version to signal that the
+	// version bits scheme is being used.
+	vbTopBits = 0x20000000
+
+	// vbTopMask is the bitmask to use to determine whether or not the
+	// version bits scheme is in use.
+	vbTopMask = 0xe0000000
+)
+
+// deploymentChecker provides a thresholdConditionChecker which can be used to
+// test a specific deployment rule.  This is required for properly detecting
+// and activating consensus rule changes.
+type deploymentChecker struct {
+	deployment *chaincfg.ConsensusDeployment
+	chain      *BlockChain
+}
+
+// Ensure the deploymentChecker type implements the thresholdConditionChecker
+// interface.
+var _ thresholdConditionChecker = deploymentChecker{}
+
+// BeginTime returns the unix timestamp for the median block time after which
+// voting on a rule change starts (at the next window).
+//
+// This implementation returns the value defined by the specific deployment the
+// checker is associated with.
+//
+// This is part of the thresholdConditionChecker interface implementation.
+func (c deploymentChecker) BeginTime() uint64 {
+	return c.deployment.StartTime
+}
+
+// EndTime returns the unix timestamp for the median block time after which an
+// attempted rule change fails if it has not already been locked in or
+// activated.
+//
+// This implementation returns the value defined by the specific deployment the
+// checker is associated with.
+//
+// This is part of the thresholdConditionChecker interface implementation.
+func (c deploymentChecker) EndTime() uint64 {
+	return c.deployment.ExpireTime
+}
+
+// RuleChangeActivationThreshold is the number of blocks for which the condition
+// must be true in order to lock in a rule change.
+//
+// This implementation returns the value defined by the chain params the checker
+// is associated with.
+//
+// This is part of the thresholdConditionChecker interface implementation.
+func (c deploymentChecker) RuleChangeActivationThreshold() uint32 {
+	return c.chain.chainParams.RuleChangeActivationThreshold
+}
+
+// MinerConfirmationWindow is the number of blocks in each threshold state
+// retarget window.
+//
+// This implementation returns the value defined by the chain params the checker
+// is associated with.
+//
+// This is part of the thresholdConditionChecker interface implementation.
+func (c deploymentChecker) MinerConfirmationWindow() uint32 {
+	return c.chain.chainParams.MinerConfirmationWindow
+}
+
+// Condition returns true when the specific bit defined by the deployment
+// associated with the checker is set.  Since block versions are part of the
+// headers the validate keys sign, the validators vote on rule changes by the
+// blocks they sign.
+//
+// This is part of the thresholdConditionChecker interface implementation.
+func (c deploymentChecker) Condition(node *blockNode) bool {
+	conditionMask := uint32(1) << c.deployment.BitNumber
+	return node.version&vbTopMask == vbTopBits &&
+		node.version&conditionMask != 0
+}
+
+// calcNextBlockVersion calculates the expected version of the block after the
+// passed previous block node based on the state of the passed rule change
+// deployments, which are signaled while they are started or locked in.
+// Deployments which are not passed are never signaled.
+//
+// This function differs from the exported CalcNextBlockVersion in that the
+// exported version uses the current best chain as the previous block node
+// while this function accepts any block node.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) calcNextBlockVersion(prevNode *blockNode, signaled []int) (uint32, error) {
+	// Set the appropriate bits for each signaled rule deployment that is
+	// either in the process of being voted on, or locked in for the
+	// activation at the next threshold window change.
+	expectedVersion := uint32(vbTopBits)
+	for _, id := range signaled {
+		if id < 0 || id >= len(b.chainParams.Deployments) {
+			return 0, DeploymentError(id)
+		}
+		deployment := &b.chainParams.Deployments[id]
+		cache := &b.deploymentCaches[id]
+		checker := deploymentChecker{deployment: deployment, chain: b}
+		state, err := b.thresholdState(prevNode, checker, cache)
+		if err != nil {
+			return 0, err
+		}
+		if state == ThresholdStarted || state == ThresholdLockedIn {
+			expectedVersion |= uint32(1) << deployment.BitNumber
+		}
+	}
+	return expectedVersion, nil
+}
+
+// CalcNextBlockVersion calculates the expected version of the block after the
+// end of the current best chain which signals the passed rule change
+// deployments while they are started or locked in.  Validators only signal the
+// deployments their operators opted into.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CalcNextBlockVersion(signaled []int) (uint32, error) {
+	b.chainLock.Lock()
+	version, err := b.calcNextBlockVersion(b.bestNode, signaled)
+	b.chainLock.Unlock()
+	return version, err
+}
+
+// deploymentState returns the current rule change threshold for a given
+// deployment ID for the block AFTER the provided node.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) deploymentState(prevNode *blockNode, deploymentID int) (ThresholdState, error) {
+	if deploymentID < 0 || deploymentID >= len(b.chainParams.Deployments) {
+		return ThresholdFailed, DeploymentError(deploymentID)
+	}
+
+	deployment := &b.chainParams.Deployments[deploymentID]
+	checker := deploymentChecker{deployment: deployment, chain: b}
+	cache := &b.deploymentCaches[deploymentID]
+
+	return b.thresholdState(prevNode, checker, cache)
+}
+
+// isDeploymentActive returns whether the rule change of the passed deployment
+// applies to the block AFTER the provided node, either because the height of
+// the block reached the activation height of the deployment or because the
+// validators signaled it.
+//
+// This function MUST be called with the chain state lock held (for writes).
+func (b *BlockChain) isDeploymentActive(prevNode *blockNode, deploymentID int) (bool, error) {
+	var height uint32
+	if prevNode != nil {
+		height = prevNode.height + 1
+	}
+	if deploymentID >= 0 && deploymentID < len(b.chainParams.Deployments) &&
+		b.chainParams.IsDeploymentActive(deploymentID, height) {
+
+		return true, nil
+	}
+
+	state, err := b.deploymentState(prevNode, deploymentID)
+	if err != nil {
+		return false, err
+	}
+	return state == ThresholdActive, nil
+}
+
+// ThresholdState returns the current rule change threshold state of the given
+// deployment ID for the block AFTER the end of the current best chain.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) ThresholdState(deploymentID int) (ThresholdState, error) {
+	b.chainLock.Lock()
+	state, err := b.deploymentState(b.bestNode, deploymentID)
+	b.chainLock.Unlock()
+
+	return state, err
+}
+
+// IsDeploymentActive returns true if the target deploymentID is active, and
+// false otherwise, for the block AFTER the end of the current best chain.  A
+// deployment is active once its activation height is reached or once it was
+// signaled by the validators.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) IsDeploymentActive(deploymentID int) (bool, error) {
+	b.chainLock.Lock()
+	active, err := b.isDeploymentActive(b.bestNode, deploymentID)
+	b.chainLock.Unlock()
+
+	return active, err
+}
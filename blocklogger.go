@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -62,9 +63,18 @@ func (b *blockProgressLogger) LogBlockHeight(block *provautil.Block) {
 	if b.receivedLogTx == 1 {
 		txStr = "transaction"
 	}
-	b.subsystemLogger.Infof("%s %d %s in the last %s (%d %s, height %d, %s)",
+	timestamp := block.MsgBlock().Header.Timestamp
+	msg := fmt.Sprintf("%s %d %s in the last %s (%d %s, height %d, %s)",
 		b.progressAction, b.receivedLogBlocks, blockStr, tDuration, b.receivedLogTx,
-		txStr, block.Height(), block.MsgBlock().Header.Timestamp)
+		txStr, block.Height(), timestamp)
+	b.subsystemLogger.Info(newLogMessage(msg, logFields{
+		"action":       b.progressAction,
+		"blocks":       b.receivedLogBlocks,
+		"transactions": b.receivedLogTx,
+		"duration":     tDuration.String(),
+		"height":       block.Height(),
+		"timestamp":    timestamp.Unix(),
+	}))
 
 	b.receivedLogBlocks = 0
 	b.receivedLogTx = 0
@@ -7,12 +7,13 @@ package main
 
 import (
 	"container/list"
+	"fmt"
 	"net"
 	"os"
 	"path/filepath"
-	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/chaincfg"
@@ -40,11 +41,28 @@ const (
 	// maxRequestedTxns is the maximum number of requested transactions
 	// hashes to store in memory.
 	maxRequestedTxns = wire.MaxInvPerMsg
+
+	// maxInFlightBlocksPerPeer is the maximum number of blocks requested
+	// from a single peer at once while fetching the blocks of the
+	// downloaded headers in headers-first mode.
+	maxInFlightBlocksPerPeer = 128
+
+	// blockDownloadWindow is the maximum number of blocks ahead of the next
+	// block to process which are requested or held at once in headers-first
+	// mode.  It bounds the memory used by blocks which arrive out of order.
+	blockDownloadWindow = 1024
 )
 
 // zeroHash is the zero value hash (all zeros).  It is defined as a convenience.
 var zeroHash chainhash.Hash
 
+// headerNode is used as a node in a list of headers that are linked together
+// up to the latest checkpoint.
+type headerNode struct {
+	height uint32
+	hash   *chainhash.Hash
+}
+
 // newPeerMsg signifies a newly connected peer to the block handler.
 type newPeerMsg struct {
 	peer *serverPeer
@@ -64,6 +82,36 @@ type invMsg struct {
 	peer *serverPeer
 }
 
+// headersMsg packages a bitcoin headers message and the peer it came from
+// together so the block handler has access to that information.
+type headersMsg struct {
+	headers *wire.MsgHeaders
+	peer    *serverPeer
+}
+
+// cmpctBlockMsg packages a cmpctblock message and the peer it came from
+// together so the block handler has access to that information.
+type cmpctBlockMsg struct {
+	cmpctBlock *wire.MsgCmpctBlock
+	peer       *serverPeer
+}
+
+// blockTxnMsg packages a blocktxn message and the peer it came from together
+// so the block handler has access to that information.
+type blockTxnMsg struct {
+	blockTxn *wire.MsgBlockTxn
+	peer     *serverPeer
+}
+
+// partialBlock houses a block announced with a cmpctblock message which is
+// waiting for the transactions that could not be found in the memory pool to
+// be delivered by the peer in response to a getblocktxn message.
+type partialBlock struct {
+	peer    *serverPeer
+	block   *wire.MsgBlock
+	missing []uint32
+}
+
 // donePeerMsg signifies a newly disconnected peer to the block handler.
 type donePeerMsg struct {
 	peer *serverPeer
@@ -107,6 +155,12 @@ type isCurrentMsg struct {
 	reply chan bool
 }
 
+// headersTipMsg is a message type to be sent across the message channel for
+// retrieving the last of the headers which were synced ahead of their blocks.
+type headersTipMsg struct {
+	reply chan *headerNode
+}
+
 // pauseMsg is a message type to be sent across the message channel for
 // pausing the block manager.  This effectively provides the caller with
 // exclusive access over the manager until a receive is performed on the
@@ -115,6 +169,12 @@ type pauseMsg struct {
 	unpause <-chan struct{}
 }
 
+// repairBlocksMsg is a message type to be sent across the message channel for
+// re-downloading the blocks whose stored data was found to be corrupt.
+type repairBlocksMsg struct {
+	hashes []chainhash.Hash
+}
+
 // blockManager provides a concurrency safe block manager for handling all
 // incoming blocks.
 type blockManager struct {
@@ -130,6 +190,68 @@ type blockManager struct {
 	msgChan         chan interface{}
 	wg              sync.WaitGroup
 	quit            chan struct{}
+
+	// The following fields are used for headers-first mode.  The headers
+	// up to the latest checkpoint are downloaded from the sync peer while
+	// nextCheckpoint is set, after which the blocks of the headers are
+	// fetched from all of the sync candidates in parallel.  The blocks
+	// which arrive before their parent are held until it is processed.
+	headersFirstMode bool
+	headerList       *list.List
+	nextCheckpoint   *chaincfg.Checkpoint
+	heldBlocks       map[chainhash.Hash]*blockMsg
+
+	// partialBlocks houses the blocks announced with cmpctblock messages
+	// which are waiting for their missing transactions.
+	partialBlocks map[chainhash.Hash]*partialBlock
+
+	// repairBlocks houses the blocks whose stored data was found to be
+	// corrupt and which are downloaded from the sync candidates again to
+	// replace it.
+	repairBlocks map[chainhash.Hash]struct{}
+}
+
+// resetHeaderState sets the headers-first mode state to values appropriate for
+// syncing from a new peer.
+func (b *blockManager) resetHeaderState(newestHash *chainhash.Hash, newestHeight uint32) {
+	b.headersFirstMode = false
+	b.headerList.Init()
+	b.heldBlocks = make(map[chainhash.Hash]*blockMsg)
+	b.nextCheckpoint = b.findNextHeaderCheckpoint(newestHeight)
+
+	// Add an entry for the latest known block into the header pool.
+	// This allows the next downloaded header to prove it links to the chain
+	// properly.
+	node := headerNode{height: newestHeight, hash: newestHash}
+	b.headerList.PushBack(&node)
+}
+
+// findNextHeaderCheckpoint returns the next checkpoint after the passed height.
+// It returns nil when there is not one either because the height is already
+// later than the final checkpoint or some other reason such as disabled
+// checkpoints.
+func (b *blockManager) findNextHeaderCheckpoint(height uint32) *chaincfg.Checkpoint {
+	checkpoints := b.chain.Checkpoints()
+	if len(checkpoints) == 0 || cfg.DisableCheckpoints {
+		return nil
+	}
+
+	// There is no next checkpoint if the height is already after the final
+	// checkpoint.
+	finalCheckpoint := &checkpoints[len(checkpoints)-1]
+	if height >= finalCheckpoint.Height {
+		return nil
+	}
+
+	// Find the next checkpoint.
+	nextCheckpoint := finalCheckpoint
+	for i := len(checkpoints) - 2; i >= 0; i-- {
+		if height >= checkpoints[i].Height {
+			break
+		}
+		nextCheckpoint = &checkpoints[i]
+	}
+	return nextCheckpoint
 }
 
 // startSync will choose the best peer among the available candidate peers to
@@ -171,6 +293,7 @@ func (b *blockManager) startSync(peers *list.List) {
 		// we may ignore blocks we need that the last sync peer failed
 		// to send.
 		b.requestedBlocks = make(map[chainhash.Hash]struct{})
+		b.resetHeaderState(best.Hash, best.Height)
 
 		locator, err := b.chain.LatestBlockLocator()
 		if err != nil {
@@ -181,7 +304,21 @@ func (b *blockManager) startSync(peers *list.List) {
 
 		bmgrLog.Infof("Syncing to block height %d from peer %v",
 			bestPeer.LastBlock(), bestPeer.Addr())
-		bestPeer.PushGetBlocksMsg(locator, &zeroHash)
+
+		// When the current height is less than a known checkpoint,
+		// download the headers up to the latest checkpoint before
+		// fetching their blocks.  The regression test tool does not
+		// serve headers, so headers-first mode is not used for it.
+		if b.nextCheckpoint != nil && !cfg.RegressionTest {
+			latest := b.chain.LatestCheckpoint()
+			bestPeer.PushGetHeadersMsg(locator, latest.Hash)
+			b.headersFirstMode = true
+			bmgrLog.Infof("Downloading headers for blocks %d to %d "+
+				"from peer %s", best.Height+1, latest.Height,
+				bestPeer.Addr())
+		} else {
+			bestPeer.PushGetBlocksMsg(locator, &zeroHash)
+		}
 		b.syncPeer = bestPeer
 	} else {
 		bmgrLog.Warnf("No sync peer candidates available")
@@ -237,6 +374,14 @@ func (b *blockManager) handleNewPeerMsg(peers *list.List, sp *serverPeer) {
 
 	// Start syncing by choosing the best candidate if needed.
 	b.startSync(peers)
+
+	// Let the peer help fetch the blocks of the downloaded headers.
+	if b.headersFirstMode && b.nextCheckpoint == nil {
+		b.fetchHeaderBlocks(peers)
+	}
+
+	// Request the blocks to repair which could not be requested yet.
+	b.requestRepairBlocks(peers)
 }
 
 // handleDonePeerMsg deals with peers that have signalled they are done.  It
@@ -268,12 +413,32 @@ func (b *blockManager) handleDonePeerMsg(peers *list.List, sp *serverPeer) {
 		delete(b.requestedBlocks, k)
 	}
 
+	// Forget the compact blocks which are waiting for transactions from
+	// the peer.  Their blocks were requested from it, so they will be
+	// fetched from elsewhere next time we get an inv as well.
+	for hash, partial := range b.partialBlocks {
+		if partial.peer == sp {
+			delete(b.partialBlocks, hash)
+		}
+	}
+
 	// Attempt to find a new peer to sync from if the quitting peer is the
-	// sync peer.
+	// sync peer.  Otherwise, request the blocks of the downloaded headers
+	// the peer did not deliver from the remaining peers.
 	if b.syncPeer != nil && b.syncPeer == sp {
 		b.syncPeer = nil
+		if b.headersFirstMode {
+			best := b.chain.BestSnapshot()
+			b.resetHeaderState(best.Hash, best.Height)
+		}
 		b.startSync(peers)
+	} else if b.headersFirstMode && b.nextCheckpoint == nil {
+		b.fetchHeaderBlocks(peers)
 	}
+
+	// Request the blocks to repair the peer did not deliver from the
+	// remaining peers.
+	b.requestRepairBlocks(peers)
 }
 
 // handleTxMsg handles transaction messages from all peers.
@@ -361,7 +526,7 @@ func (b *blockManager) current() bool {
 }
 
 // handleBlockMsg handles block messages from all peers.
-func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
+func (b *blockManager) handleBlockMsg(peers *list.List, bmsg *blockMsg) {
 	// If we didn't ask for this block then the peer is misbehaving.
 	blockHash := bmsg.block.Hash()
 	if _, exists := bmsg.peer.requestedBlocks[*blockHash]; !exists {
@@ -378,17 +543,330 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 		}
 	}
 
-	behaviorFlags := blockchain.BFNone
-
 	// Remove block from request maps. Either chain will know about it and
 	// so we shouldn't have any more instances of trying to fetch it, or we
 	// will fail the insert and thus we'll retry next time we get an inv.
 	delete(bmsg.peer.requestedBlocks, *blockHash)
 	delete(b.requestedBlocks, *blockHash)
 
+	// Blocks which were requested to replace their corrupt stored data
+	// are already part of the chain, so they are not processed.
+	if _, ok := b.repairBlocks[*blockHash]; ok {
+		b.repairBlock(peers, bmsg)
+		return
+	}
+
+	// Nothing more to do besides processing the block when not fetching
+	// the blocks of the downloaded headers.
+	if !b.headersFirstMode || b.nextCheckpoint != nil {
+		b.processBlockMsg(bmsg)
+		return
+	}
+
+	// The blocks of the downloaded headers are fetched from several peers
+	// in parallel, so they are able to arrive out of order.  Hold the
+	// blocks which arrive before their parent until it has been processed.
+	// Only blocks of the downloaded headers are requested, so a block after
+	// the next one to process is one of them.
+	front := b.headerList.Front()
+	if front != nil {
+		node := front.Value.(*headerNode)
+		if !blockHash.IsEqual(node.hash) &&
+			bmsg.block.MsgBlock().Header.Height > node.height {
+
+			b.heldBlocks[*blockHash] = bmsg
+			b.fetchHeaderBlocks(peers)
+			return
+		}
+	}
+	b.processBlockMsg(bmsg)
+
+	// Process the held blocks which are now able to connect.
+	for b.headersFirstMode {
+		front := b.headerList.Front()
+		if front == nil {
+			break
+		}
+		hash := front.Value.(*headerNode).hash
+		held, ok := b.heldBlocks[*hash]
+		if !ok {
+			break
+		}
+		delete(b.heldBlocks, *hash)
+		b.processBlockMsg(held)
+	}
+
+	// Keep the peers busy with the remaining blocks.
+	if b.headersFirstMode {
+		b.fetchHeaderBlocks(peers)
+	}
+}
+
+// requestFullBlock requests the block with the passed hash from the passed
+// peer with a getdata message.  It is used when a block announced with a
+// cmpctblock message can not be reconstructed.
+func (b *blockManager) requestFullBlock(sp *serverPeer, hash *chainhash.Hash) {
+	b.requestedBlocks[*hash] = struct{}{}
+	sp.requestedBlocks[*hash] = struct{}{}
+
+	gdmsg := wire.NewMsgGetData()
+	gdmsg.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, hash))
+	sp.QueueMessage(gdmsg, nil)
+}
+
+// requestRepairBlocks requests the blocks to repair which are not requested
+// from any peer yet from the sync peer, or the first sync candidate when there
+// is no sync peer.
+func (b *blockManager) requestRepairBlocks(peers *list.List) {
+	if len(b.repairBlocks) == 0 {
+		return
+	}
+	sp := b.syncPeer
+	if sp == nil {
+		if peers.Len() == 0 {
+			return
+		}
+		sp = peers.Front().Value.(*serverPeer)
+	}
+	for hash := range b.repairBlocks {
+		if _, exists := b.requestedBlocks[hash]; exists {
+			continue
+		}
+		hash := hash
+		bmgrLog.Infof("Requesting block %v from %s to repair its "+
+			"stored data", hash, sp)
+		b.requestFullBlock(sp, &hash)
+	}
+}
+
+// handleRepairBlocksMsg handles requests to re-download the blocks whose
+// stored data was found to be corrupt.
+func (b *blockManager) handleRepairBlocksMsg(peers *list.List, msg repairBlocksMsg) {
+	for _, hash := range msg.hashes {
+		b.repairBlocks[hash] = struct{}{}
+	}
+	b.requestRepairBlocks(peers)
+}
+
+// repairBlock replaces the corrupt stored data of the block of the passed
+// block message, which was requested for the repair, with the block.  Blocks
+// which do not match the merkle root of their header are requested again from
+// the next peer.
+func (b *blockManager) repairBlock(peers *list.List, bmsg *blockMsg) {
+	block := bmsg.block
+	blockHash := block.Hash()
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
+	merkleRoot := &block.MsgBlock().Header.MerkleRoot
+	if !merkles[len(merkles)-1].IsEqual(merkleRoot) {
+		bmgrLog.Warnf("Block %v from %s does not match its merkle root "+
+			"-- disconnecting", blockHash, bmsg.peer)
+		bmsg.peer.Disconnect()
+		return
+	}
+
+	err := b.server.db.Update(func(dbTx database.Tx) error {
+		return dbTx.RepairBlock(block)
+	})
+	if err != nil {
+		bmgrLog.Errorf("Failed to repair block %v: %v", blockHash, err)
+		return
+	}
+	delete(b.repairBlocks, *blockHash)
+	bmgrLog.Infof("Repaired the stored data of block %v", blockHash)
+	if b.server.blockScrubber != nil {
+		b.server.blockScrubber.BlockRepaired(blockHash)
+	}
+}
+
+// handleCmpctBlockMsg handles cmpctblock messages from all peers.  It
+// reconstructs the announced block from the transactions in the memory pool
+// and either processes it or requests the transactions which could not be
+// found from the peer with a getblocktxn message.
+func (b *blockManager) handleCmpctBlockMsg(peers *list.List, cmsg *cmpctBlockMsg) {
+	msg := cmsg.cmpctBlock
+	sp := cmsg.peer
+	blockHash := msg.Header.BlockHash()
+
+	// Compact blocks are only relayed between peers which are current, so
+	// ignore them while syncing.  The block will be fetched by the sync
+	// process instead.
+	if !b.current() || b.headersFirstMode {
+		bmgrLog.Debugf("Ignoring cmpctblock %v from %s while not "+
+			"current", blockHash, sp)
+		return
+	}
+
+	// Ignore the block when it is already known or being fetched.
+	if _, exists := b.requestedBlocks[blockHash]; exists {
+		return
+	}
+	iv := wire.NewInvVect(wire.InvTypeBlock, &blockHash)
+	haveInv, err := b.haveInventory(iv)
+	if err != nil {
+		bmgrLog.Warnf("Unexpected failure when checking for existing "+
+			"inventory during cmpctblock processing: %v", err)
+		return
+	}
+	if haveInv {
+		return
+	}
+
+	if msg.TotalTxns() == 0 {
+		bmgrLog.Debugf("Peer %s sent cmpctblock %v without "+
+			"transactions -- disconnecting", sp, blockHash)
+		sp.disconnectWithReason("invalid cmpctblock")
+		return
+	}
+
+	// Place the prefilled transactions in the block.
+	numTxns := msg.TotalTxns()
+	txns := make([]*wire.MsgTx, numTxns)
+	for _, prefilled := range msg.PrefilledTxns {
+		txns[prefilled.Index] = prefilled.Tx
+	}
+
+	// The short ids of the block must be unique for the transactions to be
+	// matched to them, so fall back to requesting the full block when they
+	// are not.
+	shortIDs := make(map[uint64]struct{}, len(msg.ShortIDs))
+	for _, shortID := range msg.ShortIDs {
+		if _, exists := shortIDs[shortID]; exists {
+			bmgrLog.Debugf("Duplicate short ids in cmpctblock %v "+
+				"from %s -- requesting full block", blockHash, sp)
+			b.requestFullBlock(sp, &blockHash)
+			return
+		}
+		shortIDs[shortID] = struct{}{}
+	}
+
+	// Find the transactions of the short ids in the memory pool.  Short ids
+	// which are shared by several transactions in the pool are ambiguous,
+	// so their transactions are treated as missing.
+	k0, k1 := msg.SipHashKeys()
+	poolTxns := make(map[uint64]*wire.MsgTx)
+	for _, txDesc := range b.server.txMemPool.TxDescs() {
+		shortID := wire.CmpctBlockShortID(k0, k1, txDesc.Tx.HashWithSig())
+		if _, exists := shortIDs[shortID]; !exists {
+			continue
+		}
+		if _, exists := poolTxns[shortID]; exists {
+			poolTxns[shortID] = nil
+			continue
+		}
+		poolTxns[shortID] = txDesc.Tx.MsgTx()
+	}
+
+	var missing []uint32
+	shortIdx := 0
+	for i := range txns {
+		if txns[i] != nil {
+			continue
+		}
+		txns[i] = poolTxns[msg.ShortIDs[shortIdx]]
+		shortIdx++
+		if txns[i] == nil {
+			missing = append(missing, uint32(i))
+		}
+	}
+
+	block := wire.NewMsgBlock(&msg.Header)
+	block.Transactions = txns
+	if len(missing) == 0 {
+		b.finishCmpctBlock(peers, sp, block)
+		return
+	}
+
+	// Request the missing transactions from the peer.
+	bmgrLog.Debugf("Requesting %d of %d transactions of cmpctblock %v "+
+		"from %s", len(missing), numTxns, blockHash, sp)
+	b.requestedBlocks[blockHash] = struct{}{}
+	sp.requestedBlocks[blockHash] = struct{}{}
+	b.partialBlocks[blockHash] = &partialBlock{
+		peer:    sp,
+		block:   block,
+		missing: missing,
+	}
+	getBlockTxn := wire.NewMsgGetBlockTxn(&blockHash)
+	getBlockTxn.Indexes = missing
+	sp.QueueMessage(getBlockTxn, nil)
+}
+
+// handleBlockTxnMsg handles blocktxn messages from all peers.  It completes the
+// block waiting for the delivered transactions and processes it.
+func (b *blockManager) handleBlockTxnMsg(peers *list.List, bmsg *blockTxnMsg) {
+	msg := bmsg.blockTxn
+	sp := bmsg.peer
+	partial, exists := b.partialBlocks[msg.BlockHash]
+	if !exists || partial.peer != sp {
+		bmgrLog.Debugf("Ignoring unrequested blocktxn %v from %s",
+			msg.BlockHash, sp)
+		return
+	}
+	delete(b.partialBlocks, msg.BlockHash)
+
+	if len(msg.Transactions) != len(partial.missing) {
+		bmgrLog.Debugf("Peer %s sent %d transactions for block %v "+
+			"instead of %d -- requesting full block", sp,
+			len(msg.Transactions), msg.BlockHash,
+			len(partial.missing))
+		b.requestFullBlock(sp, &msg.BlockHash)
+		return
+	}
+	for i, index := range partial.missing {
+		partial.block.Transactions[index] = msg.Transactions[i]
+	}
+	b.finishCmpctBlock(peers, sp, partial.block)
+}
+
+// finishCmpctBlock processes a block reconstructed from a cmpctblock message
+// after ensuring the transactions match the merkle root of its header.  The
+// full block is requested from the peer instead when they do not, since a
+// short id may have matched the wrong transaction.
+func (b *blockManager) finishCmpctBlock(peers *list.List, sp *serverPeer, msgBlock *wire.MsgBlock) {
+	block := provautil.NewBlock(msgBlock)
+	blockHash := block.Hash()
+	merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
+	merkleRoot := merkles[len(merkles)-1]
+	if !msgBlock.Header.MerkleRoot.IsEqual(merkleRoot) {
+		bmgrLog.Debugf("Reconstructed cmpctblock %v from %s does not "+
+			"match its merkle root -- requesting full block",
+			blockHash, sp)
+		b.requestFullBlock(sp, blockHash)
+		return
+	}
+
+	// Mark the block as requested from the peer since it was announced
+	// directly and process it like a block message.
+	b.requestedBlocks[*blockHash] = struct{}{}
+	sp.requestedBlocks[*blockHash] = struct{}{}
+	b.handleBlockMsg(peers, &blockMsg{block: block, peer: sp})
+}
+
+// processBlockMsg processes the block of the passed block message, which has
+// been requested, and updates the sync state accordingly.  When the block is
+// the next one of the downloaded headers, it is processed with less validation
+// since the headers have already been verified to link together up to the
+// latest checkpoint.
+func (b *blockManager) processBlockMsg(bmsg *blockMsg) {
+	blockHash := bmsg.block.Hash()
+	behaviorFlags := blockchain.BFNone
+	var headerEl *list.Element
+	if b.headersFirstMode && b.nextCheckpoint == nil {
+		front := b.headerList.Front()
+		if front != nil && blockHash.IsEqual(front.Value.(*headerNode).hash) {
+			behaviorFlags |= blockchain.BFFastAdd
+			headerEl = front
+		}
+	}
+
 	// Process the block to include validation, best chain selection, orphan
 	// handling, etc.
+	processStart := time.Now()
 	_, isOrphan, err := b.chain.ProcessBlock(bmsg.block, behaviorFlags)
+	if b.server.autoProfiler != nil {
+		b.server.autoProfiler.BlockProcessed("block "+blockHash.String(),
+			time.Since(processStart))
+	}
 	if err != nil {
 		// When the error is a rule error, it means the block was simply
 		// rejected as opposed to something actually going wrong, so log
@@ -450,6 +928,10 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 		// update the chain state.
 		b.progressLogger.LogBlockHeight(bmsg.block)
 
+		// Record the peer provided a new block, which protects it from
+		// eviction.
+		bmsg.peer.recordNewBlock()
+
 		// Update this peer's latest block height, for future
 		// potential sync node candidacy.
 		best := b.chain.BestSnapshot()
@@ -478,6 +960,198 @@ func (b *blockManager) handleBlockMsg(bmsg *blockMsg) {
 			go b.server.UpdatePeerHeights(blkHashUpdate, heightUpdate, bmsg.peer)
 		}
 	}
+
+	// Nothing more to do unless the block is the next one of the downloaded
+	// headers.
+	if headerEl == nil {
+		return
+	}
+	b.headerList.Remove(headerEl)
+
+	// When the block is the final one of the downloaded headers, which is
+	// the latest checkpoint, switch to normal mode by requesting blocks
+	// from the block after this one up to the end of the chain (zero hash).
+	if b.headerList.Len() > 0 {
+		return
+	}
+	b.resetHeaderState(blockHash, bmsg.block.MsgBlock().Header.Height)
+	bmgrLog.Infof("Reached the final checkpoint -- switching to normal mode")
+	locator := blockchain.BlockLocator([]*chainhash.Hash{blockHash})
+	err = b.syncPeer.PushGetBlocksMsg(locator, &zeroHash)
+	if err != nil {
+		bmgrLog.Warnf("Failed to send getblocks message to peer %s: %v",
+			b.syncPeer.Addr(), err)
+	}
+}
+
+// fetchHeaderBlocks requests the blocks of the downloaded headers which are
+// neither requested nor held yet.  The requests are spread over the sync
+// candidates which have the blocks, limited to maxInFlightBlocksPerPeer blocks
+// per peer and to blockDownloadWindow blocks ahead of the next one to process.
+func (b *blockManager) fetchHeaderBlocks(peers *list.List) {
+	fetchPeers := make([]*serverPeer, 0, peers.Len())
+	for e := peers.Front(); e != nil; e = e.Next() {
+		fetchPeers = append(fetchPeers, e.Value.(*serverPeer))
+	}
+	if len(fetchPeers) == 0 {
+		return
+	}
+
+	getDataMsgs := make(map[*serverPeer]*wire.MsgGetData)
+	nextPeer := 0
+	numInWindow := 0
+	for e := b.headerList.Front(); e != nil; e = e.Next() {
+		if numInWindow >= blockDownloadWindow {
+			break
+		}
+		numInWindow++
+
+		node := e.Value.(*headerNode)
+		if _, exists := b.requestedBlocks[*node.hash]; exists {
+			continue
+		}
+		if _, exists := b.heldBlocks[*node.hash]; exists {
+			continue
+		}
+
+		// Request the block from the next peer in turn which has it
+		// and room for another request.  Stop when there is none
+		// since the later blocks will not find one either.
+		var sp *serverPeer
+		for i := 0; i < len(fetchPeers); i++ {
+			candidate := fetchPeers[(nextPeer+i)%len(fetchPeers)]
+			if len(candidate.requestedBlocks) < maxInFlightBlocksPerPeer &&
+				candidate.LastBlock() >= node.height {
+
+				sp = candidate
+				nextPeer = (nextPeer + i + 1) % len(fetchPeers)
+				break
+			}
+		}
+		if sp == nil {
+			break
+		}
+
+		b.requestedBlocks[*node.hash] = struct{}{}
+		sp.requestedBlocks[*node.hash] = struct{}{}
+		gdmsg, ok := getDataMsgs[sp]
+		if !ok {
+			gdmsg = wire.NewMsgGetData()
+			getDataMsgs[sp] = gdmsg
+		}
+		gdmsg.AddInvVect(wire.NewInvVect(wire.InvTypeBlock, node.hash))
+	}
+	for sp, gdmsg := range getDataMsgs {
+		sp.QueueMessage(gdmsg, nil)
+	}
+}
+
+// handleHeadersMsg handles block header messages from all peers.  Headers are
+// requested when performing a headers-first sync.
+func (b *blockManager) handleHeadersMsg(peers *list.List, hmsg *headersMsg) {
+	// The remote peer is misbehaving if we didn't request headers.
+	msg := hmsg.headers
+	numHeaders := len(msg.Headers)
+	if !b.headersFirstMode || b.nextCheckpoint == nil ||
+		hmsg.peer != b.syncPeer {
+
+		bmgrLog.Warnf("Got %d unrequested headers from %s -- "+
+			"disconnecting", numHeaders, hmsg.peer.Addr())
+		hmsg.peer.Disconnect()
+		return
+	}
+
+	// Nothing to do for an empty headers message.
+	if numHeaders == 0 {
+		return
+	}
+
+	// Process all of the received headers ensuring each one is valid,
+	// connects to the previous one, and that checkpoints match.
+	var finalHash *chainhash.Hash
+	for _, blockHeader := range msg.Headers {
+		blockHash := blockHeader.BlockHash()
+		finalHash = &blockHash
+
+		// Ensure there is a previous header to compare against.
+		prevNodeEl := b.headerList.Back()
+		if prevNodeEl == nil {
+			bmgrLog.Warnf("Header list does not contain a previous " +
+				"element as expected -- disconnecting peer")
+			hmsg.peer.Disconnect()
+			return
+		}
+
+		// Ensure the header properly connects to the previous one, is
+		// at the following height, and has a valid proof of work.
+		prevNode := prevNodeEl.Value.(*headerNode)
+		if !prevNode.hash.IsEqual(&blockHeader.PrevBlock) ||
+			blockHeader.Height != prevNode.height+1 {
+
+			bmgrLog.Warnf("Received block header that does not "+
+				"properly connect to the chain from peer %s "+
+				"-- disconnecting", hmsg.peer.Addr())
+			hmsg.peer.Disconnect()
+			return
+		}
+		err := blockchain.CheckHeaderProofOfWork(blockHeader,
+			b.server.chainParams.PowLimit)
+		if err != nil {
+			bmgrLog.Warnf("Received invalid block header %s from "+
+				"peer %s: %v -- disconnecting", blockHash,
+				hmsg.peer.Addr(), err)
+			hmsg.peer.Disconnect()
+			return
+		}
+		node := headerNode{height: blockHeader.Height, hash: &blockHash}
+		b.headerList.PushBack(&node)
+
+		// Verify the header at the next checkpoint height matches.
+		if node.height != b.nextCheckpoint.Height {
+			continue
+		}
+		if !node.hash.IsEqual(b.nextCheckpoint.Hash) {
+			bmgrLog.Warnf("Block header at height %d/hash %s from "+
+				"peer %s does NOT match expected checkpoint "+
+				"hash of %s -- disconnecting", node.height,
+				node.hash, hmsg.peer.Addr(),
+				b.nextCheckpoint.Hash)
+			hmsg.peer.Disconnect()
+			return
+		}
+		bmgrLog.Infof("Verified downloaded block header against "+
+			"checkpoint at height %d/hash %s", node.height,
+			node.hash)
+		b.nextCheckpoint = b.findNextHeaderCheckpoint(node.height)
+		if b.nextCheckpoint == nil {
+			break
+		}
+	}
+
+	// When the final header is the latest checkpoint, switch to fetching
+	// the blocks for all of the headers.
+	if b.nextCheckpoint == nil {
+		// Since the first entry of the list is always the final block
+		// that is already in the database and is only used to ensure
+		// the next header links properly, it must be removed before
+		// fetching the blocks.
+		b.headerList.Remove(b.headerList.Front())
+		bmgrLog.Infof("Received %v block headers: Fetching blocks from "+
+			"%d peers", b.headerList.Len(), peers.Len())
+		b.fetchHeaderBlocks(peers)
+		return
+	}
+
+	// The latest checkpoint has not been reached yet, so request the next
+	// batch of headers starting from the latest known header and ending
+	// with the latest checkpoint.
+	locator := blockchain.BlockLocator([]*chainhash.Hash{finalHash})
+	err := hmsg.peer.PushGetHeadersMsg(locator,
+		b.chain.LatestCheckpoint().Hash)
+	if err != nil {
+		bmgrLog.Warnf("Failed to send getheaders message to peer %s: %v",
+			hmsg.peer.Addr(), err)
+	}
 }
 
 // haveInventory returns whether or not the inventory represented by the passed
@@ -565,6 +1239,13 @@ func (b *blockManager) handleInvMsg(imsg *invMsg) {
 		// for the peer.
 		imsg.peer.AddKnownInventory(iv)
 
+		// Blocks are fetched using the downloaded headers in
+		// headers-first mode, so ignore block announcements until it
+		// ends.
+		if iv.Type == wire.InvTypeBlock && b.headersFirstMode {
+			continue
+		}
+
 		// Request the inventory if we don't already have it.
 		haveInv, err := b.haveInventory(iv)
 		if err != nil {
@@ -711,7 +1392,18 @@ out:
 				msg.peer.txProcessed <- struct{}{}
 
 			case *blockMsg:
-				b.handleBlockMsg(msg)
+				b.handleBlockMsg(candidatePeers, msg)
+				msg.peer.blockProcessed <- struct{}{}
+
+			case *headersMsg:
+				b.handleHeadersMsg(candidatePeers, msg)
+
+			case *cmpctBlockMsg:
+				b.handleCmpctBlockMsg(candidatePeers, msg)
+				msg.peer.blockProcessed <- struct{}{}
+
+			case *blockTxnMsg:
+				b.handleBlockTxnMsg(candidatePeers, msg)
 				msg.peer.blockProcessed <- struct{}{}
 
 			case *invMsg:
@@ -720,6 +1412,9 @@ out:
 			case *donePeerMsg:
 				b.handleDonePeerMsg(candidatePeers, msg.peer)
 
+			case repairBlocksMsg:
+				b.handleRepairBlocksMsg(candidatePeers, msg)
+
 			case getSyncPeerMsg:
 				msg.reply <- b.syncPeer
 
@@ -749,6 +1444,9 @@ out:
 			case isCurrentMsg:
 				msg.reply <- b.current()
 
+			case headersTipMsg:
+				msg.reply <- b.headersTip()
+
 			case pauseMsg:
 				// Wait until the sender unpauses the manager.
 				<-msg.unpause
@@ -788,7 +1486,7 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 
 		// Generate the inventory vector and relay it.
 		iv := wire.NewInvVect(wire.InvTypeBlock, block.Hash())
-		b.server.RelayInventory(iv, block.MsgBlock().Header)
+		b.server.RelayInventory(iv, block)
 
 	// A block has been connected to the main block chain.
 	case blockchain.NTBlockConnected:
@@ -813,6 +1511,20 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 			b.server.AnnounceNewTransactions(acceptedTxs)
 		}
 
+		// Record how long it took for the transactions in the block to
+		// be mined in order to estimate fees.
+		b.server.feeEstimator.RegisterBlock(block)
+
+		// Notify ZeroMQ subscribers of the block and its transactions.
+		if n := b.server.zmqNotifier; n != nil {
+			n.BlockConnected(block)
+		}
+
+		// Notify gRPC subscribers of the block.
+		if g := b.server.grpcServer; g != nil {
+			g.BlockConnected(block)
+		}
+
 		if r := b.server.rpcServer; r != nil {
 			// Now that this block is in the blockchain we can mark
 			// all the transactions (except the coinbase) as no
@@ -834,6 +1546,10 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 			break
 		}
 
+		// Forget how long it took for the transactions in the block to
+		// be mined since they are waiting to be mined again.
+		b.server.feeEstimator.Rollback(block)
+
 		// Reinsert all of the transactions (except the coinbase) into
 		// the transaction pool.
 		for _, tx := range block.Transactions()[1:] {
@@ -847,6 +1563,11 @@ func (b *blockManager) handleNotifyMsg(notification *blockchain.Notification) {
 			}
 		}
 
+		// Notify gRPC subscribers of the block.
+		if g := b.server.grpcServer; g != nil {
+			g.BlockDisconnected(block)
+		}
+
 		// Notify registered websocket clients.
 		if r := b.server.rpcServer; r != nil {
 			r.ntfnMgr.NotifyBlockDisconnected(block)
@@ -886,6 +1607,30 @@ func (b *blockManager) QueueBlock(block *provautil.Block, sp *serverPeer) {
 	b.msgChan <- &blockMsg{block: block, peer: sp}
 }
 
+// QueueCmpctBlock adds the passed cmpctblock message and peer to the block
+// handling queue.
+func (b *blockManager) QueueCmpctBlock(cmpctBlock *wire.MsgCmpctBlock, sp *serverPeer) {
+	// Don't accept more blocks if we're shutting down.
+	if atomic.LoadInt32(&b.shutdown) != 0 {
+		sp.blockProcessed <- struct{}{}
+		return
+	}
+
+	b.msgChan <- &cmpctBlockMsg{cmpctBlock: cmpctBlock, peer: sp}
+}
+
+// QueueBlockTxn adds the passed blocktxn message and peer to the block handling
+// queue.
+func (b *blockManager) QueueBlockTxn(blockTxn *wire.MsgBlockTxn, sp *serverPeer) {
+	// Don't accept more blocks if we're shutting down.
+	if atomic.LoadInt32(&b.shutdown) != 0 {
+		sp.blockProcessed <- struct{}{}
+		return
+	}
+
+	b.msgChan <- &blockTxnMsg{blockTxn: blockTxn, peer: sp}
+}
+
 // QueueInv adds the passed inv message and peer to the block handling queue.
 func (b *blockManager) QueueInv(inv *wire.MsgInv, sp *serverPeer) {
 	// No channel handling here because peers do not need to block on inv
@@ -897,6 +1642,18 @@ func (b *blockManager) QueueInv(inv *wire.MsgInv, sp *serverPeer) {
 	b.msgChan <- &invMsg{inv: inv, peer: sp}
 }
 
+// QueueHeaders adds the passed headers message and peer to the block handling
+// queue.
+func (b *blockManager) QueueHeaders(headers *wire.MsgHeaders, sp *serverPeer) {
+	// No channel handling here because peers do not need to block on
+	// headers messages.
+	if atomic.LoadInt32(&b.shutdown) != 0 {
+		return
+	}
+
+	b.msgChan <- &headersMsg{headers: headers, peer: sp}
+}
+
 // DonePeer informs the blockmanager that a peer has disconnected.
 func (b *blockManager) DonePeer(sp *serverPeer) {
 	// Ignore if we are shutting down.
@@ -959,67 +1716,59 @@ func (b *blockManager) IsCurrent() bool {
 	return <-reply
 }
 
-// Pause pauses the block manager until the returned channel is closed.
+// headersTip returns the last of the headers which were synced ahead of their
+// blocks, or nil when there are none.
 //
-// Note that while paused, all peer and block processing is halted.  The
-// message sender should avoid pausing the block manager for long durations.
-func (b *blockManager) Pause() chan<- struct{} {
-	c := make(chan struct{})
-	b.msgChan <- pauseMsg{c}
-	return c
-}
-
-// checkpointSorter implements sort.Interface to allow a slice of checkpoints to
-// be sorted.
-type checkpointSorter []chaincfg.Checkpoint
-
-// Len returns the number of checkpoints in the slice.  It is part of the
-// sort.Interface implementation.
-func (s checkpointSorter) Len() int {
-	return len(s)
+// This function MUST be called from the block handler goroutine.
+func (b *blockManager) headersTip() *headerNode {
+	if !b.headersFirstMode || b.headerList.Len() == 0 {
+		return nil
+	}
+	node := b.headerList.Back().Value.(*headerNode)
+	if node.height <= b.chain.BestSnapshot().Height {
+		return nil
+	}
+	return node
 }
 
-// Swap swaps the checkpoints at the passed indices.  It is part of the
-// sort.Interface implementation.
-func (s checkpointSorter) Swap(i, j int) {
-	s[i], s[j] = s[j], s[i]
+// HeadersTip returns the last of the headers which were synced ahead of their
+// blocks, or nil when there are none.
+func (b *blockManager) HeadersTip() *headerNode {
+	reply := make(chan *headerNode)
+	b.msgChan <- headersTipMsg{reply: reply}
+	return <-reply
 }
 
-// Less returns whether the checkpoint with index i should sort before the
-// checkpoint with index j.  It is part of the sort.Interface implementation.
-func (s checkpointSorter) Less(i, j int) bool {
-	return s[i].Height < s[j].Height
+// QueuedMsgs returns the number of messages queued to the block handler.  It
+// allows long-running background work, such as rescans, to yield while the
+// block manager is busy.
+//
+// This function is safe for concurrent access.
+func (b *blockManager) QueuedMsgs() int {
+	return len(b.msgChan)
 }
 
-// mergeCheckpoints returns two slices of checkpoints merged into one slice
-// such that the checkpoints are sorted by height.  In the case the additional
-// checkpoints contain a checkpoint with the same height as a checkpoint in the
-// default checkpoints, the additional checkpoint will take precedence and
-// overwrite the default one.
-func mergeCheckpoints(defaultCheckpoints, additional []chaincfg.Checkpoint) []chaincfg.Checkpoint {
-	// Create a map of the additional checkpoints to remove duplicates while
-	// leaving the most recently-specified checkpoint.
-	extra := make(map[uint32]chaincfg.Checkpoint)
-	for _, checkpoint := range additional {
-		extra[checkpoint.Height] = checkpoint
+// RepairBlocks requests the blocks with the passed hashes, whose stored data
+// was found to be corrupt, from the sync candidates to replace the data.
+func (b *blockManager) RepairBlocks(hashes []chainhash.Hash) {
+	// Ignore if we are shutting down.
+	if atomic.LoadInt32(&b.shutdown) != 0 {
+		return
 	}
-
-	// Add all default checkpoints that do not have an override in the
-	// additional checkpoints.
-	numDefault := len(defaultCheckpoints)
-	checkpoints := make([]chaincfg.Checkpoint, 0, numDefault+len(extra))
-	for _, checkpoint := range defaultCheckpoints {
-		if _, exists := extra[checkpoint.Height]; !exists {
-			checkpoints = append(checkpoints, checkpoint)
-		}
+	select {
+	case b.msgChan <- repairBlocksMsg{hashes: hashes}:
+	case <-b.quit:
 	}
+}
 
-	// Append the additional checkpoints and return the sorted results.
-	for _, checkpoint := range extra {
-		checkpoints = append(checkpoints, checkpoint)
-	}
-	sort.Sort(checkpointSorter(checkpoints))
-	return checkpoints
+// Pause pauses the block manager until the returned channel is closed.
+//
+// Note that while paused, all peer and block processing is halted.  The
+// message sender should avoid pausing the block manager for long durations.
+func (b *blockManager) Pause() chan<- struct{} {
+	c := make(chan struct{})
+	b.msgChan <- pauseMsg{c}
+	return c
 }
 
 // newBlockManager returns a new bitcoin block manager.
@@ -1031,32 +1780,100 @@ func newBlockManager(s *server, indexManager blockchain.IndexManager) (*blockMan
 		requestedTxns:   make(map[chainhash.Hash]struct{}),
 		requestedBlocks: make(map[chainhash.Hash]struct{}),
 		progressLogger:  newBlockProgressLogger("Processed", bmgrLog),
+		headerList:      list.New(),
+		heldBlocks:      make(map[chainhash.Hash]*blockMsg),
+		partialBlocks:   make(map[chainhash.Hash]*partialBlock),
+		repairBlocks:    make(map[chainhash.Hash]struct{}),
 		msgChan:         make(chan interface{}, cfg.MaxPeers*3),
 		quit:            make(chan struct{}),
 	}
 
 	// Merge given checkpoints with the default ones unless they are disabled.
 	var checkpoints []chaincfg.Checkpoint
-	checkpoints = mergeCheckpoints(s.chainParams.Checkpoints, cfg.addCheckpoints)
+	checkpoints = blockchain.MergeCheckpoints(s.chainParams.Checkpoints,
+		cfg.addCheckpoints)
+
+	// Reset the chain state so the chain rebuilds it from the stored
+	// blocks when requested.
+	if cfg.Reindex || cfg.ReindexChainState {
+		if err := blockchain.ResetChainState(s.db, cfg.Reindex); err != nil {
+			return nil, fmt.Errorf("unable to reset the chain state: "+
+				"%v", err)
+		}
+	}
 
 	// Create a new block chain instance with the appropriate configuration.
 	var err error
 	bm.chain, err = blockchain.New(&blockchain.Config{
-		DB:            s.db,
-		ChainParams:   s.chainParams,
-		Checkpoints:   checkpoints,
-		TimeSource:    s.timeSource,
-		Notifications: bm.handleNotifyMsg,
-		SigCache:      s.sigCache,
-		IndexManager:  indexManager,
+		DB:                 s.db,
+		ChainParams:        s.chainParams,
+		Checkpoints:        checkpoints,
+		CheckpointFile:     cfg.CheckpointFile,
+		CheckpointKeys:     cfg.checkpointKeys,
+		DisableCheckpoints: cfg.DisableCheckpoints,
+		TimeSource:         s.timeSource,
+		Notifications:      bm.handleNotifyMsg,
+		SigCache:           s.sigCache,
+		IndexManager:       indexManager,
+		PruneDepth:         cfg.Prune,
+		ArchiveDepth:       cfg.ColdBlockDepth,
+		ScriptWorkers:      cfg.ScriptWorkers,
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	// Bootstrap the chain from the utxo set snapshot, if any, unless it
+	// already houses blocks after the genesis block.
+	if cfg.UtxoSnapshot != "" {
+		if bm.chain.BestSnapshot().Height != 0 {
+			bmgrLog.Infof("Not importing utxo set snapshot %s since the "+
+				"block chain is not empty", cfg.UtxoSnapshot)
+		} else if err := importUtxoSnapshot(bm.chain, cfg.UtxoSnapshot,
+			cfg.utxoSnapshotHash); err != nil {
+			return nil, fmt.Errorf("unable to import utxo set "+
+				"snapshot %s: %v", cfg.UtxoSnapshot, err)
+		}
+	}
+
+	// Verify the most recent blocks before the chain is used, repairing
+	// the best chain state records when they diverge from the chain.
+	if cfg.CheckBlocks > 0 {
+		result, err := bm.chain.VerifyChain(
+			blockchain.VerifyLevel(cfg.CheckLevel), cfg.CheckBlocks,
+			true)
+		if err != nil {
+			return nil, fmt.Errorf("unable to verify the block "+
+				"chain: %v", err)
+		}
+		if result.BestStateRepaired {
+			bmgrLog.Warnf("Repaired the best chain state records")
+		}
+		if !result.Verified() {
+			return nil, fmt.Errorf("block chain verification "+
+				"failed: %s -- the chain must be rebuilt",
+				result.Inconsistencies[0].String())
+		}
+	}
+
 	return &bm, nil
 }
 
+// importUtxoSnapshot imports the utxo set snapshot at the passed path into the
+// passed chain after ensuring it matches the passed expected integrity hash.
+func importUtxoSnapshot(chain *blockchain.BlockChain, path string,
+	expectedHash *chainhash.Hash) error {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	bmgrLog.Infof("Importing utxo set snapshot %s", path)
+	return chain.ImportUTXOSnapshot(f, expectedHash)
+}
+
 // removeRegressionDB removes the existing regression test database if running
 // in regression test mode and it already exists.
 func removeRegressionDB(dbPath string) error {
@@ -1103,7 +1920,7 @@ func warnMultipleDBs() {
 	// This is intentionally not using the known db types which depend
 	// on the database types compiled into the binary since we want to
 	// detect legacy db types as well.
-	dbTypes := []string{"ffldb", "leveldb", "sqlite"}
+	dbTypes := []string{"ffldb", "ldb", "pgsql", "leveldb", "sqlite"}
 	duplicateDbPaths := make([]string, 0, len(dbTypes)-1)
 	for _, dbType := range dbTypes {
 		if dbType == cfg.DbType {
@@ -1129,6 +1946,43 @@ func warnMultipleDBs() {
 	}
 }
 
+// coldBlockDbPath returns the path to the cold storage of the block database
+// given a database type, or an empty string when there is no cold data
+// directory.
+func coldBlockDbPath(dbType string) string {
+	if cfg.ColdDataDir == "" {
+		return ""
+	}
+	return filepath.Join(cfg.ColdDataDir, blockDbNamePrefix+"_"+dbType)
+}
+
+// blockStorageLocation returns the location of the block storage of the block
+// database given a database type, which is the cold storage path for the ffldb
+// databases and the connection string for the pgsql databases, or an empty
+// string when none is configured.
+func blockStorageLocation(dbType string) string {
+	if dbType == "pgsql" {
+		return cfg.DbPgsqlConnect
+	}
+	return coldBlockDbPath(dbType)
+}
+
+// openBlockDB opens or creates the block database at the passed path with the
+// passed function of the database package, encrypted with the configured key if
+// there is one and with the blocks housed in the configured storage location if
+// there is one, such as the cold data directory or the PostgreSQL database.
+func openBlockDB(open func(string, ...interface{}) (database.DB, error), dbPath string) (database.DB, error) {
+	if location := blockStorageLocation(cfg.DbType); location != "" {
+		return open(cfg.DbType, dbPath, activeNetParams.Net,
+			cfg.dbEncryptionKey, location)
+	}
+	if cfg.dbEncryptionKey != nil {
+		return open(cfg.DbType, dbPath, activeNetParams.Net,
+			cfg.dbEncryptionKey)
+	}
+	return open(cfg.DbType, dbPath, activeNetParams.Net)
+}
+
 // loadBlockDB loads (or creates when needed) the block database taking into
 // account the selected database backend and returns a handle to it.  It also
 // contains additional logic such warning the user if there are multiple
@@ -1155,9 +2009,12 @@ func loadBlockDB() (database.DB, error) {
 	// The regression test is special in that it needs a clean database for
 	// each run, so remove it now if it already exists.
 	removeRegressionDB(dbPath)
+	if coldPath := coldBlockDbPath(cfg.DbType); coldPath != "" {
+		removeRegressionDB(coldPath)
+	}
 
 	btcdLog.Infof("Loading block database from '%s'", dbPath)
-	db, err := database.Open(cfg.DbType, dbPath, activeNetParams.Net)
+	db, err := openBlockDB(database.Open, dbPath)
 	if err != nil {
 		// Return the error if it's not because the database doesn't
 		// exist.
@@ -1172,7 +2029,7 @@ func loadBlockDB() (database.DB, error) {
 		if err != nil {
 			return nil, err
 		}
-		db, err = database.Create(cfg.DbType, dbPath, activeNetParams.Net)
+		db, err = openBlockDB(database.Create, dbPath)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,146 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+)
+
+// blockScrubber periodically verifies the stored data of the blocks against the
+// checksums it was stored with and the block index, and hands the blocks whose
+// data is corrupt to a repair function, which downloads them from peers again.
+// The result of the last scrub is kept to be reported by the getblockscrubinfo
+// RPC.
+type blockScrubber struct {
+	db       database.DB
+	interval time.Duration
+	repair   func(hashes []chainhash.Hash)
+
+	mtx       sync.Mutex
+	scrubbing bool
+	lastScrub time.Time
+	corrupt   []database.CorruptBlock
+	repaired  map[chainhash.Hash]struct{}
+}
+
+// newBlockScrubber returns a new scrubber which scrubs the blocks of the passed
+// database at the passed interval and hands the corrupt ones to the passed
+// repair function.
+func newBlockScrubber(db database.DB, interval time.Duration,
+	repair func(hashes []chainhash.Hash)) *blockScrubber {
+
+	return &blockScrubber{
+		db:       db,
+		interval: interval,
+		repair:   repair,
+		repaired: make(map[chainhash.Hash]struct{}),
+	}
+}
+
+// scrub verifies the stored blocks once and hands the corrupt ones to the
+// repair function.  The result is discarded when the scrub is interrupted by
+// closing the passed quit channel.
+func (s *blockScrubber) scrub(quit <-chan struct{}) error {
+	s.mtx.Lock()
+	s.scrubbing = true
+	s.mtx.Unlock()
+
+	corrupt, err := s.db.ScrubBlocks(quit)
+
+	s.mtx.Lock()
+	s.scrubbing = false
+	interrupted := false
+	select {
+	case <-quit:
+		interrupted = true
+	default:
+	}
+	if err == nil && !interrupted {
+		s.lastScrub = time.Now()
+		s.corrupt = corrupt
+		s.repaired = make(map[chainhash.Hash]struct{})
+	}
+	s.mtx.Unlock()
+	if err != nil || interrupted || len(corrupt) == 0 {
+		return err
+	}
+
+	// The repair function is called without holding the lock since the
+	// repaired blocks are marked while it runs.
+	srvrLog.Warnf("Found %d blocks with corrupt stored data -- downloading "+
+		"them again", len(corrupt))
+	hashes := make([]chainhash.Hash, 0, len(corrupt))
+	for i := range corrupt {
+		hashes = append(hashes, corrupt[i].Hash)
+	}
+	s.repair(hashes)
+	return nil
+}
+
+// BlockRepaired marks the block with the passed hash as repaired in the result
+// of the last scrub.
+//
+// This function is safe for concurrent access.
+func (s *blockScrubber) BlockRepaired(hash *chainhash.Hash) {
+	s.mtx.Lock()
+	s.repaired[*hash] = struct{}{}
+	s.mtx.Unlock()
+}
+
+// Info returns the result of the last scrub for the getblockscrubinfo RPC.
+//
+// This function is safe for concurrent access.
+func (s *blockScrubber) Info() *btcjson.GetBlockScrubInfoResult {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	result := &btcjson.GetBlockScrubInfoResult{
+		Interval:      int64(s.interval / time.Second),
+		Scrubbing:     s.scrubbing,
+		CorruptBlocks: make([]btcjson.CorruptBlockResult, 0, len(s.corrupt)),
+	}
+	if !s.lastScrub.IsZero() {
+		result.LastScrub = s.lastScrub.Unix()
+	}
+	for i := range s.corrupt {
+		block := &s.corrupt[i]
+		_, repaired := s.repaired[block.Hash]
+		result.CorruptBlocks = append(result.CorruptBlocks,
+			btcjson.CorruptBlockResult{
+				Hash:     block.Hash.String(),
+				File:     block.FileNum,
+				Offset:   block.Offset,
+				Length:   block.Len,
+				Error:    block.Err.Error(),
+				Repaired: repaired,
+			})
+	}
+	return result
+}
+
+// handler scrubs the blocks at the configured interval until the passed quit
+// channel is closed.  It must be run as a goroutine.
+func (s *blockScrubber) handler(quit <-chan struct{}) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.scrub(quit); err != nil {
+				srvrLog.Errorf("Unable to scrub the stored blocks: %v",
+					err)
+			}
+
+		case <-quit:
+			return
+		}
+	}
+}
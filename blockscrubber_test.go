@@ -0,0 +1,92 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+)
+
+// scrubTestDB is a database which reports a fixed set of corrupt blocks when
+// it is scrubbed.
+type scrubTestDB struct {
+	database.DB
+	corrupt []database.CorruptBlock
+}
+
+// ScrubBlocks returns the corrupt blocks of the test database.
+func (db *scrubTestDB) ScrubBlocks(interrupt <-chan struct{}) ([]database.CorruptBlock, error) {
+	return db.corrupt, nil
+}
+
+// TestBlockScrubber ensures the scrubber hands the corrupt blocks to the repair
+// function and reports them along with whether they were repaired.
+func TestBlockScrubber(t *testing.T) {
+	t.Parallel()
+
+	hashes := []chainhash.Hash{{0x01}, {0x02}}
+	db := &scrubTestDB{corrupt: []database.CorruptBlock{
+		{Hash: hashes[0], FileNum: 1, Offset: 100, Len: 200,
+			Err: errors.New("checksum mismatch")},
+		{Hash: hashes[1], FileNum: 2, Offset: 0, Len: 300,
+			Err: errors.New("header mismatch")},
+	}}
+	var repairHashes []chainhash.Hash
+	s := newBlockScrubber(db, time.Hour, func(hashes []chainhash.Hash) {
+		repairHashes = hashes
+	})
+
+	// Ensure nothing is reported before the first scrub.
+	info := s.Info()
+	if info.Interval != 3600 || info.LastScrub != 0 ||
+		len(info.CorruptBlocks) != 0 {
+
+		t.Fatalf("Info: unexpected result before scrubbing: %+v", info)
+	}
+
+	// Ensure the result of an interrupted scrub is discarded.
+	quit := make(chan struct{})
+	close(quit)
+	if err := s.scrub(quit); err != nil {
+		t.Fatalf("scrub: unexpected error: %v", err)
+	}
+	if info := s.Info(); info.LastScrub != 0 || repairHashes != nil {
+		t.Fatalf("scrub: result of interrupted scrub was kept: %+v",
+			info)
+	}
+
+	// Scrub and mark one of the blocks as repaired.
+	if err := s.scrub(nil); err != nil {
+		t.Fatalf("scrub: unexpected error: %v", err)
+	}
+	if len(repairHashes) != 2 || repairHashes[0] != hashes[0] ||
+		repairHashes[1] != hashes[1] {
+
+		t.Fatalf("scrub: repair function got %v, want %v",
+			repairHashes, hashes)
+	}
+	s.BlockRepaired(&hashes[1])
+
+	info = s.Info()
+	if info.Scrubbing || info.LastScrub == 0 ||
+		len(info.CorruptBlocks) != 2 {
+
+		t.Fatalf("Info: unexpected result after scrubbing: %+v", info)
+	}
+	got := info.CorruptBlocks[0]
+	if got.Hash != hashes[0].String() || got.File != 1 ||
+		got.Offset != 100 || got.Length != 200 ||
+		got.Error != "checksum mismatch" || got.Repaired {
+
+		t.Fatalf("Info: unexpected corrupt block %+v", got)
+	}
+	if !info.CorruptBlocks[1].Repaired {
+		t.Fatalf("Info: repaired block is not marked as repaired")
+	}
+}
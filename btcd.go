@@ -40,7 +40,11 @@ func btcdMain(serverChan chan<- *server) error {
 		return err
 	}
 	cfg = tcfg
-	defer backendLog.Flush()
+	defer flushLogs()
+
+	// Bound the duration of the shutdown by the configured timeout.  The
+	// stages are run by the deferred functions below in reverse order.
+	shutdown := newShutdownStages(cfg.ShutdownTimeout)
 
 	// Get a channel that will be closed when a shutdown signal has been
 	// triggered either from an OS signal such as SIGINT (Ctrl+C) or from
@@ -51,6 +55,12 @@ func btcdMain(serverChan chan<- *server) error {
 	// Show version at startup.
 	btcdLog.Infof("Version %s", version())
 
+	// Run the self-test and exit if requested.  It uses its own temporary
+	// databases, so it runs before the block database is loaded.
+	if cfg.SelfTest {
+		return runSelfTest(os.Stdout, cfg.DbType, cfg.DataDir)
+	}
+
 	// Enable http profiling server if requested.
 	if cfg.Profile != "" {
 		go func() {
@@ -89,7 +99,13 @@ func btcdMain(serverChan chan<- *server) error {
 	defer func() {
 		// Ensure the database is sync'd and closed on shutdown.
 		btcdLog.Infof("Gracefully shutting down the database...")
-		db.Close()
+		ok := shutdown.run("flushing and closing the database", func() {
+			db.Close()
+		})
+		if !ok {
+			btcdLog.Errorf("The database was not closed cleanly and " +
+				"may need to recover on the next start")
+		}
 	}()
 
 	// Return now if an interrupt signal was triggered.
@@ -100,7 +116,7 @@ func btcdMain(serverChan chan<- *server) error {
 	// Drop indexes and exit if requested.
 	//
 	// NOTE: The order is important here because dropping the tx index also
-	// drops the address index since it relies on it.
+	// drops the address indexes since they rely on it.
 	if cfg.DropAddrIndex {
 		if err := indexers.DropAddrIndex(db); err != nil {
 			btcdLog.Errorf("%v", err)
@@ -109,6 +125,110 @@ func btcdMain(serverChan chan<- *server) error {
 
 		return nil
 	}
+	if cfg.DropAddrValueIndex {
+		if err := indexers.DropAddrValueIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropSpentIndex {
+		if err := indexers.DropSpentIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropTimeIndex {
+		if err := indexers.DropTimeIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropKeyIDIndex {
+		if err := indexers.DropKeyIDIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropAdminOpIndex {
+		if err := indexers.DropAdminOpIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropIssuanceIndex {
+		if err := indexers.DropIssuanceIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropCfIndex {
+		if err := indexers.DropCfIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropAddrBalanceIndex {
+		if err := indexers.DropAddrBalanceIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropScriptUtxoIndex {
+		if err := indexers.DropScriptUtxoIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropFeeStatsIndex {
+		if err := indexers.DropFeeStatsIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropMerkleIndex {
+		if err := indexers.DropMerkleIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropWatchIndex {
+		if err := indexers.DropWatchIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
+	if cfg.DropStreamIndex {
+		if err := indexers.DropStreamIndex(db); err != nil {
+			btcdLog.Errorf("%v", err)
+			return err
+		}
+
+		return nil
+	}
 	if cfg.DropTxIndex {
 		if err := indexers.DropTxIndex(db); err != nil {
 			btcdLog.Errorf("%v", err)
@@ -127,10 +247,20 @@ func btcdMain(serverChan chan<- *server) error {
 		return err
 	}
 	defer func() {
+		// In-flight RPC requests are drained before the remaining
+		// subsystems are stopped since they may still depend on them.
 		btcdLog.Infof("Gracefully shutting down the server...")
+		if !cfg.DisableRPC {
+			shutdown.run("draining in-flight RPC requests", func() {
+				server.rpcServer.Stop()
+			})
+		}
 		server.Stop()
-		server.WaitForShutdown()
-		srvrLog.Infof("Server shutdown complete")
+		ok := shutdown.run("stopping peers, the block manager, and "+
+			"indexes", server.WaitForShutdown)
+		if ok {
+			srvrLog.Infof("Server shutdown complete")
+		}
 	}()
 	server.Start()
 	if serverChan != nil {
@@ -59,6 +59,62 @@ func NewDebugLevelCmd(levelSpec string) *DebugLevelCmd {
 	}
 }
 
+// DropIndexCmd defines the dropindex JSON-RPC command.  This command is not a
+// standard Bitcoin command.  It is an extension for btcd.
+type DropIndexCmd struct {
+	Index string
+}
+
+// NewDropIndexCmd returns a new instance which can be used to issue a dropindex
+// JSON-RPC command.  This command is not a standard Bitcoin command.  It is an
+// extension for btcd.
+func NewDropIndexCmd(index string) *DropIndexCmd {
+	return &DropIndexCmd{
+		Index: index,
+	}
+}
+
+// RebuildIndexCmd defines the rebuildindex JSON-RPC command.  This command is
+// not a standard Bitcoin command.  It is an extension for btcd.
+type RebuildIndexCmd struct {
+	Index string
+}
+
+// NewRebuildIndexCmd returns a new instance which can be used to issue a
+// rebuildindex JSON-RPC command.  This command is not a standard Bitcoin
+// command.  It is an extension for btcd.
+func NewRebuildIndexCmd(index string) *RebuildIndexCmd {
+	return &RebuildIndexCmd{
+		Index: index,
+	}
+}
+
+// CheckIndexCmd defines the checkindex JSON-RPC command.  This command is not a
+// standard Bitcoin command.  It is an extension for btcd.
+type CheckIndexCmd struct {
+	Index       string
+	StartHeight *uint32 `jsonrpcdefault:"0"`
+	EndHeight   *uint32
+	Interval    *uint32 `jsonrpcdefault:"1"`
+	Repair      *bool   `jsonrpcdefault:"false"`
+}
+
+// NewCheckIndexCmd returns a new instance which can be used to issue a
+// checkindex JSON-RPC command.  This command is not a standard Bitcoin
+// command.  It is an extension for btcd.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewCheckIndexCmd(index string, startHeight, endHeight, interval *uint32, repair *bool) *CheckIndexCmd {
+	return &CheckIndexCmd{
+		Index:       index,
+		StartHeight: startHeight,
+		EndHeight:   endHeight,
+		Interval:    interval,
+		Repair:      repair,
+	}
+}
+
 // GenerateCmd defines the generate JSON-RPC command.
 type GenerateCmd struct {
 	NumBlocks uint32
@@ -72,6 +128,26 @@ func NewGenerateCmd(numBlocks uint32) *GenerateCmd {
 	}
 }
 
+// GenerateToAddressCmd defines the generatetoaddress JSON-RPC command.
+type GenerateToAddressCmd struct {
+	NumBlocks uint32
+	Address   string
+	TxIDs     *[]string
+}
+
+// NewGenerateToAddressCmd returns a new instance which can be used to issue a
+// generatetoaddress JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGenerateToAddressCmd(numBlocks uint32, address string, txIDs *[]string) *GenerateToAddressCmd {
+	return &GenerateToAddressCmd{
+		NumBlocks: numBlocks,
+		Address:   address,
+		TxIDs:     txIDs,
+	}
+}
+
 // GetBestBlockCmd defines the getbestblock JSON-RPC command.
 type GetBestBlockCmd struct{}
 
@@ -111,6 +187,44 @@ func NewGetHeadersCmd(blockLocators []string, hashStop string) *GetHeadersCmd {
 	}
 }
 
+// ReloadConfigCmd defines the reloadconfig JSON-RPC command.  This command is
+// not a standard Bitcoin command.  It is an extension for btcd.
+type ReloadConfigCmd struct{}
+
+// NewReloadConfigCmd returns a new instance which can be used to issue a
+// reloadconfig JSON-RPC command.  This command is not a standard Bitcoin
+// command.  It is an extension for btcd.
+func NewReloadConfigCmd() *ReloadConfigCmd {
+	return &ReloadConfigCmd{}
+}
+
+// SetPolicyCmd defines the setpolicy JSON-RPC command.  Parameters which are
+// not provided leave the respective part of the relay policy unchanged.  This
+// command is not a standard Bitcoin command.  It is an extension for Prova.
+type SetPolicyCmd struct {
+	MaxSigOps       *int
+	DustThreshold   *float64
+	ScriptTypes     *[]string
+	DataCarrierSize *int
+}
+
+// NewSetPolicyCmd returns a new instance which can be used to issue a setpolicy
+// JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will leave the respective part of the policy
+// unchanged.
+func NewSetPolicyCmd(maxSigOps *int, dustThreshold *float64,
+	scriptTypes *[]string, dataCarrierSize *int) *SetPolicyCmd {
+
+	return &SetPolicyCmd{
+		MaxSigOps:       maxSigOps,
+		DustThreshold:   dustThreshold,
+		ScriptTypes:     scriptTypes,
+		DataCarrierSize: dataCarrierSize,
+	}
+}
+
 func init() {
 	// No special flags for commands in this file.
 	flags := UsageFlag(0)
@@ -118,7 +232,13 @@ func init() {
 	MustRegisterCmd("debuglevel", (*DebugLevelCmd)(nil), flags)
 	MustRegisterCmd("node", (*NodeCmd)(nil), flags)
 	MustRegisterCmd("generate", (*GenerateCmd)(nil), flags)
+	MustRegisterCmd("generatetoaddress", (*GenerateToAddressCmd)(nil), flags)
 	MustRegisterCmd("getbestblock", (*GetBestBlockCmd)(nil), flags)
 	MustRegisterCmd("getcurrentnet", (*GetCurrentNetCmd)(nil), flags)
 	MustRegisterCmd("getheaders", (*GetHeadersCmd)(nil), flags)
+	MustRegisterCmd("dropindex", (*DropIndexCmd)(nil), flags)
+	MustRegisterCmd("checkindex", (*CheckIndexCmd)(nil), flags)
+	MustRegisterCmd("rebuildindex", (*RebuildIndexCmd)(nil), flags)
+	MustRegisterCmd("reloadconfig", (*ReloadConfigCmd)(nil), flags)
+	MustRegisterCmd("setpolicy", (*SetPolicyCmd)(nil), flags)
 }
@@ -101,6 +101,112 @@ func TestBtcdExtCmds(t *testing.T) {
 				ConnectSubCmd: btcjson.String("temp"),
 			},
 		},
+		{
+			name: "dropindex",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("dropindex", "txbyhashidx")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDropIndexCmd("txbyhashidx")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"dropindex","params":["txbyhashidx"],"id":1}`,
+			unmarshalled: &btcjson.DropIndexCmd{
+				Index: "txbyhashidx",
+			},
+		},
+		{
+			name: "rebuildindex",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("rebuildindex", "txbyhashidx")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewRebuildIndexCmd("txbyhashidx")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"rebuildindex","params":["txbyhashidx"],"id":1}`,
+			unmarshalled: &btcjson.RebuildIndexCmd{
+				Index: "txbyhashidx",
+			},
+		},
+		{
+			name: "reloadconfig",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("reloadconfig")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewReloadConfigCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"reloadconfig","params":[],"id":1}`,
+			unmarshalled: &btcjson.ReloadConfigCmd{},
+		},
+		{
+			name: "setpolicy",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setpolicy")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetPolicyCmd(nil, nil, nil, nil)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"setpolicy","params":[],"id":1}`,
+			unmarshalled: &btcjson.SetPolicyCmd{},
+		},
+		{
+			name: "setpolicy optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setpolicy", 4000, 0.001,
+					[]string{"safe_multisig", "nulldata"}, 40)
+			},
+			staticCmd: func() interface{} {
+				scriptTypes := []string{"safe_multisig", "nulldata"}
+				return btcjson.NewSetPolicyCmd(btcjson.Int(4000),
+					btcjson.Float64(0.001), &scriptTypes,
+					btcjson.Int(40))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setpolicy","params":[4000,0.001,["safe_multisig","nulldata"],40],"id":1}`,
+			unmarshalled: &btcjson.SetPolicyCmd{
+				MaxSigOps:       btcjson.Int(4000),
+				DustThreshold:   btcjson.Float64(0.001),
+				ScriptTypes:     &[]string{"safe_multisig", "nulldata"},
+				DataCarrierSize: btcjson.Int(40),
+			},
+		},
+		{
+			name: "checkindex",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("checkindex", "txbyhashidx")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCheckIndexCmd("txbyhashidx", nil, nil,
+					nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"checkindex","params":["txbyhashidx"],"id":1}`,
+			unmarshalled: &btcjson.CheckIndexCmd{
+				Index:       "txbyhashidx",
+				StartHeight: btcjson.Uint32(0),
+				EndHeight:   nil,
+				Interval:    btcjson.Uint32(1),
+				Repair:      btcjson.Bool(false),
+			},
+		},
+		{
+			name: "checkindex optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("checkindex", "txbyhashidx", 100,
+					200, 10, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCheckIndexCmd("txbyhashidx",
+					btcjson.Uint32(100), btcjson.Uint32(200),
+					btcjson.Uint32(10), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"checkindex","params":["txbyhashidx",100,200,10,true],"id":1}`,
+			unmarshalled: &btcjson.CheckIndexCmd{
+				Index:       "txbyhashidx",
+				StartHeight: btcjson.Uint32(100),
+				EndHeight:   btcjson.Uint32(200),
+				Interval:    btcjson.Uint32(10),
+				Repair:      btcjson.Bool(true),
+			},
+		},
 		{
 			name: "generate",
 			newCmd: func() (interface{}, error) {
@@ -114,6 +220,38 @@ func TestBtcdExtCmds(t *testing.T) {
 				NumBlocks: 1,
 			},
 		},
+		{
+			name: "generatetoaddress",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generatetoaddress", 1, "1Address")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGenerateToAddressCmd(1, "1Address", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generatetoaddress","params":[1,"1Address"],"id":1}`,
+			unmarshalled: &btcjson.GenerateToAddressCmd{
+				NumBlocks: 1,
+				Address:   "1Address",
+			},
+		},
+		{
+			name: "generatetoaddress optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("generatetoaddress", 1, "1Address",
+					`["123"]`)
+			},
+			staticCmd: func() interface{} {
+				txIDs := []string{"123"}
+				return btcjson.NewGenerateToAddressCmd(1, "1Address",
+					&txIDs)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"generatetoaddress","params":[1,"1Address",["123"]],"id":1}`,
+			unmarshalled: &btcjson.GenerateToAddressCmd{
+				NumBlocks: 1,
+				Address:   "1Address",
+				TxIDs:     &[]string{"123"},
+			},
+		},
 		{
 			name: "getbestblock",
 			newCmd: func() (interface{}, error) {
@@ -35,31 +35,41 @@ func NewDumpWalletCmd(filename string) *DumpWalletCmd {
 
 // ImportAddressCmd defines the importaddress JSON-RPC command.
 type ImportAddressCmd struct {
-	Address string
-	Rescan  *bool `jsonrpcdefault:"true"`
+	Address     string
+	Rescan      *bool   `jsonrpcdefault:"true"`
+	StartHeight *uint32 `jsonrpcdefault:"0"`
 }
 
 // NewImportAddressCmd returns a new instance which can be used to issue an
 // importaddress JSON-RPC command.
-func NewImportAddressCmd(address string, rescan *bool) *ImportAddressCmd {
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewImportAddressCmd(address string, rescan *bool, startHeight *uint32) *ImportAddressCmd {
 	return &ImportAddressCmd{
-		Address: address,
-		Rescan:  rescan,
+		Address:     address,
+		Rescan:      rescan,
+		StartHeight: startHeight,
 	}
 }
 
 // ImportPubKeyCmd defines the importpubkey JSON-RPC command.
 type ImportPubKeyCmd struct {
-	PubKey string
-	Rescan *bool `jsonrpcdefault:"true"`
+	PubKey      string
+	Rescan      *bool   `jsonrpcdefault:"true"`
+	StartHeight *uint32 `jsonrpcdefault:"0"`
 }
 
 // NewImportPubKeyCmd returns a new instance which can be used to issue an
 // importpubkey JSON-RPC command.
-func NewImportPubKeyCmd(pubKey string, rescan *bool) *ImportPubKeyCmd {
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewImportPubKeyCmd(pubKey string, rescan *bool, startHeight *uint32) *ImportPubKeyCmd {
 	return &ImportPubKeyCmd{
-		PubKey: pubKey,
-		Rescan: rescan,
+		PubKey:      pubKey,
+		Rescan:      rescan,
+		StartHeight: startHeight,
 	}
 }
 
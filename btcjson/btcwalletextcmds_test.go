@@ -62,12 +62,13 @@ func TestBtcWalletExtCmds(t *testing.T) {
 				return btcjson.NewCmd("importaddress", "1Address")
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewImportAddressCmd("1Address", nil)
+				return btcjson.NewImportAddressCmd("1Address", nil, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"importaddress","params":["1Address"],"id":1}`,
 			unmarshalled: &btcjson.ImportAddressCmd{
-				Address: "1Address",
-				Rescan:  btcjson.Bool(true),
+				Address:     "1Address",
+				Rescan:      btcjson.Bool(true),
+				StartHeight: btcjson.Uint32(0),
 			},
 		},
 		{
@@ -76,12 +77,28 @@ func TestBtcWalletExtCmds(t *testing.T) {
 				return btcjson.NewCmd("importaddress", "1Address", false)
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewImportAddressCmd("1Address", btcjson.Bool(false))
+				return btcjson.NewImportAddressCmd("1Address", btcjson.Bool(false), nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"importaddress","params":["1Address",false],"id":1}`,
 			unmarshalled: &btcjson.ImportAddressCmd{
-				Address: "1Address",
-				Rescan:  btcjson.Bool(false),
+				Address:     "1Address",
+				Rescan:      btcjson.Bool(false),
+				StartHeight: btcjson.Uint32(0),
+			},
+		},
+		{
+			name: "importaddress startheight",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("importaddress", "1Address", true, 1200)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewImportAddressCmd("1Address", btcjson.Bool(true), btcjson.Uint32(1200))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"importaddress","params":["1Address",true,1200],"id":1}`,
+			unmarshalled: &btcjson.ImportAddressCmd{
+				Address:     "1Address",
+				Rescan:      btcjson.Bool(true),
+				StartHeight: btcjson.Uint32(1200),
 			},
 		},
 		{
@@ -90,12 +107,13 @@ func TestBtcWalletExtCmds(t *testing.T) {
 				return btcjson.NewCmd("importpubkey", "031234")
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewImportPubKeyCmd("031234", nil)
+				return btcjson.NewImportPubKeyCmd("031234", nil, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"importpubkey","params":["031234"],"id":1}`,
 			unmarshalled: &btcjson.ImportPubKeyCmd{
-				PubKey: "031234",
-				Rescan: btcjson.Bool(true),
+				PubKey:      "031234",
+				Rescan:      btcjson.Bool(true),
+				StartHeight: btcjson.Uint32(0),
 			},
 		},
 		{
@@ -104,12 +122,28 @@ func TestBtcWalletExtCmds(t *testing.T) {
 				return btcjson.NewCmd("importpubkey", "031234", false)
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewImportPubKeyCmd("031234", btcjson.Bool(false))
+				return btcjson.NewImportPubKeyCmd("031234", btcjson.Bool(false), nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"importpubkey","params":["031234",false],"id":1}`,
 			unmarshalled: &btcjson.ImportPubKeyCmd{
-				PubKey: "031234",
-				Rescan: btcjson.Bool(false),
+				PubKey:      "031234",
+				Rescan:      btcjson.Bool(false),
+				StartHeight: btcjson.Uint32(0),
+			},
+		},
+		{
+			name: "importpubkey startheight",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("importpubkey", "031234", true, 1200)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewImportPubKeyCmd("031234", btcjson.Bool(true), btcjson.Uint32(1200))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"importpubkey","params":["031234",true,1200],"id":1}`,
+			unmarshalled: &btcjson.ImportPubKeyCmd{
+				PubKey:      "031234",
+				Rescan:      btcjson.Bool(true),
+				StartHeight: btcjson.Uint32(1200),
 			},
 		},
 		{
@@ -13,6 +13,19 @@ import (
 	"fmt"
 )
 
+// AbortRescanCmd defines the abortrescan JSON-RPC command.
+type AbortRescanCmd struct {
+	JobID string
+}
+
+// NewAbortRescanCmd returns a new instance which can be used to issue an
+// abortrescan JSON-RPC command.
+func NewAbortRescanCmd(jobID string) *AbortRescanCmd {
+	return &AbortRescanCmd{
+		JobID: jobID,
+	}
+}
+
 // AddNodeSubCmd defines the type used in the addnode JSON-RPC command for the
 // sub command field.
 type AddNodeSubCmd string
@@ -45,6 +58,19 @@ func NewAddNodeCmd(addr string, subCmd AddNodeSubCmd) *AddNodeCmd {
 	}
 }
 
+// BackupChainStateCmd defines the backupchainstate JSON-RPC command.
+type BackupChainStateCmd struct {
+	Path string
+}
+
+// NewBackupChainStateCmd returns a new instance which can be used to issue a
+// backupchainstate JSON-RPC command.
+func NewBackupChainStateCmd(path string) *BackupChainStateCmd {
+	return &BackupChainStateCmd{
+		Path: path,
+	}
+}
+
 // TransactionInput represents the inputs to a transaction.  Specifically a
 // transaction hash and output number pair.
 type TransactionInput struct {
@@ -52,24 +78,119 @@ type TransactionInput struct {
 	Vout uint32 `json:"vout"`
 }
 
+// CreateRawAdminTransactionParams houses the parameters of the operation
+// carried out by the transaction of the createrawadmintransaction JSON-RPC
+// command.  PubKey is used by the key operations and KeyID by the ASP key
+// operations.  Amounts is used by issue, and Inputs and Fee by destroy.
+type CreateRawAdminTransactionParams struct {
+	PubKey  string             `json:"pubkey,omitempty"`
+	KeyID   uint32             `json:"keyid,omitempty"`
+	Amounts map[string]float64 `json:"amounts,omitempty"` // In RMG
+	Inputs  []TransactionInput `json:"inputs,omitempty"`
+	Fee     float64            `json:"fee,omitempty"` // In RMG
+}
+
+// ClearBannedCmd defines the clearbanned JSON-RPC command.
+type ClearBannedCmd struct{}
+
+// NewClearBannedCmd returns a new instance which can be used to issue a
+// clearbanned JSON-RPC command.
+func NewClearBannedCmd() *ClearBannedCmd {
+	return &ClearBannedCmd{}
+}
+
+// CreateRawAdminTransactionCmd defines the createrawadmintransaction JSON-RPC
+// command.
+type CreateRawAdminTransactionCmd struct {
+	Operation string
+	Params    CreateRawAdminTransactionParams
+}
+
+// NewCreateRawAdminTransactionCmd returns a new instance which can be used to
+// issue a createrawadmintransaction JSON-RPC command.
+func NewCreateRawAdminTransactionCmd(operation string,
+	params CreateRawAdminTransactionParams) *CreateRawAdminTransactionCmd {
+
+	return &CreateRawAdminTransactionCmd{
+		Operation: operation,
+		Params:    params,
+	}
+}
+
 // CreateRawTransactionCmd defines the createrawtransaction JSON-RPC command.
 type CreateRawTransactionCmd struct {
 	Inputs   []TransactionInput
 	Amounts  map[string]float64 `jsonrpcusage:"{\"address\":amount,...}"` // In RMG
 	LockTime *int64
+	Data     *string
 }
 
 // NewCreateRawTransactionCmd returns a new instance which can be used to issue
 // a createrawtransaction JSON-RPC command.
 //
-// Amounts are in RMG.
+// Amounts are in RMG.  The hex-encoded data, when not nil, is carried by an
+// additional null data output.
 func NewCreateRawTransactionCmd(inputs []TransactionInput, amounts map[string]float64,
-	lockTime *int64) *CreateRawTransactionCmd {
+	lockTime *int64, data *string) *CreateRawTransactionCmd {
 
 	return &CreateRawTransactionCmd{
 		Inputs:   inputs,
 		Amounts:  amounts,
 		LockTime: lockTime,
+		Data:     data,
+	}
+}
+
+// CombinePSPTCmd defines the combinepspt JSON-RPC command.
+type CombinePSPTCmd struct {
+	PSPTs []string
+}
+
+// NewCombinePSPTCmd returns a new instance which can be used to issue a
+// combinepspt JSON-RPC command.
+func NewCombinePSPTCmd(pspts []string) *CombinePSPTCmd {
+	return &CombinePSPTCmd{
+		PSPTs: pspts,
+	}
+}
+
+// CreatePSPTCmd defines the createpspt JSON-RPC command.
+type CreatePSPTCmd struct {
+	HexTx string
+}
+
+// NewCreatePSPTCmd returns a new instance which can be used to issue a
+// createpspt JSON-RPC command.
+func NewCreatePSPTCmd(hexTx string) *CreatePSPTCmd {
+	return &CreatePSPTCmd{
+		HexTx: hexTx,
+	}
+}
+
+// DecodeAdminTransactionCmd defines the decodeadmintransaction JSON-RPC
+// command.
+type DecodeAdminTransactionCmd struct {
+	HexTx string
+}
+
+// NewDecodeAdminTransactionCmd returns a new instance which can be used to
+// issue a decodeadmintransaction JSON-RPC command.
+func NewDecodeAdminTransactionCmd(hexTx string) *DecodeAdminTransactionCmd {
+	return &DecodeAdminTransactionCmd{
+		HexTx: hexTx,
+	}
+}
+
+// DecodePSPTCmd defines the decodepspt JSON-RPC command.
+type DecodePSPTCmd struct {
+	PSPT string
+}
+
+// NewDecodePSPTCmd returns a new instance which can be used to issue a
+// decodepspt JSON-RPC command.
+func NewDecodePSPTCmd(pspt string) *DecodePSPTCmd {
+	return &DecodePSPTCmd{
+		PSPT: pspt,
 	}
 }
 
@@ -86,16 +207,105 @@ func NewDecodeRawTransactionCmd(hexTx string) *DecodeRawTransactionCmd {
 	}
 }
 
+// DebugScriptCmd defines the debugscript JSON-RPC command.
+type DebugScriptCmd struct {
+	HexTx        string
+	Vin          uint32
+	ScriptPubKey *string
+	Amount       *float64
+}
+
+// NewDebugScriptCmd returns a new instance which can be used to issue a
+// debugscript JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewDebugScriptCmd(hexTx string, vin uint32, scriptPubKey *string, amount *float64) *DebugScriptCmd {
+	return &DebugScriptCmd{
+		HexTx:        hexTx,
+		Vin:          vin,
+		ScriptPubKey: scriptPubKey,
+		Amount:       amount,
+	}
+}
+
 // DecodeScriptCmd defines the decodescript JSON-RPC command.
 type DecodeScriptCmd struct {
 	HexScript string
+	Annotated *bool `jsonrpcdefault:"false"`
 }
 
 // NewDecodeScriptCmd returns a new instance which can be used to issue a
 // decodescript JSON-RPC command.
-func NewDecodeScriptCmd(hexScript string) *DecodeScriptCmd {
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewDecodeScriptCmd(hexScript string, annotated *bool) *DecodeScriptCmd {
 	return &DecodeScriptCmd{
 		HexScript: hexScript,
+		Annotated: annotated,
+	}
+}
+
+// DumpUTXOSnapshotCmd defines the dumputxosnapshot JSON-RPC command.
+type DumpUTXOSnapshotCmd struct {
+	Path   string
+	Height *uint32
+}
+
+// NewDumpUTXOSnapshotCmd returns a new instance which can be used to issue a
+// dumputxosnapshot JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewDumpUTXOSnapshotCmd(path string, height *uint32) *DumpUTXOSnapshotCmd {
+	return &DumpUTXOSnapshotCmd{
+		Path:   path,
+		Height: height,
+	}
+}
+
+// EstimateFeeCmd defines the estimatefee JSON-RPC command.
+type EstimateFeeCmd struct {
+	NumBlocks int64
+}
+
+// NewEstimateFeeCmd returns a new instance which can be used to issue a
+// estimatefee JSON-RPC command.
+func NewEstimateFeeCmd(numBlocks int64) *EstimateFeeCmd {
+	return &EstimateFeeCmd{
+		NumBlocks: numBlocks,
+	}
+}
+
+// EstimateSmartFeeCmd defines the estimatesmartfee JSON-RPC command.
+type EstimateSmartFeeCmd struct {
+	ConfTarget   int64
+	EstimateMode *string `jsonrpcdefault:"\"CONSERVATIVE\""`
+}
+
+// NewEstimateSmartFeeCmd returns a new instance which can be used to issue an
+// estimatesmartfee JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewEstimateSmartFeeCmd(confTarget int64, estimateMode *string) *EstimateSmartFeeCmd {
+	return &EstimateSmartFeeCmd{
+		ConfTarget:   confTarget,
+		EstimateMode: estimateMode,
+	}
+}
+
+// FinalizePSPTCmd defines the finalizepspt JSON-RPC command.
+type FinalizePSPTCmd struct {
+	PSPT string
+}
+
+// NewFinalizePSPTCmd returns a new instance which can be used to issue a
+// finalizepspt JSON-RPC command.
+func NewFinalizePSPTCmd(pspt string) *FinalizePSPTCmd {
+	return &FinalizePSPTCmd{
+		PSPT: pspt,
 	}
 }
 
@@ -186,6 +396,19 @@ func NewGetBlockHashCmd(index int64) *GetBlockHashCmd {
 	}
 }
 
+// GetBlockHashByTimeCmd defines the getblockhashbytime JSON-RPC command.
+type GetBlockHashByTimeCmd struct {
+	Timestamp int64
+}
+
+// NewGetBlockHashByTimeCmd returns a new instance which can be used to issue a
+// getblockhashbytime JSON-RPC command.
+func NewGetBlockHashByTimeCmd(timestamp int64) *GetBlockHashByTimeCmd {
+	return &GetBlockHashByTimeCmd{
+		Timestamp: timestamp,
+	}
+}
+
 // GetBlockHeaderCmd defines the getblockheader JSON-RPC command.
 type GetBlockHeaderCmd struct {
 	Hash    string
@@ -201,6 +424,93 @@ func NewGetBlockHeaderCmd(hash string, verbose *bool) *GetBlockHeaderCmd {
 	}
 }
 
+// GetCFilterCmd defines the getcfilter JSON-RPC command.
+type GetCFilterCmd struct {
+	Hash       string
+	FilterType *uint8 `jsonrpcdefault:"0"`
+}
+
+// NewGetCFilterCmd returns a new instance which can be used to issue a
+// getcfilter JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetCFilterCmd(hash string, filterType *uint8) *GetCFilterCmd {
+	return &GetCFilterCmd{
+		Hash:       hash,
+		FilterType: filterType,
+	}
+}
+
+// GetCFHeadersCmd defines the getcfheaders JSON-RPC command.
+type GetCFHeadersCmd struct {
+	StartHeight uint32
+	StopHash    string
+	FilterType  *uint8 `jsonrpcdefault:"0"`
+}
+
+// NewGetCFHeadersCmd returns a new instance which can be used to issue a
+// getcfheaders JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetCFHeadersCmd(startHeight uint32, stopHash string, filterType *uint8) *GetCFHeadersCmd {
+	return &GetCFHeadersCmd{
+		StartHeight: startHeight,
+		StopHash:    stopHash,
+		FilterType:  filterType,
+	}
+}
+
+// HashOrHeight identifies a block by either its hash or its height.  Heights
+// may be passed as JSON numbers or strings and are converted to their decimal
+// string representation.
+type HashOrHeight string
+
+// UnmarshalJSON provides a custom Unmarshal method for HashOrHeight which also
+// accepts heights passed as JSON numbers.
+func (h *HashOrHeight) UnmarshalJSON(data []byte) error {
+	var height uint32
+	if err := json.Unmarshal(data, &height); err == nil {
+		*h = HashOrHeight(fmt.Sprintf("%d", height))
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	*h = HashOrHeight(str)
+	return nil
+}
+
+// GetBlockScrubInfoCmd defines the getblockscrubinfo JSON-RPC command.
+type GetBlockScrubInfoCmd struct{}
+
+// NewGetBlockScrubInfoCmd returns a new instance which can be used to issue a
+// getblockscrubinfo JSON-RPC command.
+func NewGetBlockScrubInfoCmd() *GetBlockScrubInfoCmd {
+	return &GetBlockScrubInfoCmd{}
+}
+
+// GetBlockStatsCmd defines the getblockstats JSON-RPC command.
+type GetBlockStatsCmd struct {
+	HashOrHeight HashOrHeight `jsonrpcusage:"hash_or_height"`
+	EndHeight    *uint32
+}
+
+// NewGetBlockStatsCmd returns a new instance which can be used to issue a
+// getblockstats JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetBlockStatsCmd(hashOrHeight HashOrHeight, endHeight *uint32) *GetBlockStatsCmd {
+	return &GetBlockStatsCmd{
+		HashOrHeight: hashOrHeight,
+		EndHeight:    endHeight,
+	}
+}
+
 // TemplateRequest is a request object as defined in BIP22
 // (https://en.bitcoin.it/wiki/BIP_0022), it is optionally provided as an
 // pointer argument to GetBlockTemplateCmd.
@@ -306,6 +616,23 @@ func NewGetChainTipsCmd() *GetChainTipsCmd {
 	return &GetChainTipsCmd{}
 }
 
+// GetCheckpointCandidatesCmd defines the getcheckpointcandidates JSON-RPC
+// command.
+type GetCheckpointCandidatesCmd struct {
+	Count *int `jsonrpcdefault:"5"`
+}
+
+// NewGetCheckpointCandidatesCmd returns a new instance which can be used to
+// issue a getcheckpointcandidates JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetCheckpointCandidatesCmd(count *int) *GetCheckpointCandidatesCmd {
+	return &GetCheckpointCandidatesCmd{
+		Count: count,
+	}
+}
+
 // GetConnectionCountCmd defines the getconnectioncount JSON-RPC command.
 type GetConnectionCountCmd struct{}
 
@@ -342,6 +669,24 @@ func NewGetHashesPerSecCmd() *GetHashesPerSecCmd {
 	return &GetHashesPerSecCmd{}
 }
 
+// GetIndexInfoCmd defines the getindexinfo JSON-RPC command.
+type GetIndexInfoCmd struct{}
+
+// NewGetIndexInfoCmd returns a new instance which can be used to issue a
+// getindexinfo JSON-RPC command.
+func NewGetIndexInfoCmd() *GetIndexInfoCmd {
+	return &GetIndexInfoCmd{}
+}
+
+// GetIssuanceInfoCmd defines the getissuanceinfo JSON-RPC command.
+type GetIssuanceInfoCmd struct{}
+
+// NewGetIssuanceInfoCmd returns a new instance which can be used to issue a
+// getissuanceinfo JSON-RPC command.
+func NewGetIssuanceInfoCmd() *GetIssuanceInfoCmd {
+	return &GetIssuanceInfoCmd{}
+}
+
 // GetInfoCmd defines the getinfo JSON-RPC command.
 type GetInfoCmd struct{}
 
@@ -351,6 +696,121 @@ func NewGetInfoCmd() *GetInfoCmd {
 	return &GetInfoCmd{}
 }
 
+// GetKeyIDActivityCmd defines the getkeyidactivity JSON-RPC command.
+type GetKeyIDActivityCmd struct {
+	KeyID       uint32
+	StartHeight *uint32 `jsonrpcdefault:"0"`
+	EndHeight   *uint32
+}
+
+// NewGetKeyIDActivityCmd returns a new instance which can be used to issue a
+// getkeyidactivity JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetKeyIDActivityCmd(keyID uint32, startHeight, endHeight *uint32) *GetKeyIDActivityCmd {
+	return &GetKeyIDActivityCmd{
+		KeyID:       keyID,
+		StartHeight: startHeight,
+		EndHeight:   endHeight,
+	}
+}
+
+// GetKeyIDInfoCmd defines the getkeyidinfo JSON-RPC command.
+type GetKeyIDInfoCmd struct {
+	KeyID uint32
+}
+
+// NewGetKeyIDInfoCmd returns a new instance which can be used to issue a
+// getkeyidinfo JSON-RPC command.
+func NewGetKeyIDInfoCmd(keyID uint32) *GetKeyIDInfoCmd {
+	return &GetKeyIDInfoCmd{
+		KeyID: keyID,
+	}
+}
+
+// GetAddressBalanceCmd defines the getaddressbalance JSON-RPC command.
+type GetAddressBalanceCmd struct {
+	Address string
+}
+
+// NewGetAddressBalanceCmd returns a new instance which can be used to issue a
+// getaddressbalance JSON-RPC command.
+func NewGetAddressBalanceCmd(address string) *GetAddressBalanceCmd {
+	return &GetAddressBalanceCmd{
+		Address: address,
+	}
+}
+
+// GetAddressUtxosCmd defines the getaddressutxos JSON-RPC command.
+type GetAddressUtxosCmd struct {
+	Address string
+}
+
+// NewGetAddressUtxosCmd returns a new instance which can be used to issue a
+// getaddressutxos JSON-RPC command.
+func NewGetAddressUtxosCmd(address string) *GetAddressUtxosCmd {
+	return &GetAddressUtxosCmd{
+		Address: address,
+	}
+}
+
+// GetAddressIssuanceCmd defines the getaddressissuance JSON-RPC command.
+type GetAddressIssuanceCmd struct {
+	Address     string
+	StartHeight *uint32 `jsonrpcdefault:"0"`
+	EndHeight   *uint32
+}
+
+// NewGetAddressIssuanceCmd returns a new instance which can be used to issue a
+// getaddressissuance JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetAddressIssuanceCmd(address string, startHeight, endHeight *uint32) *GetAddressIssuanceCmd {
+	return &GetAddressIssuanceCmd{
+		Address:     address,
+		StartHeight: startHeight,
+		EndHeight:   endHeight,
+	}
+}
+
+// GetMempoolAncestorsCmd defines the getmempoolancestors JSON-RPC command.
+type GetMempoolAncestorsCmd struct {
+	TxID    string
+	Verbose *bool `jsonrpcdefault:"false"`
+}
+
+// NewGetMempoolAncestorsCmd returns a new instance which can be used to issue a
+// getmempoolancestors JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetMempoolAncestorsCmd(txHash string, verbose *bool) *GetMempoolAncestorsCmd {
+	return &GetMempoolAncestorsCmd{
+		TxID:    txHash,
+		Verbose: verbose,
+	}
+}
+
+// GetMempoolDescendantsCmd defines the getmempooldescendants JSON-RPC command.
+type GetMempoolDescendantsCmd struct {
+	TxID    string
+	Verbose *bool `jsonrpcdefault:"false"`
+}
+
+// NewGetMempoolDescendantsCmd returns a new instance which can be used to issue
+// a getmempooldescendants JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetMempoolDescendantsCmd(txHash string, verbose *bool) *GetMempoolDescendantsCmd {
+	return &GetMempoolDescendantsCmd{
+		TxID:    txHash,
+		Verbose: verbose,
+	}
+}
+
 // GetMempoolEntryCmd defines the getmempoolentry JSON-RPC command.
 type GetMempoolEntryCmd struct {
 	TxID string
@@ -464,6 +924,30 @@ func NewGetRawTransactionCmd(txHash string, verbose *int) *GetRawTransactionCmd
 	}
 }
 
+// GetRescanInfoCmd defines the getrescaninfo JSON-RPC command.
+type GetRescanInfoCmd struct{}
+
+// NewGetRescanInfoCmd returns a new instance which can be used to issue a
+// getrescaninfo JSON-RPC command.
+func NewGetRescanInfoCmd() *GetRescanInfoCmd {
+	return &GetRescanInfoCmd{}
+}
+
+// GetSpentInfoCmd defines the getspentinfo JSON-RPC command.
+type GetSpentInfoCmd struct {
+	Txid  string
+	Index uint32
+}
+
+// NewGetSpentInfoCmd returns a new instance which can be used to issue a
+// getspentinfo JSON-RPC command.
+func NewGetSpentInfoCmd(txHash string, index uint32) *GetSpentInfoCmd {
+	return &GetSpentInfoCmd{
+		Txid:  txHash,
+		Index: index,
+	}
+}
+
 // GetTxOutCmd defines the gettxout JSON-RPC command.
 type GetTxOutCmd struct {
 	Txid           string
@@ -511,6 +995,85 @@ func NewGetTxOutSetInfoCmd() *GetTxOutSetInfoCmd {
 	return &GetTxOutSetInfoCmd{}
 }
 
+// GetTxSpendingInfoCmd defines the gettxspendinginfo JSON-RPC command.
+type GetTxSpendingInfoCmd struct {
+	Txid string
+}
+
+// NewGetTxSpendingInfoCmd returns a new instance which can be used to issue a
+// gettxspendinginfo JSON-RPC command.
+func NewGetTxSpendingInfoCmd(txHash string) *GetTxSpendingInfoCmd {
+	return &GetTxSpendingInfoCmd{
+		Txid: txHash,
+	}
+}
+
+// GetValidationTimingsCmd defines the getvalidationtimings JSON-RPC command.
+type GetValidationTimingsCmd struct{}
+
+// NewGetValidationTimingsCmd returns a new instance which can be used to issue
+// a getvalidationtimings JSON-RPC command.
+func NewGetValidationTimingsCmd() *GetValidationTimingsCmd {
+	return &GetValidationTimingsCmd{}
+}
+
+// GetValidatorStatsCmd defines the getvalidatorstats JSON-RPC command.
+type GetValidatorStatsCmd struct{}
+
+// NewGetValidatorStatsCmd returns a new instance which can be used to issue a
+// getvalidatorstats JSON-RPC command.
+func NewGetValidatorStatsCmd() *GetValidatorStatsCmd {
+	return &GetValidatorStatsCmd{}
+}
+
+// GetWatchedBalanceCmd defines the getwatchedbalance JSON-RPC command.
+type GetWatchedBalanceCmd struct {
+	Address string
+}
+
+// NewGetWatchedBalanceCmd returns a new instance which can be used to issue a
+// getwatchedbalance JSON-RPC command.
+func NewGetWatchedBalanceCmd(address string) *GetWatchedBalanceCmd {
+	return &GetWatchedBalanceCmd{
+		Address: address,
+	}
+}
+
+// GetWatchedHistoryCmd defines the getwatchedhistory JSON-RPC command.
+type GetWatchedHistoryCmd struct {
+	Address string
+	Skip    *int  `jsonrpcdefault:"0"`
+	Count   *int  `jsonrpcdefault:"100"`
+	Reverse *bool `jsonrpcdefault:"false"`
+}
+
+// NewGetWatchedHistoryCmd returns a new instance which can be used to issue a
+// getwatchedhistory JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetWatchedHistoryCmd(address string, skip, count *int, reverse *bool) *GetWatchedHistoryCmd {
+	return &GetWatchedHistoryCmd{
+		Address: address,
+		Skip:    skip,
+		Count:   count,
+		Reverse: reverse,
+	}
+}
+
+// GetWatchedUtxosCmd defines the getwatchedutxos JSON-RPC command.
+type GetWatchedUtxosCmd struct {
+	Address string
+}
+
+// NewGetWatchedUtxosCmd returns a new instance which can be used to issue a
+// getwatchedutxos JSON-RPC command.
+func NewGetWatchedUtxosCmd(address string) *GetWatchedUtxosCmd {
+	return &GetWatchedUtxosCmd{
+		Address: address,
+	}
+}
+
 // GetWorkCmd defines the getwork JSON-RPC command.
 type GetWorkCmd struct {
 	Data *string
@@ -556,6 +1119,51 @@ func NewInvalidateBlockCmd(blockHash string) *InvalidateBlockCmd {
 	}
 }
 
+// AdminOperationFilter is a filter object used by the listadminoperations
+// JSON-RPC command.  Empty fields do not restrict the returned operations.
+type AdminOperationFilter struct {
+	Types       []string `json:"types,omitempty"`
+	KeySets     []string `json:"keysets,omitempty"`
+	PubKey      string   `json:"pubkey,omitempty"`
+	KeyID       *uint32  `json:"keyid,omitempty"`
+	StartHeight uint32   `json:"startheight,omitempty"`
+	EndHeight   *uint32  `json:"endheight,omitempty"`
+}
+
+// ListAdminOperationsCmd defines the listadminoperations JSON-RPC command.
+type ListAdminOperationsCmd struct {
+	Filter *AdminOperationFilter
+}
+
+// NewListAdminOperationsCmd returns a new instance which can be used to issue
+// a listadminoperations JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewListAdminOperationsCmd(filter *AdminOperationFilter) *ListAdminOperationsCmd {
+	return &ListAdminOperationsCmd{
+		Filter: filter,
+	}
+}
+
+// ListBannedCmd defines the listbanned JSON-RPC command.
+type ListBannedCmd struct{}
+
+// NewListBannedCmd returns a new instance which can be used to issue a
+// listbanned JSON-RPC command.
+func NewListBannedCmd() *ListBannedCmd {
+	return &ListBannedCmd{}
+}
+
+// ListWatchedCmd defines the listwatched JSON-RPC command.
+type ListWatchedCmd struct{}
+
+// NewListWatchedCmd returns a new instance which can be used to issue a
+// listwatched JSON-RPC command.
+func NewListWatchedCmd() *ListWatchedCmd {
+	return &ListWatchedCmd{}
+}
+
 // PingCmd defines the ping JSON-RPC command.
 type PingCmd struct{}
 
@@ -596,6 +1204,24 @@ type GetAddressTxIdsCmd struct {
 	Request *AddressTxRequest
 }
 
+// ScanTxOutSetCmd defines the scantxoutset JSON-RPC command.
+type ScanTxOutSetCmd struct {
+	Action      string
+	ScanObjects *[]string
+}
+
+// NewScanTxOutSetCmd returns a new instance which can be used to issue a
+// scantxoutset JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewScanTxOutSetCmd(action string, scanObjects *[]string) *ScanTxOutSetCmd {
+	return &ScanTxOutSetCmd{
+		Action:      action,
+		ScanObjects: scanObjects,
+	}
+}
+
 // SearchRawTransactionsCmd defines the searchrawtransactions JSON-RPC command.
 type SearchRawTransactionsCmd struct {
 	Address     string
@@ -624,6 +1250,56 @@ func NewSearchRawTransactionsCmd(address string, verbose, skip, count *int, vinE
 	}
 }
 
+// SearchRawTransactionsByAddressCmd defines the
+// searchrawtransactionsbyaddress JSON-RPC command.
+type SearchRawTransactionsByAddressCmd struct {
+	Address      string
+	Skip         *int  `jsonrpcdefault:"0"`
+	Count        *int  `jsonrpcdefault:"100"`
+	Reverse      *bool `jsonrpcdefault:"false"`
+	IncludeTotal *bool `jsonrpcdefault:"false"`
+}
+
+// NewSearchRawTransactionsByAddressCmd returns a new instance which can be used
+// to issue a searchrawtransactionsbyaddress JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSearchRawTransactionsByAddressCmd(address string, skip, count *int, reverse, includeTotal *bool) *SearchRawTransactionsByAddressCmd {
+	return &SearchRawTransactionsByAddressCmd{
+		Address:      address,
+		Skip:         skip,
+		Count:        count,
+		Reverse:      reverse,
+		IncludeTotal: includeTotal,
+	}
+}
+
+// SearchRawTransactionsByKeyIDCmd defines the searchrawtransactionsbykeyid
+// JSON-RPC command.
+type SearchRawTransactionsByKeyIDCmd struct {
+	KeyID   uint32
+	Verbose *int  `jsonrpcdefault:"1"`
+	Skip    *int  `jsonrpcdefault:"0"`
+	Count   *int  `jsonrpcdefault:"100"`
+	Reverse *bool `jsonrpcdefault:"false"`
+}
+
+// NewSearchRawTransactionsByKeyIDCmd returns a new instance which can be used
+// to issue a searchrawtransactionsbykeyid JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSearchRawTransactionsByKeyIDCmd(keyID uint32, verbose, skip, count *int, reverse *bool) *SearchRawTransactionsByKeyIDCmd {
+	return &SearchRawTransactionsByKeyIDCmd{
+		KeyID:   keyID,
+		Verbose: verbose,
+		Skip:    skip,
+		Count:   count,
+		Reverse: reverse,
+	}
+}
+
 // SendRawTransactionCmd defines the sendrawtransaction JSON-RPC command.
 type SendRawTransactionCmd struct {
 	HexTx         string
@@ -642,6 +1318,43 @@ func NewSendRawTransactionCmd(hexTx string, allowHighFees *bool) *SendRawTransac
 	}
 }
 
+// SetBanSubCmd defines the type used in the setban JSON-RPC command for the
+// sub command field.
+type SetBanSubCmd string
+
+const (
+	// SBAdd indicates the specified subnet should be banned.
+	SBAdd SetBanSubCmd = "add"
+
+	// SBRemove indicates the ban of the specified subnet should be
+	// removed.
+	SBRemove SetBanSubCmd = "remove"
+)
+
+// SetBanCmd defines the setban JSON-RPC command.
+type SetBanCmd struct {
+	Subnet   string
+	SubCmd   SetBanSubCmd `jsonrpcusage:"\"add|remove\""`
+	BanTime  *int64       `jsonrpcdefault:"0"`
+	Absolute *bool        `jsonrpcdefault:"false"`
+}
+
+// NewSetBanCmd returns a new instance which can be used to issue a setban
+// JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSetBanCmd(subnet string, subCmd SetBanSubCmd, banTime *int64,
+	absolute *bool) *SetBanCmd {
+
+	return &SetBanCmd{
+		Subnet:   subnet,
+		SubCmd:   subCmd,
+		BanTime:  banTime,
+		Absolute: absolute,
+	}
+}
+
 // SetGenerateCmd defines the setgenerate JSON-RPC command.
 type SetGenerateCmd struct {
 	Generate     bool
@@ -660,6 +1373,36 @@ func NewSetGenerateCmd(generate bool, genProcLimit *int) *SetGenerateCmd {
 	}
 }
 
+// PrevTxOutput models an output spent by an input of the transaction signed by
+// the signrawtransactionwithkey JSON-RPC command.
+type PrevTxOutput struct {
+	Txid         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	ScriptPubKey string  `json:"scriptPubKey"`
+	Amount       float64 `json:"amount"`
+}
+
+// SignRawTransactionWithKeyCmd defines the signrawtransactionwithkey JSON-RPC
+// command.
+type SignRawTransactionWithKeyCmd struct {
+	HexTx    string
+	PrivKeys []string
+	PrevTxs  *[]PrevTxOutput
+}
+
+// NewSignRawTransactionWithKeyCmd returns a new instance which can be used to
+// issue a signrawtransactionwithkey JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSignRawTransactionWithKeyCmd(hexTx string, privKeys []string, prevTxs *[]PrevTxOutput) *SignRawTransactionWithKeyCmd {
+	return &SignRawTransactionWithKeyCmd{
+		HexTx:    hexTx,
+		PrivKeys: privKeys,
+		PrevTxs:  prevTxs,
+	}
+}
+
 // StopCmd defines the stop JSON-RPC command.
 type StopCmd struct{}
 
@@ -694,6 +1437,32 @@ func NewSubmitBlockCmd(hexBlock string, options *SubmitBlockOptions) *SubmitBloc
 	}
 }
 
+// TestBlockValidityCmd defines the testblockvalidity JSON-RPC command.
+type TestBlockValidityCmd struct {
+	HexBlock string
+}
+
+// NewTestBlockValidityCmd returns a new instance which can be used to issue a
+// testblockvalidity JSON-RPC command.
+func NewTestBlockValidityCmd(hexBlock string) *TestBlockValidityCmd {
+	return &TestBlockValidityCmd{
+		HexBlock: hexBlock,
+	}
+}
+
+// UnwatchAddressesCmd defines the unwatchaddresses JSON-RPC command.
+type UnwatchAddressesCmd struct {
+	Addresses []string
+}
+
+// NewUnwatchAddressesCmd returns a new instance which can be used to issue an
+// unwatchaddresses JSON-RPC command.
+func NewUnwatchAddressesCmd(addresses []string) *UnwatchAddressesCmd {
+	return &UnwatchAddressesCmd{
+		Addresses: addresses,
+	}
+}
+
 // ValidateAddressCmd defines the validateaddress JSON-RPC command.
 type ValidateAddressCmd struct {
 	Address string
@@ -725,6 +1494,26 @@ func NewVerifyChainCmd(checkLevel, checkDepth *int32) *VerifyChainCmd {
 	}
 }
 
+// VerifyChainDBCmd defines the verifychaindb JSON-RPC command.
+type VerifyChainDBCmd struct {
+	CheckLevel  *int32 `jsonrpcdefault:"3"`
+	CheckBlocks *int32 `jsonrpcdefault:"6"` // 0 = all
+	Repair      *bool  `jsonrpcdefault:"false"`
+}
+
+// NewVerifyChainDBCmd returns a new instance which can be used to issue a
+// verifychaindb JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewVerifyChainDBCmd(checkLevel, checkBlocks *int32, repair *bool) *VerifyChainDBCmd {
+	return &VerifyChainDBCmd{
+		CheckLevel:  checkLevel,
+		CheckBlocks: checkBlocks,
+		Repair:      repair,
+	}
+}
+
 // VerifyMessageCmd defines the verifymessage JSON-RPC command.
 type VerifyMessageCmd struct {
 	Address   string
@@ -755,15 +1544,44 @@ func NewVerifyTxOutProofCmd(proof string) *VerifyTxOutProofCmd {
 	}
 }
 
+// WatchAddressesCmd defines the watchaddresses JSON-RPC command.
+type WatchAddressesCmd struct {
+	Addresses []string
+}
+
+// NewWatchAddressesCmd returns a new instance which can be used to issue a
+// watchaddresses JSON-RPC command.
+func NewWatchAddressesCmd(addresses []string) *WatchAddressesCmd {
+	return &WatchAddressesCmd{
+		Addresses: addresses,
+	}
+}
+
 func init() {
 	// No special flags for commands in this file.
 	flags := UsageFlag(0)
 
+	MustRegisterCmd("abortrescan", (*AbortRescanCmd)(nil), flags)
 	MustRegisterCmd("addnode", (*AddNodeCmd)(nil), flags)
+	MustRegisterCmd("backupchainstate", (*BackupChainStateCmd)(nil), flags)
+	MustRegisterCmd("clearbanned", (*ClearBannedCmd)(nil), flags)
+	MustRegisterCmd("combinepspt", (*CombinePSPTCmd)(nil), flags)
+	MustRegisterCmd("createpspt", (*CreatePSPTCmd)(nil), flags)
+	MustRegisterCmd("createrawadmintransaction", (*CreateRawAdminTransactionCmd)(nil), flags)
 	MustRegisterCmd("createrawtransaction", (*CreateRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("debugscript", (*DebugScriptCmd)(nil), flags)
+	MustRegisterCmd("decodeadmintransaction", (*DecodeAdminTransactionCmd)(nil), flags)
+	MustRegisterCmd("decodepspt", (*DecodePSPTCmd)(nil), flags)
 	MustRegisterCmd("decoderawtransaction", (*DecodeRawTransactionCmd)(nil), flags)
 	MustRegisterCmd("decodescript", (*DecodeScriptCmd)(nil), flags)
+	MustRegisterCmd("dumputxosnapshot", (*DumpUTXOSnapshotCmd)(nil), flags)
+	MustRegisterCmd("estimatefee", (*EstimateFeeCmd)(nil), flags)
+	MustRegisterCmd("estimatesmartfee", (*EstimateSmartFeeCmd)(nil), flags)
+	MustRegisterCmd("finalizepspt", (*FinalizePSPTCmd)(nil), flags)
+	MustRegisterCmd("getaddressbalance", (*GetAddressBalanceCmd)(nil), flags)
+	MustRegisterCmd("getaddressissuance", (*GetAddressIssuanceCmd)(nil), flags)
 	MustRegisterCmd("getaddresstxids", (*GetAddressTxIdsCmd)(nil), flags)
+	MustRegisterCmd("getaddressutxos", (*GetAddressUtxosCmd)(nil), flags)
 	MustRegisterCmd("getaddednodeinfo", (*GetAddedNodeInfoCmd)(nil), flags)
 	MustRegisterCmd("getadmininfo", (*GetAdminInfoCmd)(nil), flags)
 	MustRegisterCmd("getbestblockhash", (*GetBestBlockHashCmd)(nil), flags)
@@ -771,14 +1589,26 @@ func init() {
 	MustRegisterCmd("getblockchaininfo", (*GetBlockChainInfoCmd)(nil), flags)
 	MustRegisterCmd("getblockcount", (*GetBlockCountCmd)(nil), flags)
 	MustRegisterCmd("getblockhash", (*GetBlockHashCmd)(nil), flags)
+	MustRegisterCmd("getblockhashbytime", (*GetBlockHashByTimeCmd)(nil), flags)
 	MustRegisterCmd("getblockheader", (*GetBlockHeaderCmd)(nil), flags)
+	MustRegisterCmd("getblockscrubinfo", (*GetBlockScrubInfoCmd)(nil), flags)
+	MustRegisterCmd("getblockstats", (*GetBlockStatsCmd)(nil), flags)
 	MustRegisterCmd("getblocktemplate", (*GetBlockTemplateCmd)(nil), flags)
+	MustRegisterCmd("getcfheaders", (*GetCFHeadersCmd)(nil), flags)
+	MustRegisterCmd("getcfilter", (*GetCFilterCmd)(nil), flags)
 	MustRegisterCmd("getchaintips", (*GetChainTipsCmd)(nil), flags)
+	MustRegisterCmd("getcheckpointcandidates", (*GetCheckpointCandidatesCmd)(nil), flags)
 	MustRegisterCmd("getconnectioncount", (*GetConnectionCountCmd)(nil), flags)
 	MustRegisterCmd("getdifficulty", (*GetDifficultyCmd)(nil), flags)
 	MustRegisterCmd("getgenerate", (*GetGenerateCmd)(nil), flags)
 	MustRegisterCmd("gethashespersec", (*GetHashesPerSecCmd)(nil), flags)
+	MustRegisterCmd("getindexinfo", (*GetIndexInfoCmd)(nil), flags)
 	MustRegisterCmd("getinfo", (*GetInfoCmd)(nil), flags)
+	MustRegisterCmd("getissuanceinfo", (*GetIssuanceInfoCmd)(nil), flags)
+	MustRegisterCmd("getkeyidactivity", (*GetKeyIDActivityCmd)(nil), flags)
+	MustRegisterCmd("getkeyidinfo", (*GetKeyIDInfoCmd)(nil), flags)
+	MustRegisterCmd("getmempoolancestors", (*GetMempoolAncestorsCmd)(nil), flags)
+	MustRegisterCmd("getmempooldescendants", (*GetMempoolDescendantsCmd)(nil), flags)
 	MustRegisterCmd("getmempoolentry", (*GetMempoolEntryCmd)(nil), flags)
 	MustRegisterCmd("getmempoolinfo", (*GetMempoolInfoCmd)(nil), flags)
 	MustRegisterCmd("getmininginfo", (*GetMiningInfoCmd)(nil), flags)
@@ -788,22 +1618,42 @@ func init() {
 	MustRegisterCmd("getpeerinfo", (*GetPeerInfoCmd)(nil), flags)
 	MustRegisterCmd("getrawmempool", (*GetRawMempoolCmd)(nil), flags)
 	MustRegisterCmd("getrawtransaction", (*GetRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("getrescaninfo", (*GetRescanInfoCmd)(nil), flags)
+	MustRegisterCmd("getspentinfo", (*GetSpentInfoCmd)(nil), flags)
 	MustRegisterCmd("gettxout", (*GetTxOutCmd)(nil), flags)
 	MustRegisterCmd("gettxoutproof", (*GetTxOutProofCmd)(nil), flags)
 	MustRegisterCmd("gettxoutsetinfo", (*GetTxOutSetInfoCmd)(nil), flags)
+	MustRegisterCmd("gettxspendinginfo", (*GetTxSpendingInfoCmd)(nil), flags)
+	MustRegisterCmd("getvalidationtimings", (*GetValidationTimingsCmd)(nil), flags)
+	MustRegisterCmd("getvalidatorstats", (*GetValidatorStatsCmd)(nil), flags)
+	MustRegisterCmd("getwatchedbalance", (*GetWatchedBalanceCmd)(nil), flags)
+	MustRegisterCmd("getwatchedhistory", (*GetWatchedHistoryCmd)(nil), flags)
+	MustRegisterCmd("getwatchedutxos", (*GetWatchedUtxosCmd)(nil), flags)
 	MustRegisterCmd("getwork", (*GetWorkCmd)(nil), flags)
 	MustRegisterCmd("help", (*HelpCmd)(nil), flags)
 	MustRegisterCmd("invalidateblock", (*InvalidateBlockCmd)(nil), flags)
+	MustRegisterCmd("listadminoperations", (*ListAdminOperationsCmd)(nil), flags)
+	MustRegisterCmd("listbanned", (*ListBannedCmd)(nil), flags)
+	MustRegisterCmd("listwatched", (*ListWatchedCmd)(nil), flags)
 	MustRegisterCmd("ping", (*PingCmd)(nil), flags)
 	MustRegisterCmd("preciousblock", (*PreciousBlockCmd)(nil), flags)
 	MustRegisterCmd("reconsiderblock", (*ReconsiderBlockCmd)(nil), flags)
+	MustRegisterCmd("scantxoutset", (*ScanTxOutSetCmd)(nil), flags)
 	MustRegisterCmd("searchrawtransactions", (*SearchRawTransactionsCmd)(nil), flags)
+	MustRegisterCmd("searchrawtransactionsbyaddress", (*SearchRawTransactionsByAddressCmd)(nil), flags)
+	MustRegisterCmd("searchrawtransactionsbykeyid", (*SearchRawTransactionsByKeyIDCmd)(nil), flags)
 	MustRegisterCmd("sendrawtransaction", (*SendRawTransactionCmd)(nil), flags)
+	MustRegisterCmd("setban", (*SetBanCmd)(nil), flags)
 	MustRegisterCmd("setgenerate", (*SetGenerateCmd)(nil), flags)
+	MustRegisterCmd("signrawtransactionwithkey", (*SignRawTransactionWithKeyCmd)(nil), flags)
 	MustRegisterCmd("stop", (*StopCmd)(nil), flags)
 	MustRegisterCmd("submitblock", (*SubmitBlockCmd)(nil), flags)
+	MustRegisterCmd("testblockvalidity", (*TestBlockValidityCmd)(nil), flags)
+	MustRegisterCmd("unwatchaddresses", (*UnwatchAddressesCmd)(nil), flags)
 	MustRegisterCmd("validateaddress", (*ValidateAddressCmd)(nil), flags)
 	MustRegisterCmd("verifychain", (*VerifyChainCmd)(nil), flags)
+	MustRegisterCmd("verifychaindb", (*VerifyChainDBCmd)(nil), flags)
 	MustRegisterCmd("verifymessage", (*VerifyMessageCmd)(nil), flags)
 	MustRegisterCmd("verifytxoutproof", (*VerifyTxOutProofCmd)(nil), flags)
+	MustRegisterCmd("watchaddresses", (*WatchAddressesCmd)(nil), flags)
 }
@@ -30,6 +30,17 @@ func TestChainSvrCmds(t *testing.T) {
 		marshalled   string
 		unmarshalled interface{}
 	}{
+		{
+			name: "abortrescan",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("abortrescan", "job1")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewAbortRescanCmd("job1")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"abortrescan","params":["job1"],"id":1}`,
+			unmarshalled: &btcjson.AbortRescanCmd{JobID: "job1"},
+		},
 		{
 			name: "addnode",
 			newCmd: func() (interface{}, error) {
@@ -41,6 +52,37 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"addnode","params":["127.0.0.1","remove"],"id":1}`,
 			unmarshalled: &btcjson.AddNodeCmd{Addr: "127.0.0.1", SubCmd: btcjson.ANRemove},
 		},
+		{
+			name: "backupchainstate",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("backupchainstate", "backup")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewBackupChainStateCmd("backup")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"backupchainstate","params":["backup"],"id":1}`,
+			unmarshalled: &btcjson.BackupChainStateCmd{Path: "backup"},
+		},
+		{
+			name: "createrawadmintransaction",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createrawadmintransaction", "issue",
+					`{"amounts":{"456":0.0123}}`)
+			},
+			staticCmd: func() interface{} {
+				params := btcjson.CreateRawAdminTransactionParams{
+					Amounts: map[string]float64{"456": .0123},
+				}
+				return btcjson.NewCreateRawAdminTransactionCmd("issue", params)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"createrawadmintransaction","params":["issue",{"amounts":{"456":0.0123}}],"id":1}`,
+			unmarshalled: &btcjson.CreateRawAdminTransactionCmd{
+				Operation: "issue",
+				Params: btcjson.CreateRawAdminTransactionParams{
+					Amounts: map[string]float64{"456": .0123},
+				},
+			},
+		},
 		{
 			name: "createrawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -52,7 +94,7 @@ func TestChainSvrCmds(t *testing.T) {
 					{Txid: "123", Vout: 1},
 				}
 				amounts := map[string]float64{"456": .0123}
-				return btcjson.NewCreateRawTransactionCmd(txInputs, amounts, nil)
+				return btcjson.NewCreateRawTransactionCmd(txInputs, amounts, nil, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"createrawtransaction","params":[[{"txid":"123","vout":1}],{"456":0.0123}],"id":1}`,
 			unmarshalled: &btcjson.CreateRawTransactionCmd{
@@ -71,7 +113,7 @@ func TestChainSvrCmds(t *testing.T) {
 					{Txid: "123", Vout: 1},
 				}
 				amounts := map[string]float64{"456": .0123}
-				return btcjson.NewCreateRawTransactionCmd(txInputs, amounts, btcjson.Int64(12312333333))
+				return btcjson.NewCreateRawTransactionCmd(txInputs, amounts, btcjson.Int64(12312333333), nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"createrawtransaction","params":[[{"txid":"123","vout":1}],{"456":0.0123},12312333333],"id":1}`,
 			unmarshalled: &btcjson.CreateRawTransactionCmd{
@@ -80,6 +122,39 @@ func TestChainSvrCmds(t *testing.T) {
 				LockTime: btcjson.Int64(12312333333),
 			},
 		},
+		{
+			name: "createrawtransaction data",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createrawtransaction", `[{"txid":"123","vout":1}]`,
+					`{"456":0.0123}`, int64(0), "deadbeef")
+			},
+			staticCmd: func() interface{} {
+				txInputs := []btcjson.TransactionInput{
+					{Txid: "123", Vout: 1},
+				}
+				amounts := map[string]float64{"456": .0123}
+				return btcjson.NewCreateRawTransactionCmd(txInputs, amounts,
+					btcjson.Int64(0), btcjson.String("deadbeef"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"createrawtransaction","params":[[{"txid":"123","vout":1}],{"456":0.0123},0,"deadbeef"],"id":1}`,
+			unmarshalled: &btcjson.CreateRawTransactionCmd{
+				Inputs:   []btcjson.TransactionInput{{Txid: "123", Vout: 1}},
+				Amounts:  map[string]float64{"456": .0123},
+				LockTime: btcjson.Int64(0),
+				Data:     btcjson.String("deadbeef"),
+			},
+		},
+		{
+			name: "decodeadmintransaction",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("decodeadmintransaction", "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDecodeAdminTransactionCmd("123")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"decodeadmintransaction","params":["123"],"id":1}`,
+			unmarshalled: &btcjson.DecodeAdminTransactionCmd{HexTx: "123"},
+		},
 		{
 			name: "decoderawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -91,16 +166,273 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"decoderawtransaction","params":["123"],"id":1}`,
 			unmarshalled: &btcjson.DecodeRawTransactionCmd{HexTx: "123"},
 		},
+		{
+			name: "clearbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("clearbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewClearBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"clearbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ClearBannedCmd{},
+		},
+		{
+			name: "combinepspt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("combinepspt", []string{"cHNwdP8B", "cHNwdP8C"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCombinePSPTCmd([]string{"cHNwdP8B", "cHNwdP8C"})
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"combinepspt","params":[["cHNwdP8B","cHNwdP8C"]],"id":1}`,
+			unmarshalled: &btcjson.CombinePSPTCmd{PSPTs: []string{"cHNwdP8B", "cHNwdP8C"}},
+		},
+		{
+			name: "createpspt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("createpspt", "0102")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewCreatePSPTCmd("0102")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"createpspt","params":["0102"],"id":1}`,
+			unmarshalled: &btcjson.CreatePSPTCmd{HexTx: "0102"},
+		},
+		{
+			name: "decodepspt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("decodepspt", "cHNwdP8B")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDecodePSPTCmd("cHNwdP8B")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"decodepspt","params":["cHNwdP8B"],"id":1}`,
+			unmarshalled: &btcjson.DecodePSPTCmd{PSPT: "cHNwdP8B"},
+		},
+		{
+			name: "finalizepspt",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("finalizepspt", "cHNwdP8B")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewFinalizePSPTCmd("cHNwdP8B")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"finalizepspt","params":["cHNwdP8B"],"id":1}`,
+			unmarshalled: &btcjson.FinalizePSPTCmd{PSPT: "cHNwdP8B"},
+		},
+		{
+			name: "debugscript",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("debugscript", "0102", 1)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDebugScriptCmd("0102", 1, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"debugscript","params":["0102",1],"id":1}`,
+			unmarshalled: &btcjson.DebugScriptCmd{
+				HexTx: "0102",
+				Vin:   1,
+			},
+		},
+		{
+			name: "debugscript optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("debugscript", "0102", 1, "51", 0.5)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDebugScriptCmd("0102", 1,
+					btcjson.String("51"), btcjson.Float64(0.5))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"debugscript","params":["0102",1,"51",0.5],"id":1}`,
+			unmarshalled: &btcjson.DebugScriptCmd{
+				HexTx:        "0102",
+				Vin:          1,
+				ScriptPubKey: btcjson.String("51"),
+				Amount:       btcjson.Float64(0.5),
+			},
+		},
 		{
 			name: "decodescript",
 			newCmd: func() (interface{}, error) {
 				return btcjson.NewCmd("decodescript", "00")
 			},
 			staticCmd: func() interface{} {
-				return btcjson.NewDecodeScriptCmd("00")
+				return btcjson.NewDecodeScriptCmd("00", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"decodescript","params":["00"],"id":1}`,
+			unmarshalled: &btcjson.DecodeScriptCmd{
+				HexScript: "00",
+				Annotated: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "decodescript optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("decodescript", "00", true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDecodeScriptCmd("00", btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"decodescript","params":["00",true],"id":1}`,
+			unmarshalled: &btcjson.DecodeScriptCmd{
+				HexScript: "00",
+				Annotated: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "dumputxosnapshot",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("dumputxosnapshot", "snapshot.dat")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDumpUTXOSnapshotCmd("snapshot.dat", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"dumputxosnapshot","params":["snapshot.dat"],"id":1}`,
+			unmarshalled: &btcjson.DumpUTXOSnapshotCmd{
+				Path:   "snapshot.dat",
+				Height: nil,
+			},
+		},
+		{
+			name: "dumputxosnapshot optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("dumputxosnapshot", "snapshot.dat", 100)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewDumpUTXOSnapshotCmd("snapshot.dat",
+					btcjson.Uint32(100))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"dumputxosnapshot","params":["snapshot.dat",100],"id":1}`,
+			unmarshalled: &btcjson.DumpUTXOSnapshotCmd{
+				Path:   "snapshot.dat",
+				Height: btcjson.Uint32(100),
+			},
+		},
+		{
+			name: "estimatefee",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("estimatefee", 6)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewEstimateFeeCmd(6)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"estimatefee","params":[6],"id":1}`,
+			unmarshalled: &btcjson.EstimateFeeCmd{
+				NumBlocks: 6,
+			},
+		},
+		{
+			name: "estimatesmartfee",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("estimatesmartfee", 6)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewEstimateSmartFeeCmd(6, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"estimatesmartfee","params":[6],"id":1}`,
+			unmarshalled: &btcjson.EstimateSmartFeeCmd{
+				ConfTarget:   6,
+				EstimateMode: btcjson.String("CONSERVATIVE"),
+			},
+		},
+		{
+			name: "estimatesmartfee optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("estimatesmartfee", 6, "ECONOMICAL")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewEstimateSmartFeeCmd(6,
+					btcjson.String("ECONOMICAL"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"estimatesmartfee","params":[6,"ECONOMICAL"],"id":1}`,
+			unmarshalled: &btcjson.EstimateSmartFeeCmd{
+				ConfTarget:   6,
+				EstimateMode: btcjson.String("ECONOMICAL"),
+			},
+		},
+		{
+			name: "getaddressbalance",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressbalance", "1Address")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressBalanceCmd("1Address")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getaddressbalance","params":["1Address"],"id":1}`,
+			unmarshalled: &btcjson.GetAddressBalanceCmd{Address: "1Address"},
+		},
+		{
+			name: "getaddressutxos",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressutxos", "1Address")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressUtxosCmd("1Address")
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getaddressutxos","params":["1Address"],"id":1}`,
+			unmarshalled: &btcjson.GetAddressUtxosCmd{Address: "1Address"},
+		},
+		{
+			name: "watchaddresses",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("watchaddresses", []string{"1Address", "7"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewWatchAddressesCmd([]string{"1Address", "7"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"watchaddresses","params":[["1Address","7"]],"id":1}`,
+			unmarshalled: &btcjson.WatchAddressesCmd{
+				Addresses: []string{"1Address", "7"},
+			},
+		},
+		{
+			name: "getwatchedhistory",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getwatchedhistory", "1Address", 5)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetWatchedHistoryCmd("1Address",
+					btcjson.Int(5), nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getwatchedhistory","params":["1Address",5],"id":1}`,
+			unmarshalled: &btcjson.GetWatchedHistoryCmd{
+				Address: "1Address",
+				Skip:    btcjson.Int(5),
+				Count:   btcjson.Int(100),
+				Reverse: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "getaddressissuance",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressissuance", "1Address")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressIssuanceCmd("1Address", nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressissuance","params":["1Address"],"id":1}`,
+			unmarshalled: &btcjson.GetAddressIssuanceCmd{
+				Address:     "1Address",
+				StartHeight: btcjson.Uint32(0),
+				EndHeight:   nil,
+			},
+		},
+		{
+			name: "getaddressissuance optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getaddressissuance", "1Address", 100, 200)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetAddressIssuanceCmd("1Address",
+					btcjson.Uint32(100), btcjson.Uint32(200))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getaddressissuance","params":["1Address",100,200],"id":1}`,
+			unmarshalled: &btcjson.GetAddressIssuanceCmd{
+				Address:     "1Address",
+				StartHeight: btcjson.Uint32(100),
+				EndHeight:   btcjson.Uint32(200),
 			},
-			marshalled:   `{"jsonrpc":"1.0","method":"decodescript","params":["00"],"id":1}`,
-			unmarshalled: &btcjson.DecodeScriptCmd{HexScript: "00"},
 		},
 		{
 			name: "getaddednodeinfo",
@@ -231,6 +563,17 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getblockhash","params":[123],"id":1}`,
 			unmarshalled: &btcjson.GetBlockHashCmd{Index: 123},
 		},
+		{
+			name: "getblockhashbytime",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockhashbytime", 1483228800)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockHashByTimeCmd(1483228800)
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getblockhashbytime","params":[1483228800],"id":1}`,
+			unmarshalled: &btcjson.GetBlockHashByTimeCmd{Timestamp: 1483228800},
+		},
 		{
 			name: "getblockheader",
 			newCmd: func() (interface{}, error) {
@@ -245,6 +588,87 @@ func TestChainSvrCmds(t *testing.T) {
 				Verbose: btcjson.Bool(true),
 			},
 		},
+		{
+			name: "getcfilter",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getcfilter", "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetCFilterCmd("123", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcfilter","params":["123"],"id":1}`,
+			unmarshalled: &btcjson.GetCFilterCmd{
+				Hash:       "123",
+				FilterType: btcjson.Uint8(0),
+			},
+		},
+		{
+			name: "getcfilter optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getcfilter", "123", 1)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetCFilterCmd("123", btcjson.Uint8(1))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcfilter","params":["123",1],"id":1}`,
+			unmarshalled: &btcjson.GetCFilterCmd{
+				Hash:       "123",
+				FilterType: btcjson.Uint8(1),
+			},
+		},
+		{
+			name: "getcfheaders",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getcfheaders", 10, "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetCFHeadersCmd(10, "123", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcfheaders","params":[10,"123"],"id":1}`,
+			unmarshalled: &btcjson.GetCFHeadersCmd{
+				StartHeight: 10,
+				StopHash:    "123",
+				FilterType:  btcjson.Uint8(0),
+			},
+		},
+		{
+			name: "getblockscrubinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockscrubinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockScrubInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getblockscrubinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetBlockScrubInfoCmd{},
+		},
+		{
+			name: "getblockstats",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockstats", "1000")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockStatsCmd("1000", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockstats","params":["1000"],"id":1}`,
+			unmarshalled: &btcjson.GetBlockStatsCmd{
+				HashOrHeight: "1000",
+			},
+		},
+		{
+			name: "getblockstats optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getblockstats", "1000", 1010)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetBlockStatsCmd("1000", btcjson.Uint32(1010))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getblockstats","params":["1000",1010],"id":1}`,
+			unmarshalled: &btcjson.GetBlockStatsCmd{
+				HashOrHeight: "1000",
+				EndHeight:    btcjson.Uint32(1010),
+			},
+		},
 		{
 			name: "getblocktemplate",
 			newCmd: func() (interface{}, error) {
@@ -339,6 +763,32 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getchaintips","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetChainTipsCmd{},
 		},
+		{
+			name: "getcheckpointcandidates",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getcheckpointcandidates")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetCheckpointCandidatesCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcheckpointcandidates","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetCheckpointCandidatesCmd{
+				Count: btcjson.Int(5),
+			},
+		},
+		{
+			name: "getcheckpointcandidates optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getcheckpointcandidates", 10)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetCheckpointCandidatesCmd(btcjson.Int(10))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getcheckpointcandidates","params":[10],"id":1}`,
+			unmarshalled: &btcjson.GetCheckpointCandidatesCmd{
+				Count: btcjson.Int(10),
+			},
+		},
 		{
 			name: "getconnectioncount",
 			newCmd: func() (interface{}, error) {
@@ -383,6 +833,28 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"gethashespersec","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetHashesPerSecCmd{},
 		},
+		{
+			name: "getindexinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getindexinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetIndexInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getindexinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetIndexInfoCmd{},
+		},
+		{
+			name: "getissuanceinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getissuanceinfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetIssuanceInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getissuanceinfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetIssuanceInfoCmd{},
+		},
 		{
 			name: "getinfo",
 			newCmd: func() (interface{}, error) {
@@ -394,6 +866,108 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"getinfo","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetInfoCmd{},
 		},
+		{
+			name: "getkeyidactivity",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getkeyidactivity", 3)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetKeyIDActivityCmd(3, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getkeyidactivity","params":[3],"id":1}`,
+			unmarshalled: &btcjson.GetKeyIDActivityCmd{
+				KeyID:       3,
+				StartHeight: btcjson.Uint32(0),
+				EndHeight:   nil,
+			},
+		},
+		{
+			name: "getkeyidactivity optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getkeyidactivity", 3, 100, 200)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetKeyIDActivityCmd(3, btcjson.Uint32(100),
+					btcjson.Uint32(200))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getkeyidactivity","params":[3,100,200],"id":1}`,
+			unmarshalled: &btcjson.GetKeyIDActivityCmd{
+				KeyID:       3,
+				StartHeight: btcjson.Uint32(100),
+				EndHeight:   btcjson.Uint32(200),
+			},
+		},
+		{
+			name: "getkeyidinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getkeyidinfo", 3)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetKeyIDInfoCmd(3)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getkeyidinfo","params":[3],"id":1}`,
+			unmarshalled: &btcjson.GetKeyIDInfoCmd{
+				KeyID: 3,
+			},
+		},
+		{
+			name: "getmempoolancestors",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getmempoolancestors", "txhash")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMempoolAncestorsCmd("txhash", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempoolancestors","params":["txhash"],"id":1}`,
+			unmarshalled: &btcjson.GetMempoolAncestorsCmd{
+				TxID:    "txhash",
+				Verbose: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "getmempoolancestors optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getmempoolancestors", "txhash", true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMempoolAncestorsCmd("txhash",
+					btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempoolancestors","params":["txhash",true],"id":1}`,
+			unmarshalled: &btcjson.GetMempoolAncestorsCmd{
+				TxID:    "txhash",
+				Verbose: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "getmempooldescendants",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getmempooldescendants", "txhash")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMempoolDescendantsCmd("txhash", nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempooldescendants","params":["txhash"],"id":1}`,
+			unmarshalled: &btcjson.GetMempoolDescendantsCmd{
+				TxID:    "txhash",
+				Verbose: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "getmempooldescendants optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getmempooldescendants", "txhash", true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetMempoolDescendantsCmd("txhash",
+					btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getmempooldescendants","params":["txhash",true],"id":1}`,
+			unmarshalled: &btcjson.GetMempoolDescendantsCmd{
+				TxID:    "txhash",
+				Verbose: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "getmempoolentry",
 			newCmd: func() (interface{}, error) {
@@ -558,6 +1132,31 @@ func TestChainSvrCmds(t *testing.T) {
 				Verbose: btcjson.Int(1),
 			},
 		},
+		{
+			name: "getrescaninfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getrescaninfo")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetRescanInfoCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getrescaninfo","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetRescanInfoCmd{},
+		},
+		{
+			name: "getspentinfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getspentinfo", "123", 1)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetSpentInfoCmd("123", 1)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"getspentinfo","params":["123",1],"id":1}`,
+			unmarshalled: &btcjson.GetSpentInfoCmd{
+				Txid:  "123",
+				Index: 1,
+			},
+		},
 		{
 			name: "gettxout",
 			newCmd: func() (interface{}, error) {
@@ -629,6 +1228,41 @@ func TestChainSvrCmds(t *testing.T) {
 			marshalled:   `{"jsonrpc":"1.0","method":"gettxoutsetinfo","params":[],"id":1}`,
 			unmarshalled: &btcjson.GetTxOutSetInfoCmd{},
 		},
+		{
+			name: "gettxspendinginfo",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("gettxspendinginfo", "123")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetTxSpendingInfoCmd("123")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"gettxspendinginfo","params":["123"],"id":1}`,
+			unmarshalled: &btcjson.GetTxSpendingInfoCmd{
+				Txid: "123",
+			},
+		},
+		{
+			name: "getvalidationtimings",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getvalidationtimings")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetValidationTimingsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getvalidationtimings","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetValidationTimingsCmd{},
+		},
+		{
+			name: "getvalidatorstats",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("getvalidatorstats")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewGetValidatorStatsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"getvalidatorstats","params":[],"id":1}`,
+			unmarshalled: &btcjson.GetValidatorStatsCmd{},
+		},
 		{
 			name: "getwork",
 			newCmd: func() (interface{}, error) {
@@ -694,6 +1328,52 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "listadminoperations",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listadminoperations")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListAdminOperationsCmd(nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"listadminoperations","params":[],"id":1}`,
+			unmarshalled: &btcjson.ListAdminOperationsCmd{
+				Filter: nil,
+			},
+		},
+		{
+			name: "listadminoperations optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listadminoperations",
+					`{"types":["issue","destroy"],"keyid":2,"startheight":10}`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListAdminOperationsCmd(&btcjson.AdminOperationFilter{
+					Types:       []string{"issue", "destroy"},
+					KeyID:       btcjson.Uint32(2),
+					StartHeight: 10,
+				})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"listadminoperations","params":[{"types":["issue","destroy"],"keyid":2,"startheight":10}],"id":1}`,
+			unmarshalled: &btcjson.ListAdminOperationsCmd{
+				Filter: &btcjson.AdminOperationFilter{
+					Types:       []string{"issue", "destroy"},
+					KeyID:       btcjson.Uint32(2),
+					StartHeight: 10,
+				},
+			},
+		},
+		{
+			name: "listbanned",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("listbanned")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewListBannedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"listbanned","params":[],"id":1}`,
+			unmarshalled: &btcjson.ListBannedCmd{},
+		},
 		{
 			name: "ping",
 			newCmd: func() (interface{}, error) {
@@ -731,6 +1411,20 @@ func TestChainSvrCmds(t *testing.T) {
 				BlockHash: "123",
 			},
 		},
+		{
+			name: "scantxoutset",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("scantxoutset", "start", []string{"raw(51)"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewScanTxOutSetCmd("start", &[]string{"raw(51)"})
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"scantxoutset","params":["start",["raw(51)"]],"id":1}`,
+			unmarshalled: &btcjson.ScanTxOutSetCmd{
+				Action:      "start",
+				ScanObjects: &[]string{"raw(51)"},
+			},
+		},
 		{
 			name: "searchrawtransactions",
 			newCmd: func() (interface{}, error) {
@@ -870,6 +1564,78 @@ func TestChainSvrCmds(t *testing.T) {
 				FilterAddrs: &[]string{"1Address"},
 			},
 		},
+		{
+			name: "searchrawtransactionsbyaddress",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("searchrawtransactionsbyaddress", "1Address")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSearchRawTransactionsByAddressCmd("1Address", nil, nil, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"searchrawtransactionsbyaddress","params":["1Address"],"id":1}`,
+			unmarshalled: &btcjson.SearchRawTransactionsByAddressCmd{
+				Address:      "1Address",
+				Skip:         btcjson.Int(0),
+				Count:        btcjson.Int(100),
+				Reverse:      btcjson.Bool(false),
+				IncludeTotal: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "searchrawtransactionsbyaddress optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("searchrawtransactionsbyaddress", "1Address", 5, 10, true, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSearchRawTransactionsByAddressCmd("1Address",
+					btcjson.Int(5), btcjson.Int(10), btcjson.Bool(true),
+					btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"searchrawtransactionsbyaddress","params":["1Address",5,10,true,true],"id":1}`,
+			unmarshalled: &btcjson.SearchRawTransactionsByAddressCmd{
+				Address:      "1Address",
+				Skip:         btcjson.Int(5),
+				Count:        btcjson.Int(10),
+				Reverse:      btcjson.Bool(true),
+				IncludeTotal: btcjson.Bool(true),
+			},
+		},
+		{
+			name: "searchrawtransactionsbykeyid",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("searchrawtransactionsbykeyid", 3)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSearchRawTransactionsByKeyIDCmd(3, nil, nil, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"searchrawtransactionsbykeyid","params":[3],"id":1}`,
+			unmarshalled: &btcjson.SearchRawTransactionsByKeyIDCmd{
+				KeyID:   3,
+				Verbose: btcjson.Int(1),
+				Skip:    btcjson.Int(0),
+				Count:   btcjson.Int(100),
+				Reverse: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "searchrawtransactionsbykeyid optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("searchrawtransactionsbykeyid", 3, 0, 5, 10, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSearchRawTransactionsByKeyIDCmd(3,
+					btcjson.Int(0), btcjson.Int(5), btcjson.Int(10),
+					btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"searchrawtransactionsbykeyid","params":[3,0,5,10,true],"id":1}`,
+			unmarshalled: &btcjson.SearchRawTransactionsByKeyIDCmd{
+				KeyID:   3,
+				Verbose: btcjson.Int(0),
+				Skip:    btcjson.Int(5),
+				Count:   btcjson.Int(10),
+				Reverse: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "sendrawtransaction",
 			newCmd: func() (interface{}, error) {
@@ -898,6 +1664,39 @@ func TestChainSvrCmds(t *testing.T) {
 				AllowHighFees: btcjson.Bool(false),
 			},
 		},
+		{
+			name: "setban",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "10.0.0.0/8", btcjson.SBAdd)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("10.0.0.0/8", btcjson.SBAdd, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["10.0.0.0/8","add"],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				Subnet:   "10.0.0.0/8",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(0),
+				Absolute: btcjson.Bool(false),
+			},
+		},
+		{
+			name: "setban optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("setban", "1.2.3.4", btcjson.SBAdd, 1800000000, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSetBanCmd("1.2.3.4", btcjson.SBAdd,
+					btcjson.Int64(1800000000), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"setban","params":["1.2.3.4","add",1800000000,true],"id":1}`,
+			unmarshalled: &btcjson.SetBanCmd{
+				Subnet:   "1.2.3.4",
+				SubCmd:   btcjson.SBAdd,
+				BanTime:  btcjson.Int64(1800000000),
+				Absolute: btcjson.Bool(true),
+			},
+		},
 		{
 			name: "setgenerate",
 			newCmd: func() (interface{}, error) {
@@ -926,6 +1725,41 @@ func TestChainSvrCmds(t *testing.T) {
 				GenProcLimit: btcjson.Int(6),
 			},
 		},
+		{
+			name: "signrawtransactionwithkey",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("signrawtransactionwithkey", "0102", []string{"privkey"})
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSignRawTransactionWithKeyCmd("0102", []string{"privkey"}, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"signrawtransactionwithkey","params":["0102",["privkey"]],"id":1}`,
+			unmarshalled: &btcjson.SignRawTransactionWithKeyCmd{
+				HexTx:    "0102",
+				PrivKeys: []string{"privkey"},
+			},
+		},
+		{
+			name: "signrawtransactionwithkey optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("signrawtransactionwithkey", "0102", []string{"privkey"},
+					`[{"txid":"123","vout":1,"scriptPubKey":"00","amount":0.5}]`)
+			},
+			staticCmd: func() interface{} {
+				prevTxs := []btcjson.PrevTxOutput{
+					{Txid: "123", Vout: 1, ScriptPubKey: "00", Amount: 0.5},
+				}
+				return btcjson.NewSignRawTransactionWithKeyCmd("0102", []string{"privkey"}, &prevTxs)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"signrawtransactionwithkey","params":["0102",["privkey"],[{"txid":"123","vout":1,"scriptPubKey":"00","amount":0.5}]],"id":1}`,
+			unmarshalled: &btcjson.SignRawTransactionWithKeyCmd{
+				HexTx:    "0102",
+				PrivKeys: []string{"privkey"},
+				PrevTxs: &[]btcjson.PrevTxOutput{
+					{Txid: "123", Vout: 1, ScriptPubKey: "00", Amount: 0.5},
+				},
+			},
+		},
 		{
 			name: "stop",
 			newCmd: func() (interface{}, error) {
@@ -970,6 +1804,19 @@ func TestChainSvrCmds(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "testblockvalidity",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("testblockvalidity", "112233")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewTestBlockValidityCmd("112233")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"testblockvalidity","params":["112233"],"id":1}`,
+			unmarshalled: &btcjson.TestBlockValidityCmd{
+				HexBlock: "112233",
+			},
+		},
 		{
 			name: "validateaddress",
 			newCmd: func() (interface{}, error) {
@@ -1025,6 +1872,37 @@ func TestChainSvrCmds(t *testing.T) {
 				CheckDepth: btcjson.Int32(500),
 			},
 		},
+		{
+			name: "verifychaindb",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("verifychaindb")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewVerifyChainDBCmd(nil, nil, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"verifychaindb","params":[],"id":1}`,
+			unmarshalled: &btcjson.VerifyChainDBCmd{
+				CheckLevel:  btcjson.Int32(3),
+				CheckBlocks: btcjson.Int32(6),
+				Repair:      btcjson.Bool(false),
+			},
+		},
+		{
+			name: "verifychaindb optional",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("verifychaindb", 1, 0, true)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewVerifyChainDBCmd(btcjson.Int32(1),
+					btcjson.Int32(0), btcjson.Bool(true))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"verifychaindb","params":[1,0,true],"id":1}`,
+			unmarshalled: &btcjson.VerifyChainDBCmd{
+				CheckLevel:  btcjson.Int32(1),
+				CheckBlocks: btcjson.Int32(0),
+				Repair:      btcjson.Bool(true),
+			},
+		},
 		{
 			name: "verifymessage",
 			newCmd: func() (interface{}, error) {
@@ -1174,3 +2052,35 @@ func TestChainSvrCmdErrors(t *testing.T) {
 		}
 	}
 }
+
+// TestHashOrHeight ensures block heights passed as JSON numbers and block
+// hashes and heights passed as JSON strings unmarshal into a HashOrHeight.
+func TestHashOrHeight(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		marshalled string
+		want       btcjson.HashOrHeight
+		wantErr    bool
+	}{
+		{marshalled: `1000`, want: "1000"},
+		{marshalled: `"1000"`, want: "1000"},
+		{marshalled: `"00000000b873e79784647a6c82962c70d228557d24a747ea4d1b8bbe878e1206"`,
+			want: "00000000b873e79784647a6c82962c70d228557d24a747ea4d1b8bbe878e1206"},
+		{marshalled: `-1`, wantErr: true},
+		{marshalled: `true`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		var got btcjson.HashOrHeight
+		err := json.Unmarshal([]byte(test.marshalled), &got)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error: %v", test.marshalled, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.marshalled, got,
+				test.want)
+		}
+	}
+}
@@ -26,6 +26,38 @@ type GetBlockHeaderVerboseResult struct {
 	Signature        string  `json:"signature,omitempty"`
 }
 
+// EstimateSmartFeeResult models the data returned from the estimatesmartfee
+// command.  FeeRate is only set when an estimate is available, otherwise Errors
+// describes why none was found.
+type EstimateSmartFeeResult struct {
+	FeeRate *float64 `json:"feerate,omitempty"`
+	Errors  []string `json:"errors,omitempty"`
+	Blocks  int64    `json:"blocks"`
+}
+
+// GetBlockStatsResult models the data returned from the getblockstats command.
+// Fees and values are in atoms and fee rates in atoms per byte.  The fee rate
+// fields only cover the standard transactions of the block and are only set
+// when the fee stats index is enabled.
+type GetBlockStatsResult struct {
+	AvgFee             *int64  `json:"avgfee,omitempty"`
+	AvgFeeRate         *int64  `json:"avgfeerate,omitempty"`
+	AvgTxSize          int64   `json:"avgtxsize"`
+	BlockHash          string  `json:"blockhash"`
+	FeeRatePercentiles []int64 `json:"feerate_percentiles,omitempty"`
+	Height             uint32  `json:"height"`
+	Ins                uint32  `json:"ins"`
+	MaxFeeRate         *int64  `json:"maxfeerate,omitempty"`
+	MinFeeRate         *int64  `json:"minfeerate,omitempty"`
+	Outs               uint32  `json:"outs"`
+	Time               int64   `json:"time"`
+	TotalFee           int64   `json:"totalfee"`
+	TotalOut           int64   `json:"total_out"`
+	TotalSize          *uint64 `json:"total_size,omitempty"`
+	Txs                uint32  `json:"txs"`
+	AdminTxs           uint32  `json:"admintxs"`
+}
+
 // GetBlockVerboseResult models the data from the getblock command when the
 // verbose flag is set.  When the verbose flag is not set, getblock returns a
 // hex-encoded string.
@@ -48,6 +80,14 @@ type GetBlockVerboseResult struct {
 	Signature        string        `json:"signature,omitempty"`
 }
 
+// BackupChainStateResult models the data returned from the backupchainstate
+// command.
+type BackupChainStateResult struct {
+	Path   string `json:"path"`
+	Height uint32 `json:"height"`
+	Hash   string `json:"hash"`
+}
+
 // CreateMultiSigResult models the data returned from the createmultisig
 // command.
 type CreateMultiSigResult struct {
@@ -55,6 +95,66 @@ type CreateMultiSigResult struct {
 	RedeemScript string `json:"redeemScript"`
 }
 
+// DebugScriptStepResult models the state of the script engine after an opcode
+// as returned by the debugscript command.
+type DebugScriptStepResult struct {
+	Script    int      `json:"script"`
+	Index     int      `json:"index"`
+	Opcode    string   `json:"opcode"`
+	Executed  bool     `json:"executed"`
+	Stack     []string `json:"stack"`
+	AltStack  []string `json:"altstack"`
+	Remaining string   `json:"remaining"`
+}
+
+// DebugScriptResult models the data returned from the debugscript command.
+type DebugScriptResult struct {
+	Valid    bool                    `json:"valid"`
+	Error    string                  `json:"error,omitempty"`
+	FailedAt string                  `json:"failedat,omitempty"`
+	Steps    []DebugScriptStepResult `json:"steps"`
+}
+
+// PSPTKeyIDResult models a key ID of an input of a PSPT as returned by the
+// decodepspt command.
+type PSPTKeyIDResult struct {
+	KeyID  uint32 `json:"keyid"`
+	PubKey string `json:"pubkey"`
+}
+
+// PSPTDerivationResult models a key derivation of an input of a PSPT as
+// returned by the decodepspt command.
+type PSPTDerivationResult struct {
+	PubKey      string `json:"pubkey"`
+	Fingerprint string `json:"fingerprint"`
+	Path        string `json:"path"`
+}
+
+// PSPTSignatureResult models a signature collected by an input of a PSPT as
+// returned by the decodepspt command.
+type PSPTSignatureResult struct {
+	PubKey    string `json:"pubkey"`
+	Signature string `json:"signature"`
+}
+
+// DecodePSPTInputResult models an input of a PSPT as returned by the
+// decodepspt command.
+type DecodePSPTInputResult struct {
+	Amount       float64                `json:"amount"`
+	ScriptPubKey string                 `json:"scriptpubkey"`
+	Required     int                    `json:"required"`
+	KeyIDs       []PSPTKeyIDResult      `json:"keyids"`
+	Derivations  []PSPTDerivationResult `json:"derivations"`
+	Signatures   []PSPTSignatureResult  `json:"signatures"`
+}
+
+// DecodePSPTResult models the data returned from the decodepspt command.
+type DecodePSPTResult struct {
+	Tx       TxRawDecodeResult       `json:"tx"`
+	Inputs   []DecodePSPTInputResult `json:"inputs"`
+	Complete bool                    `json:"complete"`
+}
+
 // DecodeScriptResult models the data returned from the decodescript command.
 type DecodeScriptResult struct {
 	Asm       string   `json:"asm"`
@@ -64,6 +164,13 @@ type DecodeScriptResult struct {
 	P2sh      string   `json:"p2sh"`
 }
 
+// FinalizePSPTResult models the data returned from the finalizepspt command.
+type FinalizePSPTResult struct {
+	PSPT     string `json:"pspt,omitempty"`
+	Hex      string `json:"hex,omitempty"`
+	Complete bool   `json:"complete"`
+}
+
 // GetAddedNodeInfoResultAddr models the data of the addresses portion of the
 // getaddednodeinfo command.
 type GetAddedNodeInfoResultAddr struct {
@@ -71,6 +178,15 @@ type GetAddedNodeInfoResultAddr struct {
 	Connected string `json:"connected"`
 }
 
+// DumpUTXOSnapshotResult models the data returned from the dumputxosnapshot
+// command.
+type DumpUTXOSnapshotResult struct {
+	Path          string `json:"path"`
+	Height        uint32 `json:"height"`
+	Hash          string `json:"hash"`
+	IntegrityHash string `json:"integrityhash"`
+}
+
 // GetAddedNodeInfoResult models the data from the getaddednodeinfo command.
 type GetAddedNodeInfoResult struct {
 	AddedNode string                        `json:"addednode"`
@@ -106,16 +222,66 @@ type GetAdminInfoResult struct {
 	ASPKeys       []ASPKeyIdResult  `json:"aspkeys,omitempty"`
 }
 
-// GetBlockChainInfoResult models the data returned from the getblockchaininfo
+// CheckpointCandidateResult models a checkpoint candidate as returned by the
+// getcheckpointcandidates command.
+type CheckpointCandidateResult struct {
+	Height uint32 `json:"height"`
+	Hash   string `json:"hash"`
+	Time   int64  `json:"time"`
+}
+
+// GetChainTipsResult models a chain tip as returned by the getchaintips
 // command.
+type GetChainTipsResult struct {
+	Height    uint32 `json:"height"`
+	Hash      string `json:"hash"`
+	BranchLen uint32 `json:"branchlen"`
+	Status    string `json:"status"`
+}
+
+// Bip9SoftForkDescription describes the current state of a consensus rule
+// change deployment as returned by the getblockchaininfo command.  The
+// activation height is omitted when the deployment only activates once it is
+// signaled.
+type Bip9SoftForkDescription struct {
+	Status           string  `json:"status"`
+	Bit              uint8   `json:"bit"`
+	StartTime        uint64  `json:"startTime"`
+	Timeout          uint64  `json:"timeout"`
+	ActivationHeight *uint32 `json:"activationheight,omitempty"`
+}
+
+// SoftForkDescription describes the current state of a consensus rule change
+// deployment in the format of the softforks field of the getblockchaininfo
+// command.  The height is the activation height of the deployment and is
+// omitted when it only activates once it is signaled.
+type SoftForkDescription struct {
+	Type   string                   `json:"type"`
+	Bip9   *Bip9SoftForkDescription `json:"bip9,omitempty"`
+	Height *uint32                  `json:"height,omitempty"`
+	Active bool                     `json:"active"`
+}
+
+// GetBlockChainInfoResult models the data returned from the getblockchaininfo
+// command.  The prune height and automatic pruning fields are only set when
+// pruning is enabled.
 type GetBlockChainInfoResult struct {
-	Chain                string  `json:"chain"`
-	Blocks               int32   `json:"blocks"`
-	Headers              int32   `json:"headers"`
-	BestBlockHash        string  `json:"bestblockhash"`
-	Difficulty           float64 `json:"difficulty"`
-	VerificationProgress float64 `json:"verificationprogress"`
-	ChainWork            string  `json:"chainwork"`
+	Chain                string                              `json:"chain"`
+	Blocks               uint32                              `json:"blocks"`
+	Headers              uint32                              `json:"headers"`
+	BestBlockHash        string                              `json:"bestblockhash"`
+	Difficulty           float64                             `json:"difficulty"`
+	MedianTime           int64                               `json:"mediantime"`
+	VerificationProgress float64                             `json:"verificationprogress"`
+	InitialBlockDownload bool                                `json:"initialblockdownload"`
+	ChainWork            string                              `json:"chainwork"`
+	SizeOnDisk           int64                               `json:"size_on_disk"`
+	Pruned               bool                                `json:"pruned"`
+	PruneHeight          *uint32                             `json:"pruneheight,omitempty"`
+	AutomaticPruning     *bool                               `json:"automatic_pruning,omitempty"`
+	SoftForks            map[string]*SoftForkDescription     `json:"softforks"`
+	Bip9SoftForks        map[string]*Bip9SoftForkDescription `json:"bip9_softforks"`
+	Warnings             string                              `json:"warnings"`
 }
 
 // GetBlockTemplateResultTx models the transactions field of the
@@ -134,6 +300,24 @@ type GetBlockTemplateResultAux struct {
 	Flags string `json:"flags"`
 }
 
+// GetBlockTemplateResultValidateKey models the validatekeys field of the
+// getblocktemplate command.
+type GetBlockTemplateResultValidateKey struct {
+	PubKey       string `json:"pubkey"`
+	RateLimited  bool   `json:"ratelimited"`
+	RecentBlocks int    `json:"recentblocks"`
+}
+
+// GetBlockTemplateResultAdminThread models the adminthreads field of the
+// getblocktemplate command.  SpentBy is the 1-based index of the template
+// transaction which spends the tip of the thread, if any.
+type GetBlockTemplateResultAdminThread struct {
+	ID       uint32 `json:"id"`
+	Name     string `json:"name"`
+	OutPoint string `json:"outpoint"`
+	SpentBy  int64  `json:"spentby,omitempty"`
+}
+
 // GetBlockTemplateResult models the data returned from the getblocktemplate
 // command.
 type GetBlockTemplateResult struct {
@@ -170,25 +354,37 @@ type GetBlockTemplateResult struct {
 	// Block proposal from BIP 0023.
 	Capabilities  []string `json:"capabilities,omitempty"`
 	RejectReasion string   `json:"reject-reason,omitempty"`
+
+	// Prova extensions.  The header must be signed by one of the validate
+	// keys which is not rate limited, and admin transactions must spend
+	// the tips of their threads.
+	ValidateKeys       []GetBlockTemplateResultValidateKey `json:"validatekeys,omitempty"`
+	RateLimitWindow    int                                 `json:"ratelimitwindow,omitempty"`
+	RateLimitMaxBlocks int                                 `json:"ratelimitmaxblocks,omitempty"`
+	AdminThreads       []GetBlockTemplateResultAdminThread `json:"adminthreads,omitempty"`
 }
 
 // GetMempoolEntryResult models the data returned from the getmempoolentry
-// command.
+// command, and from the getmempoolancestors and getmempooldescendants commands
+// when the verbose flag is set.  The counts, sizes and fees of the descendants
+// and ancestors include the transaction itself, and their fees are in atoms.
 type GetMempoolEntryResult struct {
-	Size             int32    `json:"size"`
-	Fee              float64  `json:"fee"`
-	ModifiedFee      float64  `json:"modifiedfee"`
-	Time             int64    `json:"time"`
-	Height           int64    `json:"height"`
-	StartingPriority float64  `json:"startingpriority"`
-	CurrentPriority  float64  `json:"currentpriority"`
-	DescendantCount  int64    `json:"descendantcount"`
-	DescendantSize   int64    `json:"descendantsize"`
-	DescendantFees   float64  `json:"descendantfees"`
-	AncestorCount    int64    `json:"ancestorcount"`
-	AncestorSize     int64    `json:"ancestorsize"`
-	AncestorFees     float64  `json:"ancestorfees"`
-	Depends          []string `json:"depends"`
+	Size              int32    `json:"size"`
+	Fee               float64  `json:"fee"`
+	ModifiedFee       float64  `json:"modifiedfee"`
+	Time              int64    `json:"time"`
+	Height            int64    `json:"height"`
+	StartingPriority  float64  `json:"startingpriority"`
+	CurrentPriority   float64  `json:"currentpriority"`
+	DescendantCount   int64    `json:"descendantcount"`
+	DescendantSize    int64    `json:"descendantsize"`
+	DescendantFees    float64  `json:"descendantfees"`
+	AncestorCount     int64    `json:"ancestorcount"`
+	AncestorSize      int64    `json:"ancestorsize"`
+	AncestorFees      float64  `json:"ancestorfees"`
+	Depends           []string `json:"depends"`
+	SpentBy           []string `json:"spentby"`
+	BIP125Replaceable bool     `json:"bip125-replaceable"`
 }
 
 // GetMempoolInfoResult models the data returned from the getmempoolinfo
@@ -201,13 +397,22 @@ type GetMempoolInfoResult struct {
 // GetNetworkInfoResult models the data returned from the getnetworkinfo
 // command.
 type GetNetworkInfoResult struct {
-	Version         int32                  `json:"version"`
-	ProtocolVersion int32                  `json:"protocolversion"`
-	TimeOffset      int64                  `json:"timeoffset"`
-	Connections     int32                  `json:"connections"`
-	Networks        []NetworksResult       `json:"networks"`
-	RelayFee        float64                `json:"relayfee"`
-	LocalAddresses  []LocalAddressesResult `json:"localaddresses"`
+	Version            int32                  `json:"version"`
+	SubVersion         string                 `json:"subversion"`
+	ProtocolVersion    int32                  `json:"protocolversion"`
+	LocalServices      string                 `json:"localservices"`
+	LocalServicesNames []string               `json:"localservicesnames"`
+	LocalRelay         bool                   `json:"localrelay"`
+	TimeOffset         int64                  `json:"timeoffset"`
+	NetworkActive      bool                   `json:"networkactive"`
+	Connections        int32                  `json:"connections"`
+	ConnectionsIn      int32                  `json:"connections_in"`
+	ConnectionsOut     int32                  `json:"connections_out"`
+	Networks           []NetworksResult       `json:"networks"`
+	RelayFee           float64                `json:"relayfee"`
+	IncrementalFee     float64                `json:"incrementalfee"`
+	LocalAddresses     []LocalAddressesResult `json:"localaddresses"`
+	Warnings           string                 `json:"warnings"`
 }
 
 // GetPeerInfoResult models the data returned from the getpeerinfo command.
@@ -225,14 +430,19 @@ type GetPeerInfoResult struct {
 	TimeOffset     int64   `json:"timeoffset"`
 	PingTime       float64 `json:"pingtime"`
 	PingWait       float64 `json:"pingwait,omitempty"`
+	MinPing        float64 `json:"minping,omitempty"`
 	Version        uint32  `json:"version"`
 	SubVer         string  `json:"subver"`
 	Inbound        bool    `json:"inbound"`
+	Encrypted      bool    `json:"encrypted"`
+	BlockRelayOnly bool    `json:"blockrelayonly"`
 	StartingHeight uint32  `json:"startingheight"`
 	CurrentHeight  uint32  `json:"currentheight,omitempty"`
 	BanScore       int32   `json:"banscore"`
 	FeeFilter      int64   `json:"feefilter"`
 	SyncNode       bool    `json:"syncnode"`
+	LastBlockTime  int64   `json:"lastblocktime"`
+	ProtectedBy    string  `json:"protectedby,omitempty"`
 }
 
 // GetRawMempoolVerboseResult models the data returned from the getrawmempool
@@ -259,6 +469,297 @@ type ScriptPubKeyResult struct {
 	Addresses []string `json:"addresses,omitempty"`
 }
 
+// GetBlockHashByTimeResult models the data from the getblockhashbytime
+// command.
+type GetBlockHashByTimeResult struct {
+	Hash   string `json:"hash"`
+	Height uint32 `json:"height"`
+	Time   int64  `json:"time"`
+}
+
+// CorruptBlockResult models a block whose stored data was found to be corrupt
+// as returned by the getblockscrubinfo command.
+type CorruptBlockResult struct {
+	Hash     string `json:"hash"`
+	File     uint32 `json:"file"`
+	Offset   uint32 `json:"offset"`
+	Length   uint32 `json:"length"`
+	Error    string `json:"error"`
+	Repaired bool   `json:"repaired"`
+}
+
+// GetBlockScrubInfoResult models the data from the getblockscrubinfo command.
+// LastScrub is 0 until the first scrub has finished.
+type GetBlockScrubInfoResult struct {
+	Interval      int64                `json:"interval"`
+	Scrubbing     bool                 `json:"scrubbing"`
+	LastScrub     int64                `json:"lastscrub"`
+	CorruptBlocks []CorruptBlockResult `json:"corruptblocks"`
+}
+
+// GetIndexInfoResult models the status of an optional index as returned by the
+// getindexinfo command.
+type GetIndexInfoResult struct {
+	Name            string  `json:"name"`
+	Key             string  `json:"key"`
+	Enabled         bool    `json:"enabled"`
+	Height          int32   `json:"height"`
+	Synced          bool    `json:"synced"`
+	PercentComplete float64 `json:"percentcomplete"`
+}
+
+// GetIssuanceInfoResult models the data from the getissuanceinfo command.
+type GetIssuanceInfoResult struct {
+	Hash               string `json:"hash"`
+	Height             uint32 `json:"height"`
+	Supply             uint64 `json:"supply"`
+	TotalIssued        uint64 `json:"totalissued"`
+	TotalDestroyed     uint64 `json:"totaldestroyed"`
+	LastIssuanceHeight uint32 `json:"lastissuanceheight"`
+	HistoryStart       uint32 `json:"historystart"`
+}
+
+// IndexInconsistencyResult models an index entry which does not match the block
+// it was created for as returned by the checkindex command.
+type IndexInconsistencyResult struct {
+	Height   int32  `json:"height"`
+	Hash     string `json:"hash"`
+	Key      string `json:"key"`
+	Reason   string `json:"reason"`
+	Repaired bool   `json:"repaired"`
+}
+
+// CheckIndexResult models the data returned from the checkindex command.
+type CheckIndexResult struct {
+	Index           string                     `json:"index"`
+	BlocksChecked   int                        `json:"blockschecked"`
+	Inconsistencies []IndexInconsistencyResult `json:"inconsistencies"`
+}
+
+// ReloadConfigResult models the data returned from the reloadconfig command.
+// Both lists hold the names of configuration options which changed since they
+// were last loaded.
+type ReloadConfigResult struct {
+	Applied         []string `json:"applied"`
+	RequiresRestart []string `json:"requiresrestart"`
+}
+
+// SetPolicyResult models the data returned from the setpolicy command.  It
+// describes the relay policy in effect once the command was applied.
+type SetPolicyResult struct {
+	MaxSigOps       int      `json:"maxsigops"`
+	DustThreshold   float64  `json:"dustthreshold"`
+	ScriptTypes     []string `json:"scripttypes"`
+	DataCarrierSize int      `json:"datacarriersize"`
+}
+
+// SimulatedBlockResult models a block generated and processed by the
+// simulatechain command.  The error is set when the block was rejected.
+type SimulatedBlockResult struct {
+	Name      string `json:"name"`
+	Hash      string `json:"hash"`
+	Height    uint32 `json:"height"`
+	MainChain bool   `json:"mainchain"`
+	Error     string `json:"error,omitempty"`
+}
+
+// KeyIDActivityResult models an output created or spent under a key ID as
+// returned by the getkeyidactivity command.  The index is the output index for
+// created outputs and the input index for spent outputs.
+type KeyIDActivityResult struct {
+	Type     string  `json:"type"`
+	Height   uint32  `json:"height"`
+	Txid     string  `json:"txid"`
+	Index    uint32  `json:"index"`
+	PrevTxid string  `json:"prevtxid,omitempty"`
+	PrevVout *uint32 `json:"prevvout,omitempty"`
+	Value    float64 `json:"value"`
+	Address  string  `json:"address,omitempty"`
+}
+
+// GetKeyIDInfoResult models the data from the getkeyidinfo command.
+type GetKeyIDInfoResult struct {
+	KeyID           uint32 `json:"keyid"`
+	PubKey          string `json:"pubkey,omitempty"`
+	ProvisionHeight uint32 `json:"provisionheight"`
+	Revoked         bool   `json:"revoked"`
+	RevokeHeight    uint32 `json:"revokeheight,omitempty"`
+}
+
+// GetAddressBalanceResult models the data returned from the getaddressbalance
+// command.
+type GetAddressBalanceResult struct {
+	Balance   float64 `json:"balance"`
+	UtxoCount uint32  `json:"utxocount"`
+}
+
+// AddressUtxoResult models an unspent output paying to an address or key ID as
+// returned by the getaddressutxos command.
+type AddressUtxoResult struct {
+	Txid         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	Height       uint32  `json:"height"`
+	Value        float64 `json:"value"`
+	ScriptPubKey string  `json:"scriptpubkey"`
+}
+
+// ListWatchedResult models a watched address or key ID as returned by the
+// listwatched command.
+type ListWatchedResult struct {
+	Address      string  `json:"address"`
+	Height       uint32  `json:"height"`
+	Seeded       bool    `json:"seeded"`
+	Rescanning   bool    `json:"rescanning,omitempty"`
+	RescanHeight uint32  `json:"rescanheight,omitempty"`
+	Balance      float64 `json:"balance"`
+	UtxoCount    uint32  `json:"utxocount"`
+}
+
+// ScanTxOutSetResult models the data returned from the scantxoutset command.
+type ScanTxOutSetResult struct {
+	Success     bool                  `json:"success"`
+	Height      uint32                `json:"height"`
+	BestBlock   string                `json:"bestblock"`
+	Unspents    []ScanTxOutSetUnspent `json:"unspents"`
+	TotalAmount float64               `json:"total_amount"`
+}
+
+// ScanTxOutSetUnspent models an unspent output matching a scan object as
+// returned by the scantxoutset command.
+type ScanTxOutSetUnspent struct {
+	Txid         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	ScriptPubKey string  `json:"scriptPubKey"`
+	Desc         string  `json:"desc"`
+	Amount       float64 `json:"amount"`
+	Height       uint32  `json:"height"`
+}
+
+// AddressIssuanceResult models tokens issued to or destroyed from an address as
+// returned by the getaddressissuance command.  The index is the output index
+// for issued outputs and the input index for destroyed outputs.
+type AddressIssuanceResult struct {
+	Type     string  `json:"type"`
+	Height   uint32  `json:"height"`
+	Txid     string  `json:"txid"`
+	Index    uint32  `json:"index"`
+	PrevTxid string  `json:"prevtxid,omitempty"`
+	PrevVout *uint32 `json:"prevvout,omitempty"`
+	Value    float64 `json:"value"`
+}
+
+// AdminOperationResult models an admin operation as returned by the
+// listadminoperations command.
+type AdminOperationResult struct {
+	Type   string  `json:"type"`
+	Height uint32  `json:"height"`
+	Txid   string  `json:"txid"`
+	Vout   uint32  `json:"vout"`
+	Thread string  `json:"thread"`
+	KeySet string  `json:"keyset,omitempty"`
+	PubKey string  `json:"pubkey,omitempty"`
+	KeyID  uint32  `json:"keyid,omitempty"`
+	Value  float64 `json:"value,omitempty"`
+}
+
+// GetCFHeadersResult models the data from the getcfheaders command.
+type GetCFHeadersResult struct {
+	StopHash         string   `json:"stophash"`
+	PrevFilterHeader string   `json:"prevfilterheader"`
+	FilterHashes     []string `json:"filterhashes"`
+	FilterHeaders    []string `json:"filterheaders"`
+}
+
+// RescanInfoResult models a rescan job as returned by the getrescaninfo
+// command.  EndHeight is -1 for rescans which follow the tip of the main chain
+// and Height is -1 until the first block is rescanned.  ETA is the estimated
+// number of seconds until the rescan finishes, or -1 when unknown.
+type RescanInfoResult struct {
+	JobID           string  `json:"jobid"`
+	Status          string  `json:"status"`
+	Resumable       bool    `json:"resumable"`
+	Addresses       int     `json:"addresses"`
+	StartHeight     int32   `json:"startheight"`
+	EndHeight       int32   `json:"endheight"`
+	Height          int32   `json:"height"`
+	Progress        float64 `json:"progress"`
+	BlocksPerSecond float64 `json:"blockspersecond"`
+	ETA             int64   `json:"eta"`
+}
+
+// ValidationPhaseResult models the rolling statistics of a validation phase
+// returned by the getvalidationtimings command.  All times are in
+// milliseconds.
+type ValidationPhaseResult struct {
+	Phase   string  `json:"phase"`
+	Samples int     `json:"samples"`
+	Last    float64 `json:"last"`
+	Mean    float64 `json:"mean"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+}
+
+// LastBlockTimingsResult models the time spent in each validation phase by the
+// most recently connected block returned by the getvalidationtimings command.
+// The phase times are in milliseconds keyed by phase name.
+type LastBlockTimingsResult struct {
+	Hash   string             `json:"hash"`
+	Height uint32             `json:"height"`
+	Phases map[string]float64 `json:"phases"`
+}
+
+// GetValidationTimingsResult models the data from the getvalidationtimings
+// command.
+type GetValidationTimingsResult struct {
+	Blocks    uint64                  `json:"blocks"`
+	Window    int                     `json:"window"`
+	LastBlock *LastBlockTimingsResult `json:"lastblock,omitempty"`
+	Phases    []ValidationPhaseResult `json:"phases"`
+}
+
+// ValidatorStatsResult models the recent blocks of a validate key as returned
+// by the getvalidatorstats command.
+type ValidatorStatsResult struct {
+	PubKey          string  `json:"pubkey"`
+	Blocks          int     `json:"blocks"`
+	Share           float64 `json:"share"`
+	Remaining       int     `json:"remaining"`
+	RateLimited     bool    `json:"ratelimited"`
+	LastBlockHeight uint32  `json:"lastblockheight,omitempty"`
+	LastBlockHash   string  `json:"lastblockhash,omitempty"`
+}
+
+// GetValidatorStatsResult models the data from the getvalidatorstats command.
+type GetValidatorStatsResult struct {
+	Hash       string                 `json:"hash"`
+	Height     uint32                 `json:"height"`
+	Window     int                    `json:"window"`
+	MaxBlocks  int                    `json:"maxblocks"`
+	Validators []ValidatorStatsResult `json:"validators"`
+}
+
+// GetSpentInfoResult models the data from the getspentinfo command.
+type GetSpentInfoResult struct {
+	Txid   string `json:"txid"`
+	Index  uint32 `json:"index"`
+	Height uint32 `json:"height"`
+}
+
+// TxOutSpendResult models the transaction input which spent an output as
+// returned by the gettxspendinginfo command.
+type TxOutSpendResult struct {
+	Vout         uint32 `json:"vout"`
+	SpendingTxid string `json:"spendingtxid"`
+	Vin          uint32 `json:"vin"`
+	Height       uint32 `json:"height"`
+}
+
+// GetTxSpendingInfoResult models the data from the gettxspendinginfo command.
+type GetTxSpendingInfoResult struct {
+	Txid   string             `json:"txid"`
+	Spends []TxOutSpendResult `json:"spends"`
+}
+
 // GetTxOutResult models the data from the gettxout command.
 type GetTxOutResult struct {
 	BestBlock     string             `json:"bestblock"`
@@ -270,10 +771,13 @@ type GetTxOutResult struct {
 }
 
 // GetNetTotalsResult models the data returned from the getnettotals command.
+// The bytes per message are keyed by message command.
 type GetNetTotalsResult struct {
-	TotalBytesRecv uint64 `json:"totalbytesrecv"`
-	TotalBytesSent uint64 `json:"totalbytessent"`
-	TimeMillis     int64  `json:"timemillis"`
+	TotalBytesRecv  uint64            `json:"totalbytesrecv"`
+	TotalBytesSent  uint64            `json:"totalbytessent"`
+	TimeMillis      int64             `json:"timemillis"`
+	BytesRecvPerMsg map[string]uint64 `json:"bytesrecv_per_msg"`
+	BytesSentPerMsg map[string]uint64 `json:"bytessent_per_msg"`
 }
 
 // ScriptSig models a signature script.  It is defined separately since it only
@@ -422,6 +926,15 @@ type InfoChainResult struct {
 	Errors          string  `json:"errors"`
 }
 
+// ListBannedResult models the data of a ban from the listbanned command.
+type ListBannedResult struct {
+	Address       string `json:"address"`
+	BanCreated    int64  `json:"ban_created"`
+	BannedUntil   int64  `json:"banned_until"`
+	BanDuration   int64  `json:"ban_duration"`
+	TimeRemaining int64  `json:"time_remaining"`
+}
+
 // LocalAddressesResult models the localaddresses data from the getnetworkinfo
 // command.
 type LocalAddressesResult struct {
@@ -432,10 +945,11 @@ type LocalAddressesResult struct {
 
 // NetworksResult models the networks data from the getnetworkinfo command.
 type NetworksResult struct {
-	Name      string `json:"name"`
-	Limited   bool   `json:"limited"`
-	Reachable bool   `json:"reachable"`
-	Proxy     string `json:"proxy"`
+	Name                      string `json:"name"`
+	Limited                   bool   `json:"limited"`
+	Reachable                 bool   `json:"reachable"`
+	Proxy                     string `json:"proxy"`
+	ProxyRandomizeCredentials bool   `json:"proxy_randomize_credentials"`
 }
 
 // TxRawResult models the data from the getrawtransaction command.
@@ -467,6 +981,94 @@ type SearchRawTransactionsResult struct {
 	Blocktime     int64        `json:"blocktime,omitempty"`
 }
 
+// AddressTxResult models a transaction involving an address as returned by the
+// searchrawtransactionsbyaddress command.  The category is "funding" when the
+// transaction only pays to the address, "spending" when it only spends from the
+// address, and "both" otherwise.
+type AddressTxResult struct {
+	Txid          string  `json:"txid"`
+	BlockHash     string  `json:"blockhash"`
+	BlockHeight   uint32  `json:"blockheight"`
+	Confirmations uint64  `json:"confirmations"`
+	Received      float64 `json:"received"`
+	Sent          float64 `json:"sent"`
+	Category      string  `json:"category"`
+}
+
+// SearchRawTransactionsByAddressResult models the data from the
+// searchrawtransactionsbyaddress command.  The total is only set when it was
+// requested.
+type SearchRawTransactionsByAddressResult struct {
+	Total        *uint32           `json:"total,omitempty"`
+	Transactions []AddressTxResult `json:"transactions"`
+}
+
+// SimulatedOutputResult models an output spent or created by a block as
+// returned by the testblockvalidity command.
+type SimulatedOutputResult struct {
+	Txid         string  `json:"txid"`
+	Vout         uint32  `json:"vout"`
+	Value        float64 `json:"value"`
+	ScriptPubKey string  `json:"scriptpubkey"`
+}
+
+// KeySetChangeResult models the keys an admin key set gains and loses as
+// returned by the testblockvalidity command.
+type KeySetChangeResult struct {
+	KeySet  string   `json:"keyset"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// KeyIDChangeResult models an ASP key ID which is provisioned or revoked as
+// returned by the testblockvalidity command.
+type KeyIDChangeResult struct {
+	KeyID  uint32 `json:"keyid"`
+	PubKey string `json:"pubkey"`
+	Added  bool   `json:"added"`
+}
+
+// TestBlockValidityResult models the data from the testblockvalidity command.
+// Only the hash, validity, and reject reason are set when the block is invalid.
+type TestBlockValidityResult struct {
+	Hash          string                  `json:"hash"`
+	Valid         bool                    `json:"valid"`
+	RejectReason  string                  `json:"rejectreason,omitempty"`
+	Height        uint32                  `json:"height,omitempty"`
+	Depth         uint32                  `json:"depth,omitempty"`
+	Fees          float64                 `json:"fees,omitempty"`
+	Subsidy       float64                 `json:"subsidy,omitempty"`
+	SupplyBefore  uint64                  `json:"supplybefore,omitempty"`
+	SupplyAfter   uint64                  `json:"supplyafter,omitempty"`
+	LastKeyID     uint32                  `json:"lastkeyid,omitempty"`
+	Spent         []SimulatedOutputResult `json:"spent,omitempty"`
+	Created       []SimulatedOutputResult `json:"created,omitempty"`
+	KeySetChanges []KeySetChangeResult    `json:"keysetchanges,omitempty"`
+	KeyIDChanges  []KeyIDChangeResult     `json:"keyidchanges,omitempty"`
+}
+
+// AdminOpResult models an operation of the decodeadmintransaction command.
+// PubKey is set for the key operations, KeyID for the ASP key operations,
+// Address for issue, and Amount for issue and destroy.
+type AdminOpResult struct {
+	Operation string  `json:"operation"`
+	PubKey    string  `json:"pubkey,omitempty"`
+	KeyID     uint32  `json:"keyid,omitempty"`
+	Address   string  `json:"address,omitempty"`
+	Amount    float64 `json:"amount,omitempty"`
+}
+
+// DecodeAdminTransactionResult models the data from the decodeadmintransaction
+// command.
+type DecodeAdminTransactionResult struct {
+	Txid       string          `json:"txid"`
+	Thread     uint32          `json:"thread"`
+	ThreadName string          `json:"threadname"`
+	ThreadTip  string          `json:"threadtip"`
+	Operations []AdminOpResult `json:"operations"`
+	Destroyed  []string        `json:"destroyed,omitempty"`
+}
+
 // TxRawDecodeResult models the data from the decoderawtransaction command.
 type TxRawDecodeResult struct {
 	Txid     string `json:"txid"`
@@ -482,3 +1084,19 @@ type ValidateAddressChainResult struct {
 	IsValid bool   `json:"isvalid"`
 	Address string `json:"address,omitempty"`
 }
+
+// VerifyChainInconsistencyResult models an inconsistency found by the
+// verifychaindb command.
+type VerifyChainInconsistencyResult struct {
+	Hash        string `json:"hash,omitempty"`
+	Height      uint32 `json:"height"`
+	Description string `json:"description"`
+}
+
+// VerifyChainDBResult models the data returned by the verifychaindb command.
+type VerifyChainDBResult struct {
+	Verified          bool                             `json:"verified"`
+	BlocksChecked     uint32                           `json:"blockschecked"`
+	BestStateRepaired bool                             `json:"beststaterepaired"`
+	Inconsistencies   []VerifyChainInconsistencyResult `json:"inconsistencies,omitempty"`
+}
@@ -177,7 +177,9 @@ func NewStopNotifySpentCmd(outPoints []OutPoint) *StopNotifySpentCmd {
 	}
 }
 
-// RescanCmd defines the rescan JSON-RPC command.
+// RescanCmd defines the rescan JSON-RPC command.  An empty EndBlock rescans
+// through the tip of the main chain, which allows a JobID to be passed without
+// an end block.
 //
 // NOTE: Deprecated. Use RescanBlocksCmd instead.
 type RescanCmd struct {
@@ -185,6 +187,7 @@ type RescanCmd struct {
 	Addresses  []string
 	OutPoints  []OutPoint
 	EndBlock   *string
+	JobID      *string
 }
 
 // NewRescanCmd returns a new instance which can be used to issue a rescan
@@ -194,12 +197,13 @@ type RescanCmd struct {
 // for optional parameters will use the default value.
 //
 // NOTE: Deprecated. Use NewRescanBlocksCmd instead.
-func NewRescanCmd(beginBlock string, addresses []string, outPoints []OutPoint, endBlock *string) *RescanCmd {
+func NewRescanCmd(beginBlock string, addresses []string, outPoints []OutPoint, endBlock *string, jobID *string) *RescanCmd {
 	return &RescanCmd{
 		BeginBlock: beginBlock,
 		Addresses:  addresses,
 		OutPoints:  outPoints,
 		EndBlock:   endBlock,
+		JobID:      jobID,
 	}
 }
 
@@ -221,21 +225,83 @@ func NewRescanBlocksCmd(blockHashes []string) *RescanBlocksCmd {
 	return &RescanBlocksCmd{BlockHashes: blockHashes}
 }
 
+// NotifyWatchedCmd defines the notifywatched JSON-RPC command.
+type NotifyWatchedCmd struct{}
+
+// NewNotifyWatchedCmd returns a new instance which can be used to issue a
+// notifywatched JSON-RPC command.
+func NewNotifyWatchedCmd() *NotifyWatchedCmd {
+	return &NotifyWatchedCmd{}
+}
+
+// StopNotifyWatchedCmd defines the stopnotifywatched JSON-RPC command.
+type StopNotifyWatchedCmd struct{}
+
+// NewStopNotifyWatchedCmd returns a new instance which can be used to issue a
+// stopnotifywatched JSON-RPC command.
+func NewStopNotifyWatchedCmd() *StopNotifyWatchedCmd {
+	return &StopNotifyWatchedCmd{}
+}
+
+// NotifyAdminTransactionsCmd defines the notifyadmintransactions JSON-RPC
+// command.
+type NotifyAdminTransactionsCmd struct{}
+
+// NewNotifyAdminTransactionsCmd returns a new instance which can be used to
+// issue a notifyadmintransactions JSON-RPC command.
+func NewNotifyAdminTransactionsCmd() *NotifyAdminTransactionsCmd {
+	return &NotifyAdminTransactionsCmd{}
+}
+
+// StopNotifyAdminTransactionsCmd defines the stopnotifyadmintransactions
+// JSON-RPC command.
+type StopNotifyAdminTransactionsCmd struct{}
+
+// NewStopNotifyAdminTransactionsCmd returns a new instance which can be used to
+// issue a stopnotifyadmintransactions JSON-RPC command.
+func NewStopNotifyAdminTransactionsCmd() *StopNotifyAdminTransactionsCmd {
+	return &StopNotifyAdminTransactionsCmd{}
+}
+
+// NotifyPeerEventsCmd defines the notifypeerevents JSON-RPC command.
+type NotifyPeerEventsCmd struct{}
+
+// NewNotifyPeerEventsCmd returns a new instance which can be used to issue a
+// notifypeerevents JSON-RPC command.
+func NewNotifyPeerEventsCmd() *NotifyPeerEventsCmd {
+	return &NotifyPeerEventsCmd{}
+}
+
+// StopNotifyPeerEventsCmd defines the stopnotifypeerevents JSON-RPC command.
+type StopNotifyPeerEventsCmd struct{}
+
+// NewStopNotifyPeerEventsCmd returns a new instance which can be used to issue
+// a stopnotifypeerevents JSON-RPC command.
+func NewStopNotifyPeerEventsCmd() *StopNotifyPeerEventsCmd {
+	return &StopNotifyPeerEventsCmd{}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets.
 	flags := UFWebsocketOnly
 
 	MustRegisterCmd("authenticate", (*AuthenticateCmd)(nil), flags)
 	MustRegisterCmd("loadtxfilter", (*LoadTxFilterCmd)(nil), flags)
+	MustRegisterCmd("notifyadmintransactions", (*NotifyAdminTransactionsCmd)(nil), flags)
 	MustRegisterCmd("notifyblocks", (*NotifyBlocksCmd)(nil), flags)
 	MustRegisterCmd("notifynewtransactions", (*NotifyNewTransactionsCmd)(nil), flags)
+	MustRegisterCmd("notifypeerevents", (*NotifyPeerEventsCmd)(nil), flags)
 	MustRegisterCmd("notifyreceived", (*NotifyReceivedCmd)(nil), flags)
 	MustRegisterCmd("notifyspent", (*NotifySpentCmd)(nil), flags)
+	MustRegisterCmd("notifywatched", (*NotifyWatchedCmd)(nil), flags)
 	MustRegisterCmd("session", (*SessionCmd)(nil), flags)
+	MustRegisterCmd("stopnotifyadmintransactions", (*StopNotifyAdminTransactionsCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyblocks", (*StopNotifyBlocksCmd)(nil), flags)
 	MustRegisterCmd("stopnotifynewtransactions", (*StopNotifyNewTransactionsCmd)(nil), flags)
+	MustRegisterCmd("stopnotifypeerevents", (*StopNotifyPeerEventsCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyspent", (*StopNotifySpentCmd)(nil), flags)
 	MustRegisterCmd("stopnotifyreceived", (*StopNotifyReceivedCmd)(nil), flags)
+	MustRegisterCmd("stopnotifywatched", (*StopNotifyWatchedCmd)(nil), flags)
 	MustRegisterCmd("rescan", (*RescanCmd)(nil), flags)
 	MustRegisterCmd("rescanblocks", (*RescanBlocksCmd)(nil), flags)
 }
@@ -126,6 +126,61 @@ func TestChainSvrWsCmds(t *testing.T) {
 				Addresses: []string{"1Address"},
 			},
 		},
+		{
+			name: "notifywatched",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifywatched")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyWatchedCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"notifywatched","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyWatchedCmd{},
+		},
+		{
+			name: "notifyadmintransactions",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifyadmintransactions")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyAdminTransactionsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"notifyadmintransactions","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyAdminTransactionsCmd{},
+		},
+		{
+			name: "stopnotifyadmintransactions",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifyadmintransactions")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyAdminTransactionsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifyadmintransactions","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyAdminTransactionsCmd{},
+		},
+		{
+			name: "notifypeerevents",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("notifypeerevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewNotifyPeerEventsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"notifypeerevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.NotifyPeerEventsCmd{},
+		},
+		{
+			name: "stopnotifypeerevents",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("stopnotifypeerevents")
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewStopNotifyPeerEventsCmd()
+			},
+			marshalled:   `{"jsonrpc":"1.0","method":"stopnotifypeerevents","params":[],"id":1}`,
+			unmarshalled: &btcjson.StopNotifyPeerEventsCmd{},
+		},
 		{
 			name: "notifyspent",
 			newCmd: func() (interface{}, error) {
@@ -165,7 +220,7 @@ func TestChainSvrWsCmds(t *testing.T) {
 					Hash:  "0000000000000000000000000000000000000000000000000000000000000123",
 					Index: 0,
 				}}
-				return btcjson.NewRescanCmd("123", addrs, ops, nil)
+				return btcjson.NewRescanCmd("123", addrs, ops, nil, nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"rescan","params":["123",["1Address"],[{"hash":"0000000000000000000000000000000000000000000000000000000000000123","index":0}]],"id":1}`,
 			unmarshalled: &btcjson.RescanCmd{
@@ -183,7 +238,7 @@ func TestChainSvrWsCmds(t *testing.T) {
 			staticCmd: func() interface{} {
 				addrs := []string{"1Address"}
 				ops := []btcjson.OutPoint{{Hash: "123", Index: 0}}
-				return btcjson.NewRescanCmd("123", addrs, ops, btcjson.String("456"))
+				return btcjson.NewRescanCmd("123", addrs, ops, btcjson.String("456"), nil)
 			},
 			marshalled: `{"jsonrpc":"1.0","method":"rescan","params":["123",["1Address"],[{"hash":"123","index":0}],"456"],"id":1}`,
 			unmarshalled: &btcjson.RescanCmd{
@@ -193,6 +248,25 @@ func TestChainSvrWsCmds(t *testing.T) {
 				EndBlock:   btcjson.String("456"),
 			},
 		},
+		{
+			name: "rescan job",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("rescan", "123", `["1Address"]`, `[]`, "", "job1")
+			},
+			staticCmd: func() interface{} {
+				addrs := []string{"1Address"}
+				ops := []btcjson.OutPoint{}
+				return btcjson.NewRescanCmd("123", addrs, ops, btcjson.String(""), btcjson.String("job1"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"rescan","params":["123",["1Address"],[],"","job1"],"id":1}`,
+			unmarshalled: &btcjson.RescanCmd{
+				BeginBlock: "123",
+				Addresses:  []string{"1Address"},
+				OutPoints:  []btcjson.OutPoint{},
+				EndBlock:   btcjson.String(""),
+				JobID:      btcjson.String("job1"),
+			},
+		},
 		{
 			name: "loadtxfilter",
 			newCmd: func() (interface{}, error) {
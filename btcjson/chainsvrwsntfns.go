@@ -75,6 +75,29 @@ const (
 	// from the chain server that inform a client that a transaction that
 	// matches the loaded filter was accepted by the mempool.
 	RelevantTxAcceptedNtfnMethod = "relevanttxaccepted"
+
+	// WatchedActivityNtfnMethod is the method used for notifications from
+	// the chain server that inform a client that a transaction involving a
+	// watched address or key ID was accepted by the mempool or connected
+	// to the main chain.
+	WatchedActivityNtfnMethod = "watchedactivity"
+
+	// AdminTransactionNtfnMethod is the method used for notifications from
+	// the chain server that inform a client that a transaction on the root,
+	// provision or issue thread was accepted by the mempool or connected to
+	// the main chain.
+	AdminTransactionNtfnMethod = "admintransaction"
+
+	// AdminAlertNtfnMethod is the method used for notifications from the
+	// chain server that inform a client that an admin transaction which
+	// competes with the pending admin transactions or carries out unusual
+	// operations was seen by the mempool.
+	AdminAlertNtfnMethod = "adminalert"
+
+	// PeerEventNtfnMethod is the method used for notifications from the
+	// chain server that inform a client that a peer connected, completed
+	// the version handshake, misbehaved or disconnected.
+	PeerEventNtfnMethod = "peerevent"
 )
 
 // BlockConnectedNtfn defines the blockconnected JSON-RPC notification.
@@ -285,6 +308,96 @@ func NewRelevantTxAcceptedNtfn(txHex string) *RelevantTxAcceptedNtfn {
 	return &RelevantTxAcceptedNtfn{Transaction: txHex}
 }
 
+// WatchedActivityNtfn defines the watchedactivity JSON-RPC notification.
+type WatchedActivityNtfn struct {
+	Address  string        `json:"address"`
+	TxID     string        `json:"txid"`
+	Received float64       `json:"received"`
+	Sent     float64       `json:"sent"`
+	Block    *BlockDetails `json:"block,omitempty"`
+}
+
+// NewWatchedActivityNtfn returns a new instance which can be used to issue a
+// watchedactivity JSON-RPC notification.  The block is nil for transactions
+// accepted by the mempool.
+func NewWatchedActivityNtfn(address, txHash string, received, sent float64, block *BlockDetails) *WatchedActivityNtfn {
+	return &WatchedActivityNtfn{
+		Address:  address,
+		TxID:     txHash,
+		Received: received,
+		Sent:     sent,
+		Block:    block,
+	}
+}
+
+// AdminTransactionNtfn defines the admintransaction JSON-RPC notification.
+type AdminTransactionNtfn struct {
+	TxID       string                 `json:"txid"`
+	Thread     string                 `json:"thread"`
+	Operations []AdminOperationResult `json:"operations"`
+	Block      *BlockDetails          `json:"block,omitempty"`
+}
+
+// NewAdminTransactionNtfn returns a new instance which can be used to issue an
+// admintransaction JSON-RPC notification.  The block is nil for transactions
+// accepted by the mempool.
+func NewAdminTransactionNtfn(txHash, thread string, ops []AdminOperationResult, block *BlockDetails) *AdminTransactionNtfn {
+	return &AdminTransactionNtfn{
+		TxID:       txHash,
+		Thread:     thread,
+		Operations: ops,
+		Block:      block,
+	}
+}
+
+// AdminAlertNtfn defines the adminalert JSON-RPC notification.
+type AdminAlertNtfn struct {
+	Type        string  `json:"type"`
+	TxID        string  `json:"txid"`
+	Thread      string  `json:"thread"`
+	Reason      string  `json:"reason"`
+	PendingTxID *string `json:"pendingtxid,omitempty"`
+}
+
+// NewAdminAlertNtfn returns a new instance which can be used to issue an
+// adminalert JSON-RPC notification.  The pending transaction ID is nil for
+// alerts which do not involve a pending transaction.
+func NewAdminAlertNtfn(alertType, txHash, thread, reason string, pendingTxHash *string) *AdminAlertNtfn {
+	return &AdminAlertNtfn{
+		Type:        alertType,
+		TxID:        txHash,
+		Thread:      thread,
+		Reason:      reason,
+		PendingTxID: pendingTxHash,
+	}
+}
+
+// PeerEventNtfn defines the peerevent JSON-RPC notification.
+type PeerEventNtfn struct {
+	Type     string `json:"type"`
+	Time     int64  `json:"time"`
+	ID       int32  `json:"id"`
+	Addr     string `json:"addr"`
+	Inbound  bool   `json:"inbound"`
+	BanScore uint32 `json:"banscore"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// NewPeerEventNtfn returns a new instance which can be used to issue a
+// peerevent JSON-RPC notification.  The reason is empty for events other than
+// misbehavior and disconnects.
+func NewPeerEventNtfn(eventType string, time int64, id int32, addr string, inbound bool, banScore uint32, reason string) *PeerEventNtfn {
+	return &PeerEventNtfn{
+		Type:     eventType,
+		Time:     time,
+		ID:       id,
+		Addr:     addr,
+		Inbound:  inbound,
+		BanScore: banScore,
+		Reason:   reason,
+	}
+}
+
 func init() {
 	// The commands in this file are only usable by websockets and are
 	// notifications.
@@ -301,4 +414,8 @@ func init() {
 	MustRegisterCmd(TxAcceptedNtfnMethod, (*TxAcceptedNtfn)(nil), flags)
 	MustRegisterCmd(TxAcceptedVerboseNtfnMethod, (*TxAcceptedVerboseNtfn)(nil), flags)
 	MustRegisterCmd(RelevantTxAcceptedNtfnMethod, (*RelevantTxAcceptedNtfn)(nil), flags)
+	MustRegisterCmd(WatchedActivityNtfnMethod, (*WatchedActivityNtfn)(nil), flags)
+	MustRegisterCmd(AdminTransactionNtfnMethod, (*AdminTransactionNtfn)(nil), flags)
+	MustRegisterCmd(AdminAlertNtfnMethod, (*AdminAlertNtfn)(nil), flags)
+	MustRegisterCmd(PeerEventNtfnMethod, (*PeerEventNtfn)(nil), flags)
 }
@@ -225,6 +225,107 @@ func TestChainSvrWsNtfns(t *testing.T) {
 				Transaction: "001122",
 			},
 		},
+		{
+			name: "watchedactivity",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("watchedactivity", "1Address", "123", 1.5, 0.0,
+					`{"height":100000,"hash":"123","index":2,"time":12345678}`)
+			},
+			staticNtfn: func() interface{} {
+				blockDetails := &btcjson.BlockDetails{
+					Height: 100000,
+					Hash:   "123",
+					Index:  2,
+					Time:   12345678,
+				}
+				return btcjson.NewWatchedActivityNtfn("1Address", "123", 1.5, 0,
+					blockDetails)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"watchedactivity","params":["1Address","123",1.5,0,{"height":100000,"hash":"123","index":2,"time":12345678}],"id":null}`,
+			unmarshalled: &btcjson.WatchedActivityNtfn{
+				Address:  "1Address",
+				TxID:     "123",
+				Received: 1.5,
+				Sent:     0,
+				Block: &btcjson.BlockDetails{
+					Height: 100000,
+					Hash:   "123",
+					Index:  2,
+					Time:   12345678,
+				},
+			},
+		},
+		{
+			name: "admintransaction",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("admintransaction", "123", "issue",
+					`[{"type":"issue","height":0,"txid":"123","vout":1,"thread":"issue","value":1.5}]`)
+			},
+			staticNtfn: func() interface{} {
+				ops := []btcjson.AdminOperationResult{{
+					Type:   "issue",
+					Txid:   "123",
+					Vout:   1,
+					Thread: "issue",
+					Value:  1.5,
+				}}
+				return btcjson.NewAdminTransactionNtfn("123", "issue", ops, nil)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"admintransaction","params":["123","issue",[{"type":"issue","height":0,"txid":"123","vout":1,"thread":"issue","value":1.5}]],"id":null}`,
+			unmarshalled: &btcjson.AdminTransactionNtfn{
+				TxID:   "123",
+				Thread: "issue",
+				Operations: []btcjson.AdminOperationResult{{
+					Type:   "issue",
+					Txid:   "123",
+					Vout:   1,
+					Thread: "issue",
+					Value:  1.5,
+				}},
+			},
+		},
+		{
+			name: "adminalert",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("adminalert", "conflict", "123",
+					"root", "reason", "456")
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewAdminAlertNtfn("conflict", "123",
+					"root", "reason", btcjson.String("456"))
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"adminalert","params":["conflict","123","root","reason","456"],"id":null}`,
+			unmarshalled: &btcjson.AdminAlertNtfn{
+				Type:        "conflict",
+				TxID:        "123",
+				Thread:      "root",
+				Reason:      "reason",
+				PendingTxID: btcjson.String("456"),
+			},
+		},
+		{
+			name: "peerevent",
+			newNtfn: func() (interface{}, error) {
+				return btcjson.NewCmd("peerevent", "misbehavior",
+					12345678, 3, "127.0.0.1:7979", true, 20,
+					"reason")
+			},
+			staticNtfn: func() interface{} {
+				return btcjson.NewPeerEventNtfn("misbehavior",
+					12345678, 3, "127.0.0.1:7979", true, 20,
+					"reason")
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"peerevent","params":["misbehavior",12345678,3,"127.0.0.1:7979",true,20,"reason"],"id":null}`,
+			unmarshalled: &btcjson.PeerEventNtfn{
+				Type:     "misbehavior",
+				Time:     12345678,
+				ID:       3,
+				Addr:     "127.0.0.1:7979",
+				Inbound:  true,
+				BanScore: 20,
+				Reason:   "reason",
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -24,9 +24,26 @@ func NewSetValidateKeysCmd(privKeys []string) *SetValidateKeysCmd {
 	}
 }
 
+// SimulateChainCmd defines the simulatechain JSON-RPC command.
+// This command is not a standard command, it is an extension for testing
+// prova.
+type SimulateChainCmd struct {
+	Scenario string
+}
+
+// NewSimulateChainCmd returns a new SimulateChainCmd which can be used to
+// issue a simulatechain JSON-RPC command.  This command is not a standard
+// command. It is an extension for prova.
+func NewSimulateChainCmd(scenario string) *SimulateChainCmd {
+	return &SimulateChainCmd{
+		Scenario: scenario,
+	}
+}
+
 func init() {
 	// No special flags for commands in this file.
 	flags := UsageFlag(0)
 
 	MustRegisterCmd("setvalidatekeys", (*SetValidateKeysCmd)(nil), flags)
+	MustRegisterCmd("simulatechain", (*SimulateChainCmd)(nil), flags)
 }
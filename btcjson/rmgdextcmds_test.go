@@ -43,6 +43,19 @@ func TestRmgdExtCmds(t *testing.T) {
 				PrivKeys: []string{"1234"},
 			},
 		},
+		{
+			name: "simulatechain",
+			newCmd: func() (interface{}, error) {
+				return btcjson.NewCmd("simulatechain", `{"seed":1}`)
+			},
+			staticCmd: func() interface{} {
+				return btcjson.NewSimulateChainCmd(`{"seed":1}`)
+			},
+			marshalled: `{"jsonrpc":"1.0","method":"simulatechain","params":["{\"seed\":1}"],"id":1}`,
+			unmarshalled: &btcjson.SimulateChainCmd{
+				Scenario: `{"seed":1}`,
+			},
+		},
 	}
 
 	t.Logf("Running %d tests", len(tests))
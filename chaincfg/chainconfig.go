@@ -0,0 +1,395 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// minRootKeySetSize is the least amount of root keys a custom network needs,
+// since root thread transactions are signed by two of them.
+const minRootKeySetSize = 2
+
+// GenesisConfig describes the header of the genesis block of a custom network.
+// The genesis block holds the same coinbase transaction as the default
+// networks, which creates the admin threads.
+type GenesisConfig struct {
+	// Timestamp is the time of the genesis block as a unix timestamp.
+	Timestamp int64 `json:"timestamp"`
+
+	// Bits is the difficulty target of the genesis block in compact form.
+	// It defaults to the proof of work limit of the network.
+	Bits uint32 `json:"bits"`
+
+	// Nonce is the nonce of the genesis block.
+	Nonce uint64 `json:"nonce"`
+}
+
+// ChainConfig describes a custom Prova network as decoded from a JSON chain
+// config file, so private deployments are able to define their own network
+// without changing the default networks.  The fields which are omitted from
+// the file take the values of the main network, except for the DNS seeds.
+type ChainConfig struct {
+	// Name is the human-readable identifier of the network, which also
+	// names its data and log directories.
+	Name string `json:"name"`
+
+	// Net is the magic number identifying the messages of the network.
+	Net uint32 `json:"net"`
+
+	// Port and RPCPort are the default peer-to-peer and RPC ports of the
+	// network.  The RPC port of the main network is used when RPCPort is
+	// empty.
+	Port    string `json:"port"`
+	RPCPort string `json:"rpcport"`
+
+	// DNSSeeds are the hostnames of the DNS seeds of the network.
+	DNSSeeds []string `json:"dnsseeds"`
+
+	// Genesis describes the genesis block of the network.
+	Genesis GenesisConfig `json:"genesis"`
+
+	// RootKeys, ProvisionKeys, IssueKeys and ValidateKeys are the hex
+	// encoded compressed public keys of the initial admin key sets, and
+	// ASPKeys maps the initial ASP key IDs to their keys.
+	RootKeys      []string          `json:"rootkeys"`
+	ProvisionKeys []string          `json:"provisionkeys"`
+	IssueKeys     []string          `json:"issuekeys"`
+	ValidateKeys  []string          `json:"validatekeys"`
+	ASPKeys       map[uint32]string `json:"aspkeys"`
+
+	// PowLimitBits is the highest allowed proof of work value for a block
+	// in compact form.
+	PowLimitBits uint32 `json:"powlimitbits"`
+
+	// CoinbaseMaturity is the number of blocks required before coinbase
+	// outputs can be spent.
+	CoinbaseMaturity uint16 `json:"coinbasematurity"`
+
+	// TargetTimePerBlock is the desired number of seconds between blocks.
+	TargetTimePerBlock uint32 `json:"targettimeperblock"`
+
+	// GenerateSupported specifies whether or not CPU mining is allowed.
+	GenerateSupported bool `json:"generatesupported"`
+
+	// RelayNonStdTxs specifies whether or not non-standard transactions
+	// are relayed by default.
+	RelayNonStdTxs bool `json:"relaynonstdtxs"`
+
+	// ProvaAddrID and PrivateKeyID are the first bytes of the addresses and
+	// WIF private keys of the network.
+	ProvaAddrID  byte `json:"provaaddrid"`
+	PrivateKeyID byte `json:"privatekeyid"`
+
+	// HDPrivateKeyID and HDPublicKeyID are the hex encoded magics of the
+	// BIP32 extended keys of the network, and HDCoinType is its BIP44 coin
+	// type.
+	HDPrivateKeyID string `json:"hdprivatekeyid"`
+	HDPublicKeyID  string `json:"hdpublickeyid"`
+	HDCoinType     uint32 `json:"hdcointype"`
+
+	// PowAveragingWindow, PowMaxAdjustDown and PowMaxAdjustUp configure the
+	// difficulty adjustment.
+	PowAveragingWindow int   `json:"powaveragingwindow"`
+	PowMaxAdjustDown   int64 `json:"powmaxadjustdown"`
+	PowMaxAdjustUp     int64 `json:"powmaxadjustup"`
+
+	// ChainWindowMaxBlocks is the maximum number of blocks of the averaging
+	// window signed by a single validate key.
+	ChainWindowMaxBlocks int `json:"chainwindowmaxblocks"`
+
+	// MaximumFeeAmount is the maximum fee of a single transaction in atoms.
+	MaximumFeeAmount int64 `json:"maximumfeeamount"`
+
+	// MaxBlockSize is the maximum serialized size of a block in bytes.  It
+	// must not exceed the maximum payload of a block message.
+	MaxBlockSize int `json:"maxblocksize"`
+
+	// MinerConfirmationWindow is the number of blocks of each window the
+	// signaling of rule changes is counted in, and
+	// RuleChangeActivationThreshold is the number of blocks of a window
+	// which must signal a rule change for it to lock in.
+	MinerConfirmationWindow       uint32 `json:"minerconfirmationwindow"`
+	RuleChangeActivationThreshold uint32 `json:"rulechangeactivationthreshold"`
+
+	// TimelockHeight is the height of the first block which enforces the
+	// timelock deployment regardless of its signaling.  It defaults to
+	// never activating without being signaled.
+	TimelockHeight uint32 `json:"timelockheight"`
+
+	// TimelockStartTime and TimelockExpireTime are the median block times
+	// at which signaling the timelock deployment starts and fails when it
+	// was not locked in.
+	TimelockStartTime  uint64 `json:"timelockstarttime"`
+	TimelockExpireTime uint64 `json:"timelockexpiretime"`
+}
+
+// NewChainConfig returns a chain config with the parameters of the main
+// network and without a name, magic, ports, or admin keys.
+func NewChainConfig() *ChainConfig {
+	params := &MainNetParams
+	timelocks := &params.Deployments[DeploymentTimelocks]
+	return &ChainConfig{
+		PowLimitBits:         params.PowLimitBits,
+		CoinbaseMaturity:     params.CoinbaseMaturity,
+		TargetTimePerBlock:   uint32(params.TargetTimePerBlock / time.Second),
+		GenerateSupported:    params.GenerateSupported,
+		RelayNonStdTxs:       params.RelayNonStdTxs,
+		ProvaAddrID:          params.ProvaAddrID,
+		PrivateKeyID:         params.PrivateKeyID,
+		HDPrivateKeyID:       hex.EncodeToString(params.HDPrivateKeyID[:]),
+		HDPublicKeyID:        hex.EncodeToString(params.HDPublicKeyID[:]),
+		HDCoinType:           params.HDCoinType,
+		PowAveragingWindow:   params.PowAveragingWindow,
+		PowMaxAdjustDown:     params.PowMaxAdjustDown,
+		PowMaxAdjustUp:       params.PowMaxAdjustUp,
+		ChainWindowMaxBlocks: params.ChainWindowMaxBlocks,
+		MaximumFeeAmount:     params.MaximumFeeAmount,
+		MaxBlockSize:         wire.MaxBlockPayload,
+
+		MinerConfirmationWindow:       params.MinerConfirmationWindow,
+		RuleChangeActivationThreshold: params.RuleChangeActivationThreshold,
+		TimelockHeight:                timelocks.ActivationHeight,
+		TimelockStartTime:             timelocks.StartTime,
+		TimelockExpireTime:            timelocks.ExpireTime,
+	}
+}
+
+// DecodeChainConfig decodes a JSON chain config from the passed reader.  The
+// fields which are omitted take the values of NewChainConfig, and unknown
+// fields are rejected so misspelled parameters are not silently ignored.
+func DecodeChainConfig(r io.Reader) (*ChainConfig, error) {
+	config := NewChainConfig()
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// compactToBig converts a compact representation of a whole number to an
+// unsigned 256-bit number.  It is the same as blockchain.CompactToBig, which
+// is not able to be imported by this package.
+func compactToBig(compact uint32) *big.Int {
+	mantissa := compact & 0x007fffff
+	isNegative := compact&0x00800000 != 0
+	exponent := uint(compact >> 24)
+
+	var bn *big.Int
+	if exponent <= 3 {
+		mantissa >>= 8 * (3 - exponent)
+		bn = big.NewInt(int64(mantissa))
+	} else {
+		bn = big.NewInt(int64(mantissa))
+		bn.Lsh(bn, 8*(exponent-3))
+	}
+	if isNegative {
+		bn = bn.Neg(bn)
+	}
+	return bn
+}
+
+// parsePort ensures the passed port is a valid TCP port.
+func parsePort(name, port string) error {
+	n, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || n == 0 {
+		return fmt.Errorf("invalid %s %q", name, port)
+	}
+	return nil
+}
+
+// parseHDKeyID decodes the passed hex encoded extended key magic.
+func parseHDKeyID(name, s string) ([4]byte, error) {
+	var id [4]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(id) {
+		return id, fmt.Errorf("invalid %s %q: must be 4 hex encoded "+
+			"bytes", name, s)
+	}
+	copy(id[:], b)
+	return id, nil
+}
+
+// Params returns the network parameters described by the chain config.  An
+// error is returned when the config does not describe a usable network.  The
+// returned parameters still need to be registered with Register.
+func (c *ChainConfig) Params() (*Params, error) {
+	switch c.Name {
+	case "":
+		return nil, errors.New("the network has no name")
+	case MainNetParams.Name, RegressionNetParams.Name, TestNetParams.Name,
+		SimNetParams.Name:
+		return nil, fmt.Errorf("the network name %q is used by a "+
+			"default network", c.Name)
+	}
+	if c.Net == 0 {
+		return nil, errors.New("the network has no magic")
+	}
+	if err := parsePort("port", c.Port); err != nil {
+		return nil, err
+	}
+	if c.RPCPort != "" {
+		if err := parsePort("rpcport", c.RPCPort); err != nil {
+			return nil, err
+		}
+	}
+	if c.PowAveragingWindow <= 0 {
+		return nil, errors.New("the averaging window must be positive")
+	}
+	if c.TargetTimePerBlock == 0 {
+		return nil, errors.New("the target time per block must be " +
+			"positive")
+	}
+	if c.MaxBlockSize <= 0 || c.MaxBlockSize > wire.MaxBlockPayload {
+		return nil, fmt.Errorf("the maximum block size must be between "+
+			"1 and %d", wire.MaxBlockPayload)
+	}
+	if c.MinerConfirmationWindow == 0 {
+		return nil, errors.New("the miner confirmation window must be " +
+			"positive")
+	}
+	if c.RuleChangeActivationThreshold == 0 ||
+		c.RuleChangeActivationThreshold > c.MinerConfirmationWindow {
+
+		return nil, fmt.Errorf("the rule change activation threshold "+
+			"must be between 1 and %d", c.MinerConfirmationWindow)
+	}
+	powLimit := compactToBig(c.PowLimitBits)
+	if powLimit.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid proof of work limit bits %08x",
+			c.PowLimitBits)
+	}
+	hdPrivateKeyID, err := parseHDKeyID("hdprivatekeyid", c.HDPrivateKeyID)
+	if err != nil {
+		return nil, err
+	}
+	hdPublicKeyID, err := parseHDKeyID("hdpublickeyid", c.HDPublicKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the initial admin key sets and ensure there are enough root
+	// keys to sign root thread transactions and enough validate keys to
+	// sign blocks without exceeding their share of the averaging window.
+	keySets := make(map[btcec.KeySetType]btcec.PublicKeySet)
+	for keySetType, keys := range map[btcec.KeySetType][]string{
+		btcec.RootKeySet:      c.RootKeys,
+		btcec.ProvisionKeySet: c.ProvisionKeys,
+		btcec.IssueKeySet:     c.IssueKeys,
+		btcec.ValidateKeySet:  c.ValidateKeys,
+	} {
+		keySet, err := btcec.ParsePubKeySet(btcec.S256(), keys...)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %v keys: %v", keySetType,
+				err)
+		}
+		keySets[keySetType] = keySet
+	}
+	if len(keySets[btcec.RootKeySet]) < minRootKeySetSize {
+		return nil, fmt.Errorf("the network needs at least %d root keys",
+			minRootKeySetSize)
+	}
+	params := &Params{
+		Name:                     c.Name,
+		Net:                      wire.BitcoinNet(c.Net),
+		DefaultPort:              c.Port,
+		AdminKeySets:             keySets,
+		PowLimit:                 powLimit,
+		PowLimitBits:             c.PowLimitBits,
+		CoinbaseMaturity:         c.CoinbaseMaturity,
+		SubsidyReductionInterval: MainNetParams.SubsidyReductionInterval,
+		TargetTimePerBlock:       time.Duration(c.TargetTimePerBlock) * time.Second,
+		GenerateSupported:        c.GenerateSupported,
+		Checkpoints:              []Checkpoint{},
+		BlockEnforceNumRequired:  MainNetParams.BlockEnforceNumRequired,
+		BlockRejectNumRequired:   MainNetParams.BlockRejectNumRequired,
+		BlockUpgradeNumToCheck:   MainNetParams.BlockUpgradeNumToCheck,
+		RelayNonStdTxs:           c.RelayNonStdTxs,
+		ProvaAddrID:              c.ProvaAddrID,
+		PrivateKeyID:             c.PrivateKeyID,
+		HDPrivateKeyID:           hdPrivateKeyID,
+		HDPublicKeyID:            hdPublicKeyID,
+		HDCoinType:               c.HDCoinType,
+		PowAveragingWindow:       c.PowAveragingWindow,
+		PowMaxAdjustDown:         c.PowMaxAdjustDown,
+		PowMaxAdjustUp:           c.PowMaxAdjustUp,
+		ChainWindowMaxBlocks:     c.ChainWindowMaxBlocks,
+		MaximumFeeAmount:         c.MaximumFeeAmount,
+		MaxBlockSize:             c.MaxBlockSize,
+
+		RuleChangeActivationThreshold: c.RuleChangeActivationThreshold,
+		MinerConfirmationWindow:       c.MinerConfirmationWindow,
+	}
+	params.Deployments = MainNetParams.Deployments
+	params.Deployments[DeploymentTimelocks].ActivationHeight = c.TimelockHeight
+	params.Deployments[DeploymentTimelocks].StartTime = c.TimelockStartTime
+	params.Deployments[DeploymentTimelocks].ExpireTime = c.TimelockExpireTime
+	numValidateKeys := len(keySets[btcec.ValidateKeySet])
+	if numValidateKeys == 0 || (params.ChainWindowMaxBlocks > 0 &&
+		numValidateKeys < params.MinValidateKeySetSize()) {
+
+		return nil, fmt.Errorf("the network has %d validate keys, but "+
+			"needs at least %d to progress", numValidateKeys,
+			params.MinValidateKeySetSize())
+	}
+
+	params.ASPKeyIdMap = make(btcec.KeyIdMap, len(c.ASPKeys))
+	for keyID, key := range c.ASPKeys {
+		if keyID == 0 {
+			return nil, errors.New("ASP key ID 0 is not valid")
+		}
+		serialized, err := hex.DecodeString(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ASP key of key ID %d: %v",
+				keyID, err)
+		}
+		pubKey, err := btcec.ParsePubKey(serialized, btcec.S256())
+		if err != nil {
+			return nil, fmt.Errorf("invalid ASP key of key ID %d: %v",
+				keyID, err)
+		}
+		params.ASPKeyIdMap[btcec.KeyID(keyID)] = pubKey
+	}
+
+	for _, host := range c.DNSSeeds {
+		params.DNSSeeds = append(params.DNSSeeds, DNSSeed{Host: host})
+	}
+
+	// The genesis block holds the coinbase transaction of the default
+	// networks, which creates the admin threads.
+	genesisBits := c.Genesis.Bits
+	if genesisBits == 0 {
+		genesisBits = c.PowLimitBits
+	}
+	genesis := &wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:    4,
+			PrevBlock:  chainhash.Hash{},
+			MerkleRoot: genesisMerkleRoot,
+			Timestamp:  time.Unix(c.Genesis.Timestamp, 0),
+			Bits:       genesisBits,
+			Nonce:      c.Genesis.Nonce,
+		},
+		Transactions: []*wire.MsgTx{&genesisCoinbaseTx},
+	}
+	genesis.Header.Size = uint32(genesis.SerializeSize())
+	genesisHash := genesis.Header.BlockHash()
+	params.GenesisBlock = genesis
+	params.GenesisHash = &genesisHash
+
+	return params, nil
+}
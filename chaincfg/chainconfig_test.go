@@ -0,0 +1,227 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chaincfg_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	. "github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/wire"
+)
+
+// testChainConfig is a chain config which describes a valid custom network.
+const testChainConfig = `{
+	"name": "chainconfigtest",
+	"net": 1836674676,
+	"port": "17979",
+	"dnsseeds": ["seed.example.com"],
+	"genesis": {"timestamp": 1483228800, "nonce": 7},
+	"rootkeys": [
+		"025ceeba2ab4a635df2c0301a3d773da06ac5a18a7c3e0d09a795d7e57d233edf1",
+		"038ef4a121bcaf1b1f175557a12896f8bc93b095e84817f90e9a901cd2113a8202"
+	],
+	"validatekeys": [
+		"03133752072c8bc132679655c671b7953c2edabc575f42d60fa2e4caac09770061",
+		"02ab82d1531552c5b67e528047542ff9a2550ee4df7a88d67037754069db0541f9"
+	],
+	"aspkeys": {
+		"1": "025ceeba2ab4a635df2c0301a3d773da06ac5a18a7c3e0d09a795d7e57d233edf1"
+	},
+	"powlimitbits": 545259519,
+	"targettimeperblock": 60,
+	"provaaddrid": 88,
+	"chainwindowmaxblocks": 16,
+	"maxblocksize": 1000000,
+	"minerconfirmationwindow": 100,
+	"rulechangeactivationthreshold": 90,
+	"timelockheight": 500,
+	"timelockstarttime": 1500000000
+}`
+
+// TestChainConfig ensures a chain config decodes into the network parameters
+// it describes, with the parameters it omits taken from the main network.
+func TestChainConfig(t *testing.T) {
+	config, err := DecodeChainConfig(strings.NewReader(testChainConfig))
+	if err != nil {
+		t.Fatalf("DecodeChainConfig: %v", err)
+	}
+	params, err := config.Params()
+	if err != nil {
+		t.Fatalf("Params: %v", err)
+	}
+
+	if params.Name != "chainconfigtest" || params.Net != 1836674676 ||
+		params.DefaultPort != "17979" {
+
+		t.Errorf("unexpected network %q (%d) on port %s", params.Name,
+			params.Net, params.DefaultPort)
+	}
+	if len(params.DNSSeeds) != 1 ||
+		params.DNSSeeds[0].Host != "seed.example.com" {
+
+		t.Errorf("unexpected DNS seeds %v", params.DNSSeeds)
+	}
+	if len(params.AdminKeySets[btcec.RootKeySet]) != 2 ||
+		len(params.AdminKeySets[btcec.ValidateKeySet]) != 2 ||
+		len(params.AdminKeySets[btcec.IssueKeySet]) != 0 {
+
+		t.Errorf("unexpected admin key sets %v", params.AdminKeySets)
+	}
+	if len(params.ASPKeyIdMap) != 1 || params.ASPKeyIdMap[1] == nil {
+		t.Errorf("unexpected ASP keys %v", params.ASPKeyIdMap)
+	}
+	if params.TargetTimePerBlock != time.Minute {
+		t.Errorf("got target time per block %v, want %v",
+			params.TargetTimePerBlock, time.Minute)
+	}
+	if params.PowLimit.BitLen() != 255 {
+		t.Errorf("unexpected proof of work limit %064x", params.PowLimit)
+	}
+	if params.BlockSizeLimit() != 1000000 {
+		t.Errorf("got block size limit %d, want %d",
+			params.BlockSizeLimit(), 1000000)
+	}
+	if params.ProvaAddrID != 88 || params.HDCoinType != 0 ||
+		params.CoinbaseMaturity != MainNetParams.CoinbaseMaturity ||
+		params.PowAveragingWindow != MainNetParams.PowAveragingWindow {
+
+		t.Errorf("unexpected parameters %+v", params)
+	}
+
+	if params.IsDeploymentActive(DeploymentTimelocks, 499) ||
+		!params.IsDeploymentActive(DeploymentTimelocks, 500) {
+
+		t.Errorf("unexpected timelock deployment %+v",
+			params.Deployments[DeploymentTimelocks])
+	}
+	timelocks := params.Deployments[DeploymentTimelocks]
+	if params.MinerConfirmationWindow != 100 ||
+		params.RuleChangeActivationThreshold != 90 ||
+		timelocks.StartTime != 1500000000 ||
+		timelocks.ExpireTime != MainNetParams.Deployments[DeploymentTimelocks].ExpireTime {
+
+		t.Errorf("unexpected deployment signaling %d/%d %+v",
+			params.RuleChangeActivationThreshold,
+			params.MinerConfirmationWindow, timelocks)
+	}
+
+	// The genesis block holds the coinbase transaction of the default
+	// networks and has the described header.
+	genesis := params.GenesisBlock
+	header := &genesis.Header
+	if header.Timestamp.Unix() != 1483228800 || header.Nonce != 7 ||
+		header.Bits != params.PowLimitBits {
+
+		t.Errorf("unexpected genesis header %+v", header)
+	}
+	if int(header.Size) != genesis.SerializeSize() {
+		t.Errorf("got genesis size %d, want %d", header.Size,
+			genesis.SerializeSize())
+	}
+	if header.MerkleRoot != MainNetParams.GenesisBlock.Header.MerkleRoot {
+		t.Errorf("got genesis merkle root %v, want %v",
+			header.MerkleRoot,
+			MainNetParams.GenesisBlock.Header.MerkleRoot)
+	}
+	if hash := header.BlockHash(); !params.GenesisHash.IsEqual(&hash) {
+		t.Errorf("got genesis hash %v, want %v", params.GenesisHash,
+			hash)
+	}
+
+	// The network is registered once.
+	if err := Register(params); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := Register(params); err != ErrDuplicateNet {
+		t.Errorf("Register: got %v, want %v", err, ErrDuplicateNet)
+	}
+}
+
+// TestChainConfigErrors ensures chain configs which do not describe a usable
+// network are rejected.
+func TestChainConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		replace []string
+	}{
+		{
+			name:    "unknown field",
+			replace: []string{`"port"`, `"peerport"`},
+		},
+		{
+			name:    "default network name",
+			replace: []string{`"chainconfigtest"`, `"regtest"`},
+		},
+		{
+			name:    "missing magic",
+			replace: []string{`1836674676`, `0`},
+		},
+		{
+			name:    "invalid port",
+			replace: []string{`"17979"`, `"port"`},
+		},
+		{
+			name: "single root key",
+			replace: []string{",\n\t\t\"038ef4a121bcaf1b1f175557a1289" +
+				"6f8bc93b095e84817f90e9a901cd2113a8202\"", ""},
+		},
+		{
+			name:    "invalid validate key",
+			replace: []string{`"03133752`, `"05133752`},
+		},
+		{
+			name:    "too few validate keys",
+			replace: []string{`"chainwindowmaxblocks": 16`, `"chainwindowmaxblocks": 15`},
+		},
+		{
+			name:    "too large blocks",
+			replace: []string{`1000000`, `2500001`},
+		},
+		{
+			name:    "invalid extended key magic",
+			replace: []string{`"maxblocksize"`, `"hdpublickeyid": "0488", "maxblocksize"`},
+		},
+		{
+			name:    "activation threshold exceeds window",
+			replace: []string{`"rulechangeactivationthreshold": 90`, `"rulechangeactivationthreshold": 101`},
+		},
+		{
+			name:    "empty confirmation window",
+			replace: []string{`"minerconfirmationwindow": 100`, `"minerconfirmationwindow": 0`},
+		},
+		{
+			name:    "invalid ASP key ID",
+			replace: []string{`"1": "025c`, `"0": "025c`},
+		},
+	}
+
+	for _, test := range tests {
+		if !strings.Contains(testChainConfig, test.replace[0]) {
+			t.Fatalf("%s: chain config does not contain %s", test.name,
+				test.replace[0])
+		}
+		s := strings.Replace(testChainConfig, test.replace[0],
+			test.replace[1], 1)
+		config, err := DecodeChainConfig(strings.NewReader(s))
+		if err == nil {
+			_, err = config.Params()
+		}
+		if err == nil {
+			t.Errorf("%s: unexpected success", test.name)
+		}
+	}
+
+	// The default chain config lacks the parameters every network needs.
+	if _, err := NewChainConfig().Params(); err == nil {
+		t.Error("NewChainConfig: unexpected success")
+	}
+	if NewChainConfig().MaxBlockSize != wire.MaxBlockPayload {
+		t.Errorf("NewChainConfig: got max block size %d, want %d",
+			NewChainConfig().MaxBlockSize, wire.MaxBlockPayload)
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package chainhash
+
+import (
+	"testing"
+)
+
+// TestSipHash24 ensures SipHash24 returns the expected values for the test
+// vectors of the SipHash reference implementation.
+func TestSipHash24(t *testing.T) {
+	// The reference vectors use the key 00 01 02 ... 0f and the message
+	// 00 01 02 ... of the given length.
+	const k0, k1 = 0x0706050403020100, 0x0f0e0d0c0b0a0908
+	tests := []struct {
+		length int
+		want   uint64
+	}{
+		{0, 0x726fdb47dd0e0e31},
+		{1, 0x74f839c593dc67fd},
+		{7, 0xab0200f58b01d137},
+		{8, 0x93f5f5799a932462},
+		{15, 0xa129ca6149be45e5},
+	}
+
+	for i, test := range tests {
+		data := make([]byte, test.length)
+		for j := range data {
+			data[j] = byte(j)
+		}
+		if got := SipHash24(k0, k1, data); got != test.want {
+			t.Errorf("SipHash24 #%d: got %x, want %x", i, got,
+				test.want)
+		}
+	}
+}
@@ -53,6 +53,14 @@ type Checkpoint struct {
 	Hash   *chainhash.Hash
 }
 
+// FilterHeaderCheckpoint identifies the known good filter header of the basic
+// compact filter of the main chain block at a height.  Light clients and
+// rescans use them to verify the chains of filter headers served to them.
+type FilterHeaderCheckpoint struct {
+	Height uint32
+	Header *chainhash.Hash
+}
+
 // DNSSeed identifies a DNS seed.
 type DNSSeed struct {
 	// Host defines the hostname of the seed.
@@ -63,6 +71,51 @@ type DNSSeed struct {
 	HasFiltering bool
 }
 
+// Constants that define the deployment offset in the deployments field of the
+// parameters for each deployment.
+const (
+	// DeploymentTimelocks defines the rule change deployment which enforces
+	// OP_CHECKLOCKTIMEVERIFY, OP_CHECKSEQUENCEVERIFY, and the relative lock
+	// times of transaction inputs, and allows outputs to timelocked Prova
+	// scripts.
+	DeploymentTimelocks = iota
+
+	// NOTE: DefinedDeployments must always come last since it is used to
+	// determine how many defined deployments there currently are.
+
+	// DefinedDeployments is the number of currently defined deployments.
+	DefinedDeployments
+)
+
+// DeploymentNeverActive is the activation height of a deployment which only
+// activates once it is signaled.
+const DeploymentNeverActive = math.MaxUint32
+
+// DeploymentNeverStarts is the start time of a deployment which is defined but
+// not scheduled, so it can never be signaled.
+const DeploymentNeverStarts = math.MaxInt64
+
+// ConsensusDeployment defines a rule change which becomes active either once
+// the validators signaled it by the versions of the blocks they signed, in the
+// style of BIP0009, or at a fixed block height.
+type ConsensusDeployment struct {
+	// BitNumber is the bit of the block version which signals the rule
+	// change.
+	BitNumber uint8
+
+	// StartTime is the median block time after which signaling the rule
+	// change starts.
+	StartTime uint64
+
+	// ExpireTime is the median block time after which the rule change
+	// fails when it was not locked in yet.
+	ExpireTime uint64
+
+	// ActivationHeight is the height of the first block the rule change
+	// applies to regardless of its signaling.
+	ActivationHeight uint32
+}
+
 // Params defines a Bitcoin network by its parameters.  These parameters may be
 // used by Bitcoin applications to differentiate networks as well as addresses
 // and keys for one network from those intended for use on another network.
@@ -118,6 +171,10 @@ type Params struct {
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints []Checkpoint
 
+	// FilterHeaderCheckpoints are the filter header checkpoints ordered
+	// from oldest to newest.
+	FilterHeaderCheckpoints []FilterHeaderCheckpoint
+
 	// Enforce current block version once network has
 	// upgraded.  This is part of BIP0034.
 	BlockEnforceNumRequired uint64
@@ -129,6 +186,19 @@ type Params struct {
 	// The number of nodes to check.  This is part of BIP0034.
 	BlockUpgradeNumToCheck uint64
 
+	// RuleChangeActivationThreshold is the number of blocks of a miner
+	// confirmation window which must signal a rule change for it to lock
+	// in.
+	RuleChangeActivationThreshold uint32
+
+	// MinerConfirmationWindow is the number of blocks of each window the
+	// signaling of rule changes is counted in.
+	MinerConfirmationWindow uint32
+
+	// Deployments define the rule changes of the network and how they
+	// become active.
+	Deployments [DefinedDeployments]ConsensusDeployment
+
 	// Mempool parameters
 	RelayNonStdTxs bool
 
@@ -158,6 +228,24 @@ type Params struct {
 
 	// Maximum fee allowed in a single transaction, in atoms.
 	MaximumFeeAmount int64
+
+	// Maximum serialized size of a block, in bytes.  The maximum payload
+	// of a block message is used when it is zero.
+	MaxBlockSize int
+}
+
+// BlockSizeLimit returns the maximum serialized size of a block, in bytes.
+func (p Params) BlockSizeLimit() int {
+	if p.MaxBlockSize > 0 && p.MaxBlockSize < wire.MaxBlockPayload {
+		return p.MaxBlockSize
+	}
+	return wire.MaxBlockPayload
+}
+
+// IsDeploymentActive returns whether the rule change of the passed deployment
+// applies to the block at the passed height regardless of its signaling.
+func (p Params) IsDeploymentActive(deploymentID int, height uint32) bool {
+	return height >= p.Deployments[deploymentID].ActivationHeight
 }
 
 // MaxActualTimespan returns a timespan with the down-dampening factor applied.
@@ -252,6 +340,9 @@ var MainNetParams = Params{
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: []Checkpoint{},
 
+	// Filter header checkpoints ordered from oldest to newest.
+	FilterHeaderCheckpoints: []FilterHeaderCheckpoint{},
+
 	// Enforce current block version once majority of the network has
 	// upgraded.
 	// 75% (750 / 1000)
@@ -262,6 +353,19 @@ var MainNetParams = Params{
 	BlockRejectNumRequired:  950,
 	BlockUpgradeNumToCheck:  1000,
 
+	// Consensus rule change deployments.  A rule change locks in once
+	// 95% (1916 / 2016) of the blocks of a confirmation window signal it.
+	RuleChangeActivationThreshold: 1916,
+	MinerConfirmationWindow:       2016,
+	Deployments: [DefinedDeployments]ConsensusDeployment{
+		DeploymentTimelocks: {
+			BitNumber:        0,
+			StartTime:        DeploymentNeverStarts,
+			ExpireTime:       math.MaxInt64,
+			ActivationHeight: DeploymentNeverActive,
+		},
+	},
+
 	// Mempool parameters
 	RelayNonStdTxs: false,
 
@@ -364,6 +468,19 @@ var RegressionNetParams = Params{
 	BlockRejectNumRequired:  950,
 	BlockUpgradeNumToCheck:  1000,
 
+	// Consensus rule change deployments.  A rule change locks in once
+	// 75% (108 / 144) of the blocks of a confirmation window signal it.
+	RuleChangeActivationThreshold: 108,
+	MinerConfirmationWindow:       144,
+	Deployments: [DefinedDeployments]ConsensusDeployment{
+		DeploymentTimelocks: {
+			BitNumber:        0,
+			StartTime:        0,
+			ExpireTime:       math.MaxInt64,
+			ActivationHeight: 0,
+		},
+	},
+
 	// Mempool parameters
 	RelayNonStdTxs: true,
 
@@ -459,6 +576,9 @@ var TestNetParams = Params{
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: []Checkpoint{},
 
+	// Filter header checkpoints ordered from oldest to newest.
+	FilterHeaderCheckpoints: []FilterHeaderCheckpoint{},
+
 	// Enforce current block version once majority of the network has
 	// upgraded.
 	// 51% (51 / 100)
@@ -469,6 +589,19 @@ var TestNetParams = Params{
 	BlockRejectNumRequired:  75,
 	BlockUpgradeNumToCheck:  100,
 
+	// Consensus rule change deployments.  A rule change locks in once
+	// 75% (1512 / 2016) of the blocks of a confirmation window signal it.
+	RuleChangeActivationThreshold: 1512,
+	MinerConfirmationWindow:       2016,
+	Deployments: [DefinedDeployments]ConsensusDeployment{
+		DeploymentTimelocks: {
+			BitNumber:        0,
+			StartTime:        DeploymentNeverStarts,
+			ExpireTime:       math.MaxInt64,
+			ActivationHeight: DeploymentNeverActive,
+		},
+	},
+
 	// Mempool parameters
 	RelayNonStdTxs: true,
 
@@ -506,7 +639,9 @@ var TestNetParams = Params{
 // testing.  The functionality is intended to differ in that the only nodes
 // which are specifically specified are used to create the network rather than
 // following normal discovery rules.  This is important as otherwise it would
-// just turn into another public testnet.
+// just turn into another public testnet.  The private keys of its admin key
+// sets are well known, so simulations are able to sign blocks and admin
+// transactions.
 var SimNetParams = Params{
 	Name:        "simnet",
 	Net:         wire.SimNet,
@@ -514,8 +649,52 @@ var SimNetParams = Params{
 	DNSSeeds:    []DNSSeed{}, // NOTE: There must NOT be any seeds.
 
 	// Chain parameters
-	GenesisBlock:             &simNetGenesisBlock,
-	GenesisHash:              &simNetGenesisHash,
+	GenesisBlock: &simNetGenesisBlock,
+	GenesisHash:  &simNetGenesisHash,
+	AdminKeySets: func() map[btcec.KeySetType]btcec.PublicKeySet {
+		keySets := make(map[btcec.KeySetType]btcec.PublicKeySet)
+
+		// Root keys
+		keySets[btcec.RootKeySet], _ = btcec.ParsePubKeySet(btcec.S256(),
+			"02b41d9d3adf69f80e12aadac3658651849d500450ee85a7937ef0cfe01e850474", // priv 8579dff3a2b0c685b519d0a56df3199218c2c23fc220cabf23fe9437673698f1
+			"0347781f4ccc8e72440bb214e08454400ce3c1b5f3e9f77ee19fc4d281ba053a6b", // priv 2d758de881a00f4048867bb70831b4653fa75715c3352d00dfef8533966bff82
+		)
+
+		// Provision Keys
+		keySets[btcec.ProvisionKeySet], _ = btcec.ParsePubKeySet(btcec.S256(),
+			"037eef0466745453b735822207dd78e7a2e6246e2fb73630dedb78ccc2fe7a27ff", // priv 5be5469c0b3a09132be3dbcddce1282857fa427a3a44b9d3d15383a36d3726cb
+			"03fd833182fcaaa0fba28cd40e3fc23364fb29ebafb186d029d6ca35b84316cdf3", // priv 0134a5e18128d2010c5f36afdf71fdfaa24b4341b94d17724d6996fed17734ab
+		)
+
+		// Issue Keys
+		keySets[btcec.IssueKeySet], _ = btcec.ParsePubKeySet(btcec.S256(),
+			"03f8be1ae88e9d6b85defbb50f7e1a4a24003c76ed8d1806f68a310790bd838a9c", // priv f44207058ee9f840f506e122187fac5414fcf2f1fa9b38368389cab67860b03b
+			"02399372b6696e9b72a83d54fccd9403892ea86b8e6c51b81a6f5e3e1b8fe404b9", // priv cff74e91c46b13e1ec75f37741eb963fe3dabd3f893103f74555f3e2bfa3f3b2
+		)
+
+		// Validate keys.  There are enough of them to progress the chain
+		// within the limit of blocks per key of the averaging window.
+		keySets[btcec.ValidateKeySet], _ = btcec.ParsePubKeySet(btcec.S256(),
+			"02d1482d27c8a2d97d609a355dd922e8b0b98f26d0e7517c50442a0a38f2280c87", // priv 854489b28043cda72329662fa1094c52d0c1ff1efb3748229465373c1bf67d79
+			"032fad4650a5e5d2bcf51e22cadbadad83b832f3d472c042e8e10ab559d0c1dcd6", // priv 84ff4d96aa06ed3e3fb900eb4fe0f885f19d3b80511ef38a3b0f72dec111195e
+			"02ea2c41eec0d4a50c3905a3a06f4238cb6ee8c1b61c0206ca1a839abfe6b38605", // priv 7f70de8b7618661c7607d0bb68d85b12d87e831b09edf18a6c4a26a3471abd52
+			"03d136784ab77d6e2041087044881efdbbddfdcfded388eaa27106abbb3f7dd18d", // priv af9083fca5329d57480d6c55313f8b15723be3255ef7659e32e643c29741e524
+			"0392955ac65fcd1fea222543ca46ca5e35628cbfff9316664429c5ca94425105ae", // priv 5472ded5f897361d5d36d47e3364a013a9def19af2869e04317682d4ed5aa52b
+			"02dc244692369e35b74bafa2afcb5a1968151bc632b2b937caa3d073d137b2b521", // priv 37b15a967ae805f78292c72d32391ec77f3e01c17fdc2dd5cdd3eb1bd7df2f93
+			"02d1650fd3e2e7892c499ade9b7f2a7e93c2bc01ae61b20ce4a76842adbfbcbca6", // priv 7e443ba42fd3bfd5864ee98aa885d44b401656d60c807e23ac9242df4abb3689
+			"030fb85b19a4502eb41b57f8ebc96f9bc615ca7a4cbfeb22f3886f3e4c73366a4a", // priv b1866f958384f7397e746fff05759021e1cf5d140877cd5cbd589a68868c0ccd
+			"0246e3dc27c3d4474c75963debd9dca24a07ece6575aa107336bc3e596e2bb5a9b", // priv 1aa625c2f7fa27193e6beeadb471b6c0885ae506df9667517cc44edae56bb3e3
+			"02c117f8f93833c8f82ba51183d8664b79b2b4ee7e3cae4452427f1683d60b42f8", // priv 02a6c1d859fb87622942b444b4fdb77f67b12aaac722c5abd88a89b8433c9160
+			"0274f1e635e6c2d3aec8f9e538d459e727c9ea56ffca05d54f69f8d5d3c5bcdd2c", // priv 79572ef5c06b1484de6d35e0a7b6ea44784e4654932860e2ed08c066c1090395
+		)
+
+		return keySets
+	}(),
+	ASPKeyIdMap: func() btcec.KeyIdMap {
+		pubKey1, _ := btcec.ParsePubKey(hexToBytes("02b41d9d3adf69f80e12aadac3658651849d500450ee85a7937ef0cfe01e850474"), btcec.S256())
+		pubKey2, _ := btcec.ParsePubKey(hexToBytes("0347781f4ccc8e72440bb214e08454400ce3c1b5f3e9f77ee19fc4d281ba053a6b"), btcec.S256())
+		return map[btcec.KeyID]*btcec.PublicKey{btcec.KeyID(1): pubKey1, btcec.KeyID(2): pubKey2}
+	}(),
 	PowLimit:                 simNetPowLimit,
 	PowLimitBits:             0x207fffff,
 	CoinbaseMaturity:         100,
@@ -526,6 +705,9 @@ var SimNetParams = Params{
 	// Checkpoints ordered from oldest to newest.
 	Checkpoints: nil,
 
+	// Filter header checkpoints ordered from oldest to newest.
+	FilterHeaderCheckpoints: nil,
+
 	// Enforce current block version once majority of the network has
 	// upgraded.
 	// 51% (51 / 100)
@@ -536,11 +718,25 @@ var SimNetParams = Params{
 	BlockRejectNumRequired:  75,
 	BlockUpgradeNumToCheck:  100,
 
+	// Consensus rule change deployments.  A rule change locks in once
+	// 75% (108 / 144) of the blocks of a confirmation window signal it.
+	RuleChangeActivationThreshold: 108,
+	MinerConfirmationWindow:       144,
+	Deployments: [DefinedDeployments]ConsensusDeployment{
+		DeploymentTimelocks: {
+			BitNumber:        0,
+			StartTime:        0,
+			ExpireTime:       math.MaxInt64,
+			ActivationHeight: 0,
+		},
+	},
+
 	// Mempool parameters
 	RelayNonStdTxs: true,
 
 	// Address encoding magics
 	PrivateKeyID: 0x64, // starts with 4 (uncompressed) or F (compressed)
+	ProvaAddrID:  0x55, // starts with S
 
 	// BIP32 hierarchical deterministic extended key magics
 	HDPrivateKeyID: [4]byte{0x04, 0x20, 0xb9, 0x00}, // starts with sprv
@@ -80,3 +80,34 @@ func TestMinValidateKeySetSize(t *testing.T) {
 		t.Error(str)
 	}
 }
+
+// TestIsDeploymentActive ensures deployments become active at their activation
+// height, and that the timelock deployment only applies to the test networks
+// from their start.
+func TestIsDeploymentActive(t *testing.T) {
+	params := Params{}
+	params.Deployments[DeploymentTimelocks].ActivationHeight = 100
+	if params.IsDeploymentActive(DeploymentTimelocks, 99) {
+		t.Error("deployment active before its activation height")
+	}
+	if !params.IsDeploymentActive(DeploymentTimelocks, 100) {
+		t.Error("deployment not active at its activation height")
+	}
+
+	tests := []struct {
+		params *Params
+		active bool
+	}{
+		{&MainNetParams, false},
+		{&TestNetParams, false},
+		{&RegressionNetParams, true},
+		{&SimNetParams, true},
+	}
+	for _, test := range tests {
+		active := test.params.IsDeploymentActive(DeploymentTimelocks, 0)
+		if active != test.active {
+			t.Errorf("%s: got timelocks active %v, want %v",
+				test.params.Name, active, test.active)
+		}
+	}
+}
@@ -42,6 +42,8 @@ type config struct {
 	SimNet         bool   `long:"simnet" description:"Use the simulation test network"`
 	NumCandidates  int    `short:"n" long:"numcandidates" description:"Max num of checkpoint candidates to show {1-20}"`
 	UseGoOutput    bool   `short:"g" long:"gooutput" description:"Display the candidates using Go syntax that is ready to insert into the btcchain checkpoint list"`
+	CheckpointFile string `short:"o" long:"checkpointfile" description:"Write the candidates to the given checkpoint file signed with --signingkey"`
+	SigningKey     string `long:"signingkey" description:"Hex-encoded private key used to sign the checkpoint file"`
 }
 
 // validDbType returns whether or not dbType is a supported database type.
@@ -128,5 +130,14 @@ func loadConfig() (*config, []string, error) {
 		return nil, nil, err
 	}
 
+	// A checkpoint file must be signed.
+	if cfg.CheckpointFile != "" && cfg.SigningKey == "" {
+		str := "%s: The checkpointfile option requires a signingkey"
+		err := fmt.Errorf(str, "loadConfig")
+		fmt.Fprintln(os.Stderr, err)
+		parser.WriteHelp(os.Stderr)
+		return nil, nil, err
+	}
+
 	return &cfg, remainingArgs, nil
 }
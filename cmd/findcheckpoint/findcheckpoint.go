@@ -6,11 +6,13 @@
 package main
 
 import (
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/database"
@@ -70,50 +72,17 @@ func findCandidates(chain *blockchain.BlockChain, latestHash *chainhash.Hash) ([
 			checkpointConfirmations)
 	}
 
-	// For the first checkpoint, the required height is any block after the
-	// genesis block, so long as the chain has at least the required number
-	// of confirmations (which is enforced above).
-	if len(activeNetParams.Checkpoints) == 0 {
-		requiredHeight = 1
+	// Find the candidates after the latest checkpoint.
+	found, err := chain.CheckpointCandidates(cfg.NumCandidates)
+	if err != nil {
+		return nil, err
 	}
-
-	// Indeterminate progress setup.
-	numBlocksToTest := block.Height() - requiredHeight
-	progressInterval := (numBlocksToTest / 100) + 1 // min 1
-	fmt.Print("Searching for candidates")
-	defer fmt.Println()
-
-	// Loop backwards through the chain to find checkpoint candidates.
-	candidates := make([]*chaincfg.Checkpoint, 0, cfg.NumCandidates)
-	numTested := uint32(0)
-	for len(candidates) < cfg.NumCandidates && block.Height() > requiredHeight {
-		// Display progress.
-		if numTested%progressInterval == 0 {
-			fmt.Print(".")
-		}
-
-		// Determine if this block is a checkpoint candidate.
-		isCandidate, err := chain.IsCheckpointCandidate(block)
-		if err != nil {
-			return nil, err
-		}
-
-		// All checks passed, so this node seems like a reasonable
-		// checkpoint candidate.
-		if isCandidate {
-			checkpoint := chaincfg.Checkpoint{
-				Height: block.Height(),
-				Hash:   block.Hash(),
-			}
-			candidates = append(candidates, &checkpoint)
-		}
-
-		prevHash := &block.MsgBlock().Header.PrevBlock
-		block, err = chain.BlockByHash(prevHash)
-		if err != nil {
-			return nil, err
-		}
-		numTested++
+	candidates := make([]*chaincfg.Checkpoint, 0, len(found))
+	for i := range found {
+		candidates = append(candidates, &chaincfg.Checkpoint{
+			Height: found[i].Height,
+			Hash:   &found[i].Hash,
+		})
 	}
 	return candidates, nil
 }
@@ -133,6 +102,33 @@ func showCandidate(candidateNum int, checkpoint *chaincfg.Checkpoint) {
 
 }
 
+// writeCheckpointFile writes the passed checkpoint candidates, which are
+// ordered from newest to oldest, to the configured checkpoint file signed with
+// the configured signing key.
+func writeCheckpointFile(candidates []*chaincfg.Checkpoint) error {
+	keyBytes, err := hex.DecodeString(cfg.SigningKey)
+	if err != nil {
+		return err
+	}
+	key, _ := btcec.PrivKeyFromBytes(btcec.S256(), keyBytes)
+
+	checkpoints := make([]chaincfg.Checkpoint, 0, len(candidates))
+	for i := len(candidates) - 1; i >= 0; i-- {
+		checkpoints = append(checkpoints, *candidates[i])
+	}
+
+	f, err := os.Create(cfg.CheckpointFile)
+	if err != nil {
+		return err
+	}
+	err = blockchain.WriteCheckpointFile(f, checkpoints, key)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 func main() {
 	// Load configuration and parse command line.
 	tcfg, _, err := loadConfig()
@@ -183,4 +179,15 @@ func main() {
 	for i, checkpoint := range candidates {
 		showCandidate(i+1, checkpoint)
 	}
+
+	// Write the signed checkpoint file when requested.
+	if cfg.CheckpointFile != "" {
+		if err := writeCheckpointFile(candidates); err != nil {
+			fmt.Fprintln(os.Stderr, "Unable to write checkpoint file:",
+				err)
+			return
+		}
+		fmt.Printf("Wrote %d checkpoints to %s\n", len(candidates),
+			cfg.CheckpointFile)
+	}
 }
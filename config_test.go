@@ -7,6 +7,8 @@ import (
 	"regexp"
 	"runtime"
 	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
 )
 
 var (
@@ -70,3 +72,101 @@ func TestCreateDefaultConfigFile(t *testing.T) {
 		t.Error("Could not find rpcpass in generated default config file.")
 	}
 }
+
+// TestParseSubsystemLogFiles ensures subsystem log file specifications are
+// parsed into the expected files and invalid specifications are rejected.
+func TestParseSubsystemLogFiles(t *testing.T) {
+	logDir := filepath.Join("logs", "mainnet")
+	absFile, err := filepath.Abs("rpc.log")
+	if err != nil {
+		t.Fatalf("Failed obtaining absolute path: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		specs   []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "default and relative files",
+			specs: []string{"PEER", "CHAN=chain.log"},
+			want: map[string]string{
+				"PEER": filepath.Join(logDir, "peer.log"),
+				"CHAN": filepath.Join(logDir, "chain.log"),
+			},
+		},
+		{
+			name:  "absolute file",
+			specs: []string{"RPCS=" + absFile},
+			want:  map[string]string{"RPCS": absFile},
+		},
+		{
+			name:    "unknown subsystem",
+			specs:   []string{"NOPE"},
+			wantErr: true,
+		},
+		{
+			name:    "empty file",
+			specs:   []string{"PEER="},
+			wantErr: true,
+		},
+		{
+			name:    "duplicate subsystem",
+			specs:   []string{"PEER", "PEER=peer2.log"},
+			wantErr: true,
+		},
+		{
+			name:    "shared file",
+			specs:   []string{"PEER=net.log", "SRVR=net.log"},
+			wantErr: true,
+		},
+		{
+			name:    "main log file",
+			specs:   []string{"TXMP=" + defaultLogFilename},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := parseSubsystemLogFiles(test.specs, logDir)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: unexpected log files %v", test.name, got)
+			continue
+		}
+		for subsysID, want := range test.want {
+			if got[subsysID] != want {
+				t.Errorf("%s: unexpected log file for %s - got "+
+					"%s, want %s", test.name, subsysID,
+					got[subsysID], want)
+			}
+		}
+	}
+}
+
+// TestParseDeployments ensures the signaled deployments are looked up by the
+// names getblockchaininfo reports them by and unknown names are rejected.
+func TestParseDeployments(t *testing.T) {
+	ids, err := parseDeployments([]string{"timelocks"})
+	if err != nil {
+		t.Fatalf("parseDeployments: unexpected error: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != chaincfg.DeploymentTimelocks {
+		t.Fatalf("parseDeployments: got %v, want [%d]", ids,
+			chaincfg.DeploymentTimelocks)
+	}
+	if ids, err := parseDeployments(nil); err != nil || len(ids) != 0 {
+		t.Fatalf("parseDeployments: got %v, %v for no deployments",
+			ids, err)
+	}
+	if _, err := parseDeployments([]string{"segwit"}); err == nil {
+		t.Fatal("parseDeployments: expected error for unknown deployment")
+	}
+}
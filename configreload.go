@@ -0,0 +1,249 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bitgo/prova/provautil"
+	flags "github.com/btcsuite/go-flags"
+)
+
+// reloadableOptions are the long names of the configuration options which are
+// applied when the configuration is reloaded.  All other options only take
+// effect after a restart.
+var reloadableOptions = map[string]struct{}{
+	"debuglevel":       {},
+	"nobanning":        {},
+	"banduration":      {},
+	"banthreshold":     {},
+	"rpcmaxclients":    {},
+	"rpcmaxwebsockets": {},
+	"minrelaytxfee":    {},
+	"limitfreerelay":   {},
+	"relaypriority":    {},
+}
+
+var (
+	// loadedOptions holds the options as parsed from the config file and
+	// the command line when the configuration was loaded, before they were
+	// validated and adjusted.  Reloads compare the newly parsed options to
+	// it in order to find the changed options.
+	loadedOptions config
+
+	// reloadMtx serializes configuration reloads.
+	reloadMtx sync.Mutex
+
+	// activeSettings holds the *runtimeSettings which are currently in
+	// effect.
+	activeSettings atomic.Value
+)
+
+// runtimeSettings houses the values of reloadable options which are read by
+// the running server.  The settings are replaced as a whole on reload, so they
+// must not be modified once stored.
+type runtimeSettings struct {
+	disableBanning   bool
+	banDuration      time.Duration
+	banThreshold     uint32
+	rpcMaxClients    int
+	rpcMaxWebsockets int
+	minRelayTxFee    provautil.Amount
+}
+
+// storeRuntimeSettings makes the runtime settings of the passed options the
+// active settings.
+func storeRuntimeSettings(opts *config, minRelayTxFee provautil.Amount) {
+	activeSettings.Store(&runtimeSettings{
+		disableBanning:   opts.DisableBanning,
+		banDuration:      opts.BanDuration,
+		banThreshold:     opts.BanThreshold,
+		rpcMaxClients:    opts.RPCMaxClients,
+		rpcMaxWebsockets: opts.RPCMaxWebsockets,
+		minRelayTxFee:    minRelayTxFee,
+	})
+}
+
+// settings returns the runtime settings which are currently in effect.
+//
+// This function is safe for concurrent access.
+func settings() *runtimeSettings {
+	return activeSettings.Load().(*runtimeSettings)
+}
+
+// readConfigOptions parses the config file and the command line options in
+// the same way as loadConfig, but without validating or applying them.
+func readConfigOptions() (*config, error) {
+	// Pre-parse the command line options to find the config file.
+	preOpts := defaultConfig()
+	preParser := newConfigParser(&preOpts, &serviceOptions{}, flags.None)
+	preParser.Parse()
+
+	opts := defaultConfig()
+	parser := newConfigParser(&opts, &serviceOptions{}, flags.None)
+	if !(preOpts.RegressionTest || preOpts.SimNet) || preOpts.ConfigFile !=
+		defaultConfigFile {
+
+		err := flags.NewIniParser(parser).ParseFile(preOpts.ConfigFile)
+		if err != nil {
+			if _, ok := err.(*os.PathError); !ok {
+				return nil, fmt.Errorf("error parsing config "+
+					"file: %v", err)
+			}
+		}
+	}
+
+	// Don't add peers from the config file when in regression test mode.
+	if preOpts.RegressionTest && len(opts.AddPeers) > 0 {
+		opts.AddPeers = nil
+	}
+
+	// Parse command line options again to ensure they take precedence.
+	if _, err := parser.Parse(); err != nil {
+		return nil, err
+	}
+
+	return &opts, nil
+}
+
+// changedOptions returns the long names of the options which differ between
+// the passed configurations.
+func changedOptions(oldOpts, newOpts *config) []string {
+	oldValue := reflect.ValueOf(oldOpts).Elem()
+	newValue := reflect.ValueOf(newOpts).Elem()
+	optsType := oldValue.Type()
+
+	var changed []string
+	for i := 0; i < optsType.NumField(); i++ {
+		name := optsType.Field(i).Tag.Get("long")
+		if name == "" {
+			continue
+		}
+		if !reflect.DeepEqual(oldValue.Field(i).Interface(),
+			newValue.Field(i).Interface()) {
+
+			changed = append(changed, name)
+		}
+	}
+
+	return changed
+}
+
+// reloadConfig parses the config file and the command line options again and
+// applies the reloadable options which changed since the configuration was
+// last loaded.  It returns the names of the applied options along with the
+// names of the changed options which only take effect after a restart.  No
+// options are applied when any of the reloadable options is invalid.
+//
+// This function is safe for concurrent access.
+func (s *server) reloadConfig() ([]string, []string, error) {
+	reloadMtx.Lock()
+	defer reloadMtx.Unlock()
+
+	opts, err := readConfigOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var applied, requiresRestart []string
+	for _, name := range changedOptions(&loadedOptions, opts) {
+		if _, ok := reloadableOptions[name]; ok {
+			applied = append(applied, name)
+		} else {
+			requiresRestart = append(requiresRestart, name)
+		}
+	}
+	sort.Strings(applied)
+	sort.Strings(requiresRestart)
+	if len(applied) == 0 {
+		return applied, requiresRestart, nil
+	}
+
+	// Validate the reloadable options the same way as loadConfig before
+	// applying any of them.
+	levels, err := parseDebugLevels(opts.DebugLevel)
+	if err != nil {
+		return nil, nil, err
+	}
+	if opts.BanDuration < time.Second {
+		str := "The banduration option may not be less than 1s -- " +
+			"parsed [%v]"
+		return nil, nil, fmt.Errorf(str, opts.BanDuration)
+	}
+	minRelayTxFee, err := provautil.NewAmount(opts.MinRelayTxFee)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid minrelaytxfee: %v", err)
+	}
+
+	// Subsystems which are not given a level return to the default level
+	// just like at startup.
+	if opts.DebugLevel != loadedOptions.DebugLevel {
+		setLogLevels(defaultLogLevel)
+		for subsysID, logLevel := range levels {
+			setLogLevel(subsysID, logLevel)
+		}
+	}
+	storeRuntimeSettings(opts, minRelayTxFee)
+
+	// Update the relay policy of the mempool.  The mining policy keeps the
+	// minimum fee it was created with since block templates are generated
+	// concurrently.
+	policy := s.txMemPool.Policy()
+	policy.MinRelayTxFee = minRelayTxFee
+	policy.FreeTxRelayLimit = opts.FreeTxRelayLimit
+	policy.DisableRelayPriority = !opts.RelayPriority
+	s.txMemPool.SetPolicy(policy)
+
+	// Record the applied options so later reloads only report options
+	// which changed since.
+	loadedOptions.DebugLevel = opts.DebugLevel
+	loadedOptions.DisableBanning = opts.DisableBanning
+	loadedOptions.BanDuration = opts.BanDuration
+	loadedOptions.BanThreshold = opts.BanThreshold
+	loadedOptions.RPCMaxClients = opts.RPCMaxClients
+	loadedOptions.RPCMaxWebsockets = opts.RPCMaxWebsockets
+	loadedOptions.MinRelayTxFee = opts.MinRelayTxFee
+	loadedOptions.FreeTxRelayLimit = opts.FreeTxRelayLimit
+	loadedOptions.RelayPriority = opts.RelayPriority
+
+	return applied, requiresRestart, nil
+}
+
+// reloadHandler reloads the configuration whenever one of the reload signals
+// is received.  It must be run as a goroutine.
+func (s *server) reloadHandler() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, reloadSignals...)
+
+out:
+	for {
+		select {
+		case sig := <-sigChan:
+			srvrLog.Infof("Received signal (%s).  Reloading "+
+				"configuration...", sig)
+			applied, requiresRestart, err := s.reloadConfig()
+			if err != nil {
+				srvrLog.Errorf("Unable to reload configuration: "+
+					"%v", err)
+				continue
+			}
+			srvrLog.Infof("Reloaded configuration -- applied %v, "+
+				"requires restart %v", applied, requiresRestart)
+
+		case <-s.quit:
+			break out
+		}
+	}
+
+	signal.Stop(sigChan)
+	s.wg.Done()
+}
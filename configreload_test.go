@@ -0,0 +1,33 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestChangedOptions ensures the changed options are reported by their long
+// names.
+func TestChangedOptions(t *testing.T) {
+	t.Parallel()
+
+	oldOpts := defaultConfig()
+	newOpts := defaultConfig()
+	if changed := changedOptions(&oldOpts, &newOpts); len(changed) != 0 {
+		t.Fatalf("unexpected changed options %v", changed)
+	}
+
+	newOpts.BanDuration = time.Hour
+	newOpts.AddPeers = []string{"127.0.0.1"}
+	newOpts.minRelayTxFee = 1
+	changed := changedOptions(&oldOpts, &newOpts)
+	want := []string{"addpeer", "banduration"}
+	if !reflect.DeepEqual(changed, want) {
+		t.Fatalf("unexpected changed options - got %v, want %v",
+			changed, want)
+	}
+}
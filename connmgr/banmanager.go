@@ -0,0 +1,285 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// banListVersion is the version of the serialized ban list.
+const banListVersion = 1
+
+// ErrBanned is used to indicate that a connection to a banned address was
+// refused.
+var ErrBanned = errors.New("address is banned")
+
+// BanEntry describes a banned subnet along with the time the ban was created
+// and the time it expires.
+type BanEntry struct {
+	Subnet  *net.IPNet
+	Created time.Time
+	Until   time.Time
+}
+
+// serializedBanEntry is the form a ban entry is stored by in the ban list
+// file.
+type serializedBanEntry struct {
+	Subnet  string `json:"subnet"`
+	Created int64  `json:"created"`
+	Until   int64  `json:"until"`
+}
+
+// serializedBanList is the form the ban list file is stored by.
+type serializedBanList struct {
+	Version int                   `json:"version"`
+	Bans    []*serializedBanEntry `json:"bans"`
+}
+
+// BanManager keeps track of the banned subnets and persists them in a file so
+// the bans survive restarts.  Expired bans are ignored and dropped the next
+// time the ban list changes.
+type BanManager struct {
+	mtx     sync.Mutex
+	banFile string
+	bans    map[string]*BanEntry
+}
+
+// ParseSubnet parses a subnet in CIDR notation, or a single IP address which is
+// treated as the subnet which only contains it.  IPv4 subnets are normalized to
+// their 4-byte form.
+func ParseSubnet(s string) (*net.IPNet, error) {
+	var subnet *net.IPNet
+	if ip := net.ParseIP(s); ip != nil {
+		subnet = &net.IPNet{IP: ip, Mask: net.CIDRMask(8*net.IPv6len,
+			8*net.IPv6len)}
+	} else {
+		var err error
+		_, subnet, err = net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP address or subnet %q", s)
+		}
+	}
+
+	if ip4 := subnet.IP.To4(); ip4 != nil {
+		mask := subnet.Mask
+		if len(mask) == net.IPv6len {
+			mask = mask[net.IPv6len-net.IPv4len:]
+		}
+		subnet = &net.IPNet{IP: ip4, Mask: mask}
+	}
+	return subnet, nil
+}
+
+// addrIP returns the IP address of the passed network address, or nil when it
+// does not have one, such as for onion addresses.
+func addrIP(addr net.Addr) net.IP {
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+// sweep removes the expired bans.
+//
+// This function MUST be called with the ban manager lock held (for writes).
+func (bm *BanManager) sweep(now time.Time) {
+	for key, entry := range bm.bans {
+		if !now.Before(entry.Until) {
+			delete(bm.bans, key)
+		}
+	}
+}
+
+// save writes the ban list to the ban file.  The list is written to a
+// temporary file first, so an interrupted write does not corrupt the existing
+// ban list.  Nothing is written when the ban manager has no ban file.
+//
+// This function MUST be called with the ban manager lock held (for writes).
+func (bm *BanManager) save() error {
+	if bm.banFile == "" {
+		return nil
+	}
+
+	sbl := serializedBanList{
+		Version: banListVersion,
+		Bans:    make([]*serializedBanEntry, 0, len(bm.bans)),
+	}
+	for key, entry := range bm.bans {
+		sbl.Bans = append(sbl.Bans, &serializedBanEntry{
+			Subnet:  key,
+			Created: entry.Created.Unix(),
+			Until:   entry.Until.Unix(),
+		})
+	}
+	sort.Slice(sbl.Bans, func(i, j int) bool {
+		return sbl.Bans[i].Subnet < sbl.Bans[j].Subnet
+	})
+
+	tmpFile := bm.banFile + ".tmp"
+	w, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(&sbl); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, bm.banFile)
+}
+
+// Load loads the ban list from the ban file, dropping the bans which expired in
+// the meantime.  A missing ban file is not an error.
+func (bm *BanManager) Load() error {
+	bm.mtx.Lock()
+	defer bm.mtx.Unlock()
+
+	if bm.banFile == "" {
+		return nil
+	}
+	r, err := os.Open(bm.banFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	var sbl serializedBanList
+	if err := json.NewDecoder(r).Decode(&sbl); err != nil {
+		return fmt.Errorf("error reading %s: %v", bm.banFile, err)
+	}
+	if sbl.Version != banListVersion {
+		return fmt.Errorf("unknown version %v in serialized ban list",
+			sbl.Version)
+	}
+
+	bans := make(map[string]*BanEntry, len(sbl.Bans))
+	for _, sbe := range sbl.Bans {
+		subnet, err := ParseSubnet(sbe.Subnet)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", bm.banFile, err)
+		}
+		bans[subnet.String()] = &BanEntry{
+			Subnet:  subnet,
+			Created: time.Unix(sbe.Created, 0),
+			Until:   time.Unix(sbe.Until, 0),
+		}
+	}
+	bm.bans = bans
+	bm.sweep(time.Now())
+	return nil
+}
+
+// Ban bans the passed subnet until the passed time and saves the ban list.  An
+// existing ban of the subnet is replaced.
+func (bm *BanManager) Ban(subnet *net.IPNet, until time.Time) error {
+	bm.mtx.Lock()
+	defer bm.mtx.Unlock()
+
+	now := time.Now()
+	bm.sweep(now)
+	bm.bans[subnet.String()] = &BanEntry{
+		Subnet:  subnet,
+		Created: now,
+		Until:   until,
+	}
+	return bm.save()
+}
+
+// Unban removes the ban of the passed subnet and saves the ban list.  It
+// returns false when the subnet is not banned.
+func (bm *BanManager) Unban(subnet *net.IPNet) (bool, error) {
+	bm.mtx.Lock()
+	defer bm.mtx.Unlock()
+
+	bm.sweep(time.Now())
+	key := subnet.String()
+	if _, ok := bm.bans[key]; !ok {
+		return false, nil
+	}
+	delete(bm.bans, key)
+	return true, bm.save()
+}
+
+// Clear removes all bans and saves the ban list.
+func (bm *BanManager) Clear() error {
+	bm.mtx.Lock()
+	defer bm.mtx.Unlock()
+
+	bm.bans = make(map[string]*BanEntry)
+	return bm.save()
+}
+
+// IsBanned returns whether or not the passed IP address is in any of the
+// banned subnets.
+func (bm *BanManager) IsBanned(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	bm.mtx.Lock()
+	defer bm.mtx.Unlock()
+
+	now := time.Now()
+	for _, entry := range bm.bans {
+		if now.Before(entry.Until) && entry.Subnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAddrBanned returns whether or not the IP address of the passed network
+// address is in any of the banned subnets.  Addresses without an IP address,
+// such as onion addresses, are never banned.
+func (bm *BanManager) IsAddrBanned(addr net.Addr) bool {
+	return bm.IsBanned(addrIP(addr))
+}
+
+// Banned returns the bans which have not expired yet ordered by their subnets.
+func (bm *BanManager) Banned() []BanEntry {
+	bm.mtx.Lock()
+	defer bm.mtx.Unlock()
+
+	now := time.Now()
+	entries := make([]BanEntry, 0, len(bm.bans))
+	for _, entry := range bm.bans {
+		if now.Before(entry.Until) {
+			entries = append(entries, *entry)
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Subnet.String() < entries[j].Subnet.String()
+	})
+	return entries
+}
+
+// NewBanManager returns a new ban manager which persists the bans in the passed
+// file.  The bans are only kept in memory when the file is empty.  Use Load to
+// load the bans saved by a previous run.
+func NewBanManager(banFile string) *BanManager {
+	return &BanManager{
+		banFile: banFile,
+		bans:    make(map[string]*BanEntry),
+	}
+}
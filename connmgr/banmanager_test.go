@@ -0,0 +1,152 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestParseSubnet ensures single addresses and subnets in CIDR notation are
+// parsed and normalized.
+func TestParseSubnet(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "1.2.3.4", want: "1.2.3.4/32"},
+		{in: "1.2.3.4/24", want: "1.2.3.0/24"},
+		{in: "::ffff:1.2.3.4", want: "1.2.3.4/32"},
+		{in: "2001:db8::1", want: "2001:db8::1/128"},
+		{in: "2001:db8::1/32", want: "2001:db8::/32"},
+		{in: "1.2.3.4/33", err: true},
+		{in: "example.com", err: true},
+	}
+
+	for _, test := range tests {
+		subnet, err := ParseSubnet(test.in)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseSubnet(%q): expected error", test.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSubnet(%q): unexpected error: %v", test.in,
+				err)
+			continue
+		}
+		if subnet.String() != test.want {
+			t.Errorf("ParseSubnet(%q): got %v, want %v", test.in,
+				subnet, test.want)
+		}
+	}
+}
+
+// TestBanManager ensures subnets are banned until their bans expire or are
+// removed, and that the bans are persisted across ban manager instances.
+func TestBanManager(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "banmanager")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	banFile := filepath.Join(dir, "banlist.json")
+
+	bm := NewBanManager(banFile)
+	if err := bm.Load(); err != nil {
+		t.Fatalf("Load: unexpected error with a missing file: %v", err)
+	}
+
+	subnet, _ := ParseSubnet("10.0.0.0/8")
+	host, _ := ParseSubnet("192.168.1.1")
+	expired, _ := ParseSubnet("172.16.0.1")
+	now := time.Now()
+	for _, ban := range []struct {
+		subnet *net.IPNet
+		until  time.Time
+	}{
+		{subnet, now.Add(time.Hour)},
+		{host, now.Add(2 * time.Hour)},
+		{expired, now.Add(-time.Second)},
+	} {
+		if err := bm.Ban(ban.subnet, ban.until); err != nil {
+			t.Fatalf("Ban: unexpected error: %v", err)
+		}
+	}
+
+	tests := []struct {
+		addr   net.Addr
+		banned bool
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 8333}, true},
+		{&net.TCPAddr{IP: net.ParseIP("192.168.1.1"), Port: 8333}, true},
+		{&net.TCPAddr{IP: net.ParseIP("192.168.1.2"), Port: 8333}, false},
+		{&net.TCPAddr{IP: net.ParseIP("172.16.0.1"), Port: 8333}, false},
+		{mockAddr{"tcp", "10.0.0.1:8333"}, true},
+		{mockAddr{"tcp", "abcdefghijklmnop.onion:8333"}, false},
+	}
+	check := func(bm *BanManager) {
+		for _, test := range tests {
+			if got := bm.IsAddrBanned(test.addr); got != test.banned {
+				t.Errorf("IsAddrBanned(%v): got %v, want %v",
+					test.addr, got, test.banned)
+			}
+		}
+	}
+	check(bm)
+
+	// The bans are reloaded without the expired one.
+	bm = NewBanManager(banFile)
+	if err := bm.Load(); err != nil {
+		t.Fatalf("Load: unexpected error: %v", err)
+	}
+	check(bm)
+	banned := bm.Banned()
+	if len(banned) != 2 || banned[0].Subnet.String() != "10.0.0.0/8" ||
+		banned[1].Subnet.String() != "192.168.1.1/32" {
+		t.Fatalf("Banned: got %v, want the bans of 10.0.0.0/8 and "+
+			"192.168.1.1/32", banned)
+	}
+	if banned[0].Until.Unix() != now.Add(time.Hour).Unix() {
+		t.Errorf("Banned: got ban until %v, want %v", banned[0].Until,
+			now.Add(time.Hour))
+	}
+
+	// Removing a ban lifts it, and removing it again reports it was not
+	// banned.
+	if ok, err := bm.Unban(subnet); !ok || err != nil {
+		t.Fatalf("Unban: got %v, %v, want true, nil", ok, err)
+	}
+	if ok, err := bm.Unban(subnet); ok || err != nil {
+		t.Fatalf("Unban: got %v, %v for a subnet which is not banned, "+
+			"want false, nil", ok, err)
+	}
+	if bm.IsBanned(net.ParseIP("10.1.2.3")) {
+		t.Errorf("IsBanned: address of a removed ban is banned")
+	}
+
+	// Clearing the bans lifts them all, also after a restart.
+	if err := bm.Clear(); err != nil {
+		t.Fatalf("Clear: unexpected error: %v", err)
+	}
+	bm = NewBanManager(banFile)
+	if err := bm.Load(); err != nil {
+		t.Fatalf("Load: unexpected error: %v", err)
+	}
+	if banned := bm.Banned(); len(banned) != 0 {
+		t.Errorf("Banned: got %v after clearing the bans, want none",
+			banned)
+	}
+}
@@ -52,13 +52,16 @@ const (
 )
 
 // ConnReq is the connection request to a network address. If permanent, the
-// connection will be retried on disconnection.
+// connection will be retried on disconnection.  If block relay only, the
+// connection is only used to relay blocks and will be replaced by another
+// block relay only connection on failure.
 type ConnReq struct {
 	// The following variables must only be used atomically.
 	id uint64
 
-	Addr      net.Addr
-	Permanent bool
+	Addr           net.Addr
+	Permanent      bool
+	BlockRelayOnly bool
 
 	conn       net.Conn
 	state      ConnState
@@ -123,6 +126,11 @@ type Config struct {
 	// maintain. Defaults to 8.
 	TargetOutbound uint32
 
+	// TargetBlockRelayOnly is the number of outbound block relay only
+	// network connections to maintain in addition to TargetOutbound.
+	// Defaults to 0.
+	TargetBlockRelayOnly uint32
+
 	// RetryDuration is the duration to wait before retrying connection
 	// requests. Defaults to 5s.
 	RetryDuration time.Duration
@@ -141,6 +149,12 @@ type Config struct {
 
 	// Dial connects to the address on the named network. It cannot be nil.
 	Dial func(net.Addr) (net.Conn, error)
+
+	// BanManager keeps track of the banned subnets.  Inbound connections
+	// from banned addresses are closed as soon as they are accepted and
+	// outbound connections to banned addresses fail without being dialed.
+	// It may be nil if the caller does not ban any addresses.
+	BanManager *BanManager
 }
 
 // handleConnected is used to queue a successful connection.
@@ -201,10 +215,10 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 				"-- retrying connection in: %v", maxFailedAttempts,
 				cm.cfg.RetryDuration)
 			time.AfterFunc(cm.cfg.RetryDuration, func() {
-				cm.NewConnReq()
+				cm.newConnReq(c.BlockRelayOnly)
 			})
 		} else {
-			go cm.NewConnReq()
+			go cm.newConnReq(c.BlockRelayOnly)
 		}
 	}
 }
@@ -216,7 +230,8 @@ func (cm *ConnManager) handleFailedConn(c *ConnReq) {
 // connections so that we remain connected to the network.  Connection requests
 // are processed and mapped by their assigned ids.
 func (cm *ConnManager) connHandler() {
-	conns := make(map[uint64]*ConnReq, cm.cfg.TargetOutbound)
+	target := cm.cfg.TargetOutbound + cm.cfg.TargetBlockRelayOnly
+	conns := make(map[uint64]*ConnReq, target)
 out:
 	for {
 		select {
@@ -249,7 +264,7 @@ out:
 						go cm.cfg.OnDisconnection(connReq)
 					}
 
-					if uint32(len(conns)) < cm.cfg.TargetOutbound && msg.retry {
+					if uint32(len(conns)) < target && msg.retry {
 						cm.handleFailedConn(connReq)
 					}
 				} else {
@@ -275,6 +290,12 @@ out:
 // NewConnReq creates a new connection request and connects to the
 // corresponding address.
 func (cm *ConnManager) NewConnReq() {
+	cm.newConnReq(false)
+}
+
+// newConnReq creates a new connection request, which is block relay only if
+// requested, and connects to the corresponding address.
+func (cm *ConnManager) newConnReq(blockRelayOnly bool) {
 	if atomic.LoadInt32(&cm.stop) != 0 {
 		return
 	}
@@ -282,7 +303,7 @@ func (cm *ConnManager) NewConnReq() {
 		return
 	}
 
-	c := &ConnReq{}
+	c := &ConnReq{BlockRelayOnly: blockRelayOnly}
 	atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
 
 	addr, err := cm.cfg.GetNewAddress()
@@ -305,6 +326,10 @@ func (cm *ConnManager) Connect(c *ConnReq) {
 	if atomic.LoadUint64(&c.id) == 0 {
 		atomic.StoreUint64(&c.id, atomic.AddUint64(&cm.connReqCount, 1))
 	}
+	if cm.cfg.BanManager != nil && cm.cfg.BanManager.IsAddrBanned(c.Addr) {
+		cm.requests <- handleFailed{c, ErrBanned}
+		return
+	}
 	log.Debugf("Attempting to connect to %v", c)
 	conn, err := cm.cfg.Dial(c.Addr)
 	if err != nil {
@@ -346,6 +371,14 @@ func (cm *ConnManager) listenHandler(listener net.Listener) {
 			}
 			continue
 		}
+		if cm.cfg.BanManager != nil &&
+			cm.cfg.BanManager.IsAddrBanned(conn.RemoteAddr()) {
+
+			log.Debugf("Refused connection from banned address %s",
+				conn.RemoteAddr())
+			conn.Close()
+			continue
+		}
 		go cm.cfg.OnAccept(conn)
 	}
 
@@ -376,6 +409,9 @@ func (cm *ConnManager) Start() {
 	for i := atomic.LoadUint64(&cm.connReqCount); i < uint64(cm.cfg.TargetOutbound); i++ {
 		go cm.NewConnReq()
 	}
+	for i := uint32(0); i < cm.cfg.TargetBlockRelayOnly; i++ {
+		go cm.newConnReq(true)
+	}
 }
 
 // Wait blocks until the connection manager halts gracefully.
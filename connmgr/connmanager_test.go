@@ -227,6 +227,62 @@ func TestTargetOutbound(t *testing.T) {
 	cmgr.Stop()
 }
 
+// TestTargetBlockRelayOnly tests the target number of block relay only
+// connections is maintained in addition to the target outbound connections.
+//
+// We wait until all connections are established and count the block relay
+// only ones, then disconnect one of them and wait for it to be replaced by
+// another block relay only connection.
+func TestTargetBlockRelayOnly(t *testing.T) {
+	targetOutbound := uint32(3)
+	targetBlockRelayOnly := uint32(2)
+	connected := make(chan *ConnReq)
+	cmgr, err := New(&Config{
+		TargetOutbound:       targetOutbound,
+		TargetBlockRelayOnly: targetBlockRelayOnly,
+		Dial:                 mockDialer,
+		GetNewAddress: func() (net.Addr, error) {
+			return &net.TCPAddr{
+				IP:   net.ParseIP("127.0.0.1"),
+				Port: 18555,
+			}, nil
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+	var blockRelayOnly []*ConnReq
+	for i := uint32(0); i < targetOutbound+targetBlockRelayOnly; i++ {
+		c := <-connected
+		if c.BlockRelayOnly {
+			blockRelayOnly = append(blockRelayOnly, c)
+		}
+	}
+	if uint32(len(blockRelayOnly)) != targetBlockRelayOnly {
+		t.Fatalf("block relay only: got %d connections, want %d",
+			len(blockRelayOnly), targetBlockRelayOnly)
+	}
+
+	select {
+	case c := <-connected:
+		t.Fatalf("block relay only: got unexpected connection - %v", c.Addr)
+	case <-time.After(time.Millisecond):
+		break
+	}
+
+	cmgr.Disconnect(blockRelayOnly[0].ID())
+	c := <-connected
+	if !c.BlockRelayOnly {
+		t.Fatalf("block relay only: got replacement connection %v "+
+			"which is not block relay only", c)
+	}
+	cmgr.Stop()
+}
+
 // TestRetryPermanent tests that permanent connection requests are retried.
 //
 // We make a permanent connection request using Connect, disconnect it using
@@ -545,3 +601,72 @@ out:
 	cmgr.Stop()
 	cmgr.Wait()
 }
+
+// TestBannedAddresses ensures the connection manager refuses inbound
+// connections from and outbound connections to banned addresses.
+func TestBannedAddresses(t *testing.T) {
+	bm := NewBanManager("")
+	subnet, _ := ParseSubnet("10.0.0.0/8")
+	if err := bm.Ban(subnet, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Ban: unexpected error: %v", err)
+	}
+
+	receivedConns := make(chan net.Conn)
+	connected := make(chan *ConnReq)
+	listener := newMockListener("127.0.0.1:8333")
+	cmgr, err := New(&Config{
+		Listeners: []net.Listener{listener},
+		OnAccept: func(conn net.Conn) {
+			receivedConns <- conn
+		},
+		OnConnection: func(c *ConnReq, conn net.Conn) {
+			connected <- c
+		},
+		Dial:       mockDialer,
+		BanManager: bm,
+	})
+	if err != nil {
+		t.Fatalf("New error: %v", err)
+	}
+	cmgr.Start()
+
+	// Only the connection from the address which is not banned is
+	// accepted.
+	go func() {
+		listener.Connect("10.1.2.3", 10000)
+		listener.Connect("127.0.0.1", 10001)
+	}()
+	select {
+	case conn := <-receivedConns:
+		if conn.RemoteAddr().String() != "127.0.0.1:10001" {
+			t.Fatalf("accepted connection from %v, want 127.0.0.1:10001",
+				conn.RemoteAddr())
+		}
+	case <-time.After(time.Millisecond * 50):
+		t.Fatalf("Timeout waiting for the accepted connection")
+	}
+
+	// Only the connection to the address which is not banned is dialed.
+	go cmgr.Connect(&ConnReq{
+		Addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 18555},
+	})
+	go cmgr.Connect(&ConnReq{
+		Addr: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 18555},
+	})
+	select {
+	case c := <-connected:
+		if c.Addr.String() != "127.0.0.1:18555" {
+			t.Fatalf("connected to %v, want 127.0.0.1:18555", c.Addr)
+		}
+	case <-time.After(time.Millisecond * 50):
+		t.Fatalf("Timeout waiting for the outbound connection")
+	}
+	select {
+	case c := <-connected:
+		t.Fatalf("connected to banned address %v", c.Addr)
+	case <-time.After(time.Millisecond * 20):
+	}
+
+	cmgr.Stop()
+	cmgr.Wait()
+}
@@ -9,6 +9,7 @@ Connection Manager Overview
 
 Connection Manager handles all the general connection concerns such as
 maintaining a set number of outbound connections, sourcing peers, banning,
-limiting max connections, tor lookup, etc.
+limiting max connections, tor lookup, tor stream isolation and onion services,
+etc.
 */
 package connmgr
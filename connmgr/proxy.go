@@ -0,0 +1,71 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"time"
+
+	"github.com/btcsuite/go-socks/socks"
+)
+
+// Proxy dials connections through a SOCKS5 proxy.
+//
+// With stream isolation enabled, the connections to each peer address are made
+// with their own proxy credentials, which Tor uses to route them over separate
+// circuits, so peers are unable to link the connections of the node to each
+// other.  The credentials are derived from the peer address and a secret which
+// is random for every proxy, so they are the same for reconnections to a peer
+// and differ between runs.
+type Proxy struct {
+	addr            string
+	username        string
+	password        string
+	streamIsolation bool
+	secret          [32]byte
+}
+
+// isolationCredentials returns the proxy credentials of the connections to the
+// passed peer address when stream isolation is enabled.
+func (p *Proxy) isolationCredentials(addr string) (string, string) {
+	mac := hmac.New(sha256.New, p.secret[:])
+	mac.Write([]byte(addr))
+	sum := mac.Sum(nil)
+	return hex.EncodeToString(sum[:16]), hex.EncodeToString(sum[16:])
+}
+
+// DialTimeout connects to the passed address through the proxy, failing when
+// the connection is not established within the passed timeout.
+func (p *Proxy) DialTimeout(network, addr string, timeout time.Duration) (net.Conn, error) {
+	proxy := &socks.Proxy{
+		Addr:     p.addr,
+		Username: p.username,
+		Password: p.password,
+	}
+	if p.streamIsolation {
+		proxy.Username, proxy.Password = p.isolationCredentials(addr)
+	}
+	return proxy.DialTimeout(network, addr, timeout)
+}
+
+// NewProxy returns a new proxy for the SOCKS5 proxy at the passed address
+// which authenticates with the passed credentials.  The credentials are
+// replaced by the ones of each peer when stream isolation is enabled.
+func NewProxy(addr, username, password string, streamIsolation bool) (*Proxy, error) {
+	p := &Proxy{
+		addr:            addr,
+		username:        username,
+		password:        password,
+		streamIsolation: streamIsolation,
+	}
+	if _, err := rand.Read(p.secret[:]); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import "testing"
+
+// TestProxyIsolationCredentials ensures the proxy credentials used for stream
+// isolation are the same for the connections to a peer and differ between
+// peers and proxies.
+func TestProxyIsolationCredentials(t *testing.T) {
+	p, err := NewProxy("127.0.0.1:9050", "", "", true)
+	if err != nil {
+		t.Fatalf("NewProxy: unexpected error: %v", err)
+	}
+	other, err := NewProxy("127.0.0.1:9050", "", "", true)
+	if err != nil {
+		t.Fatalf("NewProxy: unexpected error: %v", err)
+	}
+
+	user, pass := p.isolationCredentials("1.2.3.4:7979")
+	if user == "" || pass == "" {
+		t.Fatalf("got empty credentials %q:%q", user, pass)
+	}
+	if u, pw := p.isolationCredentials("1.2.3.4:7979"); u != user || pw != pass {
+		t.Errorf("got credentials %q:%q for the same peer, want %q:%q",
+			u, pw, user, pass)
+	}
+	if u, pw := p.isolationCredentials("1.2.3.5:7979"); u == user || pw == pass {
+		t.Errorf("got the same credentials for another peer")
+	}
+	if u, pw := other.isolationCredentials("1.2.3.4:7979"); u == user || pw == pass {
+		t.Errorf("got the same credentials for another proxy")
+	}
+}
@@ -0,0 +1,337 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// torControlTimeout is the maximum time connecting to the Tor control
+	// port and waiting for the reply to a command may take.
+	torControlTimeout = 30 * time.Second
+
+	// torReplyOK is the status code of successful Tor control replies.
+	torReplyOK = 250
+
+	// torSafeCookieServerKey and torSafeCookieClientKey are the HMAC keys
+	// of the hashes the Tor control port and the controller prove they know
+	// the authentication cookie with.
+	torSafeCookieServerKey = "Tor safe cookie authentication server-to-controller hash"
+	torSafeCookieClientKey = "Tor safe cookie authentication controller-to-server hash"
+
+	// torCookieSize is the size of the Tor authentication cookie.
+	torCookieSize = 32
+)
+
+var (
+	// ErrTorInvalidControlReply indicates the Tor control port replied in
+	// an unexpected format.
+	ErrTorInvalidControlReply = errors.New("invalid tor control reply")
+
+	// ErrTorNoAuthMethod indicates none of the authentication methods the
+	// Tor control port accepts is supported.
+	ErrTorNoAuthMethod = errors.New("no supported tor control " +
+		"authentication method")
+)
+
+// TorControlError describes a Tor control command which failed.
+type TorControlError struct {
+	Code    int
+	Message string
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e TorControlError) Error() string {
+	return fmt.Sprintf("tor control error %d: %s", e.Code, e.Message)
+}
+
+// TorController is a client of the Tor control protocol, which is used to
+// create the onion service the node accepts connections from Tor on.  The
+// onion services created by a controller are removed by Tor when the
+// connection to the control port is closed.
+type TorController struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// readReply reads a reply to a command and returns its status code and lines,
+// which include the data of data replies.
+func (tc *TorController) readReply() (int, []string, error) {
+	var code int
+	var lines []string
+	for {
+		line, err := tc.r.ReadString('\n')
+		if err != nil {
+			return 0, nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if len(line) < 4 {
+			return 0, nil, ErrTorInvalidControlReply
+		}
+		code, err = strconv.Atoi(line[:3])
+		if err != nil {
+			return 0, nil, ErrTorInvalidControlReply
+		}
+		lines = append(lines, line[4:])
+
+		switch line[3] {
+		case ' ':
+			return code, lines, nil
+		case '-':
+		case '+':
+			// The data of data replies is terminated by a line
+			// only containing a period.
+			for {
+				data, err := tc.r.ReadString('\n')
+				if err != nil {
+					return 0, nil, err
+				}
+				data = strings.TrimRight(data, "\r\n")
+				if data == "." {
+					break
+				}
+				lines = append(lines, strings.TrimPrefix(data, "."))
+			}
+		default:
+			return 0, nil, ErrTorInvalidControlReply
+		}
+	}
+}
+
+// command sends the passed command to the control port and returns the lines
+// of the reply.  A TorControlError is returned when the command fails.
+func (tc *TorController) command(cmd string) ([]string, error) {
+	tc.conn.SetDeadline(time.Now().Add(torControlTimeout))
+	defer tc.conn.SetDeadline(time.Time{})
+
+	if _, err := tc.conn.Write([]byte(cmd + "\r\n")); err != nil {
+		return nil, err
+	}
+	code, lines, err := tc.readReply()
+	if err != nil {
+		return nil, err
+	}
+	if code != torReplyOK {
+		return nil, TorControlError{Code: code,
+			Message: lines[len(lines)-1]}
+	}
+	return lines, nil
+}
+
+// parseTorKeywords parses the keyword arguments of a reply line, which are
+// separated by spaces and whose values may be quoted.
+func parseTorKeywords(line string) map[string]string {
+	keywords := make(map[string]string)
+	for len(line) > 0 {
+		line = strings.TrimLeft(line, " ")
+		eq := strings.IndexAny(line, "= ")
+		if eq == -1 {
+			keywords[line] = ""
+			break
+		}
+		key := line[:eq]
+		if line[eq] == ' ' {
+			keywords[key] = ""
+			line = line[eq:]
+			continue
+		}
+		line = line[eq+1:]
+
+		var value string
+		if strings.HasPrefix(line, "\"") {
+			// Quoted values end at the first unescaped quote.
+			var buf bytes.Buffer
+			i := 1
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+				}
+				buf.WriteByte(line[i])
+			}
+			value = buf.String()
+			if i < len(line) {
+				i++
+			}
+			line = line[i:]
+		} else {
+			end := strings.IndexByte(line, ' ')
+			if end == -1 {
+				end = len(line)
+			}
+			value = line[:end]
+			line = line[end:]
+		}
+		keywords[key] = value
+	}
+	return keywords
+}
+
+// quoteTorString returns the passed string quoted for the control protocol.
+func quoteTorString(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "\"", "\\\"", -1)
+	return "\"" + s + "\""
+}
+
+// authenticateSafeCookie authenticates with the passed cookie without sending
+// it, after ensuring the control port knows it as well.
+func (tc *TorController) authenticateSafeCookie(cookie []byte) error {
+	clientNonce := make([]byte, 32)
+	if _, err := rand.Read(clientNonce); err != nil {
+		return err
+	}
+	lines, err := tc.command("AUTHCHALLENGE SAFECOOKIE " +
+		hex.EncodeToString(clientNonce))
+	if err != nil {
+		return err
+	}
+	keywords := parseTorKeywords(strings.TrimPrefix(lines[0],
+		"AUTHCHALLENGE "))
+	serverHash, err := hex.DecodeString(keywords["SERVERHASH"])
+	if err != nil {
+		return ErrTorInvalidControlReply
+	}
+	serverNonce, err := hex.DecodeString(keywords["SERVERNONCE"])
+	if err != nil {
+		return ErrTorInvalidControlReply
+	}
+
+	message := make([]byte, 0, len(cookie)+len(clientNonce)+len(serverNonce))
+	message = append(message, cookie...)
+	message = append(message, clientNonce...)
+	message = append(message, serverNonce...)
+	mac := hmac.New(sha256.New, []byte(torSafeCookieServerKey))
+	mac.Write(message)
+	if !hmac.Equal(mac.Sum(nil), serverHash) {
+		return errors.New("tor control port does not know the " +
+			"authentication cookie")
+	}
+
+	mac = hmac.New(sha256.New, []byte(torSafeCookieClientKey))
+	mac.Write(message)
+	_, err = tc.command("AUTHENTICATE " + hex.EncodeToString(mac.Sum(nil)))
+	return err
+}
+
+// Authenticate authenticates with the control port.  The passed password is
+// used when it is not empty, otherwise no authentication or the authentication
+// cookie of Tor is used, whichever the control port accepts.
+func (tc *TorController) Authenticate(password string) error {
+	lines, err := tc.command("PROTOCOLINFO 1")
+	if err != nil {
+		return err
+	}
+	methods := make(map[string]bool)
+	var cookieFile string
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "AUTH ") {
+			continue
+		}
+		keywords := parseTorKeywords(strings.TrimPrefix(line, "AUTH "))
+		for _, method := range strings.Split(keywords["METHODS"], ",") {
+			methods[method] = true
+		}
+		cookieFile = keywords["COOKIEFILE"]
+	}
+
+	switch {
+	case password != "":
+		if !methods["HASHEDPASSWORD"] {
+			return ErrTorNoAuthMethod
+		}
+		_, err = tc.command("AUTHENTICATE " + quoteTorString(password))
+		return err
+
+	case methods["NULL"]:
+		_, err = tc.command("AUTHENTICATE")
+		return err
+
+	case methods["SAFECOOKIE"] || methods["COOKIE"]:
+		cookie, err := ioutil.ReadFile(cookieFile)
+		if err != nil {
+			return err
+		}
+		if len(cookie) != torCookieSize {
+			return fmt.Errorf("tor authentication cookie %s has "+
+				"invalid size %d", cookieFile, len(cookie))
+		}
+		if methods["SAFECOOKIE"] {
+			return tc.authenticateSafeCookie(cookie)
+		}
+		_, err = tc.command("AUTHENTICATE " + hex.EncodeToString(cookie))
+		return err
+	}
+	return ErrTorNoAuthMethod
+}
+
+// AddOnion creates an onion service which forwards the connections to the
+// passed virtual port to the passed target address.  The service uses the
+// passed private key, which is formatted as the key type, a colon and the
+// base64-encoded key, or a new key of the passed type when it is "NEW:<type>".
+// It returns the service ID, which is the onion address without the .onion
+// suffix, along with the new private key in the same format when one was
+// created.
+func (tc *TorController) AddOnion(privateKey string, virtPort uint16,
+	target string) (string, string, error) {
+
+	lines, err := tc.command(fmt.Sprintf("ADD_ONION %s Port=%d,%s",
+		privateKey, virtPort, target))
+	if err != nil {
+		return "", "", err
+	}
+
+	var serviceID, newKey string
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "ServiceID="):
+			serviceID = strings.TrimPrefix(line, "ServiceID=")
+		case strings.HasPrefix(line, "PrivateKey="):
+			newKey = strings.TrimPrefix(line, "PrivateKey=")
+		}
+	}
+	if serviceID == "" {
+		return "", "", ErrTorInvalidControlReply
+	}
+	return serviceID, newKey, nil
+}
+
+// Wait blocks until the connection to the control port is closed, at which
+// point the onion services created by the controller are removed by Tor.
+// Asynchronous events are not subscribed to, so nothing but the closing of the
+// connection is expected.
+func (tc *TorController) Wait() error {
+	_, err := io.Copy(ioutil.Discard, tc.r)
+	return err
+}
+
+// Close closes the connection to the control port, which removes the onion
+// services created by the controller.
+func (tc *TorController) Close() error {
+	return tc.conn.Close()
+}
+
+// DialTorController connects to the Tor control port at the passed address.
+// Use Authenticate before issuing any other commands.
+func DialTorController(addr string) (*TorController, error) {
+	conn, err := net.DialTimeout("tcp", addr, torControlTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &TorController{conn: conn, r: bufio.NewReader(conn)}, nil
+}
@@ -0,0 +1,232 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package connmgr
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestParseTorKeywords ensures the keyword arguments of Tor control replies are
+// parsed with quoted and unquoted values.
+func TestParseTorKeywords(t *testing.T) {
+	got := parseTorKeywords(`METHODS=COOKIE,SAFECOOKIE COOKIEFILE="/var/lib/tor/control \"auth\" cookie" FLAG`)
+	want := map[string]string{
+		"METHODS":    "COOKIE,SAFECOOKIE",
+		"COOKIEFILE": `/var/lib/tor/control "auth" cookie`,
+		"FLAG":       "",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// fakeTorControl serves a Tor control port on a loopback listener which
+// replies to each command with the reply of the passed function, and records
+// the commands it received.
+type fakeTorControl struct {
+	l        net.Listener
+	commands chan string
+}
+
+func newFakeTorControl(t *testing.T, reply func(cmd string) string) *fakeTorControl {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	f := &fakeTorControl{l: l, commands: make(chan string, 16)}
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimRight(line, "\r\n")
+			f.commands <- cmd
+			conn.Write([]byte(reply(cmd)))
+		}
+	}()
+	return f
+}
+
+// TestTorControllerAddOnion ensures onion services are created after
+// authenticating with the safe cookie, cookie, password and null methods.
+func TestTorControllerAddOnion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "torcontrol")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cookie := make([]byte, torCookieSize)
+	for i := range cookie {
+		cookie[i] = byte(i)
+	}
+	cookieFile := filepath.Join(dir, "control_auth_cookie")
+	if err := ioutil.WriteFile(cookieFile, cookie, 0600); err != nil {
+		t.Fatalf("unable to write cookie: %v", err)
+	}
+	serverNonce := make([]byte, 32)
+	for i := range serverNonce {
+		serverNonce[i] = byte(255 - i)
+	}
+
+	// safeCookieHash returns the hash of the passed key for the passed
+	// client nonce.
+	safeCookieHash := func(key string, clientNonce []byte) string {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write(cookie)
+		mac.Write(clientNonce)
+		mac.Write(serverNonce)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	tests := []struct {
+		name     string
+		methods  string
+		password string
+		wantAuth func(clientNonce []byte) string
+	}{
+		{
+			name:    "safe cookie",
+			methods: "COOKIE,SAFECOOKIE",
+			wantAuth: func(clientNonce []byte) string {
+				return "AUTHENTICATE " + safeCookieHash(
+					torSafeCookieClientKey, clientNonce)
+			},
+		},
+		{
+			name:    "cookie",
+			methods: "COOKIE",
+			wantAuth: func([]byte) string {
+				return "AUTHENTICATE " + hex.EncodeToString(cookie)
+			},
+		},
+		{
+			name:     "password",
+			methods:  "HASHEDPASSWORD",
+			password: `pass "word"`,
+			wantAuth: func([]byte) string {
+				return `AUTHENTICATE "pass \"word\""`
+			},
+		},
+		{
+			name:    "null",
+			methods: "NULL",
+			wantAuth: func([]byte) string {
+				return "AUTHENTICATE"
+			},
+		},
+	}
+
+	for _, test := range tests {
+		var clientNonce []byte
+		var authenticated bool
+		f := newFakeTorControl(t, func(cmd string) string {
+			switch {
+			case cmd == "PROTOCOLINFO 1":
+				return fmt.Sprintf("250-PROTOCOLINFO 1\r\n"+
+					"250-AUTH METHODS=%s COOKIEFILE=%q\r\n"+
+					"250-VERSION Tor=\"0.3.1.9\"\r\n250 OK\r\n",
+					test.methods, cookieFile)
+			case strings.HasPrefix(cmd, "AUTHCHALLENGE SAFECOOKIE "):
+				clientNonce, _ = hex.DecodeString(cmd[25:])
+				return fmt.Sprintf("250 AUTHCHALLENGE "+
+					"SERVERHASH=%s SERVERNONCE=%x\r\n",
+					safeCookieHash(torSafeCookieServerKey,
+						clientNonce), serverNonce)
+			case strings.HasPrefix(cmd, "AUTHENTICATE"):
+				if cmd != test.wantAuth(clientNonce) {
+					return "515 Authentication failed\r\n"
+				}
+				authenticated = true
+				return "250 OK\r\n"
+			case strings.HasPrefix(cmd, "ADD_ONION ") && authenticated:
+				return "250-ServiceID=expyuzz4wqqyqhjn\r\n" +
+					"250-PrivateKey=RSA1024:MIICXAIBAAKBgQ\r\n" +
+					"250 OK\r\n"
+			}
+			return "510 Unrecognized command\r\n"
+		})
+
+		tc, err := DialTorController(f.l.Addr().String())
+		if err != nil {
+			t.Fatalf("%s: DialTorController: unexpected error: %v",
+				test.name, err)
+		}
+		if err := tc.Authenticate(test.password); err != nil {
+			t.Errorf("%s: Authenticate: unexpected error: %v",
+				test.name, err)
+		}
+		serviceID, key, err := tc.AddOnion("NEW:RSA1024", 7979,
+			"127.0.0.1:7979")
+		if err != nil {
+			t.Errorf("%s: AddOnion: unexpected error: %v", test.name,
+				err)
+		}
+		if serviceID != "expyuzz4wqqyqhjn" || key != "RSA1024:MIICXAIBAAKBgQ" {
+			t.Errorf("%s: AddOnion: got service %q with key %q",
+				test.name, serviceID, key)
+		}
+		tc.Close()
+		f.l.Close()
+
+		var last string
+		for len(f.commands) > 0 {
+			last = <-f.commands
+		}
+		if want := "ADD_ONION NEW:RSA1024 Port=7979,127.0.0.1:7979"; last != want {
+			t.Errorf("%s: got command %q, want %q", test.name, last,
+				want)
+		}
+	}
+}
+
+// TestTorControllerErrors ensures failed commands and authentication without a
+// supported method return errors.
+func TestTorControllerErrors(t *testing.T) {
+	f := newFakeTorControl(t, func(cmd string) string {
+		if cmd == "PROTOCOLINFO 1" {
+			return "250-PROTOCOLINFO 1\r\n250-AUTH METHODS=NULL\r\n" +
+				"250 OK\r\n"
+		}
+		return "512 Bad arguments to ADD_ONION\r\n"
+	})
+	defer f.l.Close()
+
+	tc, err := DialTorController(f.l.Addr().String())
+	if err != nil {
+		t.Fatalf("DialTorController: unexpected error: %v", err)
+	}
+	defer tc.Close()
+
+	// A password is unable to be used without the hashed password method.
+	if err := tc.Authenticate("password"); err != ErrTorNoAuthMethod {
+		t.Errorf("Authenticate: got error %v, want %v", err,
+			ErrTorNoAuthMethod)
+	}
+
+	_, _, err = tc.AddOnion("NEW:RSA1024", 7979, "127.0.0.1:7979")
+	want := TorControlError{Code: 512, Message: "Bad arguments to ADD_ONION"}
+	if err != want {
+		t.Errorf("AddOnion: got error %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is part of the ffldb package rather than the ffldb_test package as
+// it provides whitebox testing.
+
+package ffldb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+// waitArchived waits for the archive handler of the passed database to move the
+// block files before the passed file number to the cold storage.
+func waitArchived(t *testing.T, idb database.DB, toFileNum uint32) {
+	store := idb.(*db).store.(*blockStore)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		store.archiveMtx.Lock()
+		archivedFileNum := store.archivedFileNum
+		store.archiveMtx.Unlock()
+		if archivedFileNum >= toFileNum {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timeout waiting for block files before %d to "+
+				"be archived - archived %d", toFileNum,
+				archivedFileNum)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestArchiveBlocks ensures archiving blocks moves the flat files which house
+// them to the cold storage in the background, that they remain available from
+// it after reopening the database, that files which were not moved before the
+// database was closed are moved once it is reopened, and that pruning them
+// removes them from the cold storage.
+func TestArchiveBlocks(t *testing.T) {
+	t.Parallel()
+
+	// Ensure the ldb databases, which have no flat files, reject a cold
+	// storage path.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-archivetest")
+	coldPath := dbPath + "-cold"
+	_ = os.RemoveAll(dbPath)
+	_ = os.RemoveAll(coldPath)
+	_, err := database.Create(ldbDbType, dbPath, blockDataNet, []byte(nil),
+		coldPath)
+	if !checkDbError(t, "Create ldb with cold path", err,
+		database.ErrInvalid) {
+		return
+	}
+	_ = os.RemoveAll(dbPath)
+
+	// Create a new database with cold storage to run tests against.
+	idb, err := database.Create(dbType, dbPath, blockDataNet, []byte(nil),
+		coldPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer os.RemoveAll(coldPath)
+	defer func() {
+		idb.Close()
+	}()
+
+	// Store blocks based on the genesis block, which are distinguished by
+	// their height, in files small enough to hold only a couple each.
+	idb.(*db).store.(*blockStore).maxBlockFileSize = 1024
+	blocks := make([]*provautil.Block, 8)
+	for i := range blocks {
+		msgBlock := *chaincfg.MainNetParams.GenesisBlock
+		msgBlock.Header.Height = uint32(i)
+		blocks[i] = provautil.NewBlock(&msgBlock)
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StoreBlock: unexpected error: %v", err)
+	}
+
+	// Ensure blocks are only able to be archived in read-write
+	// transactions.
+	archived := blocks[len(blocks)/2]
+	err = idb.View(func(tx database.Tx) error {
+		return tx.ArchiveBlocks(archived.Hash())
+	})
+	if !checkDbError(t, "ArchiveBlocks", err, database.ErrTxNotWritable) {
+		return
+	}
+
+	// Archive the first half of the blocks and ensure the archive watermark
+	// has been committed and the files before the one which houses the
+	// block they were archived before have been moved to the cold storage
+	// while the rest have been left in place.
+	var location blockLocation
+	err = idb.Update(func(tx database.Tx) error {
+		blockRow, err := tx.(*transaction).fetchBlockRow(archived.Hash())
+		if err != nil {
+			return err
+		}
+		location = deserializeBlockLoc(blockRow)
+		return tx.ArchiveBlocks(archived.Hash())
+	})
+	if err != nil {
+		t.Fatalf("ArchiveBlocks: unexpected error: %v", err)
+	}
+	if location.blockFileNum == 0 {
+		t.Fatalf("ArchiveBlocks: block %v is in the first file",
+			archived.Hash())
+	}
+	err = idb.View(func(tx database.Tx) error {
+		gotFileNum := tx.(*transaction).archivedFileNum()
+		if gotFileNum != location.blockFileNum {
+			t.Fatalf("ArchiveBlocks: archive watermark %d, want %d",
+				gotFileNum, location.blockFileNum)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ArchiveBlocks: unexpected error: %v", err)
+	}
+	// checkFiles ensures the files up to the one which houses the block the
+	// blocks were archived before are in the right storage.
+	checkFiles := func(name string) {
+		for fileNum := uint32(0); fileNum <= location.blockFileNum; fileNum++ {
+			wantCold := fileNum < location.blockFileNum
+			if fileExists(blockFilePath(coldPath, fileNum)) != wantCold ||
+				fileExists(blockFilePath(dbPath, fileNum)) == wantCold {
+
+				t.Fatalf("%s: file %d is in the wrong storage - "+
+					"want cold %v", name, fileNum, wantCold)
+			}
+		}
+	}
+	waitArchived(t, idb, location.blockFileNum)
+	checkFiles("ArchiveBlocks")
+
+	// checkBlocks ensures all of the blocks are able to be fetched from the
+	// passed database.
+	checkBlocks := func(name string, idb database.DB) {
+		err := idb.View(func(tx database.Tx) error {
+			for _, block := range blocks {
+				gotBytes, err := tx.FetchBlock(block.Hash())
+				if err != nil {
+					return err
+				}
+				wantBytes, _ := block.Bytes()
+				if !bytes.Equal(gotBytes, wantBytes) {
+					t.Fatalf("%s: FetchBlock: block %v mismatch",
+						name, block.Hash())
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+	}
+	checkBlocks("archived", idb)
+
+	// Reopen the database and ensure the blocks are still able to be
+	// fetched.
+	idb.Close()
+	idb, err = database.Open(dbType, dbPath, blockDataNet, []byte(nil),
+		coldPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database (%s) %v", dbType, err)
+	}
+	checkBlocks("reopened", idb)
+
+	// Put the first file back in the database path as though it was not
+	// moved before the database was closed and ensure it is moved once the
+	// database is reopened.
+	idb.Close()
+	err = os.Rename(blockFilePath(coldPath, 0), blockFilePath(dbPath, 0))
+	if err != nil {
+		t.Fatalf("Failed to restore block file: %v", err)
+	}
+	idb, err = database.Open(dbType, dbPath, blockDataNet, []byte(nil),
+		coldPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database (%s) %v", dbType, err)
+	}
+	waitArchived(t, idb, location.blockFileNum)
+	checkFiles("resumed")
+	checkBlocks("resumed", idb)
+
+	// Prune the archived blocks and ensure their files have been removed
+	// from the cold storage.
+	err = idb.Update(func(tx database.Tx) error {
+		return tx.PruneBlocks(archived.Hash())
+	})
+	if err != nil {
+		t.Fatalf("PruneBlocks: unexpected error: %v", err)
+	}
+	for fileNum := uint32(0); fileNum < location.blockFileNum; fileNum++ {
+		if fileExists(blockFilePath(coldPath, fileNum)) {
+			t.Fatalf("PruneBlocks: file %d was not removed from the "+
+				"cold storage", fileNum)
+		}
+	}
+}
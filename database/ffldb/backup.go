@@ -0,0 +1,139 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bitgo/prova/database"
+	"github.com/btcsuite/goleveldb/leveldb"
+	"github.com/btcsuite/goleveldb/leveldb/iterator"
+	"github.com/btcsuite/goleveldb/leveldb/opt"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+)
+
+const (
+	// backupBatchSize is the approximate number of bytes of the records
+	// which are written to a leveldb database at once while it is written
+	// by a backup.
+	backupBatchSize = 16 * 1024 * 1024 // 16 MiB
+)
+
+// copyLdbRecords writes the records of the passed iterator to the passed
+// leveldb database in batches.  The last batch is written synchronously, so all
+// of the records are durable once it returns.
+func copyLdbRecords(dstDb *leveldb.DB, iter iterator.Iterator) error {
+	batch := new(leveldb.Batch)
+	var batchSize int
+	for ok := iter.First(); ok; ok = iter.Next() {
+		batch.Put(iter.Key(), iter.Value())
+		batchSize += len(iter.Key()) + len(iter.Value())
+		if batchSize < backupBatchSize {
+			continue
+		}
+		if err := dstDb.Write(batch, nil); err != nil {
+			return err
+		}
+		batch.Reset()
+		batchSize = 0
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return dstDb.Write(batch, &opt.WriteOptions{Sync: true})
+}
+
+// backupMetadata writes the metadata of the passed transaction to a new
+// metadata database at the passed path.
+func backupMetadata(dstPath string, tx *transaction) error {
+	// The metadata of a backup of an encrypted database is encrypted with
+	// the same key.
+	enc := tx.db.encryptor
+	if err := checkKey(dstPath, enc, true); err != nil {
+		return err
+	}
+	metadataDbPath := filepath.Join(dstPath, metadataDbName)
+	dstDb, releaseLdb, err := openLdb(metadataDbPath,
+		metadataDbOptions(true), enc)
+	if err != nil {
+		str := fmt.Sprintf("failed to create metadata database %q: %v",
+			metadataDbPath, err)
+		return convertErr(str, err)
+	}
+	defer releaseLdb()
+	defer dstDb.Close()
+
+	// The iterator of the snapshot includes the metadata which has not
+	// been flushed from the cache yet.
+	iter := tx.snapshot.NewIterator(&util.Range{})
+	defer iter.Release()
+	if err := copyLdbRecords(dstDb, iter); err != nil {
+		str := fmt.Sprintf("failed to copy metadata: %v", err)
+		return convertErr(str, err)
+	}
+	return nil
+}
+
+// Backup writes a consistent copy of the database to a new database at the
+// passed path while the database remains open.  The metadata is copied from a
+// snapshot, along with the blocks it references.  The backup of an encrypted
+// database is encrypted with the same key.  Read-write transactions wait
+// while the blocks are copied, since committing them could otherwise prune the
+// blocks which are being copied.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) Backup(dst string) error {
+	if fileExists(dst) {
+		str := fmt.Sprintf("backup destination %q already exists", dst)
+		return makeDbErr(database.ErrDbExists, str, nil)
+	}
+
+	// The transaction ensures the database is not closed while the backup
+	// is written.
+	db.writeLock.Lock()
+	tx, err := db.begin(false)
+	if err != nil {
+		db.writeLock.Unlock()
+		return err
+	}
+	defer tx.Rollback()
+
+	// The blocks of the snapshot end at the write cursor position stored
+	// in its metadata.
+	writeRow := tx.metaBucket.Get(writeLocKeyName)
+	if writeRow == nil {
+		db.writeLock.Unlock()
+		str := "write cursor does not exist"
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+	curFileNum, curOffset, err := deserializeWriteRow(writeRow)
+	if err != nil {
+		db.writeLock.Unlock()
+		return err
+	}
+
+	log.Infof("Backing up the database to %s", dst)
+	err = os.MkdirAll(dst, 0700)
+	if err != nil {
+		str := fmt.Sprintf("failed to create backup destination %q: %v",
+			dst, err)
+		err = makeDbErr(database.ErrDriverSpecific, str, err)
+	} else {
+		err = db.store.backup(dst, tx.prunedFileNum(), curFileNum,
+			curOffset)
+	}
+	db.writeLock.Unlock()
+	if err == nil {
+		err = backupMetadata(dst, tx)
+	}
+	if err != nil {
+		_ = os.RemoveAll(dst)
+		return err
+	}
+	log.Infof("Backed up the database to %s", dst)
+	return nil
+}
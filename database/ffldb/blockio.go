@@ -112,6 +112,39 @@ type blockStore struct {
 	// basePath is the base path used for the flat block files and metadata.
 	basePath string
 
+	// coldPath is the path of the directory the archived flat block files
+	// are moved to, or empty when there is no cold storage.  The files
+	// which are not found in the base path are read from it.
+	coldPath string
+
+	// The following fields are related to moving the archived flat files
+	// to the cold storage, which is done by the archive handler goroutine
+	// so the commits which archive blocks do not wait for whole files to
+	// be copied.  They are only set when there is a cold storage.
+	//
+	// archiveMtx protects archiveTo and archivedFileNum.
+	//
+	// archiveTo is the committed archive watermark.  The files with numbers
+	// before it are moved to the cold storage.
+	//
+	// archivedFileNum is the number of the first file which is not known
+	// to have been moved to the cold storage.
+	//
+	// archiveWake is signalled when the watermark advances, archiveQuit is
+	// closed to stop the archive handler, and archiveWg is used to wait
+	// for it to finish.
+	//
+	// moveMtx is held while a file is moved to the cold storage and while
+	// the files are backed up, so a backup does not copy a file which is
+	// removed from the base path while it is being opened.
+	archiveMtx      sync.Mutex
+	archiveTo       uint32
+	archivedFileNum uint32
+	archiveWake     chan struct{}
+	archiveQuit     chan struct{}
+	archiveWg       sync.WaitGroup
+	moveMtx         sync.Mutex
+
 	// maxBlockFileSize is the maximum size for each file used to store
 	// blocks.  It is defined on the store so the whitebox tests can
 	// override the value.
@@ -224,6 +257,19 @@ func blockFilePath(dbPath string, fileNum uint32) string {
 	return filepath.Join(dbPath, fileName)
 }
 
+// filePath returns the path of the block file with the passed number, which is
+// in the cold storage when the file has been moved there.
+func (s *blockStore) filePath(fileNum uint32) string {
+	filePath := blockFilePath(s.basePath, fileNum)
+	if s.coldPath == "" {
+		return filePath
+	}
+	if _, err := os.Stat(filePath); os.IsNotExist(err) {
+		return blockFilePath(s.coldPath, fileNum)
+	}
+	return filePath
+}
+
 // openWriteFile returns a file handle for the passed flat file number in
 // read/write mode.  The file will be created if needed.  It is typically used
 // for the current file that will have all new data appended.  Unlike openFile,
@@ -252,7 +298,7 @@ func (s *blockStore) openWriteFile(fileNum uint32) (filer, error) {
 // for WRITES.
 func (s *blockStore) openFile(fileNum uint32) (*lockableFile, error) {
 	// Open the appropriate file as read-only.
-	filePath := blockFilePath(s.basePath, fileNum)
+	filePath := s.filePath(fileNum)
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, makeDbErr(database.ErrDriverSpecific, err.Error(),
@@ -296,18 +342,195 @@ func (s *blockStore) openFile(fileNum uint32) (*lockableFile, error) {
 	return blockFile, nil
 }
 
-// deleteFile removes the block file for the passed flat file number.  The file
-// must already be closed and it is the responsibility of the caller to do any
-// other state cleanup necessary.
+// deleteFile removes the block file for the passed flat file number, from the
+// cold storage as well when it has been moved there.  The file must already be
+// closed and it is the responsibility of the caller to do any other state
+// cleanup necessary.
 func (s *blockStore) deleteFile(fileNum uint32) error {
 	filePath := blockFilePath(s.basePath, fileNum)
-	if err := os.Remove(filePath); err != nil {
+	err := os.Remove(filePath)
+	if s.coldPath != "" && (err == nil || os.IsNotExist(err)) {
+		// The file is in both places when it was being moved to the
+		// cold storage during an unclean shutdown.
+		coldErr := os.Remove(blockFilePath(s.coldPath, fileNum))
+		if err != nil || !os.IsNotExist(coldErr) {
+			err = coldErr
+		}
+	}
+	if err != nil {
 		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
 	}
 
 	return nil
 }
 
+// pruneFiles closes and removes the block files with numbers in the range
+// [fromFileNum, toFileNum) since the data of the blocks they house has been
+// pruned.  Files which no longer exist, such as those which were removed
+// before an unclean shutdown, are skipped.
+//
+// Any errors are simply logged at a warning level rather than being returned
+// since the metadata already marks the blocks as pruned, so a file which fails
+// to be removed is only wasted space.
+func (s *blockStore) pruneFiles(fromFileNum, toFileNum uint32) {
+	s.obfMutex.Lock()
+	defer s.obfMutex.Unlock()
+
+	for fileNum := fromFileNum; fileNum < toFileNum; fileNum++ {
+		s.closeFile(fileNum)
+		err := s.deleteFileFunc(fileNum)
+		if dbErr, ok := err.(database.Error); ok &&
+			os.IsNotExist(dbErr.Err) {
+
+			continue
+		}
+		if err != nil {
+			_ = log.Warnf("PRUNE: Failed to delete block file "+
+				"number %d: %v", fileNum, err)
+		}
+	}
+}
+
+// closeFile closes the passed flat file number if it is open.
+//
+// This function MUST be called with the overall files mutex (s.obfMutex) locked
+// for WRITES.
+func (s *blockStore) closeFile(fileNum uint32) {
+	blockFile, ok := s.openBlockFiles[fileNum]
+	if !ok {
+		return
+	}
+	s.lruMutex.Lock()
+	s.openBlocksLRU.Remove(s.fileNumToLRUElem[fileNum])
+	delete(s.fileNumToLRUElem, fileNum)
+	s.lruMutex.Unlock()
+
+	// Close the file under the write lock for the file in case any readers
+	// are currently reading from it.
+	blockFile.Lock()
+	_ = blockFile.file.Close()
+	blockFile.Unlock()
+	delete(s.openBlockFiles, fileNum)
+}
+
+// archiveFile moves the passed flat file number to the cold storage.  The file
+// is copied and synced before it is removed from the base path, so it is
+// available from either location at all times.
+func (s *blockStore) archiveFile(fileNum uint32) error {
+	// The copy is written under a temporary name, so a partial copy left
+	// by an unclean shutdown is never read.
+	coldFilePath := blockFilePath(s.coldPath, fileNum)
+	tmpPath := coldFilePath + ".tmp"
+	_ = os.Remove(tmpPath)
+	err := s.copyBlockFileTo(tmpPath, fileNum, -1)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, coldFilePath); err != nil {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+
+	// Close the file so it is opened from the cold storage the next time
+	// it is read.  The file is pruned while it is being copied when it is
+	// no longer in the base path, in which case the copy is removed as
+	// well.
+	s.obfMutex.Lock()
+	s.closeFile(fileNum)
+	err = os.Remove(blockFilePath(s.basePath, fileNum))
+	if os.IsNotExist(err) {
+		err = os.Remove(coldFilePath)
+	}
+	s.obfMutex.Unlock()
+	if err != nil {
+		return makeDbErr(database.ErrDriverSpecific, err.Error(), err)
+	}
+	return nil
+}
+
+// archiveFiles advances the archive watermark to the passed file number and
+// wakes the archive handler, which moves the flat block files with numbers
+// before it that are still in the base path to the cold storage.  It does not
+// wait for the files to be moved, and does nothing when there is no cold
+// storage.
+//
+// This is part of the blockStorage interface implementation.
+func (s *blockStore) archiveFiles(toFileNum uint32) {
+	if s.coldPath == "" {
+		return
+	}
+
+	s.archiveMtx.Lock()
+	if toFileNum > s.archiveTo {
+		s.archiveTo = toFileNum
+	}
+	s.archiveMtx.Unlock()
+
+	select {
+	case s.archiveWake <- struct{}{}:
+	default:
+	}
+}
+
+// archiveHandler moves the flat block files before the archive watermark to
+// the cold storage each time it is woken up until the archive quit channel is
+// closed.  It must be run as a goroutine.
+func (s *blockStore) archiveHandler() {
+	defer s.archiveWg.Done()
+
+	for {
+		select {
+		case <-s.archiveWake:
+			s.archivePending()
+
+		case <-s.archiveQuit:
+			return
+		}
+	}
+}
+
+// archivePending moves the flat block files with numbers before the archive
+// watermark, which are still in the base path, to the cold storage.  It stops
+// early when the archive quit channel is closed.
+//
+// Any errors are simply logged at a warning level rather than being returned
+// since the blocks remain available from the base path, and the files are
+// moved again the next time blocks are archived.
+func (s *blockStore) archivePending() {
+	for {
+		select {
+		case <-s.archiveQuit:
+			return
+		default:
+		}
+
+		s.archiveMtx.Lock()
+		fileNum := s.archivedFileNum
+		done := fileNum >= s.archiveTo
+		s.archiveMtx.Unlock()
+		if done {
+			return
+		}
+
+		if fileExists(blockFilePath(s.basePath, fileNum)) {
+			log.Debugf("Moving block file %d to cold storage",
+				fileNum)
+			s.moveMtx.Lock()
+			err := s.archiveFile(fileNum)
+			s.moveMtx.Unlock()
+			if err != nil {
+				log.Warnf("ARCHIVE: Failed to move block file "+
+					"number %d to cold storage: %v", fileNum,
+					err)
+				return
+			}
+		}
+
+		s.archiveMtx.Lock()
+		s.archivedFileNum++
+		s.archiveMtx.Unlock()
+	}
+}
+
 // blockFile attempts to return an existing file handle for the passed flat file
 // number if it is already open as well as marking it as most recently used.  It
 // will also open the file when it's not already open subject to the rules
@@ -588,6 +811,164 @@ func (s *blockStore) readBlockRegion(loc blockLocation, offset, numBytes uint32)
 	return serializedData, nil
 }
 
+// scanBlockFile reads the block records of the passed block file in the order
+// they were written and invokes the passed function with the location and the
+// raw bytes of each block.  The records are read up to the passed end offset,
+// or to the end of the file when it is zero.  Since the records are only
+// delimited by their lengths, the scan stops at the first record which is
+// truncated or fails the integrity checks.
+//
+// Format: <network><block length><serialized block><checksum>
+func (s *blockStore) scanBlockFile(fileNum, endOffset uint32, fn func(loc blockLocation, rawBlock []byte) error) error {
+	blockFile, err := s.blockFile(fileNum)
+	if err != nil {
+		return err
+	}
+	defer blockFile.RUnlock()
+
+	var offset uint32
+	for endOffset == 0 || offset < endOffset {
+		// Read the network and the length of the block.  Reaching the
+		// end of the file before a record starts ends the scan.
+		var recordHdr [8]byte
+		n, err := blockFile.file.ReadAt(recordHdr[:], int64(offset))
+		if n == 0 && err == io.EOF {
+			return nil
+		}
+		if n != len(recordHdr) {
+			log.Warnf("Block file %d is truncated at offset %d",
+				fileNum, offset)
+			return nil
+		}
+		serializedNet := byteOrder.Uint32(recordHdr[0:4])
+		if serializedNet != uint32(s.network) {
+			log.Warnf("Block record in file %d at offset %d is for "+
+				"the wrong network - got %d, want %d", fileNum,
+				offset, serializedNet, uint32(s.network))
+			return nil
+		}
+		blockLen := byteOrder.Uint32(recordHdr[4:8])
+		fullLen := blockLen + 12
+		if blockLen < blockHdrSize || fullLen < blockLen ||
+			(endOffset != 0 && offset+fullLen > endOffset) {
+
+			log.Warnf("Block record in file %d at offset %d has "+
+				"invalid length %d", fileNum, offset, blockLen)
+			return nil
+		}
+
+		// Read the full record and ensure it matches its checksum.
+		serializedData := make([]byte, fullLen)
+		n, err = blockFile.file.ReadAt(serializedData, int64(offset))
+		if n != len(serializedData) {
+			log.Warnf("Block file %d is truncated at offset %d",
+				fileNum, offset)
+			return nil
+		}
+		serializedChecksum := binary.BigEndian.Uint32(
+			serializedData[fullLen-4:])
+		calculatedChecksum := crc32.Checksum(serializedData[:fullLen-4],
+			castagnoli)
+		if serializedChecksum != calculatedChecksum {
+			log.Warnf("Block record in file %d at offset %d does "+
+				"not match its checksum - got %x, want %x",
+				fileNum, offset, calculatedChecksum,
+				serializedChecksum)
+			return nil
+		}
+
+		loc := blockLocation{
+			blockFileNum: fileNum,
+			fileOffset:   offset,
+			blockLen:     fullLen,
+		}
+		if err := fn(loc, serializedData[8:fullLen-4]); err != nil {
+			return err
+		}
+		offset += fullLen
+	}
+	return nil
+}
+
+// copyBlockFile copies the first numBytes bytes of the passed block file to the
+// block file with the same number at the passed path and syncs it.  The whole
+// file is copied when numBytes is negative.
+func (s *blockStore) copyBlockFile(dstPath string, fileNum uint32, numBytes int64) error {
+	return s.copyBlockFileTo(blockFilePath(dstPath, fileNum), fileNum,
+		numBytes)
+}
+
+// copyBlockFileTo copies the first numBytes bytes of the passed block file to a
+// new file at the passed file path and syncs it.  The whole file is copied when
+// numBytes is negative.
+func (s *blockStore) copyBlockFileTo(dstFilePath string, fileNum uint32, numBytes int64) error {
+	srcFile, err := os.Open(s.filePath(fileNum))
+	if err != nil {
+		str := fmt.Sprintf("failed to open block file %d: %v", fileNum,
+			err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	defer srcFile.Close()
+
+	dstFile, err := os.OpenFile(dstFilePath,
+		os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		str := fmt.Sprintf("failed to create block file %d: %v",
+			fileNum, err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	if numBytes < 0 {
+		_, err = io.Copy(dstFile, srcFile)
+	} else {
+		_, err = io.CopyN(dstFile, srcFile, numBytes)
+	}
+	if err == nil {
+		err = dstFile.Sync()
+	}
+	if closeErr := dstFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		str := fmt.Sprintf("failed to copy block file %d: %v", fileNum,
+			err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	return nil
+}
+
+// backup copies the flat files with numbers in the range [fromFileNum,
+// toFileNum] to the passed path, the last one up to the passed offset.  The
+// files are only ever appended to past the write cursor and only removed when
+// pruned, so they are able to be copied while the database is in use as long
+// as no blocks are pruned.  The files are not moved to the cold storage while
+// they are copied.
+func (s *blockStore) backup(dstPath string, fromFileNum, toFileNum, toOffset uint32) error {
+	s.moveMtx.Lock()
+	defer s.moveMtx.Unlock()
+
+	for fileNum := fromFileNum; fileNum < toFileNum; fileNum++ {
+		if err := s.copyBlockFile(dstPath, fileNum, -1); err != nil {
+			return err
+		}
+	}
+
+	// The write file does not exist before the first block is written to
+	// it.
+	if toOffset == 0 {
+		return nil
+	}
+	return s.copyBlockFile(dstPath, toFileNum, int64(toOffset))
+}
+
+// writeLocation returns the number of the current write file and the offset in
+// it the next block will be written to.
+func (s *blockStore) writeLocation() (uint32, uint32) {
+	wc := s.writeCursor
+	wc.RLock()
+	defer wc.RUnlock()
+	return wc.curFileNum, wc.curOffset
+}
+
 // syncBlocks performs a file system sync on the flat file associated with the
 // store's current write cursor.  It is safe to call even when there is not a
 // current write file in which case it will have no effect.
@@ -720,9 +1101,24 @@ func (s *blockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
 // to detect unexpected shutdowns in the middle of writes so the block files
 // can be reconciled.
 func scanBlockFiles(dbPath string) (int, uint32) {
+	// The block files before the first one on disk have been pruned, so
+	// start the scan from it.  The file names are zero padded, so the
+	// matches are sorted by file number.
+	firstFile := 0
+	matches, _ := filepath.Glob(filepath.Join(dbPath, "*.fdb"))
+	for _, match := range matches {
+		var fileNum uint32
+		_, err := fmt.Sscanf(filepath.Base(match), blockFilenameTemplate,
+			&fileNum)
+		if err == nil {
+			firstFile = int(fileNum)
+			break
+		}
+	}
+
 	lastFile := -1
 	fileLen := uint32(0)
-	for i := 0; ; i++ {
+	for i := firstFile; ; i++ {
 		filePath := blockFilePath(dbPath, uint32(i))
 		st, err := os.Stat(filePath)
 		if err != nil {
@@ -739,8 +1135,9 @@ func scanBlockFiles(dbPath string) (int, uint32) {
 }
 
 // newBlockStore returns a new block store with the current block file number
-// and offset set and all fields initialized.
-func newBlockStore(basePath string, network wire.BitcoinNet) *blockStore {
+// and offset set and all fields initialized.  The cold path is the directory
+// the archived files are moved to, or empty when there is no cold storage.
+func newBlockStore(basePath, coldPath string, network wire.BitcoinNet) *blockStore {
 	// Look for the end of the latest block to file to determine what the
 	// write cursor position is from the viewpoing of the block files on
 	// disk.
@@ -753,6 +1150,7 @@ func newBlockStore(basePath string, network wire.BitcoinNet) *blockStore {
 	store := &blockStore{
 		network:          network,
 		basePath:         basePath,
+		coldPath:         coldPath,
 		maxBlockFileSize: maxBlockFileSize,
 		openBlockFiles:   make(map[uint32]*lockableFile),
 		openBlocksLRU:    list.New(),
@@ -767,5 +1165,52 @@ func newBlockStore(basePath string, network wire.BitcoinNet) *blockStore {
 	store.openFileFunc = store.openFile
 	store.openWriteFileFunc = store.openWriteFile
 	store.deleteFileFunc = store.deleteFile
+
+	if coldPath != "" {
+		store.archiveWake = make(chan struct{}, 1)
+		store.archiveQuit = make(chan struct{})
+		store.archiveWg.Add(1)
+		go store.archiveHandler()
+	}
 	return store
 }
+
+// newFlatBlockStore returns a new block store which houses the blocks of the
+// database at the passed path in flat files, the archived ones in the passed
+// cold path when it is not empty.  It is the block storage of the databases
+// created by the ffldb driver.
+func newFlatBlockStore(dbPath, coldPath string, network wire.BitcoinNet, create bool) (blockStorage, error) {
+	if coldPath != "" {
+		if err := os.MkdirAll(coldPath, 0700); err != nil {
+			str := fmt.Sprintf("failed to create cold storage "+
+				"directory %s: %v", coldPath, err)
+			return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+	}
+	return newBlockStore(dbPath, coldPath, network), nil
+}
+
+// close stops the archive handler and closes the open flat files which house
+// the blocks.  A file which is being moved to the cold storage is finished
+// first, while the remaining ones are moved after the database is reopened.
+func (s *blockStore) close() {
+	if s.archiveQuit != nil {
+		close(s.archiveQuit)
+		s.archiveWg.Wait()
+	}
+
+	wc := s.writeCursor
+	if wc.curFile.file != nil {
+		_ = wc.curFile.file.Close()
+		wc.curFile.file = nil
+	}
+	for _, blockFile := range s.openBlockFiles {
+		_ = blockFile.file.Close()
+	}
+	s.openBlockFiles = nil
+	s.openBlocksLRU.Init()
+	s.fileNumToLRUElem = nil
+}
+
+// Enforce blockStore implements the blockStorage interface.
+var _ blockStorage = (*blockStore)(nil)
@@ -0,0 +1,89 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// blockStorage houses the data of the stored blocks on behalf of the database,
+// which only keeps the location of each block in the block index.
+//
+// The blocks are appended to a sequence of numbered block files, which are
+// written one after the other and are never modified afterwards.  A location
+// consists of the number of the file which houses a block, its offset in the
+// file and the length of its record.  How the files are kept is up to the
+// storage, which makes the database usable with storage engines besides flat
+// files while retaining the semantics the database relies on:
+//
+//   - Blocks are only written while a write transaction is committed, and the
+//     current write position is recorded in the metadata once they are
+//   - Writes after a position are able to be rolled back, which is how
+//     failed commits and unclean shutdowns are reconciled with the metadata
+//   - Whole files are removed when the blocks before a block are pruned
+//   - Whole files are moved to the cold storage, when the storage has one,
+//     when the blocks before a block are archived
+//   - The records of the files are able to be scanned to rebuild the block
+//     index
+type blockStorage interface {
+	// writeBlock appends the passed raw block to the current write file
+	// and returns its location.  It moves on to the next file when the
+	// block does not fit in the current one.
+	writeBlock(rawBlock []byte) (blockLocation, error)
+
+	// readBlock returns the raw block with the passed hash housed at the
+	// passed location.
+	readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte, error)
+
+	// readBlockRegion returns the passed number of bytes at the passed
+	// offset of the raw block housed at the passed location.
+	readBlockRegion(loc blockLocation, offset, numBytes uint32) ([]byte, error)
+
+	// scanBlockFile invokes the passed function with the location and raw
+	// bytes of each block housed by the passed file in the order they were
+	// written, up to the passed end offset or the end of the file when it
+	// is zero.
+	scanBlockFile(fileNum, endOffset uint32, fn func(loc blockLocation, rawBlock []byte) error) error
+
+	// writeLocation returns the number of the current write file and the
+	// offset in it the next block is written to.
+	writeLocation() (uint32, uint32)
+
+	// handleRollback removes the blocks written after the passed file
+	// number and offset and moves the write position back to it.
+	handleRollback(oldBlockFileNum, oldBlockOffset uint32)
+
+	// pruneFiles removes the files with numbers in the range
+	// [fromFileNum, toFileNum).
+	pruneFiles(fromFileNum, toFileNum uint32)
+
+	// archiveFiles moves the files with numbers before the passed one to
+	// the cold storage, where the blocks they house remain available.  It
+	// is called with the committed archive watermark while the database
+	// write lock is held, so it must not wait for the files to be moved.
+	// It does nothing when the storage has no cold storage.
+	archiveFiles(toFileNum uint32)
+
+	// backup copies the blocks housed by the files with numbers in the
+	// range [fromFileNum, toFileNum] to a new storage for the database at
+	// the passed path.  Only the data before the passed offset is copied
+	// from the last file.
+	backup(dstPath string, fromFileNum, toFileNum, toOffset uint32) error
+
+	// syncBlocks ensures the written blocks are durable before the
+	// metadata which references them is.
+	syncBlocks() error
+
+	// close releases the resources of the storage.
+	close()
+}
+
+// newBlockStorageFunc is the type of the functions which create the block
+// storage of a database at the passed path.  The location is specific to the
+// storage, such as the directory of the cold storage the archived flat files are
+// moved to, and empty when it is not configured.  The create flag is whether or
+// not the database is being created.
+type newBlockStorageFunc func(dbPath, location string, network wire.BitcoinNet, create bool) (blockStorage, error)
@@ -75,6 +75,15 @@ var (
 	// writeLocKeyName is the key used to store the current write file
 	// location.
 	writeLocKeyName = []byte("ffldb-writeloc")
+
+	// pruneLocKeyName is the key used to store the number of the first
+	// block file which has not been pruned.
+	pruneLocKeyName = []byte("ffldb-pruneloc")
+
+	// archiveLocKeyName is the key used to store the archive watermark,
+	// which is the number of the first block file which has not been
+	// archived.
+	archiveLocKeyName = []byte("ffldb-archiveloc")
 )
 
 // Common error strings.
@@ -967,6 +976,15 @@ type transaction struct {
 	pendingBlocks    map[chainhash.Hash]int
 	pendingBlockData []pendingBlock
 
+	// Block files in the range [pendingPruneFrom, pendingPruneTo) that need
+	// to be deleted on commit since the blocks they house have been pruned.
+	pendingPruneFrom uint32
+	pendingPruneTo   uint32
+
+	// Block files before pendingArchiveTo that need to be moved to the cold
+	// storage after commit since the blocks they house have been archived.
+	pendingArchiveTo uint32
+
 	// Keys that need to be stored or deleted on commit.
 	pendingKeys   *treap.Mutable
 	pendingRemove *treap.Mutable
@@ -1190,6 +1208,49 @@ func (tx *transaction) StoreBlock(block *provautil.Block) error {
 	return nil
 }
 
+// StoreBlockHeader stores the provided block header into the database without
+// the data of the block.  The block is treated like a pruned block, so its
+// header is available, but fetching its data results in ErrBlockPruned.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockExists when the block hash already exists
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) StoreBlockHeader(header *wire.BlockHeader) error {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "store block header requires a writable database " +
+			"transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Reject the block if it already exists.
+	blockHash := header.BlockHash()
+	if tx.hasBlock(&blockHash) {
+		str := fmt.Sprintf("block %s already exists", blockHash)
+		return makeDbErr(database.ErrBlockExists, str, nil)
+	}
+
+	var buf bytes.Buffer
+	if err := header.Serialize(&buf); err != nil {
+		str := fmt.Sprintf("failed to serialize header of block %s",
+			blockHash)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+
+	// Add a record in the block index for the block with an empty
+	// location since there is no block data.
+	blockRow := serializeBlockRow(blockLocation{}, buf.Bytes())
+	return tx.blockIdxBucket.Put(blockHash[:], blockRow)
+}
+
 // HasBlock returns whether or not a block with the given hash exists in the
 // database.
 //
@@ -1239,6 +1300,38 @@ func (tx *transaction) fetchBlockRow(hash *chainhash.Hash) ([]byte, error) {
 	return blockRow, nil
 }
 
+// prunedFileNum returns the number of the first block file which has not been
+// pruned from the viewpoint of the transaction.
+func (tx *transaction) prunedFileNum() uint32 {
+	pruneRow := tx.metaBucket.Get(pruneLocKeyName)
+	if len(pruneRow) != 4 {
+		return 0
+	}
+	return byteOrder.Uint32(pruneRow)
+}
+
+// archivedFileNum returns the archive watermark, which is the number of the
+// first block file which has not been archived, from the viewpoint of the
+// transaction.
+func (tx *transaction) archivedFileNum() uint32 {
+	archiveRow := tx.metaBucket.Get(archiveLocKeyName)
+	if len(archiveRow) != 4 {
+		return 0
+	}
+	return byteOrder.Uint32(archiveRow)
+}
+
+// checkPruned returns ErrBlockPruned when the block with the provided hash and
+// location is housed by a block file before the passed first block file which
+// has not been pruned, or was stored without its data.
+func checkPruned(hash *chainhash.Hash, loc blockLocation, prunedFileNum uint32) error {
+	if loc.blockFileNum < prunedFileNum || loc.blockLen == 0 {
+		str := fmt.Sprintf("block %s has been pruned", hash)
+		return makeDbErr(database.ErrBlockPruned, str, nil)
+	}
+	return nil
+}
+
 // FetchBlockHeader returns the raw serialized bytes for the block header
 // identified by the given hash.  The raw bytes are in the format returned by
 // Serialize on a wire.BlockHeader.
@@ -1368,10 +1461,14 @@ func (tx *transaction) FetchBlock(hash *chainhash.Hash) ([]byte, error) {
 		return nil, err
 	}
 	location := deserializeBlockLoc(blockRow)
+	err = checkPruned(hash, location, tx.prunedFileNum())
+	if err != nil {
+		return nil, err
+	}
 
 	// Read the block from the appropriate location.  The function also
 	// performs a checksum over the data to detect data corruption.
-	blockBytes, err := tx.db.store.readBlock(hash, location)
+	blockBytes, err := tx.db.readBlock(hash, location)
 	if err != nil {
 		return nil, err
 	}
@@ -1499,6 +1596,10 @@ func (tx *transaction) FetchBlockRegion(region *database.BlockRegion) ([]byte, e
 		return nil, err
 	}
 	location := deserializeBlockLoc(blockRow)
+	err = checkPruned(region.Hash, location, tx.prunedFileNum())
+	if err != nil {
+		return nil, err
+	}
 
 	// Ensure the region is within the bounds of the block.
 	endOffset := region.Offset + region.Len
@@ -1511,7 +1612,7 @@ func (tx *transaction) FetchBlockRegion(region *database.BlockRegion) ([]byte, e
 	}
 
 	// Read the region from the appropriate disk block file.
-	regionBytes, err := tx.db.store.readBlockRegion(location, region.Offset,
+	regionBytes, err := tx.db.readBlockRegion(location, region.Offset,
 		region.Len)
 	if err != nil {
 		return nil, err
@@ -1574,6 +1675,7 @@ func (tx *transaction) FetchBlockRegions(regions []database.BlockRegion) ([][]by
 	// hence there is no need to fetch those from disk.
 	blockRegions := make([][]byte, len(regions))
 	fetchList := make([]bulkFetchData, 0, len(regions))
+	prunedFileNum := tx.prunedFileNum()
 	for i := range regions {
 		region := &regions[i]
 
@@ -1597,6 +1699,10 @@ func (tx *transaction) FetchBlockRegions(regions []database.BlockRegion) ([][]by
 			return nil, err
 		}
 		location := deserializeBlockLoc(blockRow)
+		err = checkPruned(region.Hash, location, prunedFileNum)
+		if err != nil {
+			return nil, err
+		}
 
 		// Ensure the region is within the bounds of the block.
 		endOffset := region.Offset + region.Len
@@ -1617,7 +1723,7 @@ func (tx *transaction) FetchBlockRegions(regions []database.BlockRegion) ([][]by
 		ri := fetchData.replyIndex
 		region := &regions[ri]
 		location := fetchData.blockLocation
-		regionBytes, err := tx.db.store.readBlockRegion(*location,
+		regionBytes, err := tx.db.readBlockRegion(*location,
 			region.Offset, region.Len)
 		if err != nil {
 			return nil, err
@@ -1628,6 +1734,276 @@ func (tx *transaction) FetchBlockRegions(regions []database.BlockRegion) ([][]by
 	return blockRegions, nil
 }
 
+// PruneBlocks removes the raw serialized bytes of the blocks stored before the
+// block identified by the given hash in order to reclaim disk space.  Only
+// whole block files are removed, so the blocks stored before the given block
+// in the same file are retained.  The files are removed on commit.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if the requested block hash does not exist
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) PruneBlocks(hash *chainhash.Hash) error {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "prune blocks requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Blocks which are pending to be written on commit are stored in the
+	// current write file, which is never pruned, so there is nothing to do.
+	if _, exists := tx.pendingBlocks[*hash]; exists {
+		return nil
+	}
+
+	// Nothing to do when the file which houses the block, and therefore
+	// all of the files before it, have already been pruned.
+	blockRow, err := tx.fetchBlockRow(hash)
+	if err != nil {
+		return err
+	}
+	location := deserializeBlockLoc(blockRow)
+	prunedFileNum := tx.prunedFileNum()
+	if location.blockFileNum <= prunedFileNum {
+		return nil
+	}
+
+	// Mark the blocks in the files before the one which houses the block
+	// as pruned and remember to delete the files on commit.
+	var pruneRow [4]byte
+	byteOrder.PutUint32(pruneRow[:], location.blockFileNum)
+	if err := tx.metaBucket.Put(pruneLocKeyName, pruneRow[:]); err != nil {
+		return err
+	}
+	if tx.pendingPruneTo == 0 {
+		tx.pendingPruneFrom = prunedFileNum
+	}
+	tx.pendingPruneTo = location.blockFileNum
+	return nil
+}
+
+// ArchiveBlocks moves the raw serialized bytes of the blocks stored before the
+// block identified by the given hash to the cold storage of the database, when
+// it has one.  Only whole block files are moved, so the blocks stored before the
+// given block in the same file are left in place.  The archive watermark is
+// advanced on commit and the files are moved in the background afterwards.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if the requested block hash does not exist
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) ArchiveBlocks(hash *chainhash.Hash) error {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "archive blocks requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Blocks which are pending to be written on commit are stored in the
+	// current write file, which is never archived, so there is nothing to
+	// do.
+	if _, exists := tx.pendingBlocks[*hash]; exists {
+		return nil
+	}
+
+	// Advance the archive watermark to the file which houses the block and
+	// remember to move the files before it after commit.  The block might
+	// only have its header stored, in which case its location does not
+	// identify a file.
+	blockRow, err := tx.fetchBlockRow(hash)
+	if err != nil {
+		return err
+	}
+	location := deserializeBlockLoc(blockRow)
+	if location.blockLen == 0 ||
+		location.blockFileNum <= tx.archivedFileNum() {
+
+		return nil
+	}
+	var archiveRow [4]byte
+	byteOrder.PutUint32(archiveRow[:], location.blockFileNum)
+	if err := tx.metaBucket.Put(archiveLocKeyName, archiveRow[:]); err != nil {
+		return err
+	}
+	tx.pendingArchiveTo = location.blockFileNum
+	return nil
+}
+
+// RebuildBlockIndex rebuilds the block index from the block records in the
+// flat files which have not been pruned, and returns the hashes of the blocks
+// found in the order they were written.  The index records of the blocks in
+// those files are replaced, while the records of pruned blocks and of blocks
+// stored without their data are retained.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) RebuildBlockIndex() ([]chainhash.Hash, error) {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return nil, err
+	}
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "rebuild block index requires a writable database " +
+			"transaction"
+		return nil, makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// Remove the records of the blocks housed by the files which are
+	// rebuilt, so blocks which can no longer be read from them are not
+	// reported as existing.
+	prunedFileNum := tx.prunedFileNum()
+	var staleKeys [][]byte
+	err := tx.blockIdxBucket.ForEach(func(k, v []byte) error {
+		loc := deserializeBlockLoc(v)
+		if loc.blockLen != 0 && loc.blockFileNum >= prunedFileNum {
+			staleKeys = append(staleKeys, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range staleKeys {
+		if err := tx.blockIdxBucket.Delete(k); err != nil {
+			return nil, err
+		}
+	}
+
+	// Add a record for every block in the files up to the current write
+	// position.  The write file does not exist before the first block is
+	// written to it.
+	curFileNum, curOffset := tx.db.store.writeLocation()
+	var hashes []chainhash.Hash
+	seen := make(map[chainhash.Hash]struct{})
+	for fileNum := prunedFileNum; fileNum <= curFileNum; fileNum++ {
+		var endOffset uint32
+		if fileNum == curFileNum {
+			if curOffset == 0 {
+				break
+			}
+			endOffset = curOffset
+		}
+
+		numBlocks := len(hashes)
+		err := tx.db.store.scanBlockFile(fileNum, endOffset,
+			func(loc blockLocation, rawBlock []byte) error {
+				if tx.db.encryptor != nil {
+					var err error
+					rawBlock, err = tx.db.encryptor.decryptBlock(
+						rawBlock)
+					if err != nil {
+						return err
+					}
+				}
+				var header wire.BlockHeader
+				err := header.Deserialize(bytes.NewReader(rawBlock))
+				if err != nil {
+					log.Warnf("Skipping block in file %d at "+
+						"offset %d with invalid header: %v",
+						fileNum, loc.fileOffset, err)
+					return nil
+				}
+				hash := header.BlockHash()
+				if _, ok := seen[hash]; ok {
+					return nil
+				}
+				seen[hash] = struct{}{}
+				hashes = append(hashes, hash)
+
+				blockRow := serializeBlockRow(loc,
+					rawBlock[:blockHdrSize])
+				return tx.blockIdxBucket.Put(hash[:], blockRow)
+			})
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("Indexed %d blocks from block file %d",
+			len(hashes)-numBlocks, fileNum)
+	}
+
+	return hashes, nil
+}
+
+// RepairBlock stores the provided block again in place of its stored data and
+// updates its record in the block index to the location of the new copy when
+// the transaction is committed.
+//
+// Returns the following errors as required by the interface contract:
+//   - ErrBlockNotFound if the block hash does not exist
+//   - ErrBlockPruned if the block has been pruned or was stored without its
+//     data
+//   - ErrTxNotWritable if attempted against a read-only transaction
+//   - ErrTxClosed if the transaction has already been closed
+//
+// This function is part of the database.Tx interface implementation.
+func (tx *transaction) RepairBlock(block *provautil.Block) error {
+	// Ensure transaction state is valid.
+	if err := tx.checkClosed(); err != nil {
+		return err
+	}
+
+	// Ensure the transaction is writable.
+	if !tx.writable {
+		str := "repair block requires a writable database transaction"
+		return makeDbErr(database.ErrTxNotWritable, str, nil)
+	}
+
+	// The block must be stored with its data, which has not been pruned.
+	blockHash := block.Hash()
+	blockRow, err := tx.fetchBlockRow(blockHash)
+	if err != nil {
+		return err
+	}
+	location := deserializeBlockLoc(blockRow)
+	err = checkPruned(blockHash, location, tx.prunedFileNum())
+	if err != nil {
+		return err
+	}
+
+	blockBytes, err := block.Bytes()
+	if err != nil {
+		str := fmt.Sprintf("failed to get serialized bytes for block %s",
+			blockHash)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+
+	// Add the block to the pending blocks, which replaces its record in
+	// the block index when the transaction is committed.
+	if _, exists := tx.pendingBlocks[*blockHash]; exists {
+		return nil
+	}
+	if tx.pendingBlocks == nil {
+		tx.pendingBlocks = make(map[chainhash.Hash]int)
+	}
+	tx.pendingBlocks[*blockHash] = len(tx.pendingBlockData)
+	tx.pendingBlockData = append(tx.pendingBlockData, pendingBlock{
+		hash:  blockHash,
+		bytes: blockBytes,
+	})
+	log.Debugf("Added block %s to pending blocks for repair", blockHash)
+
+	return nil
+}
+
 // close marks the transaction closed then releases any pending data, the
 // underlying snapshot, the transaction read lock, and the write lock when the
 // transaction is writable.
@@ -1681,11 +2057,7 @@ func (tx *transaction) writePendingAndCommit() error {
 	// These variables are only updated here in this function and there can
 	// only be one write transaction active at a time, so it's safe to store
 	// them for potential rollback.
-	wc := tx.db.store.writeCursor
-	wc.RLock()
-	oldBlkFileNum := wc.curFileNum
-	oldBlkOffset := wc.curOffset
-	wc.RUnlock()
+	oldBlkFileNum, oldBlkOffset := tx.db.store.writeLocation()
 
 	// rollback is a closure that is used to rollback all writes to the
 	// block files.
@@ -1697,7 +2069,7 @@ func (tx *transaction) writePendingAndCommit() error {
 	// Loop through all of the pending blocks to store and write them.
 	for _, blockData := range tx.pendingBlockData {
 		log.Tracef("Storing block %s", blockData.hash)
-		location, err := tx.db.store.writeBlock(blockData.bytes)
+		location, err := tx.db.writeBlock(blockData.bytes)
 		if err != nil {
 			rollback()
 			return err
@@ -1717,7 +2089,7 @@ func (tx *transaction) writePendingAndCommit() error {
 	}
 
 	// Update the metadata for the current write file and offset.
-	writeRow := serializeWriteRow(wc.curFileNum, wc.curOffset)
+	writeRow := serializeWriteRow(tx.db.store.writeLocation())
 	if err := tx.metaBucket.Put(writeLocKeyName, writeRow); err != nil {
 		rollback()
 		return convertErr("failed to store write cursor", err)
@@ -1725,7 +2097,28 @@ func (tx *transaction) writePendingAndCommit() error {
 
 	// Atomically update the database cache.  The cache automatically
 	// handles flushing to the underlying persistent storage database.
-	return tx.db.cache.commitTx(tx)
+	if err := tx.db.cache.commitTx(tx); err != nil {
+		return err
+	}
+
+	// Delete the block files which have been pruned.  The cache is flushed
+	// first so the blocks they house are marked as pruned in persistent
+	// storage before they are gone.
+	if tx.pendingPruneTo > tx.pendingPruneFrom {
+		if err := tx.db.cache.flush(); err != nil {
+			return err
+		}
+		tx.db.store.pruneFiles(tx.pendingPruneFrom, tx.pendingPruneTo)
+	}
+
+	// Hand the committed archive watermark to the block storage, which
+	// moves the block files before it to the cold storage in the
+	// background since copying them would hold the write lock for too
+	// long.  The blocks they house remain available throughout.
+	if tx.pendingArchiveTo > 0 {
+		tx.db.store.archiveFiles(tx.pendingArchiveTo)
+	}
+	return nil
 }
 
 // Commit commits all changes that have been made to the root metadata bucket
@@ -1789,8 +2182,61 @@ type db struct {
 	writeLock sync.Mutex   // Limit to one write transaction at a time.
 	closeLock sync.RWMutex // Make database close block while txns active.
 	closed    bool         // Is the database closed?
-	store     *blockStore  // Handles read/writing blocks to flat files.
+	dbType    string       // Type of the driver which opened the database.
+	store     blockStorage // Handles read/writing blocks to the storage.
 	cache     *dbCache     // Cache layer which wraps underlying leveldb DB.
+
+	// encryptor encrypts the block data and the metadata when the database
+	// is encrypted at rest and is nil otherwise.  releaseLdb releases the
+	// storage of the metadata database once it is closed.
+	encryptor  *encryptor
+	releaseLdb func()
+}
+
+// writeBlock writes the passed raw block to the block storage, encrypted when
+// the database is encrypted at rest, and returns its location.
+func (db *db) writeBlock(rawBlock []byte) (blockLocation, error) {
+	if db.encryptor != nil {
+		var err error
+		rawBlock, err = db.encryptor.encryptBlock(rawBlock)
+		if err != nil {
+			return blockLocation{}, err
+		}
+	}
+	return db.store.writeBlock(rawBlock)
+}
+
+// readBlock reads the raw block with the passed hash at the passed location
+// from the block storage, decrypting it when the database is encrypted at
+// rest.
+func (db *db) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte, error) {
+	blockBytes, err := db.store.readBlock(hash, loc)
+	if err != nil || db.encryptor == nil {
+		return blockBytes, err
+	}
+	return db.encryptor.decryptBlock(blockBytes)
+}
+
+// readBlockRegion reads the passed number of bytes at the passed offset of the
+// raw block at the passed location from the block storage, decrypting them when
+// the database is encrypted at rest.
+func (db *db) readBlockRegion(loc blockLocation, offset, numBytes uint32) ([]byte, error) {
+	if db.encryptor == nil {
+		return db.store.readBlockRegion(loc, offset, numBytes)
+	}
+
+	// The encrypted block is prefixed by its initialization vector.
+	iv, err := db.store.readBlockRegion(loc, 0, encryptionIVSize)
+	if err != nil {
+		return nil, err
+	}
+	region, err := db.store.readBlockRegion(loc, encryptionIVSize+offset,
+		numBytes)
+	if err != nil {
+		return nil, err
+	}
+	db.encryptor.decryptBlockRegion(iv, region, offset)
+	return region, nil
 }
 
 // Enforce db implements the database.DB interface.
@@ -1801,7 +2247,7 @@ var _ database.DB = (*db)(nil)
 //
 // This function is part of the database.DB interface implementation.
 func (db *db) Type() string {
-	return dbType
+	return db.dbType
 }
 
 // begin is the implementation function for the Begin database method.  See its
@@ -1983,19 +2429,10 @@ func (db *db) Close() error {
 	// database will be marked closed even if this fails given there is no
 	// good way for the caller to recover from a failure here anyways.
 	closeErr := db.cache.Close()
+	db.releaseLdb()
 
-	// Close any open flat files that house the blocks.
-	wc := db.store.writeCursor
-	if wc.curFile.file != nil {
-		_ = wc.curFile.file.Close()
-		wc.curFile.file = nil
-	}
-	for _, blockFile := range db.store.openBlockFiles {
-		_ = blockFile.file.Close()
-	}
-	db.store.openBlockFiles = nil
-	db.store.openBlocksLRU.Init()
-	db.store.fileNumToLRUElem = nil
+	// Close the storage that houses the blocks.
+	db.store.close()
 
 	return closeErr
 }
@@ -2039,9 +2476,41 @@ func initDB(ldb *leveldb.DB) error {
 	return nil
 }
 
-// openDB opens the database at the provided path.  database.ErrDbDoesNotExist
-// is returned if the database doesn't exist and the create flag is not set.
+// openDB opens the database at the provided path with the blocks housed in flat
+// files.  database.ErrDbDoesNotExist is returned if the database doesn't exist
+// and the create flag is not set.
 func openDB(dbPath string, network wire.BitcoinNet, create bool) (database.DB, error) {
+	return openDBWithStorage(dbType, newFlatBlockStore, dbPath, "", network,
+		nil, create)
+}
+
+// metadataDbOptions returns the options the metadata database is opened with.
+// The create flag is whether or not it must not exist yet.
+func metadataDbOptions(create bool) *opt.Options {
+	return &opt.Options{
+		ErrorIfExist: create,
+		Strict:       opt.DefaultStrict,
+		Compression:  opt.NoCompression,
+		Filter:       filter.NewBloomFilter(10),
+	}
+}
+
+// openDBWithStorage opens the database at the provided path with the blocks
+// housed in the block storage created by the passed function at the passed
+// storage location, such as the cold path the archived blocks are moved to.  The
+// data of the database is encrypted at rest with the passed encryption key,
+// unless it is nil.  database.ErrDbDoesNotExist is returned if the database
+// doesn't exist and the create flag is not set.
+func openDBWithStorage(dbType string, newStore newBlockStorageFunc, dbPath, location string, network wire.BitcoinNet, encryptionKey []byte, create bool) (database.DB, error) {
+	var enc *encryptor
+	if encryptionKey != nil {
+		var err error
+		enc, err = newEncryptor(encryptionKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Error if the database doesn't exist and the create flag is not set.
 	metadataDbPath := filepath.Join(dbPath, metadataDbName)
 	dbExists := fileExists(metadataDbPath)
@@ -2058,28 +2527,47 @@ func openDB(dbPath string, network wire.BitcoinNet, create bool) (database.DB, e
 		_ = os.MkdirAll(dbPath, 0700)
 	}
 
-	// Open the metadata database (will create it if needed).
-	opts := opt.Options{
-		ErrorIfExist: create,
-		Strict:       opt.DefaultStrict,
-		Compression:  opt.NoCompression,
-		Filter:       filter.NewBloomFilter(10),
+	// Ensure the database is opened with the key it is encrypted with, if
+	// any, or record the key of a new encrypted database.
+	if err := checkKey(dbPath, enc, create && !dbExists); err != nil {
+		return nil, err
 	}
-	ldb, err := leveldb.OpenFile(metadataDbPath, &opts)
+
+	// Open the metadata database (will create it if needed).
+	ldb, releaseLdb, err := openLdb(metadataDbPath,
+		metadataDbOptions(create), enc)
 	if err != nil {
 		return nil, convertErr(err.Error(), err)
 	}
 
-	// Create the block store which includes scanning the existing flat
-	// block files to find what the current write cursor position is
-	// according to the data that is actually on disk.  Also create the
-	// database cache which wraps the underlying leveldb database to provide
-	// write caching.
-	store := newBlockStore(dbPath, network)
+	// Create the block storage which includes finding what the current
+	// write cursor position is according to the data that is actually
+	// stored.  Also create the database cache which wraps the underlying
+	// leveldb database to provide write caching.
+	store, err := newStore(dbPath, location, network, create)
+	if err != nil {
+		_ = ldb.Close()
+		releaseLdb()
+		return nil, err
+	}
 	cache := newDbCache(ldb, store, defaultCacheSize, defaultFlushSecs)
-	pdb := &db{store: store, cache: cache}
+	pdb := &db{
+		dbType:     dbType,
+		store:      store,
+		cache:      cache,
+		encryptor:  enc,
+		releaseLdb: releaseLdb,
+	}
 
 	// Perform any reconciliation needed between the block and metadata as
-	// well as database initialization, if needed.
-	return reconcileDB(pdb, create)
+	// well as database initialization, if needed.  The underlying databases
+	// are closed on failure so they are able to be opened again.
+	rdb, err := reconcileDB(pdb, create)
+	if err != nil {
+		_ = ldb.Close()
+		releaseLdb()
+		store.close()
+		return nil, err
+	}
+	return rdb, nil
 }
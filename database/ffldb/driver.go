@@ -17,51 +17,90 @@ var log = btclog.Disabled
 
 const (
 	dbType = "ffldb"
+
+	// ldbDbType is the type of the databases which house the blocks in a
+	// leveldb database instead of flat files.
+	ldbDbType = "ldb"
 )
 
-// parseArgs parses the arguments from the database Open/Create methods.
-func parseArgs(funcName string, args ...interface{}) (string, wire.BitcoinNet, error) {
-	if len(args) != 2 {
-		return "", 0, fmt.Errorf("invalid arguments to %s.%s -- "+
-			"expected database path and block network", dbType,
+// parseArgs parses the arguments from the database Open/Create methods.  The
+// encryption key and the block storage location are optional, and nil and empty
+// respectively when they are not passed.  The location is the cold storage path
+// for the ffldb databases and the connection string of the PostgreSQL database
+// for the pgsql databases.
+func parseArgs(dbType, funcName string, args ...interface{}) (string, wire.BitcoinNet, []byte, string, error) {
+	if len(args) < 2 || len(args) > 4 {
+		return "", 0, nil, "", fmt.Errorf("invalid arguments to %s.%s "+
+			"-- expected database path, block network, and "+
+			"optional encryption key and block storage location",
+			dbType,
 			funcName)
 	}
 
 	dbPath, ok := args[0].(string)
 	if !ok {
-		return "", 0, fmt.Errorf("first argument to %s.%s is invalid -- "+
-			"expected database path string", dbType, funcName)
+		return "", 0, nil, "", fmt.Errorf("first argument to %s.%s is "+
+			"invalid -- expected database path string", dbType,
+			funcName)
 	}
 
 	network, ok := args[1].(wire.BitcoinNet)
 	if !ok {
-		return "", 0, fmt.Errorf("second argument to %s.%s is invalid -- "+
-			"expected block network", dbType, funcName)
+		return "", 0, nil, "", fmt.Errorf("second argument to %s.%s is "+
+			"invalid -- expected block network", dbType, funcName)
 	}
 
-	return dbPath, network, nil
-}
+	var encryptionKey []byte
+	if len(args) >= 3 {
+		encryptionKey, ok = args[2].([]byte)
+		if !ok {
+			return "", 0, nil, "", fmt.Errorf("third argument to %s.%s "+
+				"is invalid -- expected encryption key bytes",
+				dbType, funcName)
+		}
+	}
 
-// openDBDriver is the callback provided during driver registration that opens
-// an existing database for use.
-func openDBDriver(args ...interface{}) (database.DB, error) {
-	dbPath, network, err := parseArgs("Open", args...)
-	if err != nil {
-		return nil, err
+	var location string
+	if len(args) == 4 {
+		location, ok = args[3].(string)
+		if !ok {
+			return "", 0, nil, "", fmt.Errorf("fourth argument to "+
+				"%s.%s is invalid -- expected block storage "+
+				"location string", dbType, funcName)
+		}
 	}
 
-	return openDB(dbPath, network, false)
+	return dbPath, network, encryptionKey, location, nil
 }
 
-// createDBDriver is the callback provided during driver registration that
-// creates, initializes, and opens a database for use.
-func createDBDriver(args ...interface{}) (database.DB, error) {
-	dbPath, network, err := parseArgs("Create", args...)
-	if err != nil {
-		return nil, err
+// openDBDriver returns the callback provided during driver registration that
+// opens an existing database of the passed type for use.
+func openDBDriver(dbType string, newStore newBlockStorageFunc) func(args ...interface{}) (database.DB, error) {
+	return func(args ...interface{}) (database.DB, error) {
+		dbPath, network, encryptionKey, location, err := parseArgs(
+			dbType, "Open", args...)
+		if err != nil {
+			return nil, err
+		}
+
+		return openDBWithStorage(dbType, newStore, dbPath, location,
+			network, encryptionKey, false)
 	}
+}
 
-	return openDB(dbPath, network, true)
+// createDBDriver returns the callback provided during driver registration that
+// creates, initializes, and opens a database of the passed type for use.
+func createDBDriver(dbType string, newStore newBlockStorageFunc) func(args ...interface{}) (database.DB, error) {
+	return func(args ...interface{}) (database.DB, error) {
+		dbPath, network, encryptionKey, location, err := parseArgs(
+			dbType, "Create", args...)
+		if err != nil {
+			return nil, err
+		}
+
+		return openDBWithStorage(dbType, newStore, dbPath, location,
+			network, encryptionKey, true)
+	}
 }
 
 // useLogger is the callback provided during driver registration that sets the
@@ -70,12 +109,13 @@ func useLogger(logger btclog.Logger) {
 	log = logger
 }
 
-func init() {
-	// Register the driver.
+// registerDriver registers the driver of the databases of the passed type,
+// which house their blocks in the storage created by the passed function.
+func registerDriver(dbType string, newStore newBlockStorageFunc) {
 	driver := database.Driver{
 		DbType:    dbType,
-		Create:    createDBDriver,
-		Open:      openDBDriver,
+		Create:    createDBDriver(dbType, newStore),
+		Open:      openDBDriver(dbType, newStore),
 		UseLogger: useLogger,
 	}
 	if err := database.RegisterDriver(driver); err != nil {
@@ -83,3 +123,13 @@ func init() {
 			dbType, err))
 	}
 }
+
+func init() {
+	// Register the drivers.  The databases of all of them house the
+	// metadata in leveldb, while the ffldb databases house the blocks in
+	// flat files and the ldb databases house them in a separate leveldb
+	// database.  The pgsql databases, which house them in PostgreSQL, are
+	// registered by pgsqlblockio.go when built with the pgsql tag.
+	registerDriver(dbType, newFlatBlockStore)
+	registerDriver(ldbDbType, newLdbBlockStore)
+}
@@ -6,6 +6,7 @@
 package ffldb_test
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -14,6 +15,7 @@ import (
 	"testing"
 
 	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/database"
 	"github.com/bitgo/prova/database/ffldb"
 	"github.com/bitgo/prova/provautil"
@@ -38,8 +40,9 @@ func TestCreateOpenFail(t *testing.T) {
 	// Ensure that attempting to open a database with the wrong number of
 	// parameters returns the expected error.
 	wantErr := fmt.Errorf("invalid arguments to %s.Open -- expected "+
-		"database path and block network", dbType)
-	_, err = database.Open(dbType, 1, 2, 3)
+		"database path, block network, and optional encryption key "+
+		"and block storage location", dbType)
+	_, err = database.Open(dbType, 1, 2, 3, 4, 5)
 	if err.Error() != wantErr.Error() {
 		t.Errorf("Open: did not receive expected error - got %v, "+
 			"want %v", err, wantErr)
@@ -68,11 +71,34 @@ func TestCreateOpenFail(t *testing.T) {
 		return
 	}
 
+	// Ensure that attempting to open a database with an invalid type for
+	// the third parameter returns the expected error.
+	wantErr = fmt.Errorf("third argument to %s.Open is invalid -- "+
+		"expected encryption key bytes", dbType)
+	_, err = database.Open(dbType, "noexist", blockDataNet, "invalid")
+	if err.Error() != wantErr.Error() {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure that attempting to open a database with an invalid type for
+	// the fourth parameter returns the expected error.
+	wantErr = fmt.Errorf("fourth argument to %s.Open is invalid -- "+
+		"expected block storage location string", dbType)
+	_, err = database.Open(dbType, "noexist", blockDataNet, []byte(nil), 1)
+	if err.Error() != wantErr.Error() {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
 	// Ensure that attempting to create a database with the wrong number of
 	// parameters returns the expected error.
 	wantErr = fmt.Errorf("invalid arguments to %s.Create -- expected "+
-		"database path and block network", dbType)
-	_, err = database.Create(dbType, 1, 2, 3)
+		"database path, block network, and optional encryption key "+
+		"and block storage location", dbType)
+	_, err = database.Create(dbType, 1, 2, 3, 4, 5)
 	if err.Error() != wantErr.Error() {
 		t.Errorf("Create: did not receive expected error - got %v, "+
 			"want %v", err, wantErr)
@@ -101,6 +127,28 @@ func TestCreateOpenFail(t *testing.T) {
 		return
 	}
 
+	// Ensure that attempting to create a database with an invalid type for
+	// the third parameter returns the expected error.
+	wantErr = fmt.Errorf("third argument to %s.Create is invalid -- "+
+		"expected encryption key bytes", dbType)
+	_, err = database.Create(dbType, "noexist", blockDataNet, "invalid")
+	if err.Error() != wantErr.Error() {
+		t.Errorf("Create: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure that attempting to create a database with an invalid type for
+	// the fourth parameter returns the expected error.
+	wantErr = fmt.Errorf("fourth argument to %s.Create is invalid -- "+
+		"expected block storage location string", dbType)
+	_, err = database.Create(dbType, "noexist", blockDataNet, []byte(nil), 1)
+	if err.Error() != wantErr.Error() {
+		t.Errorf("Create: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
 	// Ensure operations against a closed database return the expected
 	// error.
 	dbPath := filepath.Join(os.TempDir(), "ffldb-createfail")
@@ -141,6 +189,12 @@ func TestCreateOpenFail(t *testing.T) {
 		return
 	}
 
+	wantErrCode = database.ErrDbNotOpen
+	err = db.Backup(filepath.Join(os.TempDir(), "ffldb-createfailbackup"))
+	if !checkDbError(t, "Backup", err, wantErrCode) {
+		return
+	}
+
 	wantErrCode = database.ErrDbNotOpen
 	err = db.Close()
 	if !checkDbError(t, "Close", err, wantErrCode) {
@@ -287,3 +341,474 @@ func TestInterface(t *testing.T) {
 		testInterface(t, db)
 	})
 }
+
+// TestPruneBlocks ensures pruning removes the block files before the one which
+// houses the given block while the headers of the pruned blocks remain
+// available, including after reopening the database.
+func TestPruneBlocks(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-prunetest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	// Create blocks based on the genesis block which are distinguished by
+	// their height.
+	blocks := make([]*provautil.Block, 41)
+	for i := range blocks {
+		msgBlock := *chaincfg.MainNetParams.GenesisBlock
+		msgBlock.Header.Height = uint32(i)
+		blocks[i] = provautil.NewBlock(&msgBlock)
+	}
+	newBlock := blocks[40]
+	blocks = blocks[:40]
+
+	// Store the blocks in small block files so they span several files.
+	ffldb.TstRunWithMaxBlockFileSize(db, 1024, func() {
+		for _, block := range blocks {
+			err = db.Update(func(tx database.Tx) error {
+				return tx.StoreBlock(block)
+			})
+			if err != nil {
+				return
+			}
+		}
+	})
+	if err != nil {
+		t.Errorf("StoreBlock: unexpected error: %v", err)
+		return
+	}
+
+	// Ensure pruning requires a writable transaction.
+	pruneHash := blocks[30].Hash()
+	err = db.View(func(tx database.Tx) error {
+		return tx.PruneBlocks(pruneHash)
+	})
+	if !checkDbError(t, "PruneBlocks on read-only tx", err,
+		database.ErrTxNotWritable) {
+		return
+	}
+	err = db.Update(func(tx database.Tx) error {
+		return tx.PruneBlocks(pruneHash)
+	})
+	if err != nil {
+		t.Errorf("PruneBlocks: unexpected error: %v", err)
+		return
+	}
+	if _, err := os.Stat(filepath.Join(dbPath, "000000000.fdb")); !os.IsNotExist(err) {
+		t.Errorf("PruneBlocks: first block file was not removed")
+		return
+	}
+
+	// checkPruned ensures the first block is pruned, its header and the
+	// block which was passed to PruneBlocks are still available.
+	checkPruned := func() error {
+		return db.View(func(tx database.Tx) error {
+			firstHash := blocks[0].Hash()
+			_, err := tx.FetchBlock(firstHash)
+			if !checkDbError(t, "FetchBlock", err,
+				database.ErrBlockPruned) {
+
+				return fmt.Errorf("FetchBlock: pruned block " +
+					"fetched")
+			}
+			region := database.BlockRegion{Hash: firstHash, Len: 1}
+			_, err = tx.FetchBlockRegion(&region)
+			if !checkDbError(t, "FetchBlockRegion", err,
+				database.ErrBlockPruned) {
+
+				return fmt.Errorf("FetchBlockRegion: pruned " +
+					"block region fetched")
+			}
+			if exists, _ := tx.HasBlock(firstHash); !exists {
+				return fmt.Errorf("HasBlock: pruned block " +
+					"does not exist")
+			}
+			if _, err := tx.FetchBlockHeader(firstHash); err != nil {
+				return fmt.Errorf("FetchBlockHeader: unexpected "+
+					"error: %v", err)
+			}
+			if _, err := tx.FetchBlock(pruneHash); err != nil {
+				return fmt.Errorf("FetchBlock: unexpected "+
+					"error: %v", err)
+			}
+			return nil
+		})
+	}
+	if err := checkPruned(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// Close and reopen the database to ensure the blocks remain pruned and
+	// new blocks are still able to be stored.
+	db.Close()
+	db, err = database.Open(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to open test database (%s) %v", dbType, err)
+		return
+	}
+	defer db.Close()
+	if err := checkPruned(); err != nil {
+		t.Error(err)
+		return
+	}
+	err = db.Update(func(tx database.Tx) error {
+		return tx.StoreBlock(newBlock)
+	})
+	if err != nil {
+		t.Errorf("StoreBlock: unexpected error: %v", err)
+	}
+}
+
+// TestStoreBlockHeader ensures blocks stored without their data exist and have
+// their headers available while fetching their data reports them as pruned.
+func TestStoreBlockHeader(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-storeblockheadertest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	header := chaincfg.MainNetParams.GenesisBlock.Header
+	hash := header.BlockHash()
+	err = db.View(func(tx database.Tx) error {
+		return tx.StoreBlockHeader(&header)
+	})
+	if !checkDbError(t, "StoreBlockHeader on read-only tx", err,
+		database.ErrTxNotWritable) {
+		return
+	}
+	err = db.Update(func(tx database.Tx) error {
+		return tx.StoreBlockHeader(&header)
+	})
+	if err != nil {
+		t.Errorf("StoreBlockHeader: unexpected error: %v", err)
+		return
+	}
+
+	err = db.Update(func(tx database.Tx) error {
+		err := tx.StoreBlockHeader(&header)
+		if !checkDbError(t, "StoreBlockHeader duplicate", err,
+			database.ErrBlockExists) {
+
+			return fmt.Errorf("StoreBlockHeader: stored duplicate")
+		}
+		err = tx.StoreBlock(provautil.NewBlock(
+			chaincfg.MainNetParams.GenesisBlock))
+		if !checkDbError(t, "StoreBlock duplicate", err,
+			database.ErrBlockExists) {
+
+			return fmt.Errorf("StoreBlock: stored duplicate")
+		}
+
+		if exists, _ := tx.HasBlock(&hash); !exists {
+			return fmt.Errorf("HasBlock: block does not exist")
+		}
+		gotHeader, err := tx.FetchBlockHeader(&hash)
+		if err != nil {
+			return fmt.Errorf("FetchBlockHeader: unexpected error: "+
+				"%v", err)
+		}
+		var buf bytes.Buffer
+		if err := header.Serialize(&buf); err != nil {
+			return err
+		}
+		if !bytes.Equal(gotHeader, buf.Bytes()) {
+			return fmt.Errorf("FetchBlockHeader: stored header " +
+				"mismatch")
+		}
+		_, err = tx.FetchBlock(&hash)
+		if !checkDbError(t, "FetchBlock", err, database.ErrBlockPruned) {
+			return fmt.Errorf("FetchBlock: block without data " +
+				"fetched")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestRebuildBlockIndex ensures rebuilding the block index restores the
+// records of the blocks in the flat files in the order they were stored while
+// retaining the records of blocks stored without their data.
+func TestRebuildBlockIndex(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-rebuildindextest")
+	_ = os.RemoveAll(dbPath)
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	defer db.Close()
+
+	// Create blocks based on the genesis block which are distinguished by
+	// their height, and a header which is stored without its block.
+	blocks := make([]*provautil.Block, 20)
+	for i := range blocks {
+		msgBlock := *chaincfg.MainNetParams.GenesisBlock
+		msgBlock.Header.Height = uint32(i)
+		blocks[i] = provautil.NewBlock(&msgBlock)
+	}
+	header := chaincfg.MainNetParams.GenesisBlock.Header
+	header.Height = uint32(len(blocks))
+	headerHash := header.BlockHash()
+
+	// Store the blocks in small block files so they span several files.
+	ffldb.TstRunWithMaxBlockFileSize(db, 1024, func() {
+		for _, block := range blocks {
+			err = db.Update(func(tx database.Tx) error {
+				return tx.StoreBlock(block)
+			})
+			if err != nil {
+				return
+			}
+		}
+	})
+	if err != nil {
+		t.Errorf("StoreBlock: unexpected error: %v", err)
+		return
+	}
+	err = db.Update(func(tx database.Tx) error {
+		return tx.StoreBlockHeader(&header)
+	})
+	if err != nil {
+		t.Errorf("StoreBlockHeader: unexpected error: %v", err)
+		return
+	}
+
+	// Ensure rebuilding requires a writable transaction.
+	err = db.View(func(tx database.Tx) error {
+		_, err := tx.RebuildBlockIndex()
+		return err
+	})
+	if !checkDbError(t, "RebuildBlockIndex on read-only tx", err,
+		database.ErrTxNotWritable) {
+		return
+	}
+
+	// Remove the records of some of the blocks from the block index to
+	// simulate their loss.
+	lostBlocks := []*provautil.Block{blocks[0], blocks[7], blocks[19]}
+	err = db.Update(func(tx database.Tx) error {
+		blockIdx := tx.Metadata().Bucket([]byte("ffldb-blockidx"))
+		for _, block := range lostBlocks {
+			if err := blockIdx.Delete(block.Hash()[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("Delete: unexpected error: %v", err)
+		return
+	}
+
+	var hashes []chainhash.Hash
+	err = db.Update(func(tx database.Tx) error {
+		var err error
+		hashes, err = tx.RebuildBlockIndex()
+		return err
+	})
+	if err != nil {
+		t.Errorf("RebuildBlockIndex: unexpected error: %v", err)
+		return
+	}
+	if len(hashes) != len(blocks) {
+		t.Errorf("RebuildBlockIndex: got %d hashes, want %d",
+			len(hashes), len(blocks))
+		return
+	}
+	for i, block := range blocks {
+		if hashes[i] != *block.Hash() {
+			t.Errorf("RebuildBlockIndex: hash #%d is %v, want %v",
+				i, hashes[i], block.Hash())
+			return
+		}
+	}
+
+	// Ensure the lost blocks are available again and the header stored
+	// without its block remains.
+	err = db.View(func(tx database.Tx) error {
+		for _, block := range lostBlocks {
+			blockBytes, err := tx.FetchBlock(block.Hash())
+			if err != nil {
+				return fmt.Errorf("FetchBlock: unexpected "+
+					"error: %v", err)
+			}
+			wantBytes, err := block.Bytes()
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(blockBytes, wantBytes) {
+				return fmt.Errorf("FetchBlock: block %v "+
+					"mismatch", block.Hash())
+			}
+		}
+		if exists, _ := tx.HasBlock(&headerHash); !exists {
+			return fmt.Errorf("HasBlock: block without data does " +
+				"not exist")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestBackup ensures a backup of a database of each type houses the metadata
+// and the blocks which were not pruned as of when it was written, and that
+// both the database and the backup remain usable.
+func TestBackup(t *testing.T) {
+	t.Parallel()
+
+	for _, backupDbType := range []string{dbType, "ldb"} {
+		testBackup(t, backupDbType)
+	}
+}
+
+// testBackup performs the backup tests for the passed database type.
+func testBackup(t *testing.T, dbType string) {
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-backuptest-"+dbType)
+	backupPath := dbPath + "-backup"
+	_ = os.RemoveAll(dbPath)
+	_ = os.RemoveAll(backupPath)
+	db, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer os.RemoveAll(dbPath)
+	defer os.RemoveAll(backupPath)
+	defer db.Close()
+
+	// Create blocks based on the genesis block which are distinguished by
+	// their height.
+	blocks := make([]*provautil.Block, 31)
+	for i := range blocks {
+		msgBlock := *chaincfg.MainNetParams.GenesisBlock
+		msgBlock.Header.Height = uint32(i)
+		blocks[i] = provautil.NewBlock(&msgBlock)
+	}
+	newBlock := blocks[30]
+	blocks = blocks[:30]
+
+	// Store the blocks in small block files so they span several files,
+	// prune the first ones, and store a value in the metadata.
+	ffldb.TstRunWithMaxBlockFileSize(db, 1024, func() {
+		for _, block := range blocks {
+			err = db.Update(func(tx database.Tx) error {
+				return tx.StoreBlock(block)
+			})
+			if err != nil {
+				return
+			}
+		}
+	})
+	if err != nil {
+		t.Errorf("StoreBlock (%s): unexpected error: %v", dbType, err)
+		return
+	}
+	bucketKey, key, value := []byte("bucket"), []byte("key"), []byte("value")
+	err = db.Update(func(tx database.Tx) error {
+		if err := tx.PruneBlocks(blocks[10].Hash()); err != nil {
+			return err
+		}
+		bucket, err := tx.Metadata().CreateBucket(bucketKey)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, value)
+	})
+	if err != nil {
+		t.Errorf("Update (%s): unexpected error: %v", dbType, err)
+		return
+	}
+
+	// Ensure a backup is not written to an existing path.
+	err = db.Backup(dbPath)
+	if !checkDbError(t, "Backup to existing path", err,
+		database.ErrDbExists) {
+		return
+	}
+
+	// Back the database up and ensure it remains usable.
+	if err := db.Backup(backupPath); err != nil {
+		t.Errorf("Backup (%s): unexpected error: %v", dbType, err)
+		return
+	}
+	err = db.Update(func(tx database.Tx) error {
+		return tx.StoreBlock(newBlock)
+	})
+	if err != nil {
+		t.Errorf("StoreBlock (%s): unexpected error: %v", dbType, err)
+		return
+	}
+
+	// Open the backup and ensure it houses the state of the database as
+	// of the backup.
+	backupDb, err := database.Open(dbType, backupPath, blockDataNet)
+	if err != nil {
+		t.Errorf("Failed to open backup database (%s) %v", dbType, err)
+		return
+	}
+	defer backupDb.Close()
+	err = backupDb.View(func(tx database.Tx) error {
+		bucket := tx.Metadata().Bucket(bucketKey)
+		if bucket == nil || !bytes.Equal(bucket.Get(key), value) {
+			return fmt.Errorf("Get: value was not backed up")
+		}
+		_, err := tx.FetchBlock(blocks[0].Hash())
+		if !checkDbError(t, "FetchBlock", err, database.ErrBlockPruned) {
+			return fmt.Errorf("FetchBlock: pruned block fetched")
+		}
+		for _, block := range blocks[10:] {
+			gotBytes, err := tx.FetchBlock(block.Hash())
+			if err != nil {
+				return fmt.Errorf("FetchBlock: unexpected "+
+					"error: %v", err)
+			}
+			wantBytes, _ := block.Bytes()
+			if !bytes.Equal(gotBytes, wantBytes) {
+				return fmt.Errorf("FetchBlock: block %v "+
+					"mismatch", block.Hash())
+			}
+		}
+		if exists, _ := tx.HasBlock(newBlock.Hash()); exists {
+			return fmt.Errorf("HasBlock: block stored after the " +
+				"backup exists")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("View (%s): %v", dbType, err)
+		return
+	}
+	err = backupDb.Update(func(tx database.Tx) error {
+		return tx.StoreBlock(newBlock)
+	})
+	if err != nil {
+		t.Errorf("StoreBlock backup (%s): unexpected error: %v", dbType,
+			err)
+	}
+}
@@ -0,0 +1,365 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the implementation of the encryption of the data of a
+// database at rest.
+
+package ffldb
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bitgo/prova/database"
+	"github.com/btcsuite/goleveldb/leveldb"
+	"github.com/btcsuite/goleveldb/leveldb/opt"
+	"github.com/btcsuite/goleveldb/leveldb/storage"
+)
+
+const (
+	// EncryptionKeySize is the size of the keys the data of encrypted
+	// databases is encrypted with.
+	EncryptionKeySize = 32
+
+	// keyCheckFileName is the name of the file which houses the key check
+	// value of an encrypted database.  Its existence marks the database as
+	// encrypted.
+	keyCheckFileName = "keycheck"
+
+	// encryptionIVSize is the size of the initialization vectors which
+	// prefix the encrypted block data and the encrypted metadata files.
+	encryptionIVSize = aes.BlockSize
+)
+
+// encryptor encrypts the data of a database at rest with AES-256 in counter
+// mode using subkeys derived from the encryption key of the database.
+//
+// The raw bytes of each block are encrypted with a random initialization vector
+// which prefixes them, so the blocks are encrypted the same way regardless of
+// the storage they are housed by and regions of them are able to be decrypted
+// without the rest.  The metadata is encrypted at the level of the files of the
+// leveldb database, which includes the keys.
+type encryptor struct {
+	blocks   cipher.Block
+	metadata cipher.Block
+	keyCheck []byte
+}
+
+// deriveKey returns the subkey of the passed encryption key for the passed
+// purpose.
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// newEncryptor returns an encryptor for the passed encryption key, which must
+// be EncryptionKeySize bytes.
+func newEncryptor(key []byte) (*encryptor, error) {
+	if len(key) != EncryptionKeySize {
+		str := fmt.Sprintf("encryption key is %d bytes instead of %d",
+			len(key), EncryptionKeySize)
+		return nil, makeDbErr(database.ErrInvalid, str, nil)
+	}
+
+	// The key sizes are valid, so creating the ciphers can't fail.
+	blocks, _ := aes.NewCipher(deriveKey(key, "blocks"))
+	metadata, _ := aes.NewCipher(deriveKey(key, "metadata"))
+	return &encryptor{
+		blocks:   blocks,
+		metadata: metadata,
+		keyCheck: deriveKey(key, "keycheck"),
+	}, nil
+}
+
+// xorKeyStream XORs the passed source bytes with the key stream of the passed
+// cipher in counter mode for the passed initialization vector, starting at the
+// passed offset in the stream, and writes the result to the destination.
+func xorKeyStream(block cipher.Block, iv []byte, offset uint64, dst, src []byte) {
+	// Advance the counter, which is the big-endian initialization vector,
+	// to the block of the stream the offset is in.
+	var counter [encryptionIVSize]byte
+	copy(counter[:], iv)
+	low := binary.BigEndian.Uint64(counter[8:])
+	high := binary.BigEndian.Uint64(counter[:8])
+	blockNum := offset / encryptionIVSize
+	if low+blockNum < low {
+		high++
+	}
+	binary.BigEndian.PutUint64(counter[8:], low+blockNum)
+	binary.BigEndian.PutUint64(counter[:8], high)
+
+	// Discard the part of the first block before the offset.
+	stream := cipher.NewCTR(block, counter[:])
+	if skip := offset % encryptionIVSize; skip != 0 {
+		var discard [encryptionIVSize]byte
+		stream.XORKeyStream(discard[:skip], discard[:skip])
+	}
+	stream.XORKeyStream(dst, src)
+}
+
+// newIV returns a random initialization vector.
+func newIV() ([]byte, error) {
+	iv := make([]byte, encryptionIVSize)
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		str := fmt.Sprintf("failed to generate initialization vector: "+
+			"%v", err)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	return iv, nil
+}
+
+// encryptBlock returns the encryption of the passed raw block, which is
+// prefixed by its initialization vector.
+func (e *encryptor) encryptBlock(rawBlock []byte) ([]byte, error) {
+	iv, err := newIV()
+	if err != nil {
+		return nil, err
+	}
+	encrypted := make([]byte, encryptionIVSize+len(rawBlock))
+	copy(encrypted, iv)
+	xorKeyStream(e.blocks, iv, 0, encrypted[encryptionIVSize:], rawBlock)
+	return encrypted, nil
+}
+
+// decryptBlock returns the raw block of the passed encrypted block.
+func (e *encryptor) decryptBlock(encrypted []byte) ([]byte, error) {
+	if len(encrypted) < encryptionIVSize {
+		str := fmt.Sprintf("encrypted block data of %d bytes is "+
+			"missing its initialization vector", len(encrypted))
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	rawBlock := make([]byte, len(encrypted)-encryptionIVSize)
+	xorKeyStream(e.blocks, encrypted[:encryptionIVSize], 0, rawBlock,
+		encrypted[encryptionIVSize:])
+	return rawBlock, nil
+}
+
+// decryptBlockRegion decrypts the passed region of an encrypted block in place
+// given the initialization vector of the block and the offset of the region
+// in the raw block.
+func (e *encryptor) decryptBlockRegion(iv, region []byte, offset uint32) {
+	xorKeyStream(e.blocks, iv, uint64(offset), region, region)
+}
+
+// checkKey ensures the database at the passed path is encrypted when an
+// encryptor is passed and is encrypted with its key, or is not encrypted when
+// none is passed.  When a database is created with an encryptor, the key check
+// value which identifies its key is written instead.
+func checkKey(dbPath string, e *encryptor, create bool) error {
+	keyCheckPath := filepath.Join(dbPath, keyCheckFileName)
+	if create && e != nil {
+		err := ioutil.WriteFile(keyCheckPath, e.keyCheck, 0600)
+		if err != nil {
+			str := fmt.Sprintf("failed to write key check value: %v",
+				err)
+			return makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+		return nil
+	}
+
+	keyCheck, err := ioutil.ReadFile(keyCheckPath)
+	switch {
+	case os.IsNotExist(err) && e != nil:
+		str := "database is not encrypted -- it must be opened " +
+			"without an encryption key"
+		return makeDbErr(database.ErrInvalid, str, nil)
+
+	case os.IsNotExist(err):
+		return nil
+
+	case err != nil:
+		str := fmt.Sprintf("failed to read key check value: %v", err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+
+	case e == nil:
+		str := "database is encrypted -- it must be opened with its " +
+			"encryption key"
+		return makeDbErr(database.ErrInvalid, str, nil)
+
+	case !hmac.Equal(keyCheck, e.keyCheck):
+		str := "encryption key does not match the key the database " +
+			"is encrypted with"
+		return makeDbErr(database.ErrInvalid, str, nil)
+	}
+	return nil
+}
+
+// openLdb opens the leveldb database at the passed path with the passed options.
+// When an encryptor is passed, the files of the database are encrypted with its
+// metadata key.  The returned function releases the storage of the database and
+// must be called once the database is closed.
+func openLdb(path string, opts *opt.Options, e *encryptor) (*leveldb.DB, func(), error) {
+	if e == nil {
+		ldb, err := leveldb.OpenFile(path, opts)
+		return ldb, func() {}, err
+	}
+
+	stor, err := storage.OpenFile(path, opts.GetReadOnly())
+	if err != nil {
+		return nil, nil, err
+	}
+	ldb, err := leveldb.Open(&encryptedStorage{stor, e.metadata}, opts)
+	if err != nil {
+		_ = stor.Close()
+		return nil, nil, err
+	}
+	return ldb, func() { _ = stor.Close() }, nil
+}
+
+// encryptedStorage is a leveldb storage which encrypts the files of the
+// underlying storage.  Each file is prefixed by a random initialization vector,
+// so the files are able to be read at random offsets.  Leveldb never modifies
+// the files after they were written, so the key stream of each file is used
+// only once.
+type encryptedStorage struct {
+	storage.Storage
+	block cipher.Block
+}
+
+// Open opens the file with the passed descriptor for reading and decrypting.
+//
+// This is part of the leveldb storage.Storage interface implementation.
+func (s *encryptedStorage) Open(fd storage.FileDesc) (storage.Reader, error) {
+	r, err := s.Storage.Open(fd)
+	if err != nil {
+		return nil, err
+	}
+	iv := make([]byte, encryptionIVSize)
+	if _, err := r.ReadAt(iv, 0); err != nil {
+		_ = r.Close()
+		return nil, &storage.ErrCorrupted{
+			Fd:  fd,
+			Err: fmt.Errorf("missing initialization vector: %v", err),
+		}
+	}
+	return &encryptedReader{r: r, block: s.block, iv: iv}, nil
+}
+
+// Create creates the file with the passed descriptor for encrypting and
+// writing, starting with a new initialization vector.
+//
+// This is part of the leveldb storage.Storage interface implementation.
+func (s *encryptedStorage) Create(fd storage.FileDesc) (storage.Writer, error) {
+	iv, err := newIV()
+	if err != nil {
+		return nil, err
+	}
+	w, err := s.Storage.Create(fd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(iv); err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	stream := cipher.NewCTR(s.block, iv)
+	return &encryptedWriter{w: w, stream: stream}, nil
+}
+
+// encryptedReader decrypts the data of a file of an encrypted storage.
+type encryptedReader struct {
+	r     storage.Reader
+	block cipher.Block
+	iv    []byte
+
+	// mtx protects the position of sequential reads.
+	mtx sync.Mutex
+	pos int64
+}
+
+// ReadAt reads and decrypts the data at the passed offset.
+//
+// This is part of the io.ReaderAt interface implementation.
+func (r *encryptedReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.r.ReadAt(p, off+encryptionIVSize)
+	xorKeyStream(r.block, r.iv, uint64(off), p[:n], p[:n])
+	return n, err
+}
+
+// Read reads and decrypts the data at the current position.
+//
+// This is part of the io.Reader interface implementation.
+func (r *encryptedReader) Read(p []byte) (int, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	n, err := r.ReadAt(p, r.pos)
+	r.pos += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek sets the position of the next read.
+//
+// This is part of the io.Seeker interface implementation.
+func (r *encryptedReader) Seek(offset int64, whence int) (int64, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		size, err := r.r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, err
+		}
+		offset += size - encryptionIVSize
+	default:
+		return 0, fmt.Errorf("invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position %d", offset)
+	}
+	r.pos = offset
+	return offset, nil
+}
+
+// Close closes the underlying file.
+//
+// This is part of the io.Closer interface implementation.
+func (r *encryptedReader) Close() error {
+	return r.r.Close()
+}
+
+// encryptedWriter encrypts the data written to a file of an encrypted storage.
+type encryptedWriter struct {
+	w      storage.Writer
+	stream cipher.Stream
+}
+
+// Write encrypts and writes the passed data.
+//
+// This is part of the io.Writer interface implementation.
+func (w *encryptedWriter) Write(p []byte) (int, error) {
+	encrypted := make([]byte, len(p))
+	w.stream.XORKeyStream(encrypted, p)
+	return w.w.Write(encrypted)
+}
+
+// Sync syncs the underlying file.
+//
+// This is part of the storage.Syncer interface implementation.
+func (w *encryptedWriter) Sync() error {
+	return w.w.Sync()
+}
+
+// Close closes the underlying file.
+//
+// This is part of the io.Closer interface implementation.
+func (w *encryptedWriter) Close() error {
+	return w.w.Close()
+}
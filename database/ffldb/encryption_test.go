@@ -0,0 +1,198 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is part of the ffldb package rather than the ffldb_test package as
+// it provides whitebox testing.
+
+package ffldb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+// TestEncryption ensures the blocks and metadata of encrypted databases of each
+// type are not stored in plaintext, that they are able to be fetched after
+// reopening and from a backup with the key they are encrypted with, and that
+// opening them without it fails.
+func TestEncryption(t *testing.T) {
+	t.Parallel()
+
+	for _, encDbType := range []string{dbType, ldbDbType} {
+		testEncryption(t, encDbType)
+	}
+}
+
+// containsPlaintext returns whether any file within the passed path contains
+// the passed plaintext.
+func containsPlaintext(t *testing.T, path string, plaintext []byte) bool {
+	var found bool
+	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Contains(contents, plaintext) {
+			found = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Walk: unexpected error: %v", err)
+	}
+	return found
+}
+
+// testEncryption performs the encryption tests for the passed database type.
+func testEncryption(t *testing.T, dbType string) {
+	key := bytes.Repeat([]byte{0x01}, EncryptionKeySize)
+	wrongKey := bytes.Repeat([]byte{0x02}, EncryptionKeySize)
+
+	// Ensure keys of the wrong size are rejected.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-encryptiontest-"+dbType)
+	backupPath := dbPath + "-backup"
+	_ = os.RemoveAll(dbPath)
+	_ = os.RemoveAll(backupPath)
+	_, err := database.Create(dbType, dbPath, blockDataNet, key[:16])
+	if !checkDbError(t, "Create with short key", err, database.ErrInvalid) {
+		return
+	}
+
+	// Create a new encrypted database to run tests against.
+	idb, err := database.Create(dbType, dbPath, blockDataNet, key)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer os.RemoveAll(backupPath)
+	defer func() {
+		idb.Close()
+	}()
+
+	// Store a block and a value in the metadata which are easily
+	// recognized.
+	msgBlock := *chaincfg.MainNetParams.GenesisBlock
+	msgBlock.Header.Height = 1
+	block := provautil.NewBlock(&msgBlock)
+	blockBytes, _ := block.Bytes()
+	bucketKey := []byte("encryptionbucket")
+	key1, value := []byte("encryptionkey"), []byte("encryptionvalue")
+	err = idb.Update(func(tx database.Tx) error {
+		if err := tx.StoreBlock(block); err != nil {
+			return err
+		}
+		bucket, err := tx.Metadata().CreateBucket(bucketKey)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key1, value)
+	})
+	if err != nil {
+		t.Fatalf("Update (%s): unexpected error: %v", dbType, err)
+	}
+
+	// checkData ensures the block, a region of it, and the value are able
+	// to be fetched from the passed database.
+	checkData := func(name string, idb database.DB) {
+		err := idb.View(func(tx database.Tx) error {
+			gotBytes, err := tx.FetchBlock(block.Hash())
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(gotBytes, blockBytes) {
+				t.Fatalf("%s (%s): FetchBlock: block mismatch",
+					name, dbType)
+			}
+			gotRegion, err := tx.FetchBlockRegion(&database.BlockRegion{
+				Hash:   block.Hash(),
+				Offset: 37,
+				Len:    50,
+			})
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(gotRegion, blockBytes[37:87]) {
+				t.Fatalf("%s (%s): FetchBlockRegion: region "+
+					"mismatch", name, dbType)
+			}
+			bucket := tx.Metadata().Bucket(bucketKey)
+			if bucket == nil || !bytes.Equal(bucket.Get(key1), value) {
+				t.Fatalf("%s (%s): Get: value mismatch", name,
+					dbType)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s (%s): unexpected error: %v", name, dbType,
+				err)
+		}
+	}
+	checkData("stored", idb)
+
+	// Back the database up, close it, and ensure none of the files of
+	// either contain the block or the metadata in plaintext.
+	if err := idb.Backup(backupPath); err != nil {
+		t.Fatalf("Backup (%s): unexpected error: %v", dbType, err)
+	}
+	idb.Close()
+	for _, path := range []string{dbPath, backupPath} {
+		for _, plaintext := range [][]byte{blockBytes[:80], bucketKey, value} {
+			if containsPlaintext(t, path, plaintext) {
+				t.Fatalf("%s (%s): %q is stored in plaintext",
+					path, dbType, plaintext)
+			}
+		}
+	}
+
+	// Ensure the database is not able to be opened without its key or
+	// with a different one.
+	_, err = database.Open(dbType, dbPath, blockDataNet)
+	if !checkDbError(t, "Open without key", err, database.ErrInvalid) {
+		return
+	}
+	_, err = database.Open(dbType, dbPath, blockDataNet, wrongKey)
+	if !checkDbError(t, "Open with wrong key", err, database.ErrInvalid) {
+		return
+	}
+
+	// Reopen the database and the backup with the key and ensure the data
+	// is able to be fetched from both.
+	idb, err = database.Open(dbType, dbPath, blockDataNet, key)
+	if err != nil {
+		t.Fatalf("Failed to open test database (%s) %v", dbType, err)
+	}
+	checkData("reopened", idb)
+	backupDb, err := database.Open(dbType, backupPath, blockDataNet, key)
+	if err != nil {
+		t.Fatalf("Failed to open backup database (%s) %v", dbType, err)
+	}
+	defer backupDb.Close()
+	checkData("backup", backupDb)
+
+	// Ensure a database which is not encrypted is not able to be opened
+	// with a key.
+	plainPath := dbPath + "-plain"
+	_ = os.RemoveAll(plainPath)
+	plainDb, err := database.Create(dbType, plainPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(plainPath)
+	plainDb.Close()
+	_, err = database.Open(dbType, plainPath, blockDataNet, key)
+	if !checkDbError(t, "Open unencrypted with key", err,
+		database.ErrInvalid) {
+		return
+	}
+}
@@ -18,10 +18,16 @@ import "github.com/bitgo/prova/database"
 // file size for the database set to the provided value.  The value will be set
 // back to the original value upon completion.
 func TstRunWithMaxBlockFileSize(idb database.DB, size uint32, fn func()) {
-	ffldb := idb.(*db)
-	origSize := ffldb.store.maxBlockFileSize
+	var maxSize *uint32
+	switch store := idb.(*db).store.(type) {
+	case *blockStore:
+		maxSize = &store.maxBlockFileSize
+	case *ldbBlockStore:
+		maxSize = &store.maxBlockFileSize
+	}
+	origSize := *maxSize
 
-	ffldb.store.maxBlockFileSize = size
+	*maxSize = size
 	fn()
-	ffldb.store.maxBlockFileSize = origSize
+	*maxSize = origSize
 }
@@ -1857,6 +1857,13 @@ func testClosedTxInterface(tc *testContext, tx database.Tx) bool {
 			return false
 		}
 
+		// Ensure StoreBlockHeader returns expected error.
+		testName = "StoreBlockHeader on closed tx"
+		err = tx.StoreBlockHeader(&block.MsgBlock().Header)
+		if !checkDbError(tc.t, testName, err, wantErrCode) {
+			return false
+		}
+
 		// Ensure FetchBlock returns expected error.
 		testName = fmt.Sprintf("FetchBlock #%d on closed tx", i)
 		_, err = tx.FetchBlock(blockHash)
@@ -1924,6 +1931,20 @@ func testClosedTxInterface(tc *testContext, tx database.Tx) bool {
 		return false
 	}
 
+	// Ensure PruneBlocks returns expected error.
+	testName = "PruneBlocks on closed tx"
+	err = tx.PruneBlocks(&allBlockHashes[0])
+	if !checkDbError(tc.t, testName, err, wantErrCode) {
+		return false
+	}
+
+	// Ensure RebuildBlockIndex returns expected error.
+	testName = "RebuildBlockIndex on closed tx"
+	_, err = tx.RebuildBlockIndex()
+	if !checkDbError(tc.t, testName, err, wantErrCode) {
+		return false
+	}
+
 	// ---------------
 	// Commit/Rollback
 	// ---------------
@@ -0,0 +1,508 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file contains the implementation functions for reading, writing, and
+// otherwise working with the blocks housed in leveldb instead of flat files.
+
+package ffldb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"path/filepath"
+	"sync"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/wire"
+	"github.com/btcsuite/goleveldb/leveldb"
+	"github.com/btcsuite/goleveldb/leveldb/opt"
+	"github.com/btcsuite/goleveldb/leveldb/util"
+)
+
+const (
+	// blocksDbName is the name of the leveldb database which houses the
+	// blocks of the databases created by the ldb driver.
+	blocksDbName = "blocks"
+
+	// blockKeySize is the number of bytes of the keys of the block
+	// records.
+	//
+	// The serialized key format is:
+	//
+	//  [0:4]  Block file (4 bytes)
+	//  [4:8]  File offset (4 bytes)
+	blockKeySize = 8
+)
+
+var (
+	// syncKey is the key which is written synchronously to sync the
+	// blocks written before it.  It sorts before the keys of the block
+	// records, so it is not mistaken for one.
+	syncKey = []byte{0x00}
+)
+
+// blockKey returns the key of the block record at the passed block file number
+// and offset.  The keys sort in the order the records were written.
+func blockKey(fileNum, offset uint32) []byte {
+	var key [blockKeySize]byte
+	binary.BigEndian.PutUint32(key[0:4], fileNum)
+	binary.BigEndian.PutUint32(key[4:8], offset)
+	return key[:]
+}
+
+// ldbBlockStore houses the blocks of a database in a leveldb database instead
+// of flat files, so the compaction and replication of the storage engine apply
+// to the block data too.
+//
+// Each block record is housed under a key which consists of a block file number
+// and offset.  The files are only emulated -- the offset of a record is the sum
+// of the lengths of the records written to the same file before it, and the
+// next file is started when the record would exceed the max file size.  That
+// way the records have the same locations they would have in flat files, and
+// pruning and rolling back the records are range deletions.
+//
+// The records have the same format as in the flat files:
+// <network><block length><serialized block><checksum>
+type ldbBlockStore struct {
+	// network is the specific network to use in the records for each
+	// block.
+	network wire.BitcoinNet
+
+	// ldb is the leveldb database which houses the block records.
+	ldb *leveldb.DB
+
+	// maxBlockFileSize is the maximum size for each emulated file used to
+	// store blocks.  It is defined on the store so the tests can override
+	// the value.
+	maxBlockFileSize uint32
+
+	// mtx protects the write position fields.
+	//
+	// curFileNum is the current emulated block file the next new block
+	// will be written to.
+	//
+	// curOffset is the offset in the current emulated block file where the
+	// next new block will be written.
+	mtx        sync.RWMutex
+	curFileNum uint32
+	curOffset  uint32
+}
+
+// Enforce ldbBlockStore implements the blockStorage interface.
+var _ blockStorage = (*ldbBlockStore)(nil)
+
+// checkRecord ensures the integrity of the passed block record by checking
+// that its serialized network matches the network associated with the store
+// and comparing its calculated checksum against the serialized one.  The
+// description identifies the record in the returned error.
+func (s *ldbBlockStore) checkRecord(record []byte, desc string) error {
+	if len(record) < 12 {
+		str := fmt.Sprintf("block data for %s has invalid length %d",
+			desc, len(record))
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	// Calculate the checksum of the record and ensure it matches the
+	// serialized checksum.
+	n := len(record)
+	serializedChecksum := binary.BigEndian.Uint32(record[n-4:])
+	calculatedChecksum := crc32.Checksum(record[:n-4], castagnoli)
+	if serializedChecksum != calculatedChecksum {
+		str := fmt.Sprintf("block data for %s checksum does not match "+
+			"- got %x, want %x", desc, calculatedChecksum,
+			serializedChecksum)
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	// The network associated with the block must match the current active
+	// network.
+	serializedNet := byteOrder.Uint32(record[:4])
+	if serializedNet != uint32(s.network) {
+		str := fmt.Sprintf("block data for %s is for the wrong network "+
+			"- got %d, want %d", desc, serializedNet,
+			uint32(s.network))
+		return makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+
+	return nil
+}
+
+// fetchRecord returns the block record housed at the passed location.
+//
+// Returns ErrDriverSpecific if the record fails to be read and ErrCorruption if
+// it does not exist or its length doesn't match the location.
+func (s *ldbBlockStore) fetchRecord(loc blockLocation, desc string) ([]byte, error) {
+	record, err := s.ldb.Get(blockKey(loc.blockFileNum, loc.fileOffset),
+		nil)
+	if err == leveldb.ErrNotFound {
+		str := fmt.Sprintf("block data for %s does not exist at file "+
+			"%d, offset %d", desc, loc.blockFileNum, loc.fileOffset)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	if err != nil {
+		str := fmt.Sprintf("failed to read %s from file %d, offset %d: "+
+			"%v", desc, loc.blockFileNum, loc.fileOffset, err)
+		return nil, convertErr(str, err)
+	}
+	if uint32(len(record)) != loc.blockLen {
+		str := fmt.Sprintf("block data for %s at file %d, offset %d "+
+			"has length %d, want %d", desc, loc.blockFileNum,
+			loc.fileOffset, len(record), loc.blockLen)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	return record, nil
+}
+
+// writeBlock appends the specified raw block bytes at the store's write
+// position and increments it accordingly.  When the block would exceed the max
+// file size for the current emulated file, the block is written to the next
+// one.
+//
+// This is part of the blockStorage interface implementation.
+func (s *ldbBlockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
+	blockLen := uint32(len(rawBlock))
+	fullLen := blockLen + 12
+
+	// NOTE: The write position is only changed during this function which
+	// can only be called during a write transaction, of which there can be
+	// only one at a time.
+	s.mtx.RLock()
+	fileNum, offset := s.curFileNum, s.curOffset
+	s.mtx.RUnlock()
+	finalOffset := offset + fullLen
+	if finalOffset < offset || finalOffset > s.maxBlockFileSize {
+		fileNum++
+		offset = 0
+	}
+
+	record := make([]byte, fullLen)
+	byteOrder.PutUint32(record[0:4], uint32(s.network))
+	byteOrder.PutUint32(record[4:8], blockLen)
+	copy(record[8:], rawBlock)
+	binary.BigEndian.PutUint32(record[fullLen-4:],
+		crc32.Checksum(record[:fullLen-4], castagnoli))
+	if err := s.ldb.Put(blockKey(fileNum, offset), record, nil); err != nil {
+		str := fmt.Sprintf("failed to write block to file %d, offset "+
+			"%d: %v", fileNum, offset, err)
+		return blockLocation{}, convertErr(str, err)
+	}
+
+	s.mtx.Lock()
+	s.curFileNum, s.curOffset = fileNum, offset+fullLen
+	s.mtx.Unlock()
+
+	loc := blockLocation{
+		blockFileNum: fileNum,
+		fileOffset:   offset,
+		blockLen:     fullLen,
+	}
+	return loc, nil
+}
+
+// readBlock reads the specified block record and returns the serialized block.
+// It ensures the integrity of the block data the same way it is ensured for
+// the flat files.
+//
+// This is part of the blockStorage interface implementation.
+func (s *ldbBlockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte, error) {
+	desc := fmt.Sprintf("block %s", hash)
+	record, err := s.fetchRecord(loc, desc)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkRecord(record, desc); err != nil {
+		return nil, err
+	}
+
+	// The raw block excludes the network, length of the block, and
+	// checksum.
+	return record[8 : len(record)-4], nil
+}
+
+// readBlockRegion reads the specified amount of data at the provided offset for
+// a given block location.  The offset is relative to the start of the
+// serialized block (as opposed to the beginning of the block record).
+//
+// This is part of the blockStorage interface implementation.
+func (s *ldbBlockStore) readBlockRegion(loc blockLocation, offset, numBytes uint32) ([]byte, error) {
+	record, err := s.fetchRecord(loc, "block region")
+	if err != nil {
+		return nil, err
+	}
+
+	// Regions are offsets into the actual block, however the record for a
+	// block includes an initial 4 bytes for network + 4 bytes for block
+	// length.  Thus, add 8 bytes to adjust.
+	readOffset := uint64(offset) + 8
+	endOffset := readOffset + uint64(numBytes)
+	if endOffset > uint64(len(record)) {
+		str := fmt.Sprintf("failed to read region from block file %d, "+
+			"offset %d, len %d: beyond the end of the record",
+			loc.blockFileNum, loc.fileOffset+uint32(readOffset),
+			numBytes)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+	return record[readOffset:endOffset], nil
+}
+
+// scanBlockFile reads the block records of the passed emulated block file in
+// the order they were written and invokes the passed function with the location
+// and the raw bytes of each block.  The records are read up to the passed end
+// offset, or to the end of the file when it is zero.  The scan stops at the
+// first record which fails the integrity checks.
+//
+// This is part of the blockStorage interface implementation.
+func (s *ldbBlockStore) scanBlockFile(fileNum, endOffset uint32, fn func(loc blockLocation, rawBlock []byte) error) error {
+	limit := blockKey(fileNum+1, 0)
+	if endOffset != 0 {
+		limit = blockKey(fileNum, endOffset)
+	}
+	iter := s.ldb.NewIterator(&util.Range{
+		Start: blockKey(fileNum, 0),
+		Limit: limit,
+	}, nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != blockKeySize {
+			continue
+		}
+		offset := binary.BigEndian.Uint32(key[4:8])
+		record := copySlice(iter.Value())
+		desc := fmt.Sprintf("record in file %d at offset %d", fileNum,
+			offset)
+		if err := s.checkRecord(record, desc); err != nil {
+			log.Warnf("Block %v", err)
+			return nil
+		}
+
+		loc := blockLocation{
+			blockFileNum: fileNum,
+			fileOffset:   offset,
+			blockLen:     uint32(len(record)),
+		}
+		if err := fn(loc, record[8:len(record)-4]); err != nil {
+			return err
+		}
+	}
+	if err := iter.Error(); err != nil {
+		str := fmt.Sprintf("failed to scan block file %d: %v", fileNum,
+			err)
+		return convertErr(str, err)
+	}
+	return nil
+}
+
+// backup copies the block records of the emulated block files with numbers in
+// the range [fromFileNum, toFileNum] to a new leveldb database at the passed
+// path, the last one up to the passed offset.  The records are read from a
+// snapshot, so the ones which are written while they are copied are excluded.
+//
+// This is part of the blockStorage interface implementation.
+func (s *ldbBlockStore) backup(dstPath string, fromFileNum, toFileNum, toOffset uint32) error {
+	snapshot, err := s.ldb.GetSnapshot()
+	if err != nil {
+		str := fmt.Sprintf("failed to snapshot block records: %v", err)
+		return convertErr(str, err)
+	}
+	defer snapshot.Release()
+
+	opts := opt.Options{
+		ErrorIfExist: true,
+		Strict:       opt.DefaultStrict,
+		Compression:  opt.NoCompression,
+	}
+	blocksDbPath := filepath.Join(dstPath, blocksDbName)
+	dstDb, err := leveldb.OpenFile(blocksDbPath, &opts)
+	if err != nil {
+		str := fmt.Sprintf("failed to create block database %q: %v",
+			blocksDbPath, err)
+		return convertErr(str, err)
+	}
+	defer dstDb.Close()
+
+	iter := snapshot.NewIterator(&util.Range{
+		Start: blockKey(fromFileNum, 0),
+		Limit: blockKey(toFileNum, toOffset),
+	}, nil)
+	defer iter.Release()
+	err = copyLdbRecords(dstDb, iter)
+	if err != nil {
+		str := fmt.Sprintf("failed to copy block records: %v", err)
+		return convertErr(str, err)
+	}
+	return nil
+}
+
+// writeLocation returns the number of the current emulated write file and the
+// offset in it the next block will be written to.
+//
+// This is part of the blockStorage interface implementation.
+func (s *ldbBlockStore) writeLocation() (uint32, uint32) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.curFileNum, s.curOffset
+}
+
+// deleteRange deletes the block records with keys in the passed range and
+// returns the number of deleted records.
+func (s *ldbBlockStore) deleteRange(r *util.Range) (int, error) {
+	iter := s.ldb.NewIterator(r, nil)
+	defer iter.Release()
+
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		batch.Delete(copySlice(iter.Key()))
+	}
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+	if batch.Len() == 0 {
+		return 0, nil
+	}
+	return batch.Len(), s.ldb.Write(batch, &opt.WriteOptions{Sync: true})
+}
+
+// handleRollback deletes the block records written after the provided file
+// number and offset and moves the write position back to it.  Any errors are
+// simply logged at a warning level for the same reasons as they are for the
+// flat files.
+//
+// This is part of the blockStorage interface implementation.
+func (s *ldbBlockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// Nothing to do if the rollback point is the same as the current write
+	// position.
+	if s.curFileNum == oldBlockFileNum && s.curOffset == oldBlockOffset {
+		return
+	}
+
+	// Regardless of any failures that happen below, reposition the write
+	// position to the old block file and offset.
+	defer func() {
+		s.curFileNum = oldBlockFileNum
+		s.curOffset = oldBlockOffset
+	}()
+
+	log.Debugf("ROLLBACK: Rolling back to file %d, offset %d",
+		oldBlockFileNum, oldBlockOffset)
+
+	_, err := s.deleteRange(&util.Range{
+		Start: blockKey(oldBlockFileNum, oldBlockOffset),
+	})
+	if err != nil {
+		_ = log.Warnf("ROLLBACK: Failed to delete block records: %v",
+			err)
+	}
+}
+
+// pruneFiles deletes the block records of the emulated block files with numbers
+// in the range [fromFileNum, toFileNum).  Any errors are logged at a warning
+// level since records which fail to be deleted are deleted when the store is
+// next opened.
+//
+// This is part of the blockStorage interface implementation.
+func (s *ldbBlockStore) pruneFiles(fromFileNum, toFileNum uint32) {
+	if fromFileNum >= toFileNum {
+		return
+	}
+	numDeleted, err := s.deleteRange(&util.Range{
+		Start: blockKey(fromFileNum, 0),
+		Limit: blockKey(toFileNum, 0),
+	})
+	if err != nil {
+		_ = log.Warnf("Failed to prune block files: %v", err)
+		return
+	}
+	if numDeleted > 0 {
+		log.Debugf("Pruned %d block records from files %d to %d",
+			numDeleted, fromFileNum, toFileNum-1)
+	}
+}
+
+// syncBlocks ensures the block records written to the store are durable by
+// writing the sync key synchronously, which syncs the leveldb journal that
+// also houses the records written before it.
+//
+// This is part of the blockStorage interface implementation.
+func (s *ldbBlockStore) syncBlocks() error {
+	err := s.ldb.Put(syncKey, nil, &opt.WriteOptions{Sync: true})
+	if err != nil {
+		str := fmt.Sprintf("failed to sync block records: %v", err)
+		return convertErr(str, err)
+	}
+	return nil
+}
+
+// close closes the leveldb database which houses the block records.
+//
+// This is part of the blockStorage interface implementation.
+func (s *ldbBlockStore) close() {
+	_ = s.ldb.Close()
+}
+
+// archiveFiles does nothing since the block records are housed by a single
+// leveldb database, which has no cold storage.
+//
+// This is part of the blockStorage interface implementation.
+func (s *ldbBlockStore) archiveFiles(toFileNum uint32) {}
+
+// newLdbBlockStore returns a new block store which houses the blocks of the
+// database at the passed path in leveldb.  The write position is set to the end
+// of the last block record.  It is the block storage of the databases created by
+// the ldb driver, which does not support cold storage.
+func newLdbBlockStore(dbPath, coldPath string, network wire.BitcoinNet, create bool) (blockStorage, error) {
+	if coldPath != "" {
+		str := fmt.Sprintf("cold storage is not supported by the %s "+
+			"database type", ldbDbType)
+		return nil, makeDbErr(database.ErrInvalid, str, nil)
+	}
+
+	opts := opt.Options{
+		ErrorIfExist:   create,
+		ErrorIfMissing: !create,
+		Strict:         opt.DefaultStrict,
+		Compression:    opt.NoCompression,
+	}
+	blocksDbPath := filepath.Join(dbPath, blocksDbName)
+	ldb, err := leveldb.OpenFile(blocksDbPath, &opts)
+	if err != nil {
+		str := fmt.Sprintf("failed to open block database %q: %v",
+			blocksDbPath, err)
+		return nil, convertErr(str, err)
+	}
+
+	store := &ldbBlockStore{
+		network:          network,
+		ldb:              ldb,
+		maxBlockFileSize: maxBlockFileSize,
+	}
+
+	// The last record determines the write position from the viewpoint of
+	// the stored data.
+	iter := ldb.NewIterator(nil, nil)
+	if iter.Last() && len(iter.Key()) == blockKeySize {
+		key := iter.Key()
+		store.curFileNum = binary.BigEndian.Uint32(key[0:4])
+		store.curOffset = binary.BigEndian.Uint32(key[4:8]) +
+			uint32(len(iter.Value()))
+	}
+	err = iter.Error()
+	iter.Release()
+	if err != nil {
+		_ = ldb.Close()
+		str := fmt.Sprintf("failed to find the last block record: %v",
+			err)
+		return nil, convertErr(str, err)
+	}
+
+	log.Tracef("Found latest block record in file %d ending at offset %d",
+		store.curFileNum, store.curOffset)
+	return store, nil
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is part of the ffldb package rather than the ffldb_test package as
+// it provides whitebox testing.
+
+package ffldb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/btcsuite/goleveldb/leveldb"
+)
+
+// TestLdbBlockStore ensures the databases of the ldb driver house the blocks in
+// leveldb while storing, fetching, pruning, and rebuilding the index of the
+// blocks behaves the same as with flat files, and that block records written
+// after the metadata was last updated are rolled back when the database is
+// reopened.
+func TestLdbBlockStore(t *testing.T) {
+	t.Parallel()
+
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-ldbblockstoretest")
+	_ = os.RemoveAll(dbPath)
+	idb, err := database.Create(ldbDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", ldbDbType,
+			err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer func() {
+		idb.Close()
+	}()
+	if gotDbType := idb.Type(); gotDbType != ldbDbType {
+		t.Fatalf("Type: unexpected driver type - got %v, want %v",
+			gotDbType, ldbDbType)
+	}
+
+	// Create blocks based on the genesis block which are distinguished by
+	// their height and store them in small block files so they span
+	// several files.
+	blocks := make([]*provautil.Block, 40)
+	for i := range blocks {
+		msgBlock := *chaincfg.MainNetParams.GenesisBlock
+		msgBlock.Header.Height = uint32(i)
+		blocks[i] = provautil.NewBlock(&msgBlock)
+	}
+	store := idb.(*db).store.(*ldbBlockStore)
+	store.maxBlockFileSize = 1024
+	for _, block := range blocks {
+		err = idb.Update(func(tx database.Tx) error {
+			return tx.StoreBlock(block)
+		})
+		if err != nil {
+			t.Fatalf("StoreBlock: unexpected error: %v", err)
+		}
+	}
+	if fileNum, _ := store.writeLocation(); fileNum == 0 {
+		t.Fatal("writeLocation: blocks do not span several files")
+	}
+	matches, _ := filepath.Glob(filepath.Join(dbPath, "*.fdb"))
+	if len(matches) != 0 {
+		t.Fatalf("StoreBlock: unexpected flat block files %v", matches)
+	}
+
+	// checkBlock ensures the passed block and a region of it are able to
+	// be fetched.
+	checkBlock := func(name string, block *provautil.Block) {
+		wantBytes, _ := block.Bytes()
+		err := idb.View(func(tx database.Tx) error {
+			gotBytes, err := tx.FetchBlock(block.Hash())
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(gotBytes, wantBytes) {
+				t.Fatalf("%s: FetchBlock: block %v mismatch",
+					name, block.Hash())
+			}
+			gotRegion, err := tx.FetchBlockRegion(&database.BlockRegion{
+				Hash:   block.Hash(),
+				Offset: 4,
+				Len:    32,
+			})
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(gotRegion, wantBytes[4:36]) {
+				t.Fatalf("%s: FetchBlockRegion: block %v region "+
+					"mismatch", name, block.Hash())
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+	}
+	checkBlock("stored", blocks[0])
+	checkBlock("stored", blocks[39])
+
+	// Write a block record which is not referenced by the metadata to
+	// simulate an unclean shutdown and ensure it is rolled back when the
+	// database is reopened.
+	wantFileNum, wantOffset := store.writeLocation()
+	orphanLoc, err := store.writeBlock([]byte("orphan block record"))
+	if err != nil {
+		t.Fatalf("writeBlock: unexpected error: %v", err)
+	}
+	idb.Close()
+	idb, err = database.Open(ldbDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to open test database (%s) %v", ldbDbType, err)
+	}
+	store = idb.(*db).store.(*ldbBlockStore)
+	store.maxBlockFileSize = 1024
+	fileNum, offset := store.writeLocation()
+	if fileNum != wantFileNum || offset != wantOffset {
+		t.Fatalf("writeLocation: got file %d, offset %d after reopening, "+
+			"want file %d, offset %d", fileNum, offset, wantFileNum,
+			wantOffset)
+	}
+	orphanKey := blockKey(orphanLoc.blockFileNum, orphanLoc.fileOffset)
+	if _, err := store.ldb.Get(orphanKey, nil); err != leveldb.ErrNotFound {
+		t.Fatalf("Get: orphan block record was not rolled back: %v", err)
+	}
+	checkBlock("reopened", blocks[39])
+
+	// Prune the blocks before the last ones and ensure the records of the
+	// pruned files are removed.
+	err = idb.Update(func(tx database.Tx) error {
+		return tx.PruneBlocks(blocks[30].Hash())
+	})
+	if err != nil {
+		t.Fatalf("PruneBlocks: unexpected error: %v", err)
+	}
+	if _, err := store.ldb.Get(blockKey(0, 0), nil); err != leveldb.ErrNotFound {
+		t.Fatalf("Get: record of pruned block was not removed: %v", err)
+	}
+	err = idb.View(func(tx database.Tx) error {
+		_, err := tx.FetchBlock(blocks[0].Hash())
+		return err
+	})
+	if !checkDbError(t, "FetchBlock", err, database.ErrBlockPruned) {
+		return
+	}
+	checkBlock("pruned", blocks[30])
+
+	// Remove the record of the last block from the block index and ensure
+	// rebuilding the index from the stored records makes it available
+	// again.
+	err = idb.Update(func(tx database.Tx) error {
+		blockIdx := tx.Metadata().Bucket(blockIdxBucketName)
+		return blockIdx.Delete(blocks[39].Hash()[:])
+	})
+	if err != nil {
+		t.Fatalf("Delete: unexpected error: %v", err)
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		_, err := tx.RebuildBlockIndex()
+		return err
+	})
+	if err != nil {
+		t.Fatalf("RebuildBlockIndex: unexpected error: %v", err)
+	}
+	checkBlock("rebuilt", blocks[39])
+
+	// Ensure the database is not able to be opened by the ffldb driver,
+	// which finds no block files for the blocks the metadata references.
+	idb.Close()
+	_, err = database.Open(dbType, dbPath, blockDataNet)
+	if !checkDbError(t, "Open ffldb", err, database.ErrCorruption) {
+		return
+	}
+	idb, err = database.Open(ldbDbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to open test database (%s) %v", ldbDbType, err)
+	}
+}
@@ -0,0 +1,554 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is only built with the following build tag, so binaries only link
+// the PostgreSQL driver when the pgsql database type is wanted.
+// +build pgsql
+
+// This file contains the implementation functions for reading, writing, and
+// otherwise working with the blocks housed in PostgreSQL instead of flat files.
+
+package ffldb
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sync"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/wire"
+
+	// Register the PostgreSQL driver of the database/sql package.
+	_ "github.com/lib/pq"
+)
+
+const (
+	// pgsqlDbType is the type of the databases which house the blocks in a
+	// PostgreSQL database instead of flat files.
+	pgsqlDbType = "pgsql"
+
+	// pgsqlDriverName is the name the PostgreSQL driver is registered with
+	// in the database/sql package.
+	pgsqlDriverName = "postgres"
+
+	// pgsqlCreateTable is the statement which creates the table which
+	// houses the block records.  The records are identified by the
+	// emulated block file number and offset they are written to.
+	pgsqlCreateTable = `CREATE TABLE IF NOT EXISTS blocks (
+		filenum BIGINT NOT NULL,
+		fileoffset BIGINT NOT NULL,
+		record BYTEA NOT NULL,
+		PRIMARY KEY (filenum, fileoffset)
+	)`
+)
+
+// pgsqlBlockStore houses the blocks of a database in a PostgreSQL database
+// instead of flat files, so the blocks are kept on a database server with its
+// own replication, backups and storage management, while the metadata remains
+// in the local leveldb database.
+//
+// Each block record is housed in a row identified by a block file number and
+// offset.  The files are emulated the same way as by the ldb storage, so the
+// records have the same locations they would have in flat files, and pruning
+// and rolling back the records are deletions of ranges of rows.
+//
+// The records have the same format as in the flat files:
+// <network><block length><serialized block><checksum>
+type pgsqlBlockStore struct {
+	// network is the specific network to use in the records for each
+	// block.
+	network wire.BitcoinNet
+
+	// sqlDB is the connection pool of the PostgreSQL database which houses
+	// the block records.
+	sqlDB *sql.DB
+
+	// maxBlockFileSize is the maximum size for each emulated file used to
+	// store blocks.  It is defined on the store so the tests can override
+	// the value.
+	maxBlockFileSize uint32
+
+	// mtx protects the write position fields.
+	//
+	// curFileNum is the current emulated block file the next new block
+	// will be written to.
+	//
+	// curOffset is the offset in the current emulated block file where the
+	// next new block will be written.
+	mtx        sync.RWMutex
+	curFileNum uint32
+	curOffset  uint32
+}
+
+// Enforce pgsqlBlockStore implements the blockStorage interface.
+var _ blockStorage = (*pgsqlBlockStore)(nil)
+
+// pgsqlErr returns a driver-specific database error with the passed
+// description for the passed error of the PostgreSQL database.
+func pgsqlErr(desc string, err error) database.Error {
+	return makeDbErr(database.ErrDriverSpecific, desc, err)
+}
+
+// checkRecord ensures the integrity of the passed block record by checking
+// that its serialized network matches the network associated with the store
+// and comparing its calculated checksum against the serialized one.  The
+// description identifies the record in the returned error.
+func (s *pgsqlBlockStore) checkRecord(record []byte, desc string) error {
+	if len(record) < 12 {
+		str := fmt.Sprintf("block data for %s has invalid length %d",
+			desc, len(record))
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	n := len(record)
+	serializedChecksum := binary.BigEndian.Uint32(record[n-4:])
+	calculatedChecksum := crc32.Checksum(record[:n-4], castagnoli)
+	if serializedChecksum != calculatedChecksum {
+		str := fmt.Sprintf("block data for %s checksum does not match "+
+			"- got %x, want %x", desc, calculatedChecksum,
+			serializedChecksum)
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+
+	serializedNet := byteOrder.Uint32(record[:4])
+	if serializedNet != uint32(s.network) {
+		str := fmt.Sprintf("block data for %s is for the wrong network "+
+			"- got %d, want %d", desc, serializedNet,
+			uint32(s.network))
+		return makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+
+	return nil
+}
+
+// writeBlock appends the specified raw block bytes at the store's write
+// position and increments it accordingly.  When the block would exceed the max
+// file size for the current emulated file, the block is written to the next
+// one.  The row is durable once the insert returns since each statement is
+// committed on its own.
+//
+// This is part of the blockStorage interface implementation.
+func (s *pgsqlBlockStore) writeBlock(rawBlock []byte) (blockLocation, error) {
+	blockLen := uint32(len(rawBlock))
+	fullLen := blockLen + 12
+
+	// NOTE: The write position is only changed during this function which
+	// can only be called during a write transaction, of which there can be
+	// only one at a time.
+	s.mtx.RLock()
+	fileNum, offset := s.curFileNum, s.curOffset
+	s.mtx.RUnlock()
+	finalOffset := offset + fullLen
+	if finalOffset < offset || finalOffset > s.maxBlockFileSize {
+		fileNum++
+		offset = 0
+	}
+
+	record := make([]byte, fullLen)
+	byteOrder.PutUint32(record[0:4], uint32(s.network))
+	byteOrder.PutUint32(record[4:8], blockLen)
+	copy(record[8:], rawBlock)
+	binary.BigEndian.PutUint32(record[fullLen-4:],
+		crc32.Checksum(record[:fullLen-4], castagnoli))
+	_, err := s.sqlDB.Exec(`INSERT INTO blocks (filenum, fileoffset, record)
+		VALUES ($1, $2, $3)`, fileNum, offset, record)
+	if err != nil {
+		str := fmt.Sprintf("failed to write block to file %d, offset "+
+			"%d: %v", fileNum, offset, err)
+		return blockLocation{}, pgsqlErr(str, err)
+	}
+
+	s.mtx.Lock()
+	s.curFileNum, s.curOffset = fileNum, offset+fullLen
+	s.mtx.Unlock()
+
+	loc := blockLocation{
+		blockFileNum: fileNum,
+		fileOffset:   offset,
+		blockLen:     fullLen,
+	}
+	return loc, nil
+}
+
+// readBlock reads the specified block record and returns the serialized block.
+// It ensures the integrity of the block data the same way it is ensured for
+// the flat files.
+//
+// This is part of the blockStorage interface implementation.
+func (s *pgsqlBlockStore) readBlock(hash *chainhash.Hash, loc blockLocation) ([]byte, error) {
+	desc := fmt.Sprintf("block %s", hash)
+	var record []byte
+	err := s.sqlDB.QueryRow(`SELECT record FROM blocks
+		WHERE filenum = $1 AND fileoffset = $2`, loc.blockFileNum,
+		loc.fileOffset).Scan(&record)
+	if err == sql.ErrNoRows {
+		str := fmt.Sprintf("block data for %s does not exist at file "+
+			"%d, offset %d", desc, loc.blockFileNum, loc.fileOffset)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	if err != nil {
+		str := fmt.Sprintf("failed to read %s from file %d, offset %d: "+
+			"%v", desc, loc.blockFileNum, loc.fileOffset, err)
+		return nil, pgsqlErr(str, err)
+	}
+	if uint32(len(record)) != loc.blockLen {
+		str := fmt.Sprintf("block data for %s at file %d, offset %d "+
+			"has length %d, want %d", desc, loc.blockFileNum,
+			loc.fileOffset, len(record), loc.blockLen)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	if err := s.checkRecord(record, desc); err != nil {
+		return nil, err
+	}
+
+	// The raw block excludes the network, length of the block, and
+	// checksum.
+	return record[8 : len(record)-4], nil
+}
+
+// readBlockRegion reads the specified amount of data at the provided offset for
+// a given block location.  The offset is relative to the start of the
+// serialized block (as opposed to the beginning of the block record).  Only the
+// region is transferred from the database server.
+//
+// This is part of the blockStorage interface implementation.
+func (s *pgsqlBlockStore) readBlockRegion(loc blockLocation, offset, numBytes uint32) ([]byte, error) {
+	// Regions are offsets into the actual block, however the record for a
+	// block includes an initial 4 bytes for network + 4 bytes for block
+	// length.  Thus, add 8 bytes to adjust.  The substrings of the database
+	// start at one.
+	readOffset := uint64(offset) + 8
+	var recordLen uint64
+	var region []byte
+	err := s.sqlDB.QueryRow(`SELECT length(record),
+		substr(record, $1, $2) FROM blocks
+		WHERE filenum = $3 AND fileoffset = $4`, readOffset+1, numBytes,
+		loc.blockFileNum, loc.fileOffset).Scan(&recordLen, &region)
+	if err == sql.ErrNoRows {
+		str := fmt.Sprintf("block region does not exist at file %d, "+
+			"offset %d", loc.blockFileNum, loc.fileOffset)
+		return nil, makeDbErr(database.ErrCorruption, str, nil)
+	}
+	if err != nil {
+		str := fmt.Sprintf("failed to read region from block file %d, "+
+			"offset %d, len %d: %v", loc.blockFileNum,
+			loc.fileOffset+uint32(readOffset), numBytes, err)
+		return nil, pgsqlErr(str, err)
+	}
+	if readOffset+uint64(numBytes) > recordLen {
+		str := fmt.Sprintf("failed to read region from block file %d, "+
+			"offset %d, len %d: beyond the end of the record",
+			loc.blockFileNum, loc.fileOffset+uint32(readOffset),
+			numBytes)
+		return nil, makeDbErr(database.ErrDriverSpecific, str, nil)
+	}
+	return region, nil
+}
+
+// scanBlockFile reads the block records of the passed emulated block file in
+// the order they were written and invokes the passed function with the location
+// and the raw bytes of each block.  The records are read up to the passed end
+// offset, or to the end of the file when it is zero.  The scan stops at the
+// first record which fails the integrity checks.
+//
+// This is part of the blockStorage interface implementation.
+func (s *pgsqlBlockStore) scanBlockFile(fileNum, endOffset uint32, fn func(loc blockLocation, rawBlock []byte) error) error {
+	limit := uint64(endOffset)
+	if endOffset == 0 {
+		limit = 1 << 32
+	}
+	rows, err := s.sqlDB.Query(`SELECT fileoffset, record FROM blocks
+		WHERE filenum = $1 AND fileoffset < $2 ORDER BY fileoffset`,
+		fileNum, limit)
+	if err != nil {
+		str := fmt.Sprintf("failed to scan block file %d: %v", fileNum,
+			err)
+		return pgsqlErr(str, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var offset uint32
+		var record []byte
+		if err := rows.Scan(&offset, &record); err != nil {
+			str := fmt.Sprintf("failed to scan block file %d: %v",
+				fileNum, err)
+			return pgsqlErr(str, err)
+		}
+		desc := fmt.Sprintf("record in file %d at offset %d", fileNum,
+			offset)
+		if err := s.checkRecord(record, desc); err != nil {
+			log.Warnf("Block %v", err)
+			return nil
+		}
+
+		loc := blockLocation{
+			blockFileNum: fileNum,
+			fileOffset:   offset,
+			blockLen:     uint32(len(record)),
+		}
+		if err := fn(loc, record[8:len(record)-4]); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		str := fmt.Sprintf("failed to scan block file %d: %v", fileNum,
+			err)
+		return pgsqlErr(str, err)
+	}
+	return nil
+}
+
+// backup copies the block records of the emulated block files with numbers in
+// the range [fromFileNum, toFileNum] to flat files at the passed path, the last
+// one up to the passed offset.  The records have the same format and locations
+// in the flat files, so the backup is a database of the ffldb type which does
+// not depend on the database server.
+//
+// This is part of the blockStorage interface implementation.
+func (s *pgsqlBlockStore) backup(dstPath string, fromFileNum, toFileNum, toOffset uint32) error {
+	rows, err := s.sqlDB.Query(`SELECT filenum, fileoffset, record
+		FROM blocks WHERE filenum >= $1 AND (filenum < $2 OR
+		(filenum = $2 AND fileoffset < $3)) ORDER BY filenum, fileoffset`,
+		fromFileNum, toFileNum, toOffset)
+	if err != nil {
+		str := fmt.Sprintf("failed to read block records: %v", err)
+		return pgsqlErr(str, err)
+	}
+	defer rows.Close()
+
+	// closeFile syncs and closes the flat file which is being written.
+	var file *os.File
+	closeFile := func() error {
+		if file == nil {
+			return nil
+		}
+		err := file.Sync()
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		file = nil
+		return err
+	}
+	defer closeFile()
+
+	curFileNum := int64(-1)
+	for rows.Next() {
+		var fileNum, offset uint32
+		var record []byte
+		if err := rows.Scan(&fileNum, &offset, &record); err != nil {
+			str := fmt.Sprintf("failed to read block records: %v",
+				err)
+			return pgsqlErr(str, err)
+		}
+		if int64(fileNum) != curFileNum {
+			if err := closeFile(); err != nil {
+				str := fmt.Sprintf("failed to write block file "+
+					"%d: %v", curFileNum, err)
+				return makeDbErr(database.ErrDriverSpecific, str,
+					err)
+			}
+			file, err = os.OpenFile(blockFilePath(dstPath, fileNum),
+				os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+			if err != nil {
+				str := fmt.Sprintf("failed to create block "+
+					"file %d: %v", fileNum, err)
+				return makeDbErr(database.ErrDriverSpecific, str,
+					err)
+			}
+			curFileNum = int64(fileNum)
+		}
+		if _, err := file.WriteAt(record, int64(offset)); err != nil {
+			str := fmt.Sprintf("failed to write block file %d: %v",
+				fileNum, err)
+			return makeDbErr(database.ErrDriverSpecific, str, err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		str := fmt.Sprintf("failed to read block records: %v", err)
+		return pgsqlErr(str, err)
+	}
+	if err := closeFile(); err != nil {
+		str := fmt.Sprintf("failed to write block file %d: %v",
+			curFileNum, err)
+		return makeDbErr(database.ErrDriverSpecific, str, err)
+	}
+	return nil
+}
+
+// writeLocation returns the number of the current emulated write file and the
+// offset in it the next block will be written to.
+//
+// This is part of the blockStorage interface implementation.
+func (s *pgsqlBlockStore) writeLocation() (uint32, uint32) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+	return s.curFileNum, s.curOffset
+}
+
+// handleRollback deletes the block records written after the provided file
+// number and offset and moves the write position back to it.  Any errors are
+// simply logged at a warning level for the same reasons as they are for the
+// flat files.
+//
+// This is part of the blockStorage interface implementation.
+func (s *pgsqlBlockStore) handleRollback(oldBlockFileNum, oldBlockOffset uint32) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// Nothing to do if the rollback point is the same as the current write
+	// position.
+	if s.curFileNum == oldBlockFileNum && s.curOffset == oldBlockOffset {
+		return
+	}
+
+	// Regardless of any failures that happen below, reposition the write
+	// position to the old block file and offset.
+	defer func() {
+		s.curFileNum = oldBlockFileNum
+		s.curOffset = oldBlockOffset
+	}()
+
+	log.Debugf("ROLLBACK: Rolling back to file %d, offset %d",
+		oldBlockFileNum, oldBlockOffset)
+
+	_, err := s.sqlDB.Exec(`DELETE FROM blocks WHERE filenum > $1 OR
+		(filenum = $1 AND fileoffset >= $2)`, oldBlockFileNum,
+		oldBlockOffset)
+	if err != nil {
+		_ = log.Warnf("ROLLBACK: Failed to delete block records: %v",
+			err)
+	}
+}
+
+// pruneFiles deletes the block records of the emulated block files with numbers
+// in the range [fromFileNum, toFileNum).  Any errors are logged at a warning
+// level since records which fail to be deleted are deleted when the store is
+// next opened.
+//
+// This is part of the blockStorage interface implementation.
+func (s *pgsqlBlockStore) pruneFiles(fromFileNum, toFileNum uint32) {
+	if fromFileNum >= toFileNum {
+		return
+	}
+	result, err := s.sqlDB.Exec(`DELETE FROM blocks
+		WHERE filenum >= $1 AND filenum < $2`, fromFileNum, toFileNum)
+	if err != nil {
+		_ = log.Warnf("Failed to prune block files: %v", err)
+		return
+	}
+	if numDeleted, err := result.RowsAffected(); err == nil && numDeleted > 0 {
+		log.Debugf("Pruned %d block records from files %d to %d",
+			numDeleted, fromFileNum, toFileNum-1)
+	}
+}
+
+// syncBlocks does nothing since each block record is committed by the database
+// server as it is written.
+//
+// This is part of the blockStorage interface implementation.
+func (s *pgsqlBlockStore) syncBlocks() error {
+	return nil
+}
+
+// close closes the connections to the PostgreSQL database which houses the
+// block records.
+//
+// This is part of the blockStorage interface implementation.
+func (s *pgsqlBlockStore) close() {
+	_ = s.sqlDB.Close()
+}
+
+// archiveFiles does nothing since the storage of the block records is managed
+// by the database server, which has no cold storage.
+//
+// This is part of the blockStorage interface implementation.
+func (s *pgsqlBlockStore) archiveFiles(toFileNum uint32) {}
+
+// newPgsqlBlockStore returns a new block store which houses the blocks of the
+// database at the passed path in the PostgreSQL database identified by the
+// passed connection string.  The write position is set to the end of the last
+// block record.  A new database refuses to use a PostgreSQL database which
+// already houses block records, since they are rolled back as soon as the new
+// metadata is reconciled with them.  It is the block storage of the databases
+// created by the pgsql driver.
+func newPgsqlBlockStore(dbPath, dataSource string, network wire.BitcoinNet, create bool) (blockStorage, error) {
+	if dataSource == "" {
+		str := fmt.Sprintf("the %s database type requires the "+
+			"connection string of the PostgreSQL database which "+
+			"houses the blocks", pgsqlDbType)
+		return nil, makeDbErr(database.ErrInvalid, str, nil)
+	}
+
+	sqlDB, err := sql.Open(pgsqlDriverName, dataSource)
+	if err == nil {
+		err = sqlDB.Ping()
+	}
+	if err != nil {
+		if sqlDB != nil {
+			_ = sqlDB.Close()
+		}
+		str := fmt.Sprintf("failed to connect to the block database: %v",
+			err)
+		return nil, pgsqlErr(str, err)
+	}
+
+	store := &pgsqlBlockStore{
+		network:          network,
+		sqlDB:            sqlDB,
+		maxBlockFileSize: maxBlockFileSize,
+	}
+	if err := store.open(create); err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+
+	log.Tracef("Found latest block record in file %d ending at offset %d",
+		store.curFileNum, store.curOffset)
+	return store, nil
+}
+
+// open creates the table which houses the block records when the create flag is
+// set and sets the write position to the end of the last block record.
+func (s *pgsqlBlockStore) open(create bool) error {
+	if create {
+		if _, err := s.sqlDB.Exec(pgsqlCreateTable); err != nil {
+			str := fmt.Sprintf("failed to create the blocks table: "+
+				"%v", err)
+			return pgsqlErr(str, err)
+		}
+	}
+
+	// The last record determines the write position from the viewpoint of
+	// the stored data.
+	var fileNum, offset, recordLen uint32
+	err := s.sqlDB.QueryRow(`SELECT filenum, fileoffset, length(record)
+		FROM blocks ORDER BY filenum DESC, fileoffset DESC LIMIT 1`).Scan(
+		&fileNum, &offset, &recordLen)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		str := fmt.Sprintf("failed to find the last block record: %v",
+			err)
+		return pgsqlErr(str, err)
+	}
+	if create {
+		str := "the PostgreSQL database already houses block records"
+		return makeDbErr(database.ErrDbExists, str, nil)
+	}
+	s.curFileNum = fileNum
+	s.curOffset = offset + recordLen
+	return nil
+}
+
+func init() {
+	// Register the driver of the databases which house their blocks in
+	// PostgreSQL.
+	registerDriver(pgsqlDbType, newPgsqlBlockStore)
+}
@@ -0,0 +1,244 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is ignored during the regular tests due to the following build tag.
+// +build pgsql
+
+// This file is part of the ffldb package rather than the ffldb_test package as
+// it provides whitebox testing.
+
+package ffldb
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+// pgsqlTestEnv is the environment variable with the connection string of the
+// scratch PostgreSQL database the pgsql tests run against.  Its blocks table is
+// dropped by the tests.
+const pgsqlTestEnv = "PROVA_TEST_PGSQL"
+
+// TestPgsqlBlockStore ensures the databases of the pgsql driver house the
+// blocks in PostgreSQL while storing, fetching, pruning, backing up, and
+// rebuilding the index of the blocks behaves the same as with flat files, and
+// that block records written after the metadata was last updated are rolled
+// back when the database is reopened.  It is skipped unless the connection
+// string of a scratch PostgreSQL database is set in the environment.
+func TestPgsqlBlockStore(t *testing.T) {
+	dataSource := os.Getenv(pgsqlTestEnv)
+	if dataSource == "" {
+		t.Skipf("%s is not set", pgsqlTestEnv)
+	}
+
+	// Ensure the driver requires the connection string.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-pgsqlblockstoretest")
+	backupPath := dbPath + "-backup"
+	_ = os.RemoveAll(dbPath)
+	_ = os.RemoveAll(backupPath)
+	_, err := database.Create(pgsqlDbType, dbPath, blockDataNet)
+	if !checkDbError(t, "Create", err, database.ErrInvalid) {
+		return
+	}
+	_ = os.RemoveAll(dbPath)
+
+	// Create a new database to run tests against.
+	sqlDB, err := sql.Open(pgsqlDriverName, dataSource)
+	if err == nil {
+		_, err = sqlDB.Exec("DROP TABLE IF EXISTS blocks")
+		sqlDB.Close()
+	}
+	if err != nil {
+		t.Fatalf("Failed to drop the blocks table: %v", err)
+	}
+	idb, err := database.Create(pgsqlDbType, dbPath, blockDataNet,
+		[]byte(nil), dataSource)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", pgsqlDbType,
+			err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer os.RemoveAll(backupPath)
+	defer func() {
+		idb.Close()
+	}()
+	if gotDbType := idb.Type(); gotDbType != pgsqlDbType {
+		t.Fatalf("Type: unexpected driver type - got %v, want %v",
+			gotDbType, pgsqlDbType)
+	}
+
+	// Create blocks based on the genesis block which are distinguished by
+	// their height and store them in small block files so they span
+	// several files.
+	blocks := make([]*provautil.Block, 40)
+	for i := range blocks {
+		msgBlock := *chaincfg.MainNetParams.GenesisBlock
+		msgBlock.Header.Height = uint32(i)
+		blocks[i] = provautil.NewBlock(&msgBlock)
+	}
+	pgStore := idb.(*db).store.(*pgsqlBlockStore)
+	pgStore.maxBlockFileSize = 1024
+	for _, block := range blocks {
+		err = idb.Update(func(tx database.Tx) error {
+			return tx.StoreBlock(block)
+		})
+		if err != nil {
+			t.Fatalf("StoreBlock: unexpected error: %v", err)
+		}
+	}
+	if fileNum, _ := pgStore.writeLocation(); fileNum == 0 {
+		t.Fatal("writeLocation: blocks do not span several files")
+	}
+	matches, _ := filepath.Glob(filepath.Join(dbPath, "*.fdb"))
+	if len(matches) != 0 {
+		t.Fatalf("StoreBlock: unexpected flat block files %v", matches)
+	}
+
+	// checkBlock ensures the passed block and a region of it are able to
+	// be fetched from the passed database.
+	checkBlock := func(name string, idb database.DB, block *provautil.Block) {
+		wantBytes, _ := block.Bytes()
+		err := idb.View(func(tx database.Tx) error {
+			gotBytes, err := tx.FetchBlock(block.Hash())
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(gotBytes, wantBytes) {
+				t.Fatalf("%s: FetchBlock: block %v mismatch",
+					name, block.Hash())
+			}
+			gotRegion, err := tx.FetchBlockRegion(&database.BlockRegion{
+				Hash:   block.Hash(),
+				Offset: 4,
+				Len:    32,
+			})
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(gotRegion, wantBytes[4:36]) {
+				t.Fatalf("%s: FetchBlockRegion: block %v region "+
+					"mismatch", name, block.Hash())
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+	}
+	checkBlock("stored", idb, blocks[0])
+	checkBlock("stored", idb, blocks[39])
+
+	// Ensure a region beyond the end of a block is rejected.
+	err = idb.View(func(tx database.Tx) error {
+		_, err := tx.FetchBlockRegion(&database.BlockRegion{
+			Hash:   blocks[0].Hash(),
+			Offset: 4,
+			Len:    1 << 20,
+		})
+		return err
+	})
+	if !checkDbError(t, "FetchBlockRegion", err,
+		database.ErrBlockRegionInvalid) {
+		return
+	}
+
+	// Write a block record which is not referenced by the metadata to
+	// simulate an unclean shutdown and ensure it is rolled back when the
+	// database is reopened.
+	wantFileNum, wantOffset := pgStore.writeLocation()
+	_, err = pgStore.writeBlock([]byte("orphan block record"))
+	if err != nil {
+		t.Fatalf("writeBlock: unexpected error: %v", err)
+	}
+	idb.Close()
+	idb, err = database.Open(pgsqlDbType, dbPath, blockDataNet,
+		[]byte(nil), dataSource)
+	if err != nil {
+		t.Fatalf("Failed to open test database (%s) %v", pgsqlDbType,
+			err)
+	}
+	pgStore = idb.(*db).store.(*pgsqlBlockStore)
+	pgStore.maxBlockFileSize = 1024
+	fileNum, offset := pgStore.writeLocation()
+	if fileNum != wantFileNum || offset != wantOffset {
+		t.Fatalf("writeLocation: got file %d, offset %d after reopening, "+
+			"want file %d, offset %d", fileNum, offset, wantFileNum,
+			wantOffset)
+	}
+	checkBlock("reopened", idb, blocks[39])
+
+	// Prune the blocks before the last ones and ensure the records of the
+	// pruned files are removed.
+	err = idb.Update(func(tx database.Tx) error {
+		return tx.PruneBlocks(blocks[30].Hash())
+	})
+	if err != nil {
+		t.Fatalf("PruneBlocks: unexpected error: %v", err)
+	}
+	var numPruned int
+	err = pgStore.sqlDB.QueryRow(`SELECT count(*) FROM blocks
+		WHERE filenum = 0`).Scan(&numPruned)
+	if err != nil || numPruned != 0 {
+		t.Fatalf("QueryRow: records of pruned blocks were not removed "+
+			"- got %d records: %v", numPruned, err)
+	}
+	err = idb.View(func(tx database.Tx) error {
+		_, err := tx.FetchBlock(blocks[0].Hash())
+		return err
+	})
+	if !checkDbError(t, "FetchBlock", err, database.ErrBlockPruned) {
+		return
+	}
+	checkBlock("pruned", idb, blocks[30])
+
+	// Remove the record of the last block from the block index and ensure
+	// rebuilding the index from the stored records makes it available
+	// again.
+	err = idb.Update(func(tx database.Tx) error {
+		blockIdx := tx.Metadata().Bucket(blockIdxBucketName)
+		return blockIdx.Delete(blocks[39].Hash()[:])
+	})
+	if err != nil {
+		t.Fatalf("Delete: unexpected error: %v", err)
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		_, err := tx.RebuildBlockIndex()
+		return err
+	})
+	if err != nil {
+		t.Fatalf("RebuildBlockIndex: unexpected error: %v", err)
+	}
+	checkBlock("rebuilt", idb, blocks[39])
+
+	// Ensure the backup houses the blocks in flat files, so it is opened by
+	// the ffldb driver without the PostgreSQL database.
+	if err := idb.Backup(backupPath); err != nil {
+		t.Fatalf("Backup: unexpected error: %v", err)
+	}
+	backupDb, err := database.Open(dbType, backupPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to open backup (%s) %v", dbType, err)
+	}
+	checkBlock("backup", backupDb, blocks[30])
+	checkBlock("backup", backupDb, blocks[39])
+	backupDb.Close()
+
+	// Ensure a new database refuses to use the PostgreSQL database which
+	// already houses block records.
+	newPath := dbPath + "-new"
+	_ = os.RemoveAll(newPath)
+	defer os.RemoveAll(newPath)
+	_, err = database.Create(pgsqlDbType, newPath, blockDataNet,
+		[]byte(nil), dataSource)
+	if !checkDbError(t, "Create", err, database.ErrDbExists) {
+		return
+	}
+}
@@ -59,9 +59,13 @@ func reconcileDB(pdb *db, create bool) (database.DB, error) {
 		}
 	}
 
-	// Load the current write cursor position from the metadata.
-	var curFileNum, curOffset uint32
+	// Load the current write cursor position, the first block file which
+	// has not been pruned and the archive watermark from the metadata.
+	var curFileNum, curOffset, prunedFileNum, archivedFileNum uint32
 	err := pdb.View(func(tx database.Tx) error {
+		prunedFileNum = tx.(*transaction).prunedFileNum()
+		archivedFileNum = tx.(*transaction).archivedFileNum()
+
 		writeRow := tx.Metadata().Get(writeLocKeyName)
 		if writeRow == nil {
 			str := "write cursor does not exist"
@@ -83,14 +87,14 @@ func reconcileDB(pdb *db, create bool) (database.DB, error) {
 	// the middle of being written.  Since the metadata isn't updated until
 	// after the block data is written, this is effectively just a rollback
 	// to the known good point before the unclean shutdown.
-	wc := pdb.store.writeCursor
-	if wc.curFileNum > curFileNum || (wc.curFileNum == curFileNum &&
-		wc.curOffset > curOffset) {
+	storeFileNum, storeOffset := pdb.store.writeLocation()
+	if storeFileNum > curFileNum || (storeFileNum == curFileNum &&
+		storeOffset > curOffset) {
 
 		log.Info("Detected unclean shutdown - Repairing...")
 		log.Debugf("Metadata claims file %d, offset %d. Block data is "+
 			"at file %d, offset %d", curFileNum, curOffset,
-			wc.curFileNum, wc.curOffset)
+			storeFileNum, storeOffset)
 		pdb.store.handleRollback(curFileNum, curOffset)
 		log.Infof("Database sync complete")
 	}
@@ -104,15 +108,25 @@ func reconcileDB(pdb *db, create bool) (database.DB, error) {
 	// possible to rescan and rebuild the metadata from the block files,
 	// however, that would need to happen with coordination from a higher
 	// layer since it could invalidate other metadata.
-	if wc.curFileNum < curFileNum || (wc.curFileNum == curFileNum &&
-		wc.curOffset < curOffset) {
+	if storeFileNum < curFileNum || (storeFileNum == curFileNum &&
+		storeOffset < curOffset) {
 
 		str := fmt.Sprintf("metadata claims file %d, offset %d, but "+
 			"block data is at file %d, offset %d", curFileNum,
-			curOffset, wc.curFileNum, wc.curOffset)
+			curOffset, storeFileNum, storeOffset)
 		_ = log.Warnf("***Database corruption detected***: %v", str)
 		return nil, makeDbErr(database.ErrCorruption, str, nil)
 	}
 
+	// Remove any block files which have been pruned, but were not deleted
+	// before an unclean shutdown.
+	pdb.store.pruneFiles(0, prunedFileNum)
+
+	// Resume moving the block files which have been archived, but were
+	// not moved to the cold storage before the database was closed.
+	if archivedFileNum > 0 {
+		pdb.store.archiveFiles(archivedFileNum)
+	}
+
 	return pdb, nil
 }
@@ -0,0 +1,120 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package ffldb
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+)
+
+const (
+	// scrubBatchSize is the number of block index records which are
+	// verified at once while the blocks are scrubbed.  Read-write
+	// transactions wait for each batch, so it is kept small.
+	scrubBatchSize = 64
+)
+
+// verifyBlock reads the data of the block with the passed hash and block index
+// record and ensures it matches the checksum it was stored with and the header
+// in the record.
+func (tx *transaction) verifyBlock(hash *chainhash.Hash, blockRow []byte) error {
+	location := deserializeBlockLoc(blockRow)
+	blockBytes, err := tx.db.readBlock(hash, location)
+	if err != nil {
+		return err
+	}
+	blockHdr := blockRow[blockHdrOffset : blockHdrOffset+blockHdrSize]
+	if len(blockBytes) < blockHdrSize ||
+		!bytes.Equal(blockBytes[:blockHdrSize], blockHdr) {
+
+		str := fmt.Sprintf("block data for block %s does not match the "+
+			"header in the block index", hash)
+		return makeDbErr(database.ErrCorruption, str, nil)
+	}
+	return nil
+}
+
+// scrubBatch verifies the data of up to scrubBatchSize blocks in the block
+// index, starting with the first block at or after the passed key.  It returns
+// the blocks which failed to be verified and the key to start the next batch
+// at, which is nil once all of the blocks have been verified.
+func (db *db) scrubBatch(startKey []byte) ([]database.CorruptBlock, []byte, error) {
+	// Read-write transactions wait while the batch is verified, since
+	// committing them could otherwise prune the blocks which are being
+	// read.
+	db.writeLock.Lock()
+	defer db.writeLock.Unlock()
+	tx, err := db.begin(false)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var corrupt []database.CorruptBlock
+	prunedFileNum := tx.prunedFileNum()
+	cursor := tx.blockIdxBucket.Cursor()
+	ok := cursor.Seek(startKey)
+	for i := 0; ok && i < scrubBatchSize; i++ {
+		var hash chainhash.Hash
+		copy(hash[:], cursor.Key())
+		blockRow := cursor.Value()
+		location := deserializeBlockLoc(blockRow)
+		if checkPruned(&hash, location, prunedFileNum) == nil {
+			if err := tx.verifyBlock(&hash, blockRow); err != nil {
+				corrupt = append(corrupt, database.CorruptBlock{
+					Hash:    hash,
+					FileNum: location.blockFileNum,
+					Offset:  location.fileOffset,
+					Len:     location.blockLen,
+					Err:     err,
+				})
+			}
+		}
+		ok = cursor.Next()
+	}
+	if !ok {
+		return corrupt, nil, nil
+	}
+
+	// The next batch starts at the key after the last one verified.
+	return corrupt, append([]byte(nil), cursor.Key()...), nil
+}
+
+// ScrubBlocks verifies the stored data of every block which has not been
+// pruned against the checksum it was stored with and the header in the block
+// index, and returns the blocks which failed to be verified.  Read-write
+// transactions wait while each batch of blocks is verified.
+//
+// This function is part of the database.DB interface implementation.
+func (db *db) ScrubBlocks(interrupt <-chan struct{}) ([]database.CorruptBlock, error) {
+	log.Infof("Scrubbing the stored blocks")
+	var corrupt []database.CorruptBlock
+	startKey := []byte{}
+	for startKey != nil {
+		select {
+		case <-interrupt:
+			log.Infof("Block scrub interrupted")
+			return corrupt, nil
+		default:
+		}
+
+		batch, nextKey, err := db.scrubBatch(startKey)
+		if err != nil {
+			return corrupt, err
+		}
+		for i := range batch {
+			log.Warnf("Corrupt block %s in file %d at offset %d: %v",
+				batch[i].Hash, batch[i].FileNum, batch[i].Offset,
+				batch[i].Err)
+		}
+		corrupt = append(corrupt, batch...)
+		startKey = nextKey
+	}
+	log.Infof("Scrubbed the stored blocks -- %d corrupt", len(corrupt))
+	return corrupt, nil
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is part of the ffldb package rather than the ffldb_test package as
+// it provides whitebox testing.
+
+package ffldb
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+)
+
+// TestScrubBlocks ensures scrubbing the blocks of a database of each type finds
+// the blocks whose stored data was damaged and that repairing them makes them
+// available again.
+func TestScrubBlocks(t *testing.T) {
+	t.Parallel()
+
+	for _, scrubDbType := range []string{dbType, ldbDbType} {
+		testScrubBlocks(t, scrubDbType)
+	}
+}
+
+// testScrubBlocks performs the scrub tests for the passed database type.
+func testScrubBlocks(t *testing.T, dbType string) {
+	// Create a new database to run tests against.
+	dbPath := filepath.Join(os.TempDir(), "ffldb-scrubtest-"+dbType)
+	_ = os.RemoveAll(dbPath)
+	idb, err := database.Create(dbType, dbPath, blockDataNet)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.RemoveAll(dbPath)
+	defer idb.Close()
+
+	// Store enough blocks based on the genesis block, which are
+	// distinguished by their height, to span several scrub batches.
+	blocks := make([]*provautil.Block, scrubBatchSize*2+1)
+	for i := range blocks {
+		msgBlock := *chaincfg.MainNetParams.GenesisBlock
+		msgBlock.Header.Height = uint32(i)
+		blocks[i] = provautil.NewBlock(&msgBlock)
+	}
+	err = idb.Update(func(tx database.Tx) error {
+		for _, block := range blocks {
+			if err := tx.StoreBlock(block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StoreBlock (%s): unexpected error: %v", dbType, err)
+	}
+
+	// Ensure no blocks are reported as corrupt while they are intact.
+	corrupt, err := idb.ScrubBlocks(nil)
+	if err != nil {
+		t.Fatalf("ScrubBlocks (%s): unexpected error: %v", dbType, err)
+	}
+	if len(corrupt) != 0 {
+		t.Fatalf("ScrubBlocks (%s): got %d corrupt blocks, want none",
+			dbType, len(corrupt))
+	}
+
+	// Damage the stored data of one of the blocks.
+	damaged := blocks[scrubBatchSize+1]
+	var location blockLocation
+	err = idb.View(func(tx database.Tx) error {
+		blockRow, err := tx.(*transaction).fetchBlockRow(damaged.Hash())
+		location = deserializeBlockLoc(blockRow)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("fetchBlockRow (%s): unexpected error: %v", dbType, err)
+	}
+	switch store := idb.(*db).store.(type) {
+	case *blockStore:
+		file, err := os.OpenFile(blockFilePath(dbPath,
+			location.blockFileNum), os.O_RDWR, 0)
+		if err != nil {
+			t.Fatalf("OpenFile: unexpected error: %v", err)
+		}
+		_, err = file.WriteAt([]byte{0xff, 0xff},
+			int64(location.fileOffset)+100)
+		file.Close()
+		if err != nil {
+			t.Fatalf("WriteAt: unexpected error: %v", err)
+		}
+
+	case *ldbBlockStore:
+		key := blockKey(location.blockFileNum, location.fileOffset)
+		record, err := store.ldb.Get(key, nil)
+		if err != nil {
+			t.Fatalf("Get: unexpected error: %v", err)
+		}
+		record[100] ^= 0xff
+		if err := store.ldb.Put(key, record, nil); err != nil {
+			t.Fatalf("Put: unexpected error: %v", err)
+		}
+	}
+
+	// Ensure scrubbing stops early when it is interrupted.
+	interrupt := make(chan struct{})
+	close(interrupt)
+	corrupt, err = idb.ScrubBlocks(interrupt)
+	if err != nil || len(corrupt) != 0 {
+		t.Fatalf("ScrubBlocks (%s): got %d corrupt blocks and error %v "+
+			"when interrupted, want none", dbType, len(corrupt), err)
+	}
+
+	// Ensure the damaged block is reported with its location.
+	corrupt, err = idb.ScrubBlocks(nil)
+	if err != nil {
+		t.Fatalf("ScrubBlocks (%s): unexpected error: %v", dbType, err)
+	}
+	if len(corrupt) != 1 || corrupt[0].Hash != *damaged.Hash() ||
+		corrupt[0].FileNum != location.blockFileNum ||
+		corrupt[0].Offset != location.fileOffset ||
+		corrupt[0].Len != location.blockLen {
+
+		t.Fatalf("ScrubBlocks (%s): got corrupt blocks %+v, want block "+
+			"%v at %+v", dbType, corrupt, damaged.Hash(), location)
+	}
+	if !checkDbError(t, "ScrubBlocks", corrupt[0].Err,
+		database.ErrCorruption) {
+		return
+	}
+
+	// Ensure blocks are only able to be repaired in read-write
+	// transactions and when they exist.
+	err = idb.View(func(tx database.Tx) error {
+		return tx.RepairBlock(damaged)
+	})
+	if !checkDbError(t, "RepairBlock", err, database.ErrTxNotWritable) {
+		return
+	}
+	msgBlock := *chaincfg.MainNetParams.GenesisBlock
+	msgBlock.Header.Height = uint32(len(blocks))
+	err = idb.Update(func(tx database.Tx) error {
+		return tx.RepairBlock(provautil.NewBlock(&msgBlock))
+	})
+	if !checkDbError(t, "RepairBlock", err, database.ErrBlockNotFound) {
+		return
+	}
+
+	// Repair the damaged block and ensure it is able to be fetched and is
+	// no longer reported as corrupt.
+	err = idb.Update(func(tx database.Tx) error {
+		return tx.RepairBlock(damaged)
+	})
+	if err != nil {
+		t.Fatalf("RepairBlock (%s): unexpected error: %v", dbType, err)
+	}
+	err = idb.View(func(tx database.Tx) error {
+		gotBytes, err := tx.FetchBlock(damaged.Hash())
+		if err != nil {
+			return err
+		}
+		wantBytes, _ := damaged.Bytes()
+		if !bytes.Equal(gotBytes, wantBytes) {
+			t.Fatalf("FetchBlock (%s): repaired block mismatch",
+				dbType)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("FetchBlock (%s): unexpected error: %v", dbType, err)
+	}
+	corrupt, err = idb.ScrubBlocks(nil)
+	if err != nil {
+		t.Fatalf("ScrubBlocks (%s): unexpected error: %v", dbType, err)
+	}
+	if len(corrupt) != 0 {
+		t.Fatalf("ScrubBlocks (%s): got %d corrupt blocks after repair, "+
+			"want none", dbType, len(corrupt))
+	}
+}
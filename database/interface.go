@@ -11,6 +11,7 @@ package database
 import (
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
 )
 
 // Cursor represents a cursor over key/value pairs and nested buckets of a
@@ -230,6 +231,22 @@ type Tx interface {
 	// Other errors are possible depending on the implementation.
 	StoreBlock(block *provautil.Block) error
 
+	// StoreBlockHeader stores the provided block header into the database
+	// without the data of the block, such as when the chain state as of
+	// the block is obtained from a trusted source instead of by processing
+	// the blocks.  The block is treated like a pruned block, so HasBlock
+	// reports it as existing and its header is available, however fetching
+	// its data, or regions of it, results in ErrBlockPruned.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrBlockExists when the block hash already exists
+	//   - ErrTxNotWritable if attempted against a read-only transaction
+	//   - ErrTxClosed if the transaction has already been closed
+	//
+	// Other errors are possible depending on the implementation.
+	StoreBlockHeader(header *wire.BlockHeader) error
+
 	// HasBlock returns whether or not a block with the given hash exists
 	// in the database.
 	//
@@ -307,6 +324,7 @@ type Tx interface {
 	// The interface contract guarantees at least the following errors will
 	// be returned (other implementation-specific errors are possible):
 	//   - ErrBlockNotFound if the requested block hash does not exist
+	//   - ErrBlockPruned if the requested block has been pruned
 	//   - ErrTxClosed if the transaction has already been closed
 	//   - ErrCorruption if the database has somehow become corrupted
 	//
@@ -325,6 +343,7 @@ type Tx interface {
 	// be returned (other implementation-specific errors are possible):
 	//   - ErrBlockNotFound if the any of the requested block hashes do not
 	//     exist
+	//   - ErrBlockPruned if any of the requested blocks have been pruned
 	//   - ErrTxClosed if the transaction has already been closed
 	//   - ErrCorruption if the database has somehow become corrupted
 	//
@@ -352,6 +371,7 @@ type Tx interface {
 	//   - ErrBlockNotFound if the requested block hash does not exist
 	//   - ErrBlockRegionInvalid if the region exceeds the bounds of the
 	//     associated block
+	//   - ErrBlockPruned if the requested block has been pruned
 	//   - ErrTxClosed if the transaction has already been closed
 	//   - ErrCorruption if the database has somehow become corrupted
 	//
@@ -380,6 +400,7 @@ type Tx interface {
 	//     exist
 	//   - ErrBlockRegionInvalid if one or more region exceed the bounds of
 	//     the associated block
+	//   - ErrBlockPruned if any of the requested blocks have been pruned
 	//   - ErrTxClosed if the transaction has already been closed
 	//   - ErrCorruption if the database has somehow become corrupted
 	//
@@ -390,6 +411,67 @@ type Tx interface {
 	// implementations.
 	FetchBlockRegions(regions []BlockRegion) ([][]byte, error)
 
+	// PruneBlocks removes the raw serialized bytes of the blocks stored
+	// before the block identified by the given hash in order to reclaim
+	// storage space.  The headers of the pruned blocks remain available and
+	// HasBlock continues to report them as existing, however fetching their
+	// data, or regions of it, results in ErrBlockPruned once the
+	// transaction is committed.  Depending on the backend implementation,
+	// some of the blocks stored before the given block might be retained,
+	// such as those which share storage with it.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrBlockNotFound if the requested block hash does not exist
+	//   - ErrTxNotWritable if attempted against a read-only transaction
+	//   - ErrTxClosed if the transaction has already been closed
+	PruneBlocks(hash *chainhash.Hash) error
+
+	// ArchiveBlocks moves the raw serialized bytes of the blocks stored
+	// before the block identified by the given hash to the cold storage of
+	// the database, if it has one, once the transaction is committed.  The
+	// data might be moved in the background after the commit returns, and
+	// the archived blocks remain available throughout.  Depending on
+	// the backend implementation, some of the blocks stored before the
+	// given block might be left in place, such as those which share storage
+	// with it.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrBlockNotFound if the requested block hash does not exist
+	//   - ErrTxNotWritable if attempted against a read-only transaction
+	//   - ErrTxClosed if the transaction has already been closed
+	ArchiveBlocks(hash *chainhash.Hash) error
+
+	// RebuildBlockIndex rebuilds the index of the stored blocks from the
+	// block data in the backing storage, which makes the blocks available
+	// again when their index records were lost or corrupted.  It returns
+	// the hashes of the blocks which were found in the order they were
+	// stored.  The records of pruned blocks and of blocks stored without
+	// their data are retained, but their hashes are not returned.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrTxNotWritable if attempted against a read-only transaction
+	//   - ErrTxClosed if the transaction has already been closed
+	RebuildBlockIndex() ([]chainhash.Hash, error)
+
+	// RepairBlock stores the provided block again in place of its stored
+	// data, which is useful when ScrubBlocks found the data to be corrupt.
+	// The block index is updated to the location of the new copy, so the
+	// corrupt data is no longer referenced.
+	//
+	// The interface contract guarantees at least the following errors will
+	// be returned (other implementation-specific errors are possible):
+	//   - ErrBlockNotFound if the block hash does not exist
+	//   - ErrBlockPruned if the block has been pruned or was stored
+	//     without its data
+	//   - ErrTxNotWritable if attempted against a read-only transaction
+	//   - ErrTxClosed if the transaction has already been closed
+	//
+	// Other errors are possible depending on the implementation.
+	RepairBlock(block *provautil.Block) error
+
 	// ******************************************************************
 	// Methods related to both atomic metadata storage and block storage.
 	// ******************************************************************
@@ -409,6 +491,22 @@ type Tx interface {
 	Rollback() error
 }
 
+// CorruptBlock describes a stored block whose data failed to be verified by
+// ScrubBlocks.
+type CorruptBlock struct {
+	// Hash is the hash of the block.
+	Hash chainhash.Hash
+
+	// FileNum, Offset, and Len identify the region of the block storage
+	// which houses the data of the block.
+	FileNum uint32
+	Offset  uint32
+	Len     uint32
+
+	// Err describes why the data failed to be verified.
+	Err error
+}
+
 // DB provides a generic interface that is used to store bitcoin blocks and
 // related metadata.  This interface is intended to be agnostic to the actual
 // mechanism used for backend data storage.  The RegisterDriver function can be
@@ -460,6 +558,29 @@ type DB interface {
 	// user-supplied function will result in a panic.
 	Update(fn func(tx Tx) error) error
 
+	// Backup writes a consistent copy of the database to a new database at
+	// the passed path while the database remains open.  The copy is able
+	// to be opened with the same driver and parameters as the database,
+	// unless the driver documents otherwise.
+	// Read-only transactions proceed while the copy is written, while
+	// read-write transactions wait for it to finish.
+	//
+	// The following errors are required to be returned:
+	//   - ErrDbExists if the passed path already exists
+	//   - ErrDbNotOpen if the database is closed
+	Backup(dst string) error
+
+	// ScrubBlocks verifies the stored data of every block which has not
+	// been pruned against the checksum it was stored with and the header
+	// in the block index, and returns the blocks which failed to be
+	// verified.  The blocks are verified in small batches, so the database
+	// remains usable while they are.  When the passed interrupt channel is
+	// closed, it stops early and returns the corrupt blocks found so far.
+	//
+	// The following errors are required to be returned:
+	//   - ErrDbNotOpen if the database is closed
+	ScrubBlocks(interrupt <-chan struct{}) ([]CorruptBlock, error)
+
 	// Close cleanly shuts down the database and syncs all data.  It will
 	// block until all database transactions have been finalized (rolled
 	// back or committed).
@@ -0,0 +1,343 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dnsseed
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bitgo/prova/addrmgr"
+	"github.com/bitgo/prova/peer"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// defaultCrawlers is the default number of peers crawled concurrently.
+	defaultCrawlers = 8
+
+	// crawlTimeout is the maximum duration of each step of crawling a
+	// peer, which are completing the handshake and receiving addresses.
+	crawlTimeout = 20 * time.Second
+
+	// recrawlInterval is the minimum duration between crawls of the same
+	// peer.
+	recrawlInterval = 15 * time.Minute
+
+	// goodTimeout is how long peers are good after they were last crawled
+	// successfully.  Peers which fail to be crawled stop being good right
+	// away.
+	goodTimeout = 24 * time.Hour
+
+	// idleWait is how long crawlers wait for the address manager to learn
+	// about new addresses when there is no address to crawl.
+	idleWait = 5 * time.Second
+
+	// maxPickTries is the maximum number of addresses picked from the
+	// address manager to find one to crawl.
+	maxPickTries = 100
+)
+
+var (
+	// ErrAddrManagerNil is used to indicate that AddrManager cannot be nil
+	// in the crawler configuration.
+	ErrAddrManagerNil = errors.New("CrawlerConfig: AddrManager cannot be nil")
+
+	// ErrDialNil is used to indicate that Dial cannot be nil in the crawler
+	// configuration.
+	ErrDialNil = errors.New("CrawlerConfig: Dial cannot be nil")
+)
+
+// CrawlerConfig holds the configuration options related to the crawler.
+type CrawlerConfig struct {
+	// AddrManager is the address manager the addresses to crawl are picked
+	// from.  The outcome of crawls and the addresses learned from the
+	// crawled peers are recorded in it.
+	AddrManager *addrmgr.AddrManager
+
+	// Dial connects to the address of a peer.  It cannot be nil.
+	Dial func(net.Addr) (net.Conn, error)
+
+	// PeerConfig is the configuration of the peers created to crawl.  The
+	// chain parameters must be set since only peers on the default port
+	// of the network are crawled.  Its listeners are replaced by the
+	// crawler.
+	PeerConfig peer.Config
+
+	// Crawlers is the number of peers crawled concurrently.  Defaults to
+	// 8.
+	Crawlers int
+}
+
+// crawledNode is the outcome of crawling a peer.
+type crawledNode struct {
+	ip          net.IP
+	services    wire.ServiceFlag
+	lastCrawl   time.Time
+	lastSuccess time.Time // Zero when the last crawl failed.
+}
+
+// Crawler crawls the network by completing the version handshake with the
+// peers known to an address manager and asking them for the addresses they
+// know.
+type Crawler struct {
+	// The following variables must only be used atomically.
+	start int32
+	stop  int32
+
+	cfg         CrawlerConfig
+	defaultPort uint16
+	wg          sync.WaitGroup
+	quit        chan struct{}
+
+	mtx   sync.Mutex
+	nodes map[string]*crawledNode
+}
+
+// NewCrawler returns a new crawler.  Use Start to begin crawling.
+func NewCrawler(cfg *CrawlerConfig) (*Crawler, error) {
+	if cfg.AddrManager == nil {
+		return nil, ErrAddrManagerNil
+	}
+	if cfg.Dial == nil {
+		return nil, ErrDialNil
+	}
+	if cfg.PeerConfig.ChainParams == nil {
+		return nil, errors.New("CrawlerConfig: ChainParams cannot be nil")
+	}
+	port, err := strconv.ParseUint(cfg.PeerConfig.ChainParams.DefaultPort,
+		10, 16)
+	if err != nil {
+		return nil, err
+	}
+	c := Crawler{
+		cfg:         *cfg, // Copy so caller can't mutate
+		defaultPort: uint16(port),
+		quit:        make(chan struct{}),
+		nodes:       make(map[string]*crawledNode),
+	}
+	if c.cfg.Crawlers <= 0 {
+		c.cfg.Crawlers = defaultCrawlers
+	}
+	return &c, nil
+}
+
+// Start begins crawling the network.
+func (c *Crawler) Start() {
+	// Already started?
+	if atomic.AddInt32(&c.start, 1) != 1 {
+		return
+	}
+
+	log.Trace("Crawler started")
+	for i := 0; i < c.cfg.Crawlers; i++ {
+		c.wg.Add(1)
+		go c.crawlHandler()
+	}
+}
+
+// Stop stops crawling and waits for the crawls in progress to be aborted.
+func (c *Crawler) Stop() {
+	if atomic.AddInt32(&c.stop, 1) != 1 {
+		log.Warnf("Crawler already stopped")
+		return
+	}
+
+	close(c.quit)
+	c.wg.Wait()
+	log.Trace("Crawler stopped")
+}
+
+// GoodAddresses returns the IPs of the peers supporting the passed services
+// which were crawled successfully within the last 24 hours.
+//
+// This function is safe for concurrent access.
+func (c *Crawler) GoodAddresses(services wire.ServiceFlag) []net.IP {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	var ips []net.IP
+	for _, node := range c.nodes {
+		if node.lastSuccess.IsZero() ||
+			time.Since(node.lastSuccess) > goodTimeout ||
+			node.services&services != services {
+
+			continue
+		}
+		ips = append(ips, node.ip)
+	}
+	return ips
+}
+
+// crawlHandler crawls the addresses picked from the address manager until the
+// crawler is stopped.  It must be run as a goroutine.
+func (c *Crawler) crawlHandler() {
+out:
+	for {
+		na := c.nextAddress()
+		if na == nil {
+			select {
+			case <-c.quit:
+				break out
+			case <-time.After(idleWait):
+			}
+			continue
+		}
+		c.crawl(na)
+
+		select {
+		case <-c.quit:
+			break out
+		default:
+		}
+	}
+
+	c.wg.Done()
+}
+
+// nextAddress picks the next address to crawl from the address manager and
+// marks it as crawled, or returns nil when there is none.  Only the addresses
+// of IPv4 and IPv6 peers on the default port of the network are crawled
+// since DNS responses are unable to carry other addresses.
+func (c *Crawler) nextAddress() *wire.NetAddressV2 {
+	now := time.Now()
+	for tries := 0; tries < maxPickTries; tries++ {
+		ka := c.cfg.AddrManager.GetAddress()
+		if ka == nil {
+			return nil
+		}
+		na := ka.NetAddressV2()
+		ip := na.IP()
+		if ip == nil || na.Network == wire.NetCJDNS ||
+			na.Port != c.defaultPort {
+
+			continue
+		}
+
+		key := addrmgr.NetAddressV2Key(na)
+		c.mtx.Lock()
+		node, ok := c.nodes[key]
+		if ok && now.Sub(node.lastCrawl) < recrawlInterval {
+			c.mtx.Unlock()
+			continue
+		}
+		if !ok {
+			node = &crawledNode{ip: ip}
+			c.nodes[key] = node
+		}
+		node.lastCrawl = now
+		c.mtx.Unlock()
+		return na
+	}
+	return nil
+}
+
+// recordCrawl records the outcome of crawling the passed address.  The services
+// are only used when the crawl succeeded.
+func (c *Crawler) recordCrawl(na *wire.NetAddressV2, success bool,
+	services wire.ServiceFlag) {
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	node, ok := c.nodes[addrmgr.NetAddressV2Key(na)]
+	if !ok {
+		return
+	}
+	if success {
+		node.services = services
+		node.lastSuccess = time.Now()
+	} else {
+		node.lastSuccess = time.Time{}
+	}
+}
+
+// crawl connects to the peer at the passed address, completes the version
+// handshake with it and asks it for the addresses it knows, which are added to
+// the address manager.
+func (c *Crawler) crawl(na *wire.NetAddressV2) {
+	addrManager := c.cfg.AddrManager
+	addrManager.AttemptV2(na)
+
+	addr := &net.TCPAddr{IP: na.IP(), Port: int(na.Port)}
+	conn, err := c.cfg.Dial(addr)
+	if err != nil {
+		log.Debugf("Failed to connect to %s: %v", addr, err)
+		c.recordCrawl(na, false, 0)
+		return
+	}
+
+	verAck := make(chan struct{}, 1)
+	gotAddrs := make(chan struct{}, 1)
+	signal := func(ch chan struct{}) {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	cfg := c.cfg.PeerConfig
+	cfg.Listeners = peer.MessageListeners{
+		OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+			signal(verAck)
+		},
+		OnAddr: func(p *peer.Peer, msg *wire.MsgAddr) {
+			addrManager.AddAddresses(msg.AddrList, p.NA())
+			signal(gotAddrs)
+		},
+		OnAddrV2: func(p *peer.Peer, msg *wire.MsgAddrV2) {
+			addrManager.AddAddressesV2(msg.AddrList, na)
+			signal(gotAddrs)
+		},
+	}
+	p, err := peer.NewOutboundPeer(&cfg, addr.String())
+	if err != nil {
+		log.Debugf("Cannot create peer %s: %v", addr, err)
+		conn.Close()
+		c.recordCrawl(na, false, 0)
+		return
+	}
+	disconnected := make(chan struct{})
+	p.AssociateConnection(conn)
+	go func() {
+		p.WaitForDisconnect()
+		close(disconnected)
+	}()
+	defer func() {
+		p.Disconnect()
+		<-disconnected
+	}()
+
+	timeout := time.NewTimer(crawlTimeout)
+	defer timeout.Stop()
+	select {
+	case <-verAck:
+	case <-disconnected:
+		log.Debugf("Peer %s disconnected during the handshake", addr)
+		c.recordCrawl(na, false, 0)
+		return
+	case <-timeout.C:
+		log.Debugf("Handshake with peer %s timed out", addr)
+		c.recordCrawl(na, false, 0)
+		return
+	case <-c.quit:
+		return
+	}
+	addrManager.GoodV2(na)
+	c.recordCrawl(na, true, p.Services())
+	log.Debugf("Crawled peer %s (services %v)", addr, p.Services())
+
+	// Ask the peer for the addresses it knows.  Peers which do not answer
+	// are still good since they completed the handshake.
+	p.QueueMessage(wire.NewMsgGetAddr(), nil)
+	select {
+	case <-gotAddrs:
+	case <-disconnected:
+	case <-time.After(crawlTimeout):
+	case <-c.quit:
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dnsseed
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/addrmgr"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/peer"
+	"github.com/bitgo/prova/wire"
+)
+
+// pipeConn is one end of a net.Pipe with TCP addresses, which peers require.
+type pipeConn struct {
+	net.Conn
+	laddr, raddr net.Addr
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return c.laddr }
+func (c *pipeConn) RemoteAddr() net.Addr { return c.raddr }
+
+// fakePeer completes the version handshake on the passed connection and
+// answers getaddr messages with the passed address until the connection is
+// closed.
+func fakePeer(conn net.Conn, params *chaincfg.Params, known *wire.NetAddress) {
+	defer conn.Close()
+	pver := wire.ProtocolVersion
+	for {
+		msg, _, err := wire.ReadMessage(conn, pver, params.Net)
+		if err != nil {
+			return
+		}
+		var replies []wire.Message
+		switch msg := msg.(type) {
+		case *wire.MsgVersion:
+			version := wire.NewMsgVersion(&msg.AddrYou, &msg.AddrMe,
+				1, 0)
+			version.Services = wire.SFNodeNetwork | wire.SFNodeBloom
+			replies = append(replies, version, wire.NewMsgVerAck())
+		case *wire.MsgGetAddr:
+			addrs := wire.NewMsgAddr()
+			addrs.AddAddress(known)
+			replies = append(replies, addrs)
+		}
+		for _, reply := range replies {
+			err := wire.WriteMessage(conn, reply, pver, params.Net)
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// TestCrawler ensures the crawler reports the peers it completed the
+// handshake with as good and crawls the addresses they know.
+func TestCrawler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dnsseed")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	params := &chaincfg.MainNetParams
+	amgr := addrmgr.New(dir, nil)
+	if err := amgr.AddAddressByIP("1.2.3.4:" + params.DefaultPort); err != nil {
+		t.Fatalf("AddAddressByIP: unexpected error: %v", err)
+	}
+
+	// Every dial reaches a fake peer which completes the handshake and
+	// answers getaddr with a single address.
+	known := wire.NewNetAddressIPPort(net.ParseIP("5.6.7.8"), 7979,
+		wire.SFNodeNetwork)
+	dial := func(addr net.Addr) (net.Conn, error) {
+		local := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 50000}
+		c1, c2 := net.Pipe()
+		go fakePeer(c2, params, known)
+		return &pipeConn{c1, local, addr}, nil
+	}
+
+	c, err := NewCrawler(&CrawlerConfig{
+		AddrManager: amgr,
+		Dial:        dial,
+		PeerConfig: peer.Config{
+			UserAgentName:    "crawler",
+			UserAgentVersion: "1.0",
+			ChainParams:      params,
+			DisableRelayTx:   true,
+		},
+		Crawlers: 1,
+	})
+	if err != nil {
+		t.Fatalf("NewCrawler: unexpected error: %v", err)
+	}
+	c.Start()
+	defer c.Stop()
+
+	// The address learned from the first peer is crawled in turn.
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		ips := c.GoodAddresses(wire.SFNodeBloom)
+		if len(ips) == 2 && amgr.NumAddresses() == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got good addresses %v and %d known addresses, "+
+				"want 2 of each", ips, amgr.NumAddresses())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if ips := c.GoodAddresses(wire.SFNodeNetwork | 1<<20); len(ips) != 0 {
+		t.Errorf("got good addresses %v for unsupported services", ips)
+	}
+}
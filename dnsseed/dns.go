@@ -0,0 +1,184 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dnsseed
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+)
+
+// DNS record types, classes and response codes used by the seeder (RFC 1035
+// and RFC 3596).
+const (
+	typeA    uint16 = 1
+	typeAAAA uint16 = 28
+	typeANY  uint16 = 255
+
+	classIN  uint16 = 1
+	classANY uint16 = 255
+
+	rcodeSuccess        = 0
+	rcodeFormatError    = 1
+	rcodeNameError      = 3
+	rcodeNotImplemented = 4
+	rcodeRefused        = 5
+)
+
+const (
+	// headerSize is the size of the header of DNS messages.
+	headerSize = 12
+
+	// maxUDPSize is the maximum size of DNS messages sent over UDP
+	// without extensions.
+	maxUDPSize = 512
+
+	// maxNameSize is the maximum size of an encoded domain name.
+	maxNameSize = 255
+
+	// namePointer is the compressed name pointing to the name of the
+	// question, which always starts right after the header.
+	namePointer = 0xc000 | headerSize
+)
+
+// Flags of the header of DNS messages.
+const (
+	flagResponse      = 1 << 15
+	flagAuthoritative = 1 << 10
+	flagRecursion     = 1 << 8
+	opcodeMask        = 0xf << 11
+)
+
+var (
+	// errShortMessage is returned for messages which end prematurely.
+	errShortMessage = errors.New("dns message too short")
+
+	// errInvalidName is returned for malformed domain names.
+	errInvalidName = errors.New("invalid domain name")
+)
+
+// question is the question section of a DNS query.
+type question struct {
+	name   string // Lowercase without a trailing dot.
+	qtype  uint16
+	qclass uint16
+	raw    []byte // Encoded question to echo in the response.
+}
+
+// query is a parsed DNS query.
+type query struct {
+	id       uint16
+	flags    uint16
+	question *question
+}
+
+// parseQuery parses the passed DNS query, which must have exactly one question.
+// Compressed names are rejected since queries have no names to point to.
+func parseQuery(msg []byte) (*query, error) {
+	if len(msg) < headerSize {
+		return nil, errShortMessage
+	}
+	q := &query{
+		id:    binary.BigEndian.Uint16(msg[0:2]),
+		flags: binary.BigEndian.Uint16(msg[2:4]),
+	}
+	if q.flags&flagResponse != 0 {
+		return nil, errors.New("dns message is a response")
+	}
+	if binary.BigEndian.Uint16(msg[4:6]) != 1 {
+		return q, errors.New("dns query must have one question")
+	}
+
+	labels := make([]string, 0, 4)
+	offset := headerSize
+	for {
+		if offset >= len(msg) {
+			return q, errShortMessage
+		}
+		size := int(msg[offset])
+		offset++
+		if size == 0 {
+			break
+		}
+		if size > 63 || offset+size > len(msg) ||
+			offset-headerSize+size > maxNameSize {
+
+			return q, errInvalidName
+		}
+		labels = append(labels, strings.ToLower(string(msg[offset:offset+size])))
+		offset += size
+	}
+	if offset+4 > len(msg) {
+		return q, errShortMessage
+	}
+	q.question = &question{
+		name:   strings.Join(labels, "."),
+		qtype:  binary.BigEndian.Uint16(msg[offset : offset+2]),
+		qclass: binary.BigEndian.Uint16(msg[offset+2 : offset+4]),
+		raw:    msg[headerSize : offset+4],
+	}
+	return q, nil
+}
+
+// appendResponse appends the response to the passed query with the passed
+// response code and the passed IPs as answers to b.  Answers which do not fit
+// in maxUDPSize are left out.
+func appendResponse(b []byte, q *query, rcode uint16, ttl uint32,
+	ips []net.IP) []byte {
+
+	var raw []byte
+	if q.question != nil {
+		raw = q.question.raw
+	}
+	if headerSize+len(raw) > maxUDPSize {
+		raw, ips = nil, nil
+	}
+
+	start := len(b)
+	flags := flagResponse | flagAuthoritative | q.flags&opcodeMask |
+		q.flags&flagRecursion | rcode
+	var qdCount uint16
+	if raw != nil {
+		qdCount = 1
+	}
+	b = appendUint16(b, q.id)
+	b = appendUint16(b, flags)
+	b = appendUint16(b, qdCount)
+	b = appendUint16(b, 0) // Answer count, set below.
+	b = appendUint16(b, 0)
+	b = appendUint16(b, 0)
+	b = append(b, raw...)
+
+	var anCount uint16
+	for _, ip := range ips {
+		rtype, rdata := typeAAAA, ip.To16()
+		if ip4 := ip.To4(); ip4 != nil {
+			rtype, rdata = typeA, ip4
+		}
+		if len(b)-start+12+len(rdata) > maxUDPSize {
+			break
+		}
+		b = appendUint16(b, namePointer)
+		b = appendUint16(b, rtype)
+		b = appendUint16(b, classIN)
+		b = appendUint32(b, ttl)
+		b = appendUint16(b, uint16(len(rdata)))
+		b = append(b, rdata...)
+		anCount++
+	}
+	binary.BigEndian.PutUint16(b[start+6:start+8], anCount)
+	return b
+}
+
+// appendUint16 appends v to b in big endian.
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+// appendUint32 appends v to b in big endian.
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
@@ -0,0 +1,27 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package dnsseed implements a DNS seeder for the Prova network, which allows
+private networks to run their own seeders without third-party software.
+
+The seeder is made up of two parts.  The Crawler connects to the addresses known
+to an address manager, completes the version handshake with them and asks them
+for the addresses they know in turn, which are added back to the address
+manager.  The peers which completed the handshake recently are the good peers.
+The Server answers the DNS queries for the host name of the seeder with the IP
+addresses of good peers, which nodes resolve to discover peers when they start.
+
+Only peers listening on the default port of the network are served since DNS
+responses are unable to carry ports.  A and AAAA queries are answered with up
+to MaxAnswers random IPv4 and IPv6 addresses respectively.  As with the seeders
+of Bitcoin, queries for x<services>.<host>, where services is the service flag
+in hex, are answered with the addresses of the peers supporting the services.
+
+The Server implements the subset of the DNS wire protocol over UDP described in
+RFC 1035 which is needed to answer these queries directly.  The NS record of the
+host name of the seeder must be delegated to the address the Server listens on
+for resolvers to reach it.
+*/
+package dnsseed
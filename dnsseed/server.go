@@ -0,0 +1,217 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dnsseed
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// MaxAnswers is the maximum number of addresses in a response.
+	MaxAnswers = 25
+
+	// defaultTTL is the default time to live of the answers.
+	defaultTTL = time.Minute
+)
+
+var (
+	// ErrConnNil is used to indicate that Conn cannot be nil in the
+	// configuration.
+	ErrConnNil = errors.New("Config: Conn cannot be nil")
+
+	// ErrGoodAddressesNil is used to indicate that GoodAddresses cannot be
+	// nil in the configuration.
+	ErrGoodAddressesNil = errors.New("Config: GoodAddresses cannot be nil")
+)
+
+// Config holds the configuration options related to the DNS seeder server.
+type Config struct {
+	// Host is the host name the seeder answers queries for, such as
+	// seed.example.com.  Queries for other names are refused.
+	Host string
+
+	// Conn is the connection queries are received and answered on.  The
+	// server takes ownership of it and closes it when stopped.
+	Conn net.PacketConn
+
+	// TTL is how long resolvers may cache the answers.  Defaults to 1
+	// minute.
+	TTL time.Duration
+
+	// GoodAddresses returns the IPs of the good peers supporting the
+	// passed services.  It cannot be nil.
+	GoodAddresses func(services wire.ServiceFlag) []net.IP
+}
+
+// Server answers DNS queries for the host name of the seeder with the IPs of
+// good peers.
+type Server struct {
+	// The following variables must only be used atomically.
+	start int32
+	stop  int32
+
+	cfg  Config
+	host string
+	wg   sync.WaitGroup
+}
+
+// New returns a new DNS seeder server.  Use Start to begin answering queries.
+func New(cfg *Config) (*Server, error) {
+	if cfg.Conn == nil {
+		return nil, ErrConnNil
+	}
+	if cfg.GoodAddresses == nil {
+		return nil, ErrGoodAddressesNil
+	}
+	host := strings.TrimSuffix(strings.ToLower(cfg.Host), ".")
+	if host == "" {
+		return nil, errors.New("Config: Host cannot be empty")
+	}
+	s := Server{
+		cfg:  *cfg, // Copy so caller can't mutate
+		host: host,
+	}
+	if s.cfg.TTL <= 0 {
+		s.cfg.TTL = defaultTTL
+	}
+	return &s, nil
+}
+
+// Start begins answering queries.
+func (s *Server) Start() {
+	// Already started?
+	if atomic.AddInt32(&s.start, 1) != 1 {
+		return
+	}
+
+	log.Infof("DNS seeder for %s listening on %s", s.host,
+		s.cfg.Conn.LocalAddr())
+	s.wg.Add(1)
+	go s.queryHandler()
+}
+
+// Stop stops answering queries and closes the connection.
+func (s *Server) Stop() {
+	if atomic.AddInt32(&s.stop, 1) != 1 {
+		log.Warnf("DNS seeder already stopped")
+		return
+	}
+
+	// Ignore the error since this is shutdown and there is no way to
+	// recover anyways.
+	_ = s.cfg.Conn.Close()
+	s.wg.Wait()
+}
+
+// queryHandler reads and answers queries until the server is stopped.  It
+// must be run as a goroutine.
+func (s *Server) queryHandler() {
+	buf := make([]byte, maxUDPSize)
+	resp := make([]byte, 0, maxUDPSize)
+	for {
+		n, addr, err := s.cfg.Conn.ReadFrom(buf)
+		if err != nil {
+			// Only log the error if not forcibly shutting down.
+			if atomic.LoadInt32(&s.stop) != 0 {
+				break
+			}
+			log.Errorf("Can't read DNS query: %v", err)
+			continue
+		}
+
+		resp = s.handleQuery(resp[:0], buf[:n])
+		if resp == nil {
+			continue
+		}
+		if _, err := s.cfg.Conn.WriteTo(resp, addr); err != nil {
+			log.Debugf("Can't answer DNS query from %s: %v", addr,
+				err)
+		}
+	}
+
+	s.wg.Done()
+	log.Tracef("DNS seeder query handler done")
+}
+
+// handleQuery appends the response to the passed query to b, or returns nil
+// when the query does not warrant a response.
+func (s *Server) handleQuery(b, msg []byte) []byte {
+	q, err := parseQuery(msg)
+	if q == nil {
+		// Messages without a header or which are responses are
+		// dropped.
+		return nil
+	}
+	if err != nil {
+		log.Debugf("Malformed DNS query: %v", err)
+		return appendResponse(b, q, rcodeFormatError, 0, nil)
+	}
+	if q.flags&opcodeMask != 0 {
+		return appendResponse(b, q, rcodeNotImplemented, 0, nil)
+	}
+
+	question := q.question
+	if question.qclass != classIN && question.qclass != classANY {
+		return appendResponse(b, q, rcodeRefused, 0, nil)
+	}
+	services, ok := s.servicesForName(question.name)
+	if !ok {
+		// Names below the host of the seeder do not exist and other
+		// names are not served by the seeder.
+		if strings.HasSuffix(question.name, "."+s.host) {
+			return appendResponse(b, q, rcodeNameError, 0, nil)
+		}
+		return appendResponse(b, q, rcodeRefused, 0, nil)
+	}
+
+	wantIPv4 := question.qtype == typeA || question.qtype == typeANY
+	wantIPv6 := question.qtype == typeAAAA || question.qtype == typeANY
+	var ips []net.IP
+	for _, ip := range s.cfg.GoodAddresses(services) {
+		isIPv4 := ip.To4() != nil
+		if (isIPv4 && wantIPv4) || (!isIPv4 && wantIPv6) {
+			ips = append(ips, ip)
+		}
+	}
+	rand.Shuffle(len(ips), func(i, j int) {
+		ips[i], ips[j] = ips[j], ips[i]
+	})
+	if len(ips) > MaxAnswers {
+		ips = ips[:MaxAnswers]
+	}
+	log.Tracef("Answering DNS query for %s (type %d) with %d addresses",
+		question.name, question.qtype, len(ips))
+
+	ttl := uint32(s.cfg.TTL / time.Second)
+	return appendResponse(b, q, rcodeSuccess, ttl, ips)
+}
+
+// servicesForName returns the services the peers in the answers to queries for
+// the passed name must support.  Queries for the host of the seeder are
+// answered with full nodes and queries for x<services>.<host> with the peers
+// supporting the services in hex.  It returns false for other names.
+func (s *Server) servicesForName(name string) (wire.ServiceFlag, bool) {
+	if name == s.host {
+		return wire.SFNodeNetwork, true
+	}
+	label := strings.TrimSuffix(name, "."+s.host)
+	if label == name || len(label) < 2 || label[0] != 'x' {
+		return 0, false
+	}
+	services, err := strconv.ParseUint(label[1:], 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return wire.ServiceFlag(services), true
+}
@@ -0,0 +1,230 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package dnsseed
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/wire"
+)
+
+// buildQuery returns a DNS query for the passed name and record type.
+func buildQuery(id uint16, name string, qtype uint16) []byte {
+	b := appendUint16(nil, id)
+	b = appendUint16(b, flagRecursion)
+	b = appendUint16(b, 1)
+	b = append(b, 0, 0, 0, 0, 0, 0)
+	for _, label := range strings.Split(name, ".") {
+		b = append(b, byte(len(label)))
+		b = append(b, label...)
+	}
+	b = append(b, 0)
+	b = appendUint16(b, qtype)
+	return appendUint16(b, classIN)
+}
+
+// testResponse is a parsed response to a query built by buildQuery.
+type testResponse struct {
+	id    uint16
+	rcode int
+	ips   []net.IP
+	ttl   uint32
+}
+
+// parseTestResponse parses the passed response to the passed query.
+func parseTestResponse(t *testing.T, query, resp []byte) *testResponse {
+	if len(resp) < len(query) {
+		t.Fatalf("response too short: %x", resp)
+	}
+	flags := binary.BigEndian.Uint16(resp[2:4])
+	if flags&flagResponse == 0 || flags&flagAuthoritative == 0 {
+		t.Fatalf("response flags %04x missing QR or AA", flags)
+	}
+	r := &testResponse{
+		id:    binary.BigEndian.Uint16(resp[0:2]),
+		rcode: int(flags & 0xf),
+	}
+	anCount := int(binary.BigEndian.Uint16(resp[6:8]))
+	offset := len(query)
+	for i := 0; i < anCount; i++ {
+		if binary.BigEndian.Uint16(resp[offset:]) != namePointer {
+			t.Fatalf("answer %d does not point to the question", i)
+		}
+		r.ttl = binary.BigEndian.Uint32(resp[offset+6:])
+		size := int(binary.BigEndian.Uint16(resp[offset+10:]))
+		offset += 12
+		r.ips = append(r.ips, net.IP(resp[offset:offset+size]))
+		offset += size
+	}
+	if offset != len(resp) {
+		t.Fatalf("got %d trailing bytes in response", len(resp)-offset)
+	}
+	return r
+}
+
+// TestHandleQuery ensures the queries for the host of the seeder and for
+// service filters are answered with the matching good addresses, and other
+// queries with the appropriate errors.
+func TestHandleQuery(t *testing.T) {
+	t.Parallel()
+
+	good := map[wire.ServiceFlag][]net.IP{
+		wire.SFNodeNetwork: {
+			net.ParseIP("1.2.3.4"), net.ParseIP("2001:db8::1"),
+		},
+		wire.SFNodeNetwork | wire.SFNodeBloom: {
+			net.ParseIP("5.6.7.8"),
+		},
+	}
+	s, err := New(&Config{
+		Host: "Seed.Example.com.",
+		Conn: &net.UDPConn{},
+		GoodAddresses: func(services wire.ServiceFlag) []net.IP {
+			return good[services]
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		qtype uint16
+		rcode int
+		ips   []string
+	}{
+		{"seed.example.com", typeA, rcodeSuccess, []string{"1.2.3.4"}},
+		{"SEED.example.com", typeAAAA, rcodeSuccess,
+			[]string{"2001:db8::1"}},
+		{"x5.seed.example.com", typeA, rcodeSuccess,
+			[]string{"5.6.7.8"}},
+		{"x5.seed.example.com", typeAAAA, rcodeSuccess, nil},
+		{"seed.example.com", 16, rcodeSuccess, nil},
+		{"foo.seed.example.com", typeA, rcodeNameError, nil},
+		{"xz.seed.example.com", typeA, rcodeNameError, nil},
+		{"example.com", typeA, rcodeRefused, nil},
+	}
+	for i, test := range tests {
+		query := buildQuery(uint16(i), test.name, test.qtype)
+		resp := s.handleQuery(nil, query)
+		r := parseTestResponse(t, query, resp)
+		if r.id != uint16(i) || r.rcode != test.rcode {
+			t.Errorf("%s (type %d): got id %d rcode %d, want id %d "+
+				"rcode %d", test.name, test.qtype, r.id, r.rcode,
+				i, test.rcode)
+			continue
+		}
+		if len(r.ips) != len(test.ips) {
+			t.Errorf("%s (type %d): got answers %v, want %v",
+				test.name, test.qtype, r.ips, test.ips)
+			continue
+		}
+		for j, ip := range r.ips {
+			if !ip.Equal(net.ParseIP(test.ips[j])) {
+				t.Errorf("%s (type %d): got answers %v, want %v",
+					test.name, test.qtype, r.ips, test.ips)
+			}
+		}
+		if len(r.ips) > 0 && r.ttl != uint32(defaultTTL/time.Second) {
+			t.Errorf("%s (type %d): got ttl %d", test.name,
+				test.qtype, r.ttl)
+		}
+	}
+
+	// Malformed queries are answered with a format error and responses
+	// are dropped.
+	query := buildQuery(1, "seed.example.com", typeA)
+	resp := s.handleQuery(nil, query[:len(query)-2])
+	if rcode := binary.BigEndian.Uint16(resp[2:4]) & 0xf; rcode != rcodeFormatError {
+		t.Errorf("truncated query: got rcode %d, want %d", rcode,
+			rcodeFormatError)
+	}
+	query[2] |= flagResponse >> 8
+	if resp := s.handleQuery(nil, query); resp != nil {
+		t.Errorf("response: got answer %x, want none", resp)
+	}
+}
+
+// TestHandleQueryMaxAnswers ensures responses are limited to MaxAnswers
+// addresses which fit in a UDP message.
+func TestHandleQueryMaxAnswers(t *testing.T) {
+	t.Parallel()
+
+	var ips []net.IP
+	for i := 0; i < 2*MaxAnswers; i++ {
+		ipv6 := net.ParseIP("2001:db8::")
+		ipv6[15] = byte(i)
+		ips = append(ips, net.IPv4(10, 0, 0, byte(i)), ipv6)
+	}
+	s, err := New(&Config{
+		Host: "seed.example.com",
+		Conn: &net.UDPConn{},
+		GoodAddresses: func(services wire.ServiceFlag) []net.IP {
+			return ips
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+
+	for _, qtype := range []uint16{typeA, typeAAAA} {
+		query := buildQuery(1, "seed.example.com", qtype)
+		resp := s.handleQuery(nil, query)
+		if len(resp) > maxUDPSize {
+			t.Errorf("type %d: got response of %d bytes", qtype,
+				len(resp))
+		}
+		r := parseTestResponse(t, query, resp)
+		if len(r.ips) == 0 || len(r.ips) > MaxAnswers {
+			t.Errorf("type %d: got %d answers", qtype, len(r.ips))
+		}
+	}
+}
+
+// TestServer ensures the server answers queries received on its connection.
+func TestServer(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket: unexpected error: %v", err)
+	}
+	s, err := New(&Config{
+		Host: "seed.example.com",
+		Conn: conn,
+		GoodAddresses: func(services wire.ServiceFlag) []net.IP {
+			return []net.IP{net.ParseIP("1.2.3.4")}
+		},
+	})
+	if err != nil {
+		t.Fatalf("New: unexpected error: %v", err)
+	}
+	s.Start()
+	defer s.Stop()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Dial: unexpected error: %v", err)
+	}
+	defer client.Close()
+	client.SetDeadline(time.Now().Add(10 * time.Second))
+
+	query := buildQuery(7, "seed.example.com", typeA)
+	if _, err := client.Write(query); err != nil {
+		t.Fatalf("Write: unexpected error: %v", err)
+	}
+	buf := make([]byte, maxUDPSize)
+	n, err := client.Read(buf)
+	if err != nil {
+		t.Fatalf("Read: unexpected error: %v", err)
+	}
+	r := parseTestResponse(t, query, buf[:n])
+	if r.id != 7 || len(r.ips) != 1 || !r.ips[0].Equal(net.IPv4(1, 2, 3, 4)) {
+		t.Fatalf("got id %d answers %v, want id 7 answer 1.2.3.4", r.id,
+			r.ips)
+	}
+}
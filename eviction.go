@@ -0,0 +1,151 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+const (
+	// evictProtectLatency is the number of inbound peers with the lowest
+	// ping times which are protected from eviction.
+	evictProtectLatency = 8
+
+	// evictProtectBlocks is the number of inbound peers which most
+	// recently provided a new block which are protected from eviction.
+	evictProtectBlocks = 4
+)
+
+// Reasons for which peers are protected from eviction as reported by the
+// getpeerinfo RPC.  Peers which are not protected are eviction candidates.
+const (
+	protectOutbound    = "outbound"
+	protectWhitelisted = "whitelisted"
+	protectLatency     = "latency"
+	protectBlocks      = "blocks"
+	protectLongevity   = "longevity"
+)
+
+// evictionCandidate describes an inbound peer which may be evicted to make
+// room for a new peer when the maximum number of peers is reached.
+type evictionCandidate struct {
+	id            int32
+	whitelisted   bool
+	connected     time.Time
+	minPingMicros int64
+	lastNewBlock  time.Time
+	group         string
+}
+
+// protectEvictionCandidates returns the reasons the passed candidates are
+// protected from eviction by their IDs, along with the remaining candidates
+// which are not protected.
+//
+// The peers protected are those which are whitelisted, the peers with the
+// lowest ping times, the peers which most recently provided new blocks and
+// finally half of the remaining peers which have been connected the longest.
+// An attacker is unable to imitate all of these at once without being better
+// connected and more useful than the honest peers, so the node keeps some
+// honest peers even when it is flooded with inbound connections.
+func protectEvictionCandidates(candidates []*evictionCandidate) (map[int32]string,
+	[]*evictionCandidate) {
+
+	protected := make(map[int32]string, len(candidates))
+	remaining := make([]*evictionCandidate, 0, len(candidates))
+	for _, c := range candidates {
+		if c.whitelisted {
+			protected[c.id] = protectWhitelisted
+			continue
+		}
+		remaining = append(remaining, c)
+	}
+
+	// protect protects up to the passed number of the first remaining
+	// candidates for which the passed function returns true once they are
+	// sorted by the passed less function.
+	protect := func(n int, reason string,
+		less func(a, b *evictionCandidate) bool,
+		eligible func(c *evictionCandidate) bool) {
+
+		sort.SliceStable(remaining, func(i, j int) bool {
+			return less(remaining[i], remaining[j])
+		})
+		kept := remaining[:0]
+		for _, c := range remaining {
+			if n > 0 && eligible(c) {
+				protected[c.id] = reason
+				n--
+				continue
+			}
+			kept = append(kept, c)
+		}
+		remaining = kept
+	}
+
+	// Peers which have not answered a ping yet have no latency and peers
+	// which have not provided a new block are not protected for it.
+	protect(evictProtectLatency, protectLatency,
+		func(a, b *evictionCandidate) bool {
+			return a.minPingMicros < b.minPingMicros
+		},
+		func(c *evictionCandidate) bool {
+			return c.minPingMicros > 0
+		})
+	protect(evictProtectBlocks, protectBlocks,
+		func(a, b *evictionCandidate) bool {
+			return a.lastNewBlock.After(b.lastNewBlock)
+		},
+		func(c *evictionCandidate) bool {
+			return !c.lastNewBlock.IsZero()
+		})
+	protect(len(remaining)/2, protectLongevity,
+		func(a, b *evictionCandidate) bool {
+			return a.connected.Before(b.connected)
+		},
+		func(c *evictionCandidate) bool {
+			return true
+		})
+
+	return protected, remaining
+}
+
+// selectPeerToEvict returns the candidate to evict out of the passed
+// candidates, or nil when all of them are protected.  The most recently
+// connected candidate of the network group with the most unprotected
+// candidates is evicted, so an attacker connecting from a few network groups
+// evicts its own connections first.
+func selectPeerToEvict(candidates []*evictionCandidate) *evictionCandidate {
+	_, remaining := protectEvictionCandidates(candidates)
+	if len(remaining) == 0 {
+		return nil
+	}
+
+	groups := make(map[string][]*evictionCandidate)
+	for _, c := range remaining {
+		groups[c.group] = append(groups[c.group], c)
+	}
+
+	// Pick the largest group, preferring the group with the most recently
+	// connected candidate when their sizes are the same.
+	var evictGroup []*evictionCandidate
+	var evictYoungest *evictionCandidate
+	for _, group := range groups {
+		youngest := group[0]
+		for _, c := range group[1:] {
+			if c.connected.After(youngest.connected) {
+				youngest = c
+			}
+		}
+		if len(group) > len(evictGroup) ||
+			(len(group) == len(evictGroup) &&
+				youngest.connected.After(evictYoungest.connected)) {
+
+			evictGroup = group
+			evictYoungest = youngest
+		}
+	}
+	return evictYoungest
+}
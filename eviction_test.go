@@ -0,0 +1,83 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestEvictionPolicy ensures inbound peers are protected from eviction by
+// latency, recent block provision and longevity, and the most recently
+// connected peer of the largest network group of the remaining peers is
+// evicted.
+func TestEvictionPolicy(t *testing.T) {
+	t.Parallel()
+
+	// Peers with higher IDs connected more recently.  Peer 0 is
+	// whitelisted, peers 1 to 10 answered pings with increasing ping times
+	// and peers 11 to 15 provided new blocks, where the peers with higher
+	// IDs provided them more recently.
+	base := time.Unix(1500000000, 0)
+	groups := map[int32]string{16: "a", 17: "a", 18: "b", 19: "c"}
+	var candidates []*evictionCandidate
+	for id := int32(0); id < 20; id++ {
+		c := &evictionCandidate{
+			id:          id,
+			whitelisted: id == 0,
+			connected:   base.Add(time.Duration(id) * time.Minute),
+			group:       groups[id],
+		}
+		if id >= 1 && id <= 10 {
+			c.minPingMicros = int64(id) * 100
+		}
+		if id >= 11 && id <= 15 {
+			c.lastNewBlock = base.Add(time.Duration(id) * time.Hour)
+		}
+		candidates = append(candidates, c)
+	}
+
+	protected, remaining := protectEvictionCandidates(candidates)
+	wantProtected := map[int32]string{
+		0:  protectWhitelisted,
+		1:  protectLatency,
+		2:  protectLatency,
+		3:  protectLatency,
+		4:  protectLatency,
+		5:  protectLatency,
+		6:  protectLatency,
+		7:  protectLatency,
+		8:  protectLatency,
+		12: protectBlocks,
+		13: protectBlocks,
+		14: protectBlocks,
+		15: protectBlocks,
+		9:  protectLongevity,
+		10: protectLongevity,
+		11: protectLongevity,
+	}
+	if !reflect.DeepEqual(protected, wantProtected) {
+		t.Fatalf("protectEvictionCandidates: got protected %v, want %v",
+			protected, wantProtected)
+	}
+	if len(remaining) != 4 {
+		t.Fatalf("protectEvictionCandidates: got %d remaining "+
+			"candidates, want 4", len(remaining))
+	}
+
+	evict := selectPeerToEvict(candidates)
+	if evict == nil || evict.id != 17 {
+		t.Fatalf("selectPeerToEvict: got %v, want peer 17", evict)
+	}
+
+	// No peer is evicted when all of them are protected.
+	if evict := selectPeerToEvict(candidates[:9]); evict != nil {
+		t.Fatalf("selectPeerToEvict: got peer %d, want none", evict.id)
+	}
+	if evict := selectPeerToEvict(nil); evict != nil {
+		t.Fatalf("selectPeerToEvict: got peer %d, want none", evict.id)
+	}
+}
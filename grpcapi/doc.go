@@ -0,0 +1,30 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package grpcapi implements a gRPC server which exposes chain queries,
+transaction submission, and streaming notifications of blocks and transactions
+as an alternative to the JSON-RPC and websocket interface.
+
+The service and its messages are defined in node.proto.  Any gRPC client can be
+generated from it, and the provapb package houses the Go stubs generated from it
+and signer.proto.  The server registers the Node service of those stubs with
+grpc-go and requires a TLS configuration.
+
+The queries and submissions are answered by a Backend provided by the caller,
+while the server itself fans the notifications passed to BlockConnected,
+BlockDisconnected and TransactionAccepted out to the subscribed streams.
+Streams of subscribers which do not keep up are ended with the
+RESOURCE_EXHAUSTED status rather than slowing down the node.
+
+The package also provides RemoteSigner, a client of the BlockSigner service
+defined in signer.proto, which signs generated blocks with a validate key held
+by an HSM or a signing service rather than by the node.  It verifies the
+signatures it receives against the public key of the validate key.
+*/
+package grpcapi
+
+// The stubs of the provapb package are generated with protoc, protoc-gen-go and
+// protoc-gen-go-grpc in the path.
+//go:generate protoc --go_out=provapb --go_opt=paths=source_relative --go_opt=Mnode.proto=github.com/bitgo/prova/grpcapi/provapb --go_opt=Msigner.proto=github.com/bitgo/prova/grpcapi/provapb --go-grpc_out=provapb --go-grpc_opt=paths=source_relative --go-grpc_opt=Mnode.proto=github.com/bitgo/prova/grpcapi/provapb --go-grpc_opt=Msigner.proto=github.com/bitgo/prova/grpcapi/provapb node.proto signer.proto
@@ -0,0 +1,16 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package provapb houses the Go stubs generated from node.proto and signer.proto
+of the grpcapi package for grpc-go.
+
+The grpcapi package serves the Node service and calls the BlockSigner service
+with them.  They are also provided for other Go clients of the Node service and
+Go implementations of the BlockSigner service.
+
+The files besides this one are generated by the go:generate directive of the
+grpcapi package and must not be edited.  They require grpc-go v1.64.0 or later.
+*/
+package provapb
@@ -0,0 +1,344 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"sync"
+
+	"github.com/bitgo/prova/grpcapi/provapb"
+	"github.com/bitgo/prova/provautil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxRecvMsgSize is the maximum size of a request message.
+	maxRecvMsgSize = 4 * 1024 * 1024
+
+	// subscriberBufferSize is the number of notifications which are queued
+	// for a subscriber before its stream is ended.
+	subscriberBufferSize = 100
+)
+
+// Backend answers the unary methods of the Node service.  Errors created with
+// the status package of grpc-go are reported to clients with their status
+// code, while other errors are reported with the INTERNAL status.
+type Backend interface {
+	// GetBestBlock returns the block at the tip of the main chain.
+	GetBestBlock(req *provapb.GetBestBlockRequest) (*provapb.BlockInfo, error)
+
+	// GetBlock returns a block of the main chain by hash or height.
+	GetBlock(req *provapb.GetBlockRequest) (*provapb.Block, error)
+
+	// GetTransaction returns a transaction from the memory pool or the main
+	// chain.
+	GetTransaction(req *provapb.GetTransactionRequest) (*provapb.Transaction, error)
+
+	// SendTransaction submits a transaction to the memory pool.
+	SendTransaction(req *provapb.SendTransactionRequest) (*provapb.SendTransactionResponse, error)
+}
+
+// Config houses the configuration of a server.
+type Config struct {
+	// Listeners are the listeners the server accepts connections on.  The
+	// connections are secured with the TLS configuration.
+	Listeners []net.Listener
+
+	// TLSConfig is the TLS configuration of the server, which must provide
+	// a certificate.
+	TLSConfig *tls.Config
+
+	// Backend answers the unary methods.
+	Backend Backend
+
+	// Authenticate reports whether the call with the passed context carries
+	// valid credentials in its metadata.  All calls are accepted when it is
+	// nil.
+	Authenticate func(ctx context.Context) bool
+}
+
+// subscriber is a stream subscribed to notifications.
+type subscriber struct {
+	includeRaw bool
+	msgs       chan interface{}
+
+	// overflow is closed once a notification is dropped because the queue
+	// of the subscriber is full.
+	overflow chan struct{}
+	dropped  bool
+}
+
+// Server is a gRPC server for the Node service.
+type Server struct {
+	cfg        Config
+	grpcServer *grpc.Server
+	wg         sync.WaitGroup
+	quit       chan struct{}
+
+	mtx       sync.Mutex
+	blockSubs map[*subscriber]struct{}
+	txSubs    map[*subscriber]struct{}
+}
+
+// nodeServer implements the Node service generated from node.proto on top of
+// a server.
+type nodeServer struct {
+	provapb.UnimplementedNodeServer
+	s *Server
+}
+
+// Ensure nodeServer implements the provapb.NodeServer interface.
+var _ provapb.NodeServer = (*nodeServer)(nil)
+
+// New returns a new server with the passed configuration.  It does not serve
+// requests until Start is called.
+func New(cfg *Config) *Server {
+	s := &Server{
+		cfg:       *cfg,
+		quit:      make(chan struct{}),
+		blockSubs: make(map[*subscriber]struct{}),
+		txSubs:    make(map[*subscriber]struct{}),
+	}
+	opts := []grpc.ServerOption{
+		grpc.MaxRecvMsgSize(maxRecvMsgSize),
+		grpc.UnaryInterceptor(s.interceptUnary),
+		grpc.StreamInterceptor(s.interceptStream),
+	}
+	if cfg.TLSConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg.TLSConfig)))
+	}
+	s.grpcServer = grpc.NewServer(opts...)
+	provapb.RegisterNodeServer(s.grpcServer, &nodeServer{s: s})
+	return s
+}
+
+// Start starts serving requests on the listeners of the server.
+func (s *Server) Start() {
+	for _, listener := range s.cfg.Listeners {
+		s.wg.Add(1)
+		go func(listener net.Listener) {
+			log.Infof("gRPC server listening on %s", listener.Addr())
+			if err := s.grpcServer.Serve(listener); err != nil {
+				log.Errorf("gRPC server on %s failed: %v",
+					listener.Addr(), err)
+			}
+			s.wg.Done()
+		}(listener)
+	}
+}
+
+// Stop ends the streams and closes the listeners and connections of the
+// server once the pending unary calls are answered.
+func (s *Server) Stop() {
+	close(s.quit)
+	s.grpcServer.GracefulStop()
+	s.wg.Wait()
+}
+
+// authenticate returns an error with the UNAUTHENTICATED status unless the call
+// with the passed context carries valid credentials.
+func (s *Server) authenticate(ctx context.Context) error {
+	if s.cfg.Authenticate == nil || s.cfg.Authenticate(ctx) {
+		return nil
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		log.Warnf("gRPC authentication failure from %s", p.Addr)
+	}
+	return status.Error(codes.Unauthenticated, "invalid credentials")
+}
+
+// interceptUnary authenticates unary calls before they are handled and
+// reports the errors which do not carry a status with the INTERNAL status.
+func (s *Server) interceptUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := s.authenticate(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := handler(ctx, req)
+	if err != nil {
+		if _, ok := status.FromError(err); !ok {
+			err = status.Error(codes.Internal, err.Error())
+		}
+		return nil, err
+	}
+	return resp, nil
+}
+
+// interceptStream authenticates streaming calls before they are handled.
+func (s *Server) interceptStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := s.authenticate(stream.Context()); err != nil {
+		return err
+	}
+	return handler(srv, stream)
+}
+
+// GetBestBlock returns the block at the tip of the main chain.
+func (n *nodeServer) GetBestBlock(ctx context.Context, req *provapb.GetBestBlockRequest) (*provapb.BlockInfo, error) {
+	return n.s.cfg.Backend.GetBestBlock(req)
+}
+
+// GetBlock returns a block of the main chain by hash or height.
+func (n *nodeServer) GetBlock(ctx context.Context, req *provapb.GetBlockRequest) (*provapb.Block, error) {
+	return n.s.cfg.Backend.GetBlock(req)
+}
+
+// GetTransaction returns a transaction from the memory pool or the main chain.
+func (n *nodeServer) GetTransaction(ctx context.Context, req *provapb.GetTransactionRequest) (*provapb.Transaction, error) {
+	return n.s.cfg.Backend.GetTransaction(req)
+}
+
+// SendTransaction submits a transaction to the memory pool.
+func (n *nodeServer) SendTransaction(ctx context.Context, req *provapb.SendTransactionRequest) (*provapb.SendTransactionResponse, error) {
+	return n.s.cfg.Backend.SendTransaction(req)
+}
+
+// SubscribeBlocks streams a notification for each block connected to or
+// disconnected from the main chain.
+func (n *nodeServer) SubscribeBlocks(req *provapb.SubscribeBlocksRequest, stream provapb.Node_SubscribeBlocksServer) error {
+	return n.s.subscribe(stream, n.s.blockSubs, req.IncludeRaw)
+}
+
+// SubscribeTransactions streams a notification for each transaction accepted
+// to the memory pool.
+func (n *nodeServer) SubscribeTransactions(req *provapb.SubscribeTransactionsRequest, stream provapb.Node_SubscribeTransactionsServer) error {
+	return n.s.subscribe(stream, n.s.txSubs, req.IncludeRaw)
+}
+
+// subscribe adds a subscriber to the passed set of subscribers and sends the
+// notifications queued for it on the passed stream until the client goes away,
+// the subscriber falls behind, or the server is stopped.
+func (s *Server) subscribe(stream grpc.ServerStream, subs map[*subscriber]struct{}, includeRaw bool) error {
+	sub := &subscriber{
+		includeRaw: includeRaw,
+		msgs:       make(chan interface{}, subscriberBufferSize),
+		overflow:   make(chan struct{}),
+	}
+	s.mtx.Lock()
+	subs[sub] = struct{}{}
+	s.mtx.Unlock()
+	defer func() {
+		s.mtx.Lock()
+		delete(subs, sub)
+		s.mtx.Unlock()
+	}()
+
+	// Send the headers right away so the client knows the subscription
+	// is in place.
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+	for {
+		select {
+		case msg := <-sub.msgs:
+			if err := stream.SendMsg(msg); err != nil {
+				return err
+			}
+
+		case <-sub.overflow:
+			return status.Error(codes.ResourceExhausted, "subscriber "+
+				"does not keep up with the notifications")
+
+		case <-stream.Context().Done():
+			return nil
+
+		case <-s.quit:
+			return status.Error(codes.Unavailable, "server is "+
+				"shutting down")
+		}
+	}
+}
+
+// publish queues the notification returned by the passed function for each of
+// the passed subscribers.  The function is invoked at most once for each value
+// of includeRaw.
+func (s *Server) publish(subs map[*subscriber]struct{}, notification func(includeRaw bool) interface{}) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	var msgs [2]interface{}
+	for sub := range subs {
+		if sub.dropped {
+			continue
+		}
+		i := 0
+		if sub.includeRaw {
+			i = 1
+		}
+		if msgs[i] == nil {
+			msgs[i] = notification(sub.includeRaw)
+		}
+		select {
+		case sub.msgs <- msgs[i]:
+		default:
+			sub.dropped = true
+			close(sub.overflow)
+		}
+	}
+}
+
+// notifyBlock queues a notification of the passed block for the block
+// subscribers.
+func (s *Server) notifyBlock(block *provautil.Block, disconnected bool) {
+	s.publish(s.blockSubs, func(includeRaw bool) interface{} {
+		hash := *block.Hash()
+		n := &provapb.BlockNotification{
+			Hash:         hash[:],
+			Height:       block.Height(),
+			Disconnected: disconnected,
+		}
+		if includeRaw {
+			raw, err := block.Bytes()
+			if err != nil {
+				log.Errorf("Unable to serialize block %v: %v",
+					block.Hash(), err)
+			}
+			n.RawBlock = raw
+		}
+		return n
+	})
+}
+
+// BlockConnected notifies the block subscribers of the passed block, which was
+// connected to the main chain.
+//
+// This function is safe for concurrent access.
+func (s *Server) BlockConnected(block *provautil.Block) {
+	s.notifyBlock(block, false)
+}
+
+// BlockDisconnected notifies the block subscribers of the passed block, which
+// was disconnected from the main chain.
+//
+// This function is safe for concurrent access.
+func (s *Server) BlockDisconnected(block *provautil.Block) {
+	s.notifyBlock(block, true)
+}
+
+// TransactionAccepted notifies the transaction subscribers of the passed
+// transaction, which was accepted to the memory pool.
+//
+// This function is safe for concurrent access.
+func (s *Server) TransactionAccepted(tx *provautil.Tx) {
+	s.publish(s.txSubs, func(includeRaw bool) interface{} {
+		hash := *tx.Hash()
+		n := &provapb.TransactionNotification{Txid: hash[:]}
+		if includeRaw {
+			buf := bytes.NewBuffer(make([]byte, 0,
+				tx.MsgTx().SerializeSize()))
+			if err := tx.MsgTx().Serialize(buf); err != nil {
+				log.Errorf("Unable to serialize transaction "+
+					"%v: %v", tx.Hash(), err)
+			}
+			n.RawTx = buf.Bytes()
+		}
+		return n
+	})
+}
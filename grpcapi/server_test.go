@@ -0,0 +1,297 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/grpcapi/provapb"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testBackend is a backend which answers with fixed values.
+type testBackend struct{}
+
+func (testBackend) GetBestBlock(req *provapb.GetBestBlockRequest) (*provapb.BlockInfo, error) {
+	return &provapb.BlockInfo{Hash: []byte{1}, Height: 5, Time: 1000}, nil
+}
+
+func (testBackend) GetBlock(req *provapb.GetBlockRequest) (*provapb.Block, error) {
+	if req.Height > 5 {
+		return nil, status.Errorf(codes.NotFound, "no block at height "+
+			"%d", req.Height)
+	}
+	return &provapb.Block{
+		Height:        req.Height,
+		Confirmations: 6 - req.Height,
+	}, nil
+}
+
+func (testBackend) GetTransaction(req *provapb.GetTransactionRequest) (*provapb.Transaction, error) {
+	return nil, io.ErrUnexpectedEOF
+}
+
+func (testBackend) SendTransaction(req *provapb.SendTransactionRequest) (*provapb.SendTransactionResponse, error) {
+	return &provapb.SendTransactionResponse{Txid: req.RawTx}, nil
+}
+
+// newTestTLSConfigs returns the TLS configuration of a server with a new
+// self-signed certificate and the TLS configuration of clients trusting it.
+func newTestTLSConfigs(t *testing.T) (*tls.Config, *tls.Config) {
+	certPEM, keyPEM, err := provautil.NewTLSCertPair("prova test",
+		time.Now().Add(time.Hour), nil)
+	if err != nil {
+		t.Fatalf("unable to create certificate: %v", err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("unable to load certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+	return &tls.Config{Certificates: []tls.Certificate{cert}},
+		&tls.Config{RootCAs: roots}
+}
+
+// newTestServer starts a server with the test backend on a local listener and
+// returns it along with a client connected to it.  Calls with the "invalid"
+// authorization are rejected.
+func newTestServer(t *testing.T) (*Server, *grpc.ClientConn) {
+	serverTLS, clientTLS := newTestTLSConfigs(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	s := New(&Config{
+		Listeners: []net.Listener{listener},
+		TLSConfig: serverTLS,
+		Backend:   testBackend{},
+		Authenticate: func(ctx context.Context) bool {
+			md, _ := metadata.FromIncomingContext(ctx)
+			auth := md.Get("authorization")
+			return len(auth) == 0 || auth[0] != "invalid"
+		},
+	})
+	s.Start()
+
+	conn, err := grpc.NewClient(listener.Addr().String(),
+		grpc.WithTransportCredentials(credentials.NewTLS(clientTLS)))
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+	return s, conn
+}
+
+// waitForSubscribers waits until the passed number of subscribers are
+// registered with the server.
+func waitForSubscribers(t *testing.T, s *Server, subs map[*subscriber]struct{}, n int) {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		s.mtx.Lock()
+		numSubs := len(subs)
+		s.mtx.Unlock()
+		if numSubs == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d subscribers, want %d", numSubs, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestUnaryCalls ensures unary calls are answered by the backend and errors
+// are reported with their status.
+func TestUnaryCalls(t *testing.T) {
+	s, conn := newTestServer(t)
+	defer s.Stop()
+	defer conn.Close()
+	client := provapb.NewNodeClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	best, err := client.GetBestBlock(ctx, &provapb.GetBestBlockRequest{})
+	if err != nil {
+		t.Fatalf("GetBestBlock: unexpected error: %v", err)
+	}
+	if !bytes.Equal(best.Hash, []byte{1}) || best.Height != 5 ||
+		best.Time != 1000 {
+
+		t.Errorf("GetBestBlock: unexpected block %v", best)
+	}
+	block, err := client.GetBlock(ctx, &provapb.GetBlockRequest{Height: 2})
+	if err != nil {
+		t.Fatalf("GetBlock: unexpected error: %v", err)
+	}
+	if block.Height != 2 || block.Confirmations != 4 {
+		t.Errorf("GetBlock: unexpected block %v", block)
+	}
+	rawTx := bytes.Repeat([]byte{0xab}, 300)
+	sent, err := client.SendTransaction(ctx,
+		&provapb.SendTransactionRequest{RawTx: rawTx})
+	if err != nil {
+		t.Fatalf("SendTransaction: unexpected error: %v", err)
+	}
+	if !bytes.Equal(sent.Txid, rawTx) {
+		t.Errorf("SendTransaction: unexpected txid %x", sent.Txid)
+	}
+
+	invalidCtx := metadata.AppendToOutgoingContext(ctx, "authorization",
+		"invalid")
+	tests := []struct {
+		name string
+		call func() error
+		code codes.Code
+		msg  string
+	}{
+		{"GetBlock", func() error {
+			_, err := client.GetBlock(ctx,
+				&provapb.GetBlockRequest{Height: 6})
+			return err
+		}, codes.NotFound, "no block at height 6"},
+		{"GetTransaction", func() error {
+			_, err := client.GetTransaction(ctx,
+				&provapb.GetTransactionRequest{})
+			return err
+		}, codes.Internal, "unexpected EOF"},
+		{"GetBestBlock", func() error {
+			_, err := client.GetBestBlock(invalidCtx,
+				&provapb.GetBestBlockRequest{})
+			return err
+		}, codes.Unauthenticated, "invalid credentials"},
+		{"SubscribeBlocks", func() error {
+			stream, err := client.SubscribeBlocks(invalidCtx,
+				&provapb.SubscribeBlocksRequest{})
+			if err != nil {
+				return err
+			}
+			_, err = stream.Recv()
+			return err
+		}, codes.Unauthenticated, "invalid credentials"},
+	}
+	for _, test := range tests {
+		st := status.Convert(test.call())
+		if st.Code() != test.code || st.Message() != test.msg {
+			t.Errorf("%s: unexpected status - got %v %q, want %v %q",
+				test.name, st.Code(), st.Message(), test.code,
+				test.msg)
+		}
+	}
+}
+
+// TestSubscriptions ensures notifications are streamed to the subscribers and
+// the streams end when the server stops.
+func TestSubscriptions(t *testing.T) {
+	s, conn := newTestServer(t)
+	defer conn.Close()
+	client := provapb.NewNodeClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	blocks, err := client.SubscribeBlocks(ctx,
+		&provapb.SubscribeBlocksRequest{IncludeRaw: true})
+	if err != nil {
+		t.Fatalf("SubscribeBlocks: unexpected error: %v", err)
+	}
+	txns, err := client.SubscribeTransactions(ctx,
+		&provapb.SubscribeTransactionsRequest{})
+	if err != nil {
+		t.Fatalf("SubscribeTransactions: unexpected error: %v", err)
+	}
+	waitForSubscribers(t, s, s.blockSubs, 1)
+	waitForSubscribers(t, s, s.txSubs, 1)
+
+	msgBlock := wire.MsgBlock{Header: wire.BlockHeader{Version: 1}}
+	block := provautil.NewBlock(&msgBlock)
+	block.SetHeight(42)
+	s.BlockConnected(block)
+	s.BlockDisconnected(block)
+	raw, err := block.Bytes()
+	if err != nil {
+		t.Fatalf("unable to serialize block: %v", err)
+	}
+	for _, disconnected := range []bool{false, true} {
+		n, err := blocks.Recv()
+		if err != nil {
+			t.Fatalf("SubscribeBlocks: unexpected error: %v", err)
+		}
+		if !bytes.Equal(n.Hash, block.Hash()[:]) || n.Height != 42 ||
+			!bytes.Equal(n.RawBlock, raw) ||
+			n.Disconnected != disconnected {
+
+			t.Errorf("SubscribeBlocks: unexpected notification %v",
+				n)
+		}
+	}
+
+	tx := provautil.NewTx(wire.NewMsgTx(1))
+	s.TransactionAccepted(tx)
+	n, err := txns.Recv()
+	if err != nil {
+		t.Fatalf("SubscribeTransactions: unexpected error: %v", err)
+	}
+	if !bytes.Equal(n.Txid, tx.Hash()[:]) || len(n.RawTx) != 0 {
+		t.Errorf("SubscribeTransactions: unexpected notification %v",
+			n)
+	}
+
+	// Ensure the streams end with the unavailable status when the server
+	// stops.
+	done := make(chan struct{})
+	go func() {
+		s.Stop()
+		close(done)
+	}()
+	if _, err := blocks.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("SubscribeBlocks: unexpected error after stop %v", err)
+	}
+	if _, err := txns.Recv(); status.Code(err) != codes.Unavailable {
+		t.Errorf("SubscribeTransactions: unexpected error after stop "+
+			"%v", err)
+	}
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("server did not stop")
+	}
+}
+
+// TestSlowSubscriber ensures a subscriber which does not keep up is dropped
+// without blocking the notifications.
+func TestSlowSubscriber(t *testing.T) {
+	s := New(&Config{})
+	sub := &subscriber{
+		msgs:     make(chan interface{}, subscriberBufferSize),
+		overflow: make(chan struct{}),
+	}
+	s.txSubs[sub] = struct{}{}
+
+	tx := provautil.NewTx(wire.NewMsgTx(1))
+	for i := 0; i <= subscriberBufferSize; i++ {
+		s.TransactionAccepted(tx)
+	}
+	select {
+	case <-sub.overflow:
+	default:
+		t.Fatal("subscriber was not dropped")
+	}
+	if len(sub.msgs) != subscriberBufferSize {
+		t.Errorf("unexpected number of queued notifications %d",
+			len(sub.msgs))
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcapi
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/grpcapi/provapb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// signTimeout is the maximum amount of time a remote signer is given to answer
+// a request.
+const signTimeout = 10 * time.Second
+
+// SignerConfig houses the configuration of a remote signer.
+type SignerConfig struct {
+	// Address is the host:port of the signing service.
+	Address string
+
+	// PubKey is the public key of the validate key held by the signing
+	// service.
+	PubKey *btcec.PublicKey
+
+	// TLSConfig is the TLS configuration used to connect to the signing
+	// service.
+	TLSConfig *tls.Config
+
+	// User and Pass are the credentials sent to the signing service with
+	// basic authentication.  No credentials are sent when User is empty.
+	User string
+	Pass string
+}
+
+// basicAuth sends credentials with basic authentication in the metadata of
+// calls.  It satisfies the credentials.PerRPCCredentials interface.
+type basicAuth struct {
+	user string
+	pass string
+}
+
+// GetRequestMetadata returns the authorization metadata of the credentials.
+func (a *basicAuth) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	auth := base64.StdEncoding.EncodeToString([]byte(a.user + ":" + a.pass))
+	return map[string]string{"authorization": "Basic " + auth}, nil
+}
+
+// RequireTransportSecurity returns true since the credentials must not be sent
+// in plain text.
+func (a *basicAuth) RequireTransportSecurity() bool {
+	return true
+}
+
+// RemoteSigner signs block headers by calling the SignBlock method of a
+// BlockSigner service, which is defined in signer.proto, so the validate key
+// can be held by an HSM or a signing service instead of the node.  It
+// satisfies the wire.BlockSigner interface.
+type RemoteSigner struct {
+	cfg    SignerConfig
+	conn   *grpc.ClientConn
+	client provapb.BlockSignerClient
+}
+
+// NewRemoteSigner returns a new remote signer with the passed configuration.
+// The connection to the signing service is established when it is first used.
+func NewRemoteSigner(cfg *SignerConfig) (*RemoteSigner, error) {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(credentials.NewTLS(cfg.TLSConfig)),
+	}
+	if cfg.User != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(&basicAuth{
+			user: cfg.User,
+			pass: cfg.Pass,
+		}))
+	}
+	conn, err := grpc.NewClient(cfg.Address, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &RemoteSigner{
+		cfg:    *cfg,
+		conn:   conn,
+		client: provapb.NewBlockSignerClient(conn),
+	}, nil
+}
+
+// PubKey returns the public key of the validate key held by the signing
+// service.
+func (s *RemoteSigner) PubKey() *btcec.PublicKey {
+	return s.cfg.PubKey
+}
+
+// Sign has the signing service sign the passed hash.  The returned signature
+// is verified against the public key of the signer, so a misbehaving service
+// is unable to cause invalid blocks to be generated.
+func (s *RemoteSigner) Sign(hash []byte) (*btcec.Signature, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+	resp, err := s.client.SignBlock(ctx, &provapb.SignBlockRequest{
+		PubKey: s.cfg.PubKey.SerializeCompressed(),
+		Hash:   hash,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("signer %s: %v", s.cfg.Address, err)
+	}
+
+	sig, err := btcec.ParseDERSignature(resp.Signature, btcec.S256())
+	if err != nil {
+		return nil, fmt.Errorf("signer %s returned a malformed "+
+			"signature: %v", s.cfg.Address, err)
+	}
+	if !sig.Verify(hash, s.cfg.PubKey) {
+		return nil, fmt.Errorf("signer %s returned an invalid "+
+			"signature", s.cfg.Address)
+	}
+	return sig, nil
+}
+
+// Close closes the connection to the signing service.
+func (s *RemoteSigner) Close() error {
+	return s.conn.Close()
+}
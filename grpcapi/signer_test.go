@@ -0,0 +1,122 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package grpcapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/grpcapi/provapb"
+	"github.com/bitgo/prova/wire"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// testSigner is a BlockSigner service which signs with a fixed key.
+type testSigner struct {
+	provapb.UnimplementedBlockSignerServer
+	key *btcec.PrivateKey
+}
+
+// SignBlock signs the requested hash.  Calls with the wrong credentials or for
+// another key are failed.
+func (s *testSigner) SignBlock(ctx context.Context, req *provapb.SignBlockRequest) (*provapb.SignBlockResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass"))
+	if values := md.Get("authorization"); len(values) != 1 ||
+		values[0] != auth {
+
+		return nil, status.Error(codes.Unauthenticated,
+			"invalid credentials")
+	}
+	if !bytes.Equal(req.PubKey, s.key.PubKey().SerializeCompressed()) {
+		return nil, status.Errorf(codes.NotFound, "unknown key %x",
+			req.PubKey)
+	}
+	sig, err := s.key.Sign(req.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return &provapb.SignBlockResponse{Signature: sig.Serialize()}, nil
+}
+
+// TestRemoteSigner ensures block headers signed by a remote signer verify
+// against its key and that failures of the signing service are reported.
+func TestRemoteSigner(t *testing.T) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("unable to create key: %v", err)
+	}
+	serverTLS, clientTLS := newTestTLSConfigs(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	server := grpc.NewServer(grpc.Creds(credentials.NewTLS(serverTLS)))
+	provapb.RegisterBlockSignerServer(server, &testSigner{key: key})
+	go server.Serve(listener)
+	defer server.Stop()
+
+	newSigner := func(pubKey *btcec.PublicKey, user string) *RemoteSigner {
+		signer, err := NewRemoteSigner(&SignerConfig{
+			Address:   listener.Addr().String(),
+			PubKey:    pubKey,
+			TLSConfig: clientTLS,
+			User:      user,
+			Pass:      "pass",
+		})
+		if err != nil {
+			t.Fatalf("NewRemoteSigner: unexpected error: %v", err)
+		}
+		return signer
+	}
+
+	// Ensure a header signed by the remote signer is marked with its key
+	// and verifies.
+	signer := newSigner(key.PubKey(), "user")
+	defer signer.Close()
+	header := wire.NewBlockHeader(&chainhash.Hash{1}, &chainhash.Hash{2},
+		0x207fffff, 0)
+	if err := header.Sign(signer); err != nil {
+		t.Fatalf("Sign: unexpected error: %v", err)
+	}
+	if !bytes.Equal(header.ValidatingPubKey[:],
+		key.PubKey().SerializeCompressed()) {
+
+		t.Errorf("Sign: header is marked with key %v", header.ValidatingPubKey)
+	}
+	if !header.Verify(key.PubKey()) {
+		t.Error("Sign: signature does not verify")
+	}
+
+	// Ensure the status of failed calls is reported.
+	hash := chainhash.HashB([]byte("block"))
+	otherUser := newSigner(key.PubKey(), "other")
+	defer otherUser.Close()
+	_, err = otherUser.Sign(hash)
+	if err == nil || !strings.Contains(err.Error(), "invalid credentials") {
+		t.Errorf("Sign: unexpected error with wrong credentials: %v",
+			err)
+	}
+	otherKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("unable to create key: %v", err)
+	}
+	otherSigner := newSigner(otherKey.PubKey(), "user")
+	defer otherSigner.Close()
+	_, err = otherSigner.Sign(hash)
+	if err == nil || !strings.Contains(err.Error(), "unknown key") {
+		t.Errorf("Sign: unexpected error for unknown key: %v", err)
+	}
+}
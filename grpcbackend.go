@@ -0,0 +1,207 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"net"
+	"net/http"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/grpcapi"
+	"github.com/bitgo/prova/grpcapi/provapb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	grpcpeer "google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// grpcBackend answers the unary methods of the gRPC server with the handlers
+// of the RPC server so both interfaces behave the same.
+type grpcBackend struct {
+	s *rpcServer
+}
+
+// Ensure grpcBackend implements the grpcapi.Backend interface.
+var _ grpcapi.Backend = (*grpcBackend)(nil)
+
+// grpcRPCError converts the passed error returned by an RPC handler to an
+// error with the matching gRPC status code.
+func grpcRPCError(err error) error {
+	rpcErr, ok := err.(*btcjson.RPCError)
+	if !ok {
+		return err
+	}
+	switch rpcErr.Code {
+	case btcjson.ErrRPCBlockNotFound: // Same code as ErrRPCNoTxInfo.
+		return status.Errorf(codes.NotFound, "%s", rpcErr.Message)
+	case btcjson.ErrRPCInvalidParameter,
+		btcjson.ErrRPCDecodeHexString: // Same code as ErrRPCDeserialization.
+
+		return status.Errorf(codes.InvalidArgument, "%s",
+			rpcErr.Message)
+	}
+	return status.Errorf(codes.Internal, "%s", rpcErr.Message)
+}
+
+// grpcHash converts the passed hash of a request to a chainhash.Hash.
+func grpcHash(b []byte) (*chainhash.Hash, error) {
+	hash, err := chainhash.NewHash(b)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v",
+			err)
+	}
+	return hash, nil
+}
+
+// GetBestBlock returns the block at the tip of the main chain.
+func (b *grpcBackend) GetBestBlock(req *provapb.GetBestBlockRequest) (*provapb.BlockInfo, error) {
+	best := b.s.chain.BestSnapshot()
+	header, err := b.s.chain.FetchHeader(best.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return &provapb.BlockInfo{
+		Hash:   best.Hash[:],
+		Height: best.Height,
+		Time:   header.Timestamp.Unix(),
+	}, nil
+}
+
+// GetBlock returns a block of the main chain by hash or, when the hash is
+// empty, by height.
+func (b *grpcBackend) GetBlock(req *provapb.GetBlockRequest) (*provapb.Block, error) {
+	var hash *chainhash.Hash
+	var err error
+	if len(req.Hash) == 0 {
+		hash, err = b.s.chain.BlockHashByHeight(req.Height)
+		if err != nil {
+			return nil, status.Errorf(codes.NotFound,
+				"no block at height %d", req.Height)
+		}
+	} else if hash, err = grpcHash(req.Hash); err != nil {
+		return nil, err
+	}
+
+	// Blocks which are not in the main chain are not found.
+	height, err := b.s.chain.BlockHeightByHash(hash)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound,
+			"block %v not found", hash)
+	}
+	var blkBytes []byte
+	err = b.s.server.db.View(func(dbTx database.Tx) error {
+		var err error
+		blkBytes, err = dbTx.FetchBlock(hash)
+		return err
+	})
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound,
+			"block %v not found", hash)
+	}
+
+	best := b.s.chain.BestSnapshot()
+	return &provapb.Block{
+		Hash:          hash[:],
+		Height:        height,
+		Confirmations: best.Height - height + 1,
+		RawBlock:      blkBytes,
+	}, nil
+}
+
+// GetTransaction returns a transaction from the memory pool or, when the
+// transaction index is enabled, from the main chain.
+func (b *grpcBackend) GetTransaction(req *provapb.GetTransactionRequest) (*provapb.Transaction, error) {
+	txHash, err := grpcHash(req.Txid)
+	if err != nil {
+		return nil, err
+	}
+	verbose := 1
+	result, err := handleGetRawTransaction(b.s, &btcjson.GetRawTransactionCmd{
+		Txid:    txHash.String(),
+		Verbose: &verbose,
+	}, nil)
+	if err != nil {
+		return nil, grpcRPCError(err)
+	}
+	rawTxn := result.(btcjson.TxRawResult)
+	rawTx, err := hex.DecodeString(rawTxn.Hex)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &provapb.Transaction{
+		Txid:          txHash[:],
+		RawTx:         rawTx,
+		Confirmations: uint32(rawTxn.Confirmations),
+	}
+	if rawTxn.BlockHash != "" {
+		blkHash, err := chainhash.NewHashFromStr(rawTxn.BlockHash)
+		if err != nil {
+			return nil, err
+		}
+		tx.BlockHash = blkHash[:]
+		tx.BlockHeight, err = b.s.chain.BlockHeightByHash(blkHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+// SendTransaction submits a transaction to the memory pool and relays it to
+// the network.
+func (b *grpcBackend) SendTransaction(req *provapb.SendTransactionRequest) (*provapb.SendTransactionResponse, error) {
+	result, err := handleSendRawTransaction(b.s, &btcjson.SendRawTransactionCmd{
+		HexTx: hex.EncodeToString(req.RawTx),
+	}, nil)
+	if err != nil {
+		return nil, grpcRPCError(err)
+	}
+	txHash, err := chainhash.NewHashFromStr(result.(string))
+	if err != nil {
+		return nil, err
+	}
+	return &provapb.SendTransactionResponse{Txid: txHash[:]}, nil
+}
+
+// newGRPCServer returns a gRPC server listening on the passed address.  It
+// uses the certificate and the credentials of the passed RPC server.
+func newGRPCServer(listenAddr string, s *rpcServer) (*grpcapi.Server, error) {
+	keypair, err := tls.LoadX509KeyPair(cfg.RPCCert, cfg.RPCKey)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	return grpcapi.New(&grpcapi.Config{
+		Listeners: []net.Listener{listener},
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{keypair},
+			MinVersion:   tls.VersionTLS12,
+		},
+		Backend: &grpcBackend{s: s},
+		Authenticate: func(ctx context.Context) bool {
+			// Check the credentials in the metadata of the call
+			// the same way as those of RPC requests.
+			md, _ := metadata.FromIncomingContext(ctx)
+			r := &http.Request{Header: http.Header{
+				"Authorization": md.Get("authorization"),
+			}}
+			if p, ok := grpcpeer.FromContext(ctx); ok {
+				r.RemoteAddr = p.Addr.String()
+			}
+			ok, _, _ := s.checkAuth(r, true)
+			return ok
+		},
+	}), nil
+}
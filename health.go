@@ -0,0 +1,138 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/wire"
+)
+
+// healthStatus houses the health of the node as reported by the /health
+// endpoint.  The node is healthy when its database is readable, it is synced
+// to the best chain known by its peers, and it is connected to at least one
+// peer.  The peer event counts do not affect the health, but let monitoring
+// spot peers churning or misbehaving.
+type healthStatus struct {
+	Healthy      bool            `json:"healthy"`
+	Synced       bool            `json:"synced"`
+	BestHeight   uint32          `json:"bestheight"`
+	BestHash     string          `json:"besthash"`
+	LastBlockAge int64           `json:"lastblockage"`
+	Peers        int32           `json:"peers"`
+	PeerEvents   peerEventCounts `json:"peerevents"`
+	Database     string          `json:"database"`
+}
+
+// healthStatus returns the current health of the node.  The database is checked
+// by loading the header of the best block, which also provides the age of the
+// last block in seconds.
+func (s *server) healthStatus() *healthStatus {
+	best := s.blockManager.chain.BestSnapshot()
+	status := &healthStatus{
+		Synced:     s.blockManager.IsCurrent(),
+		BestHeight: best.Height,
+		BestHash:   best.Hash.String(),
+		Peers:      s.ConnectedCount(),
+		PeerEvents: s.peerEventCounters.snapshot(),
+		Database:   "ok",
+	}
+
+	var header wire.BlockHeader
+	err := s.db.View(func(dbTx database.Tx) error {
+		serializedHeader, err := dbTx.FetchBlockHeader(best.Hash)
+		if err != nil {
+			return err
+		}
+		return header.Deserialize(bytes.NewReader(serializedHeader))
+	})
+	if err != nil {
+		status.Database = err.Error()
+	} else {
+		status.LastBlockAge = int64(time.Since(header.Timestamp) /
+			time.Second)
+	}
+
+	status.Healthy = err == nil && status.Synced && status.Peers > 0
+	return status
+}
+
+// newHealthHandler returns an HTTP handler which serves the /health endpoint
+// with the status returned by the passed function.  The status is written as a
+// JSON object with the 200 status code when the node is healthy and the 503
+// status code otherwise, so load balancers and orchestrators can act on the
+// status code alone.
+func newHealthHandler(status func() *healthStatus) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "405 Method not allowed.",
+				http.StatusMethodNotAllowed)
+			return
+		}
+
+		st := status()
+		body, err := json.Marshal(st)
+		if err != nil {
+			errStr := fmt.Sprintf("500 Unable to marshal health "+
+				"status: %v", err)
+			http.Error(w, errStr, http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		if st.Healthy {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write(append(body, '\n'))
+	})
+	return mux
+}
+
+// watchdogHandler sends watchdog notifications to the service manager at half
+// the passed interval as long as the node is responsive and its database is
+// readable, so the service manager restarts the node when it hangs.  Being out
+// of sync or without peers does not stop the notifications since restarting
+// does not help in those cases.  It must be run as a goroutine.
+func (s *server) watchdogHandler(interval time.Duration) {
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+
+out:
+	for {
+		select {
+		case <-ticker.C:
+			status := s.healthStatus()
+			if status.Database != "ok" {
+				srvrLog.Warnf("Withholding watchdog notification "+
+					"due to database failure: %v",
+					status.Database)
+				continue
+			}
+
+			state := fmt.Sprintf("WATCHDOG=1\nSTATUS=Height %d, "+
+				"%d peers, synced: %v", status.BestHeight,
+				status.Peers, status.Synced)
+			if _, err := sdNotify(state); err != nil {
+				srvrLog.Warnf("Unable to notify service manager: "+
+					"%v", err)
+			}
+
+		case <-s.quit:
+			break out
+		}
+	}
+
+	s.wg.Done()
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestHealthHandler ensures the health endpoint reports the status with the
+// status code matching the health of the node.
+func TestHealthHandler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		method     string
+		status     healthStatus
+		wantStatus int
+	}{
+		{
+			name:   "healthy",
+			method: "GET",
+			status: healthStatus{
+				Healthy:    true,
+				Synced:     true,
+				BestHeight: 10,
+				Peers:      3,
+				Database:   "ok",
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:   "not synced",
+			method: "GET",
+			status: healthStatus{
+				BestHeight: 5,
+				Peers:      3,
+				Database:   "ok",
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:       "wrong method",
+			method:     "POST",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, test := range tests {
+		status := test.status
+		handler := newHealthHandler(func() *healthStatus {
+			return &status
+		})
+		req := httptest.NewRequest(test.method, "/health", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != test.wantStatus {
+			t.Errorf("%s: unexpected status code - got %d, want %d",
+				test.name, rec.Code, test.wantStatus)
+			continue
+		}
+		if test.wantStatus == http.StatusMethodNotAllowed {
+			continue
+		}
+
+		var got healthStatus
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Errorf("%s: malformed body: %v", test.name, err)
+			continue
+		}
+		if got != test.status {
+			t.Errorf("%s: unexpected status - got %+v, want %+v",
+				test.name, got, test.status)
+		}
+	}
+}
+
+// TestSdNotify ensures states are sent to the socket of the service manager
+// and nothing is sent when there is no service manager.
+func TestSdNotify(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "prova")
+	if err != nil {
+		t.Fatalf("Failed creating a temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	socketPath := filepath.Join(tmpDir, "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{
+		Name: socketPath,
+		Net:  "unixgram",
+	})
+	if err != nil {
+		t.Skipf("unixgram sockets are not supported: %v", err)
+	}
+	defer conn.Close()
+
+	defer os.Unsetenv("NOTIFY_SOCKET")
+	os.Unsetenv("NOTIFY_SOCKET")
+	sent, err := sdNotify("READY=1")
+	if sent || err != nil {
+		t.Fatalf("sdNotify: unexpected result without socket: %v, %v",
+			sent, err)
+	}
+
+	os.Setenv("NOTIFY_SOCKET", socketPath)
+	sent, err = sdNotify("READY=1")
+	if !sent || err != nil {
+		t.Fatalf("sdNotify: unexpected result: %v, %v", sent, err)
+	}
+	buf := make([]byte, 64)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Read: unexpected error: %v", err)
+	}
+	if got := string(buf[:n]); got != "READY=1" {
+		t.Fatalf("unexpected state %q", got)
+	}
+}
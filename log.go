@@ -14,11 +14,14 @@ import (
 	"github.com/bitgo/prova/blockchain/indexers"
 	"github.com/bitgo/prova/connmgr"
 	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/dnsseed"
+	"github.com/bitgo/prova/grpcapi"
 	"github.com/bitgo/prova/mempool"
 	"github.com/bitgo/prova/mining"
 	"github.com/bitgo/prova/mining/cpuminer"
 	"github.com/bitgo/prova/peer"
 	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/zmqpub"
 	"github.com/btcsuite/btclog"
 	"github.com/btcsuite/seelog"
 )
@@ -37,15 +40,26 @@ var (
 	btcdLog    = btclog.Disabled
 	chanLog    = btclog.Disabled
 	discLog    = btclog.Disabled
+	grpcLog    = btclog.Disabled
 	indxLog    = btclog.Disabled
 	minrLog    = btclog.Disabled
 	peerLog    = btclog.Disabled
 	rpcsLog    = btclog.Disabled
 	scrpLog    = btclog.Disabled
+	seedLog    = btclog.Disabled
 	srvrLog    = btclog.Disabled
 	txmpLog    = btclog.Disabled
+	zmqpLog    = btclog.Disabled
 )
 
+// activeLogFormat is the format of the log output.  It is set when the backend
+// logger is initialized, before any of the subsystem loggers are created.
+var activeLogFormat = logFormatText
+
+// subsystemBackends maps the identifiers of the subsystems which write to a log
+// file of their own to their backend logger.
+var subsystemBackends = make(map[string]seelog.LoggerInterface)
+
 // subsystemLoggers maps each subsystem identifier to its associated logger.
 var subsystemLoggers = map[string]btclog.Logger{
 	"ADXR": adxrLog,
@@ -55,14 +69,17 @@ var subsystemLoggers = map[string]btclog.Logger{
 	"BMGR": bmgrLog,
 	"CHAN": chanLog,
 	"DISC": discLog,
+	"GRPC": grpcLog,
 	"INDX": indxLog,
 	"MINR": minrLog,
 	"PEER": peerLog,
 	"PRVA": btcdLog,
 	"RPCS": rpcsLog,
 	"SCRP": scrpLog,
+	"SEED": seedLog,
 	"SRVR": srvrLog,
 	"TXMP": txmpLog,
+	"ZMQP": zmqpLog,
 }
 
 // useLogger updates the logger references for subsystemID to logger.  Invalid
@@ -99,6 +116,10 @@ func useLogger(subsystemID string, logger btclog.Logger) {
 	case "DISC":
 		discLog = logger
 
+	case "GRPC":
+		grpcLog = logger
+		grpcapi.UseLogger(logger)
+
 	case "INDX":
 		indxLog = logger
 		indexers.UseLogger(logger)
@@ -122,38 +143,95 @@ func useLogger(subsystemID string, logger btclog.Logger) {
 		scrpLog = logger
 		txscript.UseLogger(logger)
 
+	case "SEED":
+		seedLog = logger
+		dnsseed.UseLogger(logger)
+
 	case "SRVR":
 		srvrLog = logger
 
 	case "TXMP":
 		txmpLog = logger
 		mempool.UseLogger(logger)
+
+	case "ZMQP":
+		zmqpLog = logger
+		zmqpub.UseLogger(logger)
 	}
 }
 
-// initSeelogLogger initializes a new seelog logger that is used as the backend
-// for all logging subsystems.
-func initSeelogLogger(logFile string) {
+// logRotation describes when log files are rotated and what happens to the
+// rotated files.
+type logRotation struct {
+	maxSize  int  // Megabytes
+	maxRolls int  // Number of rotated files to keep
+	compress bool // Archive rotated files into a zip file instead of deleting
+}
+
+// newSeelogLogger returns a new seelog logger which writes to the console and
+// the passed log file in the passed format.  The log file is rotated according
+// to the passed rotation policy.  In the JSON log format, the subsystem loggers
+// write complete JSON objects, so the logger writes their messages as is.
+func newSeelogLogger(logFile, logFormat string, rotation *logRotation) (seelog.LoggerInterface, error) {
 	config := `
 	<seelog type="adaptive" mininterval="2000000" maxinterval="100000000"
 		critmsgcount="500" minlevel="trace">
 		<outputs formatid="all">
 			<console />
-			<rollingfile type="size" filename="%s" maxsize="10485760" maxrolls="3" />
+			<rollingfile type="size" filename="%s" maxsize="%d" maxrolls="%d"%s />
 		</outputs>
 		<formats>
-			<format id="all" format="%%Time %%Date [%%LEV] %%Msg%%n" />
+			<format id="all" format="%s" />
 		</formats>
 	</seelog>`
-	config = fmt.Sprintf(config, logFile)
+	format := "%Time %Date [%LEV] %Msg%n"
+	if logFormat == logFormatJSON {
+		format = "%Msg%n"
+	}
+	var archive string
+	if rotation.compress {
+		archive = fmt.Sprintf(` archivetype="zip" archivepath="%s.zip"`,
+			logFile)
+	}
+	config = fmt.Sprintf(config, logFile, rotation.maxSize*1024*1024,
+		rotation.maxRolls, archive, format)
 
-	logger, err := seelog.LoggerFromConfigAsString(config)
+	return seelog.LoggerFromConfigAsString(config)
+}
+
+// initSeelogLogger initializes a new seelog logger that is used as the backend
+// for all logging subsystems which do not have a log file of their own.
+func initSeelogLogger(logFile, logFormat string, rotation *logRotation) {
+	logger, err := newSeelogLogger(logFile, logFormat, rotation)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to create logger: %v", err)
 		os.Exit(1)
 	}
 
 	backendLog = logger
+	activeLogFormat = logFormat
+}
+
+// initSubsystemLogFile initializes a new seelog logger that is used as the
+// backend for the provided subsystem instead of the shared backend, so the
+// messages of the subsystem are written to the passed log file.  It must be
+// called after initSeelogLogger and before the subsystem logger is created.
+func initSubsystemLogFile(subsystemID, logFile string, rotation *logRotation) error {
+	logger, err := newSeelogLogger(logFile, activeLogFormat, rotation)
+	if err != nil {
+		return err
+	}
+
+	subsystemBackends[subsystemID] = logger
+	return nil
+}
+
+// flushLogs flushes the messages buffered by the backend loggers.
+func flushLogs() {
+	for _, logger := range subsystemBackends {
+		logger.Flush()
+	}
+	backendLog.Flush()
 }
 
 // setLogLevel sets the logging level for provided subsystem.  Invalid
@@ -172,9 +250,19 @@ func setLogLevel(subsystemID string, logLevel string) {
 		level = btclog.InfoLvl
 	}
 
-	// Create new logger for the subsystem if needed.
+	// Create new logger for the subsystem if needed.  Subsystems with a log
+	// file of their own write to their own backend.
 	if logger == btclog.Disabled {
-		logger = btclog.NewSubsystemLogger(backendLog, subsystemID+": ")
+		backend, ok := subsystemBackends[subsystemID]
+		if !ok {
+			backend = backendLog
+		}
+		if activeLogFormat == logFormatJSON {
+			logger = newJSONLogger(backend, subsystemID)
+		} else {
+			logger = btclog.NewSubsystemLogger(backend,
+				subsystemID+": ")
+		}
 		useLogger(subsystemID, logger)
 	}
 	logger.SetLevel(level)
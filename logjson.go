@@ -0,0 +1,280 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/btcsuite/btclog"
+)
+
+const (
+	// logFormatText is the log format which writes human-readable lines.
+	logFormatText = "text"
+
+	// logFormatJSON is the log format which writes a JSON object per line.
+	logFormatJSON = "json"
+)
+
+// logBackend is the part of the seelog logger interface the JSON loggers write
+// their lines to.
+type logBackend interface {
+	Trace(v ...interface{})
+	Debug(v ...interface{})
+	Info(v ...interface{})
+	Warn(v ...interface{}) error
+	Error(v ...interface{}) error
+	Critical(v ...interface{}) error
+}
+
+// logFieldser is implemented by values which carry key/value fields in addition
+// to their message when passed to the logging functions that do not take a
+// format string.  The JSON loggers emit the fields as separate JSON values while
+// the text loggers only write the message.  Any package may define such values
+// without depending on the logging backend.
+type logFieldser interface {
+	LogFields() map[string]interface{}
+}
+
+// logFields houses the key/value fields of a log message.
+type logFields map[string]interface{}
+
+// logMessage is a log message with key/value fields.  It implements the
+// fmt.Stringer interface, so it is written as the plain message by the text
+// loggers, and the logFieldser interface for the JSON loggers.
+type logMessage struct {
+	text   string
+	fields logFields
+}
+
+// String returns the message text.
+//
+// This is part of the fmt.Stringer interface.
+func (m logMessage) String() string {
+	return m.text
+}
+
+// LogFields returns the key/value fields of the message.
+//
+// This is part of the logFieldser interface.
+func (m logMessage) LogFields() map[string]interface{} {
+	return m.fields
+}
+
+// newLogMessage returns a log message with the passed text and fields.
+func newLogMessage(text string, fields logFields) logMessage {
+	return logMessage{text: text, fields: fields}
+}
+
+// jsonLogEntry is the JSON object written for every log message.
+type jsonLogEntry struct {
+	Time      string                 `json:"time"`
+	Subsystem string                 `json:"subsystem"`
+	Level     string                 `json:"level"`
+	Message   string                 `json:"message"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+}
+
+// jsonLogger is a subsystem logger which writes every message as a single line
+// JSON object to the backend.  It implements the btclog.Logger interface.
+type jsonLogger struct {
+	level     uint32 // Atomic, btclog.LogLevel
+	closed    int32  // Atomic, set once the logger is closed
+	backend   logBackend
+	subsystem string
+}
+
+// Ensure the jsonLogger type implements the btclog.Logger interface.
+var _ btclog.Logger = (*jsonLogger)(nil)
+
+// newJSONLogger returns a new logger for the passed subsystem which writes JSON
+// lines to the passed backend.
+func newJSONLogger(backend logBackend, subsystem string) *jsonLogger {
+	return &jsonLogger{
+		level:     uint32(btclog.InfoLvl),
+		backend:   backend,
+		subsystem: subsystem,
+	}
+}
+
+// marshalLogEntry returns the JSON encoding of the passed entry.  Fields which
+// can't be encoded as JSON are replaced by their string representation.  The
+// fields are copied before they are replaced, so the map of the caller is left
+// untouched.
+func marshalLogEntry(entry *jsonLogEntry) []byte {
+	encoded := *entry
+	encoded.Fields = make(map[string]interface{}, len(entry.Fields))
+	for key, value := range entry.Fields {
+		switch v := value.(type) {
+		case error:
+			encoded.Fields[key] = v.Error()
+		case fmt.Stringer:
+			encoded.Fields[key] = v.String()
+		default:
+			encoded.Fields[key] = value
+		}
+	}
+	line, err := json.Marshal(&encoded)
+	if err == nil {
+		return line
+	}
+
+	for key, value := range encoded.Fields {
+		encoded.Fields[key] = fmt.Sprint(value)
+	}
+	line, _ = json.Marshal(&encoded)
+	return line
+}
+
+// write writes the passed message and fields at the passed level to the
+// backend when the level is enabled.
+func (l *jsonLogger) write(level btclog.LogLevel, msg string, fields map[string]interface{}) {
+	if atomic.LoadInt32(&l.closed) != 0 {
+		return
+	}
+	if minLevel := l.Level(); minLevel == btclog.Off || level < minLevel {
+		return
+	}
+
+	entry := jsonLogEntry{
+		Time:      time.Now().Format(time.RFC3339Nano),
+		Subsystem: l.subsystem,
+		Level:     level.String(),
+		Message:   msg,
+		Fields:    fields,
+	}
+	line := string(marshalLogEntry(&entry))
+	switch level {
+	case btclog.TraceLvl:
+		l.backend.Trace(line)
+	case btclog.DebugLvl:
+		l.backend.Debug(line)
+	case btclog.InfoLvl:
+		l.backend.Info(line)
+	case btclog.WarnLvl:
+		l.backend.Warn(line)
+	case btclog.ErrorLvl:
+		l.backend.Error(line)
+	default:
+		l.backend.Critical(line)
+	}
+}
+
+// writeValues writes the passed values at the passed level.  The fields of any
+// values implementing the logFieldser interface are merged into the fields of
+// the message.
+func (l *jsonLogger) writeValues(level btclog.LogLevel, v []interface{}) {
+	var fields map[string]interface{}
+	for _, value := range v {
+		fieldser, ok := value.(logFieldser)
+		if !ok {
+			continue
+		}
+		for key, field := range fieldser.LogFields() {
+			if fields == nil {
+				fields = make(map[string]interface{})
+			}
+			fields[key] = field
+		}
+	}
+	l.write(level, fmt.Sprint(v...), fields)
+}
+
+// Tracef formats the message according to the format specifier and writes it
+// at the trace level.
+func (l *jsonLogger) Tracef(format string, params ...interface{}) {
+	l.write(btclog.TraceLvl, fmt.Sprintf(format, params...), nil)
+}
+
+// Debugf formats the message according to the format specifier and writes it
+// at the debug level.
+func (l *jsonLogger) Debugf(format string, params ...interface{}) {
+	l.write(btclog.DebugLvl, fmt.Sprintf(format, params...), nil)
+}
+
+// Infof formats the message according to the format specifier and writes it at
+// the info level.
+func (l *jsonLogger) Infof(format string, params ...interface{}) {
+	l.write(btclog.InfoLvl, fmt.Sprintf(format, params...), nil)
+}
+
+// Warnf formats the message according to the format specifier and writes it at
+// the warn level.
+func (l *jsonLogger) Warnf(format string, params ...interface{}) error {
+	l.write(btclog.WarnLvl, fmt.Sprintf(format, params...), nil)
+	return nil
+}
+
+// Errorf formats the message according to the format specifier and writes it
+// at the error level.
+func (l *jsonLogger) Errorf(format string, params ...interface{}) error {
+	l.write(btclog.ErrorLvl, fmt.Sprintf(format, params...), nil)
+	return nil
+}
+
+// Criticalf formats the message according to the format specifier and writes
+// it at the critical level.
+func (l *jsonLogger) Criticalf(format string, params ...interface{}) error {
+	l.write(btclog.CriticalLvl, fmt.Sprintf(format, params...), nil)
+	return nil
+}
+
+// Trace formats the message using the default formats for its operands and
+// writes it at the trace level.
+func (l *jsonLogger) Trace(v ...interface{}) {
+	l.writeValues(btclog.TraceLvl, v)
+}
+
+// Debug formats the message using the default formats for its operands and
+// writes it at the debug level.
+func (l *jsonLogger) Debug(v ...interface{}) {
+	l.writeValues(btclog.DebugLvl, v)
+}
+
+// Info formats the message using the default formats for its operands and
+// writes it at the info level.
+func (l *jsonLogger) Info(v ...interface{}) {
+	l.writeValues(btclog.InfoLvl, v)
+}
+
+// Warn formats the message using the default formats for its operands and
+// writes it at the warn level.
+func (l *jsonLogger) Warn(v ...interface{}) error {
+	l.writeValues(btclog.WarnLvl, v)
+	return nil
+}
+
+// Error formats the message using the default formats for its operands and
+// writes it at the error level.
+func (l *jsonLogger) Error(v ...interface{}) error {
+	l.writeValues(btclog.ErrorLvl, v)
+	return nil
+}
+
+// Critical formats the message using the default formats for its operands and
+// writes it at the critical level.
+func (l *jsonLogger) Critical(v ...interface{}) error {
+	l.writeValues(btclog.CriticalLvl, v)
+	return nil
+}
+
+// Level returns the current logging level.
+func (l *jsonLogger) Level() btclog.LogLevel {
+	return btclog.LogLevel(atomic.LoadUint32(&l.level))
+}
+
+// SetLevel changes the logging level to the passed level.
+func (l *jsonLogger) SetLevel(level btclog.LogLevel) {
+	atomic.StoreUint32(&l.level, uint32(level))
+}
+
+// Close closes the logger so no further messages are written.  It does not
+// close the backend since it is shared with the other subsystem loggers.
+func (l *jsonLogger) Close() {
+	atomic.StoreInt32(&l.closed, 1)
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btclog"
+)
+
+// testLogBackend is a logBackend which records the lines written to it along
+// with their levels.
+type testLogBackend struct {
+	lines []string
+}
+
+func (b *testLogBackend) add(level string, v []interface{}) {
+	b.lines = append(b.lines, level+" "+v[0].(string))
+}
+
+func (b *testLogBackend) Trace(v ...interface{}) { b.add("trace", v) }
+func (b *testLogBackend) Debug(v ...interface{}) { b.add("debug", v) }
+func (b *testLogBackend) Info(v ...interface{})  { b.add("info", v) }
+
+func (b *testLogBackend) Warn(v ...interface{}) error {
+	b.add("warn", v)
+	return nil
+}
+
+func (b *testLogBackend) Error(v ...interface{}) error {
+	b.add("error", v)
+	return nil
+}
+
+func (b *testLogBackend) Critical(v ...interface{}) error {
+	b.add("critical", v)
+	return nil
+}
+
+// TestJSONLogger ensures the JSON logger writes the expected JSON objects at
+// the expected levels and honors the logging level.
+func TestJSONLogger(t *testing.T) {
+	t.Parallel()
+
+	backend := &testLogBackend{}
+	logger := newJSONLogger(backend, "TEST")
+	logger.Debugf("hidden %d", 1)
+	logger.Infof("block %d", 5)
+	logger.Warn(newLogMessage("processed", logFields{
+		"height": 7,
+		"err":    errors.New("failed"),
+	}))
+	logger.SetLevel(btclog.Off)
+	logger.Critical("hidden")
+	logger.SetLevel(btclog.TraceLvl)
+	logger.Close()
+	logger.Critical("hidden")
+
+	if len(backend.lines) != 2 {
+		t.Fatalf("unexpected number of lines %d: %q", len(backend.lines),
+			backend.lines)
+	}
+	tests := []struct {
+		level   string
+		message string
+		fields  map[string]interface{}
+	}{
+		{level: "info", message: "block 5"},
+		{
+			level:   "warn",
+			message: "processed",
+			fields: map[string]interface{}{
+				"height": float64(7),
+				"err":    "failed",
+			},
+		},
+	}
+	for i, test := range tests {
+		line := backend.lines[i]
+		prefix := test.level + " "
+		if len(line) < len(prefix) || line[:len(prefix)] != prefix {
+			t.Errorf("line #%d: unexpected backend level: %q", i, line)
+			continue
+		}
+		var entry jsonLogEntry
+		if err := json.Unmarshal([]byte(line[len(prefix):]), &entry); err != nil {
+			t.Errorf("line #%d: malformed JSON: %v", i, err)
+			continue
+		}
+		if entry.Subsystem != "TEST" || entry.Level != test.level ||
+			entry.Message != test.message || entry.Time == "" {
+			t.Errorf("line #%d: unexpected entry %+v", i, entry)
+		}
+		if len(entry.Fields) != len(test.fields) {
+			t.Errorf("line #%d: unexpected fields %v", i, entry.Fields)
+			continue
+		}
+		for key, want := range test.fields {
+			if entry.Fields[key] != want {
+				t.Errorf("line #%d: unexpected field %s - got %v, "+
+					"want %v", i, key, entry.Fields[key], want)
+			}
+		}
+	}
+}
+
+// TestMarshalLogEntryFields ensures encoding an entry leaves the fields of the
+// caller untouched.
+func TestMarshalLogEntryFields(t *testing.T) {
+	t.Parallel()
+
+	err := errors.New("failed")
+	fields := map[string]interface{}{"err": err}
+	line := marshalLogEntry(&jsonLogEntry{Message: "processed",
+		Fields: fields})
+	var entry jsonLogEntry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		t.Fatalf("malformed JSON: %v", err)
+	}
+	if entry.Fields["err"] != "failed" {
+		t.Errorf("unexpected field err %v", entry.Fields["err"])
+	}
+	if fields["err"] != err {
+		t.Errorf("fields of the caller were modified: %v", fields)
+	}
+}
+
+// TestLogMessageString ensures log messages are written as their plain text by
+// the text loggers.
+func TestLogMessageString(t *testing.T) {
+	t.Parallel()
+
+	msg := newLogMessage("connected", logFields{"peer": "127.0.0.1"})
+	if got := msg.String(); got != "connected" {
+		t.Fatalf("unexpected message %q", got)
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// AdminAlertType identifies the reason an admin transaction is flagged by an
+// admin alert.
+type AdminAlertType int
+
+// These constants define the reasons admin transactions are flagged.
+const (
+	// AdminAlertConflict indicates an admin transaction which spends the
+	// tip of its thread that is already spent by a pending admin
+	// transaction, so the two compete with each other.
+	AdminAlertConflict AdminAlertType = iota
+
+	// AdminAlertDuplicateOp indicates an admin transaction which adds or
+	// revokes a key which a pending admin transaction of the same thread
+	// adds or revokes as well.
+	AdminAlertDuplicateOp
+
+	// AdminAlertKeySetDepleted indicates an admin transaction which revokes
+	// keys from an admin key set until fewer keys remain than are needed to
+	// sign the transactions of its thread, or no validate keys remain to
+	// sign blocks.
+	AdminAlertKeySetDepleted
+)
+
+// adminAlertTypeStrings is a map of admin alert types back to their constant
+// names for pretty printing.
+var adminAlertTypeStrings = map[AdminAlertType]string{
+	AdminAlertConflict:       "conflict",
+	AdminAlertDuplicateOp:    "duplicateop",
+	AdminAlertKeySetDepleted: "keysetdepleted",
+}
+
+// String returns the AdminAlertType in human-readable form.
+func (t AdminAlertType) String() string {
+	if s, ok := adminAlertTypeStrings[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown AdminAlertType (%d)", int(t))
+}
+
+// minAdminKeySetSizes are the numbers of keys the admin key sets need to keep.
+// The transactions of the admin threads are signed by two keys of the key set
+// of their thread, and blocks by one of the validate keys.
+var minAdminKeySetSizes = map[btcec.KeySetType]int{
+	btcec.RootKeySet:      2,
+	btcec.ProvisionKeySet: 2,
+	btcec.IssueKeySet:     2,
+	btcec.ValidateKeySet:  1,
+}
+
+// AdminAlert describes an admin transaction which competes with the pending
+// admin transactions in the memory pool or carries out unusual operations, so
+// chain operators are able to react before it is mined.
+type AdminAlert struct {
+	// Type is the reason the transaction is flagged.
+	Type AdminAlertType
+
+	// Tx is the flagged admin transaction.  It is flagged before it is
+	// checked against the rules of the memory pool, so conflicting
+	// transactions are flagged even though they are rejected afterwards.
+	Tx *provautil.Tx
+
+	// Thread is the admin thread the transaction carries on.
+	Thread provautil.ThreadID
+
+	// PendingTx is the pending admin transaction the flagged one conflicts
+	// with or repeats an operation of.  It is nil for depleted key sets.
+	PendingTx *provautil.Tx
+
+	// Reason describes the alert in human-readable form.
+	Reason string
+}
+
+// adminOpKey identifies the key an admin operation adds or revokes.
+type adminOpKey struct {
+	keySet btcec.KeySetType
+	pubKey [btcec.PubKeyBytesLenCompressed]byte
+	keyID  btcec.KeyID
+}
+
+// newAdminOpKey returns the identifier of the passed key of the passed key set.
+// The key ID is only set for ASP keys.
+func newAdminOpKey(keySet btcec.KeySetType, pubKey *btcec.PublicKey, keyID btcec.KeyID) adminOpKey {
+	opKey := adminOpKey{keySet: keySet, keyID: keyID}
+	copy(opKey.pubKey[:], pubKey.SerializeCompressed())
+	return opKey
+}
+
+// pendingAdminTxs returns the admin transactions in the pool which carry the
+// passed thread on from the tip of the main chain, in the order they spend
+// each other.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) pendingAdminTxs(thread provautil.ThreadID) []*provautil.Tx {
+	var pending []*provautil.Tx
+	tip := mp.cfg.ThreadTips()[thread]
+	for tip != nil {
+		spender, ok := mp.outpoints[*tip]
+		if !ok {
+			break
+		}
+		pending = append(pending, spender)
+		tip = wire.NewOutPoint(spender.Hash(), 0)
+	}
+	return pending
+}
+
+// adminAlerts returns the alerts raised by the passed transaction when it is an
+// admin transaction which competes with the pending admin transactions in the
+// pool or depletes an admin key set.  The admin state of the main chain
+// extended by the pending admin transactions of the thread of the transaction
+// is what it is checked against.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) adminAlerts(tx *provautil.Tx) []*AdminAlert {
+	threadInt, adminOutputs := txscript.GetAdminDetails(tx)
+	if threadInt < 0 {
+		return nil
+	}
+	thread := provautil.ThreadID(threadInt)
+	newAlert := func(alertType AdminAlertType, pendingTx *provautil.Tx,
+		format string, args ...interface{}) *AdminAlert {
+
+		return &AdminAlert{
+			Type:      alertType,
+			Tx:        tx,
+			Thread:    thread,
+			PendingTx: pendingTx,
+			Reason:    fmt.Sprintf(format, args...),
+		}
+	}
+
+	var alerts []*AdminAlert
+	threadTip := tx.MsgTx().TxIn[0].PreviousOutPoint
+	if spender, ok := mp.outpoints[threadTip]; ok {
+		alerts = append(alerts, newAlert(AdminAlertConflict, spender,
+			"transaction %v spends %v thread tip %v which is "+
+				"already spent by pending transaction %v",
+			tx.Hash(), thread, threadTip, spender.Hash()))
+	}
+
+	// The issue thread does not change the admin key sets.
+	if thread == provautil.IssueThread {
+		return alerts
+	}
+
+	// Apply the operations of the pending transactions to the sizes of
+	// the key sets and remember which transaction changed each key.
+	keySets := mp.cfg.GetAdminKeySets()
+	sizes := make(map[btcec.KeySetType]int, len(minAdminKeySetSizes))
+	for keySet := range minAdminKeySetSizes {
+		sizes[keySet] = len(keySets[keySet])
+	}
+	pendingOps := make(map[adminOpKey]*provautil.Tx)
+	for _, pendingTx := range mp.pendingAdminTxs(thread) {
+		_, pendingOutputs := txscript.GetAdminDetails(pendingTx)
+		for _, pendingOutput := range pendingOutputs {
+			isAddOp, keySet, pubKey, keyID :=
+				txscript.ExtractAdminOpData(pendingOutput)
+			opKey := newAdminOpKey(keySet, pubKey, keyID)
+			pendingOps[opKey] = pendingTx
+			if isAddOp {
+				sizes[opKey.keySet]++
+			} else {
+				sizes[opKey.keySet]--
+			}
+		}
+	}
+
+	depleted := make(map[btcec.KeySetType]bool)
+	for _, adminOutput := range adminOutputs {
+		isAddOp, keySet, pubKey, keyID :=
+			txscript.ExtractAdminOpData(adminOutput)
+		opKey := newAdminOpKey(keySet, pubKey, keyID)
+		opName := "revokes"
+		if isAddOp {
+			opName = "adds"
+		}
+		if pendingTx, ok := pendingOps[opKey]; ok {
+			alerts = append(alerts, newAlert(AdminAlertDuplicateOp,
+				pendingTx, "transaction %v %v %v key %x which "+
+					"pending transaction %v changes as well",
+				tx.Hash(), opName, opKey.keySet, opKey.pubKey,
+				pendingTx.Hash()))
+		}
+		if isAddOp {
+			sizes[opKey.keySet]++
+			continue
+		}
+		sizes[opKey.keySet]--
+		minSize, ok := minAdminKeySetSizes[opKey.keySet]
+		if ok && sizes[opKey.keySet] < minSize && !depleted[opKey.keySet] {
+			depleted[opKey.keySet] = true
+			alerts = append(alerts, newAlert(AdminAlertKeySetDepleted,
+				nil, "transaction %v leaves %d %v keys, fewer "+
+					"than the %d needed", tx.Hash(),
+				sizes[opKey.keySet], opKey.keySet, minSize))
+		}
+	}
+	return alerts
+}
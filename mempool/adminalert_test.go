@@ -0,0 +1,145 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestAdminAlerts ensures admin transactions which compete with the pending
+// admin transactions of their thread or deplete an admin key set raise the
+// expected alerts.
+func TestAdminAlerts(t *testing.T) {
+	t.Parallel()
+
+	// Create three validate keys, two of which are part of the validate
+	// key set of the chain.
+	pubKeys := make([]*btcec.PublicKey, 3)
+	for i := range pubKeys {
+		_, pubKeys[i] = btcec.PrivKeyFromBytes(btcec.S256(),
+			[]byte{byte(i + 1)})
+	}
+	keySets := map[btcec.KeySetType]btcec.PublicKeySet{
+		btcec.ValidateKeySet: {*pubKeys[0], *pubKeys[1]},
+	}
+	rootTip := wire.NewOutPoint(&chainhash.Hash{0x01}, 0)
+	mp := New(&Config{
+		ThreadTips: func() map[provautil.ThreadID]*wire.OutPoint {
+			return map[provautil.ThreadID]*wire.OutPoint{
+				provautil.RootThread: rootTip,
+			}
+		},
+		GetAdminKeySets: func() map[btcec.KeySetType]btcec.PublicKeySet {
+			return keySets
+		},
+	})
+
+	// createAdminTx returns a root thread transaction which spends the
+	// passed thread tip and performs the passed validate key operations.
+	type validateKeyOp struct {
+		op     byte
+		pubKey *btcec.PublicKey
+	}
+	createAdminTx := func(tip *wire.OutPoint, ops ...validateKeyOp) *provautil.Tx {
+		threadScript, err := txscript.ProvaThreadScript(
+			provautil.RootThread)
+		if err != nil {
+			t.Fatalf("ProvaThreadScript: %v", err)
+		}
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(wire.NewTxIn(tip, nil))
+		tx.AddTxOut(wire.NewTxOut(0, threadScript))
+		for _, op := range ops {
+			data := make([]byte, 1+btcec.PubKeyBytesLenCompressed)
+			data[0] = op.op
+			copy(data[1:], op.pubKey.SerializeCompressed())
+			script, err := txscript.NewScriptBuilder().
+				AddOp(txscript.OP_RETURN).AddData(data).Script()
+			if err != nil {
+				t.Fatalf("NewScriptBuilder: %v", err)
+			}
+			tx.AddTxOut(wire.NewTxOut(0, script))
+		}
+		return provautil.NewTx(tx)
+	}
+	add := func(pubKey *btcec.PublicKey) validateKeyOp {
+		return validateKeyOp{txscript.AdminOpValidateKeyAdd, pubKey}
+	}
+	revoke := func(pubKey *btcec.PublicKey) validateKeyOp {
+		return validateKeyOp{txscript.AdminOpValidateKeyRevoke, pubKey}
+	}
+
+	// Add a pending transaction which revokes the first validate key.
+	pendingTx := createAdminTx(rootTip, revoke(pubKeys[0]))
+	mp.outpoints[*rootTip] = pendingTx
+	pendingTip := wire.NewOutPoint(pendingTx.Hash(), 0)
+
+	nonAdminTx := wire.NewMsgTx(1)
+	nonAdminTx.AddTxIn(wire.NewTxIn(rootTip, nil))
+	nonAdminTx.AddTxOut(wire.NewTxOut(1000, []byte{txscript.OP_TRUE}))
+
+	tests := []struct {
+		name  string
+		tx    *provautil.Tx
+		types []AdminAlertType
+	}{
+		{
+			name:  "transaction which is not an admin transaction",
+			tx:    provautil.NewTx(nonAdminTx),
+			types: nil,
+		},
+		{
+			name: "competing removal of the same validate key",
+			tx:   createAdminTx(rootTip, revoke(pubKeys[0])),
+			types: []AdminAlertType{AdminAlertConflict,
+				AdminAlertDuplicateOp, AdminAlertKeySetDepleted},
+		},
+		{
+			name:  "addition of a key after the pending transaction",
+			tx:    createAdminTx(pendingTip, add(pubKeys[2])),
+			types: nil,
+		},
+		{
+			name:  "removal of the last validate key",
+			tx:    createAdminTx(pendingTip, revoke(pubKeys[1])),
+			types: []AdminAlertType{AdminAlertKeySetDepleted},
+		},
+		{
+			name: "replacement of the last validate key",
+			tx: createAdminTx(pendingTip, add(pubKeys[2]),
+				revoke(pubKeys[1])),
+			types: nil,
+		},
+	}
+
+	for _, test := range tests {
+		var types []AdminAlertType
+		for _, alert := range mp.adminAlerts(test.tx) {
+			if alert.Tx != test.tx ||
+				alert.Thread != provautil.RootThread {
+
+				t.Errorf("%s: unexpected alert %+v", test.name,
+					alert)
+			}
+			wantPending := alert.Type != AdminAlertKeySetDepleted
+			if (alert.PendingTx == pendingTx) != wantPending {
+				t.Errorf("%s: unexpected pending transaction "+
+					"of %v alert", test.name, alert.Type)
+			}
+			types = append(types, alert.Type)
+		}
+		if !reflect.DeepEqual(types, test.types) {
+			t.Errorf("%s: got alert types %v, want %v", test.name,
+				types, test.types)
+		}
+	}
+}
@@ -56,6 +56,8 @@ be an exhaustive list.
    - Max signature operations per transaction
    - Max orphan transaction size
    - Max number of orphan transactions allowed
+   - Dust threshold, standard script classes, and max data carrier size
+   - Replacement of the policy while the pool is running
  - Additional metadata tracking for each transaction
    - Timestamp when the transaction was added to the pool
    - Most recent block height when the transaction was added to the pool
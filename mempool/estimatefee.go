@@ -0,0 +1,271 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+const (
+	// MaxEstimateFeeTarget is the maximum number of blocks within which a
+	// transaction can be targeted to confirm by a fee estimate.
+	// Transactions which are not confirmed within this many blocks of
+	// being observed are considered to have failed every target.
+	MaxEstimateFeeTarget = 25
+
+	// estimateFeeBucketSize is the minimum number of transactions whose
+	// confirmation times are grouped together when checking whether the
+	// transactions which pay a given fee rate confirm within a target.
+	estimateFeeBucketSize = 20
+
+	// defaultEstimateFeeMaxObservations is the default maximum number of
+	// transactions with known confirmation times which are kept to base
+	// the fee estimates on.
+	defaultEstimateFeeMaxObservations = 10000
+)
+
+// ErrNoFeeEstimate indicates there is not enough data about recent
+// transactions to estimate a fee rate for the requested target and
+// confidence.
+var ErrNoFeeEstimate = errors.New("insufficient data to estimate fee rate")
+
+// observedTx houses the details of a transaction the fee estimator has seen
+// entering the memory pool which are needed to estimate fees.
+type observedTx struct {
+	hash    chainhash.Hash
+	feeRate int64
+
+	// observed is the height of the best chain when the transaction
+	// entered the memory pool.
+	observed uint32
+
+	// final is the height of the block at which the confirmation time of
+	// the transaction became known, either because the block mined it or
+	// because it was not mined within MaxEstimateFeeTarget blocks.
+	final uint32
+
+	// confirmBlocks is the number of blocks it took to mine the
+	// transaction, or zero when it was not mined within
+	// MaxEstimateFeeTarget blocks.
+	confirmBlocks uint32
+}
+
+// FeeEstimator estimates the fee rate transactions need to pay to be mined
+// within a target number of blocks by tracking how many blocks it took for the
+// transactions which entered the memory pool to be mined.
+//
+// The fee rate for a target is the lowest fee rate for which the recent
+// transactions paying around that rate, and every higher rate, were mined
+// within the target with at least the requested confidence.
+type FeeEstimator struct {
+	mtx             sync.Mutex
+	maxObservations int
+	height          uint32
+
+	// pending houses the observed transactions whose confirmation time is
+	// not known yet, while finished houses the transactions whose
+	// confirmation time is known in the order it became known.
+	pending  map[chainhash.Hash]*observedTx
+	finished []*observedTx
+}
+
+// NewFeeEstimator returns a new fee estimator which bases its estimates on at
+// most the passed number of recent transactions with known confirmation
+// times.  A default is used when the passed maximum is not positive.
+func NewFeeEstimator(maxObservations int) *FeeEstimator {
+	if maxObservations <= 0 {
+		maxObservations = defaultEstimateFeeMaxObservations
+	}
+	return &FeeEstimator{
+		maxObservations: maxObservations,
+		pending:         make(map[chainhash.Hash]*observedTx),
+	}
+}
+
+// ObserveTransaction records the passed transaction which entered the memory
+// pool, so its confirmation time is tracked.  Admin transactions are ignored
+// since they do not pay fees.
+//
+// This function is safe for concurrent access.
+func (fe *FeeEstimator) ObserveTransaction(txDesc *TxDesc) {
+	threadInt, _ := txscript.GetAdminDetails(txDesc.Tx)
+	if threadInt >= 0 {
+		return
+	}
+
+	fe.mtx.Lock()
+	defer fe.mtx.Unlock()
+
+	hash := *txDesc.Tx.Hash()
+	if _, exists := fe.pending[hash]; exists {
+		return
+	}
+	fe.pending[hash] = &observedTx{
+		hash:     hash,
+		feeRate:  txDesc.FeePerKB,
+		observed: txDesc.Height,
+	}
+}
+
+// finish records the confirmation time of the passed pending transaction as
+// known at the current height.
+//
+// This function MUST be called with the fee estimator lock held.
+func (fe *FeeEstimator) finish(o *observedTx, confirmBlocks uint32) {
+	delete(fe.pending, o.hash)
+	o.final = fe.height
+	o.confirmBlocks = confirmBlocks
+	fe.finished = append(fe.finished, o)
+	if len(fe.finished) > fe.maxObservations {
+		fe.finished[0] = nil
+		fe.finished = fe.finished[1:]
+	}
+}
+
+// RegisterBlock records the confirmation times of the observed transactions
+// mined by the passed block, which must have been connected to the end of the
+// main chain.
+//
+// This function is safe for concurrent access.
+func (fe *FeeEstimator) RegisterBlock(block *provautil.Block) {
+	fe.mtx.Lock()
+	defer fe.mtx.Unlock()
+
+	fe.height = block.Height()
+	for _, tx := range block.Transactions()[1:] {
+		o, exists := fe.pending[*tx.Hash()]
+		if !exists {
+			continue
+		}
+		confirmBlocks := uint32(1)
+		if fe.height > o.observed {
+			confirmBlocks = fe.height - o.observed
+		}
+		if confirmBlocks > MaxEstimateFeeTarget {
+			confirmBlocks = 0
+		}
+		fe.finish(o, confirmBlocks)
+	}
+
+	// Transactions which have not been mined within the maximum target are
+	// considered to have failed every target.  This includes the
+	// transactions which left the memory pool without being mined, such as
+	// double spends.
+	for _, o := range fe.pending {
+		if fe.height >= o.observed+MaxEstimateFeeTarget {
+			fe.finish(o, 0)
+		}
+	}
+}
+
+// Rollback undoes the registration of the passed block, which must have been
+// disconnected from the end of the main chain, so the transactions whose
+// confirmation times became known with it are pending again.
+//
+// This function is safe for concurrent access.
+func (fe *FeeEstimator) Rollback(block *provautil.Block) {
+	fe.mtx.Lock()
+	defer fe.mtx.Unlock()
+
+	height := block.Height()
+	for len(fe.finished) > 0 {
+		o := fe.finished[len(fe.finished)-1]
+		if o.final != height {
+			break
+		}
+		fe.finished[len(fe.finished)-1] = nil
+		fe.finished = fe.finished[:len(fe.finished)-1]
+		o.final = 0
+		o.confirmBlocks = 0
+		fe.pending[o.hash] = o
+	}
+	if height > 0 {
+		fe.height = height - 1
+	}
+}
+
+// feeSample is the outcome of an observed transaction for a target.
+type feeSample struct {
+	feeRate   int64
+	confirmed bool
+}
+
+// EstimateFee returns the estimated fee rate, in atoms per kB, a transaction
+// needs to pay to be mined within the passed number of blocks with the passed
+// confidence, which must be between 0 and 1.  ErrNoFeeEstimate is returned
+// when there is not enough data about recent transactions.
+//
+// This function is safe for concurrent access.
+func (fe *FeeEstimator) EstimateFee(target uint32, confidence float64) (int64, error) {
+	if target < 1 || target > MaxEstimateFeeTarget {
+		return 0, fmt.Errorf("fee estimate target %d is out of range "+
+			"[1, %d]", target, MaxEstimateFeeTarget)
+	}
+	if confidence <= 0 || confidence > 1 {
+		return 0, fmt.Errorf("fee estimate confidence %v is out of "+
+			"range (0, 1]", confidence)
+	}
+
+	fe.mtx.Lock()
+	samples := make([]feeSample, 0, len(fe.finished)+len(fe.pending))
+	for _, o := range fe.finished {
+		samples = append(samples, feeSample{
+			feeRate: o.feeRate,
+			confirmed: o.confirmBlocks != 0 &&
+				o.confirmBlocks <= target,
+		})
+	}
+
+	// Pending transactions which have already waited for the target
+	// number of blocks failed it, while the outcome of the others is not
+	// known yet.
+	for _, o := range fe.pending {
+		if fe.height >= o.observed+target {
+			samples = append(samples, feeSample{feeRate: o.feeRate})
+		}
+	}
+	fe.mtx.Unlock()
+
+	// Group the samples into buckets of similar fee rates starting from
+	// the highest fee rate and find the lowest bucket for which it and all
+	// of the buckets above it confirmed within the target often enough.
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i].feeRate > samples[j].feeRate
+	})
+	estimate := int64(-1)
+	for start := 0; start+estimateFeeBucketSize <= len(samples); {
+		// Keep samples with the same fee rate in the same bucket.
+		end := start + estimateFeeBucketSize
+		for end < len(samples) &&
+			samples[end].feeRate == samples[end-1].feeRate {
+
+			end++
+		}
+
+		var confirmed int
+		for _, sample := range samples[start:end] {
+			if sample.confirmed {
+				confirmed++
+			}
+		}
+		if float64(confirmed) < confidence*float64(end-start) {
+			break
+		}
+		estimate = samples[end-1].feeRate
+		start = end
+	}
+	if estimate == -1 {
+		return 0, ErrNoFeeEstimate
+	}
+
+	return estimate, nil
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/mining"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// newEstimateFeeTestTx returns a distinct transaction for the passed index.
+func newEstimateFeeTestTx(index uint32) *provautil.Tx {
+	msgTx := wire.NewMsgTx(wire.TxVersion)
+	msgTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: index}, nil))
+	msgTx.AddTxOut(wire.NewTxOut(1, nil))
+	return provautil.NewTx(msgTx)
+}
+
+// newEstimateFeeTestBlock returns a block at the passed height which mines the
+// passed transactions after a coinbase.
+func newEstimateFeeTestBlock(height uint32, txns []*provautil.Tx) *provautil.Block {
+	msgBlock := wire.MsgBlock{}
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		nil))
+	msgBlock.AddTransaction(coinbase)
+	for _, tx := range txns {
+		msgBlock.AddTransaction(tx.MsgTx())
+	}
+	block := provautil.NewBlock(&msgBlock)
+	block.SetHeight(height)
+	return block
+}
+
+// TestFeeEstimator ensures the fee estimator bases its estimates on the
+// confirmation times of the observed transactions and handles disconnected
+// blocks and transactions which are never mined.
+func TestFeeEstimator(t *testing.T) {
+	t.Parallel()
+
+	const (
+		startHeight = 100
+		highFeeRate = 2000
+		lowFeeRate  = 1000
+	)
+	fe := NewFeeEstimator(0)
+	fe.RegisterBlock(newEstimateFeeTestBlock(startHeight, nil))
+
+	// Observe a bucket of transactions paying a high fee rate which are
+	// mined in the next block and a bucket paying a low fee rate which are
+	// mined two blocks later.
+	var highTxns, lowTxns []*provautil.Tx
+	for i := uint32(0); i < 2*estimateFeeBucketSize; i++ {
+		tx := newEstimateFeeTestTx(i)
+		feeRate := int64(highFeeRate)
+		if i < estimateFeeBucketSize {
+			highTxns = append(highTxns, tx)
+		} else {
+			lowTxns = append(lowTxns, tx)
+			feeRate = lowFeeRate
+		}
+		fe.ObserveTransaction(&TxDesc{TxDesc: mining.TxDesc{
+			Tx:       tx,
+			Height:   startHeight,
+			FeePerKB: feeRate,
+		}})
+	}
+	if _, err := fe.EstimateFee(1, 0.95); err != ErrNoFeeEstimate {
+		t.Fatalf("EstimateFee: unexpected error before any block - "+
+			"got %v, want %v", err, ErrNoFeeEstimate)
+	}
+
+	fe.RegisterBlock(newEstimateFeeTestBlock(startHeight+1, highTxns))
+	fe.RegisterBlock(newEstimateFeeTestBlock(startHeight+2, nil))
+	lowBlock := newEstimateFeeTestBlock(startHeight+3, lowTxns)
+	fe.RegisterBlock(lowBlock)
+
+	tests := []struct {
+		name   string
+		target uint32
+		want   int64
+	}{
+		{name: "next block", target: 1, want: highFeeRate},
+		{name: "two blocks", target: 2, want: highFeeRate},
+		{name: "three blocks", target: 3, want: lowFeeRate},
+		{name: "max target", target: MaxEstimateFeeTarget, want: lowFeeRate},
+	}
+	for _, test := range tests {
+		got, err := fe.EstimateFee(test.target, 0.95)
+		if err != nil {
+			t.Fatalf("EstimateFee (%s): unexpected error: %v",
+				test.name, err)
+		}
+		if got != test.want {
+			t.Fatalf("EstimateFee (%s): got %d, want %d", test.name,
+				got, test.want)
+		}
+	}
+
+	// Disconnecting the block which mined the low fee rate transactions
+	// must make them pending again, so they only count as failures for the
+	// targets they have already waited for.
+	fe.Rollback(lowBlock)
+	for _, target := range []uint32{1, 3} {
+		got, err := fe.EstimateFee(target, 0.95)
+		if err != nil {
+			t.Fatalf("EstimateFee (target %d after rollback): "+
+				"unexpected error: %v", target, err)
+		}
+		if got != highFeeRate {
+			t.Fatalf("EstimateFee (target %d after rollback): got %d, "+
+				"want %d", target, got, highFeeRate)
+		}
+	}
+
+	// Transactions which are not mined within the maximum target must fail
+	// every target.
+	for height := uint32(startHeight + 3); height <= startHeight+MaxEstimateFeeTarget; height++ {
+		fe.RegisterBlock(newEstimateFeeTestBlock(height, nil))
+	}
+	got, err := fe.EstimateFee(MaxEstimateFeeTarget, 0.95)
+	if err != nil {
+		t.Fatalf("EstimateFee (expired): unexpected error: %v", err)
+	}
+	if got != highFeeRate {
+		t.Fatalf("EstimateFee (expired): got %d, want %d", got,
+			highFeeRate)
+	}
+
+	// Invalid targets and confidences must be rejected.
+	invalid := []struct {
+		target     uint32
+		confidence float64
+	}{
+		{target: 0, confidence: 0.95},
+		{target: MaxEstimateFeeTarget + 1, confidence: 0.95},
+		{target: 1, confidence: 0},
+		{target: 1, confidence: 1.5},
+	}
+	for _, test := range invalid {
+		_, err := fe.EstimateFee(test.target, test.confidence)
+		if err == nil || err == ErrNoFeeEstimate {
+			t.Fatalf("EstimateFee (target %d, confidence %v): "+
+				"unexpected error: %v", test.target,
+				test.confidence, err)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"container/list"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -40,6 +41,16 @@ const (
 	// orphanExpireScanInterval is the minimum amount of time in between
 	// scans of the orphan pool to evict expired transactions.
 	orphanExpireScanInterval = time.Minute * 5
+
+	// MaxRBFSequence is the maximum sequence number an input can use to
+	// signal that the transaction spending it can be replaced by a
+	// transaction paying a higher fee.
+	MaxRBFSequence = 0xfffffffd
+
+	// MaxReplacementEvictions is the maximum number of transactions a
+	// replacement transaction can evict from the pool, including the
+	// transactions spending the outputs of the replaced transactions.
+	MaxReplacementEvictions = 100
 )
 
 // Tag represents an identifier to use for tagging orphan transactions.  The
@@ -90,6 +101,11 @@ type Config struct {
 	// utxo view.
 	CalcSequenceLock func(*provautil.Tx, *blockchain.UtxoViewpoint) (*blockchain.SequenceLock, error)
 
+	// IsDeploymentActive defines the function to use in order to determine
+	// whether the rule change of the passed deployment applies to the
+	// block after the current chain tip within the best chain.
+	IsDeploymentActive func(deploymentID int) (bool, error)
+
 	// SigCache defines a signature cache to use.
 	SigCache *txscript.SigCache
 
@@ -103,6 +119,18 @@ type Config struct {
 	// indexing the unconfirmed transactions in the memory pool.
 	// This can be nil if the address index is not enabled.
 	AddrIndex *indexers.AddrIndex
+
+	// FeeEstimator defines the optional fee estimator which observes the
+	// new transactions accepted into the memory pool.  This can be nil if
+	// fee estimation is not needed.
+	FeeEstimator *FeeEstimator
+
+	// OnAdminAlert defines the optional function to invoke with the alerts
+	// raised by new admin transactions which compete with the pending
+	// admin transactions or carry out unusual operations.  It is invoked
+	// with the mempool lock held, so it must not call back into the
+	// mempool.  This can be nil if admin alerts are not needed.
+	OnAdminAlert func(*AdminAlert)
 }
 
 // Policy houses the policy (configuration parameters) which is used to
@@ -143,6 +171,30 @@ type Policy struct {
 	// MinRelayTxFee defines the minimum transaction fee in RMG/kB to be
 	// considered a non-zero fee.
 	MinRelayTxFee provautil.Amount
+
+	// RejectReplacement defines whether to reject transactions which spend
+	// outputs already spent by transactions in the pool, even when the
+	// transactions they conflict with signal replaceability and the
+	// replacement pays a higher fee.
+	RejectReplacement bool
+
+	// DustThreshold is the value in atoms below which outputs are
+	// considered dust in addition to the outputs which are dust at
+	// MinRelayTxFee.  Zero disables the threshold.
+	DustThreshold provautil.Amount
+
+	// StandardScriptClasses are the classes of public key scripts which
+	// the outputs of transactions other than admin transactions must have
+	// to be standard.  When empty, every recognized script class is
+	// standard.  Removing txscript.NullDataTy makes data carrier outputs
+	// non-standard.
+	StandardScriptClasses []txscript.ScriptClass
+
+	// MaxDataCarrierSize is the maximum number of bytes a standard data
+	// carrier output of a transaction other than an admin transaction may
+	// carry.  Zero selects txscript.MaxDataCarrierSize, which is also the
+	// largest size recognized as a data carrier output.
+	MaxDataCarrierSize int
 }
 
 // TxDesc is a descriptor containing a transaction in the mempool along with
@@ -153,6 +205,12 @@ type TxDesc struct {
 	// StartingPriority is the priority of the transaction when it was added
 	// to the pool.
 	StartingPriority float64
+
+	// AncestorCount and DescendantCount are the number of transactions in
+	// the pool the transaction spends outputs of and which spend its
+	// outputs, directly or indirectly, including the transaction itself.
+	AncestorCount   int64
+	DescendantCount int64
 }
 
 // orphanTx is normal transaction that references an ancestor transaction
@@ -481,11 +539,14 @@ func (mp *TxPool) removeTransaction(tx *provautil.Tx, removeRedeemers bool) {
 			mp.cfg.AddrIndex.RemoveUnconfirmedTx(txHash)
 		}
 
-		// Mark the referenced outpoints as unspent by the pool.
+		// Mark the referenced outpoints as unspent by the pool and
+		// update the packages the transaction was part of.
+		related := mp.txRelatives(tx)
 		for _, txIn := range txDesc.Tx.MsgTx().TxIn {
 			delete(mp.outpoints, txIn.PreviousOutPoint)
 		}
 		delete(mp.pool, *txHash)
+		mp.updatePackageStats(related)
 		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
 	}
 }
@@ -547,6 +608,13 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *provaut
 	for _, txIn := range tx.MsgTx().TxIn {
 		mp.outpoints[txIn.PreviousOutPoint] = tx
 	}
+
+	// Track the fees and sizes of the packages the transaction is part of.
+	// Transactions added back from disconnected blocks can already have
+	// descendants in the pool, so they are updated as well.
+	related := mp.txRelatives(tx)
+	related[*tx.Hash()] = txD
+	mp.updatePackageStats(related)
 	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
 
 	// Add unconfirmed address index entries associated with the transaction
@@ -560,21 +628,242 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint, tx *provaut
 
 // checkPoolDoubleSpend checks whether or not the passed transaction is
 // attempting to spend coins already spent by other transactions in the pool.
+// Spending the same coins is allowed when replacement is not rejected by the
+// policy and every transaction spending them signals replaceability, in which
+// case the returned flag indicates the transaction is a potential replacement.
 // Note it does not check for double spends against transactions already in the
 // main chain.
 //
 // This function MUST be called with the mempool lock held (for reads).
-func (mp *TxPool) checkPoolDoubleSpend(tx *provautil.Tx) error {
+func (mp *TxPool) checkPoolDoubleSpend(tx *provautil.Tx) (bool, error) {
+	var isReplacement bool
 	for _, txIn := range tx.MsgTx().TxIn {
-		if txR, exists := mp.outpoints[txIn.PreviousOutPoint]; exists {
+		txR, exists := mp.outpoints[txIn.PreviousOutPoint]
+		if !exists {
+			continue
+		}
+		if mp.cfg.Policy.RejectReplacement ||
+			!mp.signalsReplacement(txR, nil) {
+
 			str := fmt.Sprintf("output %v already spent by "+
 				"transaction %v in the memory pool",
 				txIn.PreviousOutPoint, txR.Hash())
-			return txRuleError(wire.RejectDuplicate, str)
+			return false, txRuleError(wire.RejectDuplicate, str)
 		}
+		isReplacement = true
 	}
 
-	return nil
+	return isReplacement, nil
+}
+
+// signalsReplacement returns whether the passed transaction in the pool can be
+// replaced, which is the case when any of its inputs uses a sequence number of
+// at most MaxRBFSequence or any of the transactions in the pool it spends can
+// be replaced.  The cache holds the results for the transactions which were
+// already checked and may be nil.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) signalsReplacement(tx *provautil.Tx, cache map[chainhash.Hash]bool) bool {
+	if cache == nil {
+		cache = make(map[chainhash.Hash]bool)
+	}
+	if signals, ok := cache[*tx.Hash()]; ok {
+		return signals
+	}
+
+	for _, txIn := range tx.MsgTx().TxIn {
+		if txIn.Sequence <= MaxRBFSequence {
+			cache[*tx.Hash()] = true
+			return true
+		}
+	}
+
+	// Cache the result before visiting the parents so it is not computed
+	// again when several parents share ancestors.
+	cache[*tx.Hash()] = false
+	for _, txIn := range tx.MsgTx().TxIn {
+		parent, ok := mp.pool[txIn.PreviousOutPoint.Hash]
+		if !ok {
+			continue
+		}
+		if mp.signalsReplacement(parent.Tx, cache) {
+			cache[*tx.Hash()] = true
+			return true
+		}
+	}
+
+	return false
+}
+
+// txDescendants adds the transactions in the pool which spend outputs of the
+// passed transaction to the passed set, recursively.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) txDescendants(tx *provautil.Tx, descendants map[chainhash.Hash]*provautil.Tx) {
+	prevOut := wire.OutPoint{Hash: *tx.Hash()}
+	for i := range tx.MsgTx().TxOut {
+		prevOut.Index = uint32(i)
+		txR, exists := mp.outpoints[prevOut]
+		if !exists {
+			continue
+		}
+		if _, ok := descendants[*txR.Hash()]; ok {
+			continue
+		}
+		descendants[*txR.Hash()] = txR
+		mp.txDescendants(txR, descendants)
+	}
+}
+
+// txAncestors adds the transactions in the pool whose outputs the passed
+// transaction spends to the passed set, recursively.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) txAncestors(tx *provautil.Tx, ancestors map[chainhash.Hash]*TxDesc) {
+	for _, txIn := range tx.MsgTx().TxIn {
+		parentHash := txIn.PreviousOutPoint.Hash
+		if _, ok := ancestors[parentHash]; ok {
+			continue
+		}
+		parent, exists := mp.pool[parentHash]
+		if !exists {
+			continue
+		}
+		ancestors[parentHash] = parent
+		mp.txAncestors(parent.Tx, ancestors)
+	}
+}
+
+// txRelatives returns the ancestors and descendants of the passed transaction
+// in the pool, which are the transactions whose packages change when the
+// transaction is added to or removed from the pool.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) txRelatives(tx *provautil.Tx) map[chainhash.Hash]*TxDesc {
+	related := make(map[chainhash.Hash]*TxDesc)
+	mp.txAncestors(tx, related)
+	descendants := make(map[chainhash.Hash]*provautil.Tx)
+	mp.txDescendants(tx, descendants)
+	for hash := range descendants {
+		related[hash] = mp.pool[hash]
+	}
+	return related
+}
+
+// updatePackageStats recomputes the number, total fees and sizes of the
+// ancestors and descendants of the passed transactions in the pool.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) updatePackageStats(descs map[chainhash.Hash]*TxDesc) {
+	for _, desc := range descs {
+		size := int64(desc.Tx.MsgTx().SerializeSize())
+		desc.AncestorFee, desc.AncestorSize = desc.Fee, size
+		desc.DescendantFee, desc.DescendantSize = desc.Fee, size
+
+		ancestors := make(map[chainhash.Hash]*TxDesc)
+		mp.txAncestors(desc.Tx, ancestors)
+		desc.AncestorCount = int64(len(ancestors)) + 1
+		for _, ancestor := range ancestors {
+			desc.AncestorFee += ancestor.Fee
+			desc.AncestorSize += int64(ancestor.Tx.MsgTx().SerializeSize())
+		}
+		descendants := make(map[chainhash.Hash]*provautil.Tx)
+		mp.txDescendants(desc.Tx, descendants)
+		desc.DescendantCount = int64(len(descendants)) + 1
+		for hash, descendant := range descendants {
+			desc.DescendantFee += mp.pool[hash].Fee
+			desc.DescendantSize += int64(descendant.MsgTx().SerializeSize())
+		}
+	}
+}
+
+// validateReplacement checks whether the passed transaction, which pays the
+// passed fee and spends outputs already spent by replaceable transactions in
+// the pool, satisfies the rules to replace them.  It returns the transactions
+// which need to be removed from the pool when it is accepted, which are the
+// conflicting transactions along with the transactions spending their outputs.
+//
+// The replacement must pay a higher fee rate than each of the transactions it
+// conflicts with, at least the total fee of the removed transactions plus the
+// minimum relay fee for its own size, may not spend outputs of transactions in
+// the pool the conflicting transactions don't spend, and may not remove more
+// than MaxReplacementEvictions transactions.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) validateReplacement(tx *provautil.Tx, txFee int64) (map[chainhash.Hash]*provautil.Tx, error) {
+	txHash := tx.Hash()
+	txSize := int64(tx.MsgTx().SerializeSize())
+	txFeePerKB := txFee * 1000 / txSize
+
+	// Find the transactions spending the same outputs and the transactions
+	// they spend from the pool.
+	evictions := make(map[chainhash.Hash]*provautil.Tx)
+	conflictParents := make(map[chainhash.Hash]struct{})
+	for _, txIn := range tx.MsgTx().TxIn {
+		txR, exists := mp.outpoints[txIn.PreviousOutPoint]
+		if !exists {
+			continue
+		}
+		if _, ok := evictions[*txR.Hash()]; ok {
+			continue
+		}
+		conflict := mp.pool[*txR.Hash()]
+		if txFeePerKB <= conflict.FeePerKB {
+			str := fmt.Sprintf("replacement transaction %v has a fee "+
+				"rate of %d which is not higher than the fee "+
+				"rate of %d of the replaced transaction %v",
+				txHash, txFeePerKB, conflict.FeePerKB, txR.Hash())
+			return nil, txRuleError(wire.RejectInsufficientFee, str)
+		}
+		evictions[*txR.Hash()] = txR
+		for _, parentIn := range txR.MsgTx().TxIn {
+			conflictParents[parentIn.PreviousOutPoint.Hash] = struct{}{}
+		}
+	}
+	for _, conflict := range evictions {
+		mp.txDescendants(conflict, evictions)
+	}
+	if len(evictions) > MaxReplacementEvictions {
+		str := fmt.Sprintf("replacement transaction %v evicts %d "+
+			"transactions which is more than the maximum of %d",
+			txHash, len(evictions), MaxReplacementEvictions)
+		return nil, txRuleError(wire.RejectNonstandard, str)
+	}
+
+	for _, txIn := range tx.MsgTx().TxIn {
+		parentHash := txIn.PreviousOutPoint.Hash
+		if _, ok := evictions[parentHash]; ok {
+			str := fmt.Sprintf("replacement transaction %v spends "+
+				"transaction %v which it replaces", txHash,
+				parentHash)
+			return nil, txRuleError(wire.RejectInvalid, str)
+		}
+		if _, ok := mp.pool[parentHash]; !ok {
+			continue
+		}
+		if _, ok := conflictParents[parentHash]; !ok {
+			str := fmt.Sprintf("replacement transaction %v spends "+
+				"new unconfirmed transaction %v", txHash,
+				parentHash)
+			return nil, txRuleError(wire.RejectNonstandard, str)
+		}
+	}
+
+	var evictedFees int64
+	for hash := range evictions {
+		evictedFees += mp.pool[hash].Fee
+	}
+	minFee := evictedFees + calcMinRequiredTxRelayFee(txSize,
+		mp.cfg.Policy.MinRelayTxFee)
+	if txFee < minFee {
+		str := fmt.Sprintf("replacement transaction %v has %d fees "+
+			"which is under the required amount of %d to replace "+
+			"transactions paying %d fees", txHash, txFee, minFee,
+			evictedFees)
+		return nil, txRuleError(wire.RejectInsufficientFee, str)
+	}
+
+	return evictions, nil
 }
 
 // fetchInputUtxos loads utxo details about the input transactions referenced by
@@ -657,6 +946,16 @@ func (mp *TxPool) maybeAcceptTransaction(tx *provautil.Tx, isNew, rateLimit bool
 		return nil, nil, txRuleError(wire.RejectInvalid, str)
 	}
 
+	// Flag new admin transactions which compete with the pending admin
+	// transactions or carry out unusual operations.  This happens before
+	// the double spend checks so competing transactions are flagged even
+	// though they are rejected.
+	if isNew && mp.cfg.OnAdminAlert != nil {
+		for _, alert := range mp.adminAlerts(tx) {
+			mp.cfg.OnAdminAlert(alert)
+		}
+	}
+
 	// Don't accept transactions with a lock time after the maximum int32
 	// value for now.  This is an artifact of older bitcoind clients which
 	// treated this field as an int32 and would treat anything larger
@@ -679,8 +978,7 @@ func (mp *TxPool) maybeAcceptTransaction(tx *provautil.Tx, isNew, rateLimit bool
 	// forbid their acceptance.
 	if !mp.cfg.Policy.AcceptNonStd {
 		err = checkTransactionStandard(tx, nextBlockHeight,
-			medianTimePast, mp.cfg.Policy.MinRelayTxFee,
-			mp.cfg.Policy.MaxTxVersion)
+			medianTimePast, &mp.cfg.Policy)
 		if err != nil {
 			// Attempt to extract a reject code from the error so
 			// it can be retained.  When not possible, fall back to
@@ -703,7 +1001,10 @@ func (mp *TxPool) maybeAcceptTransaction(tx *provautil.Tx, isNew, rateLimit bool
 	// at this point.  There is a more in-depth check that happens later
 	// after fetching the referenced transaction inputs from the main chain
 	// which examines the actual spend data and prevents double spends.
-	err = mp.checkPoolDoubleSpend(tx)
+	//
+	// Transactions which only conflict with replaceable transactions are
+	// checked against the replacement rules once their fee is known.
+	isReplacement, err := mp.checkPoolDoubleSpend(tx)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -785,12 +1086,37 @@ func (mp *TxPool) maybeAcceptTransaction(tx *provautil.Tx, isNew, rateLimit bool
 		return nil, nil, err
 	}
 
+	// Make sure a replacement pays enough to replace the transactions it
+	// conflicts with.
+	var evictions map[chainhash.Hash]*provautil.Tx
+	if isReplacement {
+		evictions, err = mp.validateReplacement(tx, txFee)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
 	// CheckTransactionOutputs checks outputs for state violations.
 	err = blockchain.CheckTransactionOutputs(tx, keyView, mp.cfg.ChainParams)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	// Don't allow transactions which pay to timelocked scripts before the
+	// next block is able to include them.
+	timelocksActive, err := mp.cfg.IsDeploymentActive(
+		chaincfg.DeploymentTimelocks)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = blockchain.CheckTransactionTimelocks(tx, timelocksActive)
+	if err != nil {
+		if cerr, ok := err.(blockchain.RuleError); ok {
+			return nil, nil, chainRuleError(cerr)
+		}
+		return nil, nil, err
+	}
+
 	// Don't allow transactions with non-standard inputs if the network
 	// parameters forbid their acceptance.
 	if !mp.cfg.Policy.AcceptNonStd {
@@ -905,9 +1231,24 @@ func (mp *TxPool) maybeAcceptTransaction(tx *provautil.Tx, isNew, rateLimit bool
 		return nil, nil, err
 	}
 
+	// Remove the transactions the replacement replaces along with the
+	// transactions spending their outputs.
+	for _, evicted := range evictions {
+		log.Debugf("Replacing transaction %v with %v", evicted.Hash(),
+			txHash)
+		mp.removeTransaction(evicted, false)
+	}
+
 	// Add to transaction pool.
 	txD := mp.addTransaction(utxoView, tx, bestHeight, txFee)
 
+	// Track how long it takes for new transactions to be mined in order
+	// to estimate fees.  Transactions added back from disconnected blocks
+	// are not new, so their confirmation times would be misleading.
+	if isNew && mp.cfg.FeeEstimator != nil {
+		mp.cfg.FeeEstimator.ObserveTransaction(txD)
+	}
+
 	log.Debugf("Accepted transaction %v (pool size: %v)", txHash,
 		len(mp.pool))
 
@@ -1099,6 +1440,29 @@ func (mp *TxPool) ProcessTransaction(tx *provautil.Tx, allowOrphan, rateLimit bo
 	return nil, err
 }
 
+// Policy returns the policy the pool currently applies to transactions.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Policy() Policy {
+	mp.mtx.RLock()
+	policy := mp.cfg.Policy
+	mp.mtx.RUnlock()
+
+	return policy
+}
+
+// SetPolicy replaces the policy the pool applies to transactions which are
+// processed from now on.  Transactions which are already in the pool are not
+// checked against the new policy.  The script classes of the policy must not
+// be modified afterwards.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) SetPolicy(policy Policy) {
+	mp.mtx.Lock()
+	mp.cfg.Policy = policy
+	mp.mtx.Unlock()
+}
+
 // Count returns the number of transactions in the main pool.  It does not
 // include the orphan pool.
 //
@@ -1156,7 +1520,10 @@ func (mp *TxPool) MiningDescs() []*mining.TxDesc {
 	descs := make([]*mining.TxDesc, len(mp.pool))
 	i := 0
 	for _, desc := range mp.pool {
-		descs[i] = &desc.TxDesc
+		// Copy the descriptors since the pool updates the package fees
+		// and sizes as transactions are added and removed.
+		descCopy := desc.TxDesc
+		descs[i] = &descCopy
 		i++
 	}
 	mp.mtx.RUnlock()
@@ -1211,6 +1578,141 @@ func (mp *TxPool) RawMempoolVerbose() map[string]*btcjson.GetRawMempoolVerboseRe
 	return result
 }
 
+// mempoolEntry returns the passed transaction in the pool as a fully populated
+// btcjson result.  The passed cache is used to avoid recomputing whether the
+// transactions in the pool signal replaceability.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) mempoolEntry(desc *TxDesc, bestHeight uint32, replaceable map[chainhash.Hash]bool) *btcjson.GetMempoolEntryResult {
+	// Calculate the current priority based on the inputs to the
+	// transaction.  Use zero if one or more of the input transactions
+	// can't be found for some reason.
+	tx := desc.Tx
+	var currentPriority float64
+	utxos, err := mp.fetchInputUtxos(tx)
+	if err == nil {
+		currentPriority = mining.CalcPriority(tx.MsgTx(), utxos,
+			bestHeight+1)
+	}
+
+	fee := provautil.Amount(desc.Fee).ToRMG()
+	entry := &btcjson.GetMempoolEntryResult{
+		Size:              int32(tx.MsgTx().SerializeSize()),
+		Fee:               fee,
+		ModifiedFee:       fee,
+		Time:              desc.Added.Unix(),
+		Height:            int64(desc.Height),
+		StartingPriority:  desc.StartingPriority,
+		CurrentPriority:   currentPriority,
+		DescendantCount:   desc.DescendantCount,
+		DescendantSize:    desc.DescendantSize,
+		DescendantFees:    float64(desc.DescendantFee),
+		AncestorCount:     desc.AncestorCount,
+		AncestorSize:      desc.AncestorSize,
+		AncestorFees:      float64(desc.AncestorFee),
+		Depends:           make([]string, 0),
+		SpentBy:           make([]string, 0),
+		BIP125Replaceable: mp.signalsReplacement(tx, replaceable),
+	}
+	seen := make(map[chainhash.Hash]struct{})
+	for _, txIn := range tx.MsgTx().TxIn {
+		hash := txIn.PreviousOutPoint.Hash
+		if _, ok := seen[hash]; ok || !mp.haveTransaction(&hash) {
+			continue
+		}
+		seen[hash] = struct{}{}
+		entry.Depends = append(entry.Depends, hash.String())
+	}
+	prevOut := wire.OutPoint{Hash: *tx.Hash()}
+	for i := range tx.MsgTx().TxOut {
+		prevOut.Index = uint32(i)
+		txR, exists := mp.outpoints[prevOut]
+		if !exists {
+			continue
+		}
+		if _, ok := seen[*txR.Hash()]; ok {
+			continue
+		}
+		seen[*txR.Hash()] = struct{}{}
+		entry.SpentBy = append(entry.SpentBy, txR.Hash().String())
+	}
+	sort.Strings(entry.Depends)
+	sort.Strings(entry.SpentBy)
+
+	return entry
+}
+
+// MempoolEntry returns the transaction with the passed hash in the pool as a
+// fully populated btcjson result.  An error is returned when the transaction is
+// not in the pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolEntry(txHash *chainhash.Hash) (*btcjson.GetMempoolEntryResult, error) {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	desc, exists := mp.pool[*txHash]
+	if !exists {
+		return nil, fmt.Errorf("transaction is not in the pool")
+	}
+	return mp.mempoolEntry(desc, mp.cfg.BestHeight(), nil), nil
+}
+
+// MempoolAncestors returns the transactions in the pool whose outputs the
+// transaction with the passed hash spends, directly or indirectly, as fully
+// populated btcjson results keyed by transaction hash.  An error is returned
+// when the transaction is not in the pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolAncestors(txHash *chainhash.Hash) (map[string]*btcjson.GetMempoolEntryResult, error) {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	desc, exists := mp.pool[*txHash]
+	if !exists {
+		return nil, fmt.Errorf("transaction is not in the pool")
+	}
+	ancestors := make(map[chainhash.Hash]*TxDesc)
+	mp.txAncestors(desc.Tx, ancestors)
+
+	result := make(map[string]*btcjson.GetMempoolEntryResult, len(ancestors))
+	bestHeight := mp.cfg.BestHeight()
+	replaceable := make(map[chainhash.Hash]bool)
+	for hash, ancestor := range ancestors {
+		result[hash.String()] = mp.mempoolEntry(ancestor, bestHeight,
+			replaceable)
+	}
+	return result, nil
+}
+
+// MempoolDescendants returns the transactions in the pool which spend outputs
+// of the transaction with the passed hash, directly or indirectly, as fully
+// populated btcjson results keyed by transaction hash.  An error is returned
+// when the transaction is not in the pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MempoolDescendants(txHash *chainhash.Hash) (map[string]*btcjson.GetMempoolEntryResult, error) {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	desc, exists := mp.pool[*txHash]
+	if !exists {
+		return nil, fmt.Errorf("transaction is not in the pool")
+	}
+	descendants := make(map[chainhash.Hash]*provautil.Tx)
+	mp.txDescendants(desc.Tx, descendants)
+
+	result := make(map[string]*btcjson.GetMempoolEntryResult,
+		len(descendants))
+	bestHeight := mp.cfg.BestHeight()
+	replaceable := make(map[chainhash.Hash]bool)
+	for hash := range descendants {
+		result[hash.String()] = mp.mempoolEntry(mp.pool[hash],
+			bestHeight, replaceable)
+	}
+	return result, nil
+}
+
 // LastUpdated returns the last time a transaction was added to or removed from
 // the main pool.  It does not include the orphan pool.
 //
@@ -26,6 +26,7 @@ import (
 // transations to be appear as though they are spending completely valid utxos.
 type fakeChain struct {
 	sync.RWMutex
+	params         *chaincfg.Params
 	utxos          *blockchain.UtxoViewpoint
 	currentHeight  uint32
 	medianTimePast time.Time
@@ -144,6 +145,12 @@ func (s *fakeChain) CalcSequenceLock(tx *provautil.Tx,
 	}, nil
 }
 
+// IsDeploymentActive returns whether the passed deployment is active for the
+// block after the current height associated with the fake chain instance.
+func (s *fakeChain) IsDeploymentActive(deploymentID int) (bool, error) {
+	return s.params.IsDeploymentActive(deploymentID, s.BestHeight()+1), nil
+}
+
 // spendableOutput is a convenience type that houses a particular utxo and the
 // amount associated with it.
 type spendableOutput struct {
@@ -276,6 +283,72 @@ func (p *poolHarness) CreateSignedTx(inputs []spendableOutput, numOutputs uint32
 	return provautil.NewTx(tx), nil
 }
 
+// AddCoinbaseOutputs adds a mature coinbase transaction with the requested
+// number of outputs of the provided value to the fake chain and returns its
+// outputs.  This allows creating transactions which pay fees, which the outputs
+// returned by newPoolHarness are too small for.
+func (p *poolHarness) AddCoinbaseOutputs(numOutputs uint32, value int64) ([]spendableOutput, error) {
+	coinbase, err := p.CreateCoinbaseTx(1, numOutputs)
+	if err != nil {
+		return nil, err
+	}
+	for _, txOut := range coinbase.MsgTx().TxOut {
+		txOut.Value = value
+	}
+	coinbase = provautil.NewTx(coinbase.MsgTx())
+	p.chain.utxos.AddTxOuts(coinbase, 1)
+
+	outputs := make([]spendableOutput, 0, numOutputs)
+	for i := uint32(0); i < numOutputs; i++ {
+		outputs = append(outputs, txOutToSpendableOut(coinbase, i))
+	}
+	return outputs, nil
+}
+
+// CreateFeeTx creates a new signed transaction that consumes the provided
+// inputs with the provided sequence number and pays the total input amount
+// less the provided fee to a single output.  The output is to the payment
+// script associated with the harness and all inputs are assumed to do the
+// same.
+func (p *poolHarness) CreateFeeTx(inputs []spendableOutput, fee int64, sequence uint32) (*provautil.Tx, error) {
+	var totalInput provautil.Amount
+	for _, input := range inputs {
+		totalInput += input.amount
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	for _, input := range inputs {
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: input.outPoint,
+			SignatureScript:  nil,
+			Sequence:         sequence,
+		})
+	}
+	tx.AddTxOut(&wire.TxOut{
+		PkScript: p.payScript,
+		Value:    int64(totalInput) - fee,
+	})
+
+	lookupKey := func(a provautil.Address) ([]txscript.PrivateKey, error) {
+		return []txscript.PrivateKey{
+			{Key: p.privKey1, Compressed: true},
+			{Key: p.privKey2, Compressed: true},
+		}, nil
+	}
+
+	// Sign the new transaction.
+	for i := range tx.TxIn {
+		sigScript, err := txscript.SignTxOutput(p.chainParams, tx,
+			i, int64(inputs[i].amount), p.payScript, txscript.SigHashAll, txscript.KeyClosure(lookupKey), nil)
+		if err != nil {
+			return nil, err
+		}
+		tx.TxIn[i].SignatureScript = sigScript
+	}
+
+	return provautil.NewTx(tx), nil
+}
+
 // CreateTxChain creates a chain of zero-fee transactions (each subsequent
 // transaction spends the entire amount from the previous one) with the first
 // one spending the provided outpoint.  Each transaction spends the entire
@@ -356,7 +429,10 @@ func newPoolHarness(chainParams *chaincfg.Params) (*poolHarness, []spendableOutp
 	}
 
 	// Create a new fake chain and harness bound to it.
-	chain := &fakeChain{utxos: blockchain.NewUtxoViewpoint()}
+	chain := &fakeChain{
+		params: chainParams,
+		utxos:  blockchain.NewUtxoViewpoint(),
+	}
 	harness := poolHarness{
 		privKey1:    privKey1,
 		privKey2:    privKey2,
@@ -375,20 +451,21 @@ func newPoolHarness(chainParams *chaincfg.Params) (*poolHarness, []spendableOutp
 				MinRelayTxFee:        1000, // 1 Atom per byte
 				MaxTxVersion:         1,
 			},
-			ChainParams:      chainParams,
-			FetchUtxoView:    chain.FetchUtxoView,
-			ThreadTips:       chain.ThreadTips,
-			LastKeyID:        chain.LastKeyID,
-			TotalSupply:      chain.TotalSupply,
-			GetKeyIDs:        chain.KeyIDs,
-			GetAdminKeySets:  chain.AdminKeySets,
-			BestHeight:       chain.BestHeight,
-			MedianTimePast:   chain.MedianTimePast,
-			CalcSequenceLock: chain.CalcSequenceLock,
-			SigCache:         nil,
-			HashCache:        txscript.NewHashCache(200),
-			TimeSource:       blockchain.NewMedianTime(),
-			AddrIndex:        nil,
+			ChainParams:        chainParams,
+			FetchUtxoView:      chain.FetchUtxoView,
+			ThreadTips:         chain.ThreadTips,
+			LastKeyID:          chain.LastKeyID,
+			TotalSupply:        chain.TotalSupply,
+			GetKeyIDs:          chain.KeyIDs,
+			GetAdminKeySets:    chain.AdminKeySets,
+			BestHeight:         chain.BestHeight,
+			MedianTimePast:     chain.MedianTimePast,
+			CalcSequenceLock:   chain.CalcSequenceLock,
+			IsDeploymentActive: chain.IsDeploymentActive,
+			SigCache:           nil,
+			HashCache:          txscript.NewHashCache(200),
+			TimeSource:         blockchain.NewMedianTime(),
+			AddrIndex:          nil,
 		}),
 	}
 
@@ -862,3 +939,386 @@ func TestMultiInputOrphanDoubleSpend(t *testing.T) {
 	// was not moved to the transaction pool.
 	testPoolMembership(tc, doubleSpendTx, false, false)
 }
+
+// TestSetPolicy ensures the pool applies a replaced policy to the transactions
+// which are processed afterwards.
+func TestSetPolicy(t *testing.T) {
+	t.Parallel()
+
+	harness, outputs, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	// Disallow free transactions by setting the free transaction relay
+	// limit to zero.
+	policy := harness.txPool.Policy()
+	policy.FreeTxRelayLimit = 0
+	harness.txPool.SetPolicy(policy)
+	if got := harness.txPool.Policy(); !reflect.DeepEqual(got, policy) {
+		t.Fatalf("Policy: unexpected policy - got %+v, want %+v", got,
+			policy)
+	}
+
+	// Ensure a free transaction is now rejected by the rate limiter.
+	chainedTxns, err := harness.CreateTxChain(outputs[0], 1)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	_, err = harness.txPool.ProcessTransaction(chainedTxns[0], false,
+		true, 0)
+	if err == nil {
+		t.Fatalf("ProcessTransaction: accepted free transaction " +
+			"despite a zero free transaction relay limit")
+	}
+	testPoolMembership(tc, chainedTxns[0], false, false)
+}
+
+// TestReplaceByFee ensures transactions which spend outputs already spent by
+// transactions in the pool replace them when the replaced transactions signal
+// replaceability and the replacement satisfies the replacement rules, and are
+// rejected otherwise.
+func TestReplaceByFee(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	tc := &testContext{t, harness}
+
+	// Use outputs large enough to pay fees so each case can spend its
+	// own output.
+	outs, err := harness.AddCoinbaseOutputs(8, 100000)
+	if err != nil {
+		t.Fatalf("unable to create coinbase: %v", err)
+	}
+
+	mustCreate := func(inputs []spendableOutput, fee int64, sequence uint32) *provautil.Tx {
+		tx, err := harness.CreateFeeTx(inputs, fee, sequence)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		return tx
+	}
+	mustAccept := func(tx *provautil.Tx) {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept "+
+				"transaction %v: %v", tx.Hash(), err)
+		}
+	}
+	mustReject := func(tx *provautil.Tx) {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err == nil {
+			t.Fatalf("ProcessTransaction: accepted invalid "+
+				"replacement %v", tx.Hash())
+		}
+	}
+
+	// A replaceable transaction is replaced by a transaction paying enough
+	// more fees.
+	tx := mustCreate(outs[0:1], 1000, MaxRBFSequence)
+	mustAccept(tx)
+	replacement := mustCreate(outs[0:1], 5000, wire.MaxTxInSequenceNum)
+	mustAccept(replacement)
+	testPoolMembership(tc, tx, false, false)
+	testPoolMembership(tc, replacement, false, true)
+
+	// A transaction which does not signal replaceability is not replaced.
+	tx = mustCreate(outs[1:2], 1000, wire.MaxTxInSequenceNum)
+	mustAccept(tx)
+	mustReject(mustCreate(outs[1:2], 5000, wire.MaxTxInSequenceNum))
+	testPoolMembership(tc, tx, false, true)
+
+	// A replacement with a higher fee rate which does not pay the fees of
+	// the replaced transaction plus the relay fee for its own size is
+	// rejected.
+	tx = mustCreate(outs[2:3], 1000, MaxRBFSequence)
+	mustAccept(tx)
+	mustReject(mustCreate(outs[2:3], 1100, wire.MaxTxInSequenceNum))
+	testPoolMembership(tc, tx, false, true)
+
+	// Replacing a transaction evicts the transactions spending its outputs
+	// as well, and transactions inherit replaceability from the
+	// transactions in the pool they spend.
+	tx = mustCreate(outs[3:4], 1000, MaxRBFSequence)
+	mustAccept(tx)
+	child := mustCreate([]spendableOutput{txOutToSpendableOut(tx, 0)},
+		1000, wire.MaxTxInSequenceNum)
+	mustAccept(child)
+	childReplacement := mustCreate([]spendableOutput{
+		txOutToSpendableOut(tx, 0)}, 5000, wire.MaxTxInSequenceNum)
+	mustAccept(childReplacement)
+	testPoolMembership(tc, child, false, false)
+	testPoolMembership(tc, childReplacement, false, true)
+	replacement = mustCreate(outs[3:4], 10000, wire.MaxTxInSequenceNum)
+	mustAccept(replacement)
+	testPoolMembership(tc, tx, false, false)
+	testPoolMembership(tc, childReplacement, false, false)
+	testPoolMembership(tc, replacement, false, true)
+
+	// A replacement may not spend outputs of transactions in the pool the
+	// replaced transactions don't spend.
+	unconfirmed := mustCreate(outs[4:5], 1000, wire.MaxTxInSequenceNum)
+	mustAccept(unconfirmed)
+	tx = mustCreate(outs[5:6], 1000, MaxRBFSequence)
+	mustAccept(tx)
+	mustReject(mustCreate([]spendableOutput{outs[5],
+		txOutToSpendableOut(unconfirmed, 0)}, 10000,
+		wire.MaxTxInSequenceNum))
+	testPoolMembership(tc, tx, false, true)
+
+	// No transaction is replaced when the policy rejects replacements.
+	harness.txPool.cfg.Policy.RejectReplacement = true
+	tx = mustCreate(outs[6:7], 1000, MaxRBFSequence)
+	mustAccept(tx)
+	mustReject(mustCreate(outs[6:7], 5000, wire.MaxTxInSequenceNum))
+	testPoolMembership(tc, tx, false, true)
+}
+
+// TestPackageStats ensures the pool tracks the total fees and sizes of the
+// ancestors and descendants of its transactions as transactions are added and
+// removed.
+func TestPackageStats(t *testing.T) {
+	t.Parallel()
+
+	harness, _, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	outs, err := harness.AddCoinbaseOutputs(1, 100000)
+	if err != nil {
+		t.Fatalf("unable to create coinbase: %v", err)
+	}
+
+	// Create a chain of a parent, child and grandchild paying different
+	// fees.
+	fees := []int64{1000, 5000, 2000}
+	var txns []*provautil.Tx
+	var sizes []int64
+	inputs := outs
+	for _, fee := range fees {
+		tx, err := harness.CreateFeeTx(inputs, fee, wire.MaxTxInSequenceNum)
+		if err != nil {
+			t.Fatalf("unable to create transaction: %v", err)
+		}
+		_, err = harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept "+
+				"transaction: %v", err)
+		}
+		txns = append(txns, tx)
+		sizes = append(sizes, int64(tx.MsgTx().SerializeSize()))
+		inputs = []spendableOutput{txOutToSpendableOut(tx, 0)}
+	}
+
+	checkStats := func(desc string, tx *provautil.Tx, ancestorFee,
+		ancestorSize, descendantFee, descendantSize int64) {
+
+		harness.txPool.mtx.RLock()
+		txD := harness.txPool.pool[*tx.Hash()]
+		harness.txPool.mtx.RUnlock()
+		if txD.AncestorFee != ancestorFee ||
+			txD.AncestorSize != ancestorSize {
+
+			t.Fatalf("%s: got ancestor fee %d and size %d, want "+
+				"%d and %d", desc, txD.AncestorFee,
+				txD.AncestorSize, ancestorFee, ancestorSize)
+		}
+		if txD.DescendantFee != descendantFee ||
+			txD.DescendantSize != descendantSize {
+
+			t.Fatalf("%s: got descendant fee %d and size %d, "+
+				"want %d and %d", desc, txD.DescendantFee,
+				txD.DescendantSize, descendantFee,
+				descendantSize)
+		}
+	}
+	totalSize := sizes[0] + sizes[1] + sizes[2]
+	checkStats("parent", txns[0], 1000, sizes[0], 8000, totalSize)
+	checkStats("child", txns[1], 6000, sizes[0]+sizes[1], 7000,
+		sizes[1]+sizes[2])
+	checkStats("grandchild", txns[2], 8000, totalSize, 2000, sizes[2])
+
+	// Removing the parent as if it was mined must remove it from the
+	// packages of the remaining transactions.
+	harness.txPool.RemoveTransaction(txns[0], false)
+	checkStats("child after removal", txns[1], 5000, sizes[1], 7000,
+		sizes[1]+sizes[2])
+	checkStats("grandchild after removal", txns[2], 7000,
+		sizes[1]+sizes[2], 2000, sizes[2])
+}
+
+// TestMempoolEntry ensures the pool describes its transactions along with their
+// ancestors and descendants, and rejects transactions which are not in it.
+func TestMempoolEntry(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	chainedTxns, err := harness.CreateTxChain(spendableOuts[0], 3)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range chainedTxns {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept valid "+
+				"transaction %v", err)
+		}
+	}
+
+	// The middle transaction of the chain has one ancestor and one
+	// descendant.
+	parent, middle, child := chainedTxns[0], chainedTxns[1], chainedTxns[2]
+	entry, err := harness.txPool.MempoolEntry(middle.Hash())
+	if err != nil {
+		t.Fatalf("MempoolEntry: unexpected error: %v", err)
+	}
+	size := int64(middle.MsgTx().SerializeSize())
+	if entry.Size != int32(size) || entry.AncestorCount != 2 ||
+		entry.DescendantCount != 2 {
+
+		t.Fatalf("MempoolEntry: got size %d, ancestor count %d and "+
+			"descendant count %d, want %d, 2 and 2", entry.Size,
+			entry.AncestorCount, entry.DescendantCount, size)
+	}
+	wantAncestorSize := size + int64(parent.MsgTx().SerializeSize())
+	if entry.AncestorSize != wantAncestorSize {
+		t.Fatalf("MempoolEntry: got ancestor size %d, want %d",
+			entry.AncestorSize, wantAncestorSize)
+	}
+	if len(entry.Depends) != 1 || entry.Depends[0] != parent.Hash().String() {
+		t.Fatalf("MempoolEntry: got depends %v, want [%v]",
+			entry.Depends, parent.Hash())
+	}
+	if len(entry.SpentBy) != 1 || entry.SpentBy[0] != child.Hash().String() {
+		t.Fatalf("MempoolEntry: got spent by %v, want [%v]",
+			entry.SpentBy, child.Hash())
+	}
+	if entry.BIP125Replaceable {
+		t.Fatal("MempoolEntry: transaction with final sequence " +
+			"numbers is replaceable")
+	}
+
+	// The ancestors of the last transaction are the other two, and the
+	// descendants of the first one are the other two.
+	ancestors, err := harness.txPool.MempoolAncestors(child.Hash())
+	if err != nil {
+		t.Fatalf("MempoolAncestors: unexpected error: %v", err)
+	}
+	descendants, err := harness.txPool.MempoolDescendants(parent.Hash())
+	if err != nil {
+		t.Fatalf("MempoolDescendants: unexpected error: %v", err)
+	}
+	if len(ancestors) != 2 || ancestors[parent.Hash().String()] == nil ||
+		ancestors[middle.Hash().String()] == nil {
+
+		t.Fatalf("MempoolAncestors: unexpected ancestors %v", ancestors)
+	}
+	if len(descendants) != 2 || descendants[middle.Hash().String()] == nil ||
+		descendants[child.Hash().String()] == nil {
+
+		t.Fatalf("MempoolDescendants: unexpected descendants %v",
+			descendants)
+	}
+
+	// Transactions which are not in the pool must be rejected.
+	unknown := &chainhash.Hash{0x01}
+	if _, err := harness.txPool.MempoolEntry(unknown); err == nil {
+		t.Fatal("MempoolEntry: no error for unknown transaction")
+	}
+	if _, err := harness.txPool.MempoolAncestors(unknown); err == nil {
+		t.Fatal("MempoolAncestors: no error for unknown transaction")
+	}
+	if _, err := harness.txPool.MempoolDescendants(unknown); err == nil {
+		t.Fatal("MempoolDescendants: no error for unknown transaction")
+	}
+}
+
+// TestTimelockedOutputs ensures transactions which pay to timelocked Prova
+// scripts are only accepted once the timelock deployment is active for the
+// next block.
+func TestTimelockedOutputs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		params   *chaincfg.Params
+		accepted bool
+	}{
+		{"timelocks not active", &chaincfg.MainNetParams, false},
+		{"timelocks active", &chaincfg.SimNetParams, true},
+	}
+
+	for _, test := range tests {
+		harness, _, err := newPoolHarness(test.params)
+		if err != nil {
+			t.Fatalf("%s: unable to create test pool: %v", test.name,
+				err)
+		}
+		tc := &testContext{t, harness}
+		outputs, err := harness.AddCoinbaseOutputs(1, 100000000)
+		if err != nil {
+			t.Fatalf("%s: unable to add coinbase outputs: %v",
+				test.name, err)
+		}
+
+		pkScript, err := txscript.PayToAddrLockTimeScript(
+			harness.payAddr, 500000)
+		if err != nil {
+			t.Fatalf("%s: unable to create timelocked script: %v",
+				test.name, err)
+		}
+		tx := wire.NewMsgTx(wire.TxVersion)
+		tx.AddTxIn(&wire.TxIn{
+			PreviousOutPoint: outputs[0].outPoint,
+			Sequence:         wire.MaxTxInSequenceNum,
+		})
+		tx.AddTxOut(&wire.TxOut{
+			PkScript: pkScript,
+			Value:    int64(outputs[0].amount) - 10000,
+		})
+		lookupKey := func(a provautil.Address) ([]txscript.PrivateKey, error) {
+			return []txscript.PrivateKey{
+				{Key: harness.privKey1, Compressed: true},
+				{Key: harness.privKey2, Compressed: true},
+			}, nil
+		}
+		sigScript, err := txscript.SignTxOutput(test.params, tx, 0,
+			int64(outputs[0].amount), harness.payScript,
+			txscript.SigHashAll, txscript.KeyClosure(lookupKey), nil)
+		if err != nil {
+			t.Fatalf("%s: unable to sign transaction: %v", test.name,
+				err)
+		}
+		tx.TxIn[0].SignatureScript = sigScript
+
+		timelockedTx := provautil.NewTx(tx)
+		_, err = harness.txPool.ProcessTransaction(timelockedTx, false,
+			false, 0)
+		if test.accepted {
+			if err != nil {
+				t.Fatalf("%s: ProcessTransaction: unexpected "+
+					"error: %v", test.name, err)
+			}
+			testPoolMembership(tc, timelockedTx, false, true)
+			continue
+		}
+		rerr, ok := err.(RuleError)
+		if !ok {
+			t.Fatalf("%s: ProcessTransaction: got %v, want a rule "+
+				"error", test.name, err)
+		}
+		cerr, ok := rerr.Err.(blockchain.RuleError)
+		if !ok || cerr.ErrorCode != blockchain.ErrTimelockNotActive {
+			t.Fatalf("%s: ProcessTransaction: got %v, want %v",
+				test.name, rerr.Err, blockchain.ErrTimelockNotActive)
+		}
+		testPoolMembership(tc, timelockedTx, false, false)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// persistedPoolMagic identifies the start of saved memory pool
+	// transactions.
+	persistedPoolMagic uint32 = 0x6c6f6f70
+
+	// persistedPoolVersion is the current version of the format of saved
+	// memory pool transactions.
+	persistedPoolVersion uint32 = 1
+)
+
+// -----------------------------------------------------------------------------
+// The transactions in the memory pool are saved so they survive a restart of
+// the node.
+//
+// The serialized format is:
+//
+//   <magic><version><num txns><entries>
+//
+//   Field             Type               Size
+//   magic             uint32             4 bytes
+//   version           uint32             4 bytes
+//   num txns          uint32             4 bytes
+//   entries           []entry            variable
+//
+// The integers are little-endian.  Each entry is:
+//
+//   <added><tx>
+//
+//   Field             Type               Size
+//   added             int64              8 bytes
+//   tx                wire.MsgTx         variable
+//
+// where added is the time the transaction was added to the memory pool in
+// seconds since the epoch.  Every transaction comes after the transactions in
+// the memory pool whose outputs it spends, so the transactions can be added back
+// in order without going through the orphan pool.
+// -----------------------------------------------------------------------------
+
+// SaveTransactions writes the transactions in the memory pool to w in a format
+// which can be read back with LoadTransactions.  It returns the number of
+// written transactions.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) SaveTransactions(w io.Writer) (int, error) {
+	mp.mtx.RLock()
+	descs := make([]*TxDesc, 0, len(mp.pool))
+	for _, desc := range mp.pool {
+		descs = append(descs, desc)
+	}
+
+	// Order the transactions by the time they were added and put the
+	// transactions they spend from the pool before them.
+	sort.Slice(descs, func(i, j int) bool {
+		if !descs[i].Added.Equal(descs[j].Added) {
+			return descs[i].Added.Before(descs[j].Added)
+		}
+		return bytes.Compare(descs[i].Tx.Hash()[:],
+			descs[j].Tx.Hash()[:]) < 0
+	})
+	ordered := make([]*TxDesc, 0, len(descs))
+	visited := make(map[chainhash.Hash]struct{}, len(descs))
+	var visit func(desc *TxDesc)
+	visit = func(desc *TxDesc) {
+		if _, ok := visited[*desc.Tx.Hash()]; ok {
+			return
+		}
+		visited[*desc.Tx.Hash()] = struct{}{}
+		for _, txIn := range desc.Tx.MsgTx().TxIn {
+			parent, ok := mp.pool[txIn.PreviousOutPoint.Hash]
+			if ok {
+				visit(parent)
+			}
+		}
+		ordered = append(ordered, desc)
+	}
+	for _, desc := range descs {
+		visit(desc)
+	}
+	mp.mtx.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	var buf [8]byte
+	binary.LittleEndian.PutUint32(buf[:4], persistedPoolMagic)
+	bw.Write(buf[:4])
+	binary.LittleEndian.PutUint32(buf[:4], persistedPoolVersion)
+	bw.Write(buf[:4])
+	binary.LittleEndian.PutUint32(buf[:4], uint32(len(ordered)))
+	bw.Write(buf[:4])
+	for _, desc := range ordered {
+		binary.LittleEndian.PutUint64(buf[:], uint64(desc.Added.Unix()))
+		bw.Write(buf[:])
+		if err := desc.Tx.MsgTx().Serialize(bw); err != nil {
+			return 0, err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+
+	return len(ordered), nil
+}
+
+// LoadTransactions reads the transactions written by SaveTransactions from r
+// and adds the ones which are still valid against the current chain to the
+// memory pool along with the time they were originally added.  The
+// transactions are not rate limited.  It returns the number of accepted and
+// rejected transactions.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) LoadTransactions(r io.Reader) (int, int, error) {
+	br := bufio.NewReader(r)
+	var buf [8]byte
+	if _, err := io.ReadFull(br, buf[:4]); err != nil {
+		return 0, 0, err
+	}
+	if magic := binary.LittleEndian.Uint32(buf[:4]); magic != persistedPoolMagic {
+		return 0, 0, fmt.Errorf("not saved memory pool transactions "+
+			"(magic %08x)", magic)
+	}
+	if _, err := io.ReadFull(br, buf[:4]); err != nil {
+		return 0, 0, err
+	}
+	if version := binary.LittleEndian.Uint32(buf[:4]); version != persistedPoolVersion {
+		return 0, 0, fmt.Errorf("unsupported version %d of saved "+
+			"memory pool transactions", version)
+	}
+	if _, err := io.ReadFull(br, buf[:4]); err != nil {
+		return 0, 0, err
+	}
+	numTxns := binary.LittleEndian.Uint32(buf[:4])
+
+	var accepted, rejected int
+	for i := uint32(0); i < numTxns; i++ {
+		if _, err := io.ReadFull(br, buf[:]); err != nil {
+			return accepted, rejected, err
+		}
+		added := time.Unix(int64(binary.LittleEndian.Uint64(buf[:])), 0)
+		var msgTx wire.MsgTx
+		if err := msgTx.Deserialize(br); err != nil {
+			return accepted, rejected, err
+		}
+		tx := provautil.NewTx(&msgTx)
+
+		mp.mtx.Lock()
+		missingParents, txD, err := mp.maybeAcceptTransaction(tx, true,
+			false, true)
+		if err == nil && len(missingParents) == 0 {
+			txD.Added = added
+		}
+		mp.mtx.Unlock()
+		if err != nil || len(missingParents) > 0 {
+			if err == nil {
+				err = fmt.Errorf("missing parent transaction %v",
+					missingParents[0])
+			}
+			log.Debugf("Rejected saved transaction %v: %v",
+				tx.Hash(), err)
+			rejected++
+			continue
+		}
+		accepted++
+	}
+
+	return accepted, rejected, nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg"
+)
+
+// TestSaveLoadTransactions ensures the transactions saved from a memory pool
+// are added back to another memory pool along with the times they were added,
+// and that the ones which are not valid anymore are rejected.
+func TestSaveLoadTransactions(t *testing.T) {
+	t.Parallel()
+
+	harness, spendableOuts, err := newPoolHarness(&chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("unable to create test pool: %v", err)
+	}
+	chainedTxns, err := harness.CreateTxChain(spendableOuts[0], 5)
+	if err != nil {
+		t.Fatalf("unable to create transaction chain: %v", err)
+	}
+	for _, tx := range chainedTxns {
+		_, err := harness.txPool.ProcessTransaction(tx, false, false, 0)
+		if err != nil {
+			t.Fatalf("ProcessTransaction: failed to accept valid "+
+				"transaction %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	numTxns, err := harness.txPool.SaveTransactions(&buf)
+	if err != nil {
+		t.Fatalf("SaveTransactions: unexpected error: %v", err)
+	}
+	if numTxns != len(chainedTxns) {
+		t.Fatalf("SaveTransactions: saved %d transactions, want %d",
+			numTxns, len(chainedTxns))
+	}
+	saved := buf.Bytes()
+
+	// Load the transactions into a new pool bound to the same chain.
+	cfg := harness.txPool.cfg
+	pool := New(&cfg)
+	accepted, rejected, err := pool.LoadTransactions(bytes.NewReader(saved))
+	if err != nil {
+		t.Fatalf("LoadTransactions: unexpected error: %v", err)
+	}
+	if accepted != len(chainedTxns) || rejected != 0 {
+		t.Fatalf("LoadTransactions: got %d accepted and %d rejected, "+
+			"want %d and 0", accepted, rejected, len(chainedTxns))
+	}
+	for _, tx := range chainedTxns {
+		if !pool.IsTransactionInPool(tx.Hash()) {
+			t.Fatalf("LoadTransactions: transaction %v is not in "+
+				"the pool", tx.Hash())
+		}
+		want := harness.txPool.pool[*tx.Hash()].Added.Unix()
+		if got := pool.pool[*tx.Hash()].Added.Unix(); got != want {
+			t.Fatalf("LoadTransactions: transaction %v added at %d, "+
+				"want %d", tx.Hash(), got, want)
+		}
+	}
+
+	// Loading the transactions again must reject all of them since they
+	// are already in the pool.
+	accepted, rejected, err = pool.LoadTransactions(bytes.NewReader(saved))
+	if err != nil {
+		t.Fatalf("LoadTransactions: unexpected error: %v", err)
+	}
+	if accepted != 0 || rejected != len(chainedTxns) {
+		t.Fatalf("LoadTransactions: got %d accepted and %d rejected, "+
+			"want 0 and %d", accepted, rejected, len(chainedTxns))
+	}
+
+	// Data which was not written by SaveTransactions must be rejected.
+	corrupt := append([]byte{0x00}, saved[1:]...)
+	_, _, err = pool.LoadTransactions(bytes.NewReader(corrupt))
+	if err == nil {
+		t.Fatal("LoadTransactions: no error for data with a bad magic")
+	}
+}
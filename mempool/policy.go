@@ -105,6 +105,8 @@ func checkInputsStandard(tx *provautil.Tx, utxoView *blockchain.UtxoViewpoint) e
 		case txscript.ProvaTy:
 			fallthrough
 		case txscript.GeneralProvaTy:
+			fallthrough
+		case txscript.ProvaTimelockTy:
 			break
 		case txscript.ProvaAdminTy:
 			sigPops, err := txscript.ParseScript(txIn.SignatureScript)
@@ -169,6 +171,8 @@ func checkPkScriptStandard(pkScript []byte, scriptClass txscript.ScriptClass) er
 	case txscript.ProvaTy:
 		fallthrough
 	case txscript.GeneralProvaTy:
+		fallthrough
+	case txscript.ProvaTimelockTy:
 		break
 	case txscript.ProvaAdminTy:
 		// TODO(prova): apply validation rules here
@@ -251,25 +255,57 @@ func isDust(txOut *wire.TxOut, minRelayTxFee provautil.Amount) bool {
 	return txOut.Value*1000/(3*int64(totalSize)) < int64(minRelayTxFee)
 }
 
+// isStandardScriptClass returns whether outputs with public key scripts of the
+// passed class are standard according to the policy.
+func (p *Policy) isStandardScriptClass(scriptClass txscript.ScriptClass) bool {
+	if len(p.StandardScriptClasses) == 0 {
+		return true
+	}
+	for _, class := range p.StandardScriptClasses {
+		if class == scriptClass {
+			return true
+		}
+	}
+	return false
+}
+
+// maxDataCarrierSize returns the maximum number of bytes a standard data
+// carrier output may carry according to the policy.
+func (p *Policy) maxDataCarrierSize() int {
+	if p.MaxDataCarrierSize == 0 {
+		return txscript.MaxDataCarrierSize
+	}
+	return p.MaxDataCarrierSize
+}
+
+// isDust returns whether or not the passed transaction output is considered
+// dust according to the policy.
+func (p *Policy) isDust(txOut *wire.TxOut) bool {
+	if txOut.Value < int64(p.DustThreshold) {
+		return true
+	}
+	return isDust(txOut, p.MinRelayTxFee)
+}
+
 // checkTransactionStandard performs a series of checks on a transaction to
 // ensure it is a "standard" transaction.  A standard transaction is one that
 // conforms to several additional limiting cases over what is considered a
 // "sane" transaction such as having a version in the supported range, being
 // finalized, conforming to more stringent size constraints, having scripts
 // of recognized forms, and not containing "dust" outputs (those that are
-// so small it costs more to process them than they are worth).
+// so small it costs more to process them than they are worth).  The limits
+// which can be tuned at runtime are taken from the passed policy.
 // TODO(prova): Notice that this code is a dupclicate of transaction
 // validation code in CheckTransactionSanity() of validate.go
 // TODO(prova): extract functionality into admin tx validator.
 func checkTransactionStandard(tx *provautil.Tx, height uint32,
-	medianTimePast time.Time, minRelayTxFee provautil.Amount,
-	maxTxVersion int32) error {
+	medianTimePast time.Time, policy *Policy) error {
 	// The transaction must be a currently supported version.
 	msgTx := tx.MsgTx()
-	if msgTx.Version > maxTxVersion || msgTx.Version < 1 {
+	if msgTx.Version > policy.MaxTxVersion || msgTx.Version < 1 {
 		str := fmt.Sprintf("transaction version %d is not in the "+
 			"valid range of %d-%d", msgTx.Version, 1,
-			maxTxVersion)
+			policy.MaxTxVersion)
 		return txRuleError(wire.RejectNonstandard, str)
 	}
 
@@ -333,6 +369,15 @@ func checkTransactionStandard(tx *provautil.Tx, height uint32,
 			return txRuleError(rejectCode, str)
 		}
 
+		// The policy may restrict the script classes of the outputs
+		// of transactions which are not admin transactions, since
+		// admin transactions are subject to the rules of their thread.
+		if !hasAdminOut && !policy.isStandardScriptClass(scriptClass) {
+			str := fmt.Sprintf("transaction output %d: script class "+
+				"%v is not standard", txInIndex, scriptClass)
+			return txRuleError(wire.RejectNonstandard, str)
+		}
+
 		// Only first output can be admin output
 		if scriptClass == txscript.ProvaAdminTy {
 			if txInIndex != 0 {
@@ -357,7 +402,25 @@ func checkTransactionStandard(tx *provautil.Tx, height uint32,
 		// "dust".
 		if scriptClass == txscript.NullDataTy {
 			numNullDataOutputs++
-		} else if !tx.IsCoinbase() && !hasAdminOut && isDust(txOut, minRelayTxFee) {
+
+			// The data carried must not exceed the size allowed by
+			// the policy.  Admin operations are exempt.
+			if !hasAdminOut {
+				pushes, _ := txscript.PushedData(txOut.PkScript)
+				dataLen := 0
+				for _, data := range pushes {
+					dataLen += len(data)
+				}
+				if dataLen > policy.maxDataCarrierSize() {
+					str := fmt.Sprintf("transaction output %d: "+
+						"data carrier size of %d bytes is "+
+						"larger than max allowed size of %d "+
+						"bytes", txInIndex, dataLen,
+						policy.maxDataCarrierSize())
+					return txRuleError(wire.RejectNonstandard, str)
+				}
+			}
+		} else if !tx.IsCoinbase() && !hasAdminOut && policy.isDust(txOut) {
 			str := fmt.Sprintf("transaction output %d: payment "+
 				"of %d is dust", txInIndex, txOut.Value)
 			return txRuleError(wire.RejectDust, str)
@@ -328,6 +328,10 @@ func TestCheckTransactionStandard(t *testing.T) {
 		Value:    0,
 		PkScript: adminOpPkScript,
 	}
+	// Create a data carrier output script carrying 40 bytes.
+	dataCarrierPkScript, _ := txscript.NullDataScript(
+		bytes.Repeat([]byte{0x01}, 40))
+
 	// create root tx out
 	rootPkScript, _ := txscript.ProvaThreadScript(provautil.RootThread)
 	rootTxOut := wire.TxOut{
@@ -345,6 +349,7 @@ func TestCheckTransactionStandard(t *testing.T) {
 		name       string
 		tx         wire.MsgTx
 		height     uint32
+		policy     *Policy // nil for the default policy
 		isStandard bool
 		code       wire.RejectCode
 	}{
@@ -587,13 +592,106 @@ func TestCheckTransactionStandard(t *testing.T) {
 			isStandard: false,
 			code:       wire.RejectInvalid,
 		},
+		{
+			name: "Output below the dust threshold of the policy",
+			tx: wire.MsgTx{
+				Version:  1,
+				TxIn:     []*wire.TxIn{&dummyTxIn},
+				TxOut:    []*wire.TxOut{&dummyTxOut},
+				LockTime: 0,
+			},
+			height: 300000,
+			policy: &Policy{
+				MaxTxVersion:  1,
+				DustThreshold: provautil.Amount(dummyTxOut.Value + 1),
+			},
+			isStandard: false,
+			code:       wire.RejectDust,
+		},
+		{
+			name: "Script class not standard by the policy",
+			tx: wire.MsgTx{
+				Version:  1,
+				TxIn:     []*wire.TxIn{&dummyTxIn},
+				TxOut:    []*wire.TxOut{&dummyTxOut},
+				LockTime: 0,
+			},
+			height: 300000,
+			policy: &Policy{
+				MaxTxVersion: 1,
+				StandardScriptClasses: []txscript.ScriptClass{
+					txscript.NullDataTy,
+				},
+			},
+			isStandard: false,
+			code:       wire.RejectNonstandard,
+		},
+		{
+			name: "Admin transaction with script classes restricted " +
+				"by the policy",
+			tx: wire.MsgTx{
+				Version:  1,
+				TxIn:     []*wire.TxIn{&dummyTxIn},
+				TxOut:    []*wire.TxOut{&rootTxOut, &adminOpTxOut},
+				LockTime: 0,
+			},
+			height: 300000,
+			policy: &Policy{
+				MaxTxVersion: 1,
+				StandardScriptClasses: []txscript.ScriptClass{
+					txscript.ProvaTy,
+				},
+			},
+			isStandard: true,
+		},
+		{
+			name: "Nulldata output at the data carrier size of the policy",
+			tx: wire.MsgTx{
+				Version: 1,
+				TxIn:    []*wire.TxIn{&dummyTxIn},
+				TxOut: []*wire.TxOut{{
+					Value:    0,
+					PkScript: dataCarrierPkScript,
+				}},
+				LockTime: 0,
+			},
+			height:     300000,
+			policy:     &Policy{MaxTxVersion: 1, MaxDataCarrierSize: 40},
+			isStandard: true,
+		},
+		{
+			name: "Nulldata output above the data carrier size of " +
+				"the policy",
+			tx: wire.MsgTx{
+				Version: 1,
+				TxIn:    []*wire.TxIn{&dummyTxIn},
+				TxOut: []*wire.TxOut{{
+					Value:    0,
+					PkScript: dataCarrierPkScript,
+				}},
+				LockTime: 0,
+			},
+			height:     300000,
+			policy:     &Policy{MaxTxVersion: 1, MaxDataCarrierSize: 39},
+			isStandard: false,
+			code:       wire.RejectNonstandard,
+		},
 	}
 
+	defaultPolicy := Policy{
+		MinRelayTxFee: DefaultMinRelayTxFee,
+		MaxTxVersion:  1,
+	}
 	pastMedianTime := time.Now()
 	for _, test := range tests {
+		policy := &defaultPolicy
+		if test.policy != nil {
+			policy = test.policy
+		}
+
 		// Ensure standardness is as expected.
 		err := checkTransactionStandard(provautil.NewTx(&test.tx),
-			test.height, pastMedianTime, DefaultMinRelayTxFee, 1)
+			test.height, pastMedianTime, policy)
 		if err == nil && test.isStandard {
 			// Test passes since function returned standard for a
 			// transaction which is intended to be standard.
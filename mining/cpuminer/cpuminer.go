@@ -50,8 +50,57 @@ var (
 	// and is based on the number of processor cores.  This helps ensure the
 	// system stays reasonably responsive under heavy load.
 	defaultNumWorkers = uint32(runtime.NumCPU())
+
+	// errRateLimited is returned when all of the validate keys have signed
+	// their permitted share of the recent blocks.
+	errRateLimited = errors.New("block generation rate limited -- all " +
+		"validate keys have signed their permitted share of the recent " +
+		"blocks")
+)
+
+// ValidateKeyPolicy determines how the validate key which signs a generated
+// block is chosen among the validate keys which are not rate limited.
+type ValidateKeyPolicy int
+
+const (
+	// RandomValidateKey chooses one of the keys at random.
+	RandomValidateKey ValidateKeyPolicy = iota
+
+	// RoundRobinValidateKey chooses the keys in turn in the order they
+	// were set.
+	RoundRobinValidateKey
+
+	// LowestShareValidateKey chooses the key which signed the fewest of
+	// the recent blocks, and thus is furthest from its rate limit.  Ties
+	// are broken at random.
+	LowestShareValidateKey
 )
 
+// validateKeyPolicyStrings maps the validate key policies to their names.
+var validateKeyPolicyStrings = map[ValidateKeyPolicy]string{
+	RandomValidateKey:      "random",
+	RoundRobinValidateKey:  "roundrobin",
+	LowestShareValidateKey: "lowestshare",
+}
+
+// String returns the ValidateKeyPolicy in human-readable form.
+func (p ValidateKeyPolicy) String() string {
+	if s, ok := validateKeyPolicyStrings[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown ValidateKeyPolicy (%d)", int(p))
+}
+
+// ParseValidateKeyPolicy returns the validate key policy with the passed name.
+func ParseValidateKeyPolicy(name string) (ValidateKeyPolicy, error) {
+	for policy, s := range validateKeyPolicyStrings {
+		if s == name {
+			return policy, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown validate key policy %q", name)
+}
+
 // Config is a descriptor containing the cpu miner configuration.
 type Config struct {
 	// ChainParams identifies which chain parameters the cpu miner is
@@ -94,6 +143,20 @@ type Config struct {
 	// AdminKeySets defines the function to use to retrieve the
 	// admin key sets
 	AdminKeySets func() map[btcec.KeySetType]btcec.PublicKeySet
+
+	// ValidateKeys are the initial validate keys used to sign generated
+	// blocks.  They can be signers which keep the private key elsewhere,
+	// such as in an HSM or a remote signing service.
+	ValidateKeys []wire.BlockSigner
+
+	// ValidateKeyPolicy determines which of the validate keys which are
+	// not rate limited signs each generated block.
+	ValidateKeyPolicy ValidateKeyPolicy
+
+	// ValidateKeyShare defines the function to use to determine how many
+	// of the recent blocks were signed with a validate key.  It is only
+	// required by the LowestShareValidateKey policy.
+	ValidateKeyShare func(validatePubKey wire.BlockValidatingPubKey) (int, error)
 }
 
 // CPUMiner provides facilities for solving blocks (mining) using the CPU in
@@ -107,7 +170,8 @@ type CPUMiner struct {
 	g                 *mining.BlkTmplGenerator
 	cfg               Config
 	numWorkers        uint32
-	validateKeys      []*btcec.PrivateKey
+	validateKeys      []wire.BlockSigner
+	nextValidateKey   int // protected by submitBlockLock
 	started           bool
 	discreteMining    bool
 	submitBlockLock   sync.Mutex
@@ -219,7 +283,7 @@ func (m *CPUMiner) submitBlock(block *provautil.Block) bool {
 // stale block such as a new block showing up or periodically when there are
 // new transactions and enough time has elapsed without finding a solution.
 func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight uint32,
-	ticker *time.Ticker, validateKey *btcec.PrivateKey,
+	ticker *time.Ticker, validateKey wire.BlockSigner,
 	quit chan struct{}) bool {
 
 	// Create some convenience variables.
@@ -260,7 +324,13 @@ func (m *CPUMiner) solveBlock(msgBlock *wire.MsgBlock, blockHeight uint32,
 				return false
 			}
 
-			m.g.UpdateBlockTime(msgBlock, validateKey)
+			// The block is abandoned when it can't be signed
+			// again, so a new block template can be generated.
+			err := m.g.UpdateBlockTime(msgBlock, validateKey)
+			if err != nil {
+				log.Errorf("Failed to sign block: %v", err)
+				return false
+			}
 
 		default:
 			// Non-blocking select to fall through
@@ -335,7 +405,7 @@ out:
 		// Confirm that validate keys are present.
 		if len(m.validateKeys) == 0 {
 			errStr := fmt.Sprintf("Missing validate keys, set via"+
-				" setvalidatekeys or env var %s", validateKeysEnvironmentKey)
+				" setvalidatekeys, validatesigner or env var %s", validateKeysEnvironmentKey)
 			log.Errorf(errStr)
 			continue
 		}
@@ -352,34 +422,19 @@ out:
 			continue
 		}
 
-		// Pick a validate key to use, absent rate-limited keys.
-		var nonRateLimitedValidateKeys []*btcec.PrivateKey
-		var validateKey *btcec.PrivateKey
-		var validateKeyErr error
-		for _, privKey := range m.validateKeys {
-			var validatePubKey wire.BlockValidatingPubKey
-			copy(validatePubKey[:wire.BlockValidatingPubKeySize], privKey.PubKey().SerializeCompressed()[:wire.BlockValidatingPubKeySize])
-			isRateLimited, validateKeyErr := m.cfg.IsValidateKeyRateLimited(validatePubKey)
-			if validateKeyErr != nil || isRateLimited {
-				continue
-			}
-			nonRateLimitedValidateKeys = append(nonRateLimitedValidateKeys, privKey)
-		}
-		if validateKeyErr != nil {
+		// Choose a validate key to use, absent rate-limited keys.
+		validateKey, err := m.selectValidateKey(m.validateKeys)
+		if err == errRateLimited {
 			m.submitBlockLock.Unlock()
-			errStr := fmt.Sprintf("Failed checking validate key %v", validateKeyErr)
-			log.Errorf(errStr)
-			time.Sleep(time.Second)
+			log.Errorf("Block generation rate limited.")
+			time.Sleep(5 * time.Second)
 			continue
 		}
-		if keysCount := len(nonRateLimitedValidateKeys); keysCount > 0 {
-			// Choose a signing key at random.
-			validateKey = nonRateLimitedValidateKeys[rand.Intn(keysCount)]
-		} else {
+		if err != nil {
 			m.submitBlockLock.Unlock()
-			errStr := fmt.Sprintf("Block generation rate limited.")
+			errStr := fmt.Sprintf("Failed checking validate key %v", err)
 			log.Errorf(errStr)
-			time.Sleep(5 * time.Second)
+			time.Sleep(time.Second)
 			continue
 		}
 
@@ -423,6 +478,74 @@ func (m *CPUMiner) detectInvalidValidateKey() *btcec.PublicKey {
 	return nil
 }
 
+// validatingPubKey returns the block validating public key of the passed
+// validate key.
+func validatingPubKey(validateKey wire.BlockSigner) wire.BlockValidatingPubKey {
+	var pubKey wire.BlockValidatingPubKey
+	copy(pubKey[:], validateKey.PubKey().SerializeCompressed())
+	return pubKey
+}
+
+// selectValidateKey chooses the validate key to sign the next generated block
+// with among the passed keys which are not rate limited according to the
+// configured policy.  errRateLimited is returned when all of the keys are rate
+// limited.
+//
+// This function MUST be called with the submit block lock held.
+func (m *CPUMiner) selectValidateKey(validateKeys []wire.BlockSigner) (wire.BlockSigner, error) {
+	// Determine the positions of the keys which are not rate limited.
+	var candidates []int
+	for i, validateKey := range validateKeys {
+		isRateLimited, err := m.cfg.IsValidateKeyRateLimited(
+			validatingPubKey(validateKey))
+		if err != nil {
+			return nil, err
+		}
+		if !isRateLimited {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, errRateLimited
+	}
+
+	switch m.cfg.ValidateKeyPolicy {
+	case RoundRobinValidateKey:
+		// Choose the first key at or after the position following the
+		// previously chosen one, wrapping around to the first key.
+		chosen := candidates[0]
+		for _, i := range candidates {
+			if i >= m.nextValidateKey {
+				chosen = i
+				break
+			}
+		}
+		m.nextValidateKey = chosen + 1
+		return validateKeys[chosen], nil
+
+	case LowestShareValidateKey:
+		var lowest []int
+		lowestShare := -1
+		for _, i := range candidates {
+			share, err := m.cfg.ValidateKeyShare(
+				validatingPubKey(validateKeys[i]))
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case lowestShare == -1 || share < lowestShare:
+				lowest = []int{i}
+				lowestShare = share
+			case share == lowestShare:
+				lowest = append(lowest, i)
+			}
+		}
+		candidates = lowest
+	}
+
+	return validateKeys[candidates[rand.Intn(len(candidates))]], nil
+}
+
 // miningWorkerController launches the worker goroutines that are used to
 // generate block templates and solve them.  It also provides the ability to
 // dynamically adjust the number of running worker goroutines.
@@ -493,7 +616,7 @@ func (m *CPUMiner) EstablishValidateKeys() {
 		return
 	}
 	validateKeys := strings.Split(validateKeyValue, ",")
-	validatePrivKeys := make([]*btcec.PrivateKey, len(validateKeys))
+	validatePrivKeys := make([]wire.BlockSigner, len(validateKeys))
 	for i, privKeyStr := range validateKeys {
 		privKeyBytes, err := hex.DecodeString(privKeyStr)
 		if err != nil {
@@ -625,10 +748,10 @@ func (m *CPUMiner) NumWorkers() int32 {
 	return int32(m.numWorkers)
 }
 
-// SetValidateKeys updates the private keys used for signing.
+// SetValidateKeys updates the validate keys used for signing.
 //
 // This function is safe for concurrent access.
-func (m *CPUMiner) SetValidateKeys(validateKeys []*btcec.PrivateKey) {
+func (m *CPUMiner) SetValidateKeys(validateKeys []wire.BlockSigner) {
 	m.Lock()
 	defer m.Unlock()
 	m.validateKeys = validateKeys
@@ -637,7 +760,7 @@ func (m *CPUMiner) SetValidateKeys(validateKeys []*btcec.PrivateKey) {
 // ValidateKeys returns the validate keys set to sign blocks.
 //
 // This function is safe for concurrent access.
-func (m *CPUMiner) ValidateKeys() []*btcec.PrivateKey {
+func (m *CPUMiner) ValidateKeys() []wire.BlockSigner {
 	m.Lock()
 	defer m.Unlock()
 	return m.validateKeys
@@ -649,6 +772,20 @@ func (m *CPUMiner) ValidateKeys() []*btcec.PrivateKey {
 // generating a new block template.  When a block is solved, it is submitted.
 // The function returns a list of the hashes of generated blocks.
 func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
+	return m.GenerateBlocks(n, nil, nil)
+}
+
+// GenerateBlocks generates the requested number of blocks the same way as
+// GenerateNBlocks, except the blocks pay to the passed address unless it is
+// nil, in which case one of the configured mining addresses is chosen for each
+// block.
+//
+// When the passed transaction hashes are not nil, the first block includes
+// exactly the memory pool transactions with those hashes instead of the ones
+// selected by the policy settings, and the blocks after it do not include any
+// transactions.  An error is returned when any of them is not able to be
+// included.
+func (m *CPUMiner) GenerateBlocks(n uint32, payToAddr provautil.Address, txHashes []*chainhash.Hash) ([]*chainhash.Hash, error) {
 	m.Lock()
 
 	// Respond with an error if server is already mining.
@@ -692,18 +829,40 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 		m.submitBlockLock.Lock()
 		curHeight := m.g.BestSnapshot().Height
 
-		// Choose a payment address at random.
-		rand.Seed(time.Now().UnixNano())
-		payToAddr := m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+		// Choose a payment address at random unless one was passed.
+		blockPayToAddr := payToAddr
+		if blockPayToAddr == nil {
+			rand.Seed(time.Now().UnixNano())
+			blockPayToAddr = m.cfg.MiningAddrs[rand.Intn(len(m.cfg.MiningAddrs))]
+		}
 
-		// Choose a validate key at random.
-		validateKeys := m.ValidateKeys()
-		validateKey := validateKeys[rand.Intn(len(validateKeys))]
+		// Choose a validate key, absent rate-limited keys.  Waiting for
+		// a key to become available is pointless since no blocks are
+		// generated in the meantime.
+		validateKey, err := m.selectValidateKey(m.ValidateKeys())
+		if err != nil {
+			m.submitBlockLock.Unlock()
+			m.stopDiscreteMining()
+			return nil, err
+		}
 
 		// Create a new block template using the available transactions
 		// in the memory pool as a source of transactions to potentially
-		// include in the block.
-		template, err := m.g.NewBlockTemplate(payToAddr, validateKey)
+		// include in the block, or the requested ones.  Trying again is
+		// pointless when the requested transactions can't be included.
+		var template *mining.BlockTemplate
+		if txHashes != nil {
+			template, err = m.g.NewBlockTemplateWithTxs(blockPayToAddr,
+				validateKey, txHashes)
+			if err != nil {
+				m.submitBlockLock.Unlock()
+				m.stopDiscreteMining()
+				return nil, err
+			}
+		} else {
+			template, err = m.g.NewBlockTemplate(blockPayToAddr,
+				validateKey)
+		}
 		m.submitBlockLock.Unlock()
 		if err != nil {
 			errStr := fmt.Sprintf("Failed to create new block "+
@@ -721,20 +880,32 @@ func (m *CPUMiner) GenerateNBlocks(n uint32) ([]*chainhash.Hash, error) {
 			m.submitBlock(block)
 			blockHashes[i] = block.Hash()
 			i++
+
+			// The requested transactions are in the first block,
+			// so the blocks after it are left empty.
+			if txHashes != nil {
+				txHashes = []*chainhash.Hash{}
+			}
 			if i == n {
 				log.Tracef("Generated %d blocks", i)
-				m.Lock()
-				close(m.speedMonitorQuit)
-				m.wg.Wait()
-				m.started = false
-				m.discreteMining = false
-				m.Unlock()
+				m.stopDiscreteMining()
 				return blockHashes, nil
 			}
 		}
 	}
 }
 
+// stopDiscreteMining stops the speed monitor started by GenerateNBlocks and
+// marks the miner as stopped.
+func (m *CPUMiner) stopDiscreteMining() {
+	m.Lock()
+	close(m.speedMonitorQuit)
+	m.wg.Wait()
+	m.started = false
+	m.discreteMining = false
+	m.Unlock()
+}
+
 // New returns a new instance of a CPU miner for the provided configuration.
 // Use Start to begin the mining process.  See the documentation for CPUMiner
 // type for more details.
@@ -743,6 +914,7 @@ func New(cfg *Config) *CPUMiner {
 		g:                 cfg.BlockTemplateGenerator,
 		cfg:               *cfg,
 		numWorkers:        defaultNumWorkers,
+		validateKeys:      cfg.ValidateKeys,
 		updateNumWorkers:  make(chan struct{}),
 		queryHashesPerSec: make(chan float64),
 		updateHashes:      make(chan uint64),
@@ -0,0 +1,95 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package cpuminer
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestSelectValidateKey ensures each validate key policy chooses among the keys
+// which are not rate limited as intended.
+func TestSelectValidateKey(t *testing.T) {
+	t.Parallel()
+
+	keys := make([]wire.BlockSigner, 3)
+	for i := range keys {
+		key, err := btcec.NewPrivateKey(btcec.S256())
+		if err != nil {
+			t.Fatalf("unable to create key: %v", err)
+		}
+		keys[i] = key
+	}
+
+	// The second key is rate limited and the first key signed more of the
+	// recent blocks than the third.
+	rateLimited := map[wire.BlockValidatingPubKey]bool{
+		validatingPubKey(keys[1]): true,
+	}
+	shares := map[wire.BlockValidatingPubKey]int{
+		validatingPubKey(keys[0]): 2,
+		validatingPubKey(keys[1]): 3,
+		validatingPubKey(keys[2]): 1,
+	}
+	newMiner := func(policy ValidateKeyPolicy) *CPUMiner {
+		return New(&Config{
+			IsValidateKeyRateLimited: func(pubKey wire.BlockValidatingPubKey) (bool, error) {
+				return rateLimited[pubKey], nil
+			},
+			ValidateKeyPolicy: policy,
+			ValidateKeyShare: func(pubKey wire.BlockValidatingPubKey) (int, error) {
+				return shares[pubKey], nil
+			},
+		})
+	}
+
+	tests := []struct {
+		policy ValidateKeyPolicy
+		want   []int // expected positions of the chosen keys, -1 for any
+	}{
+		{RandomValidateKey, []int{-1, -1, -1, -1, -1, -1}},
+		{RoundRobinValidateKey, []int{0, 2, 0, 2}},
+		{LowestShareValidateKey, []int{2, 2, 2}},
+	}
+	for _, test := range tests {
+		m := newMiner(test.policy)
+		for i, want := range test.want {
+			key, err := m.selectValidateKey(keys)
+			if err != nil {
+				t.Fatalf("%v #%d: unexpected error: %v", test.policy,
+					i, err)
+			}
+			if key == keys[1] {
+				t.Fatalf("%v #%d: chose the rate limited key",
+					test.policy, i)
+			}
+			if want != -1 && key != keys[want] {
+				t.Fatalf("%v #%d: chose the wrong key", test.policy,
+					i)
+			}
+		}
+	}
+
+	// Ensure an error is returned when all of the keys are rate limited.
+	_, err := newMiner(RandomValidateKey).selectValidateKey(keys[1:2])
+	if err != errRateLimited {
+		t.Fatalf("selectValidateKey: got error %v, want %v", err,
+			errRateLimited)
+	}
+
+	// Ensure the policies are parsed from their names.
+	for policy, name := range validateKeyPolicyStrings {
+		parsed, err := ParseValidateKeyPolicy(name)
+		if err != nil || parsed != policy {
+			t.Fatalf("ParseValidateKeyPolicy(%q): got %v, %v", name,
+				parsed, err)
+		}
+	}
+	if _, err := ParseValidateKeyPolicy("fastest"); err == nil {
+		t.Fatal("ParseValidateKeyPolicy: unknown policy was accepted")
+	}
+}
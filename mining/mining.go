@@ -9,10 +9,11 @@ import (
 	"bytes"
 	"container/heap"
 	"encoding/hex"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/bitgo/prova/blockchain"
-	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/provautil"
@@ -25,14 +26,6 @@ const (
 	// transaction to be considered high priority.
 	MinHighPriority = 0.0
 
-	// generatedBlockVersion is the version of the block being generated.
-	// It is defined as a constant here rather than using the
-	// wire.BlockVersion constant since a change in the block version
-	// will require changes to the generated block.  Using the wire constant
-	// for generated block version could allow creation of invalid blocks
-	// for the updated version.
-	generatedBlockVersion = 4
-
 	// blockHeaderOverhead is the max number of bytes it takes to serialize
 	// a block header and max possible transaction count.
 	blockHeaderOverhead = wire.MaxBlockHeaderPayload + wire.MaxVarIntPayload
@@ -61,6 +54,19 @@ type TxDesc struct {
 
 	// FeePerKB is the fee the transaction pays in Satoshi per 1000 bytes.
 	FeePerKB int64
+
+	// AncestorFee and AncestorSize are the total fee and serialized size
+	// of the transaction along with all of the transactions in the source
+	// pool it spends outputs of, directly or indirectly.  They are zero
+	// when the source pool does not track them.
+	AncestorFee  int64
+	AncestorSize int64
+
+	// DescendantFee and DescendantSize are the total fee and serialized
+	// size of the transaction along with all of the transactions in the
+	// source pool which spend its outputs, directly or indirectly.
+	DescendantFee  int64
+	DescendantSize int64
 }
 
 // TxSource represents a source of transactions to consider for inclusion in
@@ -82,21 +88,151 @@ type TxSource interface {
 	HaveTransaction(hash *chainhash.Hash) bool
 }
 
+// filteredTxSource is a transaction source which only provides the
+// transactions of another source with the chosen hashes.
+type filteredTxSource struct {
+	TxSource
+	txHashes map[chainhash.Hash]struct{}
+}
+
+// newFilteredTxSource returns a transaction source which only provides the
+// transactions of the passed source with the passed hashes.
+func newFilteredTxSource(txSource TxSource, txHashes []*chainhash.Hash) *filteredTxSource {
+	filtered := &filteredTxSource{
+		TxSource: txSource,
+		txHashes: make(map[chainhash.Hash]struct{}, len(txHashes)),
+	}
+	for _, txHash := range txHashes {
+		filtered.txHashes[*txHash] = struct{}{}
+	}
+	return filtered
+}
+
+// MiningDescs returns a slice of mining descriptors for the chosen transactions
+// in the source pool.
+//
+// This is part of the TxSource interface implementation.
+func (s *filteredTxSource) MiningDescs() []*TxDesc {
+	var descs []*TxDesc
+	for _, desc := range s.TxSource.MiningDescs() {
+		if _, ok := s.txHashes[*desc.Tx.Hash()]; ok {
+			descs = append(descs, desc)
+		}
+	}
+	return descs
+}
+
+// HaveTransaction returns whether or not the passed transaction hash is one of
+// the chosen transactions and exists in the source pool.
+//
+// This is part of the TxSource interface implementation.
+func (s *filteredTxSource) HaveTransaction(hash *chainhash.Hash) bool {
+	if _, ok := s.txHashes[*hash]; !ok {
+		return false
+	}
+	return s.TxSource.HaveTransaction(hash)
+}
+
 // txPrioItem houses a transaction along with extra information that allows the
 // transaction to be prioritized and track dependencies on other transactions
 // which have not been mined into a block yet.
 type txPrioItem struct {
 	tx       *provautil.Tx
 	fee      int64
+	size     int64
 	priority float64
-	feePerKB int64
 	isAdmin  bool
 
+	// feePerKB is the fee per kilobyte of the package made up of the
+	// transaction along with its ancestors which have not been included in
+	// the block yet, so a transaction paying a high fee raises the fee per
+	// kilobyte of the transactions it depends on to be included along with
+	// it.  pkgFee and pkgSize are the total fee and size of the package.
+	feePerKB int64
+	pkgFee   int64
+	pkgSize  int64
+
 	// dependsOn holds a map of transaction hashes which this one depends
 	// on.  It will only be set when the transaction references other
 	// transactions in the source pool and hence must come after them in
 	// a block.
 	dependsOn map[chainhash.Hash]struct{}
+
+	// ancestors holds the transactions in the source pool this one depends
+	// on, directly or indirectly, which have not been included in the
+	// block yet, while descendants holds the transactions which depend on
+	// this one.  They are nil until the dependencies are resolved.
+	ancestors   map[chainhash.Hash]*txPrioItem
+	descendants map[chainhash.Hash]*txPrioItem
+
+	// index is the index of the item in the priority queue, or -1 when it
+	// is not in the queue.  skipped is set once the transaction can't be
+	// included in the block anymore.
+	index   int
+	skipped bool
+}
+
+// setPackage sets the total fee and size of the package of the transaction and
+// updates its fee per kilobyte accordingly.
+func (item *txPrioItem) setPackage(fee, size int64) {
+	item.pkgFee = fee
+	item.pkgSize = size
+	item.feePerKB = 0
+	if size > 0 {
+		item.feePerKB = fee * 1000 / size
+	}
+}
+
+// packageItems returns the transactions of the package of the item, which are
+// its ancestors that have not been included in the block yet followed by the
+// item itself, ordered so every transaction comes after the transactions it
+// depends on.
+func (item *txPrioItem) packageItems() []*txPrioItem {
+	items := make([]*txPrioItem, 0, len(item.ancestors)+1)
+	for _, ancestor := range item.ancestors {
+		items = append(items, ancestor)
+	}
+
+	// A transaction always has fewer ancestors than the transactions which
+	// depend on it.
+	sort.Slice(items, func(i, j int) bool {
+		if len(items[i].ancestors) != len(items[j].ancestors) {
+			return len(items[i].ancestors) < len(items[j].ancestors)
+		}
+		return bytes.Compare(items[i].tx.Hash()[:],
+			items[j].tx.Hash()[:]) < 0
+	})
+	return append(items, item)
+}
+
+// resolveAncestors populates the ancestors of the passed item, and adds the
+// item to the descendants of each of them, using the passed items keyed by
+// transaction hash.  It returns false and marks the item as skipped when it
+// depends on a transaction which is not among the items or is skipped itself.
+func resolveAncestors(item *txPrioItem, items map[chainhash.Hash]*txPrioItem) bool {
+	if item.ancestors != nil {
+		return !item.skipped
+	}
+
+	item.ancestors = make(map[chainhash.Hash]*txPrioItem)
+	for parentHash := range item.dependsOn {
+		parent, ok := items[parentHash]
+		if !ok || !resolveAncestors(parent, items) {
+			item.skipped = true
+			return false
+		}
+		item.ancestors[parentHash] = parent
+		for hash, ancestor := range parent.ancestors {
+			item.ancestors[hash] = ancestor
+		}
+	}
+	for _, ancestor := range item.ancestors {
+		if ancestor.descendants == nil {
+			ancestor.descendants = make(map[chainhash.Hash]*txPrioItem)
+		}
+		ancestor.descendants[*item.tx.Hash()] = item
+	}
+	return true
 }
 
 // isAdmin returns whether or not this transaction has an admin txout
@@ -143,12 +279,16 @@ func (pq *txPriorityQueue) Less(i, j int) bool {
 // part of the heap.Interface implementation.
 func (pq *txPriorityQueue) Swap(i, j int) {
 	pq.items[i], pq.items[j] = pq.items[j], pq.items[i]
+	pq.items[i].index = i
+	pq.items[j].index = j
 }
 
 // Push pushes the passed item onto the priority queue.  It is part of the
 // heap.Interface implementation.
 func (pq *txPriorityQueue) Push(x interface{}) {
-	pq.items = append(pq.items, x.(*txPrioItem))
+	item := x.(*txPrioItem)
+	item.index = len(pq.items)
+	pq.items = append(pq.items, item)
 }
 
 // Pop removes the highest priority item (according to Less) from the priority
@@ -158,6 +298,7 @@ func (pq *txPriorityQueue) Pop() interface{} {
 	item := pq.items[n-1]
 	pq.items[n-1] = nil
 	pq.items = pq.items[0 : n-1]
+	item.index = -1
 	return item
 }
 
@@ -435,6 +576,11 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 // coinbase which will replace the one generated for the block template.  Thus
 // the need to have configured address can be avoided.
 //
+// The header is signed with the passed validate key.  When it is nil, the
+// header is left unsigned and the validate key checks of the template are
+// skipped, since external block producing software signs the header with its
+// own validate key once the block has been solved.
+//
 // The transactions selected and included are prioritized according to several
 // factors.  First, each transaction has a priority calculated based on its
 // value, age of inputs, and size.  Transactions which consist of larger
@@ -455,7 +601,14 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 // Once the high-priority area (if configured) has been filled with
 // transactions, or the priority falls below what is considered high-priority,
 // the priority queue is updated to prioritize by fees per kilobyte (then
-// priority).
+// priority) and the transactions which depend on others are added to it as
+// well.  The fee per kilobyte of a transaction is that of its package, which is
+// made up of the transaction along with the transactions it depends on which
+// have not been included yet, so a transaction paying a high fee pulls the
+// low-fee transactions it spends into the block with it (child pays for
+// parent).  Packages are included as a whole with every transaction after the
+// ones it depends on, and the packages of the transactions which depend on the
+// included ones are updated accordingly.
 //
 // When the fees per kilobyte drop below the TxMinFreeFee policy setting, the
 // transaction will be skipped unless the BlockMinSize policy setting is
@@ -488,7 +641,43 @@ func NewBlkTmplGenerator(policy *Policy, params *chaincfg.Params,
 //  |  transactions (while block size   |   |
 //  |  <= policy.BlockMinSize)          |   |
 //   -----------------------------------  --
-func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress provautil.Address, validateKey *btcec.PrivateKey) (*BlockTemplate, error) {
+func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress provautil.Address, validateKey wire.BlockSigner) (*BlockTemplate, error) {
+	return g.newBlockTemplate(g.txSource, false, payToAddress, validateKey)
+}
+
+// NewBlockTemplateWithTxs returns a new block template the same way as
+// NewBlockTemplate, except the block includes exactly the source pool
+// transactions with the passed hashes instead of the ones selected by the
+// policy settings.  Transactions which spend outputs of other transactions in
+// the source pool are only able to be included along with them.  An error is
+// returned when any of the transactions is not able to be included.
+func (g *BlkTmplGenerator) NewBlockTemplateWithTxs(payToAddress provautil.Address, validateKey wire.BlockSigner, txHashes []*chainhash.Hash) (*BlockTemplate, error) {
+	txSource := newFilteredTxSource(g.txSource, txHashes)
+	template, err := g.newBlockTemplate(txSource, true, payToAddress,
+		validateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	included := make(map[chainhash.Hash]struct{},
+		len(template.Block.Transactions))
+	for _, msgTx := range template.Block.Transactions {
+		included[msgTx.TxHash()] = struct{}{}
+	}
+	for _, txHash := range txHashes {
+		if _, ok := included[*txHash]; !ok {
+			return nil, fmt.Errorf("transaction %v is not able to "+
+				"be included in the block", txHash)
+		}
+	}
+	return template, nil
+}
+
+// newBlockTemplate returns a new block template using the transactions from
+// the passed transaction source.  The free transactions are included regardless
+// of the minimum block size when includeFree is set.  See NewBlockTemplate for
+// details.
+func (g *BlkTmplGenerator) newBlockTemplate(txSource TxSource, includeFree bool, payToAddress provautil.Address, validateKey wire.BlockSigner) (*BlockTemplate, error) {
 	// Extend the most recently known best block.
 	best := g.chain.BestSnapshot()
 	prevHash := best.Hash
@@ -519,7 +708,7 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress provautil.Address, vali
 	// number of items that are available for the priority queue.  Also,
 	// choose the initial sort order for the priority queue based on whether
 	// or not there is an area allocated for high-priority transactions.
-	sourceTxns := g.txSource.MiningDescs()
+	sourceTxns := txSource.MiningDescs()
 	sortedByFee := g.policy.BlockPrioritySize == 0
 	priorityQueue := newTxPriorityQueue(len(sourceTxns), sortedByFee)
 
@@ -535,12 +724,11 @@ func (g *BlkTmplGenerator) NewBlockTemplate(payToAddress provautil.Address, vali
 	keyView.SetKeys(g.chain.AdminKeySets())
 	keyView.SetKeyIDs(g.chain.KeyIDs())
 
-	// dependers is used to track transactions which depend on another
-	// transaction in the source pool.  This, in conjunction with the
-	// dependsOn map kept with each dependent transaction helps quickly
-	// determine which dependent transactions are now eligible for inclusion
-	// in the block once each transaction has been included.
-	dependers := make(map[chainhash.Hash]map[chainhash.Hash]*txPrioItem)
+	// prioItems holds the transactions which are candidates for inclusion
+	// keyed by their hash, so the transactions which depend on others in
+	// the source pool can be linked to them once all are known.
+	prioItems := make(map[chainhash.Hash]*txPrioItem, len(sourceTxns))
+	candidates := make([]*txPrioItem, 0, len(sourceTxns))
 
 	// Create slices to hold the fees and number of signature operations
 	// for each of the selected transactions and add an entry for the
@@ -586,13 +774,13 @@ mempoolLoop:
 		// Setup dependencies for any transactions which reference
 		// other transactions in the mempool so they can be properly
 		// ordered below.
-		prioItem := &txPrioItem{tx: tx}
+		prioItem := &txPrioItem{tx: tx, index: -1}
 		for _, txIn := range tx.MsgTx().TxIn {
 			originHash := &txIn.PreviousOutPoint.Hash
 			originIndex := txIn.PreviousOutPoint.Index
 			utxoEntry := utxos.LookupEntry(originHash)
 			if utxoEntry == nil || utxoEntry.IsOutputSpent(originIndex) {
-				if !g.txSource.HaveTransaction(originHash) {
+				if !txSource.HaveTransaction(originHash) {
 					log.Tracef("Skipping tx %s because "+
 						"it references unspent output "+
 						"%s which is not available",
@@ -603,12 +791,6 @@ mempoolLoop:
 				// The transaction is referencing another
 				// transaction in the source pool, so setup an
 				// ordering dependency.
-				deps, exists := dependers[*originHash]
-				if !exists {
-					deps = make(map[chainhash.Hash]*txPrioItem)
-					dependers[*originHash] = deps
-				}
-				deps[*prioItem.tx.Hash()] = prioItem
 				if prioItem.dependsOn == nil {
 					prioItem.dependsOn = make(
 						map[chainhash.Hash]struct{})
@@ -627,16 +809,20 @@ mempoolLoop:
 		prioItem.priority = CalcPriority(tx.MsgTx(), utxos,
 			nextBlockHeight)
 
-		// Calculate the fee in Atoms/kB.
-		prioItem.feePerKB = txDesc.FeePerKB
+		// Calculate the fee in Atoms/kB of the package of the
+		// transaction.  The source pool tracks the fees and sizes of
+		// the transactions each one depends on.
 		prioItem.fee = txDesc.Fee
+		prioItem.size = int64(tx.MsgTx().SerializeSize())
 		prioItem.isAdmin = isAdmin(tx.MsgTx())
-
-		// Add the transaction to the priority queue to mark it ready
-		// for inclusion in the block unless it has dependencies.
-		if prioItem.dependsOn == nil {
-			heap.Push(priorityQueue, prioItem)
+		if txDesc.AncestorSize > 0 {
+			prioItem.setPackage(txDesc.AncestorFee,
+				txDesc.AncestorSize)
+		} else {
+			prioItem.setPackage(prioItem.fee, prioItem.size)
 		}
+		prioItems[*tx.Hash()] = prioItem
+		candidates = append(candidates, prioItem)
 
 		// Merge the referenced outputs from the input transactions to
 		// this transaction into the block utxo view.  This allows the
@@ -644,36 +830,65 @@ mempoolLoop:
 		mergeUtxoView(blockUtxos, utxos)
 	}
 
+	// Link the transactions which depend on others to all of the
+	// transactions they depend on, directly or indirectly, and add the
+	// transactions without dependencies to the priority queue to mark them
+	// ready for inclusion in the block.  Transactions which depend on a
+	// skipped transaction are skipped as well.
+	var numDependers int
+	for _, prioItem := range candidates {
+		if !resolveAncestors(prioItem, prioItems) {
+			log.Tracef("Skipping tx %s because it depends on a "+
+				"skipped transaction", prioItem.tx.Hash())
+			continue
+		}
+		if len(prioItem.ancestors) == 0 {
+			heap.Push(priorityQueue, prioItem)
+			continue
+		}
+		numDependers++
+	}
+
 	log.Tracef("Priority queue len %d, dependers len %d",
-		priorityQueue.Len(), len(dependers))
+		priorityQueue.Len(), numDependers)
 
 	// The starting block size is the size of the block header plus the max
 	// possible transaction count size, plus the size of the coinbase
 	// transaction.
 	blockSize := blockHeaderOverhead + uint32(coinbaseTx.MsgTx().SerializeSize())
 	blockSigOps := numCoinbaseSigOps
+	blockDataCarriers := uint32(0)
 	totalFees := int64(0)
 
-	// Choose which transactions make it into the block.
-	for priorityQueue.Len() > 0 {
-		// Grab the highest priority (or highest fee per kilobyte
-		// depending on the sort order) transaction.
-		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
+	// skipTxn marks the passed transaction along with the transactions
+	// which depend on it as skipped.
+	skipTxn := func(item *txPrioItem) {
+		logSkippedDeps(item.tx, item.descendants)
+		item.skipped = true
+		for _, descendant := range item.descendants {
+			descendant.skipped = true
+		}
+	}
+
+	// includeTxn adds the passed transaction to the block unless it would
+	// make the block invalid, in which case it is skipped along with the
+	// transactions which depend on it.  It returns whether the transaction
+	// was added.
+	includeTxn := func(prioItem *txPrioItem) bool {
 		tx := prioItem.tx
 
-		// Grab the list of transactions which depend on this one (if any).
-		deps := dependers[*tx.Hash()]
-
-		// Enforce maximum block size.  Also check for overflow.
-		txSize := uint32(tx.MsgTx().SerializeSize())
-		blockPlusTxSize := blockSize + txSize
-		if blockPlusTxSize < blockSize ||
-			blockPlusTxSize >= g.policy.BlockMaxSize {
+		// Enforce the maximum number of data carrier outputs per block
+		// when the policy limits them.
+		numDataCarriers := countDataCarriers(tx)
+		if g.policy.BlockMaxDataCarriers > 0 &&
+			blockDataCarriers+numDataCarriers >
+				g.policy.BlockMaxDataCarriers {
 
 			log.Tracef("Skipping tx %s because it would exceed "+
-				"the max block size", tx.Hash())
-			logSkippedDeps(tx, deps)
-			continue
+				"the maximum data carrier outputs per block",
+				tx.Hash())
+			skipTxn(prioItem)
+			return false
 		}
 
 		// Enforce maximum signature operations per block.  Also check
@@ -683,16 +898,16 @@ mempoolLoop:
 			blockSigOps+numSigOps > blockchain.MaxSigOpsPerBlock {
 			log.Tracef("Skipping tx %s because it would "+
 				"exceed the maximum sigops per block", tx.Hash())
-			logSkippedDeps(tx, deps)
-			continue
+			skipTxn(prioItem)
+			return false
 		}
 		numP2SHSigOps, err := blockchain.CountP2SHSigOps(tx, false,
 			blockUtxos)
 		if err != nil {
 			log.Tracef("Skipping tx %s due to error in "+
 				"CountP2SHSigOps: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
+			skipTxn(prioItem)
+			return false
 		}
 		numSigOps += int64(numP2SHSigOps)
 		if blockSigOps+numSigOps < blockSigOps ||
@@ -700,13 +915,110 @@ mempoolLoop:
 			log.Tracef("Skipping tx %s because it would "+
 				"exceed the maximum sigops per block (p2sh)",
 				tx.Hash())
-			logSkippedDeps(tx, deps)
+			skipTxn(prioItem)
+			return false
+		}
+
+		// Ensure the transaction inputs pass all of the necessary
+		// preconditions before allowing it to be added to the block.
+		_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
+			blockUtxos, g.chainParams)
+		if err != nil {
+			log.Tracef("Skipping tx %s due to error in "+
+				"CheckTransactionInputs: %v", tx.Hash(), err)
+			skipTxn(prioItem)
+			return false
+		}
+
+		// CheckTransactionOutputs checks outputs for state violations.
+		err = blockchain.CheckTransactionOutputs(tx, keyView, g.chainParams)
+		if err != nil {
+			log.Tracef("Skipping tx %s due to error in "+
+				"CheckTransactionOutputs: %v", tx.Hash(), err)
+			skipTxn(prioItem)
+			return false
+		}
+
+		err = blockchain.ValidateTransactionScripts(tx, blockUtxos, keyView,
+			txscript.StandardVerifyFlags, g.sigCache, g.hashCache)
+		if err != nil {
+			log.Tracef("Skipping tx %s due to error in "+
+				"ValidateTransactionScripts: %v", tx.Hash(), err)
+			skipTxn(prioItem)
+			return false
+		}
+
+		// Spend the transaction inputs in the block utxo view and add
+		// an entry for it to ensure any transactions which reference
+		// this one have it available as an input and can ensure they
+		// aren't double spending.
+		spendTransaction(blockUtxos, tx, nextBlockHeight)
+
+		// Add the transaction to the block, increment counters, and
+		// save the fees and signature operation counts to the block
+		// template.
+		blockTxns = append(blockTxns, tx)
+		blockSize += uint32(prioItem.size)
+		blockSigOps += numSigOps
+		blockDataCarriers += numDataCarriers
+		totalFees += prioItem.fee
+		txFees = append(txFees, prioItem.fee)
+		txSigOpCounts = append(txSigOpCounts, numSigOps)
+
+		log.Tracef("Adding tx %s (priority %.2f, feePerKB %d)",
+			prioItem.tx.Hash(), prioItem.priority, prioItem.feePerKB)
+
+		// Transactions included as part of the package of another one
+		// may still be in the priority queue.
+		if prioItem.index >= 0 {
+			heap.Remove(priorityQueue, prioItem.index)
+		}
+
+		// Remove the transaction from the packages of the transactions
+		// which depend on it and add the ones which do not have any
+		// other unsatisfied dependencies to the priority queue unless
+		// they are already in it.
+		for _, item := range prioItem.descendants {
+			delete(item.ancestors, *tx.Hash())
+			item.setPackage(item.pkgFee-prioItem.fee,
+				item.pkgSize-prioItem.size)
+			if item.index >= 0 {
+				heap.Fix(priorityQueue, item.index)
+				continue
+			}
+			if len(item.ancestors) == 0 && !item.skipped {
+				heap.Push(priorityQueue, item)
+			}
+		}
+		return true
+	}
+
+	// Choose which transactions make it into the block.
+	for priorityQueue.Len() > 0 {
+		// Grab the highest priority (or highest fee per kilobyte of
+		// its package depending on the sort order) transaction.
+		prioItem := heap.Pop(priorityQueue).(*txPrioItem)
+		if prioItem.skipped {
+			continue
+		}
+		tx := prioItem.tx
+
+		// Enforce maximum block size for the whole package.  Also
+		// check for overflow.
+		pkgSize := uint32(prioItem.pkgSize)
+		blockPlusTxSize := blockSize + pkgSize
+		if blockPlusTxSize < blockSize ||
+			blockPlusTxSize >= g.policy.BlockMaxSize {
+
+			log.Tracef("Skipping tx %s because it would exceed "+
+				"the max block size", tx.Hash())
+			logSkippedDeps(tx, prioItem.descendants)
 			continue
 		}
 
 		// Skip free transactions once the block is larger than the
 		// minimum block size.
-		if sortedByFee &&
+		if sortedByFee && !includeFree &&
 			prioItem.feePerKB < int64(g.policy.TxMinFreeFee) &&
 			blockPlusTxSize >= g.policy.BlockMinSize {
 
@@ -715,7 +1027,7 @@ mempoolLoop:
 				"minBlockSize %d", tx.Hash(), prioItem.feePerKB,
 				g.policy.TxMinFreeFee, blockPlusTxSize,
 				g.policy.BlockMinSize)
-			logSkippedDeps(tx, deps)
+			logSkippedDeps(tx, prioItem.descendants)
 			continue
 		}
 
@@ -731,6 +1043,16 @@ mempoolLoop:
 				blockPlusTxSize, g.policy.BlockPrioritySize,
 				prioItem.priority, MinHighPriority)
 
+			// Packages are prioritized by fee as a whole, so the
+			// transactions which still depend on others are ready
+			// for inclusion from now on as well.
+			for _, item := range candidates {
+				if item.index < 0 && item != prioItem &&
+					!item.skipped && len(item.ancestors) > 0 {
+
+					heap.Push(priorityQueue, item)
+				}
+			}
 			sortedByFee = true
 			priorityQueue.SetLessFunc(txPQByFee)
 
@@ -748,63 +1070,13 @@ mempoolLoop:
 			}
 		}
 
-		// Ensure the transaction inputs pass all of the necessary
-		// preconditions before allowing it to be added to the block.
-		_, err = blockchain.CheckTransactionInputs(tx, nextBlockHeight,
-			blockUtxos, g.chainParams)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"CheckTransactionInputs: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
-		}
-
-		// CheckTransactionOutputs checks outputs for state violations.
-		err = blockchain.CheckTransactionOutputs(tx, keyView, g.chainParams)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"CheckTransactionOutputs: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
-		}
-
-		err = blockchain.ValidateTransactionScripts(tx, blockUtxos, keyView,
-			txscript.StandardVerifyFlags, g.sigCache, g.hashCache)
-		if err != nil {
-			log.Tracef("Skipping tx %s due to error in "+
-				"ValidateTransactionScripts: %v", tx.Hash(), err)
-			logSkippedDeps(tx, deps)
-			continue
-		}
-
-		// Spend the transaction inputs in the block utxo view and add
-		// an entry for it to ensure any transactions which reference
-		// this one have it available as an input and can ensure they
-		// aren't double spending.
-		spendTransaction(blockUtxos, tx, nextBlockHeight)
-
-		// Add the transaction to the block, increment counters, and
-		// save the fees and signature operation counts to the block
-		// template.
-		blockTxns = append(blockTxns, tx)
-		blockSize += txSize
-		blockSigOps += numSigOps
-		totalFees += prioItem.fee
-		txFees = append(txFees, prioItem.fee)
-		txSigOpCounts = append(txSigOpCounts, numSigOps)
-
-		log.Tracef("Adding tx %s (priority %.2f, feePerKB %d)",
-			prioItem.tx.Hash(), prioItem.priority, prioItem.feePerKB)
-
-		// Add transactions which depend on this one (and also do not
-		// have any other unsatisified dependencies) to the priority
-		// queue.
-		for _, item := range deps {
-			// Add the transaction to the priority queue if there
-			// are no more dependencies after this one.
-			delete(item.dependsOn, *tx.Hash())
-			if len(item.dependsOn) == 0 {
-				heap.Push(priorityQueue, item)
+		// Add the transactions of the package in order, starting with
+		// the transactions it depends on which have not been included
+		// yet.  The package can't be completed once one of them is
+		// skipped.
+		for _, item := range prioItem.packageItems() {
+			if !includeTxn(item) {
+				break
 			}
 		}
 	}
@@ -840,11 +1112,20 @@ mempoolLoop:
 		return nil, err
 	}
 
+	// Calculate the next expected block version based on the state of the
+	// rule change deployments, which signals the deployments being voted
+	// on that the operator opted into.
+	nextBlockVersion, err := g.chain.CalcNextBlockVersion(
+		g.policy.SignalDeployments)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create a new block ready to be solved.
 	merkles := blockchain.BuildMerkleTreeStore(blockTxns)
 	var msgBlock wire.MsgBlock
 	msgBlock.Header = wire.BlockHeader{
-		Version:    generatedBlockVersion,
+		Version:    nextBlockVersion,
 		PrevBlock:  *prevHash,
 		MerkleRoot: *merkles[len(merkles)-1],
 		Timestamp:  ts,
@@ -853,8 +1134,16 @@ mempoolLoop:
 		Size:       blockSize,
 	}
 
-	// Sign the block
-	msgBlock.Header.Sign(validateKey)
+	// Sign the block unless it is left for the caller to sign once solved,
+	// in which case the validate key checks are skipped below.
+	checkFlags := blockchain.BFNone
+	if validateKey != nil {
+		if err := msgBlock.Header.Sign(validateKey); err != nil {
+			return nil, err
+		}
+	} else {
+		checkFlags |= blockchain.BFNoValidateKeyCheck
+	}
 
 	for _, tx := range blockTxns {
 		if err := msgBlock.AddTransaction(tx.MsgTx()); err != nil {
@@ -866,7 +1155,7 @@ mempoolLoop:
 	// consensus rules to ensure it properly connects to the current best
 	// chain with no issues.
 	block := provautil.NewBlock(&msgBlock)
-	if err := g.chain.CheckConnectBlock(block); err != nil {
+	if err := g.chain.CheckConnectBlock(block, checkFlags); err != nil {
 		return nil, err
 	}
 
@@ -889,9 +1178,10 @@ mempoolLoop:
 // several blocks to ensure the new time is after that time per the chain
 // consensus rules.  Finally, it will update the target difficulty if needed
 // based on the new time for the test networks since their target difficulty can
-// change based upon time.
+// change based upon time.  The header is re-signed with the passed validate key
+// unless it is nil, in which case it is left unsigned.
 func (g *BlkTmplGenerator) UpdateBlockTime(msgBlock *wire.MsgBlock,
-	validateKey *btcec.PrivateKey) error {
+	validateKey wire.BlockSigner) error {
 
 	// The new timestamp is potentially adjusted to ensure it comes after
 	// the median time of the last several blocks per the chain consensus
@@ -900,9 +1190,10 @@ func (g *BlkTmplGenerator) UpdateBlockTime(msgBlock *wire.MsgBlock,
 	msgBlock.Header.Timestamp = newTime
 
 	// Re-sign the block, since we updated the block time
-	msgBlock.Header.Sign(validateKey)
-
-	return nil
+	if validateKey == nil {
+		return nil
+	}
+	return msgBlock.Header.Sign(validateKey)
 }
 
 // BestSnapshot returns information about the current best chain block and
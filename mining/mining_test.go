@@ -9,8 +9,11 @@ import (
 	"container/heap"
 	"math/rand"
 	"testing"
+	"time"
 
+	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
 )
 
 // TestTxFeePrioHeap ensures the priority queue for transaction fees and
@@ -109,3 +112,151 @@ func TestTxFeePrioHeap(t *testing.T) {
 		highest = prioItem
 	}
 }
+
+// TestPackageItems ensures transactions which depend on other transactions are
+// linked to all of their ancestors and prioritized by the fee per kilobyte of
+// their packages, so a high-fee transaction pulls the low-fee transactions it
+// spends into the block in order.
+func TestPackageItems(t *testing.T) {
+	// newItem returns an item for a distinct transaction which spends an
+	// output of each of the passed transactions.
+	var nextIndex uint32
+	newItem := func(fee, size int64, parents ...*chainhash.Hash) *txPrioItem {
+		msgTx := wire.NewMsgTx(wire.TxVersion)
+		msgTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: nextIndex}, nil))
+		nextIndex++
+		item := &txPrioItem{index: -1, fee: fee, size: size}
+		for _, parent := range parents {
+			msgTx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: *parent}, nil))
+			if item.dependsOn == nil {
+				item.dependsOn = make(map[chainhash.Hash]struct{})
+			}
+			item.dependsOn[*parent] = struct{}{}
+		}
+		item.tx = provautil.NewTx(msgTx)
+		item.setPackage(fee, size)
+		return item
+	}
+
+	// Create a chain of free transactions a <- b <- c and a transaction d
+	// which spends a and c and pays a high fee, along with an unrelated
+	// transaction paying a fee per kilobyte between the ones of d and its
+	// package.  Transaction f depends on a transaction which is missing
+	// and g depends on f.
+	a := newItem(0, 100)
+	b := newItem(0, 100, a.tx.Hash())
+	c := newItem(0, 100, b.tx.Hash())
+	d := newItem(4000, 100, a.tx.Hash(), c.tx.Hash())
+	u := newItem(5000, 1000)
+	f := newItem(1000, 100, &chainhash.Hash{0x01})
+	g := newItem(1000, 100, f.tx.Hash())
+	items := make(map[chainhash.Hash]*txPrioItem)
+	for _, item := range []*txPrioItem{a, b, c, d, u, f, g} {
+		items[*item.tx.Hash()] = item
+	}
+
+	// Link the items and set the packages from the resolved ancestors as
+	// the source pool would.
+	priorityQueue := newTxPriorityQueue(len(items), true)
+	for _, item := range []*txPrioItem{g, d, c, b, a, u, f} {
+		if !resolveAncestors(item, items) {
+			continue
+		}
+		pkgFee, pkgSize := item.fee, item.size
+		for _, ancestor := range item.ancestors {
+			pkgFee += ancestor.fee
+			pkgSize += ancestor.size
+		}
+		item.setPackage(pkgFee, pkgSize)
+		heap.Push(priorityQueue, item)
+	}
+	if !f.skipped || !g.skipped {
+		t.Fatal("transactions depending on a missing transaction are " +
+			"not skipped")
+	}
+	if len(d.ancestors) != 3 || len(a.descendants) != 3 {
+		t.Fatalf("got %d ancestors of d and %d descendants of a, "+
+			"want 3 and 3", len(d.ancestors), len(a.descendants))
+	}
+	for i, item := range priorityQueue.items {
+		if item.index != i {
+			t.Fatalf("item at queue index %d has index %d", i,
+				item.index)
+		}
+	}
+
+	// The package of d pays 10000 per kilobyte and must come first with
+	// the transactions in dependency order.
+	top := heap.Pop(priorityQueue).(*txPrioItem)
+	if top != d || top.feePerKB != 10000 || top.index != -1 {
+		t.Fatalf("got top item with feePerKB %d, want d with 10000",
+			top.feePerKB)
+	}
+	wantOrder := []*txPrioItem{a, b, c, d}
+	for i, item := range top.packageItems() {
+		if item != wantOrder[i] {
+			t.Fatalf("package item %d is %v, want %v", i,
+				item.tx.Hash(), wantOrder[i].tx.Hash())
+		}
+	}
+}
+
+// fakeTxSource is a transaction source which provides a fixed set of
+// transactions.
+type fakeTxSource struct {
+	descs []*TxDesc
+}
+
+// LastUpdated returns the zero time.
+func (s *fakeTxSource) LastUpdated() time.Time {
+	return time.Time{}
+}
+
+// MiningDescs returns the mining descriptors of the transactions.
+func (s *fakeTxSource) MiningDescs() []*TxDesc {
+	return s.descs
+}
+
+// HaveTransaction returns whether or not the passed transaction hash is the
+// hash of one of the transactions.
+func (s *fakeTxSource) HaveTransaction(hash *chainhash.Hash) bool {
+	for _, desc := range s.descs {
+		if desc.Tx.Hash().IsEqual(hash) {
+			return true
+		}
+	}
+	return false
+}
+
+// TestFilteredTxSource ensures a filtered transaction source only provides the
+// chosen transactions of its source.
+func TestFilteredTxSource(t *testing.T) {
+	source := &fakeTxSource{}
+	for i := 0; i < 4; i++ {
+		msgTx := wire.NewMsgTx(1)
+		msgTx.AddTxOut(wire.NewTxOut(int64(i), nil))
+		source.descs = append(source.descs,
+			&TxDesc{Tx: provautil.NewTx(msgTx)})
+	}
+	missing := chainhash.Hash{0x01}
+	filtered := newFilteredTxSource(source, []*chainhash.Hash{
+		source.descs[1].Tx.Hash(), source.descs[3].Tx.Hash(), &missing,
+	})
+
+	descs := filtered.MiningDescs()
+	if len(descs) != 2 || descs[0] != source.descs[1] ||
+		descs[1] != source.descs[3] {
+
+		t.Fatalf("MiningDescs: unexpected descriptors %v", descs)
+	}
+	for i, desc := range source.descs {
+		want := i == 1 || i == 3
+		if got := filtered.HaveTransaction(desc.Tx.Hash()); got != want {
+			t.Errorf("HaveTransaction #%d: got %v, want %v", i, got,
+				want)
+		}
+	}
+	if filtered.HaveTransaction(&missing) {
+		t.Error("HaveTransaction: unexpected missing transaction")
+	}
+}
@@ -8,6 +8,7 @@ package mining
 import (
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
 )
 
@@ -38,6 +39,36 @@ type Policy struct {
 	// required for a transaction to be treated as free for mining purposes
 	// (block template generation).
 	TxMinFreeFee provautil.Amount
+
+	// BlockMaxDataCarriers is the maximum number of data carrier (null
+	// data) outputs of the transactions included in a generated block
+	// template.  The outputs of admin transactions, which carry admin
+	// operations, are not counted.  Zero means there is no limit.
+	BlockMaxDataCarriers uint32
+
+	// SignalDeployments are the IDs of the consensus rule change
+	// deployments the versions of the generated blocks signal while they
+	// are started or locked in.  Deployments are never signaled unless
+	// the operator opted into them.
+	SignalDeployments []int
+}
+
+// countDataCarriers returns the number of data carrier outputs of the passed
+// transaction which count against the BlockMaxDataCarriers limit of the policy.
+// Admin transactions carry admin operations in their null data outputs, so
+// none of their outputs count.
+func countDataCarriers(tx *provautil.Tx) uint32 {
+	if threadInt, _ := txscript.GetAdminDetails(tx); threadInt >= 0 {
+		return 0
+	}
+
+	var numDataCarriers uint32
+	for _, txOut := range tx.MsgTx().TxOut {
+		if txscript.GetScriptClass(txOut.PkScript) == txscript.NullDataTy {
+			numDataCarriers++
+		}
+	}
+	return numDataCarriers
 }
 
 // minInt is a helper function to return the minimum of two ints.  This avoids
@@ -11,6 +11,7 @@ import (
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
 )
 
@@ -164,3 +165,56 @@ func TestCalcPriority(t *testing.T) {
 		}
 	}
 }
+
+// TestCountDataCarriers ensures the data carrier outputs of transactions are
+// counted unless the transactions are admin transactions.
+func TestCountDataCarriers(t *testing.T) {
+	dataScript, err := txscript.NullDataScript([]byte("anchor"))
+	if err != nil {
+		t.Fatalf("NullDataScript: unexpected error: %v", err)
+	}
+	rootScript, err := txscript.ProvaThreadScript(provautil.RootThread)
+	if err != nil {
+		t.Fatalf("ProvaThreadScript: unexpected error: %v", err)
+	}
+	otherScript := []byte{txscript.OP_TRUE}
+
+	tests := []struct {
+		name      string
+		pkScripts [][]byte
+		want      uint32
+	}{
+		{
+			name:      "no data carrier outputs",
+			pkScripts: [][]byte{otherScript},
+			want:      0,
+		},
+		{
+			name:      "one data carrier output",
+			pkScripts: [][]byte{otherScript, dataScript},
+			want:      1,
+		},
+		{
+			name:      "two data carrier outputs",
+			pkScripts: [][]byte{dataScript, otherScript, dataScript},
+			want:      2,
+		},
+		{
+			name:      "admin transaction",
+			pkScripts: [][]byte{rootScript, dataScript},
+			want:      0,
+		},
+	}
+
+	for _, test := range tests {
+		msgTx := wire.NewMsgTx(1)
+		for _, pkScript := range test.pkScripts {
+			msgTx.AddTxOut(wire.NewTxOut(0, pkScript))
+		}
+		got := countDataCarriers(provautil.NewTx(msgTx))
+		if got != test.want {
+			t.Errorf("countDataCarriers (%s): got %d, want %d",
+				test.name, got, test.want)
+		}
+	}
+}
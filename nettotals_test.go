@@ -0,0 +1,57 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/wire"
+)
+
+// TestNetTotalsPerMsg ensures the bytes received and sent are counted per
+// message command, with the messages which failed to decode counted under
+// *other*.
+func TestNetTotalsPerMsg(t *testing.T) {
+	s := &server{
+		bytesRecvPerMsg: make(map[string]uint64),
+		bytesSentPerMsg: make(map[string]uint64),
+	}
+	sp := &serverPeer{server: s}
+
+	sp.OnRead(nil, 24, wire.NewMsgVerAck(), nil)
+	sp.OnRead(nil, 32, wire.NewMsgPing(1), nil)
+	sp.OnRead(nil, 32, wire.NewMsgPing(2), nil)
+	sp.OnRead(nil, 10, nil, nil)
+	sp.OnRead(nil, 0, nil, nil)
+	sp.OnWrite(nil, 32, wire.NewMsgPong(1), nil)
+
+	recv, sent := s.NetTotalsPerMsg()
+	wantRecv := map[string]uint64{
+		wire.CmdVerAck:  24,
+		wire.CmdPing:    64,
+		otherMsgCommand: 10,
+	}
+	if len(recv) != len(wantRecv) {
+		t.Fatalf("received: got %v, want %v", recv, wantRecv)
+	}
+	for command, bytes := range wantRecv {
+		if recv[command] != bytes {
+			t.Fatalf("received: got %v, want %v", recv, wantRecv)
+		}
+	}
+	if len(sent) != 1 || sent[wire.CmdPong] != 32 {
+		t.Fatalf("sent: got %v, want 32 bytes of pong", sent)
+	}
+	if totalRecv, totalSent := s.NetTotals(); totalRecv != 98 || totalSent != 32 {
+		t.Fatalf("got totals %d and %d, want 98 and 32", totalRecv,
+			totalSent)
+	}
+
+	// The returned totals are copies.
+	recv[wire.CmdPing] = 0
+	if recv, _ := s.NetTotalsPerMsg(); recv[wire.CmdPing] != 64 {
+		t.Fatalf("totals modified through the returned map")
+	}
+}
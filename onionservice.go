@@ -0,0 +1,141 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bitgo/prova/addrmgr"
+	"github.com/bitgo/prova/connmgr"
+)
+
+const (
+	// onionKeyFileName is the name of the file in the data directory the
+	// private key of the onion service is kept in, so the onion address
+	// stays the same across restarts.
+	onionKeyFileName = "onion_private_key"
+
+	// onionKeyType is the type of the keys of created onion services.
+	// Addresses in addr messages only hold the 80 bits of the onion
+	// addresses of version 2 services, encoded in the OnionCat range, so
+	// the services are created as version 2 services to be advertised.
+	onionKeyType = "RSA1024"
+
+	// onionServiceRetryInterval is the time to wait before creating the
+	// onion service again after it failed or Tor removed it.
+	onionServiceRetryInterval = time.Minute
+)
+
+// onionServiceTarget returns the port the onion service is reachable on, which
+// is the default port of the network, along with the local address the
+// service forwards the incoming connections to, which is the first listen
+// address, on the loopback interface when it listens on all interfaces.
+func onionServiceTarget() (uint16, string) {
+	port, _ := strconv.ParseUint(activeNetParams.DefaultPort, 10, 16)
+	host, listenPort, err := net.SplitHostPort(cfg.Listeners[0])
+	if err != nil {
+		host, listenPort = "", activeNetParams.DefaultPort
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	return uint16(port), net.JoinHostPort(host, listenPort)
+}
+
+// createOnionService creates the onion service through the Tor control port
+// and adds its address to the local addresses advertised to peers.  The
+// service is removed by Tor when the returned controller is closed.
+func (s *server) createOnionService(keyFile string) (*connmgr.TorController, error) {
+	tc, err := connmgr.DialTorController(cfg.TorControl)
+	if err != nil {
+		return nil, err
+	}
+	if err := tc.Authenticate(cfg.TorPassword); err != nil {
+		tc.Close()
+		return nil, err
+	}
+
+	// Use the key of the service created by a previous run, if any.
+	privateKey := "NEW:" + onionKeyType
+	if key, err := ioutil.ReadFile(keyFile); err == nil {
+		privateKey = strings.TrimSpace(string(key))
+	} else if !os.IsNotExist(err) {
+		tc.Close()
+		return nil, err
+	}
+
+	port, target := onionServiceTarget()
+	serviceID, newKey, err := tc.AddOnion(privateKey, port, target)
+	if err != nil {
+		tc.Close()
+		return nil, err
+	}
+	if newKey != "" {
+		err := ioutil.WriteFile(keyFile, []byte(newKey+"\n"), 0600)
+		if err != nil {
+			tc.Close()
+			return nil, err
+		}
+	}
+
+	na, err := s.addrManager.HostToNetAddress(serviceID+".onion", port,
+		s.services)
+	if err != nil {
+		tc.Close()
+		return nil, err
+	}
+	err = s.addrManager.AddLocalAddress(na, addrmgr.ManualPrio)
+	if err != nil {
+		tc.Close()
+		return nil, err
+	}
+	srvrLog.Infof("Accepting connections from Tor on onion service "+
+		"%s.onion:%d", serviceID, port)
+	return tc, nil
+}
+
+// onionServiceHandler keeps the onion service incoming connections from Tor
+// are accepted on.  The service is created again when it fails to be created
+// or the connection to the Tor control port is lost, which removes it.
+//
+// It must be run as a goroutine.
+func (s *server) onionServiceHandler() {
+	keyFile := filepath.Join(cfg.DataDir, onionKeyFileName)
+out:
+	for {
+		tc, err := s.createOnionService(keyFile)
+		if err != nil {
+			srvrLog.Warnf("Unable to create onion service: %v", err)
+		} else {
+			done := make(chan error, 1)
+			go func() {
+				done <- tc.Wait()
+			}()
+			select {
+			case err := <-done:
+				srvrLog.Warnf("Lost connection to the Tor control "+
+					"port, which removed the onion service: %v",
+					err)
+			case <-s.quit:
+				tc.Close()
+				break out
+			}
+		}
+
+		select {
+		case <-time.After(onionServiceRetryInterval):
+		case <-s.quit:
+			break out
+		}
+	}
+
+	s.wg.Done()
+}
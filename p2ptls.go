@@ -0,0 +1,175 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// p2pTLSHandshakeTimeout is the maximum time the TLS handshake of a
+	// peer connection, including the detection of whether the remote peer
+	// starts one, may take.
+	p2pTLSHandshakeTimeout = 30 * time.Second
+
+	// tlsRecordTypeHandshake and tlsMajorVersion are the first two bytes of
+	// the TLS record which starts a TLS handshake.  No network magic starts
+	// with them, so they tell TLS connections apart from plaintext ones.
+	tlsRecordTypeHandshake = 0x16
+	tlsMajorVersion        = 0x03
+)
+
+// certPin is the SHA-256 fingerprint of a DER-encoded certificate.
+type certPin [sha256.Size]byte
+
+// parseCertPin parses a hex-encoded SHA-256 certificate fingerprint.  The
+// bytes may be separated by colons as in the output of openssl.
+func parseCertPin(s string) (certPin, error) {
+	var pin certPin
+	b, err := hex.DecodeString(strings.Replace(s, ":", "", -1))
+	if err != nil || len(b) != len(pin) {
+		return pin, fmt.Errorf("invalid certificate fingerprint %q", s)
+	}
+	copy(pin[:], b)
+	return pin, nil
+}
+
+// verifyPinnedCert returns a function which ensures the leaf certificate a
+// peer presents is one of the passed pinned certificates.
+func verifyPinnedCert(pins []certPin) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("peer presented no certificate")
+		}
+		fingerprint := certPin(sha256.Sum256(rawCerts[0]))
+		for _, pin := range pins {
+			if pin == fingerprint {
+				return nil
+			}
+		}
+		return fmt.Errorf("peer certificate %x is not pinned",
+			fingerprint[:])
+	}
+}
+
+// p2pTLS provides the TLS-encrypted peer-to-peer transport.  Nodes which
+// accept TLS connections advertise it with the SFNodeTLS service flag, and
+// connections are upgraded to TLS when they are dialed to peers known to
+// advertise it.  Inbound connections are told apart by their first bytes, so
+// TLS and plaintext peers share the same listeners.
+type p2pTLS struct {
+	config   *tls.Config
+	required bool
+}
+
+// newP2PTLS returns the TLS transport for peer connections using the passed
+// certificate and key files, which are generated when both do not exist.
+// Peers are only authenticated when certificate pins are passed, in which case
+// inbound peers have to present a client certificate as well.  Plaintext
+// connections are refused when required is set, which pins require since
+// plaintext peers would not be authenticated.
+func newP2PTLS(certFile, keyFile string, pins []certPin, required bool) (*p2pTLS, error) {
+	if len(pins) != 0 && !required {
+		return nil, errors.New("pinned peer certificates require " +
+			"TLS-encrypted peer connections to be required")
+	}
+	if !fileExists(keyFile) && !fileExists(certFile) {
+		if err := genCertPair(certFile, keyFile); err != nil {
+			return nil, err
+		}
+	}
+	keypair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// The certificates of peers are self-signed, so they are verified
+	// against the pinned fingerprints rather than certificate authorities.
+	config := &tls.Config{
+		Certificates:       []tls.Certificate{keypair},
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: true,
+	}
+	if len(pins) != 0 {
+		config.ClientAuth = tls.RequireAnyClientCert
+		config.VerifyPeerCertificate = verifyPinnedCert(pins)
+	}
+	return &p2pTLS{config: config, required: required}, nil
+}
+
+// handshake performs the TLS handshake of the passed connection within the
+// handshake timeout.
+func handshake(conn net.Conn, tlsConn *tls.Conn) error {
+	conn.SetDeadline(time.Now().Add(p2pTLSHandshakeTimeout))
+	err := tlsConn.Handshake()
+	conn.SetDeadline(time.Time{})
+	return err
+}
+
+// client upgrades the passed outbound connection to TLS.
+func (t *p2pTLS) client(conn net.Conn) (net.Conn, error) {
+	tlsConn := tls.Client(conn, t.config)
+	if err := handshake(conn, tlsConn); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// peekedConn is a connection whose first bytes were peeked at.  Reads are
+// served from the buffered reader the bytes were peeked with.
+type peekedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Read reads data from the connection, starting with the peeked bytes.
+//
+// This is part of the net.Conn interface.
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// accept returns the passed inbound connection upgraded to TLS when the remote
+// peer starts a TLS handshake, or the plaintext connection otherwise.  An
+// error is returned for plaintext connections when TLS is required.
+func (t *p2pTLS) accept(conn net.Conn) (net.Conn, error) {
+	r := bufio.NewReader(conn)
+	conn.SetReadDeadline(time.Now().Add(p2pTLSHandshakeTimeout))
+	start, err := r.Peek(2)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	peeked := &peekedConn{Conn: conn, r: r}
+
+	if start[0] != tlsRecordTypeHandshake || start[1] != tlsMajorVersion {
+		if t.required {
+			return nil, errors.New("plaintext connection refused")
+		}
+		return peeked, nil
+	}
+
+	tlsConn := tls.Server(peeked, t.config)
+	if err := handshake(conn, tlsConn); err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// isTLSConn returns whether or not the passed connection is encrypted with
+// TLS.
+func isTLSConn(conn net.Conn) bool {
+	_, ok := conn.(*tls.Conn)
+	return ok
+}
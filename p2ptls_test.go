@@ -0,0 +1,258 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestParseCertPin ensures certificate fingerprints are parsed with and
+// without colons, and invalid ones are rejected.
+func TestParseCertPin(t *testing.T) {
+	t.Parallel()
+
+	want := certPin(sha256.Sum256([]byte("prova")))
+	hexPin := hex.EncodeToString(want[:])
+	colonPin := ""
+	for i := 0; i < len(hexPin); i += 2 {
+		if i > 0 {
+			colonPin += ":"
+		}
+		colonPin += hexPin[i : i+2]
+	}
+
+	for _, s := range []string{hexPin, colonPin} {
+		pin, err := parseCertPin(s)
+		if err != nil {
+			t.Errorf("parseCertPin(%q): unexpected error: %v", s, err)
+			continue
+		}
+		if pin != want {
+			t.Errorf("parseCertPin(%q): got %x, want %x", s, pin, want)
+		}
+	}
+
+	for _, s := range []string{"", "zz", hexPin[:62], hexPin + "00"} {
+		if _, err := parseCertPin(s); err == nil {
+			t.Errorf("parseCertPin(%q): unexpected success", s)
+		}
+	}
+}
+
+// newTestP2PTLS returns a TLS transport whose certificate is generated in the
+// passed directory under the passed name, along with the pin of the
+// certificate.
+func newTestP2PTLS(t *testing.T, dir, name string, pins []certPin,
+	required bool) (*p2pTLS, certPin) {
+
+	certFile := filepath.Join(dir, name+".cert")
+	keyFile := filepath.Join(dir, name+".key")
+	p2pTLS, err := newP2PTLS(certFile, keyFile, pins, required)
+	if err != nil {
+		t.Fatalf("newP2PTLS: unexpected error: %v", err)
+	}
+
+	certPEM, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		t.Fatalf("unable to read certificate: %v", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		t.Fatalf("unable to decode certificate")
+	}
+	return p2pTLS, sha256.Sum256(block.Bytes)
+}
+
+// acceptResult is the outcome of accepting a connection.
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// testAccept accepts a connection on the passed listener with the passed
+// transport and sends the outcome to the returned channel.
+func testAccept(t *testing.T, l net.Listener, server *p2pTLS) chan acceptResult {
+	result := make(chan acceptResult, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			result <- acceptResult{err: err}
+			return
+		}
+		accepted, err := server.accept(conn)
+		if err != nil {
+			conn.Close()
+		}
+		result <- acceptResult{conn: accepted, err: err}
+	}()
+	return result
+}
+
+// TestP2PTLS ensures TLS and plaintext peer connections are told apart,
+// plaintext connections are refused when TLS is required, and only peers with
+// pinned certificates are accepted when certificates are pinned.
+func TestP2PTLS(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "prova")
+	if err != nil {
+		t.Fatalf("unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	defer l.Close()
+
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("unable to dial: %v", err)
+		}
+		return conn
+	}
+
+	server, serverPin := newTestP2PTLS(t, dir, "server", nil, false)
+	client, clientPin := newTestP2PTLS(t, dir, "client", nil, false)
+	other, _ := newTestP2PTLS(t, dir, "other", nil, false)
+
+	// The certificate is only generated when it does not exist yet.
+	_, pin := newTestP2PTLS(t, dir, "server", nil, false)
+	if pin != serverPin {
+		t.Errorf("the existing certificate was not used")
+	}
+
+	// Connections starting a TLS handshake are encrypted.
+	result := testAccept(t, l, server)
+	conn, err := client.client(dial())
+	if err != nil {
+		t.Fatalf("client: unexpected error: %v", err)
+	}
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("unable to write: %v", err)
+	}
+	r := <-result
+	if r.err != nil {
+		t.Fatalf("accept: unexpected error: %v", r.err)
+	}
+	if !isTLSConn(r.conn) {
+		t.Errorf("accept: got plaintext connection, want TLS")
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(r.conn, buf); err != nil ||
+		string(buf) != "ping" {
+
+		t.Errorf("accept: got %q (%v), want %q", buf, err, "ping")
+	}
+	conn.Close()
+	r.conn.Close()
+
+	// Plaintext connections are accepted unless TLS is required, and the
+	// bytes peeked at are still read.  The first byte of the simnet magic
+	// is the one of a TLS handshake record.
+	magic := []byte{0x16, 0x1c, 0x14, 0x12}
+	required, _ := newTestP2PTLS(t, dir, "server", nil, true)
+	for _, test := range []struct {
+		server  *p2pTLS
+		wantErr bool
+	}{
+		{server, false},
+		{required, true},
+	} {
+		result := testAccept(t, l, test.server)
+		conn := dial()
+		if _, err := conn.Write(magic); err != nil {
+			t.Fatalf("unable to write: %v", err)
+		}
+		r := <-result
+		conn.Close()
+		if test.wantErr {
+			if r.err == nil {
+				t.Errorf("accept: plaintext connection was not " +
+					"refused")
+				r.conn.Close()
+			}
+			continue
+		}
+		if r.err != nil {
+			t.Fatalf("accept: unexpected error: %v", r.err)
+		}
+		if isTLSConn(r.conn) {
+			t.Errorf("accept: got TLS connection, want plaintext")
+		}
+		buf := make([]byte, len(magic))
+		if _, err := io.ReadFull(r.conn, buf); err != nil ||
+			string(buf) != string(magic) {
+
+			t.Errorf("accept: got %x (%v), want %x", buf, err, magic)
+		}
+		r.conn.Close()
+	}
+
+	// Peers pinning certificates only complete handshakes with peers
+	// presenting a pinned certificate.
+	pinned, _ := newTestP2PTLS(t, dir, "server", []certPin{clientPin},
+		true)
+	pinning, _ := newTestP2PTLS(t, dir, "client", []certPin{serverPin},
+		true)
+	otherPinning, _ := newTestP2PTLS(t, dir, "client",
+		[]certPin{clientPin}, true)
+
+	// Pinning certificates without requiring TLS is refused since
+	// plaintext peers would not be authenticated.
+	_, err = newP2PTLS(filepath.Join(dir, "client.cert"),
+		filepath.Join(dir, "client.key"), []certPin{serverPin}, false)
+	if err == nil {
+		t.Error("newP2PTLS: unexpected success with pins without " +
+			"required TLS")
+	}
+	tests := []struct {
+		name    string
+		server  *p2pTLS
+		client  *p2pTLS
+		wantErr bool
+	}{
+		{"pinned client", pinned, client, false},
+		{"unpinned client", pinned, other, true},
+		{"pinned server", server, pinning, false},
+		{"unpinned server", server, otherPinning, true},
+	}
+	for _, test := range tests {
+		result := testAccept(t, l, test.server)
+		raw := dial()
+		conn, clientErr := test.client.client(raw)
+		if clientErr == nil {
+			// Complete the exchange so the server learns whether
+			// the client certificate is accepted.
+			conn.Write([]byte("ping"))
+		}
+		r := <-result
+		raw.Close()
+		if r.conn != nil {
+			buf := make([]byte, 4)
+			_, err := io.ReadFull(r.conn, buf)
+			if r.err == nil && err != nil {
+				r.err = err
+			}
+			r.conn.Close()
+		}
+		gotErr := clientErr != nil || r.err != nil
+		if gotErr != test.wantErr {
+			t.Errorf("%s: got client error %v and server error %v, "+
+				"want error %v", test.name, clientErr, r.err,
+				test.wantErr)
+		}
+	}
+}
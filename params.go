@@ -6,6 +6,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/bitgo/prova/chaincfg"
 )
 
@@ -53,3 +55,32 @@ var simNetParams = params{
 	Params:  &chaincfg.SimNetParams,
 	rpcPort: "18556",
 }
+
+// loadChainConfig loads the custom network described by the chain config file
+// at the passed path and registers its parameters.  The RPC port of the main
+// network is used when the file does not specify one.
+func loadChainConfig(path string) (*params, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	chainConfig, err := chaincfg.DecodeChainConfig(f)
+	if err != nil {
+		return nil, err
+	}
+	netParams, err := chainConfig.Params()
+	if err != nil {
+		return nil, err
+	}
+	if err := chaincfg.Register(netParams); err != nil {
+		return nil, err
+	}
+
+	rpcPort := chainConfig.RPCPort
+	if rpcPort == "" {
+		rpcPort = mainNetParams.rpcPort
+	}
+	return &params{Params: netParams, rpcPort: rpcPort}, nil
+}
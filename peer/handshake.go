@@ -0,0 +1,71 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"strings"
+
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// handshakeProofComment is the user agent comment which announces that
+	// the sender knows a handshake token and sends a hsproof message after
+	// the version messages have been exchanged.
+	handshakeProofComment = "hsproof"
+
+	// handshakeProofTag separates the proofs of the handshake token from
+	// any other use of the token as a key.
+	handshakeProofTag = "prova handshake proof"
+)
+
+// handshakeProof returns the proof of the passed handshake token sent by the
+// peer with the passed version nonce to the peer with the passed version nonce.
+// The nonce of the receiver acts as a challenge, so a proof observed on one
+// connection is not accepted on any other, and the order of the nonces keeps
+// a proof from being reflected back to its sender.  The token itself is never
+// sent to the remote peer.
+//
+// The proof does not protect a connection against an attacker relaying it
+// between two peers which know the token.  TLS is required for that.
+func handshakeProof(token []byte, senderNonce, receiverNonce uint64) [wire.HandshakeProofSize]byte {
+	var buf [16]byte
+	binary.LittleEndian.PutUint64(buf[:8], senderNonce)
+	binary.LittleEndian.PutUint64(buf[8:], receiverNonce)
+	mac := hmac.New(sha256.New, token)
+	mac.Write([]byte(handshakeProofTag))
+	mac.Write(buf[:])
+
+	var proof [wire.HandshakeProofSize]byte
+	copy(proof[:], mac.Sum(nil))
+	return proof
+}
+
+// verifyHandshakeProof returns whether or not the passed proof was made with
+// the passed handshake token by the peer with the passed version nonce for the
+// peer with the passed version nonce.
+func verifyHandshakeProof(token []byte, proof *wire.MsgHandshakeProof, senderNonce, receiverNonce uint64) bool {
+	want := handshakeProof(token, senderNonce, receiverNonce)
+	return hmac.Equal(proof.Proof[:], want[:])
+}
+
+// announcesHandshakeProof returns whether or not the user agent of the passed
+// version message announces that the sender sends a hsproof message.
+func announcesHandshakeProof(msg *wire.MsgVersion) bool {
+	// The comments of the user agent are enclosed in parentheses and
+	// separated by semicolons.
+	fields := strings.FieldsFunc(msg.UserAgent, func(r rune) bool {
+		return r == '(' || r == ')' || r == ';' || r == ' ' || r == '/'
+	})
+	for _, field := range fields {
+		if field == handshakeProofComment {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/wire"
+)
+
+// TestVerifyHandshakeProof ensures the proof of the handshake token is only
+// accepted for the token and pair of version nonces it was made for.
+func TestVerifyHandshakeProof(t *testing.T) {
+	t.Parallel()
+
+	token := []byte("secret")
+	proof := wire.NewMsgHandshakeProof(handshakeProof(token, 1, 2))
+	if !verifyHandshakeProof(token, proof, 1, 2) {
+		t.Errorf("valid proof not verified")
+	}
+	if verifyHandshakeProof([]byte("other"), proof, 1, 2) {
+		t.Errorf("proof verified with a different token")
+	}
+
+	// A proof observed on one connection must not be accepted on another
+	// one, where the receiver chose a different nonce, even when the
+	// nonce of the sender is replayed.
+	if verifyHandshakeProof(token, proof, 1, 3) {
+		t.Errorf("proof verified for a different receiver nonce")
+	}
+	if verifyHandshakeProof(token, proof, 3, 2) {
+		t.Errorf("proof verified for a different sender nonce")
+	}
+
+	// A proof must not be reflected back to its sender.
+	if verifyHandshakeProof(token, proof, 2, 1) {
+		t.Errorf("reflected proof verified")
+	}
+}
+
+// TestAnnouncesHandshakeProof ensures the announcement of the proof is only
+// recognized as a comment of its own in the user agent.
+func TestAnnouncesHandshakeProof(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		comments []string
+		want     bool
+	}{
+		{[]string{handshakeProofComment}, true},
+		{[]string{"other", handshakeProofComment}, true},
+		{[]string{"other"}, false},
+		{[]string{handshakeProofComment + "=1"}, false},
+		{nil, false},
+	}
+	for i, test := range tests {
+		msg := wire.NewMsgVersion(&wire.NetAddress{}, &wire.NetAddress{},
+			1, 0)
+		msg.AddUserAgent("peer", "1.0", test.comments...)
+		if got := announcesHandshakeProof(msg); got != test.want {
+			t.Errorf("test #%d: user agent %q announces %v, want %v",
+				i, msg.UserAgent, got, test.want)
+		}
+	}
+}
@@ -205,6 +205,19 @@ func messageSummary(msg wire.Message) string {
 		return fmt.Sprintf("hash %s, ver %d, %d tx, %s", msg.BlockHash(),
 			header.Version, len(msg.Transactions), header.Timestamp)
 
+	case *wire.MsgCmpctBlock:
+		return fmt.Sprintf("hash %s, %d tx, %d prefilled",
+			msg.Header.BlockHash(), msg.TotalTxns(),
+			len(msg.PrefilledTxns))
+
+	case *wire.MsgGetBlockTxn:
+		return fmt.Sprintf("hash %s, %d tx", msg.BlockHash,
+			len(msg.Indexes))
+
+	case *wire.MsgBlockTxn:
+		return fmt.Sprintf("hash %s, %d tx", msg.BlockHash,
+			len(msg.Transactions))
+
 	case *wire.MsgInv:
 		return invSummary(msg.InvList)
 
@@ -28,7 +28,7 @@ import (
 
 const (
 	// MaxProtocolVersion is the max protocol version the peer supports.
-	MaxProtocolVersion = wire.FeeFilterVersion
+	MaxProtocolVersion = wire.NoChecksumVersion
 
 	// outputBufferSize is the number of elements the output channels use.
 	outputBufferSize = 50
@@ -102,6 +102,9 @@ type MessageListeners struct {
 	// OnAddr is invoked when a peer receives an addr bitcoin message.
 	OnAddr func(p *Peer, msg *wire.MsgAddr)
 
+	// OnAddrV2 is invoked when a peer receives an addrv2 bitcoin message.
+	OnAddrV2 func(p *Peer, msg *wire.MsgAddrV2)
+
 	// OnPing is invoked when a peer receives a ping bitcoin message.
 	OnPing func(p *Peer, msg *wire.MsgPing)
 
@@ -172,6 +175,37 @@ type MessageListeners struct {
 	// message.
 	OnSendHeaders func(p *Peer, msg *wire.MsgSendHeaders)
 
+	// OnSendAddrV2 is invoked when a peer receives a sendaddrv2 bitcoin
+	// message before the verack message.
+	OnSendAddrV2 func(p *Peer, msg *wire.MsgSendAddrV2)
+
+	// OnNoChecksum is invoked when a peer receives a nochecksum message.
+	OnNoChecksum func(p *Peer, msg *wire.MsgNoChecksum)
+
+	// OnSendCmpct is invoked when a peer receives a sendcmpct message.
+	OnSendCmpct func(p *Peer, msg *wire.MsgSendCmpct)
+
+	// OnCmpctBlock is invoked when a peer receives a cmpctblock message.
+	OnCmpctBlock func(p *Peer, msg *wire.MsgCmpctBlock)
+
+	// OnGetBlockTxn is invoked when a peer receives a getblocktxn message.
+	OnGetBlockTxn func(p *Peer, msg *wire.MsgGetBlockTxn)
+
+	// OnBlockTxn is invoked when a peer receives a blocktxn message.
+	OnBlockTxn func(p *Peer, msg *wire.MsgBlockTxn)
+
+	// OnGetCFilters is invoked when a peer receives a getcfilters message.
+	OnGetCFilters func(p *Peer, msg *wire.MsgGetCFilters)
+
+	// OnCFilter is invoked when a peer receives a cfilter message.
+	OnCFilter func(p *Peer, msg *wire.MsgCFilter)
+
+	// OnGetCFHeaders is invoked when a peer receives a getcfheaders message.
+	OnGetCFHeaders func(p *Peer, msg *wire.MsgGetCFHeaders)
+
+	// OnCFHeaders is invoked when a peer receives a cfheaders message.
+	OnCFHeaders func(p *Peer, msg *wire.MsgCFHeaders)
+
 	// OnRead is invoked when a peer receives a bitcoin message.  It
 	// consists of the number of bytes read, the message, and whether or not
 	// an error in the read occurred.  Typically, callers will opt to use
@@ -234,6 +268,45 @@ type Config struct {
 	// not send inv messages for transactions.
 	DisableRelayTx bool
 
+	// AllowChecksumSkip specifies whether message checksums may be skipped
+	// when the connection is over a Unix socket or TrustedConn is set.  A
+	// nochecksum message is sent to such peers after the version
+	// negotiation when the negotiated protocol version supports it, and
+	// checksums are only omitted from messages sent to a remote peer once
+	// it has sent one as well.
+	AllowChecksumSkip bool
+
+	// TrustedConn specifies whether the operator explicitly trusts the
+	// connection to the remote peer, such as by whitelisting its address,
+	// which permits skipping message checksums on it when AllowChecksumSkip
+	// is set.
+	TrustedConn bool
+
+	// HandshakeToken is a secret shared by the peers of a private network.
+	// When it is set and the remote peer announces a token as well, both
+	// peers send a proof of the token over the nonces of both version
+	// messages directly after exchanging them, and HandshakeVerified
+	// reports whether the proof of the remote peer was valid.  The token
+	// itself is never sent.
+	HandshakeToken []byte
+
+	// SendLimiter and RecvLimiter limit the combined rate at which data is
+	// sent to and received from all of the peers sharing them.  They can be
+	// omitted in which case the combined rates are not limited.
+	SendLimiter *RateLimiter
+	RecvLimiter *RateLimiter
+
+	// MaxSendRate and MaxRecvRate limit the rate in bytes per second at
+	// which data is sent to and received from the peer alone.  They can be
+	// omitted in which case the rates of the peer are not limited.
+	//
+	// The rates are limited by pausing between messages.  Reads are paused
+	// the same way as while the listeners run, so the pauses do not count
+	// towards the stall and idle timeouts, and pongs are sent without
+	// waiting for the send rates.
+	MaxSendRate uint64
+	MaxRecvRate uint64
+
 	// Listeners houses callback functions to be invoked on receiving peer
 	// messages.
 	Listeners MessageListeners
@@ -340,6 +413,7 @@ type StatsSnap struct {
 	LastPingNonce  uint64
 	LastPingTime   time.Time
 	LastPingMicros int64
+	MinPingMicros  int64
 }
 
 // HashFunc is a function which returns a block hash, height and error
@@ -388,14 +462,25 @@ type Peer struct {
 	lastSend      int64
 	connected     int32
 	disconnect    int32
+	skipRecvCsum  int32
+	skipSendCsum  int32
+	lastDecode    int64
 
 	conn net.Conn
 
 	// These fields are set at creation time and never modified, so they are
 	// safe to read from concurrently without a mutex.
-	addr    string
-	cfg     Config
-	inbound bool
+	addr         string
+	cfg          Config
+	inbound      bool
+	sendLimiters []*RateLimiter
+	recvLimiters []*RateLimiter
+
+	// sendDelay and recvDelay are how long sending and reading are paused
+	// before the next message to respect the rate limits.  Each is only
+	// accessed by the goroutine writing to or reading from the connection.
+	sendDelay time.Duration
+	recvDelay time.Duration
 
 	flagsMtx             sync.Mutex // protects the peer flags below
 	na                   *wire.NetAddress
@@ -406,8 +491,12 @@ type Peer struct {
 	advertisedProtoVer   uint32 // protocol version advertised by remote
 	protocolVersion      uint32 // negotiated protocol version
 	sendHeadersPreferred bool   // peer sent a sendheaders message
+	sendCmpctPreferred   bool   // peer wants cmpctblock announcements
+	sendAddrV2Preferred  bool   // peer sent a sendaddrv2 message
 	versionSent          bool
+	localNonce           uint64 // nonce of the version message sent
 	verAckReceived       bool
+	handshakeVerified    bool // remote peer proved the handshake token
 
 	knownInventory     *mruInventoryMap
 	prevGetBlocksMtx   sync.Mutex
@@ -428,11 +517,12 @@ type Peer struct {
 	lastPingNonce      uint64    // Set to nonce if we have a pending ping.
 	lastPingTime       time.Time // Time we sent last ping.
 	lastPingMicros     int64     // Time for last ping to return.
+	minPingMicros      int64     // Lowest time for a ping to return.
 
 	stallControl  chan stallControlMsg
 	outputQueue   chan outMsg
 	sendQueue     chan outMsg
-	sendDoneQueue chan struct{}
+	sendDoneQueue chan time.Duration
 	outputInvChan chan *wire.InvVect
 	inQuit        chan struct{}
 	queueQuit     chan struct{}
@@ -512,6 +602,7 @@ func (p *Peer) StatsSnapshot() *StatsSnap {
 		LastPingNonce:  p.lastPingNonce,
 		LastPingMicros: p.lastPingMicros,
 		LastPingTime:   p.lastPingTime,
+		MinPingMicros:  p.minPingMicros,
 	}
 
 	p.statsMtx.RUnlock()
@@ -578,6 +669,18 @@ func (p *Peer) UserAgent() string {
 	return userAgent
 }
 
+// HandshakeVerified returns whether or not the remote peer proved that it
+// knows the handshake token of the local peer during the version handshake.
+//
+// This function is safe for concurrent access.
+func (p *Peer) HandshakeVerified() bool {
+	p.flagsMtx.Lock()
+	verified := p.handshakeVerified
+	p.flagsMtx.Unlock()
+
+	return verified
+}
+
 // LastAnnouncedBlock returns the last announced block of the remote peer.
 //
 // This function is safe for concurrent access.
@@ -622,6 +725,18 @@ func (p *Peer) LastPingMicros() int64 {
 	return lastPingMicros
 }
 
+// MinPingMicros returns the lowest ping micros of the remote peer, or 0 when
+// the peer has not answered any ping yet.
+//
+// This function is safe for concurrent access.
+func (p *Peer) MinPingMicros() int64 {
+	p.statsMtx.RLock()
+	minPingMicros := p.minPingMicros
+	p.statsMtx.RUnlock()
+
+	return minPingMicros
+}
+
 // VersionKnown returns the whether or not the version of a peer is known
 // locally.
 //
@@ -682,6 +797,15 @@ func (p *Peer) LastRecv() time.Time {
 	return time.Unix(atomic.LoadInt64(&p.lastRecv), 0)
 }
 
+// LastDecodeTime returns the time spent decoding the payload of the most
+// recently received message.  Message listeners are invoked before the next
+// message is read, so it is the decode time of the message passed to them.
+//
+// This function is safe for concurrent access.
+func (p *Peer) LastDecodeTime() time.Duration {
+	return time.Duration(atomic.LoadInt64(&p.lastDecode))
+}
+
 // LocalAddr returns the local address of the connection.
 //
 // This function is safe fo concurrent access.
@@ -755,6 +879,31 @@ func (p *Peer) WantsHeaders() bool {
 	return sendHeadersPreferred
 }
 
+// WantsAddrV2 returns if the peer wants addrv2 messages instead of addr
+// messages for addresses.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsAddrV2() bool {
+	p.flagsMtx.Lock()
+	sendAddrV2Preferred := p.sendAddrV2Preferred
+	p.flagsMtx.Unlock()
+
+	return sendAddrV2Preferred
+}
+
+// WantsCmpctBlocks returns if the peer wants new blocks to be announced by
+// sending cmpctblock messages directly instead of inventory vectors or header
+// messages.
+//
+// This function is safe for concurrent access.
+func (p *Peer) WantsCmpctBlocks() bool {
+	p.flagsMtx.Lock()
+	sendCmpctPreferred := p.sendCmpctPreferred
+	p.flagsMtx.Unlock()
+
+	return sendCmpctPreferred
+}
+
 // localVersionMsg creates a version message that can be used to send to the
 // remote peer.
 func (p *Peer) localVersionMsg() (*wire.MsgVersion, error) {
@@ -804,7 +953,12 @@ func (p *Peer) localVersionMsg() (*wire.MsgVersion, error) {
 
 	// Version message.
 	msg := wire.NewMsgVersion(ourNA, theirNA, nonce, blockNum)
-	msg.AddUserAgent(p.cfg.UserAgentName, p.cfg.UserAgentVersion)
+	if len(p.cfg.HandshakeToken) != 0 {
+		msg.AddUserAgent(p.cfg.UserAgentName, p.cfg.UserAgentVersion,
+			handshakeProofComment)
+	} else {
+		msg.AddUserAgent(p.cfg.UserAgentName, p.cfg.UserAgentVersion)
+	}
 
 	// XXX: bitcoind appears to always enable the full node services flag
 	// of the remote peer netaddress field in the version message regardless
@@ -871,6 +1025,41 @@ func (p *Peer) PushAddrMsg(addresses []*wire.NetAddress) ([]*wire.NetAddress, er
 	return msg.AddrList, nil
 }
 
+// PushAddrV2Msg sends an addrv2 message to the connected peer using the
+// provided addresses.  Like PushAddrMsg, it limits the addresses to the maximum
+// number allowed by the message and randomizes the chosen addresses when there
+// are too many.  It returns the addresses that were actually sent and no
+// message will be sent if there are no entries in the provided addresses slice.
+// Callers must only use it when WantsAddrV2 returns true.
+//
+// This function is safe for concurrent access.
+func (p *Peer) PushAddrV2Msg(addresses []*wire.NetAddressV2) ([]*wire.NetAddressV2, error) {
+
+	// Nothing to send.
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+
+	msg := wire.NewMsgAddrV2()
+	msg.AddrList = make([]*wire.NetAddressV2, len(addresses))
+	copy(msg.AddrList, addresses)
+
+	// Randomize the addresses sent if there are more than the maximum allowed.
+	if len(msg.AddrList) > wire.MaxAddrPerMsg {
+		// Shuffle the address list.
+		for i := range msg.AddrList {
+			j := rand.Intn(i + 1)
+			msg.AddrList[i], msg.AddrList[j] = msg.AddrList[j], msg.AddrList[i]
+		}
+
+		// Truncate it to the maximum size.
+		msg.AddrList = msg.AddrList[:wire.MaxAddrPerMsg]
+	}
+
+	p.QueueMessage(msg, nil)
+	return msg.AddrList, nil
+}
+
 // PushGetBlocksMsg sends a getblocks message for the provided block locator
 // and stop hash.  It will ignore back-to-back duplicate requests.
 //
@@ -1074,22 +1263,52 @@ func (p *Peer) handlePongMsg(msg *wire.MsgPong) {
 			p.lastPingMicros = time.Since(p.lastPingTime).Nanoseconds()
 			p.lastPingMicros /= 1000 // convert to usec.
 			p.lastPingNonce = 0
+			if p.minPingMicros == 0 ||
+				p.lastPingMicros < p.minPingMicros {
+
+				p.minPingMicros = p.lastPingMicros
+			}
 		}
 		p.statsMtx.Unlock()
 	}
 }
 
+// isUnixAddr returns whether the passed address refers to a Unix socket.
+func isUnixAddr(addr net.Addr) bool {
+	if _, ok := addr.(*net.UnixAddr); ok {
+		return true
+	}
+	return addr.Network() == "unix"
+}
+
+// checksumSkipAllowed returns whether the local peer is configured to skip
+// message checksums and the connection to the remote peer is either a Unix
+// socket or trusted by the operator.  Loopback connections are not enough on
+// their own since they include the peers connecting through a local Tor onion
+// service.
+func (p *Peer) checksumSkipAllowed() bool {
+	if !p.cfg.AllowChecksumSkip || p.conn == nil {
+		return false
+	}
+	return p.cfg.TrustedConn || isUnixAddr(p.conn.RemoteAddr())
+}
+
 // readMessage reads the next bitcoin message from the peer with logging.
 func (p *Peer) readMessage() (wire.Message, []byte, error) {
-	n, msg, buf, err := wire.ReadMessageN(p.conn, p.ProtocolVersion(),
-		p.cfg.ChainParams.Net)
+	skipChecksum := atomic.LoadInt32(&p.skipRecvCsum) != 0
+	n, msg, buf, decodeTime, err := wire.ReadMessageTimedN(p.conn,
+		p.ProtocolVersion(), p.cfg.ChainParams.Net, skipChecksum)
 	atomic.AddUint64(&p.bytesReceived, uint64(n))
+	atomic.StoreInt64(&p.lastDecode, int64(decodeTime))
 	if p.cfg.Listeners.OnRead != nil {
 		p.cfg.Listeners.OnRead(p, n, msg, err)
 	}
 	if err != nil {
 		return nil, nil, err
 	}
+	if delay := p.charge(p.recvLimiters, n); delay > p.recvDelay {
+		p.recvDelay = delay
+	}
 
 	// Use closures to log expensive operations so they are only run when
 	// the logging level requires it.
@@ -1144,13 +1363,53 @@ func (p *Peer) writeMessage(msg wire.Message) error {
 	}))
 
 	// Write the message to the peer.
-	n, err := wire.WriteMessageN(p.conn, msg, p.ProtocolVersion(),
+	writeMessageN := wire.WriteMessageN
+	if atomic.LoadInt32(&p.skipSendCsum) != 0 {
+		writeMessageN = wire.WriteMessageNoChecksumN
+	}
+	n, err := writeMessageN(p.conn, msg, p.ProtocolVersion(),
 		p.cfg.ChainParams.Net)
 	atomic.AddUint64(&p.bytesSent, uint64(n))
 	if p.cfg.Listeners.OnWrite != nil {
 		p.cfg.Listeners.OnWrite(p, n, msg, err)
 	}
-	return err
+	if err != nil {
+		return err
+	}
+	if delay := p.charge(p.sendLimiters, n); delay > p.sendDelay {
+		p.sendDelay = delay
+	}
+	return nil
+}
+
+// charge charges the passed number of bytes transferred to the passed rate
+// limiters and returns how long to wait until all of them allow for more data
+// to be transferred.
+func (p *Peer) charge(limiters []*RateLimiter, n int) time.Duration {
+	var delay time.Duration
+	now := time.Now()
+	for _, limiter := range limiters {
+		if d := limiter.charge(n, now); d > delay {
+			delay = d
+		}
+	}
+	return delay
+}
+
+// pauseReads blocks for the time reads are paused to respect the receive rate
+// limits or until the peer is disconnected.
+func (p *Peer) pauseReads() {
+	delay := p.recvDelay
+	p.recvDelay = 0
+	if delay <= 0 {
+		return
+	}
+	timer := time.NewTimer(delay)
+	select {
+	case <-timer.C:
+	case <-p.quit:
+		timer.Stop()
+	}
 }
 
 // isAllowedReadError returns whether or not the passed error is allowed without
@@ -1240,6 +1499,18 @@ func (p *Peer) maybeAddDeadline(pendingResponses map[string]time.Time, msgCmd st
 		// headers.
 		deadline = time.Now().Add(stallResponseTimeout * 3)
 		pendingResponses[wire.CmdHeaders] = deadline
+
+	case wire.CmdGetBlockTxn:
+		// Expects a blocktxn message.
+		pendingResponses[wire.CmdBlockTxn] = deadline
+
+	case wire.CmdGetCFilters:
+		// Expects a cfilter message.
+		pendingResponses[wire.CmdCFilter] = deadline
+
+	case wire.CmdGetCFHeaders:
+		// Expects a cfheaders message.
+		pendingResponses[wire.CmdCFHeaders] = deadline
 	}
 }
 
@@ -1443,8 +1714,13 @@ out:
 		atomic.StoreInt64(&p.lastRecv, time.Now().Unix())
 		p.stallControl <- stallControlMsg{sccReceiveMessage, rmsg}
 
-		// Handle each supported message type.
+		// Pause reading to respect the receive rate limits.  The pause
+		// is part of the handler time, so the stall deadlines are
+		// extended by it and the idle timer is stopped during it.
 		p.stallControl <- stallControlMsg{sccHandlerStart, rmsg}
+		p.pauseReads()
+
+		// Handle each supported message type.
 		switch msg := rmsg.(type) {
 		case *wire.MsgVersion:
 
@@ -1478,6 +1754,11 @@ out:
 				p.cfg.Listeners.OnAddr(p, msg)
 			}
 
+		case *wire.MsgAddrV2:
+			if p.cfg.Listeners.OnAddrV2 != nil {
+				p.cfg.Listeners.OnAddrV2(p, msg)
+			}
+
 		case *wire.MsgPing:
 			p.handlePingMsg(msg)
 			if p.cfg.Listeners.OnPing != nil {
@@ -1579,6 +1860,86 @@ out:
 				p.cfg.Listeners.OnSendHeaders(p, msg)
 			}
 
+		case *wire.MsgSendAddrV2:
+			// The preference for addrv2 messages is only accepted
+			// before the verack message as required by BIP0155.
+			// No read lock is necessary because verAckReceived is not
+			// written to in any other goroutine.
+			if p.verAckReceived {
+				log.Debugf("Ignoring sendaddrv2 message after "+
+					"verack from %v", p)
+				break
+			}
+			p.flagsMtx.Lock()
+			p.sendAddrV2Preferred = true
+			p.flagsMtx.Unlock()
+
+			if p.cfg.Listeners.OnSendAddrV2 != nil {
+				p.cfg.Listeners.OnSendAddrV2(p, msg)
+			}
+
+		case *wire.MsgNoChecksum:
+			// Only stop computing checksums for messages sent to the
+			// remote peer when the local configuration allows it too.
+			if p.checksumSkipAllowed() {
+				atomic.StoreInt32(&p.skipSendCsum, 1)
+			} else {
+				log.Debugf("Ignoring nochecksum message from %v", p)
+			}
+
+			if p.cfg.Listeners.OnNoChecksum != nil {
+				p.cfg.Listeners.OnNoChecksum(p, msg)
+			}
+
+		case *wire.MsgSendCmpct:
+			// Only announce new blocks to the peer with cmpctblock
+			// messages when it asked for a supported version.  A
+			// later sendcmpct message may turn announcing off again.
+			if msg.CmpctBlockVersion == wire.CmpctBlockVersion {
+				p.flagsMtx.Lock()
+				p.sendCmpctPreferred = msg.AnnounceUsingCmpctBlock
+				p.flagsMtx.Unlock()
+			}
+
+			if p.cfg.Listeners.OnSendCmpct != nil {
+				p.cfg.Listeners.OnSendCmpct(p, msg)
+			}
+
+		case *wire.MsgCmpctBlock:
+			if p.cfg.Listeners.OnCmpctBlock != nil {
+				p.cfg.Listeners.OnCmpctBlock(p, msg)
+			}
+
+		case *wire.MsgGetBlockTxn:
+			if p.cfg.Listeners.OnGetBlockTxn != nil {
+				p.cfg.Listeners.OnGetBlockTxn(p, msg)
+			}
+
+		case *wire.MsgBlockTxn:
+			if p.cfg.Listeners.OnBlockTxn != nil {
+				p.cfg.Listeners.OnBlockTxn(p, msg)
+			}
+
+		case *wire.MsgGetCFilters:
+			if p.cfg.Listeners.OnGetCFilters != nil {
+				p.cfg.Listeners.OnGetCFilters(p, msg)
+			}
+
+		case *wire.MsgCFilter:
+			if p.cfg.Listeners.OnCFilter != nil {
+				p.cfg.Listeners.OnCFilter(p, msg)
+			}
+
+		case *wire.MsgGetCFHeaders:
+			if p.cfg.Listeners.OnGetCFHeaders != nil {
+				p.cfg.Listeners.OnGetCFHeaders(p, msg)
+			}
+
+		case *wire.MsgCFHeaders:
+			if p.cfg.Listeners.OnCFHeaders != nil {
+				p.cfg.Listeners.OnCFHeaders(p, msg)
+			}
+
 		default:
 			log.Debugf("Received unhandled message of type %v "+
 				"from %v", rmsg.Command(), p)
@@ -1618,14 +1979,49 @@ func (p *Peer) queueHandler() {
 	// passed to outHandler.
 	waiting := false
 
+	// While the send rate limits are exceeded, the resume channel is set
+	// and only pongs are passed to the outHandler so they are not delayed
+	// past the ping timeout of the remote peer.  Pongs are queued in front
+	// of the other pending messages for the same reason.
+	var resume <-chan time.Time
+	var resumeTimer *time.Timer
+	defer func() {
+		if resumeTimer != nil {
+			resumeTimer.Stop()
+		}
+	}()
+
 	// To avoid duplication below.
 	queuePacket := func(msg outMsg, list *list.List, waiting bool) bool {
-		if !waiting {
+		_, isPong := msg.msg.(*wire.MsgPong)
+		if !waiting && (resume == nil || isPong) {
 			p.sendQueue <- msg
+			return true
+		}
+		if isPong {
+			list.PushFront(msg)
 		} else {
 			list.PushBack(msg)
 		}
-		// we are always waiting now.
+		return waiting
+	}
+
+	// sendNext passes the next pending message that may be sent to the
+	// outHandler and returns whether one was passed.
+	sendNext := func() bool {
+		next := pendingMsgs.Front()
+		if next == nil {
+			return false
+		}
+		if _, isPong := next.Value.(outMsg).msg.(*wire.MsgPong); !isPong &&
+			resume != nil {
+			return false
+		}
+
+		// Notify the outHandler about the next item to
+		// asynchronously send.
+		val := pendingMsgs.Remove(next)
+		p.sendQueue <- val.(outMsg)
 		return true
 	}
 out:
@@ -1635,20 +2031,32 @@ out:
 			waiting = queuePacket(msg, pendingMsgs, waiting)
 
 		// This channel is notified when a message has been sent across
-		// the network socket.
-		case <-p.sendDoneQueue:
-			// No longer waiting if there are no more messages
-			// in the pending messages queue.
-			next := pendingMsgs.Front()
-			if next == nil {
-				waiting = false
-				continue
+		// the network socket along with how long to pause sending to
+		// respect the send rate limits.
+		case delay := <-p.sendDoneQueue:
+			if delay > 0 {
+				if resumeTimer == nil {
+					resumeTimer = time.NewTimer(delay)
+				} else {
+					if resume != nil && !resumeTimer.Stop() {
+						<-resumeTimer.C
+					}
+					resumeTimer.Reset(delay)
+				}
+				resume = resumeTimer.C
 			}
 
-			// Notify the outHandler about the next item to
-			// asynchronously send.
-			val := pendingMsgs.Remove(next)
-			p.sendQueue <- val.(outMsg)
+			// No longer waiting if there are no more messages
+			// that may be sent in the pending messages queue.
+			waiting = sendNext()
+
+		// This channel is notified when the send rate limits allow for
+		// more data to be sent.
+		case <-resume:
+			resume = nil
+			if !waiting {
+				waiting = sendNext()
+			}
 
 		case iv := <-p.outputInvChan:
 			// No handshake?  They'll find out soon enough.
@@ -1783,12 +2191,13 @@ out:
 			// update the last send time, signal the sender of the
 			// message that it has been sent (if requested), and
 			// signal the send queue to the deliver the next queued
-			// message.
+			// message once the send rate limits allow for it.
 			atomic.StoreInt64(&p.lastSend, time.Now().Unix())
 			if msg.doneChan != nil {
 				msg.doneChan <- struct{}{}
 			}
-			p.sendDoneQueue <- struct{}{}
+			p.sendDoneQueue <- p.sendDelay
+			p.sendDelay = 0
 
 		case <-p.quit:
 			break out
@@ -1968,8 +2377,25 @@ func (p *Peer) start() error {
 	go p.outHandler()
 	go p.pingHandler()
 
+	// Ask for addrv2 messages, which must be done before sending the verack
+	// message, when the negotiated protocol version supports them.
+	if p.ProtocolVersion() >= wire.AddrV2Version {
+		p.QueueMessage(wire.NewMsgSendAddrV2(), nil)
+	}
+
 	// Send our verack message now that the IO processing machinery has started.
 	p.QueueMessage(wire.NewMsgVerAck(), nil)
+
+	// Inform local peers that checksums are no longer verified so they may
+	// stop computing them when the negotiated protocol version supports
+	// it.  Verification is disabled before the message is sent since the
+	// remote peer may start omitting them immediately.
+	if p.checksumSkipAllowed() &&
+		p.ProtocolVersion() >= wire.NoChecksumVersion {
+
+		atomic.StoreInt32(&p.skipRecvCsum, 1)
+		p.QueueMessage(wire.NewMsgNoChecksum(), nil)
+	}
 	return nil
 }
 
@@ -1983,12 +2409,13 @@ func (p *Peer) WaitForDisconnect() {
 
 // readRemoteVersionMsg waits for the next message to arrive from the remote
 // peer.  If the next message is not a version message or the version is not
-// acceptable then return an error.
-func (p *Peer) readRemoteVersionMsg() error {
+// acceptable then return an error.  The version message is returned unless a
+// different message was received.
+func (p *Peer) readRemoteVersionMsg() (*wire.MsgVersion, error) {
 	// Read their version message.
 	msg, _, err := p.readMessage()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	remoteVerMsg, ok := msg.(*wire.MsgVersion)
@@ -1998,10 +2425,76 @@ func (p *Peer) readRemoteVersionMsg() error {
 
 		rejectMsg := wire.NewMsgReject(msg.Command(), wire.RejectMalformed,
 			errStr)
-		return p.writeMessage(rejectMsg)
+		return nil, p.writeMessage(rejectMsg)
 	}
 
 	if err := p.handleRemoteVersionMsg(remoteVerMsg); err != nil {
+		return nil, err
+	}
+	return remoteVerMsg, nil
+}
+
+// exchangeHandshakeProofs exchanges the proofs of the handshake token with the
+// remote peer and verifies the proof it sends when both peers announced a token
+// in their version messages.  It must only be called once both version
+// messages have been exchanged since the proofs cover both of their nonces.
+//
+// The outbound peer sends its proof first, so the peers never wait for each
+// other to read.
+func (p *Peer) exchangeHandshakeProofs(remoteVerMsg *wire.MsgVersion) error {
+	if len(p.cfg.HandshakeToken) == 0 ||
+		!announcesHandshakeProof(remoteVerMsg) {
+
+		return nil
+	}
+
+	p.flagsMtx.Lock()
+	localNonce := p.localNonce
+	p.flagsMtx.Unlock()
+
+	proof := handshakeProof(p.cfg.HandshakeToken, localNonce,
+		remoteVerMsg.Nonce)
+	if !p.inbound {
+		err := p.writeMessage(wire.NewMsgHandshakeProof(proof))
+		if err != nil {
+			return err
+		}
+	}
+
+	// The remote peer announced its proof, so it must follow the version
+	// messages.
+	msg, _, err := p.readMessage()
+	if err != nil {
+		return err
+	}
+	remoteProof, ok := msg.(*wire.MsgHandshakeProof)
+	if !ok {
+		return fmt.Errorf("expected %s message after the version "+
+			"handshake, got %s", wire.CmdHandshakeProof,
+			msg.Command())
+	}
+	verified := verifyHandshakeProof(p.cfg.HandshakeToken, remoteProof,
+		remoteVerMsg.Nonce, localNonce)
+	p.flagsMtx.Lock()
+	p.handshakeVerified = verified
+	p.flagsMtx.Unlock()
+
+	if p.inbound {
+		return p.writeMessage(wire.NewMsgHandshakeProof(proof))
+	}
+	return nil
+}
+
+// finishVersionHandshake exchanges the proofs of the handshake token once both
+// version messages have been exchanged and then notifies the version listener.
+// The passed remote version message may be nil when the remote peer sent a
+// different message instead.
+func (p *Peer) finishVersionHandshake(remoteVerMsg *wire.MsgVersion) error {
+	if remoteVerMsg == nil {
+		return nil
+	}
+
+	if err := p.exchangeHandshakeProofs(remoteVerMsg); err != nil {
 		return err
 	}
 
@@ -2024,6 +2517,7 @@ func (p *Peer) writeLocalVersionMsg() error {
 
 	p.flagsMtx.Lock()
 	p.versionSent = true
+	p.localNonce = localVerMsg.Nonce
 	p.flagsMtx.Unlock()
 	return nil
 }
@@ -2032,11 +2526,16 @@ func (p *Peer) writeLocalVersionMsg() error {
 // then sends our version message. If the events do not occur in that order then
 // it returns an error.
 func (p *Peer) negotiateInboundProtocol() error {
-	if err := p.readRemoteVersionMsg(); err != nil {
+	remoteVerMsg, err := p.readRemoteVersionMsg()
+	if err != nil {
 		return err
 	}
 
-	return p.writeLocalVersionMsg()
+	if err := p.writeLocalVersionMsg(); err != nil {
+		return err
+	}
+
+	return p.finishVersionHandshake(remoteVerMsg)
 }
 
 // negotiateOutboundProtocol sends our version message then waits to receive a
@@ -2047,7 +2546,12 @@ func (p *Peer) negotiateOutboundProtocol() error {
 		return err
 	}
 
-	return p.readRemoteVersionMsg()
+	remoteVerMsg, err := p.readRemoteVersionMsg()
+	if err != nil {
+		return err
+	}
+
+	return p.finishVersionHandshake(remoteVerMsg)
 }
 
 // newPeerBase returns a new base bitcoin peer based on the inbound flag.  This
@@ -2071,8 +2575,8 @@ func newPeerBase(origCfg *Config, inbound bool) *Peer {
 		knownInventory:  newMruInventoryMap(maxKnownInventory),
 		stallControl:    make(chan stallControlMsg, 1), // nonblocking sync
 		outputQueue:     make(chan outMsg, outputBufferSize),
-		sendQueue:       make(chan outMsg, 1),   // nonblocking sync
-		sendDoneQueue:   make(chan struct{}, 1), // nonblocking sync
+		sendQueue:       make(chan outMsg, 1),        // nonblocking sync
+		sendDoneQueue:   make(chan time.Duration, 1), // nonblocking sync
 		outputInvChan:   make(chan *wire.InvVect, outputBufferSize),
 		inQuit:          make(chan struct{}),
 		queueQuit:       make(chan struct{}),
@@ -2082,6 +2586,20 @@ func newPeerBase(origCfg *Config, inbound bool) *Peer {
 		services:        cfg.Services,
 		protocolVersion: cfg.ProtocolVersion,
 	}
+	if cfg.SendLimiter != nil {
+		p.sendLimiters = append(p.sendLimiters, cfg.SendLimiter)
+	}
+	if cfg.MaxSendRate != 0 {
+		p.sendLimiters = append(p.sendLimiters,
+			NewRateLimiter(cfg.MaxSendRate))
+	}
+	if cfg.RecvLimiter != nil {
+		p.recvLimiters = append(p.recvLimiters, cfg.RecvLimiter)
+	}
+	if cfg.MaxRecvRate != 0 {
+		p.recvLimiters = append(p.recvLimiters,
+			NewRateLimiter(cfg.MaxRecvRate))
+	}
 	return &p
 }
 
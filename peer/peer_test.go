@@ -6,10 +6,13 @@
 package peer_test
 
 import (
+	"bytes"
 	"errors"
 	"io"
+	"io/ioutil"
 	"net"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -101,6 +104,7 @@ type peerStats struct {
 	wantLastPingTime    time.Time
 	wantLastPingNonce   uint64
 	wantLastPingMicros  int64
+	wantMinPingMicros   int64
 	wantTimeOffset      int64
 	wantBytesSent       uint64
 	wantBytesReceived   uint64
@@ -133,6 +137,11 @@ func testPeer(t *testing.T, p *peer.Peer, s peerStats) {
 		return
 	}
 
+	if p.MinPingMicros() != s.wantMinPingMicros {
+		t.Errorf("testPeer: wrong MinPingMicros - got %v, want %v", p.MinPingMicros(), s.wantMinPingMicros)
+		return
+	}
+
 	if p.VerAckReceived() != s.wantVerAckReceived {
 		t.Errorf("testPeer: wrong VerAckReceived - got %v, want %v", p.VerAckReceived(), s.wantVerAckReceived)
 		return
@@ -234,9 +243,10 @@ func TestPeerConnection(t *testing.T) {
 		wantLastPingTime:    time.Time{},
 		wantLastPingNonce:   uint64(0),
 		wantLastPingMicros:  int64(0),
+		wantMinPingMicros:   int64(0),
 		wantTimeOffset:      int64(0),
-		wantBytesSent:       158, // 134 version + 24 verack
-		wantBytesReceived:   158,
+		wantBytesSent:       182, // 134 version + 24 sendaddrv2 + 24 verack
+		wantBytesReceived:   182,
 	}
 	tests := []struct {
 		name  string
@@ -315,6 +325,7 @@ func TestPeerConnection(t *testing.T) {
 // TestPeerListeners tests that the peer listeners are called as expected.
 func TestPeerListeners(t *testing.T) {
 	verack := make(chan struct{}, 1)
+	sendAddrV2 := make(chan struct{}, 2)
 	ok := make(chan wire.Message, 20)
 	peerCfg := &peer.Config{
 		Listeners: peer.MessageListeners{
@@ -324,6 +335,9 @@ func TestPeerListeners(t *testing.T) {
 			OnAddr: func(p *peer.Peer, msg *wire.MsgAddr) {
 				ok <- msg
 			},
+			OnAddrV2: func(p *peer.Peer, msg *wire.MsgAddrV2) {
+				ok <- msg
+			},
 			OnPing: func(p *peer.Peer, msg *wire.MsgPing) {
 				ok <- msg
 			},
@@ -387,6 +401,33 @@ func TestPeerListeners(t *testing.T) {
 			OnSendHeaders: func(p *peer.Peer, msg *wire.MsgSendHeaders) {
 				ok <- msg
 			},
+			OnSendAddrV2: func(p *peer.Peer, msg *wire.MsgSendAddrV2) {
+				sendAddrV2 <- struct{}{}
+			},
+			OnSendCmpct: func(p *peer.Peer, msg *wire.MsgSendCmpct) {
+				ok <- msg
+			},
+			OnCmpctBlock: func(p *peer.Peer, msg *wire.MsgCmpctBlock) {
+				ok <- msg
+			},
+			OnGetBlockTxn: func(p *peer.Peer, msg *wire.MsgGetBlockTxn) {
+				ok <- msg
+			},
+			OnBlockTxn: func(p *peer.Peer, msg *wire.MsgBlockTxn) {
+				ok <- msg
+			},
+			OnGetCFilters: func(p *peer.Peer, msg *wire.MsgGetCFilters) {
+				ok <- msg
+			},
+			OnCFilter: func(p *peer.Peer, msg *wire.MsgCFilter) {
+				ok <- msg
+			},
+			OnGetCFHeaders: func(p *peer.Peer, msg *wire.MsgGetCFHeaders) {
+				ok <- msg
+			},
+			OnCFHeaders: func(p *peer.Peer, msg *wire.MsgCFHeaders) {
+				ok <- msg
+			},
 		},
 		UserAgentName:    "peer",
 		UserAgentVersion: "1.0",
@@ -433,6 +474,10 @@ func TestPeerListeners(t *testing.T) {
 			"OnAddr",
 			wire.NewMsgAddr(),
 		},
+		{
+			"OnAddrV2",
+			wire.NewMsgAddrV2(),
+		},
 		{
 			"OnPing",
 			wire.NewMsgPing(42),
@@ -511,7 +556,50 @@ func TestPeerListeners(t *testing.T) {
 			"OnSendHeaders",
 			wire.NewMsgSendHeaders(),
 		},
+		{
+			"OnSendCmpct",
+			wire.NewMsgSendCmpct(true, wire.CmpctBlockVersion),
+		},
+		{
+			"OnCmpctBlock",
+			wire.NewMsgCmpctBlock(wire.NewBlockHeader(&chainhash.Hash{}, &chainhash.Hash{}, 1, 1), 42),
+		},
+		{
+			"OnGetBlockTxn",
+			wire.NewMsgGetBlockTxn(&chainhash.Hash{}),
+		},
+		{
+			"OnBlockTxn",
+			wire.NewMsgBlockTxn(&chainhash.Hash{}),
+		},
+		{
+			"OnGetCFilters",
+			wire.NewMsgGetCFilters(wire.GCSFilterBasic, 0, &chainhash.Hash{}),
+		},
+		{
+			"OnCFilter",
+			wire.NewMsgCFilter(wire.GCSFilterBasic, &chainhash.Hash{}, []byte{0x00}),
+		},
+		{
+			"OnGetCFHeaders",
+			wire.NewMsgGetCFHeaders(wire.GCSFilterBasic, 0, &chainhash.Hash{}),
+		},
+		{
+			"OnCFHeaders",
+			wire.NewMsgCFHeaders(wire.GCSFilterBasic, &chainhash.Hash{}, &chainhash.Hash{}),
+		},
+	}
+	// The sendaddrv2 message is sent before the verack message by peers
+	// which negotiated a recent enough protocol version.
+	select {
+	case <-sendAddrV2:
+	default:
+		t.Errorf("TestPeerListeners: no sendaddrv2 before verack")
 	}
+
+	// A sendaddrv2 message after the verack message must be ignored.
+	outPeer.QueueMessage(wire.NewMsgSendAddrV2(), nil)
+
 	t.Logf("Running %d tests", len(tests))
 	for _, test := range tests {
 		// Queue the test message
@@ -523,10 +611,412 @@ func TestPeerListeners(t *testing.T) {
 			return
 		}
 	}
+	if !inPeer.WantsHeaders() {
+		t.Errorf("TestPeerListeners: WantsHeaders false after " +
+			"sendheaders")
+	}
+	if !inPeer.WantsCmpctBlocks() {
+		t.Errorf("TestPeerListeners: WantsCmpctBlocks false after " +
+			"sendcmpct")
+	}
+	if !inPeer.WantsAddrV2() {
+		t.Errorf("TestPeerListeners: WantsAddrV2 false after " +
+			"sendaddrv2")
+	}
+	if len(sendAddrV2) != 0 {
+		t.Errorf("TestPeerListeners: sendaddrv2 after verack was " +
+			"not ignored")
+	}
 	inPeer.Disconnect()
 	outPeer.Disconnect()
 }
 
+// headerRecorder records the headers of all messages written through it.
+type headerRecorder struct {
+	io.Writer
+	mtx     sync.Mutex
+	headers [][]byte
+}
+
+// Write records the data when it is a message header and passes it on to the
+// underlying writer.
+func (r *headerRecorder) Write(b []byte) (int, error) {
+	if len(b) == wire.MessageHeaderSize {
+		r.mtx.Lock()
+		r.headers = append(r.headers, append([]byte(nil), b...))
+		r.mtx.Unlock()
+	}
+	return r.Writer.Write(b)
+}
+
+// TestPeerChecksumSkip tests that message checksums are only skipped on trusted
+// connections once both peers have agreed to it.
+func TestPeerChecksumSkip(t *testing.T) {
+	noChecksum := make(chan struct{}, 1)
+	txs := make(chan struct{}, 1)
+	peerCfg := &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnTx: func(p *peer.Peer, msg *wire.MsgTx) {
+				txs <- struct{}{}
+			},
+		},
+		UserAgentName:     "peer",
+		UserAgentVersion:  "1.0",
+		ChainParams:       &chaincfg.MainNetParams,
+		AllowChecksumSkip: true,
+		TrustedConn:       true,
+	}
+	inConn, outConn := pipe(
+		&conn{raddr: "127.0.0.1:18555"},
+		&conn{raddr: "127.0.0.1:18556"},
+	)
+	recorder := &headerRecorder{Writer: outConn.Writer}
+	outConn.Writer = recorder
+
+	inPeer := peer.NewInboundPeer(peerCfg)
+	inPeer.AssociateConnection(inConn)
+
+	peerCfg.Listeners = peer.MessageListeners{
+		OnNoChecksum: func(p *peer.Peer, msg *wire.MsgNoChecksum) {
+			noChecksum <- struct{}{}
+		},
+	}
+	outPeer, err := peer.NewOutboundPeer(peerCfg, "127.0.0.1:18555")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err %v", err)
+	}
+	outPeer.AssociateConnection(outConn)
+	defer inPeer.Disconnect()
+	defer outPeer.Disconnect()
+
+	select {
+	case <-noChecksum:
+	case <-time.After(time.Second * 1):
+		t.Fatalf("TestPeerChecksumSkip: nochecksum timeout")
+	}
+
+	// Messages sent after the nochecksum message was received must not
+	// include a checksum and must still be accepted by the remote peer.
+	outPeer.QueueMessage(wire.NewMsgTx(wire.TxVersion), nil)
+	select {
+	case <-txs:
+	case <-time.After(time.Second * 1):
+		t.Fatalf("TestPeerChecksumSkip: tx timeout")
+	}
+
+	recorder.mtx.Lock()
+	defer recorder.mtx.Unlock()
+	txHeader := recorder.headers[len(recorder.headers)-1]
+	if !bytes.Equal(txHeader[4:6], []byte(wire.CmdTx)) {
+		t.Fatalf("TestPeerChecksumSkip: unexpected last message %q",
+			txHeader[4:16])
+	}
+	if !bytes.Equal(txHeader[20:24], []byte{0, 0, 0, 0}) {
+		t.Fatalf("TestPeerChecksumSkip: checksum not skipped - got %x",
+			txHeader[20:24])
+	}
+}
+
+// TestPeerChecksumSkipOldVersion tests that the nochecksum message is not sent
+// to local peers which negotiated a protocol version before it was added, and
+// that checksums are still included in the messages sent to them.
+func TestPeerChecksumSkipOldVersion(t *testing.T) {
+	noChecksum := make(chan struct{}, 1)
+	txs := make(chan struct{}, 1)
+	peerCfg := &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnTx: func(p *peer.Peer, msg *wire.MsgTx) {
+				txs <- struct{}{}
+			},
+			OnNoChecksum: func(p *peer.Peer, msg *wire.MsgNoChecksum) {
+				noChecksum <- struct{}{}
+			},
+		},
+		UserAgentName:     "peer",
+		UserAgentVersion:  "1.0",
+		ChainParams:       &chaincfg.MainNetParams,
+		ProtocolVersion:   wire.NoChecksumVersion - 1,
+		AllowChecksumSkip: true,
+		TrustedConn:       true,
+	}
+	inConn, outConn := pipe(
+		&conn{raddr: "127.0.0.1:18555"},
+		&conn{raddr: "127.0.0.1:18556"},
+	)
+	recorder := &headerRecorder{Writer: outConn.Writer}
+	outConn.Writer = recorder
+
+	inPeer := peer.NewInboundPeer(peerCfg)
+	inPeer.AssociateConnection(inConn)
+
+	outCfg := *peerCfg
+	outCfg.ProtocolVersion = 0
+	outPeer, err := peer.NewOutboundPeer(&outCfg, "127.0.0.1:18555")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err %v", err)
+	}
+	outPeer.AssociateConnection(outConn)
+	defer inPeer.Disconnect()
+	defer outPeer.Disconnect()
+
+	// The tx is received after the handshake completed, so any nochecksum
+	// message would have been received before it.
+	outPeer.QueueMessage(wire.NewMsgTx(wire.TxVersion), nil)
+	select {
+	case <-txs:
+	case <-time.After(time.Second * 1):
+		t.Fatalf("TestPeerChecksumSkipOldVersion: tx timeout")
+	}
+	if len(noChecksum) != 0 {
+		t.Fatalf("TestPeerChecksumSkipOldVersion: nochecksum sent " +
+			"for protocol version before NoChecksumVersion")
+	}
+
+	recorder.mtx.Lock()
+	defer recorder.mtx.Unlock()
+	for _, header := range recorder.headers {
+		if bytes.Equal(header[20:24], []byte{0, 0, 0, 0}) {
+			t.Fatalf("TestPeerChecksumSkipOldVersion: checksum "+
+				"skipped for message %q", header[4:16])
+		}
+	}
+}
+
+// TestPeerChecksumSkipLoopback tests that checksums are not skipped on loopback
+// connections the operator did not trust, such as those from a local Tor onion
+// service.
+func TestPeerChecksumSkipLoopback(t *testing.T) {
+	noChecksum := make(chan struct{}, 1)
+	txs := make(chan struct{}, 1)
+	peerCfg := &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnTx: func(p *peer.Peer, msg *wire.MsgTx) {
+				txs <- struct{}{}
+			},
+			OnNoChecksum: func(p *peer.Peer, msg *wire.MsgNoChecksum) {
+				noChecksum <- struct{}{}
+			},
+		},
+		UserAgentName:     "peer",
+		UserAgentVersion:  "1.0",
+		ChainParams:       &chaincfg.MainNetParams,
+		AllowChecksumSkip: true,
+	}
+	inConn, outConn := pipe(
+		&conn{raddr: "127.0.0.1:18555"},
+		&conn{raddr: "127.0.0.1:18556"},
+	)
+	recorder := &headerRecorder{Writer: outConn.Writer}
+	outConn.Writer = recorder
+
+	inPeer := peer.NewInboundPeer(peerCfg)
+	inPeer.AssociateConnection(inConn)
+
+	outPeer, err := peer.NewOutboundPeer(peerCfg, "127.0.0.1:18555")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err %v", err)
+	}
+	outPeer.AssociateConnection(outConn)
+	defer inPeer.Disconnect()
+	defer outPeer.Disconnect()
+
+	// The tx is received after the handshake completed, so any nochecksum
+	// message would have been received before it.
+	outPeer.QueueMessage(wire.NewMsgTx(wire.TxVersion), nil)
+	select {
+	case <-txs:
+	case <-time.After(time.Second * 1):
+		t.Fatalf("TestPeerChecksumSkipLoopback: tx timeout")
+	}
+	if len(noChecksum) != 0 {
+		t.Fatalf("TestPeerChecksumSkipLoopback: nochecksum sent on " +
+			"untrusted loopback connection")
+	}
+
+	recorder.mtx.Lock()
+	defer recorder.mtx.Unlock()
+	for _, header := range recorder.headers {
+		if bytes.Equal(header[20:24], []byte{0, 0, 0, 0}) {
+			t.Fatalf("TestPeerChecksumSkipLoopback: checksum "+
+				"skipped for message %q", header[4:16])
+		}
+	}
+}
+
+// TestPeerHandshakeToken tests that peers only report the handshake token as
+// verified when the remote peer proved it knows the same token.
+func TestPeerHandshakeToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		inToken      string
+		outToken     string
+		wantVerified bool
+	}{
+		{"same token", "secret", "secret", true},
+		{"different tokens", "secret", "other", false},
+		{"no remote token", "secret", "", false},
+		{"no tokens", "", "", false},
+	}
+
+	for _, test := range tests {
+		versions := make(chan struct{}, 2)
+		newPeerCfg := func(token string) *peer.Config {
+			cfg := &peer.Config{
+				Listeners: peer.MessageListeners{
+					OnVersion: func(p *peer.Peer, msg *wire.MsgVersion) {
+						versions <- struct{}{}
+					},
+				},
+				UserAgentName:    "peer",
+				UserAgentVersion: "1.0",
+				ChainParams:      &chaincfg.MainNetParams,
+			}
+			if token != "" {
+				cfg.HandshakeToken = []byte(token)
+			}
+			return cfg
+		}
+		inConn, outConn := pipe(
+			&conn{raddr: "10.0.0.1:18555"},
+			&conn{raddr: "10.0.0.2:18555"},
+		)
+		inPeer := peer.NewInboundPeer(newPeerCfg(test.inToken))
+		inPeer.AssociateConnection(inConn)
+		outPeer, err := peer.NewOutboundPeer(newPeerCfg(test.outToken),
+			"10.0.0.2:18555")
+		if err != nil {
+			t.Fatalf("%s: NewOutboundPeer: unexpected err %v",
+				test.name, err)
+		}
+		outPeer.AssociateConnection(outConn)
+
+		for i := 0; i < 2; i++ {
+			select {
+			case <-versions:
+			case <-time.After(time.Second):
+				t.Fatalf("%s: version timeout", test.name)
+			}
+		}
+		if got := inPeer.HandshakeVerified(); got != test.wantVerified {
+			t.Errorf("%s: inbound peer verified %v, want %v",
+				test.name, got, test.wantVerified)
+		}
+		if got := outPeer.HandshakeVerified(); got != test.wantVerified {
+			t.Errorf("%s: outbound peer verified %v, want %v",
+				test.name, got, test.wantVerified)
+		}
+		inPeer.Disconnect()
+		outPeer.Disconnect()
+	}
+}
+
+// messageRecorder records all data written through it.
+type messageRecorder struct {
+	io.Writer
+	mtx  sync.Mutex
+	data bytes.Buffer
+}
+
+// Write records the data and passes it on to the underlying writer.
+func (r *messageRecorder) Write(b []byte) (int, error) {
+	r.mtx.Lock()
+	r.data.Write(b)
+	r.mtx.Unlock()
+	return r.Writer.Write(b)
+}
+
+// TestPeerHandshakeProofReplay tests that the version and hsproof messages an
+// attacker observed on a connection do not prove the handshake token when they
+// are replayed on another connection.
+func TestPeerHandshakeProofReplay(t *testing.T) {
+	peer.TstAllowSelfConns()
+
+	versions := make(chan struct{}, 2)
+	peerCfg := &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnVersion: func(p *peer.Peer, msg *wire.MsgVersion) {
+				versions <- struct{}{}
+			},
+		},
+		UserAgentName:    "peer",
+		UserAgentVersion: "1.0",
+		ChainParams:      &chaincfg.MainNetParams,
+		HandshakeToken:   []byte("secret"),
+	}
+	waitForVersions := func(n int) {
+		for i := 0; i < n; i++ {
+			select {
+			case <-versions:
+			case <-time.After(time.Second):
+				t.Fatal("TestPeerHandshakeProofReplay: version " +
+					"timeout")
+			}
+		}
+	}
+
+	// Record the messages of an outbound peer which proves the token to
+	// an inbound peer.
+	inConn, outConn := pipe(
+		&conn{raddr: "10.0.0.1:18555"},
+		&conn{raddr: "10.0.0.2:18555"},
+	)
+	recorder := &messageRecorder{Writer: outConn.Writer}
+	outConn.Writer = recorder
+	inPeer := peer.NewInboundPeer(peerCfg)
+	inPeer.AssociateConnection(inConn)
+	outPeer, err := peer.NewOutboundPeer(peerCfg, "10.0.0.2:18555")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err %v", err)
+	}
+	outPeer.AssociateConnection(outConn)
+	waitForVersions(2)
+	if !inPeer.HandshakeVerified() {
+		t.Fatal("TestPeerHandshakeProofReplay: proof of the honest " +
+			"peer not verified")
+	}
+	inPeer.Disconnect()
+	outPeer.Disconnect()
+
+	pver := wire.ProtocolVersion
+	btcnet := peerCfg.ChainParams.Net
+	recorder.mtx.Lock()
+	recorded := bytes.NewReader(recorder.data.Bytes())
+	recorder.mtx.Unlock()
+	var observed []wire.Message
+	for len(observed) < 2 {
+		msg, _, err := wire.ReadMessage(recorded, pver, btcnet)
+		if err != nil {
+			t.Fatalf("ReadMessage: unexpected err %v", err)
+		}
+		switch msg.(type) {
+		case *wire.MsgVersion, *wire.MsgHandshakeProof:
+			observed = append(observed, msg)
+		}
+	}
+
+	// Replay the observed messages to another inbound peer which knows
+	// the token.
+	inConn, attackerConn := pipe(
+		&conn{raddr: "10.0.0.3:18555"},
+		&conn{raddr: "10.0.0.2:18555"},
+	)
+	inPeer = peer.NewInboundPeer(peerCfg)
+	inPeer.AssociateConnection(inConn)
+	defer inPeer.Disconnect()
+	go io.Copy(ioutil.Discard, attackerConn)
+	for _, msg := range observed {
+		err := wire.WriteMessage(attackerConn, msg, pver,
+			btcnet)
+		if err != nil {
+			t.Fatalf("WriteMessage: unexpected err %v", err)
+		}
+	}
+	waitForVersions(1)
+	if inPeer.HandshakeVerified() {
+		t.Fatal("TestPeerHandshakeProofReplay: replayed proof verified")
+	}
+}
+
 // TestOutboundPeer tests that the outbound peer works as expected.
 func TestOutboundPeer(t *testing.T) {
 
@@ -673,3 +1163,142 @@ func init() {
 	// Allow self connection when running the tests.
 	peer.TstAllowSelfConns()
 }
+
+// TestPeerRateLimit tests that the messages sent to a peer are delayed to
+// respect the send rate limit.
+func TestPeerRateLimit(t *testing.T) {
+	addrs := make(chan time.Time, 2)
+	verack := make(chan struct{}, 1)
+	peerCfg := &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnAddr: func(p *peer.Peer, msg *wire.MsgAddr) {
+				addrs <- time.Now()
+			},
+		},
+		UserAgentName:    "peer",
+		UserAgentVersion: "1.0",
+		ChainParams:      &chaincfg.MainNetParams,
+	}
+	inConn, outConn := pipe(
+		&conn{raddr: "10.0.0.1:18555"},
+		&conn{raddr: "10.0.0.2:18556"},
+	)
+	inPeer := peer.NewInboundPeer(peerCfg)
+	inPeer.AssociateConnection(inConn)
+
+	peerCfg.Listeners = peer.MessageListeners{
+		OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+			verack <- struct{}{}
+		},
+	}
+	peerCfg.MaxSendRate = 2000
+	outPeer, err := peer.NewOutboundPeer(peerCfg, "10.0.0.1:18555")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err %v", err)
+	}
+	outPeer.AssociateConnection(outConn)
+	defer inPeer.Disconnect()
+	defer outPeer.Disconnect()
+
+	select {
+	case <-verack:
+	case <-time.After(time.Second * 1):
+		t.Fatalf("TestPeerRateLimit: verack timeout")
+	}
+
+	// The first addr message of about 3000 bytes exceeds the remaining
+	// second worth of bytes, so the second message is delayed by at least
+	// half a second.
+	msg := wire.NewMsgAddr()
+	for i := 0; i < 100; i++ {
+		na := wire.NewNetAddressIPPort(net.IPv4(1, 2, 3, byte(i)), 7979,
+			wire.SFNodeNetwork)
+		msg.AddAddress(na)
+	}
+	outPeer.QueueMessage(msg, nil)
+	outPeer.QueueMessage(msg, nil)
+
+	var times [2]time.Time
+	for i := range times {
+		select {
+		case times[i] = <-addrs:
+		case <-time.After(time.Second * 5):
+			t.Fatalf("TestPeerRateLimit: addr %d timeout", i)
+		}
+	}
+	if delay := times[1].Sub(times[0]); delay < 500*time.Millisecond {
+		t.Fatalf("TestPeerRateLimit: second addr delayed by %v, want "+
+			"at least 500ms", delay)
+	}
+}
+
+// TestPeerRateLimitPong tests that pongs are sent to a peer without waiting
+// for the send rate limit.
+func TestPeerRateLimitPong(t *testing.T) {
+	events := make(chan string, 3)
+	verack := make(chan struct{}, 1)
+	peerCfg := &peer.Config{
+		Listeners: peer.MessageListeners{
+			OnAddr: func(p *peer.Peer, msg *wire.MsgAddr) {
+				events <- "addr"
+				p.QueueMessage(wire.NewMsgPing(42), nil)
+			},
+			OnPong: func(p *peer.Peer, msg *wire.MsgPong) {
+				events <- "pong"
+			},
+		},
+		UserAgentName:    "peer",
+		UserAgentVersion: "1.0",
+		ChainParams:      &chaincfg.MainNetParams,
+	}
+	inConn, outConn := pipe(
+		&conn{raddr: "10.0.0.1:18555"},
+		&conn{raddr: "10.0.0.2:18556"},
+	)
+	inPeer := peer.NewInboundPeer(peerCfg)
+	inPeer.AssociateConnection(inConn)
+
+	peerCfg.Listeners = peer.MessageListeners{
+		OnVerAck: func(p *peer.Peer, msg *wire.MsgVerAck) {
+			verack <- struct{}{}
+		},
+	}
+	peerCfg.MaxSendRate = 2000
+	outPeer, err := peer.NewOutboundPeer(peerCfg, "10.0.0.1:18555")
+	if err != nil {
+		t.Fatalf("NewOutboundPeer: unexpected err %v", err)
+	}
+	outPeer.AssociateConnection(outConn)
+	defer inPeer.Disconnect()
+	defer outPeer.Disconnect()
+
+	select {
+	case <-verack:
+	case <-time.After(time.Second * 1):
+		t.Fatalf("TestPeerRateLimitPong: verack timeout")
+	}
+
+	// The second addr message is delayed by the send rate limit, while the
+	// pong to the ping sent on receipt of the first one is not.
+	msg := wire.NewMsgAddr()
+	for i := 0; i < 100; i++ {
+		na := wire.NewNetAddressIPPort(net.IPv4(1, 2, 3, byte(i)), 7979,
+			wire.SFNodeNetwork)
+		msg.AddAddress(na)
+	}
+	outPeer.QueueMessage(msg, nil)
+	outPeer.QueueMessage(msg, nil)
+
+	want := []string{"addr", "pong", "addr"}
+	for i, wantEvent := range want {
+		select {
+		case event := <-events:
+			if event != wantEvent {
+				t.Fatalf("TestPeerRateLimitPong: event %d is %s, "+
+					"want %s", i, event, wantEvent)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatalf("TestPeerRateLimitPong: event %d timeout", i)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the rate at which data is sent or received with a token
+// bucket which holds up to one second worth of bytes.  Each message is charged
+// after it is transferred, so messages larger than the bucket are allowed and
+// the transfer of the next message waits for the bucket to refill instead.
+//
+// A single limiter may be shared by several peers to limit their combined
+// rate.  It is safe for concurrent access.
+type RateLimiter struct {
+	rate float64 // Bytes per second.
+
+	mtx    sync.Mutex
+	tokens float64 // Negative when in debt.
+	last   time.Time
+}
+
+// NewRateLimiter returns a new rate limiter which allows the passed number of
+// bytes per second on average.  A rate of zero is unlimited.
+func NewRateLimiter(bytesPerSecond uint64) *RateLimiter {
+	return &RateLimiter{
+		rate:   float64(bytesPerSecond),
+		tokens: float64(bytesPerSecond),
+		last:   time.Now(),
+	}
+}
+
+// charge removes the passed number of bytes from the bucket and returns how
+// long to wait until the bucket is no longer in debt.
+func (r *RateLimiter) charge(n int, now time.Time) time.Duration {
+	if r.rate == 0 {
+		return 0
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if elapsed := now.Sub(r.last); elapsed > 0 {
+		r.tokens += elapsed.Seconds() * r.rate
+		if r.tokens > r.rate {
+			r.tokens = r.rate
+		}
+		r.last = now
+	}
+	r.tokens -= float64(n)
+	if r.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-r.tokens / r.rate * float64(time.Second))
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package peer
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRateLimiter ensures the rate limiter allows one second worth of bytes
+// right away and delays transfers beyond the rate until the bucket refills.
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	start := time.Now()
+	tests := []struct {
+		name    string
+		bytes   int
+		elapsed time.Duration
+		want    time.Duration
+	}{
+		{"within burst", 600, 0, 0},
+		{"exhausts burst", 400, 0, 0},
+		{"in debt", 500, 0, 500 * time.Millisecond},
+		{"partly refilled", 100, 200 * time.Millisecond, 400 * time.Millisecond},
+		{"repaid", 0, 600 * time.Millisecond, 0},
+		{"larger than burst", 3000, 1600 * time.Millisecond, 2 * time.Second},
+		{"refill capped", 1000, 10 * time.Second, 0},
+		{"after cap", 1, 10 * time.Second, time.Millisecond},
+	}
+
+	// The elapsed durations are relative to the start of the test.
+	r := NewRateLimiter(1000)
+	r.last = start
+	for _, test := range tests {
+		got := r.charge(test.bytes, start.Add(test.elapsed))
+		if got < test.want-time.Microsecond ||
+			got > test.want+time.Microsecond {
+
+			t.Errorf("%s: got delay %v, want %v", test.name, got,
+				test.want)
+		}
+	}
+
+	// A rate of zero is unlimited.
+	r = NewRateLimiter(0)
+	if got := r.charge(1<<30, start); got != 0 {
+		t.Errorf("unlimited: got delay %v, want 0", got)
+	}
+}
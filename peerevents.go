@@ -0,0 +1,189 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// peerEventType represents the type of a peer lifecycle event.
+type peerEventType int
+
+// Constants for the type of a peer lifecycle event.
+const (
+	// peerEventConnected indicates a connection to the peer was
+	// established, but the version handshake has not completed yet.
+	peerEventConnected peerEventType = iota
+
+	// peerEventHandshakeComplete indicates the version handshake with the
+	// peer completed and the peer was added to the server.
+	peerEventHandshakeComplete
+
+	// peerEventMisbehavior indicates the ban score of the peer was
+	// increased due to misbehavior.
+	peerEventMisbehavior
+
+	// peerEventDisconnected indicates the peer disconnected.
+	peerEventDisconnected
+
+	// numPeerEventTypes is the number of peer event types.  It must be the
+	// last constant.
+	numPeerEventTypes
+)
+
+// peerEventTypeStrings is a map of peer event types back to their constant
+// names for pretty printing.
+var peerEventTypeStrings = map[peerEventType]string{
+	peerEventConnected:         "peerEventConnected",
+	peerEventHandshakeComplete: "peerEventHandshakeComplete",
+	peerEventMisbehavior:       "peerEventMisbehavior",
+	peerEventDisconnected:      "peerEventDisconnected",
+}
+
+// String returns the peerEventType in human-readable form.
+func (t peerEventType) String() string {
+	if s, ok := peerEventTypeStrings[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown Peer Event Type (%d)", int(t))
+}
+
+// peerEventNames is a map of peer event types to the names used for them in
+// the peerevent websocket notification.
+var peerEventNames = map[peerEventType]string{
+	peerEventConnected:         "connected",
+	peerEventHandshakeComplete: "handshakecomplete",
+	peerEventMisbehavior:       "misbehavior",
+	peerEventDisconnected:      "disconnected",
+}
+
+// peerEvent describes a peer lifecycle event delivered to the callbacks
+// registered with the server.  The Reason field is set for misbehavior and
+// disconnect events, and BanScore holds the ban score of the peer at the time
+// of the event.
+type peerEvent struct {
+	Type     peerEventType
+	Time     time.Time
+	ID       int32
+	Addr     string
+	Inbound  bool
+	BanScore uint32
+	Reason   string
+}
+
+// peerEventCallback is used for a caller to provide a callback for peer
+// lifecycle events.  Callbacks are invoked synchronously from the goroutine
+// generating the event, so they must not block.
+type peerEventCallback func(*peerEvent)
+
+// peerEventHooks houses the registered peer event callbacks.  The server
+// registers the counters reported by the /health endpoint and the RPC server
+// forwards the events to the websocket clients which requested them with
+// notifypeerevents.  It is safe for concurrent access.
+type peerEventHooks struct {
+	mtx       sync.RWMutex
+	nextID    int
+	callbacks map[int]peerEventCallback
+}
+
+// newPeerEventHooks returns a new empty set of peer event hooks.
+func newPeerEventHooks() *peerEventHooks {
+	return &peerEventHooks{
+		callbacks: make(map[int]peerEventCallback),
+	}
+}
+
+// register adds the passed callback and returns an identifier which may be
+// used to remove it again.
+func (h *peerEventHooks) register(callback peerEventCallback) int {
+	h.mtx.Lock()
+	id := h.nextID
+	h.nextID++
+	h.callbacks[id] = callback
+	h.mtx.Unlock()
+	return id
+}
+
+// unregister removes the callback associated with the passed identifier.
+func (h *peerEventHooks) unregister(id int) {
+	h.mtx.Lock()
+	delete(h.callbacks, id)
+	h.mtx.Unlock()
+}
+
+// notify generates an event of the passed type for the peer and delivers it to
+// all registered callbacks.
+func (h *peerEventHooks) notify(typ peerEventType, sp *serverPeer, reason string) {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+
+	// Avoid gathering the peer details when nobody is listening.
+	if len(h.callbacks) == 0 {
+		return
+	}
+
+	event := peerEvent{
+		Type:     typ,
+		Time:     time.Now(),
+		ID:       sp.ID(),
+		Addr:     sp.Addr(),
+		Inbound:  sp.Inbound(),
+		BanScore: sp.banScore.Int(),
+		Reason:   reason,
+	}
+	for _, callback := range h.callbacks {
+		callback(&event)
+	}
+}
+
+// SubscribePeerEvents registers the passed callback to be invoked for all
+// peer lifecycle events.  It returns an identifier which may be passed to
+// UnsubscribePeerEvents to stop receiving events.
+//
+// This function is safe for concurrent access.
+func (s *server) SubscribePeerEvents(callback peerEventCallback) int {
+	return s.peerEvents.register(callback)
+}
+
+// UnsubscribePeerEvents removes the peer event callback associated with the
+// passed identifier returned by SubscribePeerEvents.
+//
+// This function is safe for concurrent access.
+func (s *server) UnsubscribePeerEvents(id int) {
+	s.peerEvents.unregister(id)
+}
+
+// peerEventCounts houses the number of peer events of each type generated since
+// the server started as reported by the /health endpoint.
+type peerEventCounts struct {
+	Connected         uint64 `json:"connected"`
+	HandshakeComplete uint64 `json:"handshakecomplete"`
+	Misbehavior       uint64 `json:"misbehavior"`
+	Disconnected      uint64 `json:"disconnected"`
+}
+
+// peerEventCounters counts the peer events delivered to its count callback.  It
+// is safe for concurrent access.
+type peerEventCounters struct {
+	counts [numPeerEventTypes]uint64 // Atomic
+}
+
+// count is a peerEventCallback which counts the passed event.
+func (c *peerEventCounters) count(event *peerEvent) {
+	atomic.AddUint64(&c.counts[event.Type], 1)
+}
+
+// snapshot returns the current number of events of each type.
+func (c *peerEventCounters) snapshot() peerEventCounts {
+	return peerEventCounts{
+		Connected:         atomic.LoadUint64(&c.counts[peerEventConnected]),
+		HandshakeComplete: atomic.LoadUint64(&c.counts[peerEventHandshakeComplete]),
+		Misbehavior:       atomic.LoadUint64(&c.counts[peerEventMisbehavior]),
+		Disconnected:      atomic.LoadUint64(&c.counts[peerEventDisconnected]),
+	}
+}
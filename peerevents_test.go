@@ -0,0 +1,93 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/peer"
+)
+
+// TestPeerEventHooks ensures peer event callbacks receive events until they
+// are unregistered.
+func TestPeerEventHooks(t *testing.T) {
+	hooks := newPeerEventHooks()
+	sp := &serverPeer{Peer: peer.NewInboundPeer(&peer.Config{})}
+
+	var events []*peerEvent
+	id := hooks.register(func(e *peerEvent) {
+		events = append(events, e)
+	})
+
+	hooks.notify(peerEventMisbehavior, sp, "test")
+	if len(events) != 1 {
+		t.Fatalf("notify: got %d events, want 1", len(events))
+	}
+	if events[0].Type != peerEventMisbehavior || events[0].Reason != "test" {
+		t.Fatalf("notify: unexpected event %+v", events[0])
+	}
+	if events[0].Type.String() != "peerEventMisbehavior" {
+		t.Fatalf("String: unexpected event type %v", events[0].Type)
+	}
+
+	hooks.unregister(id)
+	hooks.notify(peerEventDisconnected, sp, sp.disconnectReason())
+	if len(events) != 1 {
+		t.Fatalf("notify: got %d events after unregister, want 1",
+			len(events))
+	}
+}
+
+// TestPeerEventCounters ensures the counters reported by the health endpoint
+// count the events delivered to the hooks by type.
+func TestPeerEventCounters(t *testing.T) {
+	hooks := newPeerEventHooks()
+	counters := new(peerEventCounters)
+	hooks.register(counters.count)
+	sp := &serverPeer{Peer: peer.NewInboundPeer(&peer.Config{})}
+
+	hooks.notify(peerEventConnected, sp, "")
+	hooks.notify(peerEventHandshakeComplete, sp, "")
+	hooks.notify(peerEventMisbehavior, sp, "test")
+	hooks.notify(peerEventMisbehavior, sp, "test")
+	hooks.notify(peerEventDisconnected, sp, sp.disconnectReason())
+
+	want := peerEventCounts{
+		Connected:         1,
+		HandshakeComplete: 1,
+		Misbehavior:       2,
+		Disconnected:      1,
+	}
+	if got := counters.snapshot(); got != want {
+		t.Fatalf("snapshot: got %+v, want %+v", got, want)
+	}
+}
+
+// TestPeerEventNtfn ensures peer events are converted to peerevent
+// notifications with the names of their types.
+func TestPeerEventNtfn(t *testing.T) {
+	for typ := peerEventConnected; typ < numPeerEventTypes; typ++ {
+		if _, ok := peerEventNames[typ]; !ok {
+			t.Errorf("missing notification name for %v", typ)
+		}
+	}
+
+	event := &peerEvent{
+		Type:     peerEventMisbehavior,
+		Time:     time.Unix(12345678, 0),
+		ID:       3,
+		Addr:     "127.0.0.1:7979",
+		Inbound:  true,
+		BanScore: 20,
+		Reason:   "test",
+	}
+	want := btcjson.NewPeerEventNtfn("misbehavior", 12345678, 3,
+		"127.0.0.1:7979", true, 20, "test")
+	if got := peerEventNtfn(event); *got != *want {
+		t.Fatalf("peerEventNtfn: got %+v, want %+v", got, want)
+	}
+}
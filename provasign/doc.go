@@ -0,0 +1,69 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package provasign implements signing of Prova transactions.
+
+# Overview
+
+This package houses the signature hash algorithm of the Prova chain, the
+creation of signatures and signature scripts for the inputs of Prova
+transactions, and partially signed Prova transactions (PSPTs).  It only depends
+on the btcec, chainhash, and wire packages, so wallet backends and hardware
+signers are able to import a small library which is easy to audit instead of
+the whole node.  The script engine of the txscript package calculates
+signature hashes with this package, so signatures created here are exactly the
+ones the chain validates.
+
+# Signature Hashes
+
+Signatures commit to the transaction with the BIP0143 digest, except that the
+digest does not cover the public key script of the spent output.  It covers the
+amount of the spent output, so a signer which is fed a wrong amount produces an
+invalid signature.  NewSigHashes calculates the parts of the digest which are
+shared by all inputs of a transaction, and CalcSignatureHash the digest of a
+single input.  The hash type is always SigHashAll.
+
+# Signature Scripts
+
+The signature script of an input spending a Prova output consists of pairs of a
+compressed public key and a signature.  RawTxInSignature signs an input and
+AppendSignature adds the pair to a signature script, so the signers of an
+output are able to sign one after another.
+
+# Partially Signed Prova Transactions
+
+A transaction which is signed on several hosts travels between them as a PSPT.
+A PSPT houses the unsigned or partially signed transaction along with the
+amount and public key script of every output it spends, which is all a signer
+needs to sign.  Each input may also carry the ASP keys the key IDs of its
+public key script are bound to, so signers such as HSMs are able to check the
+keys of the output without access to the chain, and the BIP0032 derivations of
+the keys able to sign it, so signers holding a master key find the key to sign
+with.  Each signer adds its signatures with Packet.Sign, and once every input
+carries the required signatures, Packet.Extract returns the final transaction.
+Signers are also able to sign copies of the same PSPT in parallel, in which
+case Packet.Combine merges the signatures they collected, and
+Packet.Signatures lists the signatures of an input.
+
+The serialized form of a PSPT starts with the magic bytes "pspt" followed by
+0xff and a version byte, then the transaction in the wire format, and then the
+following fields of every input in order:
+
+  - the amount as a little-endian int64
+  - the public key script as variable length bytes
+  - the number of key IDs as a variable length integer, followed by each key ID
+    in increasing order as a little-endian uint32 and its compressed ASP key
+  - the number of derivations as a variable length integer, followed by each
+    compressed public key, the fingerprint of its master key as a big-endian
+    uint32, the depth as a variable length integer, and the indices of the
+    path as little-endian uint32s
+
+Version 0 PSPTs end each input after the public key script and are still read.
+Packet.Encode returns the serialized form base64 encoded.
+
+Verifying a signed PSPT requires executing the scripts, which is left to the
+txbuilder package.
+*/
+package provasign
@@ -0,0 +1,569 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package provasign
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// psptVersion is the version of the serialized form of PSPTs written by
+	// this package.  Version 0 PSPTs, which do not carry the key IDs and
+	// derivations of the inputs, are still read.
+	psptVersion = 1
+
+	// maxPkScriptSize is the maximum size of the public key script of an
+	// input of a PSPT.  It matches the maximum script size enforced by the
+	// script engine.
+	maxPkScriptSize = 10000
+
+	// maxInputKeys is the maximum number of key IDs and of derivations of
+	// an input of a PSPT.  It matches the maximum number of public keys of
+	// a multisig script.
+	maxInputKeys = 20
+
+	// maxDerivationDepth is the maximum number of levels of a derivation
+	// path.  BIP0032 limits the depth of extended keys to one byte.
+	maxDerivationDepth = 255
+
+	// HardenedKeyStart is the index of the first hardened child key of a
+	// derivation path, as defined by BIP0032.
+	HardenedKeyStart = 0x80000000
+)
+
+var (
+	// psptMagic are the bytes the serialized form of a PSPT starts with.
+	psptMagic = [5]byte{'p', 's', 'p', 't', 0xff}
+
+	// ErrInvalidPSPT is returned when decoding data which is not a PSPT.
+	ErrInvalidPSPT = errors.New("invalid PSPT magic")
+
+	// ErrIncomplete is returned by Packet.Extract when any input of the
+	// transaction lacks required signatures.
+	ErrIncomplete = errors.New("PSPT is not fully signed")
+)
+
+// KeyDerivation describes how a key able to sign an input of a PSPT derives
+// from a BIP0032 master key, so a signer which only holds the master key, such
+// as an HSM, is able to find the key to sign with.
+type KeyDerivation struct {
+	// PubKey is the derived public key.
+	PubKey *btcec.PublicKey
+
+	// Fingerprint is the fingerprint of the master key, which is the first
+	// four bytes of the hash160 of its public key in big-endian order.
+	Fingerprint uint32
+
+	// Path is the derivation path from the master key.  Indices of
+	// hardened children start at HardenedKeyStart.
+	Path []uint32
+}
+
+// PathString returns the derivation path in the m/44'/0'/0 notation.
+func (d *KeyDerivation) PathString() string {
+	var buf bytes.Buffer
+	buf.WriteByte('m')
+	for _, index := range d.Path {
+		if index >= HardenedKeyStart {
+			fmt.Fprintf(&buf, "/%d'", index-HardenedKeyStart)
+			continue
+		}
+		fmt.Fprintf(&buf, "/%d", index)
+	}
+	return buf.String()
+}
+
+// PacketInput houses the output spent by an input of the transaction of a PSPT
+// along with the metadata signers need to check what they sign.
+type PacketInput struct {
+	Amount   int64
+	PkScript []byte
+
+	// KeyIDs maps the key IDs of the public key script to the ASP keys
+	// they are bound to, so signers are able to check the keys of the
+	// spent output without access to the chain.
+	KeyIDs btcec.KeyIdMap
+
+	// Derivations describe the keys which are able to sign the input.
+	Derivations []KeyDerivation
+}
+
+// PartialSig is a signature collected by an input of a PSPT along with the
+// public key of the signer.
+type PartialSig struct {
+	PubKey    *btcec.PublicKey
+	Signature []byte
+}
+
+// Packet is a partially signed Prova transaction (PSPT).  It houses a
+// transaction along with the outputs spent by its inputs, which is all that is
+// needed to sign it without access to the chain.
+type Packet struct {
+	Tx     *wire.MsgTx
+	Inputs []PacketInput
+}
+
+// NewPacket returns a new PSPT for the passed transaction, which spends the
+// outputs described by the passed inputs in input order.
+func NewPacket(tx *wire.MsgTx, inputs []PacketInput) (*Packet, error) {
+	if len(inputs) != len(tx.TxIn) {
+		return nil, fmt.Errorf("transaction has %d inputs but %d "+
+			"spent outputs were passed", len(tx.TxIn), len(inputs))
+	}
+	return &Packet{
+		Tx:     tx.Copy(),
+		Inputs: append([]PacketInput(nil), inputs...),
+	}, nil
+}
+
+// Serialize writes the serialized form of the PSPT to the passed writer.
+func (p *Packet) Serialize(w io.Writer) error {
+	if len(p.Inputs) != len(p.Tx.TxIn) {
+		return fmt.Errorf("transaction has %d inputs but the PSPT has "+
+			"%d", len(p.Tx.TxIn), len(p.Inputs))
+	}
+	if _, err := w.Write(psptMagic[:]); err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte{psptVersion}); err != nil {
+		return err
+	}
+	if err := p.Tx.Serialize(w); err != nil {
+		return err
+	}
+	var amount [8]byte
+	for _, input := range p.Inputs {
+		binary.LittleEndian.PutUint64(amount[:], uint64(input.Amount))
+		if _, err := w.Write(amount[:]); err != nil {
+			return err
+		}
+		if err := wire.WriteVarBytes(w, 0, input.PkScript); err != nil {
+			return err
+		}
+		if err := writeInputKeys(w, &input); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeInputKeys writes the key IDs, in increasing order, and the derivations
+// of the passed input.
+func writeInputKeys(w io.Writer, input *PacketInput) error {
+	if len(input.KeyIDs) > maxInputKeys ||
+		len(input.Derivations) > maxInputKeys {
+
+		return fmt.Errorf("input has more than %d keys", maxInputKeys)
+	}
+
+	keyIDs := make([]int, 0, len(input.KeyIDs))
+	for keyID := range input.KeyIDs {
+		keyIDs = append(keyIDs, int(keyID))
+	}
+	sort.Ints(keyIDs)
+	if err := wire.WriteVarInt(w, 0, uint64(len(keyIDs))); err != nil {
+		return err
+	}
+	var buf [4]byte
+	for _, keyID := range keyIDs {
+		binary.LittleEndian.PutUint32(buf[:], uint32(keyID))
+		if _, err := w.Write(buf[:]); err != nil {
+			return err
+		}
+		pubKey := input.KeyIDs[btcec.KeyID(keyID)]
+		if _, err := w.Write(pubKey.SerializeCompressed()); err != nil {
+			return err
+		}
+	}
+
+	err := wire.WriteVarInt(w, 0, uint64(len(input.Derivations)))
+	if err != nil {
+		return err
+	}
+	for _, derivation := range input.Derivations {
+		if len(derivation.Path) > maxDerivationDepth {
+			return fmt.Errorf("derivation path is deeper than %d",
+				maxDerivationDepth)
+		}
+		_, err := w.Write(derivation.PubKey.SerializeCompressed())
+		if err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint32(buf[:], derivation.Fingerprint)
+		if _, err := w.Write(buf[:]); err != nil {
+			return err
+		}
+		err = wire.WriteVarInt(w, 0, uint64(len(derivation.Path)))
+		if err != nil {
+			return err
+		}
+		for _, index := range derivation.Path {
+			binary.LittleEndian.PutUint32(buf[:], index)
+			if _, err := w.Write(buf[:]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readPubKey reads a compressed public key.
+func readPubKey(r io.Reader) (*btcec.PublicKey, error) {
+	var serialized [btcec.PubKeyBytesLenCompressed]byte
+	if _, err := io.ReadFull(r, serialized[:]); err != nil {
+		return nil, err
+	}
+	return btcec.ParsePubKey(serialized[:], btcec.S256())
+}
+
+// readInputKeys reads the key IDs and the derivations written by
+// writeInputKeys into the passed input.
+func readInputKeys(r io.Reader, input *PacketInput) error {
+	count, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > maxInputKeys {
+		return fmt.Errorf("input has %d key IDs, more than %d", count,
+			maxInputKeys)
+	}
+	var buf [4]byte
+	for i := uint64(0); i < count; i++ {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return err
+		}
+		keyID := btcec.KeyID(binary.LittleEndian.Uint32(buf[:]))
+		pubKey, err := readPubKey(r)
+		if err != nil {
+			return err
+		}
+		if input.KeyIDs == nil {
+			input.KeyIDs = make(btcec.KeyIdMap)
+		}
+		if _, ok := input.KeyIDs[keyID]; ok {
+			return fmt.Errorf("duplicate key ID %v", keyID)
+		}
+		input.KeyIDs[keyID] = pubKey
+	}
+
+	count, err = wire.ReadVarInt(r, 0)
+	if err != nil {
+		return err
+	}
+	if count > maxInputKeys {
+		return fmt.Errorf("input has %d derivations, more than %d",
+			count, maxInputKeys)
+	}
+	for i := uint64(0); i < count; i++ {
+		var derivation KeyDerivation
+		derivation.PubKey, err = readPubKey(r)
+		if err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return err
+		}
+		derivation.Fingerprint = binary.BigEndian.Uint32(buf[:])
+		depth, err := wire.ReadVarInt(r, 0)
+		if err != nil {
+			return err
+		}
+		if depth > maxDerivationDepth {
+			return fmt.Errorf("derivation path is deeper than %d",
+				maxDerivationDepth)
+		}
+		for j := uint64(0); j < depth; j++ {
+			if _, err := io.ReadFull(r, buf[:]); err != nil {
+				return err
+			}
+			derivation.Path = append(derivation.Path,
+				binary.LittleEndian.Uint32(buf[:]))
+		}
+		input.Derivations = append(input.Derivations, derivation)
+	}
+	return nil
+}
+
+// Deserialize reads the serialized form of a PSPT from the passed reader into
+// the PSPT.
+func (p *Packet) Deserialize(r io.Reader) error {
+	var magic [len(psptMagic)]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return err
+	}
+	if magic != psptMagic {
+		return ErrInvalidPSPT
+	}
+	var version [1]byte
+	if _, err := io.ReadFull(r, version[:]); err != nil {
+		return err
+	}
+	if version[0] > psptVersion {
+		return fmt.Errorf("unsupported PSPT version %d", version[0])
+	}
+
+	var tx wire.MsgTx
+	if err := tx.Deserialize(r); err != nil {
+		return err
+	}
+	inputs := make([]PacketInput, 0, len(tx.TxIn))
+	var amount [8]byte
+	for i := 0; i < len(tx.TxIn); i++ {
+		if _, err := io.ReadFull(r, amount[:]); err != nil {
+			return err
+		}
+		pkScript, err := wire.ReadVarBytes(r, 0, maxPkScriptSize,
+			"pkScript")
+		if err != nil {
+			return err
+		}
+		input := PacketInput{
+			Amount:   int64(binary.LittleEndian.Uint64(amount[:])),
+			PkScript: pkScript,
+		}
+		if version[0] > 0 {
+			if err := readInputKeys(r, &input); err != nil {
+				return err
+			}
+		}
+		inputs = append(inputs, input)
+	}
+
+	p.Tx = &tx
+	p.Inputs = inputs
+	return nil
+}
+
+// Encode returns the base64 encoding of the serialized form of the PSPT.
+func (p *Packet) Encode() (string, error) {
+	var buf bytes.Buffer
+	if err := p.Serialize(&buf); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecodePacket returns the PSPT encoded by Packet.Encode.
+func DecodePacket(encoded string) (*Packet, error) {
+	serialized, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	var p Packet
+	r := bytes.NewReader(serialized)
+	if err := p.Deserialize(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d trailing bytes after PSPT", r.Len())
+	}
+	return &p, nil
+}
+
+// Sign adds a signature of the passed key to every input of the transaction,
+// appending it to the signatures already present.  Inputs which already carry
+// the required number of signatures or a signature of the key are left
+// untouched.
+//
+// The key is not checked against the public key scripts, so it is up to the
+// caller to only sign with the keys of the spent outputs.
+func (p *Packet) Sign(key *btcec.PrivateKey) error {
+	sigHashes := NewSigHashes(p.Tx)
+	for i := range p.Inputs {
+		if err := p.signInput(i, sigHashes, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SignInput adds a signature of the passed key to the input at the passed index
+// the same way Sign does to every input.
+func (p *Packet) SignInput(idx int, key *btcec.PrivateKey) error {
+	if idx < 0 || idx >= len(p.Tx.TxIn) {
+		return fmt.Errorf("input %d does not exist", idx)
+	}
+	return p.signInput(idx, NewSigHashes(p.Tx), key)
+}
+
+// signInput signs the input at the passed index with the passed signature
+// hashes of the transaction.
+func (p *Packet) signInput(idx int, sigHashes *SigHashes, key *btcec.PrivateKey) error {
+	input := p.Inputs[idx]
+	txIn := p.Tx.TxIn[idx]
+	if signedInput(input.PkScript, txIn.SignatureScript) {
+		return nil
+	}
+	pubKey := key.PubKey()
+	signed, err := HasSignature(txIn.SignatureScript, pubKey)
+	if err != nil {
+		return fmt.Errorf("input %d: %v", idx, err)
+	}
+	if signed {
+		return nil
+	}
+	sig, err := RawTxInSignature(p.Tx, idx, sigHashes, input.Amount,
+		SigHashAll, key)
+	if err != nil {
+		return fmt.Errorf("unable to sign input %d: %v", idx, err)
+	}
+	txIn.SignatureScript = AppendSignature(txIn.SignatureScript, pubKey, sig)
+	return nil
+}
+
+// InputComplete returns whether or not the input at the passed index carries
+// the required number of signatures.
+func (p *Packet) InputComplete(idx int) bool {
+	if idx < 0 || idx >= len(p.Tx.TxIn) {
+		return false
+	}
+	return signedInput(p.Inputs[idx].PkScript,
+		p.Tx.TxIn[idx].SignatureScript)
+}
+
+// Signatures returns the signatures collected by the input at the passed index
+// in the order they were added.
+func (p *Packet) Signatures(idx int) ([]PartialSig, error) {
+	if idx < 0 || idx >= len(p.Tx.TxIn) {
+		return nil, fmt.Errorf("input %d does not exist", idx)
+	}
+	pushes, err := PushedData(p.Tx.TxIn[idx].SignatureScript)
+	if err != nil {
+		return nil, err
+	}
+	if len(pushes)%2 != 0 {
+		return nil, ErrMalformedPush
+	}
+	sigs := make([]PartialSig, 0, len(pushes)/2)
+	for i := 0; i < len(pushes); i += 2 {
+		pubKey, err := btcec.ParsePubKey(pushes[i], btcec.S256())
+		if err != nil {
+			return nil, err
+		}
+		sigs = append(sigs, PartialSig{
+			PubKey:    pubKey,
+			Signature: pushes[i+1],
+		})
+	}
+	return sigs, nil
+}
+
+// Combine adds the signatures, key IDs, and derivations of the passed PSPT of
+// the same transaction, which were collected by other signers, to the PSPT.
+// Signatures are added to each input until it carries the required number of
+// signatures, skipping those of keys which already signed it.
+func (p *Packet) Combine(other *Packet) error {
+	if other.Tx.TxHash() != p.Tx.TxHash() {
+		return errors.New("PSPTs are not of the same transaction")
+	}
+	if len(other.Inputs) != len(p.Inputs) {
+		return fmt.Errorf("PSPTs have %d and %d inputs", len(p.Inputs),
+			len(other.Inputs))
+	}
+
+	for i := range p.Inputs {
+		input := &p.Inputs[i]
+		otherInput := &other.Inputs[i]
+		if input.Amount != otherInput.Amount ||
+			!bytes.Equal(input.PkScript, otherInput.PkScript) {
+
+			return fmt.Errorf("input %d spends different outputs", i)
+		}
+
+		sigs, err := other.Signatures(i)
+		if err != nil {
+			return fmt.Errorf("input %d: %v", i, err)
+		}
+		txIn := p.Tx.TxIn[i]
+		for _, sig := range sigs {
+			if signedInput(input.PkScript, txIn.SignatureScript) {
+				break
+			}
+			signed, err := HasSignature(txIn.SignatureScript,
+				sig.PubKey)
+			if err != nil {
+				return fmt.Errorf("input %d: %v", i, err)
+			}
+			if !signed {
+				txIn.SignatureScript = AppendSignature(
+					txIn.SignatureScript, sig.PubKey,
+					sig.Signature)
+			}
+		}
+
+		for keyID, pubKey := range otherInput.KeyIDs {
+			if input.KeyIDs == nil {
+				input.KeyIDs = make(btcec.KeyIdMap)
+			}
+			if known, ok := input.KeyIDs[keyID]; ok && !known.IsEqual(pubKey) {
+				return fmt.Errorf("input %d: key ID %v is bound "+
+					"to different keys", i, keyID)
+			}
+			input.KeyIDs[keyID] = pubKey
+		}
+		for _, derivation := range otherInput.Derivations {
+			known := false
+			for _, d := range input.Derivations {
+				if d.PubKey.IsEqual(derivation.PubKey) {
+					known = true
+					break
+				}
+			}
+			if !known {
+				input.Derivations = append(input.Derivations,
+					derivation)
+			}
+		}
+	}
+	return nil
+}
+
+// signedInput returns whether or not the passed signature script carries at
+// least the number of signatures required to spend the passed public key
+// script.  Signature scripts of Prova outputs consist of pairs of public keys
+// and signatures.
+func signedInput(pkScript, sigScript []byte) bool {
+	if len(sigScript) == 0 {
+		return false
+	}
+	nRequired, err := RequiredSignatures(pkScript)
+	if err != nil {
+		return false
+	}
+	pushes, err := PushedData(sigScript)
+	if err != nil {
+		return false
+	}
+	return len(pushes) >= 2*nRequired
+}
+
+// Complete returns whether or not every input of the transaction carries the
+// required number of signatures.
+func (p *Packet) Complete() bool {
+	for i, input := range p.Inputs {
+		if !signedInput(input.PkScript, p.Tx.TxIn[i].SignatureScript) {
+			return false
+		}
+	}
+	return true
+}
+
+// Extract returns the final transaction once every input carries the required
+// number of signatures.
+func (p *Packet) Extract() (*wire.MsgTx, error) {
+	if !p.Complete() {
+		return nil, ErrIncomplete
+	}
+	return p.Tx.Copy(), nil
+}
@@ -0,0 +1,307 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package provasign
+
+import (
+	"bytes"
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// testPkScript is the public key script of a Prova output which requires two
+// signatures: OP_2 <pubkeyhash> OP_1 OP_2 OP_3 OP_CHECKSAFEMULTISIG.
+var testPkScript = append(append([]byte{0x52, 0x14}, make([]byte, 20)...),
+	0x51, 0x52, 0x53, 0xba)
+
+// newTestPacket returns a PSPT of an unsigned transaction spending outputs of
+// the passed amounts, which are paid to testPkScript.
+func newTestPacket(t *testing.T, amounts ...int64) *Packet {
+	tx := wire.NewMsgTx(1)
+	inputs := make([]PacketInput, 0, len(amounts))
+	for i, amount := range amounts {
+		hash := chainhash.Hash{byte(i + 1)}
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&hash, uint32(i)), nil))
+		inputs = append(inputs, PacketInput{
+			Amount:   amount,
+			PkScript: testPkScript,
+		})
+	}
+	tx.AddTxOut(wire.NewTxOut(500000, testPkScript))
+	packet, err := NewPacket(tx, inputs)
+	if err != nil {
+		t.Fatalf("NewPacket: %v", err)
+	}
+	return packet
+}
+
+// TestPacketEncoding ensures PSPTs survive an encode and decode roundtrip and
+// that malformed encodings are rejected.
+func TestPacketEncoding(t *testing.T) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	packet := newTestPacket(t, 300000, 400000)
+	packet.Tx.TxIn[0].SignatureScript = []byte{0x01, 0x02}
+	packet.Inputs[0].KeyIDs = btcec.KeyIdMap{2: key.PubKey(), 3: key.PubKey()}
+	packet.Inputs[0].Derivations = []KeyDerivation{{
+		PubKey:      key.PubKey(),
+		Fingerprint: 0x01020304,
+		Path:        []uint32{HardenedKeyStart + 44, 7},
+	}}
+
+	encoded, err := packet.Encode()
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	decoded, err := DecodePacket(encoded)
+	if err != nil {
+		t.Fatalf("DecodePacket: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Inputs, packet.Inputs) {
+		t.Fatalf("DecodePacket: got inputs %+v, want %+v",
+			decoded.Inputs, packet.Inputs)
+	}
+	if path := decoded.Inputs[0].Derivations[0].PathString(); path != "m/44'/7" {
+		t.Fatalf("PathString: got %s, want m/44'/7", path)
+	}
+	if decoded.Tx.TxHash() != packet.Tx.TxHash() ||
+		!bytes.Equal(decoded.Tx.TxIn[0].SignatureScript,
+			packet.Tx.TxIn[0].SignatureScript) {
+
+		t.Fatal("DecodePacket: transaction does not match")
+	}
+
+	var buf bytes.Buffer
+	if err := packet.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	buf.WriteByte(0)
+	trailing := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{name: "not base64", encoded: "!!!"},
+		{name: "bad magic", encoded: "cHNidP8A"},
+		{name: "truncated", encoded: encoded[:len(encoded)-8]},
+		{name: "trailing bytes", encoded: trailing},
+	}
+	for _, test := range tests {
+		if _, err := DecodePacket(test.encoded); err == nil {
+			t.Errorf("%s: decoded invalid PSPT", test.name)
+		}
+	}
+
+	if _, err := NewPacket(packet.Tx, packet.Inputs[:1]); err == nil {
+		t.Error("NewPacket: accepted fewer inputs than the transaction")
+	}
+}
+
+// TestPacketDecodeVersion0 ensures PSPTs serialized before inputs carried key
+// IDs and derivations are still decoded.
+func TestPacketDecodeVersion0(t *testing.T) {
+	packet := newTestPacket(t, 300000)
+
+	var buf bytes.Buffer
+	buf.Write(psptMagic[:])
+	buf.WriteByte(0)
+	if err := packet.Tx.Serialize(&buf); err != nil {
+		t.Fatalf("Serialize: %v", err)
+	}
+	buf.Write([]byte{0xe0, 0x93, 0x04, 0, 0, 0, 0, 0})
+	if err := wire.WriteVarBytes(&buf, 0, testPkScript); err != nil {
+		t.Fatalf("WriteVarBytes: %v", err)
+	}
+
+	var decoded Packet
+	if err := decoded.Deserialize(&buf); err != nil {
+		t.Fatalf("Deserialize: %v", err)
+	}
+	if !reflect.DeepEqual(decoded.Inputs, packet.Inputs) {
+		t.Fatalf("Deserialize: got inputs %+v, want %+v",
+			decoded.Inputs, packet.Inputs)
+	}
+}
+
+// TestPacketCombine ensures the signatures and metadata collected by signers
+// of separate copies of a PSPT are combined, and PSPTs of other transactions
+// are not.
+func TestPacketCombine(t *testing.T) {
+	key1, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	key2, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	key3, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+
+	packet1 := newTestPacket(t, 300000, 400000)
+	packet2 := newTestPacket(t, 300000, 400000)
+	packet3 := newTestPacket(t, 300000, 400000)
+	packet2.Inputs[1].KeyIDs = btcec.KeyIdMap{1: key2.PubKey()}
+	packet2.Inputs[1].Derivations = []KeyDerivation{{PubKey: key2.PubKey()}}
+	for _, signer := range []struct {
+		packet *Packet
+		key    *btcec.PrivateKey
+	}{{packet1, key1}, {packet2, key2}, {packet3, key3}} {
+		if err := signer.packet.Sign(signer.key); err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+	}
+
+	// Combining the same signature twice does not add it again.
+	if err := packet1.Combine(packet1); err != nil {
+		t.Fatalf("Combine: %v", err)
+	}
+	if packet1.Complete() {
+		t.Fatal("Combine: PSPT with one signature is complete")
+	}
+
+	if err := packet1.Combine(packet2); err != nil {
+		t.Fatalf("Combine: %v", err)
+	}
+	if !packet1.Complete() {
+		t.Fatal("Combine: combined PSPT is not complete")
+	}
+	if !reflect.DeepEqual(packet1.Inputs[1].KeyIDs, packet2.Inputs[1].KeyIDs) ||
+		len(packet1.Inputs[1].Derivations) != 1 {
+
+		t.Fatalf("Combine: got input %+v", packet1.Inputs[1])
+	}
+
+	// A complete PSPT does not take more signatures.
+	if err := packet1.Combine(packet3); err != nil {
+		t.Fatalf("Combine: %v", err)
+	}
+	for i := range packet1.Tx.TxIn {
+		sigs, err := packet1.Signatures(i)
+		if err != nil {
+			t.Fatalf("Signatures: %v", err)
+		}
+		if len(sigs) != 2 || !sigs[0].PubKey.IsEqual(key1.PubKey()) ||
+			!sigs[1].PubKey.IsEqual(key2.PubKey()) {
+
+			t.Fatalf("input %d: unexpected signatures %+v", i, sigs)
+		}
+	}
+
+	other := newTestPacket(t, 300000)
+	if err := packet1.Combine(other); err == nil {
+		t.Error("Combine: combined PSPT of another transaction")
+	}
+	conflicting := newTestPacket(t, 300000, 400000)
+	conflicting.Inputs[1].KeyIDs = btcec.KeyIdMap{1: key3.PubKey()}
+	if err := packet1.Combine(conflicting); err == nil {
+		t.Error("Combine: combined conflicting key IDs")
+	}
+	if _, err := packet1.Signatures(2); err == nil {
+		t.Error("Signatures: returned signatures of missing input")
+	}
+}
+
+// TestPacketSign ensures each key signs every input once, the signatures
+// verify against the signature hashes, and only the fully signed transaction
+// is extracted.
+func TestPacketSign(t *testing.T) {
+	key1, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	key2, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	key3, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+
+	packet := newTestPacket(t, 300000, 400000)
+	if err := packet.Sign(key1); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if packet.Complete() {
+		t.Fatal("Complete: PSPT with one signature is complete")
+	}
+	if _, err := packet.Extract(); err != ErrIncomplete {
+		t.Fatalf("Extract: got error %v, want %v", err, ErrIncomplete)
+	}
+
+	// Signing again with the same key does not add another signature.
+	partial := packet.Tx.Copy()
+	if err := packet.Sign(key1); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if !reflect.DeepEqual(partial, packet.Tx) {
+		t.Fatal("Sign: signed twice with the same key")
+	}
+
+	if err := packet.Sign(key2); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if !packet.Complete() {
+		t.Fatal("Complete: fully signed PSPT is not complete")
+	}
+
+	// Signing a complete PSPT leaves it untouched.
+	signed := packet.Tx.Copy()
+	if err := packet.Sign(key3); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if !reflect.DeepEqual(signed, packet.Tx) {
+		t.Fatal("Sign: complete PSPT was changed")
+	}
+
+	tx, err := packet.Extract()
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	sigHashes := NewSigHashes(tx)
+	for i, txIn := range tx.TxIn {
+		pushes, err := PushedData(txIn.SignatureScript)
+		if err != nil {
+			t.Fatalf("PushedData: %v", err)
+		}
+		if len(pushes) != 4 {
+			t.Fatalf("input %d: got %d pushes, want 4", i, len(pushes))
+		}
+		hash, err := CalcSignatureHash(sigHashes, SigHashAll, tx, i,
+			packet.Inputs[i].Amount)
+		if err != nil {
+			t.Fatalf("CalcSignatureHash: %v", err)
+		}
+		for j := 0; j < len(pushes); j += 2 {
+			pubKey, err := btcec.ParsePubKey(pushes[j], btcec.S256())
+			if err != nil {
+				t.Fatalf("ParsePubKey: %v", err)
+			}
+			sigBytes := pushes[j+1]
+			if sigBytes[len(sigBytes)-1] != byte(SigHashAll) {
+				t.Fatalf("input %d: wrong hash type", i)
+			}
+			sig, err := btcec.ParseDERSignature(
+				sigBytes[:len(sigBytes)-1], btcec.S256())
+			if err != nil {
+				t.Fatalf("ParseDERSignature: %v", err)
+			}
+			if !sig.Verify(hash, pubKey) {
+				t.Fatalf("input %d: signature %d does not verify",
+					i, j/2)
+			}
+		}
+	}
+}
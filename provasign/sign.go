@@ -0,0 +1,168 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package provasign
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/wire"
+)
+
+// These are the opcodes of the script language which this package needs to
+// build signature scripts and to recognize the public key scripts of Prova
+// outputs.  They match the values defined by the txscript package.
+const (
+	opData75            = 0x4b
+	opPushData1         = 0x4c
+	opPushData2         = 0x4d
+	opPushData4         = 0x4e
+	op1                 = 0x51
+	op16                = 0x60
+	opCheckSafeMultiSig = 0xba
+)
+
+// minProvaScriptLength is a lower bound on the length of the public key script
+// of a Prova output, which consists of at least six opcodes.
+const minProvaScriptLength = 6
+
+var (
+	// ErrNotProvaScript is returned when a public key script is not the
+	// script of a Prova output.
+	ErrNotProvaScript = errors.New("public key script is not a Prova script")
+
+	// ErrMalformedPush is returned when a signature script contains an
+	// opcode other than a data push, or a push which exceeds the script.
+	ErrMalformedPush = errors.New("malformed data push in signature script")
+)
+
+// RawTxInSignature returns the serialized ECDSA signature for the input idx of
+// the given transaction, with hashType appended to it.  amt is the amount of
+// the output spent by the input.
+func RawTxInSignature(tx *wire.MsgTx, idx int, sigHashes *SigHashes, amt int64,
+	hashType uint32, key *btcec.PrivateKey) ([]byte, error) {
+
+	hash, err := CalcSignatureHash(sigHashes, hashType, tx, idx, amt)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := key.Sign(hash)
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign tx input: %s", err)
+	}
+
+	return append(signature.Serialize(), byte(hashType)), nil
+}
+
+// RequiredSignatures returns the number of signatures required to spend the
+// passed public key script of a Prova output, which has the form
+// <m> <pubkeyhash/keyid>... <n> OP_CHECKSAFEMULTISIG.
+func RequiredSignatures(pkScript []byte) (int, error) {
+	if len(pkScript) < minProvaScriptLength {
+		return 0, ErrNotProvaScript
+	}
+	if pkScript[len(pkScript)-1] != opCheckSafeMultiSig {
+		return 0, ErrNotProvaScript
+	}
+	m := pkScript[0]
+	if m < op1 || m > op16 {
+		return 0, ErrNotProvaScript
+	}
+	return int(m - (op1 - 1)), nil
+}
+
+// AppendSignature returns the passed signature script of a Prova input with
+// the passed public key and signature appended.  Signature scripts of Prova
+// inputs consist of pairs of compressed public keys and signatures, in any
+// order.
+func AppendSignature(sigScript []byte, pubKey *btcec.PublicKey, sig []byte) []byte {
+	script := make([]byte, len(sigScript), len(sigScript)+len(sig)+36)
+	copy(script, sigScript)
+	script = appendPush(script, pubKey.SerializeCompressed())
+	return appendPush(script, sig)
+}
+
+// HasSignature returns whether or not the passed signature script of a Prova
+// input already carries a signature of the passed public key.
+func HasSignature(sigScript []byte, pubKey *btcec.PublicKey) (bool, error) {
+	pushes, err := PushedData(sigScript)
+	if err != nil {
+		return false, err
+	}
+	serialized := pubKey.SerializeCompressed()
+	for i := 0; i+1 < len(pushes); i += 2 {
+		if bytes.Equal(pushes[i], serialized) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// PushedData returns the data pushed by the passed signature script, which
+// must consist of data pushes only.
+func PushedData(script []byte) ([][]byte, error) {
+	var pushes [][]byte
+	for len(script) > 0 {
+		op := script[0]
+		script = script[1:]
+
+		var size int
+		switch {
+		case op <= opData75:
+			size = int(op)
+		case op == opPushData1:
+			if len(script) < 1 {
+				return nil, ErrMalformedPush
+			}
+			size = int(script[0])
+			script = script[1:]
+		case op == opPushData2:
+			if len(script) < 2 {
+				return nil, ErrMalformedPush
+			}
+			size = int(binary.LittleEndian.Uint16(script))
+			script = script[2:]
+		case op == opPushData4:
+			if len(script) < 4 {
+				return nil, ErrMalformedPush
+			}
+			size = int(binary.LittleEndian.Uint32(script))
+			script = script[4:]
+		default:
+			return nil, ErrMalformedPush
+		}
+		if size < 0 || size > len(script) {
+			return nil, ErrMalformedPush
+		}
+		pushes = append(pushes, script[:size])
+		script = script[size:]
+	}
+	return pushes, nil
+}
+
+// appendPush appends the canonical push of the passed data to the script.
+func appendPush(script, data []byte) []byte {
+	size := len(data)
+	switch {
+	case size <= opData75:
+		script = append(script, byte(size))
+	case size <= 0xff:
+		script = append(script, opPushData1, byte(size))
+	case size <= 0xffff:
+		var buf [2]byte
+		binary.LittleEndian.PutUint16(buf[:], uint16(size))
+		script = append(script, opPushData2)
+		script = append(script, buf[:]...)
+	default:
+		var buf [4]byte
+		binary.LittleEndian.PutUint32(buf[:], uint32(size))
+		script = append(script, opPushData4)
+		script = append(script, buf[:]...)
+	}
+	return append(script, data...)
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package provasign
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/bitgo/prova/wire"
+)
+
+// TestPushedData ensures data appended to a signature script is pushed
+// canonically and read back, and that scripts with other opcodes are rejected.
+func TestPushedData(t *testing.T) {
+	sizes := []int{0, 1, 33, 75, 76, 255, 256, 65535, 65536}
+	var script []byte
+	for _, size := range sizes {
+		script = appendPush(script, bytes.Repeat([]byte{0xaa}, size))
+	}
+	pushes, err := PushedData(script)
+	if err != nil {
+		t.Fatalf("PushedData: %v", err)
+	}
+	if len(pushes) != len(sizes) {
+		t.Fatalf("PushedData: got %d pushes, want %d", len(pushes),
+			len(sizes))
+	}
+	for i, size := range sizes {
+		if len(pushes[i]) != size {
+			t.Errorf("push %d: got size %d, want %d", i,
+				len(pushes[i]), size)
+		}
+	}
+
+	invalid := [][]byte{
+		{0x51},             // OP_1
+		{0x02, 0x01},       // push exceeding the script
+		{0x4c},             // truncated OP_PUSHDATA1
+		{0x4d, 0x01},       // truncated OP_PUSHDATA2
+		{0x4e, 0x01, 0x00}, // truncated OP_PUSHDATA4
+	}
+	for i, script := range invalid {
+		if _, err := PushedData(script); err != ErrMalformedPush {
+			t.Errorf("invalid script %d: got error %v, want %v", i,
+				err, ErrMalformedPush)
+		}
+	}
+}
+
+// TestRequiredSignatures ensures the number of required signatures is read
+// from the public key scripts of Prova outputs and other scripts are rejected.
+func TestRequiredSignatures(t *testing.T) {
+	tests := []struct {
+		name     string
+		pkScript []byte
+		required int
+		err      error
+	}{
+		{name: "prova", pkScript: testPkScript, required: 2},
+		{
+			name:     "2 of 4",
+			pkScript: []byte{0x52, 0x51, 0x52, 0x53, 0x54, 0x54, 0xba},
+			required: 2,
+		},
+		{name: "empty", err: ErrNotProvaScript},
+		{
+			name:     "not safe multisig",
+			pkScript: []byte{0x52, 0x51, 0x52, 0x53, 0x53, 0xae},
+			err:      ErrNotProvaScript,
+		},
+		{
+			name:     "no small integer",
+			pkScript: []byte{0x01, 0x51, 0x52, 0x53, 0x53, 0xba},
+			err:      ErrNotProvaScript,
+		},
+	}
+	for _, test := range tests {
+		required, err := RequiredSignatures(test.pkScript)
+		if err != test.err || required != test.required {
+			t.Errorf("%s: got %d, %v, want %d, %v", test.name,
+				required, err, test.required, test.err)
+		}
+	}
+}
+
+// TestCalcSignatureHash ensures the signature hash commits to the amount of
+// the spent output and rejects input indexes out of range.
+func TestCalcSignatureHash(t *testing.T) {
+	packet := newTestPacket(t, 300000, 400000)
+	tx := packet.Tx
+	sigHashes := NewSigHashes(tx)
+
+	hash1, err := CalcSignatureHash(sigHashes, SigHashAll, tx, 0, 300000)
+	if err != nil {
+		t.Fatalf("CalcSignatureHash: %v", err)
+	}
+	hash2, err := CalcSignatureHash(sigHashes, SigHashAll, tx, 0, 300001)
+	if err != nil {
+		t.Fatalf("CalcSignatureHash: %v", err)
+	}
+	if bytes.Equal(hash1, hash2) {
+		t.Fatal("CalcSignatureHash: hash does not commit to the amount")
+	}
+	hash3, err := CalcSignatureHash(sigHashes, SigHashAll, tx, 1, 300000)
+	if err != nil {
+		t.Fatalf("CalcSignatureHash: %v", err)
+	}
+	if bytes.Equal(hash1, hash3) {
+		t.Fatal("CalcSignatureHash: hash does not commit to the input")
+	}
+
+	// Changing an output changes the hash of every input.
+	changed := tx.Copy()
+	changed.TxOut[0].Value--
+	hash4, err := CalcSignatureHash(NewSigHashes(changed), SigHashAll,
+		changed, 0, 300000)
+	if err != nil {
+		t.Fatalf("CalcSignatureHash: %v", err)
+	}
+	if bytes.Equal(hash1, hash4) {
+		t.Fatal("CalcSignatureHash: hash does not commit to the outputs")
+	}
+
+	for _, idx := range []int{-1, len(tx.TxIn)} {
+		_, err := CalcSignatureHash(sigHashes, SigHashAll, tx, idx, 0)
+		if err == nil {
+			t.Errorf("CalcSignatureHash: accepted input index %d", idx)
+		}
+	}
+	if _, err := CalcSignatureHash(sigHashes, SigHashAll, wire.NewMsgTx(1),
+		0, 0); err == nil {
+
+		t.Error("CalcSignatureHash: accepted transaction without inputs")
+	}
+}
@@ -0,0 +1,313 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package adminutil
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provasign"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/txbuilder"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// ErrNoOps is returned by NewKeyTx when no operations are passed.
+var ErrNoOps = errors.New("admin transaction has no operations")
+
+// Op is an admin operation which adds a key to or revokes a key from one of
+// the admin key sets of the chain.  Each operation is carried by an output of
+// an admin transaction which spends the tip of the thread it belongs to.
+type Op struct {
+	// Code is the operation, one of the txscript.AdminOp constants.
+	Code byte
+
+	// PubKey is the key which is added or revoked.
+	PubKey *btcec.PublicKey
+
+	// KeyID is the key ID the key is bound to by the ASP key operations.
+	// It is not used by the other operations.
+	KeyID btcec.KeyID
+}
+
+// opNames maps the admin op codes to the names of the operations, which are
+// the names used by the createrawadmintransaction and decodeadmintransaction
+// RPCs.
+var opNames = map[byte]string{
+	txscript.AdminOpIssueKeyAdd:        "issuekeyadd",
+	txscript.AdminOpIssueKeyRevoke:     "issuekeyrevoke",
+	txscript.AdminOpProvisionKeyAdd:    "provisionkeyadd",
+	txscript.AdminOpProvisionKeyRevoke: "provisionkeyrevoke",
+	txscript.AdminOpValidateKeyAdd:     "validatekeyadd",
+	txscript.AdminOpValidateKeyRevoke:  "validatekeyrevoke",
+	txscript.AdminOpASPKeyAdd:          "aspkeyadd",
+	txscript.AdminOpASPKeyRevoke:       "aspkeyrevoke",
+}
+
+// NewOp returns the key operation with the passed name, such as
+// "validatekeyadd", for the passed key.  The key ID is only used by the ASP key
+// operations.
+func NewOp(name string, pubKey *btcec.PublicKey, keyID btcec.KeyID) (*Op, error) {
+	for code, opName := range opNames {
+		if opName != name {
+			continue
+		}
+		op := &Op{Code: code, PubKey: pubKey}
+		if op.isASPOp() {
+			op.KeyID = keyID
+		}
+		return op, nil
+	}
+	return nil, fmt.Errorf("unknown admin operation %q", name)
+}
+
+// AddIssueKey returns an operation which adds the passed key to the issue keys.
+func AddIssueKey(pubKey *btcec.PublicKey) *Op {
+	return &Op{Code: txscript.AdminOpIssueKeyAdd, PubKey: pubKey}
+}
+
+// RevokeIssueKey returns an operation which revokes the passed issue key.
+func RevokeIssueKey(pubKey *btcec.PublicKey) *Op {
+	return &Op{Code: txscript.AdminOpIssueKeyRevoke, PubKey: pubKey}
+}
+
+// AddProvisionKey returns an operation which adds the passed key to the
+// provision keys.
+func AddProvisionKey(pubKey *btcec.PublicKey) *Op {
+	return &Op{Code: txscript.AdminOpProvisionKeyAdd, PubKey: pubKey}
+}
+
+// RevokeProvisionKey returns an operation which revokes the passed provision
+// key.
+func RevokeProvisionKey(pubKey *btcec.PublicKey) *Op {
+	return &Op{Code: txscript.AdminOpProvisionKeyRevoke, PubKey: pubKey}
+}
+
+// AddValidateKey returns an operation which adds the passed key to the
+// validate keys.
+func AddValidateKey(pubKey *btcec.PublicKey) *Op {
+	return &Op{Code: txscript.AdminOpValidateKeyAdd, PubKey: pubKey}
+}
+
+// RevokeValidateKey returns an operation which revokes the passed validate key.
+func RevokeValidateKey(pubKey *btcec.PublicKey) *Op {
+	return &Op{Code: txscript.AdminOpValidateKeyRevoke, PubKey: pubKey}
+}
+
+// AddASPKey returns an operation which binds the passed key ID to the passed
+// ASP key.  Key IDs must be added in increasing order, starting after the last
+// key ID of the chain as returned by the getadmininfo RPC.
+func AddASPKey(pubKey *btcec.PublicKey, keyID btcec.KeyID) *Op {
+	return &Op{Code: txscript.AdminOpASPKeyAdd, PubKey: pubKey, KeyID: keyID}
+}
+
+// RevokeASPKey returns an operation which revokes the binding of the passed
+// key ID to the passed ASP key.
+func RevokeASPKey(pubKey *btcec.PublicKey, keyID btcec.KeyID) *Op {
+	return &Op{Code: txscript.AdminOpASPKeyRevoke, PubKey: pubKey,
+		KeyID: keyID}
+}
+
+// Name returns the name of the operation, such as "validatekeyadd".
+func (op *Op) Name() string {
+	if name, ok := opNames[op.Code]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown operation %#x", op.Code)
+}
+
+// isASPOp returns whether or not the operation binds a key ID.
+func (op *Op) isASPOp() bool {
+	return op.Code == txscript.AdminOpASPKeyAdd ||
+		op.Code == txscript.AdminOpASPKeyRevoke
+}
+
+// Thread returns the admin thread which carries out the operation.
+func (op *Op) Thread() (provautil.ThreadID, error) {
+	switch op.Code {
+	case txscript.AdminOpIssueKeyAdd, txscript.AdminOpIssueKeyRevoke,
+		txscript.AdminOpProvisionKeyAdd,
+		txscript.AdminOpProvisionKeyRevoke:
+
+		return provautil.RootThread, nil
+
+	case txscript.AdminOpValidateKeyAdd, txscript.AdminOpValidateKeyRevoke,
+		txscript.AdminOpASPKeyAdd, txscript.AdminOpASPKeyRevoke:
+
+		return provautil.ProvisionThread, nil
+	}
+	return 0, fmt.Errorf("unknown admin operation %#x", op.Code)
+}
+
+// Script returns the public key script of the output which carries the
+// operation, which has the form OP_RETURN <op> <compressed pubkey>, followed
+// by the key ID in the same push for the ASP key operations.
+func (op *Op) Script() ([]byte, error) {
+	if _, err := op.Thread(); err != nil {
+		return nil, err
+	}
+	if op.PubKey == nil {
+		return nil, errors.New("admin operation has no key")
+	}
+	size := 1 + btcec.PubKeyBytesLenCompressed
+	if op.isASPOp() {
+		size += btcec.KeyIDSize
+	}
+	data := make([]byte, size)
+	data[0] = op.Code
+	copy(data[1:], op.PubKey.SerializeCompressed())
+	if op.isASPOp() {
+		op.KeyID.ToAddressFormat(data[1+btcec.PubKeyBytesLenCompressed:])
+	}
+	return txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(data).Script()
+}
+
+// newThreadTx returns a new admin transaction which spends the passed tip of
+// the passed thread and carries the thread on in its first output.
+func newThreadTx(threadTip *wire.OutPoint, threadID provautil.ThreadID) (*wire.MsgTx, error) {
+	threadScript, err := txscript.ProvaThreadScript(threadID)
+	if err != nil {
+		return nil, err
+	}
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(threadTip, nil))
+	tx.AddTxOut(wire.NewTxOut(0, threadScript))
+	return tx, nil
+}
+
+// NewKeyTx returns a new admin transaction which carries out the passed
+// operations.  The operations must all belong to the same thread, and the
+// transaction spends the passed tip of that thread, as returned by the
+// getadmininfo RPC.
+func NewKeyTx(threadTip *wire.OutPoint, ops ...*Op) (*wire.MsgTx, error) {
+	if len(ops) == 0 {
+		return nil, ErrNoOps
+	}
+	threadID, err := ops[0].Thread()
+	if err != nil {
+		return nil, err
+	}
+	tx, err := newThreadTx(threadTip, threadID)
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		opThread, err := op.Thread()
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+		if opThread != threadID {
+			return nil, fmt.Errorf("operation %d belongs to thread "+
+				"%d instead of %d", i, opThread, threadID)
+		}
+		pkScript, err := op.Script()
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %v", i, err)
+		}
+		tx.AddTxOut(wire.NewTxOut(0, pkScript))
+	}
+	return tx, nil
+}
+
+// NewIssueTx returns a new issue thread transaction which issues new tokens to
+// the passed outputs, which must pay to Prova addresses, such as the ones
+// returned by txbuilder.NewOutput.  The transaction spends the passed tip of
+// the issue thread.
+func NewIssueTx(threadTip *wire.OutPoint, outputs ...*wire.TxOut) (*wire.MsgTx, error) {
+	if len(outputs) == 0 {
+		return nil, errors.New("issue transaction has no outputs")
+	}
+	tx, err := newThreadTx(threadTip, provautil.IssueThread)
+	if err != nil {
+		return nil, err
+	}
+	for i, output := range outputs {
+		if output.Value <= 0 {
+			return nil, fmt.Errorf("output %d issues %d atoms", i,
+				output.Value)
+		}
+		if !isProvaScript(output.PkScript) {
+			return nil, fmt.Errorf("output %d does not pay to a "+
+				"Prova address", i)
+		}
+		tx.AddTxOut(output)
+	}
+	return tx, nil
+}
+
+// NewDestroyTx returns a new issue thread transaction which destroys the
+// tokens of the passed unspent outputs, which must be Prova outputs, less the
+// passed fee.  The transaction spends the passed tip of the issue thread.
+func NewDestroyTx(threadTip *wire.OutPoint, utxos []*txbuilder.Utxo, fee provautil.Amount) (*wire.MsgTx, error) {
+	if len(utxos) == 0 {
+		return nil, errors.New("destroy transaction spends no outputs")
+	}
+	tx, err := newThreadTx(threadTip, provautil.IssueThread)
+	if err != nil {
+		return nil, err
+	}
+	var total provautil.Amount
+	for i, utxo := range utxos {
+		if !isProvaScript(utxo.PkScript) {
+			return nil, fmt.Errorf("unspent output %d is not a "+
+				"Prova output", i)
+		}
+		tx.AddTxIn(wire.NewTxIn(&utxo.OutPoint, nil))
+		total += utxo.Amount
+	}
+	if total <= fee {
+		return nil, txbuilder.ErrInsufficientFunds
+	}
+	pkScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		Script()
+	if err != nil {
+		return nil, err
+	}
+	tx.AddTxOut(wire.NewTxOut(int64(total-fee), pkScript))
+	return tx, nil
+}
+
+// isProvaScript returns whether or not the passed public key script is the
+// script of a Prova output.
+func isProvaScript(pkScript []byte) bool {
+	class := txscript.GetScriptClass(pkScript)
+	return class == txscript.ProvaTy || class == txscript.GeneralProvaTy
+}
+
+// SignThread adds a signature of the passed admin key to the first input of the
+// passed admin transaction, which spends the tip of its thread.  Thread inputs
+// require the signatures of two keys of the key set of the thread, the root
+// keys for the root thread, the provision keys for the provision thread, and
+// the issue keys for the issue thread, so the keys are able to sign on
+// separate hosts one after another.
+//
+// The other inputs of destroy transactions spend Prova outputs, which are
+// signed with the provasign package.
+func SignThread(tx *wire.MsgTx, key *btcec.PrivateKey) error {
+	if len(tx.TxIn) == 0 {
+		return errors.New("transaction has no inputs")
+	}
+	txIn := tx.TxIn[0]
+	signed, err := provasign.HasSignature(txIn.SignatureScript, key.PubKey())
+	if err != nil {
+		return err
+	}
+	if signed {
+		return nil
+	}
+
+	// The outputs of admin threads carry no value.
+	sig, err := provasign.RawTxInSignature(tx, 0,
+		provasign.NewSigHashes(tx), 0, provasign.SigHashAll, key)
+	if err != nil {
+		return err
+	}
+	txIn.SignatureScript = provasign.AppendSignature(txIn.SignatureScript,
+		key.PubKey(), sig)
+	return nil
+}
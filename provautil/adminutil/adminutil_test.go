@@ -0,0 +1,208 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package adminutil
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provasign"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/txbuilder"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// newTestKey returns a new private key.
+func newTestKey(t *testing.T) *btcec.PrivateKey {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	return key
+}
+
+// newTestOutput returns an output paying the passed amount to a new Prova
+// address.
+func newTestOutput(t *testing.T, amount provautil.Amount) *wire.TxOut {
+	key := newTestKey(t)
+	pkHash := provautil.Hash160(key.PubKey().SerializeCompressed())
+	addr, err := provautil.NewAddressProva(pkHash, []btcec.KeyID{1, 2},
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressProva: %v", err)
+	}
+	output, err := txbuilder.NewOutput(addr, amount)
+	if err != nil {
+		t.Fatalf("NewOutput: %v", err)
+	}
+	return output
+}
+
+// TestKeyTx ensures the key operations are carried out by their threads with
+// outputs the chain recognizes as valid admin operations.
+func TestKeyTx(t *testing.T) {
+	t.Parallel()
+
+	pubKey := newTestKey(t).PubKey()
+	keyHex := hex.EncodeToString(pubKey.SerializeCompressed())
+	tests := []struct {
+		ops    []*Op
+		thread provautil.ThreadID
+		want   []string
+	}{
+		{
+			ops:    []*Op{AddIssueKey(pubKey), RevokeProvisionKey(pubKey)},
+			thread: provautil.RootThread,
+			want: []string{
+				"ADD_KEY ISSUE " + keyHex,
+				"REVOKE_KEY PROVISION " + keyHex,
+			},
+		},
+		{
+			ops: []*Op{AddValidateKey(pubKey), AddASPKey(pubKey, 7),
+				RevokeASPKey(pubKey, 3)},
+			thread: provautil.ProvisionThread,
+			want: []string{
+				"ADD_KEY VALIDATE " + keyHex,
+				"ADD_KEY ASP " + keyHex + " 7",
+				"REVOKE_KEY ASP " + keyHex + " 3",
+			},
+		},
+	}
+	threadTip := wire.NewOutPoint(&chainhash.Hash{1}, 0)
+	for i, test := range tests {
+		tx, err := NewKeyTx(threadTip, test.ops...)
+		if err != nil {
+			t.Fatalf("NewKeyTx #%d: unexpected error: %v", i, err)
+		}
+		err = blockchain.CheckTransactionSanity(provautil.NewTx(tx))
+		if err != nil {
+			t.Fatalf("NewKeyTx #%d: insane transaction: %v", i, err)
+		}
+		threadID, outputs := txscript.GetAdminDetailsMsgTx(tx)
+		if provautil.ThreadID(threadID) != test.thread {
+			t.Fatalf("NewKeyTx #%d: got thread %d, want %d", i,
+				threadID, test.thread)
+		}
+		if len(outputs) != len(test.want) {
+			t.Fatalf("NewKeyTx #%d: got %d operations, want %d", i,
+				len(outputs), len(test.want))
+		}
+		for j, output := range outputs {
+			if !txscript.IsValidAdminOp(output, test.thread) {
+				t.Fatalf("NewKeyTx #%d: operation %d is invalid",
+					i, j)
+			}
+			opStr := txscript.AdminOpString(tx.TxOut[j+1].PkScript)
+			if opStr != test.want[j] {
+				t.Fatalf("NewKeyTx #%d: got operation %q, want %q",
+					i, opStr, test.want[j])
+			}
+		}
+	}
+
+	// Ensure operations of different threads are not mixed.
+	_, err := NewKeyTx(threadTip, AddIssueKey(pubKey), AddValidateKey(pubKey))
+	if err == nil {
+		t.Fatal("NewKeyTx: operations of two threads were accepted")
+	}
+	if _, err := NewKeyTx(threadTip); err != ErrNoOps {
+		t.Fatalf("NewKeyTx: got error %v, want %v", err, ErrNoOps)
+	}
+}
+
+// TestIssueTx ensures issue and destroy transactions pass the sanity checks of
+// the chain and destroy the spent tokens less the fee.
+func TestIssueTx(t *testing.T) {
+	t.Parallel()
+
+	threadTip := wire.NewOutPoint(&chainhash.Hash{1}, 0)
+	output := newTestOutput(t, 500000)
+	issueTx, err := NewIssueTx(threadTip, output)
+	if err != nil {
+		t.Fatalf("NewIssueTx: unexpected error: %v", err)
+	}
+	if err := blockchain.CheckTransactionSanity(provautil.NewTx(issueTx)); err != nil {
+		t.Fatalf("NewIssueTx: insane transaction: %v", err)
+	}
+	_, err = NewIssueTx(threadTip, wire.NewTxOut(1, []byte{txscript.OP_TRUE}))
+	if err == nil {
+		t.Fatal("NewIssueTx: non-Prova output was accepted")
+	}
+
+	utxos := []*txbuilder.Utxo{{
+		OutPoint: wire.OutPoint{Hash: chainhash.Hash{2}, Index: 1},
+		Amount:   500000,
+		PkScript: output.PkScript,
+	}}
+	destroyTx, err := NewDestroyTx(threadTip, utxos, 1000)
+	if err != nil {
+		t.Fatalf("NewDestroyTx: unexpected error: %v", err)
+	}
+	if err := blockchain.CheckTransactionSanity(provautil.NewTx(destroyTx)); err != nil {
+		t.Fatalf("NewDestroyTx: insane transaction: %v", err)
+	}
+	if len(destroyTx.TxIn) != 2 || destroyTx.TxOut[1].Value != 499000 {
+		t.Fatalf("NewDestroyTx: got %d inputs destroying %d atoms",
+			len(destroyTx.TxIn), destroyTx.TxOut[1].Value)
+	}
+	_, err = NewDestroyTx(threadTip, utxos, 500000)
+	if err != txbuilder.ErrInsufficientFunds {
+		t.Fatalf("NewDestroyTx: got error %v, want %v", err,
+			txbuilder.ErrInsufficientFunds)
+	}
+}
+
+// TestSignThread ensures the thread input of admin transactions is signed by
+// each admin key once, with signatures which verify against the signature hash
+// of the input.
+func TestSignThread(t *testing.T) {
+	t.Parallel()
+
+	key1, key2 := newTestKey(t), newTestKey(t)
+	tx, err := NewKeyTx(wire.NewOutPoint(&chainhash.Hash{1}, 0),
+		AddValidateKey(newTestKey(t).PubKey()))
+	if err != nil {
+		t.Fatalf("NewKeyTx: unexpected error: %v", err)
+	}
+	for _, key := range []*btcec.PrivateKey{key1, key2, key1} {
+		if err := SignThread(tx, key); err != nil {
+			t.Fatalf("SignThread: unexpected error: %v", err)
+		}
+	}
+
+	pushes, err := provasign.PushedData(tx.TxIn[0].SignatureScript)
+	if err != nil {
+		t.Fatalf("PushedData: unexpected error: %v", err)
+	}
+	if len(pushes) != 4 {
+		t.Fatalf("SignThread: got %d pushes, want 4", len(pushes))
+	}
+	hash, err := provasign.CalcSignatureHash(provasign.NewSigHashes(tx),
+		provasign.SigHashAll, tx, 0, 0)
+	if err != nil {
+		t.Fatalf("CalcSignatureHash: unexpected error: %v", err)
+	}
+	for i, key := range []*btcec.PrivateKey{key1, key2} {
+		pubKey, err := btcec.ParsePubKey(pushes[2*i], btcec.S256())
+		if err != nil || !pubKey.IsEqual(key.PubKey()) {
+			t.Fatalf("SignThread: signature %d is not by key %d", i, i)
+		}
+		rawSig := pushes[2*i+1]
+		sig, err := btcec.ParseDERSignature(rawSig[:len(rawSig)-1],
+			btcec.S256())
+		if err != nil {
+			t.Fatalf("ParseDERSignature: unexpected error: %v", err)
+		}
+		if !sig.Verify(hash, pubKey) {
+			t.Fatalf("SignThread: signature %d does not verify", i)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package adminutil
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// ErrNotAdminTx is returned by DecodeTx when the passed transaction does not
+// carry on an admin thread.
+var ErrNotAdminTx = errors.New("transaction is not an admin transaction")
+
+// AdminTx houses the admin semantics of an admin transaction.
+type AdminTx struct {
+	// Thread is the admin thread the transaction carries on.
+	Thread provautil.ThreadID
+
+	// ThreadTip is the tip of the thread spent by the transaction.
+	ThreadTip wire.OutPoint
+
+	// Ops are the key operations carried out by the transactions of the
+	// root and provision threads.
+	Ops []*Op
+
+	// Issued are the outputs which issue new tokens by issue thread
+	// transactions which do not destroy tokens.
+	Issued []*wire.TxOut
+
+	// Destroyed are the outputs whose tokens are destroyed by issue thread
+	// transactions which spend them, and DestroyedAmount is the amount they
+	// destroy.  The Prova outputs of these transactions pay change.
+	Destroyed       []wire.OutPoint
+	DestroyedAmount provautil.Amount
+}
+
+// ParseOp returns the key operation carried by the passed public key script of
+// an output of an admin transaction.
+func ParseOp(pkScript []byte) (*Op, error) {
+	if len(pkScript) == 0 || pkScript[0] != txscript.OP_RETURN {
+		return nil, errors.New("admin operation does not start with " +
+			"OP_RETURN")
+	}
+	pushes, err := txscript.PushedData(pkScript)
+	if err != nil {
+		return nil, err
+	}
+	if len(pushes) != 1 || len(pushes[0]) == 0 {
+		return nil, errors.New("admin operation does not push its data")
+	}
+	data := pushes[0]
+	op := &Op{Code: data[0]}
+	if _, ok := opNames[op.Code]; !ok {
+		return nil, fmt.Errorf("unknown admin operation %#x", op.Code)
+	}
+	wantLen := 1 + btcec.PubKeyBytesLenCompressed
+	if op.isASPOp() {
+		wantLen += btcec.KeyIDSize
+	}
+	if len(data) != wantLen {
+		return nil, fmt.Errorf("%s operation pushes %d bytes instead "+
+			"of %d", op.Name(), len(data), wantLen)
+	}
+	op.PubKey, err = btcec.ParsePubKey(data[1:1+btcec.PubKeyBytesLenCompressed],
+		btcec.S256())
+	if err != nil {
+		return nil, err
+	}
+	if op.isASPOp() {
+		op.KeyID = btcec.KeyIDFromAddressBuffer(
+			data[1+btcec.PubKeyBytesLenCompressed:])
+	}
+	return op, nil
+}
+
+// DecodeTx returns the admin semantics of the passed transaction, which are the
+// admin thread it carries on and the operations it carries out.  ErrNotAdminTx
+// is returned when the transaction does not carry on an admin thread.
+//
+// Only the structure of the transaction is decoded, it is not checked against
+// the admin state of the chain.
+func DecodeTx(tx *wire.MsgTx) (*AdminTx, error) {
+	if len(tx.TxIn) == 0 || len(tx.TxOut) == 0 ||
+		txscript.GetScriptClass(tx.TxOut[0].PkScript) != txscript.ProvaAdminTy {
+
+		return nil, ErrNotAdminTx
+	}
+	threadID, _ := txscript.GetAdminDetailsMsgTx(tx)
+	if threadID < 0 {
+		return nil, ErrNotAdminTx
+	}
+	adminTx := &AdminTx{
+		Thread:    provautil.ThreadID(threadID),
+		ThreadTip: tx.TxIn[0].PreviousOutPoint,
+	}
+
+	if adminTx.Thread != provautil.IssueThread {
+		for i, txOut := range tx.TxOut[1:] {
+			op, err := ParseOp(txOut.PkScript)
+			if err != nil {
+				return nil, fmt.Errorf("output %d: %v", i+1, err)
+			}
+			opThread, _ := op.Thread()
+			if opThread != adminTx.Thread {
+				return nil, fmt.Errorf("output %d: %s operation "+
+					"on the %v thread", i+1, op.Name(),
+					adminTx.Thread)
+			}
+			adminTx.Ops = append(adminTx.Ops, op)
+		}
+		return adminTx, nil
+	}
+
+	// Issue thread transactions which spend other outputs besides the
+	// thread destroy the tokens of their null data outputs, while the
+	// others issue the tokens of their outputs.
+	isDestruction := len(tx.TxIn) > 1
+	for _, txIn := range tx.TxIn[1:] {
+		adminTx.Destroyed = append(adminTx.Destroyed,
+			txIn.PreviousOutPoint)
+	}
+	for i, txOut := range tx.TxOut[1:] {
+		switch txscript.GetScriptClass(txOut.PkScript) {
+		case txscript.NullDataTy:
+			if !isDestruction {
+				return nil, fmt.Errorf("output %d destroys "+
+					"tokens without spending any", i+1)
+			}
+			adminTx.DestroyedAmount += provautil.Amount(txOut.Value)
+
+		case txscript.ProvaTy, txscript.GeneralProvaTy:
+			if !isDestruction {
+				adminTx.Issued = append(adminTx.Issued, txOut)
+			}
+
+		default:
+			return nil, fmt.Errorf("output %d is neither a Prova "+
+				"nor a null data output", i+1)
+		}
+	}
+	return adminTx, nil
+}
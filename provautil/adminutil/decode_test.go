@@ -0,0 +1,137 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package adminutil
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/txbuilder"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestDecodeTx ensures the admin transactions built by the package decode to
+// the operations they were built from.
+func TestDecodeTx(t *testing.T) {
+	t.Parallel()
+
+	pubKey := newTestKey(t).PubKey()
+	threadTip := wire.NewOutPoint(&chainhash.Hash{1}, 0)
+
+	// Ensure key operations decode with their keys and key IDs.
+	ops := []*Op{AddValidateKey(pubKey), AddASPKey(pubKey, 7),
+		RevokeASPKey(pubKey, 3)}
+	keyTx, err := NewKeyTx(threadTip, ops...)
+	if err != nil {
+		t.Fatalf("NewKeyTx: unexpected error: %v", err)
+	}
+	adminTx, err := DecodeTx(keyTx)
+	if err != nil {
+		t.Fatalf("DecodeTx: unexpected error: %v", err)
+	}
+	if adminTx.Thread != provautil.ProvisionThread ||
+		adminTx.ThreadTip != *threadTip {
+
+		t.Fatalf("DecodeTx: got thread %v with tip %v", adminTx.Thread,
+			adminTx.ThreadTip)
+	}
+	if len(adminTx.Ops) != len(ops) {
+		t.Fatalf("DecodeTx: got %d operations, want %d",
+			len(adminTx.Ops), len(ops))
+	}
+	for i, op := range adminTx.Ops {
+		if op.Code != ops[i].Code || !op.PubKey.IsEqual(pubKey) ||
+			op.KeyID != ops[i].KeyID {
+
+			t.Fatalf("DecodeTx: got operation %d %s with key ID %d, "+
+				"want %s with key ID %d", i, op.Name(), op.KeyID,
+				ops[i].Name(), ops[i].KeyID)
+		}
+	}
+
+	// Ensure issued outputs are reported.
+	output := newTestOutput(t, 500000)
+	issueTx, err := NewIssueTx(threadTip, output)
+	if err != nil {
+		t.Fatalf("NewIssueTx: unexpected error: %v", err)
+	}
+	adminTx, err = DecodeTx(issueTx)
+	if err != nil {
+		t.Fatalf("DecodeTx: unexpected error: %v", err)
+	}
+	if adminTx.Thread != provautil.IssueThread ||
+		len(adminTx.Issued) != 1 || adminTx.Issued[0] != output {
+
+		t.Fatalf("DecodeTx: got thread %v issuing %d outputs",
+			adminTx.Thread, len(adminTx.Issued))
+	}
+
+	// Ensure destroyed outputs and amounts are reported.
+	utxo := &txbuilder.Utxo{
+		OutPoint: wire.OutPoint{Hash: chainhash.Hash{2}, Index: 1},
+		Amount:   500000,
+		PkScript: output.PkScript,
+	}
+	destroyTx, err := NewDestroyTx(threadTip, []*txbuilder.Utxo{utxo}, 1000)
+	if err != nil {
+		t.Fatalf("NewDestroyTx: unexpected error: %v", err)
+	}
+	adminTx, err = DecodeTx(destroyTx)
+	if err != nil {
+		t.Fatalf("DecodeTx: unexpected error: %v", err)
+	}
+	if len(adminTx.Issued) != 0 || len(adminTx.Destroyed) != 1 ||
+		adminTx.Destroyed[0] != utxo.OutPoint ||
+		adminTx.DestroyedAmount != 499000 {
+
+		t.Fatalf("DecodeTx: got %d issued outputs and %d destroyed "+
+			"outputs destroying %v", len(adminTx.Issued),
+			len(adminTx.Destroyed), adminTx.DestroyedAmount)
+	}
+
+	// Ensure transactions which do not carry on a thread are rejected.
+	tx := wire.NewMsgTx(wire.TxVersion)
+	tx.AddTxIn(wire.NewTxIn(threadTip, nil))
+	tx.AddTxOut(output)
+	if _, err := DecodeTx(tx); err != ErrNotAdminTx {
+		t.Fatalf("DecodeTx: got error %v, want %v", err, ErrNotAdminTx)
+	}
+}
+
+// TestParseOp ensures malformed admin operations are rejected.
+func TestParseOp(t *testing.T) {
+	t.Parallel()
+
+	pkScript, err := AddASPKey(newTestKey(t).PubKey(), 7).Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+	op, err := ParseOp(pkScript)
+	if err != nil {
+		t.Fatalf("ParseOp: unexpected error: %v", err)
+	}
+	if op.Name() != "aspkeyadd" || op.KeyID != 7 {
+		t.Fatalf("ParseOp: got %s with key ID %d", op.Name(), op.KeyID)
+	}
+
+	// Drop the key ID from the operation.
+	data := make([]byte, len(pkScript)-6)
+	copy(data, pkScript[2:len(pkScript)-4])
+	short, err := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(data).Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+	unknown := append([]byte(nil), pkScript...)
+	unknown[2] = 0xff
+	tests := [][]byte{nil, pkScript[1:], short, unknown}
+	for i, test := range tests {
+		if _, err := ParseOp(test); err == nil {
+			t.Errorf("ParseOp #%d: malformed operation was accepted", i)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package adminutil provides offline construction of Prova admin transactions.
+
+# Overview
+
+The admin state of the chain, which consists of the admin key sets and the key
+IDs of the ASP keys, is changed by admin transactions.  Each admin transaction
+spends the tip of one of the three admin threads, whose current outpoints are
+returned by the getadmininfo RPC, and carries the thread on in its first
+output.  The root thread adds and revokes the issue and provision keys, the
+provision thread adds and revokes the validate keys and binds key IDs to ASP
+keys, and the issue thread issues and destroys tokens.
+
+The operations which change the key sets are created with the typed
+constructors, such as AddValidateKey and AddASPKey, and carried out by the
+transactions NewKeyTx returns.  NewIssueTx returns a transaction which issues
+new tokens to Prova outputs, and NewDestroyTx one which destroys the tokens of
+Prova outputs.
+
+# Decoding
+
+DecodeTx returns the admin thread a transaction carries on and the operations
+it carries out, which lets signers review a transaction before they sign it.
+ParseOp decodes the key operation of a single output.
+
+# Signing
+
+The thread input of an admin transaction requires the signatures of two keys of
+the key set of its thread.  SignThread adds the signature of one key, so the
+admin keys are able to sign on separate hosts without access to the chain.  The
+Prova outputs spent by destroy transactions are signed like any other Prova
+output with the provasign package.
+*/
+package adminutil
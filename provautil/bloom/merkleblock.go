@@ -17,6 +17,7 @@ import (
 type merkleBlock struct {
 	numTx       uint32
 	allHashes   []*chainhash.Hash
+	layers      [][]chainhash.Hash
 	finalHashes []*chainhash.Hash
 	matchedBits []byte
 	bits        []byte
@@ -29,8 +30,12 @@ func (m *merkleBlock) calcTreeWidth(height uint32) uint32 {
 }
 
 // calcHash returns the hash for a sub-tree given a depth-first height and
-// node position.
+// node position.  The hash is looked up in the precomputed layers of the merkle
+// tree when they are available.
 func (m *merkleBlock) calcHash(height, pos uint32) *chainhash.Hash {
+	if m.layers != nil {
+		return &m.layers[height][pos]
+	}
 	if height == 0 {
 		return m.allHashes[pos]
 	}
@@ -78,15 +83,56 @@ func (m *merkleBlock) traverseAndBuild(height, pos uint32) {
 	}
 }
 
+// msgMerkleBlock builds the depth-first partial merkle tree and returns the
+// merkle block with the passed header which contains it.
+func (m *merkleBlock) msgMerkleBlock(header *wire.BlockHeader) *wire.MsgMerkleBlock {
+	// Calculate the number of merkle branches (height) in the tree.
+	height := uint32(0)
+	for m.calcTreeWidth(height) > 1 {
+		height++
+	}
+
+	// Build the depth-first partial merkle tree.
+	m.traverseAndBuild(height, 0)
+
+	// Create and return the merkle block.
+	msgMerkleBlock := wire.MsgMerkleBlock{
+		Header:       *header,
+		Transactions: uint32(m.numTx),
+		Hashes:       make([]*chainhash.Hash, 0, len(m.finalHashes)),
+		Flags:        make([]byte, (len(m.bits)+7)/8),
+	}
+	for _, hash := range m.finalHashes {
+		msgMerkleBlock.AddTxHash(hash)
+	}
+	for i := uint32(0); i < uint32(len(m.bits)); i++ {
+		msgMerkleBlock.Flags[i/8] |= m.bits[i] << (i % 8)
+	}
+	return &msgMerkleBlock
+}
+
 // NewMerkleBlock returns a new *wire.MsgMerkleBlock and an array of the matched
 // transaction index numbers based on the passed block and filter.
 func NewMerkleBlock(block *provautil.Block, filter *Filter) (*wire.MsgMerkleBlock, []uint32) {
+	return NewMerkleBlockWithLayers(block, filter, nil)
+}
+
+// NewMerkleBlockWithLayers returns a new *wire.MsgMerkleBlock and an array of
+// the matched transaction index numbers based on the passed block and filter
+// like NewMerkleBlock.  The passed layers of the merkle tree over the
+// transaction hashes, as built by blockchain.BuildMerkleTreeLayers, are used
+// instead of hashing the tree again.  The tree is hashed when the layers are
+// nil.
+func NewMerkleBlockWithLayers(block *provautil.Block, filter *Filter, layers [][]chainhash.Hash) (*wire.MsgMerkleBlock, []uint32) {
 	numTx := uint32(len(block.Transactions()))
 	mBlock := merkleBlock{
 		numTx:       numTx,
-		allHashes:   make([]*chainhash.Hash, 0, numTx),
+		layers:      layers,
 		matchedBits: make([]byte, 0, numTx),
 	}
+	if layers == nil {
+		mBlock.allHashes = make([]*chainhash.Hash, 0, numTx)
+	}
 
 	// Find and keep track of any transactions that match the filter.
 	var matchedIndices []uint32
@@ -97,30 +143,28 @@ func NewMerkleBlock(block *provautil.Block, filter *Filter) (*wire.MsgMerkleBloc
 		} else {
 			mBlock.matchedBits = append(mBlock.matchedBits, 0x00)
 		}
-		mBlock.allHashes = append(mBlock.allHashes, tx.Hash())
-	}
-
-	// Calculate the number of merkle branches (height) in the tree.
-	height := uint32(0)
-	for mBlock.calcTreeWidth(height) > 1 {
-		height++
+		if layers == nil {
+			mBlock.allHashes = append(mBlock.allHashes, tx.Hash())
+		}
 	}
 
-	// Build the depth-first partial merkle tree.
-	mBlock.traverseAndBuild(height, 0)
+	return mBlock.msgMerkleBlock(&block.MsgBlock().Header), matchedIndices
+}
 
-	// Create and return the merkle block.
-	msgMerkleBlock := wire.MsgMerkleBlock{
-		Header:       block.MsgBlock().Header,
-		Transactions: uint32(mBlock.numTx),
-		Hashes:       make([]*chainhash.Hash, 0, len(mBlock.finalHashes)),
-		Flags:        make([]byte, (len(mBlock.bits)+7)/8),
-	}
-	for _, hash := range mBlock.finalHashes {
-		msgMerkleBlock.AddTxHash(hash)
+// NewMerkleBlockFromLayers returns a new *wire.MsgMerkleBlock with the passed
+// header which proves the inclusion of the transactions at the passed indices.
+// The layers are those of the merkle tree over the transaction hashes of the
+// block as built by blockchain.BuildMerkleTreeLayers, so the merkle block is
+// created without access to the block itself.
+func NewMerkleBlockFromLayers(header *wire.BlockHeader, layers [][]chainhash.Hash, matchedIndices []uint32) *wire.MsgMerkleBlock {
+	numTx := uint32(len(layers[0]))
+	mBlock := merkleBlock{
+		numTx:       numTx,
+		layers:      layers,
+		matchedBits: make([]byte, numTx),
 	}
-	for i := uint32(0); i < uint32(len(mBlock.bits)); i++ {
-		msgMerkleBlock.Flags[i/8] |= mBlock.bits[i] << (i % 8)
+	for _, txIndex := range matchedIndices {
+		mBlock.matchedBits[txIndex] = 0x01
 	}
-	return &msgMerkleBlock, matchedIndices
+	return mBlock.msgMerkleBlock(header)
 }
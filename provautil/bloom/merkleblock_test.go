@@ -10,6 +10,7 @@ import (
 	"encoding/hex"
 	"testing"
 
+	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/provautil/bloom"
@@ -73,3 +74,58 @@ func TestMerkleBlock3(t *testing.T) {
 		return
 	}
 }
+
+// TestMerkleBlockFromLayers ensures merkle blocks created from precomputed
+// merkle tree layers match those created by hashing the block.
+func TestMerkleBlockFromLayers(t *testing.T) {
+	msgBlock := wire.NewMsgBlock(&wire.BlockHeader{Version: 1})
+	for i := 0; i < 7; i++ {
+		tx := wire.NewMsgTx(1)
+		tx.LockTime = uint32(i)
+		msgBlock.AddTransaction(tx)
+	}
+	blk := provautil.NewBlock(msgBlock)
+
+	hashes := make([]chainhash.Hash, 0, len(blk.Transactions()))
+	for _, tx := range blk.Transactions() {
+		hashes = append(hashes, *tx.Hash())
+	}
+	layers := blockchain.BuildMerkleTreeLayers(hashes)
+
+	encode := func(msg *wire.MsgMerkleBlock) []byte {
+		var buf bytes.Buffer
+		if err := msg.BtcEncode(&buf, wire.ProtocolVersion); err != nil {
+			t.Fatalf("BtcEncode failed: %v", err)
+		}
+		return buf.Bytes()
+	}
+
+	for _, matched := range [][]uint32{{0}, {2, 5}, {6}, {0, 1, 2, 3, 4, 5, 6}} {
+		newFilter := func() *bloom.Filter {
+			f := bloom.NewFilter(10, 0, 0.000001, wire.BloomUpdateNone)
+			for _, txIndex := range matched {
+				f.AddHash(&hashes[txIndex])
+			}
+			return f
+		}
+
+		want, wantIndices := bloom.NewMerkleBlock(blk, newFilter())
+		got, gotIndices := bloom.NewMerkleBlockWithLayers(blk, newFilter(),
+			layers)
+		if !bytes.Equal(encode(got), encode(want)) {
+			t.Errorf("NewMerkleBlockWithLayers %v: mismatched merkle "+
+				"block", matched)
+		}
+		if len(gotIndices) != len(wantIndices) {
+			t.Errorf("NewMerkleBlockWithLayers %v: got matched indices "+
+				"%v, want %v", matched, gotIndices, wantIndices)
+		}
+
+		got = bloom.NewMerkleBlockFromLayers(&msgBlock.Header, layers,
+			matched)
+		if !bytes.Equal(encode(got), encode(want)) {
+			t.Errorf("NewMerkleBlockFromLayers %v: mismatched merkle "+
+				"block", matched)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package gcs
+
+import (
+	"io"
+)
+
+// bitWriter appends bits to a byte slice starting with the most significant
+// bit of each byte.
+type bitWriter struct {
+	bytes []byte
+
+	// used is the number of bits of the last byte which are written.
+	used uint8
+}
+
+// writeBit appends the passed bit.
+func (w *bitWriter) writeBit(bit bool) {
+	if w.used == 0 || w.used == 8 {
+		w.bytes = append(w.bytes, 0)
+		w.used = 0
+	}
+	if bit {
+		w.bytes[len(w.bytes)-1] |= 0x80 >> w.used
+	}
+	w.used++
+}
+
+// writeBits appends the passed number of least significant bits of the passed
+// value starting with the most significant of them.
+func (w *bitWriter) writeBits(v uint64, n uint8) {
+	for i := n; i > 0; i-- {
+		w.writeBit(v>>(i-1)&1 == 1)
+	}
+}
+
+// writeGolombRice appends the Golomb-Rice encoding of the passed value with the
+// passed parameter.  The quotient is written in unary followed by the remainder
+// in p bits.
+func (w *bitWriter) writeGolombRice(v uint64, p uint8) {
+	for q := v >> p; q > 0; q-- {
+		w.writeBit(true)
+	}
+	w.writeBit(false)
+	w.writeBits(v, p)
+}
+
+// bitReader reads the bits written by a bitWriter.
+type bitReader struct {
+	bytes []byte
+
+	// pos is the index of the next bit to read.
+	pos uint64
+}
+
+// readBit reads the next bit.  io.EOF is returned when all bits were read.
+func (r *bitReader) readBit() (bool, error) {
+	if r.pos >= uint64(len(r.bytes))*8 {
+		return false, io.EOF
+	}
+	bit := r.bytes[r.pos/8]&(0x80>>(r.pos%8)) != 0
+	r.pos++
+	return bit, nil
+}
+
+// readBits reads the passed number of bits into the least significant bits of
+// the returned value.
+func (r *bitReader) readBits(n uint8) (uint64, error) {
+	var v uint64
+	for i := uint8(0); i < n; i++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		v <<= 1
+		if bit {
+			v |= 1
+		}
+	}
+	return v, nil
+}
+
+// readGolombRice reads a value encoded by writeGolombRice with the passed
+// parameter.
+func (r *bitReader) readGolombRice(p uint8) (uint64, error) {
+	var q uint64
+	for {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if !bit {
+			break
+		}
+		q++
+	}
+	rem, err := r.readBits(p)
+	if err != nil {
+		return 0, err
+	}
+	return q<<p | rem, nil
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package builder builds the compact block filters of Prova blocks.
+//
+// The basic filter of a block holds the public key scripts of the outputs the
+// block creates and spends, except for null data scripts.  Since Prova scripts
+// bind the funds to the public key hash of the owner along with the key IDs of
+// the account service providers, the public key hash and each key ID of a Prova
+// script are added as items too.  This lets light clients find the outputs of
+// their keys whichever key IDs they were bound to, and account service
+// providers find all outputs bound to their key IDs.
+package builder
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/gcs"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// DefaultP is the Golomb-Rice parameter of the basic filters.
+	DefaultP = 19
+
+	// DefaultM is the inverse false positive rate of the basic filters.
+	DefaultM = 784931
+)
+
+// The prefixes of the items derived from Prova scripts, which keep them from
+// matching any public key script.
+const (
+	itemTypePubKeyHash = 0x01
+	itemTypeKeyID      = 0x02
+)
+
+// DeriveKey returns the key the items of the filter of the block with the
+// passed hash are hashed with, which is the first half of the block hash.
+func DeriveKey(blockHash *chainhash.Hash) [gcs.KeySize]byte {
+	var key [gcs.KeySize]byte
+	copy(key[:], blockHash[:gcs.KeySize])
+	return key
+}
+
+// PubKeyHashItem returns the filter item matching the Prova scripts which pay
+// to the passed public key hash.
+func PubKeyHashItem(pkHash []byte) []byte {
+	return append([]byte{itemTypePubKeyHash}, pkHash...)
+}
+
+// KeyIDItem returns the filter item matching the Prova scripts which are bound
+// to the passed key ID.
+func KeyIDItem(keyID btcec.KeyID) []byte {
+	item := make([]byte, 5)
+	item[0] = itemTypeKeyID
+	binary.BigEndian.PutUint32(item[1:], uint32(keyID))
+	return item
+}
+
+// AddressItems returns the filter items matching the outputs paying to the
+// passed address.  For Prova addresses, the item matching its public key hash
+// is included so outputs bound to other key IDs match too.
+func AddressItems(addr provautil.Address) ([][]byte, error) {
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	items := [][]byte{pkScript}
+	if provaAddr, ok := addr.(*provautil.AddressProva); ok {
+		items = append(items, PubKeyHashItem(provaAddr.ScriptAddress()))
+	}
+	return items, nil
+}
+
+// scriptItems returns the filter items of the passed public key script.
+func scriptItems(pkScript []byte, chainParams *chaincfg.Params) [][]byte {
+	if len(pkScript) == 0 ||
+		txscript.GetScriptClass(pkScript) == txscript.NullDataTy {
+
+		return nil
+	}
+
+	items := [][]byte{pkScript}
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript, chainParams)
+	if err != nil {
+		return items
+	}
+	for _, addr := range addrs {
+		provaAddr, ok := addr.(*provautil.AddressProva)
+		if !ok {
+			continue
+		}
+		items = append(items, PubKeyHashItem(provaAddr.ScriptAddress()))
+		for _, keyID := range provaAddr.ScriptKeyIDs() {
+			items = append(items, KeyIDItem(keyID))
+		}
+	}
+	return items
+}
+
+// BuildBasicFilter builds the basic filter of the passed block.  The passed
+// scripts are the public key scripts of the outputs spent by the block.
+func BuildBasicFilter(block *wire.MsgBlock, prevOutScripts [][]byte, chainParams *chaincfg.Params) (*gcs.Filter, error) {
+	seen := make(map[string]struct{})
+	var items [][]byte
+	addItems := func(pkScript []byte) {
+		for _, item := range scriptItems(pkScript, chainParams) {
+			if _, ok := seen[string(item)]; ok {
+				continue
+			}
+			seen[string(item)] = struct{}{}
+			items = append(items, item)
+		}
+	}
+	for _, tx := range block.Transactions {
+		for _, txOut := range tx.TxOut {
+			addItems(txOut.PkScript)
+		}
+	}
+	for _, pkScript := range prevOutScripts {
+		addItems(pkScript)
+	}
+
+	blockHash := block.BlockHash()
+	return gcs.BuildGCSFilter(DefaultP, DefaultM, DeriveKey(&blockHash),
+		items)
+}
+
+// GetFilterHash returns the hash of the passed filter, which is the double
+// SHA-256 of its serialization.
+func GetFilterHash(filter *gcs.Filter) chainhash.Hash {
+	return chainhash.DoubleHashH(filter.NBytes())
+}
+
+// MakeHeaderForFilter returns the header of the passed filter, which commits to
+// the filter and to the header of the filter of the previous block.  The
+// header of the filter of the genesis block commits to the zero hash.
+func MakeHeaderForFilter(filter *gcs.Filter, prevHeader *chainhash.Hash) chainhash.Hash {
+	return MakeHeaderForFilterHash(GetFilterHash(filter), prevHeader)
+}
+
+// MakeHeaderForFilterHash returns the header of the filter with the passed
+// hash as described by MakeHeaderForFilter.
+func MakeHeaderForFilterHash(filterHash chainhash.Hash, prevHeader *chainhash.Hash) chainhash.Hash {
+	var buf [2 * chainhash.HashSize]byte
+	copy(buf[:], filterHash[:])
+	copy(buf[chainhash.HashSize:], prevHeader[:])
+	return chainhash.DoubleHashH(buf[:])
+}
+
+// VerifyHeaders derives the filter headers of consecutive main chain blocks
+// starting at the passed height from the hashes of their filters and the
+// filter header of the block preceding them, which is the zero hash for the
+// genesis block.  It ensures the derived filter headers match the passed filter
+// header checkpoints at the heights they cover and returns them.
+func VerifyHeaders(checkpoints []chaincfg.FilterHeaderCheckpoint, startHeight uint32, prevHeader *chainhash.Hash, filterHashes []chainhash.Hash) ([]chainhash.Hash, error) {
+	headers := make([]chainhash.Hash, 0, len(filterHashes))
+	header := *prevHeader
+	for i := range filterHashes {
+		header = MakeHeaderForFilterHash(filterHashes[i], &header)
+		headers = append(headers, header)
+	}
+
+	endHeight := startHeight + uint32(len(headers))
+	for _, checkpoint := range checkpoints {
+		if checkpoint.Height < startHeight ||
+			checkpoint.Height >= endHeight {
+
+			continue
+		}
+		header := &headers[checkpoint.Height-startHeight]
+		if *header != *checkpoint.Header {
+			return nil, fmt.Errorf("filter header %v at height %d "+
+				"does not match checkpoint %v", header,
+				checkpoint.Height, checkpoint.Header)
+		}
+	}
+	return headers, nil
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package builder
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestBuildBasicFilter ensures the basic filter of a block matches the scripts
+// it creates and spends along with the public key hashes and key IDs of its
+// Prova scripts, and nothing else.
+func TestBuildBasicFilter(t *testing.T) {
+	params := &chaincfg.MainNetParams
+	pkHash := bytes.Repeat([]byte{0x11}, 20)
+	addr, err := provautil.NewAddressProva(pkHash,
+		[]btcec.KeyID{1, 70000}, params)
+	if err != nil {
+		t.Fatalf("NewAddressProva: unexpected error: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: unexpected error: %v", err)
+	}
+	nullData, err := txscript.NullDataScript([]byte("prova"))
+	if err != nil {
+		t.Fatalf("NullDataScript: unexpected error: %v", err)
+	}
+	spentScript := []byte{txscript.OP_TRUE}
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: 1}, nil))
+	tx.AddTxOut(wire.NewTxOut(100, pkScript))
+	tx.AddTxOut(wire.NewTxOut(0, nullData))
+	block := wire.NewMsgBlock(wire.NewBlockHeader(&chainhash.Hash{},
+		&chainhash.Hash{}, 0, 0))
+	block.AddTransaction(tx)
+
+	filter, err := BuildBasicFilter(block, [][]byte{spentScript}, params)
+	if err != nil {
+		t.Fatalf("BuildBasicFilter: unexpected error: %v", err)
+	}
+	if filter.N() != 5 {
+		t.Errorf("unexpected number of items %d", filter.N())
+	}
+
+	blockHash := block.BlockHash()
+	key := DeriveKey(&blockHash)
+	addrItems, err := AddressItems(addr)
+	if err != nil {
+		t.Fatalf("AddressItems: unexpected error: %v", err)
+	}
+	matching := append(addrItems, spentScript, KeyIDItem(1),
+		KeyIDItem(70000))
+	for i, item := range matching {
+		match, err := filter.Match(key, item)
+		if err != nil {
+			t.Fatalf("Match #%d: unexpected error: %v", i, err)
+		}
+		if !match {
+			t.Errorf("Match #%d: item %x does not match", i, item)
+		}
+	}
+
+	other := [][]byte{nullData, KeyIDItem(2),
+		PubKeyHashItem(bytes.Repeat([]byte{0x22}, 20))}
+	match, err := filter.MatchAny(key, other)
+	if err != nil || match {
+		t.Errorf("MatchAny of other items: got %v, %v", match, err)
+	}
+}
+
+// TestFilterHeaders ensures the filter headers commit to the filter and to the
+// previous header.
+func TestFilterHeaders(t *testing.T) {
+	block := wire.NewMsgBlock(wire.NewBlockHeader(&chainhash.Hash{},
+		&chainhash.Hash{}, 0, 0))
+	filter, err := BuildBasicFilter(block, nil, &chaincfg.MainNetParams)
+	if err != nil {
+		t.Fatalf("BuildBasicFilter: unexpected error: %v", err)
+	}
+
+	filterHash := GetFilterHash(filter)
+	if filterHash != chainhash.DoubleHashH([]byte{0x00}) {
+		t.Errorf("unexpected filter hash %v", filterHash)
+	}
+	first := MakeHeaderForFilter(filter, &chainhash.Hash{})
+	second := MakeHeaderForFilter(filter, &first)
+	if first == second {
+		t.Error("header does not commit to the previous header")
+	}
+	want := chainhash.DoubleHashH(append(filterHash[:], first[:]...))
+	if second != want {
+		t.Errorf("unexpected header %v, want %v", second, want)
+	}
+}
+
+// TestVerifyHeaders ensures the filter headers derived from filter hashes chain
+// together and are verified against the checkpoints at the heights they cover.
+func TestVerifyHeaders(t *testing.T) {
+	prevHeader := chainhash.Hash{0x01}
+	filterHashes := []chainhash.Hash{{0x02}, {0x03}, {0x04}}
+	first := MakeHeaderForFilterHash(filterHashes[0], &prevHeader)
+	second := MakeHeaderForFilterHash(filterHashes[1], &first)
+	third := MakeHeaderForFilterHash(filterHashes[2], &second)
+	want := []chainhash.Hash{first, second, third}
+
+	tests := []struct {
+		name        string
+		checkpoints []chaincfg.FilterHeaderCheckpoint
+		valid       bool
+	}{
+		{"no checkpoints", nil, true},
+		{"matching checkpoint", []chaincfg.FilterHeaderCheckpoint{
+			{Height: 11, Header: &second},
+		}, true},
+		{"checkpoints outside range", []chaincfg.FilterHeaderCheckpoint{
+			{Height: 9, Header: &chainhash.Hash{}},
+			{Height: 13, Header: &chainhash.Hash{}},
+		}, true},
+		{"mismatched checkpoint", []chaincfg.FilterHeaderCheckpoint{
+			{Height: 10, Header: &first},
+			{Height: 12, Header: &second},
+		}, false},
+	}
+	for _, test := range tests {
+		headers, err := VerifyHeaders(test.checkpoints, 10, &prevHeader,
+			filterHashes)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: mismatched headers accepted", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(headers, want) {
+			t.Errorf("%s: got headers %v, want %v", test.name,
+				headers, want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package gcs provides the Golomb-coded sets used as compact block filters.
+
+Overview
+
+A Golomb-coded set is a probabilistic structure similar to a bloom filter which
+is considerably smaller for the same false positive rate.  Each item is hashed
+with SipHash-2-4 and mapped to the range [0, N*M), where N is the number of
+items and 1/M is the false positive rate.  The sorted hashes are stored as the
+Golomb-Rice coded differences between consecutive hashes with the parameter P.
+
+Compact block filters built from the scripts of blocks allow light clients to
+download the filters of the chain and only fetch the blocks which match their
+scripts, without revealing the scripts to the full nodes they connect to.  The
+builder package builds the filters of Prova blocks.
+*/
+package gcs
@@ -0,0 +1,209 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package gcs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// KeySize is the size of the SipHash keys the items of a filter are hashed
+// with.
+const KeySize = 16
+
+var (
+	// ErrNTooBig is returned when a filter is built with more items than
+	// fit in a uint32.
+	ErrNTooBig = errors.New("N is too big to fit in uint32")
+
+	// ErrPTooBig is returned when a filter is built with a Golomb-Rice
+	// parameter of more than 32 bits.
+	ErrPTooBig = errors.New("P is too big to fit in uint32")
+
+	// ErrMisserialized is returned when the serialized data of a filter
+	// holds fewer values than the filter claims.
+	ErrMisserialized = errors.New("filter data is misserialized")
+)
+
+// Filter is a Golomb-coded set.  It encodes the sorted hashes of its items,
+// which are mapped to the range [0, N*M), as the Golomb-Rice coded differences
+// between consecutive hashes.  A filter never misses an item it was built
+// with, while the probability of matching any other item is 1/M.
+type Filter struct {
+	n         uint32
+	p         uint8
+	modulusNM uint64
+	data      []byte
+}
+
+// sipKey splits the passed key into the two little endian halves SipHash is
+// keyed with.
+func sipKey(key [KeySize]byte) (uint64, uint64) {
+	return binary.LittleEndian.Uint64(key[0:8]),
+		binary.LittleEndian.Uint64(key[8:16])
+}
+
+// hashToRange maps the SipHash of the passed item to the range [0, modulusNM)
+// without a division by taking the high 64 bits of the 128-bit product.
+func hashToRange(k0, k1 uint64, item []byte, modulusNM uint64) uint64 {
+	hi, _ := bits.Mul64(chainhash.SipHash24(k0, k1, item), modulusNM)
+	return hi
+}
+
+// BuildGCSFilter builds a filter over the passed items with the Golomb-Rice
+// parameter p and the inverse false positive rate m, hashing the items with
+// the passed key.  Duplicate items are expected to be removed by the caller.
+func BuildGCSFilter(p uint8, m uint64, key [KeySize]byte, items [][]byte) (*Filter, error) {
+	if uint64(len(items)) > math.MaxUint32 {
+		return nil, ErrNTooBig
+	}
+	if p > 32 {
+		return nil, ErrPTooBig
+	}
+
+	f := &Filter{
+		n:         uint32(len(items)),
+		p:         p,
+		modulusNM: uint64(len(items)) * m,
+	}
+	k0, k1 := sipKey(key)
+	values := make([]uint64, 0, len(items))
+	for _, item := range items {
+		values = append(values, hashToRange(k0, k1, item, f.modulusNM))
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+
+	var w bitWriter
+	var last uint64
+	for _, v := range values {
+		w.writeGolombRice(v-last, p)
+		last = v
+	}
+	f.data = w.bytes
+	return f, nil
+}
+
+// FromBytes returns the filter with the passed number of items, parameters, and
+// serialized data as returned by Bytes.
+func FromBytes(n uint32, p uint8, m uint64, data []byte) (*Filter, error) {
+	if p > 32 {
+		return nil, ErrPTooBig
+	}
+	return &Filter{
+		n:         n,
+		p:         p,
+		modulusNM: uint64(n) * m,
+		data:      append([]byte(nil), data...),
+	}, nil
+}
+
+// FromNBytes returns the filter with the passed parameters and serialized data
+// as returned by NBytes.
+func FromNBytes(p uint8, m uint64, data []byte) (*Filter, error) {
+	r := bytes.NewReader(data)
+	n, err := wire.ReadVarInt(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if n > math.MaxUint32 {
+		return nil, ErrNTooBig
+	}
+	return FromBytes(uint32(n), p, m, data[len(data)-r.Len():])
+}
+
+// N returns the number of items the filter was built with.
+func (f *Filter) N() uint32 {
+	return f.n
+}
+
+// P returns the Golomb-Rice parameter of the filter.
+func (f *Filter) P() uint8 {
+	return f.p
+}
+
+// Bytes returns the Golomb-Rice coded data of the filter.
+func (f *Filter) Bytes() []byte {
+	return append([]byte(nil), f.data...)
+}
+
+// NBytes returns the serialization of the filter, which is the number of items
+// encoded as a variable length integer followed by the data returned by Bytes.
+func (f *Filter) NBytes() []byte {
+	var buf bytes.Buffer
+	buf.Grow(wire.MaxVarIntPayload + len(f.data))
+	_ = wire.WriteVarInt(&buf, 0, uint64(f.n))
+	buf.Write(f.data)
+	return buf.Bytes()
+}
+
+// values invokes the passed function with the hashes encoded in the filter in
+// ascending order until the function returns false.
+func (f *Filter) values(fn func(v uint64) bool) error {
+	r := bitReader{bytes: f.data}
+	var v uint64
+	for i := uint32(0); i < f.n; i++ {
+		delta, err := r.readGolombRice(f.p)
+		if err != nil {
+			return ErrMisserialized
+		}
+		v += delta
+		if !fn(v) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Match returns whether or not the passed item is likely in the filter, which
+// is built with the passed key.
+func (f *Filter) Match(key [KeySize]byte, item []byte) (bool, error) {
+	if f.n == 0 {
+		return false, nil
+	}
+	k0, k1 := sipKey(key)
+	target := hashToRange(k0, k1, item, f.modulusNM)
+
+	var match bool
+	err := f.values(func(v uint64) bool {
+		match = v == target
+		return v < target
+	})
+	return match, err
+}
+
+// MatchAny returns whether or not any of the passed items is likely in the
+// filter, which is built with the passed key.  It is faster than calling Match
+// for each item since the filter is only decoded once.
+func (f *Filter) MatchAny(key [KeySize]byte, items [][]byte) (bool, error) {
+	if f.n == 0 || len(items) == 0 {
+		return false, nil
+	}
+	k0, k1 := sipKey(key)
+	targets := make([]uint64, 0, len(items))
+	for _, item := range items {
+		targets = append(targets, hashToRange(k0, k1, item, f.modulusNM))
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i] < targets[j] })
+
+	var match bool
+	err := f.values(func(v uint64) bool {
+		for len(targets) > 0 && targets[0] < v {
+			targets = targets[1:]
+		}
+		if len(targets) == 0 {
+			return false
+		}
+		match = targets[0] == v
+		return !match
+	})
+	return match, err
+}
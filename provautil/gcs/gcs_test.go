@@ -0,0 +1,149 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package gcs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testItems returns the passed number of distinct items.
+func testItems(n int, offset uint32) [][]byte {
+	items := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		item := make([]byte, 8)
+		binary.BigEndian.PutUint32(item, offset+uint32(i))
+		copy(item[4:], "item")
+		items = append(items, item)
+	}
+	return items
+}
+
+// TestBitStream ensures values written with Golomb-Rice coding are read back.
+func TestBitStream(t *testing.T) {
+	values := []uint64{0, 1, 7, 1 << 19, 1<<19 + 3, 5 << 19, 123456789}
+	var w bitWriter
+	for _, v := range values {
+		w.writeGolombRice(v, 19)
+	}
+	r := bitReader{bytes: w.bytes}
+	for i, want := range values {
+		got, err := r.readGolombRice(19)
+		if err != nil {
+			t.Fatalf("#%d: unexpected error: %v", i, err)
+		}
+		if got != want {
+			t.Errorf("#%d: got %d, want %d", i, got, want)
+		}
+	}
+	if _, err := r.readBits(8); err == nil {
+		t.Error("read past the end of the data")
+	}
+}
+
+// TestFilter ensures filters match the items they were built with, rarely
+// match other items, and survive serialization.
+func TestFilter(t *testing.T) {
+	const p, m = 19, 784931
+	key := [KeySize]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14}
+	items := testItems(500, 0)
+	filter, err := BuildGCSFilter(p, m, key, items)
+	if err != nil {
+		t.Fatalf("BuildGCSFilter: unexpected error: %v", err)
+	}
+	if filter.N() != 500 || filter.P() != p {
+		t.Fatalf("unexpected parameters N=%d P=%d", filter.N(),
+			filter.P())
+	}
+
+	// Every item the filter was built with matches.
+	for i, item := range items {
+		match, err := filter.Match(key, item)
+		if err != nil {
+			t.Fatalf("Match #%d: unexpected error: %v", i, err)
+		}
+		if !match {
+			t.Errorf("Match #%d: item does not match", i)
+		}
+	}
+
+	// Other items only match at about the false positive rate.
+	others := testItems(10000, 1000)
+	var falsePositives int
+	for _, item := range others {
+		match, err := filter.Match(key, item)
+		if err != nil {
+			t.Fatalf("Match: unexpected error: %v", err)
+		}
+		if match {
+			falsePositives++
+		}
+	}
+	if falsePositives > 2 {
+		t.Errorf("%d false positives out of %d items", falsePositives,
+			len(others))
+	}
+
+	// MatchAny agrees with Match.
+	match, err := filter.MatchAny(key, others[:100])
+	if err != nil || match {
+		t.Errorf("MatchAny of other items: got %v, %v", match, err)
+	}
+	match, err = filter.MatchAny(key, append(others[:100:100], items[42]))
+	if err != nil || !match {
+		t.Errorf("MatchAny including an item: got %v, %v", match, err)
+	}
+
+	// A different key does not match the items.
+	match, err = filter.MatchAny([KeySize]byte{}, items[:3])
+	if err != nil || match {
+		t.Errorf("MatchAny with a different key: got %v, %v", match, err)
+	}
+
+	// The serialized filter decodes to the same filter.
+	decoded, err := FromNBytes(p, m, filter.NBytes())
+	if err != nil {
+		t.Fatalf("FromNBytes: unexpected error: %v", err)
+	}
+	if decoded.N() != filter.N() ||
+		!bytes.Equal(decoded.Bytes(), filter.Bytes()) {
+
+		t.Error("decoded filter differs from the original")
+	}
+	match, err = decoded.Match(key, items[499])
+	if err != nil || !match {
+		t.Errorf("Match of decoded filter: got %v, %v", match, err)
+	}
+
+	// Truncated data is detected.
+	truncated, err := FromBytes(filter.N(), p, m, filter.Bytes()[:100])
+	if err != nil {
+		t.Fatalf("FromBytes: unexpected error: %v", err)
+	}
+	if _, err := truncated.Match(key, items[499]); err != ErrMisserialized {
+		t.Errorf("Match of truncated filter: got %v, want %v", err,
+			ErrMisserialized)
+	}
+}
+
+// TestEmptyFilter ensures filters without items match nothing.
+func TestEmptyFilter(t *testing.T) {
+	filter, err := BuildGCSFilter(19, 784931, [KeySize]byte{}, nil)
+	if err != nil {
+		t.Fatalf("BuildGCSFilter: unexpected error: %v", err)
+	}
+	if !bytes.Equal(filter.NBytes(), []byte{0x00}) {
+		t.Errorf("unexpected serialization %x", filter.NBytes())
+	}
+	match, err := filter.Match([KeySize]byte{}, []byte{0x01})
+	if err != nil || match {
+		t.Errorf("Match: got %v, %v", match, err)
+	}
+	if _, err := BuildGCSFilter(33, 784931, [KeySize]byte{}, nil); err != ErrPTooBig {
+		t.Errorf("BuildGCSFilter with P=33: got %v, want %v", err,
+			ErrPTooBig)
+	}
+}
@@ -5,6 +5,8 @@
 package provautil
 
 import (
+	"fmt"
+
 	"github.com/bitgo/prova/wire"
 )
 
@@ -14,6 +16,19 @@ const IssueThread = ThreadID(2)
 
 type ThreadID uint8
 
+// String returns the name of the admin thread.
+func (t ThreadID) String() string {
+	switch t {
+	case RootThread:
+		return "root"
+	case ProvisionThread:
+		return "provision"
+	case IssueThread:
+		return "issue"
+	}
+	return fmt.Sprintf("unknown thread %d", uint8(t))
+}
+
 func CopyThreadTips(threadTips map[ThreadID]*wire.OutPoint) map[ThreadID]*wire.OutPoint {
 	threadTipsCopy := make(map[ThreadID]*wire.OutPoint)
 	for threadId, outPoint := range threadTips {
@@ -0,0 +1,39 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package txbuilder provides offline construction of Prova transactions.
+
+# Overview
+
+Everything in this package works without a connection to a node, so hosts
+which sign transactions without network access are able to use the same
+library as the hosts which talk to the RPC server.  The unspent outputs which
+fund a transaction are supplied by the caller, typically as returned by the
+getaddressutxos or getwatchedutxos RPCs on a connected host, and converted with
+NewUtxoFromResult.
+
+Build selects unspent outputs in the order they are supplied until they cover
+the outputs and the fee at the passed fee rate, and pays any change to a change
+address.  Fees are estimated from the worst case size of the transaction once
+all of its inputs are signed, which is how the relay policy of the mempool
+measures them.  Applications which anchor data such as hashes on the chain add a
+null data output created by NewDataOutput, which does not pay any amount.
+
+# Partially Signed Prova Transactions
+
+A transaction which is built on one host and signed on others travels as a
+partially signed Prova transaction (PSPT), which is implemented by the provasign
+package.  AuthoredTx.Packet returns the PSPT of a built transaction, which
+houses the amount and public key script of every output it spends, and
+AddPacketKeyIDs binds the key IDs of those scripts to the ASP keys of the chain
+for signers which lack access to it.  Each signer adds its signatures with
+provasign.Packet.Sign, or with SignPacket, which only signs with those of a set
+of keys the spent outputs are bound to and reports the inputs which remain
+incomplete along with the signatures they lack.  Once every input carries the
+required signatures, VerifyPacket executes the scripts against the ASP keys of
+the chain, or those carried by the PSPT, and provasign.Packet.Extract returns
+the final transaction, which SerializeTx encodes for the sendrawtransaction RPC.
+*/
+package txbuilder
@@ -0,0 +1,235 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txbuilder
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provasign"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+)
+
+// Packet returns a new PSPT for the authored transaction, which spends the
+// unspent outputs it was built from.
+func (a *AuthoredTx) Packet() (*provasign.Packet, error) {
+	if len(a.Inputs) != len(a.Tx.TxIn) {
+		return nil, fmt.Errorf("transaction has %d inputs but %d "+
+			"unspent outputs were selected", len(a.Tx.TxIn),
+			len(a.Inputs))
+	}
+	inputs := make([]provasign.PacketInput, 0, len(a.Inputs))
+	for i, utxo := range a.Inputs {
+		if a.Tx.TxIn[i].PreviousOutPoint != utxo.OutPoint {
+			return nil, fmt.Errorf("input %d spends %v instead of "+
+				"%v", i, a.Tx.TxIn[i].PreviousOutPoint, utxo.OutPoint)
+		}
+		inputs = append(inputs, provasign.PacketInput{
+			Amount:   int64(utxo.Amount),
+			PkScript: utxo.PkScript,
+		})
+	}
+	return provasign.NewPacket(a.Tx, inputs)
+}
+
+// AddPacketKeyIDs binds the key IDs of the Prova outputs spent by the inputs of
+// the passed PSPT to the passed ASP keys, as provisioned on the chain and
+// returned by the getadmininfo RPC, so signers are able to check the keys of
+// the outputs they spend without access to the chain.
+func AddPacketKeyIDs(p *provasign.Packet, aspKeys btcec.KeyIdMap) error {
+	for i := range p.Inputs {
+		input := &p.Inputs[i]
+		pops, err := txscript.ParseScript(input.PkScript)
+		if err != nil {
+			return fmt.Errorf("input %d: %v", i, err)
+		}
+		scriptType := txscript.TypeOfScript(pops)
+		if scriptType != txscript.ProvaTy &&
+			scriptType != txscript.GeneralProvaTy &&
+			scriptType != txscript.ProvaTimelockTy {
+
+			continue
+		}
+		keyIDs, err := txscript.ExtractKeyIDs(pops)
+		if err != nil {
+			return fmt.Errorf("input %d: %v", i, err)
+		}
+		for _, keyID := range keyIDs {
+			pubKey, ok := aspKeys[keyID]
+			if !ok {
+				return fmt.Errorf("input %d: unknown key ID %v",
+					i, keyID)
+			}
+			if input.KeyIDs == nil {
+				input.KeyIDs = make(btcec.KeyIdMap)
+			}
+			input.KeyIDs[keyID] = pubKey
+		}
+	}
+	return nil
+}
+
+// VerifyPacket executes the scripts of every input of the transaction of the
+// passed PSPT.  The key IDs of Prova outputs are resolved with the passed ASP
+// keys, as provisioned on the chain and returned by the getadmininfo RPC.
+// When aspKeys is nil, they are resolved with the key IDs carried by the
+// inputs of the PSPT instead.
+func VerifyPacket(p *provasign.Packet, aspKeys btcec.KeyIdMap) error {
+	hashes := txscript.NewTxSigHashes(p.Tx)
+	for i := range p.Inputs {
+		if err := verifyPacketInput(p, i, hashes, aspKeys); err != nil {
+			return fmt.Errorf("input %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// verifyPacketInput executes the scripts of the input at the passed index the
+// same way VerifyPacket does.
+func verifyPacketInput(p *provasign.Packet, idx int, hashes *txscript.TxSigHashes,
+	aspKeys btcec.KeyIdMap) error {
+
+	input := p.Inputs[idx]
+	keys := aspKeys
+	if keys == nil {
+		keys = input.KeyIDs
+	}
+	pkScript := input.PkScript
+	pops, err := txscript.ParseScript(pkScript)
+	if err != nil {
+		return err
+	}
+	scriptType := txscript.TypeOfScript(pops)
+	if scriptType == txscript.ProvaTy ||
+		scriptType == txscript.GeneralProvaTy ||
+		scriptType == txscript.ProvaTimelockTy {
+
+		keyIDs, err := txscript.ExtractKeyIDs(pops)
+		if err != nil {
+			return err
+		}
+		keyHashes := make(map[btcec.KeyID][]byte, len(keyIDs))
+		for _, keyID := range keyIDs {
+			pubKey, ok := keys[keyID]
+			if !ok {
+				return fmt.Errorf("unknown key ID %v", keyID)
+			}
+			keyHashes[keyID] = provautil.Hash160(
+				pubKey.SerializeCompressed())
+		}
+		if err := txscript.ReplaceKeyIDs(pops, keyHashes); err != nil {
+			return err
+		}
+		pkScript, err = txscript.UnparseScript(pops)
+		if err != nil {
+			return err
+		}
+	}
+
+	vm, err := txscript.NewEngine(pkScript, p.Tx, idx,
+		txscript.StandardVerifyFlags, nil, hashes, input.Amount)
+	if err != nil {
+		return err
+	}
+	return vm.Execute()
+}
+
+// SignPacket signs every input of the passed PSPT which spends a Prova output
+// with those of the passed keys the output is bound to, either by the hash of
+// their public key or by a key ID.  Key IDs are resolved with the passed ASP
+// keys the same way VerifyPacket resolves them, so the keys of ASPs are able
+// to sign for the key IDs they are provisioned under.
+//
+// The returned slice holds an entry for every input, which is nil once the
+// input carries the required signatures and its scripts verify, and the reason
+// it does not otherwise, such as the keys whose signatures are missing.
+func SignPacket(p *provasign.Packet, keys []*btcec.PrivateKey, aspKeys btcec.KeyIdMap) []error {
+	errs := make([]error, len(p.Inputs))
+	for i, input := range p.Inputs {
+		resolved := aspKeys
+		if resolved == nil {
+			resolved = input.KeyIDs
+		}
+
+		// Collect the hashes of the public keys able to sign the
+		// output along with how the script refers to them.
+		keyHashes, keyIDs := txscript.ExtractProvaKeys(input.PkScript)
+		if keyHashes == nil && keyIDs == nil {
+			errs[i] = errors.New("not a Prova script")
+			continue
+		}
+		signers := make(map[string]string, len(keyHashes)+len(keyIDs))
+		for _, keyHash := range keyHashes {
+			signers[string(keyHash)] = fmt.Sprintf("pubkey hash %x",
+				keyHash)
+		}
+		for _, keyID := range keyIDs {
+			pubKey, ok := resolved[keyID]
+			if !ok {
+				errs[i] = fmt.Errorf("unknown key ID %v", keyID)
+				break
+			}
+			keyHash := provautil.Hash160(pubKey.SerializeCompressed())
+			signers[string(keyHash)] = fmt.Sprintf("key ID %v", keyID)
+		}
+		if errs[i] != nil {
+			continue
+		}
+
+		for _, key := range keys {
+			keyHash := provautil.Hash160(key.PubKey().SerializeCompressed())
+			if _, ok := signers[string(keyHash)]; !ok {
+				continue
+			}
+			if err := p.SignInput(i, key); err != nil {
+				errs[i] = err
+				break
+			}
+		}
+		if errs[i] != nil {
+			continue
+		}
+
+		if !p.InputComplete(i) {
+			errs[i] = missingSignatures(p, i, signers)
+			continue
+		}
+		hashes := txscript.NewTxSigHashes(p.Tx)
+		if err := verifyPacketInput(p, i, hashes, aspKeys); err != nil {
+			errs[i] = fmt.Errorf("signature script does not verify: %v",
+				err)
+		}
+	}
+	return errs
+}
+
+// missingSignatures returns an error describing the signatures the input at
+// the passed index lacks, given the passed descriptions of the signers of the
+// output keyed by the hashes of their public keys.
+func missingSignatures(p *provasign.Packet, idx int, signers map[string]string) error {
+	required, err := provasign.RequiredSignatures(p.Inputs[idx].PkScript)
+	if err != nil {
+		return err
+	}
+	sigs, err := p.Signatures(idx)
+	if err != nil {
+		return err
+	}
+	for _, sig := range sigs {
+		delete(signers, string(provautil.Hash160(
+			sig.PubKey.SerializeCompressed())))
+	}
+	missing := make([]string, 0, len(signers))
+	for _, signer := range signers {
+		missing = append(missing, signer)
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("has %d of %d required signatures, any of %s is "+
+		"able to sign", len(sigs), required, strings.Join(missing, ", "))
+}
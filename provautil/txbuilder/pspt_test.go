@@ -0,0 +1,177 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txbuilder
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/provasign"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestPacketSigning ensures a PSPT is signed by a user and an ASP on separate
+// hosts, only the fully signed transaction is extracted, and it verifies
+// against the ASP keys.
+func TestPacketSigning(t *testing.T) {
+	aspKey1, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	aspKey2, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	aspKeys := btcec.KeyIdMap{1: aspKey1.PubKey(), 2: aspKey2.PubKey()}
+
+	addr, userKey := newTestAddress(t, 1, 2)
+	utxos := newTestUtxos(t, addr, 300000, 400000)
+	output, err := NewOutput(addr, 500000)
+	if err != nil {
+		t.Fatalf("NewOutput: %v", err)
+	}
+	authored, err := Build([]*wire.TxOut{output}, utxos, addr, 1000)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	packet, err := authored.Packet()
+	if err != nil {
+		t.Fatalf("Packet: %v", err)
+	}
+	if err := AddPacketKeyIDs(packet, btcec.KeyIdMap{2: aspKey2.PubKey()}); err == nil {
+		t.Fatal("AddPacketKeyIDs: added unknown key ID")
+	}
+	if err := AddPacketKeyIDs(packet, aspKeys); err != nil {
+		t.Fatalf("AddPacketKeyIDs: %v", err)
+	}
+
+	// The user signs first and hands the encoded PSPT to the ASP.
+	if err := packet.Sign(userKey); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if packet.Complete() {
+		t.Fatal("Complete: PSPT with one signature is complete")
+	}
+	if _, err := packet.Extract(); err != provasign.ErrIncomplete {
+		t.Fatalf("Extract: got error %v, want %v", err, provasign.ErrIncomplete)
+	}
+	if err := VerifyPacket(packet, aspKeys); err == nil {
+		t.Fatal("VerifyPacket: PSPT with one signature verified")
+	}
+	encoded, err := packet.Encode()
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	packet, err = provasign.DecodePacket(encoded)
+	if err != nil {
+		t.Fatalf("DecodePacket: %v", err)
+	}
+	if err := packet.Sign(aspKey1); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if !packet.Complete() {
+		t.Fatal("Complete: fully signed PSPT is not complete")
+	}
+
+	// Signing a complete PSPT again leaves it untouched.
+	signed := packet.Tx.Copy()
+	if err := packet.Sign(aspKey2); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if !reflect.DeepEqual(signed, packet.Tx) {
+		t.Fatal("Sign: complete PSPT was changed")
+	}
+
+	if err := VerifyPacket(packet, aspKeys); err != nil {
+		t.Fatalf("VerifyPacket: %v", err)
+	}
+	if err := VerifyPacket(packet, btcec.KeyIdMap{2: aspKey2.PubKey()}); err == nil {
+		t.Fatal("VerifyPacket: verified with unknown key ID")
+	}
+
+	// The key IDs carried by the PSPT resolve the keys of the outputs when
+	// no ASP keys are passed.
+	if err := VerifyPacket(packet, nil); err != nil {
+		t.Fatalf("VerifyPacket: %v", err)
+	}
+	tx, err := packet.Extract()
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if EstimateSignedSize(tx) < tx.SerializeSize() {
+		t.Fatalf("EstimateSignedSize: estimate %d is below the signed "+
+			"size %d", EstimateSignedSize(tx), tx.SerializeSize())
+	}
+	if _, err := SerializeTx(tx); err != nil {
+		t.Fatalf("SerializeTx: %v", err)
+	}
+}
+
+// TestSignPacket ensures inputs are only signed with the keys of their outputs,
+// key IDs are resolved with the ASP keys, and the inputs which remain
+// incomplete are reported.
+func TestSignPacket(t *testing.T) {
+	aspKey1, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	aspKey2, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	aspKeys := btcec.KeyIdMap{1: aspKey1.PubKey(), 2: aspKey2.PubKey()}
+
+	addr, userKey := newTestAddress(t, 1, 2)
+	otherAddr, otherKey := newTestAddress(t, 1, 2)
+	utxos := append(newTestUtxos(t, addr, 300000),
+		newTestUtxos(t, otherAddr, 0, 400000)[1])
+	output, err := NewOutput(addr, 500000)
+	if err != nil {
+		t.Fatalf("NewOutput: %v", err)
+	}
+	authored, err := Build([]*wire.TxOut{output}, utxos, addr, 1000)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	packet, err := authored.Packet()
+	if err != nil {
+		t.Fatalf("Packet: %v", err)
+	}
+
+	// The user key only signs the first input, and the ASP key completes
+	// it through its key ID.
+	errs := SignPacket(packet, []*btcec.PrivateKey{userKey}, aspKeys)
+	if len(errs) != 2 || errs[0] == nil || errs[1] == nil {
+		t.Fatalf("SignPacket: got errors %v", errs)
+	}
+	if sigs, _ := packet.Signatures(1); len(sigs) != 0 {
+		t.Fatal("SignPacket: signed input with a foreign key")
+	}
+	errs = SignPacket(packet, []*btcec.PrivateKey{aspKey2}, aspKeys)
+	if errs[0] != nil || errs[1] == nil {
+		t.Fatalf("SignPacket: got errors %v", errs)
+	}
+	errs = SignPacket(packet, []*btcec.PrivateKey{otherKey, aspKey1},
+		aspKeys)
+	if errs[0] != nil || errs[1] != nil {
+		t.Fatalf("SignPacket: got errors %v", errs)
+	}
+	if err := VerifyPacket(packet, aspKeys); err != nil {
+		t.Fatalf("VerifyPacket: %v", err)
+	}
+
+	// Key IDs which are not provisioned are reported.
+	packet, err = authored.Packet()
+	if err != nil {
+		t.Fatalf("Packet: %v", err)
+	}
+	errs = SignPacket(packet, []*btcec.PrivateKey{userKey},
+		btcec.KeyIdMap{1: aspKey1.PubKey()})
+	if errs[0] == nil || packet.InputComplete(0) {
+		t.Fatal("SignPacket: signed input with unknown key ID")
+	}
+}
@@ -0,0 +1,280 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txbuilder
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// provaSigScriptSize is the largest number of bytes of a signature
+	// script which spends a Prova output with two signatures:
+	// 2 * (OP_DATA_33 <pubkey> OP_DATA_73 <sig>)
+	provaSigScriptSize = 2 * (1 + 33 + 1 + 73)
+
+	// provaInputSize is the largest number of bytes of a signed input which
+	// spends a Prova output: 36 prev outpoint, 1 script len, the signature
+	// script, and 4 sequence.
+	provaInputSize = 36 + 1 + provaSigScriptSize + 4
+)
+
+// ErrInsufficientFunds is returned by Build when the supplied unspent outputs
+// do not cover the outputs and the fee.
+var ErrInsufficientFunds = errors.New("insufficient funds")
+
+// Utxo houses an unspent output which is able to fund a transaction.
+type Utxo struct {
+	OutPoint wire.OutPoint
+	Amount   provautil.Amount
+	PkScript []byte
+}
+
+// NewUtxoFromResult returns the unspent output described by the passed result
+// of the getaddressutxos or getwatchedutxos RPCs.
+func NewUtxoFromResult(result *btcjson.AddressUtxoResult) (*Utxo, error) {
+	hash, err := chainhash.NewHashFromStr(result.Txid)
+	if err != nil {
+		return nil, err
+	}
+	amount, err := provautil.NewAmount(result.Value)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, err := hex.DecodeString(result.ScriptPubKey)
+	if err != nil {
+		return nil, err
+	}
+	return &Utxo{
+		OutPoint: *wire.NewOutPoint(hash, result.Vout),
+		Amount:   amount,
+		PkScript: pkScript,
+	}, nil
+}
+
+// NewOutput returns an output paying the passed amount to the passed address.
+func NewOutput(addr provautil.Address, amount provautil.Amount) (*wire.TxOut, error) {
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	return wire.NewTxOut(int64(amount), pkScript), nil
+}
+
+// NewDataOutput returns a zero-value null data output carrying the passed data,
+// such as a hash an application anchors on the chain.  The data may not exceed
+// txscript.MaxDataCarrierSize bytes, and the mempool may be configured to relay
+// less.
+func NewDataOutput(data []byte) (*wire.TxOut, error) {
+	pkScript, err := txscript.NullDataScript(data)
+	if err != nil {
+		return nil, err
+	}
+	return wire.NewTxOut(0, pkScript), nil
+}
+
+// NewOutputsFromAmounts returns the outputs paying the amounts in RMG of the
+// passed map to their addresses, as passed to the createrawtransaction RPC.
+// The outputs are ordered by address so the same map always results in the
+// same transaction.
+func NewOutputsFromAmounts(amounts map[string]float64,
+	params *chaincfg.Params) ([]*wire.TxOut, error) {
+
+	addrStrs := make([]string, 0, len(amounts))
+	for addrStr := range amounts {
+		addrStrs = append(addrStrs, addrStr)
+	}
+	sort.Strings(addrStrs)
+
+	outputs := make([]*wire.TxOut, 0, len(amounts))
+	for _, addrStr := range addrStrs {
+		addr, err := provautil.DecodeAddress(addrStr, params)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %s: %v", addrStr,
+				err)
+		}
+		if !addr.IsForNet(params) {
+			return nil, fmt.Errorf("address %s is not for %s",
+				addrStr, params.Name)
+		}
+		amount, err := provautil.NewAmount(amounts[addrStr])
+		if err != nil {
+			return nil, err
+		}
+		output, err := NewOutput(addr, amount)
+		if err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
+
+// EstimateSignedSize returns the worst case serialized size of the passed
+// transaction once all of its inputs, which must spend Prova outputs, are
+// signed.  Signature scripts which are already present are replaced by the
+// estimate.
+func EstimateSignedSize(tx *wire.MsgTx) int {
+	size := tx.SerializeSize()
+	for _, txIn := range tx.TxIn {
+		size -= wire.VarIntSerializeSize(uint64(len(txIn.SignatureScript))) +
+			len(txIn.SignatureScript)
+	}
+	return size + len(tx.TxIn)*(provaInputSize-36-4)
+}
+
+// FeeForSize returns the fee of a transaction of the passed serialized size at
+// the passed fee rate in atoms per kilobyte.  It is calculated the same way as
+// the minimum fee required by the relay policy of the mempool.
+func FeeForSize(size int, feeRate provautil.Amount) provautil.Amount {
+	fee := int64(size) * int64(feeRate) / 1000
+	if fee == 0 && feeRate > 0 {
+		fee = int64(feeRate)
+	}
+	if fee < 0 || fee > provautil.MaxAtoms {
+		fee = provautil.MaxAtoms
+	}
+	return provautil.Amount(fee)
+}
+
+// EstimateFee returns the fee of the passed transaction at the passed fee rate
+// in atoms per kilobyte once all of its inputs are signed.
+func EstimateFee(tx *wire.MsgTx, feeRate provautil.Amount) provautil.Amount {
+	return FeeForSize(EstimateSignedSize(tx), feeRate)
+}
+
+// isDust returns whether or not the passed output is dust at the passed fee
+// rate.  It matches the dust rule of the relay policy of the mempool, with the
+// size of the input which spends the output being that of a Prova input.
+func isDust(txOut *wire.TxOut, feeRate provautil.Amount) bool {
+	totalSize := txOut.SerializeSize() + provaInputSize
+	return txOut.Value*1000/(3*int64(totalSize)) < int64(feeRate)
+}
+
+// AuthoredTx houses a transaction built by Build along with the unspent outputs
+// it spends in input order.
+type AuthoredTx struct {
+	Tx     *wire.MsgTx
+	Inputs []*Utxo
+	Fee    provautil.Amount
+
+	// ChangeIndex is the index of the change output, or -1 when the
+	// transaction has none.
+	ChangeIndex int
+}
+
+// Build returns a new unsigned transaction paying to the passed outputs at the
+// passed fee rate in atoms per kilobyte.  Outputs must pay a positive amount,
+// except for null data outputs, which must not pay any amount.  The passed
+// unspent outputs, which must pay to Prova addresses, are selected in order
+// until they cover the outputs and the fee.  Any change is paid to the passed
+// change address unless it is nil or the change is dust, in which case it is
+// left to the fee.
+func Build(outputs []*wire.TxOut, utxos []*Utxo, change provautil.Address,
+	feeRate provautil.Amount) (*AuthoredTx, error) {
+
+	if feeRate < 0 {
+		return nil, fmt.Errorf("negative fee rate %v", feeRate)
+	}
+
+	tx := wire.NewMsgTx(wire.TxVersion)
+	var target int64
+	for _, output := range outputs {
+		if txscript.GetScriptClass(output.PkScript) == txscript.NullDataTy {
+			if output.Value != 0 {
+				return nil, fmt.Errorf("null data output pays "+
+					"%v", provautil.Amount(output.Value))
+			}
+			tx.AddTxOut(output)
+			continue
+		}
+		if output.Value <= 0 || output.Value > provautil.MaxAtoms {
+			return nil, fmt.Errorf("output amount %v is out of range",
+				provautil.Amount(output.Value))
+		}
+		target += output.Value
+		tx.AddTxOut(output)
+	}
+	if target > provautil.MaxAtoms {
+		return nil, fmt.Errorf("total output amount %v is out of range",
+			provautil.Amount(target))
+	}
+
+	var changeScript []byte
+	if change != nil {
+		var err error
+		changeScript, err = txscript.PayToAddrScript(change)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var total int64
+	selected := make([]*Utxo, 0, len(utxos))
+	for _, utxo := range utxos {
+		if txscript.GetScriptClass(utxo.PkScript) != txscript.ProvaTy {
+			return nil, fmt.Errorf("unspent output %v does not pay "+
+				"to a Prova address", utxo.OutPoint)
+		}
+		tx.AddTxIn(wire.NewTxIn(&utxo.OutPoint, nil))
+		selected = append(selected, utxo)
+		total += int64(utxo.Amount)
+
+		fee := int64(EstimateFee(tx, feeRate))
+		if total < target+fee {
+			continue
+		}
+
+		// Add a change output when the change left over after paying
+		// the larger fee of the transaction with the change output is
+		// worth spending.
+		changeIndex := -1
+		if changeScript != nil {
+			changeOut := wire.NewTxOut(0, changeScript)
+			tx.AddTxOut(changeOut)
+			feeWithChange := int64(EstimateFee(tx, feeRate))
+			changeOut.Value = total - target - feeWithChange
+			if changeOut.Value > 0 && !isDust(changeOut, feeRate) {
+				changeIndex = len(tx.TxOut) - 1
+			} else {
+				tx.TxOut = tx.TxOut[:len(tx.TxOut)-1]
+			}
+		}
+
+		authored := &AuthoredTx{
+			Tx:          tx,
+			Inputs:      selected,
+			Fee:         provautil.Amount(total - target),
+			ChangeIndex: changeIndex,
+		}
+		if changeIndex >= 0 {
+			authored.Fee -= provautil.Amount(tx.TxOut[changeIndex].Value)
+		}
+		return authored, nil
+	}
+
+	return nil, ErrInsufficientFunds
+}
+
+// SerializeTx returns the hex-encoded serialized transaction as expected by the
+// sendrawtransaction RPC.
+func SerializeTx(tx *wire.MsgTx) (string, error) {
+	var buf bytes.Buffer
+	buf.Grow(tx.SerializeSize())
+	if err := tx.Serialize(&buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
@@ -0,0 +1,265 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txbuilder
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+// newTestAddress returns a new Prova address controlled by a new user key and
+// the passed ASP key IDs along with the user key.
+func newTestAddress(t *testing.T, keyIDs ...btcec.KeyID) (*provautil.AddressProva, *btcec.PrivateKey) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: %v", err)
+	}
+	pkHash := provautil.Hash160(key.PubKey().SerializeCompressed())
+	addr, err := provautil.NewAddressProva(pkHash, keyIDs,
+		&chaincfg.RegressionNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressProva: %v", err)
+	}
+	return addr, key
+}
+
+// newTestUtxos returns unspent outputs of the passed amounts paying to the
+// passed address.
+func newTestUtxos(t *testing.T, addr provautil.Address, amounts ...provautil.Amount) []*Utxo {
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		t.Fatalf("PayToAddrScript: %v", err)
+	}
+	utxos := make([]*Utxo, 0, len(amounts))
+	for i, amount := range amounts {
+		hash := chainhash.Hash{byte(i + 1)}
+		utxos = append(utxos, &Utxo{
+			OutPoint: *wire.NewOutPoint(&hash, uint32(i)),
+			Amount:   amount,
+			PkScript: pkScript,
+		})
+	}
+	return utxos
+}
+
+// TestBuild ensures unspent outputs are selected in order, fees cover the
+// signed size of the transaction, and change is only added when it is not
+// dust.
+func TestBuild(t *testing.T) {
+	addr, _ := newTestAddress(t, 1, 2)
+	changeAddr, _ := newTestAddress(t, 1, 2)
+	dest, _ := newTestAddress(t, 1, 2)
+	const feeRate = provautil.Amount(1000)
+
+	output, err := NewOutput(dest, 500000)
+	if err != nil {
+		t.Fatalf("NewOutput: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		amounts    []provautil.Amount
+		change     provautil.Address
+		numInputs  int
+		withChange bool
+		err        error
+	}{
+		{
+			name:       "single input with change",
+			amounts:    []provautil.Amount{1000000, 1000000},
+			change:     changeAddr,
+			numInputs:  1,
+			withChange: true,
+		},
+		{
+			name:       "several inputs with change",
+			amounts:    []provautil.Amount{200000, 200000, 300000},
+			change:     changeAddr,
+			numInputs:  3,
+			withChange: true,
+		},
+		{
+			name:      "dust change is left to the fee",
+			amounts:   []provautil.Amount{500400},
+			change:    changeAddr,
+			numInputs: 1,
+		},
+		{
+			name:      "no change address",
+			amounts:   []provautil.Amount{1000000},
+			numInputs: 1,
+		},
+		{
+			name:    "insufficient funds",
+			amounts: []provautil.Amount{200000, 200000},
+			change:  changeAddr,
+			err:     ErrInsufficientFunds,
+		},
+	}
+
+	for _, test := range tests {
+		utxos := newTestUtxos(t, addr, test.amounts...)
+		authored, err := Build([]*wire.TxOut{output}, utxos,
+			test.change, feeRate)
+		if err != test.err {
+			t.Errorf("%s: got error %v, want %v", test.name, err,
+				test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		tx := authored.Tx
+		if len(tx.TxIn) != test.numInputs ||
+			len(authored.Inputs) != test.numInputs {
+
+			t.Errorf("%s: got %d inputs, want %d", test.name,
+				len(tx.TxIn), test.numInputs)
+			continue
+		}
+		if (authored.ChangeIndex >= 0) != test.withChange {
+			t.Errorf("%s: got change index %d, want change %v",
+				test.name, authored.ChangeIndex, test.withChange)
+			continue
+		}
+
+		var in, out int64
+		for _, utxo := range authored.Inputs {
+			in += int64(utxo.Amount)
+		}
+		for _, txOut := range tx.TxOut {
+			out += txOut.Value
+		}
+		if provautil.Amount(in-out) != authored.Fee {
+			t.Errorf("%s: got fee %v, want %v", test.name,
+				authored.Fee, provautil.Amount(in-out))
+		}
+		if minFee := EstimateFee(tx, feeRate); authored.Fee < minFee {
+			t.Errorf("%s: fee %v is below the required %v",
+				test.name, authored.Fee, minFee)
+		}
+	}
+}
+
+// TestBuildRejectsNonProva ensures unspent outputs which do not pay to Prova
+// addresses are rejected since their signed size is unknown.
+func TestBuildRejectsNonProva(t *testing.T) {
+	dest, _ := newTestAddress(t, 1, 2)
+	output, err := NewOutput(dest, 1000)
+	if err != nil {
+		t.Fatalf("NewOutput: %v", err)
+	}
+	utxo := &Utxo{Amount: 100000, PkScript: []byte{txscript.OP_TRUE}}
+	if _, err := Build([]*wire.TxOut{output}, []*Utxo{utxo}, nil,
+		1000); err == nil {
+
+		t.Fatal("Build: spent non-Prova output")
+	}
+}
+
+// TestBuildDataOutput ensures null data outputs are built without paying any
+// amount and that null data outputs paying an amount are rejected.
+func TestBuildDataOutput(t *testing.T) {
+	addr, _ := newTestAddress(t, 1, 2)
+	dest, _ := newTestAddress(t, 1, 2)
+	output, err := NewOutput(dest, 500000)
+	if err != nil {
+		t.Fatalf("NewOutput: %v", err)
+	}
+	anchor := chainhash.DoubleHashB([]byte("anchor"))
+	dataOutput, err := NewDataOutput(anchor)
+	if err != nil {
+		t.Fatalf("NewDataOutput: %v", err)
+	}
+	if class := txscript.GetScriptClass(dataOutput.PkScript); class !=
+		txscript.NullDataTy {
+
+		t.Fatalf("NewDataOutput: got script class %v, want %v", class,
+			txscript.NullDataTy)
+	}
+
+	utxos := newTestUtxos(t, addr, 1000000)
+	authored, err := Build([]*wire.TxOut{output, dataOutput}, utxos, nil,
+		1000)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if len(authored.Tx.TxOut) != 2 || authored.Tx.TxOut[1] != dataOutput {
+		t.Fatalf("Build: data output is missing")
+	}
+	want := provautil.Amount(1000000 - 500000)
+	if authored.Fee != want {
+		t.Fatalf("Build: got fee %v, want %v", authored.Fee, want)
+	}
+
+	// Null data outputs may not pay any amount.
+	paying := wire.NewTxOut(1000, dataOutput.PkScript)
+	if _, err := Build([]*wire.TxOut{paying}, utxos, nil, 1000); err == nil {
+		t.Fatal("Build: built null data output paying an amount")
+	}
+
+	// The data can't exceed the size recognized as null data.
+	oversized := make([]byte, txscript.MaxDataCarrierSize+1)
+	if _, err := NewDataOutput(oversized); err == nil {
+		t.Fatal("NewDataOutput: created oversized data output")
+	}
+}
+
+// TestFeeForSize ensures fees are calculated like the minimum relay fee of the
+// mempool.
+func TestFeeForSize(t *testing.T) {
+	tests := []struct {
+		size    int
+		feeRate provautil.Amount
+		want    provautil.Amount
+	}{
+		{size: 250, feeRate: 1000, want: 250},
+		{size: 1000, feeRate: 1000, want: 1000},
+		{size: 100, feeRate: 5, want: 5},
+		{size: 250, feeRate: 0, want: 0},
+	}
+
+	for _, test := range tests {
+		got := FeeForSize(test.size, test.feeRate)
+		if got != test.want {
+			t.Errorf("FeeForSize(%d, %v): got %v, want %v",
+				test.size, test.feeRate, got, test.want)
+		}
+	}
+}
+
+// TestNewUtxoFromResult ensures RPC results are converted to unspent outputs.
+func TestNewUtxoFromResult(t *testing.T) {
+	result := &btcjson.AddressUtxoResult{
+		Txid:         "0000000000000000000000000000000000000000000000000000000000000001",
+		Vout:         3,
+		ScriptPubKey: "51",
+		Value:        1.5,
+	}
+	utxo, err := NewUtxoFromResult(result)
+	if err != nil {
+		t.Fatalf("NewUtxoFromResult: %v", err)
+	}
+	if utxo.OutPoint.Hash.String() != result.Txid ||
+		utxo.OutPoint.Index != 3 || utxo.Amount != 1500000 ||
+		len(utxo.PkScript) != 1 || utxo.PkScript[0] != txscript.OP_TRUE {
+
+		t.Fatalf("NewUtxoFromResult: unexpected unspent output %+v",
+			utxo)
+	}
+
+	result.ScriptPubKey = "zz"
+	if _, err := NewUtxoFromResult(result); err == nil {
+		t.Fatal("NewUtxoFromResult: accepted invalid script")
+	}
+}
@@ -0,0 +1,690 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// rescanProgressInterval is the minimum interval between the
+	// rescanprogress notifications of a rescan.
+	rescanProgressInterval = 10 * time.Second
+
+	// rescanYieldInterval is the interval at which a rescan checks whether
+	// the block manager has caught up with its queued work.
+	rescanYieldInterval = 100 * time.Millisecond
+
+	// rescanMaxYield is the maximum time a rescan yields to the block
+	// manager after each batch.  It keeps a steady stream of transactions
+	// from starving rescans.
+	rescanMaxYield = 5 * time.Second
+
+	// rescanStatusRunning and rescanStatusInterrupted are the statuses of
+	// rescan jobs as reported by the getrescaninfo RPC.
+	rescanStatusRunning     = "running"
+	rescanStatusInterrupted = "interrupted"
+)
+
+var (
+	// rescanJobsBucketName is the name of the database bucket which houses
+	// the checkpoints of resumable rescans keyed by their job ID.
+	rescanJobsBucketName = []byte("rescanjobs")
+
+	// errRescanAborted is returned to the client of a rescan which was
+	// aborted with the abortrescan RPC.
+	errRescanAborted = &btcjson.RPCError{
+		Code:    btcjson.ErrRPCMisc,
+		Message: "Rescan aborted",
+	}
+)
+
+// rescanCheckpoint houses the state of a rescan as of the end of its last
+// processed batch of blocks.  It is all that is needed to resume the rescan, so
+// the checkpoints of resumable rescans are persisted to the database.
+type rescanCheckpoint struct {
+	Addresses   []string           `json:"addresses"`
+	OutPoints   []btcjson.OutPoint `json:"outpoints"`
+	StartHeight uint32             `json:"startheight"`
+	EndHeight   uint32             `json:"endheight"`
+	FollowTip   bool               `json:"followtip"`
+	NextHeight  uint32             `json:"nextheight"`
+	LastHash    string             `json:"lasthash,omitempty"`
+}
+
+// rescanJob houses a rescan known to the engine, which is either running or
+// waiting to be resumed.
+type rescanJob struct {
+	id         string
+	persistent bool
+	checkpoint rescanCheckpoint
+
+	// These fields describe the current run of the job and are only
+	// meaningful while it is running.
+	running   bool
+	abort     chan struct{}
+	runStart  time.Time
+	runHeight uint32
+}
+
+// rescanEngine runs the rescans requested by websocket clients.  Rescans
+// process blocks in batches and yield to the block manager between batches so
+// they do not hold up the validation of new blocks.  The progress of rescans
+// with a job ID is checkpointed to the database after each batch, so they are
+// resumed from the last checkpoint after the client disconnects or the node
+// restarts.
+type rescanEngine struct {
+	db        database.DB
+	chain     *blockchain.BlockChain
+	queued    func() int
+	batchSize uint32
+	maxRate   int
+
+	mtx    sync.Mutex
+	jobs   map[string]*rescanJob
+	nextID uint64
+}
+
+// newRescanEngine returns a new rescan engine which processes the passed number
+// of blocks per batch and at most maxRate blocks per second, unless maxRate is
+// zero.  The queued function returns the amount of work queued to the block
+// manager, which rescans yield to.  The checkpoints of interrupted rescans are
+// loaded from the database so they are able to be resumed.
+func newRescanEngine(db database.DB, chain *blockchain.BlockChain,
+	queued func() int, batchSize uint32, maxRate int) (*rescanEngine, error) {
+
+	e := &rescanEngine{
+		db:        db,
+		chain:     chain,
+		queued:    queued,
+		batchSize: batchSize,
+		maxRate:   maxRate,
+		jobs:      make(map[string]*rescanJob),
+	}
+	err := db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(rescanJobsBucketName)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var cp rescanCheckpoint
+			if err := json.Unmarshal(v, &cp); err != nil {
+				return fmt.Errorf("corrupt checkpoint of rescan "+
+					"job %s: %v", k, err)
+			}
+			e.jobs[string(k)] = &rescanJob{
+				id:         string(k),
+				persistent: true,
+				checkpoint: cp,
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(e.jobs) > 0 {
+		rpcsLog.Infof("Loaded %d resumable rescan jobs", len(e.jobs))
+	}
+	return e, nil
+}
+
+// beginRun marks the passed job as running.
+//
+// This function MUST be called with the engine lock held.
+func (e *rescanEngine) beginRun(job *rescanJob) {
+	job.running = true
+	job.abort = make(chan struct{})
+	job.runStart = time.Now()
+	job.runHeight = job.checkpoint.NextHeight
+}
+
+// resume starts a new run of the interrupted job with the passed ID.  It
+// returns nil when there is no such job.
+//
+// This function is safe for concurrent access.
+func (e *rescanEngine) resume(id string) (*rescanJob, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	job, ok := e.jobs[id]
+	if !ok {
+		return nil, nil
+	}
+	if job.running {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Rescan job " + id + " is already running",
+		}
+	}
+	e.beginRun(job)
+	return job, nil
+}
+
+// start starts a new job which rescans from the passed checkpoint.  Jobs with
+// an ID are persisted so they are resumable, while jobs without one are
+// assigned an ID for reporting only.
+//
+// This function is safe for concurrent access.
+func (e *rescanEngine) start(id string, cp *rescanCheckpoint) (*rescanJob, error) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	persistent := id != ""
+	if !persistent {
+		for id == "" || e.jobs[id] != nil {
+			e.nextID++
+			id = fmt.Sprintf("rescan-%d", e.nextID)
+		}
+	}
+	if _, ok := e.jobs[id]; ok {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Rescan job " + id + " is already running",
+		}
+	}
+
+	job := &rescanJob{
+		id:         id,
+		persistent: persistent,
+		checkpoint: *cp,
+	}
+	e.jobs[id] = job
+	e.beginRun(job)
+	if err := e.saveCheckpoint(job); err != nil {
+		delete(e.jobs, id)
+		return nil, err
+	}
+	return job, nil
+}
+
+// end ends the current run of the passed job.  The job is forgotten, along with
+// its checkpoint, unless it is resumable and keep is set.
+//
+// This function is safe for concurrent access.
+func (e *rescanEngine) end(job *rescanJob, keep bool) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	job.running = false
+	if (keep && job.persistent) || e.jobs[job.id] != job {
+		return
+	}
+	delete(e.jobs, job.id)
+	if err := e.deleteCheckpoint(job.id); err != nil {
+		rpcsLog.Errorf("Unable to remove checkpoint of rescan job %s: %v",
+			job.id, err)
+	}
+}
+
+// saveCheckpoint persists the checkpoint of the passed job when it is
+// resumable.
+//
+// This function MUST be called with the engine lock held.
+func (e *rescanEngine) saveCheckpoint(job *rescanJob) error {
+	if !job.persistent {
+		return nil
+	}
+	serialized, err := json.Marshal(&job.checkpoint)
+	if err != nil {
+		return err
+	}
+	return e.db.Update(func(dbTx database.Tx) error {
+		bucket, err := dbTx.Metadata().CreateBucketIfNotExists(
+			rescanJobsBucketName)
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(job.id), serialized)
+	})
+}
+
+// deleteCheckpoint removes the persisted checkpoint of the job with the passed
+// ID if there is any.
+//
+// This function MUST be called with the engine lock held.
+func (e *rescanEngine) deleteCheckpoint(id string) error {
+	return e.db.Update(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(rescanJobsBucketName)
+		if bucket == nil || bucket.Get([]byte(id)) == nil {
+			return nil
+		}
+		return bucket.Delete([]byte(id))
+	})
+}
+
+// checkpoint records the passed checkpoint as the progress of the passed job.
+// It has no effect once the job is aborted.
+//
+// This function is safe for concurrent access.
+func (e *rescanEngine) checkpoint(job *rescanJob, cp *rescanCheckpoint) error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	if e.jobs[job.id] != job {
+		return nil
+	}
+	job.checkpoint = *cp
+	return e.saveCheckpoint(job)
+}
+
+// Abort stops the job with the passed ID if it is running and forgets it along
+// with its checkpoint.
+//
+// This function is safe for concurrent access.
+func (e *rescanEngine) Abort(id string) error {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	job, ok := e.jobs[id]
+	if !ok {
+		return &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "No rescan job " + id,
+		}
+	}
+	delete(e.jobs, id)
+	if job.running {
+		close(job.abort)
+	}
+	if !job.persistent {
+		return nil
+	}
+	if err := e.deleteCheckpoint(id); err != nil {
+		return &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDatabase,
+			Message: "Database error: " + err.Error(),
+		}
+	}
+	return nil
+}
+
+// rescanProgress returns the fraction of the passed height range which has
+// been rescanned, the rate of the current run in blocks per second, and the
+// estimated number of seconds until the rescan finishes, or -1 when the rate is
+// unknown.  The range starts at start and ends before end, next is the next
+// height to rescan, and scanned blocks were rescanned in the elapsed time.
+func rescanProgress(start, next, end, scanned uint32,
+	elapsed time.Duration) (float64, float64, int64) {
+
+	progress := 1.0
+	if end > start && next < end {
+		progress = float64(next-start) / float64(end-start)
+	}
+
+	var rate float64
+	if elapsed > 0 {
+		rate = float64(scanned) / elapsed.Seconds()
+	}
+	eta := int64(-1)
+	if next >= end {
+		eta = 0
+	} else if rate > 0 {
+		eta = int64(float64(end-next) / rate)
+	}
+	return progress, rate, eta
+}
+
+// Info returns the progress of all rescan jobs known to the engine ordered by
+// their job ID.
+//
+// This function is safe for concurrent access.
+func (e *rescanEngine) Info() []btcjson.RescanInfoResult {
+	best := e.chain.BestSnapshot()
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	results := make([]btcjson.RescanInfoResult, 0, len(e.jobs))
+	for _, job := range e.jobs {
+		cp := &job.checkpoint
+		end := cp.EndHeight
+		endHeight := int32(end)
+		if cp.FollowTip {
+			end = best.Height + 1
+			endHeight = -1
+		}
+
+		status := rescanStatusInterrupted
+		var scanned uint32
+		var elapsed time.Duration
+		if job.running {
+			status = rescanStatusRunning
+			scanned = cp.NextHeight - job.runHeight
+			elapsed = time.Since(job.runStart)
+		}
+		progress, rate, eta := rescanProgress(cp.StartHeight,
+			cp.NextHeight, end, scanned, elapsed)
+		if !job.running {
+			eta = -1
+		}
+
+		results = append(results, btcjson.RescanInfoResult{
+			JobID:           job.id,
+			Status:          status,
+			Resumable:       job.persistent,
+			Addresses:       len(cp.Addresses),
+			StartHeight:     int32(cp.StartHeight),
+			EndHeight:       endHeight,
+			Height:          int32(cp.NextHeight) - 1,
+			Progress:        progress,
+			BlocksPerSecond: rate,
+			ETA:             eta,
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].JobID < results[j].JobID
+	})
+	return results
+}
+
+// throttle waits after the passed job processed a batch of n blocks, which
+// started at batchStart, until the block manager caught up with its queued work
+// and the rate limit of the engine is met.  It returns false when the job was
+// aborted or the quit channel was closed while waiting.
+func (e *rescanEngine) throttle(job *rescanJob, quit <-chan struct{},
+	batchStart time.Time, n int) bool {
+
+	wait := func(d time.Duration) bool {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			return true
+		case <-job.abort:
+		case <-quit:
+		}
+		return false
+	}
+
+	yieldStart := time.Now()
+	for e.queued() > 0 && time.Since(yieldStart) < rescanMaxYield {
+		if !wait(rescanYieldInterval) {
+			return false
+		}
+	}
+
+	if e.maxRate > 0 {
+		minDuration := time.Duration(n) * time.Second /
+			time.Duration(e.maxRate)
+		if d := minDuration - time.Since(batchStart); d > 0 {
+			return wait(d)
+		}
+	}
+	return true
+}
+
+// run runs the passed job on behalf of the passed websocket client until it
+// finishes, fails, or is interrupted by the client disconnecting, in which case
+// interrupted is returned as true.
+func (e *rescanEngine) run(wsc *wsClient, job *rescanJob) (interrupted bool, err error) {
+	chain := e.chain
+	cp := job.checkpoint
+
+	lookups := rescanKeys{
+		fallbacks: make(map[string]struct{}, len(cp.Addresses)),
+		unspent:   make(map[wire.OutPoint]struct{}, len(cp.OutPoints)),
+	}
+	for _, addrStr := range cp.Addresses {
+		lookups.fallbacks[addrStr] = struct{}{}
+	}
+	for i := range cp.OutPoints {
+		hash, err := chainhash.NewHashFromStr(cp.OutPoints[i].Hash)
+		if err != nil {
+			return false, rpcDecodeHexError(cp.OutPoints[i].Hash)
+		}
+		lookups.unspent[*wire.NewOutPoint(hash, cp.OutPoints[i].Index)] =
+			struct{}{}
+	}
+
+	// lastBlock and lastBlockHash track the previously-rescanned block.
+	// They equal nil when no previous blocks have been rescanned.  A
+	// resumed rescan continues from the last block of its checkpoint, which
+	// must still be in the main chain.
+	var lastBlock *provautil.Block
+	var lastBlockHash *chainhash.Hash
+	if cp.LastHash != "" {
+		hash, err := chainhash.NewHashFromStr(cp.LastHash)
+		if err != nil {
+			return false, rpcDecodeHexError(cp.LastHash)
+		}
+		inMainChain, err := chain.MainChainHasBlock(hash)
+		if err == nil && inMainChain {
+			lastBlock, err = chain.BlockByHash(hash)
+		}
+		if err != nil {
+			return false, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCDatabase,
+				Message: "Database error: " + err.Error(),
+			}
+		}
+		if !inMainChain {
+			rpcsLog.Errorf("Stopping rescan job %s for reorged "+
+				"block %v", job.id, hash)
+			return false, &ErrRescanReorg
+		}
+		lastBlock.SetHeight(cp.NextHeight - 1)
+		lastBlockHash = hash
+	}
+
+	ticker := time.NewTicker(rescanProgressInterval)
+	defer ticker.Stop()
+
+	for cp.FollowTip || cp.NextHeight < cp.EndHeight {
+		batchStart := time.Now()
+		batchEnd := cp.NextHeight + e.batchSize
+		if batchEnd < cp.NextHeight {
+			batchEnd = ^uint32(0)
+		}
+		if !cp.FollowTip && batchEnd > cp.EndHeight {
+			batchEnd = cp.EndHeight
+		}
+		hashList, err := chain.HeightRange(cp.NextHeight, batchEnd)
+		if err != nil {
+			rpcsLog.Errorf("Error looking up block range: %v", err)
+			return false, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCDatabase,
+				Message: "Database error: " + err.Error(),
+			}
+		}
+		if len(hashList) == 0 {
+			// The rescan is finished if no blocks hashes for this
+			// range were successfully fetched and a stop block
+			// was provided.
+			if !cp.FollowTip {
+				break
+			}
+
+			// If the rescan is through the current block, set up
+			// the client to continue to receive notifications
+			// regarding all rescanned addresses and the current set
+			// of unspent outputs.
+			//
+			// This is done safely by temporarily grabbing exclusive
+			// access of the block manager.  If no more blocks have
+			// been attached between this pause and the fetch above,
+			// then it is safe to register the websocket client for
+			// continuous notifications if necessary.  Otherwise,
+			// continue the fetch loop again to rescan the new
+			// blocks (or error due to an irrecoverable reorganize).
+			blockManager := wsc.server.server.blockManager
+			pauseGuard := blockManager.Pause()
+			best := blockManager.chain.BestSnapshot()
+			caughtUp := lastBlockHash == nil || *lastBlockHash == *best.Hash
+			if caughtUp {
+				n := wsc.server.ntfnMgr
+				n.RegisterSpentRequests(wsc, lookups.unspentSlice())
+				n.RegisterTxOutAddressRequests(wsc, cp.Addresses)
+			}
+			close(pauseGuard)
+			if caughtUp {
+				break
+			}
+
+			// The main chain no longer extends the last rescanned
+			// block when it was reorganized to a shorter chain.
+			inMainChain, err := chain.MainChainHasBlock(lastBlockHash)
+			if err != nil {
+				return false, &btcjson.RPCError{
+					Code: btcjson.ErrRPCDatabase,
+					Message: "Database error: " +
+						err.Error(),
+				}
+			}
+			if !inMainChain {
+				rpcsLog.Errorf("Stopping rescan job %s for "+
+					"reorged block %v", job.id, lastBlockHash)
+				return false, &ErrRescanReorg
+			}
+			continue
+		}
+
+		var scanned int
+		for i := range hashList {
+			blk, err := chain.BlockByHash(&hashList[i])
+			if err != nil {
+				// Only handle reorgs if a block could not be
+				// found for the hash.
+				if dbErr, ok := err.(database.Error); !ok ||
+					dbErr.ErrorCode != database.ErrBlockNotFound {
+
+					rpcsLog.Errorf("Error looking up "+
+						"block: %v", err)
+					return false, &btcjson.RPCError{
+						Code: btcjson.ErrRPCDatabase,
+						Message: "Database error: " +
+							err.Error(),
+					}
+				}
+
+				// If an absolute max block was specified, don't
+				// attempt to handle the reorg.
+				if !cp.FollowTip {
+					rpcsLog.Errorf("Stopping rescan job %s "+
+						"for reorged block %v", job.id,
+						hashList[i])
+					return false, &ErrRescanReorg
+				}
+
+				// Otherwise fetch a new range of block hashes
+				// from the current height, which are verified
+				// to extend the previously processed block.
+				break
+			}
+			if lastBlockHash != nil {
+				// Ensure the block is on the same fork as the
+				// previously processed block.
+				jsonErr := descendantBlock(lastBlockHash, blk)
+				if jsonErr != nil {
+					return false, jsonErr
+				}
+			}
+			blk.SetHeight(cp.NextHeight)
+
+			// A select statement is used to stop rescans if the
+			// client requesting the rescan has disconnected or the
+			// rescan was aborted.
+			select {
+			case <-wsc.quit:
+				rpcsLog.Debugf("Stopped rescan job %s at height "+
+					"%v for disconnected client", job.id,
+					blk.Height())
+				return true, nil
+			case <-job.abort:
+				return false, errRescanAborted
+			default:
+				rescanBlock(wsc, &lookups, blk)
+				lastBlock = blk
+				lastBlockHash = blk.Hash()
+				cp.NextHeight++
+				scanned++
+			}
+
+			// Periodically notify the client of the progress
+			// completed.  Continue with next block if no progress
+			// notification is needed yet.
+			select {
+			case <-ticker.C: // fallthrough
+			default:
+				continue
+			}
+
+			n := btcjson.NewRescanProgressNtfn(hashList[i].String(),
+				int32(blk.Height()), blk.MsgBlock().Header.Timestamp.Unix())
+			mn, err := btcjson.MarshalCmd(nil, n)
+			if err != nil {
+				rpcsLog.Errorf("Failed to marshal rescan "+
+					"progress notification: %v", err)
+				continue
+			}
+
+			if err = wsc.QueueNotification(mn); err == ErrClientQuit {
+				// Finished if the client disconnected.
+				rpcsLog.Debugf("Stopped rescan job %s at "+
+					"height %v for disconnected client",
+					job.id, blk.Height())
+				return true, nil
+			}
+		}
+		if scanned == 0 {
+			continue
+		}
+
+		// Checkpoint the progress so the rescan is able to resume
+		// from here.  A failure to do so only costs resumability, so
+		// the rescan carries on.
+		cp.LastHash = lastBlockHash.String()
+		cp.OutPoints = lookups.unspentOutPoints()
+		if err := e.checkpoint(job, &cp); err != nil {
+			rpcsLog.Errorf("Unable to checkpoint rescan job %s: %v",
+				job.id, err)
+		}
+
+		if !e.throttle(job, wsc.quit, batchStart, scanned) {
+			select {
+			case <-job.abort:
+				return false, errRescanAborted
+			default:
+				return true, nil
+			}
+		}
+	}
+
+	// Notify websocket client of the finished rescan.  Due to how btcd
+	// asynchronously queues notifications to not block calling code,
+	// there is no guarantee that any of the notifications created during
+	// rescan (such as rescanprogress, recvtx and redeemingtx) will be
+	// received before the rescan RPC returns.  Therefore, another method
+	// is needed to safely inform clients that all rescan notifications have
+	// been sent.
+	if lastBlock != nil {
+		n := btcjson.NewRescanFinishedNtfn(lastBlockHash.String(),
+			int32(lastBlock.Height()),
+			lastBlock.MsgBlock().Header.Timestamp.Unix())
+		if mn, err := btcjson.MarshalCmd(nil, n); err != nil {
+			rpcsLog.Errorf("Failed to marshal rescan finished "+
+				"notification: %v", err)
+		} else {
+			// The rescan is finished, so we don't care whether the
+			// client has disconnected at this point, so discard
+			// error.
+			_ = wsc.QueueNotification(mn)
+		}
+	}
+
+	rpcsLog.Infof("Finished rescan job %s", job.id)
+	return false, nil
+}
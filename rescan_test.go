@@ -0,0 +1,215 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/database"
+	_ "github.com/bitgo/prova/database/ffldb"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestRescanProgress ensures the progress, rate, and estimated time to finish
+// of rescans are calculated properly.
+func TestRescanProgress(t *testing.T) {
+	tests := []struct {
+		name             string
+		start, next, end uint32
+		scanned          uint32
+		elapsed          time.Duration
+		progress, rate   float64
+		eta              int64
+	}{
+		{
+			name:     "not started",
+			start:    100,
+			next:     100,
+			end:      300,
+			progress: 0,
+			rate:     0,
+			eta:      -1,
+		},
+		{
+			name:     "halfway",
+			start:    100,
+			next:     200,
+			end:      300,
+			scanned:  100,
+			elapsed:  10 * time.Second,
+			progress: 0.5,
+			rate:     10,
+			eta:      10,
+		},
+		{
+			name:     "resumed",
+			start:    0,
+			next:     150,
+			end:      200,
+			scanned:  50,
+			elapsed:  25 * time.Second,
+			progress: 0.75,
+			rate:     2,
+			eta:      25,
+		},
+		{
+			name:     "finished",
+			start:    0,
+			next:     200,
+			end:      200,
+			scanned:  200,
+			elapsed:  100 * time.Second,
+			progress: 1,
+			rate:     2,
+			eta:      0,
+		},
+		{
+			name:     "empty range",
+			start:    50,
+			next:     50,
+			end:      50,
+			progress: 1,
+			eta:      0,
+		},
+	}
+
+	for _, test := range tests {
+		progress, rate, eta := rescanProgress(test.start, test.next,
+			test.end, test.scanned, test.elapsed)
+		if progress != test.progress || rate != test.rate ||
+			eta != test.eta {
+
+			t.Errorf("%s: got progress %v, rate %v, eta %v - want "+
+				"%v, %v, %v", test.name, progress, rate, eta,
+				test.progress, test.rate, test.eta)
+		}
+	}
+}
+
+// TestRescanEngineCheckpoints ensures the checkpoints of rescans with a job ID
+// survive a restart, resumed jobs continue from them, and aborted or finished
+// jobs are forgotten.
+func TestRescanEngineCheckpoints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rescantest")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	db, err := database.Create("ffldb", filepath.Join(dir, "db"),
+		wire.MainNet)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer db.Close()
+
+	queued := func() int { return 0 }
+	engine, err := newRescanEngine(db, nil, queued, 10, 0)
+	if err != nil {
+		t.Fatalf("newRescanEngine: %v", err)
+	}
+
+	cp := &rescanCheckpoint{
+		Addresses:   []string{"addr"},
+		StartHeight: 5,
+		NextHeight:  5,
+		FollowTip:   true,
+	}
+	job, err := engine.start("job1", cp)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if _, err := engine.start("job1", cp); err == nil {
+		t.Fatal("start: started job which is already running")
+	}
+	if _, err := engine.resume("job1"); err == nil {
+		t.Fatal("resume: resumed job which is already running")
+	}
+
+	// Jobs without an ID are assigned one and are not persisted.
+	ephemeral, err := engine.start("", cp)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if ephemeral.id == "" || ephemeral.persistent {
+		t.Fatalf("start: unexpected ephemeral job %q (persistent %v)",
+			ephemeral.id, ephemeral.persistent)
+	}
+
+	progressed := *cp
+	progressed.NextHeight = 15
+	progressed.LastHash = "0000000000000000000000000000000000000000000000000000000000000001"
+	progressed.OutPoints = []btcjson.OutPoint{{Hash: progressed.LastHash, Index: 1}}
+	if err := engine.checkpoint(job, &progressed); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	engine.end(job, true)
+	engine.end(ephemeral, true)
+
+	// Only the interrupted job with an ID is loaded after a restart, and
+	// it resumes from its last checkpoint.
+	engine, err = newRescanEngine(db, nil, queued, 10, 0)
+	if err != nil {
+		t.Fatalf("newRescanEngine: %v", err)
+	}
+	if len(engine.jobs) != 1 {
+		t.Fatalf("newRescanEngine: got %d jobs, want 1", len(engine.jobs))
+	}
+	job, err = engine.resume("job1")
+	if err != nil || job == nil {
+		t.Fatalf("resume: job %v, err %v", job, err)
+	}
+	if !reflect.DeepEqual(job.checkpoint, progressed) {
+		t.Fatalf("resume: got checkpoint %+v, want %+v",
+			job.checkpoint, progressed)
+	}
+	if job.runHeight != progressed.NextHeight {
+		t.Fatalf("resume: got run height %d, want %d", job.runHeight,
+			progressed.NextHeight)
+	}
+
+	// Aborting a running job signals it and removes its checkpoint.
+	if err := engine.Abort("job1"); err != nil {
+		t.Fatalf("Abort: %v", err)
+	}
+	select {
+	case <-job.abort:
+	default:
+		t.Fatal("Abort: running job was not signalled")
+	}
+	if err := engine.Abort("job1"); err == nil {
+		t.Fatal("Abort: aborted unknown job")
+	}
+	engine.end(job, true)
+
+	engine, err = newRescanEngine(db, nil, queued, 10, 0)
+	if err != nil {
+		t.Fatalf("newRescanEngine: %v", err)
+	}
+	if len(engine.jobs) != 0 {
+		t.Fatalf("newRescanEngine: got %d jobs after abort, want 0",
+			len(engine.jobs))
+	}
+
+	// Finished jobs are forgotten along with their checkpoint.
+	job, err = engine.start("job2", cp)
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	engine.end(job, false)
+	engine, err = newRescanEngine(db, nil, queued, 10, 0)
+	if err != nil {
+		t.Fatalf("newRescanEngine: %v", err)
+	}
+	if len(engine.jobs) != 0 {
+		t.Fatalf("newRescanEngine: got %d jobs after finishing, want 0",
+			len(engine.jobs))
+	}
+}
@@ -0,0 +1,341 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+)
+
+const (
+	// restPathPrefix is the path all REST endpoints are served under.
+	restPathPrefix = "/rest/"
+
+	// restMaxHeaders is the maximum number of headers returned by a single
+	// request to the headers endpoint.
+	restMaxHeaders = 2000
+)
+
+// restFormat identifies the format of the response to a REST request.
+type restFormat int
+
+// These constants define the formats of REST responses, which are selected by
+// the extension of the last path component of the request.
+const (
+	restFormatBinary restFormat = iota
+	restFormatHex
+	restFormatJSON
+)
+
+// restFormats maps the extensions of REST requests to their response formats.
+var restFormats = map[string]restFormat{
+	"bin":  restFormatBinary,
+	"hex":  restFormatHex,
+	"json": restFormatJSON,
+}
+
+// restChainInfoResult houses the state of the chain as returned by the
+// chaininfo endpoint.
+type restChainInfoResult struct {
+	Chain         string  `json:"chain"`
+	Blocks        uint32  `json:"blocks"`
+	BestBlockHash string  `json:"bestblockhash"`
+	Difficulty    float64 `json:"difficulty"`
+	MedianTime    int64   `json:"mediantime"`
+}
+
+// restError is an error which is reported to REST clients with its status
+// code.
+type restError struct {
+	status  int
+	message string
+}
+
+// Error satisfies the error interface.
+func (e *restError) Error() string {
+	return e.message
+}
+
+// newRESTError returns a REST error with the passed status code and message
+// formatted according to the passed format specifier and arguments.
+func newRESTError(status int, format string, a ...interface{}) *restError {
+	return &restError{status: status, message: fmt.Sprintf(format, a...)}
+}
+
+// parseRESTParam splits the passed last path component of a REST request into
+// its parameter and response format.
+func parseRESTParam(component string) (string, restFormat, error) {
+	dot := strings.LastIndex(component, ".")
+	if dot < 0 {
+		return "", 0, newRESTError(http.StatusBadRequest,
+			"output format not found (available: .bin, .hex, .json)")
+	}
+	format, ok := restFormats[component[dot+1:]]
+	if !ok {
+		return "", 0, newRESTError(http.StatusBadRequest,
+			"output format not found (available: .bin, .hex, .json)")
+	}
+	return component[:dot], format, nil
+}
+
+// parseRESTHash parses the passed hash of a REST request.
+func parseRESTHash(str string) (*chainhash.Hash, error) {
+	hash, err := chainhash.NewHashFromStr(str)
+	if err != nil || len(str) != chainhash.MaxHashStringSize {
+		return nil, newRESTError(http.StatusBadRequest,
+			"invalid hash: %s", str)
+	}
+	return hash, nil
+}
+
+// restRPCError converts the passed error returned by an RPC handler into a REST
+// error.
+func restRPCError(err error) error {
+	rpcErr, ok := err.(*btcjson.RPCError)
+	if !ok {
+		return err
+	}
+	switch rpcErr.Code {
+	case btcjson.ErrRPCBlockNotFound: // Same code as ErrRPCNoTxInfo.
+		return newRESTError(http.StatusNotFound, "%s", rpcErr.Message)
+	case btcjson.ErrRPCDecodeHexString, btcjson.ErrRPCInvalidParameter:
+		return newRESTError(http.StatusBadRequest, "%s", rpcErr.Message)
+	}
+	return newRESTError(http.StatusInternalServerError, "%s",
+		rpcErr.Message)
+}
+
+// writeREST writes the passed serialized data to the client in the passed
+// format.  Data in the JSON format is marshalled first.
+func writeREST(w http.ResponseWriter, format restFormat, data interface{}) error {
+	switch format {
+	case restFormatBinary:
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, err := w.Write(data.([]byte))
+		return err
+
+	case restFormatHex:
+		w.Header().Set("Content-Type", "text/plain")
+		_, err := fmt.Fprintf(w, "%x\n", data.([]byte))
+		return err
+	}
+
+	body, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(append(body, '\n'))
+	return err
+}
+
+// restBlock serves the block endpoints:
+//
+//	/rest/block/<hash>.<bin|hex|json>
+//	/rest/block/notxdetails/<hash>.<bin|hex|json>
+//
+// The JSON format matches the result of the getblock RPC, with the details of
+// the transactions unless notxdetails is requested.
+func (s *rpcServer) restBlock(w http.ResponseWriter, path string) error {
+	txDetails := true
+	if strings.HasPrefix(path, "notxdetails/") {
+		txDetails = false
+		path = strings.TrimPrefix(path, "notxdetails/")
+	}
+	param, format, err := parseRESTParam(path)
+	if err != nil {
+		return err
+	}
+	if _, err := parseRESTHash(param); err != nil {
+		return err
+	}
+
+	verbose := format == restFormatJSON
+	result, err := handleGetBlock(s, &btcjson.GetBlockCmd{
+		Hash:      param,
+		Verbose:   &verbose,
+		VerboseTx: &txDetails,
+	}, nil)
+	if err != nil {
+		return restRPCError(err)
+	}
+	if verbose {
+		return writeREST(w, format, result)
+	}
+	serialized, err := hex.DecodeString(result.(string))
+	if err != nil {
+		return err
+	}
+	return writeREST(w, format, serialized)
+}
+
+// restTx serves the transaction endpoint:
+//
+//	/rest/tx/<txid>.<bin|hex|json>
+//
+// The JSON format matches the verbose result of the getrawtransaction RPC.
+// Transactions which are not in the memory pool are only found when the
+// transaction index is enabled.
+func (s *rpcServer) restTx(w http.ResponseWriter, path string) error {
+	param, format, err := parseRESTParam(path)
+	if err != nil {
+		return err
+	}
+	if _, err := parseRESTHash(param); err != nil {
+		return err
+	}
+
+	var verbose int
+	if format == restFormatJSON {
+		verbose = 1
+	}
+	result, err := handleGetRawTransaction(s, &btcjson.GetRawTransactionCmd{
+		Txid:    param,
+		Verbose: &verbose,
+	}, nil)
+	if err != nil {
+		return restRPCError(err)
+	}
+	if verbose != 0 {
+		return writeREST(w, format, result)
+	}
+	serialized, err := hex.DecodeString(result.(string))
+	if err != nil {
+		return err
+	}
+	return writeREST(w, format, serialized)
+}
+
+// restHeaders serves the headers endpoint:
+//
+//	/rest/headers/<count>/<hash>.<bin|hex|json>
+//
+// It returns up to count headers of the main chain starting with the passed
+// block.  The JSON format is an array of the results of the getblockheader
+// RPC.
+func (s *rpcServer) restHeaders(w http.ResponseWriter, path string) error {
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 {
+		return newRESTError(http.StatusBadRequest,
+			"usage: /rest/headers/<count>/<hash>.<bin|hex|json>")
+	}
+	count, err := strconv.Atoi(parts[0])
+	if err != nil || count < 1 || count > restMaxHeaders {
+		return newRESTError(http.StatusBadRequest,
+			"header count out of range: %s", parts[0])
+	}
+	param, format, err := parseRESTParam(parts[1])
+	if err != nil {
+		return err
+	}
+	hash, err := parseRESTHash(param)
+	if err != nil {
+		return err
+	}
+
+	// Blocks which are not in the main chain are not found.
+	height, err := s.chain.BlockHeightByHash(hash)
+	if err != nil {
+		return newRESTError(http.StatusNotFound, "%s not found", param)
+	}
+	hashes, err := s.chain.HeightRange(height, height+uint32(count))
+	if err != nil {
+		return err
+	}
+
+	if format == restFormatJSON {
+		verbose := true
+		results := make([]interface{}, 0, len(hashes))
+		for i := range hashes {
+			result, err := handleGetBlockHeader(s,
+				&btcjson.GetBlockHeaderCmd{
+					Hash:    hashes[i].String(),
+					Verbose: &verbose,
+				}, nil)
+			if err != nil {
+				return restRPCError(err)
+			}
+			results = append(results, result)
+		}
+		return writeREST(w, format, results)
+	}
+
+	var buf bytes.Buffer
+	for i := range hashes {
+		header, err := s.chain.FetchHeader(&hashes[i])
+		if err != nil {
+			return err
+		}
+		if err := header.Serialize(&buf); err != nil {
+			return err
+		}
+	}
+	return writeREST(w, format, buf.Bytes())
+}
+
+// restChainInfo serves the chain info endpoint:
+//
+//	/rest/chaininfo.json
+func (s *rpcServer) restChainInfo(w http.ResponseWriter, path string) error {
+	if path != "chaininfo.json" {
+		return newRESTError(http.StatusBadRequest,
+			"output format not found (available: .json)")
+	}
+
+	best := s.chain.BestSnapshot()
+	return writeREST(w, restFormatJSON, &restChainInfoResult{
+		Chain:         s.server.chainParams.Name,
+		Blocks:        best.Height,
+		BestBlockHash: best.Hash.String(),
+		Difficulty:    getDifficultyRatio(best.Bits),
+		MedianTime:    best.MedianTime.Unix(),
+	})
+}
+
+// serveREST responds to the passed REST request.  The response is written in
+// the format selected by the extension of the request path, while errors are
+// written as plain text along with the matching status code.
+func (s *rpcServer) serveREST(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 Method not allowed.",
+			http.StatusMethodNotAllowed)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, restPathPrefix)
+	var err error
+	switch {
+	case strings.HasPrefix(path, "block/"):
+		err = s.restBlock(w, strings.TrimPrefix(path, "block/"))
+	case strings.HasPrefix(path, "tx/"):
+		err = s.restTx(w, strings.TrimPrefix(path, "tx/"))
+	case strings.HasPrefix(path, "headers/"):
+		err = s.restHeaders(w, strings.TrimPrefix(path, "headers/"))
+	case strings.HasPrefix(path, "chaininfo"):
+		err = s.restChainInfo(w, path)
+	default:
+		err = newRESTError(http.StatusNotFound, "unknown REST endpoint")
+	}
+	if err == nil {
+		return
+	}
+
+	if restErr, ok := err.(*restError); ok {
+		http.Error(w, restErr.message, restErr.status)
+		return
+	}
+	rpcsLog.Errorf("Failed to serve REST request %s: %v", r.URL.Path, err)
+	http.Error(w, "500 Internal server error.",
+		http.StatusInternalServerError)
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestParseRESTParam ensures the last path component of REST requests is split
+// into the parameter and the format selected by its extension.
+func TestParseRESTParam(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		component  string
+		wantParam  string
+		wantFormat restFormat
+		wantErr    bool
+	}{
+		{component: "abc.bin", wantParam: "abc", wantFormat: restFormatBinary},
+		{component: "abc.hex", wantParam: "abc", wantFormat: restFormatHex},
+		{component: "abc.json", wantParam: "abc", wantFormat: restFormatJSON},
+		{component: "a.b.json", wantParam: "a.b", wantFormat: restFormatJSON},
+		{component: "abc", wantErr: true},
+		{component: "abc.xml", wantErr: true},
+		{component: "abc.", wantErr: true},
+	}
+
+	for _, test := range tests {
+		param, format, err := parseRESTParam(test.component)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("parseRESTParam(%q): no error",
+					test.component)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseRESTParam(%q): unexpected error: %v",
+				test.component, err)
+			continue
+		}
+		if param != test.wantParam || format != test.wantFormat {
+			t.Errorf("parseRESTParam(%q): got %q and format %d, "+
+				"want %q and format %d", test.component, param,
+				format, test.wantParam, test.wantFormat)
+		}
+	}
+}
+
+// TestServeRESTErrors ensures malformed REST requests are rejected with the
+// matching status code.
+func TestServeRESTErrors(t *testing.T) {
+	t.Parallel()
+
+	hash := strings.Repeat("ab", 32)
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{
+			name:       "wrong method",
+			method:     "POST",
+			path:       "/rest/chaininfo.json",
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+		{
+			name:       "unknown endpoint",
+			method:     "GET",
+			path:       "/rest/utxos/" + hash + ".json",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "unknown block format",
+			method:     "GET",
+			path:       "/rest/block/" + hash + ".xml",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "short block hash",
+			method:     "GET",
+			path:       "/rest/block/abcd.json",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid tx hash",
+			method:     "GET",
+			path:       "/rest/tx/" + strings.Repeat("zz", 32) + ".hex",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "missing header count",
+			method:     "GET",
+			path:       "/rest/headers/" + hash + ".bin",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "header count out of range",
+			method:     "GET",
+			path:       "/rest/headers/2001/" + hash + ".bin",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "chain info not in json",
+			method:     "GET",
+			path:       "/rest/chaininfo.hex",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	s := &rpcServer{}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, nil)
+		w := httptest.NewRecorder()
+		s.serveREST(w, r)
+		if w.Code != test.wantStatus {
+			t.Errorf("%s: got status %d, want %d", test.name,
+				w.Code, test.wantStatus)
+		}
+	}
+}
@@ -8,6 +8,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"crypto/subtle"
 	"crypto/tls"
@@ -17,24 +18,34 @@ import (
 	"errors"
 	"fmt"
 	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/blockchain/indexers"
 	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/btcjson"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/connmgr"
 	"github.com/bitgo/prova/database"
 	"github.com/bitgo/prova/mempool"
 	"github.com/bitgo/prova/mining"
+	"github.com/bitgo/prova/provasign"
 	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/provautil/adminutil"
+	"github.com/bitgo/prova/provautil/bloom"
+	"github.com/bitgo/prova/provautil/txbuilder"
 	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
 	"github.com/btcsuite/websocket"
 	"io"
 	"io/ioutil"
+	"math"
 	"math/big"
 	"math/rand"
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -65,6 +76,16 @@ const (
 
 	// maxProtocolVersion is the max protocol version the server supports.
 	maxProtocolVersion = 70002
+
+	// maxCheckpointCandidates is the maximum number of candidates the
+	// getcheckpointcandidates RPC returns, which bounds the portion of the
+	// main chain it scans while holding the chain lock.
+	maxCheckpointCandidates = 20
+
+	// maxBlockStatsRange is the maximum number of blocks the getblockstats
+	// RPC returns the stats of, since the data and spent outputs of each
+	// block are loaded from the database.
+	maxBlockStatsRange = 1000
 )
 
 var (
@@ -117,47 +138,112 @@ type commandHandler func(*rpcServer, interface{}, <-chan struct{}) (interface{},
 // a dependency loop.
 var rpcHandlers map[string]commandHandler
 var rpcHandlersBeforeInit = map[string]commandHandler{
-	"addnode":               handleAddNode,
-	"createrawtransaction":  handleCreateRawTransaction,
-	"debuglevel":            handleDebugLevel,
-	"decoderawtransaction":  handleDecodeRawTransaction,
-	"generate":              handleGenerate,
-	"getaddednodeinfo":      handleGetAddedNodeInfo,
-	"getaddresstxids":       handleGetAddressTxIds,
-	"getadmininfo":          handleGetAdminInfo,
-	"getbestblock":          handleGetBestBlock,
-	"getbestblockhash":      handleGetBestBlockHash,
-	"getblock":              handleGetBlock,
-	"getblockcount":         handleGetBlockCount,
-	"getblockhash":          handleGetBlockHash,
-	"getblockheader":        handleGetBlockHeader,
-	"getblocktemplate":      handleGetBlockTemplate,
-	"getconnectioncount":    handleGetConnectionCount,
-	"getcurrentnet":         handleGetCurrentNet,
-	"getdifficulty":         handleGetDifficulty,
-	"getgenerate":           handleGetGenerate,
-	"gethashespersec":       handleGetHashesPerSec,
-	"getheaders":            handleGetHeaders,
-	"getinfo":               handleGetInfo,
-	"getmempoolinfo":        handleGetMempoolInfo,
-	"getmininginfo":         handleGetMiningInfo,
-	"getnettotals":          handleGetNetTotals,
-	"getnetworkhashps":      handleGetNetworkHashPS,
-	"getpeerinfo":           handleGetPeerInfo,
-	"getrawmempool":         handleGetRawMempool,
-	"getrawtransaction":     handleGetRawTransaction,
-	"gettxout":              handleGetTxOut,
-	"help":                  handleHelp,
-	"node":                  handleNode,
-	"ping":                  handlePing,
-	"searchrawtransactions": handleSearchRawTransactions,
-	"sendrawtransaction":    handleSendRawTransaction,
-	"setgenerate":           handleSetGenerate,
-	"setvalidatekeys":       handleSetValidateKeys,
-	"stop":                  handleStop,
-	"submitblock":           handleSubmitBlock,
-	"validateaddress":       handleValidateAddress,
-	"verifychain":           handleVerifyChain,
+	"abortrescan":                    handleAbortRescan,
+	"addnode":                        handleAddNode,
+	"backupchainstate":               handleBackupChainState,
+	"checkindex":                     handleCheckIndex,
+	"clearbanned":                    handleClearBanned,
+	"combinepspt":                    handleCombinePSPT,
+	"createpspt":                     handleCreatePSPT,
+	"createrawadmintransaction":      handleCreateRawAdminTransaction,
+	"createrawtransaction":           handleCreateRawTransaction,
+	"debuglevel":                     handleDebugLevel,
+	"debugscript":                    handleDebugScript,
+	"decodeadmintransaction":         handleDecodeAdminTransaction,
+	"decodepspt":                     handleDecodePSPT,
+	"decoderawtransaction":           handleDecodeRawTransaction,
+	"decodescript":                   handleDecodeScript,
+	"dropindex":                      handleDropIndex,
+	"dumputxosnapshot":               handleDumpUTXOSnapshot,
+	"estimatefee":                    handleEstimateFee,
+	"estimatesmartfee":               handleEstimateSmartFee,
+	"finalizepspt":                   handleFinalizePSPT,
+	"generate":                       handleGenerate,
+	"generatetoaddress":              handleGenerateToAddress,
+	"getaddednodeinfo":               handleGetAddedNodeInfo,
+	"getaddressbalance":              handleGetAddressBalance,
+	"getaddressissuance":             handleGetAddressIssuance,
+	"getaddresstxids":                handleGetAddressTxIds,
+	"getaddressutxos":                handleGetAddressUtxos,
+	"getadmininfo":                   handleGetAdminInfo,
+	"getbestblock":                   handleGetBestBlock,
+	"getbestblockhash":               handleGetBestBlockHash,
+	"getblock":                       handleGetBlock,
+	"getblockchaininfo":              handleGetBlockChainInfo,
+	"getblockcount":                  handleGetBlockCount,
+	"getblockhash":                   handleGetBlockHash,
+	"getblockhashbytime":             handleGetBlockHashByTime,
+	"getblockheader":                 handleGetBlockHeader,
+	"getblockscrubinfo":              handleGetBlockScrubInfo,
+	"getblockstats":                  handleGetBlockStats,
+	"getblocktemplate":               handleGetBlockTemplate,
+	"getcfheaders":                   handleGetCFHeaders,
+	"getcfilter":                     handleGetCFilter,
+	"getchaintips":                   handleGetChainTips,
+	"getcheckpointcandidates":        handleGetCheckpointCandidates,
+	"getconnectioncount":             handleGetConnectionCount,
+	"getcurrentnet":                  handleGetCurrentNet,
+	"getdifficulty":                  handleGetDifficulty,
+	"getgenerate":                    handleGetGenerate,
+	"gethashespersec":                handleGetHashesPerSec,
+	"getheaders":                     handleGetHeaders,
+	"getindexinfo":                   handleGetIndexInfo,
+	"getinfo":                        handleGetInfo,
+	"getissuanceinfo":                handleGetIssuanceInfo,
+	"getkeyidactivity":               handleGetKeyIDActivity,
+	"getkeyidinfo":                   handleGetKeyIDInfo,
+	"getmempoolancestors":            handleGetMempoolAncestors,
+	"getmempooldescendants":          handleGetMempoolDescendants,
+	"getmempoolentry":                handleGetMempoolEntry,
+	"getmempoolinfo":                 handleGetMempoolInfo,
+	"getmininginfo":                  handleGetMiningInfo,
+	"getnettotals":                   handleGetNetTotals,
+	"getnetworkhashps":               handleGetNetworkHashPS,
+	"getnetworkinfo":                 handleGetNetworkInfo,
+	"getpeerinfo":                    handleGetPeerInfo,
+	"getrawmempool":                  handleGetRawMempool,
+	"getrawtransaction":              handleGetRawTransaction,
+	"getreceivedbyaddress":           handleGetReceivedByAddress,
+	"getrescaninfo":                  handleGetRescanInfo,
+	"getspentinfo":                   handleGetSpentInfo,
+	"gettxout":                       handleGetTxOut,
+	"gettxoutproof":                  handleGetTxOutProof,
+	"gettxspendinginfo":              handleGetTxSpendingInfo,
+	"getvalidationtimings":           handleGetValidationTimings,
+	"getvalidatorstats":              handleGetValidatorStats,
+	"getwatchedbalance":              handleGetWatchedBalance,
+	"getwatchedhistory":              handleGetWatchedHistory,
+	"getwatchedutxos":                handleGetWatchedUtxos,
+	"help":                           handleHelp,
+	"importaddress":                  handleImportAddress,
+	"importpubkey":                   handleImportPubKey,
+	"listadminoperations":            handleListAdminOperations,
+	"listbanned":                     handleListBanned,
+	"listunspent":                    handleListUnspent,
+	"listwatched":                    handleListWatched,
+	"node":                           handleNode,
+	"ping":                           handlePing,
+	"rebuildindex":                   handleRebuildIndex,
+	"reloadconfig":                   handleReloadConfig,
+	"scantxoutset":                   handleScanTxOutSet,
+	"searchrawtransactions":          handleSearchRawTransactions,
+	"searchrawtransactionsbyaddress": handleSearchRawTransactionsByAddress,
+	"searchrawtransactionsbykeyid":   handleSearchRawTransactionsByKeyID,
+	"sendrawtransaction":             handleSendRawTransaction,
+	"setban":                         handleSetBan,
+	"setgenerate":                    handleSetGenerate,
+	"setpolicy":                      handleSetPolicy,
+	"signrawtransactionwithkey":      handleSignRawTransactionWithKey,
+	"setvalidatekeys":                handleSetValidateKeys,
+	"simulatechain":                  handleSimulateChain,
+	"stop":                           handleStop,
+	"submitblock":                    handleSubmitBlock,
+	"testblockvalidity":              handleTestBlockValidity,
+	"unwatchaddresses":               handleUnwatchAddresses,
+	"validateaddress":                handleValidateAddress,
+	"verifychain":                    handleVerifyChain,
+	"verifychaindb":                  handleVerifyChainDB,
+	"watchaddresses":                 handleWatchAddresses,
 }
 
 // list of commands that we recognize, but for which there is no support because
@@ -177,7 +263,6 @@ var rpcAskWallet = map[string]struct{}{
 	"getnewaddress":          {},
 	"getrawchangeaddress":    {},
 	"getreceivedbyaccount":   {},
-	"getreceivedbyaddress":   {},
 	"gettransaction":         {},
 	"gettxoutsetinfo":        {},
 	"getunconfirmedbalance":  {},
@@ -192,7 +277,6 @@ var rpcAskWallet = map[string]struct{}{
 	"listreceivedbyaddress":  {},
 	"listsinceblock":         {},
 	"listtransactions":       {},
-	"listunspent":            {},
 	"lockunspent":            {},
 	"move":                   {},
 	"sendfrom":               {},
@@ -209,58 +293,97 @@ var rpcAskWallet = map[string]struct{}{
 
 // Commands that are currently unimplemented, but should ultimately be.
 var rpcUnimplemented = map[string]struct{}{
-	"estimatefee":       {},
-	"estimatepriority":  {},
-	"getblockchaininfo": {},
-	"getchaintips":      {},
-	"getmempoolentry":   {},
-	"getnetworkinfo":    {},
-	"getwork":           {},
-	"invalidateblock":   {},
-	"preciousblock":     {},
-	"reconsiderblock":   {},
+	"estimatepriority": {},
+	"getwork":          {},
+	"invalidateblock":  {},
+	"preciousblock":    {},
+	"reconsiderblock":  {},
 }
 
 // Commands that are available to a limited user
 var rpcLimited = map[string]struct{}{
 	// Websockets commands
-	"loadtxfilter":          {},
-	"notifyblocks":          {},
-	"notifynewtransactions": {},
-	"notifyreceived":        {},
-	"notifyspent":           {},
-	"rescan":                {},
-	"rescanblocks":          {},
-	"session":               {},
+	"loadtxfilter":            {},
+	"notifyadmintransactions": {},
+	"notifyblocks":            {},
+	"notifynewtransactions":   {},
+	"notifyreceived":          {},
+	"notifyspent":             {},
+	"notifywatched":           {},
+	"rescan":                  {},
+	"rescanblocks":            {},
+	"session":                 {},
 
 	// Websockets AND HTTP/S commands
 	"help": {},
 
 	// HTTP/S-only commands
-	"createrawtransaction":  {},
-	"decoderawtransaction":  {},
-	"decodescript":          {},
-	"getaddresstxids":       {},
-	"getadmininfo":          {},
-	"getbestblock":          {},
-	"getbestblockhash":      {},
-	"getblock":              {},
-	"getblockcount":         {},
-	"getblockhash":          {},
-	"getcurrentnet":         {},
-	"getdifficulty":         {},
-	"getheaders":            {},
-	"getinfo":               {},
-	"getnettotals":          {},
-	"getnetworkhashps":      {},
-	"getrawmempool":         {},
-	"getrawtransaction":     {},
-	"gettxout":              {},
-	"searchrawtransactions": {},
-	"sendrawtransaction":    {},
-	"submitblock":           {},
-	"validateaddress":       {},
-	"verifymessage":         {},
+	"combinepspt":                    {},
+	"createpspt":                     {},
+	"createrawadmintransaction":      {},
+	"createrawtransaction":           {},
+	"debugscript":                    {},
+	"decodeadmintransaction":         {},
+	"decodepspt":                     {},
+	"decoderawtransaction":           {},
+	"decodescript":                   {},
+	"estimatefee":                    {},
+	"estimatesmartfee":               {},
+	"finalizepspt":                   {},
+	"getaddressbalance":              {},
+	"getaddressissuance":             {},
+	"getaddresstxids":                {},
+	"getaddressutxos":                {},
+	"getadmininfo":                   {},
+	"getbestblock":                   {},
+	"getbestblockhash":               {},
+	"getblock":                       {},
+	"getblockchaininfo":              {},
+	"getblockcount":                  {},
+	"getblockhash":                   {},
+	"getblockhashbytime":             {},
+	"getblockscrubinfo":              {},
+	"getblockstats":                  {},
+	"getcfheaders":                   {},
+	"getcfilter":                     {},
+	"getchaintips":                   {},
+	"getcurrentnet":                  {},
+	"getdifficulty":                  {},
+	"getheaders":                     {},
+	"getindexinfo":                   {},
+	"getinfo":                        {},
+	"getissuanceinfo":                {},
+	"getkeyidactivity":               {},
+	"getkeyidinfo":                   {},
+	"getmempoolancestors":            {},
+	"getmempooldescendants":          {},
+	"getmempoolentry":                {},
+	"getnettotals":                   {},
+	"getnetworkhashps":               {},
+	"getrawmempool":                  {},
+	"getrawtransaction":              {},
+	"getreceivedbyaddress":           {},
+	"getrescaninfo":                  {},
+	"getspentinfo":                   {},
+	"gettxout":                       {},
+	"gettxoutproof":                  {},
+	"gettxspendinginfo":              {},
+	"getvalidatorstats":              {},
+	"getwatchedbalance":              {},
+	"getwatchedhistory":              {},
+	"getwatchedutxos":                {},
+	"listadminoperations":            {},
+	"listunspent":                    {},
+	"listwatched":                    {},
+	"scantxoutset":                   {},
+	"searchrawtransactions":          {},
+	"searchrawtransactionsbyaddress": {},
+	"searchrawtransactionsbykeyid":   {},
+	"sendrawtransaction":             {},
+	"signrawtransactionwithkey":      {},
+	"submitblock":                    {},
+	"validateaddress":                {},
+	"verifymessage":                  {},
 }
 
 // builderScript is a convenience function which is used for hard-coded scripts
@@ -315,6 +438,8 @@ type gbtWorkState struct {
 	prevHash      *chainhash.Hash
 	minTimestamp  time.Time
 	template      *mining.BlockTemplate
+	validateKeys  []btcjson.GetBlockTemplateResultValidateKey
+	adminThreads  []btcjson.GetBlockTemplateResultAdminThread
 	notifyMap     map[chainhash.Hash]map[int64]chan struct{}
 	timeSource    blockchain.MedianTimeSource
 }
@@ -341,6 +466,15 @@ func handleAskWallet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (
 	return nil, ErrRPCNoWallet
 }
 
+// handleAbortRescan implements the abortrescan command.
+func handleAbortRescan(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.AbortRescanCmd)
+	if err := s.rescans.Abort(c.JobID); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
 // handleAddNode handles addnode commands.
 func handleAddNode(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.AddNodeCmd)
@@ -474,6 +608,113 @@ func peerExists(peers []*serverPeer, addr string, nodeID int32) bool {
 	return false
 }
 
+// handleSetBan implements the setban command.
+func handleSetBan(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetBanCmd)
+
+	subnet, err := connmgr.ParseSubnet(c.Subnet)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCClientInvalidIPOrSubnet,
+			Message: err.Error(),
+		}
+	}
+
+	switch c.SubCmd {
+	case btcjson.SBAdd:
+		// The ban time is the duration of the ban in seconds or, when
+		// absolute, the unix time the ban expires.  The ban duration of
+		// misbehaving peers applies when it is zero.
+		now := time.Now()
+		var until time.Time
+		switch {
+		case *c.BanTime < 0:
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "ban time must not be negative",
+			}
+		case *c.BanTime == 0:
+			until = now.Add(settings().banDuration)
+		case *c.Absolute:
+			until = time.Unix(*c.BanTime, 0)
+		default:
+			until = now.Add(time.Duration(*c.BanTime) * time.Second)
+		}
+		if !until.After(now) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "ban expires in the past",
+			}
+		}
+
+		disconnected, err := s.server.BanSubnet(subnet, until)
+		rpcsLog.Infof("Banned %v until %v, disconnected %d peers",
+			subnet, until, disconnected)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCMisc,
+				Message: "unable to save the ban list: " + err.Error(),
+			}
+		}
+
+	case btcjson.SBRemove:
+		found, err := s.server.banManager.Unban(subnet)
+		if !found {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCClientInvalidIPOrSubnet,
+				Message: fmt.Sprintf("%v is not banned", subnet),
+			}
+		}
+		rpcsLog.Infof("Removed the ban of %v", subnet)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCMisc,
+				Message: "unable to save the ban list: " + err.Error(),
+			}
+		}
+
+	default:
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "invalid subcommand for setban",
+		}
+	}
+
+	// no data returned unless an error.
+	return nil, nil
+}
+
+// handleListBanned implements the listbanned command.
+func handleListBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	now := time.Now()
+	banned := s.server.banManager.Banned()
+	bans := make([]btcjson.ListBannedResult, 0, len(banned))
+	for _, entry := range banned {
+		bans = append(bans, btcjson.ListBannedResult{
+			Address:       entry.Subnet.String(),
+			BanCreated:    entry.Created.Unix(),
+			BannedUntil:   entry.Until.Unix(),
+			BanDuration:   entry.Until.Unix() - entry.Created.Unix(),
+			TimeRemaining: entry.Until.Unix() - now.Unix(),
+		})
+	}
+	return bans, nil
+}
+
+// handleClearBanned implements the clearbanned command.
+func handleClearBanned(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	if err := s.server.banManager.Clear(); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "unable to save the ban list: " + err.Error(),
+		}
+	}
+	rpcsLog.Infof("Removed all bans")
+
+	// no data returned unless an error.
+	return nil, nil
+}
+
 // messageToHex serializes a message to the wire protocol encoding using the
 // latest protocol version and returns a hex-encoded string of the result.
 func messageToHex(msg wire.Message) (string, error) {
@@ -486,6 +727,96 @@ func messageToHex(msg wire.Message) (string, error) {
 	return hex.EncodeToString(buf.Bytes()), nil
 }
 
+// handleBackupChainState implements the backupchainstate command.
+func handleBackupChainState(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.BackupChainStateCmd)
+
+	best, err := s.chain.BackupDB(c.Path)
+	if dbErr, ok := err.(database.Error); ok &&
+		dbErr.ErrorCode == database.ErrDbExists {
+
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: dbErr.Description,
+		}
+	}
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDatabase,
+			Message: "Failed to back up the database: " + err.Error(),
+		}
+	}
+
+	return &btcjson.BackupChainStateResult{
+		Path:   c.Path,
+		Height: best.Height,
+		Hash:   best.Hash.String(),
+	}, nil
+}
+
+// handleCheckIndex handles checkindex commands.
+func handleCheckIndex(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CheckIndexCmd)
+	indexManager := s.server.indexManager
+	if indexManager == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "No optional indexes are enabled",
+		}
+	}
+
+	var startHeight uint32
+	if c.StartHeight != nil {
+		startHeight = *c.StartHeight
+	}
+	endHeight := s.chain.BestSnapshot().Height
+	if c.EndHeight != nil {
+		endHeight = *c.EndHeight
+	}
+	if startHeight > endHeight {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "End height must not be less than the start height.",
+		}
+	}
+	interval := uint32(1)
+	if c.Interval != nil && *c.Interval > 0 {
+		interval = *c.Interval
+	}
+	var repair bool
+	if c.Repair != nil {
+		repair = *c.Repair
+	}
+
+	result, err := indexManager.CheckIndex(c.Index, startHeight, endHeight,
+		interval, repair)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: err.Error(),
+		}
+	}
+
+	inconsistencies := make([]btcjson.IndexInconsistencyResult, 0,
+		len(result.Inconsistencies))
+	for i := range result.Inconsistencies {
+		inconsistency := &result.Inconsistencies[i]
+		inconsistencies = append(inconsistencies,
+			btcjson.IndexInconsistencyResult{
+				Height:   inconsistency.Height,
+				Hash:     inconsistency.BlockHash.String(),
+				Key:      hex.EncodeToString(inconsistency.Key),
+				Reason:   inconsistency.Reason,
+				Repaired: inconsistency.Repaired,
+			})
+	}
+	return &btcjson.CheckIndexResult{
+		Index:           result.Name,
+		BlocksChecked:   result.BlocksChecked,
+		Inconsistencies: inconsistencies,
+	}, nil
+}
+
 // handleCreateRawTransaction handles createrawtransaction commands.
 func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.CreateRawTransactionCmd)
@@ -573,6 +904,22 @@ func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan
 		mtx.AddTxOut(txOut)
 	}
 
+	// Add a null data output carrying the data, if given.
+	if c.Data != nil {
+		data, err := hex.DecodeString(*c.Data)
+		if err != nil {
+			return nil, rpcDecodeHexError(*c.Data)
+		}
+		pkScript, err := txscript.NullDataScript(data)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid data: " + err.Error(),
+			}
+		}
+		mtx.AddTxOut(wire.NewTxOut(0, pkScript))
+	}
+
 	// Set the Locktime, if given.
 	if c.LockTime != nil {
 		mtx.LockTime = uint32(*c.LockTime)
@@ -589,6 +936,143 @@ func handleCreateRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan
 	return mtxHex, nil
 }
 
+// adminThreadTip returns the tip of the passed admin thread, which is the tip
+// of the best chain extended by the admin transactions of the thread in the
+// memory pool, if any.
+func adminThreadTip(s *rpcServer, threadID provautil.ThreadID) (*wire.OutPoint, error) {
+	chainTip := s.chain.ThreadTips()[threadID]
+	if chainTip == nil {
+		return nil, fmt.Errorf("no tip for admin thread %d", threadID)
+	}
+	spenders := make(map[wire.OutPoint]*chainhash.Hash)
+	for _, txD := range s.server.txMemPool.TxDescs() {
+		msgTx := txD.Tx.MsgTx()
+		txThread, _ := txscript.GetAdminDetailsMsgTx(msgTx)
+		if txThread == int(threadID) {
+			spenders[msgTx.TxIn[0].PreviousOutPoint] = txD.Tx.Hash()
+		}
+	}
+	tip := *chainTip
+	for {
+		hash, ok := spenders[tip]
+		if !ok {
+			return &tip, nil
+		}
+		tip = *wire.NewOutPoint(hash, 0)
+	}
+}
+
+// handleCreateRawAdminTransaction handles createrawadmintransaction commands.
+func handleCreateRawAdminTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CreateRawAdminTransactionCmd)
+
+	var threadID provautil.ThreadID
+	var buildTx func(threadTip *wire.OutPoint) (*wire.MsgTx, error)
+	switch c.Operation {
+	case "issue":
+		outputs, err := txbuilder.NewOutputsFromAmounts(c.Params.Amounts,
+			s.server.chainParams)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: err.Error(),
+			}
+		}
+		threadID = provautil.IssueThread
+		buildTx = func(threadTip *wire.OutPoint) (*wire.MsgTx, error) {
+			return adminutil.NewIssueTx(threadTip, outputs...)
+		}
+
+	case "destroy":
+		// The amounts and scripts of the outputs to destroy are looked
+		// up in the utxo set of the best chain.
+		utxos := make([]*txbuilder.Utxo, 0, len(c.Params.Inputs))
+		for _, input := range c.Params.Inputs {
+			txHash, err := chainhash.NewHashFromStr(input.Txid)
+			if err != nil {
+				return nil, rpcDecodeHexError(input.Txid)
+			}
+			entry, err := s.chain.FetchUtxoEntry(txHash)
+			if err != nil {
+				context := "Failed to fetch utxo"
+				return nil, internalRPCError(err.Error(), context)
+			}
+			if entry == nil || entry.IsOutputSpent(input.Vout) {
+				return nil, &btcjson.RPCError{
+					Code: btcjson.ErrRPCNoTxInfo,
+					Message: fmt.Sprintf("Output %s:%d is "+
+						"not unspent", input.Txid, input.Vout),
+				}
+			}
+			utxos = append(utxos, &txbuilder.Utxo{
+				OutPoint: *wire.NewOutPoint(txHash, input.Vout),
+				Amount:   provautil.Amount(entry.AmountByIndex(input.Vout)),
+				PkScript: entry.PkScriptByIndex(input.Vout),
+			})
+		}
+		fee, err := provautil.NewAmount(c.Params.Fee)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCType,
+				Message: "Invalid fee: " + err.Error(),
+			}
+		}
+		threadID = provautil.IssueThread
+		buildTx = func(threadTip *wire.OutPoint) (*wire.MsgTx, error) {
+			return adminutil.NewDestroyTx(threadTip, utxos, fee)
+		}
+
+	default:
+		pubKeyBytes, err := hex.DecodeString(c.Params.PubKey)
+		if err != nil {
+			return nil, rpcDecodeHexError(c.Params.PubKey)
+		}
+		pubKey, err := btcec.ParsePubKey(pubKeyBytes, btcec.S256())
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid public key: " + err.Error(),
+			}
+		}
+		op, err := adminutil.NewOp(c.Operation, pubKey,
+			btcec.KeyID(c.Params.KeyID))
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: err.Error(),
+			}
+		}
+		threadID, err = op.Thread()
+		if err != nil {
+			context := "Failed to get admin thread"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		buildTx = func(threadTip *wire.OutPoint) (*wire.MsgTx, error) {
+			return adminutil.NewKeyTx(threadTip, op)
+		}
+	}
+
+	threadTip, err := adminThreadTip(s, threadID)
+	if err != nil {
+		context := "Failed to get admin thread tip"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	mtx, err := buildTx(threadTip)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+
+	// Return the serialized and hex-encoded transaction.
+	mtxHex, err := messageToHex(mtx)
+	if err != nil {
+		return nil, err
+	}
+	return mtxHex, nil
+}
+
 type addressToKey struct {
 	key        *btcec.PrivateKey
 	compressed bool
@@ -615,61 +1099,339 @@ func handleDebugLevel(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return "Done.", nil
 }
 
-// createVinList returns a slice of JSON objects for the inputs of the passed
-// transaction.
-func createVinList(mtx *wire.MsgTx) []btcjson.Vin {
-	// Coinbase transactions only have a single txin by definition.
-	vinList := make([]btcjson.Vin, len(mtx.TxIn))
-	if blockchain.IsCoinBaseTx(mtx) {
-		txIn := mtx.TxIn[0]
-		vinList[0].Coinbase = hex.EncodeToString(txIn.SignatureScript)
-		vinList[0].Sequence = txIn.Sequence
-		return vinList
+// fetchSpentOutput returns the public key script and the amount of the passed
+// output, which is spent by the input at the passed index, from the memory pool
+// or the utxo set of the best chain.
+func fetchSpentOutput(s *rpcServer, prevOut *wire.OutPoint, vin int) ([]byte, int64, error) {
+	if tx, err := s.server.txMemPool.FetchTransaction(&prevOut.Hash); err == nil {
+		prevTx := tx.MsgTx()
+		if prevOut.Index < uint32(len(prevTx.TxOut)) {
+			txOut := prevTx.TxOut[prevOut.Index]
+			return txOut.PkScript, txOut.Value, nil
+		}
+	} else {
+		entry, err := s.chain.FetchUtxoEntry(&prevOut.Hash)
+		if err != nil {
+			context := "Failed to fetch utxo"
+			return nil, 0, internalRPCError(err.Error(), context)
+		}
+		if entry != nil && !entry.IsOutputSpent(prevOut.Index) {
+			return entry.PkScriptByIndex(prevOut.Index),
+				entry.AmountByIndex(prevOut.Index), nil
+		}
 	}
+	return nil, 0, &btcjson.RPCError{
+		Code: btcjson.ErrRPCNoTxInfo,
+		Message: fmt.Sprintf("Output %v spent by input %d is not "+
+			"unspent", prevOut, vin),
+	}
+}
 
-	for i, txIn := range mtx.TxIn {
-		// The disassembled string will contain [error] inline
-		// if the script doesn't fully parse, so ignore the
-		// error here.
-		disbuf, _ := txscript.DisasmString(txIn.SignatureScript)
-
-		vinEntry := &vinList[i]
-		vinEntry.Txid = txIn.PreviousOutPoint.Hash.String()
-		vinEntry.Vout = txIn.PreviousOutPoint.Index
-		vinEntry.Sequence = txIn.Sequence
-		vinEntry.ScriptSig = &btcjson.ScriptSig{
-			Asm: disbuf,
-			Hex: hex.EncodeToString(txIn.SignatureScript),
+// decodePSPTParam decodes a PSPT passed as a parameter of an RPC.
+func decodePSPTParam(encoded string) (*provasign.Packet, error) {
+	packet, err := provasign.DecodePacket(encoded)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "PSPT decode failed: " + err.Error(),
 		}
 	}
+	return packet, nil
+}
 
-	return vinList
+// encodePSPTResult encodes a PSPT returned by an RPC.
+func encodePSPTResult(packet *provasign.Packet) (string, error) {
+	encoded, err := packet.Encode()
+	if err != nil {
+		context := "Failed to encode PSPT"
+		return "", internalRPCError(err.Error(), context)
+	}
+	return encoded, nil
 }
 
-// createVoutList returns a slice of JSON objects for the outputs of the passed
-// transaction.
-func createVoutList(mtx *wire.MsgTx, chainParams *chaincfg.Params, filterAddrMap map[string]struct{}) []btcjson.Vout {
-	voutList := make([]btcjson.Vout, 0, len(mtx.TxOut))
-	threadInt, _ := txscript.GetAdminDetailsMsgTx(mtx)
-	isAdmin := provautil.ThreadID(threadInt) == provautil.RootThread || provautil.ThreadID(threadInt) == provautil.ProvisionThread
-	for i, v := range mtx.TxOut {
-		// The disassembled string will contain [error] inline if the
-		// script doesn't fully parse, so ignore the error here.
-		disbuf, _ := txscript.DisasmString(v.PkScript)
+// handleCombinePSPT implements the combinepspt command.
+func handleCombinePSPT(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CombinePSPTCmd)
+	if len(c.PSPTs) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "No PSPTs to combine",
+		}
+	}
 
-		// Ignore the error here since an error means the script
-		// couldn't parse and there is no additional information about
-		// it anyways.
-		scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(
-			v.PkScript, chainParams)
+	packet, err := decodePSPTParam(c.PSPTs[0])
+	if err != nil {
+		return nil, err
+	}
+	for i, encoded := range c.PSPTs[1:] {
+		other, err := decodePSPTParam(encoded)
+		if err != nil {
+			return nil, err
+		}
+		if err := packet.Combine(other); err != nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Failed to combine PSPT %d: %v",
+					i+1, err),
+			}
+		}
+	}
+	return encodePSPTResult(packet)
+}
 
-		// Encode the addresses while checking if the address passes the
-		// filter when needed.
-		passesFilter := len(filterAddrMap) == 0
-		encodedAddrs := make([]string, len(addrs))
-		for j, addr := range addrs {
-			encodedAddr := addr.EncodeAddress()
-			encodedAddrs[j] = encodedAddr
+// handleCreatePSPT implements the createpspt command.
+func handleCreatePSPT(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.CreatePSPTCmd)
+
+	// Deserialize the transaction.
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var mtx wire.MsgTx
+	err = mtx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
+		}
+	}
+
+	// Look up the outputs spent by the inputs and bind the key IDs of
+	// their scripts to the ASP keys of the best chain, so signers are able
+	// to check what they sign without access to the chain.
+	inputs := make([]provasign.PacketInput, 0, len(mtx.TxIn))
+	for i, txIn := range mtx.TxIn {
+		pkScript, amount, err := fetchSpentOutput(s,
+			&txIn.PreviousOutPoint, i)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, provasign.PacketInput{
+			Amount:   amount,
+			PkScript: pkScript,
+		})
+	}
+	packet, err := provasign.NewPacket(&mtx, inputs)
+	if err != nil {
+		context := "Failed to create PSPT"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if err := txbuilder.AddPacketKeyIDs(packet, s.chain.KeyIDs()); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: err.Error(),
+		}
+	}
+	return encodePSPTResult(packet)
+}
+
+// handleDebugScript implements the debugscript command.
+func handleDebugScript(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DebugScriptCmd)
+
+	// Deserialize the transaction.
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var mtx wire.MsgTx
+	err = mtx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
+		}
+	}
+	if c.Vin >= uint32(len(mtx.TxIn)) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Input index number (vin) does not exist for " +
+				"transaction.",
+		}
+	}
+
+	// Use the provided public key script, or look up the output spent by
+	// the input in the memory pool and the utxo set of the best chain.
+	var pkScript []byte
+	var amount int64
+	if c.ScriptPubKey != nil {
+		pkScript, err = hex.DecodeString(*c.ScriptPubKey)
+		if err != nil {
+			return nil, rpcDecodeHexError(*c.ScriptPubKey)
+		}
+	} else {
+		prevOut := &mtx.TxIn[c.Vin].PreviousOutPoint
+		pkScript, amount, err = fetchSpentOutput(s, prevOut, int(c.Vin))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.Amount != nil {
+		atoms, err := provautil.NewAmount(*c.Amount)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCType,
+				Message: "Invalid amount: " + err.Error(),
+			}
+		}
+		amount = int64(atoms)
+	}
+
+	// Execute the script pair with the flags used for standard
+	// transactions while recording the state of the engine after every
+	// opcode.
+	result := &btcjson.DebugScriptResult{
+		Steps: make([]btcjson.DebugScriptStepResult, 0),
+	}
+	vm, err := txscript.NewEngine(pkScript, &mtx, int(c.Vin),
+		txscript.StandardVerifyFlags, nil, nil, amount)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	encodeStack := func(stack [][]byte) []string {
+		items := make([]string, len(stack))
+		for i, item := range stack {
+			items[i] = hex.EncodeToString(item)
+		}
+		return items
+	}
+	vm.SetStepCallback(func(info *txscript.StepInfo) error {
+		result.Steps = append(result.Steps, btcjson.DebugScriptStepResult{
+			Script:    info.ScriptIndex,
+			Index:     info.OpcodeIndex,
+			Opcode:    info.Opcode,
+			Executed:  info.Executed,
+			Stack:     encodeStack(info.Stack),
+			AltStack:  encodeStack(info.AltStack),
+			Remaining: info.RemainingScript,
+		})
+		return nil
+	})
+	if err := vm.Execute(); err != nil {
+		result.Error = err.Error()
+
+		// The program counter still points at the failing opcode unless
+		// the scripts ran to completion.
+		if dis, err := vm.DisasmPC(); err == nil {
+			result.FailedAt = dis
+		}
+		return result, nil
+	}
+	result.Valid = true
+	return result, nil
+}
+
+// handleDecodeScript handles decodescript commands.
+func handleDecodeScript(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DecodeScriptCmd)
+
+	// Convert the hex script to bytes.
+	hexStr := c.HexScript
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	script, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+
+	// The disassembled string will contain [error] inline if the script
+	// doesn't fully parse, so ignore the error here.  The annotated
+	// disassembly labels the key IDs, admin threads, and admin operations
+	// of Prova scripts.
+	var disbuf string
+	if c.Annotated != nil && *c.Annotated {
+		disbuf, _ = txscript.DisasmStringAnnotated(script)
+	} else {
+		disbuf, _ = txscript.DisasmString(script)
+	}
+
+	// Get information about the script.
+	// Ignore the error here since an error means the script couldn't parse
+	// and there is no additional information about it anyways.
+	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(script,
+		s.server.chainParams)
+	addresses := make([]string, len(addrs))
+	for i, addr := range addrs {
+		addresses[i] = addr.EncodeAddress()
+	}
+
+	// Generate and return the reply.
+	reply := btcjson.DecodeScriptResult{
+		Asm:       disbuf,
+		ReqSigs:   int32(reqSigs),
+		Type:      scriptClass.String(),
+		Addresses: addresses,
+		P2sh:      hex.EncodeToString(provautil.Hash160(script)),
+	}
+	return reply, nil
+}
+
+// createVinList returns a slice of JSON objects for the inputs of the passed
+// transaction.
+func createVinList(mtx *wire.MsgTx) []btcjson.Vin {
+	// Coinbase transactions only have a single txin by definition.
+	vinList := make([]btcjson.Vin, len(mtx.TxIn))
+	if blockchain.IsCoinBaseTx(mtx) {
+		txIn := mtx.TxIn[0]
+		vinList[0].Coinbase = hex.EncodeToString(txIn.SignatureScript)
+		vinList[0].Sequence = txIn.Sequence
+		return vinList
+	}
+
+	for i, txIn := range mtx.TxIn {
+		// The disassembled string will contain [error] inline
+		// if the script doesn't fully parse, so ignore the
+		// error here.
+		disbuf, _ := txscript.DisasmString(txIn.SignatureScript)
+
+		vinEntry := &vinList[i]
+		vinEntry.Txid = txIn.PreviousOutPoint.Hash.String()
+		vinEntry.Vout = txIn.PreviousOutPoint.Index
+		vinEntry.Sequence = txIn.Sequence
+		vinEntry.ScriptSig = &btcjson.ScriptSig{
+			Asm: disbuf,
+			Hex: hex.EncodeToString(txIn.SignatureScript),
+		}
+	}
+
+	return vinList
+}
+
+// createVoutList returns a slice of JSON objects for the outputs of the passed
+// transaction.
+func createVoutList(mtx *wire.MsgTx, chainParams *chaincfg.Params, filterAddrMap map[string]struct{}) []btcjson.Vout {
+	voutList := make([]btcjson.Vout, 0, len(mtx.TxOut))
+	threadInt, _ := txscript.GetAdminDetailsMsgTx(mtx)
+	isAdmin := provautil.ThreadID(threadInt) == provautil.RootThread || provautil.ThreadID(threadInt) == provautil.ProvisionThread
+	for i, v := range mtx.TxOut {
+		// The disassembled string will contain [error] inline if the
+		// script doesn't fully parse, so ignore the error here.
+		disbuf, _ := txscript.DisasmString(v.PkScript)
+
+		// Ignore the error here since an error means the script
+		// couldn't parse and there is no additional information about
+		// it anyways.
+		scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(
+			v.PkScript, chainParams)
+
+		// Encode the addresses while checking if the address passes the
+		// filter when needed.
+		passesFilter := len(filterAddrMap) == 0
+		encodedAddrs := make([]string, len(addrs))
+		for j, addr := range addrs {
+			encodedAddr := addr.EncodeAddress()
+			encodedAddrs[j] = encodedAddr
 
 			// No need to check the map again if the filter already
 			// passes.
@@ -735,6 +1497,81 @@ func createTxRawResult(chainParams *chaincfg.Params, mtx *wire.MsgTx,
 	return txReply, nil
 }
 
+// handleDecodeAdminTransaction handles decodeadmintransaction commands.
+func handleDecodeAdminTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DecodeAdminTransactionCmd)
+
+	// Deserialize the transaction.
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var mtx wire.MsgTx
+	err = mtx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
+		}
+	}
+	adminTx, err := adminutil.DecodeTx(&mtx)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid admin transaction: " + err.Error(),
+		}
+	}
+
+	// Describe the key operations, the issued outputs, and the destroyed
+	// tokens as operations.
+	ops := make([]btcjson.AdminOpResult, 0, len(adminTx.Ops)+
+		len(adminTx.Issued)+1)
+	for _, op := range adminTx.Ops {
+		ops = append(ops, btcjson.AdminOpResult{
+			Operation: op.Name(),
+			PubKey:    hex.EncodeToString(op.PubKey.SerializeCompressed()),
+			KeyID:     uint32(op.KeyID),
+		})
+	}
+	for _, txOut := range adminTx.Issued {
+		var address string
+		_, addrs, _, _ := txscript.ExtractPkScriptAddrs(txOut.PkScript,
+			s.server.chainParams)
+		if len(addrs) > 0 {
+			address = addrs[0].EncodeAddress()
+		}
+		ops = append(ops, btcjson.AdminOpResult{
+			Operation: "issue",
+			Address:   address,
+			Amount:    provautil.Amount(txOut.Value).ToRMG(),
+		})
+	}
+	var destroyed []string
+	if len(adminTx.Destroyed) > 0 {
+		ops = append(ops, btcjson.AdminOpResult{
+			Operation: "destroy",
+			Amount:    adminTx.DestroyedAmount.ToRMG(),
+		})
+		destroyed = make([]string, 0, len(adminTx.Destroyed))
+		for _, outPoint := range adminTx.Destroyed {
+			destroyed = append(destroyed, outPoint.String())
+		}
+	}
+
+	return &btcjson.DecodeAdminTransactionResult{
+		Txid:       mtx.TxHash().String(),
+		Thread:     uint32(adminTx.Thread),
+		ThreadName: adminTx.Thread.String(),
+		ThreadTip:  adminTx.ThreadTip.String(),
+		Operations: ops,
+		Destroyed:  destroyed,
+	}, nil
+}
+
 // handleDecodeRawTransaction handles decoderawtransaction commands.
 func handleDecodeRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.DecodeRawTransactionCmd)
@@ -768,69 +1605,330 @@ func handleDecodeRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan
 	return txReply, nil
 }
 
-// handleGenerate handles generate commands.
-func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	// Respond with an error if there are no addresses to pay the
-	// created blocks to.
-	if len(cfg.miningAddrs) == 0 {
-		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCInternal.Code,
-			Message: "No payment addresses specified " +
-				"via --miningaddr",
-		}
+// handleDecodePSPT implements the decodepspt command.
+func handleDecodePSPT(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DecodePSPTCmd)
+	packet, err := decodePSPTParam(c.PSPT)
+	if err != nil {
+		return nil, err
 	}
 
-	// Respond with an error if there's virtually 0 chance of mining a block
-	// with the CPU.
-	params := s.server.chainParams
-	if !s.server.chainParams.GenerateSupported {
-		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCDifficulty,
-			Message: fmt.Sprintf("No support for `generate` on "+
-				"the current network, %s, as it's unlikely to "+
-				"be possible to main a block with the CPU.",
-				params.Net),
+	inputs := make([]btcjson.DecodePSPTInputResult, 0, len(packet.Inputs))
+	for i, input := range packet.Inputs {
+		// The required number of signatures is only known for Prova
+		// scripts and left at zero for other scripts.
+		required, _ := provasign.RequiredSignatures(input.PkScript)
+
+		keyIDs := make([]btcjson.PSPTKeyIDResult, 0, len(input.KeyIDs))
+		for keyID, pubKey := range input.KeyIDs {
+			keyIDs = append(keyIDs, btcjson.PSPTKeyIDResult{
+				KeyID:  uint32(keyID),
+				PubKey: hex.EncodeToString(pubKey.SerializeCompressed()),
+			})
 		}
-	}
+		sort.Slice(keyIDs, func(i, j int) bool {
+			return keyIDs[i].KeyID < keyIDs[j].KeyID
+		})
 
-	c := cmd.(*btcjson.GenerateCmd)
+		derivations := make([]btcjson.PSPTDerivationResult, 0,
+			len(input.Derivations))
+		for _, derivation := range input.Derivations {
+			derivations = append(derivations, btcjson.PSPTDerivationResult{
+				PubKey:      hex.EncodeToString(derivation.PubKey.SerializeCompressed()),
+				Fingerprint: fmt.Sprintf("%08x", derivation.Fingerprint),
+				Path:        derivation.PathString(),
+			})
+		}
 
-	// Respond with an error if the client is requesting 0 blocks to be generated.
-	if c.NumBlocks == 0 {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCInternal.Code,
-			Message: "Please request a nonzero number of blocks to generate.",
+		sigs, err := packet.Signatures(i)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCDeserialization,
+				Message: fmt.Sprintf("Invalid signatures of input "+
+					"%d: %v", i, err),
+			}
 		}
+		signatures := make([]btcjson.PSPTSignatureResult, 0, len(sigs))
+		for _, sig := range sigs {
+			signatures = append(signatures, btcjson.PSPTSignatureResult{
+				PubKey:    hex.EncodeToString(sig.PubKey.SerializeCompressed()),
+				Signature: hex.EncodeToString(sig.Signature),
+			})
+		}
+
+		inputs = append(inputs, btcjson.DecodePSPTInputResult{
+			Amount:       provautil.Amount(input.Amount).ToRMG(),
+			ScriptPubKey: hex.EncodeToString(input.PkScript),
+			Required:     required,
+			KeyIDs:       keyIDs,
+			Derivations:  derivations,
+			Signatures:   signatures,
+		})
 	}
 
-	// Attempt to establish validate keys from the environment var if there
-	// are none already registered.
-	if len(s.server.cpuMiner.ValidateKeys()) == 0 {
-		s.server.cpuMiner.EstablishValidateKeys()
-	}
+	mtx := packet.Tx
+	return &btcjson.DecodePSPTResult{
+		Tx: btcjson.TxRawDecodeResult{
+			Txid:     mtx.TxHash().String(),
+			Version:  mtx.Version,
+			Locktime: mtx.LockTime,
+			Vin:      createVinList(mtx),
+			Vout:     createVoutList(mtx, s.server.chainParams, nil),
+		},
+		Inputs:   inputs,
+		Complete: packet.Complete(),
+	}, nil
+}
 
-	// Check that there are validate keys set
-	if len(s.server.cpuMiner.ValidateKeys()) == 0 {
+// handleDropIndex handles dropindex commands.
+func handleDropIndex(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DropIndexCmd)
+	indexManager := s.server.indexManager
+	if indexManager == nil {
 		return nil, &btcjson.RPCError{
-			Code: btcjson.ErrRPCInternal.Code,
-			Message: "No validate keys provided via " +
-				"--setvalidatekeys or PROVA_VALIDATE_KEYS " +
-				"environment variable",
+			Code:    btcjson.ErrRPCMisc,
+			Message: "No optional indexes are enabled",
 		}
 	}
 
-	// Create a reply
-	reply := make([]string, c.NumBlocks)
-
-	blockHashes, err := s.server.cpuMiner.GenerateNBlocks(c.NumBlocks)
-	if err != nil {
+	if err := indexManager.DropIndex(c.Index); err != nil {
 		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCInternal.Code,
+			Code:    btcjson.ErrRPCMisc,
 			Message: err.Error(),
 		}
 	}
+	return nil, nil
+}
 
-	// Mine the correct number of blocks, assigning the hex representation of the
+// handleDumpUTXOSnapshot implements the dumputxosnapshot command.
+func handleDumpUTXOSnapshot(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.DumpUTXOSnapshotCmd)
+
+	best := s.chain.BestSnapshot()
+	height := best.Height
+	if c.Height != nil {
+		height = *c.Height
+	}
+	if height == 0 || height > best.Height {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("height must be between 1 and %d",
+				best.Height),
+		}
+	}
+
+	f, err := os.Create(c.Path)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: err.Error(),
+		}
+	}
+	integrityHash, err := s.chain.ExportUTXOSnapshot(f, height)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(c.Path)
+		context := "Failed to export utxo set snapshot"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	hash, err := s.chain.BlockHashByHeight(height)
+	if err != nil {
+		context := "Failed to fetch block hash"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return &btcjson.DumpUTXOSnapshotResult{
+		Path:          c.Path,
+		Height:        height,
+		Hash:          hash.String(),
+		IntegrityHash: integrityHash.String(),
+	}, nil
+}
+
+// Fee estimate modes of the estimatesmartfee command along with the confidence
+// the transactions paying the estimated fee rate are required to have been
+// mined within the target.
+const (
+	estimateModeConservative = "CONSERVATIVE"
+	estimateModeEconomical   = "ECONOMICAL"
+
+	estimateConfidenceConservative = 0.95
+	estimateConfidenceEconomical   = 0.85
+)
+
+// handleEstimateFee implements the estimatefee command.
+func handleEstimateFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.EstimateFeeCmd)
+
+	if c.NumBlocks < 1 || c.NumBlocks > mempool.MaxEstimateFeeTarget {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("numblocks must be between 1 and %d",
+				mempool.MaxEstimateFeeTarget),
+		}
+	}
+
+	feeRate, err := s.server.feeEstimator.EstimateFee(uint32(c.NumBlocks),
+		estimateConfidenceConservative)
+	if err == mempool.ErrNoFeeEstimate {
+		return -1.0, nil
+	}
+	if err != nil {
+		context := "Failed to estimate fee"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	return provautil.Amount(feeRate).ToRMG(), nil
+}
+
+// handleEstimateSmartFee implements the estimatesmartfee command.
+func handleEstimateSmartFee(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.EstimateSmartFeeCmd)
+
+	if c.ConfTarget < 1 || c.ConfTarget > mempool.MaxEstimateFeeTarget {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("conf_target must be between 1 and %d",
+				mempool.MaxEstimateFeeTarget),
+		}
+	}
+	var confidence float64
+	switch strings.ToUpper(*c.EstimateMode) {
+	case estimateModeConservative:
+		confidence = estimateConfidenceConservative
+	case estimateModeEconomical:
+		confidence = estimateConfidenceEconomical
+	default:
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("estimate_mode must be %s or %s",
+				estimateModeConservative, estimateModeEconomical),
+		}
+	}
+
+	// Fall back to longer targets when there is not enough data to estimate
+	// the fee rate for the requested one, and report the target the
+	// estimate is for.
+	for target := c.ConfTarget; target <= mempool.MaxEstimateFeeTarget; target++ {
+		feeRate, err := s.server.feeEstimator.EstimateFee(uint32(target),
+			confidence)
+		if err == mempool.ErrNoFeeEstimate {
+			continue
+		}
+		if err != nil {
+			context := "Failed to estimate fee"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		// Transactions paying less than the minimum relay fee are not
+		// accepted into the memory pool at all.
+		if minFee := int64(settings().minRelayTxFee); feeRate < minFee {
+			feeRate = minFee
+		}
+		rate := provautil.Amount(feeRate).ToRMG()
+		return &btcjson.EstimateSmartFeeResult{
+			FeeRate: &rate,
+			Blocks:  target,
+		}, nil
+	}
+
+	return &btcjson.EstimateSmartFeeResult{
+		Errors: []string{"Insufficient data or no feerate found"},
+		Blocks: c.ConfTarget,
+	}, nil
+}
+
+// handleFinalizePSPT implements the finalizepspt command.
+func handleFinalizePSPT(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.FinalizePSPTCmd)
+	packet, err := decodePSPTParam(c.PSPT)
+	if err != nil {
+		return nil, err
+	}
+
+	// A PSPT which lacks signatures is returned as is, so it is able to be
+	// passed on to the remaining signers.
+	if !packet.Complete() {
+		encoded, err := encodePSPTResult(packet)
+		if err != nil {
+			return nil, err
+		}
+		return &btcjson.FinalizePSPTResult{PSPT: encoded}, nil
+	}
+
+	// Execute the scripts of the fully signed transaction against the ASP
+	// keys of the best chain before handing it out for broadcast.
+	if err := txbuilder.VerifyPacket(packet, s.chain.KeyIDs()); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCVerify,
+			Message: "PSPT verification failed: " + err.Error(),
+		}
+	}
+	mtx, err := packet.Extract()
+	if err != nil {
+		context := "Failed to extract transaction"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	mtxHex, err := messageToHex(mtx)
+	if err != nil {
+		return nil, err
+	}
+	return &btcjson.FinalizePSPTResult{Hex: mtxHex, Complete: true}, nil
+}
+
+// handleGenerate handles generate commands.
+func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if there are no addresses to pay the
+	// created blocks to.
+	if len(cfg.miningAddrs) == 0 {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInternal.Code,
+			Message: "No payment addresses specified " +
+				"via --miningaddr",
+		}
+	}
+
+	// Respond with an error if there's virtually 0 chance of mining a block
+	// with the CPU.
+	params := s.server.chainParams
+	if !s.server.chainParams.GenerateSupported {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCDifficulty,
+			Message: fmt.Sprintf("No support for `generate` on "+
+				"the current network, %s, as it's unlikely to "+
+				"be possible to main a block with the CPU.",
+				params.Net),
+		}
+	}
+
+	c := cmd.(*btcjson.GenerateCmd)
+
+	// Respond with an error if the client is requesting 0 blocks to be generated.
+	if c.NumBlocks == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: "Please request a nonzero number of blocks to generate.",
+		}
+	}
+
+	if err := ensureGenerateValidateKeys(s); err != nil {
+		return nil, err
+	}
+
+	// Create a reply
+	reply := make([]string, c.NumBlocks)
+
+	blockHashes, err := s.server.cpuMiner.GenerateNBlocks(c.NumBlocks)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: err.Error(),
+		}
+	}
+
+	// Mine the correct number of blocks, assigning the hex representation of the
 	// hash of each one to its place in the reply.
 	for i, hash := range blockHashes {
 		reply[i] = hash.String()
@@ -839,6 +1937,100 @@ func handleGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	return reply, nil
 }
 
+// ensureGenerateValidateKeys ensures the CPU miner has validate keys to sign
+// the generated blocks with, establishing them from the environment var when
+// there are none already registered.
+func ensureGenerateValidateKeys(s *rpcServer) error {
+	if len(s.server.cpuMiner.ValidateKeys()) == 0 {
+		s.server.cpuMiner.EstablishValidateKeys()
+	}
+	if len(s.server.cpuMiner.ValidateKeys()) == 0 {
+		return &btcjson.RPCError{
+			Code: btcjson.ErrRPCInternal.Code,
+			Message: "No validate keys provided via " +
+				"setvalidatekeys, --validatesigner or " +
+				"PROVA_VALIDATE_KEYS environment variable",
+		}
+	}
+	return nil
+}
+
+// handleGenerateToAddress handles generatetoaddress commands.
+func handleGenerateToAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GenerateToAddressCmd)
+
+	// Blocks are only generated on demand on the networks meant for
+	// testing, where the difficulty is low enough to solve them at once.
+	params := s.server.chainParams
+	if params.Net != wire.SimNet && params.Net != wire.RegNet {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCDifficulty,
+			Message: fmt.Sprintf("No support for `generatetoaddress` "+
+				"on the current network, %s, as it's only "+
+				"available on simnet and regtest", params.Net),
+		}
+	}
+
+	// Respond with an error if the client is requesting 0 blocks to be generated.
+	if c.NumBlocks == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: "Please request a nonzero number of blocks to generate.",
+		}
+	}
+
+	// Decode the address the generated blocks pay to.
+	addr, err := provautil.DecodeAddress(c.Address, params)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+	if !addr.IsForNet(params) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address: " + c.Address +
+				" is for the wrong network",
+		}
+	}
+
+	// Ensure the requested transactions are in the memory pool.
+	var txHashes []*chainhash.Hash
+	if c.TxIDs != nil {
+		txHashes = make([]*chainhash.Hash, 0, len(*c.TxIDs))
+		for _, txID := range *c.TxIDs {
+			txHash, err := chainhash.NewHashFromStr(txID)
+			if err != nil {
+				return nil, rpcDecodeHexError(txID)
+			}
+			if !s.server.txMemPool.HaveTransaction(txHash) {
+				return nil, rpcNoTxInfoError(txHash)
+			}
+			txHashes = append(txHashes, txHash)
+		}
+	}
+
+	if err := ensureGenerateValidateKeys(s); err != nil {
+		return nil, err
+	}
+
+	blockHashes, err := s.server.cpuMiner.GenerateBlocks(c.NumBlocks, addr,
+		txHashes)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInternal.Code,
+			Message: err.Error(),
+		}
+	}
+
+	reply := make([]string, len(blockHashes))
+	for i, hash := range blockHashes {
+		reply[i] = hash.String()
+	}
+	return reply, nil
+}
+
 // handleGetAddedNodeInfo handles getaddednodeinfo commands.
 func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetAddedNodeInfoCmd)
@@ -928,67 +2120,225 @@ func handleGetAddedNodeInfo(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return results, nil
 }
 
-// handleGetAddressTxIds implements the getaddresstxids command.
-func handleGetAddressTxIds(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	// Respond with an error if the address index is not enabled.
-	addrIndex := s.server.addrIndex
-	if addrIndex == nil {
+// decodeAddressOrKeyID decodes the passed string which may either be an address
+// or a key ID.  Key IDs are only considered when the string is not a valid
+// address.  The returned address is nil when a key ID was decoded.
+func decodeAddressOrKeyID(s *rpcServer, str string) (provautil.Address, btcec.KeyID, error) {
+	addr, err := provautil.DecodeAddress(str, s.server.chainParams)
+	if err == nil {
+		return addr, 0, nil
+	}
+	keyID, keyErr := strconv.ParseUint(str, 10, 32)
+	if keyErr != nil {
+		return nil, 0, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+	return nil, btcec.KeyID(keyID), nil
+}
+
+// handleGetAddressBalance implements the getaddressbalance command.
+func handleGetAddressBalance(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the address balance index is not enabled.
+	addrBalanceIndex := s.server.addrBalanceIndex
+	if !s.server.indexEnabled(addrBalanceIndex) {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCMisc,
-			Message: "Address index must be enabled (--addrindex)",
+			Message: "Address balance index must be enabled (--addrbalanceindex)",
 		}
 	}
 
-	c := cmd.(*btcjson.GetAddressTxIdsCmd)
-
-	start := uint32(0)
-	if c.Request.Start > 0 {
-		start = c.Request.Start + 1
+	c := cmd.(*btcjson.GetAddressBalanceCmd)
+	addr, keyID, err := decodeAddressOrKeyID(s, c.Address)
+	if err != nil {
+		return nil, err
 	}
 
-	end := uint32(1<<32 - 1)
-	if c.Request.End > 0 {
-		end = c.Request.End + 1
+	var balance indexers.AddrBalance
+	if addr != nil {
+		balance, err = addrBalanceIndex.BalanceForAddress(addr)
+	} else {
+		balance, err = addrBalanceIndex.BalanceForKeyID(keyID)
+	}
+	if err != nil {
+		context := "Failed to load address balance"
+		return nil, internalRPCError(err.Error(), context)
 	}
 
-	if start > end {
+	return &btcjson.GetAddressBalanceResult{
+		Balance:   provautil.Amount(balance.Balance).ToRMG(),
+		UtxoCount: balance.NumUtxos,
+	}, nil
+}
+
+// handleGetAddressUtxos implements the getaddressutxos command.
+func handleGetAddressUtxos(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the address balance index is not enabled.
+	addrBalanceIndex := s.server.addrBalanceIndex
+	if !s.server.indexEnabled(addrBalanceIndex) {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCMisc,
-			Message: "End height must not be less than the start height.",
+			Message: "Address balance index must be enabled (--addrbalanceindex)",
 		}
 	}
 
-	// Attempt to decode the supplied addresses.
-	addressTxns := make([]retrievedTx, 0)
-	for _, address := range c.Request.Addresses {
-		addr, err := provautil.DecodeAddress(address, s.server.chainParams)
-		if err != nil {
-			return nil, &btcjson.RPCError{
-				Code:    btcjson.ErrRPCInvalidAddressOrKey,
-				Message: "Invalid address or key: " + err.Error(),
-			}
-		}
+	c := cmd.(*btcjson.GetAddressUtxosCmd)
+	addr, keyID, err := decodeAddressOrKeyID(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
 
-		err = s.server.db.View(func(dbTx database.Tx) error {
-			regions, err := addrIndex.BoundedTxRegionsForAddress(
-				dbTx, addr, start, end)
-			if err != nil {
-				return err
-			}
+	var utxos []indexers.AddrUtxo
+	if addr != nil {
+		utxos, err = addrBalanceIndex.UtxosForAddress(addr)
+	} else {
+		utxos, err = addrBalanceIndex.UtxosForKeyID(keyID)
+	}
+	if err != nil {
+		context := "Failed to load address unspent outputs"
+		return nil, internalRPCError(err.Error(), context)
+	}
 
-			// Load the raw transaction bytes from the database.
-			serializedTxns, err := dbTx.FetchBlockRegions(regions)
-			if err != nil {
-				return err
-			}
+	results := make([]btcjson.AddressUtxoResult, 0, len(utxos))
+	for i := range utxos {
+		utxo := &utxos[i]
+		results = append(results, btcjson.AddressUtxoResult{
+			Txid:         utxo.OutPoint.Hash.String(),
+			Vout:         utxo.OutPoint.Index,
+			Height:       utxo.Height,
+			Value:        provautil.Amount(utxo.Value).ToRMG(),
+			ScriptPubKey: hex.EncodeToString(utxo.PkScript),
+		})
+	}
 
-			// Add the transaction and the hash of the block it is
-			// contained in to the list.
-			for i, serializedTx := range serializedTxns {
-				addressTxns = append(addressTxns, retrievedTx{
-					txBytes: serializedTx,
-					blkHash: regions[i].Hash,
-				})
+	return results, nil
+}
+
+// handleGetAddressIssuance implements the getaddressissuance command.
+func handleGetAddressIssuance(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the issuance index is not enabled.
+	issuanceIndex := s.server.issuanceIndex
+	if !s.server.indexEnabled(issuanceIndex) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Issuance index must be enabled (--issuanceindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetAddressIssuanceCmd)
+	addr, err := provautil.DecodeAddress(c.Address, s.server.chainParams)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+
+	var startHeight uint32
+	if c.StartHeight != nil {
+		startHeight = *c.StartHeight
+	}
+	endHeight := s.chain.BestSnapshot().Height
+	if c.EndHeight != nil {
+		endHeight = *c.EndHeight
+	}
+	if startHeight > endHeight {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "End height must not be less than the start height.",
+		}
+	}
+
+	entries, err := issuanceIndex.EntriesForAddress(addr, startHeight,
+		endHeight)
+	if err != nil {
+		context := "Failed to load issuance index entries"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	results := make([]btcjson.AddressIssuanceResult, 0, len(entries))
+	for i := range entries {
+		entry := &entries[i]
+		result := btcjson.AddressIssuanceResult{
+			Type:   entry.Type.String(),
+			Height: entry.Height,
+			Txid:   entry.TxHash.String(),
+			Index:  entry.Index,
+			Value:  provautil.Amount(entry.Value).ToRMG(),
+		}
+		if entry.Type == indexers.IssuanceEntryDestroy {
+			prevVout := entry.PrevOut.Index
+			result.PrevTxid = entry.PrevOut.Hash.String()
+			result.PrevVout = &prevVout
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// handleGetAddressTxIds implements the getaddresstxids command.
+func handleGetAddressTxIds(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the address index is not enabled.
+	addrIndex := s.server.addrIndex
+	if !s.server.indexEnabled(addrIndex) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Address index must be enabled (--addrindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetAddressTxIdsCmd)
+
+	start := uint32(0)
+	if c.Request.Start > 0 {
+		start = c.Request.Start + 1
+	}
+
+	end := uint32(1<<32 - 1)
+	if c.Request.End > 0 {
+		end = c.Request.End + 1
+	}
+
+	if start > end {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "End height must not be less than the start height.",
+		}
+	}
+
+	// Attempt to decode the supplied addresses.
+	addressTxns := make([]retrievedTx, 0)
+	for _, address := range c.Request.Addresses {
+		addr, err := provautil.DecodeAddress(address, s.server.chainParams)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address or key: " + err.Error(),
+			}
+		}
+
+		err = s.server.db.View(func(dbTx database.Tx) error {
+			regions, err := addrIndex.BoundedTxRegionsForAddress(
+				dbTx, addr, start, end)
+			if err != nil {
+				return err
+			}
+
+			// Load the raw transaction bytes from the database.
+			serializedTxns, err := dbTx.FetchBlockRegions(regions)
+			if err != nil {
+				return err
+			}
+
+			// Add the transaction and the hash of the block it is
+			// contained in to the list.
+			for i, serializedTx := range serializedTxns {
+				addressTxns = append(addressTxns, retrievedTx{
+					txBytes: serializedTx,
+					blkHash: regions[i].Hash,
+				})
 			}
 
 			return nil
@@ -1216,6 +2566,161 @@ func handleGetBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 	return blockReply, nil
 }
 
+// deploymentName returns the name the getblockchaininfo command reports the
+// consensus rule change deployment with the passed ID by.
+func deploymentName(deploymentID int) (string, error) {
+	switch deploymentID {
+	case chaincfg.DeploymentTimelocks:
+		return "timelocks", nil
+	}
+	return "", fmt.Errorf("unknown deployment ID %d", deploymentID)
+}
+
+// verificationProgress estimates the fraction of the blocks of the chain which
+// are validated from the height of the best block, the height of the best known
+// header, the time of the best block, and the current time.  The blocks which
+// remain are either the headers which are known ahead of the blocks or, when
+// more, the blocks expected to be found since the best block at the target
+// spacing of the network.
+func verificationProgress(height, headersHeight uint32, blockTime,
+	now time.Time, targetSpacing time.Duration) float64 {
+
+	remaining := float64(headersHeight) - float64(height)
+	if elapsed := now.Sub(blockTime); targetSpacing > 0 && elapsed > 0 {
+		expected := float64(elapsed / targetSpacing)
+		if expected > remaining {
+			remaining = expected
+		}
+	}
+	if remaining <= 0 {
+		return 1
+	}
+	return float64(height) / (float64(height) + remaining)
+}
+
+// dirSize returns the total size of the files in the passed directory and its
+// subdirectories.  Directories which do not exist, such as those of in-memory
+// databases, have a size of zero.
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// handleGetBlockChainInfo implements the getblockchaininfo command.
+func handleGetBlockChainInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// The chain only knows about blocks, so report the end of the headers
+	// which were synced ahead of their blocks, if any.
+	best := s.chain.BestSnapshot()
+	headers := best.Height
+	if node := s.server.blockManager.HeadersTip(); node != nil &&
+		node.height > headers {
+
+		headers = node.height
+	}
+
+	bestHeader, err := s.chain.FetchHeader(best.Hash)
+	if err != nil {
+		context := "Failed to fetch best block header"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	sizeOnDisk, err := dirSize(blockDbPath(cfg.DbType))
+	if err != nil {
+		context := "Failed to obtain size of block database"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	chainParams := s.server.chainParams
+	result := &btcjson.GetBlockChainInfoResult{
+		Chain:         chainParams.Name,
+		Blocks:        best.Height,
+		Headers:       headers,
+		BestBlockHash: best.Hash.String(),
+		Difficulty:    getDifficultyRatio(best.Bits),
+		MedianTime:    best.MedianTime.Unix(),
+		VerificationProgress: verificationProgress(best.Height, headers,
+			bestHeader.Timestamp, s.server.timeSource.AdjustedTime(),
+			chainParams.TargetTimePerBlock),
+		InitialBlockDownload: !s.server.blockManager.IsCurrent(),
+		ChainWork:            fmt.Sprintf("%064x", best.WorkSum),
+		SizeOnDisk:           sizeOnDisk,
+		SoftForks:            make(map[string]*btcjson.SoftForkDescription),
+		Bip9SoftForks:        make(map[string]*btcjson.Bip9SoftForkDescription),
+	}
+
+	// The data of the blocks more than the prune depth behind the best
+	// block is removed when pruning is enabled, so the first block whose
+	// data is retained is the one at the prune depth.
+	if pruneDepth := s.chain.PruneDepth(); pruneDepth != 0 {
+		var pruneHeight uint32
+		if best.Height > pruneDepth {
+			pruneHeight = best.Height - pruneDepth
+		}
+		automaticPruning := true
+		result.Pruned = true
+		result.PruneHeight = &pruneHeight
+		result.AutomaticPruning = &automaticPruning
+	}
+
+	// Report the state of each consensus rule change deployment for the
+	// next block.  A deployment which reached its activation height is
+	// active regardless of its signaling.
+	for id := 0; id < chaincfg.DefinedDeployments; id++ {
+		name, err := deploymentName(id)
+		if err != nil {
+			context := "Failed to name deployment"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		state, err := s.chain.ThresholdState(id)
+		if err != nil {
+			context := "Failed to obtain deployment status"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		active, err := s.chain.IsDeploymentActive(id)
+		if err != nil {
+			context := "Failed to obtain deployment status"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		status := strings.ToLower(strings.TrimPrefix(state.String(),
+			"Threshold"))
+		if active {
+			status = "active"
+		}
+
+		deployment := &chainParams.Deployments[id]
+		description := &btcjson.Bip9SoftForkDescription{
+			Status:    status,
+			Bit:       deployment.BitNumber,
+			StartTime: deployment.StartTime,
+			Timeout:   deployment.ExpireTime,
+		}
+		if deployment.ActivationHeight != chaincfg.DeploymentNeverActive {
+			activationHeight := deployment.ActivationHeight
+			description.ActivationHeight = &activationHeight
+		}
+		result.Bip9SoftForks[name] = description
+		result.SoftForks[name] = &btcjson.SoftForkDescription{
+			Type:   "bip9",
+			Bip9:   description,
+			Height: description.ActivationHeight,
+			Active: active,
+		}
+	}
+
+	return result, nil
+}
+
 // handleGetBlockCount implements the getblockcount command.
 func handleGetBlockCount(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	best := s.chain.BestSnapshot()
@@ -1236,6 +2741,38 @@ func handleGetBlockHash(s *rpcServer, cmd interface{}, closeChan <-chan struct{}
 	return hash.String(), nil
 }
 
+// handleGetBlockHashByTime implements the getblockhashbytime command.
+func handleGetBlockHashByTime(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the block time index is not enabled.
+	timeIndex := s.server.timeIndex
+	if !s.server.indexEnabled(timeIndex) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Block time index must be enabled (--timeindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetBlockHashByTimeCmd)
+	hash, height, blockTime, err := timeIndex.BlockByTime(
+		time.Unix(c.Timestamp, 0))
+	if err != nil {
+		context := "Failed to load block time index entry"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if hash == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCOutOfRange,
+			Message: "No block at or before the requested time",
+		}
+	}
+
+	return &btcjson.GetBlockHashByTimeResult{
+		Hash:   hash.String(),
+		Height: height,
+		Time:   blockTime.Unix(),
+	}, nil
+}
+
 // handleGetBlockHeader implements the getblockheader command.
 func handleGetBlockHeader(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetBlockHeaderCmd)
@@ -1510,9 +3047,21 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 		best := s.server.blockManager.chain.BestSnapshot()
 		minTimestamp := mining.MinimumMedianTime(best)
 
+		// Get the validate keys which are able to sign the block and
+		// the admin threads it extends, since they only change along
+		// with the template.
+		validateKeys, err := gbtValidateKeys(s.chain)
+		if err != nil {
+			context := "Failed to get validate keys"
+			return internalRPCError(err.Error(), context)
+		}
+		adminThreads := gbtAdminThreads(s.chain.ThreadTips(), msgBlock)
+
 		// Update work state to ensure another block template isn't
 		// generated until needed.
 		state.template = template
+		state.validateKeys = validateKeys
+		state.adminThreads = adminThreads
 		state.lastGenerated = time.Now()
 		state.lastTxUpdate = lastTxUpdate
 		state.prevHash = latestHash
@@ -1567,7 +3116,10 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 		// Update the time of the block template to the current time
 		// while accounting for the median time of the past several
 		// blocks per the chain consensus rules.
-		s.generator.UpdateBlockTime(msgBlock, nil)
+		if err := s.generator.UpdateBlockTime(msgBlock, nil); err != nil {
+			context := "Failed to update block time"
+			return internalRPCError(err.Error(), context)
+		}
 		msgBlock.Header.Nonce = 0
 
 		rpcsLog.Debugf("Updated block template (timestamp %v, "+
@@ -1578,6 +3130,62 @@ func (state *gbtWorkState) updateBlockTemplate(s *rpcServer, useCoinbaseValue bo
 	return nil
 }
 
+// gbtValidateKeys returns the validate keys of the current best chain along
+// with whether each of them is rate limited from signing the next block and the
+// number of recent blocks it signed.
+func gbtValidateKeys(chain *blockchain.BlockChain) ([]btcjson.GetBlockTemplateResultValidateKey, error) {
+	keySet := chain.AdminKeySets()[btcec.ValidateKeySet]
+	validateKeys := make([]btcjson.GetBlockTemplateResultValidateKey, 0,
+		len(keySet))
+	for i := range keySet {
+		var pubKey wire.BlockValidatingPubKey
+		copy(pubKey[:], keySet[i].SerializeCompressed())
+		rateLimited, err := chain.IsValidateKeyRateLimited(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		recentBlocks, err := chain.ValidateKeyShare(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		validateKeys = append(validateKeys,
+			btcjson.GetBlockTemplateResultValidateKey{
+				PubKey:       pubKey.String(),
+				RateLimited:  rateLimited,
+				RecentBlocks: recentBlocks,
+			})
+	}
+	return validateKeys, nil
+}
+
+// gbtAdminThreads returns the passed tips of the admin threads along with the
+// index of the transaction of the passed block which spends each of them, if
+// any.  Admin transactions added to the block must spend the tip of their
+// thread which has not been spent yet.
+func gbtAdminThreads(threadTips map[provautil.ThreadID]*wire.OutPoint, msgBlock *wire.MsgBlock) []btcjson.GetBlockTemplateResultAdminThread {
+	adminThreads := []btcjson.GetBlockTemplateResultAdminThread{
+		{ID: uint32(provautil.RootThread), Name: "root"},
+		{ID: uint32(provautil.ProvisionThread), Name: "provision"},
+		{ID: uint32(provautil.IssueThread), Name: "issue"},
+	}
+	for i := range adminThreads {
+		thread := &adminThreads[i]
+		tip := threadTips[provautil.ThreadID(thread.ID)]
+		if tip == nil {
+			continue
+		}
+		thread.OutPoint = tip.String()
+		for txIdx, tx := range msgBlock.Transactions {
+			for _, txIn := range tx.TxIn {
+				if txIn.PreviousOutPoint == *tip {
+					thread.SpentBy = int64(txIdx)
+				}
+			}
+		}
+	}
+	return adminThreads
+}
+
 // blockTemplateResult returns the current block template associated with the
 // state as a btcjson.GetBlockTemplateResult that is ready to be encoded to JSON
 // and returned to the caller.
@@ -1664,7 +3272,7 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 		Height:       int64(template.Height),
 		PreviousHash: header.PrevBlock.String(),
 		SigOpLimit:   blockchain.MaxSigOpsPerBlock,
-		SizeLimit:    wire.MaxBlockPayload,
+		SizeLimit:    int64(activeNetParams.BlockSizeLimit()),
 		Transactions: transactions,
 		Version:      header.Version,
 		LongPollID:   templateID,
@@ -1675,6 +3283,11 @@ func (state *gbtWorkState) blockTemplateResult(useCoinbaseValue bool, submitOld
 		Mutable:      gbtMutableFields,
 		NonceRange:   gbtNonceRange,
 		Capabilities: gbtCapabilities,
+
+		ValidateKeys:       state.validateKeys,
+		RateLimitWindow:    activeNetParams.PowAveragingWindow,
+		RateLimitMaxBlocks: activeNetParams.ChainWindowMaxBlocks,
+		AdminThreads:       state.adminThreads,
 	}
 	if useCoinbaseValue {
 		reply.CoinbaseAux = gbtCoinbaseAux
@@ -2060,6 +3673,129 @@ func handleGetBlockTemplateProposal(s *rpcServer, request *btcjson.TemplateReque
 	return nil, nil
 }
 
+// handleGetBlockScrubInfo implements the getblockscrubinfo command.
+func handleGetBlockScrubInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if block scrubbing is not enabled.
+	if s.server.blockScrubber == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Block scrubbing must be enabled (--scrubinterval)",
+		}
+	}
+
+	return s.server.blockScrubber.Info(), nil
+}
+
+// handleGetBlockStats implements the getblockstats command.
+func handleGetBlockStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Look up the main chain block by its hash or its height.
+	c := cmd.(*btcjson.GetBlockStatsCmd)
+	str := string(c.HashOrHeight)
+	var height uint32
+	if len(str) == chainhash.MaxHashStringSize {
+		hash, err := chainhash.NewHashFromStr(str)
+		if err != nil {
+			return nil, rpcDecodeHexError(str)
+		}
+		height, err = s.chain.BlockHeightByHash(hash)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found",
+			}
+		}
+	} else {
+		h, err := strconv.ParseUint(str, 10, 32)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Invalid block hash or height " + str,
+			}
+		}
+		height = uint32(h)
+	}
+	endHeight := height
+	if c.EndHeight != nil {
+		endHeight = *c.EndHeight
+	}
+	if endHeight < height {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "End height must not be less than the start height.",
+		}
+	}
+	if endHeight-height >= maxBlockStatsRange {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("No more than %d blocks may be "+
+				"requested", maxBlockStatsRange),
+		}
+	}
+	if endHeight > s.chain.BestSnapshot().Height {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCOutOfRange,
+			Message: "Block number out of range",
+		}
+	}
+
+	// The fee rates are only available from the fee stats index, so they
+	// are left out when it is not enabled or has not caught up yet.
+	feeStats := make(map[uint32]*indexers.BlockFeeStats)
+	feeStatsIndex := s.server.feeStatsIndex
+	if s.server.indexEnabled(feeStatsIndex) {
+		entries, err := feeStatsIndex.FeeStatsRange(height, endHeight)
+		if err != nil {
+			context := "Failed to load block fee stats"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		for i := range entries {
+			feeStats[entries[i].Height] = &entries[i]
+		}
+	}
+
+	results := make([]btcjson.GetBlockStatsResult, 0, endHeight-height+1)
+	for h := height; h <= endHeight; h++ {
+		stats, err := s.chain.BlockStats(h)
+		if err != nil {
+			context := "Failed to load block stats"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		result := btcjson.GetBlockStatsResult{
+			AvgTxSize: int64(stats.AvgTxSize),
+			BlockHash: stats.Hash.String(),
+			Height:    stats.Height,
+			Ins:       uint32(stats.NumInputs),
+			Outs:      uint32(stats.NumOutputs),
+			Time:      stats.Timestamp.Unix(),
+			TotalFee:  stats.TotalFees,
+			TotalOut:  stats.TotalOut,
+			Txs:       uint32(stats.NumTxns),
+			AdminTxs:  uint32(stats.NumAdminTxns),
+		}
+		if fees, ok := feeStats[h]; ok {
+			var avgFee, avgFeeRate int64
+			if fees.NumStandardTxns > 0 {
+				avgFee = fees.TotalFee / int64(fees.NumStandardTxns)
+				avgFeeRate = fees.TotalFee / int64(fees.TotalSize)
+			}
+			result.AvgFee = &avgFee
+			result.AvgFeeRate = &avgFeeRate
+			result.FeeRatePercentiles = fees.FeeRates[:]
+			result.MaxFeeRate = &fees.MaxFeeRate
+			result.MinFeeRate = &fees.MinFeeRate
+			result.TotalSize = &fees.TotalSize
+		}
+		results = append(results, result)
+	}
+
+	// A single block is returned as an object rather than an array.
+	if c.EndHeight == nil {
+		return &results[0], nil
+	}
+	return results, nil
+}
+
 // handleGetBlockTemplate implements the getblocktemplate command.
 //
 // See https://en.bitcoin.it/wiki/BIP_0022 and
@@ -2172,6 +3908,34 @@ func handleGetHeaders(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 	return hexBlockHeaders, nil
 }
 
+// handleGetIndexInfo implements the getindexinfo command.
+func handleGetIndexInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// There are no indexes to report on when none are enabled.
+	indexManager := s.server.indexManager
+	if indexManager == nil {
+		return []btcjson.GetIndexInfoResult{}, nil
+	}
+
+	statuses, err := indexManager.IndexStatuses()
+	if err != nil {
+		context := "Failed to load index status"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	results := make([]btcjson.GetIndexInfoResult, 0, len(statuses))
+	for _, status := range statuses {
+		results = append(results, btcjson.GetIndexInfoResult{
+			Name:            status.Name,
+			Key:             status.Key,
+			Enabled:         status.Enabled,
+			Height:          status.Height,
+			Synced:          status.Synced,
+			PercentComplete: status.PercentComplete,
+		})
+	}
+	return results, nil
+}
+
 // handleGetInfo implements the getinfo command. We only return the fields
 // that are not related to wallet functionality.
 func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
@@ -2185,20 +3949,196 @@ func handleGetInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (in
 		Proxy:           cfg.Proxy,
 		Difficulty:      getDifficultyRatio(best.Bits),
 		TestNet:         cfg.TestNet,
-		RelayFee:        cfg.minRelayTxFee.ToRMG(),
+		RelayFee:        settings().minRelayTxFee.ToRMG(),
 	}
 
 	return ret, nil
 }
 
-// handleGetMempoolInfo implements the getmempoolinfo command.
-func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	mempoolTxns := s.server.txMemPool.TxDescs()
+// handleGetIssuanceInfo implements the getissuanceinfo command.
+func handleGetIssuanceInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	best := s.chain.BestSnapshot()
+	info, err := s.chain.IssuanceInfo()
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDatabase,
+			Message: "Failed to fetch issuance info: " + err.Error(),
+		}
+	}
+	return &btcjson.GetIssuanceInfoResult{
+		Hash:               best.Hash.String(),
+		Height:             best.Height,
+		Supply:             info.Supply,
+		TotalIssued:        info.TotalIssued,
+		TotalDestroyed:     info.TotalDestroyed,
+		LastIssuanceHeight: info.LastIssuanceHeight,
+		HistoryStart:       info.HistoryStart,
+	}, nil
+}
 
-	var numBytes int64
-	for _, txD := range mempoolTxns {
-		numBytes += int64(txD.Tx.MsgTx().SerializeSize())
-	}
+// handleGetKeyIDActivity implements the getkeyidactivity command.
+func handleGetKeyIDActivity(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the key ID activity index is not enabled.
+	keyIDIndex := s.server.keyIDIndex
+	if !s.server.indexEnabled(keyIDIndex) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Key ID activity index must be enabled (--keyidindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetKeyIDActivityCmd)
+	var startHeight uint32
+	if c.StartHeight != nil {
+		startHeight = *c.StartHeight
+	}
+	endHeight := s.chain.BestSnapshot().Height
+	if c.EndHeight != nil {
+		endHeight = *c.EndHeight
+	}
+	if startHeight > endHeight {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "End height must not be less than the start height.",
+		}
+	}
+
+	activities, err := keyIDIndex.ActivityForKeyID(btcec.KeyID(c.KeyID),
+		startHeight, endHeight)
+	if err != nil {
+		context := "Failed to load key ID activity index entries"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	results := make([]btcjson.KeyIDActivityResult, 0, len(activities))
+	for i := range activities {
+		activity := &activities[i]
+		result := btcjson.KeyIDActivityResult{
+			Type:   activity.Type.String(),
+			Height: activity.Height,
+			Txid:   activity.TxHash.String(),
+			Index:  activity.Index,
+			Value:  provautil.Amount(activity.Value).ToRMG(),
+		}
+		if activity.Type == indexers.KeyIDOutputSpent {
+			prevVout := activity.PrevOut.Index
+			result.PrevTxid = activity.PrevOut.Hash.String()
+			result.PrevVout = &prevVout
+		}
+
+		// Ignore the error here since an error means the script
+		// couldn't parse and there is no additional information about
+		// it anyways.
+		_, addrs, _, _ := txscript.ExtractPkScriptAddrs(
+			activity.PkScript, s.server.chainParams)
+		if len(addrs) > 0 {
+			result.Address = addrs[0].EncodeAddress()
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// mempoolRelativesResult returns the passed related memory pool transactions
+// as a sorted array of their hashes unless verbose is set, in which case they
+// are returned as is.
+func mempoolRelativesResult(relatives map[string]*btcjson.GetMempoolEntryResult, verbose *bool) interface{} {
+	if verbose != nil && *verbose {
+		return relatives
+	}
+
+	hashStrings := make([]string, 0, len(relatives))
+	for hash := range relatives {
+		hashStrings = append(hashStrings, hash)
+	}
+	sort.Strings(hashStrings)
+	return hashStrings
+}
+
+// handleGetKeyIDInfo implements the getkeyidinfo command.
+func handleGetKeyIDInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetKeyIDInfoCmd)
+	keyID := btcec.KeyID(c.KeyID)
+	if keyID == 0 || keyID > s.chain.LastKeyID() {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Key ID %d has not been provisioned",
+				keyID),
+		}
+	}
+
+	info, err := s.chain.KeyIDInfo(keyID)
+	if err != nil {
+		context := "Failed to load key ID registry entry"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	result := &btcjson.GetKeyIDInfoResult{
+		KeyID:           uint32(info.KeyID),
+		ProvisionHeight: info.ProvisionHeight,
+		Revoked:         info.Revoked,
+		RevokeHeight:    info.RevokeHeight,
+	}
+	if info.PubKey != nil {
+		result.PubKey = hex.EncodeToString(info.PubKey.SerializeCompressed())
+	}
+	return result, nil
+}
+
+// handleGetMempoolAncestors implements the getmempoolancestors command.
+func handleGetMempoolAncestors(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolAncestorsCmd)
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	ancestors, err := s.server.txMemPool.MempoolAncestors(txHash)
+	if err != nil {
+		return nil, rpcNoTxInfoError(txHash)
+	}
+	return mempoolRelativesResult(ancestors, c.Verbose), nil
+}
+
+// handleGetMempoolDescendants implements the getmempooldescendants command.
+func handleGetMempoolDescendants(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolDescendantsCmd)
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	descendants, err := s.server.txMemPool.MempoolDescendants(txHash)
+	if err != nil {
+		return nil, rpcNoTxInfoError(txHash)
+	}
+	return mempoolRelativesResult(descendants, c.Verbose), nil
+}
+
+// handleGetMempoolEntry implements the getmempoolentry command.
+func handleGetMempoolEntry(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetMempoolEntryCmd)
+	txHash, err := chainhash.NewHashFromStr(c.TxID)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.TxID)
+	}
+
+	entry, err := s.server.txMemPool.MempoolEntry(txHash)
+	if err != nil {
+		return nil, rpcNoTxInfoError(txHash)
+	}
+	return entry, nil
+}
+
+// handleGetMempoolInfo implements the getmempoolinfo command.
+func handleGetMempoolInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	mempoolTxns := s.server.txMemPool.TxDescs()
+
+	var numBytes int64
+	for _, txD := range mempoolTxns {
+		numBytes += int64(txD.Tx.MsgTx().SerializeSize())
+	}
 
 	ret := &btcjson.GetMempoolInfoResult{
 		Size:  int64(len(mempoolTxns)),
@@ -2246,10 +4186,13 @@ func handleGetMiningInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{
 // handleGetNetTotals implements the getnettotals command.
 func handleGetNetTotals(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	totalBytesRecv, totalBytesSent := s.server.NetTotals()
+	bytesRecvPerMsg, bytesSentPerMsg := s.server.NetTotalsPerMsg()
 	reply := &btcjson.GetNetTotalsResult{
-		TotalBytesRecv: totalBytesRecv,
-		TotalBytesSent: totalBytesSent,
-		TimeMillis:     time.Now().UTC().UnixNano() / int64(time.Millisecond),
+		TotalBytesRecv:  totalBytesRecv,
+		TotalBytesSent:  totalBytesSent,
+		TimeMillis:      time.Now().UTC().UnixNano() / int64(time.Millisecond),
+		BytesRecvPerMsg: bytesRecvPerMsg,
+		BytesSentPerMsg: bytesSentPerMsg,
 	}
 	return reply, nil
 }
@@ -2348,9 +4291,116 @@ func handleGetNetworkHashPS(s *rpcServer, cmd interface{}, closeChan <-chan stru
 	return hashesPerSec.Int64(), nil
 }
 
+// serviceFlagNames maps the service flags to the names the getnetworkinfo
+// command reports them by, which are those of the reference implementation.
+var serviceFlagNames = map[wire.ServiceFlag]string{
+	wire.SFNodeNetwork: "NETWORK",
+	wire.SFNodeGetUTXO: "GETUTXO",
+	wire.SFNodeBloom:   "BLOOM",
+	wire.SFNodeCF:      "COMPACT_FILTERS",
+	wire.SFNodeTLS:     "TLS",
+}
+
+// serviceNames returns the names of the passed service flags ordered by their
+// bits.  Unknown flags are named by their bit.
+func serviceNames(services wire.ServiceFlag) []string {
+	names := make([]string, 0)
+	for bit := uint(0); bit < 64; bit++ {
+		flag := wire.ServiceFlag(1) << bit
+		if services&flag == 0 {
+			continue
+		}
+		name, ok := serviceFlagNames[flag]
+		if !ok {
+			name = fmt.Sprintf("UNKNOWN[%d]", bit)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+// handleGetNetworkInfo implements the getnetworkinfo command.
+func handleGetNetworkInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	var inbound, outbound int32
+	for _, sp := range s.server.Peers() {
+		if sp.Inbound() {
+			inbound++
+		} else {
+			outbound++
+		}
+	}
+
+	// Onion addresses are reachable through the onion proxy or, unless
+	// it is disabled, the regular proxy.
+	onionProxy := cfg.OnionProxy
+	if onionProxy == "" && !cfg.NoOnion {
+		onionProxy = cfg.Proxy
+	}
+	onionLimited := cfg.NoOnion || onionProxy == ""
+	networks := []btcjson.NetworksResult{
+		{
+			Name:                      "ipv4",
+			Reachable:                 true,
+			Proxy:                     cfg.Proxy,
+			ProxyRandomizeCredentials: cfg.TorIsolation,
+		},
+		{
+			Name:                      "ipv6",
+			Reachable:                 true,
+			Proxy:                     cfg.Proxy,
+			ProxyRandomizeCredentials: cfg.TorIsolation,
+		},
+		{
+			Name:                      "onion",
+			Limited:                   onionLimited,
+			Reachable:                 !onionLimited,
+			Proxy:                     onionProxy,
+			ProxyRandomizeCredentials: cfg.TorIsolation,
+		},
+	}
+
+	localAddrs := s.server.addrManager.LocalAddresses()
+	localAddresses := make([]btcjson.LocalAddressesResult, 0, len(localAddrs))
+	for _, la := range localAddrs {
+		localAddresses = append(localAddresses, btcjson.LocalAddressesResult{
+			Address: la.NetAddress.IP.String(),
+			Port:    la.NetAddress.Port,
+			Score:   int32(la.Score),
+		})
+	}
+
+	// The sub-version is the user agent peers are sent with the version
+	// message.
+	versionMsg := wire.NewMsgVersion(&wire.NetAddress{}, &wire.NetAddress{},
+		0, 0)
+	versionMsg.AddUserAgent(userAgentName, userAgentVersion)
+
+	services := s.server.services
+	relayFee := settings().minRelayTxFee.ToRMG()
+	return &btcjson.GetNetworkInfoResult{
+		Version: int32(1000000*appMajor + 10000*appMinor +
+			100*appPatch),
+		SubVersion:         versionMsg.UserAgent,
+		ProtocolVersion:    int32(maxProtocolVersion),
+		LocalServices:      fmt.Sprintf("%016x", uint64(services)),
+		LocalServicesNames: serviceNames(services),
+		LocalRelay:         !cfg.BlocksOnly,
+		TimeOffset:         int64(s.server.timeSource.Offset().Seconds()),
+		NetworkActive:      true,
+		Connections:        inbound + outbound,
+		ConnectionsIn:      inbound,
+		ConnectionsOut:     outbound,
+		Networks:           networks,
+		RelayFee:           relayFee,
+		IncrementalFee:     relayFee,
+		LocalAddresses:     localAddresses,
+	}, nil
+}
+
 // handleGetPeerInfo implements the getpeerinfo command.
 func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	peers := s.server.Peers()
+	protection := s.server.EvictionProtection()
 	syncPeer := s.server.blockManager.SyncPeer()
 	infos := make([]*btcjson.GetPeerInfoResult, 0, len(peers))
 	for _, p := range peers {
@@ -2367,15 +4417,22 @@ func handleGetPeerInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 			BytesRecv:      statsSnap.BytesRecv,
 			ConnTime:       statsSnap.ConnTime.Unix(),
 			PingTime:       float64(statsSnap.LastPingMicros),
+			MinPing:        float64(statsSnap.MinPingMicros),
 			TimeOffset:     statsSnap.TimeOffset,
 			Version:        statsSnap.Version,
 			SubVer:         statsSnap.UserAgent,
 			Inbound:        statsSnap.Inbound,
+			Encrypted:      p.encrypted,
+			BlockRelayOnly: p.blockRelayOnly,
 			StartingHeight: statsSnap.StartingHeight,
 			CurrentHeight:  statsSnap.LastBlock,
 			BanScore:       int32(p.banScore.Int()),
 			FeeFilter:      atomic.LoadInt64(&p.feeFilter),
 			SyncNode:       p == syncPeer,
+			ProtectedBy:    protection[statsSnap.ID],
+		}
+		if lastNewBlock := p.lastNewBlockTime(); !lastNewBlock.IsZero() {
+			info.LastBlockTime = lastNewBlock.Unix()
 		}
 		if p.LastPingNonce() != 0 {
 			wait := float64(time.Since(statsSnap.LastPingTime).Nanoseconds())
@@ -2430,7 +4487,7 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 	tx, err := s.server.txMemPool.FetchTransaction(txHash)
 	if err != nil {
 		txIndex := s.server.txIndex
-		if txIndex == nil {
+		if !s.server.indexEnabled(txIndex) {
 			return nil, &btcjson.RPCError{
 				Code: btcjson.ErrRPCNoTxInfo,
 				Message: "The transaction index must be " +
@@ -2527,6 +4584,143 @@ func handleGetRawTransaction(s *rpcServer, cmd interface{}, closeChan <-chan str
 	return *rawTxn, nil
 }
 
+// handleGetReceivedByAddress implements the getreceivedbyaddress command for
+// addresses and key IDs watched by the watch-only index.
+func handleGetReceivedByAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	watchIndex, err := watchIndexRequired(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GetReceivedByAddressCmd)
+	target, err := decodeWatchTarget(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+	minConf := 1
+	if c.MinConf != nil {
+		minConf = *c.MinConf
+	}
+	entries, _, err := watchIndex.History(target, 0, math.MaxUint32, false)
+	if err != nil {
+		context := "Failed to load watched history"
+		return nil, watchedQueryError(err, context)
+	}
+
+	bestHeight := s.chain.BestSnapshot().Height
+	var received int64
+	for i := range entries {
+		entry := &entries[i]
+		if int64(bestHeight)-int64(entry.Height)+1 < int64(minConf) {
+			continue
+		}
+		received += entry.Received
+	}
+	return provautil.Amount(received).ToRMG(), nil
+}
+
+// handleGetRescanInfo implements the getrescaninfo command.
+func handleGetRescanInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	return s.rescans.Info(), nil
+}
+
+// handleGetSpentInfo implements the getspentinfo command.
+func handleGetSpentInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the spent index is not enabled.
+	spentIndex := s.server.spentIndex
+	if !s.server.indexEnabled(spentIndex) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Spent index must be enabled (--spentindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetSpentInfoCmd)
+
+	// Convert the provided transaction hash hex to a Hash.
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Txid)
+	}
+
+	outpoint := wire.NewOutPoint(txHash, c.Index)
+	info, err := spentIndex.SpentInfo(outpoint)
+	if err != nil {
+		context := "Failed to load spent index entry"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if info == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCNoTxInfo,
+			Message: "No spent information for " + outpoint.String(),
+		}
+	}
+
+	return &btcjson.GetSpentInfoResult{
+		Txid:   info.TxHash.String(),
+		Index:  info.InputIndex,
+		Height: info.Height,
+	}, nil
+}
+
+// handleGetTxSpendingInfo implements the gettxspendinginfo command.
+func handleGetTxSpendingInfo(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the transaction index, which tells whether
+	// the transaction is in the main chain, or the spent index, which
+	// houses the spends, is not enabled.
+	txIndex := s.server.txIndex
+	if !s.server.indexEnabled(txIndex) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Transaction index must be enabled (--txindex)",
+		}
+	}
+	spentIndex := s.server.spentIndex
+	if !s.server.indexEnabled(spentIndex) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Spent index must be enabled (--spentindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.GetTxSpendingInfoCmd)
+
+	// Convert the provided transaction hash hex to a Hash.
+	txHash, err := chainhash.NewHashFromStr(c.Txid)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Txid)
+	}
+
+	// Only transactions in the main chain have spending information.
+	blockRegion, err := txIndex.TxBlockRegion(txHash)
+	if err != nil {
+		context := "Failed to retrieve transaction location"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if blockRegion == nil {
+		return nil, rpcNoTxInfoError(txHash)
+	}
+
+	spends, err := spentIndex.TxSpends(txHash)
+	if err != nil {
+		context := "Failed to load transaction spends"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	result := &btcjson.GetTxSpendingInfoResult{
+		Txid:   c.Txid,
+		Spends: make([]btcjson.TxOutSpendResult, 0, len(spends)),
+	}
+	for _, spend := range spends {
+		result.Spends = append(result.Spends, btcjson.TxOutSpendResult{
+			Vout:         spend.OutputIndex,
+			SpendingTxid: spend.SpentBy.TxHash.String(),
+			Vin:          spend.SpentBy.InputIndex,
+			Height:       spend.SpentBy.Height,
+		})
+	}
+	return result, nil
+}
+
 // handleGetTxOut handles gettxout commands.
 func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.GetTxOutCmd)
@@ -2633,46 +4827,907 @@ func handleGetTxOut(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (i
 		},
 		Coinbase: isCoinbase,
 	}
-	return txOutReply, nil
+	return txOutReply, nil
+}
+
+// cfIndexEnabled returns an error when the compact block filter index is not
+// enabled or the passed filter type is not served.
+func cfIndexEnabled(s *rpcServer, filterType uint8) error {
+	if !s.server.indexEnabled(s.server.cfIndex) {
+		return &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Compact block filter index must be enabled (--cfindex)",
+		}
+	}
+	if wire.FilterType(filterType) != wire.GCSFilterBasic {
+		return &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("Unknown filter type %d", filterType),
+		}
+	}
+	return nil
+}
+
+// handleGetCFilter implements the getcfilter command.
+func handleGetCFilter(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetCFilterCmd)
+	if err := cfIndexEnabled(s, *c.FilterType); err != nil {
+		return nil, err
+	}
+
+	hash, err := chainhash.NewHashFromStr(c.Hash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.Hash)
+	}
+	entries, err := s.server.cfIndex.Entries([]chainhash.Hash{*hash})
+	if err != nil {
+		context := "Failed to load compact block filter"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	if entries[0] == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found in the main chain",
+		}
+	}
+	return hex.EncodeToString(entries[0].Filter), nil
+}
+
+// handleGetCFHeaders implements the getcfheaders command.
+func handleGetCFHeaders(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetCFHeadersCmd)
+	if err := cfIndexEnabled(s, *c.FilterType); err != nil {
+		return nil, err
+	}
+
+	stopHash, err := chainhash.NewHashFromStr(c.StopHash)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.StopHash)
+	}
+	stopHeight, err := s.chain.BlockHeightByHash(stopHash)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCBlockNotFound,
+			Message: "Block not found in the main chain",
+		}
+	}
+	if c.StartHeight > stopHeight {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Start height must not be greater than the " +
+				"height of the stop block",
+		}
+	}
+	if stopHeight-c.StartHeight >= wire.MaxCFHeadersPerMsg {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("No more than %d blocks may be "+
+				"requested", wire.MaxCFHeadersPerMsg),
+		}
+	}
+
+	// Fetch the entry of the block preceding the range as well since its
+	// filter header is included in the result.  The filter header
+	// preceding the genesis block is the zero hash.
+	startHeight := c.StartHeight
+	if startHeight > 0 {
+		startHeight--
+	}
+	hashes, err := s.chain.HeightRange(startHeight, stopHeight+1)
+	if err != nil {
+		context := "Failed to fetch block hashes"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	entries, err := s.server.cfIndex.Entries(hashes)
+	if err != nil {
+		context := "Failed to load compact block filters"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	for _, entry := range entries {
+		// The block was disconnected since the range was fetched.
+		if entry == nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found in the main chain",
+			}
+		}
+	}
+	var prevHeader chainhash.Hash
+	if c.StartHeight > 0 {
+		prevHeader = entries[0].Header
+		entries = entries[1:]
+	}
+
+	result := &btcjson.GetCFHeadersResult{
+		StopHash:         stopHash.String(),
+		PrevFilterHeader: prevHeader.String(),
+		FilterHashes:     make([]string, 0, len(entries)),
+		FilterHeaders:    make([]string, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		result.FilterHashes = append(result.FilterHashes,
+			entry.FilterHash.String())
+		result.FilterHeaders = append(result.FilterHeaders,
+			entry.Header.String())
+	}
+	return result, nil
+}
+
+// handleGetTxOutProof handles gettxoutproof commands.
+func handleGetTxOutProof(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetTxOutProofCmd)
+
+	if len(c.TxIDs) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "At least one transaction id must be provided",
+		}
+	}
+	txHashes := make([]chainhash.Hash, 0, len(c.TxIDs))
+	seen := make(map[chainhash.Hash]struct{}, len(c.TxIDs))
+	for _, txID := range c.TxIDs {
+		txHash, err := chainhash.NewHashFromStr(txID)
+		if err != nil {
+			return nil, rpcDecodeHexError(txID)
+		}
+		if _, ok := seen[*txHash]; ok {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Duplicate transaction id " + txID,
+			}
+		}
+		seen[*txHash] = struct{}{}
+		txHashes = append(txHashes, *txHash)
+	}
+
+	// Look up the block containing the first transaction when no block
+	// hash is provided.
+	var blkHash *chainhash.Hash
+	if c.BlockHash != nil {
+		var err error
+		blkHash, err = chainhash.NewHashFromStr(*c.BlockHash)
+		if err != nil {
+			return nil, rpcDecodeHexError(*c.BlockHash)
+		}
+	} else {
+		txIndex := s.server.txIndex
+		if !s.server.indexEnabled(txIndex) {
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCNoTxInfo,
+				Message: "The transaction index must be " +
+					"enabled to look up the block of the " +
+					"transactions (specify --txindex or " +
+					"provide the block hash)",
+			}
+		}
+		blockRegion, err := txIndex.TxBlockRegion(&txHashes[0])
+		if err != nil {
+			context := "Failed to retrieve transaction location"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		if blockRegion == nil {
+			return nil, rpcNoTxInfoError(&txHashes[0])
+		}
+		blkHash = blockRegion.Hash
+	}
+
+	// Use the merkle tree stored by the merkle tree index when the block
+	// has been indexed and fall back to hashing the block otherwise.
+	var layers [][]chainhash.Hash
+	if s.server.indexEnabled(s.server.merkleIndex) {
+		var err error
+		layers, err = s.server.merkleIndex.MerkleLayers(blkHash)
+		if err != nil {
+			context := "Failed to retrieve merkle tree"
+			return nil, internalRPCError(err.Error(), context)
+		}
+	}
+	var header wire.BlockHeader
+	if layers != nil {
+		var err error
+		header, err = s.chain.FetchHeader(blkHash)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found",
+			}
+		}
+	} else {
+		blk, err := s.chain.BlockByHash(blkHash)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCBlockNotFound,
+				Message: "Block not found",
+			}
+		}
+		header = blk.MsgBlock().Header
+		layers = indexers.MerkleLayersForBlock(blk)
+	}
+
+	// Locate the transactions in the block.
+	var matchedIndices []uint32
+	for txIndex, txHash := range layers[0] {
+		if _, ok := seen[txHash]; ok {
+			matchedIndices = append(matchedIndices, uint32(txIndex))
+		}
+	}
+	if len(matchedIndices) != len(txHashes) {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Not all transactions found in specified or " +
+				"retrieved block",
+		}
+	}
+
+	// Serialize the merkle block proving the transactions.
+	msg := bloom.NewMerkleBlockFromLayers(&header, layers, matchedIndices)
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, maxProtocolVersion); err != nil {
+		context := "Failed to serialize merkle block"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// durationMillis returns the passed duration in milliseconds.
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// handleGetCheckpointCandidates implements the getcheckpointcandidates command.
+func handleGetCheckpointCandidates(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.GetCheckpointCandidatesCmd)
+
+	count := *c.Count
+	if count < 1 || count > maxCheckpointCandidates {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: fmt.Sprintf("count must be between 1 and %d",
+				maxCheckpointCandidates),
+		}
+	}
+
+	candidates, err := s.chain.CheckpointCandidates(count)
+	if err != nil {
+		context := "Failed to find checkpoint candidates"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	result := make([]btcjson.CheckpointCandidateResult, 0, len(candidates))
+	for _, candidate := range candidates {
+		result = append(result, btcjson.CheckpointCandidateResult{
+			Height: candidate.Height,
+			Hash:   candidate.Hash.String(),
+			Time:   candidate.Timestamp.Unix(),
+		})
+	}
+	return result, nil
+}
+
+// handleGetChainTips implements the getchaintips command.
+func handleGetChainTips(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	tips, err := s.chain.ChainTips()
+	if err != nil {
+		context := "Failed to fetch chain tips"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// The chain only knows about blocks, so add the end of the headers
+	// which were synced ahead of their blocks, if any.
+	if node := s.server.blockManager.HeadersTip(); node != nil {
+		best := s.chain.BestSnapshot()
+		if node.height > best.Height {
+			tip := blockchain.ChainTip{
+				Hash:      *node.hash,
+				Height:    node.height,
+				BranchLen: node.height - best.Height,
+				Status:    blockchain.ChainTipHeadersOnly,
+			}
+			i := sort.Search(len(tips), func(i int) bool {
+				return tips[i].Height <= tip.Height
+			})
+			tips = append(tips, blockchain.ChainTip{})
+			copy(tips[i+1:], tips[i:])
+			tips[i] = tip
+		}
+	}
+
+	result := make([]btcjson.GetChainTipsResult, 0, len(tips))
+	for _, tip := range tips {
+		result = append(result, btcjson.GetChainTipsResult{
+			Height:    tip.Height,
+			Hash:      tip.Hash.String(),
+			BranchLen: tip.BranchLen,
+			Status:    tip.Status.String(),
+		})
+	}
+	return result, nil
+}
+
+// handleGetValidationTimings implements the getvalidationtimings command.
+func handleGetValidationTimings(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	timings := s.chain.ValidationTimings()
+
+	result := &btcjson.GetValidationTimingsResult{
+		Blocks: timings.Blocks,
+		Window: timings.Window,
+		Phases: make([]btcjson.ValidationPhaseResult, 0,
+			len(timings.Phases)),
+	}
+	if timings.Last != nil {
+		phases := make(map[string]float64, len(timings.Last.Phases))
+		for phase, d := range timings.Last.Phases {
+			phases[blockchain.ValidationPhase(phase).String()] =
+				durationMillis(d)
+		}
+		result.LastBlock = &btcjson.LastBlockTimingsResult{
+			Hash:   timings.Last.Hash.String(),
+			Height: timings.Last.Height,
+			Phases: phases,
+		}
+	}
+	for _, stats := range timings.Phases {
+		result.Phases = append(result.Phases, btcjson.ValidationPhaseResult{
+			Phase:   stats.Phase.String(),
+			Samples: stats.Samples,
+			Last:    durationMillis(stats.Last),
+			Mean:    durationMillis(stats.Mean),
+			Min:     durationMillis(stats.Min),
+			Max:     durationMillis(stats.Max),
+		})
+	}
+	return result, nil
+}
+
+// handleGetValidatorStats implements the getvalidatorstats command.
+func handleGetValidatorStats(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	best := s.chain.BestSnapshot()
+	stats, err := s.chain.ValidatorStats()
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDatabase,
+			Message: "Failed to fetch validator stats: " + err.Error(),
+		}
+	}
+
+	window := s.server.chainParams.PowAveragingWindow
+	result := &btcjson.GetValidatorStatsResult{
+		Hash:       best.Hash.String(),
+		Height:     best.Height,
+		Window:     window,
+		MaxBlocks:  s.server.chainParams.ChainWindowMaxBlocks,
+		Validators: make([]btcjson.ValidatorStatsResult, 0, len(stats)),
+	}
+	for i := range stats {
+		keyStats := &stats[i]
+		validator := btcjson.ValidatorStatsResult{
+			PubKey:      keyStats.PubKey.String(),
+			Blocks:      keyStats.WindowBlocks,
+			Share:       float64(keyStats.WindowBlocks) / float64(window),
+			Remaining:   keyStats.Remaining,
+			RateLimited: keyStats.Remaining == 0,
+		}
+		if keyStats.LastBlockHash != nil {
+			validator.LastBlockHeight = keyStats.LastBlockHeight
+			validator.LastBlockHash = keyStats.LastBlockHash.String()
+		}
+		result.Validators = append(result.Validators, validator)
+	}
+	return result, nil
+}
+
+// watchIndexRequired returns the watch-only index or an error when it is not
+// enabled.
+func watchIndexRequired(s *rpcServer) (*indexers.WatchIndex, error) {
+	watchIndex := s.server.watchIndex
+	if !s.server.indexEnabled(watchIndex) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Watch-only index must be enabled (--watchindex)",
+		}
+	}
+	return watchIndex, nil
+}
+
+// decodeWatchTarget decodes the passed string, which may either be an address or
+// a key ID, into the target tracked by the watch-only index.
+func decodeWatchTarget(s *rpcServer, str string) (indexers.WatchTarget, error) {
+	addr, keyID, err := decodeAddressOrKeyID(s, str)
+	if err != nil {
+		return indexers.WatchTarget{}, err
+	}
+	if addr == nil {
+		return indexers.NewKeyIDWatchTarget(keyID), nil
+	}
+	target, err := indexers.NewAddressWatchTarget(addr)
+	if err != nil {
+		return target, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid address or key: " + err.Error(),
+		}
+	}
+	return target, nil
+}
+
+// watchedQueryError converts an error returned when querying the watch-only
+// index to an RPC error.
+func watchedQueryError(err error, context string) error {
+	if err == indexers.ErrNotWatched {
+		return &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Address or key ID is not watched",
+		}
+	}
+	return internalRPCError(err.Error(), context)
+}
+
+// handleGetWatchedBalance implements the getwatchedbalance command.
+func handleGetWatchedBalance(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	watchIndex, err := watchIndexRequired(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GetWatchedBalanceCmd)
+	target, err := decodeWatchTarget(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := watchIndex.Balance(target)
+	if err != nil {
+		return nil, watchedQueryError(err, "Failed to load watched balance")
+	}
+
+	return &btcjson.GetAddressBalanceResult{
+		Balance:   provautil.Amount(balance.Balance).ToRMG(),
+		UtxoCount: balance.NumUtxos,
+	}, nil
+}
+
+// handleGetWatchedHistory implements the getwatchedhistory command.
+func handleGetWatchedHistory(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	watchIndex, err := watchIndexRequired(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GetWatchedHistoryCmd)
+	target, err := decodeWatchTarget(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	// Override the default number of requested entries and entries to
+	// skip if needed.
+	numRequested := 100
+	if c.Count != nil {
+		numRequested = *c.Count
+		if numRequested < 0 {
+			numRequested = 1
+		}
+	}
+	var numToSkip int
+	if c.Skip != nil {
+		numToSkip = *c.Skip
+		if numToSkip < 0 {
+			numToSkip = 0
+		}
+	}
+	var reverse bool
+	if c.Reverse != nil {
+		reverse = *c.Reverse
+	}
+
+	entries, total, err := watchIndex.History(target, uint32(numToSkip),
+		uint32(numRequested), reverse)
+	if err != nil {
+		return nil, watchedQueryError(err, "Failed to load watched history")
+	}
+
+	bestHeight := s.chain.BestSnapshot().Height
+	result := &btcjson.SearchRawTransactionsByAddressResult{
+		Total:        &total,
+		Transactions: make([]btcjson.AddressTxResult, len(entries)),
+	}
+	for i := range entries {
+		entry := &entries[i]
+		blockHash, err := s.chain.BlockHashByHeight(entry.Height)
+		if err != nil {
+			context := "Failed to fetch block hash"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		category := "both"
+		switch {
+		case entry.Sent == 0:
+			category = "funding"
+		case entry.Received == 0:
+			category = "spending"
+		}
+
+		result.Transactions[i] = btcjson.AddressTxResult{
+			Txid:          entry.TxHash.String(),
+			BlockHash:     blockHash.String(),
+			BlockHeight:   entry.Height,
+			Confirmations: uint64(1 + bestHeight - entry.Height),
+			Received:      provautil.Amount(entry.Received).ToRMG(),
+			Sent:          provautil.Amount(entry.Sent).ToRMG(),
+			Category:      category,
+		}
+	}
+
+	return result, nil
+}
+
+// handleGetWatchedUtxos implements the getwatchedutxos command.
+func handleGetWatchedUtxos(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	watchIndex, err := watchIndexRequired(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.GetWatchedUtxosCmd)
+	target, err := decodeWatchTarget(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+	utxos, err := watchIndex.Utxos(target)
+	if err != nil {
+		context := "Failed to load watched unspent outputs"
+		return nil, watchedQueryError(err, context)
+	}
+
+	results := make([]btcjson.AddressUtxoResult, 0, len(utxos))
+	for i := range utxos {
+		utxo := &utxos[i]
+		results = append(results, btcjson.AddressUtxoResult{
+			Txid:         utxo.OutPoint.Hash.String(),
+			Vout:         utxo.OutPoint.Index,
+			Height:       utxo.Height,
+			Value:        provautil.Amount(utxo.Value).ToRMG(),
+			ScriptPubKey: hex.EncodeToString(utxo.PkScript),
+		})
+	}
+
+	return results, nil
+}
+
+// handleHelp implements the help command.
+func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.HelpCmd)
+
+	// Provide a usage overview of all commands when no specific command
+	// was specified.
+	var command string
+	if c.Command != nil {
+		command = *c.Command
+	}
+	if command == "" {
+		usage, err := s.helpCacher.rpcUsage(false)
+		if err != nil {
+			context := "Failed to generate RPC usage"
+			return nil, internalRPCError(err.Error(), context)
+		}
+		return usage, nil
+	}
+
+	// Check that the command asked for is supported and implemented.  Only
+	// search the main list of handlers since help should not be provided
+	// for commands that are unimplemented or related to wallet
+	// functionality.
+	if _, ok := rpcHandlers[command]; !ok {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unknown command: " + command,
+		}
+	}
+
+	// Get the help for the command.
+	help, err := s.helpCacher.rpcMethodHelp(command)
+	if err != nil {
+		context := "Failed to generate help"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return help, nil
+}
+
+// adminThreadNames maps admin thread IDs to the names used in RPC results.
+var adminThreadNames = map[provautil.ThreadID]string{
+	provautil.RootThread:      "root",
+	provautil.ProvisionThread: "provision",
+	provautil.IssueThread:     "issue",
+}
+
+// adminOperationResult converts the passed admin operation into the form used
+// in RPC results.
+func adminOperationResult(op *indexers.AdminOperation) btcjson.AdminOperationResult {
+	result := btcjson.AdminOperationResult{
+		Type:   op.Type.String(),
+		Height: op.Height,
+		Txid:   op.TxHash.String(),
+		Vout:   op.Index,
+		Thread: adminThreadNames[op.ThreadID],
+	}
+	if op.Type == indexers.AdminOpKeyAdd || op.Type == indexers.AdminOpKeyRevoke {
+		result.KeySet = op.KeySetType.String()
+		result.PubKey = hex.EncodeToString(op.PubKey[:])
+		result.KeyID = uint32(op.KeyID)
+	} else {
+		result.Value = provautil.Amount(op.Value).ToRMG()
+	}
+	return result
+}
+
+// importWatchTarget registers the passed target with the watch-only index.
+// When a rescan is requested, the target is tracked from the block at the
+// passed height and the call returns once the rescan has caught up with the
+// index.
+func importWatchTarget(s *rpcServer, target indexers.WatchTarget, rescan *bool, startHeight *uint32) error {
+	watchIndex, err := watchIndexRequired(s)
+	if err != nil {
+		return err
+	}
+
+	if rescan != nil && !*rescan {
+		_, err := watchIndex.Watch([]indexers.WatchTarget{target})
+		if err != nil {
+			context := "Failed to watch address"
+			return internalRPCError(err.Error(), context)
+		}
+		return nil
+	}
+
+	var height uint32
+	if startHeight != nil {
+		height = *startHeight
+	}
+	_, err = watchIndex.WatchFromHeight([]indexers.WatchTarget{target},
+		height)
+	if err != nil {
+		context := "Failed to watch address"
+		return internalRPCError(err.Error(), context)
+	}
+	err = watchIndex.Rescan(s.server.quit)
+	if err == indexers.ErrRescanInterrupted {
+		return &btcjson.RPCError{
+			Code: btcjson.ErrRPCMisc,
+			Message: "Rescan interrupted by shutdown, it resumes " +
+				"when the server is restarted",
+		}
+	}
+	if err != nil {
+		context := "Failed to rescan blocks"
+		return internalRPCError(err.Error(), context)
+	}
+	return nil
+}
+
+// handleImportAddress implements the importaddress command.
+func handleImportAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ImportAddressCmd)
+	target, err := decodeWatchTarget(s, c.Address)
+	if err != nil {
+		return nil, err
+	}
+	return nil, importWatchTarget(s, target, c.Rescan, c.StartHeight)
+}
+
+// handleImportPubKey implements the importpubkey command.  The public key is
+// watched through the addresses paying to its hash.
+func handleImportPubKey(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.ImportPubKeyCmd)
+	serializedPubKey, err := hex.DecodeString(c.PubKey)
+	if err != nil {
+		return nil, rpcDecodeHexError(c.PubKey)
+	}
+	pubKey, err := btcec.ParsePubKey(serializedPubKey, btcec.S256())
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidAddressOrKey,
+			Message: "Invalid public key: " + err.Error(),
+		}
+	}
+	target := indexers.NewPubKeyWatchTarget(pubKey.SerializeCompressed())
+	return nil, importWatchTarget(s, target, c.Rescan, c.StartHeight)
+}
+
+// handleListAdminOperations implements the listadminoperations command.
+func handleListAdminOperations(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the admin operation index is not enabled.
+	adminOpIndex := s.server.adminOpIndex
+	if !s.server.indexEnabled(adminOpIndex) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Admin operation index must be enabled (--adminopindex)",
+		}
+	}
+
+	c := cmd.(*btcjson.ListAdminOperationsCmd)
+	filter := c.Filter
+	if filter == nil {
+		filter = &btcjson.AdminOperationFilter{}
+	}
+	endHeight := s.chain.BestSnapshot().Height
+	if filter.EndHeight != nil {
+		endHeight = *filter.EndHeight
+	}
+	if filter.StartHeight > endHeight {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "End height must not be less than the start height.",
+		}
+	}
+
+	// Build the sets of operation types and key sets to include.
+	var types map[string]struct{}
+	if len(filter.Types) > 0 {
+		types = make(map[string]struct{}, len(filter.Types))
+		for _, typ := range filter.Types {
+			types[strings.ToLower(typ)] = struct{}{}
+		}
+	}
+	var keySets map[string]struct{}
+	if len(filter.KeySets) > 0 {
+		keySets = make(map[string]struct{}, len(filter.KeySets))
+		for _, keySet := range filter.KeySets {
+			keySets[strings.ToUpper(keySet)] = struct{}{}
+		}
+	}
+	var pubKey []byte
+	if filter.PubKey != "" {
+		var err error
+		pubKey, err = hex.DecodeString(filter.PubKey)
+		if err != nil {
+			return nil, rpcDecodeHexError(filter.PubKey)
+		}
+	}
+
+	ops, err := adminOpIndex.AdminOperations(filter.StartHeight, endHeight)
+	if err != nil {
+		context := "Failed to load admin operation index entries"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	results := make([]btcjson.AdminOperationResult, 0, len(ops))
+	for i := range ops {
+		op := &ops[i]
+		isKeyOp := op.Type == indexers.AdminOpKeyAdd ||
+			op.Type == indexers.AdminOpKeyRevoke
+
+		// Skip operations which don't match the filter.
+		if _, ok := types[op.Type.String()]; types != nil && !ok {
+			continue
+		}
+		if keySets != nil {
+			if _, ok := keySets[op.KeySetType.String()]; !isKeyOp || !ok {
+				continue
+			}
+		}
+		if pubKey != nil && (!isKeyOp || !bytes.Equal(op.PubKey[:], pubKey)) {
+			continue
+		}
+		if filter.KeyID != nil && (!isKeyOp ||
+			op.KeySetType != btcec.ASPKeySet ||
+			uint32(op.KeyID) != *filter.KeyID) {
+			continue
+		}
+
+		results = append(results, adminOperationResult(op))
+	}
+
+	return results, nil
 }
 
-// handleHelp implements the help command.
-func handleHelp(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
-	c := cmd.(*btcjson.HelpCmd)
+// handleListUnspent implements the listunspent command for the addresses and
+// key IDs watched by the watch-only index.  Only outputs of blocks in the main
+// chain are listed.
+func handleListUnspent(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	watchIndex, err := watchIndexRequired(s)
+	if err != nil {
+		return nil, err
+	}
 
-	// Provide a usage overview of all commands when no specific command
-	// was specified.
-	var command string
-	if c.Command != nil {
-		command = *c.Command
+	c := cmd.(*btcjson.ListUnspentCmd)
+	minConf, maxConf := 1, 9999999
+	if c.MinConf != nil {
+		minConf = *c.MinConf
 	}
-	if command == "" {
-		usage, err := s.helpCacher.rpcUsage(false)
+	if c.MaxConf != nil {
+		maxConf = *c.MaxConf
+	}
+
+	// List the outputs of all watched addresses and key IDs unless
+	// specific ones were requested.
+	var targets []indexers.WatchTarget
+	if c.Addresses != nil {
+		targets, err = decodeWatchTargets(s, *c.Addresses)
 		if err != nil {
-			context := "Failed to generate RPC usage"
+			return nil, err
+		}
+	} else {
+		infos, err := watchIndex.Watched()
+		if err != nil {
+			context := "Failed to load watched addresses"
 			return nil, internalRPCError(err.Error(), context)
 		}
-		return usage, nil
+		for i := range infos {
+			targets = append(targets, infos[i].Target)
+		}
 	}
 
-	// Check that the command asked for is supported and implemented.  Only
-	// search the main list of handlers since help should not be provided
-	// for commands that are unimplemented or related to wallet
-	// functionality.
-	if _, ok := rpcHandlers[command]; !ok {
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCInvalidParameter,
-			Message: "Unknown command: " + command,
+	// An output might be tracked for both its address and a key ID it
+	// references, so outputs are only listed once.
+	bestHeight := s.chain.BestSnapshot().Height
+	seen := make(map[wire.OutPoint]struct{})
+	results := make([]btcjson.ListUnspentResult, 0)
+	for _, target := range targets {
+		utxos, err := watchIndex.Utxos(target)
+		if err != nil {
+			context := "Failed to load watched unspent outputs"
+			return nil, watchedQueryError(err, context)
+		}
+		for i := range utxos {
+			utxo := &utxos[i]
+			if _, ok := seen[utxo.OutPoint]; ok {
+				continue
+			}
+			seen[utxo.OutPoint] = struct{}{}
+
+			confirmations := int64(bestHeight) - int64(utxo.Height) + 1
+			if confirmations < int64(minConf) ||
+				confirmations > int64(maxConf) {
+
+				continue
+			}
+
+			var address string
+			_, addrs, _, _ := txscript.ExtractPkScriptAddrs(
+				utxo.PkScript, s.server.chainParams)
+			if len(addrs) > 0 {
+				address = addrs[0].EncodeAddress()
+			}
+			results = append(results, btcjson.ListUnspentResult{
+				TxID:          utxo.OutPoint.Hash.String(),
+				Vout:          utxo.OutPoint.Index,
+				Address:       address,
+				ScriptPubKey:  hex.EncodeToString(utxo.PkScript),
+				Amount:        provautil.Amount(utxo.Value).ToRMG(),
+				Confirmations: confirmations,
+			})
 		}
 	}
 
-	// Get the help for the command.
-	help, err := s.helpCacher.rpcMethodHelp(command)
+	return results, nil
+}
+
+// handleListWatched implements the listwatched command.
+func handleListWatched(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	watchIndex, err := watchIndexRequired(s)
 	if err != nil {
-		context := "Failed to generate help"
+		return nil, err
+	}
+
+	infos, err := watchIndex.Watched()
+	if err != nil {
+		context := "Failed to load watched addresses"
 		return nil, internalRPCError(err.Error(), context)
 	}
-	return help, nil
+
+	results := make([]btcjson.ListWatchedResult, 0, len(infos))
+	for i := range infos {
+		info := &infos[i]
+		results = append(results, btcjson.ListWatchedResult{
+			Address:      info.Target.String(),
+			Height:       info.Height,
+			Seeded:       info.Seeded,
+			Rescanning:   info.Rescanning,
+			RescanHeight: info.RescanHeight,
+			Balance:      provautil.Amount(info.Balance.Balance).ToRMG(),
+			UtxoCount:    info.Balance.NumUtxos,
+		})
+	}
+
+	return results, nil
 }
 
 // handlePing implements the ping command.
@@ -2789,127 +5844,470 @@ func createVinListPrevOut(s *rpcServer, mtx *wire.MsgTx, chainParams *chaincfg.P
 	// Use a dynamically sized list to accommodate the address filter.
 	vinList := make([]btcjson.VinPrevOut, 0, len(mtx.TxIn))
 
-	// Lookup all of the referenced transaction outputs needed to populate
-	// the previous output information if requested.
-	var originOutputs map[wire.OutPoint]wire.TxOut
-	if vinExtra || len(filterAddrMap) > 0 {
-		var err error
-		originOutputs, err = fetchInputTxos(s, mtx)
+	// Lookup all of the referenced transaction outputs needed to populate
+	// the previous output information if requested.
+	var originOutputs map[wire.OutPoint]wire.TxOut
+	if vinExtra || len(filterAddrMap) > 0 {
+		var err error
+		originOutputs, err = fetchInputTxos(s, mtx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, txIn := range mtx.TxIn {
+		// The disassembled string will contain [error] inline
+		// if the script doesn't fully parse, so ignore the
+		// error here.
+		disbuf, _ := txscript.DisasmString(txIn.SignatureScript)
+
+		// Create the basic input entry without the additional optional
+		// previous output details which will be added later if
+		// requested and available.
+		prevOut := &txIn.PreviousOutPoint
+		vinEntry := btcjson.VinPrevOut{
+			Txid:     prevOut.Hash.String(),
+			Vout:     prevOut.Index,
+			Sequence: txIn.Sequence,
+			ScriptSig: &btcjson.ScriptSig{
+				Asm: disbuf,
+				Hex: hex.EncodeToString(txIn.SignatureScript),
+			},
+		}
+
+		// Add the entry to the list now if it already passed the filter
+		// since the previous output might not be available.
+		passesFilter := len(filterAddrMap) == 0
+		if passesFilter {
+			vinList = append(vinList, vinEntry)
+		}
+
+		// Only populate previous output information if requested and
+		// available.
+		if len(originOutputs) == 0 {
+			continue
+		}
+		originTxOut, ok := originOutputs[*prevOut]
+		if !ok {
+			continue
+		}
+
+		// Ignore the error here since an error means the script
+		// couldn't parse and there is no additional information about
+		// it anyways.
+		_, addrs, _, _ := txscript.ExtractPkScriptAddrs(
+			originTxOut.PkScript, chainParams)
+
+		// Encode the addresses while checking if the address passes the
+		// filter when needed.
+		encodedAddrs := make([]string, len(addrs))
+		for j, addr := range addrs {
+			encodedAddr := addr.EncodeAddress()
+			encodedAddrs[j] = encodedAddr
+
+			// No need to check the map again if the filter already
+			// passes.
+			if passesFilter {
+				continue
+			}
+			if _, exists := filterAddrMap[encodedAddr]; exists {
+				passesFilter = true
+			}
+		}
+
+		// Ignore the entry if it doesn't pass the filter.
+		if !passesFilter {
+			continue
+		}
+
+		// Add entry to the list if it wasn't already done above.
+		if len(filterAddrMap) != 0 {
+			vinList = append(vinList, vinEntry)
+		}
+
+		// Update the entry with previous output information if
+		// requested.
+		if vinExtra {
+			vinListEntry := &vinList[len(vinList)-1]
+			vinListEntry.PrevOut = &btcjson.PrevOut{
+				Addresses: encodedAddrs,
+				Value:     provautil.Amount(originTxOut.Value).ToRMG(),
+			}
+		}
+	}
+
+	return vinList, nil
+}
+
+// fetchMempoolTxnsForAddress queries the address index for all unconfirmed
+// transactions that involve the provided address.  The results will be limited
+// by the number to skip and the number requested.
+func fetchMempoolTxnsForAddress(s *rpcServer, addr provautil.Address, numToSkip, numRequested uint32) ([]*provautil.Tx, uint32) {
+	// There are no entries to return when there are less available than the
+	// number being skipped.
+	mpTxns := s.server.addrIndex.UnconfirmedTxnsForAddress(addr)
+	numAvailable := uint32(len(mpTxns))
+	if numToSkip > numAvailable {
+		return nil, numAvailable
+	}
+
+	// Filter the available entries based on the number to skip and number
+	// requested.
+	rangeEnd := numToSkip + numRequested
+	if rangeEnd > numAvailable {
+		rangeEnd = numAvailable
+	}
+	return mpTxns[numToSkip:rangeEnd], numToSkip
+}
+
+// handleRebuildIndex handles rebuildindex commands.
+func handleRebuildIndex(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.RebuildIndexCmd)
+	indexManager := s.server.indexManager
+	if indexManager == nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "No optional indexes are enabled",
+		}
+	}
+
+	if err := indexManager.RebuildIndex(c.Index); err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: err.Error(),
+		}
+	}
+	return nil, nil
+}
+
+// handleReloadConfig handles reloadconfig commands.
+func handleReloadConfig(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	applied, requiresRestart, err := s.server.reloadConfig()
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Unable to reload configuration: " + err.Error(),
+		}
+	}
+
+	rpcsLog.Infof("Reloaded configuration -- applied %v, requires "+
+		"restart %v", applied, requiresRestart)
+	if applied == nil {
+		applied = []string{}
+	}
+	if requiresRestart == nil {
+		requiresRestart = []string{}
+	}
+	return &btcjson.ReloadConfigResult{
+		Applied:         applied,
+		RequiresRestart: requiresRestart,
+	}, nil
+}
+
+// policyScriptClasses are the script classes which the setpolicy command can
+// make standard, in the order they are reported.
+var policyScriptClasses = []txscript.ScriptClass{
+	txscript.ProvaTy,
+	txscript.GeneralProvaTy,
+	txscript.ProvaAdminTy,
+	txscript.NullDataTy,
+	txscript.ProvaTimelockTy,
+}
+
+// handleSetPolicy implements the setpolicy command.
+func handleSetPolicy(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SetPolicyCmd)
+
+	// Validate all parameters before changing any part of the policy.
+	policy := s.server.txMemPool.Policy()
+	if c.MaxSigOps != nil {
+		if *c.MaxSigOps <= 0 {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Max signature operations must be positive",
+			}
+		}
+		policy.MaxSigOpsPerTx = *c.MaxSigOps
+	}
+	if c.DustThreshold != nil {
+		dustThreshold, err := provautil.NewAmount(*c.DustThreshold)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCType,
+				Message: "Invalid dust threshold: " + err.Error(),
+			}
+		}
+		if dustThreshold < 0 {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidParameter,
+				Message: "Dust threshold may not be negative",
+			}
+		}
+		policy.DustThreshold = dustThreshold
+	}
+	if c.ScriptTypes != nil {
+		var classes []txscript.ScriptClass
+		for _, name := range *c.ScriptTypes {
+			found := false
+			for _, class := range policyScriptClasses {
+				if class.String() == name {
+					classes = append(classes, class)
+					found = true
+				}
+			}
+			if !found {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCInvalidParameter,
+					Message: "Unknown script type: " + name,
+				}
+			}
+		}
+		policy.StandardScriptClasses = classes
+	}
+	if c.DataCarrierSize != nil {
+		if *c.DataCarrierSize < 0 ||
+			*c.DataCarrierSize > txscript.MaxDataCarrierSize {
+
+			return nil, &btcjson.RPCError{
+				Code: btcjson.ErrRPCInvalidParameter,
+				Message: fmt.Sprintf("Data carrier size must be "+
+					"between 0 and %d",
+					txscript.MaxDataCarrierSize),
+			}
+		}
+		policy.MaxDataCarrierSize = *c.DataCarrierSize
+	}
+	s.server.txMemPool.SetPolicy(policy)
+
+	// Report the policy in effect, naming every script type which is
+	// standard when the policy does not restrict them.
+	classes := policy.StandardScriptClasses
+	if len(classes) == 0 {
+		classes = policyScriptClasses
+	}
+	scriptTypes := make([]string, 0, len(classes))
+	seen := make(map[string]struct{}, len(classes))
+	for _, class := range classes {
+		name := class.String()
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		scriptTypes = append(scriptTypes, name)
+	}
+	dataCarrierSize := policy.MaxDataCarrierSize
+	if dataCarrierSize == 0 {
+		dataCarrierSize = txscript.MaxDataCarrierSize
+	}
+
+	rpcsLog.Infof("Set relay policy -- max signature operations %d, "+
+		"dust threshold %v, script types %v, data carrier size %d",
+		policy.MaxSigOpsPerTx, policy.DustThreshold, scriptTypes,
+		dataCarrierSize)
+
+	return &btcjson.SetPolicyResult{
+		MaxSigOps:       policy.MaxSigOpsPerTx,
+		DustThreshold:   policy.DustThreshold.ToRMG(),
+		ScriptTypes:     scriptTypes,
+		DataCarrierSize: dataCarrierSize,
+	}, nil
+}
+
+// handleSimulateChain implements the simulatechain command.
+func handleSimulateChain(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SimulateChainCmd)
+
+	// The scenario generator uses the well known admin keys of the
+	// regression test network and builds from its genesis block.
+	if s.server.chainParams.Net != wire.RegNet {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCMisc,
+			Message: "simulatechain is only available on the " +
+				"regression test network",
+		}
+	}
+
+	scenario, err := fullblocktests.ParseScenario([]byte(c.Scenario))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid scenario: " + err.Error(),
+		}
+	}
+	blocks, err := fullblocktests.GenerateScenario(scenario)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Unable to generate scenario: " + err.Error(),
+		}
+	}
+
+	results := make([]btcjson.SimulatedBlockResult, 0, len(blocks))
+	hashes := make([]*chainhash.Hash, 0, len(blocks))
+	for _, item := range blocks {
+		block := provautil.NewBlock(item.Block)
+		block.SetHeight(item.Height)
+		result := btcjson.SimulatedBlockResult{
+			Name:   item.Name,
+			Hash:   block.Hash().String(),
+			Height: item.Height,
+		}
+		_, err := s.server.blockManager.ProcessBlock(block,
+			blockchain.BFNone)
 		if err != nil {
-			return nil, err
+			result.Error = err.Error()
 		}
+		results = append(results, result)
+		hashes = append(hashes, block.Hash())
 	}
 
-	for _, txIn := range mtx.TxIn {
-		// The disassembled string will contain [error] inline
-		// if the script doesn't fully parse, so ignore the
-		// error here.
-		disbuf, _ := txscript.DisasmString(txIn.SignatureScript)
-
-		// Create the basic input entry without the additional optional
-		// previous output details which will be added later if
-		// requested and available.
-		prevOut := &txIn.PreviousOutPoint
-		vinEntry := btcjson.VinPrevOut{
-			Txid:     prevOut.Hash.String(),
-			Vout:     prevOut.Index,
-			Sequence: txIn.Sequence,
-			ScriptSig: &btcjson.ScriptSig{
-				Asm: disbuf,
-				Hex: hex.EncodeToString(txIn.SignatureScript),
-			},
+	// Report which blocks ended up on the main chain once all of them are
+	// processed, since later blocks may reorg earlier ones away.
+	for i, hash := range hashes {
+		mainChain, err := s.chain.MainChainHasBlock(hash)
+		if err != nil {
+			context := "Failed to check main chain"
+			return nil, internalRPCError(err.Error(), context)
 		}
+		results[i].MainChain = mainChain
+	}
 
-		// Add the entry to the list now if it already passed the filter
-		// since the previous output might not be available.
-		passesFilter := len(filterAddrMap) == 0
-		if passesFilter {
-			vinList = append(vinList, vinEntry)
+	rpcsLog.Infof("Processed %d blocks of a simulated chain", len(results))
+	return results, nil
+}
+
+// scanObjectScript decodes the passed scan object of the scantxoutset command
+// into the public key script it describes.  Scan objects are output
+// descriptors of the form addr(<address>) or raw(<hex script>).
+func scanObjectScript(s *rpcServer, desc string) ([]byte, error) {
+	if !strings.HasSuffix(desc, ")") {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid scan object " + desc,
 		}
+	}
 
-		// Only populate previous output information if requested and
-		// available.
-		if len(originOutputs) == 0 {
-			continue
+	switch {
+	case strings.HasPrefix(desc, "addr("):
+		str := desc[len("addr(") : len(desc)-1]
+		addr, err := provautil.DecodeAddress(str, s.server.chainParams)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address or key: " + err.Error(),
+			}
 		}
-		originTxOut, ok := originOutputs[*prevOut]
-		if !ok {
-			continue
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address or key: " + err.Error(),
+			}
 		}
+		return pkScript, nil
 
-		// Ignore the error here since an error means the script
-		// couldn't parse and there is no additional information about
-		// it anyways.
-		_, addrs, _, _ := txscript.ExtractPkScriptAddrs(
-			originTxOut.PkScript, chainParams)
+	case strings.HasPrefix(desc, "raw("):
+		str := desc[len("raw(") : len(desc)-1]
+		pkScript, err := hex.DecodeString(str)
+		if err != nil {
+			return nil, rpcDecodeHexError(str)
+		}
+		return pkScript, nil
+	}
 
-		// Encode the addresses while checking if the address passes the
-		// filter when needed.
-		encodedAddrs := make([]string, len(addrs))
-		for j, addr := range addrs {
-			encodedAddr := addr.EncodeAddress()
-			encodedAddrs[j] = encodedAddr
+	return nil, &btcjson.RPCError{
+		Code: btcjson.ErrRPCInvalidParameter,
+		Message: "Unsupported scan object " + desc + " (only addr() " +
+			"and raw() descriptors are supported)",
+	}
+}
 
-			// No need to check the map again if the filter already
-			// passes.
-			if passesFilter {
-				continue
-			}
-			if _, exists := filterAddrMap[encodedAddr]; exists {
-				passesFilter = true
-			}
+// handleScanTxOutSet implements the scantxoutset command.
+func handleScanTxOutSet(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the script utxo index is not enabled.
+	scriptUtxoIndex := s.server.scriptUtxoIndex
+	if !s.server.indexEnabled(scriptUtxoIndex) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Script utxo index must be enabled (--scriptutxoindex)",
 		}
+	}
 
-		// Ignore the entry if it doesn't pass the filter.
-		if !passesFilter {
-			continue
+	// Scans are served from the index and complete immediately, so there
+	// is never a scan in progress to abort or report the status of.
+	c := cmd.(*btcjson.ScanTxOutSetCmd)
+	if c.Action != "start" {
+		return nil, &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidParameter,
+			Message: "Invalid action " + c.Action + " (scans " +
+				"complete immediately, so only start is supported)",
 		}
-
-		// Add entry to the list if it wasn't already done above.
-		if len(filterAddrMap) != 0 {
-			vinList = append(vinList, vinEntry)
+	}
+	if c.ScanObjects == nil || len(*c.ScanObjects) == 0 {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidParameter,
+			Message: "At least one scan object must be provided",
 		}
+	}
 
-		// Update the entry with previous output information if
-		// requested.
-		if vinExtra {
-			vinListEntry := &vinList[len(vinList)-1]
-			vinListEntry.PrevOut = &btcjson.PrevOut{
-				Addresses: encodedAddrs,
-				Value:     provautil.Amount(originTxOut.Value).ToRMG(),
-			}
+	// Decode all scan objects before looking up any of them and skip
+	// those describing a script which has already been seen.
+	descs := make([]string, 0, len(*c.ScanObjects))
+	pkScripts := make([][]byte, 0, len(*c.ScanObjects))
+	seen := make(map[string]struct{}, len(*c.ScanObjects))
+	for _, desc := range *c.ScanObjects {
+		pkScript, err := scanObjectScript(s, desc)
+		if err != nil {
+			return nil, err
 		}
+		if _, ok := seen[string(pkScript)]; ok {
+			continue
+		}
+		seen[string(pkScript)] = struct{}{}
+		descs = append(descs, desc)
+		pkScripts = append(pkScripts, pkScript)
 	}
 
-	return vinList, nil
-}
+	best := s.chain.BestSnapshot()
+	result := &btcjson.ScanTxOutSetResult{
+		Success:   true,
+		Height:    best.Height,
+		BestBlock: best.Hash.String(),
+		Unspents:  []btcjson.ScanTxOutSetUnspent{},
+	}
+	var totalAmount int64
+	for i, pkScript := range pkScripts {
+		utxos, err := scriptUtxoIndex.UtxosForScript(pkScript)
+		if err != nil {
+			context := "Failed to load script unspent outputs"
+			return nil, internalRPCError(err.Error(), context)
+		}
 
-// fetchMempoolTxnsForAddress queries the address index for all unconfirmed
-// transactions that involve the provided address.  The results will be limited
-// by the number to skip and the number requested.
-func fetchMempoolTxnsForAddress(s *rpcServer, addr provautil.Address, numToSkip, numRequested uint32) ([]*provautil.Tx, uint32) {
-	// There are no entries to return when there are less available than the
-	// number being skipped.
-	mpTxns := s.server.addrIndex.UnconfirmedTxnsForAddress(addr)
-	numAvailable := uint32(len(mpTxns))
-	if numToSkip > numAvailable {
-		return nil, numAvailable
+		scriptHex := hex.EncodeToString(pkScript)
+		for j := range utxos {
+			utxo := &utxos[j]
+			result.Unspents = append(result.Unspents,
+				btcjson.ScanTxOutSetUnspent{
+					Txid:         utxo.OutPoint.Hash.String(),
+					Vout:         utxo.OutPoint.Index,
+					ScriptPubKey: scriptHex,
+					Desc:         descs[i],
+					Amount:       provautil.Amount(utxo.Value).ToRMG(),
+					Height:       utxo.Height,
+				})
+			totalAmount += utxo.Value
+		}
 	}
+	result.TotalAmount = provautil.Amount(totalAmount).ToRMG()
 
-	// Filter the available entries based on the number to skip and number
-	// requested.
-	rangeEnd := numToSkip + numRequested
-	if rangeEnd > numAvailable {
-		rangeEnd = numAvailable
-	}
-	return mpTxns[numToSkip:rangeEnd], numToSkip
+	return result, nil
 }
 
 // handleSearchRawTransactions implements the searchrawtransactions command.
 func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	// Respond with an error if the address index is not enabled.
 	addrIndex := s.server.addrIndex
-	if addrIndex == nil {
+	if !s.server.indexEnabled(addrIndex) {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCMisc,
 			Message: "Address index must be enabled (--addrindex)",
@@ -2928,7 +6326,7 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan
 	// transaction index.  Currently the address index relies on the
 	// transaction index, so this check is redundant, but it's better to be
 	// safe in case the address index is ever changed to not rely on it.
-	if vinExtra && s.server.txIndex == nil {
+	if vinExtra && !s.server.indexEnabled(s.server.txIndex) {
 		return nil, &btcjson.RPCError{
 			Code:    btcjson.ErrRPCMisc,
 			Message: "Transaction index must be enabled (--txindex)",
@@ -3166,7 +6564,236 @@ func handleSearchRawTransactions(s *rpcServer, cmd interface{}, closeChan <-chan
 		}
 	}
 
-	return srtList, nil
+	return srtList, nil
+}
+
+// handleSearchRawTransactionsByAddress implements the
+// searchrawtransactionsbyaddress command.
+func handleSearchRawTransactionsByAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the address value index is not enabled.
+	addrValueIndex := s.server.addrValueIndex
+	if !s.server.indexEnabled(addrValueIndex) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Address value index must be enabled (--addrvalueindex)",
+		}
+	}
+
+	// The passed address may either be an address or a key ID.  Key IDs
+	// are only considered when the string is not a valid address.
+	c := cmd.(*btcjson.SearchRawTransactionsByAddressCmd)
+	addr, err := provautil.DecodeAddress(c.Address, s.server.chainParams)
+	var keyID uint64
+	if err != nil {
+		var keyErr error
+		keyID, keyErr = strconv.ParseUint(c.Address, 10, 32)
+		if keyErr != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid address or key: " + err.Error(),
+			}
+		}
+	}
+
+	// Override the default number of requested entries and entries to
+	// skip if needed.
+	numRequested := 100
+	if c.Count != nil {
+		numRequested = *c.Count
+		if numRequested < 0 {
+			numRequested = 1
+		}
+	}
+	var numToSkip int
+	if c.Skip != nil {
+		numToSkip = *c.Skip
+		if numToSkip < 0 {
+			numToSkip = 0
+		}
+	}
+	var reverse bool
+	if c.Reverse != nil {
+		reverse = *c.Reverse
+	}
+	includeTotal := c.IncludeTotal != nil && *c.IncludeTotal
+
+	// Load the index entries along with the raw transaction bytes they
+	// reference from the database.  Counting all entries requires visiting
+	// every one of them, so it is only done when requested.
+	var entries []indexers.AddrValueEntry
+	var serializedTxns [][]byte
+	var total *uint32
+	err = s.server.db.View(func(dbTx database.Tx) error {
+		var err error
+		if addr != nil {
+			entries, err = addrValueIndex.EntriesForAddress(dbTx,
+				addr, uint32(numToSkip), uint32(numRequested),
+				reverse)
+			if err == nil && includeTotal {
+				var n uint32
+				n, err = addrValueIndex.NumEntriesForAddress(dbTx,
+					addr)
+				total = &n
+			}
+		} else {
+			entries, err = addrValueIndex.EntriesForKeyID(dbTx,
+				btcec.KeyID(keyID), uint32(numToSkip),
+				uint32(numRequested), reverse)
+			if includeTotal {
+				n := addrValueIndex.NumEntriesForKeyID(dbTx,
+					btcec.KeyID(keyID))
+				total = &n
+			}
+		}
+		if err != nil || len(entries) == 0 {
+			return err
+		}
+
+		regions := make([]database.BlockRegion, len(entries))
+		for i := range entries {
+			regions[i] = entries[i].Region
+		}
+		serializedTxns, err = dbTx.FetchBlockRegions(regions)
+		return err
+	})
+	if err != nil {
+		context := "Failed to load address value index entries"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	bestHeight := s.chain.BestSnapshot().Height
+	result := &btcjson.SearchRawTransactionsByAddressResult{
+		Total:        total,
+		Transactions: make([]btcjson.AddressTxResult, len(entries)),
+	}
+	for i := range entries {
+		var mtx wire.MsgTx
+		err := mtx.Deserialize(bytes.NewReader(serializedTxns[i]))
+		if err != nil {
+			context := "Failed to deserialize transaction"
+			return nil, internalRPCError(err.Error(), context)
+		}
+
+		entry := &entries[i]
+		category := "both"
+		switch {
+		case !entry.IsSpending():
+			category = "funding"
+		case !entry.IsFunding():
+			category = "spending"
+		}
+
+		result.Transactions[i] = btcjson.AddressTxResult{
+			Txid:          mtx.TxHash().String(),
+			BlockHash:     entry.Region.Hash.String(),
+			BlockHeight:   entry.Height,
+			Confirmations: uint64(1 + bestHeight - entry.Height),
+			Received:      provautil.Amount(entry.Received).ToRMG(),
+			Sent:          provautil.Amount(entry.Sent).ToRMG(),
+			Category:      category,
+		}
+	}
+
+	return result, nil
+}
+
+// handleSearchRawTransactionsByKeyID implements the
+// searchrawtransactionsbykeyid command.
+func handleSearchRawTransactionsByKeyID(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	// Respond with an error if the key ID activity index is not enabled.
+	keyIDIndex := s.server.keyIDIndex
+	if !s.server.indexEnabled(keyIDIndex) {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCMisc,
+			Message: "Key ID activity index must be enabled (--keyidindex)",
+		}
+	}
+
+	// Override the default number of requested entries and entries to
+	// skip if needed.
+	c := cmd.(*btcjson.SearchRawTransactionsByKeyIDCmd)
+	numRequested := 100
+	if c.Count != nil {
+		numRequested = *c.Count
+		if numRequested < 0 {
+			numRequested = 1
+		}
+	}
+	var numToSkip int
+	if c.Skip != nil {
+		numToSkip = *c.Skip
+		if numToSkip < 0 {
+			numToSkip = 0
+		}
+	}
+	var reverse bool
+	if c.Reverse != nil {
+		reverse = *c.Reverse
+	}
+
+	// Page through the transactions which create or spend outputs under
+	// the key ID in the order they appear in the main chain.
+	best := s.chain.BestSnapshot()
+	txns, err := keyIDIndex.TxnsForKeyID(btcec.KeyID(c.KeyID),
+		uint32(numToSkip), uint32(numRequested), reverse)
+	if err != nil {
+		context := "Failed to load key ID activity index entries"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// Load the transactions from the blocks which contain them.
+	// Consecutive transactions are frequently from the same block, so the
+	// most recently loaded block is reused.
+	var block *provautil.Block
+	blocks := make([]*provautil.Block, len(txns))
+	mtxns := make([]*wire.MsgTx, len(txns))
+	for i := range txns {
+		if block == nil || block.Height() != txns[i].Height {
+			block, err = s.chain.BlockByHeight(txns[i].Height)
+			if err != nil {
+				context := "Failed to load block"
+				return nil, internalRPCError(err.Error(), context)
+			}
+		}
+		for _, tx := range block.Transactions() {
+			if *tx.Hash() == txns[i].TxHash {
+				mtxns[i] = tx.MsgTx()
+				break
+			}
+		}
+		if mtxns[i] == nil {
+			return nil, internalRPCError(fmt.Sprintf("transaction "+
+				"%v not found in block at height %d",
+				txns[i].TxHash, txns[i].Height), "")
+		}
+		blocks[i] = block
+	}
+
+	// When not in verbose mode, simply return a list of serialized txns.
+	if c.Verbose != nil && *c.Verbose == 0 {
+		hexTxns := make([]string, len(mtxns))
+		for i, mtx := range mtxns {
+			hexTxns[i], err = messageToHex(mtx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return hexTxns, nil
+	}
+
+	// The verbose flag is set, so generate the JSON object and return it.
+	results := make([]btcjson.TxRawResult, len(mtxns))
+	for i, mtx := range mtxns {
+		rawTxn, err := createTxRawResult(s.server.chainParams, mtx,
+			txns[i].TxHash.String(), &blocks[i].MsgBlock().Header,
+			blocks[i].Hash().String(), txns[i].Height, best.Height)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = *rawTxn
+	}
+
+	return results, nil
 }
 
 // handleSendRawTransaction implements the sendrawtransaction command.
@@ -3283,8 +6910,8 @@ func handleSetGenerate(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 		return nil, &btcjson.RPCError{
 			Code: btcjson.ErrRPCInternal.Code,
 			Message: "No validating priv keys specified " +
-				"via --setvalidatekeys or PROVA_VALIDATE_KEYS" +
-				"env variable",
+				"via setvalidatekeys, --validatesigner or " +
+				"PROVA_VALIDATE_KEYS env variable",
 		}
 	}
 
@@ -3305,7 +6932,7 @@ func handleSetValidateKeys(s *rpcServer, cmd interface{}, closeChan <-chan struc
 			Message: "No validate keys provided",
 		}
 	}
-	validateKeys := make([]*btcec.PrivateKey, len(c.PrivKeys))
+	validateKeys := make([]wire.BlockSigner, len(c.PrivKeys))
 	for i, privKeyStr := range c.PrivKeys {
 		privKeyBytes, err := hex.DecodeString(privKeyStr)
 		if err != nil {
@@ -3319,6 +6946,139 @@ func handleSetValidateKeys(s *rpcServer, cmd interface{}, closeChan <-chan struc
 	return nil, nil
 }
 
+// handleSignRawTransactionWithKey implements the signrawtransactionwithkey
+// command.
+func handleSignRawTransactionWithKey(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.SignRawTransactionWithKeyCmd)
+
+	// Deserialize the transaction.
+	hexStr := c.HexTx
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + hexStr
+	}
+	serializedTx, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	var mtx wire.MsgTx
+	err = mtx.Deserialize(bytes.NewReader(serializedTx))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "TX decode failed: " + err.Error(),
+		}
+	}
+
+	// Decode the private keys, which must be for the active network.
+	keys := make([]*btcec.PrivateKey, 0, len(c.PrivKeys))
+	for _, encoded := range c.PrivKeys {
+		wif, err := provautil.DecodeWIF(encoded)
+		if err != nil {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Invalid private key: " + err.Error(),
+			}
+		}
+		if !wif.IsForNet(s.server.chainParams) {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCInvalidAddressOrKey,
+				Message: "Private key is for another network",
+			}
+		}
+		keys = append(keys, wif.PrivKey)
+	}
+
+	// The provided outputs take precedence over those of the memory pool
+	// and the utxo set, which allows signing spends of outputs the node
+	// does not know yet.
+	prevTxs := make(map[wire.OutPoint]provasign.PacketInput)
+	if c.PrevTxs != nil {
+		for _, prevTx := range *c.PrevTxs {
+			txHash, err := chainhash.NewHashFromStr(prevTx.Txid)
+			if err != nil {
+				return nil, rpcDecodeHexError(prevTx.Txid)
+			}
+			pkScript, err := hex.DecodeString(prevTx.ScriptPubKey)
+			if err != nil {
+				return nil, rpcDecodeHexError(prevTx.ScriptPubKey)
+			}
+			amount, err := provautil.NewAmount(prevTx.Amount)
+			if err != nil {
+				return nil, &btcjson.RPCError{
+					Code:    btcjson.ErrRPCType,
+					Message: "Invalid amount: " + err.Error(),
+				}
+			}
+			prevOut := *wire.NewOutPoint(txHash, prevTx.Vout)
+			prevTxs[prevOut] = provasign.PacketInput{
+				Amount:   int64(amount),
+				PkScript: pkScript,
+			}
+		}
+	}
+	inputs := make([]provasign.PacketInput, 0, len(mtx.TxIn))
+	lookupErrs := make(map[int]error)
+	for i, txIn := range mtx.TxIn {
+		if input, ok := prevTxs[txIn.PreviousOutPoint]; ok {
+			inputs = append(inputs, input)
+			continue
+		}
+		pkScript, amount, err := fetchSpentOutput(s,
+			&txIn.PreviousOutPoint, i)
+		if err != nil {
+			if rpcErr, ok := err.(*btcjson.RPCError); ok &&
+				rpcErr.Code == btcjson.ErrRPCNoTxInfo {
+
+				lookupErrs[i] = rpcErr
+				inputs = append(inputs, provasign.PacketInput{})
+				continue
+			}
+			return nil, err
+		}
+		inputs = append(inputs, provasign.PacketInput{
+			Amount:   amount,
+			PkScript: pkScript,
+		})
+	}
+	packet, err := provasign.NewPacket(&mtx, inputs)
+	if err != nil {
+		context := "Failed to create PSPT"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	// Fill in the signatures of the keys the outputs are bound to, with key
+	// IDs resolved to the ASP keys of the best chain, and report the inputs
+	// which remain incomplete along with the reason.
+	errs := txbuilder.SignPacket(packet, keys, s.chain.KeyIDs())
+	for i, err := range lookupErrs {
+		errs[i] = err
+	}
+	var signErrors []btcjson.SignRawTransactionError
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		txIn := mtx.TxIn[i]
+		signErrors = append(signErrors, btcjson.SignRawTransactionError{
+			TxID:      txIn.PreviousOutPoint.Hash.String(),
+			Vout:      txIn.PreviousOutPoint.Index,
+			ScriptSig: hex.EncodeToString(txIn.SignatureScript),
+			Sequence:  txIn.Sequence,
+			Error:     err.Error(),
+		})
+	}
+
+	mtxHex, err := messageToHex(packet.Tx)
+	if err != nil {
+		return nil, err
+	}
+	return &btcjson.SignRawTransactionResult{
+		Hex:      mtxHex,
+		Complete: len(signErrors) == 0,
+		Errors:   signErrors,
+	}, nil
+}
+
 // handleStop implements the stop command.
 func handleStop(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	select {
@@ -3342,6 +7102,7 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 		return nil, rpcDecodeHexError(hexStr)
 	}
 
+	decodeStart := time.Now()
 	block, err := provautil.NewBlockFromBytes(serializedBlock)
 	if err != nil {
 		return nil, &btcjson.RPCError{
@@ -3349,16 +7110,141 @@ func handleSubmitBlock(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 			Message: "Block decode failed: " + err.Error(),
 		}
 	}
+	s.chain.RecordDeserializeTime(time.Since(decodeStart))
 
+	// Rule violations are reported with the same reasons as rejected block
+	// proposals.
 	_, err = s.server.blockManager.ProcessBlock(block, blockchain.BFNone)
 	if err != nil {
-		return fmt.Sprintf("rejected: %s", err.Error()), nil
+		return chainErrToGBTErrString(err), nil
 	}
 
 	rpcsLog.Infof("Accepted block %s via submitblock", block.Hash())
 	return nil, nil
 }
 
+// simulatedOutputResults converts the passed simulated outputs into their
+// JSON-RPC representation.
+func simulatedOutputResults(outputs []blockchain.SimulatedOutput) []btcjson.SimulatedOutputResult {
+	results := make([]btcjson.SimulatedOutputResult, 0, len(outputs))
+	for _, output := range outputs {
+		results = append(results, btcjson.SimulatedOutputResult{
+			Txid:         output.OutPoint.Hash.String(),
+			Vout:         output.OutPoint.Index,
+			Value:        provautil.Amount(output.Amount).ToRMG(),
+			ScriptPubKey: hex.EncodeToString(output.PkScript),
+		})
+	}
+	return results
+}
+
+// handleTestBlockValidity implements the testblockvalidity command.
+func handleTestBlockValidity(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.TestBlockValidityCmd)
+
+	// Deserialize the block.
+	hexStr := c.HexBlock
+	if len(hexStr)%2 != 0 {
+		hexStr = "0" + c.HexBlock
+	}
+	serializedBlock, err := hex.DecodeString(hexStr)
+	if err != nil {
+		return nil, rpcDecodeHexError(hexStr)
+	}
+	block, err := provautil.NewBlockFromBytes(serializedBlock)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDeserialization,
+			Message: "Block decode failed: " + err.Error(),
+		}
+	}
+
+	// Rule violations make the block invalid while any other error means
+	// the block could not be simulated at all.
+	sim, err := s.chain.SimulateConnectBlock(block)
+	if err != nil {
+		if _, ok := err.(blockchain.RuleError); !ok {
+			return nil, &btcjson.RPCError{
+				Code:    btcjson.ErrRPCVerify,
+				Message: "Unable to simulate block: " + err.Error(),
+			}
+		}
+		return &btcjson.TestBlockValidityResult{
+			Hash:         block.Hash().String(),
+			RejectReason: err.Error(),
+		}, nil
+	}
+
+	keySetChanges := make([]btcjson.KeySetChangeResult, 0,
+		len(sim.KeySetChanges))
+	for _, change := range sim.KeySetChanges {
+		keySetChanges = append(keySetChanges, btcjson.KeySetChangeResult{
+			KeySet:  change.KeySet.String(),
+			Added:   change.Added.ToStringArray(),
+			Removed: change.Removed.ToStringArray(),
+		})
+	}
+	keyIDChanges := make([]btcjson.KeyIDChangeResult, 0,
+		len(sim.KeyIDChanges))
+	for _, change := range sim.KeyIDChanges {
+		keyIDChanges = append(keyIDChanges, btcjson.KeyIDChangeResult{
+			KeyID:  uint32(change.KeyID),
+			PubKey: hex.EncodeToString(change.PubKey.SerializeCompressed()),
+			Added:  change.Added,
+		})
+	}
+
+	return &btcjson.TestBlockValidityResult{
+		Hash:          sim.Hash.String(),
+		Valid:         true,
+		Height:        sim.Height,
+		Depth:         sim.Depth,
+		Fees:          provautil.Amount(sim.Fees).ToRMG(),
+		Subsidy:       provautil.Amount(sim.Subsidy).ToRMG(),
+		SupplyBefore:  sim.SupplyBefore,
+		SupplyAfter:   sim.SupplyAfter,
+		LastKeyID:     uint32(sim.LastKeyID),
+		Spent:         simulatedOutputResults(sim.Spent),
+		Created:       simulatedOutputResults(sim.Created),
+		KeySetChanges: keySetChanges,
+		KeyIDChanges:  keyIDChanges,
+	}, nil
+}
+
+// decodeWatchTargets decodes the passed addresses and key IDs into the targets
+// tracked by the watch-only index.
+func decodeWatchTargets(s *rpcServer, strs []string) ([]indexers.WatchTarget, error) {
+	targets := make([]indexers.WatchTarget, 0, len(strs))
+	for _, str := range strs {
+		target, err := decodeWatchTarget(s, str)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// handleUnwatchAddresses implements the unwatchaddresses command.
+func handleUnwatchAddresses(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	watchIndex, err := watchIndexRequired(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.UnwatchAddressesCmd)
+	targets, err := decodeWatchTargets(s, c.Addresses)
+	if err != nil {
+		return nil, err
+	}
+	numRemoved, err := watchIndex.Unwatch(targets)
+	if err != nil {
+		context := "Failed to unwatch addresses"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return numRemoved, nil
+}
+
 // handleValidateAddress implements the validateaddress command.
 func handleValidateAddress(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
 	c := cmd.(*btcjson.ValidateAddressCmd)
@@ -3376,39 +7262,41 @@ func handleValidateAddress(s *rpcServer, cmd interface{}, closeChan <-chan struc
 	return result, nil
 }
 
-func verifyChain(s *rpcServer, level int32, depth uint32) error {
-	best := s.chain.BestSnapshot()
-	finishHeight := best.Height - depth
-	if finishHeight < 0 {
-		finishHeight = 0
-	}
-	rpcsLog.Infof("Verifying chain for %d blocks at level %d",
-		best.Height-finishHeight, level)
-
-	for height := best.Height; height > finishHeight; height-- {
-		// Level 0 just looks up the block.
-		block, err := s.chain.BlockByHeight(height)
-		if err != nil {
-			rpcsLog.Errorf("Verify is unable to fetch block at "+
-				"height %d: %v", height, err)
-			return err
-		}
+// verifyChainParams converts the passed verification level and number of
+// blocks of the verifychain and verifychaindb commands to the parameters of
+// the chain verification.  Levels out of range are clamped and a non-positive
+// number of blocks means the entire chain.
+func verifyChainParams(level, numBlocks int32) (blockchain.VerifyLevel, uint32) {
+	switch {
+	case level < 0:
+		level = 0
+	case level > int32(blockchain.VerifyConnect):
+		level = int32(blockchain.VerifyConnect)
+	}
+	depth := uint32(math.MaxUint32)
+	if numBlocks > 0 {
+		depth = uint32(numBlocks)
+	}
+	return blockchain.VerifyLevel(level), depth
+}
 
-		// Level 1 does basic chain sanity checks.
-		if level > 0 {
-			err := blockchain.CheckBlockSanity(block,
-				activeNetParams.PowLimit, s.server.timeSource)
-			if err != nil {
-				rpcsLog.Errorf("Verify is unable to validate "+
-					"block at hash %v height %d: %v",
-					block.Hash(), height, err)
-				return err
-			}
-		}
+// verifyChain verifies the most recent blocks of the main chain according to
+// the passed level and logs the outcome.
+func verifyChain(s *rpcServer, level blockchain.VerifyLevel, depth uint32, repair bool) (*blockchain.ChainVerification, error) {
+	result, err := s.chain.VerifyChain(level, depth, repair)
+	if err != nil {
+		rpcsLog.Errorf("Unable to verify the chain: %v", err)
+		return nil, err
 	}
-	rpcsLog.Infof("Chain verify completed successfully")
-
-	return nil
+	for i := range result.Inconsistencies {
+		rpcsLog.Errorf("Chain verify found an inconsistency: %s",
+			result.Inconsistencies[i].String())
+	}
+	if result.Verified() {
+		rpcsLog.Infof("Chain verify of %d blocks completed successfully",
+			result.BlocksChecked)
+	}
+	return result, nil
 }
 
 // handleVerifyChain implements the verifychain command.
@@ -3423,8 +7311,69 @@ func handleVerifyChain(s *rpcServer, cmd interface{}, closeChan <-chan struct{})
 		checkDepth = *c.CheckDepth
 	}
 
-	err := verifyChain(s, checkLevel, uint32(checkDepth))
-	return err == nil, nil
+	level, depth := verifyChainParams(checkLevel, checkDepth)
+	result, err := verifyChain(s, level, depth, false)
+	return err == nil && result.Verified(), nil
+}
+
+// handleVerifyChainDB implements the verifychaindb command.
+func handleVerifyChainDB(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	c := cmd.(*btcjson.VerifyChainDBCmd)
+
+	var checkLevel, checkBlocks int32
+	if c.CheckLevel != nil {
+		checkLevel = *c.CheckLevel
+	}
+	if c.CheckBlocks != nil {
+		checkBlocks = *c.CheckBlocks
+	}
+	repair := c.Repair != nil && *c.Repair
+
+	level, depth := verifyChainParams(checkLevel, checkBlocks)
+	result, err := verifyChain(s, level, depth, repair)
+	if err != nil {
+		context := "Failed to verify the chain"
+		return nil, internalRPCError(err.Error(), context)
+	}
+
+	reply := btcjson.VerifyChainDBResult{
+		Verified:          result.Verified(),
+		BlocksChecked:     result.BlocksChecked,
+		BestStateRepaired: result.BestStateRepaired,
+	}
+	for _, inconsistency := range result.Inconsistencies {
+		var hash string
+		if inconsistency.Hash != nil {
+			hash = inconsistency.Hash.String()
+		}
+		reply.Inconsistencies = append(reply.Inconsistencies,
+			btcjson.VerifyChainInconsistencyResult{
+				Hash:        hash,
+				Height:      inconsistency.Height,
+				Description: inconsistency.Description,
+			})
+	}
+	return reply, nil
+}
+
+// handleWatchAddresses implements the watchaddresses command.
+func handleWatchAddresses(s *rpcServer, cmd interface{}, closeChan <-chan struct{}) (interface{}, error) {
+	watchIndex, err := watchIndexRequired(s)
+	if err != nil {
+		return nil, err
+	}
+
+	c := cmd.(*btcjson.WatchAddressesCmd)
+	targets, err := decodeWatchTargets(s, c.Addresses)
+	if err != nil {
+		return nil, err
+	}
+	numAdded, err := watchIndex.Watch(targets)
+	if err != nil {
+		context := "Failed to watch addresses"
+		return nil, internalRPCError(err.Error(), context)
+	}
+	return numAdded, nil
 }
 
 // rpcServer holds the items the rpc server may need to access (config,
@@ -3438,11 +7387,14 @@ type rpcServer struct {
 	authsha                [sha256.Size]byte
 	limitauthsha           [sha256.Size]byte
 	ntfnMgr                *wsNotificationManager
+	peerEventsID           int
+	rescans                *rescanEngine
 	numClients             int32
 	statusLines            map[int]string
 	statusLock             sync.RWMutex
 	wg                     sync.WaitGroup
 	listeners              []net.Listener
+	httpServer             *http.Server
 	gbtWorkState           *gbtWorkState
 	helpCacher             *helpCacher
 	requestProcessShutdown chan struct{}
@@ -3511,13 +7463,26 @@ func (s *rpcServer) Stop() error {
 		return nil
 	}
 	rpcsLog.Warnf("RPC server shutting down")
-	for _, listener := range s.listeners {
-		err := listener.Close()
+
+	// Stop accepting requests and wait for the in-flight requests to
+	// finish.  Websocket connections are not tracked by the HTTP server,
+	// they are shut down by the notification manager below.
+	if s.httpServer != nil {
+		err := s.httpServer.Shutdown(context.Background())
 		if err != nil {
 			rpcsLog.Errorf("Problem shutting down rpc: %v", err)
 			return err
 		}
+	} else {
+		for _, listener := range s.listeners {
+			err := listener.Close()
+			if err != nil {
+				rpcsLog.Errorf("Problem shutting down rpc: %v", err)
+				return err
+			}
+		}
 	}
+	s.server.UnsubscribePeerEvents(s.peerEventsID)
 	s.ntfnMgr.Shutdown()
 	s.ntfnMgr.WaitForShutdown()
 	close(s.quit)
@@ -3538,9 +7503,10 @@ func (s *rpcServer) RequestedProcessShutdown() <-chan struct{} {
 //
 // This function is safe for concurrent access.
 func (s *rpcServer) limitConnections(w http.ResponseWriter, remoteAddr string) bool {
-	if int(atomic.LoadInt32(&s.numClients)+1) > cfg.RPCMaxClients {
+	maxClients := settings().rpcMaxClients
+	if int(atomic.LoadInt32(&s.numClients)+1) > maxClients {
 		rpcsLog.Infof("Max RPC clients exceeded [%d] - "+
-			"disconnecting client %s", cfg.RPCMaxClients,
+			"disconnecting client %s", maxClients,
 			remoteAddr)
 		http.Error(w, "503 Too busy.  Try again later.",
 			http.StatusServiceUnavailable)
@@ -3733,6 +7699,51 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 	defer buf.Flush()
 	conn.SetReadDeadline(timeZeroVal)
 
+	// Setup a close notifier.  Since the connection is hijacked, the
+	// CloseNotifer on the ResponseWriter is not available.
+	closeChan := make(chan struct{}, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		if err != nil {
+			close(closeChan)
+		}
+	}()
+
+	// A body which is a JSON array is a batch of requests.  Otherwise it is
+	// a single request.
+	var msg []byte
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		msg = s.processBatch(trimmed, isAdmin, cfg.RPCMaxBatchSize,
+			cfg.RPCMaxConcurrentReqs, closeChan)
+	} else {
+		msg = s.processRequest(body, isAdmin, closeChan)
+	}
+
+	// There is nothing to respond to notifications.
+	if msg == nil {
+		return
+	}
+
+	// Write the response.
+	err = s.writeHTTPResponseHeaders(r, w.Header(), http.StatusOK, buf)
+	if err != nil {
+		rpcsLog.Error(err)
+		return
+	}
+	if _, err := buf.Write(msg); err != nil {
+		rpcsLog.Errorf("Failed to write marshalled reply: %v", err)
+	}
+
+	// Terminate with newline to maintain compatibility with Bitcoin Core.
+	if err := buf.WriteByte('\n'); err != nil {
+		rpcsLog.Errorf("Failed to append terminating newline to reply: %v", err)
+	}
+}
+
+// processRequest parses and executes the passed raw JSON-RPC request and
+// returns the marshalled reply.  It returns nil for notifications, which must
+// not be responded to.
+func (s *rpcServer) processRequest(body []byte, isAdmin bool, closeChan <-chan struct{}) []byte {
 	// Attempt to parse the raw body into a JSON-RPC request.
 	var responseID interface{}
 	var jsonErr error
@@ -3764,23 +7775,13 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 		// RPC quirks can be enabled by the user to avoid compatibility issues
 		// with software relying on Core's behavior.
 		if request.ID == nil && !(cfg.RPCQuirks && request.Jsonrpc == "") {
-			return
+			return nil
 		}
 
 		// The parse was at least successful enough to have an ID so
 		// set it for the response.
 		responseID = request.ID
 
-		// Setup a close notifier.  Since the connection is hijacked,
-		// the CloseNotifer on the ResponseWriter is not available.
-		closeChan := make(chan struct{}, 1)
-		go func() {
-			_, err := conn.Read(make([]byte, 1))
-			if err != nil {
-				close(closeChan)
-			}
-		}()
-
 		// Check if the user is limited and set error if method unauthorized
 		if !isAdmin {
 			if _, ok := rpcLimited[request.Method]; !ok {
@@ -3807,23 +7808,83 @@ func (s *rpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request, isAdmin
 	msg, err := createMarshalledReply(responseID, result, jsonErr)
 	if err != nil {
 		rpcsLog.Errorf("Failed to marshal reply: %v", err)
-		return
+		return nil
 	}
+	return msg
+}
 
-	// Write the response.
-	err = s.writeHTTPResponseHeaders(r, w.Header(), http.StatusOK, buf)
-	if err != nil {
-		rpcsLog.Error(err)
-		return
+// processBatch executes the requests in the passed raw JSON-RPC batch with at
+// most maxConcurrent of them running at a time, and returns the marshalled
+// array of their replies in the order of the requests.  The replies to
+// notifications are left out, so it returns nil when the batch only consists
+// of notifications.  Batches which are not a non-empty JSON array of at most
+// maxBatchSize requests are replied to with a single error.
+func (s *rpcServer) processBatch(body []byte, isAdmin bool, maxBatchSize, maxConcurrent int, closeChan <-chan struct{}) []byte {
+	var requests []json.RawMessage
+	var jsonErr error
+	if err := json.Unmarshal(body, &requests); err != nil {
+		jsonErr = &btcjson.RPCError{
+			Code:    btcjson.ErrRPCParse.Code,
+			Message: "Failed to parse request: " + err.Error(),
+		}
+	} else if len(requests) == 0 {
+		jsonErr = &btcjson.RPCError{
+			Code:    btcjson.ErrRPCInvalidRequest.Code,
+			Message: "Empty batch request",
+		}
+	} else if len(requests) > maxBatchSize {
+		jsonErr = &btcjson.RPCError{
+			Code: btcjson.ErrRPCInvalidRequest.Code,
+			Message: fmt.Sprintf("Batch of %d requests exceeds the "+
+				"maximum of %d", len(requests), maxBatchSize),
+		}
 	}
-	if _, err := buf.Write(msg); err != nil {
-		rpcsLog.Errorf("Failed to write marshalled reply: %v", err)
+	if jsonErr != nil {
+		msg, err := createMarshalledReply(nil, nil, jsonErr)
+		if err != nil {
+			rpcsLog.Errorf("Failed to marshal reply: %v", err)
+			return nil
+		}
+		return msg
 	}
 
-	// Terminate with newline to maintain compatibility with Bitcoin Core.
-	if err := buf.WriteByte('\n'); err != nil {
-		rpcsLog.Errorf("Failed to append terminating newline to reply: %v", err)
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	replies := make([][]byte, len(requests))
+	sem := makeSemaphore(maxConcurrent)
+	var wg sync.WaitGroup
+	for i := range requests {
+		sem.acquire()
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				sem.release()
+				wg.Done()
+			}()
+			replies[i] = s.processRequest(requests[i], isAdmin,
+				closeChan)
+		}(i)
+	}
+	wg.Wait()
+
+	var msg bytes.Buffer
+	for _, reply := range replies {
+		if reply == nil {
+			continue
+		}
+		if msg.Len() == 0 {
+			msg.WriteByte('[')
+		} else {
+			msg.WriteByte(',')
+		}
+		msg.Write(reply)
+	}
+	if msg.Len() == 0 {
+		return nil
 	}
+	msg.WriteByte(']')
+	return msg.Bytes()
 }
 
 // jsonAuthFail sends a message back to the client if the http auth is rejected.
@@ -3847,6 +7908,7 @@ func (s *rpcServer) Start() {
 		// handshake within the allowed timeframe.
 		ReadTimeout: time.Second * rpcAuthTimeoutSeconds,
 	}
+	s.httpServer = httpServer
 	rpcServeMux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Connection", "close")
 		w.Header().Set("Content-Type", "application/json")
@@ -3870,6 +7932,24 @@ func (s *rpcServer) Start() {
 		s.jsonRPCRead(w, r, isAdmin)
 	})
 
+	// Unauthenticated REST endpoints.
+	if cfg.REST {
+		rpcServeMux.HandleFunc(restPathPrefix, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Connection", "close")
+			r.Close = true
+
+			// Limit the number of connections to max allowed.
+			if s.limitConnections(w, r.RemoteAddr) {
+				return
+			}
+
+			// Keep track of the number of connected clients.
+			s.incrementClients()
+			defer s.decrementClients()
+			s.serveREST(w, r)
+		})
+	}
+
 	// Websocket endpoint.
 	rpcServeMux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		authenticated, isAdmin, err := s.checkAuth(r, false)
@@ -3903,6 +7983,7 @@ func (s *rpcServer) Start() {
 	}
 
 	s.ntfnMgr.Start()
+	s.peerEventsID = s.server.SubscribePeerEvents(s.ntfnMgr.NotifyPeerEvent)
 }
 
 // genCertPair generates a key/cert pair to the paths provided.
@@ -3939,7 +8020,7 @@ func newRPCServer(listenAddrs []string, generator *mining.BlkTmplGenerator, s *s
 		gbtWorkState:           newGbtWorkState(s.timeSource),
 		helpCacher:             newHelpCacher(),
 		requestProcessShutdown: make(chan struct{}),
-		quit: make(chan int),
+		quit:                   make(chan int),
 	}
 	if cfg.RPCUser != "" && cfg.RPCPass != "" {
 		login := cfg.RPCUser + ":" + cfg.RPCPass
@@ -3952,6 +8033,13 @@ func newRPCServer(listenAddrs []string, generator *mining.BlkTmplGenerator, s *s
 		rpc.limitauthsha = sha256.Sum256([]byte(auth))
 	}
 	rpc.ntfnMgr = newWsNotificationManager(&rpc)
+	rescans, err := newRescanEngine(s.db, s.blockManager.chain,
+		s.blockManager.QueuedMsgs, uint32(cfg.RescanBatchSize),
+		cfg.RescanMaxRate)
+	if err != nil {
+		return nil, err
+	}
+	rpc.rescans = rescans
 
 	// Setup TLS if not disabled.
 	listenFunc := net.Listen
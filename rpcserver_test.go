@@ -0,0 +1,238 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestProcessBatch ensures the requests of a JSON-RPC batch are all replied to
+// in order, and that malformed batches are replied to with a single error.
+func TestProcessBatch(t *testing.T) {
+	t.Parallel()
+
+	// batchReply houses the parts of a reply the test checks.
+	type batchReply struct {
+		ID    interface{}       `json:"id"`
+		Error *btcjson.RPCError `json:"error"`
+	}
+
+	tests := []struct {
+		name    string
+		batch   string
+		isAdmin bool
+		want    []batchReply
+	}{
+		{
+			name: "replies in order",
+			batch: `[{"jsonrpc":"1.0","method":"getwork","params":[],"id":1},` +
+				`{"jsonrpc":"1.0","method":"nosuchmethod","params":[],"id":"b"},` +
+				`{"jsonrpc":"1.0","method":"getwork","params":[],"id":3}]`,
+			isAdmin: true,
+			want: []batchReply{
+				{ID: 1.0, Error: ErrRPCUnimplemented},
+				{ID: "b", Error: btcjson.ErrRPCMethodNotFound},
+				{ID: 3.0, Error: ErrRPCUnimplemented},
+			},
+		},
+		{
+			name:    "limited user",
+			batch:   `[{"jsonrpc":"1.0","method":"getnetworkinfo","params":[],"id":1}]`,
+			isAdmin: false,
+			want: []batchReply{{
+				ID: 1.0,
+				Error: &btcjson.RPCError{
+					Code:    btcjson.ErrRPCInvalidParams.Code,
+					Message: "limited user not authorized for this method",
+				},
+			}},
+		},
+		{
+			name:    "malformed request in batch",
+			batch:   `[5]`,
+			isAdmin: true,
+			want: []batchReply{{
+				Error: &btcjson.RPCError{Code: btcjson.ErrRPCParse.Code},
+			}},
+		},
+	}
+
+	s := &rpcServer{}
+	for _, test := range tests {
+		msg := s.processBatch([]byte(test.batch), test.isAdmin, 10, 2,
+			nil)
+		var replies []batchReply
+		if err := json.Unmarshal(msg, &replies); err != nil {
+			t.Errorf("%s: unable to unmarshal replies %s: %v",
+				test.name, msg, err)
+			continue
+		}
+		if len(replies) != len(test.want) {
+			t.Errorf("%s: got %d replies, want %d", test.name,
+				len(replies), len(test.want))
+			continue
+		}
+		for i, reply := range replies {
+			want := test.want[i]
+			if reply.ID != want.ID || reply.Error == nil ||
+				reply.Error.Code != want.Error.Code {
+
+				t.Errorf("%s: reply #%d: got id %v and error %v, "+
+					"want id %v and error %v", test.name, i,
+					reply.ID, reply.Error, want.ID, want.Error)
+				continue
+			}
+			if want.Error.Message != "" &&
+				reply.Error.Message != want.Error.Message {
+
+				t.Errorf("%s: reply #%d: got error %q, want %q",
+					test.name, i, reply.Error.Message,
+					want.Error.Message)
+			}
+		}
+	}
+
+	// Batches which are not a non-empty array of at most the maximum number
+	// of requests are replied to with a single error.
+	request := `{"jsonrpc":"1.0","method":"getwork","params":[],"id":1}`
+	tooLarge := "[" + strings.Repeat(request+",", 10) + request + "]"
+	for _, batch := range []string{`[]`, `[{"jsonrpc":"1.0"`, tooLarge} {
+		msg := s.processBatch([]byte(batch), true, 10, 2, nil)
+		var reply batchReply
+		if err := json.Unmarshal(msg, &reply); err != nil {
+			t.Errorf("%s: unable to unmarshal reply %s: %v", batch,
+				msg, err)
+			continue
+		}
+		if reply.Error == nil {
+			t.Errorf("%s: no error in reply %s", batch, msg)
+		}
+	}
+}
+
+// TestGbtAdminThreads ensures the admin threads of block templates report the
+// tips of the threads along with the template transactions which spend them.
+func TestGbtAdminThreads(t *testing.T) {
+	t.Parallel()
+
+	rootTip := wire.NewOutPoint(&chainhash.Hash{1}, 0)
+	issueTip := wire.NewOutPoint(&chainhash.Hash{2}, 1)
+	threadTips := map[provautil.ThreadID]*wire.OutPoint{
+		provautil.RootThread:  rootTip,
+		provautil.IssueThread: issueTip,
+	}
+
+	// Create a template which spends the tip of the issue thread in its
+	// second transaction after the coinbase.
+	msgBlock := wire.NewMsgBlock(&wire.BlockHeader{})
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{},
+		wire.MaxPrevOutIndex), nil))
+	msgBlock.AddTransaction(coinbase)
+	otherTx := wire.NewMsgTx(1)
+	otherTx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{3}, 0), nil))
+	msgBlock.AddTransaction(otherTx)
+	issueTx := wire.NewMsgTx(1)
+	issueTx.AddTxIn(wire.NewTxIn(issueTip, nil))
+	msgBlock.AddTransaction(issueTx)
+
+	got := gbtAdminThreads(threadTips, msgBlock)
+	want := []btcjson.GetBlockTemplateResultAdminThread{
+		{ID: 0, Name: "root", OutPoint: rootTip.String()},
+		{ID: 1, Name: "provision"},
+		{ID: 2, Name: "issue", OutPoint: issueTip.String(), SpentBy: 2},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("gbtAdminThreads: got %d threads, want %d", len(got),
+			len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("gbtAdminThreads: got thread %+v, want %+v", got[i],
+				want[i])
+		}
+	}
+}
+
+// TestVerificationProgress ensures the verification progress accounts for both
+// the headers known ahead of the best block and the blocks expected to be found
+// since the best block.
+func TestVerificationProgress(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1500000000, 0)
+	tests := []struct {
+		name          string
+		height        uint32
+		headersHeight uint32
+		blockTime     time.Time
+		want          float64
+	}{
+		{
+			name:          "synced",
+			height:        100,
+			headersHeight: 100,
+			blockTime:     now,
+			want:          1,
+		},
+		{
+			name:          "headers ahead",
+			height:        100,
+			headersHeight: 300,
+			blockTime:     now,
+			want:          100.0 / 300.0,
+		},
+		{
+			name:          "stale tip",
+			height:        100,
+			headersHeight: 100,
+			blockTime:     now.Add(-100 * time.Minute),
+			want:          0.5,
+		},
+		{
+			name:          "block time ahead",
+			height:        100,
+			headersHeight: 100,
+			blockTime:     now.Add(time.Hour),
+			want:          1,
+		},
+	}
+
+	for _, test := range tests {
+		got := verificationProgress(test.height, test.headersHeight,
+			test.blockTime, now, time.Minute)
+		if got != test.want {
+			t.Errorf("%s: got progress %v, want %v", test.name, got,
+				test.want)
+		}
+	}
+}
+
+// TestServiceNames ensures the service flags are named by the names of the
+// reference implementation, and unknown flags by their bits.
+func TestServiceNames(t *testing.T) {
+	t.Parallel()
+
+	services := wire.SFNodeNetwork | wire.SFNodeBloom | wire.SFNodeCF |
+		wire.SFNodeTLS | wire.ServiceFlag(1)<<10
+	got := serviceNames(services)
+	want := []string{"NETWORK", "BLOOM", "COMPACT_FILTERS", "UNKNOWN[10]",
+		"TLS"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("serviceNames: got %v, want %v", got, want)
+	}
+	if got := serviceNames(0); len(got) != 0 {
+		t.Errorf("serviceNames: got %v for no services, want none", got)
+	}
+}
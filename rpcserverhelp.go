@@ -30,6 +30,73 @@ var helpDescsEnUS = map[string]string{
 	"debuglevel--result0":    "The string 'Done.'",
 	"debuglevel--result1":    "The list of subsystems",
 
+	// DropIndexCmd help.
+	"dropindex--synopsis": "Stops maintaining the passed optional index and removes all of its entries from the database.\n" +
+		"The index can be enabled again with rebuildindex without restarting the node.",
+	"dropindex-index": "The name or database key of the index as reported by getindexinfo",
+
+	// BackupChainStateCmd help.
+	"backupchainstate--synopsis": "Writes a consistent copy of the block database to a new directory on the server while the node keeps running.\n" +
+		"The copy is opened by pointing a node with the same database type at it.",
+	"backupchainstate-path": "The path of the directory on the server to write the copy to, which must not exist yet",
+
+	// BackupChainStateResult help.
+	"backupchainstateresult-path":   "The path of the written copy",
+	"backupchainstateresult-height": "The height of the best block the copy houses",
+	"backupchainstateresult-hash":   "The hash of the best block the copy houses",
+
+	// DumpUTXOSnapshotCmd help.
+	"dumputxosnapshot--synopsis": "Writes a snapshot of the unspent transaction outputs and admin state as of the main chain block at the passed height to a file on the server.\n" +
+		"A new node is able to bootstrap from the snapshot with the --utxosnapshot option instead of downloading all of the blocks before it, as long as the block is one of its checkpoints.\n" +
+		"Snapshots of blocks before the end of the main chain are recreated from the spend journal, which requires the data of the blocks after it.",
+	"dumputxosnapshot-path":   "The path of the file on the server to write the snapshot to",
+	"dumputxosnapshot-height": "The height of the block to snapshot (default: the best block)",
+
+	// DumpUTXOSnapshotResult help.
+	"dumputxosnapshotresult-path":          "The path of the written snapshot",
+	"dumputxosnapshotresult-height":        "The height of the block of the snapshot",
+	"dumputxosnapshotresult-hash":          "The hash of the block of the snapshot",
+	"dumputxosnapshotresult-integrityhash": "The integrity hash of the snapshot, which is also its last 32 bytes",
+
+	// RebuildIndexCmd help.
+	"rebuildindex--synopsis": "Removes all entries of the passed optional index and rebuilds it from scratch in the background.\n" +
+		"This also enables indexes previously dropped with dropindex.  Use getindexinfo to monitor the progress.",
+	"rebuildindex-index": "The name or database key of the index as reported by getindexinfo",
+
+	// ReloadConfigCmd help.
+	"reloadconfig--synopsis": "Parses the config file and the command line options again and applies the changed options which can be changed at runtime.\n" +
+		"These are debuglevel, nobanning, banduration, banthreshold, rpcmaxclients, rpcmaxwebsockets, minrelaytxfee, limitfreerelay, and relaypriority.\n" +
+		"No options are applied if any of them is invalid.  The configuration is also reloaded when the SIGHUP signal is received.",
+
+	// ReloadConfigResult help.
+	"reloadconfigresult-applied":         "The names of the changed options which were applied",
+	"reloadconfigresult-requiresrestart": "The names of the changed options which only take effect after a restart",
+
+	// CheckIndexCmd help.
+	"checkindex--synopsis": "Cross-checks the entries the passed optional index stores for the main chain blocks in the given range of block heights against the blocks and optionally repairs divergent entries.\n" +
+		"Only blocks the index has caught up to are checked.  Indexes which can't be checked this way must be rebuilt with rebuildindex instead.",
+	"checkindex-index":       "The name or database key of the index as reported by getindexinfo",
+	"checkindex-startheight": "The height of the first block to check",
+	"checkindex-endheight":   "The height of the last block to check (default: the current best height)",
+	"checkindex-interval":    "Only check every interval-th block of the range, which allows large ranges to be sampled",
+	"checkindex-repair":      "Rewrite the divergent entries",
+
+	// CheckIndexResult help.
+	"checkindexresult-index":           "The name of the checked index",
+	"checkindexresult-blockschecked":   "The number of blocks which were checked",
+	"checkindexresult-inconsistencies": "The divergent entries which were found",
+
+	// IndexInconsistencyResult help.
+	"indexinconsistencyresult-height":   "Height of the block the entry belongs to",
+	"indexinconsistencyresult-hash":     "Hash of the block the entry belongs to",
+	"indexinconsistencyresult-key":      "The hex-encoded database key of the entry",
+	"indexinconsistencyresult-reason":   "How the entry diverges (missing, mismatched, or unexpected)",
+	"indexinconsistencyresult-repaired": "Whether or not the entry was rewritten",
+
+	// AbortRescanCmd help.
+	"abortrescan--synopsis": "Stops the rescan with the passed job ID if it is running and removes its checkpoint.",
+	"abortrescan-jobid":     "The ID of the rescan job as returned by getrescaninfo",
+
 	// AddNodeCmd help.
 	"addnode--synopsis": "Attempts to add or remove a persistent peer.",
 	"addnode-addr":      "IP address and port of the peer to operate on",
@@ -45,6 +112,24 @@ var helpDescsEnUS = map[string]string{
 	"transactioninput-txid": "The hash of the input transaction",
 	"transactioninput-vout": "The specific output of the input transaction to redeem",
 
+	// CreateRawAdminTransactionCmd help.
+	"createrawadmintransaction--synopsis": "Returns a new admin transaction which carries out the passed operation and spends the tip of its admin thread, including pending admin transactions in the memory pool.\n" +
+		"The operation is one of issuekeyadd, issuekeyrevoke, provisionkeyadd, provisionkeyrevoke, validatekeyadd, validatekeyrevoke, aspkeyadd, aspkeyrevoke, issue, or destroy.\n" +
+		"The transaction is not signed. The thread input requires the signatures of two keys of the key set of the thread.",
+	"createrawadmintransaction-operation": "The admin operation",
+	"createrawadmintransaction-params":    "The parameters of the operation",
+	"createrawadmintransaction--result0":  "Hex-encoded bytes of the serialized transaction",
+
+	// CreateRawAdminTransactionParams help.
+	"createrawadmintransactionparams-pubkey":         "The hex-encoded compressed public key which is added or revoked by the key operations",
+	"createrawadmintransactionparams-keyid":          "The key ID which is bound to the key by the ASP key operations",
+	"createrawadmintransactionparams-amounts":        "JSON object with the addresses new tokens are issued to as keys and amounts as values (issue only)",
+	"createrawadmintransactionparams-amounts--key":   "address",
+	"createrawadmintransactionparams-amounts--value": "n.nnn",
+	"createrawadmintransactionparams-amounts--desc":  "The address as the key and the amount in RMG as the value",
+	"createrawadmintransactionparams-inputs":         "The unspent outputs whose tokens are destroyed (destroy only)",
+	"createrawadmintransactionparams-fee":            "The fee in RMG which is left out of the destroyed tokens (destroy only)",
+
 	// CreateRawTransactionCmd help.
 	"createrawtransaction--synopsis": "Returns a new transaction spending the provided inputs and sending to the provided addresses.\n" +
 		"The transaction inputs are not signed in the created transaction.\n" +
@@ -55,6 +140,7 @@ var helpDescsEnUS = map[string]string{
 	"createrawtransaction-amounts--value": "n.nnn",
 	"createrawtransaction-amounts--desc":  "The destination address as the key and the amount in RMG as the value",
 	"createrawtransaction-locktime":       "Locktime value; a non-zero value will also locktime-activate the inputs",
+	"createrawtransaction-data":           "Hex-encoded data of at most 80 bytes to carry in an additional nulldata output which does not pay any amount, such as a hash to anchor on the chain",
 	"createrawtransaction--result0":       "Hex-encoded bytes of the serialized transaction",
 
 	// ScriptSig help.
@@ -100,6 +186,26 @@ var helpDescsEnUS = map[string]string{
 	"txrawdecoderesult-vin":      "The transaction inputs as JSON objects",
 	"txrawdecoderesult-vout":     "The transaction outputs as JSON objects",
 
+	// DecodeAdminTransactionCmd help.
+	"decodeadmintransaction--synopsis": "Returns a JSON object describing the admin thread the provided serialized, hex-encoded admin transaction carries on and the operations it carries out.\n" +
+		"The transaction is not checked against the admin state of the chain.",
+	"decodeadmintransaction-hextx": "Serialized, hex-encoded admin transaction",
+
+	// DecodeAdminTransactionResult help.
+	"decodeadmintransactionresult-txid":       "The hash of the transaction",
+	"decodeadmintransactionresult-thread":     "The ID of the admin thread the transaction carries on",
+	"decodeadmintransactionresult-threadname": "The name of the admin thread (root, provision, or issue)",
+	"decodeadmintransactionresult-threadtip":  "The tip of the thread spent by the transaction",
+	"decodeadmintransactionresult-operations": "The operations carried out by the transaction",
+	"decodeadmintransactionresult-destroyed":  "The outputs whose tokens are destroyed by the transaction",
+
+	// AdminOpResult help.
+	"adminopresult-operation": "The operation, as accepted by createrawadmintransaction (e.g. validatekeyadd, issue, or destroy)",
+	"adminopresult-pubkey":    "The hex-encoded compressed public key which is added or revoked by the key operations",
+	"adminopresult-keyid":     "The key ID which is bound to the key by the ASP key operations",
+	"adminopresult-address":   "The address new tokens are issued to (issue only)",
+	"adminopresult-amount":    "The amount in RMG which is issued or destroyed",
+
 	// DecodeRawTransactionCmd help.
 	"decoderawtransaction--synopsis": "Returns a JSON object representing the provided serialized, hex-encoded transaction.",
 	"decoderawtransaction-hextx":     "Serialized, hex-encoded transaction",
@@ -108,16 +214,122 @@ var helpDescsEnUS = map[string]string{
 	"setvalidatekeys--synopsis": "Sets the private keys to use to sign generated blocks",
 	"setvalidatekeys-privkeys":  "Hex-encoded 32 byte private keys",
 
+	// SimulateChainCmd help.
+	"simulatechain--synopsis": "Generates the blocks of the passed scenario and processes them in order, which reproduces a chain deterministically.\n" +
+		"The scenario is a JSON object with a seed, an optional starttime, and a list of steps.\n" +
+		"Each step has an op of mine (count blocks), fork (count blocks on the block named from), admin (an action such as issue, issuekeyadd, or aspkeyadd with pubkey, keyid, and amount), or txbatch (count transactions spending mature coinbase outputs), and optionally names its last block.\n" +
+		"The chain builds from the genesis block, so it is meant for fresh nodes.  Only available on the regression test network.",
+	"simulatechain-scenario": "The JSON-encoded scenario",
+
+	// SimulatedBlockResult help.
+	"simulatedblockresult-name":      "The name of the block in the scenario",
+	"simulatedblockresult-hash":      "The hash of the block",
+	"simulatedblockresult-height":    "The height of the block",
+	"simulatedblockresult-mainchain": "Whether the block was part of the main chain once processed",
+	"simulatedblockresult-error":     "The reason the block was rejected",
+
+	// DebugScriptCmd help.
+	"debugscript--synopsis": "Executes the signature script of a transaction input along with the public key script of the output it spends one opcode at a time and returns the state of the script engine after every opcode.\n" +
+		"This is useful to find out why a spend, such as a Prova multisig spend, fails.",
+	"debugscript-hextx":        "Serialized, hex-encoded transaction",
+	"debugscript-vin":          "The index of the input to execute the scripts of",
+	"debugscript-scriptpubkey": "The hex-encoded public key script of the spent output (default: looked up in the memory pool and the utxo set)",
+	"debugscript-amount":       "The amount of the spent output in RMG, which is covered by the signatures (default: looked up along with the public key script, otherwise 0)",
+
+	// DebugScriptStepResult help.
+	"debugscriptstepresult-script":    "The index of the script of the opcode (0 for the signature script, 1 for the public key script, 2 for a pay-to-script-hash redeem script)",
+	"debugscriptstepresult-index":     "The index of the opcode within its script",
+	"debugscriptstepresult-opcode":    "Disassembly of the opcode",
+	"debugscriptstepresult-executed":  "Whether the opcode was executed, rather than skipped in a conditional branch which is not executing",
+	"debugscriptstepresult-stack":     "The hex-encoded items of the data stack after the opcode, the top item last",
+	"debugscriptstepresult-altstack":  "The hex-encoded items of the alternate stack after the opcode, the top item last",
+	"debugscriptstepresult-remaining": "Disassembly of the remaining opcodes of the script",
+
+	// DebugScriptResult help.
+	"debugscriptresult-valid":    "Whether the scripts executed successfully",
+	"debugscriptresult-error":    "The reason the scripts failed",
+	"debugscriptresult-failedat": "Disassembly of the opcode which failed, prefixed by the script index and offset",
+	"debugscriptresult-steps":    "The state of the script engine after every opcode which executed successfully",
+
+	// CreatePSPTCmd help.
+	"createpspt--synopsis": "Returns a new base64-encoded partially signed Prova transaction (PSPT) of the provided unsigned transaction, which is passed between the signers of its inputs.\n" +
+		"The PSPT carries the outputs spent by the inputs along with the ASP keys their key IDs are bound to on the best chain, so signers are able to check what they sign without access to the chain.",
+	"createpspt-hextx":    "Serialized, hex-encoded unsigned transaction spending unspent outputs",
+	"createpspt--result0": "The base64-encoded PSPT",
+
+	// DecodePSPTCmd help.
+	"decodepspt--synopsis": "Returns a JSON object representing the provided PSPT.",
+	"decodepspt-pspt":      "The base64-encoded PSPT",
+
+	// PSPTKeyIDResult help.
+	"psptkeyidresult-keyid":  "The key ID used by the public key script",
+	"psptkeyidresult-pubkey": "The hex-encoded ASP key the key ID is bound to",
+
+	// PSPTDerivationResult help.
+	"psptderivationresult-pubkey":      "The hex-encoded public key able to sign the input",
+	"psptderivationresult-fingerprint": "The hex-encoded fingerprint of the master key the public key derives from",
+	"psptderivationresult-path":        "The BIP0032 derivation path of the public key (e.g. m/44'/0'/0)",
+
+	// PSPTSignatureResult help.
+	"psptsignatureresult-pubkey":    "The hex-encoded public key of the signer",
+	"psptsignatureresult-signature": "The hex-encoded signature, including the hash type",
+
+	// DecodePSPTInputResult help.
+	"decodepsptinputresult-amount":       "The amount of the spent output in RMG",
+	"decodepsptinputresult-scriptpubkey": "The hex-encoded public key script of the spent output",
+	"decodepsptinputresult-required":     "The number of signatures required to spend the output (0 for scripts other than Prova scripts)",
+	"decodepsptinputresult-keyids":       "The key IDs of the public key script and the ASP keys they are bound to",
+	"decodepsptinputresult-derivations":  "The derivations of the keys able to sign the input",
+	"decodepsptinputresult-signatures":   "The signatures collected so far",
+
+	// DecodePSPTResult help.
+	"decodepsptresult-tx":       "The transaction of the PSPT",
+	"decodepsptresult-inputs":   "The outputs spent by the inputs of the transaction, in the same order",
+	"decodepsptresult-complete": "Whether every input carries the required number of signatures",
+
+	// CombinePSPTCmd help.
+	"combinepspt--synopsis": "Combines the signatures, key IDs, and derivations of the provided PSPTs of the same transaction, which were collected by separate signers, and returns the base64-encoded combined PSPT.",
+	"combinepspt-pspts":     "The base64-encoded PSPTs to combine",
+	"combinepspt--result0":  "The base64-encoded combined PSPT",
+
+	// FinalizePSPTCmd help.
+	"finalizepspt--synopsis": "Verifies the scripts of a fully signed PSPT against the ASP keys of the best chain and returns the serialized, hex-encoded transaction ready to be sent.\n" +
+		"A PSPT which lacks signatures is returned unchanged.",
+	"finalizepspt-pspt": "The base64-encoded PSPT",
+
+	// FinalizePSPTResult help.
+	"finalizepsptresult-pspt":     "The base64-encoded PSPT when it lacks signatures",
+	"finalizepsptresult-hex":      "The serialized, hex-encoded transaction when the PSPT is complete",
+	"finalizepsptresult-complete": "Whether every input carries the required number of signatures",
+
 	// DecodeScriptResult help.
-	"decodescriptresult-asm":       "Disassembly of the script",
+	"decodescriptresult-asm":       "Disassembly of the script, annotated when requested",
 	"decodescriptresult-reqSigs":   "The number of required signatures",
 	"decodescriptresult-type":      "The type of the script (e.g. 'pubkeyhash')",
-	"decodescriptresult-addresses": "The bitcoin addresses associated with this script",
-	"decodescriptresult-p2sh":      "The script hash for use in pay-to-script-hash transactions",
+	"decodescriptresult-addresses": "The Prova addresses associated with this script",
+	"decodescriptresult-p2sh":      "The hex-encoded hash of the script for use in pay-to-script-hash transactions",
 
 	// DecodeScriptCmd help.
 	"decodescript--synopsis": "Returns a JSON object with information about the provided hex-encoded script.",
 	"decodescript-hexscript": "Hex-encoded script",
+	"decodescript-annotated": "Label the key IDs, the thresholds, and the key hashes of Prova scripts, the thread of admin thread scripts, and the operation and key of admin operations in the disassembly",
+
+	// EstimateFeeCmd help.
+	"estimatefee--synopsis": "Estimates the fee rate a transaction needs to pay to be mined within the passed number of blocks, based on how long it took to mine the recent transactions which entered the memory pool.\n" +
+		"Returns -1 when there is not enough data to estimate a fee rate.",
+	"estimatefee-numblocks": "The number of blocks within which the transaction should be mined",
+	"estimatefee--result0":  "The estimated fee rate in RMG per kilobyte, or -1",
+
+	// EstimateSmartFeeCmd help.
+	"estimatesmartfee--synopsis": "Estimates the fee rate a transaction needs to pay to be mined within the passed number of blocks, based on how long it took to mine the recent transactions which entered the memory pool.\n" +
+		"When there is not enough data for the passed target, the estimate for the lowest longer target with enough data is returned instead.",
+	"estimatesmartfee-conftarget":   "The number of blocks within which the transaction should be mined",
+	"estimatesmartfee-estimatemode": "The required confidence: CONSERVATIVE (95%) or ECONOMICAL (85%) of the recent transactions paying the rate mined within the target",
+
+	// EstimateSmartFeeResult help.
+	"estimatesmartfeeresult-feerate": "The estimated fee rate in RMG per kilobyte, which is never below the minimum relay fee (only when an estimate is available)",
+	"estimatesmartfeeresult-errors":  "Why no estimate is available (only when no estimate is available)",
+	"estimatesmartfeeresult-blocks":  "The number of blocks the estimate is for",
 
 	// GenerateCmd help
 	"generate--synopsis": "Generates a set number of blocks (simnet or regtest only) and returns a JSON\n" +
@@ -126,6 +338,14 @@ var helpDescsEnUS = map[string]string{
 	"generate-validatekeys": "Hex-encoded private keys to use for block signing",
 	"generate--result0":     "The hashes, in order, of blocks generated by the call",
 
+	// GenerateToAddressCmd help
+	"generatetoaddress--synopsis": "Generates a set number of blocks paying to the given address (simnet or regtest only)\n" +
+		" and returns a JSON array of their hashes.",
+	"generatetoaddress-numblocks": "Number of blocks to generate",
+	"generatetoaddress-address":   "The address the coinbase outputs of the generated blocks pay to",
+	"generatetoaddress-txids":     "The hashes of the memory pool transactions to include in the first block, which are then the only transactions of the generated blocks",
+	"generatetoaddress--result0":  "The hashes, in order, of blocks generated by the call",
+
 	// GetAddedNodeInfoResultAddr help.
 	"getaddednodeinforesultaddr-address":   "The ip address for this DNS entry",
 	"getaddednodeinforesultaddr-connected": "The connection 'direction' (inbound/outbound/false)",
@@ -143,6 +363,31 @@ var helpDescsEnUS = map[string]string{
 	"getaddednodeinfo--condition1": "dns=true",
 	"getaddednodeinfo--result0":    "List of added peers",
 
+	// GetAddressBalanceCmd help.
+	"getaddressbalance--synopsis": "Returns the current confirmed balance of the passed address or key ID.\n" +
+		"Usage of this RPC requires the optional --addrbalanceindex flag to be activated, otherwise all responses will simply return with an error stating the address balance index has not yet been built.",
+	"getaddressbalance-address": "The address or key ID to return the balance for",
+
+	// GetAddressBalanceResult help.
+	"getaddressbalanceresult-balance":   "The total value of the unspent outputs in RMG",
+	"getaddressbalanceresult-utxocount": "The number of unspent outputs",
+
+	// GetAddressIssuanceCmd help.
+	"getaddressissuance--synopsis": "Returns all tokens issued to or destroyed from the passed address in the given range of block heights.\n" +
+		"Usage of this RPC requires the optional --issuanceindex flag to be activated, otherwise all responses will simply return with an error stating the issuance index has not yet been built.",
+	"getaddressissuance-address":     "The address to return issuance and destruction for",
+	"getaddressissuance-startheight": "The height of the first block to include",
+	"getaddressissuance-endheight":   "The height of the last block to include (default: the current best height)",
+
+	// AddressIssuanceResult help.
+	"addressissuanceresult-type":     "The type of entry (issue or destroy)",
+	"addressissuanceresult-height":   "Height of the block containing the issuance or destruction transaction",
+	"addressissuanceresult-txid":     "The hash of the issuance or destruction transaction",
+	"addressissuanceresult-index":    "The output index for issued outputs or the input index for destroyed outputs",
+	"addressissuanceresult-prevtxid": "The hash of the transaction containing the destroyed output",
+	"addressissuanceresult-prevvout": "The index of the destroyed output",
+	"addressissuanceresult-value":    "The value issued or destroyed in RMG",
+
 	// GetAddressTxIds help.
 	"getaddresstxids--synopsis": "Returns transaction-ids involving the passed address.\n" +
 		"Usage of this RPC requires the optional --addrindex flag to be activated, otherwise all responses will simply return with an error stating the address index has not yet been built.\n" +
@@ -155,6 +400,18 @@ var helpDescsEnUS = map[string]string{
 	"addresstxrequest-start":     "The block to start at",
 	"addresstxrequest-end":       "The block to end at",
 
+	// GetAddressUtxosCmd help.
+	"getaddressutxos--synopsis": "Returns the unspent outputs paying to the passed address or key ID.\n" +
+		"Usage of this RPC requires the optional --addrbalanceindex flag to be activated, otherwise all responses will simply return with an error stating the address balance index has not yet been built.",
+	"getaddressutxos-address": "The address or key ID to return the unspent outputs for",
+
+	// AddressUtxoResult help.
+	"addressutxoresult-txid":         "The hash of the transaction containing the output",
+	"addressutxoresult-vout":         "The index of the output",
+	"addressutxoresult-height":       "Height of the block containing the output",
+	"addressutxoresult-value":        "The value of the output in RMG",
+	"addressutxoresult-scriptpubkey": "The hex-encoded public key script of the output",
+
 	// GetBestBlockResult help.
 	"getbestblockresult-hash":   "Hex-encoded bytes of the best block hash",
 	"getbestblockresult-height": "Height of the best block",
@@ -242,6 +499,46 @@ var helpDescsEnUS = map[string]string{
 	"getblockverboseresult-validatingpubkey":  "The validating public key signing the block",
 	"getblockverboseresult-signature":         "The signature of the block generator",
 
+	// GetBlockChainInfoCmd help.
+	"getblockchaininfo--synopsis": "Returns information about the current state of the block chain, including the status of the consensus rule change deployments.",
+
+	// GetBlockChainInfoResult help.
+	"getblockchaininforesult-chain":                 "The name of the network",
+	"getblockchaininforesult-blocks":                "The height of the best block of the main chain",
+	"getblockchaininforesult-headers":               "The height of the best known header, which is ahead of the blocks while headers are synced",
+	"getblockchaininforesult-bestblockhash":         "The hash of the best block of the main chain",
+	"getblockchaininforesult-difficulty":            "The proof-of-work difficulty as a multiple of the minimum difficulty",
+	"getblockchaininforesult-mediantime":            "The median time of the past blocks as a unix timestamp",
+	"getblockchaininforesult-verificationprogress":  "An estimate of the fraction of the blocks of the chain which are validated",
+	"getblockchaininforesult-initialblockdownload":  "Whether or not the node is still syncing the chain",
+	"getblockchaininforesult-chainwork":             "The hex-encoded total work of the main chain",
+	"getblockchaininforesult-size_on_disk":          "The size of the block database in bytes",
+	"getblockchaininforesult-pruned":                "Whether or not the data of old blocks is pruned",
+	"getblockchaininforesult-pruneheight":           "The height of the first block whose data is retained (only when pruned)",
+	"getblockchaininforesult-automatic_pruning":     "Whether or not blocks are pruned automatically as the chain grows (only when pruned)",
+	"getblockchaininforesult-softforks":             "The status of the consensus rule change deployments",
+	"getblockchaininforesult-softforks--key":        "name",
+	"getblockchaininforesult-softforks--value":      "object",
+	"getblockchaininforesult-softforks--desc":       "The name of each deployment as the key and its status as the value",
+	"getblockchaininforesult-warnings":              "Any network and blockchain warnings",
+	"getblockchaininforesult-bip9_softforks":        "The status of the consensus rule change deployments in the BIP0009 format",
+	"getblockchaininforesult-bip9_softforks--key":   "name",
+	"getblockchaininforesult-bip9_softforks--value": "object",
+	"getblockchaininforesult-bip9_softforks--desc":  "The name of each deployment as the key and its status as the value",
+
+	// SoftForkDescription help.
+	"softforkdescription-type":   "The type of the deployment, which is always bip9",
+	"softforkdescription-bip9":   "The BIP0009 status of the deployment",
+	"softforkdescription-height": "The height at which the deployment activates regardless of its signaling (omitted when it only activates once signaled)",
+	"softforkdescription-active": "Whether or not the deployment is active for the next block",
+
+	// Bip9SoftForkDescription help.
+	"bip9softforkdescription-status":           "The state of the deployment for the next block (defined, started, lockedin, active, or failed)",
+	"bip9softforkdescription-bit":              "The bit of the block version the validators signal the deployment with",
+	"bip9softforkdescription-startTime":        "The median block time as a unix timestamp at which signaling the deployment starts",
+	"bip9softforkdescription-timeout":          "The median block time as a unix timestamp at which the deployment fails when it was not locked in",
+	"bip9softforkdescription-activationheight": "The height at which the deployment activates regardless of its signaling (omitted when it only activates once signaled)",
+
 	// GetBlockCountCmd help.
 	"getblockcount--synopsis": "Returns the number of blocks in the longest block chain.",
 	"getblockcount--result0":  "The current block count",
@@ -251,6 +548,16 @@ var helpDescsEnUS = map[string]string{
 	"getblockhash-index":     "The block height",
 	"getblockhash--result0":  "The block hash",
 
+	// GetBlockHashByTimeCmd help.
+	"getblockhashbytime--synopsis": "Returns the block in the best block chain with the latest timestamp at or before the given time.\n" +
+		"Usage of this RPC requires the optional --timeindex flag to be activated, otherwise all responses will simply return with an error stating the block time index has not yet been built.",
+	"getblockhashbytime-timestamp": "The time in seconds since 1 Jan 1970 GMT",
+
+	// GetBlockHashByTimeResult help.
+	"getblockhashbytimeresult-hash":   "The block hash",
+	"getblockhashbytimeresult-height": "The block height",
+	"getblockhashbytimeresult-time":   "The block time in seconds since 1 Jan 1970 GMT",
+
 	// GetBlockHeaderCmd help.
 	"getblockheader--synopsis":   "Returns information about a block header given its hash.",
 	"getblockheader-hash":        "The hash of the block",
@@ -296,29 +603,88 @@ var helpDescsEnUS = map[string]string{
 	"getblocktemplateresultaux-flags": "Hex-encoded byte-for-byte data to include in the coinbase signature script",
 
 	// GetBlockTemplateResult help.
-	"getblocktemplateresult-bits":              "Hex-encoded compressed difficulty",
-	"getblocktemplateresult-curtime":           "Current time as seen by the server (recommended for block time); must fall within mintime/maxtime rules",
-	"getblocktemplateresult-height":            "Height of the block to be solved",
-	"getblocktemplateresult-previousblockhash": "Hex-encoded big-endian hash of the previous block",
-	"getblocktemplateresult-sigoplimit":        "Number of sigops allowed in blocks ",
-	"getblocktemplateresult-sizelimit":         "Number of bytes allowed in blocks",
-	"getblocktemplateresult-transactions":      "Array of transactions as JSON objects",
-	"getblocktemplateresult-version":           "The block version",
-	"getblocktemplateresult-coinbaseaux":       "Data that should be included in the coinbase signature script",
-	"getblocktemplateresult-coinbasetxn":       "Information about the coinbase transaction",
-	"getblocktemplateresult-coinbasevalue":     "Total amount available for the coinbase in Atoms",
-	"getblocktemplateresult-workid":            "This value must be returned with result if provided (not provided)",
-	"getblocktemplateresult-longpollid":        "Identifier for long poll request which allows monitoring for expiration",
-	"getblocktemplateresult-longpolluri":       "An alternate URI to use for long poll requests if provided (not provided)",
-	"getblocktemplateresult-submitold":         "Not applicable",
-	"getblocktemplateresult-target":            "Hex-encoded big-endian number which valid results must be less than",
-	"getblocktemplateresult-expires":           "Maximum number of seconds (starting from when the server sent the response) this work is valid for",
-	"getblocktemplateresult-maxtime":           "Maximum allowed time",
-	"getblocktemplateresult-mintime":           "Minimum allowed time",
-	"getblocktemplateresult-mutable":           "List of mutations the server explicitly allows",
-	"getblocktemplateresult-noncerange":        "Two concatenated hex-encoded big-endian 32-bit integers which represent the valid ranges of nonces the miner may scan",
-	"getblocktemplateresult-capabilities":      "List of server capabilities including 'proposal' to indicate support for block proposals",
-	"getblocktemplateresult-reject-reason":     "Reason the proposal was invalid as-is (only applies to proposal responses)",
+	"getblocktemplateresult-bits":               "Hex-encoded compressed difficulty",
+	"getblocktemplateresult-curtime":            "Current time as seen by the server (recommended for block time); must fall within mintime/maxtime rules",
+	"getblocktemplateresult-height":             "Height of the block to be solved",
+	"getblocktemplateresult-previousblockhash":  "Hex-encoded big-endian hash of the previous block",
+	"getblocktemplateresult-sigoplimit":         "Number of sigops allowed in blocks ",
+	"getblocktemplateresult-sizelimit":          "Number of bytes allowed in blocks",
+	"getblocktemplateresult-transactions":       "Array of transactions as JSON objects",
+	"getblocktemplateresult-version":            "The block version",
+	"getblocktemplateresult-coinbaseaux":        "Data that should be included in the coinbase signature script",
+	"getblocktemplateresult-coinbasetxn":        "Information about the coinbase transaction",
+	"getblocktemplateresult-coinbasevalue":      "Total amount available for the coinbase in Atoms",
+	"getblocktemplateresult-workid":             "This value must be returned with result if provided (not provided)",
+	"getblocktemplateresult-longpollid":         "Identifier for long poll request which allows monitoring for expiration",
+	"getblocktemplateresult-longpolluri":        "An alternate URI to use for long poll requests if provided (not provided)",
+	"getblocktemplateresult-submitold":          "Not applicable",
+	"getblocktemplateresult-target":             "Hex-encoded big-endian number which valid results must be less than",
+	"getblocktemplateresult-expires":            "Maximum number of seconds (starting from when the server sent the response) this work is valid for",
+	"getblocktemplateresult-maxtime":            "Maximum allowed time",
+	"getblocktemplateresult-mintime":            "Minimum allowed time",
+	"getblocktemplateresult-mutable":            "List of mutations the server explicitly allows",
+	"getblocktemplateresult-noncerange":         "Two concatenated hex-encoded big-endian 32-bit integers which represent the valid ranges of nonces the miner may scan",
+	"getblocktemplateresult-capabilities":       "List of server capabilities including 'proposal' to indicate support for block proposals",
+	"getblocktemplateresult-reject-reason":      "Reason the proposal was invalid as-is (only applies to proposal responses)",
+	"getblocktemplateresult-validatekeys":       "The validate keys of the chain, one of which must sign the block header if it is not rate limited",
+	"getblocktemplateresult-ratelimitwindow":    "Number of blocks, including the new one, over which the blocks signed by each validate key are counted",
+	"getblocktemplateresult-ratelimitmaxblocks": "Maximum number of blocks each validate key may sign within the rate limit window (not provided when unlimited)",
+	"getblocktemplateresult-adminthreads":       "The tips of the admin threads which admin transactions added to the block must spend",
+
+	// GetBlockTemplateResultValidateKey help.
+	"getblocktemplateresultvalidatekey-pubkey":       "Hex-encoded compressed public key of the validate key",
+	"getblocktemplateresultvalidatekey-ratelimited":  "Whether the key is rate limited from signing the block",
+	"getblocktemplateresultvalidatekey-recentblocks": "Number of blocks signed by the key within the rate limit window",
+
+	// GetBlockTemplateResultAdminThread help.
+	"getblocktemplateresultadminthread-id":       "The admin thread ID",
+	"getblocktemplateresultadminthread-name":     "The name of the admin thread",
+	"getblocktemplateresultadminthread-outpoint": "The outpoint of the tip of the thread as of the previous block",
+	"getblocktemplateresultadminthread-spentby":  "The transaction which spends the tip (by 1-based index in the 'transactions' list), if any",
+
+	// GetBlockScrubInfoCmd help.
+	"getblockscrubinfo--synopsis": "Returns the result of the last scrub of the stored blocks, which verifies their data against the checksums it was stored with and the block index.\n" +
+		"The blocks whose data is corrupt are downloaded from peers again to repair it.",
+
+	// GetBlockScrubInfoResult help.
+	"getblockscrubinforesult-interval":      "The number of seconds between the scrubs",
+	"getblockscrubinforesult-scrubbing":     "Whether or not a scrub is running",
+	"getblockscrubinforesult-lastscrub":     "The time the last scrub finished in seconds since 1 Jan 1970 GMT, or 0 when none has finished yet",
+	"getblockscrubinforesult-corruptblocks": "The blocks whose stored data was found to be corrupt by the last scrub",
+
+	// CorruptBlockResult help.
+	"corruptblockresult-hash":     "The hash of the block",
+	"corruptblockresult-file":     "The number of the block file which houses the data",
+	"corruptblockresult-offset":   "The offset of the data in the block file",
+	"corruptblockresult-length":   "The length of the data in the block file",
+	"corruptblockresult-error":    "Why the data failed to be verified",
+	"corruptblockresult-repaired": "Whether or not the data was replaced with the block downloaded from a peer",
+
+	// GetBlockStatsCmd help.
+	"getblockstats--synopsis": "Returns aggregate statistics about the transactions of a block or a range of blocks in the main chain.\n" +
+		"The fee rate fields only cover the standard transactions since the coinbase and admin transactions do not pay meaningful fees, and are only returned when the fee stats index is enabled (--feestatsindex).",
+	"getblockstats-hashorheight": "The hash or the height of the block, or of the first block of the range",
+	"getblockstats-endheight":    "The height of the last block of the range",
+	"getblockstats--condition0":  "endheight not specified",
+	"getblockstats--condition1":  "endheight specified",
+
+	// GetBlockStatsResult help.
+	"getblockstatsresult-avgfee":              "The average fee of the standard transactions in atoms",
+	"getblockstatsresult-avgfeerate":          "The average fee rate of the standard transactions in atoms per byte",
+	"getblockstatsresult-avgtxsize":           "The average size of the transactions other than the coinbase in bytes",
+	"getblockstatsresult-blockhash":           "The hash of the block",
+	"getblockstatsresult-feerate_percentiles": "The fee rates at the 10th, 25th, 50th, 75th and 90th percentile weighted by size in atoms per byte",
+	"getblockstatsresult-height":              "The height of the block",
+	"getblockstatsresult-ins":                 "The number of inputs of the transactions other than the coinbase",
+	"getblockstatsresult-maxfeerate":          "The highest fee rate paid by a standard transaction in atoms per byte",
+	"getblockstatsresult-minfeerate":          "The lowest fee rate paid by a standard transaction in atoms per byte",
+	"getblockstatsresult-outs":                "The number of outputs of the transactions, including the coinbase",
+	"getblockstatsresult-time":                "The block time in seconds since 1 Jan 1970 GMT",
+	"getblockstatsresult-totalfee":            "The sum of the fees paid by the transactions in atoms",
+	"getblockstatsresult-total_out":           "The total value of the outputs of the transactions other than the coinbase in atoms",
+	"getblockstatsresult-total_size":          "The sum of the sizes of the standard transactions in bytes",
+	"getblockstatsresult-txs":                 "The number of transactions in the block, including the coinbase",
+	"getblockstatsresult-admintxs":            "The number of admin transactions in the block",
 
 	// GetBlockTemplateCmd help.
 	"getblocktemplate--synopsis": "Returns a JSON object with information necessary to construct a block to mine or accepts a proposal to validate.\n" +
@@ -329,6 +695,45 @@ var helpDescsEnUS = map[string]string{
 	"getblocktemplate--condition2": "mode=proposal, accepted",
 	"getblocktemplate--result1":    "An error string which represents why the proposal was rejected or nothing if accepted",
 
+	// GetCFilterCmd help.
+	"getcfilter--synopsis": "Returns the compact block filter of a block in the main chain.\n" +
+		"Usage of this RPC requires the optional --cfindex flag to be activated, otherwise all responses will simply return with an error stating the compact block filter index has not yet been built.",
+	"getcfilter-hash":       "The hash of the block",
+	"getcfilter-filtertype": "The type of the filter, which must be 0 for the basic filter",
+	"getcfilter--result0":   "Hex-encoded bytes of the serialized filter",
+
+	// GetCFHeadersCmd help.
+	"getcfheaders--synopsis": "Returns the filter hashes and filter headers of a range of blocks in the main chain along with the filter header of the block preceding them, which allows them to be verified against the filter header checkpoints.\n" +
+		"Usage of this RPC requires the optional --cfindex flag to be activated, otherwise all responses will simply return with an error stating the compact block filter index has not yet been built.",
+	"getcfheaders-startheight": "The height of the first block of the range",
+	"getcfheaders-stophash":    "The hash of the last block of the range, which may not be more than 2000 blocks after the first",
+	"getcfheaders-filtertype":  "The type of the filters, which must be 0 for the basic filter",
+
+	// GetCFHeadersResult help.
+	"getcfheadersresult-stophash":         "The hash of the last block of the range",
+	"getcfheadersresult-prevfilterheader": "The filter header of the block preceding the range, which is the zero hash for the genesis block",
+	"getcfheadersresult-filterhashes":     "The hashes of the filters of the blocks in the range",
+	"getcfheadersresult-filterheaders":    "The filter headers of the blocks in the range",
+
+	// GetChainTipsCmd help.
+	"getchaintips--synopsis": "Returns information about the tips of all known branches of the block chain, including the end of the main chain, ordered by height from the highest to the lowest.",
+
+	// GetChainTipsResult help.
+	"getchaintipsresult-height":    "The height of the tip",
+	"getchaintipsresult-hash":      "The hash of the tip",
+	"getchaintipsresult-branchlen": "The number of blocks between the tip and the main chain, which is zero for the end of the main chain",
+	"getchaintipsresult-status":    "The status of the branch (active, valid-fork, invalid, or headers-only)",
+
+	// GetCheckpointCandidatesCmd help.
+	"getcheckpointcandidates--synopsis": "Scans the main chain backwards from the most recent block with enough confirmations and returns the blocks which are good checkpoint candidates, ordered from the highest to the lowest.\n" +
+		"The scan stops at the latest checkpoint.  Candidates must be in the main chain, have enough confirmations, have timestamps in order with the blocks on either side of them, and only contain standard scripts.",
+	"getcheckpointcandidates-count": "The maximum number of candidates to return {1-20}",
+
+	// CheckpointCandidateResult help.
+	"checkpointcandidateresult-height": "The height of the block",
+	"checkpointcandidateresult-hash":   "The hash of the block",
+	"checkpointcandidateresult-time":   "The timestamp of the block in seconds since 1 Jan 1970 GMT",
+
 	// GetConnectionCountCmd help.
 	"getconnectioncount--synopsis": "Returns the number of active connections to other peers.",
 	"getconnectioncount--result0":  "The number of connections",
@@ -385,9 +790,101 @@ var helpDescsEnUS = map[string]string{
 	"getheaders-hashstop":      "Block hash to stop including block headers for; if not found, all headers to the latest known block are returned.",
 	"getheaders--result0":      "Serialized block headers of all located blocks, limited to some arbitrary maximum number of hashes (currently 2000, which matches the wire protocol headers message, but this is not guaranteed)",
 
+	// GetIndexInfoCmd help.
+	"getindexinfo--synopsis": "Returns the status of each of the enabled optional indexes.\n" +
+		"Indexes which are behind the main chain are caught up in the background, during which they may return incomplete results.",
+
+	// GetIndexInfoResult help.
+	"getindexinforesult-name":            "The name of the index",
+	"getindexinforesult-key":             "The database key of the index",
+	"getindexinforesult-enabled":         "Whether or not the index is being maintained, which is false for indexes dropped with dropindex",
+	"getindexinforesult-height":          "The height of the last block included in the index",
+	"getindexinforesult-synced":          "Whether or not the index has caught up to the main chain",
+	"getindexinforesult-percentcomplete": "The percentage of the blocks in the main chain which have been indexed",
+
 	// GetInfoCmd help.
 	"getinfo--synopsis": "Returns a JSON object containing various state info.",
 
+	// GetIssuanceInfoCmd help.
+	"getissuanceinfo--synopsis": "Returns the current token supply of the main chain along with the cumulative amounts issued and destroyed by the issue thread, in atoms.\n" +
+		"The history of databases which predate it is recreated from the stored blocks, while chains bootstrapped from a utxo set snapshot, or pruned before the history was created, count the supply at the start of the history as issued.",
+
+	// GetIssuanceInfoResult help.
+	"getissuanceinforesult-hash":               "Block hash at which the returned totals are valid",
+	"getissuanceinforesult-height":             "Height of the block at which the returned totals are valid",
+	"getissuanceinforesult-supply":             "Net chain issuance value, the same as the totalsupply of getadmininfo",
+	"getissuanceinforesult-totalissued":        "Cumulative amount of atoms issued",
+	"getissuanceinforesult-totaldestroyed":     "Cumulative amount of atoms destroyed",
+	"getissuanceinforesult-lastissuanceheight": "Height of the last block which issued new tokens, or 0 when none is known",
+	"getissuanceinforesult-historystart":       "Height the issuance history starts at",
+
+	// GetKeyIDActivityCmd help.
+	"getkeyidactivity--synopsis": "Returns every output created or spent under the passed ASP key ID in the given range of block heights.\n" +
+		"Usage of this RPC requires the optional --keyidindex flag to be activated, otherwise all responses will simply return with an error stating the key ID activity index has not yet been built.",
+	"getkeyidactivity-keyid":       "The key ID to return activity for",
+	"getkeyidactivity-startheight": "The height of the first block to include",
+	"getkeyidactivity-endheight":   "The height of the last block to include (default: the current best height)",
+
+	// KeyIDActivityResult help.
+	"keyidactivityresult-type":     "The type of activity (created or spent)",
+	"keyidactivityresult-height":   "Height of the block containing the transaction",
+	"keyidactivityresult-txid":     "The hash of the transaction which created or spent the output",
+	"keyidactivityresult-index":    "The output index for created outputs or the input index for spent outputs",
+	"keyidactivityresult-prevtxid": "The hash of the transaction containing the spent output",
+	"keyidactivityresult-prevvout": "The index of the spent output",
+	"keyidactivityresult-value":    "The value of the output in RMG",
+	"keyidactivityresult-address":  "The address the output pays to",
+
+	// GetKeyIDInfoCmd help.
+	"getkeyidinfo--synopsis": "Returns the ASP key the passed key ID is bound to, the height of the block which provisioned it, and whether or not it has been revoked.\n" +
+		"Key IDs which were bound before the start of the key ID registry, which starts at the end of the main chain for databases which predate it, report the height it starts at.",
+	"getkeyidinfo-keyid": "The key ID to return the binding of",
+
+	// GetKeyIDInfoResult help.
+	"getkeyidinforesult-keyid":           "The key ID",
+	"getkeyidinforesult-pubkey":          "The compressed ASP pubKey the key ID is bound to, omitted when it was revoked before the start of the key ID registry",
+	"getkeyidinforesult-provisionheight": "Height of the block which provisioned the key ID",
+	"getkeyidinforesult-revoked":         "Whether or not the key ID has been revoked",
+	"getkeyidinforesult-revokeheight":    "Height of the block which revoked the key ID, omitted when it is not known",
+
+	// GetMempoolAncestorsCmd help.
+	"getmempoolancestors--synopsis":   "Returns the transactions in the memory pool whose outputs the passed transaction spends, directly or indirectly.",
+	"getmempoolancestors-txid":        "The hash of the transaction, which must be in the memory pool",
+	"getmempoolancestors-verbose":     "Returns JSON objects keyed by transaction hash when true or an array of transaction hashes when false",
+	"getmempoolancestors--condition0": "verbose=false",
+	"getmempoolancestors--condition1": "verbose=true",
+	"getmempoolancestors--result0":    "Array of transaction hashes",
+
+	// GetMempoolDescendantsCmd help.
+	"getmempooldescendants--synopsis":   "Returns the transactions in the memory pool which spend outputs of the passed transaction, directly or indirectly.",
+	"getmempooldescendants-txid":        "The hash of the transaction, which must be in the memory pool",
+	"getmempooldescendants-verbose":     "Returns JSON objects keyed by transaction hash when true or an array of transaction hashes when false",
+	"getmempooldescendants--condition0": "verbose=false",
+	"getmempooldescendants--condition1": "verbose=true",
+	"getmempooldescendants--result0":    "Array of transaction hashes",
+
+	// GetMempoolEntryCmd help.
+	"getmempoolentry--synopsis": "Returns information about a transaction in the memory pool.",
+	"getmempoolentry-txid":      "The hash of the transaction, which must be in the memory pool",
+
+	// GetMempoolEntryResult help.
+	"getmempoolentryresult-size":               "Transaction size in bytes",
+	"getmempoolentryresult-fee":                "Transaction fee in RMG",
+	"getmempoolentryresult-modifiedfee":        "Transaction fee in RMG used for mining, which is always the same as the fee",
+	"getmempoolentryresult-time":               "Local time transaction entered pool in seconds since 1 Jan 1970 GMT",
+	"getmempoolentryresult-height":             "Block height when transaction entered the pool",
+	"getmempoolentryresult-startingpriority":   "Priority when transaction entered the pool",
+	"getmempoolentryresult-currentpriority":    "Current priority",
+	"getmempoolentryresult-descendantcount":    "Number of transactions in the pool spending outputs of this one, directly or indirectly, including this one",
+	"getmempoolentryresult-descendantsize":     "Total size in bytes of the descendants including this transaction",
+	"getmempoolentryresult-descendantfees":     "Total fee in atoms of the descendants including this transaction",
+	"getmempoolentryresult-ancestorcount":      "Number of transactions in the pool this one spends outputs of, directly or indirectly, including this one",
+	"getmempoolentryresult-ancestorsize":       "Total size in bytes of the ancestors including this transaction",
+	"getmempoolentryresult-ancestorfees":       "Total fee in atoms of the ancestors including this transaction",
+	"getmempoolentryresult-depends":            "Unconfirmed transactions used as inputs for this transaction",
+	"getmempoolentryresult-spentby":            "Unconfirmed transactions spending outputs of this transaction",
+	"getmempoolentryresult-bip125-replaceable": "Whether this transaction or one of its unconfirmed ancestors signals it can be replaced by a transaction paying a higher fee",
+
 	// GetMempoolInfoCmd help.
 	"getmempoolinfo--synopsis": "Returns memory pool information",
 
@@ -417,13 +914,54 @@ var helpDescsEnUS = map[string]string{
 	"getnetworkhashps-height":    "Perform estimate ending with this height or -1 for current best chain block height",
 	"getnetworkhashps--result0":  "Estimated hashes per second",
 
+	// GetNetworkInfoCmd help.
+	"getnetworkinfo--synopsis": "Returns a JSON object containing information related to the peer-to-peer networking of the server.",
+
+	// GetNetworkInfoResult help.
+	"getnetworkinforesult-version":            "The version of the server",
+	"getnetworkinforesult-subversion":         "The user agent the server sends to its peers",
+	"getnetworkinforesult-protocolversion":    "The latest protocol version supported by the server",
+	"getnetworkinforesult-localservices":      "The hex-encoded services the server offers to its peers",
+	"getnetworkinforesult-localservicesnames": "The names of the services the server offers to its peers",
+	"getnetworkinforesult-localrelay":         "Whether or not the server relays transactions to its peers",
+	"getnetworkinforesult-timeoffset":         "The time offset of the server in seconds",
+	"getnetworkinforesult-networkactive":      "Whether or not the peer-to-peer networking is enabled",
+	"getnetworkinforesult-connections":        "The number of connected peers",
+	"getnetworkinforesult-connections_in":     "The number of inbound peers",
+	"getnetworkinforesult-connections_out":    "The number of outbound peers",
+	"getnetworkinforesult-networks":           "Information about each network the server connects to",
+	"getnetworkinforesult-relayfee":           "The minimum fee rate in RMG/kB for transactions to be relayed",
+	"getnetworkinforesult-incrementalfee":     "The minimum fee rate increase in RMG/kB for replacing or limiting transactions",
+	"getnetworkinforesult-localaddresses":     "The addresses the server advertises to its peers",
+	"getnetworkinforesult-warnings":           "Any network and blockchain warnings",
+
+	// NetworksResult help.
+	"networksresult-name":                        "The name of the network (ipv4, ipv6 or onion)",
+	"networksresult-limited":                     "Whether or not the server is limited to other networks than this one",
+	"networksresult-reachable":                   "Whether or not the network is reachable",
+	"networksresult-proxy":                       "The proxy used to connect to the network, if any",
+	"networksresult-proxy_randomize_credentials": "Whether or not random credentials are used for the proxy connections",
+
+	// LocalAddressesResult help.
+	"localaddressesresult-address": "The advertised address",
+	"localaddressesresult-port":    "The advertised port",
+	"localaddressesresult-score":   "The priority of the address",
+
 	// GetNetTotalsCmd help.
 	"getnettotals--synopsis": "Returns a JSON object containing network traffic statistics.",
 
 	// GetNetTotalsResult help.
-	"getnettotalsresult-totalbytesrecv": "Total bytes received",
-	"getnettotalsresult-totalbytessent": "Total bytes sent",
-	"getnettotalsresult-timemillis":     "Number of milliseconds since 1 Jan 1970 GMT",
+	"getnettotalsresult-totalbytesrecv":           "Total bytes received",
+	"getnettotalsresult-totalbytessent":           "Total bytes sent",
+	"getnettotalsresult-timemillis":               "Number of milliseconds since 1 Jan 1970 GMT",
+	"getnettotalsresult-bytesrecv_per_msg":        "JSON object with the message commands as keys and the bytes received as values",
+	"getnettotalsresult-bytesrecv_per_msg--key":   "command",
+	"getnettotalsresult-bytesrecv_per_msg--value": "n",
+	"getnettotalsresult-bytesrecv_per_msg--desc":  "Total bytes received in messages of the command, where *other* counts the messages which failed to decode",
+	"getnettotalsresult-bytessent_per_msg":        "JSON object with the message commands as keys and the bytes sent as values",
+	"getnettotalsresult-bytessent_per_msg--key":   "command",
+	"getnettotalsresult-bytessent_per_msg--value": "n",
+	"getnettotalsresult-bytessent_per_msg--desc":  "Total bytes sent in messages of the command",
 
 	// GetPeerInfoResult help.
 	"getpeerinforesult-id":             "A unique node ID",
@@ -439,14 +977,19 @@ var helpDescsEnUS = map[string]string{
 	"getpeerinforesult-timeoffset":     "The time offset of the peer",
 	"getpeerinforesult-pingtime":       "Number of microseconds the last ping took",
 	"getpeerinforesult-pingwait":       "Number of microseconds a queued ping has been waiting for a response",
+	"getpeerinforesult-minping":        "Lowest number of microseconds a ping took, which protects the fastest inbound peers from eviction",
 	"getpeerinforesult-version":        "The protocol version of the peer",
 	"getpeerinforesult-subver":         "The user agent of the peer",
 	"getpeerinforesult-inbound":        "Whether or not the peer is an inbound connection",
+	"getpeerinforesult-encrypted":      "Whether or not the connection to the peer is encrypted with TLS",
+	"getpeerinforesult-blockrelayonly": "Whether or not the peer is an outbound connection which only relays blocks",
 	"getpeerinforesult-startingheight": "The latest block height the peer knew about when the connection was established",
 	"getpeerinforesult-currentheight":  "The current height of the peer",
 	"getpeerinforesult-banscore":       "The ban score",
 	"getpeerinforesult-feefilter":      "The requested minimum fee a transaction must have to be announced to the peer",
 	"getpeerinforesult-syncnode":       "Whether or not the peer is the sync peer",
+	"getpeerinforesult-lastblocktime":  "Time the peer last provided a new block in seconds since 1 Jan 1970 GMT, which protects the inbound peers providing blocks from eviction, or 0 if it did not provide any",
+	"getpeerinforesult-protectedby":    "Why the peer is protected from eviction when the maximum number of peers is reached (outbound, whitelisted, latency, blocks or longevity), or empty if the inbound peer may be evicted",
 
 	// GetPeerInfoCmd help.
 	"getpeerinfo--synopsis": "Returns data about each connected network peer as an array of json objects.",
@@ -475,6 +1018,56 @@ var helpDescsEnUS = map[string]string{
 	"getrawtransaction--condition1": "verbose=true",
 	"getrawtransaction--result0":    "Hex-encoded bytes of the serialized transaction",
 
+	// GetReceivedByAddressCmd help.
+	"getreceivedbyaddress--synopsis": "Returns the total amount received by the passed watched address or key ID in transactions with at least the passed number of confirmations.\n" +
+		"Only the transactions tracked since the address or key ID was imported or registered with watchaddresses are included.\n" +
+		"Usage of this RPC requires the optional --watchindex flag to be activated, otherwise all responses will simply return with an error stating the watch-only index has not yet been built.",
+	"getreceivedbyaddress-address":  "The watched address or key ID to return the received amount for",
+	"getreceivedbyaddress-minconf":  "The minimum number of confirmations of the included transactions",
+	"getreceivedbyaddress--result0": "The total amount received in RMG",
+
+	// GetRescanInfoCmd help.
+	"getrescaninfo--synopsis": "Returns the progress of the running rescans and of the interrupted rescans which are able to be resumed.",
+	"getrescaninfo--result0":  "The rescan jobs ordered by their job ID",
+
+	// RescanInfoResult help.
+	"rescaninforesult-jobid":           "The ID of the rescan job",
+	"rescaninforesult-status":          "The status of the rescan job (running or interrupted)",
+	"rescaninforesult-resumable":       "Whether or not the rescan job is checkpointed so it is able to be resumed",
+	"rescaninforesult-addresses":       "The number of addresses included in the rescan",
+	"rescaninforesult-startheight":     "The height of the first block to rescan",
+	"rescaninforesult-endheight":       "The height of the final block to rescan, or -1 when the rescan continues through the best block",
+	"rescaninforesult-height":          "The height of the last checkpointed block, or -1 when none was rescanned yet",
+	"rescaninforesult-progress":        "The fraction of the blocks to rescan which were rescanned",
+	"rescaninforesult-blockspersecond": "The number of blocks rescanned per second since the rescan was started or resumed",
+	"rescaninforesult-eta":             "The estimated number of seconds until the rescan completes, or -1 when unknown",
+
+	// GetSpentInfoCmd help.
+	"getspentinfo--synopsis": "Returns the transaction input which spent the passed output.\n" +
+		"Usage of this RPC requires the optional --spentindex flag to be activated, otherwise all responses will simply return with an error stating the spent index has not yet been built.",
+	"getspentinfo-txid":  "The hash of the transaction containing the output",
+	"getspentinfo-index": "The index of the output",
+
+	// GetSpentInfoResult help.
+	"getspentinforesult-txid":   "The hash of the spending transaction",
+	"getspentinforesult-index":  "The index of the spending input",
+	"getspentinforesult-height": "The height of the block containing the spending transaction",
+
+	// GetTxSpendingInfoCmd help.
+	"gettxspendinginfo--synopsis": "Returns the transaction inputs which spent the outputs of the passed transaction in the main chain.\n" +
+		"Usage of this RPC requires the optional --txindex and --spentindex flags to be activated, otherwise all responses will simply return with an error stating the transaction index or the spent index has not yet been built.",
+	"gettxspendinginfo-txid": "The hash of the transaction",
+
+	// GetTxSpendingInfoResult help.
+	"gettxspendinginforesult-txid":   "The hash of the transaction",
+	"gettxspendinginforesult-spends": "The spent outputs of the transaction ordered by output index -- unspent outputs are omitted",
+
+	// TxOutSpendResult help.
+	"txoutspendresult-vout":         "The index of the spent output",
+	"txoutspendresult-spendingtxid": "The hash of the spending transaction",
+	"txoutspendresult-vin":          "The index of the spending input",
+	"txoutspendresult-height":       "The height of the block containing the spending transaction",
+
 	// GetTxOutResult help.
 	"gettxoutresult-bestblock":     "The block hash that contains the transaction output",
 	"gettxoutresult-confirmations": "The number of confirmations",
@@ -489,6 +1082,79 @@ var helpDescsEnUS = map[string]string{
 	"gettxout-vout":           "The index of the output",
 	"gettxout-includemempool": "Include the mempool when true",
 
+	// GetTxOutProofCmd help.
+	"gettxoutproof--synopsis": "Returns a hex-encoded merkle block proving the inclusion of the passed transactions in a block.\n" +
+		"The block is looked up with the transaction index when no block hash is passed.",
+	"gettxoutproof-txids":     "The hashes of the transactions, which must all be in the same block",
+	"gettxoutproof-blockhash": "The hash of the block containing the transactions",
+	"gettxoutproof--result0":  "Hex-encoded bytes of the serialized merkle block",
+
+	// GetValidationTimingsCmd help.
+	"getvalidationtimings--synopsis": "Returns the time spent in each phase of validating and connecting the most recently connected block along with rolling statistics of each phase.\n" +
+		"The phases are deserialize, headerchecks, scriptvalidation, utxoupdate, dbcommit, and indexupdate.",
+
+	// GetValidationTimingsResult help.
+	"getvalidationtimingsresult-blocks":    "The number of blocks connected since the node started",
+	"getvalidationtimingsresult-window":    "The maximum number of most recent samples the statistics of each phase are calculated over",
+	"getvalidationtimingsresult-lastblock": "The time spent in each phase by the most recently connected block (omitted before the first block is connected)",
+	"getvalidationtimingsresult-phases":    "The rolling statistics of each phase",
+
+	// GetValidatorStatsCmd help.
+	"getvalidatorstats--synopsis": "Returns the number of blocks each key of the validate key set signed in the averaging window ending at the best block, how many more it is allowed to sign before it is rate limited, and the last block it signed.\n" +
+		"The last blocks of databases which predate their tracking are known from the blocks of the averaging window at the time they were upgraded on, and chains bootstrapped from a utxo set snapshot only know the blocks signed after it.",
+
+	// GetValidatorStatsResult help.
+	"getvalidatorstatsresult-hash":       "Block hash at which the returned stats are valid",
+	"getvalidatorstatsresult-height":     "Height of the block at which the returned stats are valid",
+	"getvalidatorstatsresult-window":     "The number of most recent blocks the share of each validate key is counted over",
+	"getvalidatorstatsresult-maxblocks":  "The maximum number of blocks of the window a validate key is allowed to sign, or 0 when validate keys are not rate limited",
+	"getvalidatorstatsresult-validators": "The stats of each key of the validate key set",
+
+	// ValidatorStatsResult help.
+	"validatorstatsresult-pubkey":          "The compressed validate public key",
+	"validatorstatsresult-blocks":          "The number of blocks of the window signed by the key",
+	"validatorstatsresult-share":           "The fraction of the blocks of the window signed by the key",
+	"validatorstatsresult-remaining":       "The number of blocks the key is allowed to sign before it is rate limited",
+	"validatorstatsresult-ratelimited":     "Whether or not the key is rate limited from signing the next block",
+	"validatorstatsresult-lastblockheight": "The height of the last main chain block signed by the key (omitted when unknown)",
+	"validatorstatsresult-lastblockhash":   "The hash of the last main chain block signed by the key (omitted when unknown)",
+
+	// LastBlockTimingsResult help.
+	"lastblocktimingsresult-hash":          "The hash of the block",
+	"lastblocktimingsresult-height":        "The height of the block",
+	"lastblocktimingsresult-phases":        "JSON object with the phase names as keys and the times as values",
+	"lastblocktimingsresult-phases--key":   "phase",
+	"lastblocktimingsresult-phases--value": "n.nnn",
+	"lastblocktimingsresult-phases--desc":  "The time in milliseconds spent in the phase",
+
+	// ValidationPhaseResult help.
+	"validationphaseresult-phase":   "The name of the phase",
+	"validationphaseresult-samples": "The number of samples the statistics are calculated over",
+	"validationphaseresult-last":    "The time in milliseconds spent in the phase by the most recent block",
+	"validationphaseresult-mean":    "The mean time in milliseconds spent in the phase",
+	"validationphaseresult-min":     "The minimum time in milliseconds spent in the phase",
+	"validationphaseresult-max":     "The maximum time in milliseconds spent in the phase",
+
+	// GetWatchedBalanceCmd help.
+	"getwatchedbalance--synopsis": "Returns the current balance of the passed watched address or key ID.\n" +
+		"Only the outputs created after the address or key ID was registered with watchaddresses are included unless they were copied from the address balance index at registration.\n" +
+		"Usage of this RPC requires the optional --watchindex flag to be activated, otherwise all responses will simply return with an error stating the watch-only index has not yet been built.",
+	"getwatchedbalance-address": "The watched address or key ID to return the balance for",
+
+	// GetWatchedHistoryCmd help.
+	"getwatchedhistory--synopsis": "Returns the transactions involving the passed watched address or key ID since it was registered along with the value they moved.\n" +
+		"Each transaction is classified as funding when it only pays to the address, spending when it only spends from it, or both.\n" +
+		"Usage of this RPC requires the optional --watchindex flag to be activated, otherwise all responses will simply return with an error stating the watch-only index has not yet been built.",
+	"getwatchedhistory-address": "The watched address or key ID to return the history for",
+	"getwatchedhistory-skip":    "The number of leading transactions to leave out of the final response",
+	"getwatchedhistory-count":   "The maximum number of transactions to return",
+	"getwatchedhistory-reverse": "Specifies that the transactions should be returned in reverse chronological order",
+
+	// GetWatchedUtxosCmd help.
+	"getwatchedutxos--synopsis": "Returns the tracked unspent outputs paying to the passed watched address or key ID.\n" +
+		"Usage of this RPC requires the optional --watchindex flag to be activated, otherwise all responses will simply return with an error stating the watch-only index has not yet been built.",
+	"getwatchedutxos-address": "The watched address or key ID to return the unspent outputs for",
+
 	// HelpCmd help.
 	"help--synopsis":   "Returns a list of all commands or help for a specified command.",
 	"help-command":     "The command to retrieve help for",
@@ -497,10 +1163,102 @@ var helpDescsEnUS = map[string]string{
 	"help--result0":    "List of commands",
 	"help--result1":    "Help for specified command",
 
+	// ImportAddressCmd help.
+	"importaddress--synopsis": "Watches the passed address or key ID with the watch-only index.\n" +
+		"When rescan is true, the blocks from the passed start height are scanned for its outputs and the call returns once the rescan has caught up with the chain; interrupted rescans resume when the server is restarted.\n" +
+		"Usage of this RPC requires the optional --watchindex flag to be activated, otherwise all responses will simply return with an error stating the watch-only index has not yet been built.",
+	"importaddress-address":     "The address or key ID to watch",
+	"importaddress-rescan":      "Whether or not to scan the blocks connected before the address was imported",
+	"importaddress-startheight": "The height of the first block to rescan",
+
+	// ImportPubKeyCmd help.
+	"importpubkey--synopsis": "Watches the addresses paying to the hash of the passed public key with the watch-only index.\n" +
+		"When rescan is true, the blocks from the passed start height are scanned for their outputs and the call returns once the rescan has caught up with the chain; interrupted rescans resume when the server is restarted.\n" +
+		"Usage of this RPC requires the optional --watchindex flag to be activated, otherwise all responses will simply return with an error stating the watch-only index has not yet been built.",
+	"importpubkey-pubkey":      "The hex-encoded public key to watch",
+	"importpubkey-rescan":      "Whether or not to scan the blocks connected before the public key was imported",
+	"importpubkey-startheight": "The height of the first block to rescan",
+
+	// ListAdminOperationsCmd help.
+	"listadminoperations--synopsis": "Returns the history of operations carried out by admin transactions, such as keys being provisioned or revoked and tokens being issued or destroyed.\n" +
+		"Usage of this RPC requires the optional --adminopindex flag to be activated, otherwise all responses will simply return with an error stating the admin operation index has not yet been built.",
+	"listadminoperations-filter": "AdminOperationFilter object restricting the returned operations",
+
+	// AdminOperationFilter help.
+	"adminoperationfilter-types":       "Only include operations of these types (addkey, revokekey, issue, destroy)",
+	"adminoperationfilter-keysets":     "Only include key operations on these key sets (ROOT, PROVISION, ISSUE, VALIDATE, ASP)",
+	"adminoperationfilter-pubkey":      "Only include key operations on this hex-encoded compressed public key",
+	"adminoperationfilter-keyid":       "Only include key operations on this ASP key ID",
+	"adminoperationfilter-startheight": "The height of the first block to include",
+	"adminoperationfilter-endheight":   "The height of the last block to include (default: the current best height)",
+
+	// AdminOperationResult help.
+	"adminoperationresult-type":   "The type of operation (addkey, revokekey, issue, destroy)",
+	"adminoperationresult-height": "Height of the block containing the admin transaction",
+	"adminoperationresult-txid":   "The hash of the admin transaction",
+	"adminoperationresult-vout":   "The index of the output carrying out the operation",
+	"adminoperationresult-thread": "The admin thread of the transaction (root, provision, issue)",
+	"adminoperationresult-keyset": "The key set of a key operation",
+	"adminoperationresult-pubkey": "The hex-encoded public key of a key operation",
+	"adminoperationresult-keyid":  "The key ID of an ASP key operation",
+	"adminoperationresult-value":  "The amount issued or destroyed in RMG",
+
+	// ListUnspentCmd help.
+	"listunspent--synopsis": "Returns the unspent outputs of the main chain paying to the watched addresses and key IDs.\n" +
+		"Usage of this RPC requires the optional --watchindex flag to be activated, otherwise all responses will simply return with an error stating the watch-only index has not yet been built.",
+	"listunspent-minconf":   "The minimum number of confirmations of the returned outputs",
+	"listunspent-maxconf":   "The maximum number of confirmations of the returned outputs",
+	"listunspent-addresses": "The watched addresses and key IDs to return the outputs for (default: all watched addresses and key IDs)",
+
+	// ListUnspentResult help.
+	"listunspentresult-txid":          "The hash of the transaction containing the output",
+	"listunspentresult-vout":          "The index of the output",
+	"listunspentresult-address":       "The address the output pays to",
+	"listunspentresult-account":       "Unused, always empty",
+	"listunspentresult-scriptPubKey":  "The hex-encoded public key script of the output",
+	"listunspentresult-redeemScript":  "Unused, always empty",
+	"listunspentresult-amount":        "The value of the output in RMG",
+	"listunspentresult-confirmations": "The number of confirmations of the block containing the output",
+	"listunspentresult-spendable":     "Always false since the node does not hold keys",
+
+	// ListWatchedCmd help.
+	"listwatched--synopsis": "Returns the addresses and key IDs registered with watchaddresses along with their balances.\n" +
+		"Usage of this RPC requires the optional --watchindex flag to be activated, otherwise all responses will simply return with an error stating the watch-only index has not yet been built.",
+
+	// ListWatchedResult help.
+	"listwatchedresult-address":      "The watched address or key ID",
+	"listwatchedresult-height":       "The height of the block after which the address or key ID is tracked",
+	"listwatchedresult-seeded":       "Whether or not the unspent outputs existing at registration were copied from the address balance index",
+	"listwatchedresult-rescanning":   "Whether or not the rescan of the blocks connected before the address or key ID was imported is in progress",
+	"listwatchedresult-rescanheight": "The height of the last rescanned block while rescanning",
+	"listwatchedresult-balance":      "The total value of the tracked unspent outputs in RMG",
+	"listwatchedresult-utxocount":    "The number of tracked unspent outputs",
+
 	// PingCmd help.
 	"ping--synopsis": "Queues a ping to be sent to each connected peer.\n" +
 		"Ping times are provided by getpeerinfo via the pingtime and pingwait fields.",
 
+	// ScanTxOutSetCmd help.
+	"scantxoutset--synopsis": "Returns the unspent outputs paying to the scripts described by the passed scan objects.\n" +
+		"Scan objects are output descriptors of the form addr(<address>) or raw(<hex script>).",
+	"scantxoutset-action":      "The action to perform, which must be start since scans complete immediately",
+	"scantxoutset-scanobjects": "The descriptors of the scripts to scan for",
+
+	// ScanTxOutSetResult help.
+	"scantxoutsetresult-success":      "Whether or not the scan succeeded",
+	"scantxoutsetresult-height":       "The height of the best block at the time of the scan",
+	"scantxoutsetresult-bestblock":    "The hash of the best block at the time of the scan",
+	"scantxoutsetresult-unspents":     "The unspent outputs paying to the scanned scripts",
+	"scantxoutsetresult-total_amount": "The total value of the unspent outputs in RMG",
+
+	// ScanTxOutSetUnspent help.
+	"scantxoutsetunspent-txid":         "The hash of the transaction containing the output",
+	"scantxoutsetunspent-vout":         "The index of the output",
+	"scantxoutsetunspent-scriptPubKey": "The hex-encoded public key script of the output",
+	"scantxoutsetunspent-desc":         "The scan object the output matched",
+	"scantxoutsetunspent-amount":       "The value of the output in RMG",
+	"scantxoutsetunspent-height":       "Height of the block containing the output",
+
 	// SearchRawTransactionsCmd help.
 	"searchrawtransactions--synopsis": "Returns raw data for transactions involving the passed address.\n" +
 		"Returned transactions are pulled from both the database, and transactions currently in the mempool.\n" +
@@ -518,17 +1276,115 @@ var helpDescsEnUS = map[string]string{
 	"searchrawtransactions-filteraddrs": "Address list.  Only inputs or outputs with matching address will be returned",
 	"searchrawtransactions--result0":    "Hex-encoded serialized transaction",
 
+	// SearchRawTransactionsByAddressCmd help.
+	"searchrawtransactionsbyaddress--synopsis": "Returns the transactions involving the passed address or key ID along with the value they moved.\n" +
+		"Each transaction is classified as funding when it only pays to the address, spending when it only spends from it, or both.\n" +
+		"Only transactions confirmed in blocks are returned.\n" +
+		"Usage of this RPC requires the optional --addrvalueindex flag to be activated, otherwise all responses will simply return with an error stating the address value index has not yet been built.",
+	"searchrawtransactionsbyaddress-address":      "The address or numeric key ID to search for",
+	"searchrawtransactionsbyaddress-skip":         "The number of leading transactions to leave out of the final response",
+	"searchrawtransactionsbyaddress-count":        "The maximum number of transactions to return",
+	"searchrawtransactionsbyaddress-reverse":      "Specifies that the transactions should be returned in reverse chronological order",
+	"searchrawtransactionsbyaddress-includetotal": "Include the total number of transactions involving the address or key ID, which requires visiting all of them",
+
+	// SearchRawTransactionsByAddressResult help.
+	"searchrawtransactionsbyaddressresult-total":        "The total number of transactions involving the address or key ID (only when requested)",
+	"searchrawtransactionsbyaddressresult-transactions": "The requested transactions",
+
+	// AddressTxResult help.
+	"addresstxresult-txid":          "The hash of the transaction",
+	"addresstxresult-blockhash":     "Hash of the block the transaction is part of",
+	"addresstxresult-blockheight":   "Height of the block the transaction is part of",
+	"addresstxresult-confirmations": "Number of confirmations of the block",
+	"addresstxresult-received":      "Total value of the transaction outputs paying to the address or key ID",
+	"addresstxresult-sent":          "Total value spent from the address or key ID by the transaction inputs",
+	"addresstxresult-category":      "The classification of the transaction (funding, spending, or both)",
+
+	// SearchRawTransactionsByKeyIDCmd help.
+	"searchrawtransactionsbykeyid--synopsis": "Returns raw data for the transactions which create or spend outputs bound to the passed key ID, in the order they appear in the main chain.\n" +
+		"Only transactions confirmed in blocks are returned.\n" +
+		"Usage of this RPC requires the optional --keyidindex flag to be activated, otherwise all responses will simply return with an error stating the key ID activity index has not yet been built.",
+	"searchrawtransactionsbykeyid-keyid":       "The key ID to search for",
+	"searchrawtransactionsbykeyid-verbose":     "Specifies the transaction is returned as a JSON object instead of hex-encoded string",
+	"searchrawtransactionsbykeyid--condition0": "verbose=0",
+	"searchrawtransactionsbykeyid--condition1": "verbose=1",
+	"searchrawtransactionsbykeyid-skip":        "The number of leading transactions to leave out of the final response",
+	"searchrawtransactionsbykeyid-count":       "The maximum number of transactions to return",
+	"searchrawtransactionsbykeyid-reverse":     "Specifies that the transactions should be returned in reverse chronological order",
+	"searchrawtransactionsbykeyid--result0":    "Hex-encoded serialized transaction",
+
 	// SendRawTransactionCmd help.
 	"sendrawtransaction--synopsis":     "Submits the serialized, hex-encoded transaction to the local peer and relays it to the network.",
 	"sendrawtransaction-hextx":         "Serialized, hex-encoded signed transaction",
 	"sendrawtransaction-allowhighfees": "Whether or not to allow insanely high fees (btcd does not yet implement this parameter, so it has no effect)",
 	"sendrawtransaction--result0":      "The hash of the transaction",
 
+	// SetBanCmd help.
+	"setban--synopsis": "Bans a subnet or an IP address from connecting to the server, or removes such a ban.\n" +
+		"Connected peers in a banned subnet are disconnected, and the bans are kept across restarts until they expire.",
+	"setban-subnet":   "The subnet in CIDR notation, or a single IP address",
+	"setban-subcmd":   "'add' to ban the subnet or 'remove' to remove its ban",
+	"setban-bantime":  "The duration of the ban in seconds, or the unix time it expires when absolute is true; 0 bans for the configured ban duration",
+	"setban-absolute": "Whether or not the ban time is a unix time",
+
+	// ListBannedCmd help.
+	"listbanned--synopsis": "Returns the bans which are in effect.",
+
+	// ListBannedResult help.
+	"listbannedresult-address":        "The banned subnet",
+	"listbannedresult-ban_created":    "The unix time the ban was created",
+	"listbannedresult-banned_until":   "The unix time the ban expires",
+	"listbannedresult-ban_duration":   "The duration of the ban in seconds",
+	"listbannedresult-time_remaining": "The number of seconds until the ban expires",
+
+	// ClearBannedCmd help.
+	"clearbanned--synopsis": "Removes all bans.",
+
 	// SetGenerateCmd help.
 	"setgenerate--synopsis":    "Set the server to generate coins (mine) or not.",
 	"setgenerate-generate":     "Use true to enable generation, false to disable it",
 	"setgenerate-genproclimit": "The number of processors (cores) to limit generation to or -1 for default",
 
+	// SetPolicyCmd help.
+	"setpolicy--synopsis": "Changes the standardness policy the memory pool applies to the transactions it relays from now on and returns the policy in effect.\n" +
+		"Parameters which are not provided leave the respective part of the policy unchanged, so calling it without parameters only returns the policy.\n" +
+		"The policy is not applied to admin transactions, which are subject to the rules of their thread, and returns to the configured policy on restart.",
+	"setpolicy-maxsigops":       "The maximum number of signature operations of a standard transaction",
+	"setpolicy-dustthreshold":   "The value in RMG below which outputs are dust in addition to the outputs which are dust at the minimum relay fee, or 0 to disable the threshold",
+	"setpolicy-scripttypes":     "The types of output scripts which are standard (safe_multisig, admin, nulldata, or safe_multisig_timelock), or an empty array for all of them",
+	"setpolicy-datacarriersize": "The maximum number of bytes carried by a standard nulldata output, or 0 for the default of 80",
+
+	// SetPolicyResult help.
+	"setpolicyresult-maxsigops":       "The maximum number of signature operations of a standard transaction",
+	"setpolicyresult-dustthreshold":   "The value in RMG below which outputs are dust in addition to the outputs which are dust at the minimum relay fee",
+	"setpolicyresult-scripttypes":     "The types of output scripts which are standard",
+	"setpolicyresult-datacarriersize": "The maximum number of bytes carried by a standard nulldata output",
+
+	// SignRawTransactionWithKeyCmd help.
+	"signrawtransactionwithkey--synopsis": "Signs the inputs of a transaction which spend Prova outputs with those of the provided private keys the outputs are bound to, appending the signatures to the signatures already present.\n" +
+		"Key IDs of the outputs are resolved to the ASP keys of the best chain, so ASPs are able to sign for the key IDs they are provisioned under, and the inputs which remain incomplete are reported along with the reason.",
+	"signrawtransactionwithkey-hextx":    "Serialized, hex-encoded transaction",
+	"signrawtransactionwithkey-privkeys": "The WIF-encoded private keys to sign with",
+	"signrawtransactionwithkey-prevtxs":  "The outputs spent by the inputs (default: looked up in the memory pool and the utxo set)",
+
+	// PrevTxOutput help.
+	"prevtxoutput-txid":         "The hash of the transaction of the output",
+	"prevtxoutput-vout":         "The index of the output",
+	"prevtxoutput-scriptPubKey": "The hex-encoded public key script of the output",
+	"prevtxoutput-amount":       "The amount of the output in RMG",
+
+	// SignRawTransactionResult help.
+	"signrawtransactionresult-hex":      "The serialized, hex-encoded transaction with the signatures added",
+	"signrawtransactionresult-complete": "Whether every input carries the required number of signatures and its scripts verify",
+	"signrawtransactionresult-errors":   "The inputs which remain incomplete, omitted when complete",
+
+	// SignRawTransactionError help.
+	"signrawtransactionerror-txid":      "The hash of the transaction of the output spent by the input",
+	"signrawtransactionerror-vout":      "The index of the output spent by the input",
+	"signrawtransactionerror-scriptSig": "The hex-encoded signature script of the input",
+	"signrawtransactionerror-sequence":  "The sequence number of the input",
+	"signrawtransactionerror-error":     "The reason the input is incomplete, such as the keys whose signatures are missing",
+
 	// StopCmd help.
 	"stop--synopsis": "Shutdown Prova.",
 	"stop--result0":  "The string 'Prova stopping.'",
@@ -544,10 +1400,53 @@ var helpDescsEnUS = map[string]string{
 	"submitblock--condition1": "Block rejected",
 	"submitblock--result1":    "The reason the block was rejected",
 
+	// TestBlockValidityCmd help.
+	"testblockvalidity--synopsis": "Performs full contextual validation of a serialized, hex-encoded block on top of its parent without modifying the chain state and returns the changes connecting it would make.\n" +
+		"The parent of the block must be part of the main chain, which allows historical blocks to be replayed in isolation.",
+	"testblockvalidity-hexblock": "Serialized, hex-encoded block",
+
+	// TestBlockValidityResult help.
+	"testblockvalidityresult-hash":          "The hash of the block",
+	"testblockvalidityresult-valid":         "Whether or not the block passes validation",
+	"testblockvalidityresult-rejectreason":  "The rule the block violates (only when valid is false)",
+	"testblockvalidityresult-height":        "The height of the block",
+	"testblockvalidityresult-depth":         "The number of blocks after the parent of the block in the main chain",
+	"testblockvalidityresult-fees":          "The total fees collected by the block",
+	"testblockvalidityresult-subsidy":       "The subsidy of the block",
+	"testblockvalidityresult-supplybefore":  "The total supply before the block",
+	"testblockvalidityresult-supplyafter":   "The total supply after the block",
+	"testblockvalidityresult-lastkeyid":     "The last provisioned ASP key ID after the block",
+	"testblockvalidityresult-spent":         "The unspent outputs spent by the block",
+	"testblockvalidityresult-created":       "The outputs created by the block which remain unspent",
+	"testblockvalidityresult-keysetchanges": "The keys each admin key set gains and loses",
+	"testblockvalidityresult-keyidchanges":  "The ASP key IDs provisioned and revoked",
+
+	// SimulatedOutputResult help.
+	"simulatedoutputresult-txid":         "The hash of the transaction of the output",
+	"simulatedoutputresult-vout":         "The index of the output",
+	"simulatedoutputresult-value":        "The value of the output",
+	"simulatedoutputresult-scriptpubkey": "The hex-encoded public key script of the output",
+
+	// KeySetChangeResult help.
+	"keysetchangeresult-keyset":  "The admin key set (ROOT, PROVISION, ISSUE, or VALIDATE)",
+	"keysetchangeresult-added":   "The hex-encoded keys added to the key set",
+	"keysetchangeresult-removed": "The hex-encoded keys removed from the key set",
+
+	// KeyIDChangeResult help.
+	"keyidchangeresult-keyid":  "The ASP key ID",
+	"keyidchangeresult-pubkey": "The hex-encoded public key of the key ID",
+	"keyidchangeresult-added":  "Whether the key ID is provisioned rather than revoked",
+
 	// ValidateAddressResult help.
 	"validateaddresschainresult-isvalid": "Whether or not the address is valid",
 	"validateaddresschainresult-address": "The bitcoin address (only when isvalid is true)",
 
+	// UnwatchAddressesCmd help.
+	"unwatchaddresses--synopsis": "Stops watching the passed addresses and key IDs and removes their tracked unspent outputs, balances, and history.\n" +
+		"Usage of this RPC requires the optional --watchindex flag to be activated, otherwise all responses will simply return with an error stating the watch-only index has not yet been built.",
+	"unwatchaddresses-addresses": "The addresses and numeric key IDs to stop watching",
+	"unwatchaddresses--result0":  "The number of addresses and key IDs which were watched",
+
 	// ValidateAddressCmd help.
 	"validateaddress--synopsis": "Verify an address is valid.",
 	"validateaddress-address":   "Bitcoin address to validate",
@@ -557,11 +1456,32 @@ var helpDescsEnUS = map[string]string{
 		"The actual checks performed by the checklevel parameter are implementation specific.\n" +
 		"For Prova this is:\n" +
 		"checklevel=0 - Look up each block and ensure it can be loaded from the database.\n" +
-		"checklevel=1 - Perform basic context-free sanity checks on each block.",
+		"checklevel=1 - Perform basic context-free sanity checks on each block.\n" +
+		"checklevel=2 - Disconnect each block with its spend journal entry and ensure the outputs it creates are in the utxo set.\n" +
+		"checklevel=3 - Reconnect the disconnected blocks with full validation and ensure the spend journal and utxo set are reproduced.",
 	"verifychain-checklevel": "How thorough the block verification is",
-	"verifychain-checkdepth": "The number of blocks to check",
+	"verifychain-checkdepth": "The number of blocks to check (0 for all)",
 	"verifychain--result0":   "Whether or not the chain verified",
 
+	// VerifyChainInconsistencyResult help.
+	"verifychaininconsistencyresult-hash":        "The hash of the inconsistent block (omitted for the best chain state records)",
+	"verifychaininconsistencyresult-height":      "The height of the inconsistent block",
+	"verifychaininconsistencyresult-description": "The description of the inconsistency",
+
+	// VerifyChainDBResult help.
+	"verifychaindbresult-verified":          "Whether or not the chain verified",
+	"verifychaindbresult-blockschecked":     "The number of blocks which passed all checks",
+	"verifychaindbresult-beststaterepaired": "Whether or not the best chain state records were repaired",
+	"verifychaindbresult-inconsistencies":   "The inconsistencies which were found",
+
+	// VerifyChainDBCmd help.
+	"verifychaindb--synopsis": "Verifies the chain state database and optionally repairs the best chain state records.\n" +
+		"The checklevel parameter has the same meaning as for verifychain.\n" +
+		"The best chain state records are always checked against the chain state.",
+	"verifychaindb-checklevel":  "How thorough the block verification is",
+	"verifychaindb-checkblocks": "The number of most recent blocks to check (0 for all)",
+	"verifychaindb-repair":      "Rewrite the best chain state records when they are inconsistent",
+
 	// VerifyMessageCmd help.
 	"verifymessage--synopsis": "Verify a signed message.",
 	"verifymessage-address":   "The bitcoin address to use for the signature",
@@ -569,6 +1489,14 @@ var helpDescsEnUS = map[string]string{
 	"verifymessage-message":   "The signed message",
 	"verifymessage--result0":  "Whether or not the signature verified",
 
+	// WatchAddressesCmd help.
+	"watchaddresses--synopsis": "Registers the passed addresses and key IDs with the watch-only index, which tracks their unspent outputs, balances, and history from the next block on.\n" +
+		"When the --addrbalanceindex flag is activated too, the unspent outputs existing at registration are included.\n" +
+		"Addresses and key IDs which are already watched are left untouched.\n" +
+		"Usage of this RPC requires the optional --watchindex flag to be activated, otherwise all responses will simply return with an error stating the watch-only index has not yet been built.",
+	"watchaddresses-addresses": "The addresses and numeric key IDs to watch",
+	"watchaddresses--result0":  "The number of newly watched addresses and key IDs",
+
 	// -------- Websocket-specific help --------
 
 	// Session help.
@@ -609,6 +1537,25 @@ var helpDescsEnUS = map[string]string{
 	"stopnotifyspent--synopsis": "Cancel registered spending notifications for each passed outpoint.",
 	"stopnotifyspent-outpoints": "List of transaction outpoints to stop monitoring.",
 
+	// NotifyWatchedCmd help.
+	"notifywatched--synopsis": "Send a watchedactivity notification when a transaction involving an address or key ID watched by the watch-only index is accepted into the mempool or connected to the main chain.\n" +
+		"Usage of this command requires the optional --watchindex flag to be activated.",
+
+	// StopNotifyWatchedCmd help.
+	"stopnotifywatched--synopsis": "Cancel registered watchedactivity notifications.",
+
+	// NotifyAdminTransactionsCmd help.
+	"notifyadmintransactions--synopsis": "Send an admintransaction notification with the decoded admin operations when a transaction on the root, provision or issue thread is accepted into the mempool or connected to the main chain, and an adminalert notification when the mempool sees an admin transaction which competes with the pending admin transactions or carries out unusual operations.",
+
+	// StopNotifyAdminTransactionsCmd help.
+	"stopnotifyadmintransactions--synopsis": "Cancel registered admintransaction and adminalert notifications.",
+
+	// NotifyPeerEventsCmd help.
+	"notifypeerevents--synopsis": "Send a peerevent notification when a peer connects, completes the version handshake, has its ban score increased for misbehavior or disconnects.",
+
+	// StopNotifyPeerEventsCmd help.
+	"stopnotifypeerevents--synopsis": "Cancel registered peerevent notifications.",
+
 	// LoadTxFilterCmd help.
 	"loadtxfilter--synopsis": "Load, add to, or reload a websocket client's transaction filter for mempool transactions, new blocks and rescanblocks.",
 	"loadtxfilter-reload":    "Load a new filter instead of adding data to an existing one",
@@ -617,13 +1564,16 @@ var helpDescsEnUS = map[string]string{
 
 	// Rescan help.
 	"rescan--synopsis": "Rescan block chain for transactions to addresses.\n" +
-		"When the endblock parameter is omitted, the rescan continues through the best block in the main chain.\n" +
+		"When the endblock parameter is omitted or empty, the rescan continues through the best block in the main chain.\n" +
 		"Rescan results are sent as recvtx and redeemingtx notifications.\n" +
+		"Blocks are rescanned in batches of --rescanbatchsize blocks, between which the rescan yields to the validation of new blocks.\n" +
+		"When a jobid is passed, the progress is checkpointed after each batch and the rescan is resumed from the last checkpoint by issuing it again with the same jobid after a disconnect or restart.\n" +
 		"This call returns once the rescan completes.",
 	"rescan-beginblock": "Hash of the first block to begin rescanning",
 	"rescan-addresses":  "List of addresses to include in the rescan",
 	"rescan-outpoints":  "List of transaction outpoints to include in the rescan",
 	"rescan-endblock":   "Hash of final block to rescan",
+	"rescan-jobid":      "ID of the job to checkpoint the rescan under -- the other parameters are ignored when resuming an interrupted job",
 
 	// RescanBlocks help.
 	"rescanblocks--synopsis":   "Rescan blocks for transactions matching the loaded transaction filter.",
@@ -639,63 +1589,133 @@ var helpDescsEnUS = map[string]string{
 // This information is used to generate the help.  Each result type must be a
 // pointer to the type (or nil to indicate no return value).
 var rpcResultTypes = map[string][]interface{}{
-	"addnode":               nil,
-	"createrawtransaction":  {(*string)(nil)},
-	"debuglevel":            {(*string)(nil), (*string)(nil)},
-	"decoderawtransaction":  {(*btcjson.TxRawDecodeResult)(nil)},
-	"decodescript":          {(*btcjson.DecodeScriptResult)(nil)},
-	"generate":              {(*[]string)(nil)},
-	"getaddednodeinfo":      {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
-	"getaddresstxids":       {(*[]string)(nil)},
-	"getadmininfo":          {(*btcjson.GetAdminInfoResult)(nil)},
-	"getbestblock":          {(*btcjson.GetBestBlockResult)(nil)},
-	"getbestblockhash":      {(*string)(nil)},
-	"getblock":              {(*string)(nil), (*btcjson.GetBlockVerboseResult)(nil)},
-	"getblockcount":         {(*int64)(nil)},
-	"getblockhash":          {(*string)(nil)},
-	"getblockheader":        {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
-	"getblocktemplate":      {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
-	"getconnectioncount":    {(*int32)(nil)},
-	"getcurrentnet":         {(*uint32)(nil)},
-	"getdifficulty":         {(*float64)(nil)},
-	"getgenerate":           {(*bool)(nil)},
-	"gethashespersec":       {(*float64)(nil)},
-	"getheaders":            {(*[]string)(nil)},
-	"getinfo":               {(*btcjson.InfoChainResult)(nil)},
-	"getmempoolinfo":        {(*btcjson.GetMempoolInfoResult)(nil)},
-	"getmininginfo":         {(*btcjson.GetMiningInfoResult)(nil)},
-	"getnettotals":          {(*btcjson.GetNetTotalsResult)(nil)},
-	"getnetworkhashps":      {(*int64)(nil)},
-	"getpeerinfo":           {(*[]btcjson.GetPeerInfoResult)(nil)},
-	"getrawmempool":         {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
-	"getrawtransaction":     {(*string)(nil), (*btcjson.TxRawResult)(nil)},
-	"gettxout":              {(*btcjson.GetTxOutResult)(nil)},
-	"node":                  nil,
-	"help":                  {(*string)(nil), (*string)(nil)},
-	"ping":                  nil,
-	"searchrawtransactions": {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
-	"sendrawtransaction":    {(*string)(nil)},
-	"setgenerate":           nil,
-	"setvalidatekeys":       nil,
-	"stop":                  {(*string)(nil)},
-	"submitblock":           {nil, (*string)(nil)},
-	"validateaddress":       {(*btcjson.ValidateAddressChainResult)(nil)},
-	"verifychain":           {(*bool)(nil)},
-	"verifymessage":         {(*bool)(nil)},
+	"abortrescan":                    nil,
+	"addnode":                        nil,
+	"backupchainstate":               {(*btcjson.BackupChainStateResult)(nil)},
+	"checkindex":                     {(*btcjson.CheckIndexResult)(nil)},
+	"clearbanned":                    nil,
+	"combinepspt":                    {(*string)(nil)},
+	"createpspt":                     {(*string)(nil)},
+	"createrawadmintransaction":      {(*string)(nil)},
+	"createrawtransaction":           {(*string)(nil)},
+	"debuglevel":                     {(*string)(nil), (*string)(nil)},
+	"debugscript":                    {(*btcjson.DebugScriptResult)(nil)},
+	"decodeadmintransaction":         {(*btcjson.DecodeAdminTransactionResult)(nil)},
+	"decodepspt":                     {(*btcjson.DecodePSPTResult)(nil)},
+	"decoderawtransaction":           {(*btcjson.TxRawDecodeResult)(nil)},
+	"decodescript":                   {(*btcjson.DecodeScriptResult)(nil)},
+	"estimatefee":                    {(*float64)(nil)},
+	"estimatesmartfee":               {(*btcjson.EstimateSmartFeeResult)(nil)},
+	"finalizepspt":                   {(*btcjson.FinalizePSPTResult)(nil)},
+	"generate":                       {(*[]string)(nil)},
+	"generatetoaddress":              {(*[]string)(nil)},
+	"getaddednodeinfo":               {(*[]string)(nil), (*[]btcjson.GetAddedNodeInfoResult)(nil)},
+	"getaddressbalance":              {(*btcjson.GetAddressBalanceResult)(nil)},
+	"getaddressissuance":             {(*[]btcjson.AddressIssuanceResult)(nil)},
+	"getaddresstxids":                {(*[]string)(nil)},
+	"getaddressutxos":                {(*[]btcjson.AddressUtxoResult)(nil)},
+	"getadmininfo":                   {(*btcjson.GetAdminInfoResult)(nil)},
+	"getbestblock":                   {(*btcjson.GetBestBlockResult)(nil)},
+	"getbestblockhash":               {(*string)(nil)},
+	"getblock":                       {(*string)(nil), (*btcjson.GetBlockVerboseResult)(nil)},
+	"getblockchaininfo":              {(*btcjson.GetBlockChainInfoResult)(nil)},
+	"getblockcount":                  {(*int64)(nil)},
+	"getblockhash":                   {(*string)(nil)},
+	"getblockhashbytime":             {(*btcjson.GetBlockHashByTimeResult)(nil)},
+	"getblockheader":                 {(*string)(nil), (*btcjson.GetBlockHeaderVerboseResult)(nil)},
+	"getblockscrubinfo":              {(*btcjson.GetBlockScrubInfoResult)(nil)},
+	"getblockstats":                  {(*btcjson.GetBlockStatsResult)(nil), (*[]btcjson.GetBlockStatsResult)(nil)},
+	"getblocktemplate":               {(*btcjson.GetBlockTemplateResult)(nil), (*string)(nil), nil},
+	"getcfheaders":                   {(*btcjson.GetCFHeadersResult)(nil)},
+	"getcfilter":                     {(*string)(nil)},
+	"getchaintips":                   {(*[]btcjson.GetChainTipsResult)(nil)},
+	"getcheckpointcandidates":        {(*[]btcjson.CheckpointCandidateResult)(nil)},
+	"getconnectioncount":             {(*int32)(nil)},
+	"getcurrentnet":                  {(*uint32)(nil)},
+	"getdifficulty":                  {(*float64)(nil)},
+	"getgenerate":                    {(*bool)(nil)},
+	"gethashespersec":                {(*float64)(nil)},
+	"getheaders":                     {(*[]string)(nil)},
+	"getindexinfo":                   {(*[]btcjson.GetIndexInfoResult)(nil)},
+	"getinfo":                        {(*btcjson.InfoChainResult)(nil)},
+	"getissuanceinfo":                {(*btcjson.GetIssuanceInfoResult)(nil)},
+	"getkeyidactivity":               {(*[]btcjson.KeyIDActivityResult)(nil)},
+	"getkeyidinfo":                   {(*btcjson.GetKeyIDInfoResult)(nil)},
+	"getmempoolancestors":            {(*[]string)(nil), (*btcjson.GetMempoolEntryResult)(nil)},
+	"getmempooldescendants":          {(*[]string)(nil), (*btcjson.GetMempoolEntryResult)(nil)},
+	"getmempoolentry":                {(*btcjson.GetMempoolEntryResult)(nil)},
+	"getmempoolinfo":                 {(*btcjson.GetMempoolInfoResult)(nil)},
+	"getmininginfo":                  {(*btcjson.GetMiningInfoResult)(nil)},
+	"getnettotals":                   {(*btcjson.GetNetTotalsResult)(nil)},
+	"getnetworkinfo":                 {(*btcjson.GetNetworkInfoResult)(nil)},
+	"getnetworkhashps":               {(*int64)(nil)},
+	"getpeerinfo":                    {(*[]btcjson.GetPeerInfoResult)(nil)},
+	"getrawmempool":                  {(*[]string)(nil), (*btcjson.GetRawMempoolVerboseResult)(nil)},
+	"getrawtransaction":              {(*string)(nil), (*btcjson.TxRawResult)(nil)},
+	"getreceivedbyaddress":           {(*float64)(nil)},
+	"getrescaninfo":                  {(*[]btcjson.RescanInfoResult)(nil)},
+	"getspentinfo":                   {(*btcjson.GetSpentInfoResult)(nil)},
+	"gettxout":                       {(*btcjson.GetTxOutResult)(nil)},
+	"gettxoutproof":                  {(*string)(nil)},
+	"gettxspendinginfo":              {(*btcjson.GetTxSpendingInfoResult)(nil)},
+	"getvalidationtimings":           {(*btcjson.GetValidationTimingsResult)(nil)},
+	"getvalidatorstats":              {(*btcjson.GetValidatorStatsResult)(nil)},
+	"getwatchedbalance":              {(*btcjson.GetAddressBalanceResult)(nil)},
+	"getwatchedhistory":              {(*btcjson.SearchRawTransactionsByAddressResult)(nil)},
+	"getwatchedutxos":                {(*[]btcjson.AddressUtxoResult)(nil)},
+	"importaddress":                  nil,
+	"importpubkey":                   nil,
+	"listadminoperations":            {(*[]btcjson.AdminOperationResult)(nil)},
+	"listunspent":                    {(*[]btcjson.ListUnspentResult)(nil)},
+	"listbanned":                     {(*[]btcjson.ListBannedResult)(nil)},
+	"listwatched":                    {(*[]btcjson.ListWatchedResult)(nil)},
+	"node":                           nil,
+	"dropindex":                      nil,
+	"dumputxosnapshot":               {(*btcjson.DumpUTXOSnapshotResult)(nil)},
+	"rebuildindex":                   nil,
+	"reloadconfig":                   {(*btcjson.ReloadConfigResult)(nil)},
+	"help":                           {(*string)(nil), (*string)(nil)},
+	"ping":                           nil,
+	"scantxoutset":                   {(*btcjson.ScanTxOutSetResult)(nil)},
+	"searchrawtransactions":          {(*string)(nil), (*[]btcjson.SearchRawTransactionsResult)(nil)},
+	"searchrawtransactionsbyaddress": {(*btcjson.SearchRawTransactionsByAddressResult)(nil)},
+	"searchrawtransactionsbykeyid":   {(*string)(nil), (*[]btcjson.TxRawResult)(nil)},
+	"sendrawtransaction":             {(*string)(nil)},
+	"setban":                         nil,
+	"setgenerate":                    nil,
+	"setpolicy":                      {(*btcjson.SetPolicyResult)(nil)},
+	"signrawtransactionwithkey":      {(*btcjson.SignRawTransactionResult)(nil)},
+	"setvalidatekeys":                nil,
+	"simulatechain":                  {(*[]btcjson.SimulatedBlockResult)(nil)},
+	"stop":                           {(*string)(nil)},
+	"submitblock":                    {nil, (*string)(nil)},
+	"testblockvalidity":              {(*btcjson.TestBlockValidityResult)(nil)},
+	"unwatchaddresses":               {(*int)(nil)},
+	"validateaddress":                {(*btcjson.ValidateAddressChainResult)(nil)},
+	"verifychain":                    {(*bool)(nil)},
+	"verifychaindb":                  {(*btcjson.VerifyChainDBResult)(nil)},
+	"verifymessage":                  {(*bool)(nil)},
+	"watchaddresses":                 {(*int)(nil)},
 
 	// Websocket commands.
-	"loadtxfilter":              nil,
-	"session":                   {(*btcjson.SessionResult)(nil)},
-	"notifyblocks":              nil,
-	"stopnotifyblocks":          nil,
-	"notifynewtransactions":     nil,
-	"stopnotifynewtransactions": nil,
-	"notifyreceived":            nil,
-	"stopnotifyreceived":        nil,
-	"notifyspent":               nil,
-	"stopnotifyspent":           nil,
-	"rescan":                    nil,
-	"rescanblocks":              {(*[]btcjson.RescannedBlock)(nil)},
+	"loadtxfilter":                nil,
+	"session":                     {(*btcjson.SessionResult)(nil)},
+	"notifyblocks":                nil,
+	"stopnotifyblocks":            nil,
+	"notifynewtransactions":       nil,
+	"stopnotifynewtransactions":   nil,
+	"notifyreceived":              nil,
+	"stopnotifyreceived":          nil,
+	"notifyspent":                 nil,
+	"stopnotifyspent":             nil,
+	"notifywatched":               nil,
+	"stopnotifywatched":           nil,
+	"notifyadmintransactions":     nil,
+	"stopnotifyadmintransactions": nil,
+	"notifypeerevents":            nil,
+	"stopnotifypeerevents":        nil,
+	"rescan":                      nil,
+	"rescanblocks":                {(*[]btcjson.RescannedBlock)(nil)},
 }
 
 // helpCacher provides a concurrent safe type that provides help and usage for
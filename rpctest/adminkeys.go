@@ -0,0 +1,163 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpctest
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/wire"
+)
+
+// wellKnownAdminKeys houses the hex-encoded private keys matching the admin key
+// sets, the validate keys, and the ASP key IDs of a network.
+type wellKnownAdminKeys struct {
+	root      []string
+	provision []string
+	issue     []string
+	validate  []string
+	asp       map[btcec.KeyID]string
+}
+
+// networkAdminKeys houses the admin keys of the networks whose private keys are
+// well known, which are the networks the harness is able to run its nodes on.
+var networkAdminKeys = map[wire.BitcoinNet]*wellKnownAdminKeys{
+	wire.RegNet: {
+		root: []string{
+			"eaf02ca348c524e6392655ba4d29603cd1a7347d9d65cfe93ce1ebffdca22694",
+			"2b8c52b77b327c755b9b375500d3f4b2da9b0a1ff65f6891d311fe94295bc26a",
+		},
+		provision: []string{
+			"f954b388f5db3a1d2915cda434206d791b47cf3d4e78cc32fbeb77ea25d20d7d",
+			"627f6f1d5d8f38bd60b6aaea2f74c72917deffcc2a5a64f67d3e0a28a2d711c1",
+		},
+		issue: []string{
+			"3f9222ab4d30b1795941d9815e5833a4da70cb04bff59a5fd2ddc4641e58607e",
+			"0a40defde0e49e1f78edb9cea5c499f704fabc140d6fd1a4df8405365e2e4f0f",
+		},
+		validate: []string{
+			"d36c82406d3c77ebc342aaa16f24a985fbfe63c75e6fd2afeffa1ba69632d252",
+			"05fa7a36092cc7accc8008365fd8d07229c794be2a4e9361c662b5cae9492fa3",
+			"a3262a6f506e4bfd4bc5b0708b2162e755410c8670e38c53928eb093ece2d37e",
+			"041bf76c17185bcddbbb5d40122d04528fbe6c68f488c16a4e85711410134b5e",
+			"224688827325203eb53d0ec0f044b72312c8e11fc4fdada7b91416e7b54939d5",
+			"c37e338bebe77d1ca77438ad7a382dc97c28703d793c732d88348eb5f26f9732",
+			"6d4a926fec187ee0a0b0395cadb39360687b8416809c21ab32490e944784d6a3",
+		},
+		asp: map[btcec.KeyID]string{
+			1: "eaf02ca348c524e6392655ba4d29603cd1a7347d9d65cfe93ce1ebffdca22694",
+			2: "2b8c52b77b327c755b9b375500d3f4b2da9b0a1ff65f6891d311fe94295bc26a",
+		},
+	},
+	wire.SimNet: {
+		root: []string{
+			"8579dff3a2b0c685b519d0a56df3199218c2c23fc220cabf23fe9437673698f1",
+			"2d758de881a00f4048867bb70831b4653fa75715c3352d00dfef8533966bff82",
+		},
+		provision: []string{
+			"5be5469c0b3a09132be3dbcddce1282857fa427a3a44b9d3d15383a36d3726cb",
+			"0134a5e18128d2010c5f36afdf71fdfaa24b4341b94d17724d6996fed17734ab",
+		},
+		issue: []string{
+			"f44207058ee9f840f506e122187fac5414fcf2f1fa9b38368389cab67860b03b",
+			"cff74e91c46b13e1ec75f37741eb963fe3dabd3f893103f74555f3e2bfa3f3b2",
+		},
+		validate: []string{
+			"854489b28043cda72329662fa1094c52d0c1ff1efb3748229465373c1bf67d79",
+			"84ff4d96aa06ed3e3fb900eb4fe0f885f19d3b80511ef38a3b0f72dec111195e",
+			"7f70de8b7618661c7607d0bb68d85b12d87e831b09edf18a6c4a26a3471abd52",
+			"af9083fca5329d57480d6c55313f8b15723be3255ef7659e32e643c29741e524",
+			"5472ded5f897361d5d36d47e3364a013a9def19af2869e04317682d4ed5aa52b",
+			"37b15a967ae805f78292c72d32391ec77f3e01c17fdc2dd5cdd3eb1bd7df2f93",
+			"7e443ba42fd3bfd5864ee98aa885d44b401656d60c807e23ac9242df4abb3689",
+			"b1866f958384f7397e746fff05759021e1cf5d140877cd5cbd589a68868c0ccd",
+			"1aa625c2f7fa27193e6beeadb471b6c0885ae506df9667517cc44edae56bb3e3",
+			"02a6c1d859fb87622942b444b4fdb77f67b12aaac722c5abd88a89b8433c9160",
+			"79572ef5c06b1484de6d35e0a7b6ea44784e4654932860e2ed08c066c1090395",
+		},
+		asp: map[btcec.KeyID]string{
+			1: "8579dff3a2b0c685b519d0a56df3199218c2c23fc220cabf23fe9437673698f1",
+			2: "2d758de881a00f4048867bb70831b4653fa75715c3352d00dfef8533966bff82",
+		},
+	},
+}
+
+// AdminKeys houses the private keys of the admin key sets, the validate keys,
+// and the ASP key IDs of a network.  They allow tests to sign blocks, admin
+// transactions, and the ASP side of spends from Prova addresses.
+type AdminKeys struct {
+	Root      []*btcec.PrivateKey
+	Provision []*btcec.PrivateKey
+	Issue     []*btcec.PrivateKey
+	Validate  []*btcec.PrivateKey
+	ASP       map[btcec.KeyID]*btcec.PrivateKey
+}
+
+// ASPKeyIDs returns the provisioned ASP key IDs in ascending order.
+func (k *AdminKeys) ASPKeyIDs() []btcec.KeyID {
+	keyIDs := make([]btcec.KeyID, 0, len(k.ASP))
+	for keyID := range k.ASP {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Slice(keyIDs, func(i, j int) bool {
+		return keyIDs[i] < keyIDs[j]
+	})
+	return keyIDs
+}
+
+// parsePrivKeys decodes the passed hex-encoded private keys.
+func parsePrivKeys(hexKeys []string) ([]*btcec.PrivateKey, error) {
+	keys := make([]*btcec.PrivateKey, 0, len(hexKeys))
+	for _, hexKey := range hexKeys {
+		keyBytes, err := hex.DecodeString(hexKey)
+		if err != nil {
+			return nil, err
+		}
+		key, _ := btcec.PrivKeyFromBytes(btcec.S256(), keyBytes)
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// NewAdminKeys returns the admin keys of the passed network.  Only the
+// regression and simulation test networks have well known admin keys, so an
+// error is returned for any other network.
+func NewAdminKeys(net *chaincfg.Params) (*AdminKeys, error) {
+	wellKnown, ok := networkAdminKeys[net.Net]
+	if !ok {
+		return nil, fmt.Errorf("admin keys of network %s are unknown",
+			net.Name)
+	}
+
+	keys := &AdminKeys{
+		ASP: make(map[btcec.KeyID]*btcec.PrivateKey),
+	}
+	var err error
+	if keys.Root, err = parsePrivKeys(wellKnown.root); err != nil {
+		return nil, err
+	}
+	keys.Provision, err = parsePrivKeys(wellKnown.provision)
+	if err != nil {
+		return nil, err
+	}
+	if keys.Issue, err = parsePrivKeys(wellKnown.issue); err != nil {
+		return nil, err
+	}
+	keys.Validate, err = parsePrivKeys(wellKnown.validate)
+	if err != nil {
+		return nil, err
+	}
+	for keyID, hexKey := range wellKnown.asp {
+		aspKeys, err := parsePrivKeys([]string{hexKey})
+		if err != nil {
+			return nil, err
+		}
+		keys.ASP[keyID] = aspKeys[0]
+	}
+	return keys, nil
+}
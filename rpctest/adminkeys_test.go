@@ -0,0 +1,64 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpctest
+
+import (
+	"testing"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+)
+
+// TestNewAdminKeys ensures the well known admin keys of the networks the
+// harness runs its nodes on match the admin key sets and ASP key IDs of their
+// parameters, and that the validate keys are able to progress the chain.
+func TestNewAdminKeys(t *testing.T) {
+	t.Parallel()
+
+	for _, params := range []*chaincfg.Params{&chaincfg.SimNetParams,
+		&chaincfg.RegressionNetParams} {
+
+		keys, err := NewAdminKeys(params)
+		if err != nil {
+			t.Errorf("NewAdminKeys(%s): unexpected error: %v",
+				params.Name, err)
+			continue
+		}
+		keySets := map[btcec.KeySetType][]*btcec.PrivateKey{
+			btcec.RootKeySet:      keys.Root,
+			btcec.ProvisionKeySet: keys.Provision,
+			btcec.IssueKeySet:     keys.Issue,
+			btcec.ValidateKeySet:  keys.Validate,
+		}
+		for keySet, privKeys := range keySets {
+			for _, privKey := range privKeys {
+				if params.AdminKeySets[keySet].Pos(privKey.PubKey()) == -1 {
+					t.Errorf("%s: key %x is not in the %v key set",
+						params.Name,
+						privKey.PubKey().SerializeCompressed(),
+						keySet)
+				}
+			}
+		}
+		if params.ChainWindowMaxBlocks > 0 &&
+			len(keys.Validate) < params.MinValidateKeySetSize() {
+
+			t.Errorf("%s: got %d validate keys, want at least %d",
+				params.Name, len(keys.Validate),
+				params.MinValidateKeySetSize())
+		}
+		for _, keyID := range keys.ASPKeyIDs() {
+			pubKey := params.ASPKeyIdMap[keyID]
+			if pubKey == nil || !pubKey.IsEqual(keys.ASP[keyID].PubKey()) {
+				t.Errorf("%s: key of ASP key ID %d does not match",
+					params.Name, keyID)
+			}
+		}
+	}
+
+	if _, err := NewAdminKeys(&chaincfg.MainNetParams); err == nil {
+		t.Error("NewAdminKeys: no error for the main network")
+	}
+}
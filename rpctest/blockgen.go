@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/provautil"
@@ -81,13 +82,13 @@ func solveBlock(header *wire.BlockHeader, targetDifficulty *big.Int) bool {
 // standardCoinbaseScript returns a standard script suitable for use as the
 // signature script of the coinbase transaction of a new block. In particular,
 // it starts with the block height that is required by version 2 blocks.
-func standardCoinbaseScript(nextBlockHeight int32) ([]byte, error) {
+func standardCoinbaseScript(nextBlockHeight uint32) ([]byte, error) {
 	return txscript.NewScriptBuilder().AddInt64(int64(nextBlockHeight)).Script()
 }
 
 // createCoinbaseTx returns a coinbase transaction paying an appropriate
 // subsidy based on the passed block height to the provided address.
-func createCoinbaseTx(coinbaseScript []byte, nextBlockHeight int32,
+func createCoinbaseTx(coinbaseScript []byte, nextBlockHeight uint32,
 	addr provautil.Address, net *chaincfg.Params) (*provautil.Tx, error) {
 
 	// Create the script to pay to the provided payment address.
@@ -112,10 +113,11 @@ func createCoinbaseTx(coinbaseScript []byte, nextBlockHeight int32,
 	return provautil.NewTx(tx), nil
 }
 
-// createBlock creates a new block building from the previous block.
+// createBlock creates a new block building from the previous block and signs
+// it with the passed validate key.
 func createBlock(prevBlock *provautil.Block, inclusionTxs []*provautil.Tx,
-	blockVersion int32, blockTime time.Time,
-	miningAddr provautil.Address, net *chaincfg.Params) (*provautil.Block, error) {
+	blockVersion int32, blockTime time.Time, miningAddr provautil.Address,
+	validateKey *btcec.PrivateKey, net *chaincfg.Params) (*provautil.Block, error) {
 
 	prevHash := prevBlock.Hash()
 	blockHeight := prevBlock.Height() + 1
@@ -149,7 +151,7 @@ func createBlock(prevBlock *provautil.Block, inclusionTxs []*provautil.Tx,
 	merkles := blockchain.BuildMerkleTreeStore(blockTxns)
 	var block wire.MsgBlock
 	block.Header = wire.BlockHeader{
-		Version:    blockVersion,
+		Version:    uint32(blockVersion),
 		PrevBlock:  *prevHash,
 		MerkleRoot: *merkles[len(merkles)-1],
 		Timestamp:  ts,
@@ -161,7 +163,10 @@ func createBlock(prevBlock *provautil.Block, inclusionTxs []*provautil.Tx,
 			return nil, err
 		}
 	}
-	block.Header.Size = block.SerializeSize()
+	block.Header.Size = uint32(block.SerializeSize())
+	if err := block.Header.Sign(validateKey); err != nil {
+		return nil, err
+	}
 
 	found := solveBlock(&block.Header, net.PowLimit)
 	if !found {
@@ -1,12 +1,22 @@
-// Package rpctest provides a btcd-specific RPC testing harness crafting and
-// executing integration tests by driving a `btcd` instance via the `RPC`
+// Package rpctest provides a prova-specific RPC testing harness crafting and
+// executing integration tests by driving a `prova` instance via the `RPC`
 // interface. Each instance of an active harness comes equipped with a simple
 // in-memory HD wallet capable of properly syncing to the generated chain,
-// creating new addresses, and crafting fully signed transactions paying to an
-// arbitrary set of outputs.
+// creating new Prova addresses, and crafting fully signed transactions paying
+// to an arbitrary set of outputs.
+//
+// The harness runs its nodes on the simulation or regression test network,
+// whose admin keys are well known.  The validate keys are loaded into each node
+// so it is able to generate blocks, and the admin keys are available through
+// AdminKeys so tests are able to sign admin transactions and spends from Prova
+// addresses.  The nodes are driven through Client, a websocket RPC client which
+// uses the Prova types throughout.  Addresses of keys controlled by a test are created with NewProvaAddress and
+// funded from the mature coinbase outputs of the harness with FundAddress.
+// Multiple harnesses are connected together with ConnectNode and JoinNodes.
 //
 // This package was designed specifically to act as an RPC testing harness for
-// `btcd`. However, the constructs presented are general enough to be adapted to
-// any project wishing to programmatically drive a `btcd` instance of its
-// systems/integration tests.
+// `prova`. However, the constructs presented are general enough to be adapted
+// to any project wishing to programmatically drive a `prova` instance of its
+// systems/integration tests, such as wallets writing end-to-end tests against
+// real nodes.
 package rpctest
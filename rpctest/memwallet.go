@@ -19,7 +19,6 @@ import (
 	"github.com/bitgo/prova/provautil/hdkeychain"
 	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
-	"github.com/btcsuite/btcrpcclient"
 )
 
 var (
@@ -56,7 +55,7 @@ func (u *utxo) isMature(height int32) bool {
 // chain.
 type chainUpdate struct {
 	blockHeight  int32
-	filteredTxns []*btcutil.Tx
+	filteredTxns []*provautil.Tx
 }
 
 // undoEntry is functionally the opposite of a chainUpdate. An undoEntry is
@@ -69,11 +68,18 @@ type undoEntry struct {
 
 // memWallet is a simple in-memory wallet whose purpose is to provide basic
 // wallet functionality to the harness. The wallet uses a hard-coded HD key
-// hierarchy which promotes reproducibility between harness test runs.
+// hierarchy which promotes reproducibility between harness test runs.  Its
+// addresses are Prova addresses which are spent with the wallet key along
+// with the key of the first ASP key ID of the address.
 type memWallet struct {
 	coinbaseKey  *btcec.PrivateKey
 	coinbaseAddr provautil.Address
 
+	// keyIDs are the ASP key IDs of the addresses of the wallet and aspKey
+	// is the private key of the first of them.
+	keyIDs []btcec.KeyID
+	aspKey *btcec.PrivateKey
+
 	// hdRoot is the root master private key for the wallet.
 	hdRoot *hdkeychain.ExtendedKey
 
@@ -103,14 +109,23 @@ type memWallet struct {
 
 	net *chaincfg.Params
 
-	rpc *btcrpcclient.Client
+	rpc *Client
 
 	sync.RWMutex
 }
 
 // newMemWallet creates and returns a fully initialized instance of the
-// memWallet given a particular blockchain's parameters.
-func newMemWallet(net *chaincfg.Params, harnessID uint32) (*memWallet, error) {
+// memWallet given a particular blockchain's parameters and its admin keys.
+func newMemWallet(net *chaincfg.Params, harnessID uint32,
+	adminKeys *AdminKeys) (*memWallet, error) {
+
+	keyIDs := adminKeys.ASPKeyIDs()
+	if len(keyIDs) < 2 {
+		return nil, fmt.Errorf("at least two ASP key IDs are required, "+
+			"got %d", len(keyIDs))
+	}
+	keyIDs = keyIDs[:2]
+
 	// The wallet's final HD seed is: hdSeed || harnessID. This method
 	// ensures that each harness instance uses a deterministic root seed
 	// based on its harness ID.
@@ -133,7 +148,7 @@ func newMemWallet(net *chaincfg.Params, harnessID uint32) (*memWallet, error) {
 	if err != nil {
 		return nil, err
 	}
-	coinbaseAddr, err := keyToAddr(coinbaseKey, net)
+	coinbaseAddr, err := coinbaseChild.Address(keyIDs, net)
 	if err != nil {
 		return nil, err
 	}
@@ -147,6 +162,8 @@ func newMemWallet(net *chaincfg.Params, harnessID uint32) (*memWallet, error) {
 		net:               net,
 		coinbaseKey:       coinbaseKey,
 		coinbaseAddr:      coinbaseAddr,
+		keyIDs:            keyIDs,
+		aspKey:            adminKeys.ASP[keyIDs[0]],
 		hdIndex:           1,
 		hdRoot:            hdRoot,
 		addrs:             addrs,
@@ -172,14 +189,14 @@ func (m *memWallet) SyncedHeight() int32 {
 
 // SetRPCClient saves the passed rpc connection to btcd as the wallet's
 // personal rpc connection.
-func (m *memWallet) SetRPCClient(rpcClient *btcrpcclient.Client) {
+func (m *memWallet) SetRPCClient(rpcClient *Client) {
 	m.rpc = rpcClient
 }
 
 // IngestBlock is a call-back which is to be triggered each time a new block is
 // connected to the main chain. Ingesting a block updates the wallet's internal
 // utxo state based on the outputs created and destroyed within each block.
-func (m *memWallet) IngestBlock(height int32, header *wire.BlockHeader, filteredTxns []*btcutil.Tx) {
+func (m *memWallet) IngestBlock(height int32, header *wire.BlockHeader, filteredTxns []*provautil.Tx) {
 	// Append this new chain update to the end of the queue of new chain
 	// updates.
 	m.chainMtx.Lock()
@@ -315,17 +332,13 @@ func (m *memWallet) newAddress() (provautil.Address, error) {
 	if err != nil {
 		return nil, err
 	}
-	privKey, err := childKey.ECPrivKey()
-	if err != nil {
-		return nil, err
-	}
 
-	addr, err := keyToAddr(privKey, m.net)
+	addr, err := childKey.Address(m.keyIDs, m.net)
 	if err != nil {
 		return nil, err
 	}
 
-	err = m.rpc.LoadTxFilter(false, []btcutil.Address{addr}, nil)
+	err = m.rpc.LoadTxFilter(false, []provautil.Address{addr}, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -356,8 +369,9 @@ func (m *memWallet) NewAddress() (provautil.Address, error) {
 func (m *memWallet) fundTx(tx *wire.MsgTx, amt provautil.Amount, feeRate provautil.Amount) error {
 	const (
 		// spendSize is the largest number of bytes of a sigScript
-		// which spends a p2pkh output: OP_DATA_73 <sig> OP_DATA_33 <pubkey>
-		spendSize = 1 + 73 + 1 + 33
+		// which spends a Prova output with two signatures:
+		// 2 * (OP_DATA_33 <pubkey> OP_DATA_73 <sig>)
+		spendSize = 2 * (1 + 33 + 1 + 73)
 	)
 
 	var (
@@ -472,8 +486,16 @@ func (m *memWallet) CreateTransaction(outputs []*wire.TxOut, feeRate provautil.A
 			return nil, err
 		}
 
-		sigScript, err := txscript.SignatureScript(tx, i, utxo.pkScript,
-			txscript.SigHashAll, privKey, true)
+		keys := []txscript.PrivateKey{
+			{Key: privKey, Compressed: true},
+			{Key: m.aspKey, Compressed: true},
+		}
+		lookupKey := func(provautil.Address) ([]txscript.PrivateKey, error) {
+			return keys, nil
+		}
+		sigScript, err := txscript.SignTxOutput(m.net, tx, i,
+			int64(utxo.value), utxo.pkScript, txscript.SigHashAll,
+			txscript.KeyClosure(lookupKey), nil)
 		if err != nil {
 			return nil, err
 		}
@@ -533,12 +555,12 @@ func (m *memWallet) ConfirmedBalance() provautil.Amount {
 	return balance
 }
 
-// keyToAddr maps the passed private to corresponding p2pkh address.
-func keyToAddr(key *btcec.PrivateKey, net *chaincfg.Params) (provautil.Address, error) {
-	serializedKey := key.PubKey().SerializeCompressed()
-	pubKeyAddr, err := provautil.NewAddressPubKey(serializedKey, net)
-	if err != nil {
-		return nil, err
-	}
-	return pubKeyAddr.AddressPubKeyHash(), nil
+// NewProvaAddress returns the Prova address of the passed public key and ASP
+// key IDs.  Outputs paying to the address are spent with signatures of the
+// key and of the key of any one of the key IDs.
+func NewProvaAddress(pubKey *btcec.PublicKey, keyIDs []btcec.KeyID,
+	net *chaincfg.Params) (*provautil.AddressProva, error) {
+
+	pkHash := provautil.Hash160(pubKey.SerializeCompressed())
+	return provautil.NewAddressProva(pkHash, keyIDs, net)
 }
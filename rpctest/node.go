@@ -16,15 +16,13 @@ import (
 	"strings"
 	"time"
 
-	"github.com/bitgo/prova/wire"
-
 	"github.com/bitgo/prova/provautil"
-	rpc "github.com/btcsuite/btcrpcclient"
 )
 
 // nodeConfig contains all the args, and data required to launch a btcd process
 // and connect the rpc client to it.
 type nodeConfig struct {
+	network    string
 	rpcUser    string
 	rpcPass    string
 	listen     string
@@ -44,9 +42,13 @@ type nodeConfig struct {
 	certificates []byte
 }
 
-// newConfig returns a newConfig with all default values.
-func newConfig(prefix, certFile, keyFile string, extra []string) (*nodeConfig, error) {
+// newConfig returns a newConfig with all default values for a node on the
+// passed network.
+func newConfig(prefix, network, certFile, keyFile string,
+	extra []string) (*nodeConfig, error) {
+
 	a := &nodeConfig{
+		network:   network,
 		listen:    "127.0.0.1:18555",
 		rpcListen: "127.0.0.1:18556",
 		rpcUser:   "user",
@@ -91,8 +93,8 @@ func (n *nodeConfig) setDefaults() error {
 // process.
 func (n *nodeConfig) arguments() []string {
 	args := []string{}
-	// --simnet
-	args = append(args, fmt.Sprintf("--%s", strings.ToLower(wire.SimNet.String())))
+	// --regtest, --simnet, etc.
+	args = append(args, fmt.Sprintf("--%s", strings.ToLower(n.network)))
 	if n.rpcUser != "" {
 		// --rpcuser
 		args = append(args, fmt.Sprintf("--rpcuser=%s", n.rpcUser))
@@ -148,14 +150,13 @@ func (n *nodeConfig) command() *exec.Cmd {
 
 // rpcConnConfig returns the rpc connection config that can be used to connect
 // to the btcd process that is launched via Start().
-func (n *nodeConfig) rpcConnConfig() rpc.ConnConfig {
-	return rpc.ConnConfig{
-		Host:                 n.rpcListen,
-		Endpoint:             n.endpoint,
-		User:                 n.rpcUser,
-		Pass:                 n.rpcPass,
-		Certificates:         n.certificates,
-		DisableAutoReconnect: true,
+func (n *nodeConfig) rpcConnConfig() ConnConfig {
+	return ConnConfig{
+		Host:         n.rpcListen,
+		Endpoint:     n.endpoint,
+		User:         n.rpcUser,
+		Pass:         n.rpcPass,
+		Certificates: n.certificates,
 	}
 }
 
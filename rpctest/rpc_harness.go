@@ -6,6 +6,8 @@
 package rpctest
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -19,8 +21,8 @@ import (
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
-	"github.com/btcsuite/btcrpcclient"
 )
 
 const (
@@ -61,10 +63,11 @@ var (
 // Harness to exercise functionality.
 type HarnessTestCase func(r *Harness, t *testing.T)
 
-// Harness fully encapsulates an active btcd process to provide a unified
-// platform for creating rpc driven integration tests involving btcd. The
-// active btcd node will typically be run in simnet mode in order to allow for
-// easy generation of test blockchains.  The active btcd process is fully
+// Harness fully encapsulates an active prova process to provide a unified
+// platform for creating rpc driven integration tests involving prova. The
+// active prova node is run on the simulation or regression test network, whose
+// admin keys are well known, in order to allow for easy generation of test
+// blockchains and admin transactions.  The active prova process is fully
 // managed by Harness, which handles the necessary initialization, and teardown
 // of the process along with any temporary directories created as a result.
 // Multiple Harness instances may be run concurrently, in order to allow for
@@ -75,11 +78,12 @@ type Harness struct {
 	// to.
 	ActiveNet *chaincfg.Params
 
-	Node     *btcrpcclient.Client
+	Node     *Client
 	node     *node
-	handlers *btcrpcclient.NotificationHandlers
+	handlers *NotificationHandlers
 
-	wallet *memWallet
+	wallet    *memWallet
+	adminKeys *AdminKeys
 
 	testNodeDir    string
 	maxConnRetries int
@@ -94,7 +98,7 @@ type Harness struct {
 // used.
 //
 // NOTE: This function is safe for concurrent access.
-func New(activeNet *chaincfg.Params, handlers *btcrpcclient.NotificationHandlers,
+func New(activeNet *chaincfg.Params, handlers *NotificationHandlers,
 	extraArgs []string) (*Harness, error) {
 
 	harnessStateMtx.Lock()
@@ -112,7 +116,13 @@ func New(activeNet *chaincfg.Params, handlers *btcrpcclient.NotificationHandlers
 		return nil, err
 	}
 
-	wallet, err := newMemWallet(activeNet, uint32(numTestInstances))
+	adminKeys, err := NewAdminKeys(activeNet)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, err := newMemWallet(activeNet, uint32(numTestInstances),
+		adminKeys)
 	if err != nil {
 		return nil, err
 	}
@@ -120,7 +130,8 @@ func New(activeNet *chaincfg.Params, handlers *btcrpcclient.NotificationHandlers
 	miningAddr := fmt.Sprintf("--miningaddr=%s", wallet.coinbaseAddr)
 	extraArgs = append(extraArgs, miningAddr)
 
-	config, err := newConfig("rpctest", certFile, keyFile, extraArgs)
+	config, err := newConfig("rpctest", activeNet.Name, certFile, keyFile,
+		extraArgs)
 	if err != nil {
 		return nil, err
 	}
@@ -138,7 +149,7 @@ func New(activeNet *chaincfg.Params, handlers *btcrpcclient.NotificationHandlers
 	numTestInstances++
 
 	if handlers == nil {
-		handlers = &btcrpcclient.NotificationHandlers{}
+		handlers = &NotificationHandlers{}
 	}
 
 	// If a handler for the OnFilteredBlock{Connected,Disconnected} callback
@@ -147,7 +158,7 @@ func New(activeNet *chaincfg.Params, handlers *btcrpcclient.NotificationHandlers
 	// callback.
 	if handlers.OnFilteredBlockConnected != nil {
 		obc := handlers.OnFilteredBlockConnected
-		handlers.OnFilteredBlockConnected = func(height int32, header *wire.BlockHeader, filteredTxns []*btcutil.Tx) {
+		handlers.OnFilteredBlockConnected = func(height int32, header *wire.BlockHeader, filteredTxns []*provautil.Tx) {
 			wallet.IngestBlock(height, header, filteredTxns)
 			obc(height, header, filteredTxns)
 		}
@@ -173,6 +184,7 @@ func New(activeNet *chaincfg.Params, handlers *btcrpcclient.NotificationHandlers
 		ActiveNet:      activeNet,
 		nodeNum:        nodeNum,
 		wallet:         wallet,
+		adminKeys:      adminKeys,
 	}
 
 	// Track this newly created test instance within the package level
@@ -183,9 +195,11 @@ func New(activeNet *chaincfg.Params, handlers *btcrpcclient.NotificationHandlers
 }
 
 // SetUp initializes the rpc test state. Initialization includes: starting up a
-// simnet node, creating a websockets client and connecting to the started
-// node, and finally: optionally generating and submitting a testchain with a
-// configurable number of mature coinbase outputs coinbase outputs.
+// prova node, creating a websockets client and connecting to the started
+// node, loading the validate keys into the node so it is able to sign the
+// blocks it generates, and finally: optionally generating and submitting a
+// testchain with a configurable number of mature coinbase outputs coinbase
+// outputs.
 //
 // NOTE: This method and TearDown should always be called from the same
 // goroutine as they are not concurrent safe.
@@ -198,12 +212,15 @@ func (h *Harness) SetUp(createTestChain bool, numMatureOutputs uint32) error {
 	if err := h.connectRPCClient(); err != nil {
 		return err
 	}
+	if err := h.setValidateKeys(); err != nil {
+		return err
+	}
 
 	h.wallet.Start()
 
 	// Filter transactions that pay to the coinbase associated with the
 	// wallet.
-	filterAddrs := []btcutil.Address{h.wallet.coinbaseAddr}
+	filterAddrs := []provautil.Address{h.wallet.coinbaseAddr}
 	if err := h.Node.LoadTxFilter(true, filterAddrs, nil); err != nil {
 		return err
 	}
@@ -284,12 +301,12 @@ func (h *Harness) TearDown() error {
 // we're not able to establish a connection, this function returns with an
 // error.
 func (h *Harness) connectRPCClient() error {
-	var client *btcrpcclient.Client
+	var client *Client
 	var err error
 
 	rpcConf := h.node.config.rpcConnConfig()
 	for i := 0; i < h.maxConnRetries; i++ {
-		if client, err = btcrpcclient.New(&rpcConf, h.handlers); err != nil {
+		if client, err = NewClient(&rpcConf, h.handlers); err != nil {
 			time.Sleep(time.Duration(i) * 50 * time.Millisecond)
 			continue
 		}
@@ -305,7 +322,30 @@ func (h *Harness) connectRPCClient() error {
 	return nil
 }
 
-// NewAddress returns a fresh address spendable by the Harness' internal
+// setValidateKeys loads the validate keys of the network into the node so the
+// blocks it generates are signed.
+func (h *Harness) setValidateKeys() error {
+	privKeys := make([]string, 0, len(h.adminKeys.Validate))
+	for _, key := range h.adminKeys.Validate {
+		privKeys = append(privKeys, hex.EncodeToString(key.Serialize()))
+	}
+	param, err := json.Marshal(privKeys)
+	if err != nil {
+		return err
+	}
+	_, err = h.Node.RawRequest("setvalidatekeys", []json.RawMessage{param})
+	return err
+}
+
+// AdminKeys returns the admin keys of the network the harness runs on.  The
+// keys allow tests to sign admin transactions, such as provisioning new ASP
+// key IDs, as well as spends from Prova addresses using the provisioned key
+// IDs.
+func (h *Harness) AdminKeys() *AdminKeys {
+	return h.adminKeys
+}
+
+// NewAddress returns a fresh Prova address spendable by the Harness' internal
 // wallet.
 //
 // This function is safe for concurrent access.
@@ -332,6 +372,30 @@ func (h *Harness) SendOutputs(targetOutputs []*wire.TxOut,
 	return h.wallet.SendOutputs(targetOutputs, feeRate)
 }
 
+// FundAddress sends the passed amount from the harness' available mature
+// coinbase outputs to the passed address and generates a block confirming the
+// transaction.  This allows tests to fund addresses of keys they control, such
+// as ones created with NewProvaAddress.
+//
+// This function is safe for concurrent access.
+func (h *Harness) FundAddress(addr provautil.Address, amt provautil.Amount,
+	feeRate provautil.Amount) (*chainhash.Hash, error) {
+
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	output := wire.NewTxOut(int64(amt), pkScript)
+	txid, err := h.SendOutputs([]*wire.TxOut{output}, feeRate)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := h.Node.Generate(1); err != nil {
+		return nil, err
+	}
+	return txid, nil
+}
+
 // CreateTransaction returns a fully signed transaction paying to the specified
 // outputs while observing the desired fee rate. The passed fee rate should be
 // expressed in atoms-per-byte. Any unspent outputs selected as inputs for
@@ -360,7 +424,7 @@ func (h *Harness) UnlockOutputs(inputs []*wire.TxIn) {
 // RPCConfig returns the harnesses current rpc configuration. This allows other
 // potential RPC clients created within tests to connect to a given test
 // harness instance.
-func (h *Harness) RPCConfig() btcrpcclient.ConnConfig {
+func (h *Harness) RPCConfig() ConnConfig {
 	return h.node.config.rpcConnConfig()
 }
 
@@ -393,9 +457,13 @@ func (h *Harness) GenerateAndSubmitBlock(txns []*provautil.Tx, blockVersion int3
 	}
 	prevBlock := provautil.NewBlock(mBlock)
 
-	// Create a new block including the specified transactions
+	// Create a new block including the specified transactions.  The
+	// validate keys take turns signing the blocks since a single key may
+	// only sign a limited number of blocks within the averaging window.
+	validateKeys := h.adminKeys.Validate
+	validateKey := validateKeys[int(prevBlockHeight+1)%len(validateKeys)]
 	newBlock, err := createBlock(prevBlock, txns, blockVersion,
-		blockTime, h.wallet.coinbaseAddr, h.ActiveNet)
+		blockTime, h.wallet.coinbaseAddr, validateKey, h.ActiveNet)
 	if err != nil {
 		return nil, err
 	}
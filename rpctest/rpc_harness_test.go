@@ -9,11 +9,13 @@
 package rpctest
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"testing"
 	"time"
 
+	"github.com/bitgo/prova/btcjson"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/provautil"
@@ -382,7 +384,7 @@ func testGenerateAndSubmitBlock(r *Harness, t *testing.T) {
 	// properly.
 	header := block.MsgBlock().Header
 	blockVersion = header.Version
-	if blockVersion != targetBlockVersion {
+	if blockVersion != uint32(targetBlockVersion) {
 		t.Fatalf("block version mismatch: expected %v, got %v",
 			targetBlockVersion, blockVersion)
 	}
@@ -433,6 +435,45 @@ func testMemWalletReorg(r *Harness, t *testing.T) {
 	}
 }
 
+func testSendBatch(r *Harness, t *testing.T) {
+	// Send a batch which mixes valid commands with one the node fails.
+	bestHash, bestHeight, err := r.Node.GetBestBlock()
+	if err != nil {
+		t.Fatalf("unable to get best block: %v", err)
+	}
+	results, err := r.Node.SendBatch([]interface{}{
+		btcjson.NewGetBlockCountCmd(),
+		btcjson.NewGetBlockHashCmd(int64(bestHeight) + 1),
+		btcjson.NewGetBlockHashCmd(int64(bestHeight)),
+	})
+	if err != nil {
+		t.Fatalf("unable to send batch: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d batch results, want 3", len(results))
+	}
+
+	// The results must be in the order of the commands, with the error of
+	// the failed command confined to its own result.
+	var count int32
+	if err := json.Unmarshal(results[0].Result, &count); err != nil {
+		t.Fatalf("unable to unmarshal block count: %v", err)
+	}
+	if count != bestHeight {
+		t.Fatalf("block count is %d, want %d", count, bestHeight)
+	}
+	if results[1].Err == nil {
+		t.Fatalf("getblockhash beyond the best block did not fail")
+	}
+	var hashStr string
+	if err := json.Unmarshal(results[2].Result, &hashStr); err != nil {
+		t.Fatalf("unable to unmarshal block hash: %v", err)
+	}
+	if hashStr != bestHash.String() {
+		t.Fatalf("block hash is %s, want %s", hashStr, bestHash)
+	}
+}
+
 func testMemWalletLockedOutputs(r *Harness, t *testing.T) {
 	// Obtain the initial balance of the wallet at this point.
 	startingBalance := r.ConfirmedBalance()
@@ -481,6 +522,7 @@ var harnessTestCases = []HarnessTestCase{
 	testGenerateAndSubmitBlock,
 	testMemWalletReorg,
 	testMemWalletLockedOutputs,
+	testSendBatch,
 }
 
 var mainHarness *Harness
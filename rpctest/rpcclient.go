@@ -0,0 +1,640 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpctest
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/bitgo/prova/btcjson"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+	"github.com/btcsuite/websocket"
+)
+
+// ErrClientShutdown is returned by the requests of a client which has been
+// shut down or lost its connection to the node.
+var ErrClientShutdown = errors.New("the client has been shut down")
+
+// ConnConfig describes the connection of a client to the websocket endpoint of
+// the RPC server of a node.
+type ConnConfig struct {
+	// Host is the host and port of the RPC server.
+	Host string
+
+	// Endpoint is the websocket endpoint of the RPC server, usually ws.
+	Endpoint string
+
+	// User and Pass are the credentials of the RPC server.
+	User string
+	Pass string
+
+	// Certificates are the PEM encoded certificates the TLS certificate of
+	// the RPC server is verified with.
+	Certificates []byte
+}
+
+// NotificationHandlers defines the callbacks invoked for the notifications of
+// the node.  The callbacks are invoked in the order the notifications are
+// received from a single goroutine, so they must not block on each other.  A
+// nil callback ignores its notifications.
+type NotificationHandlers struct {
+	// OnFilteredBlockConnected is invoked when a block is connected to the
+	// main chain, along with the transactions of it which match the
+	// transaction filter of the client.  It requires NotifyBlocks.
+	OnFilteredBlockConnected func(height int32, header *wire.BlockHeader,
+		filteredTxns []*provautil.Tx)
+
+	// OnFilteredBlockDisconnected is invoked when a block is disconnected
+	// from the main chain.  It requires NotifyBlocks.
+	OnFilteredBlockDisconnected func(height int32, header *wire.BlockHeader)
+}
+
+// rawMessage is a response to a request or a notification received from the
+// RPC server.  Notifications do not have an ID.
+type rawMessage struct {
+	ID     *uint64           `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+	Result json.RawMessage   `json:"result"`
+	Error  *btcjson.RPCError `json:"error"`
+}
+
+// rpcResponse is the result or error of a request.
+type rpcResponse struct {
+	result json.RawMessage
+	err    error
+}
+
+// BatchResult is the result or error of a command of a batch sent with
+// SendBatch.
+type BatchResult struct {
+	// Result is the raw result of the command, which is nil when Err is
+	// set.
+	Result json.RawMessage
+
+	// Err is the error the node replied to the command with, if any.
+	Err error
+}
+
+// Client is a websocket JSON-RPC client for the RPC server of a Prova node.  It
+// provides the requests used by the harness and its tests, along with the
+// filtered block notifications the in-memory wallet is driven by, using the
+// Prova types throughout.
+//
+// A client is safe for concurrent access.
+type Client struct {
+	conn     *websocket.Conn
+	handlers NotificationHandlers
+
+	// config and httpClient are used to send batches of commands as HTTP
+	// POST requests, since the websocket endpoint does not serve batches.
+	config     ConnConfig
+	httpClient *http.Client
+
+	// sendMtx serializes the writes to the websocket connection.
+	sendMtx sync.Mutex
+
+	mtx      sync.Mutex
+	nextID   uint64
+	pending  map[uint64]chan *rpcResponse
+	ntfns    []*rawMessage
+	shutdown bool
+
+	ntfnSignal chan struct{}
+	quit       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// NewClient connects to the websocket endpoint of the RPC server described by
+// the passed config and returns a client which invokes the passed notification
+// handlers, which may be nil.
+func NewClient(config *ConnConfig, handlers *NotificationHandlers) (*Client, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(config.Certificates) {
+		return nil, errors.New("no valid RPC server certificates")
+	}
+	tlsConfig := &tls.Config{RootCAs: pool}
+	dialer := websocket.Dialer{
+		TLSClientConfig: tlsConfig,
+	}
+	header := make(http.Header)
+	req, err := http.NewRequest("GET", "/", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(config.User, config.Pass)
+	header.Set("Authorization", req.Header.Get("Authorization"))
+
+	url := fmt.Sprintf("wss://%s/%s", config.Host, config.Endpoint)
+	conn, resp, err := dialer.Dial(url, header)
+	if err != nil {
+		if err == websocket.ErrBadHandshake && resp != nil {
+			return nil, fmt.Errorf("websocket handshake failed: %s",
+				resp.Status)
+		}
+		return nil, err
+	}
+
+	c := &Client{
+		conn:   conn,
+		config: *config,
+		httpClient: &http.Client{
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		pending:    make(map[uint64]chan *rpcResponse),
+		ntfnSignal: make(chan struct{}, 1),
+		quit:       make(chan struct{}),
+	}
+	if handlers != nil {
+		c.handlers = *handlers
+	}
+	c.wg.Add(2)
+	go c.inHandler()
+	go c.ntfnHandler()
+	return c, nil
+}
+
+// inHandler reads the messages of the RPC server, hands the responses to the
+// pending requests and queues the notifications for ntfnHandler.  It must be
+// run as a goroutine.
+func (c *Client) inHandler() {
+	defer c.wg.Done()
+	defer c.Shutdown()
+
+	for {
+		_, msg, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var raw rawMessage
+		if err := json.Unmarshal(msg, &raw); err != nil {
+			continue
+		}
+
+		c.mtx.Lock()
+		if raw.ID == nil {
+			if raw.Method != "" {
+				c.ntfns = append(c.ntfns, &raw)
+				select {
+				case c.ntfnSignal <- struct{}{}:
+				default:
+				}
+			}
+			c.mtx.Unlock()
+			continue
+		}
+		respChan, ok := c.pending[*raw.ID]
+		delete(c.pending, *raw.ID)
+		c.mtx.Unlock()
+		if !ok {
+			continue
+		}
+		resp := &rpcResponse{result: raw.Result}
+		if raw.Error != nil {
+			resp.err = raw.Error
+		}
+		respChan <- resp
+	}
+}
+
+// ntfnHandler invokes the notification handlers for the queued notifications
+// in order.  It must be run as a goroutine.
+func (c *Client) ntfnHandler() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.ntfnSignal:
+		case <-c.quit:
+			return
+		}
+
+		c.mtx.Lock()
+		ntfns := c.ntfns
+		c.ntfns = nil
+		c.mtx.Unlock()
+		for _, ntfn := range ntfns {
+			c.handleNotification(ntfn)
+		}
+	}
+}
+
+// handleNotification invokes the notification handler of the passed
+// notification.  Notifications without a handler and malformed notifications
+// are ignored.
+func (c *Client) handleNotification(ntfn *rawMessage) {
+	cmd, err := btcjson.UnmarshalCmd(&btcjson.Request{
+		Method: ntfn.Method,
+		Params: ntfn.Params,
+	})
+	if err != nil {
+		return
+	}
+
+	switch cmd := cmd.(type) {
+	case *btcjson.FilteredBlockConnectedNtfn:
+		if c.handlers.OnFilteredBlockConnected == nil {
+			return
+		}
+		header, err := parseHeader(cmd.Header)
+		if err != nil {
+			return
+		}
+		txns := make([]*provautil.Tx, 0, len(cmd.SubscribedTxs))
+		for _, hexTx := range cmd.SubscribedTxs {
+			serializedTx, err := hex.DecodeString(hexTx)
+			if err != nil {
+				return
+			}
+			tx, err := provautil.NewTxFromBytes(serializedTx)
+			if err != nil {
+				return
+			}
+			txns = append(txns, tx)
+		}
+		c.handlers.OnFilteredBlockConnected(cmd.Height, header, txns)
+
+	case *btcjson.FilteredBlockDisconnectedNtfn:
+		if c.handlers.OnFilteredBlockDisconnected == nil {
+			return
+		}
+		header, err := parseHeader(cmd.Header)
+		if err != nil {
+			return
+		}
+		c.handlers.OnFilteredBlockDisconnected(cmd.Height, header)
+	}
+}
+
+// parseHeader decodes the passed hex-encoded block header.
+func parseHeader(hexHeader string) (*wire.BlockHeader, error) {
+	serializedHeader, err := hex.DecodeString(hexHeader)
+	if err != nil {
+		return nil, err
+	}
+	var header wire.BlockHeader
+	err = header.Deserialize(bytes.NewReader(serializedHeader))
+	if err != nil {
+		return nil, err
+	}
+	return &header, nil
+}
+
+// sendRequest sends the request with the passed method and parameters and
+// waits for its result.
+func (c *Client) sendRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	respChan := make(chan *rpcResponse, 1)
+	c.mtx.Lock()
+	if c.shutdown {
+		c.mtx.Unlock()
+		return nil, ErrClientShutdown
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = respChan
+	c.mtx.Unlock()
+
+	if params == nil {
+		params = []json.RawMessage{}
+	}
+	msg, err := json.Marshal(&btcjson.Request{
+		Jsonrpc: "1.0",
+		Method:  method,
+		Params:  params,
+		ID:      id,
+	})
+	if err == nil {
+		c.sendMtx.Lock()
+		err = c.conn.WriteMessage(websocket.TextMessage, msg)
+		c.sendMtx.Unlock()
+	}
+	if err != nil {
+		c.mtx.Lock()
+		delete(c.pending, id)
+		c.mtx.Unlock()
+		return nil, err
+	}
+
+	select {
+	case resp := <-respChan:
+		return resp.result, resp.err
+	case <-c.quit:
+		return nil, ErrClientShutdown
+	}
+}
+
+// sendCmd sends the passed command, which must be registered with btcjson, and
+// unmarshals its result into the passed result unless it is nil.
+func (c *Client) sendCmd(cmd interface{}, result interface{}) error {
+	marshalledCmd, err := btcjson.MarshalCmd(0, cmd)
+	if err != nil {
+		return err
+	}
+	var req btcjson.Request
+	if err := json.Unmarshal(marshalledCmd, &req); err != nil {
+		return err
+	}
+	rawResult, err := c.sendRequest(req.Method, req.Params)
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(rawResult, result)
+}
+
+// RawRequest sends a request with the passed method and raw parameters, which
+// allows commands btcjson does not know of to be issued, and returns its raw
+// result.
+func (c *Client) RawRequest(method string, params []json.RawMessage) (json.RawMessage, error) {
+	return c.sendRequest(method, params)
+}
+
+// SendBatch sends the passed commands, which must be registered with btcjson,
+// to the node as a single batch of JSON-RPC requests and returns their results
+// in the order of the commands.  The batch is sent as an HTTP POST request
+// rather than over the websocket connection.  The error is only set when the
+// batch as a whole fails, while the errors of individual commands are returned
+// in their results.
+func (c *Client) SendBatch(cmds []interface{}) ([]BatchResult, error) {
+	c.mtx.Lock()
+	if c.shutdown {
+		c.mtx.Unlock()
+		return nil, ErrClientShutdown
+	}
+	c.mtx.Unlock()
+
+	// Number the requests of the batch by their index, which is how the
+	// replies are matched with them since the JSON-RPC specification does
+	// not require the replies to be in the order of the requests.
+	requests := make([]json.RawMessage, 0, len(cmds))
+	for i, cmd := range cmds {
+		marshalledCmd, err := btcjson.MarshalCmd(i, cmd)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, marshalledCmd)
+	}
+	body, err := json.Marshal(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", "https://"+c.config.Host,
+		bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth(c.config.User, c.config.Pass)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("batch request failed: %s: %s",
+			resp.Status, bytes.TrimSpace(respBody))
+	}
+
+	// A batch the node rejects as a whole is replied to with a single
+	// error rather than an array.
+	var replies []rawMessage
+	if err := json.Unmarshal(respBody, &replies); err != nil {
+		var reply rawMessage
+		if json.Unmarshal(respBody, &reply) == nil && reply.Error != nil {
+			return nil, reply.Error
+		}
+		return nil, err
+	}
+
+	results := make([]BatchResult, len(cmds))
+	received := make([]bool, len(cmds))
+	for _, reply := range replies {
+		if reply.ID == nil || *reply.ID >= uint64(len(cmds)) {
+			return nil, errors.New("batch reply with unexpected id")
+		}
+		if reply.Error != nil {
+			results[*reply.ID].Err = reply.Error
+		} else {
+			results[*reply.ID].Result = reply.Result
+		}
+		received[*reply.ID] = true
+	}
+	for i := range received {
+		if !received[i] {
+			return nil, fmt.Errorf("no reply to command #%d of the "+
+				"batch", i)
+		}
+	}
+	return results, nil
+}
+
+// Shutdown closes the connection to the RPC server.  The pending requests and
+// all requests after it fail with ErrClientShutdown.
+func (c *Client) Shutdown() {
+	c.mtx.Lock()
+	if c.shutdown {
+		c.mtx.Unlock()
+		return
+	}
+	c.shutdown = true
+	c.mtx.Unlock()
+
+	close(c.quit)
+	c.conn.Close()
+}
+
+// WaitForShutdown blocks until the goroutines of the client have exited after
+// Shutdown.
+func (c *Client) WaitForShutdown() {
+	c.wg.Wait()
+}
+
+// AddNode adds, removes, or connects once to the passed peer according to the
+// passed command.
+func (c *Client) AddNode(host string, command btcjson.AddNodeSubCmd) error {
+	return c.sendCmd(btcjson.NewAddNodeCmd(host, command), nil)
+}
+
+// Generate has the node generate the passed number of blocks and returns their
+// hashes.
+func (c *Client) Generate(numBlocks uint32) ([]*chainhash.Hash, error) {
+	var hashStrs []string
+	err := c.sendCmd(btcjson.NewGenerateCmd(numBlocks), &hashStrs)
+	if err != nil {
+		return nil, err
+	}
+	return parseHashes(hashStrs)
+}
+
+// GetBestBlock returns the hash and height of the end of the main chain.
+func (c *Client) GetBestBlock() (*chainhash.Hash, int32, error) {
+	var result btcjson.GetBestBlockResult
+	if err := c.sendCmd(btcjson.NewGetBestBlockCmd(), &result); err != nil {
+		return nil, 0, err
+	}
+	hash, err := chainhash.NewHashFromStr(result.Hash)
+	if err != nil {
+		return nil, 0, err
+	}
+	return hash, int32(result.Height), nil
+}
+
+// GetBlock returns the block with the passed hash.
+func (c *Client) GetBlock(blockHash *chainhash.Hash) (*wire.MsgBlock, error) {
+	verbose := false
+	var hexBlock string
+	cmd := btcjson.NewGetBlockCmd(blockHash.String(), &verbose, nil)
+	if err := c.sendCmd(cmd, &hexBlock); err != nil {
+		return nil, err
+	}
+	serializedBlock, err := hex.DecodeString(hexBlock)
+	if err != nil {
+		return nil, err
+	}
+	var block wire.MsgBlock
+	err = block.Deserialize(bytes.NewReader(serializedBlock))
+	if err != nil {
+		return nil, err
+	}
+	return &block, nil
+}
+
+// GetBlockCount returns the height of the end of the main chain.
+func (c *Client) GetBlockCount() (int64, error) {
+	var count int64
+	err := c.sendCmd(btcjson.NewGetBlockCountCmd(), &count)
+	return count, err
+}
+
+// GetBlockHash returns the hash of the main chain block at the passed height.
+func (c *Client) GetBlockHash(blockHeight int64) (*chainhash.Hash, error) {
+	var hashStr string
+	err := c.sendCmd(btcjson.NewGetBlockHashCmd(blockHeight), &hashStr)
+	if err != nil {
+		return nil, err
+	}
+	return chainhash.NewHashFromStr(hashStr)
+}
+
+// GetInfo returns miscellaneous information about the node.
+func (c *Client) GetInfo() (*btcjson.InfoChainResult, error) {
+	var info btcjson.InfoChainResult
+	if err := c.sendCmd(btcjson.NewGetInfoCmd(), &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetPeerInfo returns information about the peers of the node.
+func (c *Client) GetPeerInfo() ([]btcjson.GetPeerInfoResult, error) {
+	var peers []btcjson.GetPeerInfoResult
+	err := c.sendCmd(btcjson.NewGetPeerInfoCmd(), &peers)
+	return peers, err
+}
+
+// GetRawMempool returns the hashes of the transactions in the memory pool of
+// the node.
+func (c *Client) GetRawMempool() ([]*chainhash.Hash, error) {
+	verbose := false
+	var hashStrs []string
+	err := c.sendCmd(btcjson.NewGetRawMempoolCmd(&verbose), &hashStrs)
+	if err != nil {
+		return nil, err
+	}
+	return parseHashes(hashStrs)
+}
+
+// LoadTxFilter loads, or with reload set replaces, the transaction filter of
+// the client with the passed addresses and outpoints.  The transactions of the
+// filtered block notifications are those which pay to the addresses or spend
+// the outpoints.
+func (c *Client) LoadTxFilter(reload bool, addresses []provautil.Address,
+	outPoints []wire.OutPoint) error {
+
+	addrStrs := make([]string, 0, len(addresses))
+	for _, addr := range addresses {
+		addrStrs = append(addrStrs, addr.EncodeAddress())
+	}
+	ops := make([]btcjson.OutPoint, 0, len(outPoints))
+	for _, op := range outPoints {
+		ops = append(ops, btcjson.OutPoint{
+			Hash:  op.Hash.String(),
+			Index: op.Index,
+		})
+	}
+	return c.sendCmd(btcjson.NewLoadTxFilterCmd(reload, addrStrs, ops), nil)
+}
+
+// NotifyBlocks registers the client for the filtered block notifications.
+func (c *Client) NotifyBlocks() error {
+	return c.sendCmd(btcjson.NewNotifyBlocksCmd(), nil)
+}
+
+// SendRawTransaction submits the passed transaction to the node and returns
+// its hash.
+func (c *Client) SendRawTransaction(tx *wire.MsgTx, allowHighFees bool) (*chainhash.Hash, error) {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return nil, err
+	}
+	cmd := btcjson.NewSendRawTransactionCmd(hex.EncodeToString(buf.Bytes()),
+		&allowHighFees)
+	var hashStr string
+	if err := c.sendCmd(cmd, &hashStr); err != nil {
+		return nil, err
+	}
+	return chainhash.NewHashFromStr(hashStr)
+}
+
+// SubmitBlock submits the passed block to the node.  The node rejecting the
+// block results in an error.
+func (c *Client) SubmitBlock(block *provautil.Block,
+	options *btcjson.SubmitBlockOptions) error {
+
+	serializedBlock, err := block.Bytes()
+	if err != nil {
+		return err
+	}
+	cmd := btcjson.NewSubmitBlockCmd(hex.EncodeToString(serializedBlock),
+		options)
+	var reason *string
+	if err := c.sendCmd(cmd, &reason); err != nil {
+		return err
+	}
+	if reason != nil {
+		return fmt.Errorf("block rejected: %s", *reason)
+	}
+	return nil
+}
+
+// parseHashes decodes the passed hashes in their byte-reversed hex encoding.
+func parseHashes(hashStrs []string) ([]*chainhash.Hash, error) {
+	hashes := make([]*chainhash.Hash, 0, len(hashStrs))
+	for _, hashStr := range hashStrs {
+		hash, err := chainhash.NewHashFromStr(hashStr)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, nil
+}
@@ -9,8 +9,8 @@ import (
 	"reflect"
 	"time"
 
+	"github.com/bitgo/prova/btcjson"
 	"github.com/bitgo/prova/chaincfg/chainhash"
-	"github.com/btcsuite/btcrpcclient"
 )
 
 // JoinType is an enum representing a particular type of "node join". A node
@@ -116,7 +116,7 @@ func ConnectNode(from *Harness, to *Harness) error {
 	numPeers := len(peerInfo)
 
 	targetAddr := to.node.config.listen
-	if err := from.Node.AddNode(targetAddr, btcrpcclient.ANAdd); err != nil {
+	if err := from.Node.AddNode(targetAddr, btcjson.ANAdd); err != nil {
 		return err
 	}
 
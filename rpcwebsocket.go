@@ -17,14 +17,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math"
 	"sync"
 	"time"
 
 	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/indexers"
 	"github.com/bitgo/prova/btcjson"
 	"github.com/bitgo/prova/chaincfg/chainhash"
-	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/mempool"
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
@@ -63,19 +63,25 @@ type wsCommandHandler func(*wsClient, interface{}) (interface{}, error)
 // causes a dependency loop.
 var wsHandlers map[string]wsCommandHandler
 var wsHandlersBeforeInit = map[string]wsCommandHandler{
-	"loadtxfilter":              handleLoadTxFilter,
-	"help":                      handleWebsocketHelp,
-	"notifyblocks":              handleNotifyBlocks,
-	"notifynewtransactions":     handleNotifyNewTransactions,
-	"notifyreceived":            handleNotifyReceived,
-	"notifyspent":               handleNotifySpent,
-	"session":                   handleSession,
-	"stopnotifyblocks":          handleStopNotifyBlocks,
-	"stopnotifynewtransactions": handleStopNotifyNewTransactions,
-	"stopnotifyspent":           handleStopNotifySpent,
-	"stopnotifyreceived":        handleStopNotifyReceived,
-	"rescan":                    handleRescan,
-	"rescanblocks":              handleRescanBlocks,
+	"loadtxfilter":                handleLoadTxFilter,
+	"help":                        handleWebsocketHelp,
+	"notifyadmintransactions":     handleNotifyAdminTransactions,
+	"notifyblocks":                handleNotifyBlocks,
+	"notifynewtransactions":       handleNotifyNewTransactions,
+	"notifypeerevents":            handleNotifyPeerEvents,
+	"notifyreceived":              handleNotifyReceived,
+	"notifyspent":                 handleNotifySpent,
+	"notifywatched":               handleNotifyWatched,
+	"session":                     handleSession,
+	"stopnotifyadmintransactions": handleStopNotifyAdminTransactions,
+	"stopnotifyblocks":            handleStopNotifyBlocks,
+	"stopnotifynewtransactions":   handleStopNotifyNewTransactions,
+	"stopnotifypeerevents":        handleStopNotifyPeerEvents,
+	"stopnotifyspent":             handleStopNotifySpent,
+	"stopnotifyreceived":          handleStopNotifyReceived,
+	"stopnotifywatched":           handleStopNotifyWatched,
+	"rescan":                      handleRescan,
+	"rescanblocks":                handleRescanBlocks,
 }
 
 // WebsocketHandler handles a new websocket client by creating a new wsClient,
@@ -92,9 +98,10 @@ func (s *rpcServer) WebsocketHandler(conn *websocket.Conn, remoteAddr string,
 
 	// Limit max number of websocket clients.
 	rpcsLog.Infof("New websocket client %s", remoteAddr)
-	if s.ntfnMgr.NumClients()+1 > cfg.RPCMaxWebsockets {
+	maxWebsockets := settings().rpcMaxWebsockets
+	if s.ntfnMgr.NumClients()+1 > maxWebsockets {
 		rpcsLog.Infof("Max websocket clients exceeded [%d] - "+
-			"disconnecting client %s", cfg.RPCMaxWebsockets,
+			"disconnecting client %s", maxWebsockets,
 			remoteAddr)
 		conn.Close()
 		return
@@ -246,6 +253,34 @@ func (m *wsNotificationManager) NotifyMempoolTx(tx *provautil.Tx, isNew bool) {
 	}
 }
 
+// NotifyAdminAlert passes an alert raised by the mempool for an admin
+// transaction to the notification manager for admin alert notification
+// processing.
+func (m *wsNotificationManager) NotifyAdminAlert(alert *mempool.AdminAlert) {
+	// As NotifyAdminAlert will be called by mempool and the RPC server
+	// may no longer be running, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun
+	// shutting down.
+	select {
+	case m.queueNotification <- (*notificationAdminAlert)(alert):
+	case <-m.quit:
+	}
+}
+
+// NotifyPeerEvent passes a peer lifecycle event to the notification manager
+// for peer event notification processing.  It is registered with the server as
+// a peer event callback.
+func (m *wsNotificationManager) NotifyPeerEvent(event *peerEvent) {
+	// As NotifyPeerEvent will be called by the server and the RPC server
+	// may no longer be running, use a select statement to unblock
+	// enqueuing the notification once the RPC server has begun
+	// shutting down.
+	select {
+	case m.queueNotification <- (*notificationPeerEvent)(event):
+	case <-m.quit:
+	}
+}
+
 // Notification types
 type notificationBlockConnected provautil.Block
 type notificationBlockDisconnected provautil.Block
@@ -253,6 +288,8 @@ type notificationTxAcceptedByMempool struct {
 	isNew bool
 	tx    *provautil.Tx
 }
+type notificationAdminAlert mempool.AdminAlert
+type notificationPeerEvent peerEvent
 
 // Notification control requests
 type notificationRegisterClient wsClient
@@ -261,6 +298,12 @@ type notificationRegisterBlocks wsClient
 type notificationUnregisterBlocks wsClient
 type notificationRegisterNewMempoolTxs wsClient
 type notificationUnregisterNewMempoolTxs wsClient
+type notificationRegisterWatched wsClient
+type notificationUnregisterWatched wsClient
+type notificationRegisterAdminTxs wsClient
+type notificationUnregisterAdminTxs wsClient
+type notificationRegisterPeerEvents wsClient
+type notificationUnregisterPeerEvents wsClient
 type notificationRegisterSpent struct {
 	wsc *wsClient
 	ops []*wire.OutPoint
@@ -293,6 +336,9 @@ func (m *wsNotificationManager) notificationHandler() {
 	// since it is quite a bit more efficient than using the entire struct.
 	blockNotifications := make(map[chan struct{}]*wsClient)
 	txNotifications := make(map[chan struct{}]*wsClient)
+	watchedNotifications := make(map[chan struct{}]*wsClient)
+	adminTxNotifications := make(map[chan struct{}]*wsClient)
+	peerNotifications := make(map[chan struct{}]*wsClient)
 	watchedOutPoints := make(map[wire.OutPoint]map[chan struct{}]*wsClient)
 	watchedAddrs := make(map[string]map[chan struct{}]*wsClient)
 
@@ -324,6 +370,16 @@ out:
 						block)
 				}
 
+				if len(watchedNotifications) != 0 {
+					m.notifyWatchedBlock(watchedNotifications,
+						block)
+				}
+
+				if len(adminTxNotifications) != 0 {
+					m.notifyAdminBlock(adminTxNotifications,
+						block)
+				}
+
 			case *notificationBlockDisconnected:
 				block := (*provautil.Block)(n)
 
@@ -340,6 +396,25 @@ out:
 				}
 				m.notifyForTx(watchedOutPoints, watchedAddrs, n.tx, nil)
 				m.notifyRelevantTxAccepted(n.tx, clients)
+				if n.isNew && len(watchedNotifications) != 0 {
+					m.notifyWatchedTx(watchedNotifications, n.tx)
+				}
+				if n.isNew && len(adminTxNotifications) != 0 {
+					m.notifyAdminTx(adminTxNotifications, n.tx,
+						nil, 0)
+				}
+
+			case *notificationAdminAlert:
+				if len(adminTxNotifications) != 0 {
+					m.notifyAdminAlert(adminTxNotifications,
+						(*mempool.AdminAlert)(n))
+				}
+
+			case *notificationPeerEvent:
+				if len(peerNotifications) != 0 {
+					m.notifyPeerEvent(peerNotifications,
+						(*peerEvent)(n))
+				}
 
 			case *notificationRegisterBlocks:
 				wsc := (*wsClient)(n)
@@ -359,6 +434,9 @@ out:
 				// the client itself.
 				delete(blockNotifications, wsc.quit)
 				delete(txNotifications, wsc.quit)
+				delete(watchedNotifications, wsc.quit)
+				delete(adminTxNotifications, wsc.quit)
+				delete(peerNotifications, wsc.quit)
 				for k := range wsc.spentRequests {
 					op := k
 					m.removeSpentRequest(watchedOutPoints, wsc, &op)
@@ -388,6 +466,30 @@ out:
 				wsc := (*wsClient)(n)
 				delete(txNotifications, wsc.quit)
 
+			case *notificationRegisterWatched:
+				wsc := (*wsClient)(n)
+				watchedNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterWatched:
+				wsc := (*wsClient)(n)
+				delete(watchedNotifications, wsc.quit)
+
+			case *notificationRegisterAdminTxs:
+				wsc := (*wsClient)(n)
+				adminTxNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterAdminTxs:
+				wsc := (*wsClient)(n)
+				delete(adminTxNotifications, wsc.quit)
+
+			case *notificationRegisterPeerEvents:
+				wsc := (*wsClient)(n)
+				peerNotifications[wsc.quit] = wsc
+
+			case *notificationUnregisterPeerEvents:
+				wsc := (*wsClient)(n)
+				delete(peerNotifications, wsc.quit)
+
 			default:
 				rpcsLog.Warn("Unhandled notification type")
 			}
@@ -793,6 +895,192 @@ func (m *wsNotificationManager) notifyForNewTx(clients map[chan struct{}]*wsClie
 	}
 }
 
+// RegisterWatchedUpdates requests notifications to the passed websocket client
+// when transactions involving the addresses and key IDs watched by the
+// watch-only index are accepted by the memory pool or connected to the main
+// chain.
+func (m *wsNotificationManager) RegisterWatchedUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterWatched)(wsc)
+}
+
+// UnregisterWatchedUpdates removes watched activity notifications for the
+// passed websocket client.
+func (m *wsNotificationManager) UnregisterWatchedUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterWatched)(wsc)
+}
+
+// notifyWatchedActivity notifies websocket clients that have registered for
+// watched activity updates of the passed activity.  The block is nil for
+// transactions accepted by the memory pool.
+func (*wsNotificationManager) notifyWatchedActivity(clients map[chan struct{}]*wsClient,
+	activity []indexers.WatchActivity, block *provautil.Block) {
+
+	for i := range activity {
+		a := &activity[i]
+		ntfn := btcjson.NewWatchedActivityNtfn(a.Target.String(),
+			a.TxHash.String(), provautil.Amount(a.Received).ToRMG(),
+			provautil.Amount(a.Sent).ToRMG(), blockDetails(block, a.TxIndex))
+		marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+		if err != nil {
+			rpcsLog.Errorf("Failed to marshal watched activity "+
+				"notification: %v", err)
+			return
+		}
+		for _, wsc := range clients {
+			wsc.QueueNotification(marshalledJSON)
+		}
+	}
+}
+
+// notifyWatchedBlock notifies websocket clients that have registered for
+// watched activity updates of the activity the watch-only index recorded for
+// the passed block connected to the main chain.
+func (m *wsNotificationManager) notifyWatchedBlock(clients map[chan struct{}]*wsClient,
+	block *provautil.Block) {
+
+	watchIndex := m.server.server.watchIndex
+	if !m.server.server.indexEnabled(watchIndex) {
+		return
+	}
+	activity, err := watchIndex.BlockActivity(block)
+	if err != nil {
+		rpcsLog.Errorf("Failed to load watched activity of block %v: %v",
+			block.Hash(), err)
+		return
+	}
+	m.notifyWatchedActivity(clients, activity, block)
+}
+
+// notifyWatchedTx notifies websocket clients that have registered for watched
+// activity updates when the passed transaction accepted by the memory pool
+// involves a watched address or key ID.
+func (m *wsNotificationManager) notifyWatchedTx(clients map[chan struct{}]*wsClient,
+	tx *provautil.Tx) {
+
+	watchIndex := m.server.server.watchIndex
+	if !m.server.server.indexEnabled(watchIndex) {
+		return
+	}
+	activity, err := watchIndex.TxActivity(tx)
+	if err != nil {
+		rpcsLog.Errorf("Failed to load watched activity of transaction "+
+			"%v: %v", tx.Hash(), err)
+		return
+	}
+	m.notifyWatchedActivity(clients, activity, nil)
+}
+
+// RegisterAdminTxUpdates requests notifications to the passed websocket client
+// when transactions on the root, provision or issue thread are accepted by the
+// memory pool or connected to the main chain.
+func (m *wsNotificationManager) RegisterAdminTxUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterAdminTxs)(wsc)
+}
+
+// UnregisterAdminTxUpdates removes admin transaction notifications for the
+// passed websocket client.
+func (m *wsNotificationManager) UnregisterAdminTxUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterAdminTxs)(wsc)
+}
+
+// notifyAdminTx notifies websocket clients that have registered for admin
+// transaction updates of the passed transaction along with the admin
+// operations it carries out, unless it is not an admin transaction.  The block
+// is nil for transactions accepted by the memory pool.
+func (*wsNotificationManager) notifyAdminTx(clients map[chan struct{}]*wsClient,
+	tx *provautil.Tx, block *provautil.Block, txIndex int) {
+
+	threadInt, _ := txscript.GetAdminDetails(tx)
+	if threadInt < 0 {
+		return
+	}
+
+	var height uint32
+	if block != nil {
+		height = block.Height()
+	}
+	ops := indexers.TxAdminOperations(tx, height)
+	results := make([]btcjson.AdminOperationResult, 0, len(ops))
+	for i := range ops {
+		results = append(results, adminOperationResult(&ops[i]))
+	}
+	ntfn := btcjson.NewAdminTransactionNtfn(tx.Hash().String(),
+		adminThreadNames[provautil.ThreadID(threadInt)], results,
+		blockDetails(block, txIndex))
+	marshalledJSON, err := btcjson.MarshalCmd(nil, ntfn)
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal admin transaction "+
+			"notification: %v", err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
+// notifyAdminBlock notifies websocket clients that have registered for admin
+// transaction updates of the admin transactions in the passed block connected
+// to the main chain.
+func (m *wsNotificationManager) notifyAdminBlock(clients map[chan struct{}]*wsClient,
+	block *provautil.Block) {
+
+	for txIndex, tx := range block.Transactions() {
+		m.notifyAdminTx(clients, tx, block, txIndex)
+	}
+}
+
+// notifyAdminAlert notifies websocket clients that have registered for admin
+// transaction updates of the passed admin alert.
+func (*wsNotificationManager) notifyAdminAlert(clients map[chan struct{}]*wsClient,
+	alert *mempool.AdminAlert) {
+
+	marshalledJSON, err := btcjson.MarshalCmd(nil, adminAlertNtfn(alert))
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal admin alert notification: %v",
+			err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
+// RegisterPeerEventUpdates requests notifications to the passed websocket
+// client when a peer connects, completes the version handshake, misbehaves or
+// disconnects.
+func (m *wsNotificationManager) RegisterPeerEventUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationRegisterPeerEvents)(wsc)
+}
+
+// UnregisterPeerEventUpdates removes peer event notifications for the passed
+// websocket client.
+func (m *wsNotificationManager) UnregisterPeerEventUpdates(wsc *wsClient) {
+	m.queueNotification <- (*notificationUnregisterPeerEvents)(wsc)
+}
+
+// peerEventNtfn returns the peerevent notification for the passed peer event.
+func peerEventNtfn(event *peerEvent) *btcjson.PeerEventNtfn {
+	return btcjson.NewPeerEventNtfn(peerEventNames[event.Type],
+		event.Time.Unix(), event.ID, event.Addr, event.Inbound,
+		event.BanScore, event.Reason)
+}
+
+// notifyPeerEvent notifies websocket clients that have registered for peer
+// event updates of the passed peer event.
+func (*wsNotificationManager) notifyPeerEvent(clients map[chan struct{}]*wsClient,
+	event *peerEvent) {
+
+	marshalledJSON, err := btcjson.MarshalCmd(nil, peerEventNtfn(event))
+	if err != nil {
+		rpcsLog.Errorf("Failed to marshal peer event notification: %v",
+			err)
+		return
+	}
+	for _, wsc := range clients {
+		wsc.QueueNotification(marshalledJSON)
+	}
+}
+
 // RegisterSpentRequests requests a notification when each of the passed
 // outpoints is confirmed spent (contained in a block connected to the main
 // chain) for the passed websocket client.  The request is automatically
@@ -1789,6 +2077,51 @@ func handleStopNotifyNewTransactions(wsc *wsClient, icmd interface{}) (interface
 	return nil, nil
 }
 
+// handleNotifyWatched implements the notifywatched command extension for
+// websocket connections.
+func handleNotifyWatched(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	if _, err := watchIndexRequired(wsc.server); err != nil {
+		return nil, err
+	}
+	wsc.server.ntfnMgr.RegisterWatchedUpdates(wsc)
+	return nil, nil
+}
+
+// handleStopNotifyWatched implements the stopnotifywatched command extension
+// for websocket connections.
+func handleStopNotifyWatched(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterWatchedUpdates(wsc)
+	return nil, nil
+}
+
+// handleNotifyAdminTransactions implements the notifyadmintransactions command
+// extension for websocket connections.
+func handleNotifyAdminTransactions(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.RegisterAdminTxUpdates(wsc)
+	return nil, nil
+}
+
+// handleStopNotifyAdminTransactions implements the stopnotifyadmintransactions
+// command extension for websocket connections.
+func handleStopNotifyAdminTransactions(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterAdminTxUpdates(wsc)
+	return nil, nil
+}
+
+// handleNotifyPeerEvents implements the notifypeerevents command extension for
+// websocket connections.
+func handleNotifyPeerEvents(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.RegisterPeerEventUpdates(wsc)
+	return nil, nil
+}
+
+// handleStopNotifyPeerEvents implements the stopnotifypeerevents command
+// extension for websocket connections.
+func handleStopNotifyPeerEvents(wsc *wsClient, icmd interface{}) (interface{}, error) {
+	wsc.server.ntfnMgr.UnregisterPeerEventUpdates(wsc)
+	return nil, nil
+}
+
 // handleNotifyReceived implements the notifyreceived command extension for
 // websocket connections.
 func handleNotifyReceived(wsc *wsClient, icmd interface{}) (interface{}, error) {
@@ -1904,6 +2237,19 @@ func (r *rescanKeys) unspentSlice() []*wire.OutPoint {
 	return ops
 }
 
+// unspentOutPoints returns the currently-unspent outpoints for the rescan
+// lookup keys in the form they are checkpointed in.
+func (r *rescanKeys) unspentOutPoints() []btcjson.OutPoint {
+	ops := make([]btcjson.OutPoint, 0, len(r.unspent))
+	for op := range r.unspent {
+		ops = append(ops, btcjson.OutPoint{
+			Hash:  op.Hash.String(),
+			Index: op.Index,
+		})
+	}
+	return ops
+}
+
 // ErrRescanReorg defines the error that is returned when an unrecoverable
 // reorganize is detected during a rescan.
 var ErrRescanReorg = btcjson.RPCError{
@@ -2130,42 +2476,6 @@ func handleRescanBlocks(wsc *wsClient, icmd interface{}) (interface{}, error) {
 	return &discoveredData, nil
 }
 
-// recoverFromReorg attempts to recover from a detected reorganize during a
-// rescan.  It fetches a new range of block shas from the database and
-// verifies that the new range of blocks is on the same fork as a previous
-// range of blocks.  If this condition does not hold true, the JSON-RPC error
-// for an unrecoverable reorganize is returned.
-func recoverFromReorg(chain *blockchain.BlockChain, minBlock, maxBlock uint32,
-	lastBlock *chainhash.Hash) ([]chainhash.Hash, error) {
-
-	hashList, err := chain.HeightRange(minBlock, maxBlock)
-	if err != nil {
-		rpcsLog.Errorf("Error looking up block range: %v", err)
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCDatabase,
-			Message: "Database error: " + err.Error(),
-		}
-	}
-	if lastBlock == nil || len(hashList) == 0 {
-		return hashList, nil
-	}
-
-	blk, err := chain.BlockByHash(&hashList[0])
-	if err != nil {
-		rpcsLog.Errorf("Error looking up possibly reorged block: %v",
-			err)
-		return nil, &btcjson.RPCError{
-			Code:    btcjson.ErrRPCDatabase,
-			Message: "Database error: " + err.Error(),
-		}
-	}
-	jsonErr := descendantBlock(lastBlock, blk)
-	if jsonErr != nil {
-		return nil, jsonErr
-	}
-	return hashList, nil
-}
-
 // descendantBlock returns the appropriate JSON-RPC error if a current block
 // fetched during a reorganize is not a direct child of the parent block hash.
 func descendantBlock(prevHash *chainhash.Hash, curBlock *provautil.Block) error {
@@ -2181,6 +2491,13 @@ func descendantBlock(prevHash *chainhash.Hash, curBlock *provautil.Block) error
 // handleRescan implements the rescan command extension for websocket
 // connections.
 //
+// Rescans are run by the rescan engine of the server, which processes blocks
+// in batches.  When a job ID is passed, the progress is checkpointed after
+// each batch, and issuing the rescan again with the same job ID after the
+// client disconnected or the node restarted resumes it from the last
+// checkpoint.  Notifications for the blocks after the last checkpoint are sent
+// again in that case.
+//
 // NOTE: This does not smartly handle reorgs, and fixing requires database
 // changes (for safe, concurrent access to full block ranges, and support
 // for other chains than the best chain).  It will, however, detect whether
@@ -2194,33 +2511,70 @@ func handleRescan(wsc *wsClient, icmd interface{}) (interface{}, error) {
 		return nil, btcjson.ErrRPCInternal
 	}
 
-	outpoints := make([]*wire.OutPoint, 0, len(cmd.OutPoints))
-	for i := range cmd.OutPoints {
-		cmdOutpoint := &cmd.OutPoints[i]
-		blockHash, err := chainhash.NewHashFromStr(cmdOutpoint.Hash)
+	engine := wsc.server.rescans
+	var jobID string
+	if cmd.JobID != nil {
+		jobID = *cmd.JobID
+	}
+
+	// Resume the job from its checkpoint if there is one, in which case
+	// the other parameters are ignored.
+	var job *rescanJob
+	if jobID != "" {
+		var err error
+		job, err = engine.resume(jobID)
 		if err != nil {
-			return nil, rpcDecodeHexError(cmdOutpoint.Hash)
+			return nil, err
+		}
+		if job != nil {
+			rpcsLog.Infof("Resuming rescan job %s at height %d", jobID,
+				job.checkpoint.NextHeight)
 		}
-		outpoint := wire.NewOutPoint(blockHash, cmdOutpoint.Index)
-		outpoints = append(outpoints, outpoint)
 	}
 
-	numAddrs := len(cmd.Addresses)
-	if numAddrs == 1 {
-		rpcsLog.Info("Beginning rescan for 1 address")
-	} else {
-		rpcsLog.Infof("Beginning rescan for %d addresses", numAddrs)
+	if job == nil {
+		cp, err := newRescanCheckpoint(wsc.server.chain, cmd)
+		if err != nil {
+			return nil, err
+		}
+		job, err = engine.start(jobID, cp)
+		if err != nil {
+			return nil, err
+		}
+
+		numAddrs := len(cmd.Addresses)
+		if numAddrs == 1 {
+			rpcsLog.Infof("Beginning rescan job %s for 1 address",
+				job.id)
+		} else {
+			rpcsLog.Infof("Beginning rescan job %s for %d addresses",
+				job.id, numAddrs)
+		}
 	}
 
-	// Build lookup maps.
-	lookups := rescanKeys{
-		fallbacks:           map[string]struct{}{},
-		pubKeyHashes:        map[[ripemd160.Size]byte]struct{}{},
-		scriptHashes:        map[[ripemd160.Size]byte]struct{}{},
-		compressedPubKeys:   map[[33]byte]struct{}{},
-		uncompressedPubKeys: map[[65]byte]struct{}{},
-		unspent:             map[wire.OutPoint]struct{}{},
+	// Interrupted jobs are kept so they are resumable, as are jobs which
+	// failed for any other reason than a reorganize, which invalidates the
+	// checkpoint.
+	interrupted, err := engine.run(wsc, job)
+	engine.end(job, interrupted || (err != nil && err != error(&ErrRescanReorg)))
+	if err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// newRescanCheckpoint returns the checkpoint a new rescan requested by the
+// passed command starts from.
+func newRescanCheckpoint(chain *blockchain.BlockChain,
+	cmd *btcjson.RescanCmd) (*rescanCheckpoint, error) {
+
+	for i := range cmd.OutPoints {
+		_, err := chainhash.NewHashFromStr(cmd.OutPoints[i].Hash)
+		if err != nil {
+			return nil, rpcDecodeHexError(cmd.OutPoints[i].Hash)
+		}
 	}
+
 	for _, addrStr := range cmd.Addresses {
 		_, err := provautil.DecodeAddress(addrStr, activeNetParams.Params)
 		if err != nil {
@@ -2231,17 +2585,8 @@ func handleRescan(wsc *wsClient, icmd interface{}) (interface{}, error) {
 			}
 			return nil, &jsonErr
 		}
-		// A new address type must have been added.  Use encoded
-		// payment address string as a fallback until a fast path
-		// is added.
-		lookups.fallbacks[addrStr] = struct{}{}
-	}
-	for _, outpoint := range outpoints {
-		lookups.unspent[*outpoint] = struct{}{}
 	}
 
-	chain := wsc.server.chain
-
 	minBlockHash, err := chainhash.NewHashFromStr(cmd.BeginBlock)
 	if err != nil {
 		return nil, rpcDecodeHexError(cmd.BeginBlock)
@@ -2254,219 +2599,29 @@ func handleRescan(wsc *wsClient, icmd interface{}) (interface{}, error) {
 		}
 	}
 
-	maxBlock := uint32(math.MaxUint32)
-	if cmd.EndBlock != nil {
+	cp := &rescanCheckpoint{
+		Addresses:   cmd.Addresses,
+		OutPoints:   cmd.OutPoints,
+		StartHeight: minBlock,
+		NextHeight:  minBlock,
+		FollowTip:   true,
+	}
+	if cmd.EndBlock != nil && *cmd.EndBlock != "" {
 		maxBlockHash, err := chainhash.NewHashFromStr(*cmd.EndBlock)
 		if err != nil {
 			return nil, rpcDecodeHexError(*cmd.EndBlock)
 		}
-		maxBlock, err = chain.BlockHeightByHash(maxBlockHash)
+		maxBlock, err := chain.BlockHeightByHash(maxBlockHash)
 		if err != nil {
 			return nil, &btcjson.RPCError{
 				Code:    btcjson.ErrRPCBlockNotFound,
 				Message: "Error getting block: " + err.Error(),
 			}
 		}
+		cp.EndHeight = maxBlock
+		cp.FollowTip = false
 	}
-
-	// lastBlock and lastBlockHash track the previously-rescanned block.
-	// They equal nil when no previous blocks have been rescanned.
-	var lastBlock *provautil.Block
-	var lastBlockHash *chainhash.Hash
-
-	// A ticker is created to wait at least 10 seconds before notifying the
-	// websocket client of the current progress completed by the rescan.
-	ticker := time.NewTicker(10 * time.Second)
-	defer ticker.Stop()
-
-	// Instead of fetching all block shas at once, fetch in smaller chunks
-	// to ensure large rescans consume a limited amount of memory.
-fetchRange:
-	for minBlock < maxBlock {
-		// Limit the max number of hashes to fetch at once to the
-		// maximum number of items allowed in a single inventory.
-		// This value could be higher since it's not creating inventory
-		// messages, but this mirrors the limiting logic used in the
-		// peer-to-peer protocol.
-		maxLoopBlock := maxBlock
-		if maxLoopBlock-minBlock > wire.MaxInvPerMsg {
-			maxLoopBlock = minBlock + wire.MaxInvPerMsg
-		}
-		hashList, err := chain.HeightRange(minBlock, maxLoopBlock)
-		if err != nil {
-			rpcsLog.Errorf("Error looking up block range: %v", err)
-			return nil, &btcjson.RPCError{
-				Code:    btcjson.ErrRPCDatabase,
-				Message: "Database error: " + err.Error(),
-			}
-		}
-		if len(hashList) == 0 {
-			// The rescan is finished if no blocks hashes for this
-			// range were successfully fetched and a stop block
-			// was provided.
-			if maxBlock != math.MaxInt32 {
-				break
-			}
-
-			// If the rescan is through the current block, set up
-			// the client to continue to receive notifications
-			// regarding all rescanned addresses and the current set
-			// of unspent outputs.
-			//
-			// This is done safely by temporarily grabbing exclusive
-			// access of the block manager.  If no more blocks have
-			// been attached between this pause and the fetch above,
-			// then it is safe to register the websocket client for
-			// continuous notifications if necessary.  Otherwise,
-			// continue the fetch loop again to rescan the new
-			// blocks (or error due to an irrecoverable reorganize).
-			blockManager := wsc.server.server.blockManager
-			pauseGuard := blockManager.Pause()
-			best := blockManager.chain.BestSnapshot()
-			curHash := best.Hash
-			again := true
-			if lastBlockHash == nil || *lastBlockHash == *curHash {
-				again = false
-				n := wsc.server.ntfnMgr
-				n.RegisterSpentRequests(wsc, lookups.unspentSlice())
-				n.RegisterTxOutAddressRequests(wsc, cmd.Addresses)
-			}
-			close(pauseGuard)
-			if err != nil {
-				rpcsLog.Errorf("Error fetching best block "+
-					"hash: %v", err)
-				return nil, &btcjson.RPCError{
-					Code: btcjson.ErrRPCDatabase,
-					Message: "Database error: " +
-						err.Error(),
-				}
-			}
-			if again {
-				continue
-			}
-			break
-		}
-
-	loopHashList:
-		for i := range hashList {
-			blk, err := chain.BlockByHash(&hashList[i])
-			if err != nil {
-				// Only handle reorgs if a block could not be
-				// found for the hash.
-				if dbErr, ok := err.(database.Error); !ok ||
-					dbErr.ErrorCode != database.ErrBlockNotFound {
-
-					rpcsLog.Errorf("Error looking up "+
-						"block: %v", err)
-					return nil, &btcjson.RPCError{
-						Code: btcjson.ErrRPCDatabase,
-						Message: "Database error: " +
-							err.Error(),
-					}
-				}
-
-				// If an absolute max block was specified, don't
-				// attempt to handle the reorg.
-				if maxBlock != math.MaxInt32 {
-					rpcsLog.Errorf("Stopping rescan for "+
-						"reorged block %v",
-						cmd.EndBlock)
-					return nil, &ErrRescanReorg
-				}
-
-				// If the lookup for the previously valid block
-				// hash failed, there may have been a reorg.
-				// Fetch a new range of block hashes and verify
-				// that the previously processed block (if there
-				// was any) still exists in the database.  If it
-				// doesn't, we error.
-				//
-				// A goto is used to branch executation back to
-				// before the range was evaluated, as it must be
-				// reevaluated for the new hashList.
-				minBlock += uint32(i)
-				hashList, err = recoverFromReorg(chain,
-					minBlock, maxBlock, lastBlockHash)
-				if err != nil {
-					return nil, err
-				}
-				if len(hashList) == 0 {
-					break fetchRange
-				}
-				goto loopHashList
-			}
-			if i == 0 && lastBlockHash != nil {
-				// Ensure the new hashList is on the same fork
-				// as the last block from the old hashList.
-				jsonErr := descendantBlock(lastBlockHash, blk)
-				if jsonErr != nil {
-					return nil, jsonErr
-				}
-			}
-
-			// A select statement is used to stop rescans if the
-			// client requesting the rescan has disconnected.
-			select {
-			case <-wsc.quit:
-				rpcsLog.Debugf("Stopped rescan at height %v "+
-					"for disconnected client", blk.Height())
-				return nil, nil
-			default:
-				rescanBlock(wsc, &lookups, blk)
-				lastBlock = blk
-				lastBlockHash = blk.Hash()
-			}
-
-			// Periodically notify the client of the progress
-			// completed.  Continue with next block if no progress
-			// notification is needed yet.
-			select {
-			case <-ticker.C: // fallthrough
-			default:
-				continue
-			}
-
-			n := btcjson.NewRescanProgressNtfn(hashList[i].String(),
-				int32(blk.Height()), blk.MsgBlock().Header.Timestamp.Unix())
-			mn, err := btcjson.MarshalCmd(nil, n)
-			if err != nil {
-				rpcsLog.Errorf("Failed to marshal rescan "+
-					"progress notification: %v", err)
-				continue
-			}
-
-			if err = wsc.QueueNotification(mn); err == ErrClientQuit {
-				// Finished if the client disconnected.
-				rpcsLog.Debugf("Stopped rescan at height %v "+
-					"for disconnected client", blk.Height())
-				return nil, nil
-			}
-		}
-
-		minBlock += uint32(len(hashList))
-	}
-
-	// Notify websocket client of the finished rescan.  Due to how btcd
-	// asynchronously queues notifications to not block calling code,
-	// there is no guarantee that any of the notifications created during
-	// rescan (such as rescanprogress, recvtx and redeemingtx) will be
-	// received before the rescan RPC returns.  Therefore, another method
-	// is needed to safely inform clients that all rescan notifications have
-	// been sent.
-	n := btcjson.NewRescanFinishedNtfn(lastBlockHash.String(),
-		int32(lastBlock.Height()),
-		lastBlock.MsgBlock().Header.Timestamp.Unix())
-	if mn, err := btcjson.MarshalCmd(nil, n); err != nil {
-		rpcsLog.Errorf("Failed to marshal rescan finished "+
-			"notification: %v", err)
-	} else {
-		// The rescan is finished, so we don't care whether the client
-		// has disconnected at this point, so discard error.
-		_ = wsc.QueueNotification(mn)
-	}
-
-	rpcsLog.Info("Finished rescan")
-	return nil, nil
+	return cp, nil
 }
 
 func init() {
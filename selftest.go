@@ -0,0 +1,402 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"text/tabwriter"
+	"time"
+
+	"github.com/bitgo/prova/blockchain"
+	"github.com/bitgo/prova/blockchain/fullblocktests"
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/txscript"
+	"github.com/bitgo/prova/wire"
+)
+
+const (
+	// selfTestBenchTime is how long each of the timed benchmarks of the
+	// self-test runs for.
+	selfTestBenchTime = time.Second
+
+	// selfTestDBKeys is the number of keys written and read back by the
+	// database benchmark, selfTestDBBatch the number of them written by
+	// each database transaction, and selfTestDBValueLength the length of
+	// their values.
+	selfTestDBKeys        = 100000
+	selfTestDBBatch       = 5000
+	selfTestDBValueLength = 100
+
+	// These are the minimums a machine needs to meet to be considered
+	// suitable for running a node on the main network.
+	selfTestMinCPUs      = 2
+	selfTestMinHashRate  = 100   // MB/s of double SHA-256
+	selfTestMinSigRate   = 2000  // signature verifications per second
+	selfTestMinWriteRate = 20000 // database writes per second
+	selfTestMinReadRate  = 50000 // database reads per second
+)
+
+// selfTestNetworks are the networks whose genesis blocks are verified by the
+// self-test.
+var selfTestNetworks = []*chaincfg.Params{
+	&chaincfg.MainNetParams,
+	&chaincfg.TestNetParams,
+	&chaincfg.RegressionNetParams,
+	&chaincfg.SimNetParams,
+}
+
+// benchmarkHashing returns the rate in megabytes per second at which blocks of
+// data are double SHA-256 hashed.
+func benchmarkHashing(duration time.Duration) float64 {
+	data := make([]byte, 1<<20)
+	var hashed int
+	start := time.Now()
+	for time.Since(start) < duration {
+		chainhash.DoubleHashB(data)
+		hashed += len(data)
+	}
+	return float64(hashed) / (1 << 20) / time.Since(start).Seconds()
+}
+
+// benchmarkSignatures returns the number of ECDSA signatures verified per
+// second.
+func benchmarkSignatures(duration time.Duration) (float64, error) {
+	privKey, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		return 0, err
+	}
+	hash := chainhash.DoubleHashB([]byte("prova self-test"))
+	sig, err := privKey.Sign(hash)
+	if err != nil {
+		return 0, err
+	}
+	pubKey := privKey.PubKey()
+
+	var verified int
+	start := time.Now()
+	for time.Since(start) < duration {
+		if !sig.Verify(hash, pubKey) {
+			return 0, errors.New("signature failed to verify")
+		}
+		verified++
+	}
+	return float64(verified) / time.Since(start).Seconds(), nil
+}
+
+// createSelfTestDB creates a database of the passed type named by the passed
+// name in the passed directory.
+func createSelfTestDB(dbType, dir, name string) (database.DB, error) {
+	return database.Create(dbType, filepath.Join(dir, name), wire.SimNet)
+}
+
+// benchmarkDatabase returns the number of keys written, including committing
+// them, and read back per second with a new database of the passed type in
+// the passed directory.
+func benchmarkDatabase(dbType, dir string) (writeRate, readRate float64, err error) {
+	db, err := createSelfTestDB(dbType, dir, "bench")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer db.Close()
+
+	bucketName := []byte("selftest")
+	key := make([]byte, 4)
+	value := bytes.Repeat([]byte{0xa5}, selfTestDBValueLength)
+
+	start := time.Now()
+	for batch := 0; batch < selfTestDBKeys; batch += selfTestDBBatch {
+		err := db.Update(func(dbTx database.Tx) error {
+			bucket, err := dbTx.Metadata().CreateBucketIfNotExists(
+				bucketName)
+			if err != nil {
+				return err
+			}
+			for i := batch; i < batch+selfTestDBBatch; i++ {
+				binary.BigEndian.PutUint32(key, uint32(i))
+				if err := bucket.Put(key, value); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	writeRate = selfTestDBKeys / time.Since(start).Seconds()
+
+	start = time.Now()
+	err = db.View(func(dbTx database.Tx) error {
+		bucket := dbTx.Metadata().Bucket(bucketName)
+		for i := 0; i < selfTestDBKeys; i++ {
+			binary.BigEndian.PutUint32(key, uint32(i))
+			if len(bucket.Get(key)) != selfTestDBValueLength {
+				return fmt.Errorf("key %d was not read back", i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	readRate = selfTestDBKeys / time.Since(start).Seconds()
+	return writeRate, readRate, nil
+}
+
+// verifyGenesisBlocks ensures the genesis block of every network hashes to the
+// hash of its parameters and commits to its transactions.  Genesis blocks are
+// exempt from the regular consensus checks, so those are not run.
+func verifyGenesisBlocks() error {
+	for _, params := range selfTestNetworks {
+		block := provautil.NewBlock(params.GenesisBlock)
+		if !block.Hash().IsEqual(params.GenesisHash) {
+			return fmt.Errorf("%s genesis block hashes to %v "+
+				"instead of %v", params.Name, block.Hash(),
+				params.GenesisHash)
+		}
+		merkles := blockchain.BuildMerkleTreeStore(block.Transactions())
+		merkleRoot := merkles[len(merkles)-1]
+		header := &block.MsgBlock().Header
+		if !header.MerkleRoot.IsEqual(merkleRoot) {
+			return fmt.Errorf("%s genesis block has merkle root "+
+				"%v instead of %v", params.Name,
+				header.MerkleRoot, merkleRoot)
+		}
+	}
+	return nil
+}
+
+// verifyKnownBlocks processes the bundle of known valid and invalid blocks of
+// the fullblocktests package with a new chain in a new database of the passed
+// type in the passed directory, and ensures each of them is accepted or
+// rejected as expected.  It returns the number of blocks which were verified.
+func verifyKnownBlocks(dbType, dir string) (int, error) {
+	tests, err := fullblocktests.Generate(false)
+	if err != nil {
+		return 0, fmt.Errorf("unable to generate blocks: %v", err)
+	}
+
+	db, err := createSelfTestDB(dbType, dir, "consensus")
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	// Copy the chain params to ensure processing the blocks does not
+	// affect the global instance.
+	params := chaincfg.RegressionNetParams
+	chain, err := blockchain.New(&blockchain.Config{
+		DB:          db,
+		ChainParams: &params,
+		TimeSource:  blockchain.NewMedianTime(),
+		SigCache:    txscript.NewSigCache(1000),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var verified int
+	for _, test := range tests {
+		for _, item := range test {
+			if err := verifyKnownBlock(chain, item); err != nil {
+				return verified, err
+			}
+			verified++
+		}
+	}
+	return verified, nil
+}
+
+// verifyKnownBlock ensures the passed test instance of the fullblocktests
+// package has the expected result when processed by the passed chain.
+func verifyKnownBlock(chain *blockchain.BlockChain, item fullblocktests.TestInstance) error {
+	switch item := item.(type) {
+	case fullblocktests.AcceptedBlock:
+		block := provautil.NewBlock(item.Block)
+		block.SetHeight(item.Height)
+		isMainChain, isOrphan, err := chain.ProcessBlock(block,
+			blockchain.BFNone)
+		if err != nil {
+			return fmt.Errorf("block %q was rejected: %v",
+				item.Name, err)
+		}
+		if isMainChain != item.IsMainChain || isOrphan != item.IsOrphan {
+			return fmt.Errorf("block %q was accepted with main "+
+				"chain %v and orphan %v, want %v and %v",
+				item.Name, isMainChain, isOrphan,
+				item.IsMainChain, item.IsOrphan)
+		}
+
+	case fullblocktests.RejectedBlock:
+		block := provautil.NewBlock(item.Block)
+		block.SetHeight(item.Height)
+		_, _, err := chain.ProcessBlock(block, blockchain.BFNone)
+		if err == nil {
+			return fmt.Errorf("block %q was accepted", item.Name)
+		}
+		rerr, ok := err.(blockchain.RuleError)
+		if !ok || rerr.ErrorCode != item.RejectCode {
+			return fmt.Errorf("block %q was rejected with %v, want "+
+				"%v", item.Name, err, item.RejectCode)
+		}
+
+	case fullblocktests.RejectedNonCanonicalBlock:
+		var msgBlock wire.MsgBlock
+		err := msgBlock.BtcDecode(bytes.NewReader(item.RawBlock), 0)
+		if _, ok := err.(*wire.MessageError); !ok {
+			return fmt.Errorf("block %q was decoded", item.Name)
+		}
+
+	case fullblocktests.OrphanOrRejectedBlock:
+		block := provautil.NewBlock(item.Block)
+		block.SetHeight(item.Height)
+		_, isOrphan, err := chain.ProcessBlock(block, blockchain.BFNone)
+		if err != nil {
+			if _, ok := err.(blockchain.RuleError); !ok {
+				return fmt.Errorf("block %q failed to process: "+
+					"%v", item.Name, err)
+			}
+		}
+		if !isOrphan {
+			return fmt.Errorf("block %q was accepted, but is not "+
+				"an orphan", item.Name)
+		}
+
+	case fullblocktests.ExpectedTip:
+		best := chain.BestSnapshot()
+		if *best.Hash != item.Block.BlockHash() ||
+			best.Height != item.Height {
+
+			return fmt.Errorf("block %q is not the tip -- got "+
+				"%v (height %d)", item.Name, best.Hash,
+				best.Height)
+		}
+
+	default:
+		return fmt.Errorf("unknown test instance type %T", item)
+	}
+	return nil
+}
+
+// selfTestCheck houses the measured value of a hardware check of the self-test
+// and the minimum it needs to meet.
+type selfTestCheck struct {
+	name    string
+	unit    string
+	value   float64
+	minimum float64
+}
+
+// ok returns whether or not the measured value meets the minimum.
+func (c *selfTestCheck) ok() bool {
+	return c.value >= c.minimum
+}
+
+// writeSelfTestReport writes the hardware suitability report of the passed
+// number of verified known blocks, consensus errors, and hardware checks to the
+// passed writer.  It returns whether or not the machine is suitable.
+func writeSelfTestReport(w io.Writer, verified int, consensusErrs []error, checks []selfTestCheck) bool {
+	suitable := true
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "Consensus\t\t\t")
+	if len(consensusErrs) == 0 {
+		fmt.Fprintf(tw, "  genesis blocks\t%d networks\t\tok\n",
+			len(selfTestNetworks))
+		fmt.Fprintf(tw, "  known blocks and transactions\t%d "+
+			"blocks\t\tok\n", verified)
+	}
+	for _, err := range consensusErrs {
+		suitable = false
+		fmt.Fprintf(tw, "  FAILED\t%v\t\t\n", err)
+	}
+
+	fmt.Fprintln(tw, "\t\t\t")
+	fmt.Fprintln(tw, "Hardware\tmeasured\tminimum\t")
+	for _, check := range checks {
+		status := "ok"
+		if !check.ok() {
+			status = "BELOW MINIMUM"
+			suitable = false
+		}
+		fmt.Fprintf(tw, "  %s\t%.0f %s\t%.0f %s\t%s\n", check.name,
+			check.value, check.unit, check.minimum, check.unit,
+			status)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	if suitable {
+		fmt.Fprintln(w, "Result: this machine is suitable for running "+
+			"a Prova node")
+	} else {
+		fmt.Fprintln(w, "Result: this machine is NOT suitable for "+
+			"running a Prova node")
+	}
+	return suitable
+}
+
+// runSelfTest runs the benchmarks and consensus checks of the self-test with
+// databases of the passed type in a temporary directory in the passed data
+// directory, so the disk the node stores its data on is measured, and writes
+// the report to the passed writer.  An error is returned when the machine is
+// not suitable for running a node.
+func runSelfTest(w io.Writer, dbType, dataDir string) error {
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir(dataDir, "selftest")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	fmt.Fprintf(w, "Prova %s self-test on %s/%s\n\n", version(),
+		runtime.GOOS, runtime.GOARCH)
+
+	var consensusErrs []error
+	if err := verifyGenesisBlocks(); err != nil {
+		consensusErrs = append(consensusErrs, err)
+	}
+	verified, err := verifyKnownBlocks(dbType, dir)
+	if err != nil {
+		consensusErrs = append(consensusErrs, err)
+	}
+
+	sigRate, err := benchmarkSignatures(selfTestBenchTime)
+	if err != nil {
+		consensusErrs = append(consensusErrs, err)
+	}
+	writeRate, readRate, err := benchmarkDatabase(dbType, dir)
+	if err != nil {
+		return fmt.Errorf("unable to benchmark the %s database: %v",
+			dbType, err)
+	}
+	checks := []selfTestCheck{
+		{"CPUs", "", float64(runtime.NumCPU()), selfTestMinCPUs},
+		{"double SHA-256", "MB/s", benchmarkHashing(selfTestBenchTime),
+			selfTestMinHashRate},
+		{"signature verification", "/s", sigRate, selfTestMinSigRate},
+		{dbType + " writes", "/s", writeRate, selfTestMinWriteRate},
+		{dbType + " reads", "/s", readRate, selfTestMinReadRate},
+	}
+
+	if !writeSelfTestReport(w, verified, consensusErrs, checks) {
+		return errors.New("self-test failed")
+	}
+	return nil
+}
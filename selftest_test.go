@@ -0,0 +1,110 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSelfTestConsensus ensures the genesis blocks and the bundle of known
+// blocks pass the consensus checks of the self-test.
+func TestSelfTestConsensus(t *testing.T) {
+	if err := verifyGenesisBlocks(); err != nil {
+		t.Fatalf("verifyGenesisBlocks: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "selftest")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	verified, err := verifyKnownBlocks("ffldb", dir)
+	if err != nil {
+		t.Fatalf("verifyKnownBlocks: %v", err)
+	}
+	if verified == 0 {
+		t.Fatal("verifyKnownBlocks: no blocks were verified")
+	}
+}
+
+// TestSelfTestBenchmarks ensures the benchmarks of the self-test measure
+// positive rates.
+func TestSelfTestBenchmarks(t *testing.T) {
+	if rate := benchmarkHashing(10 * time.Millisecond); rate <= 0 {
+		t.Errorf("benchmarkHashing: got rate %v", rate)
+	}
+	rate, err := benchmarkSignatures(10 * time.Millisecond)
+	if err != nil || rate <= 0 {
+		t.Errorf("benchmarkSignatures: got rate %v, err %v", rate, err)
+	}
+	dir, err := ioutil.TempDir("", "selftest")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	writeRate, readRate, err := benchmarkDatabase("ffldb", dir)
+	if err != nil || writeRate <= 0 || readRate <= 0 {
+		t.Errorf("benchmarkDatabase: got rates %v and %v, err %v",
+			writeRate, readRate, err)
+	}
+}
+
+// TestSelfTestReport ensures the report only declares a machine suitable when
+// the consensus checks pass and every hardware check meets its minimum.
+func TestSelfTestReport(t *testing.T) {
+	passing := []selfTestCheck{
+		{name: "CPUs", value: 4, minimum: 2},
+		{name: "double SHA-256", unit: "MB/s", value: 150, minimum: 100},
+	}
+	failing := []selfTestCheck{
+		{name: "CPUs", value: 1, minimum: 2},
+	}
+
+	tests := []struct {
+		name          string
+		consensusErrs []error
+		checks        []selfTestCheck
+		suitable      bool
+		contains      string
+	}{
+		{
+			name:     "suitable",
+			checks:   passing,
+			suitable: true,
+			contains: "is suitable",
+		},
+		{
+			name:     "below minimum",
+			checks:   failing,
+			contains: "BELOW MINIMUM",
+		},
+		{
+			name:          "consensus failure",
+			consensusErrs: []error{errors.New("block \"b1\" was rejected")},
+			checks:        passing,
+			contains:      "was rejected",
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		suitable := writeSelfTestReport(&buf, 10, test.consensusErrs,
+			test.checks)
+		if suitable != test.suitable {
+			t.Errorf("%s: got suitable %v, want %v", test.name,
+				suitable, test.suitable)
+		}
+		if !strings.Contains(buf.String(), test.contains) {
+			t.Errorf("%s: report does not contain %q:\n%s",
+				test.name, test.contains, buf.String())
+		}
+	}
+}
@@ -14,6 +14,9 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"net/http"
+	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -24,10 +27,13 @@ import (
 	"github.com/bitgo/prova/addrmgr"
 	"github.com/bitgo/prova/blockchain"
 	"github.com/bitgo/prova/blockchain/indexers"
+	"github.com/bitgo/prova/blockchain/indexers/streamsink"
 	"github.com/bitgo/prova/chaincfg"
 	"github.com/bitgo/prova/chaincfg/chainhash"
 	"github.com/bitgo/prova/connmgr"
 	"github.com/bitgo/prova/database"
+	"github.com/bitgo/prova/dnsseed"
+	"github.com/bitgo/prova/grpcapi"
 	"github.com/bitgo/prova/mempool"
 	"github.com/bitgo/prova/mining"
 	"github.com/bitgo/prova/mining/cpuminer"
@@ -36,6 +42,7 @@ import (
 	"github.com/bitgo/prova/provautil/bloom"
 	"github.com/bitgo/prova/txscript"
 	"github.com/bitgo/prova/wire"
+	"github.com/bitgo/prova/zmqpub"
 )
 
 const (
@@ -50,10 +57,26 @@ const (
 	// defaultTargetOutbound is the default number of outbound peers to target.
 	defaultTargetOutbound = 8
 
+	// defaultTargetBlockRelayOnly is the default number of block relay only
+	// outbound peers to target in addition to the outbound peers.  Block
+	// relay only peers do not relay transactions and addresses, which
+	// makes them harder to discover for attackers trying to partition the
+	// node from the network.
+	defaultTargetBlockRelayOnly = 2
+
 	// connectionRetryInterval is the base amount of time to wait in between
 	// retries when connecting to persistent peers.  It is adjusted by the
 	// number of retries such that there is a retry backoff.
 	connectionRetryInterval = time.Second * 5
+
+	// mempoolFileName is the name of the file in the data directory the
+	// transactions in the memory pool are saved to when --persistmempool
+	// is set.
+	mempoolFileName = "mempool.dat"
+
+	// banListFileName is the name of the file in the data directory the
+	// banned subnets are saved to.
+	banListFileName = "banlist.json"
 )
 
 var (
@@ -123,12 +146,11 @@ type updatePeerHeightsMsg struct {
 }
 
 // peerState maintains state of inbound, persistent, outbound peers as well
-// as banned peers and outbound groups.
+// as outbound groups.
 type peerState struct {
 	inboundPeers    map[int32]*serverPeer
 	outboundPeers   map[int32]*serverPeer
 	persistentPeers map[int32]*serverPeer
-	banned          map[string]time.Time
 	outboundGroups  map[string]int
 }
 
@@ -172,11 +194,14 @@ type server struct {
 	chainParams          *chaincfg.Params
 	addrManager          *addrmgr.AddrManager
 	connManager          *connmgr.ConnManager
+	banManager           *connmgr.BanManager
+	p2pTLS               *p2pTLS
 	sigCache             *txscript.SigCache
 	hashCache            *txscript.HashCache
 	rpcServer            *rpcServer
 	blockManager         *blockManager
 	txMemPool            *mempool.TxPool
+	feeEstimator         *mempool.FeeEstimator
 	cpuMiner             *cpuminer.CPUMiner
 	modifyRebroadcastInv chan interface{}
 	newPeers             chan *serverPeer
@@ -192,26 +217,82 @@ type server struct {
 	db                   database.DB
 	timeSource           blockchain.MedianTimeSource
 	services             wire.ServiceFlag
+	peerEvents           *peerEventHooks
+	peerEventCounters    *peerEventCounters
+	healthListener       net.Listener
+	autoProfiler         *autoProfiler
+	blockScrubber        *blockScrubber
+	zmqNotifier          *zmqpub.Notifier
+	grpcServer           *grpcapi.Server
+	dnsSeeder            *dnsseed.Server
+	crawler              *dnsseed.Crawler
+	sendLimiter          *peer.RateLimiter
+	recvLimiter          *peer.RateLimiter
+
+	// anchors are the addresses of the block relay only peers saved on the
+	// last shutdown, which are reconnected to on startup.
+	anchors []net.Addr
+
+	// The bytes received from and sent to all peers since start per
+	// message command are protected by the netTotalsMtx mutex.
+	netTotalsMtx    sync.Mutex
+	bytesRecvPerMsg map[string]uint64
+	bytesSentPerMsg map[string]uint64
 
 	// The following fields are used for optional indexes.  They will be nil
 	// if the associated index is not enabled.  These fields are set during
 	// initial creation of the server and never changed afterwards, so they
 	// do not need to be protected for concurrent access.
-	txIndex   *indexers.TxIndex
-	addrIndex *indexers.AddrIndex
+	txIndex          *indexers.TxIndex
+	addrIndex        *indexers.AddrIndex
+	addrValueIndex   *indexers.AddrValueIndex
+	spentIndex       *indexers.SpentIndex
+	timeIndex        *indexers.TimeIndex
+	keyIDIndex       *indexers.KeyIDIndex
+	adminOpIndex     *indexers.AdminOpIndex
+	issuanceIndex    *indexers.IssuanceIndex
+	cfIndex          *indexers.CfIndex
+	addrBalanceIndex *indexers.AddrBalanceIndex
+	streamIndex      *indexers.StreamIndex
+	scriptUtxoIndex  *indexers.ScriptUtxoIndex
+	feeStatsIndex    *indexers.FeeStatsIndex
+	merkleIndex      *indexers.MerkleIndex
+	watchIndex       *indexers.WatchIndex
+
+	// streamSink is the external system the events of the stream index
+	// are delivered to.  It is nil when the stream index is not enabled.
+	streamSink indexers.StreamSink
+
+	// indexManager manages the optional indexes above.  It is nil when
+	// none of them are enabled.
+	indexManager *indexers.Manager
+}
+
+// indexEnabled returns whether or not the passed optional index is enabled.
+// Indexes which have been dropped at runtime are not considered enabled.
+func (s *server) indexEnabled(indexer indexers.Indexer) bool {
+	if s.indexManager == nil {
+		return false
+	}
+	return s.indexManager.IndexEnabled(indexer)
 }
 
 // serverPeer extends the peer to maintain state shared by the server and
 // the blockmanager.
 type serverPeer struct {
 	// The following variables must only be used atomically
-	feeFilter int64
+	feeFilter    int64
+	lastNewBlock int64 // Unix nanoseconds, 0 if none.
 
 	*peer.Peer
 
 	connReq         *connmgr.ConnReq
 	server          *server
 	persistent      bool
+	blockRelayOnly  bool
+	isWhitelisted   bool
+	encrypted       bool
+	addrV2          *wire.NetAddressV2 // address of outbound peers
 	continueHash    *chainhash.Hash
 	relayMtx        sync.Mutex
 	disableRelayTx  bool
@@ -222,6 +303,8 @@ type serverPeer struct {
 	filter          *bloom.Filter
 	knownAddresses  map[string]struct{}
 	banScore        connmgr.DynamicBanScore
+	reasonMtx       sync.Mutex
+	disconnectRsn   string
 	quit            chan struct{}
 	// The following chans are used to sync blockmanager and server.
 	txProcessed    chan struct{}
@@ -251,6 +334,69 @@ func (sp *serverPeer) newestBlock() (*chainhash.Hash, uint32, error) {
 	return best.Hash, best.Height, nil
 }
 
+// hostToNetAddress returns the netaddress of the given host for the peer
+// package and records the address of any network the host has as the address
+// of the outbound peer.  The version message is unable to carry the addresses
+// of networks which do not fit in a wire.NetAddress, such as version 3 onion
+// addresses, so an unspecified IPv6 address is returned for those.
+func (sp *serverPeer) hostToNetAddress(host string, port uint16,
+	services wire.ServiceFlag) (*wire.NetAddress, error) {
+
+	na, err := sp.server.addrManager.HostToNetAddressV2(host, port, services)
+	if err != nil {
+		return nil, err
+	}
+	sp.addrV2 = na
+	if legacy := na.ToLegacy(); legacy != nil {
+		return legacy, nil
+	}
+	ip := na.IP()
+	if ip == nil {
+		ip = net.IPv6unspecified
+	}
+	return wire.NewNetAddressIPPort(ip, port, services), nil
+}
+
+// naV2 returns the address of the peer, which is the address of any network
+// the address manager knows outbound peers by.
+func (sp *serverPeer) naV2() *wire.NetAddressV2 {
+	if sp.addrV2 != nil {
+		return sp.addrV2
+	}
+	return wire.NewNetAddressV2FromLegacy(sp.NA())
+}
+
+// recordNewBlock records the peer provided a new block, which protects it from
+// eviction.
+// It is safe for concurrent access.
+func (sp *serverPeer) recordNewBlock() {
+	atomic.StoreInt64(&sp.lastNewBlock, time.Now().UnixNano())
+}
+
+// lastNewBlockTime returns the time the peer last provided a new block, or the
+// zero time when it did not provide any.
+// It is safe for concurrent access.
+func (sp *serverPeer) lastNewBlockTime() time.Time {
+	nanos := atomic.LoadInt64(&sp.lastNewBlock)
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// evictionCandidate returns the details of the peer the eviction policy
+// selects the peer to evict from.
+func (sp *serverPeer) evictionCandidate() *evictionCandidate {
+	return &evictionCandidate{
+		id:            sp.ID(),
+		whitelisted:   sp.isWhitelisted,
+		connected:     sp.TimeConnected(),
+		minPingMicros: sp.MinPingMicros(),
+		lastNewBlock:  sp.lastNewBlockTime(),
+		group:         addrmgr.GroupKeyV2(sp.naV2()),
+	}
+}
+
 // addKnownAddresses adds the given addresses to the set of known addresses to
 // the peer to prevent sending duplicate addresses.
 func (sp *serverPeer) addKnownAddresses(addresses []*wire.NetAddress) {
@@ -265,6 +411,22 @@ func (sp *serverPeer) addressKnown(na *wire.NetAddress) bool {
 	return exists
 }
 
+// addKnownAddressesV2 is like addKnownAddresses for the addresses of any
+// network.  The keys of addresses which fit in a wire.NetAddress are the same
+// for both, so the addresses are known regardless of the message they were
+// sent in.
+func (sp *serverPeer) addKnownAddressesV2(addresses []*wire.NetAddressV2) {
+	for _, na := range addresses {
+		sp.knownAddresses[addrmgr.NetAddressV2Key(na)] = struct{}{}
+	}
+}
+
+// addressKnownV2 is like addressKnown for the addresses of any network.
+func (sp *serverPeer) addressKnownV2(na *wire.NetAddressV2) bool {
+	_, exists := sp.knownAddresses[addrmgr.NetAddressV2Key(na)]
+	return exists
+}
+
 // setDisableRelayTx toggles relaying of transactions for the given peer.
 // It is safe for concurrent access.
 func (sp *serverPeer) setDisableRelayTx(disable bool) {
@@ -284,6 +446,34 @@ func (sp *serverPeer) relayTxDisabled() bool {
 	return isDisabled
 }
 
+// disconnectWithReason records the reason the peer is being disconnected,
+// which is reported to peer event subscribers, and then disconnects the peer.
+// Only the first recorded reason is kept.
+// It is safe for concurrent access.
+func (sp *serverPeer) disconnectWithReason(reason string) {
+	sp.reasonMtx.Lock()
+	if sp.disconnectRsn == "" {
+		sp.disconnectRsn = reason
+	}
+	sp.reasonMtx.Unlock()
+
+	sp.Disconnect()
+}
+
+// disconnectReason returns the reason recorded when the peer was disconnected
+// locally.  It defaults to the connection being closed otherwise.
+// It is safe for concurrent access.
+func (sp *serverPeer) disconnectReason() string {
+	sp.reasonMtx.Lock()
+	reason := sp.disconnectRsn
+	sp.reasonMtx.Unlock()
+
+	if reason == "" {
+		return "connection closed"
+	}
+	return reason
+}
+
 // pushAddrMsg sends an addr message to the connected peer using the provided
 // addresses.
 func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddress) {
@@ -297,12 +487,31 @@ func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddress) {
 	known, err := sp.PushAddrMsg(addrs)
 	if err != nil {
 		peerLog.Errorf("Can't push address message to %s: %v", sp.Peer, err)
-		sp.Disconnect()
+		sp.disconnectWithReason("failed to push address message")
 		return
 	}
 	sp.addKnownAddresses(known)
 }
 
+// pushAddrV2Msg sends an addrv2 message to the connected peer using the
+// provided addresses.
+func (sp *serverPeer) pushAddrV2Msg(addresses []*wire.NetAddressV2) {
+	// Filter addresses already known to the peer.
+	addrs := make([]*wire.NetAddressV2, 0, len(addresses))
+	for _, addr := range addresses {
+		if !sp.addressKnownV2(addr) {
+			addrs = append(addrs, addr)
+		}
+	}
+	known, err := sp.PushAddrV2Msg(addrs)
+	if err != nil {
+		peerLog.Errorf("Can't push address message to %s: %v", sp.Peer, err)
+		sp.disconnectWithReason("failed to push address message")
+		return
+	}
+	sp.addKnownAddressesV2(known)
+}
+
 // addBanScore increases the persistent and decaying ban score fields by the
 // values passed as parameters. If the resulting score exceeds half of the ban
 // threshold, a warning is logged including the reason provided. Further, if
@@ -310,10 +519,15 @@ func (sp *serverPeer) pushAddrMsg(addresses []*wire.NetAddress) {
 // disconnected.
 func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) {
 	// No warning is logged and no score is calculated if banning is disabled.
-	if cfg.DisableBanning {
+	settings := settings()
+	if settings.disableBanning {
 		return
 	}
-	warnThreshold := cfg.BanThreshold >> 1
+	if sp.isWhitelisted {
+		peerLog.Debugf("Misbehaving whitelisted peer %s: %s", sp, reason)
+		return
+	}
+	warnThreshold := settings.banThreshold >> 1
 	if transient == 0 && persistent == 0 {
 		// The score is not being increased, but a warning message is still
 		// logged if the score is above the warn threshold.
@@ -325,14 +539,15 @@ func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) {
 		return
 	}
 	score := sp.banScore.Increase(persistent, transient)
+	sp.server.peerEvents.notify(peerEventMisbehavior, sp, reason)
 	if score > warnThreshold {
 		peerLog.Warnf("Misbehaving peer %s: %s -- ban score increased to %d",
 			sp, reason, score)
-		if score > cfg.BanThreshold {
+		if score > settings.banThreshold {
 			peerLog.Warnf("Misbehaving peer %s -- banning and disconnecting",
 				sp)
 			sp.server.BanPeer(sp)
-			sp.Disconnect()
+			sp.disconnectWithReason("banned: " + reason)
 		}
 	}
 }
@@ -341,6 +556,15 @@ func (sp *serverPeer) addBanScore(persistent, transient uint32, reason string) {
 // and is used to negotiate the protocol version details as well as kick start
 // the communications.
 func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) {
+	// Only keep the connections to peers which are whitelisted or know the
+	// handshake token in whitelist-only mode.
+	if cfg.WhitelistOnly && !sp.isWhitelisted && !sp.HandshakeVerified() {
+		srvrLog.Infof("Disconnecting peer %s which is neither whitelisted "+
+			"nor knows the handshake token", sp)
+		sp.disconnectWithReason("not whitelisted")
+		return
+	}
+
 	// Add the remote peer time as a sample for creating an offset against
 	// the local clock to keep the network time in sync.
 	sp.server.timeSource.AddTimeSample(sp.Addr(), msg.Timestamp)
@@ -349,21 +573,23 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) {
 	sp.server.blockManager.NewPeer(sp)
 
 	// Choose whether or not to relay transactions before a filter command
-	// is received.
-	sp.setDisableRelayTx(msg.DisableRelayTx)
+	// is received.  Transactions are never relayed to block relay only
+	// peers.
+	sp.setDisableRelayTx(msg.DisableRelayTx || sp.blockRelayOnly)
 
 	// Update the address manager and request known addresses from the
 	// remote peer for outbound connections.  This is skipped when running
 	// on the simulation test network since it is only intended to connect
 	// to specified peers and actively avoids advertising and connecting to
-	// discovered peers.
+	// discovered peers.  Addresses are not exchanged with block relay only
+	// peers.
 	if !cfg.SimNet {
 		addrManager := sp.server.addrManager
 		// Outbound connections.
 		if !sp.Inbound() {
 			// TODO(davec): Only do this if not doing the initial block
 			// download and the local address is routable.
-			if !cfg.DisableListen /* && isCurrent? */ {
+			if !cfg.DisableListen && !sp.blockRelayOnly /* && isCurrent? */ {
 				// Get address that best matches.
 				lna := addrManager.GetBestLocalAddress(sp.NA())
 				if addrmgr.IsRoutable(lna) {
@@ -378,15 +604,26 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) {
 			// include a timestamp with addresses.
 			hasTimestamp := sp.ProtocolVersion() >=
 				wire.NetAddressTimeVersion
-			if addrManager.NeedMoreAddresses() && hasTimestamp {
+			if addrManager.NeedMoreAddresses() && hasTimestamp &&
+				!sp.blockRelayOnly {
+
 				sp.QueueMessage(wire.NewMsgGetAddr(), nil)
 			}
 
 			// Mark the address as a known good address.
-			addrManager.Good(sp.NA())
+			addrManager.GoodV2(sp.naV2())
 		}
 	}
 
+	// Ask peers which support compact blocks to announce new blocks by
+	// sending them directly as cmpctblock messages.  This is skipped in
+	// blocks only mode since there are no transactions in the memory pool
+	// to reconstruct the blocks from.
+	if !cfg.BlocksOnly && sp.ProtocolVersion() >= wire.CompactBlocksVersion {
+		sp.QueueMessage(wire.NewMsgSendCmpct(true,
+			wire.CmpctBlockVersion), nil)
+	}
+
 	// Add valid peer to the server.
 	sp.server.AddPeer(sp)
 }
@@ -396,12 +633,21 @@ func (sp *serverPeer) OnVersion(_ *peer.Peer, msg *wire.MsgVersion) {
 // pool up to the maximum inventory allowed per message.  When the peer has a
 // bloom filter loaded, the contents are filtered accordingly.
 func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *wire.MsgMemPool) {
+	// There is no point in advertising the contents of the memory pool
+	// when transaction relay is disabled entirely.
+	if cfg.BlocksOnly {
+		peerLog.Tracef("Ignoring mempool request from %v -- blocksonly "+
+			"enabled", sp)
+		return
+	}
+
 	// Only allow mempool requests if the server has bloom filtering
 	// enabled.
 	if sp.server.services&wire.SFNodeBloom != wire.SFNodeBloom {
 		peerLog.Debugf("peer %v sent mempool request with bloom "+
 			"filtering disabled -- disconnecting", sp)
-		sp.Disconnect()
+		sp.disconnectWithReason("mempool request with bloom filtering " +
+			"disabled")
 		return
 	}
 
@@ -449,6 +695,11 @@ func (sp *serverPeer) OnTx(_ *peer.Peer, msg *wire.MsgTx) {
 			msg.TxHash(), sp)
 		return
 	}
+	if sp.blockRelayOnly {
+		peerLog.Tracef("Ignoring tx %v from block relay only peer %v",
+			msg.TxHash(), sp)
+		return
+	}
 
 	// Add the transaction to the known inventory for the peer.
 	// Convert the raw MsgTx to a provautil.Tx which provides some convenience
@@ -472,6 +723,7 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 	// Convert the raw MsgBlock to a provautil.Block which provides some
 	// convenience methods and things such as hash caching.
 	block := provautil.NewBlockFromBlockAndBytes(msg, buf)
+	sp.server.blockManager.chain.RecordDeserializeTime(sp.LastDecodeTime())
 
 	// Add the block to the known inventory for the peer.
 	iv := wire.NewInvVect(wire.InvTypeBlock, block.Hash())
@@ -492,12 +744,181 @@ func (sp *serverPeer) OnBlock(_ *peer.Peer, msg *wire.MsgBlock, buf []byte) {
 	<-sp.blockProcessed
 }
 
+// OnCmpctBlock is invoked when a peer receives a cmpctblock message.  It
+// blocks until the block has been reconstructed and processed or the missing
+// transactions have been requested.
+func (sp *serverPeer) OnCmpctBlock(_ *peer.Peer, msg *wire.MsgCmpctBlock) {
+	// Add the block to the known inventory for the peer.
+	blockHash := msg.Header.BlockHash()
+	iv := wire.NewInvVect(wire.InvTypeBlock, &blockHash)
+	sp.AddKnownInventory(iv)
+
+	sp.server.blockManager.QueueCmpctBlock(msg, sp)
+	<-sp.blockProcessed
+}
+
+// OnBlockTxn is invoked when a peer receives a blocktxn message in response to
+// a getblocktxn message.  It blocks until the completed block has been
+// processed.
+func (sp *serverPeer) OnBlockTxn(_ *peer.Peer, msg *wire.MsgBlockTxn) {
+	sp.server.blockManager.QueueBlockTxn(msg, sp)
+	<-sp.blockProcessed
+}
+
+// OnGetBlockTxn is invoked when a peer receives a getblocktxn message.  It
+// responds with a blocktxn message containing the requested transactions of a
+// block which was announced to the peer with a cmpctblock message.  The ban
+// score of the peer is increased when it requests transactions which are not in
+// the block.
+func (sp *serverPeer) OnGetBlockTxn(_ *peer.Peer, msg *wire.MsgGetBlockTxn) {
+	block, err := sp.server.blockManager.chain.BlockByHash(&msg.BlockHash)
+	if err != nil {
+		peerLog.Debugf("Unable to fetch block %v requested by %s: %v",
+			msg.BlockHash, sp, err)
+		return
+	}
+
+	txns := block.MsgBlock().Transactions
+	blockTxn := wire.NewMsgBlockTxn(&msg.BlockHash)
+	for _, index := range msg.Indexes {
+		if int(index) >= len(txns) {
+			sp.addBanScore(100, 0, "getblocktxn")
+			return
+		}
+		blockTxn.AddTransaction(txns[index])
+	}
+	sp.QueueMessage(blockTxn, nil)
+}
+
+// cfilterRange returns the hashes of the main chain blocks from the passed start
+// height through the passed stop hash which are requested by a getcfilters or
+// getcfheaders message.  The peer is disconnected when the server does not
+// serve compact block filters and its ban score is increased when it requests
+// an unknown filter type or more than the passed number of blocks.  Nil is
+// returned when the request can't be served.
+func (sp *serverPeer) cfilterRange(cmd string, filterType wire.FilterType, startHeight uint32, stopHash *chainhash.Hash, maxRange uint32) []chainhash.Hash {
+	if sp.server.services&wire.SFNodeCF != wire.SFNodeCF {
+		peerLog.Debugf("peer %v sent %s request with compact block "+
+			"filters disabled -- disconnecting", sp, cmd)
+		sp.disconnectWithReason(cmd + " request with compact block " +
+			"filters disabled")
+		return nil
+	}
+	if !sp.server.indexEnabled(sp.server.cfIndex) {
+		peerLog.Debugf("Ignoring %s request from %s -- compact block "+
+			"filter index dropped", cmd, sp)
+		return nil
+	}
+	if filterType != wire.GCSFilterBasic {
+		sp.addBanScore(100, 0, cmd)
+		return nil
+	}
+
+	chain := sp.server.blockManager.chain
+	stopHeight, err := chain.BlockHeightByHash(stopHash)
+	if err != nil {
+		peerLog.Debugf("Unable to find stop block %v of %s request "+
+			"from %s: %v", stopHash, cmd, sp, err)
+		return nil
+	}
+	if startHeight > stopHeight || stopHeight-startHeight >= maxRange {
+		sp.addBanScore(100, 0, cmd)
+		return nil
+	}
+
+	hashes, err := chain.HeightRange(startHeight, stopHeight+1)
+	if err != nil {
+		peerLog.Debugf("Unable to fetch blocks of %s request from %s: "+
+			"%v", cmd, sp, err)
+		return nil
+	}
+	return hashes
+}
+
+// OnGetCFilters is invoked when a peer receives a getcfilters message.  It
+// responds with a cfilter message for each of the requested blocks.
+func (sp *serverPeer) OnGetCFilters(_ *peer.Peer, msg *wire.MsgGetCFilters) {
+	hashes := sp.cfilterRange(wire.CmdGetCFilters, msg.FilterType,
+		msg.StartHeight, &msg.StopHash, wire.MaxGetCFiltersReqRange)
+	if len(hashes) == 0 {
+		return
+	}
+
+	entries, err := sp.server.cfIndex.Entries(hashes)
+	if err != nil {
+		peerLog.Errorf("Unable to fetch compact block filters: %v", err)
+		return
+	}
+	for i, entry := range entries {
+		// The block was disconnected since the range was fetched.
+		if entry == nil {
+			return
+		}
+		sp.QueueMessage(wire.NewMsgCFilter(msg.FilterType, &hashes[i],
+			entry.Filter), nil)
+	}
+}
+
+// OnGetCFHeaders is invoked when a peer receives a getcfheaders message.  It
+// responds with a cfheaders message containing the hashes of the filters of
+// the requested blocks along with the filter header of the block preceding
+// them, which allows the peer to derive their filter headers.
+func (sp *serverPeer) OnGetCFHeaders(_ *peer.Peer, msg *wire.MsgGetCFHeaders) {
+	hashes := sp.cfilterRange(wire.CmdGetCFHeaders, msg.FilterType,
+		msg.StartHeight, &msg.StopHash, wire.MaxCFHeadersPerMsg)
+	if len(hashes) == 0 {
+		return
+	}
+
+	// Fetch the entry of the block preceding the range as well since its
+	// filter header is included in the message.  The filter header
+	// preceding the genesis block is the zero hash.
+	if msg.StartHeight > 0 {
+		prevHash, err := sp.server.blockManager.chain.BlockHashByHeight(
+			msg.StartHeight - 1)
+		if err != nil {
+			peerLog.Debugf("Unable to fetch block preceding "+
+				"getcfheaders request from %s: %v", sp, err)
+			return
+		}
+		hashes = append([]chainhash.Hash{*prevHash}, hashes...)
+	}
+	entries, err := sp.server.cfIndex.Entries(hashes)
+	if err != nil {
+		peerLog.Errorf("Unable to fetch compact block filters: %v", err)
+		return
+	}
+
+	var prevHeader chainhash.Hash
+	if msg.StartHeight > 0 {
+		if entries[0] == nil {
+			return
+		}
+		prevHeader = entries[0].Header
+		entries = entries[1:]
+	}
+	cfHeaders := wire.NewMsgCFHeaders(msg.FilterType, &msg.StopHash,
+		&prevHeader)
+	for _, entry := range entries {
+		// The block was disconnected since the range was fetched.
+		if entry == nil {
+			return
+		}
+		filterHash := entry.FilterHash
+		if err := cfHeaders.AddCFHash(&filterHash); err != nil {
+			peerLog.Errorf("Unable to add filter hash: %v", err)
+			return
+		}
+	}
+	sp.QueueMessage(cfHeaders, nil)
+}
+
 // OnInv is invoked when a peer receives an inv bitcoin message and is
 // used to examine the inventory being advertised by the remote peer and react
 // accordingly.  We pass the message down to blockmanager which will call
 // QueueMessage with any appropriate responses.
 func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
-	if !cfg.BlocksOnly {
+	if !cfg.BlocksOnly && !sp.blockRelayOnly {
 		if len(msg.InvList) > 0 {
 			sp.server.blockManager.QueueInv(msg, sp)
 		}
@@ -508,11 +929,12 @@ func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 	for _, invVect := range msg.InvList {
 		if invVect.Type == wire.InvTypeTx {
 			peerLog.Tracef("Ignoring tx %v in inv from %v -- "+
-				"blocksonly enabled", invVect.Hash, sp)
+				"transaction relay disabled", invVect.Hash, sp)
 			if sp.ProtocolVersion() >= wire.BIP0037Version {
 				peerLog.Infof("Peer %v is announcing "+
 					"transactions -- disconnecting", sp)
-				sp.Disconnect()
+				sp.disconnectWithReason("announced transactions " +
+					"with transaction relay disabled")
 				return
 			}
 			continue
@@ -529,6 +951,12 @@ func (sp *serverPeer) OnInv(_ *peer.Peer, msg *wire.MsgInv) {
 	}
 }
 
+// OnHeaders is invoked when a peer receives a headers bitcoin message.  The
+// message is passed down to the block manager.
+func (sp *serverPeer) OnHeaders(_ *peer.Peer, msg *wire.MsgHeaders) {
+	sp.server.blockManager.QueueHeaders(msg, sp)
+}
+
 // handleGetData is invoked when a peer receives a getdata bitcoin message and
 // is used to deliver block and transaction information.
 func (sp *serverPeer) OnGetData(_ *peer.Peer, msg *wire.MsgGetData) {
@@ -785,12 +1213,12 @@ func (sp *serverPeer) enforceNodeBloomFlag(cmd string) bool {
 		// to ensure the violation is logged and the peer is
 		// disconnected regardless.
 		if sp.ProtocolVersion() >= wire.BIP0111Version &&
-			!cfg.DisableBanning {
+			!settings().disableBanning {
 
 			// Disonnect the peer regardless of whether it was
 			// banned.
 			sp.addBanScore(100, 0, cmd)
-			sp.Disconnect()
+			sp.disconnectWithReason("unsupported " + cmd + " request")
 			return false
 		}
 
@@ -798,7 +1226,7 @@ func (sp *serverPeer) enforceNodeBloomFlag(cmd string) bool {
 		// state.
 		peerLog.Debugf("%s sent an unsupported %s request -- "+
 			"disconnecting", sp, cmd)
-		sp.Disconnect()
+		sp.disconnectWithReason("unsupported " + cmd + " request")
 		return false
 	}
 
@@ -814,7 +1242,7 @@ func (sp *serverPeer) OnFeeFilter(p *peer.Peer, msg *wire.MsgFeeFilter) {
 	if msg.MinFee < 0 || msg.MinFee > provautil.MaxAtoms {
 		peerLog.Debugf("Peer %v sent an invalid feefilter '%v' -- "+
 			"disconnecting", sp, provautil.Amount(msg.MinFee))
-		sp.Disconnect()
+		sp.disconnectWithReason("invalid feefilter")
 		return
 	}
 
@@ -835,7 +1263,7 @@ func (sp *serverPeer) OnFilterAdd(_ *peer.Peer, msg *wire.MsgFilterAdd) {
 	if sp.filter.IsLoaded() {
 		peerLog.Debugf("%s sent a filteradd request with no filter "+
 			"loaded -- disconnecting", sp)
-		sp.Disconnect()
+		sp.disconnectWithReason("filteradd with no filter loaded")
 		return
 	}
 
@@ -856,7 +1284,7 @@ func (sp *serverPeer) OnFilterClear(_ *peer.Peer, msg *wire.MsgFilterClear) {
 	if !sp.filter.IsLoaded() {
 		peerLog.Debugf("%s sent a filterclear request with no "+
 			"filter loaded -- disconnecting", sp)
-		sp.Disconnect()
+		sp.disconnectWithReason("filterclear with no filter loaded")
 		return
 	}
 
@@ -875,7 +1303,11 @@ func (sp *serverPeer) OnFilterLoad(_ *peer.Peer, msg *wire.MsgFilterLoad) {
 		return
 	}
 
-	sp.setDisableRelayTx(false)
+	// Block relay only peers are unable to enable transaction relay with
+	// a filter.
+	if !sp.blockRelayOnly {
+		sp.setDisableRelayTx(false)
+	}
 
 	sp.filter.Reload(msg)
 }
@@ -909,6 +1341,13 @@ func (sp *serverPeer) OnGetAddr(_ *peer.Peer, msg *wire.MsgGetAddr) {
 	}
 	sp.sentAddrs = true
 
+	// Send the addresses of all networks to peers which asked for addrv2
+	// messages.
+	if sp.WantsAddrV2() {
+		sp.pushAddrV2Msg(sp.server.addrManager.AddressCacheV2())
+		return
+	}
+
 	// Get the current known addresses from the address manager.
 	addrCache := sp.server.addrManager.AddressCache()
 
@@ -927,6 +1366,14 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 		return
 	}
 
+	// Ignore addresses from block relay only peers since they are excluded
+	// from address relay.
+	if sp.blockRelayOnly {
+		peerLog.Debugf("Ignoring addr message from block relay only "+
+			"peer %v", sp)
+		return
+	}
+
 	// Ignore old style addresses which don't include a timestamp.
 	if sp.ProtocolVersion() < wire.NetAddressTimeVersion {
 		return
@@ -936,7 +1383,7 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 	if len(msg.AddrList) == 0 {
 		peerLog.Errorf("Command [%s] from %s does not contain any addresses",
 			msg.Command(), sp)
-		sp.Disconnect()
+		sp.disconnectWithReason("empty addr message")
 		return
 	}
 
@@ -966,16 +1413,69 @@ func (sp *serverPeer) OnAddr(_ *peer.Peer, msg *wire.MsgAddr) {
 	sp.server.addrManager.AddAddresses(msg.AddrList, sp.NA())
 }
 
+// OnAddrV2 is invoked when a peer receives an addrv2 bitcoin message and is
+// used to notify the server about advertised addresses, which may also be
+// addresses of networks which do not fit in addr messages.
+func (sp *serverPeer) OnAddrV2(_ *peer.Peer, msg *wire.MsgAddrV2) {
+	// Ignore addresses when running on the simulation test network.  This
+	// helps prevent the network from becoming another public test network
+	// since it will not be able to learn about other peers that have not
+	// specifically been provided.
+	if cfg.SimNet {
+		return
+	}
+
+	// Ignore addresses from block relay only peers since they are excluded
+	// from address relay.
+	if sp.blockRelayOnly {
+		peerLog.Debugf("Ignoring addrv2 message from block relay only "+
+			"peer %v", sp)
+		return
+	}
+
+	// A message that has no addresses is invalid.
+	if len(msg.AddrList) == 0 {
+		peerLog.Errorf("Command [%s] from %s does not contain any addresses",
+			msg.Command(), sp)
+		sp.disconnectWithReason("empty addrv2 message")
+		return
+	}
+
+	for _, na := range msg.AddrList {
+		// Don't add more address if we're disconnecting.
+		if !sp.Connected() {
+			return
+		}
+
+		// Set the timestamp to 5 days ago if it's more than 24 hours
+		// in the future so this address is one of the first to be
+		// removed when space is needed.
+		now := time.Now()
+		if na.Timestamp.After(now.Add(time.Minute * 10)) {
+			na.Timestamp = now.Add(-1 * time.Hour * 24 * 5)
+		}
+
+		// Add address to known addresses for this peer.
+		sp.addKnownAddressesV2([]*wire.NetAddressV2{na})
+	}
+
+	// Add addresses to server address manager.  The address manager
+	// ignores the addresses of unknown networks.
+	sp.server.addrManager.AddAddressesV2(msg.AddrList, sp.naV2())
+}
+
 // OnRead is invoked when a peer receives a message and it is used to update
 // the bytes received by the server.
 func (sp *serverPeer) OnRead(_ *peer.Peer, bytesRead int, msg wire.Message, err error) {
 	sp.server.AddBytesReceived(uint64(bytesRead))
+	sp.server.addMsgBytes(sp.server.bytesRecvPerMsg, msg, bytesRead)
 }
 
 // OnWrite is invoked when a peer sends a message and it is used to update
 // the bytes sent by the server.
 func (sp *serverPeer) OnWrite(_ *peer.Peer, bytesWritten int, msg wire.Message, err error) {
 	sp.server.AddBytesSent(uint64(bytesWritten))
+	sp.server.addMsgBytes(sp.server.bytesSentPerMsg, msg, bytesWritten)
 }
 
 // randomUint16Number returns a random uint16 in a specified input range.  Note
@@ -1031,6 +1531,16 @@ func (s *server) AnnounceNewTransactions(newTxs []*mempool.TxDesc) {
 		iv := wire.NewInvVect(wire.InvTypeTx, txD.Tx.Hash())
 		s.RelayInventory(iv, txD)
 
+		// Notify ZeroMQ subscribers about mempool transactions.
+		if s.zmqNotifier != nil {
+			s.zmqNotifier.TransactionAccepted(txD.Tx)
+		}
+
+		// Notify gRPC subscribers about mempool transactions.
+		if s.grpcServer != nil {
+			s.grpcServer.TransactionAccepted(txD.Tx)
+		}
+
 		if s.rpcServer != nil {
 			// Notify websocket clients about mempool transactions.
 			s.rpcServer.ntfnMgr.NotifyMempoolTx(txD.Tx, true)
@@ -1159,9 +1669,22 @@ func (s *server) pushMerkleBlockMsg(sp *serverPeer, hash *chainhash.Hash, doneCh
 		return err
 	}
 
+	// Use the merkle tree stored by the merkle tree index when the block
+	// has been indexed so the block does not need to be hashed again.
+	var layers [][]chainhash.Hash
+	if s.indexEnabled(s.merkleIndex) {
+		layers, err = s.merkleIndex.MerkleLayers(hash)
+		if err != nil {
+			peerLog.Warnf("Unable to fetch merkle tree of block %v: %v",
+				hash, err)
+			layers = nil
+		}
+	}
+
 	// Generate a merkle block by filtering the requested block according
 	// to the filter for the peer.
-	merkle, matchedTxIndices := bloom.NewMerkleBlock(blk, sp.filter)
+	merkle, matchedTxIndices := bloom.NewMerkleBlockWithLayers(blk,
+		sp.filter, layers)
 
 	// Once we have fetched data wait for any previous operation to finish.
 	if waitChan != nil {
@@ -1230,7 +1753,7 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	// Ignore new peers if we're shutting down.
 	if atomic.LoadInt32(&s.shutdown) != 0 {
 		srvrLog.Infof("New peer %s ignored - server is shutting down", sp)
-		sp.Disconnect()
+		sp.disconnectWithReason("server is shutting down")
 		return false
 	}
 
@@ -1238,28 +1761,28 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	host, _, err := net.SplitHostPort(sp.Addr())
 	if err != nil {
 		srvrLog.Debugf("can't split hostport %v", err)
-		sp.Disconnect()
+		sp.disconnectWithReason("invalid address")
 		return false
 	}
-	if banEnd, ok := state.banned[host]; ok {
-		if time.Now().Before(banEnd) {
-			srvrLog.Debugf("Peer %s is banned for another %v - disconnecting",
-				host, banEnd.Sub(time.Now()))
-			sp.Disconnect()
-			return false
-		}
-
-		srvrLog.Infof("Peer %s is no longer banned", host)
-		delete(state.banned, host)
+	if s.banManager.IsBanned(net.ParseIP(host)) {
+		srvrLog.Debugf("Peer %s is banned - disconnecting", host)
+		sp.disconnectWithReason("peer is banned")
+		return false
 	}
 
 	// TODO: Check for max peers from a single IP.
 
-	// Limit max number of total peers.
-	if state.Count() >= cfg.MaxPeers {
+	// Limit max number of total peers.  Inbound and block relay only peers
+	// make room by evicting an unprotected inbound peer instead, so the
+	// node is unable to be crowded out by inbound connections while it
+	// keeps its most useful peers.
+	if state.Count() >= cfg.MaxPeers &&
+		((!sp.Inbound() && !sp.blockRelayOnly) ||
+			!s.evictInboundPeer(state)) {
+
 		srvrLog.Infof("Max peers reached [%d] - disconnecting peer %s",
 			cfg.MaxPeers, sp)
-		sp.Disconnect()
+		sp.disconnectWithReason("max peers reached")
 		// TODO: how to handle permanent peers here?
 		// they should be rescheduled.
 		return false
@@ -1270,20 +1793,45 @@ func (s *server) handleAddPeerMsg(state *peerState, sp *serverPeer) bool {
 	if sp.Inbound() {
 		state.inboundPeers[sp.ID()] = sp
 	} else {
-		state.outboundGroups[addrmgr.GroupKey(sp.NA())]++
+		state.outboundGroups[addrmgr.GroupKeyV2(sp.naV2())]++
 		if sp.persistent {
 			state.persistentPeers[sp.ID()] = sp
 		} else {
 			state.outboundPeers[sp.ID()] = sp
 		}
 	}
+	s.peerEvents.notify(peerEventHandshakeComplete, sp, "")
+
+	return true
+}
+
+// evictInboundPeer disconnects the inbound peer selected by the eviction
+// policy and removes it from the peer state to make room for a new peer.
+// Outbound peers, and thus block relay only peers and anchors, are never
+// evicted.  It returns whether a peer was evicted.  It is invoked from the
+// peerHandler goroutine.
+func (s *server) evictInboundPeer(state *peerState) bool {
+	candidates := make([]*evictionCandidate, 0, len(state.inboundPeers))
+	for _, sp := range state.inboundPeers {
+		candidates = append(candidates, sp.evictionCandidate())
+	}
+	evict := selectPeerToEvict(candidates)
+	if evict == nil {
+		return false
+	}
 
+	sp := state.inboundPeers[evict.id]
+	srvrLog.Debugf("Evicting inbound peer %s", sp)
+	delete(state.inboundPeers, sp.ID())
+	sp.disconnectWithReason("evicted to make room for a new peer")
 	return true
 }
 
 // handleDonePeerMsg deals with peers that have signalled they are done.  It is
 // invoked from the peerHandler goroutine.
 func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
+	s.peerEvents.notify(peerEventDisconnected, sp, sp.disconnectReason())
+
 	var list map[int32]*serverPeer
 	if sp.persistent {
 		list = state.persistentPeers
@@ -1294,7 +1842,7 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	}
 	if _, ok := list[sp.ID()]; ok {
 		if !sp.Inbound() && sp.VersionKnown() {
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[addrmgr.GroupKeyV2(sp.naV2())]--
 		}
 		if !sp.Inbound() && sp.connReq != nil {
 			s.connManager.Disconnect(sp.connReq.ID())
@@ -1311,7 +1859,7 @@ func (s *server) handleDonePeerMsg(state *peerState, sp *serverPeer) {
 	// Update the address' last seen time if the peer has acknowledged
 	// our version and has sent us its version as well.
 	if sp.VerAckReceived() && sp.VersionKnown() && sp.NA() != nil {
-		s.addrManager.Connected(sp.NA())
+		s.addrManager.ConnectedV2(sp.naV2())
 	}
 
 	// If we get here it means that either we didn't know about the peer
@@ -1326,31 +1874,67 @@ func (s *server) handleBanPeerMsg(state *peerState, sp *serverPeer) {
 		srvrLog.Debugf("can't split ban peer %s %v", sp.Addr(), err)
 		return
 	}
+	subnet, err := connmgr.ParseSubnet(host)
+	if err != nil {
+		srvrLog.Debugf("can't ban peer %s %v", sp.Addr(), err)
+		return
+	}
 	direction := directionString(sp.Inbound())
+	banDuration := settings().banDuration
 	srvrLog.Infof("Banned peer %s (%s) for %v", host, direction,
-		cfg.BanDuration)
-	state.banned[host] = time.Now().Add(cfg.BanDuration)
+		banDuration)
+	err = s.banManager.Ban(subnet, time.Now().Add(banDuration))
+	if err != nil {
+		srvrLog.Errorf("Unable to save the ban list: %v", err)
+	}
 }
 
 // handleRelayInvMsg deals with relaying inventory to peers that are not already
 // known to have it.  It is invoked from the peerHandler goroutine.
 func (s *server) handleRelayInvMsg(state *peerState, msg relayMsg) {
+	var cmpctBlock *wire.MsgCmpctBlock
 	state.forAllPeers(func(sp *serverPeer) {
 		if !sp.Connected() {
 			return
 		}
 
+		// If the inventory is a block and the peer prefers compact
+		// blocks, send a cmpctblock message instead of an inventory
+		// message.  The message is only built once for all peers.
+		if msg.invVect.Type == wire.InvTypeBlock && sp.WantsCmpctBlocks() {
+			if cmpctBlock == nil {
+				block, ok := msg.data.(*provautil.Block)
+				if !ok {
+					peerLog.Warnf("Underlying data for " +
+						"cmpctblock is not a block")
+					return
+				}
+				nonce, err := wire.RandomUint64()
+				if err != nil {
+					peerLog.Errorf("Failed to generate "+
+						"cmpctblock nonce: %v", err)
+					return
+				}
+				cmpctBlock = wire.NewMsgCmpctBlockFromBlock(
+					block.MsgBlock(), nonce)
+			}
+			sp.AddKnownInventory(msg.invVect)
+			sp.QueueMessage(cmpctBlock, nil)
+			return
+		}
+
 		// If the inventory is a block and the peer prefers headers,
 		// generate and send a headers message instead of an inventory
 		// message.
 		if msg.invVect.Type == wire.InvTypeBlock && sp.WantsHeaders() {
-			blockHeader, ok := msg.data.(wire.BlockHeader)
+			block, ok := msg.data.(*provautil.Block)
 			if !ok {
 				peerLog.Warnf("Underlying data for headers" +
-					" is not a block header")
+					" is not a block")
 				return
 			}
 			msgHeaders := wire.NewMsgHeaders()
+			blockHeader := block.MsgBlock().Header
 			if err := msgHeaders.AddBlockHeader(&blockHeader); err != nil {
 				peerLog.Errorf("Failed to add block"+
 					" header: %v", err)
@@ -1449,6 +2033,14 @@ type removeNodeMsg struct {
 	reply chan error
 }
 
+type disconnectBannedMsg struct {
+	reply chan int
+}
+
+type getEvictionProtectionMsg struct {
+	reply chan map[int32]string
+}
+
 // handleQuery is the central handler for all queries and commands from other
 // goroutines related to peer state.
 func (s *server) handleQuery(state *peerState, querymsg interface{}) {
@@ -1506,7 +2098,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found := disconnectPeer(state.persistentPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[addrmgr.GroupKeyV2(sp.naV2())]--
 		})
 
 		if found {
@@ -1542,7 +2134,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
 			// Keep group counts ok since we remove from
 			// the list now.
-			state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+			state.outboundGroups[addrmgr.GroupKeyV2(sp.naV2())]--
 		})
 		if found {
 			// If there are multiple outbound connections to the same
@@ -1550,7 +2142,7 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 			// peers are found.
 			for found {
 				found = disconnectPeer(state.outboundPeers, msg.cmp, func(sp *serverPeer) {
-					state.outboundGroups[addrmgr.GroupKey(sp.NA())]--
+					state.outboundGroups[addrmgr.GroupKeyV2(sp.naV2())]--
 				})
 			}
 			msg.reply <- nil
@@ -1558,6 +2150,36 @@ func (s *server) handleQuery(state *peerState, querymsg interface{}) {
 		}
 
 		msg.reply <- errors.New("peer not found")
+
+	case disconnectBannedMsg:
+		// Disconnect all peers whose addresses are banned.  The peers
+		// are removed from the peer state once they are done.
+		var banned []*serverPeer
+		state.forAllPeers(func(sp *serverPeer) {
+			host, _, err := net.SplitHostPort(sp.Addr())
+			if err == nil && s.banManager.IsBanned(net.ParseIP(host)) {
+				banned = append(banned, sp)
+			}
+		})
+		for _, sp := range banned {
+			sp.disconnectWithReason("peer is banned")
+		}
+		msg.reply <- len(banned)
+
+	case getEvictionProtectionMsg:
+		candidates := make([]*evictionCandidate, 0,
+			len(state.inboundPeers))
+		for _, sp := range state.inboundPeers {
+			candidates = append(candidates, sp.evictionCandidate())
+		}
+		protected, _ := protectEvictionCandidates(candidates)
+		for id := range state.outboundPeers {
+			protected[id] = protectOutbound
+		}
+		for id := range state.persistentPeers {
+			protected[id] = protectOutbound
+		}
+		msg.reply <- protected
 	}
 }
 
@@ -1578,7 +2200,7 @@ func disconnectPeer(peerList map[int32]*serverPeer, compareFunc func(*serverPeer
 			// This is ok because we are not continuing
 			// to iterate so won't corrupt the loop.
 			delete(peerList, addr)
-			peer.Disconnect()
+			peer.disconnectWithReason("disconnected by request")
 			return true
 		}
 	}
@@ -1587,24 +2209,31 @@ func disconnectPeer(peerList map[int32]*serverPeer, compareFunc func(*serverPeer
 
 // newPeerConfig returns the configuration for the given serverPeer.
 func newPeerConfig(sp *serverPeer) *peer.Config {
-	return &peer.Config{
+	peerCfg := &peer.Config{
 		Listeners: peer.MessageListeners{
-			OnVersion:     sp.OnVersion,
-			OnMemPool:     sp.OnMemPool,
-			OnTx:          sp.OnTx,
-			OnBlock:       sp.OnBlock,
-			OnInv:         sp.OnInv,
-			OnGetData:     sp.OnGetData,
-			OnGetBlocks:   sp.OnGetBlocks,
-			OnGetHeaders:  sp.OnGetHeaders,
-			OnFeeFilter:   sp.OnFeeFilter,
-			OnFilterAdd:   sp.OnFilterAdd,
-			OnFilterClear: sp.OnFilterClear,
-			OnFilterLoad:  sp.OnFilterLoad,
-			OnGetAddr:     sp.OnGetAddr,
-			OnAddr:        sp.OnAddr,
-			OnRead:        sp.OnRead,
-			OnWrite:       sp.OnWrite,
+			OnVersion:      sp.OnVersion,
+			OnMemPool:      sp.OnMemPool,
+			OnTx:           sp.OnTx,
+			OnBlock:        sp.OnBlock,
+			OnInv:          sp.OnInv,
+			OnHeaders:      sp.OnHeaders,
+			OnGetData:      sp.OnGetData,
+			OnGetBlocks:    sp.OnGetBlocks,
+			OnGetHeaders:   sp.OnGetHeaders,
+			OnFeeFilter:    sp.OnFeeFilter,
+			OnCmpctBlock:   sp.OnCmpctBlock,
+			OnGetBlockTxn:  sp.OnGetBlockTxn,
+			OnBlockTxn:     sp.OnBlockTxn,
+			OnGetCFilters:  sp.OnGetCFilters,
+			OnGetCFHeaders: sp.OnGetCFHeaders,
+			OnFilterAdd:    sp.OnFilterAdd,
+			OnFilterClear:  sp.OnFilterClear,
+			OnFilterLoad:   sp.OnFilterLoad,
+			OnGetAddr:      sp.OnGetAddr,
+			OnAddr:         sp.OnAddr,
+			OnAddrV2:       sp.OnAddrV2,
+			OnRead:         sp.OnRead,
+			OnWrite:        sp.OnWrite,
 
 			// Note: The reference client currently bans peers that send alerts
 			// not signed with its key.  We could verify against their key, but
@@ -1612,16 +2241,109 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			// other implementations' alert messages, we will not relay theirs.
 			OnAlert: nil,
 		},
-		NewestBlock:      sp.newestBlock,
-		HostToNetAddress: sp.server.addrManager.HostToNetAddress,
-		Proxy:            cfg.Proxy,
-		UserAgentName:    userAgentName,
-		UserAgentVersion: userAgentVersion,
-		ChainParams:      sp.server.chainParams,
-		Services:         sp.server.services,
-		DisableRelayTx:   cfg.BlocksOnly,
-		ProtocolVersion:  wire.FeeFilterVersion,
+		NewestBlock:       sp.newestBlock,
+		HostToNetAddress:  sp.hostToNetAddress,
+		Proxy:             cfg.Proxy,
+		UserAgentName:     userAgentName,
+		UserAgentVersion:  userAgentVersion,
+		ChainParams:       sp.server.chainParams,
+		Services:          sp.server.services,
+		DisableRelayTx:    cfg.BlocksOnly || sp.blockRelayOnly,
+		AllowChecksumSkip: cfg.SkipLocalChecksum,
+		TrustedConn:       sp.isWhitelisted,
+		HandshakeToken:    handshakeToken(),
+		ProtocolVersion:   peer.MaxProtocolVersion,
+	}
+
+	// Whitelisted peers are exempt from the bandwidth limits.
+	if !sp.isWhitelisted {
+		peerCfg.SendLimiter = sp.server.sendLimiter
+		peerCfg.RecvLimiter = sp.server.recvLimiter
+		peerCfg.MaxSendRate = cfg.PeerUploadRate * 1000
+		peerCfg.MaxRecvRate = cfg.PeerDownloadRate * 1000
+	}
+	return peerCfg
+}
+
+// initDNSSeeder creates the crawler and the DNS seeder server which answers the
+// queries for the host name given by --dnsseedserver with the addresses of the
+// peers the crawler reports as good.
+func (s *server) initDNSSeeder() error {
+	crawler, err := dnsseed.NewCrawler(&dnsseed.CrawlerConfig{
+		AddrManager: s.addrManager,
+		Dial:        s.dialPeer,
+		PeerConfig: peer.Config{
+			NewestBlock: func() (*chainhash.Hash, uint32, error) {
+				best := s.blockManager.chain.BestSnapshot()
+				return best.Hash, best.Height, nil
+			},
+			Proxy:            cfg.Proxy,
+			UserAgentName:    userAgentName,
+			UserAgentVersion: userAgentVersion,
+			ChainParams:      s.chainParams,
+			DisableRelayTx:   true,
+			HandshakeToken:   handshakeToken(),
+			ProtocolVersion:  peer.MaxProtocolVersion,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.ListenPacket("udp", cfg.DNSSeedListen)
+	if err != nil {
+		return err
+	}
+	seeder, err := dnsseed.New(&dnsseed.Config{
+		Host:          cfg.DNSSeedServer,
+		Conn:          conn,
+		GoodAddresses: crawler.GoodAddresses,
+	})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	s.crawler = crawler
+	s.dnsSeeder = seeder
+	return nil
+}
+
+// handshakeToken returns the handshake token peers prove they know in the
+// version handshake, or nil when none is configured.
+func handshakeToken() []byte {
+	if cfg.HandshakeToken == "" {
+		return nil
+	}
+	return []byte(cfg.HandshakeToken)
+}
+
+// isWhitelisted returns whether the IP address of the passed network address
+// is included in the whitelisted networks and IPs.
+func isWhitelisted(addr net.Addr) bool {
+	if len(cfg.whitelists) == 0 {
+		return false
+	}
+
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		srvrLog.Warnf("Unable to SplitHostPort on '%s': %v", addr, err)
+		return false
+	}
+	return isWhitelistedIP(net.ParseIP(host))
+}
+
+// isWhitelistedIP returns whether the passed IP address is included in the
+// whitelisted networks and IPs.
+func isWhitelistedIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipnet := range cfg.whitelists {
+		if ipnet.Contains(ip) {
+			return true
+		}
 	}
+	return false
 }
 
 // inboundPeerConnected is invoked by the connection manager when a new inbound
@@ -1629,12 +2351,63 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 // instance, associates it with the connection, and starts a goroutine to wait
 // for disconnection.
 func (s *server) inboundPeerConnected(conn net.Conn) {
+	// Connections which are not whitelisted are unable to be accepted in
+	// whitelist-only mode without a handshake token to prove.
+	whitelisted := isWhitelisted(conn.RemoteAddr())
+	if cfg.WhitelistOnly && cfg.HandshakeToken == "" && !whitelisted {
+		srvrLog.Debugf("Refused connection from %s which is not "+
+			"whitelisted", conn.RemoteAddr())
+		conn.Close()
+		return
+	}
+
+	// Complete the TLS handshake when the remote peer starts one.
+	if s.p2pTLS != nil {
+		tlsConn, err := s.p2pTLS.accept(conn)
+		if err != nil {
+			srvrLog.Debugf("Refused connection from %s: %v",
+				conn.RemoteAddr(), err)
+			conn.Close()
+			return
+		}
+		conn = tlsConn
+	}
+
 	sp := newServerPeer(s, false)
+	sp.isWhitelisted = whitelisted
+	sp.encrypted = isTLSConn(conn)
 	sp.Peer = peer.NewInboundPeer(newPeerConfig(sp))
 	sp.AssociateConnection(conn)
+	s.peerEvents.notify(peerEventConnected, sp, "")
 	go s.peerDoneHandler(sp)
 }
 
+// dialPeer connects to the passed peer address.  The connection is upgraded to
+// TLS when TLS-encrypted peer connections are required, or when enabled and the
+// address manager knows the peer accepts them.
+func (s *server) dialPeer(addr net.Addr) (net.Conn, error) {
+	conn, err := btcdDial(addr)
+	if err != nil || s.p2pTLS == nil {
+		return conn, err
+	}
+
+	if !s.p2pTLS.required {
+		na, err := s.addrManager.DeserializeNetAddress(addr.String())
+		if err != nil ||
+			s.addrManager.Services(na)&wire.SFNodeTLS != wire.SFNodeTLS {
+
+			return conn, nil
+		}
+	}
+
+	tlsConn, err := s.p2pTLS.client(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
 // outboundPeerConnected is invoked by the connection manager when a new
 // outbound connection is established.  It initializes a new outbound server
 // peer instance, associates it with the relevant state such as the connection
@@ -1642,6 +2415,8 @@ func (s *server) inboundPeerConnected(conn net.Conn) {
 // manager of the attempt.
 func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	sp := newServerPeer(s, c.Permanent)
+	sp.blockRelayOnly = c.BlockRelayOnly
+	sp.isWhitelisted = isWhitelisted(conn.RemoteAddr())
 	p, err := peer.NewOutboundPeer(newPeerConfig(sp), c.Addr.String())
 	if err != nil {
 		srvrLog.Debugf("Cannot create outbound peer %s: %v", c.Addr, err)
@@ -1649,9 +2424,11 @@ func (s *server) outboundPeerConnected(c *connmgr.ConnReq, conn net.Conn) {
 	}
 	sp.Peer = p
 	sp.connReq = c
+	sp.encrypted = isTLSConn(conn)
 	sp.AssociateConnection(conn)
+	s.peerEvents.notify(peerEventConnected, sp, "")
 	go s.peerDoneHandler(sp)
-	s.addrManager.Attempt(sp.NA())
+	s.addrManager.AttemptV2(sp.naV2())
 }
 
 // peerDoneHandler handles peer disconnects by notifiying the server that it's
@@ -1686,6 +2463,15 @@ func (s *server) peerHandler() {
 	// in this handler.
 	s.addrManager.Start()
 	s.blockManager.Start()
+	if s.indexManager != nil {
+		s.indexManager.Start()
+	}
+	if s.streamIndex != nil {
+		s.streamIndex.Start(s.streamSink)
+	}
+	if s.crawler != nil {
+		s.crawler.Start()
+	}
 
 	srvrLog.Tracef("Starting peer handler")
 
@@ -1693,7 +2479,6 @@ func (s *server) peerHandler() {
 		inboundPeers:    make(map[int32]*serverPeer),
 		persistentPeers: make(map[int32]*serverPeer),
 		outboundPeers:   make(map[int32]*serverPeer),
-		banned:          make(map[string]time.Time),
 		outboundGroups:  make(map[string]int),
 	}
 
@@ -1709,7 +2494,16 @@ func (s *server) peerHandler() {
 				s.addrManager.AddAddresses(addrs, addrs[0])
 			})
 	}
-	go s.connManager.Start()
+	s.connManager.Start()
+
+	// Reconnect to the anchors after starting the connection manager so
+	// they do not count against the automatic outbound connections.
+	for _, addr := range s.anchors {
+		go s.connManager.Connect(&connmgr.ConnReq{
+			Addr:           addr,
+			BlockRelayOnly: true,
+		})
+	}
 
 out:
 	for {
@@ -1743,10 +2537,14 @@ out:
 			s.handleQuery(state, qmsg)
 
 		case <-s.quit:
+			// Save the block relay only peers as anchors to
+			// reconnect to on the next startup.
+			s.saveAnchors(state)
+
 			// Disconnect all peers on server shutdown.
 			state.forAllPeers(func(sp *serverPeer) {
 				srvrLog.Tracef("Shutdown peer %s", sp)
-				sp.Disconnect()
+				sp.disconnectWithReason("server is shutting down")
 			})
 			break out
 		}
@@ -1754,6 +2552,20 @@ out:
 
 	s.connManager.Stop()
 	s.blockManager.Stop()
+	if cfg.PersistMempool {
+		s.saveMempool()
+	}
+	if s.streamIndex != nil {
+		if err := s.streamIndex.Stop(); err != nil {
+			srvrLog.Warnf("Unable to close stream sink: %v", err)
+		}
+	}
+	if s.indexManager != nil {
+		s.indexManager.Stop()
+	}
+	if s.crawler != nil {
+		s.crawler.Stop()
+	}
 	s.addrManager.Stop()
 
 	// Drain channels before exiting so nothing is left waiting around
@@ -1834,6 +2646,17 @@ func (s *server) Peers() []*serverPeer {
 	return <-replyChan
 }
 
+// EvictionProtection returns the reasons the connected peers are protected from
+// eviction by their IDs.  Inbound peers which are not included are eviction
+// candidates.
+func (s *server) EvictionProtection() map[int32]string {
+	replyChan := make(chan map[int32]string)
+
+	s.query <- getEvictionProtectionMsg{reply: replyChan}
+
+	return <-replyChan
+}
+
 // DisconnectNodeByAddr disconnects a peer by target address. Both outbound and
 // inbound nodes will be searched for the target node. An error message will
 // be returned if the peer was not found.
@@ -1888,6 +2711,17 @@ func (s *server) RemoveNodeByID(id int32) error {
 	return <-replyChan
 }
 
+// BanSubnet bans the passed subnet until the passed time and disconnects the
+// connected peers whose addresses are in it.  It returns the number of peers
+// which were disconnected.
+func (s *server) BanSubnet(subnet *net.IPNet, until time.Time) (int, error) {
+	err := s.banManager.Ban(subnet, until)
+
+	replyChan := make(chan int)
+	s.query <- disconnectBannedMsg{reply: replyChan}
+	return <-replyChan, err
+}
+
 // ConnectNode adds `addr' as a new outbound peer. If permanent is true then the
 // peer will be persistent and reconnect if the connection is lost.
 // It is an error to call this with an already existing peer.
@@ -1918,6 +2752,44 @@ func (s *server) NetTotals() (uint64, uint64) {
 		atomic.LoadUint64(&s.bytesSent)
 }
 
+// otherMsgCommand is the command the bytes of messages which failed to decode
+// are counted under.
+const otherMsgCommand = "*other*"
+
+// addMsgBytes adds the passed number of bytes to the counter of the command of
+// the passed message in the passed per message totals of the server.  It is
+// safe for concurrent access.
+func (s *server) addMsgBytes(totals map[string]uint64, msg wire.Message, bytes int) {
+	if bytes == 0 {
+		return
+	}
+	command := otherMsgCommand
+	if msg != nil {
+		command = msg.Command()
+	}
+
+	s.netTotalsMtx.Lock()
+	totals[command] += uint64(bytes)
+	s.netTotalsMtx.Unlock()
+}
+
+// NetTotalsPerMsg returns the bytes received and sent across the network for
+// all peers per message command.  It is safe for concurrent access.
+func (s *server) NetTotalsPerMsg() (map[string]uint64, map[string]uint64) {
+	s.netTotalsMtx.Lock()
+	defer s.netTotalsMtx.Unlock()
+
+	recv := make(map[string]uint64, len(s.bytesRecvPerMsg))
+	for command, bytes := range s.bytesRecvPerMsg {
+		recv[command] = bytes
+	}
+	sent := make(map[string]uint64, len(s.bytesSentPerMsg))
+	for command, bytes := range s.bytesSentPerMsg {
+		sent[command] = bytes
+	}
+	return recv, sent
+}
+
 // UpdatePeerHeights updates the heights of all peers who have have announced
 // the latest connected main chain block, or a recognized orphan. These height
 // updates allow us to dynamically refresh peer heights, ensuring sync peer
@@ -1988,6 +2860,76 @@ cleanup:
 	s.wg.Done()
 }
 
+// saveMempool writes the transactions in the memory pool to the mempool file
+// in the data directory.  The file is replaced atomically, so a failure leaves
+// the previously saved transactions intact.
+func (s *server) saveMempool() {
+	path := filepath.Join(cfg.DataDir, mempoolFileName)
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		srvrLog.Errorf("Unable to save mempool: %v", err)
+		return
+	}
+	numTxns, err := s.txMemPool.SaveTransactions(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		srvrLog.Errorf("Unable to save mempool: %v", err)
+		return
+	}
+	srvrLog.Infof("Saved %d mempool transactions to %s", numTxns, path)
+}
+
+// saveAnchors saves the addresses of the connected block relay only peers to the
+// anchors file in the data directory.
+func (s *server) saveAnchors(state *peerState) {
+	var addrs []string
+	for _, sp := range state.outboundPeers {
+		if sp.blockRelayOnly {
+			addrs = append(addrs, addrmgr.NetAddressV2Key(sp.naV2()))
+		}
+	}
+	if len(addrs) == 0 {
+		return
+	}
+
+	path := filepath.Join(cfg.DataDir, anchorsFileName)
+	if err := saveAnchors(path, addrs); err != nil {
+		srvrLog.Errorf("Unable to save anchors: %v", err)
+		return
+	}
+	srvrLog.Infof("Saved %d anchors to %s", len(addrs), path)
+}
+
+// loadMempool adds the transactions saved to the mempool file in the data
+// directory which are still valid against the current chain back to the
+// memory pool.
+func (s *server) loadMempool() {
+	path := filepath.Join(cfg.DataDir, mempoolFileName)
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		srvrLog.Errorf("Unable to load mempool: %v", err)
+		return
+	}
+	defer f.Close()
+
+	accepted, rejected, err := s.txMemPool.LoadTransactions(f)
+	if err != nil {
+		srvrLog.Errorf("Unable to load mempool from %s: %v", path, err)
+	}
+	srvrLog.Infof("Loaded %d mempool transactions from %s (%d no longer "+
+		"valid)", accepted, path, rejected)
+}
+
 // Start begins accepting connections from peers.
 func (s *server) Start() {
 	// Already started?
@@ -1997,6 +2939,12 @@ func (s *server) Start() {
 
 	srvrLog.Trace("Starting server")
 
+	// Add the transactions which were in the memory pool on shutdown back
+	// before any peer can relay them again.
+	if cfg.PersistMempool {
+		s.loadMempool()
+	}
+
 	// Start the peer handler which in turn starts the address and block
 	// managers.
 	s.wg.Add(1)
@@ -2007,6 +2955,18 @@ func (s *server) Start() {
 		go s.upnpUpdateThread()
 	}
 
+	// Accept incoming connections from Tor on an onion service.
+	if cfg.TorControl != "" {
+		s.wg.Add(1)
+		go s.onionServiceHandler()
+	}
+
+	// Reload the configuration on the reload signals of the platform.
+	if len(reloadSignals) > 0 {
+		s.wg.Add(1)
+		go s.reloadHandler()
+	}
+
 	if !cfg.DisableRPC {
 		s.wg.Add(1)
 
@@ -2017,10 +2977,69 @@ func (s *server) Start() {
 		s.rpcServer.Start()
 	}
 
+	// Serve the gRPC API if enabled.
+	if s.grpcServer != nil {
+		s.grpcServer.Start()
+	}
+
+	// Resume the rescans of the watch-only index which were interrupted by
+	// a shutdown.
+	if s.indexEnabled(s.watchIndex) {
+		s.wg.Add(1)
+		go func() {
+			err := s.watchIndex.Rescan(s.quit)
+			if err != nil && err != indexers.ErrRescanInterrupted {
+				srvrLog.Errorf("Unable to rescan watched addresses: "+
+					"%v", err)
+			}
+			s.wg.Done()
+		}()
+	}
+
 	// Start the CPU miner if generation is enabled.
 	if cfg.Generate {
 		s.cpuMiner.Start()
 	}
+
+	// Capture profiles on resource pressure if any threshold is set.
+	if s.autoProfiler != nil {
+		s.wg.Add(1)
+		go func() {
+			s.autoProfiler.handler(s.quit)
+			s.wg.Done()
+		}()
+	}
+
+	// Scrub the stored blocks periodically if enabled.
+	if s.blockScrubber != nil {
+		s.wg.Add(1)
+		go func() {
+			s.blockScrubber.handler(s.quit)
+			s.wg.Done()
+		}()
+	}
+
+	// Serve the health endpoint if enabled.
+	if s.healthListener != nil {
+		srvrLog.Infof("Health endpoint listening on %s",
+			s.healthListener.Addr())
+		go http.Serve(s.healthListener, newHealthHandler(s.healthStatus))
+	}
+
+	// Answer DNS seeder queries if enabled.
+	if s.dnsSeeder != nil {
+		s.dnsSeeder.Start()
+	}
+
+	// Let the service manager know the server is ready and keep its
+	// watchdog from expiring if it is enabled.
+	if _, err := sdNotify("READY=1"); err != nil {
+		srvrLog.Warnf("Unable to notify service manager: %v", err)
+	}
+	if interval := sdWatchdogInterval(); interval > 0 {
+		s.wg.Add(1)
+		go s.watchdogHandler(interval)
+	}
 }
 
 // Stop gracefully shuts down the server by stopping and disconnecting all
@@ -2033,6 +3052,9 @@ func (s *server) Stop() error {
 	}
 
 	srvrLog.Warnf("Server shutting down")
+	if _, err := sdNotify("STOPPING=1"); err != nil {
+		srvrLog.Warnf("Unable to notify service manager: %v", err)
+	}
 
 	// Stop the CPU miner if needed
 	s.cpuMiner.Stop()
@@ -2042,6 +3064,26 @@ func (s *server) Stop() error {
 		s.rpcServer.Stop()
 	}
 
+	// Shutdown the gRPC server if it's enabled.
+	if s.grpcServer != nil {
+		s.grpcServer.Stop()
+	}
+
+	// Stop serving the health endpoint.
+	if s.healthListener != nil {
+		s.healthListener.Close()
+	}
+
+	// Stop answering DNS seeder queries.
+	if s.dnsSeeder != nil {
+		s.dnsSeeder.Stop()
+	}
+
+	// Disconnect the ZeroMQ subscribers.
+	if s.zmqNotifier != nil {
+		s.zmqNotifier.Close()
+	}
+
 	// Signal the remaining goroutines to quit.
 	close(s.quit)
 	return nil
@@ -2204,9 +3246,38 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	if cfg.NoPeerBloomFilters {
 		services &^= wire.SFNodeBloom
 	}
+	if cfg.CfIndex {
+		services |= wire.SFNodeCF
+	}
+	if cfg.Prune != 0 {
+		// Pruned nodes are unable to serve the full block chain.
+		services &^= wire.SFNodeNetwork
+	}
+
+	// Accept TLS-encrypted peer connections and advertise it so peers
+	// encrypt the connections they make.
+	var p2pTLS *p2pTLS
+	if cfg.P2PTLS {
+		var err error
+		p2pTLS, err = newP2PTLS(cfg.P2PTLSCert, cfg.P2PTLSKey,
+			cfg.p2pTLSPins, cfg.P2PTLSRequired)
+		if err != nil {
+			return nil, err
+		}
+		services |= wire.SFNodeTLS
+	}
 
 	amgr := addrmgr.New(cfg.DataDir, btcdLookup)
 
+	// Load the bans which were in effect when the server was last
+	// stopped.  A corrupt ban list is discarded rather than preventing
+	// the server from starting.
+	banManager := connmgr.NewBanManager(filepath.Join(cfg.DataDir,
+		banListFileName))
+	if err := banManager.Load(); err != nil {
+		srvrLog.Warnf("Unable to load the ban list: %v", err)
+	}
+
 	var listeners []net.Listener
 	var nat NAT
 	if !cfg.DisableListen {
@@ -2334,6 +3405,8 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	s := server{
 		chainParams:          chainParams,
 		addrManager:          amgr,
+		banManager:           banManager,
+		p2pTLS:               p2pTLS,
 		newPeers:             make(chan *serverPeer, cfg.MaxPeers),
 		donePeers:            make(chan *serverPeer, cfg.MaxPeers),
 		banPeers:             make(chan *serverPeer, cfg.MaxPeers),
@@ -2347,8 +3420,19 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		db:                   db,
 		timeSource:           blockchain.NewMedianTime(),
 		services:             services,
+		peerEvents:           newPeerEventHooks(),
+		peerEventCounters:    new(peerEventCounters),
 		sigCache:             txscript.NewSigCache(cfg.SigCacheMaxSize),
 		hashCache:            txscript.NewHashCache(cfg.SigCacheMaxSize),
+		bytesRecvPerMsg:      make(map[string]uint64),
+		bytesSentPerMsg:      make(map[string]uint64),
+	}
+	s.SubscribePeerEvents(s.peerEventCounters.count)
+	if cfg.MaxUploadRate != 0 {
+		s.sendLimiter = peer.NewRateLimiter(cfg.MaxUploadRate * 1000)
+	}
+	if cfg.MaxDownloadRate != 0 {
+		s.recvLimiter = peer.NewRateLimiter(cfg.MaxDownloadRate * 1000)
 	}
 
 	// Create the transaction and address indexes if needed.
@@ -2358,12 +3442,17 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	// addrindex is run first, it may not have the transactions from the
 	// current block indexed.
 	var indexes []indexers.Indexer
-	if cfg.TxIndex || cfg.AddrIndex {
-		// Enable transaction index if address index is enabled since it
-		// requires it.
+	if cfg.TxIndex || cfg.AddrIndex || cfg.AddrValueIndex ||
+		cfg.KeyIDIndex || cfg.IssuanceIndex || cfg.AddrBalanceIndex ||
+		cfg.CfIndex || cfg.StreamIndex || cfg.ScriptUtxoIndex ||
+		cfg.FeeStatsIndex || cfg.WatchIndex {
+
+		// Enable transaction index if an address index or an index
+		// which needs the referenced inputs is enabled since they
+		// require it.
 		if !cfg.TxIndex {
 			indxLog.Infof("Transaction index enabled because it " +
-				"is required by the address index")
+				"is required by the other enabled indexes")
 			cfg.TxIndex = true
 		} else {
 			indxLog.Info("Transaction index is enabled")
@@ -2377,12 +3466,87 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		s.addrIndex = indexers.NewAddrIndex(db, chainParams)
 		indexes = append(indexes, s.addrIndex)
 	}
+	if cfg.AddrValueIndex {
+		indxLog.Info("Address value index is enabled")
+		s.addrValueIndex = indexers.NewAddrValueIndex(db, chainParams)
+		indexes = append(indexes, s.addrValueIndex)
+	}
+	if cfg.SpentIndex {
+		indxLog.Info("Spent index is enabled")
+		s.spentIndex = indexers.NewSpentIndex(db)
+		indexes = append(indexes, s.spentIndex)
+	}
+	if cfg.TimeIndex {
+		indxLog.Info("Block time index is enabled")
+		s.timeIndex = indexers.NewTimeIndex(db)
+		indexes = append(indexes, s.timeIndex)
+	}
+	if cfg.KeyIDIndex {
+		indxLog.Info("Key ID activity index is enabled")
+		s.keyIDIndex = indexers.NewKeyIDIndex(db, chainParams)
+		indexes = append(indexes, s.keyIDIndex)
+	}
+	if cfg.AdminOpIndex {
+		indxLog.Info("Admin operation index is enabled")
+		s.adminOpIndex = indexers.NewAdminOpIndex(db)
+		indexes = append(indexes, s.adminOpIndex)
+	}
+	if cfg.IssuanceIndex {
+		indxLog.Info("Issuance index is enabled")
+		s.issuanceIndex = indexers.NewIssuanceIndex(db, chainParams)
+		indexes = append(indexes, s.issuanceIndex)
+	}
+	if cfg.CfIndex {
+		indxLog.Info("Compact block filter index is enabled")
+		s.cfIndex = indexers.NewCfIndex(db, chainParams)
+		indexes = append(indexes, s.cfIndex)
+	}
+	if cfg.AddrBalanceIndex {
+		indxLog.Info("Address balance index is enabled")
+		s.addrBalanceIndex = indexers.NewAddrBalanceIndex(db, chainParams)
+		indexes = append(indexes, s.addrBalanceIndex)
+	}
+	if cfg.StreamIndex {
+		indxLog.Infof("Stream index is enabled (sink %s)", cfg.StreamSink)
+		sink, err := streamsink.New(cfg.StreamSink)
+		if err != nil {
+			return nil, err
+		}
+		s.streamSink = sink
+		s.streamIndex = indexers.NewStreamIndex(db, chainParams)
+		indexes = append(indexes, s.streamIndex)
+	}
+	if cfg.ScriptUtxoIndex {
+		indxLog.Info("Script utxo index is enabled")
+		s.scriptUtxoIndex = indexers.NewScriptUtxoIndex(db)
+		indexes = append(indexes, s.scriptUtxoIndex)
+	}
+	if cfg.FeeStatsIndex {
+		indxLog.Info("Fee stats index is enabled")
+		s.feeStatsIndex = indexers.NewFeeStatsIndex(db)
+		indexes = append(indexes, s.feeStatsIndex)
+	}
+	if cfg.MerkleIndex {
+		indxLog.Info("Merkle tree index is enabled")
+		s.merkleIndex = indexers.NewMerkleIndex(db)
+		indexes = append(indexes, s.merkleIndex)
+	}
+	if cfg.WatchIndex {
+		indxLog.Info("Watch-only index is enabled")
+		s.watchIndex = indexers.NewWatchIndex(db, chainParams)
+		indexes = append(indexes, s.watchIndex)
+	}
 
 	// Create an index manager if any of the optional indexes are enabled.
 	var indexManager blockchain.IndexManager
 	if len(indexes) > 0 {
-		indexManager = indexers.NewManager(db, indexes)
+		s.indexManager = indexers.NewManager(db, indexes)
+		indexManager = s.indexManager
 	}
+	// Create the fee estimator before the block manager since it registers
+	// the blocks connected to the chain with it.
+	s.feeEstimator = mempool.NewFeeEstimator(0)
+
 	bm, err := newBlockManager(&s, indexManager)
 	if err != nil {
 		return nil, err
@@ -2399,6 +3563,8 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 			MaxSigOpsPerTx:       blockchain.MaxSigOpsPerBlock / 5,
 			MinRelayTxFee:        cfg.minRelayTxFee,
 			MaxTxVersion:         2,
+			RejectReplacement:    cfg.RejectReplacement,
+			MaxDataCarrierSize:   cfg.DataCarrierSize,
 		},
 		ChainParams:     chainParams,
 		FetchUtxoView:   s.blockManager.chain.FetchUtxoView,
@@ -2413,9 +3579,22 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		HashCache:       s.hashCache,
 		TimeSource:      s.timeSource,
 		AddrIndex:       s.addrIndex,
+		FeeEstimator:    s.feeEstimator,
+		OnAdminAlert:    s.handleAdminAlert,
 		CalcSequenceLock: func(tx *provautil.Tx, view *blockchain.UtxoViewpoint) (*blockchain.SequenceLock, error) {
 			return bm.chain.CalcSequenceLock(tx, view, true)
 		},
+		IsDeploymentActive: bm.chain.IsDeploymentActive,
+	}
+	if cfg.NoDataCarrier {
+		// Transactions with data carrier outputs are not relayed when
+		// null data is not among the standard script classes.
+		txC.Policy.StandardScriptClasses = []txscript.ScriptClass{
+			txscript.ProvaTy,
+			txscript.GeneralProvaTy,
+			txscript.ProvaAdminTy,
+			txscript.ProvaTimelockTy,
+		}
 	}
 	s.txMemPool = mempool.New(&txC)
 
@@ -2425,10 +3604,12 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	// NOTE: The CPU miner relies on the mempool, so the mempool has to be
 	// created before calling the function to create the CPU miner.
 	policy := mining.Policy{
-		BlockMinSize:      cfg.BlockMinSize,
-		BlockMaxSize:      cfg.BlockMaxSize,
-		BlockPrioritySize: cfg.BlockPrioritySize,
-		TxMinFreeFee:      cfg.minRelayTxFee,
+		BlockMinSize:         cfg.BlockMinSize,
+		BlockMaxSize:         cfg.BlockMaxSize,
+		BlockPrioritySize:    cfg.BlockPrioritySize,
+		TxMinFreeFee:         cfg.minRelayTxFee,
+		BlockMaxDataCarriers: cfg.BlockMaxDataCarriers,
+		SignalDeployments:    cfg.signalDeployments,
 	}
 
 	blockTemplateGenerator := mining.NewBlkTmplGenerator(&policy, s.chainParams,
@@ -2442,6 +3623,9 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		IsCurrent:                bm.IsCurrent,
 		IsValidateKeyRateLimited: bm.chain.IsValidateKeyRateLimited,
 		AdminKeySets:             bm.chain.AdminKeySets,
+		ValidateKeys:             cfg.validateSigners,
+		ValidateKeyPolicy:        cfg.validateKeyPolicy,
+		ValidateKeyShare:         bm.chain.ValidateKeyShare,
 	})
 
 	// Only setup a function to return new addresses to connect to when
@@ -2465,11 +3649,35 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 				// in the same group so that we are not connecting
 				// to the same network segment at the expense of
 				// others.
-				key := addrmgr.GroupKey(addr.NetAddress())
+				na := addr.NetAddressV2()
+				key := addrmgr.GroupKeyV2(na)
 				if s.OutboundGroupCount(key) != 0 {
 					continue
 				}
 
+				// Skip I2P addresses since there is no transport
+				// to connect to them.
+				if na.Network == wire.NetI2P {
+					continue
+				}
+
+				// Skip addresses which are banned.
+				ip := na.IP()
+				if legacy := addr.NetAddress(); legacy != nil {
+					ip = legacy.IP
+				}
+				if ip != nil && s.banManager.IsBanned(ip) {
+					continue
+				}
+
+				// Skip addresses which are not whitelisted in
+				// whitelist-only mode unless peers are able to
+				// prove the handshake token.
+				if cfg.WhitelistOnly && cfg.HandshakeToken == "" &&
+					!isWhitelistedIP(ip) {
+					continue
+				}
+
 				// only allow recent nodes (10mins) after we failed 30
 				// times
 				if tries < 30 && time.Since(addr.LastAttempt()) < 10*time.Minute {
@@ -2477,12 +3685,12 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 				}
 
 				// allow nondefault ports after 50 failed tries.
-				if tries < 50 && fmt.Sprintf("%d", addr.NetAddress().Port) !=
+				if tries < 50 && fmt.Sprintf("%d", na.Port) !=
 					activeNetParams.DefaultPort {
 					continue
 				}
 
-				addrString := addrmgr.NetAddressKey(addr.NetAddress())
+				addrString := addrmgr.NetAddressV2Key(na)
 				return addrStringToNetAddr(addrString)
 			}
 
@@ -2495,14 +3703,50 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 	if cfg.MaxPeers < targetOutbound {
 		targetOutbound = cfg.MaxPeers
 	}
+
+	// Block relay only connections are only made automatically, which
+	// includes reconnecting to the anchors saved on the last shutdown.
+	// Anchors which are unable to be connected to are replaced by
+	// automatic block relay only connections.
+	var targetBlockRelayOnly int
+	if newAddressFunc != nil {
+		targetBlockRelayOnly = defaultTargetBlockRelayOnly
+		if cfg.MaxPeers-targetOutbound < targetBlockRelayOnly {
+			targetBlockRelayOnly = cfg.MaxPeers - targetOutbound
+		}
+
+		anchorsPath := filepath.Join(cfg.DataDir, anchorsFileName)
+		anchors, err := loadAnchors(anchorsPath)
+		if err != nil {
+			srvrLog.Warnf("Unable to load anchors: %v", err)
+		}
+		for _, addr := range anchors {
+			if len(s.anchors) == targetBlockRelayOnly {
+				break
+			}
+			netAddr, err := addrStringToNetAddr(addr)
+			if err != nil {
+				srvrLog.Warnf("Ignoring anchor %s: %v", addr, err)
+				continue
+			}
+			s.anchors = append(s.anchors, netAddr)
+		}
+		if len(s.anchors) > 0 {
+			srvrLog.Infof("Loaded %d anchors from %s", len(s.anchors),
+				anchorsPath)
+		}
+		targetBlockRelayOnly -= len(s.anchors)
+	}
 	cmgr, err := connmgr.New(&connmgr.Config{
-		Listeners:      listeners,
-		OnAccept:       s.inboundPeerConnected,
-		RetryDuration:  connectionRetryInterval,
-		TargetOutbound: uint32(targetOutbound),
-		Dial:           btcdDial,
-		OnConnection:   s.outboundPeerConnected,
-		GetNewAddress:  newAddressFunc,
+		Listeners:            listeners,
+		OnAccept:             s.inboundPeerConnected,
+		RetryDuration:        connectionRetryInterval,
+		TargetOutbound:       uint32(targetOutbound),
+		TargetBlockRelayOnly: uint32(targetBlockRelayOnly),
+		Dial:                 s.dialPeer,
+		OnConnection:         s.outboundPeerConnected,
+		GetNewAddress:        newAddressFunc,
+		BanManager:           s.banManager,
 	})
 	if err != nil {
 		return nil, err
@@ -2526,6 +3770,43 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 		})
 	}
 
+	if cfg.AutoProfileHeap > 0 || cfg.AutoProfileGoroutine > 0 ||
+		cfg.AutoProfileBlockTime > 0 {
+
+		s.autoProfiler = newAutoProfiler(cfg.AutoProfileDir,
+			cfg.AutoProfileHeap*1024*1024, cfg.AutoProfileGoroutine,
+			cfg.AutoProfileBlockTime, cfg.AutoProfileKeep)
+	}
+
+	if cfg.ScrubInterval > 0 {
+		s.blockScrubber = newBlockScrubber(s.db, cfg.ScrubInterval,
+			s.blockManager.RepairBlocks)
+	}
+
+	if cfg.HealthListen != "" {
+		s.healthListener, err = net.Listen("tcp", cfg.HealthListen)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.DNSSeedServer != "" {
+		if err := s.initDNSSeeder(); err != nil {
+			return nil, err
+		}
+	}
+
+	if endpoints := zmqEndpoints(cfg); len(endpoints) > 0 {
+		s.zmqNotifier, err = zmqpub.NewNotifier(endpoints)
+		if err != nil {
+			return nil, err
+		}
+		for topic, p := range s.zmqNotifier.Publishers() {
+			srvrLog.Infof("Publishing %s ZeroMQ notifications on %v",
+				topic, p.Endpoint())
+		}
+	}
+
 	if !cfg.DisableRPC {
 		s.rpcServer, err = newRPCServer(cfg.RPCListeners,
 			blockTemplateGenerator, &s)
@@ -2538,6 +3819,14 @@ func newServer(listenAddrs []string, db database.DB, chainParams *chaincfg.Param
 			<-s.rpcServer.RequestedProcessShutdown()
 			shutdownRequestChannel <- struct{}{}
 		}()
+
+		if cfg.GRPCListen != "" {
+			s.grpcServer, err = newGRPCServer(cfg.GRPCListen,
+				s.rpcServer)
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	return &s, nil
@@ -0,0 +1,51 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/bitgo/prova/peer"
+	"github.com/bitgo/prova/wire"
+)
+
+// TestServerPeerBlocksOnly ensures mempool requests are ignored in blocks only
+// mode while the fee filter of the peer is still stored.
+func TestServerPeerBlocksOnly(t *testing.T) {
+	origCfg := cfg
+	defer func() { cfg = origCfg }()
+
+	// The server does not have bloom filtering enabled, so a mempool
+	// request which is not ignored disconnects the peer.
+	newTestPeer := func() *serverPeer {
+		return &serverPeer{
+			Peer:   peer.NewInboundPeer(&peer.Config{}),
+			server: &server{},
+		}
+	}
+
+	cfg = &config{BlocksOnly: true}
+	sp := newTestPeer()
+	sp.OnMemPool(sp.Peer, wire.NewMsgMemPool())
+	if sp.disconnectRsn != "" {
+		t.Fatalf("OnMemPool: peer disconnected in blocks only mode: %v",
+			sp.disconnectRsn)
+	}
+
+	sp.OnFeeFilter(sp.Peer, wire.NewMsgFeeFilter(1000))
+	if got := atomic.LoadInt64(&sp.feeFilter); got != 1000 {
+		t.Fatalf("OnFeeFilter: got fee filter %d, want 1000", got)
+	}
+
+	// Ensure the same request is handled when blocks only mode is off.
+	cfg = &config{}
+	sp = newTestPeer()
+	sp.OnMemPool(sp.Peer, wire.NewMsgMemPool())
+	if sp.disconnectRsn == "" {
+		t.Fatal("OnMemPool: peer not disconnected with blocks only " +
+			"mode off")
+	}
+}
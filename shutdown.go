@@ -0,0 +1,77 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"time"
+)
+
+// shutdownProgressInterval is the interval at which the progress of shutdown
+// stages which are still running is logged.
+const shutdownProgressInterval = 5 * time.Second
+
+// shutdownStages runs the stages of the shutdown of the process so they
+// complete within a shared deadline.  The deadline starts with the first stage
+// which is run.
+type shutdownStages struct {
+	timeout  time.Duration
+	interval time.Duration
+	deadline time.Time
+}
+
+// newShutdownStages returns a new instance which bounds the duration of all
+// stages by the passed timeout.
+func newShutdownStages(timeout time.Duration) *shutdownStages {
+	return &shutdownStages{
+		timeout:  timeout,
+		interval: shutdownProgressInterval,
+	}
+}
+
+// run runs the passed stage and logs its progress.  It stops waiting for the
+// stage once the deadline has passed, in which case the stage keeps running in
+// the background and false is returned.  Every stage is given at least one
+// progress interval, so the final stages, such as flushing the database, are
+// still attempted when earlier stages used up the timeout.
+func (s *shutdownStages) run(name string, stage func()) bool {
+	now := time.Now()
+	if s.deadline.IsZero() {
+		s.deadline = now.Add(s.timeout)
+	}
+	remaining := s.deadline.Sub(now)
+	if remaining < s.interval {
+		remaining = s.interval
+	}
+
+	btcdLog.Infof("Shutdown: %s...", name)
+	done := make(chan struct{})
+	go func() {
+		stage()
+		close(done)
+	}()
+
+	timeout := time.NewTimer(remaining)
+	defer timeout.Stop()
+	progress := time.NewTicker(s.interval)
+	defer progress.Stop()
+	for {
+		select {
+		case <-done:
+			btcdLog.Infof("Shutdown: %s done (%v)", name,
+				time.Since(now))
+			return true
+
+		case <-progress.C:
+			btcdLog.Infof("Shutdown: still %s (%v elapsed, %v left)",
+				name, time.Since(now), s.deadline.Sub(time.Now()))
+
+		case <-timeout.C:
+			btcdLog.Errorf("Shutdown: gave up %s after %v -- the "+
+				"shutdown timeout of %v was exceeded", name,
+				time.Since(now), s.timeout)
+			return false
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestShutdownStages ensures stages share the deadline and that stages run
+// after the deadline passed are still given the progress interval.
+func TestShutdownStages(t *testing.T) {
+	t.Parallel()
+
+	stages := newShutdownStages(50 * time.Millisecond)
+	stages.interval = 20 * time.Millisecond
+
+	if !stages.run("fast", func() {}) {
+		t.Fatal("fast stage was not reported as done")
+	}
+
+	block := make(chan struct{})
+	defer close(block)
+	start := time.Now()
+	if stages.run("blocked", func() { <-block }) {
+		t.Fatal("blocked stage was reported as done")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("blocked stage was waited on for %v", elapsed)
+	}
+
+	// The deadline has passed, but the stage gets the progress interval.
+	ran := stages.run("final", func() { time.Sleep(5 * time.Millisecond) })
+	if !ran {
+		t.Fatal("final stage was not given the progress interval")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"fmt"
+
+	"github.com/bitgo/prova/btcec"
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/wire"
+)
+
+// sigCheck is a signature check deferred to a BatchVerifier along with the
+// input whose script performed it.
+type sigCheck struct {
+	sigHash chainhash.Hash
+	sig     *btcec.Signature
+	pubKey  *btcec.PublicKey
+	tx      *wire.MsgTx
+	txIdx   int
+}
+
+// BatchVerifier collects the signature checks of the scripts of many inputs,
+// possibly of different transactions, so they are verified together once the
+// scripts executed rather than one at a time while executing them.  Checks
+// which were already verified, either by an earlier check of the batch or as
+// recorded by the signature cache, are only verified once, and the scripts of
+// all inputs of the batch are checked for cheaper failures, such as missing
+// signatures, before any signature is verified.
+//
+// ECDSA signatures do not allow verifying several signatures at once faster
+// than verifying them one at a time, so the signatures of the batch are still
+// verified individually.
+//
+// Only the checks of an OP_CHECKSAFEMULTISIG which is the last opcode executed
+// by an input are deferred, since the boolean it pushes then decides whether
+// the input is valid, so a failing check invalidates the input both when it is
+// verified right away and when it is verified with the batch.  Other
+// signature checks are verified right away.
+//
+// A BatchVerifier is not safe for concurrent access.
+type BatchVerifier struct {
+	sigCache *SigCache
+	checks   []sigCheck
+}
+
+// NewBatchVerifier returns a new empty batch verifier which consults and
+// populates the passed signature cache, which may be nil.
+func NewBatchVerifier(sigCache *SigCache) *BatchVerifier {
+	return &BatchVerifier{sigCache: sigCache}
+}
+
+// Len returns the number of signature checks collected by the batch verifier
+// and not verified yet.
+func (b *BatchVerifier) Len() int {
+	return len(b.checks)
+}
+
+// add defers the passed signature check of the input at the passed index of the
+// passed transaction.
+func (b *BatchVerifier) add(sigHash []byte, sig *btcec.Signature,
+	pubKey *btcec.PublicKey, tx *wire.MsgTx, txIdx int) {
+
+	check := sigCheck{sig: sig, pubKey: pubKey, tx: tx, txIdx: txIdx}
+	copy(check.sigHash[:], sigHash)
+	b.checks = append(b.checks, check)
+}
+
+// Verify verifies every signature check collected by the batch verifier and
+// empties it.  It returns an error naming the input which performed the first
+// check that failed, if any.  The scripts of that input are invalid.
+func (b *BatchVerifier) Verify() error {
+	checks := b.checks
+	b.checks = nil
+
+	verified := make(map[chainhash.Hash][]*sigCheck, len(checks))
+	for i := range checks {
+		check := &checks[i]
+
+		// Skip checks which are identical to an earlier check of the
+		// batch, which already verified.
+		duplicate := false
+		for _, earlier := range verified[check.sigHash] {
+			if earlier.pubKey.IsEqual(check.pubKey) &&
+				earlier.sig.IsEqual(check.sig) {
+
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+		verified[check.sigHash] = append(verified[check.sigHash], check)
+
+		if b.sigCache != nil &&
+			b.sigCache.Exists(check.sigHash, check.sig, check.pubKey) {
+
+			continue
+		}
+		if !check.sig.Verify(check.sigHash[:], check.pubKey) {
+			str := fmt.Sprintf("signature of input %d of transaction "+
+				"%v does not verify", check.txIdx,
+				check.tx.TxHash())
+			return scriptError(ErrEvalFalse, str)
+		}
+		if b.sigCache != nil {
+			b.sigCache.Add(check.sigHash, check.sig, check.pubKey)
+		}
+	}
+	return nil
+}
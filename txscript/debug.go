@@ -0,0 +1,74 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package txscript
+
+import (
+	"bytes"
+)
+
+// StepInfo houses the state of the script engine right after it stepped over
+// an opcode.  It is passed to the StepCallback of the engine when debugging
+// why a script fails.
+type StepInfo struct {
+	// ScriptIndex is the index of the script the opcode belongs to.  Index
+	// 0 is the signature script, 1 is the public key script, and 2 is the
+	// redeem script of pay-to-script-hash spends.
+	ScriptIndex int
+
+	// OpcodeIndex is the index of the opcode within its script.
+	OpcodeIndex int
+
+	// Opcode is the disassembly of the opcode.
+	Opcode string
+
+	// Executed is false when the opcode was skipped since it is in a
+	// conditional branch which is not executing.
+	Executed bool
+
+	// Stack and AltStack are the contents of the data and alternate stacks
+	// after the opcode, where the last item is the top of the stack.
+	Stack    [][]byte
+	AltStack [][]byte
+
+	// RemainingScript is the one-line disassembly of the opcodes of the
+	// script which follow the opcode.
+	RemainingScript string
+}
+
+// StepCallback is invoked by the script engine with the state of the engine
+// after every opcode it successfully steps over.  Returning an error stops the
+// execution with that error, which is useful for breakpoints.
+type StepCallback func(info *StepInfo) error
+
+// SetStepCallback sets the callback the engine invokes after every opcode it
+// steps over, which allows the execution of a script pair to be traced one
+// opcode at a time.  Passing nil disables the callback.
+func (vm *Engine) SetStepCallback(callback StepCallback) {
+	vm.stepCallback = callback
+}
+
+// stepInfo returns the state of the engine after stepping over the opcode at
+// the current program counter, which must be valid.
+func (vm *Engine) stepInfo(executed bool) *StepInfo {
+	script := vm.scripts[vm.scriptIdx]
+
+	var remaining bytes.Buffer
+	for i := vm.scriptOff + 1; i < len(script); i++ {
+		if remaining.Len() > 0 {
+			remaining.WriteByte(' ')
+		}
+		remaining.WriteString(script[i].print(true))
+	}
+
+	return &StepInfo{
+		ScriptIndex:     vm.scriptIdx,
+		OpcodeIndex:     vm.scriptOff,
+		Opcode:          script[vm.scriptOff].print(true),
+		Executed:        executed,
+		Stack:           vm.GetStack(),
+		AltStack:        vm.GetAltStack(),
+		RemainingScript: remaining.String(),
+	}
+}
@@ -106,6 +106,9 @@ type Engine struct {
 	bip16           bool     // treat execution as pay-to-script-hash
 	savedFirstStack [][]byte // stack from first script for bip16 scripts
 	inputAmount     int64
+	stepCallback    StepCallback
+	batchVerifier   *BatchVerifier
+	sigHashes       map[SigHashType][]byte // signature hashes by hash type
 }
 
 // hasFlag returns whether the script engine instance has the passed flag set.
@@ -113,6 +116,45 @@ func (vm *Engine) hasFlag(flag ScriptFlags) bool {
 	return vm.flags&flag == flag
 }
 
+// isFinalOpcode returns whether or not the opcode being executed is the last
+// opcode of the last script the engine executes.  The public key script of a
+// pay-to-script-hash input is not final, since the redeem script follows.
+func (vm *Engine) isFinalOpcode() bool {
+	if vm.bip16 && vm.scriptIdx < 2 {
+		return false
+	}
+	return vm.scriptIdx == len(vm.scripts)-1 &&
+		vm.scriptOff == len(vm.scripts[vm.scriptIdx])-1 &&
+		len(vm.condStack) == 0
+}
+
+// calcSigHash returns the signature hash of the input of the engine for the
+// passed hash type.  The hash is calculated once per hash type, and the
+// partial sighashes of the transaction are calculated once when they were not
+// passed to NewEngine.
+func (vm *Engine) calcSigHash(hashType SigHashType) []byte {
+	if hash, ok := vm.sigHashes[hashType]; ok {
+		return hash
+	}
+	if vm.hashCache == nil {
+		vm.hashCache = NewTxSigHashes(&vm.tx)
+	}
+	hash := calcSignatureHashNew(nil, vm.hashCache, hashType, &vm.tx,
+		vm.txIdx, vm.inputAmount)
+	if vm.sigHashes == nil {
+		vm.sigHashes = make(map[SigHashType][]byte)
+	}
+	vm.sigHashes[hashType] = hash
+	return hash
+}
+
+// SetBatchVerifier defers the signature checks of the engine which are able to
+// be deferred to the passed batch verifier, as described by BatchVerifier.
+// The input is only valid when Execute succeeds and the batch verifies.
+func (vm *Engine) SetBatchVerifier(b *BatchVerifier) {
+	vm.batchVerifier = b
+}
+
 // isBranchExecuting returns whether or not the current conditional branch is
 // actively executing.  For example, when the data stack has an OP_FALSE on it
 // and an OP_IF is encountered, the branch is inactive until an OP_ELSE or
@@ -291,6 +333,7 @@ func (vm *Engine) Step() (done bool, err error) {
 		return true, err
 	}
 	opcode := &vm.scripts[vm.scriptIdx][vm.scriptOff]
+	executing := vm.isBranchExecuting() || opcode.isConditional()
 
 	// Execute the opcode while taking into account several things such as
 	// disabled opcodes, illegal opcodes, maximum allowed operations per
@@ -309,6 +352,13 @@ func (vm *Engine) Step() (done bool, err error) {
 		return false, scriptError(ErrStackOverflow, str)
 	}
 
+	// Report the state of the engine when stepping is being traced.
+	if vm.stepCallback != nil {
+		if err := vm.stepCallback(vm.stepInfo(executing)); err != nil {
+			return true, err
+		}
+	}
+
 	// Prepare for next instruction.
 	vm.scriptOff++
 	if vm.scriptOff >= len(vm.scripts[vm.scriptIdx]) {
@@ -6,9 +6,14 @@
 package txscript
 
 import (
+	"bytes"
+	"errors"
 	"testing"
 
+	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provasign"
+	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/wire"
 )
 
@@ -433,3 +438,205 @@ func TestCheckSignatureEncoding(t *testing.T) {
 		}
 	}
 }
+
+// TestStepCallback ensures the step callback of the engine is invoked with the
+// state of the engine after every opcode and an error it returns stops the
+// execution.
+func TestStepCallback(t *testing.T) {
+	t.Parallel()
+
+	tx := &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: 0},
+			SignatureScript:  mustParseShortForm("1 2"),
+			Sequence:         4294967295,
+		}},
+		TxOut: []*wire.TxOut{{Value: 1000000000}},
+	}
+	pkScript := mustParseShortForm("0 IF 1 ENDIF ADD 3 EQUAL")
+
+	vm, err := NewEngine(pkScript, tx, 0, 0, nil, nil, -1)
+	if err != nil {
+		t.Fatalf("NewEngine: unexpected error: %v", err)
+	}
+	var steps []StepInfo
+	vm.SetStepCallback(func(info *StepInfo) error {
+		steps = append(steps, *info)
+		return nil
+	})
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute: unexpected error: %v", err)
+	}
+
+	if len(steps) != 9 {
+		t.Fatalf("unexpected number of steps - got %d, want 9",
+			len(steps))
+	}
+	skipped := steps[4]
+	if skipped.ScriptIndex != 1 || skipped.OpcodeIndex != 2 ||
+		skipped.Opcode != "1" || skipped.Executed {
+
+		t.Fatalf("unexpected skipped step %+v", skipped)
+	}
+	add := steps[6]
+	if add.Opcode != "OP_ADD" || !add.Executed ||
+		add.RemainingScript != "3 OP_EQUAL" ||
+		len(add.Stack) != 1 || !bytes.Equal(add.Stack[0], []byte{3}) {
+
+		t.Fatalf("unexpected add step %+v", add)
+	}
+	if last := steps[len(steps)-1]; last.RemainingScript != "" {
+		t.Fatalf("unexpected remaining script %q after last step",
+			last.RemainingScript)
+	}
+
+	// An error returned by the callback stops the execution.
+	vm, err = NewEngine(pkScript, tx, 0, 0, nil, nil, -1)
+	if err != nil {
+		t.Fatalf("NewEngine: unexpected error: %v", err)
+	}
+	errBreak := errors.New("break")
+	count := 0
+	vm.SetStepCallback(func(info *StepInfo) error {
+		count++
+		if info.Opcode == "OP_ADD" {
+			return errBreak
+		}
+		return nil
+	})
+	if err := vm.Execute(); err != errBreak {
+		t.Fatalf("Execute: unexpected error - got %v, want %v", err,
+			errBreak)
+	}
+	if count != 7 {
+		t.Fatalf("unexpected number of steps - got %d, want 7", count)
+	}
+}
+
+// TestBatchVerifier ensures the signature checks of a final
+// OP_CHECKSAFEMULTISIG are deferred to the batch verifier of the engine, and
+// that the batch only verifies when the signatures are valid.
+func TestBatchVerifier(t *testing.T) {
+	t.Parallel()
+
+	key1, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	key2, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("NewPrivateKey: unexpected error: %v", err)
+	}
+	pkScript, err := NewScriptBuilder().AddInt64(2).
+		AddData(provautil.Hash160(key1.PubKey().SerializeCompressed())).
+		AddData(provautil.Hash160(key2.PubKey().SerializeCompressed())).
+		AddInt64(2).AddOp(OP_CHECKSAFEMULTISIG).Script()
+	if err != nil {
+		t.Fatalf("Script: unexpected error: %v", err)
+	}
+
+	// signedTx returns a transaction spending the output whose input is
+	// signed by both keys, with the signature of the second key made over
+	// the passed amount.
+	const amount = 1000000
+	signedTx := func(signedAmount int64) *wire.MsgTx {
+		tx := wire.NewMsgTx(1)
+		tx.AddTxIn(wire.NewTxIn(&wire.OutPoint{Hash: chainhash.Hash{1}},
+			nil))
+		tx.AddTxOut(wire.NewTxOut(amount-1000, pkScript))
+		sigHashes := provasign.NewSigHashes(tx)
+		sig1, err := provasign.RawTxInSignature(tx, 0, sigHashes, amount,
+			uint32(SigHashAll), key1)
+		if err != nil {
+			t.Fatalf("RawTxInSignature: unexpected error: %v", err)
+		}
+		sig2, err := provasign.RawTxInSignature(tx, 0, sigHashes,
+			signedAmount, uint32(SigHashAll), key2)
+		if err != nil {
+			t.Fatalf("RawTxInSignature: unexpected error: %v", err)
+		}
+		tx.TxIn[0].SignatureScript, err = NewScriptBuilder().
+			AddData(key1.PubKey().SerializeCompressed()).AddData(sig1).
+			AddData(key2.PubKey().SerializeCompressed()).AddData(sig2).
+			Script()
+		if err != nil {
+			t.Fatalf("Script: unexpected error: %v", err)
+		}
+		return tx
+	}
+
+	sigCache := NewSigCache(10)
+	batch := NewBatchVerifier(sigCache)
+	valid := signedTx(amount)
+	invalid := signedTx(amount + 1)
+	for _, tx := range []*wire.MsgTx{valid, valid, invalid} {
+		vm, err := NewEngine(pkScript, tx, 0, StandardVerifyFlags, nil,
+			nil, amount)
+		if err != nil {
+			t.Fatalf("NewEngine: unexpected error: %v", err)
+		}
+		vm.SetBatchVerifier(batch)
+		if err := vm.Execute(); err != nil {
+			t.Fatalf("Execute: unexpected error: %v", err)
+		}
+	}
+	if batch.Len() != 6 {
+		t.Fatalf("Len: got %d deferred checks, want 6", batch.Len())
+	}
+	err = batch.Verify()
+	if !IsErrorCode(err, ErrEvalFalse) {
+		t.Fatalf("Verify: unexpected error - got %v, want %v", err,
+			ErrEvalFalse)
+	}
+	if batch.Len() != 0 {
+		t.Fatalf("Len: got %d checks after Verify, want 0", batch.Len())
+	}
+
+	// The signatures which verified were added to the signature cache.
+	pushes, err := provasign.PushedData(valid.TxIn[0].SignatureScript)
+	if err != nil {
+		t.Fatalf("PushedData: unexpected error: %v", err)
+	}
+	sig1, err := btcec.ParseDERSignature(pushes[1][:len(pushes[1])-1],
+		btcec.S256())
+	if err != nil {
+		t.Fatalf("ParseDERSignature: unexpected error: %v", err)
+	}
+	hash, err := provasign.CalcSignatureHash(provasign.NewSigHashes(valid),
+		uint32(SigHashAll), valid, 0, amount)
+	if err != nil {
+		t.Fatalf("CalcSignatureHash: unexpected error: %v", err)
+	}
+	var sigHash chainhash.Hash
+	copy(sigHash[:], hash)
+	if !sigCache.Exists(sigHash, sig1, key1.PubKey()) {
+		t.Fatal("Verify: valid signature was not cached")
+	}
+
+	// The signature checks of the valid spend verify right away without
+	// the batch verifier, and the sighash is calculated once.
+	vm, err := NewEngine(pkScript, valid, 0, StandardVerifyFlags, nil,
+		nil, amount)
+	if err != nil {
+		t.Fatalf("NewEngine: unexpected error: %v", err)
+	}
+	if err := vm.Execute(); err != nil {
+		t.Fatalf("Execute: unexpected error: %v", err)
+	}
+	if len(vm.sigHashes) != 1 ||
+		!bytes.Equal(vm.sigHashes[SigHashAll], hash) {
+
+		t.Fatalf("unexpected cached sighashes %x", vm.sigHashes)
+	}
+
+	// Without the batch verifier, the invalid spend fails right away.
+	vm, err = NewEngine(pkScript, invalid, 0, StandardVerifyFlags, nil,
+		nil, amount)
+	if err != nil {
+		t.Fatalf("NewEngine: unexpected error: %v", err)
+	}
+	if err := vm.Execute(); err == nil {
+		t.Fatal("Execute: invalid spend succeeded")
+	}
+}
@@ -9,10 +9,12 @@ import (
 	"sync"
 
 	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provasign"
 	"github.com/bitgo/prova/wire"
 )
 
 // TxSigHashes houses the partial set of sighashes introduced within BIP0143.
+// It has the same layout as provasign.SigHashes, which calculates them.
 // This partial set of sighashes may be re-used within each input across a
 // transaction when validating all inputs. As a result, validation complexity
 // for SigHashAll can be reduced by a polynomial factor.
@@ -25,11 +27,7 @@ type TxSigHashes struct {
 // NewTxSigHashes computes, and returns the cached sighashes a the given
 // transaction.
 func NewTxSigHashes(tx *wire.MsgTx) *TxSigHashes {
-	return &TxSigHashes{
-		HashPrevOuts: calcHashPrevOuts(tx),
-		HashSequence: calcHashSequence(tx),
-		HashOutputs:  calcHashOutputs(tx),
-	}
+	return (*TxSigHashes)(provasign.NewSigHashes(tx))
 }
 
 // HashCache houses a set of partial sighashes keyed by txid. The set of partial
@@ -2214,17 +2214,13 @@ func opcodeCheckSafeMultiSig(op *parsedOpcode, vm *Engine) error {
 		signatures = append(signatures, sigInfo)
 	}
 
-	// Get script starting from the most recent OP_CODESEPARATOR.
-	// TODO(prova): Possibly remove
-	script := vm.subScript()
-
-	// Remove any of the signatures since there is no way for a signature to
-	// sign itself.
-	// TODO(prova): this will likely change -- need to figure out if we really need to include the script when signing
-	for _, sigInfo := range signatures {
-		script = removeOpcodeByData(script, sigInfo.signature)
-		script = removeOpcodeByData(script, sigInfo.pubKey)
-	}
+	// The signature hash digest does not commit to the script, so there is
+	// no need to strip the signatures from it as OP_CHECKMULTISIG does.
+	//
+	// The checks of the signatures are deferred to the batch verifier of
+	// the engine, if any, when this is the last opcode the input executes,
+	// since the pushed result then decides whether the input is valid.
+	deferChecks := vm.batchVerifier != nil && vm.isFinalOpcode()
 
 	success := true
 	// Initially increment, since we decrement immediately at the top of loop
@@ -2322,16 +2318,15 @@ func opcodeCheckSafeMultiSig(op *parsedOpcode, vm *Engine) error {
 			return err
 		}
 
-		// Create a new HashCache adding the intermediate sigHashes of this
-		// tx to it.
-		sigHashes := vm.hashCache
-		if sigHashes == nil {
-			sigHashes = NewTxSigHashes(&vm.tx)
-		}
-		// Generate the signature hash based on the signature hash type.
-		hash := calcSignatureHashNew(script, sigHashes, hashType, &vm.tx, vm.txIdx, vm.inputAmount)
+		// Generate the signature hash based on the signature hash type,
+		// which is shared by all signatures of the same type.
+		hash := vm.calcSigHash(hashType)
 		var valid bool
-		if vm.sigCache != nil {
+		if deferChecks {
+			vm.batchVerifier.add(hash, parsedSig, parsedPubKey, &vm.tx,
+				vm.txIdx)
+			valid = true
+		} else if vm.sigCache != nil {
 			var sigHash chainhash.Hash
 			copy(sigHash[:], hash)
 
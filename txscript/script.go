@@ -12,8 +12,10 @@ import (
 	"fmt"
 	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provasign"
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/wire"
+	"strings"
 	"time"
 )
 
@@ -241,6 +243,118 @@ func DisasmString(buf []byte) (string, error) {
 	return disbuf.String(), err
 }
 
+// adminOpNames maps the admin op codes to the names of the operations, which
+// label admin operations in annotated disassemblies.
+var adminOpNames = map[byte]string{
+	AdminOpIssueKeyAdd:        "issuekeyadd",
+	AdminOpIssueKeyRevoke:     "issuekeyrevoke",
+	AdminOpProvisionKeyAdd:    "provisionkeyadd",
+	AdminOpProvisionKeyRevoke: "provisionkeyrevoke",
+	AdminOpValidateKeyAdd:     "validatekeyadd",
+	AdminOpValidateKeyRevoke:  "validatekeyrevoke",
+	AdminOpASPKeyAdd:          "aspkeyadd",
+	AdminOpASPKeyRevoke:       "aspkeyrevoke",
+}
+
+// annotateAdminOp returns the label of the data of an admin operation script of
+// structure <OP_RETURN><OP_DATA>, which names the operation and the key it
+// adds or revokes, or an empty string when the data is not a known operation.
+func annotateAdminOp(pops []parsedOpcode) string {
+	if len(pops) != 2 || pops[0].opcode.value != OP_RETURN ||
+		(pops[1].opcode.value != OP_DATA_34 &&
+			pops[1].opcode.value != OP_DATA_38) {
+
+		return ""
+	}
+	name, ok := adminOpNames[pops[1].data[0]]
+	if !ok {
+		return ""
+	}
+	isASPOp := pops[1].data[0] == AdminOpASPKeyAdd ||
+		pops[1].data[0] == AdminOpASPKeyRevoke
+	if isASPOp != (pops[1].opcode.value == OP_DATA_38) {
+		return ""
+	}
+	_, pubKey, err := ExtractAdminData(pops)
+	if err != nil {
+		return ""
+	}
+	label := fmt.Sprintf("<%s:%x", name, pubKey.SerializeCompressed())
+	if isASPOp {
+		_, _, keyID, _ := ExtractASPData(pops)
+		label += fmt.Sprintf(" keyid:%d", keyID)
+	}
+	return label + ">"
+}
+
+// annotateScript returns the labels which replace the disassembly of the
+// opcodes of the passed script in an annotated disassembly.  Opcodes which are
+// not labeled have an empty label.
+func annotateScript(pops []parsedOpcode) []string {
+	labels := make([]string, len(pops))
+	if isProvaTimelock(pops) {
+		lock, _ := asLock(pops[0])
+		if pops[1].opcode.value == OP_CHECKLOCKTIMEVERIFY {
+			labels[0] = fmt.Sprintf("<locktime:%d>", lock)
+		} else {
+			labels[0] = fmt.Sprintf("<sequence:%d>", lock)
+		}
+		copy(labels[timelockPrefixLen:],
+			annotateScript(pops[timelockPrefixLen:]))
+		return labels
+	}
+	switch {
+	case isGeneralProva(pops):
+		sLen := len(pops)
+		labels[0] = fmt.Sprintf("<required:%d>", asSmallInt(pops[0].opcode))
+		labels[sLen-2] = fmt.Sprintf("<keys:%d>",
+			asSmallInt(pops[sLen-2].opcode))
+		for i := 1; i < sLen-2; i++ {
+			if len(pops[i].data) == 20 {
+				labels[i] = fmt.Sprintf("<pkhash:%x>", pops[i].data)
+				continue
+			}
+			keyID, _ := asInt32(pops[i])
+			labels[i] = fmt.Sprintf("<keyid:%d>", keyID)
+		}
+
+	case isProvaAdmin(pops):
+		threadID := provautil.ThreadID(asSmallInt(pops[0].opcode))
+		labels[0] = fmt.Sprintf("<thread:%v>", threadID)
+
+	default:
+		if label := annotateAdminOp(pops); label != "" {
+			labels[1] = label
+		}
+	}
+	return labels
+}
+
+// DisasmStringAnnotated formats a disassembled script for one line printing
+// like DisasmString, except the Prova specific parts of standard Prova, admin
+// thread, and admin operation scripts are labeled with their meaning.  For
+// example, the key IDs of Prova scripts are shown as <keyid:1>, the thread of
+// admin thread scripts as <thread:provision>, and the data of admin operations
+// as the name of the operation along with its key, such as
+// <aspkeyadd:02ab... keyid:3>.  Other scripts are disassembled the same way as
+// DisasmString does.
+func DisasmStringAnnotated(buf []byte) (string, error) {
+	opcodes, err := ParseScript(buf)
+	if err != nil {
+		return DisasmString(buf)
+	}
+
+	labels := annotateScript(opcodes)
+	tokens := make([]string, len(opcodes))
+	for i := range opcodes {
+		tokens[i] = labels[i]
+		if tokens[i] == "" {
+			tokens[i] = opcodes[i].print(true)
+		}
+	}
+	return strings.Join(tokens, " "), nil
+}
+
 // removeOpcode will remove any opcode matching ``opcode'' from the opcode
 // stream in pkscript
 func removeOpcode(pkscript []parsedOpcode, opcode byte) []parsedOpcode {
@@ -267,7 +381,9 @@ func asInt32(pop parsedOpcode) (int32, error) {
 // We assume a Prova address structure like this:
 // basic: <2 hash keyID1 keyID2 3 OP_CHECKSAFEMULTISIG>
 // general: <x hash/keyID hash/keyID y OP_CHECKSAFEMULTISIG>
+// Either may be prefixed by a timelock.
 func ExtractKeyIDs(pkScript []parsedOpcode) ([]btcec.KeyID, error) {
+	pkScript = stripTimelock(pkScript)
 	// the basic structure has 6 elements, as described above
 	if len(pkScript) < 6 || !isSmallInt(pkScript[len(pkScript)-2].opcode) {
 		return nil, fmt.Errorf("unable to extract keyIDs from script, "+
@@ -293,7 +409,9 @@ func ExtractKeyIDs(pkScript []parsedOpcode) ([]btcec.KeyID, error) {
 // We assume a Prova address structure like this:
 // basic: <2 hash keyID1 keyID2 3 OP_CHECKSAFEMULTISIG>
 // general: <x hash/keyID hash/keyID y OP_CHECKSAFEMULTISIG>
+// Either may be prefixed by a timelock, which is kept.
 func ReplaceKeyIDs(pkScript []parsedOpcode, keyIdMap map[btcec.KeyID][]byte) error {
+	pkScript = stripTimelock(pkScript)
 	// the basic structure has 6 elements, as described above
 	if len(pkScript) < 6 || !isSmallInt(pkScript[len(pkScript)-2].opcode) {
 		return fmt.Errorf("unable to extract keyIDs from script, "+
@@ -578,131 +696,19 @@ func calcSignatureHash(script []parsedOpcode, hashType SigHashType, tx *wire.Msg
 	return chainhash.DoubleHashB(wbuf.Bytes())
 }
 
-// calcHashPrevOuts calculates a single hash of all the previous outputs
-// (txid:index) referenced within the passed transaction. This calculated hash
-// can be re-used when validating all inputs with signature hash type of
-// SigHashAll. This allows validation to re-use previous hashing computation,
-// reducing the complexity of validating SigHashAll inputs from  O(N^2) to O(N).
-func calcHashPrevOuts(tx *wire.MsgTx) chainhash.Hash {
-	var b bytes.Buffer
-	for _, in := range tx.TxIn {
-		// First write out the 32-byte transaction ID one of whose
-		// outputs are being referenced by this input.
-		b.Write(in.PreviousOutPoint.Hash[:])
-
-		// Next, we'll encode the index of the referenced output as a
-		// little endian integer.
-		var buf [4]byte
-		binary.LittleEndian.PutUint32(buf[:], in.PreviousOutPoint.Index)
-		b.Write(buf[:])
-	}
-
-	return chainhash.DoubleHashH(b.Bytes())
-}
-
-// calcHashSequence computes an aggregated hash of each of the sequence numbers
-// within the inputs of the passed transaction. This single hash can be re-used
-// when validating all inputs with signature hash type of
-// SigHashAll. This allows validation to re-use previous hashing computation,
-// reducing the complexity of validating SigHashAll inputs from  O(N^2) to O(N).
-func calcHashSequence(tx *wire.MsgTx) chainhash.Hash {
-	var b bytes.Buffer
-	for _, in := range tx.TxIn {
-		var buf [4]byte
-		binary.LittleEndian.PutUint32(buf[:], in.Sequence)
-		b.Write(buf[:])
-	}
-	return chainhash.DoubleHashH(b.Bytes())
-}
-
-// calcHashOutputs computes a hash digest of all outputs created by the
-// transaction encoded using the wire format. This single hash can be re-used
-// when validating all inputs with signature hash type of
-// SigHashAll. This allows validation to re-use previous hashing computation,
-// reducing the complexity of validating SigHashAll inputs from  O(N^2) to O(N).
-func calcHashOutputs(tx *wire.MsgTx) chainhash.Hash {
-	var b bytes.Buffer
-	for _, out := range tx.TxOut {
-		wire.WriteTxOut(&b, 0, 0, out)
-	}
-	return chainhash.DoubleHashH(b.Bytes())
-}
-
 // calcSignatureHashNew computes the sighash digest of a transaction's input
-// using the new, optimized digest calculation algorithm defined in BIP0143:
-// https://github.com/bitcoin/bips/blob/master/bip-0143.mediawiki.
-// This function makes use of pre-calculated sighash fragments stored within
-// the passed HashCache to eliminate duplicate hashing computations when
-// calculating the final digest, reducing the complexity from O(N^2) to O(N).
-// Additionally, signatures now cover the input value of the referenced unspent
-// output. This allows offline, or hardware wallets to compute the exact amount
-// being spent, in addition to the final transaction fee. In the case the
-// wallet if fed an invalid input amount, the real sighash will differ causing
-// the produced signature to be invalid.
+// using the digest calculation algorithm of the provasign package, which is
+// derived from BIP0143 and does not commit to the passed script.  It returns
+// nil when the passed input index is out of range.
 func calcSignatureHashNew(subScript []parsedOpcode, sigHashes *TxSigHashes,
 	hashType SigHashType, tx *wire.MsgTx, idx int, amt int64) []byte {
 
-	// As a sanity check, ensure the passed input index for the transaction
-	// is valid.
-	if idx >= len(tx.TxIn) {
-		fmt.Errorf("calcSignatureHashNew error: idx %d but %d txins",
-			idx, len(tx.TxIn))
+	hash, err := provasign.CalcSignatureHash(
+		(*provasign.SigHashes)(sigHashes), uint32(hashType), tx, idx, amt)
+	if err != nil {
 		return nil
 	}
-
-	// For now we only accept SigHashAll transactions
-	if hashType != SigHashAll {
-		fmt.Errorf("calcSignatureHashNew error: idx %d with wrong hashType %v.",
-			idx, hashType)
-	}
-
-	// We'll utilize this buffer throughout to incrementally calculate
-	// the signature hash for this transaction.
-	var sigHash bytes.Buffer
-
-	// First write out, then encode the transaction's version number.
-	var bVersion [4]byte
-	binary.LittleEndian.PutUint32(bVersion[:], uint32(tx.Version))
-	sigHash.Write(bVersion[:])
-
-	// Next, write the cached hashPrevOuts.
-	sigHash.Write(sigHashes.HashPrevOuts[:])
-
-	// Next, write the cached hashSequence
-	sigHash.Write(sigHashes.HashSequence[:])
-
-	// Next, write the outpoint being spent.
-	sigHash.Write(tx.TxIn[idx].PreviousOutPoint.Hash[:])
-	var bIndex [4]byte
-	binary.LittleEndian.PutUint32(bIndex[:], tx.TxIn[idx].PreviousOutPoint.Index)
-	sigHash.Write(bIndex[:])
-
-	// In BIP 143, we would write the scriptCode of the input itself here.
-	// The script code can be relevant to certain hardware wallets.
-	// There is no use-case for this in the Prova chain.
-
-	// Next, add the input amount, and sequence number of the input being
-	// signed.
-	var bAmount [8]byte
-	binary.LittleEndian.PutUint64(bAmount[:], uint64(amt))
-	sigHash.Write(bAmount[:])
-	var bSequence [4]byte
-	binary.LittleEndian.PutUint32(bSequence[:], tx.TxIn[idx].Sequence)
-	sigHash.Write(bSequence[:])
-
-	// Next, add the  pre-generated hashoutputs sighash fragment.
-	sigHash.Write(sigHashes.HashOutputs[:])
-
-	// Finally, write out the transaction's locktime, and the sig hash
-	// type.
-	var bLockTime [4]byte
-	binary.LittleEndian.PutUint32(bLockTime[:], tx.LockTime)
-	sigHash.Write(bLockTime[:])
-	var bHashType [4]byte
-	binary.LittleEndian.PutUint32(bHashType[:], uint32(hashType))
-	sigHash.Write(bHashType[:])
-
-	return chainhash.DoubleHashB(sigHash.Bytes())
+	return hash
 }
 
 // asSmallInt returns the passed opcode, which must be true according to
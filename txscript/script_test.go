@@ -4163,3 +4163,84 @@ func TestIsUnspendable(t *testing.T) {
 		}
 	}
 }
+
+// TestDisasmStringAnnotated ensures the Prova specific parts of scripts are
+// labeled in annotated disassemblies while other scripts are disassembled the
+// same way as DisasmString does.
+func TestDisasmStringAnnotated(t *testing.T) {
+	t.Parallel()
+
+	pubKey := "025ceeba2ab4a635df2c0301a3d773da06ac5a18a7c3e0d09a795d7e57d233edf1"
+	pkHash := "1a2b3c4d5e6f708192a3b4c5d6e7f8091a2b3c4d"
+	tests := []struct {
+		name     string
+		script   string
+		expected string
+	}{
+		{
+			name:     "prova",
+			script:   "2 DATA_20 0x" + pkHash + " 1 DATA_2 0x2c01 3 CHECKSAFEMULTISIG",
+			expected: "<required:2> <pkhash:" + pkHash + "> <keyid:1> <keyid:300> <keys:3> OP_CHECKSAFEMULTISIG",
+		},
+		{
+			name:     "prova with lock time",
+			script:   "500000 CHECKLOCKTIMEVERIFY DROP 2 DATA_20 0x" + pkHash + " 1 2 3 CHECKSAFEMULTISIG",
+			expected: "<locktime:500000> OP_CHECKLOCKTIMEVERIFY OP_DROP <required:2> <pkhash:" + pkHash + "> <keyid:1> <keyid:2> <keys:3> OP_CHECKSAFEMULTISIG",
+		},
+		{
+			name:     "prova with sequence lock",
+			script:   "144 CHECKSEQUENCEVERIFY DROP 2 DATA_20 0x" + pkHash + " 1 2 3 CHECKSAFEMULTISIG",
+			expected: "<sequence:144> OP_CHECKSEQUENCEVERIFY OP_DROP <required:2> <pkhash:" + pkHash + "> <keyid:1> <keyid:2> <keys:3> OP_CHECKSAFEMULTISIG",
+		},
+		{
+			name:     "admin thread",
+			script:   "1 CHECKTHREAD",
+			expected: "<thread:provision> OP_CHECKTHREAD",
+		},
+		{
+			name:     "admin operation",
+			script:   "RETURN DATA_34 0x11" + pubKey,
+			expected: "OP_RETURN <validatekeyadd:" + pubKey + ">",
+		},
+		{
+			name:     "asp admin operation",
+			script:   "RETURN DATA_38 0x13" + pubKey + "03000000",
+			expected: "OP_RETURN <aspkeyadd:" + pubKey + " keyid:3>",
+		},
+		{
+			name:     "asp admin operation without key id",
+			script:   "RETURN DATA_34 0x13" + pubKey,
+			expected: "OP_RETURN 13" + pubKey,
+		},
+		{
+			name:     "unknown admin operation",
+			script:   "RETURN DATA_34 0x7f" + pubKey,
+			expected: "OP_RETURN 7f" + pubKey,
+		},
+		{
+			name:     "nonstandard",
+			script:   "DUP HASH160 DATA_20 0x" + pkHash + " EQUALVERIFY CHECKSIG",
+			expected: "OP_DUP OP_HASH160 " + pkHash + " OP_EQUALVERIFY OP_CHECKSIG",
+		},
+	}
+
+	for _, test := range tests {
+		script := mustParseShortForm(test.script)
+		got, err := DisasmStringAnnotated(script)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("%s: got %q, want %q", test.name, got,
+				test.expected)
+		}
+	}
+
+	// Scripts which fail to parse are disassembled the same way as
+	// DisasmString does.
+	_, err := DisasmStringAnnotated([]byte{OP_DATA_2, 0x01})
+	if err == nil {
+		t.Errorf("unexpected success for truncated script")
+	}
+}
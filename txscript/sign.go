@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg"
+	"github.com/bitgo/prova/provasign"
 	"github.com/bitgo/prova/provautil"
 	"github.com/bitgo/prova/wire"
 	"sort"
@@ -34,23 +35,18 @@ func RawTxInSignature(tx *wire.MsgTx, idx int, subScript []byte,
 }
 
 // RawTxInSignatureNew returns the serialized ECDSA signature for the input idx of
-// the given transaction, with hashType appended to it.
+// the given transaction, with hashType appended to it.  The signature is created
+// by the provasign package, which does not commit to subScript.
 // TODO(prova): need to cleanup the old/new versions
 func RawTxInSignatureNew(tx *wire.MsgTx, idx int, txSigHashes *TxSigHashes, amt int64, subScript []byte,
 	hashType SigHashType, key *btcec.PrivateKey) ([]byte, error) {
 
-	parsedScript, err := ParseScript(subScript)
-	if err != nil {
+	if _, err := ParseScript(subScript); err != nil {
 		return nil, fmt.Errorf("cannot parse output script: %v", err)
 	}
 
-	hash := calcSignatureHashNew(parsedScript, txSigHashes, hashType, tx, idx, amt)
-	signature, err := key.Sign(hash)
-	if err != nil {
-		return nil, fmt.Errorf("cannot sign tx input: %s", err)
-	}
-
-	return append(signature.Serialize(), byte(hashType)), nil
+	return provasign.RawTxInSignature(tx, idx,
+		(*provasign.SigHashes)(txSigHashes), amt, uint32(hashType), key)
 }
 
 // SignatureScript creates an input signature script for tx to spend RMG sent
@@ -133,7 +129,7 @@ func sign(chainParams *chaincfg.Params, tx *wire.MsgTx, idx int, inputAmt int64,
 	}
 
 	switch class {
-	case ProvaTy:
+	case ProvaTy, ProvaTimelockTy:
 		// We use the keysDb lookup to get a list of privKeys
 		// that are needed for signing.
 		keys, err := kdb.GetKey(addresses[0])
@@ -175,7 +171,7 @@ func mergeScripts(chainParams *chaincfg.Params, tx *wire.MsgTx, idx int,
 	nRequired int, sigScript, prevScript []byte) []byte {
 
 	switch class {
-	case ProvaTy:
+	case ProvaTy, ProvaTimelockTy:
 		return mergeProvaSig(tx, idx, addresses, nRequired, pkScript,
 			sigScript, prevScript)
 	case ProvaAdminTy:
@@ -96,6 +96,15 @@ func mkGetKey(keys map[string]addressToKey) KeyDB {
 }
 
 func checkScripts(msg string, tx *wire.MsgTx, idx int, inputAmt int64, sigScript []byte, pkScript []byte) error {
+	return checkScriptsWithFlags(msg, tx, idx, inputAmt, sigScript,
+		pkScript, ScriptBip16|ScriptVerifyDERSignatures)
+}
+
+// checkScriptsWithFlags is like checkScripts, except the scripts are executed
+// with the passed flags.
+func checkScriptsWithFlags(msg string, tx *wire.MsgTx, idx int, inputAmt int64,
+	sigScript []byte, pkScript []byte, flags ScriptFlags) error {
+
 	tx.TxIn[idx].SignatureScript = sigScript
 
 	// Before passing the script to the VM, we check whether it is an Prova script.
@@ -125,7 +134,7 @@ func checkScripts(msg string, tx *wire.MsgTx, idx int, inputAmt int64, sigScript
 
 	keyView.SetKeys(keySets)
 	// If script is Prova script, we replace all keyIDs with pubKeyHashes.
-	if TypeOfScript(pops) == ProvaTy {
+	if class := TypeOfScript(pops); class == ProvaTy || class == ProvaTimelockTy {
 		keyIDs, err := ExtractKeyIDs(pops)
 		keyIdMap := keyView.LookupKeyIDs(keyIDs)
 		ReplaceKeyIDs(pops, keyIdMap)
@@ -145,8 +154,7 @@ func checkScripts(msg string, tx *wire.MsgTx, idx int, inputAmt int64, sigScript
 		}
 	}
 
-	vm, err := NewEngine(pkScript, tx, idx,
-		ScriptBip16|ScriptVerifyDERSignatures, nil, nil, inputAmt)
+	vm, err := NewEngine(pkScript, tx, idx, flags, nil, nil, inputAmt)
 	if err != nil {
 		return fmt.Errorf("failed to make script engine for %s: %v",
 			msg, err)
@@ -691,6 +699,133 @@ var sigScriptTests = []tstSigScript{
 	},
 }
 
+// TestSignTimelockedTxOutput ensures outputs to timelocked Prova scripts are
+// signed like outputs to the Prova scripts they lock, and that the signed
+// inputs are only valid once their timelock expired.
+func TestSignTimelockedTxOutput(t *testing.T) {
+	t.Parallel()
+
+	keyId1 := btcec.KeyIDFromAddressBuffer([]byte{0, 0, 1, 0})
+	key1, _ := btcec.PrivKeyFromBytes(btcec.S256(), []byte{
+		0xea, 0xf0, 0x2c, 0xa3, 0x48, 0xc5, 0x24, 0xe6,
+		0x39, 0x26, 0x55, 0xba, 0x4d, 0x29, 0x60, 0x3c,
+		0xd1, 0xa7, 0x34, 0x7d, 0x9d, 0x65, 0xcf, 0xe9,
+		0x3c, 0xe1, 0xeb, 0xff, 0xdc, 0xa2, 0x26, 0x94,
+	})
+	keyId2 := btcec.KeyIDFromAddressBuffer([]byte{1, 0, 0, 0})
+	key3, err := btcec.NewPrivateKey(btcec.S256())
+	if err != nil {
+		t.Fatalf("failed to make privKey: %v", err)
+	}
+	pkHash := provautil.Hash160(key3.PubKey().SerializeCompressed())
+	addr, err := provautil.NewAddressProva(pkHash,
+		[]btcec.KeyID{keyId1, keyId2}, &chaincfg.TestNetParams)
+	if err != nil {
+		t.Fatalf("failed to make Prova address: %v", err)
+	}
+	kdb := KeyClosure(func(provautil.Address) ([]PrivateKey, error) {
+		return []PrivateKey{{key1, true}, {key3, true}}, nil
+	})
+
+	hash, _ := chainhash.NewHashFromStr("08886fe11cc704bc617ebaf50f8bed16a66da84141d26d786a054f2c361c905a")
+	flags := ScriptBip16 | ScriptVerifyDERSignatures |
+		ScriptVerifyCheckLockTimeVerify | ScriptVerifyCheckSequenceVerify
+
+	tests := []struct {
+		name     string
+		lockOp   byte
+		lock     uint32
+		version  int32
+		lockTime uint32
+		sequence uint32
+		valid    bool
+	}{
+		{
+			name:     "lock time expired",
+			lockOp:   OP_CHECKLOCKTIMEVERIFY,
+			lock:     500,
+			version:  1,
+			lockTime: 500,
+			sequence: 0,
+			valid:    true,
+		},
+		{
+			name:     "lock time not expired",
+			lockOp:   OP_CHECKLOCKTIMEVERIFY,
+			lock:     500,
+			version:  1,
+			lockTime: 499,
+			sequence: 0,
+		},
+		{
+			name:     "lock time of finalized input",
+			lockOp:   OP_CHECKLOCKTIMEVERIFY,
+			lock:     500,
+			version:  1,
+			lockTime: 500,
+			sequence: wire.MaxTxInSequenceNum,
+		},
+		{
+			name:     "sequence lock expired",
+			lockOp:   OP_CHECKSEQUENCEVERIFY,
+			lock:     144,
+			version:  2,
+			sequence: 144,
+			valid:    true,
+		},
+		{
+			name:     "sequence lock not expired",
+			lockOp:   OP_CHECKSEQUENCEVERIFY,
+			lock:     144,
+			version:  2,
+			sequence: 143,
+		},
+		{
+			name:     "sequence lock of version 1 transaction",
+			lockOp:   OP_CHECKSEQUENCEVERIFY,
+			lock:     144,
+			version:  1,
+			sequence: 144,
+		},
+	}
+
+	for _, test := range tests {
+		var pkScript []byte
+		if test.lockOp == OP_CHECKLOCKTIMEVERIFY {
+			pkScript, err = PayToAddrLockTimeScript(addr, test.lock)
+		} else {
+			pkScript, err = PayToAddrSequenceLockScript(addr,
+				test.lock)
+		}
+		if err != nil {
+			t.Errorf("%s: failed to make script: %v", test.name, err)
+			continue
+		}
+
+		tx := wire.NewMsgTx(test.version)
+		txIn := wire.NewTxIn(wire.NewOutPoint(hash, 0), nil)
+		txIn.Sequence = test.sequence
+		tx.AddTxIn(txIn)
+		tx.AddTxOut(wire.NewTxOut(1000000000, pkScript))
+		tx.LockTime = test.lockTime
+
+		sigScript, err := SignTxOutput(&chaincfg.TestNetParams, tx, 0,
+			5000000000, pkScript, SigHashAll, kdb, nil)
+		if err != nil {
+			t.Errorf("%s: failed to sign output: %v", test.name, err)
+			continue
+		}
+		err = checkScriptsWithFlags(test.name, tx, 0, 5000000000,
+			sigScript, pkScript, flags)
+		if test.valid && err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: unexpected valid input", test.name)
+		}
+	}
+}
+
 // Test the sigscript generation for valid and invalid inputs, all
 // hashTypes, and with and without compression.  This test creates
 // sigscripts to spend fake coinbase inputs, as sigscripts cannot be
@@ -7,6 +7,7 @@ package txscript
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/bitgo/prova/btcec"
 	"github.com/bitgo/prova/chaincfg"
@@ -47,26 +48,28 @@ type ScriptClass byte
 
 // Classes of script payment known about in the blockchain.
 const (
-	NonStandardTy  ScriptClass = iota // None of the recognized forms.
-	PubKeyTy                          // Pay pubkey.
-	PubKeyHashTy                      // Pay pubkey hash.
-	ScriptHashTy                      // Pay to script hash.
-	MultiSigTy                        // Multi signature.
-	NullDataTy                        // Empty data-only (provably prunable).
-	ProvaTy                           // Prova standard 2-of-3 type (subset of GeneralProvaTy)
-	GeneralProvaTy                    // Prova (generalized m-of-n) script
-	ProvaAdminTy                      // Prova Admin Operations
+	NonStandardTy   ScriptClass = iota // None of the recognized forms.
+	PubKeyTy                           // Pay pubkey.
+	PubKeyHashTy                       // Pay pubkey hash.
+	ScriptHashTy                       // Pay to script hash.
+	MultiSigTy                         // Multi signature.
+	NullDataTy                         // Empty data-only (provably prunable).
+	ProvaTy                            // Prova standard 2-of-3 type (subset of GeneralProvaTy)
+	GeneralProvaTy                     // Prova (generalized m-of-n) script
+	ProvaAdminTy                       // Prova Admin Operations
+	ProvaTimelockTy                    // Prova script prefixed by a timelock
 )
 
 // scriptClassToName houses the human-readable strings which describe each
 // script class.
 var scriptClassToName = []string{
 	// TODO(prova): clean up non-used types
-	NonStandardTy:  "nonstandard",
-	NullDataTy:     "nulldata",
-	ProvaTy:        "safe_multisig",
-	GeneralProvaTy: "safe_multisig",
-	ProvaAdminTy:   "admin",
+	NonStandardTy:   "nonstandard",
+	NullDataTy:      "nulldata",
+	ProvaTy:         "safe_multisig",
+	GeneralProvaTy:  "safe_multisig",
+	ProvaAdminTy:    "admin",
+	ProvaTimelockTy: "safe_multisig_timelock",
 }
 
 // String implements the Stringer interface by returning the name of
@@ -178,9 +181,74 @@ func isProva(pops []parsedOpcode) bool {
 	return m == n-1
 }
 
+// timelockPrefixLen is the number of opcodes of the timelock which prefixes the
+// Prova script of a timelocked Prova script.
+const timelockPrefixLen = 3
+
+// asLock returns the lock time or sequence pushed by the passed opcode, and
+// whether it pushes a canonically encoded number which fits in 32 bits without
+// being negative.
+func asLock(pop parsedOpcode) (int64, bool) {
+	if isSmallInt(pop.opcode) {
+		return int64(asSmallInt(pop.opcode)), true
+	}
+	if pop.opcode.value > OP_PUSHDATA4 || !canonicalPush(pop) {
+		return 0, false
+	}
+	lock, err := makeScriptNum(pop.data, true, 5)
+	if err != nil || lock < 0 || lock > math.MaxUint32 {
+		return 0, false
+	}
+	return int64(lock), true
+}
+
+// isProvaTimelock returns true if the passed script is a Prova script, either a
+// standard or a generalized one, which can only be spent once an absolute or a
+// relative timelock expired:
+// <lock> OP_CHECKLOCKTIMEVERIFY|OP_CHECKSEQUENCEVERIFY OP_DROP <prova script>
+func isProvaTimelock(pops []parsedOpcode) bool {
+	if len(pops) <= timelockPrefixLen {
+		return false
+	}
+	if pops[1].opcode.value != OP_CHECKLOCKTIMEVERIFY &&
+		pops[1].opcode.value != OP_CHECKSEQUENCEVERIFY {
+		return false
+	}
+	if pops[2].opcode.value != OP_DROP {
+		return false
+	}
+	if _, ok := asLock(pops[0]); !ok {
+		return false
+	}
+	return isGeneralProva(pops[timelockPrefixLen:])
+}
+
+// stripTimelock returns the Prova script of the passed script when it is a
+// timelocked Prova script, and the passed script otherwise.  The returned
+// script shares the opcodes of the passed one.
+func stripTimelock(pops []parsedOpcode) []parsedOpcode {
+	if isProvaTimelock(pops) {
+		return pops[timelockPrefixLen:]
+	}
+	return pops
+}
+
+// ExtractTimelock returns the timelock opcode, either OP_CHECKLOCKTIMEVERIFY or
+// OP_CHECKSEQUENCEVERIFY, and the lock time or sequence the passed timelocked
+// Prova public key script is locked with.  The returned opcode is OP_0 when the
+// script is not a timelocked Prova script.
+func ExtractTimelock(pkScript []byte) (byte, int64) {
+	pops, err := ParseScript(pkScript)
+	if err != nil || !isProvaTimelock(pops) {
+		return OP_0, 0
+	}
+	lock, _ := asLock(pops[0])
+	return pops[1].opcode.value, lock
+}
+
 // IsProvaTx determines if a transaction is a standard prova transaction
-// consisting of only outputs to standard prova scripts and 0-value nulldata
-// scripts.
+// consisting of only outputs to standard or timelocked prova scripts and
+// 0-value nulldata scripts.
 func IsProvaTx(tx *provautil.Tx) bool {
 	msgTx := tx.MsgTx()
 
@@ -205,7 +273,7 @@ func IsProvaTx(tx *provautil.Tx) bool {
 			if atoms != 0 {
 				return false
 			}
-		} else if !isGeneralProva(pops) {
+		} else if !isGeneralProva(pops) && !isProvaTimelock(pops) {
 			return false
 		}
 	}
@@ -344,6 +412,8 @@ func typeOfScript(pops []parsedOpcode) ScriptClass {
 		return GeneralProvaTy
 	} else if isProvaAdmin(pops) {
 		return ProvaAdminTy
+	} else if isProvaTimelock(pops) {
+		return ProvaTimelockTy
 	}
 	return NonStandardTy
 }
@@ -434,6 +504,48 @@ func PayToAddrScript(addr provautil.Address) ([]byte, error) {
 	return nil, scriptError(ErrUnsupportedAddress, "unsupported address type")
 }
 
+// payToAddrTimelockScript creates a new script to pay a transaction output to
+// the specified address once the passed timelock expired.
+func payToAddrTimelockScript(addr provautil.Address, lockOp byte,
+	lock int64) ([]byte, error) {
+
+	script, err := PayToAddrScript(addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewScriptBuilder().
+		AddInt64(lock).
+		AddOp(lockOp).
+		AddOp(OP_DROP).
+		AddOps(script).
+		Script()
+}
+
+// PayToAddrLockTimeScript creates a new script to pay a transaction output to
+// the specified address which can only be spent by a transaction whose lock
+// time is at least the passed lock time.  Like the lock time of a transaction,
+// the lock time is either a block height or a unix timestamp.
+func PayToAddrLockTimeScript(addr provautil.Address, lockTime uint32) ([]byte, error) {
+	return payToAddrTimelockScript(addr, OP_CHECKLOCKTIMEVERIFY,
+		int64(lockTime))
+}
+
+// PayToAddrSequenceLockScript creates a new script to pay a transaction output
+// to the specified address which can only be spent by an input whose relative
+// lock time, as encoded by its sequence number, is at least the one encoded by
+// the passed sequence number.  An Error with the error code
+// ErrUnsatisfiedLockTime is returned when the passed sequence number disables
+// relative lock times.
+func PayToAddrSequenceLockScript(addr provautil.Address, sequence uint32) ([]byte, error) {
+	if sequence&wire.SequenceLockTimeDisabled != 0 {
+		str := fmt.Sprintf("sequence %x disables relative lock times",
+			sequence)
+		return nil, scriptError(ErrUnsatisfiedLockTime, str)
+	}
+	return payToAddrTimelockScript(addr, OP_CHECKSEQUENCEVERIFY,
+		int64(sequence))
+}
+
 // ProvaThreadScript creates a new script to pay a transaction output to an
 // Prova Admin Thread.
 func ProvaThreadScript(threadID provautil.ThreadID) ([]byte, error) {
@@ -512,9 +624,19 @@ func ExtractPkScriptAddrs(pkScript []byte, chainParams *chaincfg.Params) (Script
 	}
 
 	scriptClass := typeOfScript(pops)
+
+	// Timelocked Prova scripts have the addresses and required signatures
+	// of the Prova script they lock.
+	if scriptClass == ProvaTimelockTy {
+		pops = pops[timelockPrefixLen:]
+		if !isProva(pops) {
+			return scriptClass, nil, 0, nil
+		}
+	}
+
 	switch scriptClass {
 
-	case ProvaTy:
+	case ProvaTy, ProvaTimelockTy:
 		numKeyIDs := len(pops) - 4
 		requiredSigs = numKeyIDs
 		keyIDError := false
@@ -548,3 +670,32 @@ func ExtractPkScriptAddrs(pkScript []byte, chainParams *chaincfg.Params) (Script
 
 	return scriptClass, addrs, requiredSigs, nil
 }
+
+// ExtractProvaKeys returns the public key hashes and the key IDs the passed
+// Prova public key script, either a standard, a generalized, or a timelocked
+// one, is bound to in the order they appear in the script.  Both are nil when
+// the script is not a Prova script.
+func ExtractProvaKeys(pkScript []byte) ([][]byte, []btcec.KeyID) {
+	pops, err := ParseScript(pkScript)
+	if err != nil {
+		return nil, nil
+	}
+	pops = stripTimelock(pops)
+	if !isGeneralProva(pops) {
+		return nil, nil
+	}
+
+	// The key hashes and key ids have already been validated by
+	// isGeneralProva, so the errors can be ignored.
+	var keyHashes [][]byte
+	var keyIDs []btcec.KeyID
+	for _, pop := range pops[1 : len(pops)-2] {
+		if len(pop.data) == 20 {
+			keyHashes = append(keyHashes, pop.data)
+			continue
+		}
+		keyID, _ := asInt32(pop)
+		keyIDs = append(keyIDs, btcec.KeyID(keyID))
+	}
+	return keyHashes, keyIDs
+}
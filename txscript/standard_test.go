@@ -75,6 +75,26 @@ func TestExtractPkScriptAddrs(t *testing.T) {
 			reqSigs: 2,
 			class:   ProvaTy,
 		},
+		{
+			name: "timelocked prova",
+			script: decodeHex("0320a107b175521435dbbf04bca061e49d" +
+				"ace08f858d8775c0a57c8e030000015153ba"),
+			addrs: []provautil.Address{
+				newAddressProva(decodeHex("35dbbf04bca061e49dace08f858d8775c0a57c8e"),
+					[]btcec.KeyID{0x10000, 1}),
+			},
+			reqSigs: 2,
+			class:   ProvaTimelockTy,
+		},
+		{
+			name: "timelocked general prova",
+			script: mustParseShortForm("144 CHECKSEQUENCEVERIFY DROP " +
+				"2 DATA_20 0x35dbbf04bca061e49dace08f858d8775c0a57c8e " +
+				"1 2 3 4 5 CHECKSAFEMULTISIG"),
+			addrs:   nil,
+			reqSigs: 0,
+			class:   ProvaTimelockTy,
+		},
 		{
 			name:    "empty script",
 			script:  []byte{},
@@ -121,6 +141,67 @@ func TestExtractPkScriptAddrs(t *testing.T) {
 	}
 }
 
+// TestExtractProvaKeys ensures the key hashes and key IDs of standard and
+// generalized Prova scripts are extracted and that other scripts have none.
+func TestExtractProvaKeys(t *testing.T) {
+	t.Parallel()
+
+	hash1 := decodeHex("35dbbf04bca061e49dace08f858d8775c0a57c8e")
+	hash2 := decodeHex("0232abdc893e7f0631364d7fd01cb33d24da4532")
+	tests := []struct {
+		name      string
+		script    []byte
+		keyHashes [][]byte
+		keyIDs    []btcec.KeyID
+	}{
+		{
+			name: "standard prova",
+			script: decodeHex("521435dbbf04bca061e49dace08f858d87" +
+				"75c0a57c8e030000015153ba"),
+			keyHashes: [][]byte{hash1},
+			keyIDs:    []btcec.KeyID{0x10000, 1},
+		},
+		{
+			name: "general prova",
+			script: mustParseShortForm("3 DATA_20 0x35dbbf04bca061e4" +
+				"9dace08f858d8775c0a57c8e DATA_20 0x0232abdc893e7f" +
+				"0631364d7fd01cb33d24da4532 1 2 3 5 " +
+				"CHECKSAFEMULTISIG"),
+			keyHashes: [][]byte{hash1, hash2},
+			keyIDs:    []btcec.KeyID{1, 2, 3},
+		},
+		{
+			name: "timelocked prova",
+			script: mustParseShortForm("500000 CHECKLOCKTIMEVERIFY DROP " +
+				"2 DATA_20 0x35dbbf04bca061e49dace08f858d8775c0a57c8e " +
+				"1 2 3 CHECKSAFEMULTISIG"),
+			keyHashes: [][]byte{hash1},
+			keyIDs:    []btcec.KeyID{1, 2},
+		},
+		{
+			name:   "nulldata",
+			script: mustParseShortForm("RETURN 4"),
+		},
+		{
+			name:   "script that does not parse",
+			script: []byte{OP_DATA_45},
+		},
+	}
+
+	for _, test := range tests {
+		keyHashes, keyIDs := ExtractProvaKeys(test.script)
+		if !reflect.DeepEqual(keyHashes, test.keyHashes) {
+			t.Errorf("ExtractProvaKeys (%s): unexpected key hashes - "+
+				"got %x, want %x", test.name, keyHashes,
+				test.keyHashes)
+		}
+		if !reflect.DeepEqual(keyIDs, test.keyIDs) {
+			t.Errorf("ExtractProvaKeys (%s): unexpected key IDs - "+
+				"got %v, want %v", test.name, keyIDs, test.keyIDs)
+		}
+	}
+}
+
 // TestIsValidAdminOp tests the IsValidAdminOp function.
 func TestIsValidAdminOp(t *testing.T) {
 	// Create some dummy admin op output.
@@ -297,6 +378,89 @@ func TestPayToAddrScript(t *testing.T) {
 	}
 }
 
+// TestPayToAddrTimelockScripts ensures the timelocked scripts which pay to an
+// address lock the script PayToAddrScript creates, and that their timelocks are
+// extracted again.
+func TestPayToAddrTimelockScripts(t *testing.T) {
+	t.Parallel()
+
+	addr := newAddressProva(
+		decodeHex("35dbbf04bca061e49dace08f858d8775c0a57c8e"),
+		[]btcec.KeyID{0x10000, 1})
+	provaScript := "521435dbbf04bca061e49dace08f858d8775c0a57c8e03000001" +
+		"5153ba"
+
+	tests := []struct {
+		name     string
+		lockOp   byte
+		lock     uint32
+		expected string
+		err      error
+	}{
+		{
+			name:     "lock time",
+			lockOp:   OP_CHECKLOCKTIMEVERIFY,
+			lock:     500000,
+			expected: "0320a107b175" + provaScript,
+		},
+		{
+			name:     "lock time beyond 31 bits",
+			lockOp:   OP_CHECKLOCKTIMEVERIFY,
+			lock:     0xffffffff,
+			expected: "05ffffffff00b175" + provaScript,
+		},
+		{
+			name:     "sequence lock",
+			lockOp:   OP_CHECKSEQUENCEVERIFY,
+			lock:     144,
+			expected: "029000b275" + provaScript,
+		},
+		{
+			name:   "sequence lock disabled",
+			lockOp: OP_CHECKSEQUENCEVERIFY,
+			lock:   wire.SequenceLockTimeDisabled | 144,
+			err:    scriptError(ErrUnsatisfiedLockTime, ""),
+		},
+	}
+
+	for _, test := range tests {
+		var pkScript []byte
+		var err error
+		if test.lockOp == OP_CHECKLOCKTIMEVERIFY {
+			pkScript, err = PayToAddrLockTimeScript(addr, test.lock)
+		} else {
+			pkScript, err = PayToAddrSequenceLockScript(addr,
+				test.lock)
+		}
+		if e := tstCheckScriptError(err, test.err); e != nil {
+			t.Errorf("%s: %v", test.name, e)
+			continue
+		}
+		if test.err != nil {
+			continue
+		}
+		if !bytes.Equal(pkScript, decodeHex(test.expected)) {
+			t.Errorf("%s: got %x, want %s", test.name, pkScript,
+				test.expected)
+			continue
+		}
+		if class := GetScriptClass(pkScript); class != ProvaTimelockTy {
+			t.Errorf("%s: got class %v, want %v", test.name, class,
+				ProvaTimelockTy)
+		}
+		lockOp, lock := ExtractTimelock(pkScript)
+		if lockOp != test.lockOp || lock != int64(test.lock) {
+			t.Errorf("%s: got timelock %x %d, want %x %d",
+				test.name, lockOp, lock, test.lockOp, test.lock)
+		}
+	}
+
+	// Scripts which are not timelocked have no timelock.
+	if lockOp, _ := ExtractTimelock(decodeHex(provaScript)); lockOp != OP_0 {
+		t.Errorf("got timelock opcode %x for unlocked script", lockOp)
+	}
+}
+
 // TestMultiSigScript ensures the MultiSigScript function returns the expected
 // scripts and errors.
 func TestMultiSigScript(t *testing.T) {
@@ -633,6 +797,41 @@ var scriptClassTests = []struct {
 		script: "0 CHECKTHREAD",
 		class:  ProvaAdminTy,
 	},
+	{
+		name: "prova script with lock time",
+		script: "500000 CHECKLOCKTIMEVERIFY DROP 2 DATA_20 0x433ec2ac1ff" +
+			"a1b7b7d027f564529c57197f9ae88 1 2 3 CHECKSAFEMULTISIG",
+		class: ProvaTimelockTy,
+	},
+	{
+		name: "prova script with sequence lock",
+		script: "144 CHECKSEQUENCEVERIFY DROP 2 DATA_20 0x433ec2ac1ffa1b" +
+			"7b7d027f564529c57197f9ae88 1 2 3 4 5 CHECKSAFEMULTISIG",
+		class: ProvaTimelockTy,
+	},
+	{
+		name: "prova script with negative lock time",
+		script: "-1 CHECKLOCKTIMEVERIFY DROP 2 DATA_20 0x433ec2ac1ffa1b" +
+			"7b7d027f564529c57197f9ae88 1 2 3 CHECKSAFEMULTISIG",
+		class: NonStandardTy,
+	},
+	{
+		name: "prova script with lock time beyond 32 bits",
+		script: "4294967296 CHECKLOCKTIMEVERIFY DROP 2 DATA_20 0x433ec2" +
+			"ac1ffa1b7b7d027f564529c57197f9ae88 1 2 3 CHECKSAFEMULTISIG",
+		class: NonStandardTy,
+	},
+	{
+		name: "prova script with lock time left on the stack",
+		script: "500000 CHECKLOCKTIMEVERIFY 2 DATA_20 0x433ec2ac1ffa1b7" +
+			"b7d027f564529c57197f9ae88 1 2 3 CHECKSAFEMULTISIG",
+		class: NonStandardTy,
+	},
+	{
+		name:   "admin script with lock time",
+		script: "500000 CHECKLOCKTIMEVERIFY DROP 0 CHECKTHREAD",
+		class:  NonStandardTy,
+	},
 }
 
 // TestScriptClass ensures all the scripts in scriptClassTests have the expected
@@ -671,6 +870,11 @@ func TestStringifyClass(t *testing.T) {
 			class:    NullDataTy,
 			stringed: "nulldata",
 		},
+		{
+			name:     "provatimelockty",
+			class:    ProvaTimelockTy,
+			stringed: "safe_multisig_timelock",
+		},
 		{
 			name:     "broken",
 			class:    ScriptClass(255),
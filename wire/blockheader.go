@@ -143,9 +143,21 @@ func (h *BlockHeader) hashForSigning() []byte {
 	return chainhash.PowHashB(buf.Bytes())
 }
 
-// Sign uses the supplied private key to sign the signing-hash of the block
-// header, and sets it in the Signature field.
-func (h *BlockHeader) Sign(key *btcec.PrivateKey) error {
+// BlockSigner describes a validate key which is able to sign block headers.
+// It is implemented by *btcec.PrivateKey, and can be implemented by signers
+// which keep the private key elsewhere, such as in an HSM or a remote signing
+// service.
+type BlockSigner interface {
+	// PubKey returns the public key of the validate key.
+	PubKey() *btcec.PublicKey
+
+	// Sign signs the passed hash with the validate key.
+	Sign(hash []byte) (*btcec.Signature, error)
+}
+
+// Sign uses the supplied signer to sign the signing-hash of the block header,
+// and sets it in the Signature field.
+func (h *BlockHeader) Sign(key BlockSigner) error {
 	hash := h.hashForSigning()
 	signature, err := key.Sign(hash)
 	if err != nil {
@@ -42,19 +42,27 @@ https://en.bitcoin.it/wiki/Protocol_specification.
 The initial handshake consists of two peers sending each other a version message
 (MsgVersion) followed by responding with a verack message (MsgVerAck).  Both
 peers use the information in the version message (MsgVersion) to negotiate
-things such as protocol version and supported services with each other.  Once
-the initial handshake is complete, the following chart indicates message
-interactions in no particular order.
+things such as protocol version and supported services with each other.  Peers
+of private networks which share a handshake token and announce it in the user
+agent of their version message also exchange hsproof messages
+(MsgHandshakeProof) directly after the version messages.  Once the initial
+handshake is complete, the following chart indicates message interactions in no
+particular order.
 
 	Peer A Sends                          Peer B Responds
 	----------------------------------------------------------------------------
-	getaddr message (MsgGetAddr)          addr message (MsgAddr)
+	getaddr message (MsgGetAddr)          addr message (MsgAddr) -or-
+	                                      addrv2 message (MsgAddrV2)****
 	getblocks message (MsgGetBlocks)      inv message (MsgInv)
 	inv message (MsgInv)                  getdata message (MsgGetData)
 	getdata message (MsgGetData)          block message (MsgBlock) -or-
 	                                      tx message (MsgTx) -or-
 	                                      notfound message (MsgNotFound)
 	getheaders message (MsgGetHeaders)    headers message (MsgHeaders)
+	cmpctblock message (MsgCmpctBlock)    getblocktxn message (MsgGetBlockTxn)**
+	getblocktxn message (MsgGetBlockTxn)  blocktxn message (MsgBlockTxn)
+	getcfilters message (MsgGetCFilters)  cfilter message (MsgCFilter)***
+	getcfheaders message (MsgGetCFHeaders) cfheaders message (MsgCFHeaders)***
 	ping message (MsgPing)                pong message (MsgHeaders)* -or-
 	                                      (none -- Ability to send message is enough)
 
@@ -62,6 +70,18 @@ interactions in no particular order.
 	* The pong message was not added until later protocol versions as defined
 	  in BIP0031.  The BIP0031Version constant can be used to detect a recent
 	  enough protocol version for this purpose (version > BIP0031Version).
+	** The getblocktxn message is only sent when the transactions of the
+	  compact block can not all be found in the memory pool.  The compact
+	  block messages were not added until the protocol version defined by
+	  the CompactBlocksVersion constant.
+	*** The compact block filter messages are only served by peers which
+	  advertise the SFNodeCF service flag.  They were not added until the
+	  protocol version defined by the NodeCFVersion constant.
+	**** The addrv2 message is only sent to peers which sent a sendaddrv2
+	  message (MsgSendAddrV2) before the verack message.  Unlike the addr
+	  message, it is able to carry the addresses of version 3 Tor onion
+	  services, I2P and CJDNS (BIP0155).  The messages were not added until
+	  the protocol version defined by the AddrV2Version constant.
 
 Common Parameters
 
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"time"
 	"unicode/utf8"
 
 	"github.com/bitgo/prova/chaincfg/chainhash"
@@ -29,29 +30,44 @@ const MaxMessagePayload = (1024 * 1024 * 32) // 32MB
 
 // Commands used in bitcoin message headers which describe the type of message.
 const (
-	CmdVersion     = "version"
-	CmdVerAck      = "verack"
-	CmdGetAddr     = "getaddr"
-	CmdAddr        = "addr"
-	CmdGetBlocks   = "getblocks"
-	CmdInv         = "inv"
-	CmdGetData     = "getdata"
-	CmdNotFound    = "notfound"
-	CmdBlock       = "block"
-	CmdTx          = "tx"
-	CmdGetHeaders  = "getheaders"
-	CmdHeaders     = "headers"
-	CmdPing        = "ping"
-	CmdPong        = "pong"
-	CmdAlert       = "alert"
-	CmdMemPool     = "mempool"
-	CmdFilterAdd   = "filteradd"
-	CmdFilterClear = "filterclear"
-	CmdFilterLoad  = "filterload"
-	CmdMerkleBlock = "merkleblock"
-	CmdReject      = "reject"
-	CmdSendHeaders = "sendheaders"
-	CmdFeeFilter   = "feefilter"
+	CmdVersion      = "version"
+	CmdVerAck       = "verack"
+	CmdGetAddr      = "getaddr"
+	CmdAddr         = "addr"
+	CmdGetBlocks    = "getblocks"
+	CmdInv          = "inv"
+	CmdGetData      = "getdata"
+	CmdNotFound     = "notfound"
+	CmdBlock        = "block"
+	CmdTx           = "tx"
+	CmdGetHeaders   = "getheaders"
+	CmdHeaders      = "headers"
+	CmdPing         = "ping"
+	CmdPong         = "pong"
+	CmdAlert        = "alert"
+	CmdMemPool      = "mempool"
+	CmdFilterAdd    = "filteradd"
+	CmdFilterClear  = "filterclear"
+	CmdFilterLoad   = "filterload"
+	CmdMerkleBlock  = "merkleblock"
+	CmdReject       = "reject"
+	CmdSendHeaders  = "sendheaders"
+	CmdFeeFilter    = "feefilter"
+	CmdNoChecksum   = "nochecksum"
+	CmdSendCmpct    = "sendcmpct"
+	CmdCmpctBlock   = "cmpctblock"
+	CmdGetBlockTxn  = "getblocktxn"
+	CmdBlockTxn     = "blocktxn"
+	CmdGetCFilters  = "getcfilters"
+	CmdCFilter      = "cfilter"
+	CmdGetCFHeaders = "getcfheaders"
+	CmdCFHeaders    = "cfheaders"
+	CmdSendAddrV2   = "sendaddrv2"
+	CmdAddrV2       = "addrv2"
+
+	// CmdHandshakeProof is only exchanged by the peers of private
+	// networks which share a handshake token.
+	CmdHandshakeProof = "hsproof"
 )
 
 // Message is an interface that describes a bitcoin message.  A type that
@@ -139,6 +155,42 @@ func makeEmptyMessage(command string) (Message, error) {
 	case CmdFeeFilter:
 		msg = &MsgFeeFilter{}
 
+	case CmdNoChecksum:
+		msg = &MsgNoChecksum{}
+
+	case CmdSendCmpct:
+		msg = &MsgSendCmpct{}
+
+	case CmdCmpctBlock:
+		msg = &MsgCmpctBlock{}
+
+	case CmdGetBlockTxn:
+		msg = &MsgGetBlockTxn{}
+
+	case CmdBlockTxn:
+		msg = &MsgBlockTxn{}
+
+	case CmdGetCFilters:
+		msg = &MsgGetCFilters{}
+
+	case CmdCFilter:
+		msg = &MsgCFilter{}
+
+	case CmdGetCFHeaders:
+		msg = &MsgGetCFHeaders{}
+
+	case CmdCFHeaders:
+		msg = &MsgCFHeaders{}
+
+	case CmdSendAddrV2:
+		msg = &MsgSendAddrV2{}
+
+	case CmdAddrV2:
+		msg = &MsgAddrV2{}
+
+	case CmdHandshakeProof:
+		msg = &MsgHandshakeProof{}
+
 	default:
 		return nil, fmt.Errorf("unhandled command [%s]", command)
 	}
@@ -201,6 +253,21 @@ func discardInput(r io.Reader, n uint32) {
 // information and returns the number of bytes written.    This function is the
 // same as WriteMessage except it also returns the number of bytes written.
 func WriteMessageN(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) (int, error) {
+	return writeMessageN(w, msg, pver, btcnet, false)
+}
+
+// WriteMessageNoChecksumN is the same as WriteMessageN except the payload
+// checksum is not computed and the checksum field of the header is left
+// zeroed.  It must only be used when the remote peer has indicated it does not
+// verify checksums via a nochecksum message.
+func WriteMessageNoChecksumN(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) (int, error) {
+	return writeMessageN(w, msg, pver, btcnet, true)
+}
+
+// writeMessageN writes a bitcoin Message to w including the necessary header
+// information and returns the number of bytes written.  The payload checksum
+// is only computed when skipChecksum is false.
+func writeMessageN(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet, skipChecksum bool) (int, error) {
 	totalBytes := 0
 
 	// Enforce max command size.
@@ -244,7 +311,9 @@ func WriteMessageN(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) (in
 	hdr.magic = btcnet
 	hdr.command = cmd
 	hdr.length = uint32(lenp)
-	copy(hdr.checksum[:], chainhash.DoubleHashB(payload)[0:4])
+	if !skipChecksum {
+		copy(hdr.checksum[:], chainhash.DoubleHashB(payload)[0:4])
+	}
 
 	// Encode the header for the message.  This is done to a buffer
 	// rather than directly to the writer since writeElements doesn't
@@ -281,6 +350,32 @@ func WriteMessage(w io.Writer, msg Message, pver uint32, btcnet BitcoinNet) erro
 // message.  This function is the same as ReadMessage except it also returns the
 // number of bytes read.
 func ReadMessageN(r io.Reader, pver uint32, btcnet BitcoinNet) (int, Message, []byte, error) {
+	return readMessageN(r, pver, btcnet, false, nil)
+}
+
+// ReadMessageNoChecksumN is the same as ReadMessageN except the payload
+// checksum in the message header is not verified.  This is intended for use on
+// local connections where the local peer has sent a nochecksum message to the
+// remote peer.
+func ReadMessageNoChecksumN(r io.Reader, pver uint32, btcnet BitcoinNet) (int, Message, []byte, error) {
+	return readMessageN(r, pver, btcnet, true, nil)
+}
+
+// ReadMessageTimedN is the same as ReadMessageN, or ReadMessageNoChecksumN when
+// skipChecksum is true, except it also returns the time spent decoding the
+// payload of the message.  Unlike the total time of the call, it does not
+// include waiting for the message to arrive.
+func ReadMessageTimedN(r io.Reader, pver uint32, btcnet BitcoinNet, skipChecksum bool) (int, Message, []byte, time.Duration, error) {
+	var decodeTime time.Duration
+	n, msg, buf, err := readMessageN(r, pver, btcnet, skipChecksum,
+		&decodeTime)
+	return n, msg, buf, decodeTime, err
+}
+
+// readMessageN reads, validates, and parses the next bitcoin Message from r.
+// The payload checksum is only verified when skipChecksum is false.  The time
+// spent decoding the payload is stored to decodeTime when it is not nil.
+func readMessageN(r io.Reader, pver uint32, btcnet BitcoinNet, skipChecksum bool, decodeTime *time.Duration) (int, Message, []byte, error) {
 	totalBytes := 0
 	n, hdr, err := readMessageHeader(r)
 	totalBytes += n
@@ -341,18 +436,25 @@ func ReadMessageN(r io.Reader, pver uint32, btcnet BitcoinNet) (int, Message, []
 	}
 
 	// Test checksum.
-	checksum := chainhash.DoubleHashB(payload)[0:4]
-	if !bytes.Equal(checksum[:], hdr.checksum[:]) {
-		str := fmt.Sprintf("payload checksum failed - header "+
-			"indicates %v, but actual checksum is %v.",
-			hdr.checksum, checksum)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+	if !skipChecksum {
+		checksum := chainhash.DoubleHashB(payload)[0:4]
+		if !bytes.Equal(checksum[:], hdr.checksum[:]) {
+			str := fmt.Sprintf("payload checksum failed - header "+
+				"indicates %v, but actual checksum is %v.",
+				hdr.checksum, checksum)
+			return totalBytes, nil, nil, messageError("ReadMessage",
+				str)
+		}
 	}
 
 	// Unmarshal message.  NOTE: This must be a *bytes.Buffer since the
 	// MsgVersion BtcDecode function requires it.
 	pr := bytes.NewBuffer(payload)
+	decodeStart := time.Now()
 	err = msg.BtcDecode(pr, pver)
+	if decodeTime != nil {
+		*decodeTime = time.Since(decodeStart)
+	}
 	if err != nil {
 		return totalBytes, nil, nil, err
 	}
@@ -70,6 +70,21 @@ func TestMessage(t *testing.T) {
 	bh := NewBlockHeader(&chainhash.Hash{}, &chainhash.Hash{}, 0, 0)
 	msgMerkleBlock := NewMsgMerkleBlock(bh)
 	msgReject := NewMsgReject("block", RejectDuplicate, "duplicate block")
+	msgNoChecksum := NewMsgNoChecksum()
+	msgSendCmpct := NewMsgSendCmpct(true, CmpctBlockVersion)
+	msgCmpctBlock := NewMsgCmpctBlock(bh, 123123)
+	msgGetBlockTxn := NewMsgGetBlockTxn(&chainhash.Hash{})
+	msgBlockTxn := NewMsgBlockTxn(&chainhash.Hash{})
+	msgGetCFilters := NewMsgGetCFilters(GCSFilterBasic, 0, &chainhash.Hash{})
+	msgCFilter := NewMsgCFilter(GCSFilterBasic, &chainhash.Hash{},
+		[]byte{0x01})
+	msgGetCFHeaders := NewMsgGetCFHeaders(GCSFilterBasic, 0,
+		&chainhash.Hash{})
+	msgCFHeaders := NewMsgCFHeaders(GCSFilterBasic, &chainhash.Hash{},
+		&chainhash.Hash{})
+	msgSendAddrV2 := NewMsgSendAddrV2()
+	msgAddrV2 := NewMsgAddrV2()
+	msgHandshakeProof := NewMsgHandshakeProof([HandshakeProofSize]byte{0x01})
 
 	tests := []struct {
 		in     Message    // Value to encode
@@ -99,6 +114,18 @@ func TestMessage(t *testing.T) {
 		{msgFilterLoad, msgFilterLoad, pver, MainNet, 35},
 		{msgMerkleBlock, msgMerkleBlock, pver, MainNet, 239},
 		{msgReject, msgReject, pver, MainNet, 79},
+		{msgNoChecksum, msgNoChecksum, pver, MainNet, 24},
+		{msgSendCmpct, msgSendCmpct, pver, MainNet, 33},
+		{msgCmpctBlock, msgCmpctBlock, pver, MainNet, 243},
+		{msgGetBlockTxn, msgGetBlockTxn, pver, MainNet, 57},
+		{msgBlockTxn, msgBlockTxn, pver, MainNet, 57},
+		{msgGetCFilters, msgGetCFilters, pver, MainNet, 61},
+		{msgCFilter, msgCFilter, pver, MainNet, 59},
+		{msgGetCFHeaders, msgGetCFHeaders, pver, MainNet, 61},
+		{msgCFHeaders, msgCFHeaders, pver, MainNet, 90},
+		{msgSendAddrV2, msgSendAddrV2, pver, MainNet, 24},
+		{msgAddrV2, msgAddrV2, pver, MainNet, 25},
+		{msgHandshakeProof, msgHandshakeProof, pver, MainNet, 56},
 	}
 
 	t.Logf("Running %d tests", len(tests))
@@ -166,6 +193,43 @@ func TestMessage(t *testing.T) {
 	}
 }
 
+// TestMessageNoChecksum tests the Read/WriteMessageNoChecksumN API.
+func TestMessageNoChecksum(t *testing.T) {
+	pver := ProtocolVersion
+	msgTx := NewMsgTx(1)
+
+	// Ensure messages written without a checksum have a zeroed checksum
+	// field and can be read back without verifying it.
+	var buf bytes.Buffer
+	nw, err := WriteMessageNoChecksumN(&buf, msgTx, pver, MainNet)
+	if err != nil {
+		t.Fatalf("WriteMessageNoChecksumN: unexpected error %v", err)
+	}
+	if !bytes.Equal(buf.Bytes()[20:24], []byte{0, 0, 0, 0}) {
+		t.Fatalf("WriteMessageNoChecksumN: checksum not zeroed - got %x",
+			buf.Bytes()[20:24])
+	}
+	nr, msg, _, err := ReadMessageNoChecksumN(bytes.NewReader(buf.Bytes()),
+		pver, MainNet)
+	if err != nil {
+		t.Fatalf("ReadMessageNoChecksumN: unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(msg, msgTx) {
+		t.Fatalf("ReadMessageNoChecksumN\n got: %v want: %v",
+			spew.Sdump(msg), spew.Sdump(msgTx))
+	}
+	if nr != nw {
+		t.Fatalf("ReadMessageNoChecksumN: unexpected num bytes read - "+
+			"got %d, want %d", nr, nw)
+	}
+
+	// Ensure the regular read path still rejects the missing checksum.
+	_, _, _, err = ReadMessageN(bytes.NewReader(buf.Bytes()), pver, MainNet)
+	if _, ok := err.(*MessageError); !ok {
+		t.Fatalf("ReadMessageN: expected checksum error, got %v", err)
+	}
+}
+
 // TestReadMessageWireErrors performs negative tests against wire decoding into
 // concrete messages to confirm error paths work correctly.
 func TestReadMessageWireErrors(t *testing.T) {
@@ -0,0 +1,257 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestAddrV2 tests the MsgAddrV2 API.
+func TestAddrV2(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "addrv2"
+	msg := NewMsgAddrV2()
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgAddrV2: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value for latest protocol version.
+	// Num addresses (varInt) + max allowed addresses.
+	wantPayload := uint32(537009)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure NetAddressV2s are added properly.
+	na := NewNetAddressV2(time.Now(), SFNodeNetwork, NetTorV3,
+		make([]byte, 32), 8333)
+	err := msg.AddAddress(na)
+	if err != nil {
+		t.Errorf("AddAddress: %v", err)
+	}
+	if msg.AddrList[0] != na {
+		t.Errorf("AddAddress: wrong address added - got %v, want %v",
+			spew.Sprint(msg.AddrList[0]), spew.Sprint(na))
+	}
+
+	// Ensure the address list is cleared properly.
+	msg.ClearAddresses()
+	if len(msg.AddrList) != 0 {
+		t.Errorf("ClearAddresses: address list is not empty - "+
+			"got %v [%v], want %v", len(msg.AddrList),
+			spew.Sprint(msg.AddrList[0]), 0)
+	}
+
+	// Ensure adding more than the max allowed addresses per message
+	// returns error.
+	for i := 0; i < MaxAddrPerMsg+1; i++ {
+		err = msg.AddAddress(na)
+	}
+	if err == nil {
+		t.Errorf("AddAddress: expected error on too many addresses " +
+			"not received")
+	}
+	err = msg.AddAddresses(na)
+	if err == nil {
+		t.Errorf("AddAddresses: expected error on too many addresses " +
+			"not received")
+	}
+}
+
+// TestAddrV2Wire tests the MsgAddrV2 wire encode and decode for addresses of
+// various networks.
+func TestAddrV2Wire(t *testing.T) {
+	torV3 := make([]byte, 32)
+	for i := range torV3 {
+		torV3[i] = byte(i)
+	}
+
+	// Addresses of an IPv4, a version 3 Tor and an unknown network.
+	na := &NetAddressV2{
+		Timestamp: time.Unix(0x495fab29, 0), // 2009-01-03 12:15:05 -0600 CST
+		Services:  SFNodeNetwork,
+		Network:   NetIPv4,
+		Addr:      []byte{0x7f, 0x00, 0x00, 0x01},
+		Port:      8333,
+	}
+	na2 := &NetAddressV2{
+		Timestamp: time.Unix(0x495fab29, 0), // 2009-01-03 12:15:05 -0600 CST
+		Services:  SFNodeNetwork | SFNodeBloom,
+		Network:   NetTorV3,
+		Addr:      torV3,
+		Port:      8334,
+	}
+	na3 := &NetAddressV2{
+		Timestamp: time.Unix(0x495fab29, 0), // 2009-01-03 12:15:05 -0600 CST
+		Services:  SFNodeNetwork,
+		Network:   NetworkID(0x20),
+		Addr:      []byte{0x01, 0x02},
+		Port:      8335,
+	}
+
+	// Empty address message.
+	noAddr := NewMsgAddrV2()
+	noAddrEncoded := []byte{
+		0x00, // Varint for number of addresses
+	}
+
+	// Address message with multiple addresses.
+	multiAddr := NewMsgAddrV2()
+	multiAddr.AddAddresses(na, na2, na3)
+	multiAddrEncoded := []byte{
+		0x03,                   // Varint for number of addresses
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,                   // Varint for SFNodeNetwork
+		0x01,                   // NetIPv4
+		0x04,                   // Varint for address size
+		0x7f, 0x00, 0x00, 0x01, // IP 127.0.0.1
+		0x20, 0x8d, // Port 8333 in big-endian
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x05, // Varint for SFNodeNetwork|SFNodeBloom
+		0x04, // NetTorV3
+		0x20, // Varint for address size
+		0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+		0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+		0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17,
+		0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f, // Public key
+		0x20, 0x8e, // Port 8334 in big-endian
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,       // Varint for SFNodeNetwork
+		0x20,       // Unknown network
+		0x02,       // Varint for address size
+		0x01, 0x02, // Address
+		0x20, 0x8f, // Port 8335 in big-endian
+	}
+
+	tests := []struct {
+		in   *MsgAddrV2 // Message to encode
+		out  *MsgAddrV2 // Expected decoded message
+		buf  []byte     // Wire encoding
+		pver uint32     // Protocol version for wire encoding
+	}{
+		// Latest protocol version with no addresses.
+		{
+			noAddr,
+			noAddr,
+			noAddrEncoded,
+			ProtocolVersion,
+		},
+
+		// Latest protocol version with multiple addresses.
+		{
+			multiAddr,
+			multiAddr,
+			multiAddrEncoded,
+			ProtocolVersion,
+		},
+	}
+
+	t.Logf("Running %d tests", len(tests))
+	for i, test := range tests {
+		// Encode the message to wire format.
+		var buf bytes.Buffer
+		err := test.in.BtcEncode(&buf, test.pver)
+		if err != nil {
+			t.Errorf("BtcEncode #%d error %v", i, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), test.buf) {
+			t.Errorf("BtcEncode #%d\n got: %s want: %s", i,
+				spew.Sdump(buf.Bytes()), spew.Sdump(test.buf))
+			continue
+		}
+
+		// Decode the message from wire format.
+		var msg MsgAddrV2
+		rbuf := bytes.NewReader(test.buf)
+		err = msg.BtcDecode(rbuf, test.pver)
+		if err != nil {
+			t.Errorf("BtcDecode #%d error %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(&msg, test.out) {
+			t.Errorf("BtcDecode #%d\n got: %s want: %s", i,
+				spew.Sdump(msg), spew.Sdump(test.out))
+			continue
+		}
+	}
+}
+
+// TestAddrV2WireErrors performs negative tests against wire encode and decode
+// of MsgAddrV2 to confirm error paths work correctly.
+func TestAddrV2WireErrors(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Message which is invalid for protocol versions before AddrV2Version.
+	var buf bytes.Buffer
+	msg := NewMsgAddrV2()
+	if err := msg.BtcEncode(&buf, AddrV2Version-1); err == nil {
+		t.Errorf("BtcEncode: expected error for old protocol version")
+	}
+	noAddrEncoded := []byte{0x00}
+	err := msg.BtcDecode(bytes.NewReader(noAddrEncoded), AddrV2Version-1)
+	if err == nil {
+		t.Errorf("BtcDecode: expected error for old protocol version")
+	}
+
+	// Address of a known network with the wrong size.
+	badSizeEncoded := []byte{
+		0x01,                   // Varint for number of addresses
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,             // Varint for SFNodeNetwork
+		0x01,             // NetIPv4
+		0x03,             // Varint for address size
+		0x7f, 0x00, 0x00, // Truncated IP
+		0x20, 0x8d, // Port 8333 in big-endian
+	}
+	err = msg.BtcDecode(bytes.NewReader(badSizeEncoded), pver)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode: got error %v <%T>, want MessageError",
+			err, err)
+	}
+
+	// Address which exceeds the max address size.
+	tooLongEncoded := []byte{
+		0x01,                   // Varint for number of addresses
+		0x29, 0xab, 0x5f, 0x49, // Timestamp
+		0x01,             // Varint for SFNodeNetwork
+		0x20,             // Unknown network
+		0xfd, 0x01, 0x02, // Varint for address size 513
+	}
+	err = msg.BtcDecode(bytes.NewReader(tooLongEncoded), pver)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode: got error %v <%T>, want MessageError",
+			err, err)
+	}
+	msg.ClearAddresses()
+	msg.AddAddress(&NetAddressV2{Network: NetworkID(0x20),
+		Addr: make([]byte, MaxAddrV2Size+1)})
+	err = msg.BtcEncode(&buf, pver)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcEncode: got error %v <%T>, want MessageError",
+			err, err)
+	}
+
+	// Message with more than the max allowed addresses.
+	tooManyEncoded := []byte{
+		0xfd, 0xe9, 0x03, // Varint for number of addresses (1001)
+	}
+	err = msg.BtcDecode(bytes.NewReader(tooManyEncoded), pver)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("BtcDecode: got error %v <%T>, want MessageError",
+			err, err)
+	}
+}
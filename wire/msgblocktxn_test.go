@@ -0,0 +1,72 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestBlockTxn tests the MsgBlockTxn API and its wire encoding.
+func TestBlockTxn(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "blocktxn"
+	hash := blockOne.BlockHash()
+	msg := NewMsgBlockTxn(&hash)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgBlockTxn: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(MaxBlockPayload)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Test encode and decode with latest protocol version.
+	msg.AddTransaction(blockOne.Transactions[0])
+	msg.AddTransaction(multiTx)
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Errorf("encode of MsgBlockTxn failed %v err <%v>", msg, err)
+	}
+	wantLen := 32 + 1 + blockOne.Transactions[0].SerializeSize() +
+		multiTx.SerializeSize()
+	if buf.Len() != wantLen {
+		t.Errorf("BtcEncode: wrong encoded length - got %v, want %v",
+			buf.Len(), wantLen)
+	}
+	encoded := buf.Bytes()
+	var readmsg MsgBlockTxn
+	if err := readmsg.BtcDecode(&buf, pver); err != nil {
+		t.Errorf("decode of MsgBlockTxn failed [%v] err <%v>", buf,
+			err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Errorf("BtcDecode got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+
+	// Ensure the message can not be encoded or decoded with protocol
+	// versions before compact blocks were added.
+	pver = CompactBlocksVersion - 1
+	if err := msg.BtcEncode(&buf, pver); err == nil {
+		t.Errorf("encode of MsgBlockTxn succeeded when it should " +
+			"have failed")
+	}
+	if err := readmsg.BtcDecode(bytes.NewReader(encoded), pver); err == nil {
+		t.Errorf("decode of MsgBlockTxn succeeded when it should " +
+			"have failed")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestCFHeaders tests the MsgCFHeaders API and its wire encoding.
+func TestCFHeaders(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "cfheaders"
+	stopHash := blockOne.BlockHash()
+	prevHeader := chainhash.Hash{0x01}
+	msg := NewMsgCFHeaders(GCSFilterBasic, &stopHash, &prevHeader)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgCFHeaders: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(1 + 32 + 32 + 9 + MaxCFHeadersPerMsg*32)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure no more than the maximum number of hashes can be added.
+	for i := 0; i < MaxCFHeadersPerMsg; i++ {
+		hash := chainhash.Hash{byte(i), byte(i >> 8)}
+		if err := msg.AddCFHash(&hash); err != nil {
+			t.Fatalf("AddCFHash #%d: unexpected error %v", i, err)
+		}
+	}
+	if err := msg.AddCFHash(&chainhash.Hash{}); err == nil {
+		t.Errorf("AddCFHash: added more than %d hashes",
+			MaxCFHeadersPerMsg)
+	}
+
+	// Test encode and decode with latest protocol version.
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Errorf("encode of MsgCFHeaders failed %v err <%v>", msg, err)
+	}
+	wantLen := 1 + 32 + 32 + 3 + MaxCFHeadersPerMsg*32
+	if buf.Len() != wantLen {
+		t.Errorf("BtcEncode: wrong encoded length - got %v, want %v",
+			buf.Len(), wantLen)
+	}
+	encoded := buf.Bytes()
+	var readmsg MsgCFHeaders
+	if err := readmsg.BtcDecode(bytes.NewReader(encoded), pver); err != nil {
+		t.Errorf("decode of MsgCFHeaders failed [%v] err <%v>", buf,
+			err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Errorf("BtcDecode got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+
+	// Ensure messages with too many hashes are rejected.
+	msg.FilterHashes = append(msg.FilterHashes, &chainhash.Hash{})
+	if err := msg.BtcEncode(&buf, pver); err == nil {
+		t.Errorf("encode of MsgCFHeaders with too many hashes " +
+			"succeeded when it should have failed")
+	}
+	tooMany := append([]byte(nil), encoded[:65]...)
+	tooMany = append(tooMany, 0xfd, 0xd1, 0x07)
+	if err := readmsg.BtcDecode(bytes.NewReader(tooMany), pver); err == nil {
+		t.Errorf("decode of MsgCFHeaders with too many hashes " +
+			"succeeded when it should have failed")
+	}
+
+	// Ensure the message can not be encoded or decoded with protocol
+	// versions before compact block filters were added.
+	pver = NodeCFVersion - 1
+	msg.FilterHashes = msg.FilterHashes[:1]
+	if err := msg.BtcEncode(&buf, pver); err == nil {
+		t.Errorf("encode of MsgCFHeaders succeeded when it should " +
+			"have failed")
+	}
+	if err := readmsg.BtcDecode(bytes.NewReader(encoded), pver); err == nil {
+		t.Errorf("decode of MsgCFHeaders succeeded when it should " +
+			"have failed")
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestCFilter tests the MsgCFilter API and its wire encoding.
+func TestCFilter(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "cfilter"
+	hash := blockOne.BlockHash()
+	data := []byte{0x02, 0x9c, 0x40, 0x80}
+	msg := NewMsgCFilter(GCSFilterBasic, &hash, data)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgCFilter: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(1 + 32 + 9 + MaxCFilterDataSize)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Test encode and decode with latest protocol version.
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Errorf("encode of MsgCFilter failed %v err <%v>", msg, err)
+	}
+	wantLen := 1 + 32 + 1 + len(data)
+	if buf.Len() != wantLen {
+		t.Errorf("BtcEncode: wrong encoded length - got %v, want %v",
+			buf.Len(), wantLen)
+	}
+	encoded := buf.Bytes()
+	var readmsg MsgCFilter
+	if err := readmsg.BtcDecode(bytes.NewReader(encoded), pver); err != nil {
+		t.Errorf("decode of MsgCFilter failed [%v] err <%v>", buf, err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Errorf("BtcDecode got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+
+	// Ensure filters exceeding the maximum size are rejected.
+	msg.Data = make([]byte, MaxCFilterDataSize+1)
+	if err := msg.BtcEncode(&buf, pver); err == nil {
+		t.Errorf("encode of oversized MsgCFilter succeeded when it " +
+			"should have failed")
+	}
+
+	// Ensure the message can not be encoded or decoded with protocol
+	// versions before compact block filters were added.
+	pver = NodeCFVersion - 1
+	msg.Data = data
+	if err := msg.BtcEncode(&buf, pver); err == nil {
+		t.Errorf("encode of MsgCFilter succeeded when it should " +
+			"have failed")
+	}
+	if err := readmsg.BtcDecode(bytes.NewReader(encoded), pver); err == nil {
+		t.Errorf("decode of MsgCFilter succeeded when it should " +
+			"have failed")
+	}
+}
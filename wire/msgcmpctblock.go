@@ -0,0 +1,269 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+)
+
+// CmpctBlockShortIDSize is the number of bytes each short transaction id of a
+// cmpctblock message takes on the wire.
+const CmpctBlockShortIDSize = 6
+
+// PrefilledTx houses a transaction which is sent in full as part of a
+// cmpctblock message along with its index in the block.
+type PrefilledTx struct {
+	// Index is the absolute index of the transaction in the block.  It is
+	// encoded on the wire as the difference from the index of the previous
+	// prefilled transaction.
+	Index uint32
+	Tx    *MsgTx
+}
+
+// MsgCmpctBlock implements the Message interface and represents a cmpctblock
+// message.  It is used to relay a block by only sending the short ids of the
+// transactions the receiving peer is expected to already have in its memory
+// pool, along with the transactions it is unlikely to have, such as the
+// coinbase, in full.  The receiving peer requests any transaction it is unable
+// to find with a getblocktxn message.
+//
+// The short id of a transaction is the lower 6 bytes of the SipHash-2-4 of its
+// hash including signatures, keyed by the first 16 bytes of the single SHA256
+// of the serialized block header followed by the nonce.
+//
+// This message was not added until protocol versions starting with
+// CompactBlocksVersion.
+type MsgCmpctBlock struct {
+	Header        BlockHeader
+	Nonce         uint64
+	ShortIDs      []uint64
+	PrefilledTxns []PrefilledTx
+}
+
+// TotalTxns returns the number of transactions in the block the message
+// describes.
+func (msg *MsgCmpctBlock) TotalTxns() int {
+	return len(msg.ShortIDs) + len(msg.PrefilledTxns)
+}
+
+// SipHashKeys returns the keys used to compute the short transaction ids of the
+// message.
+func (msg *MsgCmpctBlock) SipHashKeys() (uint64, uint64) {
+	buf := bytes.NewBuffer(make([]byte, 0, MaxBlockHeaderPayload+8))
+	_ = writeBlockHeader(buf, 0, &msg.Header)
+	_ = writeElement(buf, msg.Nonce)
+	hash := chainhash.HashB(buf.Bytes())
+	return binary.LittleEndian.Uint64(hash[0:8]),
+		binary.LittleEndian.Uint64(hash[8:16])
+}
+
+// CmpctBlockShortID returns the short id of the transaction with the passed
+// hash including signatures for the passed keys as returned by SipHashKeys.
+func CmpctBlockShortID(k0, k1 uint64, txHashWithSig *chainhash.Hash) uint64 {
+	return chainhash.SipHash24(k0, k1, txHashWithSig[:]) & (1<<(8*CmpctBlockShortIDSize) - 1)
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcDecode(r io.Reader, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("cmpctblock message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+
+	err := readBlockHeader(r, pver, &msg.Header)
+	if err != nil {
+		return err
+	}
+	err = readElement(r, &msg.Nonce)
+	if err != nil {
+		return err
+	}
+
+	// Read num short ids and limit to max.
+	count, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > maxTxPerBlock {
+		str := fmt.Sprintf("too many short ids for message "+
+			"[count %v, max %v]", count, maxTxPerBlock)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+	msg.ShortIDs = make([]uint64, count)
+	var buf [8]byte
+	for i := range msg.ShortIDs {
+		_, err := io.ReadFull(r, buf[:CmpctBlockShortIDSize])
+		if err != nil {
+			return err
+		}
+		msg.ShortIDs[i] = binary.LittleEndian.Uint64(buf[:])
+	}
+
+	// Read num prefilled transactions and limit the total number of
+	// transactions to max.
+	count, err = ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	totalTxns := uint64(len(msg.ShortIDs)) + count
+	if totalTxns > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions for message "+
+			"[count %v, max %v]", totalTxns, maxTxPerBlock)
+		return messageError("MsgCmpctBlock.BtcDecode", str)
+	}
+	msg.PrefilledTxns = make([]PrefilledTx, count)
+	var index uint64
+	for i := range msg.PrefilledTxns {
+		diff, err := ReadVarInt(r, pver)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			index++
+		}
+		if diff >= totalTxns-index {
+			str := fmt.Sprintf("prefilled transaction index is out "+
+				"of range [diff %v, index %v, count %v]", diff,
+				index, totalTxns)
+			return messageError("MsgCmpctBlock.BtcDecode", str)
+		}
+		index += diff
+
+		tx := MsgTx{}
+		err = tx.BtcDecode(r, pver)
+		if err != nil {
+			return err
+		}
+		msg.PrefilledTxns[i] = PrefilledTx{Index: uint32(index), Tx: &tx}
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) BtcEncode(w io.Writer, pver uint32) error {
+	if pver < CompactBlocksVersion {
+		str := fmt.Sprintf("cmpctblock message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgCmpctBlock.BtcEncode", str)
+	}
+
+	totalTxns := msg.TotalTxns()
+	if totalTxns > maxTxPerBlock {
+		str := fmt.Sprintf("too many transactions for message "+
+			"[count %v, max %v]", totalTxns, maxTxPerBlock)
+		return messageError("MsgCmpctBlock.BtcEncode", str)
+	}
+
+	err := writeBlockHeader(w, pver, &msg.Header)
+	if err != nil {
+		return err
+	}
+	err = writeElement(w, msg.Nonce)
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(msg.ShortIDs)))
+	if err != nil {
+		return err
+	}
+	var buf [8]byte
+	for _, shortID := range msg.ShortIDs {
+		binary.LittleEndian.PutUint64(buf[:], shortID)
+		_, err := w.Write(buf[:CmpctBlockShortIDSize])
+		if err != nil {
+			return err
+		}
+	}
+
+	err = WriteVarInt(w, pver, uint64(len(msg.PrefilledTxns)))
+	if err != nil {
+		return err
+	}
+	for i, prefilled := range msg.PrefilledTxns {
+		diff := prefilled.Index
+		if i > 0 {
+			prev := msg.PrefilledTxns[i-1].Index
+			if prefilled.Index <= prev {
+				str := fmt.Sprintf("prefilled transaction indexes "+
+					"are not strictly increasing [index %v, "+
+					"previous %v]", prefilled.Index, prev)
+				return messageError("MsgCmpctBlock.BtcEncode", str)
+			}
+			diff = prefilled.Index - prev - 1
+		}
+		if int(prefilled.Index) >= totalTxns {
+			str := fmt.Sprintf("prefilled transaction index is out "+
+				"of range [index %v, count %v]", prefilled.Index,
+				totalTxns)
+			return messageError("MsgCmpctBlock.BtcEncode", str)
+		}
+
+		err = WriteVarInt(w, pver, uint64(diff))
+		if err != nil {
+			return err
+		}
+		err = prefilled.Tx.BtcEncode(w, pver)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCmpctBlock) Command() string {
+	return CmdCmpctBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) MaxPayloadLength(pver uint32) uint32 {
+	return MaxBlockPayload
+}
+
+// NewMsgCmpctBlock returns a new cmpctblock message that conforms to the
+// Message interface.  See MsgCmpctBlock for details.
+func NewMsgCmpctBlock(bh *BlockHeader, nonce uint64) *MsgCmpctBlock {
+	return &MsgCmpctBlock{
+		Header:        *bh,
+		Nonce:         nonce,
+		ShortIDs:      make([]uint64, 0),
+		PrefilledTxns: make([]PrefilledTx, 0),
+	}
+}
+
+// NewMsgCmpctBlockFromBlock returns a new cmpctblock message describing the
+// passed block which only prefills the coinbase transaction, since it is the
+// only transaction the receiving peer can not have in its memory pool.
+func NewMsgCmpctBlockFromBlock(block *MsgBlock, nonce uint64) *MsgCmpctBlock {
+	msg := NewMsgCmpctBlock(&block.Header, nonce)
+	if len(block.Transactions) == 0 {
+		return msg
+	}
+
+	k0, k1 := msg.SipHashKeys()
+	msg.ShortIDs = make([]uint64, 0, len(block.Transactions)-1)
+	for _, tx := range block.Transactions[1:] {
+		hash := tx.TxHashWithSig()
+		msg.ShortIDs = append(msg.ShortIDs, CmpctBlockShortID(k0, k1, &hash))
+	}
+	msg.PrefilledTxns = append(msg.PrefilledTxns, PrefilledTx{
+		Index: 0,
+		Tx:    block.Transactions[0],
+	})
+	return msg
+}
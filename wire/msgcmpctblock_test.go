@@ -0,0 +1,148 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestCmpctBlock tests the MsgCmpctBlock API and its wire encoding.
+func TestCmpctBlock(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Create a block with a few transactions which only differ in their
+	// lock times.
+	block := NewMsgBlock(&blockOne.Header)
+	block.AddTransaction(blockOne.Transactions[0])
+	for i := 0; i < 3; i++ {
+		tx := multiTx.Copy()
+		tx.LockTime = uint32(i)
+		block.AddTransaction(tx)
+	}
+
+	// Ensure the command is expected value.
+	wantCmd := "cmpctblock"
+	msg := NewMsgCmpctBlockFromBlock(block, 123123)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgCmpctBlockFromBlock: wrong command - got %v "+
+			"want %v", cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(MaxBlockPayload)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure only the coinbase is prefilled and the short ids of the other
+	// transactions are the expected values.
+	if msg.TotalTxns() != len(block.Transactions) {
+		t.Fatalf("TotalTxns: got %v, want %v", msg.TotalTxns(),
+			len(block.Transactions))
+	}
+	wantPrefilled := []PrefilledTx{{0, block.Transactions[0]}}
+	if !reflect.DeepEqual(msg.PrefilledTxns, wantPrefilled) {
+		t.Errorf("NewMsgCmpctBlockFromBlock: wrong prefilled "+
+			"transactions - got %v, want %v",
+			spew.Sdump(msg.PrefilledTxns), spew.Sdump(wantPrefilled))
+	}
+	k0, k1 := msg.SipHashKeys()
+	seen := make(map[uint64]struct{})
+	for i, shortID := range msg.ShortIDs {
+		hash := block.Transactions[i+1].TxHashWithSig()
+		if want := CmpctBlockShortID(k0, k1, &hash); shortID != want {
+			t.Errorf("NewMsgCmpctBlockFromBlock: wrong short id "+
+				"#%d - got %x, want %x", i, shortID, want)
+		}
+		if shortID >= 1<<48 {
+			t.Errorf("short id #%d %x is larger than 6 bytes", i,
+				shortID)
+		}
+		seen[shortID] = struct{}{}
+	}
+	if len(seen) != len(msg.ShortIDs) {
+		t.Errorf("NewMsgCmpctBlockFromBlock: duplicate short ids %v",
+			msg.ShortIDs)
+	}
+
+	// Ensure the short ids change along with the nonce.
+	other := NewMsgCmpctBlockFromBlock(block, 456456)
+	if reflect.DeepEqual(other.ShortIDs, msg.ShortIDs) {
+		t.Errorf("NewMsgCmpctBlockFromBlock: short ids did not " +
+			"change with the nonce")
+	}
+
+	// Prefill another transaction to exercise the differential encoding
+	// of the indexes and ensure the message survives a round trip.
+	msg.ShortIDs = msg.ShortIDs[:2]
+	msg.PrefilledTxns = append(msg.PrefilledTxns,
+		PrefilledTx{2, block.Transactions[2]})
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Fatalf("encode of MsgCmpctBlock failed %v err <%v>", msg, err)
+	}
+	wantLen := MaxBlockHeaderPayload + 8 + 1 + 2*CmpctBlockShortIDSize +
+		1 + 1 + block.Transactions[0].SerializeSize() +
+		1 + block.Transactions[2].SerializeSize()
+	if buf.Len() != wantLen {
+		t.Errorf("BtcEncode: wrong encoded length - got %v, want %v",
+			buf.Len(), wantLen)
+	}
+	encoded := buf.Bytes()
+	var readmsg MsgCmpctBlock
+	if err := readmsg.BtcDecode(&buf, pver); err != nil {
+		t.Fatalf("decode of MsgCmpctBlock failed [%v] err <%v>", buf,
+			err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Errorf("BtcDecode got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+
+	// Ensure prefilled transaction indexes which are not strictly
+	// increasing or are out of range are rejected.
+	msg.PrefilledTxns[1].Index = 0
+	if err := msg.BtcEncode(&buf, pver); err == nil {
+		t.Errorf("encode of MsgCmpctBlock with duplicate prefilled " +
+			"indexes succeeded when it should have failed")
+	}
+	msg.PrefilledTxns[1].Index = 4
+	if err := msg.BtcEncode(&buf, pver); err == nil {
+		t.Errorf("encode of MsgCmpctBlock with out of range prefilled " +
+			"index succeeded when it should have failed")
+	}
+
+	// Ensure a prefilled transaction index which is out of range is
+	// rejected when decoding.  The differential index of the second
+	// prefilled transaction immediately follows the coinbase.
+	invalid := make([]byte, len(encoded))
+	copy(invalid, encoded)
+	diffOffset := wantLen - block.Transactions[2].SerializeSize() - 1
+	invalid[diffOffset] = 0x03
+	err := readmsg.BtcDecode(bytes.NewReader(invalid), pver)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("decode of MsgCmpctBlock with out of range index - "+
+			"got %v, want MessageError", err)
+	}
+
+	// Ensure the message can not be encoded or decoded with protocol
+	// versions before compact blocks were added.
+	pver = CompactBlocksVersion - 1
+	if err := other.BtcEncode(&buf, pver); err == nil {
+		t.Errorf("encode of MsgCmpctBlock succeeded when it should " +
+			"have failed")
+	}
+	if err := readmsg.BtcDecode(bytes.NewReader(encoded), pver); err == nil {
+		t.Errorf("decode of MsgCmpctBlock succeeded when it should " +
+			"have failed")
+	}
+}
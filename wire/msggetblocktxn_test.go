@@ -0,0 +1,93 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestGetBlockTxn tests the MsgGetBlockTxn API and its wire encoding.
+func TestGetBlockTxn(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "getblocktxn"
+	hash := chainhash.Hash{0x01}
+	msg := NewMsgGetBlockTxn(&hash)
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgGetBlockTxn: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(32 + 9 + maxTxPerBlock*9)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Ensure the indexes are differentially encoded.
+	msg.Indexes = []uint32{0, 2, 3, 300}
+	wantBuf := append(hash[:],
+		0x04,             // Num indexes
+		0x00,             // Index 0
+		0x01,             // Index 2
+		0x00,             // Index 3
+		0xfd, 0x28, 0x01, // Index 300
+	)
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Errorf("encode of MsgGetBlockTxn failed %v err <%v>", msg, err)
+	}
+	if !bytes.Equal(buf.Bytes(), wantBuf) {
+		t.Errorf("BtcEncode got: %s want: %s", spew.Sdump(buf.Bytes()),
+			spew.Sdump(wantBuf))
+	}
+	var readmsg MsgGetBlockTxn
+	if err := readmsg.BtcDecode(&buf, pver); err != nil {
+		t.Errorf("decode of MsgGetBlockTxn failed [%v] err <%v>", buf,
+			err)
+	}
+	if !reflect.DeepEqual(&readmsg, msg) {
+		t.Errorf("BtcDecode got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+
+	// Ensure indexes which are not strictly increasing are rejected.
+	msg.Indexes = []uint32{2, 2}
+	if err := msg.BtcEncode(&buf, pver); err == nil {
+		t.Errorf("encode of MsgGetBlockTxn with duplicate indexes " +
+			"succeeded when it should have failed")
+	}
+
+	// Ensure indexes which overflow the max transactions per block are
+	// rejected.
+	overflow := append(hash[:], 0x02, 0x00, 0xfe, 0xff, 0xff, 0xff, 0xff)
+	err := readmsg.BtcDecode(bytes.NewReader(overflow), pver)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("decode of MsgGetBlockTxn with overflowing index - "+
+			"got %v, want MessageError", err)
+	}
+
+	// Ensure the message can not be encoded or decoded with protocol
+	// versions before compact blocks were added.
+	pver = CompactBlocksVersion - 1
+	msg.Indexes = nil
+	if err := msg.BtcEncode(&buf, pver); err == nil {
+		t.Errorf("encode of MsgGetBlockTxn succeeded when it should " +
+			"have failed")
+	}
+	if err := readmsg.BtcDecode(bytes.NewReader(wantBuf), pver); err == nil {
+		t.Errorf("decode of MsgGetBlockTxn succeeded when it should " +
+			"have failed")
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestGetCFilters tests the MsgGetCFilters and MsgGetCFHeaders API and their
+// wire encoding, which are the same apart from the command.
+func TestGetCFilters(t *testing.T) {
+	hash := blockOne.BlockHash()
+	tests := []struct {
+		msg     Message
+		readmsg Message
+		cmd     string
+	}{
+		{NewMsgGetCFilters(GCSFilterBasic, 1200, &hash),
+			&MsgGetCFilters{}, "getcfilters"},
+		{NewMsgGetCFHeaders(GCSFilterBasic, 1200, &hash),
+			&MsgGetCFHeaders{}, "getcfheaders"},
+	}
+
+	for i, test := range tests {
+		pver := ProtocolVersion
+
+		// Ensure the command is expected value.
+		if cmd := test.msg.Command(); cmd != test.cmd {
+			t.Errorf("#%d: wrong command - got %v want %v", i, cmd,
+				test.cmd)
+		}
+
+		// Ensure max payload is expected value.
+		wantPayload := uint32(1 + 4 + 32)
+		maxPayload := test.msg.MaxPayloadLength(pver)
+		if maxPayload != wantPayload {
+			t.Errorf("#%d: wrong max payload length for protocol "+
+				"version %d - got %v, want %v", i, pver,
+				maxPayload, wantPayload)
+		}
+
+		// Test encode and decode with latest protocol version.
+		var buf bytes.Buffer
+		if err := test.msg.BtcEncode(&buf, pver); err != nil {
+			t.Errorf("#%d: encode failed %v err <%v>", i, test.msg,
+				err)
+		}
+		want := append([]byte{0x00, 0xb0, 0x04, 0x00, 0x00}, hash[:]...)
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("#%d: wrong encoding - got %x, want %x", i,
+				buf.Bytes(), want)
+		}
+		if err := test.readmsg.BtcDecode(&buf, pver); err != nil {
+			t.Errorf("#%d: decode failed err <%v>", i, err)
+		}
+		if !reflect.DeepEqual(test.readmsg, test.msg) {
+			t.Errorf("#%d: BtcDecode got: %s want: %s", i,
+				spew.Sdump(test.readmsg), spew.Sdump(test.msg))
+		}
+
+		// Ensure the message can not be encoded or decoded with
+		// protocol versions before compact block filters were added.
+		pver = NodeCFVersion - 1
+		if err := test.msg.BtcEncode(&buf, pver); err == nil {
+			t.Errorf("#%d: encode succeeded when it should have "+
+				"failed", i)
+		}
+		err := test.readmsg.BtcDecode(bytes.NewReader(want), pver)
+		if err == nil {
+			t.Errorf("#%d: decode succeeded when it should have "+
+				"failed", i)
+		}
+	}
+}
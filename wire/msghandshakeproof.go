@@ -0,0 +1,57 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"io"
+)
+
+// HandshakeProofSize is the size of the proof carried by a hsproof message.
+const HandshakeProofSize = 32
+
+// MsgHandshakeProof implements the Message interface and represents a hsproof
+// message.  It is used by the peers of a private network to prove they know a
+// shared secret without sending it.  The proof is computed over the nonces of
+// the version messages of both peers, so it is only valid for the connection it
+// was made for.
+//
+// This message is only sent directly after the version messages have been
+// exchanged, and only to peers which announced in the user agent of their
+// version message that they send one as well.
+type MsgHandshakeProof struct {
+	Proof [HandshakeProofSize]byte
+}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgHandshakeProof) BtcDecode(r io.Reader, pver uint32) error {
+	_, err := io.ReadFull(r, msg.Proof[:])
+	return err
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgHandshakeProof) BtcEncode(w io.Writer, pver uint32) error {
+	_, err := w.Write(msg.Proof[:])
+	return err
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgHandshakeProof) Command() string {
+	return CmdHandshakeProof
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgHandshakeProof) MaxPayloadLength(pver uint32) uint32 {
+	return HandshakeProofSize
+}
+
+// NewMsgHandshakeProof returns a new hsproof message that conforms to the
+// Message interface.  See MsgHandshakeProof for details.
+func NewMsgHandshakeProof(proof [HandshakeProofSize]byte) *MsgHandshakeProof {
+	return &MsgHandshakeProof{Proof: proof}
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestHandshakeProof tests the MsgHandshakeProof API.
+func TestHandshakeProof(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "hsproof"
+	msg := NewMsgHandshakeProof([HandshakeProofSize]byte{0x01, 0x02})
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgHandshakeProof: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(32)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Test encode and decode.
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Errorf("encode of MsgHandshakeProof failed %v err <%v>", msg,
+			err)
+	}
+	if !bytes.Equal(buf.Bytes(), msg.Proof[:]) {
+		t.Errorf("encode of MsgHandshakeProof produced payload %v",
+			spew.Sdump(buf.Bytes()))
+	}
+	readmsg := &MsgHandshakeProof{}
+	if err := readmsg.BtcDecode(&buf, pver); err != nil {
+		t.Errorf("decode of MsgHandshakeProof failed [%v] err <%v>",
+			buf, err)
+	}
+	if !reflect.DeepEqual(readmsg, msg) {
+		t.Errorf("BtcDecode got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+
+	// Truncated proofs should fail to decode.
+	truncated := bytes.NewReader(msg.Proof[:HandshakeProofSize-1])
+	if err := readmsg.BtcDecode(truncated, pver); err == nil {
+		t.Errorf("decode of truncated MsgHandshakeProof passed")
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"fmt"
+	"io"
+)
+
+// MsgNoChecksum implements the Message interface and represents a nochecksum
+// message.  It is used to inform the remote peer that the sender no longer
+// verifies message checksums, so the remote peer may skip computing them for
+// all subsequent messages written to the sender.
+//
+// This message is only intended to be sent over local connections, such as
+// loopback or Unix sockets, where the payload can not be corrupted in transit.
+// It was not added until protocol version NoChecksumVersion.
+//
+// This message has no payload.
+type MsgNoChecksum struct{}
+
+// BtcDecode decodes r using the bitcoin protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgNoChecksum) BtcDecode(r io.Reader, pver uint32) error {
+	if pver < NoChecksumVersion {
+		str := fmt.Sprintf("nochecksum message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgNoChecksum.BtcDecode", str)
+	}
+
+	return nil
+}
+
+// BtcEncode encodes the receiver to w using the bitcoin protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgNoChecksum) BtcEncode(w io.Writer, pver uint32) error {
+	if pver < NoChecksumVersion {
+		str := fmt.Sprintf("nochecksum message invalid for protocol "+
+			"version %d", pver)
+		return messageError("MsgNoChecksum.BtcEncode", str)
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgNoChecksum) Command() string {
+	return CmdNoChecksum
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgNoChecksum) MaxPayloadLength(pver uint32) uint32 {
+	return 0
+}
+
+// NewMsgNoChecksum returns a new nochecksum message that conforms to the
+// Message interface.  See MsgNoChecksum for details.
+func NewMsgNoChecksum() *MsgNoChecksum {
+	return &MsgNoChecksum{}
+}
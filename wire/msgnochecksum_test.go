@@ -0,0 +1,67 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestNoChecksum tests the MsgNoChecksum API against the latest protocol
+// version and the protocol version prior to NoChecksumVersion.
+func TestNoChecksum(t *testing.T) {
+	pver := ProtocolVersion
+
+	// Ensure the command is expected value.
+	wantCmd := "nochecksum"
+	msg := NewMsgNoChecksum()
+	if cmd := msg.Command(); cmd != wantCmd {
+		t.Errorf("NewMsgNoChecksum: wrong command - got %v want %v",
+			cmd, wantCmd)
+	}
+
+	// Ensure max payload is expected value.
+	wantPayload := uint32(0)
+	maxPayload := msg.MaxPayloadLength(pver)
+	if maxPayload != wantPayload {
+		t.Errorf("MaxPayloadLength: wrong max payload length for "+
+			"protocol version %d - got %v, want %v", pver,
+			maxPayload, wantPayload)
+	}
+
+	// Test encode and decode with latest protocol version.
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, pver); err != nil {
+		t.Errorf("encode of MsgNoChecksum failed %v err <%v>", msg, err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("encode of MsgNoChecksum produced payload %v",
+			spew.Sdump(buf.Bytes()))
+	}
+	readmsg := NewMsgNoChecksum()
+	if err := readmsg.BtcDecode(&buf, pver); err != nil {
+		t.Errorf("decode of MsgNoChecksum failed [%v] err <%v>", buf,
+			err)
+	}
+	if !reflect.DeepEqual(readmsg, msg) {
+		t.Errorf("BtcDecode got: %s want: %s", spew.Sdump(readmsg),
+			spew.Sdump(msg))
+	}
+
+	// Older protocol versions should fail encode and decode since message
+	// didn't exist yet.
+	oldPver := NoChecksumVersion - 1
+	if err := msg.BtcEncode(&buf, oldPver); err == nil {
+		t.Errorf("encode of MsgNoChecksum passed for old protocol "+
+			"version %v", oldPver)
+	}
+	if err := readmsg.BtcDecode(&buf, oldPver); err == nil {
+		t.Errorf("decode of MsgNoChecksum passed for old protocol "+
+			"version %v", oldPver)
+	}
+}
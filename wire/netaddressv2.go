@@ -0,0 +1,270 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// MaxAddrV2Size is the maximum size of the address of a NetAddressV2.
+const MaxAddrV2Size = 512
+
+// NetworkID identifies the network of the address of a NetAddressV2 as
+// defined by BIP0155.
+type NetworkID uint8
+
+const (
+	// NetIPv4 identifies IPv4 addresses, which are 4 bytes.
+	NetIPv4 NetworkID = 1
+
+	// NetIPv6 identifies IPv6 addresses, which are 16 bytes.
+	NetIPv6 NetworkID = 2
+
+	// NetTorV2 identifies the addresses of version 2 Tor onion services,
+	// which are the 10 bytes of the hash of the service key.
+	NetTorV2 NetworkID = 3
+
+	// NetTorV3 identifies the addresses of version 3 Tor onion services,
+	// which are the 32 bytes of the service public key.
+	NetTorV3 NetworkID = 4
+
+	// NetI2P identifies I2P addresses, which are the 32 bytes of the hash
+	// of the destination.
+	NetI2P NetworkID = 5
+
+	// NetCJDNS identifies CJDNS addresses, which are 16 bytes IPv6
+	// addresses in the fc00::/8 range.
+	NetCJDNS NetworkID = 6
+)
+
+// netAddrV2Sizes maps the known networks to the size of their addresses.
+var netAddrV2Sizes = map[NetworkID]int{
+	NetIPv4:  4,
+	NetIPv6:  16,
+	NetTorV2: 10,
+	NetTorV3: 32,
+	NetI2P:   32,
+	NetCJDNS: 16,
+}
+
+// Map of network IDs back to their constant names for pretty printing.
+var networkIDStrings = map[NetworkID]string{
+	NetIPv4:  "IPv4",
+	NetIPv6:  "IPv6",
+	NetTorV2: "TorV2",
+	NetTorV3: "TorV3",
+	NetI2P:   "I2P",
+	NetCJDNS: "CJDNS",
+}
+
+// String returns the NetworkID in human-readable form.
+func (n NetworkID) String() string {
+	if s, ok := networkIDStrings[n]; ok {
+		return s
+	}
+	return fmt.Sprintf("Unknown NetworkID (%d)", uint8(n))
+}
+
+// onionCatPrefix is the IPv6 prefix addresses of version 2 Tor onion services
+// are encoded with in NetAddress.
+var onionCatPrefix = []byte{0xfd, 0x87, 0xd8, 0x7e, 0xeb, 0x43}
+
+// maxNetAddressV2Payload returns the max payload size for a NetAddressV2.
+func maxNetAddressV2Payload() uint32 {
+	// Timestamp 4 bytes + services varint + network 1 byte + address
+	// varbytes + port 2 bytes.
+	return 4 + MaxVarIntPayload + 1 + MaxVarIntPayload + MaxAddrV2Size + 2
+}
+
+// NetAddressV2 defines information about a peer on the network like
+// NetAddress, but with the address of any of the networks defined by BIP0155,
+// which includes networks whose addresses do not fit in a NetAddress.
+type NetAddressV2 struct {
+	// Last time the address was seen.  This is encoded as a uint32 on the
+	// wire and therefore is limited to 2106.
+	Timestamp time.Time
+
+	// Bitfield which identifies the services supported by the address.
+	Services ServiceFlag
+
+	// Network the address belongs to.
+	Network NetworkID
+
+	// Addr is the address of the peer, whose size depends on the network.
+	Addr []byte
+
+	// Port the peer is using.  This is encoded in big endian on the wire
+	// which differs from most everything else.
+	Port uint16
+}
+
+// HasService returns whether the specified service is supported by the address.
+func (na *NetAddressV2) HasService(service ServiceFlag) bool {
+	return na.Services&service == service
+}
+
+// AddService adds service as a supported service by the peer generating the
+// message.
+func (na *NetAddressV2) AddService(service ServiceFlag) {
+	na.Services |= service
+}
+
+// IP returns the IP address of addresses of the IPv4, IPv6 and CJDNS networks,
+// and nil for the addresses of other networks.
+func (na *NetAddressV2) IP() net.IP {
+	if len(na.Addr) != netAddrV2Sizes[na.Network] {
+		return nil
+	}
+	switch na.Network {
+	case NetIPv4:
+		return net.IPv4(na.Addr[0], na.Addr[1], na.Addr[2], na.Addr[3])
+	case NetIPv6, NetCJDNS:
+		return net.IP(append([]byte(nil), na.Addr...))
+	}
+	return nil
+}
+
+// ToLegacy returns the address as a NetAddress, which is only possible for the
+// addresses of the IPv4, IPv6 and version 2 Tor networks.  Version 2 onion
+// addresses are encoded in the OnionCat range.  It returns nil for the
+// addresses of other networks.
+func (na *NetAddressV2) ToLegacy() *NetAddress {
+	var ip net.IP
+	switch na.Network {
+	case NetIPv4, NetIPv6:
+		ip = na.IP()
+	case NetTorV2:
+		if len(na.Addr) == netAddrV2Sizes[NetTorV2] {
+			ip = make(net.IP, 0, net.IPv6len)
+			ip = append(ip, onionCatPrefix...)
+			ip = append(ip, na.Addr...)
+		}
+	}
+	if ip == nil {
+		return nil
+	}
+	return &NetAddress{
+		Timestamp: na.Timestamp,
+		Services:  na.Services,
+		IP:        ip,
+		Port:      na.Port,
+	}
+}
+
+// NewNetAddressV2 returns a new NetAddressV2 using the provided timestamp,
+// network, address, port, and supported services.  The timestamp is rounded to
+// single second precision.
+func NewNetAddressV2(timestamp time.Time, services ServiceFlag,
+	network NetworkID, addr []byte, port uint16) *NetAddressV2 {
+
+	// Limit the timestamp to one second precision since the protocol
+	// doesn't support better.
+	return &NetAddressV2{
+		Timestamp: time.Unix(timestamp.Unix(), 0),
+		Services:  services,
+		Network:   network,
+		Addr:      addr,
+		Port:      port,
+	}
+}
+
+// NewNetAddressV2FromLegacy returns the passed NetAddress as a NetAddressV2.
+// IPv4-mapped addresses belong to the IPv4 network and addresses in the
+// OnionCat range to the version 2 Tor network.
+func NewNetAddressV2FromLegacy(na *NetAddress) *NetAddressV2 {
+	network, addr := NetIPv6, []byte(na.IP.To16())
+	if ip4 := na.IP.To4(); ip4 != nil {
+		network, addr = NetIPv4, []byte(ip4)
+	} else if bytes.HasPrefix(addr, onionCatPrefix) {
+		network, addr = NetTorV2, addr[len(onionCatPrefix):]
+	}
+	return &NetAddressV2{
+		Timestamp: na.Timestamp,
+		Services:  na.Services,
+		Network:   network,
+		Addr:      append([]byte(nil), addr...),
+		Port:      na.Port,
+	}
+}
+
+// readNetAddressV2 reads an encoded NetAddressV2 from r.  The addresses of
+// unknown networks are read as is so they can be ignored by the caller, while
+// the addresses of known networks must have the size of the network.
+func readNetAddressV2(r io.Reader, pver uint32, na *NetAddressV2) error {
+	// NOTE: The bitcoin protocol uses a uint32 for the timestamp so it will
+	// stop working somewhere around 2106.
+	err := readElement(r, (*uint32Time)(&na.Timestamp))
+	if err != nil {
+		return err
+	}
+
+	services, err := ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	network, err := binarySerializer.Uint8(r)
+	if err != nil {
+		return err
+	}
+	addr, err := ReadVarBytes(r, pver, MaxAddrV2Size, "addrv2 address")
+	if err != nil {
+		return err
+	}
+	if size, ok := netAddrV2Sizes[NetworkID(network)]; ok && len(addr) != size {
+		str := fmt.Sprintf("invalid address size for network %v "+
+			"[size %d, want %d]", NetworkID(network), len(addr), size)
+		return messageError("readNetAddressV2", str)
+	}
+
+	// Sigh.  Bitcoin protocol mixes little and big endian.
+	port, err := binarySerializer.Uint16(r, bigEndian)
+	if err != nil {
+		return err
+	}
+
+	*na = NetAddressV2{
+		Timestamp: na.Timestamp,
+		Services:  ServiceFlag(services),
+		Network:   NetworkID(network),
+		Addr:      addr,
+		Port:      port,
+	}
+	return nil
+}
+
+// writeNetAddressV2 serializes a NetAddressV2 to w.
+func writeNetAddressV2(w io.Writer, pver uint32, na *NetAddressV2) error {
+	// NOTE: The bitcoin protocol uses a uint32 for the timestamp so it will
+	// stop working somewhere around 2106.
+	err := writeElement(w, uint32(na.Timestamp.Unix()))
+	if err != nil {
+		return err
+	}
+
+	err = WriteVarInt(w, pver, uint64(na.Services))
+	if err != nil {
+		return err
+	}
+	err = binarySerializer.PutUint8(w, uint8(na.Network))
+	if err != nil {
+		return err
+	}
+	if len(na.Addr) > MaxAddrV2Size {
+		str := fmt.Sprintf("address too long [size %d, max %d]",
+			len(na.Addr), MaxAddrV2Size)
+		return messageError("writeNetAddressV2", str)
+	}
+	err = WriteVarBytes(w, pver, na.Addr)
+	if err != nil {
+		return err
+	}
+
+	// Sigh.  Bitcoin protocol mixes little and big endian.
+	return binarySerializer.PutUint16(w, bigEndian, na.Port)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package wire
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// TestNetAddressV2Legacy ensures NetAddressV2 is converted from and to
+// NetAddress for the networks whose addresses fit in a NetAddress.
+func TestNetAddressV2Legacy(t *testing.T) {
+	ts := time.Unix(0x495fab29, 0)
+	tests := []struct {
+		legacy  string    // IP of the NetAddress
+		network NetworkID // Expected network
+		addr    []byte    // Expected address
+	}{
+		{"127.0.0.1", NetIPv4, []byte{0x7f, 0x00, 0x00, 0x01}},
+		{
+			"2001:db8::1",
+			NetIPv6,
+			[]byte{0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0,
+				0, 0, 0, 0x01},
+		},
+		{
+			"fd87:d87e:eb43:25de:b744:916d:e5c7:3e1a",
+			NetTorV2,
+			[]byte{0x25, 0xde, 0xb7, 0x44, 0x91, 0x6d, 0xe5, 0xc7,
+				0x3e, 0x1a},
+		},
+	}
+
+	for i, test := range tests {
+		na := NewNetAddressTimestamp(ts, SFNodeNetwork,
+			net.ParseIP(test.legacy), 8333)
+		na2 := NewNetAddressV2FromLegacy(na)
+		want := NewNetAddressV2(ts, SFNodeNetwork, test.network,
+			test.addr, 8333)
+		if !reflect.DeepEqual(na2, want) {
+			t.Errorf("NewNetAddressV2FromLegacy #%d\n got: %s want: %s",
+				i, spew.Sdump(na2), spew.Sdump(want))
+			continue
+		}
+
+		legacy := na2.ToLegacy()
+		if legacy == nil || !legacy.IP.Equal(na.IP) ||
+			legacy.Port != na.Port || legacy.Services != na.Services ||
+			!legacy.Timestamp.Equal(na.Timestamp) {
+			t.Errorf("ToLegacy #%d\n got: %s want: %s", i,
+				spew.Sdump(legacy), spew.Sdump(na))
+		}
+	}
+
+	// Addresses of networks which do not fit in a NetAddress.
+	for _, network := range []NetworkID{NetTorV3, NetI2P, NetCJDNS, 0x20} {
+		size := netAddrV2Sizes[network]
+		na := NewNetAddressV2(ts, SFNodeNetwork, network,
+			make([]byte, size), 8333)
+		if legacy := na.ToLegacy(); legacy != nil {
+			t.Errorf("ToLegacy: got %v for network %v, want nil",
+				legacy, network)
+		}
+	}
+}
+
+// TestNetworkIDStringer tests the stringized output for network IDs.
+func TestNetworkIDStringer(t *testing.T) {
+	tests := []struct {
+		in   NetworkID
+		want string
+	}{
+		{NetIPv4, "IPv4"},
+		{NetIPv6, "IPv6"},
+		{NetTorV2, "TorV2"},
+		{NetTorV3, "TorV3"},
+		{NetI2P, "I2P"},
+		{NetCJDNS, "CJDNS"},
+		{0xff, "Unknown NetworkID (255)"},
+	}
+
+	for i, test := range tests {
+		result := test.in.String()
+		if result != test.want {
+			t.Errorf("String #%d\n got: %s want: %s", i, result,
+				test.want)
+		}
+	}
+}
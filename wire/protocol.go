@@ -13,7 +13,7 @@ import (
 
 const (
 	// ProtocolVersion is the latest protocol version this package supports.
-	ProtocolVersion uint32 = 70013
+	ProtocolVersion uint32 = 70017
 
 	// MultipleAddressVersion is the protocol version which added multiple
 	// addresses per message (pver >= MultipleAddressVersion).
@@ -51,6 +51,25 @@ const (
 	// FeeFilterVersion is the protocol version which added a new
 	// feefilter message.
 	FeeFilterVersion uint32 = 70013
+
+	// NodeCFVersion is the protocol version which added the getcfilters,
+	// cfilter, getcfheaders and cfheaders messages used to serve compact
+	// block filters.
+	NodeCFVersion uint32 = 70014
+
+	// CompactBlocksVersion is the protocol version which added the
+	// sendcmpct, cmpctblock, getblocktxn and blocktxn messages used to
+	// relay compact blocks.
+	CompactBlocksVersion uint32 = 70015
+
+	// AddrV2Version is the protocol version which added the sendaddrv2
+	// and addrv2 messages used to relay addresses of networks which do
+	// not fit in the addr message (BIP0155).
+	AddrV2Version uint32 = 70016
+
+	// NoChecksumVersion is the protocol version which added the nochecksum
+	// message used to skip message checksums on local connections.
+	NoChecksumVersion uint32 = 70017
 )
 
 // ServiceFlag identifies services supported by a bitcoin peer.
@@ -67,6 +86,15 @@ const (
 	// SFNodeBloom is a flag used to indiciate a peer supports bloom
 	// filtering.
 	SFNodeBloom
+
+	// SFNodeCF is a flag used to indicate a peer serves compact block
+	// filters.  It uses the same bit as in bitcoin (BIP0157).
+	SFNodeCF ServiceFlag = 1 << 6
+
+	// SFNodeTLS is a flag used to indicate a peer accepts TLS-encrypted
+	// connections on its peer-to-peer port.  It uses a bit from the range
+	// bitcoin leaves to other uses, since the flag is specific to Prova.
+	SFNodeTLS ServiceFlag = 1 << 24
 )
 
 // Map of service flags back to their constant names for pretty printing.
@@ -74,6 +102,8 @@ var sfStrings = map[ServiceFlag]string{
 	SFNodeNetwork: "SFNodeNetwork",
 	SFNodeGetUTXO: "SFNodeGetUTXO",
 	SFNodeBloom:   "SFNodeBloom",
+	SFNodeCF:      "SFNodeCF",
+	SFNodeTLS:     "SFNodeTLS",
 }
 
 // orderedSFStrings is an ordered list of service flags from highest to
@@ -82,6 +112,8 @@ var orderedSFStrings = []ServiceFlag{
 	SFNodeNetwork,
 	SFNodeGetUTXO,
 	SFNodeBloom,
+	SFNodeCF,
+	SFNodeTLS,
 }
 
 // String returns the ServiceFlag in human-readable form.
@@ -0,0 +1,35 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+/*
+Package zmqpub implements a ZeroMQ publisher which notifies subscribers of the
+blocks connected to the main chain and the transactions accepted to the memory
+pool.
+
+The notifications follow the ones of Bitcoin Core, so existing ZeroMQ
+subscribers can consume them.  Each notification is a multipart message made up
+of the topic, the body and the sequence number of the notification for the
+topic as a 4-byte little-endian integer.  The supported topics are:
+
+  hashblock  the hash of each block connected to the main chain
+  rawblock   the serialized block
+  hashtx     the hash of each transaction accepted to the memory pool or
+             mined in a connected block
+  rawtx      the serialized transaction, including its signatures
+
+Hashes are sent in the byte order they are displayed in.
+
+Each topic is published on a TCP endpoint of its own, or on an endpoint shared
+with other topics.  The publisher is a PUB socket of libzmq, so any ZeroMQ SUB or
+XSUB socket can subscribe, and notifications for subscribers which do not keep
+up are dropped rather than slowing down the node.
+
+Publishing requires libzmq, which is linked through cgo by the pebbe/zmq4
+binding pinned in glide.yaml, so it is only available when built with the libzmq
+build tag, such as with `go install -tags libzmq . ./cmd/...`.  Without it,
+creating a publisher fails.  The tests with the libzmq build tag, which CI runs
+with libzmq installed, check the notifications with the SUB and XSUB sockets of
+libzmq.
+*/
+package zmqpub
@@ -0,0 +1,31 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmqpub
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseEndpoint returns the TCP address to listen on for the passed endpoint,
+// which must be of the form tcp://host:port.  As with ZeroMQ, a host of * binds
+// to all interfaces.
+func ParseEndpoint(endpoint string) (string, error) {
+	const scheme = "tcp://"
+	if !strings.HasPrefix(endpoint, scheme) {
+		return "", fmt.Errorf("unsupported ZeroMQ endpoint %q -- only "+
+			"tcp://host:port is supported", endpoint)
+	}
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(endpoint, scheme))
+	if err != nil {
+		return "", fmt.Errorf("invalid ZeroMQ endpoint %q: %v", endpoint,
+			err)
+	}
+	if host == "*" {
+		host = ""
+	}
+	return net.JoinHostPort(host, port), nil
+}
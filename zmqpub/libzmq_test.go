@@ -0,0 +1,175 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// This file is ignored during the regular tests due to the following build tag.
+// +build libzmq
+
+// The tests in this file publish notifications through libzmq to its SUB and
+// XSUB sockets, which requires libzmq and cgo.  They are run by a separate CI
+// job with:
+//
+//   go test -tags libzmq ./zmqpub/
+
+package zmqpub
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/bitgo/prova/provautil"
+	"github.com/bitgo/prova/wire"
+	zmq "github.com/pebbe/zmq4"
+)
+
+// newTestSocket returns a libzmq socket of the passed type connected to the
+// passed publisher.
+func newTestSocket(t *testing.T, p *Publisher, socketType zmq.Type) *zmq.Socket {
+	sock, err := zmq.NewSocket(socketType)
+	if err != nil {
+		t.Fatalf("unable to create socket: %v", err)
+	}
+	if err := sock.SetRcvtimeo(10 * time.Second); err != nil {
+		t.Fatalf("unable to set receive timeout: %v", err)
+	}
+	if err := sock.SetLinger(0); err != nil {
+		t.Fatalf("unable to set linger: %v", err)
+	}
+	if err := sock.Connect(p.Endpoint()); err != nil {
+		t.Fatalf("unable to connect to publisher: %v", err)
+	}
+	return sock
+}
+
+// recvMessage receives the next message of the passed socket.
+func recvMessage(t *testing.T, sock *zmq.Socket) [][]byte {
+	frames, err := sock.RecvMessageBytes(0)
+	if err != nil {
+		t.Fatalf("unable to receive message: %v", err)
+	}
+	return frames
+}
+
+// recvFirstMessage publishes with the passed function until the passed socket,
+// which just subscribed, receives a message and returns the last message it
+// received.  Subscriptions are sent asynchronously by libzmq, so messages
+// published before they reach the publisher are dropped.
+func recvFirstMessage(t *testing.T, sock *zmq.Socket, publish func()) [][]byte {
+	if err := sock.SetRcvtimeo(50 * time.Millisecond); err != nil {
+		t.Fatalf("unable to set receive timeout: %v", err)
+	}
+	defer sock.SetRcvtimeo(10 * time.Second)
+
+	var frames [][]byte
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		if time.Now().After(deadline) {
+			t.Fatal("subscriber did not receive any message")
+		}
+		publish()
+		var err error
+		frames, err = sock.RecvMessageBytes(0)
+		if err == nil {
+			break
+		}
+	}
+
+	// Skip the messages of any other attempts which are still queued.
+	for {
+		next, err := sock.RecvMessageBytes(0)
+		if err != nil {
+			return frames
+		}
+		frames = next
+	}
+}
+
+// checkMessage ensures the passed message has the passed topic and body and
+// returns its sequence number.
+func checkMessage(t *testing.T, frames [][]byte, topic string, body []byte) uint32 {
+	if len(frames) != 3 {
+		t.Fatalf("got message with %d frames, want 3", len(frames))
+	}
+	if string(frames[0]) != topic {
+		t.Fatalf("got topic %q, want %q", frames[0], topic)
+	}
+	if !bytes.Equal(frames[1], body) {
+		t.Fatalf("%s: got body %x, want %x", topic, frames[1], body)
+	}
+	if len(frames[2]) != 4 {
+		t.Fatalf("%s: got sequence %x, want 4 bytes", topic, frames[2])
+	}
+	return binary.LittleEndian.Uint32(frames[2])
+}
+
+// TestNotifier ensures libzmq SUB and XSUB sockets receive the notifications
+// of the topics they subscribed to with increasing sequence numbers per topic.
+func TestNotifier(t *testing.T) {
+	n, err := NewNotifier(map[string]string{
+		TopicHashBlock: "tcp://127.0.0.1:0",
+		TopicRawTx:     "tcp://127.0.0.1:0",
+	})
+	if err != nil {
+		t.Fatalf("NewNotifier: unexpected error: %v", err)
+	}
+	defer n.Close()
+	publishers := n.Publishers()
+	if publishers[TopicHashBlock] != publishers[TopicRawTx] {
+		t.Fatal("topics with the same endpoint do not share a publisher")
+	}
+	p := publishers[TopicHashBlock]
+
+	coinbase := wire.NewMsgTx(wire.TxVersion)
+	coinbase.AddTxIn(wire.NewTxIn(&wire.OutPoint{Index: wire.MaxPrevOutIndex},
+		nil))
+	coinbase.AddTxOut(wire.NewTxOut(5000, []byte{0x51}))
+	var msgBlock wire.MsgBlock
+	msgBlock.AddTransaction(coinbase)
+	block := provautil.NewBlock(&msgBlock)
+	tx := provautil.NewTx(coinbase)
+	var rawTx bytes.Buffer
+	coinbase.Serialize(&rawTx)
+
+	// Block hashes must be sent in the byte order they are displayed in.
+	hash := block.Hash()
+	displayed := make([]byte, len(hash))
+	for i := range hash {
+		displayed[i] = hash[len(hash)-1-i]
+	}
+
+	// Ensure a SUB socket receives the block hashes it subscribed to.
+	sub := newTestSocket(t, p, zmq.SUB)
+	defer sub.Close()
+	if err := sub.SetSubscribe(TopicHashBlock); err != nil {
+		t.Fatalf("unable to subscribe: %v", err)
+	}
+	frames := recvFirstMessage(t, sub, func() { n.BlockConnected(block) })
+	blockSeq := checkMessage(t, frames, TopicHashBlock, displayed)
+
+	// Ensure an XSUB socket, which subscribes with messages, receives the
+	// raw transactions it subscribed to.
+	xsub := newTestSocket(t, p, zmq.XSUB)
+	defer xsub.Close()
+	if _, err := xsub.SendBytes([]byte("\x01"+TopicRawTx), 0); err != nil {
+		t.Fatalf("unable to subscribe: %v", err)
+	}
+	frames = recvFirstMessage(t, xsub, func() { n.TransactionAccepted(tx) })
+	txSeq := checkMessage(t, frames, TopicRawTx, rawTx.Bytes())
+
+	// The sequence numbers increase per topic.  The transaction mined in
+	// the block counts for the sequence of the raw transactions, while the
+	// accepted transactions published in the mean time were not sent to
+	// the SUB socket which did not subscribe to them.
+	n.BlockConnected(block)
+	seq := checkMessage(t, recvMessage(t, sub), TopicHashBlock, displayed)
+	if seq != blockSeq+1 {
+		t.Errorf("got block hash sequence %d, want %d", seq, blockSeq+1)
+	}
+	seq = checkMessage(t, recvMessage(t, xsub), TopicRawTx, rawTx.Bytes())
+	if seq != txSeq+1 {
+		t.Errorf("got raw transaction sequence %d, want %d", seq,
+			txSeq+1)
+	}
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build !libzmq
+
+package zmqpub
+
+import "errors"
+
+// errNoLibzmq is returned when a publisher is created without libzmq.
+var errNoLibzmq = errors.New("ZeroMQ notifications require prova to be " +
+	"built with libzmq using the libzmq build tag")
+
+// Publisher is a ZeroMQ PUB socket bound to a TCP endpoint.  Publishing requires
+// libzmq, so publishers can't be created unless prova is built with the libzmq
+// build tag.
+type Publisher struct{}
+
+// Listen returns an error since publishing requires libzmq.
+func Listen(endpoint string) (*Publisher, error) {
+	if _, err := ParseEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+	return nil, errNoLibzmq
+}
+
+// Endpoint returns the endpoint the publisher is bound to.
+func (p *Publisher) Endpoint() string {
+	return ""
+}
+
+// Publish does nothing since publishers can't be created without libzmq.
+func (p *Publisher) Publish(frames ...[]byte) {}
+
+// Close does nothing since publishers can't be created without libzmq.
+func (p *Publisher) Close() error {
+	return nil
+}
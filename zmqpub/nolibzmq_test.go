@@ -0,0 +1,22 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build !libzmq
+
+package zmqpub
+
+import "testing"
+
+// TestNewNotifierNoLibzmq ensures notifiers can't be created for valid
+// endpoints when prova is built without libzmq.
+func TestNewNotifierNoLibzmq(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewNotifier(map[string]string{
+		TopicHashBlock: "tcp://127.0.0.1:0",
+	})
+	if err != errNoLibzmq {
+		t.Fatalf("NewNotifier: got error %v, want %v", err, errNoLibzmq)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmqpub
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/bitgo/prova/chaincfg/chainhash"
+	"github.com/bitgo/prova/provautil"
+)
+
+// The topics notifications are published on.
+const (
+	TopicHashBlock = "hashblock"
+	TopicRawBlock  = "rawblock"
+	TopicHashTx    = "hashtx"
+	TopicRawTx     = "rawtx"
+)
+
+// Notifier publishes notifications about blocks and transactions on the
+// endpoints configured for their topics.
+type Notifier struct {
+	mtx        sync.Mutex
+	publishers map[string]*Publisher
+	sequences  map[string]uint32
+}
+
+// NewNotifier returns a notifier which publishes the notifications of each of
+// the topics in the passed map on the endpoint it maps to.  Topics with the same
+// endpoint share a publisher.
+func NewNotifier(endpoints map[string]string) (*Notifier, error) {
+	n := &Notifier{
+		publishers: make(map[string]*Publisher, len(endpoints)),
+		sequences:  make(map[string]uint32, len(endpoints)),
+	}
+	byEndpoint := make(map[string]*Publisher, len(endpoints))
+	for topic, endpoint := range endpoints {
+		switch topic {
+		case TopicHashBlock, TopicRawBlock, TopicHashTx, TopicRawTx:
+		default:
+			n.Close()
+			return nil, fmt.Errorf("unknown ZeroMQ topic %q", topic)
+		}
+
+		p, ok := byEndpoint[endpoint]
+		if !ok {
+			var err error
+			p, err = Listen(endpoint)
+			if err != nil {
+				n.Close()
+				return nil, err
+			}
+			byEndpoint[endpoint] = p
+		}
+		n.publishers[topic] = p
+	}
+	return n, nil
+}
+
+// Publishers returns the publishers of the notifier keyed by topic.
+func (n *Notifier) Publishers() map[string]*Publisher {
+	publishers := make(map[string]*Publisher, len(n.publishers))
+	for topic, p := range n.publishers {
+		publishers[topic] = p
+	}
+	return publishers
+}
+
+// publish publishes the passed body on the passed topic along with the next
+// sequence number of the topic, unless no endpoint is configured for it.
+//
+// This function MUST be called with the notifier lock held.
+func (n *Notifier) publish(topic string, body []byte) {
+	p, ok := n.publishers[topic]
+	if !ok {
+		return
+	}
+	var seq [4]byte
+	binary.LittleEndian.PutUint32(seq[:], n.sequences[topic])
+	n.sequences[topic]++
+	p.Publish([]byte(topic), body, seq[:])
+}
+
+// hashBytes returns the passed hash in the byte order it is displayed in.
+func hashBytes(hash *chainhash.Hash) []byte {
+	buf := make([]byte, chainhash.HashSize)
+	for i, b := range hash {
+		buf[chainhash.HashSize-1-i] = b
+	}
+	return buf
+}
+
+// notifyTransaction publishes the hashtx and rawtx notifications of the passed
+// transaction.
+//
+// This function MUST be called with the notifier lock held.
+func (n *Notifier) notifyTransaction(tx *provautil.Tx) {
+	n.publish(TopicHashTx, hashBytes(tx.Hash()))
+	if _, ok := n.publishers[TopicRawTx]; ok {
+		var buf bytes.Buffer
+		buf.Grow(tx.MsgTx().SerializeSize())
+		if err := tx.MsgTx().Serialize(&buf); err != nil {
+			log.Errorf("Unable to serialize transaction %v: %v",
+				tx.Hash(), err)
+			return
+		}
+		n.publish(TopicRawTx, buf.Bytes())
+	}
+}
+
+// BlockConnected publishes the hashblock and rawblock notifications of the
+// passed block, which was connected to the main chain, followed by the hashtx
+// and rawtx notifications of its transactions.
+//
+// This function is safe for concurrent access.
+func (n *Notifier) BlockConnected(block *provautil.Block) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.publish(TopicHashBlock, hashBytes(block.Hash()))
+	if _, ok := n.publishers[TopicRawBlock]; ok {
+		serialized, err := block.Bytes()
+		if err != nil {
+			log.Errorf("Unable to serialize block %v: %v",
+				block.Hash(), err)
+		} else {
+			n.publish(TopicRawBlock, serialized)
+		}
+	}
+	for _, tx := range block.Transactions() {
+		n.notifyTransaction(tx)
+	}
+}
+
+// TransactionAccepted publishes the hashtx and rawtx notifications of the
+// passed transaction, which was accepted to the memory pool.
+//
+// This function is safe for concurrent access.
+func (n *Notifier) TransactionAccepted(tx *provautil.Tx) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	n.notifyTransaction(tx)
+}
+
+// Close closes the publishers of the notifier.
+func (n *Notifier) Close() {
+	closed := make(map[*Publisher]struct{}, len(n.publishers))
+	for _, p := range n.publishers {
+		if _, ok := closed[p]; ok {
+			continue
+		}
+		closed[p] = struct{}{}
+		if err := p.Close(); err != nil {
+			log.Warnf("Unable to close ZeroMQ publisher on %v: %v",
+				p.Endpoint(), err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package zmqpub
+
+import "testing"
+
+// TestNewNotifierErrors ensures notifiers are only created for known topics and
+// supported endpoints.
+func TestNewNotifierErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []map[string]string{
+		{"sequence": "tcp://127.0.0.1:0"},
+		{TopicHashTx: "ipc:///tmp/prova.sock"},
+		{TopicHashTx: "127.0.0.1:0"},
+	}
+	for _, endpoints := range tests {
+		if _, err := NewNotifier(endpoints); err == nil {
+			t.Errorf("NewNotifier(%v): no error", endpoints)
+		}
+	}
+}
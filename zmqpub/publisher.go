@@ -0,0 +1,99 @@
+// Copyright (c) 2017 BitGo
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// +build libzmq
+
+package zmqpub
+
+import (
+	"sync"
+
+	zmq "github.com/pebbe/zmq4"
+)
+
+// Publisher is a ZeroMQ PUB socket bound to a TCP endpoint.  It sends the
+// published messages to every connected subscriber which subscribed to a prefix
+// of the first frame of the message.
+type Publisher struct {
+	mtx      sync.Mutex
+	socket   *zmq.Socket
+	endpoint string
+}
+
+// Listen returns a new publisher bound to the passed endpoint, which must be of
+// the form tcp://host:port.
+func Listen(endpoint string) (*Publisher, error) {
+	if _, err := ParseEndpoint(endpoint); err != nil {
+		return nil, err
+	}
+
+	socket, err := zmq.NewSocket(zmq.PUB)
+	if err != nil {
+		return nil, err
+	}
+
+	// Discard the notifications which are still queued when the publisher
+	// is closed so they do not hold up the shutdown of the node.
+	if err := socket.SetLinger(0); err != nil {
+		socket.Close()
+		return nil, err
+	}
+	if err := socket.Bind(endpoint); err != nil {
+		socket.Close()
+		return nil, err
+	}
+	bound, err := socket.GetLastEndpoint()
+	if err != nil {
+		socket.Close()
+		return nil, err
+	}
+
+	return &Publisher{socket: socket, endpoint: bound}, nil
+}
+
+// Endpoint returns the endpoint the publisher is bound to, including the port
+// chosen by the system when the publisher was bound to port 0.
+func (p *Publisher) Endpoint() string {
+	return p.endpoint
+}
+
+// Publish sends the message made up of the passed frames to the subscribers
+// which subscribed to a prefix of the first frame.  As with any ZeroMQ PUB
+// socket, the message is dropped for subscribers which have too many messages
+// queued already.
+//
+// This function is safe for concurrent access.
+func (p *Publisher) Publish(frames ...[]byte) {
+	if len(frames) == 0 {
+		return
+	}
+	parts := make([]interface{}, 0, len(frames))
+	for _, frame := range frames {
+		parts = append(parts, frame)
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.socket == nil {
+		return
+	}
+	if _, err := p.socket.SendMessage(parts...); err != nil {
+		log.Warnf("Unable to publish %q message on %v: %v", frames[0],
+			p.endpoint, err)
+	}
+}
+
+// Close closes the socket of the publisher, which disconnects the subscribers.
+func (p *Publisher) Close() error {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.socket == nil {
+		return nil
+	}
+	err := p.socket.Close()
+	p.socket = nil
+	return err
+}